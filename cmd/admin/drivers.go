@@ -0,0 +1,7 @@
+package main
+
+// database/sql drivers for the index drivers "sqlite" and "postgres"
+import (
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
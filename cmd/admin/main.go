@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/cli"
+	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/justinabrahms/atchess/internal/firehose"
+	"github.com/justinabrahms/atchess/internal/identity"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// tool is filled in by init, since the commands look up their own usage
+var tool *cli.Tool[*config.Config]
+
+func init() {
+	tool = &cli.Tool[*config.Config]{
+		Name: "atchess-admin",
+		// Every command gets the protocol service's config
+		Commands: map[string]cli.Command[*config.Config]{
+			"games": {
+				Usage:   "games [--player DID]",
+				Summary: "List active games",
+				Run:     listGames,
+			},
+			"finalize": {
+				Usage:   "finalize [--result STATUS] GAME_URI",
+				Summary: "Finalize a stuck game from its moves, or force a result",
+				Run:     finalizeGame,
+			},
+			"reindex": {
+				Usage:   "reindex [--cursor SEQ] [--timeout DURATION]",
+				Summary: "Rebuild the game index by replaying the firehose",
+				Run:     reindex,
+			},
+			"purge-challenges": {
+				Usage:   "purge-challenges [--dry-run]",
+				Summary: "Delete expired challenges and challenge notifications",
+				Run:     purgeChallenges,
+			},
+			"inspect": {
+				Usage:   "inspect GAME_URI",
+				Summary: "Print a game's records from both players' repositories as JSON",
+				Run:     inspectGame,
+			},
+			"verify": {
+				Usage:   "verify [--player DID] [GAME_URI...]",
+				Summary: "Check games' records agree with each other",
+				Run:     verifyGames,
+			},
+		},
+		Order: []string{"games", "finalize", "reindex", "purge-challenges", "inspect", "verify"},
+		Help:  showHelpMessage,
+		Setup: loadConfig,
+	}
+}
+
+func main() {
+	tool.Main()
+}
+
+// loadConfig loads the protocol service's config
+func loadConfig(ctx context.Context) (*config.Config, error) {
+	// Output goes to stdout; logs go to stderr so output can be piped
+	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg, nil
+}
+
+// newClient logs in as the service account, as the protocol service does
+func newClient(cfg *config.Config) (*atproto.Client, error) {
+	client, err := atproto.NewClientWithDPoP(cfg.ATProto.PDSURL, cfg.ATProto.Handle, cfg.ATProto.ServicePassword(), cfg.ATProto.UseDPoP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in as %s: %w", cfg.ATProto.Handle, err)
+	}
+	client.SetRetryPolicy(atproto.RetryPolicy{
+		MaxAttempts: cfg.ATProto.Retry.MaxAttempts,
+		BaseDelay:   cfg.ATProto.Retry.BaseDelay,
+		MaxDelay:    cfg.ATProto.Retry.MaxDelay,
+	})
+	client.SetResolver(identity.New(identity.Options{
+		PDSURL:         cfg.ATProto.PDSURL,
+		PLCDirectories: cfg.Identity.PLCDirectories,
+		Timeout:        cfg.Identity.Timeout,
+		CacheSize:      cfg.Identity.CacheSize,
+		CacheTTL:       cfg.Identity.CacheTTL,
+	}))
+	return client, nil
+}
+
+// sharedIndex reports whether the index is kept somewhere other than the
+// protocol service's memory, where this process can read and write it
+func sharedIndex(cfg config.IndexConfig) bool {
+	return cfg.Driver != "" && cfg.Driver != "memory"
+}
+
+// openIndexStore opens the configured game index store
+func openIndexStore(ctx context.Context, cfg config.IndexConfig) (index.Store, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return index.OpenSQLStore(ctx, cfg.Driver, cfg.DSN)
+}
+
+// listGames lists active games from the shared index when there is one, and
+// otherwise from a player's repository
+func listGames(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := tool.FlagSet("games")
+	player := flags.String("player", "", "only games of this DID (default: every indexed game, or the service account's)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer out.Flush()
+	fmt.Fprintln(out, "GAME\tWHITE\tBLACK\tMOVES\tLAST ACTIVE")
+
+	if sharedIndex(cfg.Index) {
+		store, err := openIndexStore(ctx, cfg.Index)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		indexer := index.NewIndexer(store)
+		query := index.Query{Player: *player, Status: string(chess.StatusActive), Limit: index.MaxLimit}
+		for {
+			page, err := indexer.ListGames(ctx, query)
+			if err != nil {
+				return err
+			}
+			for _, game := range page.Games {
+				lastActive := game.CreatedAt
+				if game.LastMoveAt != nil {
+					lastActive = *game.LastMoveAt
+				}
+				fmt.Fprintf(out, "%s\t%s\t%s\t%d\t%s\n", game.URI, game.White, game.Black, game.MoveCount, lastActive.Format(time.RFC3339))
+			}
+			if page.Cursor == "" {
+				return nil
+			}
+			query.Cursor = page.Cursor
+		}
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	if *player == "" {
+		*player = client.GetDID()
+		log.Info().Str("player", *player).Msg("The index is in the protocol service's memory; listing the service account's games from its repository")
+	}
+	games, err := client.ListGames(ctx, *player, string(chess.StatusActive))
+	if err != nil {
+		return err
+	}
+	for _, game := range games {
+		// The repository doesn't say when the last move was made
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n", game.ID, game.White, game.Black, "-", game.CreatedAt)
+	}
+	return nil
+}
+
+// finalizeGame catches a game record up with moves that ended the game, and
+// forces a result on it if asked and its moves don't end it
+func finalizeGame(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := tool.FlagSet("finalize")
+	result := flags.String("result", "", "result to force if the moves don't end the game: white_won, black_won, draw or abandoned")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return cli.ErrUsage
+	}
+	gameURI := flags.Arg(0)
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	game, err := client.FinalizeGame(ctx, gameURI)
+	if err != nil {
+		return err
+	}
+	if game.Status == chess.StatusActive && *result != "" {
+		if game, err = client.SetGameResult(ctx, gameURI, chess.GameStatus(*result)); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%s: %s\n", gameURI, game.Status)
+	if game.Status == chess.StatusActive {
+		fmt.Fprintln(os.Stderr, "The moves don't end the game; pass --result to end it anyway")
+	}
+	return nil
+}
+
+// reindex replays the firehose from a cursor into the shared index until it
+// reaches events from after it started
+func reindex(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := tool.FlagSet("reindex")
+	cursor := flags.Int64("cursor", 0, "firehose sequence number to replay from (default: the oldest the relay holds)")
+	timeout := flags.Duration("timeout", 30*time.Minute, "give up after this long")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !sharedIndex(cfg.Index) {
+		return fmt.Errorf("the %q index driver keeps the index in the protocol service's memory; configure index.driver to rebuild it", cfg.Index.Driver)
+	}
+
+	store, err := openIndexStore(ctx, cfg.Index)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	indexer := index.NewIndexer(store)
+
+	started := time.Now()
+	var events int64
+	caughtUp := make(chan struct{})
+	var once sync.Once
+	handler := firehose.WithIndexer(indexer, func(event firehose.Event) error {
+		atomic.AddInt64(&events, 1)
+		if event.Timestamp.After(started) {
+			once.Do(func() { close(caughtUp) })
+		}
+		return nil
+	})
+
+	// A relay replays from its oldest event for cursor 0, which lastSequence
+	// treats as no cursor, so start from 1
+	opts := []firehose.Option{firehose.WithURL(cfg.Firehose.URL), firehose.WithCursor(max(*cursor, 1))}
+	client := firehose.NewClient(handler, opts...)
+	if err := client.Start(); err != nil {
+		return err
+	}
+	defer client.Stop()
+	log.Info().Str("url", cfg.Firehose.URL).Int64("cursor", max(*cursor, 1)).Msg("Replaying firehose into the index")
+
+	progress := time.NewTicker(10 * time.Second)
+	defer progress.Stop()
+	deadline := time.After(*timeout)
+	for {
+		select {
+		case <-caughtUp:
+			fmt.Printf("Caught up after indexing %d events\n", atomic.LoadInt64(&events))
+			return nil
+		case <-deadline:
+			fmt.Printf("Stopped after %s, having indexed %d events\n", *timeout, atomic.LoadInt64(&events))
+			return nil
+		case <-ctx.Done():
+			fmt.Printf("Interrupted after indexing %d events\n", atomic.LoadInt64(&events))
+			return nil
+		case <-progress.C:
+			log.Info().Int64("events", atomic.LoadInt64(&events)).Msg("Replaying")
+		}
+	}
+}
+
+// purgeChallenges deletes the service account's expired challenges
+func purgeChallenges(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := tool.FlagSet("purge-challenges")
+	dryRun := flags.Bool("dry-run", false, "list what would be deleted without deleting it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	purged, err := client.PurgeExpiredChallenges(ctx, time.Now(), *dryRun)
+	for _, uri := range purged {
+		fmt.Println(uri)
+	}
+	if err != nil {
+		return err
+	}
+	verb := "Deleted"
+	if *dryRun {
+		verb = "Would delete"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d expired records\n", verb, len(purged))
+	return nil
+}
+
+// inspectGame prints a game's records as stored
+func inspectGame(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := tool.FlagSet("inspect")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return cli.ErrUsage
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	records, err := client.InspectGame(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// verifyGames checks the given games, or every game of a player
+func verifyGames(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := tool.FlagSet("verify")
+	player := flags.String("player", "", "verify every game of this DID when no games are given (default: the service account)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	uris := flags.Args()
+	if len(uris) == 0 {
+		if *player == "" {
+			*player = client.GetDID()
+		}
+		games, err := client.ListGames(ctx, *player, "")
+		if err != nil {
+			return err
+		}
+		for _, game := range games {
+			uris = append(uris, game.ID)
+		}
+	}
+
+	failed := 0
+	for _, uri := range uris {
+		report, err := client.VerifyGame(ctx, uri)
+		if err != nil {
+			fmt.Printf("%s: %v\n", uri, err)
+			failed++
+			continue
+		}
+		if report.OK() {
+			fmt.Printf("%s: ok (%d moves, %s)\n", uri, report.Moves, report.Status)
+			continue
+		}
+		failed++
+		fmt.Printf("%s:\n", uri)
+		for _, problem := range report.Problems {
+			fmt.Printf("  - %s\n", problem)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d games have problems\n", failed, len(uris))
+	if failed > 0 {
+		return cli.ErrReported
+	}
+	return nil
+}
+
+func showHelpMessage() {
+	fmt.Println(`ATChess Admin
+
+DESCRIPTION:
+    Operations tool for the ATChess protocol service. It reads the same
+    config.yaml and logs in as the same service account, so it can only
+    change records in the service account's repository.
+
+USAGE:
+    atchess-admin COMMAND [OPTIONS]
+
+COMMANDS:`)
+	tool.PrintCommands(os.Stdout)
+	fmt.Println(`
+    Run atchess-admin COMMAND --help for a command's options.
+
+NOTES:
+    games and reindex use the game index when index.driver is a database.
+    With the default memory index, games lists the service account's games
+    from its repository, and reindex has nothing to rebuild.
+
+    reindex replays the firehose from --cursor until it sees events from
+    after it started. Relays only keep recent events, so older games must
+    be backfilled some other way.
+
+EXAMPLES:
+    # Find games whose records disagree
+    atchess-admin verify
+
+    # End a game whose opponent's mating move our record missed
+    atchess-admin finalize at://did:plc:.../app.atchess.game/...
+
+    # See what would be purged
+    atchess-admin purge-challenges --dry-run
+
+SEE ALSO:
+    atchess-protocol(1), config.yaml(5)`)
+}
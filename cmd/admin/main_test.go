@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/cli"
+	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+// run runs an atchess-admin command and returns what it printed to stdout
+func run(t *testing.T, cfg *config.Config, args ...string) (string, error) {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+	err = tool.Commands[args[0]].Run(context.Background(), cfg, args[1:])
+	writer.Close()
+	return <-output, err
+}
+
+func TestGamesListsActiveGamesFromTheIndex(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{Index: config.IndexConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "index.db")}}
+
+	store, err := index.OpenSQLStore(ctx, cfg.Index.Driver, cfg.Index.DSN)
+	if err != nil {
+		t.Fatalf("Failed to open the index: %v", err)
+	}
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, game := range []*index.Game{
+		{URI: "at://did:plc:alice/app.atchess.game/1", White: "did:plc:alice", Black: "did:plc:bob", Status: "active", CreatedAt: created},
+		{URI: "at://did:plc:alice/app.atchess.game/2", White: "did:plc:alice", Black: "did:plc:bob", Status: "draw", CreatedAt: created},
+		{URI: "at://did:plc:carol/app.atchess.game/3", White: "did:plc:carol", Black: "did:plc:dave", Status: "active", CreatedAt: created},
+	} {
+		if err := store.PutGame(ctx, game); err != nil {
+			t.Fatalf("Failed to index %s: %v", game.URI, err)
+		}
+	}
+	store.Close()
+
+	output, err := run(t, cfg, "games")
+	if err != nil {
+		t.Fatalf("games failed: %v", err)
+	}
+	if !strings.Contains(output, "app.atchess.game/1") || !strings.Contains(output, "app.atchess.game/3") || strings.Contains(output, "app.atchess.game/2") {
+		t.Errorf("Expected the active games, got:\n%s", output)
+	}
+
+	output, err = run(t, cfg, "games", "--player", "did:plc:carol")
+	if err != nil {
+		t.Fatalf("games failed: %v", err)
+	}
+	if !strings.Contains(output, "app.atchess.game/3") || strings.Contains(output, "app.atchess.game/1") {
+		t.Errorf("Expected only carol's game, got:\n%s", output)
+	}
+}
+
+func TestReindexNeedsADatabase(t *testing.T) {
+	cfg := &config.Config{Index: config.IndexConfig{Driver: "memory"}}
+	if _, err := run(t, cfg, "reindex"); err == nil || !strings.Contains(err.Error(), "index.driver") {
+		t.Errorf("Expected reindex to refuse the memory index, got %v", err)
+	}
+}
+
+func TestCommandsCheckTheirArguments(t *testing.T) {
+	for _, name := range []string{"finalize", "inspect"} {
+		if _, err := run(t, &config.Config{}, name); !errors.Is(err, cli.ErrUsage) {
+			t.Errorf("Expected %s without a game to print its usage, got %v", name, err)
+		}
+	}
+}
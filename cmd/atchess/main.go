@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/justinabrahms/atchess/internal/apiclient"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/cli"
+	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/justinabrahms/atchess/internal/tui"
+)
+
+// tool is filled in by init, since the commands look up their own usage
+var tool *cli.Tool[*apiclient.Client]
+
+func init() {
+	tool = &cli.Tool[*apiclient.Client]{
+		Name: "atchess",
+		// Every command gets a client logged in as the player
+		Commands: map[string]cli.Command[*apiclient.Client]{
+			"games": {
+				Usage:   "games [--status active|finished] [--json]",
+				Summary: "List your games",
+				Run:     listGames,
+			},
+			"show": {
+				Usage:   "show [--json] GAME",
+				Summary: "Show a game's board",
+				Run:     showGame,
+			},
+			"move": {
+				Usage:   "move GAME MOVE",
+				Summary: "Play a move in SAN (Nf3) or UCI (g1f3) notation",
+				Run:     makeMove,
+			},
+			"challenge": {
+				Usage:   "challenge [--color white|black|random] [--message TEXT] HANDLE",
+				Summary: "Challenge a player by handle or DID",
+				Run:     challenge,
+			},
+			"resign": {
+				Usage:   "resign GAME",
+				Summary: "Resign a game",
+				Run:     resign,
+			},
+			"export-pgn": {
+				Usage:   "export-pgn [-o FILE] GAME",
+				Summary: "Print a game as PGN",
+				Run:     exportPGN,
+			},
+		},
+		Order: []string{"games", "show", "move", "challenge", "resign", "export-pgn"},
+		Help:  showHelpMessage,
+		Setup: login,
+	}
+}
+
+func main() {
+	tool.Main()
+}
+
+// login logs in with the credentials from the client config
+func login(ctx context.Context) (*apiclient.Client, error) {
+	cfg, err := config.LoadClient(os.Getenv("ATCHESS_CONFIG"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Handle == "" || cfg.Password == "" {
+		return nil, fmt.Errorf("no credentials; set handle and password in %s or ATCHESS_HANDLE and ATCHESS_PASSWORD", config.DefaultClientConfigPath())
+	}
+
+	client := apiclient.New(cfg.Server)
+	session, err := client.Login(ctx, cfg.Handle, cfg.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in as %s: %w", cfg.Handle, err)
+	}
+	if session.Warning != "" {
+		fmt.Fprintln(os.Stderr, "Warning:", session.Warning)
+	}
+	return client, nil
+}
+
+// resolveGame turns a game argument into its AT URI. Besides a URI, a game
+// can be given by its record key, as found at the end of its URI.
+func resolveGame(ctx context.Context, client *apiclient.Client, ref string) (string, error) {
+	if strings.HasPrefix(ref, "at://") {
+		return ref, nil
+	}
+	games, err := client.ListGames(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	for _, game := range games {
+		if strings.HasSuffix(game.ID, "/"+ref) {
+			return game.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no game of yours has the key %q", ref)
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func listGames(ctx context.Context, client *apiclient.Client, args []string) error {
+	flags := tool.FlagSet("games")
+	status := flags.String("status", "", "only active or finished games")
+	asJSON := flags.Bool("json", false, "print the games as JSON")
+	if _, err := cli.ParseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	games, err := client.ListGames(ctx, *status)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if games == nil {
+			games = []*chess.Game{}
+		}
+		return printJSON(games)
+	}
+
+	me := client.Session().DID
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer out.Flush()
+	fmt.Fprintln(out, "GAME\tCOLOR\tOPPONENT\tSTATUS\tTO MOVE")
+	for _, game := range games {
+		color, opponent := "white", game.Black
+		if game.Black == me {
+			color, opponent = "black", game.White
+		}
+		toMove := "-"
+		if game.Status == chess.StatusActive {
+			if side, err := game.SideToMove(); err == nil {
+				toMove = side
+			}
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n", game.ID, color, opponent, game.Status, toMove)
+	}
+	return nil
+}
+
+func showGame(ctx context.Context, client *apiclient.Client, args []string) error {
+	flags := tool.FlagSet("show")
+	asJSON := flags.Bool("json", false, "print the game as JSON")
+	args, err := cli.ParseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	uri, err := resolveGame(ctx, client, args[0])
+	if err != nil {
+		return err
+	}
+	game, err := client.GetGame(ctx, uri)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(game)
+	}
+
+	perspective := "white"
+	if game.Black == client.Session().DID {
+		perspective = "black"
+	}
+	board, err := tui.RenderBoard(game.FEN, perspective)
+	if err != nil {
+		return err
+	}
+	fmt.Print(board)
+	fmt.Printf("%s\nStatus: %s\nFEN: %s\n", game.ID, game.Status, game.FEN)
+	return nil
+}
+
+func makeMove(ctx context.Context, client *apiclient.Client, args []string) error {
+	args, err := cli.ParseArgs(tool.FlagSet("move"), args, 2)
+	if err != nil {
+		return err
+	}
+	uri, err := resolveGame(ctx, client, args[0])
+	if err != nil {
+		return err
+	}
+	// Scripts move without having seen the board, so the move isn't tied to
+	// a position; it's checked against whatever the game's position is
+	result, err := client.Move(ctx, uri, "", args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%s\n", result.SAN, result.FEN)
+	if result.GameOver {
+		fmt.Printf("Game over: %s\n", result.Result)
+	}
+	return nil
+}
+
+func challenge(ctx context.Context, client *apiclient.Client, args []string) error {
+	flags := tool.FlagSet("challenge")
+	color := flags.String("color", "random", "the color you play: white, black or random")
+	message := flags.String("message", "", "a message for your opponent")
+	args, err := cli.ParseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	challenge, err := client.Challenge(ctx, args[0], *color, *message)
+	if err != nil {
+		return err
+	}
+	fmt.Println(challenge.ID)
+	return nil
+}
+
+func resign(ctx context.Context, client *apiclient.Client, args []string) error {
+	args, err := cli.ParseArgs(tool.FlagSet("resign"), args, 1)
+	if err != nil {
+		return err
+	}
+	uri, err := resolveGame(ctx, client, args[0])
+	if err != nil {
+		return err
+	}
+	return client.Resign(ctx, uri)
+}
+
+func exportPGN(ctx context.Context, client *apiclient.Client, args []string) error {
+	flags := tool.FlagSet("export-pgn")
+	output := flags.String("o", "", "write the PGN to FILE instead of stdout")
+	args, err := cli.ParseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	uri, err := resolveGame(ctx, client, args[0])
+	if err != nil {
+		return err
+	}
+	pgn, err := client.ExportPGN(ctx, uri)
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		return os.WriteFile(*output, []byte(pgn), 0o644)
+	}
+	fmt.Print(pgn)
+	return nil
+}
+
+func showHelpMessage() {
+	fmt.Println(`ATChess Command-Line Client
+
+DESCRIPTION:
+    Carries out single actions as a player through the protocol service's
+    API, for scripting and prototyping bots. Games are given by their AT URI
+    or the record key at the end of it.
+
+USAGE:
+    atchess COMMAND [OPTIONS] [ARGS]
+
+COMMANDS:`)
+	tool.PrintCommands(os.Stdout)
+	fmt.Printf(`
+    Run atchess COMMAND --help for a command's options.
+
+CONFIGURATION:
+    Credentials are read from %s
+    (or the file named by ATCHESS_CONFIG), overridden by the environment:
+        server: http://localhost:8080/api/v1   # ATCHESS_SERVER
+        handle: player1.test                   # ATCHESS_HANDLE
+        password: abcd-efgh-ijkl-mnop          # ATCHESS_PASSWORD, an app password
+
+EXIT STATUS:
+    0 on success, 1 when the command fails, 2 on a usage error.
+
+EXAMPLES:
+    # Play 1. e4 in your first active game
+    game=$(atchess games --status active --json | jq -r '.[0].id')
+    atchess move "$game" e2e4
+
+    # Challenge a player to a game as white
+    atchess challenge --color white --message "Good luck!" alice.bsky.social
+
+    # Save a game as PGN
+    atchess export-pgn -o game.pgn 3kxyz2abc
+
+SEE ALSO:
+    atchess-tui(1), atchess-protocol(1)
+`, config.DefaultClientConfigPath())
+}
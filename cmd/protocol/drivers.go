@@ -0,0 +1,8 @@
+package main
+
+// database/sql drivers for the index, scheduler and session storage drivers
+// "sqlite" and "postgres"
+import (
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
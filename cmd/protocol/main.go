@@ -7,14 +7,34 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/bot"
 	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/justinabrahms/atchess/internal/faults"
+	"github.com/justinabrahms/atchess/internal/federation"
 	"github.com/justinabrahms/atchess/internal/firehose"
+	"github.com/justinabrahms/atchess/internal/identity"
+	"github.com/justinabrahms/atchess/internal/importer"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/mail"
+	"github.com/justinabrahms/atchess/internal/oauth"
+	"github.com/justinabrahms/atchess/internal/pubsub"
+	"github.com/justinabrahms/atchess/internal/push"
+	"github.com/justinabrahms/atchess/internal/puzzle"
+	"github.com/justinabrahms/atchess/internal/rating"
+	"github.com/justinabrahms/atchess/internal/routes"
+	"github.com/justinabrahms/atchess/internal/scheduler"
+	"github.com/justinabrahms/atchess/internal/study"
+	"github.com/justinabrahms/atchess/internal/tlsserver"
+	"github.com/justinabrahms/atchess/internal/tracing"
 	"github.com/justinabrahms/atchess/internal/web"
+	"github.com/justinabrahms/atchess/internal/webhook"
+	webui "github.com/justinabrahms/atchess/web"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -22,8 +42,11 @@ import (
 func main() {
 	// Parse command line flags
 	var showHelp bool
+	var staticDir string
 	flag.BoolVar(&showHelp, "help", false, "Show help information")
 	flag.BoolVar(&showHelp, "h", false, "Show help information")
+	flag.StringVar(&staticDir, "static-dir", "", "Serve the web interface from a directory instead of the built-in files")
+	configFlags := config.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
 	if showHelp {
@@ -34,33 +57,225 @@ func main() {
 	// Setup logging
 	log.Logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
 	
-	// Load config
-	cfg, err := config.Load()
+	// Load config; problems are printed plainly since there may be several
+	cfg, err := config.LoadOptions(configFlags.Options())
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to load config")
+		fmt.Fprintf(os.Stderr, "atchess-protocol: %v\n", err)
+		os.Exit(1)
+	}
+	if configFlags.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal().Err(err).Msg("Failed to print config")
+		}
+		return
+	}
+	
+	// Export traces of API requests, PDS calls and firehose events
+	if cfg.Tracing.Enabled {
+		shutdown, err := tracing.Setup(context.Background(), cfg.Tracing)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to set up tracing")
+		} else {
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := shutdown(ctx); err != nil {
+					log.Error().Err(err).Msg("Failed to flush traces")
+				}
+			}()
+			log.Info().Str("endpoint", cfg.Tracing.Endpoint).Float64("sampleRatio", cfg.Tracing.SampleRatio).Msg("Tracing enabled")
+		}
 	}
 	
 	// Create AT Protocol client
 	client, err := atproto.NewClientWithDPoP(
 		cfg.ATProto.PDSURL,
 		cfg.ATProto.Handle,
-		cfg.ATProto.Password,
+		cfg.ATProto.ServicePassword(),
 		cfg.ATProto.UseDPoP,
 	)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create AT Protocol client")
 	}
+	if client.UsesMainPassword() {
+		log.Warn().Str("handle", cfg.ATProto.Handle).Msg("The service account logged in with its main password; create an app password and set atproto.app_password instead")
+	}
+	client.SetRetryPolicy(atproto.RetryPolicy{
+		MaxAttempts: cfg.ATProto.Retry.MaxAttempts,
+		BaseDelay:   cfg.ATProto.Retry.BaseDelay,
+		MaxDelay:    cfg.ATProto.Retry.MaxDelay,
+	})
+	client.SetListPageSize(cfg.ATProto.ListPageSize)
+	// Cache records such as games between reads; the cache is shared with
+	// every signed-in player's client so their writes keep it current
+	if cfg.ATProto.RecordCache.Size > 0 {
+		client.SetRecordCache(atproto.NewRecordCache(cfg.ATProto.RecordCache.Size, cfg.ATProto.RecordCache.TTL))
+	}
+	// Draw offers lapse unanswered after this long
+	client.SetDrawOfferTimeout(cfg.DrawOffers.Timeout)
+	// Cap the unanswered challenges one player can send another
+	client.SetChallengeCap(cfg.Challenges.MaxPendingPerOpponent)
+	// Mark games played by bot accounts, and open the bot API to them
+	client.SetBotAccounts(cfg.BotAPI.Accounts)
+	// Resolve handles and DIDs through the configured PLC directories; the
+	// resolver is shared with every signed-in player's client
+	client.SetResolver(identity.New(identity.Options{
+		PDSURL:         cfg.ATProto.PDSURL,
+		PLCDirectories: cfg.Identity.PLCDirectories,
+		Timeout:        cfg.Identity.Timeout,
+		CacheSize:      cfg.Identity.CacheSize,
+		CacheTTL:       cfg.Identity.CacheTTL,
+	}))
+	
+	// Development-only fault injection
+	var injector *faults.Injector
+	if cfg.Development.FaultInjection.Enabled {
+		if cfg.Development.Debug {
+			injector = faults.New(cfg.Development.FaultInjection)
+			client.WrapTransport(injector.Transport)
+			log.Warn().
+				Dur("latency", cfg.Development.FaultInjection.Latency).
+				Float64("pdsErrorRate", cfg.Development.FaultInjection.PDSErrorRate).
+				Dur("firehoseDisconnectInterval", cfg.Development.FaultInjection.FirehoseDisconnectInterval).
+				Msg("Fault injection enabled")
+		} else {
+			log.Warn().Msg("Ignoring fault_injection because development.debug is disabled")
+		}
+	}
 	
 	// Create WebSocket hub
 	hub := web.NewHub()
 	go hub.Run()
 	
+	// Share updates with the other replicas behind the load balancer
+	switch cfg.Broker.Driver {
+	case "", "memory":
+	case "redis":
+		broker, err := pubsub.NewRedis(cfg.Broker.RedisURL, cfg.Broker.Channel)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create Redis broker")
+		}
+		defer broker.Close()
+		hub.UseBroker(context.Background(), broker)
+		log.Info().Str("channel", cfg.Broker.Channel).Msg("Sharing WebSocket updates through Redis")
+	default:
+		log.Fatal().Str("driver", cfg.Broker.Driver).Msg("Unknown broker driver")
+	}
+	
 	// Create service
 	service := web.NewService(client, cfg)
 	
+	// User sessions are kept with OAuth sessions and pending logins, so
+	// they survive restarts and every replica knows them
+	sessionStorage, sessionKeys, err := openOAuthStorage(cfg.OAuth)
+	if err != nil {
+		log.Fatal().Err(err).Str("driver", cfg.OAuth.Driver).Msg("Failed to open session storage")
+	}
+	defer sessionStorage.Close()
+	service.SetSessionStorage(sessionStorage, sessionKeys)
+	service.Sessions().StartCleanupRoutine()
+	if err := service.LoadAnnouncements(context.Background()); err != nil {
+		log.Error().Err(err).Msg("Failed to load published announcements")
+	}
+	// Finalize games whose clocks ran out and expire challenges on time,
+	// rather than when someone next looks
+	var sched *scheduler.Scheduler
+	if cfg.Scheduler.Enabled {
+		// Falling back to memory would drop every clock and expiry on the
+		// next restart, so a configured database must open
+		jobs, err := openSchedulerStore(cfg.Scheduler)
+		if err != nil {
+			log.Fatal().Err(err).Str("driver", cfg.Scheduler.Driver).Msg("Failed to open scheduler store")
+		}
+		defer jobs.Close()
+		sched = scheduler.New(jobs, scheduler.Options{PollInterval: cfg.Scheduler.PollInterval})
+		service.SetScheduler(sched, cfg.Scheduler.DeadlineSyncInterval)
+	} else {
+		service.StartOfferSweeper()
+		service.StartChallengeCleanup()
+	}
+	// Deliver challenges, draw offers and "your move" alerts to players' own connections
+	service.SetHub(hub)
+	// Shared analysis boards, edited together over the hub
+	service.SetStudies(study.NewStudies(service))
+	// Bring games played on Lichess and Chess.com in through their public APIs
+	service.SetImporter(importer.NewFetcher(importer.LichessURL, importer.ChessComURL))
+	// Import uploaded PGN files in the background
+	pgnImports := web.NewPGNImports()
+	service.SetPGNImports(pgnImports)
+	go pgnImports.Run(context.Background())
+	if injector != nil {
+		service.SetClientTransportWrapper(injector.Transport)
+	}
+	
+	// Stream engine analysis to spectators if enabled
+	if cfg.Spectator.KibitzEnabled {
+		service.SetKibitzer(web.NewKibitzer(hub, client.GetGame, cfg.Spectator.KibitzDepth, cfg.Spectator.KibitzInterval))
+		log.Info().Int("depth", cfg.Spectator.KibitzDepth).Msg("Kibitz analysis enabled for spectators")
+	}
+	
+	// Puzzles are searched for with the built-in move generator, or with a
+	// UCI engine of their own when the bot is enabled
+	var puzzleSolver puzzle.Solver = puzzle.BuiltinSolver{}
+	// Anti-cheat analysis gets an engine of its own, so it doesn't hold up
+	// analysis players ask for
+	var cheatAnalyzer *web.GameAnalyzer
+	
+	// Let players take on a UCI engine such as Stockfish
+	if cfg.Bot.Enabled {
+		engine := bot.NewEngine(cfg.Bot.EnginePath)
+		defer engine.Close()
+		player := web.NewBotPlayer(hub, engine, client, cfg.Bot.ThinkTime)
+		if cfg.Bot.OpeningBook {
+			player.SetOpeningBook(bot.DefaultBook(), cfg.Bot.BookMoves)
+		}
+		service.SetBot(player)
+		solverEngine := bot.NewEngine(cfg.Bot.EnginePath)
+		defer solverEngine.Close()
+		puzzleSolver = solverEngine
+		analysisEngine := bot.NewEngine(cfg.Bot.EnginePath)
+		defer analysisEngine.Close()
+		service.SetAnalyzer(web.NewGameAnalyzer(analysisEngine, filepath.Base(cfg.Bot.EnginePath), cfg.Bot.AnalysisDepth))
+		if cfg.AntiCheat.Enabled {
+			cheatEngine := bot.NewEngine(cfg.Bot.EnginePath)
+			defer cheatEngine.Close()
+			cheatAnalyzer = web.NewGameAnalyzer(cheatEngine, filepath.Base(cfg.Bot.EnginePath), cfg.Bot.AnalysisDepth)
+		}
+		log.Info().Str("engine", cfg.Bot.EnginePath).Msg("Bot opponent enabled")
+	}
+	
+	// Pair players waiting in the matchmaking queue
+	service.SetMatchmaker(web.NewMatchmaker(cfg.Matchmaking.RatingBand, cfg.Matchmaking.WidenAfter))
+	service.StartMatchmaking(context.Background(), cfg.Matchmaking.PairInterval)
+	
+	// Email players who opt in about games waiting on them
+	if cfg.Email.Enabled {
+		sender := &mail.SMTP{
+			Host:     cfg.Email.SMTPHost,
+			Port:     cfg.Email.SMTPPort,
+			Username: cfg.Email.Username,
+			Password: cfg.Email.Password,
+			From:     cfg.Email.From,
+		}
+		digest := web.NewEmailDigest(sender, client, web.DigestOptions{
+			Interval:      cfg.Email.DigestInterval,
+			PendingAfter:  cfg.Email.PendingAfter,
+			ExpiryWarning: cfg.Email.ExpiryWarning,
+			BaseURL:       cfg.Server.BaseURL,
+		})
+		digest.Start(context.Background())
+		service.SetEmailDigest(digest)
+		log.Info().Str("smtpHost", cfg.Email.SMTPHost).Msg("Email digests enabled")
+	}
+	
+	// Announce, pause or forfeit when a player drops out of a live game
+	connections := web.NewConnectionMonitor(hub, client.GetGame, service.ForfeitDisconnected, cfg.LiveGames.DisconnectPolicy, cfg.LiveGames.GracePeriod)
+	hub.OnPresence(connections.PlayerPresence)
+	service.SetConnectionMonitor(connections)
+	
 	// Initialize OAuth if base URL is configured
 	if cfg.Server.BaseURL != "" {
-		if err := web.InitializeOAuth(cfg.Server.BaseURL); err != nil {
+		if err := web.InitializeOAuth(cfg.Server.BaseURL, sessionStorage, sessionKeys); err != nil {
 			log.Error().Err(err).Msg("Failed to initialize OAuth, falling back to password auth")
 		} else {
 			// Pass OAuth client to service for dynamic metadata
@@ -68,14 +283,151 @@ func main() {
 		}
 	}
 	
+	// Publish this instance and handshake with configured peers
+	if cfg.Federation.Enabled {
+		if cfg.Server.BaseURL == "" {
+			log.Warn().Msg("Federation requires server.base_url; leaving it disabled")
+		} else {
+			instance := &atproto.InstanceRecord{
+				Name:        cfg.Federation.Name,
+				Description: cfg.Federation.Description,
+				Endpoint:    cfg.Server.BaseURL,
+				Endpoints: map[string]string{
+					federation.EndpointSpectatorGames: "/api/spectator/games",
+				},
+			}
+			if err := client.PublishInstance(context.Background(), instance); err != nil {
+				log.Error().Err(err).Msg("Failed to publish instance record")
+			}
+			
+			directory := federation.NewDirectory(client.GetDID(), instance, client, cfg.Federation.AcceptInbound)
+			directory.StartRefresh(context.Background(), cfg.Federation.Peers, cfg.Federation.RefreshInterval)
+			service.SetFederation(directory)
+			log.Info().Int("peers", len(cfg.Federation.Peers)).Msg("Federation enabled")
+		}
+	}
+	
 	// Create firehose processor
 	processor := firehose.NewEventProcessor(hub)
+	// Drop challenge notifications from players the challenged player
+	// blocks or denies challenges; if we can't tell, let them through
+	processor.SetChallengeFilter(func(ctx context.Context, challenger, challenged string) bool {
+		refused, err := client.RefusesChallenges(ctx, challenged, challenger)
+		return err != nil || !refused
+	})
 	
 	// Start firehose client (optional - can be disabled in config)
 	if cfg.Firehose.Enabled {
+		firehoseOpts := []firehose.Option{firehose.WithURL(cfg.Firehose.URL)}
+		if injector != nil && injector.FirehoseDisconnectInterval() > 0 {
+			firehoseOpts = append(firehoseOpts, firehose.WithForcedDisconnects(injector.FirehoseDisconnectInterval()))
+		}
+		// Index every chess record for the spectator listing
+		// A configured database that can't be opened is fatal; falling back
+		// to memory would quietly lose everything indexed from then on
+		store, err := openIndexStore(cfg.Index)
+		if err != nil {
+			log.Fatal().Err(err).Str("driver", cfg.Index.Driver).Msg("Failed to open game index")
+		}
+		defer store.Close()
+		indexer := index.NewIndexer(store)
+		service.SetGameIndex(indexer)
+		// Time checks find games' latest moves in the index
+		client.SetMoveIndex(indexer)
+		
+		// Rate finished games and publish ratings to players' repositories
+		ratings := rating.NewRatings(service)
+		if err := ratings.Rebuild(context.Background(), indexer); err != nil {
+			log.Error().Err(err).Msg("Failed to rebuild ratings from the game index")
+		}
+		indexer.OnGameFinished(func(ctx context.Context, game *index.Game) {
+			ratings.RecordGame(ctx, game)
+		})
+		service.SetRatings(ratings)
+		
+		// Feature the most interesting live game on TV
+		tv := web.NewTV(hub, indexer, ratings)
+		indexer.OnGameFinished(tv.GameFinished)
+		indexer.OnGameFinished(service.GameFinished)
+		indexer.OnGameFinished(func(ctx context.Context, game *index.Game) {
+			// Awarding writes to players' repositories; don't hold up indexing
+			go service.AwardAchievements(context.Background(), game)
+		})
+		service.SetTV(tv)
+		go tv.Run(context.Background(), cfg.Spectator.TVInterval)
+		
+		// Mine finished games for puzzles and publish them to our repository
+		puzzles := puzzle.NewPuzzles(puzzleSolver, indexer, service)
+		service.SetPuzzles(puzzles)
+		if err := service.LoadPuzzles(context.Background()); err != nil {
+			log.Error().Err(err).Msg("Failed to load published puzzles")
+		}
+		indexer.OnGameFinished(func(ctx context.Context, game *index.Game) {
+			// Searching every position takes a while; don't hold up indexing
+			go func() {
+				if _, err := puzzles.MineGame(context.Background(), game); err != nil {
+					log.Warn().Err(err).Str("game", game.URI).Msg("Failed to mine game for puzzles")
+				}
+			}()
+		})
+		
+		// Score finished rated games for engine assistance, for operators to review
+		if cheatAnalyzer != nil {
+			detector := web.NewCheatDetector(cheatAnalyzer, indexer, client)
+			service.SetCheatDetector(detector)
+			indexer.OnGameFinished(detector.GameFinished)
+			go detector.Run(context.Background())
+			log.Info().Int("flagScore", cfg.AntiCheat.FlagScore).Msg("Anti-cheat analysis enabled")
+		}
+		
+		handler := firehose.CreateChessEventHandler(processor)
+		
+		// Answer opponents' moves with the replies players queued for them
+		handler = firehose.WithConditionalMoves(service, handler)
+		
+		// Settle game records when the opponent's move ends the game
+		handler = firehose.WithFinalizer(service, handler)
+		
+		// Deliver moves, finished games and challenges to players' webhooks
+		if cfg.Webhooks.Enabled {
+			webhookOpts := []webhook.Option{webhook.WithRetries(cfg.Webhooks.MaxAttempts, cfg.Webhooks.InitialBackoff)}
+			if cfg.Webhooks.AllowPrivateAddresses {
+				webhookOpts = append(webhookOpts, webhook.WithHTTPClient(&http.Client{}))
+			}
+			// Like the scheduler, a configured database must open rather
+			// than silently forgetting registrations on the next restart
+			webhookStore, err := openWebhookStore(cfg.Webhooks)
+			if err != nil {
+				log.Fatal().Err(err).Str("driver", cfg.Webhooks.Driver).Msg("Failed to open webhook store")
+			}
+			defer webhookStore.Close()
+			webhookOpts = append(webhookOpts, webhook.WithStore(webhookStore))
+			dispatcher := webhook.NewDispatcher(webhookOpts...)
+			service.SetWebhooks(dispatcher)
+			handler = firehose.WithWebhooks(dispatcher, handler)
+		}
+		
+		// Notify correspondence players' browsers of moves and challenges
+		if cfg.Push.Enabled {
+			pushStore, err := openPushStore(cfg.Push)
+			if err != nil {
+				log.Fatal().Err(err).Str("driver", cfg.Push.Driver).Msg("Failed to open push subscription store")
+			}
+			defer pushStore.Close()
+			notifier := push.NewNotifier(push.Options{
+				PublicKey:  cfg.Push.VAPIDPublicKey,
+				PrivateKey: cfg.Push.VAPIDPrivateKey,
+				Subject:    cfg.Push.Subject,
+				TTL:        cfg.Push.TTL,
+				Store:      pushStore,
+			})
+			service.SetPush(notifier)
+			handler = firehose.WithPush(notifier, handler)
+		}
+		
 		firehoseClient := firehose.NewClient(
-			firehose.CreateChessEventHandler(processor),
-			firehose.WithURL(cfg.Firehose.URL),
+			firehose.WithValidation(firehose.WithRecordCache(client.RecordCache(), firehose.WithIndexer(indexer, handler))),
+			firehoseOpts...,
 		)
 		
 		go func() {
@@ -89,139 +441,59 @@ func main() {
 		processor.TrackPlayer(client.GetDID())
 	}
 	
+	// Start once the game index is ready to find active games in
+	if sched != nil {
+		go sched.Run(context.Background())
+	}
+	
 	// Setup routes
 	router := mux.NewRouter()
+	registrar := routes.NewRegistrar(router, service.RequireSession)
+	registrar.Register("", routes.Root(service))
 	
-	// Add CORS middleware
-	router.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Session-ID")
-			
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-			
-			next.ServeHTTP(w, r)
-		})
-	})
+	// Resolve X-Session-ID to the logged-in user's AT Protocol client
+	apiMiddleware := []mux.MiddlewareFunc{service.SessionMiddleware}
+	if cfg.RateLimit.Enabled {
+		apiMiddleware = append(apiMiddleware, service.RateLimitMiddleware())
+	}
+	if injector != nil {
+		apiMiddleware = append(apiMiddleware, injector.Middleware)
+	}
+	// Bot accounts play through the bot API with tokens instead of sessions
+	botMiddleware := []mux.MiddlewareFunc{routes.Version(routes.APIVersion), service.BotTokenMiddleware}
+	if cfg.RateLimit.Enabled {
+		botMiddleware = append(botMiddleware, service.RateLimitMiddleware())
+	}
+	registrar.Register(routes.BotPrefix, routes.Bot(service), botMiddleware...)
+	
+	api := routes.API(service, hub)
+	registrar.Register(routes.VersionPrefix(routes.APIVersion), api,
+		append([]mux.MiddlewareFunc{routes.Version(routes.APIVersion)}, apiMiddleware...)...)
+	
+	// The paths from before the API was versioned, kept for existing clients
+	registrar.Register(routes.LegacyPrefix, api,
+		append([]mux.MiddlewareFunc{routes.Deprecated}, apiMiddleware...)...)
 	
-	// Root level health endpoint for load balancers and monitoring
-	router.HandleFunc("/health", service.HealthHandler).Methods("GET")
-	
-	// OAuth client metadata endpoint (must be before static file handler)
-	router.HandleFunc("/client-metadata.json", service.ClientMetadataHandler).Methods("GET")
-	
-	// API routes
-	api := router.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/health", service.HealthHandler).Methods("GET")
-	api.HandleFunc("/auth/login", service.LoginHandler).Methods("POST")
-	api.HandleFunc("/auth/current", service.GetCurrentUserHandler).Methods("GET")
-	api.HandleFunc("/auth/oauth/login", service.OAuthLoginHandler).Methods("POST")
-	api.HandleFunc("/callback", service.OAuthCallbackHandler).Methods("GET")
-	api.HandleFunc("/auth/session", service.GetSessionHandler).Methods("GET")
-	api.HandleFunc("/auth/logout", service.LogoutHandler).Methods("POST")
-	api.HandleFunc("/games", service.CreateGameHandler).Methods("POST")
-	api.HandleFunc("/games/{id:.*}", service.GetGameHandler).Methods("GET")
-	api.HandleFunc("/moves", service.MakeMoveHandler).Methods("POST")
-	api.HandleFunc("/challenges", service.CreateChallengeHandler).Methods("POST")
-	api.HandleFunc("/challenge-notifications", service.GetChallengeNotificationsHandler).Methods("GET")
-	api.HandleFunc("/challenge-notifications/{key}", service.DeleteChallengeNotificationHandler).Methods("DELETE")
-	api.HandleFunc("/draw-offers", service.OfferDrawHandler).Methods("POST")
-	api.HandleFunc("/draw-offers/respond", service.RespondToDrawHandler).Methods("POST")
-	api.HandleFunc("/resign", service.ResignGameHandler).Methods("POST")
-	
-	// Spectator endpoints
-	api.HandleFunc("/spectator/games", service.GetActiveGamesHandler).Methods("GET")
-	api.HandleFunc("/spectator/games/{id:.*}", service.GetSpectatorGameHandler).Methods("GET")
-	api.HandleFunc("/spectator/games/{id:.*}/count", service.UpdateSpectatorCountHandler(hub)).Methods("POST")
-	api.HandleFunc("/spectator/games/{id:.*}/abandonment", service.CheckAbandonmentHandler).Methods("GET")
-	api.HandleFunc("/spectator/games/{id:.*}/claim-abandonment", service.ClaimAbandonedGameHandler).Methods("POST")
-	
-	// Time control endpoints
-	api.HandleFunc("/games/{id:.*}/time-violation", service.CheckTimeViolationHandler).Methods("GET")
-	api.HandleFunc("/games/{id:.*}/claim-time", service.ClaimTimeVictoryHandler).Methods("POST")
-	api.HandleFunc("/games/{id:.*}/time-remaining", service.GetTimeRemainingHandler).Methods("GET")
-	
-	// WebSocket endpoint for real-time updates
-	api.HandleFunc("/ws", service.WebSocketHandler(hub))
-	
-	// Explicit OPTIONS handlers for CORS preflight requests
-	api.HandleFunc("/auth/login", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/auth/current", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/auth/oauth/login", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/auth/session", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/auth/logout", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/games", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/games/{id:.*}", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/moves", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/challenges", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/challenge-notifications", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/challenge-notifications/{key}", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/draw-offers", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/draw-offers/respond", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/resign", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/spectator/games", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/spectator/games/{id:.*}", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/spectator/games/{id:.*}/count", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/spectator/games/{id:.*}/abandonment", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/spectator/games/{id:.*}/claim-abandonment", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/games/{id:.*}/time-violation", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/games/{id:.*}/claim-time", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	api.HandleFunc("/games/{id:.*}/time-remaining", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}).Methods("OPTIONS")
-	
-	// Serve static files
-	staticDir := os.Getenv("ATCHESS_STATIC_DIR")
-	if staticDir == "" {
-		staticDir = "./web/static/"
-	}
-	router.PathPrefix("/").Handler(http.FileServer(http.Dir(staticDir)))
+	// Unauthenticated, rate-limited public API for researchers
+	if cfg.PublicAPI.Enabled {
+		registrar.Register("/public/v1", routes.Public(service), service.PublicAPIMiddleware())
+	}
+	
+	// Serve the web interface too, so a single process runs the whole site
+	if cfg.Server.ServeUI {
+		if staticDir == "" {
+			staticDir = cfg.Server.StaticDir
+		}
+		if staticDir == "" {
+			// The variable from before static_dir, kept for existing deployments
+			staticDir = os.Getenv("ATCHESS_STATIC_DIR")
+		}
+		registrar.Register("", routes.UI())
+		router.PathPrefix("/").Handler(webui.Handler(staticDir))
+		if staticDir != "" {
+			log.Info().Str("dir", staticDir).Msg("Serving the web interface from a directory")
+		}
+	}
 	
 	// Create server
 	srv := &http.Server{
@@ -232,10 +504,16 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 	
+	// Serve HTTPS directly when certificates are configured
+	server, err := tlsserver.New(srv, cfg.Server.TLS)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up TLS")
+	}
+	
 	// Start server
 	go func() {
-		log.Info().Str("addr", srv.Addr).Msg("Starting server")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Info().Str("addr", srv.Addr).Bool("tls", server.TLS()).Msg("Starting server")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("Failed to start server")
 		}
 	}()
@@ -250,13 +528,94 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	
-	if err := srv.Shutdown(ctx); err != nil {
+	// Drain WebSockets first: the HTTP server doesn't track hijacked
+	// connections, and clients should hear the server is restarting
+	if err := hub.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("WebSocket connections not drained")
+	}
+	if err := server.Shutdown(ctx); err != nil {
 		log.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
 	
 	log.Info().Msg("Server exited")
 }
 
+// openIndexStore opens the configured game index store
+func openIndexStore(cfg config.IndexConfig) (index.Store, error) {
+	if cfg.Driver == "" || cfg.Driver == "memory" {
+		return index.NewMemoryStore(), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return index.OpenSQLStore(ctx, cfg.Driver, cfg.DSN)
+}
+
+// openSchedulerStore opens the configured scheduled job store
+func openSchedulerStore(cfg config.SchedulerConfig) (scheduler.Store, error) {
+	if cfg.Driver == "" || cfg.Driver == "memory" {
+		return scheduler.NewMemoryStore(), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return scheduler.OpenSQLStore(ctx, cfg.Driver, cfg.DSN)
+}
+
+// openWebhookStore opens the configured webhook subscription store
+func openWebhookStore(cfg config.WebhooksConfig) (webhook.Store, error) {
+	if cfg.Driver == "" || cfg.Driver == "memory" {
+		return webhook.NewMemoryStore(), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return webhook.OpenSQLStore(ctx, cfg.Driver, cfg.DSN)
+}
+
+// openPushStore opens the configured push subscription store
+func openPushStore(cfg config.PushConfig) (push.Store, error) {
+	if cfg.Driver == "" || cfg.Driver == "memory" {
+		return push.NewMemoryStore(), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return push.OpenSQLStore(ctx, cfg.Driver, cfg.DSN)
+}
+
+// openOAuthStorage opens the configured storage of user and OAuth sessions,
+// along with the key box encrypting their tokens and DPoP keys
+func openOAuthStorage(cfg config.OAuthConfig) (oauth.Storage, *oauth.KeyBox, error) {
+	if cfg.Driver == "" || cfg.Driver == "memory" {
+		return oauth.NewMemoryStorage(), nil, nil
+	}
+
+	// Sessions that outlive the process must not leave usable keys behind
+	if cfg.EncryptionKey == "" {
+		return nil, nil, fmt.Errorf("oauth.encryption_key is required for the %s driver", cfg.Driver)
+	}
+	keys, err := oauth.ParseKeyBox(cfg.EncryptionKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if cfg.Driver == "redis" {
+		storage, err := oauth.NewRedisStorage(cfg.RedisURL, "atchess:oauth:")
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := storage.Ping(ctx); err != nil {
+			storage.Close()
+			return nil, nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+		return storage, keys, nil
+	}
+	storage, err := oauth.OpenSQLStorage(ctx, cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	return storage, keys, nil
+}
+
 func showHelpMessage() {
 	fmt.Println(`ATChess Protocol Service
 
@@ -270,15 +629,28 @@ USAGE:
     atchess-protocol [OPTIONS]
 
 OPTIONS:
-    -h, --help    Show this help message
+    -h, --help            Show this help message
+    --static-dir DIR      Serve the web interface from DIR instead of the
+                          built-in files, e.g. ./web/static while editing it
+    --config FILE         Read configuration from FILE instead of config.yaml
+    --print-config        Print the effective configuration, with secrets
+                          redacted, and exit
+    --KEY VALUE           Set any configuration key, e.g. --server.port 9000
 
 CONFIGURATION:
     The protocol service is configured via config.yaml in the current directory.
+    Every key can also be set by an environment variable named ATCHESS_ and
+    the key in upper case with dots as underscores (ATCHESS_SERVER_PORT for
+    server.port), or by a flag. Flags override the environment, which
+    overrides the file. Settings are checked at startup and every problem is
+    reported at once.
     
     Example config.yaml:
         server:
           host: localhost
           port: 8080        # Protocol service port
+          serve_ui: true    # Also serve the web interface (the default)
+          static_dir: ""    # Serve it from a directory instead of the built-in files
         
         atproto:
           pds_url: http://localhost:3000
@@ -301,12 +673,18 @@ BEHAVIOR:
     - Stores game data in AT Protocol repositories
     - Handles game state management with FEN/PGN notation
     - Provides REST API for chess operations
+    - Serves the built-in web interface, so no separate web server is needed
+    - Serves HTTPS itself when server.tls has a certificate or autocert,
+      redirecting plain HTTP on server.tls.redirect_addr (:80)
     - Graceful shutdown on SIGINT/SIGTERM
 
 EXAMPLES:
     # Start with default configuration
     atchess-protocol
     
+    # Run on another port, checking what the configuration comes to
+    atchess-protocol --server.port 9000 --print-config
+    
     # Show help
     atchess-protocol --help
     
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/justinabrahms/atchess/internal/apiclient"
+	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/justinabrahms/atchess/internal/tui"
+)
+
+func main() {
+	// Defaults come from the same configuration as the atchess command
+	cfg, err := config.LoadClient(os.Getenv("ATCHESS_CONFIG"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	var showHelp bool
+	var server, handle, password, script string
+	flag.BoolVar(&showHelp, "help", false, "Show help information")
+	flag.BoolVar(&showHelp, "h", false, "Show help information")
+	flag.StringVar(&server, "server", cfg.Server, "Protocol service API URL")
+	flag.StringVar(&handle, "handle", cfg.Handle, "Your handle")
+	flag.StringVar(&password, "password", "", "Your app password (prefer ATCHESS_PASSWORD)")
+	flag.StringVar(&script, "script", "", "Read commands from a file instead of the terminal")
+	flag.Parse()
+
+	if showHelp {
+		showHelpMessage()
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	stdin := bufio.NewReader(os.Stdin)
+	if handle == "" {
+		handle = prompt(stdin, "Handle: ")
+	}
+	if password == "" {
+		password = cfg.Password
+	}
+	if password == "" {
+		password = readPassword(stdin, "App password: ")
+	}
+
+	client := apiclient.New(server)
+	session, err := client.Login(ctx, handle, password)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Login failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Logged in as %s (%s)\n", session.Handle, session.DID)
+	if session.Warning != "" {
+		fmt.Printf("Warning: %s\n", session.Warning)
+	}
+
+	in := stdin
+	if script != "" {
+		f, err := os.Open(script)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open script: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = bufio.NewReader(f)
+	} else {
+		fmt.Println("Type help for commands")
+	}
+
+	if err := tui.NewSession(client, os.Stdout).Run(ctx, in); err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println()
+}
+
+func prompt(in *bufio.Reader, label string) string {
+	fmt.Print(label)
+	line, _ := in.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// readPassword prompts for a password, hiding it as it's typed where stty can
+// turn off the terminal's echo
+func readPassword(in *bufio.Reader, label string) string {
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	}
+	if stty("-echo") == nil {
+		defer func() {
+			stty("echo")
+			fmt.Println()
+		}()
+	}
+	return prompt(in, label)
+}
+
+func showHelpMessage() {
+	fmt.Println(`ATChess Terminal Client
+
+DESCRIPTION:
+    Plays ATChess games from the terminal through the protocol service's API.
+    Lists your games and challenges, draws boards with Unicode pieces and
+    submits moves in SAN or UCI notation.
+
+USAGE:
+    atchess-tui [OPTIONS]
+
+OPTIONS:
+    -h, --help               Show this help message
+    --server URL             Protocol service API (default: http://localhost:8080/api/v1)
+    --handle HANDLE          Your handle (else prompted)
+    --password PASSWORD      Your app password (else prompted)
+    --script FILE            Read commands from FILE instead of the terminal
+
+CONFIGURATION:
+    Defaults are read from the atchess command's configuration, atchess/cli.yaml
+    in your configuration directory (or the file named by ATCHESS_CONFIG), and
+    the ATCHESS_SERVER, ATCHESS_HANDLE and ATCHESS_PASSWORD variables.
+
+COMMANDS:
+    games [active|finished]                    List your games
+    open <number|game URI>                     Open a game and show its board
+    board                                      Refresh the open game
+    move <SAN|UCI>                             Move in the open game, e.g. Nf3 or g1f3
+    draw                                       Offer a draw in the open game
+    resign                                     Resign the open game
+    challenges                                 List challenges waiting for you
+    accept <number>                            Accept a listed challenge
+    decline <number>                           Decline a listed challenge
+    challenge <handle|DID> [color] [message]   Challenge a player
+    new <DID> [color]                          Start a game against a player
+    quit                                       Leave
+
+EXAMPLES:
+    # Play against a local protocol service
+    atchess-tui --handle player1.test
+
+    # Replay a scripted session, e.g. to exercise a deployment
+    ATCHESS_PASSWORD=abcd-efgh-ijkl-mnop atchess-tui --handle player1.test --script moves.txt
+
+SEE ALSO:
+    atchess(1), atchess-protocol(1)
+
+    Documentation: docs/
+    Repository: https://github.com/justinabrahms/atchess`)
+}
@@ -12,6 +12,9 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/justinabrahms/atchess/internal/routes"
+	"github.com/justinabrahms/atchess/internal/tlsserver"
+	webui "github.com/justinabrahms/atchess/web"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -19,8 +22,11 @@ import (
 func main() {
 	// Parse command line flags
 	var showHelp bool
+	var staticDir string
 	flag.BoolVar(&showHelp, "help", false, "Show help information")
 	flag.BoolVar(&showHelp, "h", false, "Show help information")
+	flag.StringVar(&staticDir, "static-dir", "", "Serve the web interface from a directory instead of the built-in files")
+	configFlags := config.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
 	if showHelp {
@@ -31,17 +37,33 @@ func main() {
 	// Setup logging
 	log.Logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
 	
-	// Load config
-	cfg, err := config.Load()
+	// Load config; problems are printed plainly since there may be several
+	cfg, err := config.LoadOptions(configFlags.Options())
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to load config")
+		fmt.Fprintf(os.Stderr, "atchess-web: %v\n", err)
+		os.Exit(1)
+	}
+	if configFlags.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal().Err(err).Msg("Failed to print config")
+		}
+		return
 	}
 	
 	// Setup routes
 	router := mux.NewRouter()
+	for _, route := range routes.UI() {
+		router.HandleFunc(route.Path, route.Handler).Methods(route.Method)
+	}
 	
-	// Serve static files
-	router.PathPrefix("/").Handler(http.FileServer(http.Dir("./web/static/")))
+	// Serve static files, built in unless a directory is configured
+	if staticDir == "" {
+		staticDir = cfg.Server.StaticDir
+	}
+	if staticDir == "" {
+		staticDir = os.Getenv("ATCHESS_STATIC_DIR")
+	}
+	router.PathPrefix("/").Handler(webui.Handler(staticDir))
 	
 	// Create server
 	srv := &http.Server{
@@ -52,10 +74,16 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 	
+	// Serve HTTPS directly when certificates are configured
+	server, err := tlsserver.New(srv, cfg.Server.TLS)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up TLS")
+	}
+	
 	// Start server
 	go func() {
-		log.Info().Str("addr", srv.Addr).Msg("Starting web server")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Info().Str("addr", srv.Addr).Bool("tls", server.TLS()).Msg("Starting web server")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("Failed to start web server")
 		}
 	}()
@@ -70,7 +98,7 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	
-	if err := srv.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(ctx); err != nil {
 		log.Fatal().Err(err).Msg("Web server forced to shutdown")
 	}
 	
@@ -90,10 +118,21 @@ USAGE:
     atchess-web [OPTIONS]
 
 OPTIONS:
-    -h, --help    Show this help message
+    -h, --help            Show this help message
+    --static-dir DIR      Serve the web interface from DIR instead of the
+                          built-in files, e.g. ./web/static while editing it
+    --config FILE         Read configuration from FILE instead of config.yaml
+    --print-config        Print the effective configuration, with secrets
+                          redacted, and exit
+    --KEY VALUE           Set any configuration key, e.g. --server.port 9000
 
 CONFIGURATION:
     The web server is configured via config.yaml in the current directory.
+    Every key can also be set by an environment variable named ATCHESS_ and
+    the key in upper case with dots as underscores (ATCHESS_SERVER_PORT for
+    server.port), or by a flag. Flags override the environment, which
+    overrides the file. Settings are checked at startup and every problem is
+    reported at once.
     
     Example config.yaml:
         server:
@@ -111,15 +150,23 @@ CONFIGURATION:
 
 BEHAVIOR:
     - Web server runs on port 8081 (protocol service port + 1)
-    - Serves static files from ./web/static/
+    - Serves the web interface built into the binary, or from server.static_dir
+    - The protocol service serves the same interface itself; this server is
+      only needed to host the interface apart from the API
     - Provides interactive chessboard interface
+    - Redirects /resolve?uri=at://... to the page for a game or challenge
     - Connects to atchess-protocol service for game operations
+    - Serves HTTPS itself when server.tls has a certificate or autocert,
+      redirecting plain HTTP on server.tls.redirect_addr (:80)
     - Graceful shutdown on SIGINT/SIGTERM
 
 EXAMPLES:
     # Start with default configuration
     atchess-web
     
+    # Run on another port, checking what the configuration comes to
+    atchess-web --server.port 9000 --print-config
+    
     # Show help
     atchess-web --help
 
@@ -0,0 +1,260 @@
+// Package apiclient is a client for the protocol service's REST API, as used
+// by the terminal client. It speaks to the API the way the web interface
+// does, so it also serves to drive the service end to end.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// SessionHeader carries the session token on authenticated requests
+const SessionHeader = "X-Session-ID"
+
+// Error is an error response from the API
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("HTTP %d", e.Status)
+	}
+	return e.Message
+}
+
+// Session is a logged-in player
+type Session struct {
+	DID         string `json:"did"`
+	Handle      string `json:"handle"`
+	AccessToken string `json:"accessToken"`
+	// Warning is set when the player logged in with their main password
+	Warning string `json:"warning,omitempty"`
+}
+
+// Notification is a challenge waiting for the player's answer
+type Notification struct {
+	URI              string
+	ChallengeURI     string
+	Challenger       string
+	ChallengerHandle string
+	Color            string
+	Message          string
+	CreatedAt        string
+	ExpiresAt        string
+}
+
+// Client calls the API at a base URL such as http://localhost:8080/api/v1
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	session    *Session
+}
+
+// New creates a client for the API at baseURL
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Session returns the logged-in player, or nil before Login
+func (c *Client) Session() *Session {
+	return c.session
+}
+
+// Login signs in with a handle and app password; later requests act as the
+// player
+func (c *Client) Login(ctx context.Context, handle, password string) (*Session, error) {
+	var session Session
+	err := c.do(ctx, http.MethodPost, "/auth/login", map[string]string{"handle": handle, "password": password}, &session)
+	if err != nil {
+		return nil, err
+	}
+	c.session = &session
+	return &session, nil
+}
+
+// ListGames returns the player's games, filtered by status "active" or
+// "finished" unless it's empty
+func (c *Client) ListGames(ctx context.Context, status string) ([]*chess.Game, error) {
+	path := "/games"
+	if status != "" {
+		path += "?status=" + url.QueryEscape(status)
+	}
+	var resp struct {
+		Games []*chess.Game `json:"games"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Games, nil
+}
+
+// GetGame returns a game by its AT URI
+func (c *Client) GetGame(ctx context.Context, gameURI string) (*chess.Game, error) {
+	var game chess.Game
+	if err := c.do(ctx, http.MethodGet, "/games/"+EncodeGameID(gameURI), nil, &game); err != nil {
+		return nil, err
+	}
+	return &game, nil
+}
+
+// CreateGame starts a game against opponentDID, with the player taking color
+func (c *Client) CreateGame(ctx context.Context, opponentDID, color string) (*chess.Game, error) {
+	var game chess.Game
+	err := c.do(ctx, http.MethodPost, "/games", map[string]string{"opponent_did": opponentDID, "color": color}, &game)
+	if err != nil {
+		return nil, err
+	}
+	return &game, nil
+}
+
+// Move plays a move, in SAN ("Nf3") or UCI ("g1f3") notation, against the
+// position fen; a game that moved on since fails with position_mismatch
+func (c *Client) Move(ctx context.Context, gameURI, fen, move string) (*chess.MoveResult, error) {
+	var result chess.MoveResult
+	err := c.do(ctx, http.MethodPost, "/moves", map[string]string{"game_id": gameURI, "fen": fen, "move": move}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ChallengeNotifications returns the challenges waiting for the player
+func (c *Client) ChallengeNotifications(ctx context.Context) ([]*Notification, error) {
+	var notifications []*Notification
+	if err := c.do(ctx, http.MethodGet, "/challenge-notifications", nil, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// Challenge invites opponent, a handle or DID, to a game
+func (c *Client) Challenge(ctx context.Context, opponent, color, message string) (*chess.Challenge, error) {
+	var challenge chess.Challenge
+	err := c.do(ctx, http.MethodPost, "/challenges", map[string]string{"opponent_did": opponent, "color": color, "message": message}, &challenge)
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// AcceptChallenge accepts a challenge, returning the game it starts
+func (c *Client) AcceptChallenge(ctx context.Context, challengeURI string) (*chess.Game, error) {
+	var game chess.Game
+	if err := c.do(ctx, http.MethodPost, "/challenges/accept", map[string]string{"challengeUri": challengeURI}, &game); err != nil {
+		return nil, err
+	}
+	return &game, nil
+}
+
+// DeclineChallenge declines a challenge
+func (c *Client) DeclineChallenge(ctx context.Context, challengeURI string) error {
+	return c.do(ctx, http.MethodPost, "/challenges/decline", map[string]string{"challengeUri": challengeURI}, nil)
+}
+
+// CancelChallenge withdraws a pending challenge the user sent
+func (c *Client) CancelChallenge(ctx context.Context, challengeURI string) error {
+	return c.do(ctx, http.MethodDelete, "/challenges/"+EncodeGameID(challengeURI), nil, nil)
+}
+
+// OfferDraw offers the opponent a draw
+func (c *Client) OfferDraw(ctx context.Context, gameURI string) error {
+	return c.do(ctx, http.MethodPost, "/draw-offers", map[string]string{"gameId": gameURI}, nil)
+}
+
+// Resign resigns a game
+func (c *Client) Resign(ctx context.Context, gameURI string) error {
+	return c.do(ctx, http.MethodPost, "/resign", map[string]string{"gameId": gameURI}, nil)
+}
+
+// ExportPGN returns a game as PGN
+func (c *Client) ExportPGN(ctx context.Context, gameURI string) (string, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/games/"+EncodeGameID(gameURI)+"/pgn", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	pgn, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(pgn), nil
+}
+
+// EncodeGameID encodes a game's AT URI for use in a path, as the API expects
+func EncodeGameID(gameURI string) string {
+	return base64.URLEncoding.EncodeToString([]byte(gameURI))
+}
+
+// do sends a request with body encoded as JSON and decodes the response into
+// out, if it isn't nil
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// send sends a request with body encoded as JSON, returning error responses
+// as *Error
+func (c *Client) send(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.session != nil {
+		req.Header.Set(SessionHeader, c.session.AccessToken)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		apiErr := &Error{Status: resp.StatusCode}
+		var envelope struct {
+			Error *Error `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&envelope) == nil && envelope.Error != nil {
+			apiErr.Code, apiErr.Message = envelope.Error.Code, envelope.Error.Message
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
@@ -0,0 +1,80 @@
+package apiclient
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEncodeGameID(t *testing.T) {
+	// The API decodes std base64 with + and / swapped for - and _
+	uri := "at://did:plc:abc/app.atchess.game/???"
+	std := base64.StdEncoding.EncodeToString([]byte(uri))
+	if encoded := EncodeGameID(uri); encoded == std {
+		t.Fatalf("Expected a URL-safe encoding, got %q", encoded)
+	}
+	decoded, err := base64.URLEncoding.DecodeString(EncodeGameID(uri))
+	if err != nil || string(decoded) != uri {
+		t.Errorf("Expected %q back, got %q (%v)", uri, decoded, err)
+	}
+}
+
+func TestClient_ReturnsAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SessionHeader) != "" {
+			t.Error("Expected no session before logging in")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"code":"invalid_credentials","message":"Invalid handle or password"}}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).Login(context.Background(), "alice.test", "wrong")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *Error, got %v", err)
+	}
+	if apiErr.Status != http.StatusUnauthorized || apiErr.Code != "invalid_credentials" || apiErr.Error() != "Invalid handle or password" {
+		t.Errorf("Unexpected error %+v", apiErr)
+	}
+}
+
+func TestClient_ExportPGN(t *testing.T) {
+	uri := "at://did:plc:abc/app.atchess.game/xyz"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/login":
+			w.Write([]byte(`{"success":true,"did":"did:plc:abc","handle":"alice.test","accessToken":"token"}`))
+		case "/games/" + EncodeGameID(uri) + "/pgn":
+			if r.Header.Get(SessionHeader) != "token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/x-chess-pgn")
+			w.Write([]byte("[Event \"ATChess game\"]\n\n1. e4 *\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL + "/")
+	if _, err := client.Login(context.Background(), "alice.test", "abcd-efgh-ijkl-mnop"); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	pgn, err := client.ExportPGN(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("ExportPGN failed: %v", err)
+	}
+	if pgn != "[Event \"ATChess game\"]\n\n1. e4 *\n" {
+		t.Errorf("Unexpected PGN %q", pgn)
+	}
+
+	if _, err := client.ExportPGN(context.Background(), uri+"-missing"); err == nil {
+		t.Error("Expected an error for a missing game")
+	}
+}
@@ -0,0 +1,131 @@
+// Package apierror defines the errors returned by the HTTP API. Every error
+// response has the same JSON body,
+//
+//	{"error": {"code": "not_your_turn", "message": "It is not your turn"}}
+//
+// so clients can tell errors apart by code rather than by message text.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Error is an API error with a stable code, a message for people and the
+// HTTP status it's returned with
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches errors with the same code, so errors.Is(err, ErrNotYourTurn)
+// holds whatever the message
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage returns a copy of the error with a more specific message
+func (e *Error) WithMessage(message string) *Error {
+	copied := *e
+	copied.Message = message
+	return &copied
+}
+
+// New creates an API error
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// General errors, one per status, for failures without a more specific code
+var (
+	ErrBadRequest       = New(http.StatusBadRequest, "bad_request", "Bad request")
+	ErrUnauthorized     = New(http.StatusUnauthorized, "unauthorized", "Not logged in")
+	ErrForbidden        = New(http.StatusForbidden, "forbidden", "Forbidden")
+	ErrNotFound         = New(http.StatusNotFound, "not_found", "Not found")
+	ErrMethodNotAllowed = New(http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+	ErrConflict         = New(http.StatusConflict, "conflict", "Conflict")
+	ErrRateLimited      = New(http.StatusTooManyRequests, "rate_limited", "Rate limit exceeded")
+	ErrInternal         = New(http.StatusInternalServerError, "internal_error", "Internal server error")
+	ErrNotImplemented   = New(http.StatusNotImplemented, "not_implemented", "Not implemented")
+	ErrUnavailable      = New(http.StatusServiceUnavailable, "unavailable", "Service unavailable")
+)
+
+// Request errors
+var (
+	ErrInvalidBody        = New(http.StatusBadRequest, "invalid_body", "Invalid request body")
+	ErrInvalidGameID      = New(http.StatusBadRequest, "invalid_game_id", "Invalid game ID")
+	ErrInvalidFEN         = New(http.StatusBadRequest, "invalid_fen", "Invalid FEN")
+	ErrInvalidMove        = New(http.StatusBadRequest, "invalid_move", "Invalid move")
+	ErrInvalidCursor      = New(http.StatusBadRequest, "invalid_cursor", "Invalid cursor")
+	ErrInvalidLimit       = New(http.StatusBadRequest, "invalid_limit", "Invalid limit")
+	ErrMessageTooLong     = New(http.StatusBadRequest, "message_too_long", "Message is too long")
+	ErrUnsupportedVersion = New(http.StatusBadRequest, "unsupported_version", "Unsupported API version")
+)
+
+// Authentication and permission errors
+var (
+	ErrInvalidCredentials = New(http.StatusUnauthorized, "invalid_credentials", "Invalid credentials or authentication failed")
+	ErrInvalidSession     = New(http.StatusUnauthorized, "invalid_session", "Invalid or expired session")
+	ErrNotAPlayer         = New(http.StatusForbidden, "not_a_player", "You are not a player in this game")
+	ErrNotYourTurn        = New(http.StatusForbidden, "not_your_turn", "It is not your turn")
+	ErrBotMove            = New(http.StatusForbidden, "bot_move", "The computer plays its own moves")
+	ErrNotOperator        = New(http.StatusForbidden, "not_operator", "Only operators can do this")
+	ErrMainPassword       = New(http.StatusForbidden, "main_password", "Log in with an app password, not your account password")
+)
+
+// Game state errors
+var (
+	ErrGameNotFound     = New(http.StatusNotFound, "game_not_found", "Game not found")
+	ErrGameFinished     = New(http.StatusConflict, "game_finished", "Game is not active")
+	ErrPositionMismatch = New(http.StatusConflict, "position_mismatch", "Submitted position does not match the current game state")
+	ErrClockPaused      = New(http.StatusConflict, "clock_paused", "The clock is paused while a player reconnects")
+	ErrGameInProgress   = New(http.StatusConflict, "game_in_progress", "The game is still being played")
+	ErrDuplicateMove    = New(http.StatusConflict, "duplicate_move", "You have already made this move")
+)
+
+// Challenge, seek and rematch errors
+var (
+	ErrNotChallenged       = New(http.StatusForbidden, "not_challenged", "This challenge is not addressed to you")
+	ErrNotChallenger       = New(http.StatusForbidden, "not_challenger", "Only the challenger can cancel this challenge")
+	ErrChallengeRefused    = New(http.StatusForbidden, "challenge_refused", "This player does not accept challenges from you")
+	ErrChallengeLimit      = New(http.StatusTooManyRequests, "challenge_limit", "You have too many pending challenges to this player")
+	ErrChallengeNotPending = New(http.StatusConflict, "challenge_not_pending", "This challenge is no longer pending")
+	ErrOwnSeek             = New(http.StatusBadRequest, "own_seek", "You cannot accept your own seek")
+	ErrSeekNotOpen         = New(http.StatusConflict, "seek_not_open", "This seek is no longer open")
+	ErrNotRematchOpponent  = New(http.StatusForbidden, "not_rematch_opponent", "This rematch offer is not addressed to you")
+	ErrRematchNotPending   = New(http.StatusConflict, "rematch_not_pending", "This rematch offer is no longer pending")
+)
+
+// Moderation errors
+var (
+	ErrAlreadyReported = New(http.StatusConflict, "already_reported", "You have already reported this player for this")
+)
+
+// ErrDisabled is returned for features this instance hasn't enabled
+var ErrDisabled = New(http.StatusNotFound, "disabled", "Not enabled")
+
+// Response is the body of an error response
+type Response struct {
+	Error *Error `json:"error"`
+}
+
+// Write sends err as a JSON error response. Errors that aren't API errors are
+// reported as internal errors without their text, which may describe internals.
+func Write(w http.ResponseWriter, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = ErrInternal
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Status)
+	_ = json.NewEncoder(w).Encode(Response{Error: apiErr})
+}
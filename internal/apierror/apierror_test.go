@@ -0,0 +1,57 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteSendsJSONError(t *testing.T) {
+	w := httptest.NewRecorder()
+	Write(w, ErrNotYourTurn)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected a JSON response, got %q", ct)
+	}
+	var resp map[string]map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode %s: %v", w.Body.String(), err)
+	}
+	if resp["error"]["code"] != "not_your_turn" || resp["error"]["message"] != "It is not your turn" {
+		t.Errorf("Unexpected body: %v", resp)
+	}
+}
+
+func TestWriteHidesOtherErrors(t *testing.T) {
+	w := httptest.NewRecorder()
+	Write(w, errors.New("dial tcp 10.0.0.1:5432: connection refused"))
+
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusInternalServerError || resp.Error.Code != "internal_error" || resp.Error.Message != "Internal server error" {
+		t.Errorf("Expected a generic internal error, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestWithMessageKeepsCode(t *testing.T) {
+	err := ErrInvalidMove.WithMessage("Invalid move: Ke9")
+	if err.Code != "invalid_move" || err.Status != http.StatusBadRequest || ErrInvalidMove.Message != "Invalid move" {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+	wrapped := fmt.Errorf("handling move: %w", err)
+	if !errors.Is(wrapped, ErrInvalidMove) || errors.Is(wrapped, ErrInvalidFEN) {
+		t.Error("Expected errors to match by code")
+	}
+
+	w := httptest.NewRecorder()
+	Write(w, wrapped)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected wrapped API errors to keep their status, got %d", w.Code)
+	}
+}
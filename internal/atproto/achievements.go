@@ -0,0 +1,100 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Achievement kinds, as in the app.atchess.achievement lexicon
+const (
+	AchievementFirstWin            = "first_win"
+	AchievementHundredGames        = "games_100"
+	AchievementKnightPromotionMate = "knight_promotion_mate"
+	AchievementWinStreak5          = "win_streak_5"
+	AchievementWinStreak10         = "win_streak_10"
+)
+
+// AchievementRecord is an app.atchess.achievement record. Its record key is
+// its kind, so a player holds each achievement once.
+type AchievementRecord struct {
+	URI        string `json:"uri"`
+	Kind       string `json:"kind"`
+	Game       string `json:"game,omitempty"` // AT URI of the game that reached it
+	UnlockedAt string `json:"unlockedAt"`
+	Issuer     string `json:"issuer,omitempty"`
+}
+
+// PublishAchievement writes an achievement to the current account's
+// repository, replacing any earlier record of the same kind
+func (c *Client) PublishAchievement(ctx context.Context, achievement *AchievementRecord) error {
+	if achievement.UnlockedAt == "" {
+		achievement.UnlockedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	record := map[string]interface{}{
+		"$type":      "app.atchess.achievement",
+		"kind":       achievement.Kind,
+		"unlockedAt": achievement.UnlockedAt,
+	}
+	if achievement.Game != "" {
+		record["game"] = achievement.Game
+	}
+	if achievement.Issuer != "" {
+		record["issuer"] = achievement.Issuer
+	}
+
+	putReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.achievement",
+		"rkey":       achievement.Kind,
+		"record":     record,
+	}
+
+	reqBody, _ := json.Marshal(putReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to publish achievement record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to publish achievement record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var putResp struct {
+		URI string `json:"uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&putResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	achievement.URI = putResp.URI
+	return nil
+}
+
+// ListAchievements returns the achievements in a player's repository, in the
+// order they were unlocked
+func (c *Client) ListAchievements(ctx context.Context, did string) ([]*AchievementRecord, error) {
+	achievements := []*AchievementRecord{}
+	err := c.listAllRecords(ctx, did, "app.atchess.achievement", func(uri, cid string, value json.RawMessage) error {
+		var achievement AchievementRecord
+		if err := json.Unmarshal(value, &achievement); err != nil || achievement.Kind == "" {
+			return nil // Skip malformed records
+		}
+		achievement.URI = uri
+		achievements = append(achievements, &achievement)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list achievements: %w", err)
+	}
+	sort.SliceStable(achievements, func(i, j int) bool {
+		return achievements[i].UnlockedAt < achievements[j].UnlockedAt
+	})
+	return achievements, nil
+}
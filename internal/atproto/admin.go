@@ -0,0 +1,266 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// ErrNotOwnRecord is returned when an operation needs a record in the
+// client's own repository
+var ErrNotOwnRecord = errors.New("record is not in this account's repository")
+
+// Record is a record as stored in a repository
+type Record struct {
+	URI   string          `json:"uri"`
+	CID   string          `json:"cid"`
+	Value json.RawMessage `json:"value"`
+}
+
+// GameRecords is a game record and the records in its players' repositories
+// that refer to it
+type GameRecords struct {
+	Game Record `json:"game"`
+	// Repos maps each player's DID to their records about the game, by
+	// collection
+	Repos map[string]map[string][]Record `json:"repos"`
+}
+
+// gameRecordCollections are the collections whose records refer to a game
+// through a game.uri field
+var gameRecordCollections = []string{
+	"app.atchess.move",
+	"app.atchess.drawOffer",
+	"app.atchess.resignation",
+	"app.atchess.timeViolation",
+	"app.atchess.chatMessage",
+	"app.atchess.rematchOffer",
+}
+
+// InspectGame collects a game record and every record in both players'
+// repositories that refers to it, as stored
+func (c *Client) InspectGame(ctx context.Context, gameURI string) (*GameRecords, error) {
+	cid, value, err := c.getGameRecord(ctx, gameURI)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	records := &GameRecords{
+		Game:  Record{URI: gameURI, CID: cid, Value: raw},
+		Repos: make(map[string]map[string][]Record),
+	}
+
+	white, _ := value["white"].(string)
+	black, _ := value["black"].(string)
+	for _, player := range []string{white, black} {
+		if player == "" || records.Repos[player] != nil {
+			continue
+		}
+		collections := make(map[string][]Record)
+		for _, collection := range gameRecordCollections {
+			err := c.listAllRecords(ctx, player, collection, func(uri, cid string, value json.RawMessage) error {
+				var ref struct {
+					Game struct {
+						URI string `json:"uri"`
+					} `json:"game"`
+				}
+				if json.Unmarshal(value, &ref) == nil && ref.Game.URI == gameURI {
+					collections[collection] = append(collections[collection], Record{URI: uri, CID: cid, Value: value})
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s's records: %w", player, err)
+			}
+		}
+		records.Repos[player] = collections
+	}
+	return records, nil
+}
+
+// GameReport is the outcome of checking a game's records against each other
+type GameReport struct {
+	URI string `json:"uri"`
+	// Moves is how many moves replay from the starting position
+	Moves  int              `json:"moves"`
+	Status chess.GameStatus `json:"status"`
+	// ReplayedStatus is the status the moves leave the game in
+	ReplayedStatus chess.GameStatus `json:"replayedStatus"`
+	Problems       []string         `json:"problems,omitempty"`
+}
+
+// OK reports whether no problems were found
+func (r *GameReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *GameReport) problem(format string, args ...interface{}) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// VerifyGame replays a game's move records from both repositories and checks
+// they agree with each other and with the game record. Readers skip moves
+// that can't be replayed; VerifyGame reports them instead, along with a game
+// record whose position or status the moves don't lead to.
+func (c *Client) VerifyGame(ctx context.Context, gameURI string) (*GameReport, error) {
+	game, err := c.GetGame(ctx, gameURI)
+	if err != nil {
+		return nil, err
+	}
+	players := []string{game.White}
+	if game.Black != game.White {
+		players = append(players, game.Black)
+	}
+	records, err := c.listGameMoveRecords(ctx, gameURI, players)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect moves: %w", err)
+	}
+	engine, err := chess.NewVariantEngine(game.Variant, "")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GameReport{URI: gameURI, Status: game.Status}
+	// fens holds the position after each replayed ply, to tell harmless
+	// duplicates from conflicting ones
+	fens := []string{engine.GetFEN()}
+	for _, record := range records {
+		if parts := strings.Split(record.URI, "/"); len(parts) >= 3 && parts[2] != record.Player {
+			report.problem("move %s is in %s's repository but was made by %s", record.URI, parts[2], record.Player)
+		}
+
+		ply := len(fens)
+		if record.ply() != 0 && record.ply() < ply {
+			if record.FEN != fens[record.ply()] {
+				report.problem("move %s conflicts with the move already recorded at ply %d", record.URI, record.ply())
+			}
+			continue
+		}
+		if record.PrevFEN != "" && record.PrevFEN != engine.GetFEN() {
+			report.problem("move %s was played from a position the game never reached", record.URI)
+			continue
+		}
+
+		expectedPlayer := game.White
+		if ply%2 == 0 {
+			expectedPlayer = game.Black
+		}
+		if record.Player != expectedPlayer {
+			report.problem("move %s at ply %d was made by %s, expected %s", record.URI, ply, record.Player, expectedPlayer)
+			break
+		}
+		promotion := record.Promotion
+		if promotion == "" {
+			promotion = chess.PromotionFromSAN(record.SAN)
+		}
+		result, err := engine.MakeMove(record.From, record.To, chess.ParsePromotion(promotion))
+		if err != nil {
+			report.problem("move %s at ply %d (%s%s) is illegal: %v", record.URI, ply, record.From, record.To, err)
+			break
+		}
+		if record.FEN != "" && record.FEN != result.FEN {
+			report.problem("move %s at ply %d doesn't reach its recorded position", record.URI, ply)
+		}
+		fens = append(fens, result.FEN)
+	}
+
+	report.Moves = len(fens) - 1
+	report.ReplayedStatus = engine.GetStatus()
+	if game.FEN != engine.GetFEN() {
+		report.problem("game record position %q doesn't match the position after its moves %q", game.FEN, engine.GetFEN())
+	}
+	switch {
+	case report.ReplayedStatus == chess.StatusActive:
+		// A game can end without a move, e.g. by resignation or agreement
+	case game.Status == chess.StatusActive:
+		report.problem("the moves ended the game (%s) but the game record is still active", report.ReplayedStatus)
+	case game.Status != report.ReplayedStatus:
+		report.problem("the game record says %s but the moves ended it %s", game.Status, report.ReplayedStatus)
+	}
+	return report, nil
+}
+
+// SetGameResult ends a game record in our repository with status, whatever
+// its moves say. It's for operators unsticking a game that can't be
+// finalized from its moves; FinalizeGame should be tried first.
+func (c *Client) SetGameResult(ctx context.Context, gameURI string, status chess.GameStatus) (*chess.Game, error) {
+	switch status {
+	case chess.StatusWhiteWon, chess.StatusBlackWon, chess.StatusDraw, chess.StatusAbandoned:
+	default:
+		return nil, fmt.Errorf("%q is not a final status", status)
+	}
+	if parts := strings.Split(gameURI, "/"); len(parts) < 5 || parts[2] != c.did {
+		return nil, ErrNotOwnRecord
+	}
+	cid, value, err := c.getGameRecord(ctx, gameURI)
+	if err != nil {
+		return nil, err
+	}
+	err = c.swapRecord(ctx, "app.atchess.game", gameURI, cid, value, func(value map[string]interface{}) error {
+		setFinalStatus(value, status)
+		value["updatedAt"] = time.Now().Format(time.RFC3339)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.GetGame(ctx, gameURI)
+}
+
+// PurgeExpiredChallenges deletes the pending challenges and challenge
+// notifications in our repository that expired before now, returning their
+// URIs. With dryRun nothing is deleted.
+func (c *Client) PurgeExpiredChallenges(ctx context.Context, now time.Time, dryRun bool) ([]string, error) {
+	var purged []string
+	for _, collection := range []string{"app.atchess.challenge", "app.atchess.challengeNotification"} {
+		rkeys := make(map[string]string)
+		err := c.listAllRecords(ctx, c.did, collection, func(uri, cid string, value json.RawMessage) error {
+			var record struct {
+				Status    string `json:"status"`
+				ExpiresAt string `json:"expiresAt"`
+			}
+			if json.Unmarshal(value, &record) != nil {
+				return nil
+			}
+			// Notifications have no status; answered challenges are history
+			if record.Status != "" && record.Status != "pending" {
+				return nil
+			}
+			expiry, err := time.Parse(time.RFC3339, record.ExpiresAt)
+			if err != nil || !expiry.Before(now) {
+				return nil
+			}
+			if parts := strings.Split(uri, "/"); len(parts) == 5 {
+				rkeys[parts[4]] = uri
+			}
+			return nil
+		})
+		if err != nil {
+			return purged, err
+		}
+
+		keys := make([]string, 0, len(rkeys))
+		for rkey := range rkeys {
+			keys = append(keys, rkey)
+		}
+		sort.Strings(keys)
+		if !dryRun {
+			for rkey, err := range c.deleteRecordsBatched(ctx, collection, keys) {
+				return purged, fmt.Errorf("failed to delete %s: %w", rkeys[rkey], err)
+			}
+		}
+		for _, rkey := range keys {
+			purged = append(purged, rkeys[rkey])
+		}
+	}
+	return purged, nil
+}
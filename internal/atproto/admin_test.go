@@ -0,0 +1,123 @@
+package atproto
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+const afterE3 = "rnbqkbnr/pppppppp/8/8/8/4P3/PPPP1PPP/RNBQKBNR b KQkq - 0 1"
+
+// laterMove marks a move as recorded after the others
+func laterMove(value map[string]interface{}) map[string]interface{} {
+	value["createdAt"] = "2024-01-01T00:01:00Z"
+	return value
+}
+
+func TestVerifyGame(t *testing.T) {
+	tests := []struct {
+		name     string
+		gameFEN  string
+		moves    map[string][]map[string]interface{}
+		problems []string
+	}{
+		{
+			name:    "consistent",
+			gameFEN: afterE4E5,
+			moves: map[string][]map[string]interface{}{
+				"did:plc:test123":  {moveValue("did:plc:test123", "e2", "e4", afterE4)},
+				"did:plc:opponent": {moveValue("did:plc:opponent", "e7", "e5", afterE4E5)},
+			},
+		},
+		{
+			name:    "game record behind its moves",
+			gameFEN: chess.StartingFEN,
+			moves: map[string][]map[string]interface{}{
+				"did:plc:test123": {moveValue("did:plc:test123", "e2", "e4", afterE4)},
+			},
+			problems: []string{"doesn't match the position after its moves"},
+		},
+		{
+			name:    "conflicting moves at the same ply",
+			gameFEN: afterE4E5,
+			moves: map[string][]map[string]interface{}{
+				"did:plc:test123": {
+					moveValue("did:plc:test123", "e2", "e4", afterE4),
+					laterMove(moveValue("did:plc:test123", "e2", "e3", afterE3)),
+				},
+				"did:plc:opponent": {moveValue("did:plc:opponent", "e7", "e5", afterE4E5)},
+			},
+			problems: []string{"conflicts with the move already recorded at ply 1"},
+		},
+		{
+			name:    "move in the wrong repository",
+			gameFEN: afterE4,
+			moves: map[string][]map[string]interface{}{
+				"did:plc:opponent": {moveValue("did:plc:test123", "e2", "e4", afterE4)},
+			},
+			problems: []string{"is in did:plc:opponent's repository but was made by did:plc:test123"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient(t, newMovesPDS(t, tt.gameFEN, tt.moves))
+			report, err := client.VerifyGame(context.Background(), movesGameURI)
+			if err != nil {
+				t.Fatalf("Failed to verify game: %v", err)
+			}
+			if len(report.Problems) != len(tt.problems) {
+				t.Fatalf("Expected %d problems, got %q", len(tt.problems), report.Problems)
+			}
+			for i, want := range tt.problems {
+				if !strings.Contains(report.Problems[i], want) {
+					t.Errorf("Expected a problem mentioning %q, got %q", want, report.Problems[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSetGameResultOnlyEndsOwnGames(t *testing.T) {
+	client := newFakeClient(t, newMovesPDS(t, chess.StartingFEN, nil))
+	ctx := context.Background()
+	if _, err := client.SetGameResult(ctx, movesGameURI, chess.StatusDraw); !errors.Is(err, ErrNotOwnRecord) {
+		t.Errorf("Expected %v for the opponent's game, got %v", ErrNotOwnRecord, err)
+	}
+	if _, err := client.SetGameResult(ctx, "at://did:plc:test123/app.atchess.game/g1", chess.StatusActive); err == nil {
+		t.Error("Expected active to be refused as a result")
+	}
+}
+
+func TestPurgeExpiredChallenges(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Hour).Format(time.RFC3339)
+	current := now.Add(time.Hour).Format(time.RFC3339)
+	pds := newFakePDS(t)
+	pds.put("at://did:plc:test123/app.atchess.challenge/ra", map[string]interface{}{"status": "pending", "expiresAt": expired})
+	pds.put("at://did:plc:test123/app.atchess.challenge/rb", map[string]interface{}{"status": "pending", "expiresAt": current})
+	pds.put("at://did:plc:test123/app.atchess.challenge/rc", map[string]interface{}{"status": "accepted", "expiresAt": expired})
+	pds.put("at://did:plc:test123/app.atchess.challengeNotification/ra", map[string]interface{}{"expiresAt": expired})
+	client := newFakeClient(t, pds)
+	ctx := context.Background()
+
+	purged, err := client.PurgeExpiredChallenges(ctx, now, true)
+	if err != nil || len(purged) != 2 || len(pds.deleted) != 0 {
+		t.Fatalf("Expected a dry run to find two records and delete none, got %v, %v, deleted %v", purged, err, pds.deleted)
+	}
+
+	purged, err = client.PurgeExpiredChallenges(ctx, now, false)
+	if err != nil {
+		t.Fatalf("Failed to purge challenges: %v", err)
+	}
+	want := []string{"app.atchess.challenge/ra", "app.atchess.challengeNotification/ra"}
+	if len(pds.deleted) != len(want) || pds.deleted[0] != want[0] || pds.deleted[1] != want[1] {
+		t.Errorf("Expected %v deleted, got %v", want, pds.deleted)
+	}
+	if len(purged) != 2 || purged[0] != "at://did:plc:test123/app.atchess.challenge/ra" {
+		t.Errorf("Unexpected purged URIs %v", purged)
+	}
+}
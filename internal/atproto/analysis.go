@@ -0,0 +1,119 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// AnalysisRecord is an app.atchess.analysis record: a computer analysis of a
+// finished game, kept under the same record key as the game
+type AnalysisRecord struct {
+	URI       string `json:"uri"`
+	CreatedAt string `json:"createdAt"`
+	Game      string `json:"game"`   // URI of the analysed game
+	Engine    string `json:"engine"` // e.g. "stockfish"
+	Depth     int    `json:"depth"`
+	*chess.GameAnalysis
+}
+
+// PublishAnalysis writes a game's analysis to the current account's
+// repository, replacing any earlier analysis of the same game
+func (c *Client) PublishAnalysis(ctx context.Context, analysis *AnalysisRecord) error {
+	rkey, err := analysisKey(analysis.Game)
+	if err != nil {
+		return err
+	}
+
+	putReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.analysis",
+		"rkey":       rkey,
+		"record": map[string]interface{}{
+			"$type":     "app.atchess.analysis",
+			"createdAt": analysis.CreatedAt,
+			"game":      analysis.Game,
+			"engine":    analysis.Engine,
+			"depth":     analysis.Depth,
+			"moves":     analysis.Moves,
+			"white":     analysis.White,
+			"black":     analysis.Black,
+		},
+	}
+
+	reqBody, _ := json.Marshal(putReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to publish analysis record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to publish analysis record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var putResp struct {
+		URI string `json:"uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&putResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	analysis.URI = putResp.URI
+	return nil
+}
+
+// GetAnalysis returns the analysis of a game published in did's repository,
+// or nil if there is none
+func (c *Client) GetAnalysis(ctx context.Context, did, gameURI string) (*AnalysisRecord, error) {
+	rkey, err := analysisKey(gameURI)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"repo": {did}, "collection": {"app.atchess.analysis"}, "rkey": {rkey}}
+	resp, err := c.makeRequest(ctx, "GET", c.pdsURL+"/xrpc/com.atproto.repo.getRecord?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analysis record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		var xrpcErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &xrpcErr) == nil && xrpcErr.Error == "RecordNotFound" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get analysis record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var getResp struct {
+		URI   string         `json:"uri"`
+		Value AnalysisRecord `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&getResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if getResp.Value.GameAnalysis == nil || getResp.Value.Game != gameURI {
+		return nil, nil
+	}
+	getResp.Value.URI = getResp.URI
+	return &getResp.Value, nil
+}
+
+// analysisKey is the record key of a game's analysis: the game's own
+func analysisKey(gameURI string) (string, error) {
+	parts := strings.Split(gameURI, "/")
+	if len(parts) < 5 || !strings.HasPrefix(gameURI, "at://") {
+		return "", fmt.Errorf("invalid AT Protocol URI format: %s", gameURI)
+	}
+	return parts[4], nil
+}
@@ -0,0 +1,116 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// AnnouncementRecord is an app.atchess.announcement record. Its record key is
+// the announcement's ID.
+type AnnouncementRecord struct {
+	URI       string `json:"-"`
+	CID       string `json:"-"`
+	CreatedAt string `json:"createdAt"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	Level     string `json:"level"`
+	Author    string `json:"author"`
+	StartsAt  string `json:"startsAt,omitempty"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+}
+
+// PublishAnnouncement writes an announcement to the current account's
+// repository under id, and sets its URI and CID. The PDS signs the commit
+// that adds it with the account's signing key.
+func (c *Client) PublishAnnouncement(ctx context.Context, id string, announcement *AnnouncementRecord) error {
+	record := map[string]interface{}{
+		"$type":     "app.atchess.announcement",
+		"createdAt": announcement.CreatedAt,
+		"title":     announcement.Title,
+		"message":   announcement.Message,
+		"level":     announcement.Level,
+		"author":    announcement.Author,
+	}
+	if announcement.StartsAt != "" {
+		record["startsAt"] = announcement.StartsAt
+	}
+	if announcement.ExpiresAt != "" {
+		record["expiresAt"] = announcement.ExpiresAt
+	}
+
+	putReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.announcement",
+		"rkey":       id,
+		"record":     record,
+	}
+
+	reqBody, _ := json.Marshal(putReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to publish announcement record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to publish announcement record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var putResp struct {
+		URI string `json:"uri"`
+		CID string `json:"cid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&putResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	announcement.URI = putResp.URI
+	announcement.CID = putResp.CID
+	return nil
+}
+
+// ListAnnouncements returns the announcement records in the current
+// account's repository
+func (c *Client) ListAnnouncements(ctx context.Context) ([]*AnnouncementRecord, error) {
+	var announcements []*AnnouncementRecord
+	err := c.listAllRecords(ctx, c.did, "app.atchess.announcement", func(uri, cid string, value json.RawMessage) error {
+		var announcement AnnouncementRecord
+		if err := json.Unmarshal(value, &announcement); err != nil || announcement.Title == "" {
+			return nil // Skip malformed records
+		}
+		announcement.URI = uri
+		announcement.CID = cid
+		announcements = append(announcements, &announcement)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list announcements: %w", err)
+	}
+	return announcements, nil
+}
+
+// DeleteAnnouncement removes the announcement with id from the current
+// account's repository
+func (c *Client) DeleteAnnouncement(ctx context.Context, id string) error {
+	deleteReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.announcement",
+		"rkey":       id,
+	}
+
+	reqBody, _ := json.Marshal(deleteReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.deleteRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to delete announcement: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete announcement: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
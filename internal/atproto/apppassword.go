@@ -0,0 +1,98 @@
+package atproto
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Scopes a PDS puts in the access tokens createSession issues, saying what
+// kind of password the session was created with
+const (
+	ScopeAccess            = "com.atproto.access" // the account's main password
+	ScopeAppPass           = "com.atproto.appPass"
+	ScopeAppPassPrivileged = "com.atproto.appPassPrivileged" // an app password that can read DMs
+)
+
+// appPasswordPattern matches app passwords as PDSes generate them
+var appPasswordPattern = regexp.MustCompile(`^[a-z0-9]{4}-[a-z0-9]{4}-[a-z0-9]{4}-[a-z0-9]{4}$`)
+
+// LooksLikeAppPassword reports whether a password has the xxxx-xxxx-xxxx-xxxx
+// form of an app password. Only the PDS can say for sure; see
+// Client.UsesAppPassword.
+func LooksLikeAppPassword(password string) bool {
+	return appPasswordPattern.MatchString(password)
+}
+
+// tokenScope reads the scope claim of an access token. The token is the
+// PDS's own and isn't verified; an unreadable token has no scope.
+func tokenScope(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Scope string `json:"scope"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	return claims.Scope
+}
+
+// Scope returns the scope of the client's session, or "" if the PDS's access
+// token doesn't say
+func (c *Client) Scope() string {
+	return c.scope
+}
+
+// UsesAppPassword reports whether the PDS says the session was created with
+// an app password
+func (c *Client) UsesAppPassword() bool {
+	return c.scope == ScopeAppPass || c.scope == ScopeAppPassPrivileged
+}
+
+// UsesMainPassword reports whether the PDS says the session was created with
+// the account's main password, which can do anything to the account,
+// including deleting it
+func (c *Client) UsesMainPassword() bool {
+	return c.scope == ScopeAccess
+}
+
+// DeleteSession ends the client's session with
+// com.atproto.server.deleteSession, revoking its refresh token
+func (c *Client) DeleteSession(ctx context.Context) error {
+	c.tokenMu.RLock()
+	refreshJWT := c.refreshJWT
+	c.tokenMu.RUnlock()
+	if refreshJWT == "" {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.server.deleteSession", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.authorize(req, refreshJWT)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete session: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
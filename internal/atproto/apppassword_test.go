@@ -0,0 +1,92 @@
+package atproto
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// scopedJWT returns a JWT-shaped token carrying scope, as a PDS issues
+func scopedJWT(scope string) string {
+	claims, _ := json.Marshal(map[string]string{"scope": scope, "sub": "did:plc:test123"})
+	return "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+}
+
+func TestLooksLikeAppPassword(t *testing.T) {
+	for password, want := range map[string]bool{
+		"abcd-efgh-ijkl-mnop": true,
+		"ab12-cd34-ef56-gh78": true,
+		"hunter2":             false,
+		"ABCD-EFGH-IJKL-MNOP": false,
+		"abcd-efgh-ijkl":      false,
+	} {
+		if got := LooksLikeAppPassword(password); got != want {
+			t.Errorf("LooksLikeAppPassword(%q) = %v, want %v", password, got, want)
+		}
+	}
+}
+
+func TestTokenScope(t *testing.T) {
+	if got := tokenScope(scopedJWT(ScopeAppPass)); got != ScopeAppPass {
+		t.Errorf("Expected %s, got %q", ScopeAppPass, got)
+	}
+	for _, token := range []string{"test-jwt", "a.!!!.c", "a." + base64.RawURLEncoding.EncodeToString([]byte("nope")) + ".c"} {
+		if got := tokenScope(token); got != "" {
+			t.Errorf("Expected no scope for %q, got %q", token, got)
+		}
+	}
+}
+
+func TestClientPasswordKind(t *testing.T) {
+	var scope string
+	var deleted string
+	pds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/xrpc/com.atproto.server.createSession":
+			json.NewEncoder(w).Encode(map[string]string{
+				"accessJwt":  scopedJWT(scope),
+				"refreshJwt": "refresh-1",
+				"did":        "did:plc:test123",
+				"handle":     "test.user",
+			})
+		case "/xrpc/com.atproto.server.deleteSession":
+			deleted = r.Header.Get("Authorization")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer pds.Close()
+
+	tests := []struct {
+		scope     string
+		app, main bool
+	}{
+		{ScopeAccess, false, true},
+		{ScopeAppPass, true, false},
+		{ScopeAppPassPrivileged, true, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		scope = tt.scope
+		client, err := NewClient(pds.URL, "test.user", "password")
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		if client.UsesAppPassword() != tt.app || client.UsesMainPassword() != tt.main {
+			t.Errorf("Scope %q: expected app=%v main=%v, got app=%v main=%v",
+				tt.scope, tt.app, tt.main, client.UsesAppPassword(), client.UsesMainPassword())
+		}
+	}
+
+	client, _ := NewClient(pds.URL, "test.user", "password")
+	if err := client.DeleteSession(context.Background()); err != nil {
+		t.Fatalf("Failed to delete session: %v", err)
+	}
+	if deleted != "Bearer refresh-1" {
+		t.Errorf("Expected the session to be deleted with the refresh token, got %q", deleted)
+	}
+}
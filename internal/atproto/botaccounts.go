@@ -0,0 +1,34 @@
+package atproto
+
+// SetBotAccounts sets the DIDs of bot accounts, whose moves are made by
+// programs through the bot API. Games we create record which of their
+// players are bots.
+func (c *Client) SetBotAccounts(dids []string) {
+	c.botAccounts = dids
+}
+
+// BotAccounts returns the DIDs of bot accounts
+func (c *Client) BotAccounts() []string {
+	return c.botAccounts
+}
+
+// IsBotAccount reports whether did is a bot account
+func (c *Client) IsBotAccount(did string) bool {
+	for _, bot := range c.botAccounts {
+		if bot == did {
+			return true
+		}
+	}
+	return false
+}
+
+// botAccountsIn returns the players that are bot accounts
+func (c *Client) botAccountsIn(players ...string) []string {
+	var bots []string
+	for _, did := range players {
+		if c.IsBotAccount(did) {
+			bots = append(bots, did)
+		}
+	}
+	return bots
+}
@@ -0,0 +1,241 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+)
+
+// CapabilityTTL is how long the results of a capability probe are cached
+const CapabilityTTL = 10 * time.Minute
+
+// probeConcurrency bounds how many repos ProbeRepos checks at once
+const probeConcurrency = 4
+
+// probeRKey is the record key written to when probing. It isn't a TID, so it
+// never names a record ATChess created.
+const probeRKey = "atchess-capability-probe"
+
+// RepoCapabilities describes what the client can do in a repository
+type RepoCapabilities struct {
+	Repo string `json:"repo"`
+	// Readable is true when our PDS hosts the repo and can serve its records
+	Readable bool `json:"readable"`
+	// Private is true when we can read the account's private data, which is
+	// only ever the case for our own repo
+	Private bool `json:"private"`
+	// Collections lists the collections the repo holds, when readable
+	Collections []string `json:"collections,omitempty"`
+	// Writable records, for each probed collection, whether we can write to it
+	Writable  map[string]bool `json:"writable"`
+	CheckedAt time.Time       `json:"checkedAt"`
+}
+
+// CanWrite reports whether a probe found the collection writable
+func (rc *RepoCapabilities) CanWrite(collection string) bool {
+	return rc.Writable[collection]
+}
+
+// capabilityCache holds probe results by repo
+type capabilityCache struct {
+	mu      sync.Mutex
+	entries map[string]*RepoCapabilities
+}
+
+// get returns a copy of the cached capabilities of a repo if they're fresh
+// and cover every collection
+func (cc *capabilityCache) get(repo string, collections []string, now time.Time) (*RepoCapabilities, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cached, ok := cc.entries[repo]
+	if !ok || now.Sub(cached.CheckedAt) > CapabilityTTL {
+		return nil, false
+	}
+	for _, collection := range collections {
+		if _, ok := cached.Writable[collection]; !ok {
+			return nil, false
+		}
+	}
+	return cached.copy(), true
+}
+
+// put stores probe results, keeping collections probed earlier if the
+// cached entry is still fresh
+func (cc *capabilityCache) put(rc *RepoCapabilities) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.entries == nil {
+		cc.entries = make(map[string]*RepoCapabilities)
+	}
+	stored := rc.copy()
+	if cached, ok := cc.entries[rc.Repo]; ok && rc.CheckedAt.Sub(cached.CheckedAt) <= CapabilityTTL {
+		for collection, writable := range cached.Writable {
+			if _, ok := stored.Writable[collection]; !ok {
+				stored.Writable[collection] = writable
+			}
+		}
+	}
+	cc.entries[rc.Repo] = stored
+}
+
+func (rc *RepoCapabilities) copy() *RepoCapabilities {
+	copied := *rc
+	copied.Collections = append([]string(nil), rc.Collections...)
+	copied.Writable = make(map[string]bool, len(rc.Writable))
+	for collection, writable := range rc.Writable {
+		copied.Writable[collection] = writable
+	}
+	return &copied
+}
+
+// Capabilities determines what the client can do in a repository: whether it
+// can read the repo, read private data, and write each of the collections.
+// Results are cached for CapabilityTTL. A probe that fails for a transient
+// reason returns an error and isn't cached.
+//
+// Writes are probed by deleting a record that doesn't exist, which the PDS
+// accepts without changing the repo when we're allowed to write to it.
+func (c *Client) Capabilities(ctx context.Context, repo string, collections ...string) (*RepoCapabilities, error) {
+	if cached, ok := c.capabilities.get(repo, collections, time.Now()); ok {
+		return cached, nil
+	}
+
+	rc := &RepoCapabilities{
+		Repo:      repo,
+		Private:   repo == c.did,
+		Writable:  make(map[string]bool, len(collections)),
+		CheckedAt: time.Now(),
+	}
+
+	described, err := c.describeRepo(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	if described != nil {
+		rc.Readable = true
+		rc.Collections = described.Collections
+	}
+
+	for _, collection := range collections {
+		switch {
+		case repo == c.did:
+			rc.Writable[collection] = true
+		case !rc.Readable:
+			// Our PDS can only write to repos it hosts
+			rc.Writable[collection] = false
+		default:
+			writable, err := c.probeWrite(ctx, repo, collection)
+			if err != nil {
+				return nil, err
+			}
+			rc.Writable[collection] = writable
+		}
+	}
+
+	c.capabilities.put(rc)
+	return rc, nil
+}
+
+// ProbeRepos checks the capabilities of several repositories at once. Repos
+// whose probe failed are missing from the result and reported in the error.
+func (c *Client) ProbeRepos(ctx context.Context, repos []string, collections ...string) (map[string]*RepoCapabilities, error) {
+	results := make(map[string]*RepoCapabilities, len(repos))
+	var errs []error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, probeConcurrency)
+
+	seen := make(map[string]bool, len(repos))
+	for _, repo := range repos {
+		if seen[repo] {
+			continue
+		}
+		seen[repo] = true
+		wg.Add(1)
+		go func(repo string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			rc, err := c.Capabilities(ctx, repo, collections...)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", repo, err))
+				return
+			}
+			results[repo] = rc
+		}(repo)
+	}
+	wg.Wait()
+
+	return results, errors.Join(errs...)
+}
+
+// CanWrite reports whether the client can write to a collection in a repo.
+// If the probe fails, it assumes the write isn't possible.
+func (c *Client) CanWrite(ctx context.Context, repo, collection string) bool {
+	rc, err := c.Capabilities(ctx, repo, collection)
+	return err == nil && rc.CanWrite(collection)
+}
+
+// describedRepo is the part of a com.atproto.repo.describeRepo response we use
+type describedRepo struct {
+	Collections []string `json:"collections"`
+}
+
+// describeRepo asks our PDS about a repo, returning nil if it doesn't host it
+func (c *Client) describeRepo(ctx context.Context, repo string) (*describedRepo, error) {
+	url := fmt.Sprintf("%s/xrpc/com.atproto.repo.describeRepo?repo=%s", c.pdsURL, neturl.QueryEscape(repo))
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe repo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		var described describedRepo
+		if err := json.NewDecoder(resp.Body).Decode(&described); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		return &described, nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return nil, nil
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to describe repo: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+}
+
+// probeWrite tries to delete a record that doesn't exist
+func (c *Client) probeWrite(ctx context.Context, repo, collection string) (bool, error) {
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"repo":       repo,
+		"collection": collection,
+		"rkey":       probeRKey,
+	})
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.deleteRecord", reqBody)
+	if err != nil {
+		return false, fmt.Errorf("failed to probe %s: %w", collection, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return false, nil
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("failed to probe %s: HTTP %d - %s", collection, resp.StatusCode, string(body))
+	}
+}
@@ -0,0 +1,107 @@
+package atproto
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newCapabilityPDS hosts our repo and did:plc:neighbour, which lets us write
+// to app.atchess.challengeNotification only, and did:plc:flaky, which fails
+func newCapabilityPDS(t *testing.T) *fakePDS {
+	t.Helper()
+	pds := newFakePDS(t)
+	pds.host("did:plc:neighbour", "app.atchess.challengeNotification")
+	pds.put("at://did:plc:neighbour/app.atchess.game/g1", map[string]interface{}{"status": "active"})
+	pds.breakRepo("did:plc:flaky", http.StatusNotImplemented)
+	return pds
+}
+
+// probes returns the write probes made, checking they used the probe record key
+func probes(t *testing.T, pds *fakePDS) int {
+	t.Helper()
+	pds.mu.Lock()
+	for _, deleted := range pds.deleted {
+		if !strings.HasSuffix(deleted, "/"+probeRKey) {
+			t.Errorf("Expected the probe record key, got %q", deleted)
+		}
+	}
+	pds.mu.Unlock()
+	return pds.requests("com.atproto.repo.deleteRecord")
+}
+
+func TestCapabilitiesProbesWrites(t *testing.T) {
+	pds := newCapabilityPDS(t)
+	client := newFakeClient(t, pds)
+	ctx := context.Background()
+
+	rc, err := client.Capabilities(ctx, "did:plc:neighbour", "app.atchess.challengeNotification", "app.atchess.game")
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	if !rc.Readable || rc.Private || !rc.CanWrite("app.atchess.challengeNotification") || rc.CanWrite("app.atchess.game") {
+		t.Errorf("Unexpected capabilities: %+v", rc)
+	}
+
+	own, _ := client.Capabilities(ctx, "did:plc:test123", "app.atchess.game")
+	if !own.Private || !own.CanWrite("app.atchess.game") {
+		t.Errorf("Expected full access to our own repo, got %+v", own)
+	}
+
+	// Repos elsewhere can't be written to, so there's nothing to probe
+	remote, _ := client.Capabilities(ctx, "did:plc:elsewhere", "app.atchess.challengeNotification")
+	if remote.Readable || remote.CanWrite("app.atchess.challengeNotification") {
+		t.Errorf("Expected no access to a repo on another PDS, got %+v", remote)
+	}
+	if probes := probes(t, pds); probes != 2 {
+		t.Errorf("Expected 2 write probes, got %d", probes)
+	}
+}
+
+func TestCapabilitiesAreCached(t *testing.T) {
+	pds := newCapabilityPDS(t)
+	client := newFakeClient(t, pds)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if !client.CanWrite(ctx, "did:plc:neighbour", "app.atchess.challengeNotification") {
+			t.Fatal("Expected the collection to be writable")
+		}
+	}
+	if describes, probes := pds.requests("com.atproto.repo.describeRepo"), probes(t, pds); describes != 1 || probes != 1 {
+		t.Errorf("Expected one probe, got %d describes and %d write probes", describes, probes)
+	}
+
+	// A collection not probed before is checked on its own
+	if client.CanWrite(ctx, "did:plc:neighbour", "app.atchess.game") {
+		t.Error("Expected the game collection not to be writable")
+	}
+	rc, _ := client.Capabilities(ctx, "did:plc:neighbour", "app.atchess.challengeNotification", "app.atchess.game")
+	if probes := probes(t, pds); len(rc.Writable) != 2 || probes != 2 {
+		t.Errorf("Expected both collections cached after 2 probes, got %v after %d", rc.Writable, probes)
+	}
+}
+
+func TestProbeReposReportsFailures(t *testing.T) {
+	pds := newCapabilityPDS(t)
+	client := newFakeClient(t, pds)
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+	repos := []string{"did:plc:neighbour", "did:plc:elsewhere", "did:plc:flaky", "did:plc:neighbour"}
+	results, err := client.ProbeRepos(context.Background(), repos, "app.atchess.challengeNotification")
+	if err == nil {
+		t.Error("Expected the failed probe to be reported")
+	}
+	if len(results) != 2 || !results["did:plc:neighbour"].CanWrite("app.atchess.challengeNotification") || results["did:plc:elsewhere"] == nil {
+		t.Errorf("Unexpected results: %v", results)
+	}
+
+	// Failures aren't cached
+	if _, err := client.Capabilities(context.Background(), "did:plc:flaky"); err == nil {
+		t.Error("Expected the probe to be retried and fail again")
+	}
+	if describes := pds.requests("com.atproto.repo.describeRepo"); describes != 4 {
+		t.Errorf("Expected 4 describes, got %d", describes)
+	}
+}
@@ -0,0 +1,366 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNotChallenger is returned when someone other than the challenger tries
+// to cancel a challenge
+var ErrNotChallenger = errors.New("challenge was not issued by this user")
+
+// ErrChallengeRefused is returned when challenging a player who blocks the
+// challenger or has them on their challenge deny-list
+var ErrChallengeRefused = errors.New("player does not accept challenges from this user")
+
+// ErrChallengeLimit is returned when the challenger already has as many
+// pending challenges out to a player as the challenge cap allows
+var ErrChallengeLimit = errors.New("too many pending challenges to this player")
+
+// SetChallengeCap limits how many pending challenges one player may have out
+// to another. Challenges past the cap aren't created, and notifications past
+// it aren't listed. Zero, the default, is no limit.
+func (c *Client) SetChallengeCap(n int) {
+	c.challengeCap = max(n, 0)
+}
+
+// ChallengeCap returns how many pending challenges one player may have out to another
+func (c *Client) ChallengeCap() int {
+	return c.challengeCap
+}
+
+// RefusesChallenges reports whether challenged refuses challenges from
+// challenger, because they block them on Bluesky or have them on the
+// deny-list in their app.atchess.settings record
+func (c *Client) RefusesChallenges(ctx context.Context, challenged, challenger string) (bool, error) {
+	denied, err := c.challengeDenials(ctx, challenged)
+	if err != nil {
+		return false, err
+	}
+	return denied[challenger], nil
+}
+
+// challengeDenials returns the DIDs a player refuses challenges from: those
+// on their deny-list and those they block
+func (c *Client) challengeDenials(ctx context.Context, did string) (map[string]bool, error) {
+	denied := make(map[string]bool)
+	settings, err := c.GetSettings(ctx, did)
+	switch {
+	case err == nil:
+		for _, deniedDID := range settings.ChallengeDenyList {
+			denied[deniedDID] = true
+		}
+	case !errors.Is(err, ErrNoSettings):
+		return nil, err
+	}
+
+	err = c.listAllRecords(ctx, did, "app.bsky.graph.block", func(uri, cid string, value json.RawMessage) error {
+		var block struct {
+			Subject string `json:"subject"`
+		}
+		if json.Unmarshal(value, &block) == nil && block.Subject != "" {
+			denied[block.Subject] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocks: %w", err)
+	}
+	return denied, nil
+}
+
+// mutedDIDs returns the accounts the user has muted on Bluesky. Mutes are
+// private, so only the user's own can be read, and none are returned if the
+// PDS can't answer.
+func (c *Client) mutedDIDs(ctx context.Context) map[string]bool {
+	muted := make(map[string]bool)
+	cursor := ""
+	for {
+		url := c.pdsURL + "/xrpc/app.bsky.graph.getMutes?limit=100"
+		if cursor != "" {
+			url += "&cursor=" + neturl.QueryEscape(cursor)
+		}
+		resp, err := c.makeRequest(ctx, "GET", url, nil)
+		if err != nil {
+			return muted
+		}
+		var page struct {
+			Mutes []struct {
+				DID string `json:"did"`
+			} `json:"mutes"`
+			Cursor string `json:"cursor"`
+		}
+		ok := resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&page) == nil
+		resp.Body.Close()
+		if !ok {
+			return muted
+		}
+		for _, mute := range page.Mutes {
+			muted[mute.DID] = true
+		}
+		if page.Cursor == "" || page.Cursor == cursor || len(page.Mutes) == 0 {
+			return muted
+		}
+		cursor = page.Cursor
+	}
+}
+
+// pendingChallengesTo counts the user's challenges to opponent that are
+// still waiting for an answer at now
+func (c *Client) pendingChallengesTo(ctx context.Context, opponentDID string, now time.Time) (int, error) {
+	pending := 0
+	err := c.listAllRecords(ctx, c.did, "app.atchess.challenge", func(uri, cid string, value json.RawMessage) error {
+		var record struct {
+			Challenged string `json:"challenged"`
+			Status     string `json:"status"`
+			ExpiresAt  string `json:"expiresAt"`
+		}
+		if json.Unmarshal(value, &record) != nil || record.Challenged != opponentDID || record.Status != "pending" {
+			return nil
+		}
+		if expiry, err := time.Parse(time.RFC3339, record.ExpiresAt); err == nil && !expiry.After(now) {
+			return nil
+		}
+		pending++
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list challenges: %w", err)
+	}
+	return pending, nil
+}
+
+// filterChallengeNotifications drops notifications from players the user
+// blocks, mutes or denies challenges, and any past the challenge cap from
+// one challenger. Notifications come newest first, so the newest are kept.
+func (c *Client) filterChallengeNotifications(ctx context.Context, notifications []*ChallengeNotification) []*ChallengeNotification {
+	if len(notifications) == 0 {
+		return notifications
+	}
+	denied, err := c.challengeDenials(ctx, c.did)
+	if err != nil {
+		log.Warn().Err(err).Str("did", c.did).Msg("Could not read who challenges are refused from")
+	}
+	muted := c.mutedDIDs(ctx)
+
+	kept := notifications[:0]
+	perChallenger := make(map[string]int)
+	for _, notification := range notifications {
+		if denied[notification.Challenger] || muted[notification.Challenger] {
+			continue
+		}
+		perChallenger[notification.Challenger]++
+		if c.challengeCap > 0 && perChallenger[notification.Challenger] > c.challengeCap {
+			continue
+		}
+		kept = append(kept, notification)
+	}
+	return kept
+}
+
+// CancelChallenge withdraws a pending challenge the user issued and deletes
+// the notification it left in the challenged player's repository, if we can
+// write there. Challenges that were answered or have expired can't be
+// cancelled.
+func (c *Client) CancelChallenge(ctx context.Context, challengeURI string) (*chess.Challenge, error) {
+	if parts := strings.Split(challengeURI, "/"); len(parts) < 5 || parts[2] != c.did {
+		return nil, ErrNotChallenger
+	}
+	challengeCID, challengeValue, err := c.getRecord(ctx, "app.atchess.challenge", challengeURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if challenger, _ := challengeValue["challenger"].(string); challenger != c.did {
+		return nil, ErrNotChallenger
+	}
+	if status, _ := challengeValue["status"].(string); status != "pending" {
+		return nil, fmt.Errorf("%w: current status %s", ErrChallengeNotPending, status)
+	}
+	if expiresAt, ok := challengeValue["expiresAt"].(string); ok {
+		if expiry, err := time.Parse(time.RFC3339, expiresAt); err == nil && expiry.Before(time.Now()) {
+			return nil, fmt.Errorf("%w: challenge expired at %s", ErrChallengeNotPending, expiresAt)
+		}
+	}
+
+	challengeValue["status"] = "cancelled"
+	challengeValue["cancelledAt"] = time.Now().UTC().Format(time.RFC3339)
+	if err := c.updateChallengeRecord(ctx, challengeURI, challengeCID, challengeValue); err != nil {
+		return nil, err
+	}
+
+	challenged, _ := challengeValue["challenged"].(string)
+	if _, err := c.deleteChallengeNotifications(ctx, challenged, map[string]bool{challengeURI: true}); err != nil {
+		log.Warn().Err(err).Str("challenge", challengeURI).Msg("Could not delete challenge notification")
+	}
+	return c.GetChallenge(ctx, challengeURI)
+}
+
+// deleteChallengeNotifications deletes the notifications for the given
+// challenges from another player's repository, returning how many were
+// deleted. Most PDSes won't let us write there, in which case nothing is
+// deleted and the player's own cleanup prunes them later.
+func (c *Client) deleteChallengeNotifications(ctx context.Context, repo string, challengeURIs map[string]bool) (int, error) {
+	const collection = "app.atchess.challengeNotification"
+	if repo == "" || !c.CanWrite(ctx, repo, collection) {
+		return 0, nil
+	}
+
+	var rkeys []string
+	err := c.listAllRecords(ctx, repo, collection, func(uri, cid string, value json.RawMessage) error {
+		var record struct {
+			Challenge struct {
+				URI string `json:"uri"`
+			} `json:"challenge"`
+		}
+		if json.Unmarshal(value, &record) != nil || !challengeURIs[record.Challenge.URI] {
+			return nil
+		}
+		if parts := strings.Split(uri, "/"); len(parts) == 5 {
+			rkeys = append(rkeys, parts[4])
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list challenge notifications: %w", err)
+	}
+
+	deleted := 0
+	for _, rkey := range rkeys {
+		reqBody, _ := json.Marshal(map[string]interface{}{
+			"repo":       repo,
+			"collection": collection,
+			"rkey":       rkey,
+		})
+		resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.deleteRecord", reqBody)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete notification: %w", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return deleted, fmt.Errorf("failed to delete notification: HTTP %d - %s", resp.StatusCode, string(body))
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// ChallengeCleanup reports what CleanupChallenges changed
+type ChallengeCleanup struct {
+	Expired int `json:"expired"` // challenges marked expired
+	Pruned  int `json:"pruned"`  // notifications deleted
+}
+
+// CleanupChallenges tidies up challenges nobody answered. The user's pending
+// challenges that expired before now are marked expired, and the
+// notifications they left with the challenged players are deleted where we
+// can write to their repositories. Notifications in the user's own
+// repository are pruned once their challenge has expired or is no longer
+// pending.
+func (c *Client) CleanupChallenges(ctx context.Context, now time.Time) (ChallengeCleanup, error) {
+	var cleanup ChallengeCleanup
+
+	updates := make(map[string]map[string]interface{})
+	expired := make(map[string]map[string]bool) // challenged DID -> challenge URIs
+	err := c.listAllRecords(ctx, c.did, "app.atchess.challenge", func(uri, cid string, value json.RawMessage) error {
+		var record map[string]interface{}
+		if json.Unmarshal(value, &record) != nil {
+			return nil
+		}
+		if status, _ := record["status"].(string); status != "pending" {
+			return nil
+		}
+		expiresAt, _ := record["expiresAt"].(string)
+		if expiry, err := time.Parse(time.RFC3339, expiresAt); err != nil || expiry.After(now) {
+			return nil
+		}
+		record["status"] = "expired"
+		record["expiredAt"] = now.UTC().Format(time.RFC3339)
+		updates[uri[strings.LastIndex(uri, "/")+1:]] = record
+
+		challenged, _ := record["challenged"].(string)
+		if expired[challenged] == nil {
+			expired[challenged] = make(map[string]bool)
+		}
+		expired[challenged][uri] = true
+		return nil
+	})
+	if err != nil {
+		return cleanup, fmt.Errorf("failed to list challenges: %w", err)
+	}
+
+	failures := c.updateRecordsBatched(ctx, "app.atchess.challenge", updates)
+	cleanup.Expired = len(updates) - len(failures)
+	for rkey := range failures {
+		for _, uris := range expired {
+			delete(uris, recordURI(c.did, "app.atchess.challenge", rkey))
+		}
+	}
+	for challenged, uris := range expired {
+		pruned, err := c.deleteChallengeNotifications(ctx, challenged, uris)
+		if err != nil {
+			log.Warn().Err(err).Str("challenged", challenged).Msg("Could not delete challenge notifications")
+		}
+		cleanup.Pruned += pruned
+	}
+
+	pruned, err := c.pruneChallengeNotifications(ctx, now)
+	cleanup.Pruned += pruned
+	if err != nil {
+		return cleanup, err
+	}
+	for _, err := range failures {
+		return cleanup, fmt.Errorf("failed to expire %d challenges: %w", len(failures), err)
+	}
+	return cleanup, nil
+}
+
+// pruneChallengeNotifications deletes the notifications in the user's
+// repository whose challenge expired before now or was answered, cancelled
+// or expired by the challenger. Notifications whose challenge can't be read
+// are kept until they expire.
+func (c *Client) pruneChallengeNotifications(ctx context.Context, now time.Time) (int, error) {
+	var rkeys []string
+	err := c.listAllRecords(ctx, c.did, "app.atchess.challengeNotification", func(uri, cid string, value json.RawMessage) error {
+		var record struct {
+			Challenge struct {
+				URI string `json:"uri"`
+			} `json:"challenge"`
+			ExpiresAt string `json:"expiresAt"`
+		}
+		if json.Unmarshal(value, &record) != nil {
+			return nil
+		}
+		stale := false
+		if expiry, err := time.Parse(time.RFC3339, record.ExpiresAt); err == nil && !expiry.After(now) {
+			stale = true
+		} else if _, challenge, err := c.getRecord(ctx, "app.atchess.challenge", record.Challenge.URI); err == nil {
+			status, _ := challenge["status"].(string)
+			stale = status != "pending"
+		}
+		if parts := strings.Split(uri, "/"); stale && len(parts) == 5 {
+			rkeys = append(rkeys, parts[4])
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list challenge notifications: %w", err)
+	}
+
+	failures := c.deleteRecordsBatched(ctx, "app.atchess.challengeNotification", rkeys)
+	for _, err := range failures {
+		return len(rkeys) - len(failures), fmt.Errorf("failed to prune %d challenge notifications: %w", len(failures), err)
+	}
+	return len(rkeys), nil
+}
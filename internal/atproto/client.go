@@ -6,30 +6,75 @@ import (
 	"crypto/sha256"
 	"encoding/base32"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	neturl "net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/justinabrahms/atchess/internal/auth"
 	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/identity"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/oauth"
+	"github.com/justinabrahms/atchess/internal/requestid"
+	"github.com/rs/zerolog/log"
 )
 
 type Client struct {
 	pdsURL      string
 	accessJWT   string
+	refreshJWT  string
 	did         string
 	handle      string
+	email       string // confirmed address from createSession, if any
+	scope       string // from the createSession access token, if it says
 	httpClient  *http.Client
 	dpopManager *auth.DPoPManager
 	useDPoP     bool
+	retry       RetryPolicy
+	pageSize    int // records requested per listRecords page
+	
+	// records caches getRecord responses, if set
+	records *RecordCache
+	
+	// capabilities caches what we can do in other repos
+	capabilities capabilityCache
+	
+	// botAccounts are the DIDs marked as bot accounts in games we create
+	botAccounts []string
+	
+	// moveIndex, if set, answers lookups of games' latest moves
+	moveIndex *index.Indexer
+	
+	// drawOfferTimeout is how long draw offers stay open unanswered; 0 for no limit
+	drawOfferTimeout time.Duration
+	
+	// challengeCap is how many pending challenges one player may have out to
+	// another; 0 for no limit
+	challengeCap int
+	
+	// resolver resolves and caches handles and DIDs
+	resolver *identity.Resolver
+	
+	// clockPauses, if set, reports time games' clocks stood still
+	clockPauses ClockPauses
+	
+	// tokenMu guards the tokens; refreshMu lets one request at a time refresh them
+	tokenMu   sync.RWMutex
+	refreshMu sync.Mutex
 }
 
 // generateGameID creates a deterministic record key for a game based on challenge parameters
 func generateGameID(challengerDID, challengedDID string, timestamp time.Time) string {
-	// Create deterministic input from challenge parameters
-	input := fmt.Sprintf("%s:%s:%d", challengerDID, challengedDID, timestamp.Unix())
+	// Create deterministic input from challenge parameters. Nanoseconds keep
+	// two challenges between the same players in the same second apart.
+	input := fmt.Sprintf("%s:%s:%d", challengerDID, challengedDID, timestamp.UnixNano())
 	
 	// Hash the input
 	hash := sha256.Sum256([]byte(input))
@@ -95,12 +140,7 @@ func NewClientWithDPoP(pdsURL, handle, password string, useDPoP bool) (*Client,
 		return nil, fmt.Errorf("failed to create session: HTTP %d", resp.StatusCode)
 	}
 	
-	var session struct {
-		AccessJwt string `json:"accessJwt"`
-		Did       string `json:"did"`
-		Handle    string `json:"handle"`
-	}
-	
+	var session sessionResponse
 	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
 		return nil, fmt.Errorf("failed to decode session response: %w", err)
 	}
@@ -108,57 +148,185 @@ func NewClientWithDPoP(pdsURL, handle, password string, useDPoP bool) (*Client,
 	client := &Client{
 		pdsURL:      pdsURL,
 		accessJWT:   session.AccessJwt,
+		refreshJWT:  session.RefreshJwt,
 		did:         session.Did,
 		handle:      session.Handle,
 		httpClient:  httpClient,
 		dpopManager: dpopManager,
 		useDPoP:     useDPoP,
+		retry:       DefaultRetryPolicy,
+		pageSize:    MaxListPageSize,
+		resolver:    identity.New(identity.Options{PDSURL: pdsURL}),
+		scope:       tokenScope(session.AccessJwt),
+	}
+
+	if session.EmailConfirmed {
+		client.email = session.Email
 	}
 
 	// If using DPoP, update the HTTP client to use the interceptor
 	if useDPoP {
-		client.httpClient = auth.NewDPoPClient(dpopManager, func() string {
-			return client.accessJWT
-		})
+		client.httpClient = auth.NewDPoPClient(dpopManager, client.token)
 	}
 
 	return client, nil
 }
 
+// NewClientWithOAuthSession creates a client acting as the user who
+// authorized an OAuth session. Requests carry the session's access token,
+// bound by DPoP proofs signed with the session's key. OAuth refresh tokens
+// are redeemed at the authorization server rather than the PDS, so the
+// client can't refresh the session; once the access token expires the user
+// logs in again.
+func NewClientWithOAuthSession(pdsURL string, session *oauth.Session) (*Client, error) {
+	if session.DPoPKey == nil {
+		return nil, errors.New("OAuth session has no DPoP key")
+	}
+	dpopManager, err := auth.NewDPoPManagerWithKey(session.DPoPKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DPoP manager: %w", err)
+	}
+	
+	client := &Client{
+		pdsURL:      pdsURL,
+		accessJWT:   session.AccessToken,
+		did:         session.DID,
+		handle:      session.Handle,
+		dpopManager: dpopManager,
+		useDPoP:     true,
+		retry:       DefaultRetryPolicy,
+		pageSize:    MaxListPageSize,
+		resolver:    identity.New(identity.Options{PDSURL: pdsURL}),
+	}
+	client.httpClient = auth.NewDPoPClient(dpopManager, client.token)
+	client.httpClient.Timeout = 30 * time.Second
+	
+	return client, nil
+}
+
 // GetDID returns the authenticated user's DID
 func (c *Client) GetDID() string {
 	return c.did
 }
 
-// makeRequest is a helper method to create and execute HTTP requests with proper authentication
-func (c *Client) makeRequest(method, url string, body []byte) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+// GetEmail returns the account's email address as its PDS reported it at
+// login, or "" if the PDS didn't or the address isn't confirmed
+func (c *Client) GetEmail() string {
+	return c.email
+}
+
+// WrapTransport installs a round tripper around the client's current transport,
+// e.g. for instrumentation or development fault injection
+func (c *Client) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.httpClient.Transport = wrap(c.httpClient.Transport)
+}
+
+// makeRequest is a helper method to create and execute HTTP requests with proper authentication.
+// Transient failures are retried according to the client's retry policy. If the
+// access token has expired, the session is refreshed and the request retried once.
+func (c *Client) makeRequest(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	return c.makeTypedRequest(ctx, method, url, "application/json", body)
+}
+
+// makeTypedRequest is makeRequest for a body that isn't JSON
+func (c *Client) makeTypedRequest(ctx context.Context, method, url, contentType string, body []byte) (resp *http.Response, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if contentType == "application/json" {
+		if err := validateWrite(url, body); err != nil {
+			return nil, err
+		}
+		defer c.records.forgetWrites(url, body)
+	}
+	ctx, span := startRequestSpan(ctx, method, url)
+	start := time.Now()
+	defer func() {
+		endRequestSpan(span, resp, err)
+		logRequest(ctx, method, url, start, resp, err)
+	}()
+	
+	token := c.token()
+	resp, err = c.doRequestWithRetry(ctx, method, url, contentType, body, token)
+	if err != nil || !isExpiredToken(resp) {
+		return resp, err
+	}
+	
+	// Without a new session, callers see the original expired token response
+	if err := c.refreshSession(token); err != nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+	addSpanEvent(ctx, "session refreshed")
+	
+	return c.doRequestWithRetry(ctx, method, url, contentType, body, c.token())
+}
+
+// doRequest sends a request authorized with the given token
+func (c *Client) doRequest(ctx context.Context, method, url, contentType string, body []byte, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	c.authorize(req, token)
 	
-	// Set authorization header based on whether DPoP is enabled
+	return c.httpClient.Do(req)
+}
+
+// authorize sets the authorization header based on whether DPoP is enabled
+func (c *Client) authorize(req *http.Request, token string) {
 	if c.useDPoP {
-		req.Header.Set("Authorization", "DPoP "+c.accessJWT)
+		req.Header.Set("Authorization", "DPoP "+token)
 	} else {
-		req.Header.Set("Authorization", "Bearer "+c.accessJWT)
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
-	
-	return c.httpClient.Do(req)
 }
 
 // CreateGameFromChallenge creates a game record using a specific rkey and challenge reference
 func (c *Client) CreateGameFromChallenge(ctx context.Context, opponentDID, color, rkey, challengeURI, challengeCID string) (*chess.Game, error) {
-	return c.createGame(ctx, opponentDID, color, &rkey, challengeURI, challengeCID)
+	return c.createGame(ctx, opponentDID, color, gameOptions{rkey: rkey, challengeURI: challengeURI, challengeCID: challengeCID})
 }
 
 func (c *Client) CreateGame(ctx context.Context, opponentDID string, color string) (*chess.Game, error) {
-	return c.createGame(ctx, opponentDID, color, nil, "", "")
+	return c.createGame(ctx, opponentDID, color, gameOptions{})
 }
 
-func (c *Client) createGame(ctx context.Context, opponentDID, color string, rkey *string, challengeURI, challengeCID string) (*chess.Game, error) {
+// CreateVariantGame creates a game played under the rules of a variant
+func (c *Client) CreateVariantGame(ctx context.Context, opponentDID, color, variant string) (*chess.Game, error) {
+	return c.createGame(ctx, opponentDID, color, gameOptions{variant: variant})
+}
+
+// CreateTimedGame creates a game played under a time control
+func (c *Client) CreateTimedGame(ctx context.Context, opponentDID, color string, timeControl *chess.TimeControl) (*chess.Game, error) {
+	return c.createGame(ctx, opponentDID, color, gameOptions{timeControl: timeControl})
+}
+
+// CreateBotGame creates a game against a human in which our side is played by
+// a chess engine at the given level
+func (c *Client) CreateBotGame(ctx context.Context, opponentDID, color string, level int) (*chess.Game, error) {
+	return c.createGame(ctx, opponentDID, color, gameOptions{botLevel: level})
+}
+
+// gameOptions are the optional parts of a new game record
+type gameOptions struct {
+	rkey         string // explicit record key; generated when empty
+	challengeURI string
+	challengeCID string
+	seekURI      string
+	seekCID      string
+	timeControl  *chess.TimeControl
+	botLevel     int
+	variant      string // empty for standard chess
+	rematchURI   string // the finished game this one is a rematch of
+	rematchCID   string
+}
+
+func (c *Client) createGame(ctx context.Context, opponentDID, color string, opts gameOptions) (*chess.Game, error) {
 	// Determine who plays white/black
 	var whiteDID, blackDID string
 	if color == "white" {
@@ -173,6 +341,11 @@ func (c *Client) createGame(ctx context.Context, opponentDID, color string, rkey
 		blackDID = opponentDID
 	}
 	
+	variant, err := chess.LookupVariant(opts.variant)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Create initial game record
 	gameRecord := map[string]interface{}{
 		"$type":     "app.atchess.game",
@@ -180,18 +353,55 @@ func (c *Client) createGame(ctx context.Context, opponentDID, color string, rkey
 		"white":     whiteDID,
 		"black":     blackDID,
 		"status":    "active",
-		"fen":       "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", // Starting position
+		"fen":       variant.StartingFEN(),
 		"pgn":       "",
 	}
 	
+	// Standard games leave the variant out, as they always have
+	if variant.Name() != chess.VariantStandard {
+		gameRecord["variant"] = variant.Name()
+	}
+	
 	// Add challenge reference if provided
-	if challengeURI != "" {
+	if opts.challengeURI != "" {
 		gameRecord["challenge"] = map[string]interface{}{
-			"uri": challengeURI,
-			"cid": challengeCID,
+			"uri": opts.challengeURI,
+			"cid": opts.challengeCID,
 		}
 	}
 	
+	if opts.seekURI != "" {
+		gameRecord["seek"] = map[string]interface{}{
+			"uri": opts.seekURI,
+			"cid": opts.seekCID,
+		}
+	}
+	
+	if opts.rematchURI != "" {
+		gameRecord["rematchOf"] = map[string]interface{}{
+			"uri": opts.rematchURI,
+			"cid": opts.rematchCID,
+		}
+	}
+	
+	if opts.timeControl != nil {
+		gameRecord["timeControl"] = timeControlRecord(opts.timeControl)
+	}
+	
+	var bot *chess.BotOpponent
+	if opts.botLevel > 0 {
+		bot = &chess.BotOpponent{Player: c.did, Level: opts.botLevel}
+		gameRecord["bot"] = map[string]interface{}{
+			"player": bot.Player,
+			"level":  bot.Level,
+		}
+	}
+	
+	botAccounts := c.botAccountsIn(whiteDID, blackDID)
+	if len(botAccounts) > 0 {
+		gameRecord["botAccounts"] = botAccounts
+	}
+	
 	// Create record in repository
 	createReq := map[string]interface{}{
 		"repo":       c.did,
@@ -200,12 +410,12 @@ func (c *Client) createGame(ctx context.Context, opponentDID, color string, rkey
 	}
 	
 	// Add explicit rkey if provided
-	if rkey != nil {
-		createReq["rkey"] = *rkey
+	if opts.rkey != "" {
+		createReq["rkey"] = opts.rkey
 	}
 	
 	reqBody, _ := json.Marshal(createReq)
-	resp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create game record: %w", err)
 	}
@@ -225,16 +435,24 @@ func (c *Client) createGame(ctx context.Context, opponentDID, color string, rkey
 	}
 	
 	return &chess.Game{
-		ID:        createResp.URI,
-		White:     whiteDID,
-		Black:     blackDID,
-		Status:    chess.StatusActive,
-		FEN:       gameRecord["fen"].(string),
-		PGN:       "",
-		CreatedAt: gameRecord["createdAt"].(string),
+		ID:          createResp.URI,
+		White:       whiteDID,
+		Black:       blackDID,
+		Status:      chess.StatusActive,
+		FEN:         gameRecord["fen"].(string),
+		PGN:         "",
+		TimeControl: opts.timeControl,
+		CreatedAt:   gameRecord["createdAt"].(string),
+		Variant:     opts.variant,
+		Bot:         bot,
+		BotAccounts: botAccounts,
 	}, nil
 }
 
+// RecordMove records a move in our repository and, if the game record is
+// ours, the position and status it leaves the game in. The move is replayed
+// under the game's rules and updated with the status they give it, so the
+// caller sees draws it couldn't detect from the position alone.
 func (c *Client) RecordMove(ctx context.Context, gameURI string, move *chess.MoveResult) error {
 	// First, fetch the game record to get its CID and current value
 	gameCID, gameValue, err := c.getGameRecord(ctx, gameURI)
@@ -242,27 +460,61 @@ func (c *Client) RecordMove(ctx context.Context, gameURI string, move *chess.Mov
 		return fmt.Errorf("failed to get game record: %w", err)
 	}
 	
+	// The move must follow on from the game's current position, and be the
+	// only one we've recorded for its ply
+	recordFEN, _ := gameValue["fen"].(string)
+	if recordFEN == "" {
+		recordFEN = chess.StartingFEN
+	}
+	variant, _ := gameValue["variant"].(string)
+	prevFEN, replayed, err := c.positionForMove(ctx, gameURI, variant, recordFEN, move)
+	if err != nil {
+		return err
+	}
+	// The result is decided by the game's rules, not the caller, including
+	// draws only the game's history reveals
+	if err := c.finalizeMove(ctx, gameURI, variant, prevFEN, replayed); err != nil {
+		return err
+	}
+	reported := move.ThinkTimeMs
+	*move = *replayed
+	moveNumber := plyFromFEN(prevFEN) + 1
+	if err := c.checkDuplicateMove(ctx, gameURI, moveNumber); err != nil {
+		return err
+	}
+	
+	now := time.Now()
+	move.ThinkTimeMs = c.thinkTime(ctx, gameURI, gameValue, prevFEN, reported, now)
+	
 	// Create move record
 	moveRecord := map[string]interface{}{
 		"$type":     "app.atchess.move",
-		"createdAt": time.Now().Format(time.RFC3339),
+		"createdAt": now.Format(time.RFC3339),
 		"game": map[string]interface{}{
 			"uri": gameURI,
 			"cid": gameCID,
 		},
-		"player": c.did,
-		"from":   move.From,
-		"to":     move.To,
-		"san":    move.SAN,
-		"fen":    move.FEN,
+		"player":     c.did,
+		"from":       move.From,
+		"to":         move.To,
+		"san":        move.SAN,
+		"fen":        move.FEN,
+		"prevFen":    prevFEN,
+		"moveNumber": moveNumber,
 	}
 	
+	if move.Promotion != "" {
+		moveRecord["promotion"] = move.Promotion
+	}
 	if move.Check {
 		moveRecord["check"] = true
 	}
 	if move.Checkmate {
 		moveRecord["checkmate"] = true
 	}
+	if move.ThinkTimeMs > 0 {
+		moveRecord["thinkTimeMs"] = move.ThinkTimeMs
+	}
 	
 	// Create move record
 	createReq := map[string]interface{}{
@@ -272,7 +524,7 @@ func (c *Client) RecordMove(ctx context.Context, gameURI string, move *chess.Mov
 	}
 	
 	reqBody, _ := json.Marshal(createReq)
-	resp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
 	if err != nil {
 		return fmt.Errorf("failed to create move record: %w", err)
 	}
@@ -282,15 +534,49 @@ func (c *Client) RecordMove(ctx context.Context, gameURI string, move *chess.Mov
 		return fmt.Errorf("failed to create move record: HTTP %d", resp.StatusCode)
 	}
 	
+	var created struct {
+		URI string `json:"uri"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&created)
+	
+	// A move that lost a race with the opponent's, or with the game ending,
+	// mustn't be left behind to be replayed
+	err = c.updateGamePosition(ctx, gameURI, gameCID, gameValue, move)
+	return c.withDiscard(ctx, err, "app.atchess.move", created.URI)
+}
+
+// ApplyOpponentMove updates a game record we own with a move the opponent
+// recorded in their own repository. Like RecordMove, the move is updated
+// with the status the game's rules give it.
+func (c *Client) ApplyOpponentMove(ctx context.Context, gameURI string, move *chess.MoveResult) error {
+	gameCID, gameValue, err := c.getGameRecord(ctx, gameURI)
+	if err != nil {
+		return fmt.Errorf("failed to get game record: %w", err)
+	}
+	
+	// A move that doesn't follow on from the record is left for
+	// updateGamePosition to report, or skip if it's already applied
+	variant, _ := gameValue["variant"].(string)
+	recordFEN, _ := gameValue["fen"].(string)
+	if replayed, err := checkMoveExtends(variant, recordFEN, move); err == nil {
+		if err := c.finalizeMove(ctx, gameURI, variant, recordFEN, replayed); err != nil {
+			return err
+		}
+		*move = *replayed
+	}
+	return c.updateGamePosition(ctx, gameURI, gameCID, gameValue, move)
+}
+
+// updateGamePosition stores the position and result after a move in the game
+// record, if the record is in our repository
+func (c *Client) updateGamePosition(ctx context.Context, gameURI, gameCID string, gameValue map[string]interface{}, move *chess.MoveResult) error {
 	// Update game record with new FEN only if it's in our repository
-	// Parse the game URI to get repo and rkey
 	parts := strings.Split(gameURI, "/")
 	if len(parts) < 5 || !strings.HasPrefix(gameURI, "at://") {
 		return fmt.Errorf("invalid game URI format: %s", gameURI)
 	}
 	
 	repo := parts[2] // The DID
-	rkey := parts[4] // The record key
 	
 	// Only update the game record if it belongs to the current user
 	if repo != c.did {
@@ -298,49 +584,62 @@ func (c *Client) RecordMove(ctx context.Context, gameURI string, move *chess.Mov
 		return nil
 	}
 	
-	// Update the game record with new FEN and status
-	gameValue["fen"] = move.FEN
-	if move.Checkmate || move.Draw {
-		if move.Checkmate {
+	// A concurrent write that left the position alone, e.g. another app's
+	// metadata, is reapplied to; anything else conflicts with the move
+	before, _ := gameValue["fen"].(string)
+	return c.swapRecord(ctx, "app.atchess.game", gameURI, gameCID, gameValue, func(value map[string]interface{}) error {
+		fen, _ := value["fen"].(string)
+		if fen == move.FEN {
+			return errUnchanged
+		}
+		if status, _ := value["status"].(string); status != "" && status != "active" {
+			return fmt.Errorf("%w: game is %s", ErrConflict, status)
+		}
+		if fen != before {
+			return fmt.Errorf("%w: the position changed before the move was recorded", ErrConflict)
+		}
+		
+		value["fen"] = move.FEN
+		if move.Status != "" && move.Status != chess.StatusActive {
+			setFinalStatus(value, move.Status)
+		} else if move.Checkmate {
 			// Determine winner based on whose turn it was
 			fenParts := strings.Split(move.FEN, " ")
 			if len(fenParts) > 1 && fenParts[1] == "w" {
-				gameValue["status"] = "black_won"
+				setFinalStatus(value, chess.StatusBlackWon)
 			} else {
-				gameValue["status"] = "white_won"
+				setFinalStatus(value, chess.StatusWhiteWon)
 			}
 		} else if move.Draw {
-			gameValue["status"] = "draw"
+			setFinalStatus(value, chess.StatusDraw)
 		}
-	}
-	gameValue["updatedAt"] = time.Now().Format(time.RFC3339)
-	
-	// Use com.atproto.repo.putRecord to update the game
-	putReq := map[string]interface{}{
-		"repo":       repo,
-		"collection": "app.atchess.game",
-		"rkey":       rkey,
-		"record":     gameValue,
-		"swapCid":    gameCID, // Optimistic concurrency control
-	}
-	
-	putReqBody, _ := json.Marshal(putReq)
-	putResp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", putReqBody)
-	if err != nil {
-		return fmt.Errorf("failed to update game record: %w", err)
-	}
-	defer putResp.Body.Close()
-	
-	if putResp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(putResp.Body)
-		return fmt.Errorf("failed to update game record: HTTP %d, body: %s", putResp.StatusCode, string(body))
-	}
-	
-	return nil
+		value["updatedAt"] = time.Now().Format(time.RFC3339)
+		return nil
+	})
 }
 
+// CreateChallenge challenges opponentDID to a game. Players who block the
+// challenger or deny them challenges refuse it with ErrChallengeRefused, and
+// challenges past the challenge cap fail with ErrChallengeLimit.
 func (c *Client) CreateChallenge(ctx context.Context, opponentDID, color, message string) (*chess.Challenge, error) {
 	createdAt := time.Now()
+	
+	// If the opponent's repository can't be read, let the challenge through
+	if refused, err := c.RefusesChallenges(ctx, opponentDID, c.did); err != nil {
+		log.Warn().Err(err).Str("opponent", opponentDID).Msg("Could not check whether opponent accepts challenges")
+	} else if refused {
+		return nil, ErrChallengeRefused
+	}
+	if c.challengeCap > 0 {
+		pending, err := c.pendingChallengesTo(ctx, opponentDID, createdAt)
+		if err != nil {
+			return nil, err
+		}
+		if pending >= c.challengeCap {
+			return nil, ErrChallengeLimit
+		}
+	}
+	
 	proposedGameID := generateGameID(c.did, opponentDID, createdAt)
 	
 	challengeRecord := map[string]interface{}{
@@ -362,7 +661,7 @@ func (c *Client) CreateChallenge(ctx context.Context, opponentDID, color, messag
 	}
 	
 	reqBody, _ := json.Marshal(createReq)
-	resp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create challenge record: %w", err)
 	}
@@ -388,12 +687,17 @@ func (c *Client) CreateChallenge(ctx context.Context, opponentDID, color, messag
 		"daysPerMove": 3,
 	}
 	
-	// Attempt to create notification but don't fail the challenge creation if it fails
-	notificationErr := c.CreateChallengeNotification(ctx, opponentDID, createResp.URI, createResp.CID, c.handle, color, message, timeControl)
-	if notificationErr != nil {
-		// Log the error but don't fail the challenge creation
-		// In a real implementation, you might want to log this properly
-		fmt.Printf("Warning: Could not create challenge notification: %v\n", notificationErr)
+	// Most PDSes won't let us write to another account's repo. Check first
+	// rather than fail; the challenged player still finds the challenge
+	// through the firehose.
+	if c.CanWrite(ctx, opponentDID, "app.atchess.challengeNotification") {
+		// Attempt to create notification but don't fail the challenge creation if it fails
+		notificationErr := c.CreateChallengeNotification(ctx, opponentDID, createResp.URI, createResp.CID, c.handle, color, message, timeControl)
+		if notificationErr != nil {
+			// Log the error but don't fail the challenge creation
+			// In a real implementation, you might want to log this properly
+			fmt.Printf("Warning: Could not create challenge notification: %v\n", notificationErr)
+		}
 	}
 	
 	return &chess.Challenge{
@@ -411,67 +715,13 @@ func (c *Client) CreateChallenge(ctx context.Context, opponentDID, color, messag
 
 // getGameRecord fetches a game record and returns its CID and value
 func (c *Client) getGameRecord(ctx context.Context, gameURI string) (string, map[string]interface{}, error) {
-	// Parse the AT Protocol URI to extract repo and rkey
-	// Format: at://did:plc:USER/app.atchess.game/RKEY
-	parts := strings.Split(gameURI, "/")
-	if len(parts) < 5 || !strings.HasPrefix(gameURI, "at://") {
-		return "", nil, fmt.Errorf("invalid AT Protocol URI format: %s", gameURI)
-	}
-	
-	repo := parts[2] // The DID
-	rkey := parts[4] // The record key
-	
-	url := fmt.Sprintf("%s/xrpc/com.atproto.repo.getRecord?repo=%s&collection=app.atchess.game&rkey=%s", 
-		c.pdsURL, repo, rkey)
-	resp, err := c.makeRequest("GET", url, nil)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to get game record: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", nil, fmt.Errorf("failed to get game record: HTTP %d - %s", resp.StatusCode, string(body))
-	}
-	
-	var getResp struct {
-		URI   string                 `json:"uri"`
-		CID   string                 `json:"cid"`
-		Value map[string]interface{} `json:"value"`
-	}
-	
-	if err := json.NewDecoder(resp.Body).Decode(&getResp); err != nil {
-		return "", nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-	
-	return getResp.CID, getResp.Value, nil
+	return c.getRecord(ctx, "app.atchess.game", gameURI)
 }
 
 func (c *Client) GetGame(ctx context.Context, gameURI string) (*chess.Game, error) {
-	// Parse the AT Protocol URI to extract repo and rkey
-	// Example URI: at://did:plc:example/app.atchess.game/3k2uv5...
-	// We need to call com.atproto.repo.getRecord
-	
-	// Parse the URI to extract components
-	// Format: at://did:plc:USER/app.atchess.game/RKEY
-	parts := strings.Split(gameURI, "/")
-	if len(parts) < 4 || !strings.HasPrefix(gameURI, "at://") {
-		return nil, fmt.Errorf("invalid AT Protocol URI format: %s", gameURI)
-	}
-	
-	repo := parts[2] // The DID
-	rkey := parts[4] // The record key
-	
-	url := fmt.Sprintf("%s/xrpc/com.atproto.repo.getRecord?repo=%s&collection=app.atchess.game&rkey=%s", 
-		c.pdsURL, repo, rkey)
-	resp, err := c.makeRequest("GET", url, nil)
+	_, raw, err := c.fetchRecord(ctx, "app.atchess.game", gameURI)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get game record: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get game record: HTTP %d", resp.StatusCode)
+		return nil, err
 	}
 	
 	var getResp struct {
@@ -489,10 +739,16 @@ func (c *Client) GetGame(ctx context.Context, gameURI string) (*chess.Game, erro
 				Increment   int    `json:"increment"`
 				DaysPerMove int    `json:"daysPerMove"`
 			} `json:"timeControl"`
+			Variant     string                 `json:"variant"`
+			Bot         *chess.BotOpponent     `json:"bot"`
+			BotAccounts []string               `json:"botAccounts"`
+			Extensions  map[string]interface{} `json:"extensions"`
+			Imported    bool                   `json:"imported"`
+			Source      *chess.GameSource      `json:"source"`
 		} `json:"value"`
 	}
 	
-	if err := json.NewDecoder(resp.Body).Decode(&getResp); err != nil {
+	if err := json.Unmarshal(raw, &getResp.Value); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 	
@@ -515,73 +771,607 @@ func (c *Client) GetGame(ctx context.Context, gameURI string) (*chess.Game, erro
 		PGN:         getResp.Value.PGN,
 		TimeControl: timeControl,
 		CreatedAt:   getResp.Value.CreatedAt,
+		Variant:     getResp.Value.Variant,
+		Bot:         getResp.Value.Bot,
+		BotAccounts: getResp.Value.BotAccounts,
+		Extensions:  chess.ParseExtensions(getResp.Value.Extensions),
+		Imported:    getResp.Value.Imported,
+		Source:      getResp.Value.Source,
 	}, nil
 }
 
-func (c *Client) GetHandle() string {
-	return c.handle
+// gameRecordValue is the stored form of an app.atchess.game record
+type gameRecordValue struct {
+	CreatedAt   string `json:"createdAt"`
+	White       string `json:"white"`
+	Black       string `json:"black"`
+	Status      string `json:"status"`
+	FEN         string `json:"fen"`
+	PGN         string `json:"pgn"`
+	TimeControl *struct {
+		Type        string `json:"type"`
+		Initial     int    `json:"initial"`
+		Increment   int    `json:"increment"`
+		DaysPerMove int    `json:"daysPerMove"`
+	} `json:"timeControl"`
+	Variant     string                 `json:"variant"`
+	Bot         *chess.BotOpponent     `json:"bot"`
+	BotAccounts []string               `json:"botAccounts"`
+	Extensions  map[string]interface{} `json:"extensions"`
+	Imported    bool                   `json:"imported"`
+	Source      *chess.GameSource      `json:"source"`
 }
 
-// ResolveHandle resolves a handle to a DID
-func (c *Client) ResolveHandle(ctx context.Context, handle string) (string, error) {
-	// If it's already a DID, return it
-	if strings.HasPrefix(handle, "did:") {
-		return handle, nil
-	}
-	
-	// Otherwise, resolve via com.atproto.identity.resolveHandle
-	url := fmt.Sprintf("%s/xrpc/com.atproto.identity.resolveHandle?handle=%s", c.pdsURL, handle)
-	
-	resp, err := c.makeRequest("GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve handle: %w", err)
+func (v *gameRecordValue) toGame(uri string) *chess.Game {
+	var timeControl *chess.TimeControl
+	if v.TimeControl != nil {
+		timeControl = &chess.TimeControl{
+			Type:        v.TimeControl.Type,
+			DaysPerMove: v.TimeControl.DaysPerMove,
+			Initial:     v.TimeControl.Initial,
+			Increment:   v.TimeControl.Increment,
+		}
 	}
-	defer resp.Body.Close()
 	
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to resolve handle: %s", string(body))
+	return &chess.Game{
+		ID:          uri,
+		White:       v.White,
+		Black:       v.Black,
+		Status:      chess.GameStatus(v.Status),
+		FEN:         v.FEN,
+		PGN:         v.PGN,
+		TimeControl: timeControl,
+		CreatedAt:   v.CreatedAt,
+		Variant:     v.Variant,
+		Bot:         v.Bot,
+		BotAccounts: v.BotAccounts,
+		Extensions:  chess.ParseExtensions(v.Extensions),
+		Imported:    v.Imported,
+		Source:      v.Source,
 	}
-	
-	var result struct {
-		DID string `json:"did"`
+}
+
+// MaxListPageSize is the most records com.atproto.repo.listRecords returns
+// in a page, and the client's default page size
+const MaxListPageSize = 100
+
+// errStopListing stops listAllRecords once a callback has found what it's
+// looking for. It isn't passed on to listAllRecords' caller.
+var errStopListing = errors.New("stop listing")
+
+// SetListPageSize sets how many records are requested in each page when
+// listing a collection, from 1 to MaxListPageSize. Smaller pages make more
+// requests but let lookups that stop early read less.
+func (c *Client) SetListPageSize(size int) {
+	c.pageSize = min(max(size, 1), MaxListPageSize)
+}
+
+// ListPageSize returns how many records are requested in each page
+func (c *Client) ListPageSize() int {
+	if c.pageSize <= 0 {
+		return MaxListPageSize
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+	return c.pageSize
+}
+
+// SetRecordCache caches the records the client reads in cache, which other
+// clients may share. A nil cache turns caching off.
+func (c *Client) SetRecordCache(cache *RecordCache) {
+	c.records = cache
+}
+
+// RecordCache returns the cache of records the client reads, or nil
+func (c *Client) RecordCache() *RecordCache {
+	return c.records
+}
+
+// listAllRecords pages through every record in a collection, calling fn for
+// each one. fn may return errStopListing to stop without reading further pages.
+func (c *Client) listAllRecords(ctx context.Context, repo, collection string, fn func(uri, cid string, value json.RawMessage) error) error {
+	cursor := ""
+	for {
+		url := fmt.Sprintf("%s/xrpc/com.atproto.repo.listRecords?repo=%s&collection=%s&limit=%d",
+			c.pdsURL, repo, collection, c.ListPageSize())
+		if cursor != "" {
+			url += "&cursor=" + neturl.QueryEscape(cursor)
+		}
+		
+		resp, err := c.makeRequest(ctx, "GET", url, nil)
+		if err != nil {
+			return fmt.Errorf("failed to list %s records: %w", collection, err)
+		}
+		
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return fmt.Errorf("failed to list %s records: HTTP %d - %s", collection, resp.StatusCode, string(body))
+		}
+		
+		var listResp struct {
+			Cursor  string `json:"cursor"`
+			Records []struct {
+				URI   string          `json:"uri"`
+				CID   string          `json:"cid"`
+				Value json.RawMessage `json:"value"`
+			} `json:"records"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&listResp)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		
+		for _, record := range listResp.Records {
+			if err := fn(record.URI, record.CID, record.Value); err != nil {
+				if errors.Is(err, errStopListing) {
+					return nil
+				}
+				return err
+			}
+		}
+		
+		// Stop on the last page, or if the PDS hands back the same cursor
+		if listResp.Cursor == "" || listResp.Cursor == cursor || len(listResp.Records) == 0 {
+			return nil
+		}
+		cursor = listResp.Cursor
 	}
-	
-	return result.DID, nil
 }
 
-// CreateChallengeNotification creates a notification in the challenged player's repository
-func (c *Client) CreateChallengeNotification(ctx context.Context, challengedDID, challengeURI, challengeCID, challengerHandle, color, message string, timeControl map[string]interface{}) error {
-	// Calculate expiration time (24 hours from now)
-	expiresAt := time.Now().Add(24 * time.Hour)
-	
-	// Create notification record
-	notificationRecord := map[string]interface{}{
-		"$type":     "app.atchess.challengeNotification",
-		"createdAt": time.Now().Format(time.RFC3339),
-		"challenge": map[string]interface{}{
-			"uri": challengeURI,
-			"cid": challengeCID,
-		},
-		"challenger":       c.did,
-		"challengerHandle": challengerHandle,
-		"color":           color,
-		"expiresAt":       expiresAt.Format(time.RFC3339),
+// findRecord returns the first record in a collection that match accepts,
+// reading no further pages once it's found. found is false if none matches.
+func (c *Client) findRecord(ctx context.Context, repo, collection string, match func(uri string, value json.RawMessage) bool) (uri, cid string, value json.RawMessage, found bool, err error) {
+	err = c.listAllRecords(ctx, repo, collection, func(recordURI, recordCID string, recordValue json.RawMessage) error {
+		if !match(recordURI, recordValue) {
+			return nil
+		}
+		uri, cid, value, found = recordURI, recordCID, recordValue, true
+		return errStopListing
+	})
+	return uri, cid, value, found, err
+}
+
+// matchesStatusFilter reports whether a game status passes a ListGames filter.
+// An empty filter matches everything and "finished" matches any non-active game.
+func matchesStatusFilter(status chess.GameStatus, filter string) bool {
+	switch filter {
+	case "":
+		return true
+	case "finished":
+		return status != chess.StatusActive
+	default:
+		return string(status) == filter
 	}
-	
-	// Add optional fields
-	if message != "" {
-		notificationRecord["message"] = message
+}
+
+// ListGames returns the games a player is part of, newest first. It pages
+// through the game records in the player's repository and also follows the
+// game references stored on the player's challenges and seeks, which point at
+// games their opponents created in their own repositories.
+func (c *Client) ListGames(ctx context.Context, did, status string) ([]*chess.Game, error) {
+	seen := make(map[string]bool)
+	var games []*chess.Game
+	
+	addGame := func(game *chess.Game) {
+		if seen[game.ID] || !matchesStatusFilter(game.Status, status) {
+			return
+		}
+		seen[game.ID] = true
+		games = append(games, game)
 	}
 	
-	if timeControl != nil {
-		notificationRecord["timeControl"] = timeControl
+	err := c.listAllRecords(ctx, did, "app.atchess.game", func(uri, cid string, value json.RawMessage) error {
+		var record gameRecordValue
+		if err := json.Unmarshal(value, &record); err != nil {
+			return nil // Skip malformed records
+		}
+		if record.White != did && record.Black != did {
+			return nil
+		}
+		addGame(record.toGame(uri))
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	
-	// Create record in challenged player's repository
+	for _, collection := range []string{"app.atchess.challenge", "app.atchess.seek"} {
+		err = c.listAllRecords(ctx, did, collection, func(uri, cid string, value json.RawMessage) error {
+			var refs struct {
+				Games []struct {
+					URI   string `json:"uri"`
+					Owner string `json:"owner"`
+				} `json:"games"`
+			}
+			if err := json.Unmarshal(value, &refs); err != nil {
+				return nil
+			}
+			
+			for _, ref := range refs.Games {
+				if ref.Owner == did || ref.URI == "" || seen[ref.URI] {
+					continue
+				}
+				game, err := c.GetGame(ctx, ref.URI)
+				if err != nil {
+					fmt.Printf("Warning: Could not load opponent game %s: %v\n", ref.URI, err)
+					continue
+				}
+				addGame(game)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	
+	sort.SliceStable(games, func(i, j int) bool {
+		return games[i].CreatedAt > games[j].CreatedAt
+	})
+	
+	return games, nil
+}
+
+// gameMoveRecord is an app.atchess.move record belonging to a game
+type gameMoveRecord struct {
+	URI       string
+	CID       string
+	CreatedAt string `json:"createdAt"`
+	Player    string `json:"player"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	SAN       string `json:"san"`
+	FEN       string `json:"fen"`
+	Promotion string `json:"promotion"`
+	// MoveNumber and PrevFEN are missing from records written before they
+	// were added
+	MoveNumber int    `json:"moveNumber"`
+	PrevFEN    string `json:"prevFen"`
+	ThinkTimeMs int64 `json:"thinkTimeMs"`
+	Game      struct {
+		URI string `json:"uri"`
+	} `json:"game"`
+}
+
+// ply returns the half-move index of the move, as recorded or derived from
+// the FEN after it was played
+func (m *gameMoveRecord) ply() int {
+	if m.MoveNumber > 0 {
+		return m.MoveNumber
+	}
+	return plyFromFEN(m.FEN)
+}
+
+// listGameMoveRecords collects the move records for a game from every player's
+// repository, ordered by ply
+func (c *Client) listGameMoveRecords(ctx context.Context, gameURI string, players []string) ([]*gameMoveRecord, error) {
+	seen := make(map[string]bool)
+	var moves []*gameMoveRecord
+	
+	for _, playerDID := range players {
+		err := c.listAllRecords(ctx, playerDID, "app.atchess.move", func(uri, cid string, value json.RawMessage) error {
+			var move gameMoveRecord
+			if err := json.Unmarshal(value, &move); err != nil || move.Game.URI != gameURI {
+				return nil
+			}
+			if seen[cid] {
+				return nil
+			}
+			seen[cid] = true
+			move.URI = uri
+			move.CID = cid
+			moves = append(moves, &move)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	
+	sort.SliceStable(moves, func(i, j int) bool {
+		if moves[i].ply() != moves[j].ply() {
+			return moves[i].ply() < moves[j].ply()
+		}
+		return moves[i].CreatedAt < moves[j].CreatedAt
+	})
+	
+	return moves, nil
+}
+
+// GetMoves collects a game's moves from both players' repositories and
+// validates them by replaying from the starting position. Duplicate
+// submissions of the same half-move are dropped.
+func (c *Client) GetMoves(ctx context.Context, gameURI string) ([]*chess.Move, error) {
+	game, err := c.GetGame(ctx, gameURI)
+	if err != nil {
+		return nil, err
+	}
+	
+	return c.getMovesForGame(ctx, game)
+}
+
+func (c *Client) getMovesForGame(ctx context.Context, game *chess.Game) ([]*chess.Move, error) {
+	players := []string{game.White}
+	if game.Black != game.White {
+		players = append(players, game.Black)
+	}
+	if game.Imported {
+		// Both sides' moves are in the importing player's repository
+		players = []string{strings.Split(game.ID, "/")[2]}
+	}
+	
+	records, err := c.listGameMoveRecords(ctx, game.ID, players)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect moves: %w", err)
+	}
+	
+	engine, err := chess.NewVariantEngine(game.Variant, "")
+	if err != nil {
+		return nil, err
+	}
+	
+	moves := make([]*chess.Move, 0, len(records))
+	for _, record := range records {
+		ply := len(moves) + 1
+		
+		// Skip duplicate submissions of a half-move we've already replayed,
+		// and moves recorded against a position the game never reached
+		if record.ply() != 0 && record.ply() < ply {
+			continue
+		}
+		if record.PrevFEN != "" && record.PrevFEN != engine.GetFEN() {
+			continue
+		}
+		
+		expectedPlayer := game.White
+		if ply%2 == 0 {
+			expectedPlayer = game.Black
+		}
+		if record.Player != expectedPlayer {
+			return nil, fmt.Errorf("move %s at ply %d was made by %s, expected %s", record.URI, ply, record.Player, expectedPlayer)
+		}
+		
+		promotion := record.Promotion
+		if promotion == "" {
+			promotion = chess.PromotionFromSAN(record.SAN)
+		}
+		
+		result, err := engine.MakeMove(record.From, record.To, chess.ParsePromotion(promotion))
+		if err != nil {
+			return nil, fmt.Errorf("move %s at ply %d (%s%s) is illegal: %w", record.URI, ply, record.From, record.To, err)
+		}
+		
+		moves = append(moves, &chess.Move{
+			Ply:       ply,
+			URI:       record.URI,
+			CID:       record.CID,
+			Player:    record.Player,
+			From:      result.From,
+			To:        result.To,
+			Promotion: result.Promotion,
+			SAN:       result.SAN,
+			FEN:       result.FEN,
+			CreatedAt: record.CreatedAt,
+			ThinkTimeMs: record.ThinkTimeMs,
+		})
+	}
+	
+	return moves, nil
+}
+
+// ExportPGN reconstructs a game's moves from both players' repositories and
+// returns it as PGN. Empty tags are filled in from the game record.
+func (c *Client) ExportPGN(ctx context.Context, gameURI string, tags chess.PGNTags) (string, error) {
+	game, err := c.GetGame(ctx, gameURI)
+	if err != nil {
+		return "", err
+	}
+	
+	history, err := c.getMovesForGame(ctx, game)
+	if err != nil {
+		return "", err
+	}
+	
+	moves := make([]chess.PGNMove, 0, len(history))
+	for _, move := range history {
+		moves = append(moves, chess.PGNMove{
+			From:      move.From,
+			To:        move.To,
+			Promotion: move.Promotion,
+			SAN:       move.SAN,
+		})
+	}
+	
+	// Imported games' players are named as they were on the site
+	if game.Source != nil {
+		if tags.White == "" {
+			tags.White = game.Source.White
+		}
+		if tags.Black == "" {
+			tags.Black = game.Source.Black
+		}
+	}
+	if tags.White == "" {
+		tags.White = game.White
+	}
+	if tags.Black == "" {
+		tags.Black = game.Black
+	}
+	if tags.Result == "" {
+		tags.Result = chess.ResultForStatus(game.Status)
+	}
+	if tags.Date == "" {
+		if createdAt, err := time.Parse(time.RFC3339, game.CreatedAt); err == nil {
+			tags.Date = createdAt.Format("2006.01.02")
+		}
+	}
+	
+	return chess.ExportPGN(chess.StartingFEN, tags, moves)
+}
+
+// InstanceRecord is the app.atchess.instance record an ATChess service publishes about itself
+type InstanceRecord struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Endpoint    string            `json:"endpoint"`
+	Endpoints   map[string]string `json:"endpoints,omitempty"`
+	Version     string            `json:"version,omitempty"`
+	CreatedAt   string            `json:"createdAt"`
+}
+
+// PublishInstance writes the instance record to the current account's repository
+func (c *Client) PublishInstance(ctx context.Context, instance *InstanceRecord) error {
+	if instance.CreatedAt == "" {
+		instance.CreatedAt = time.Now().Format(time.RFC3339)
+	}
+	
+	record := map[string]interface{}{
+		"$type":     "app.atchess.instance",
+		"createdAt": instance.CreatedAt,
+		"name":      instance.Name,
+		"endpoint":  instance.Endpoint,
+	}
+	if instance.Description != "" {
+		record["description"] = instance.Description
+	}
+	if len(instance.Endpoints) > 0 {
+		record["endpoints"] = instance.Endpoints
+	}
+	if instance.Version != "" {
+		record["version"] = instance.Version
+	}
+	
+	putReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.instance",
+		"rkey":       "self",
+		"record":     record,
+	}
+	
+	reqBody, _ := json.Marshal(putReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to publish instance record: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to publish instance record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	
+	return nil
+}
+
+// GetInstance fetches the app.atchess.instance record published by an account
+func (c *Client) GetInstance(ctx context.Context, did string) (*InstanceRecord, error) {
+	_, value, err := c.getRecord(ctx, "app.atchess.instance", fmt.Sprintf("at://%s/app.atchess.instance/self", did))
+	if err != nil {
+		return nil, err
+	}
+	
+	// Round-trip through JSON to decode into the typed record
+	data, _ := json.Marshal(value)
+	var instance InstanceRecord
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return nil, fmt.Errorf("failed to decode instance record: %w", err)
+	}
+	
+	return &instance, nil
+}
+
+// RatingRecord is the app.atchess.rating record summarising a player's rating
+// as computed by an ATChess instance
+type RatingRecord struct {
+	System    string `json:"system"` // rating system, e.g. "glicko2"
+	Rating    int    `json:"rating"`
+	Deviation int    `json:"deviation"`
+	Games     int    `json:"games"`
+	Wins      int    `json:"wins"`
+	Losses    int    `json:"losses"`
+	Draws     int    `json:"draws"`
+	Issuer    string `json:"issuer"` // DID of the instance that computed the rating
+	LastGame  string `json:"lastGame,omitempty"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// PublishRating writes the rating record to the current account's repository
+func (c *Client) PublishRating(ctx context.Context, rating *RatingRecord) error {
+	if rating.UpdatedAt == "" {
+		rating.UpdatedAt = time.Now().Format(time.RFC3339)
+	}
+	
+	record := map[string]interface{}{
+		"$type":     "app.atchess.rating",
+		"system":    rating.System,
+		"rating":    rating.Rating,
+		"deviation": rating.Deviation,
+		"games":     rating.Games,
+		"wins":      rating.Wins,
+		"losses":    rating.Losses,
+		"draws":     rating.Draws,
+		"issuer":    rating.Issuer,
+		"updatedAt": rating.UpdatedAt,
+	}
+	if rating.LastGame != "" {
+		record["lastGame"] = rating.LastGame
+	}
+	
+	putReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.rating",
+		"rkey":       "self",
+		"record":     record,
+	}
+	
+	reqBody, _ := json.Marshal(putReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to publish rating record: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to publish rating record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	
+	return nil
+}
+
+func (c *Client) GetHandle() string {
+	return c.handle
+}
+
+// CreateChallengeNotification creates a notification in the challenged player's repository
+func (c *Client) CreateChallengeNotification(ctx context.Context, challengedDID, challengeURI, challengeCID, challengerHandle, color, message string, timeControl map[string]interface{}) error {
+	// Calculate expiration time (24 hours from now)
+	expiresAt := time.Now().Add(24 * time.Hour)
+	
+	// Create notification record
+	notificationRecord := map[string]interface{}{
+		"$type":     "app.atchess.challengeNotification",
+		"createdAt": time.Now().Format(time.RFC3339),
+		"challenge": map[string]interface{}{
+			"uri": challengeURI,
+			"cid": challengeCID,
+		},
+		"challenger":       c.did,
+		"challengerHandle": challengerHandle,
+		"color":           color,
+		"expiresAt":       expiresAt.Format(time.RFC3339),
+	}
+	
+	// Add optional fields
+	if message != "" {
+		notificationRecord["message"] = message
+	}
+	
+	if timeControl != nil {
+		notificationRecord["timeControl"] = timeControl
+	}
+	
+	// Create record in challenged player's repository
 	createReq := map[string]interface{}{
 		"repo":       challengedDID,
 		"collection": "app.atchess.challengeNotification",
@@ -589,7 +1379,7 @@ func (c *Client) CreateChallengeNotification(ctx context.Context, challengedDID,
 	}
 	
 	reqBody, _ := json.Marshal(createReq)
-	resp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
 	if err != nil {
 		return fmt.Errorf("failed to create challenge notification: %w", err)
 	}
@@ -611,81 +1401,63 @@ func (c *Client) CreateChallengeNotification(ctx context.Context, challengedDID,
 	return nil
 }
 
-// GetChallengeNotifications retrieves pending challenge notifications for the current user
+// GetChallengeNotifications retrieves pending challenge notifications for the
+// current user, leaving out challengers they block, mute or deny challenges
 func (c *Client) GetChallengeNotifications(ctx context.Context) ([]*ChallengeNotification, error) {
-	// List records in the challengeNotification collection
-	url := fmt.Sprintf("%s/xrpc/com.atproto.repo.listRecords?repo=%s&collection=app.atchess.challengeNotification&limit=100",
-		c.pdsURL, c.did)
-	resp, err := c.makeRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list challenge notifications: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to list challenge notifications: HTTP %d - %s", resp.StatusCode, string(body))
-	}
-	
-	var listResp struct {
-		Records []struct {
-			URI   string `json:"uri"`
-			CID   string `json:"cid"`
-			Value struct {
-				Type      string `json:"$type"`
-				CreatedAt string `json:"createdAt"`
-				Challenge struct {
-					URI string `json:"uri"`
-					CID string `json:"cid"`
-				} `json:"challenge"`
-				Challenger       string                 `json:"challenger"`
-				ChallengerHandle string                 `json:"challengerHandle"`
-				Color            string                 `json:"color"`
-				Message          string                 `json:"message"`
-				ExpiresAt        string                 `json:"expiresAt"`
-				TimeControl      map[string]interface{} `json:"timeControl"`
-			} `json:"value"`
-		} `json:"records"`
-	}
-	
-	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-	
 	// Filter out expired notifications and convert to our type
 	var notifications []*ChallengeNotification
 	now := time.Now()
 	
-	for _, record := range listResp.Records {
+	err := c.listAllRecords(ctx, c.did, "app.atchess.challengeNotification", func(uri, cid string, value json.RawMessage) error {
+		var record struct {
+			Type      string `json:"$type"`
+			CreatedAt string `json:"createdAt"`
+			Challenge struct {
+				URI string `json:"uri"`
+				CID string `json:"cid"`
+			} `json:"challenge"`
+			Challenger       string                 `json:"challenger"`
+			ChallengerHandle string                 `json:"challengerHandle"`
+			Color            string                 `json:"color"`
+			Message          string                 `json:"message"`
+			ExpiresAt        string                 `json:"expiresAt"`
+			TimeControl      map[string]interface{} `json:"timeControl"`
+		}
+		if err := json.Unmarshal(value, &record); err != nil {
+			return nil // Skip malformed records
+		}
+		
 		// Parse expiration time
-		expiresAt, err := time.Parse(time.RFC3339, record.Value.ExpiresAt)
+		expiresAt, err := time.Parse(time.RFC3339, record.ExpiresAt)
 		if err != nil {
-			continue // Skip if we can't parse the expiration
+			return nil // Skip if we can't parse the expiration
 		}
 		
 		// Skip expired notifications
 		if expiresAt.Before(now) {
-			continue
-		}
-		
-		notification := &ChallengeNotification{
-			URI:              record.URI,
-			CID:              record.CID,
-			CreatedAt:        record.Value.CreatedAt,
-			ChallengeURI:     record.Value.Challenge.URI,
-			ChallengeCID:     record.Value.Challenge.CID,
-			Challenger:       record.Value.Challenger,
-			ChallengerHandle: record.Value.ChallengerHandle,
-			Color:            record.Value.Color,
-			Message:          record.Value.Message,
-			ExpiresAt:        record.Value.ExpiresAt,
-			TimeControl:      record.Value.TimeControl,
+			return nil
 		}
 		
-		notifications = append(notifications, notification)
+		notifications = append(notifications, &ChallengeNotification{
+			URI:              uri,
+			CID:              cid,
+			CreatedAt:        record.CreatedAt,
+			ChallengeURI:     record.Challenge.URI,
+			ChallengeCID:     record.Challenge.CID,
+			Challenger:       record.Challenger,
+			ChallengerHandle: record.ChallengerHandle,
+			Color:            record.Color,
+			Message:          record.Message,
+			ExpiresAt:        record.ExpiresAt,
+			TimeControl:      record.TimeControl,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list challenge notifications: %w", err)
 	}
 	
-	return notifications, nil
+	return c.filterChallengeNotifications(ctx, notifications), nil
 }
 
 // ChallengeNotification represents a challenge notification record
@@ -728,7 +1500,7 @@ func (c *Client) DeleteChallengeNotification(ctx context.Context, notificationUR
 	}
 	
 	reqBody, _ := json.Marshal(deleteReq)
-	resp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.deleteRecord", reqBody)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.deleteRecord", reqBody)
 	if err != nil {
 		return fmt.Errorf("failed to delete notification: %w", err)
 	}
@@ -739,7 +1511,755 @@ func (c *Client) DeleteChallengeNotification(ctx context.Context, notificationUR
 		return fmt.Errorf("failed to delete notification: HTTP %d - %s", resp.StatusCode, string(body))
 	}
 	
-	return nil
+	return nil
+}
+
+// applyWritesBatchSize is the most writes a PDS accepts in one com.atproto.repo.applyWrites call
+const applyWritesBatchSize = 200
+
+// Outcomes reported for each notification in a bulk acknowledgment
+const (
+	AckDeleted  = "deleted"
+	AckNotFound = "not_found"
+	AckInvalid  = "invalid"
+	AckFailed   = "failed"
+)
+
+// NotificationAckResult is the outcome of acknowledging a single notification
+type NotificationAckResult struct {
+	Key    string `json:"key"`
+	URI    string `json:"uri,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// AckChallengeNotifications deletes the given challenge notifications, identified
+// by record key or URI, batching the deletes with applyWrites
+func (c *Client) AckChallengeNotifications(ctx context.Context, keys []string) ([]NotificationAckResult, error) {
+	existing, err := c.listNotificationKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	
+	results := make([]NotificationAckResult, len(keys))
+	pending := make(map[int]string) // result index -> rkey
+	var rkeys []string
+	queued := make(map[string]bool)
+	
+	for i, key := range keys {
+		results[i].Key = key
+		
+		rkey := key
+		if strings.HasPrefix(key, "at://") {
+			parts := strings.Split(key, "/")
+			if len(parts) != 5 || parts[2] != c.did || parts[3] != "app.atchess.challengeNotification" {
+				results[i].Status = AckInvalid
+				results[i].Error = "not a challenge notification in your repository"
+				continue
+			}
+			rkey = parts[4]
+		}
+		
+		uri, ok := existing[rkey]
+		if !ok {
+			results[i].Status = AckNotFound
+			continue
+		}
+		results[i].URI = uri
+		pending[i] = rkey
+		
+		// The same notification listed twice is only deleted once
+		if !queued[rkey] {
+			queued[rkey] = true
+			rkeys = append(rkeys, rkey)
+		}
+	}
+	
+	failures := c.deleteRecordsBatched(ctx, "app.atchess.challengeNotification", rkeys)
+	for i, rkey := range pending {
+		if err, failed := failures[rkey]; failed {
+			results[i].Status = AckFailed
+			results[i].Error = err.Error()
+		} else {
+			results[i].Status = AckDeleted
+		}
+	}
+	
+	return results, nil
+}
+
+// AckChallengeNotificationsBefore deletes every challenge notification created before the cutoff
+func (c *Client) AckChallengeNotificationsBefore(ctx context.Context, before time.Time) ([]NotificationAckResult, error) {
+	var keys []string
+	err := c.listAllRecords(ctx, c.did, "app.atchess.challengeNotification", func(uri, cid string, value json.RawMessage) error {
+		var record struct {
+			CreatedAt string `json:"createdAt"`
+		}
+		if err := json.Unmarshal(value, &record); err != nil {
+			return nil
+		}
+		createdAt, err := time.Parse(time.RFC3339, record.CreatedAt)
+		if err != nil || !createdAt.Before(before) {
+			return nil
+		}
+		keys = append(keys, uri)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	if len(keys) == 0 {
+		return []NotificationAckResult{}, nil
+	}
+	return c.AckChallengeNotifications(ctx, keys)
+}
+
+// listNotificationKeys maps the record key of every challenge notification in the user's repository to its URI
+func (c *Client) listNotificationKeys(ctx context.Context) (map[string]string, error) {
+	keys := make(map[string]string)
+	err := c.listAllRecords(ctx, c.did, "app.atchess.challengeNotification", func(uri, cid string, value json.RawMessage) error {
+		parts := strings.Split(uri, "/")
+		if len(parts) == 5 {
+			keys[parts[4]] = uri
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// deleteRecordsBatched deletes records from the user's repository with applyWrites.
+// Each batch is atomic, so a failed batch reports its error for every key in it.
+func (c *Client) deleteRecordsBatched(ctx context.Context, collection string, rkeys []string) map[string]error {
+	failures := make(map[string]error)
+	
+	for start := 0; start < len(rkeys); start += applyWritesBatchSize {
+		batch := rkeys[start:min(start+applyWritesBatchSize, len(rkeys))]
+		
+		writes := make([]map[string]interface{}, 0, len(batch))
+		for _, rkey := range batch {
+			writes = append(writes, map[string]interface{}{
+				"$type":      "com.atproto.repo.applyWrites#delete",
+				"collection": collection,
+				"rkey":       rkey,
+			})
+		}
+		
+		if err := c.applyWrites(ctx, writes); err != nil {
+			for _, rkey := range batch {
+				failures[rkey] = err
+			}
+		}
+	}
+	
+	return failures
+}
+
+// updateRecordsBatched rewrites records in the user's repository with
+// applyWrites, keyed by rkey. Like deleteRecordsBatched, a failed batch reports
+// its error for every key in it.
+func (c *Client) updateRecordsBatched(ctx context.Context, collection string, values map[string]map[string]interface{}) map[string]error {
+	rkeys := make([]string, 0, len(values))
+	for rkey := range values {
+		rkeys = append(rkeys, rkey)
+	}
+	sort.Strings(rkeys)
+	
+	failures := make(map[string]error)
+	for start := 0; start < len(rkeys); start += applyWritesBatchSize {
+		batch := rkeys[start:min(start+applyWritesBatchSize, len(rkeys))]
+		
+		writes := make([]map[string]interface{}, 0, len(batch))
+		for _, rkey := range batch {
+			writes = append(writes, map[string]interface{}{
+				"$type":      "com.atproto.repo.applyWrites#update",
+				"collection": collection,
+				"rkey":       rkey,
+				"value":      values[rkey],
+			})
+		}
+		
+		if err := c.applyWrites(ctx, writes); err != nil {
+			for _, rkey := range batch {
+				failures[rkey] = err
+			}
+		}
+	}
+	
+	return failures
+}
+
+func (c *Client) applyWrites(ctx context.Context, writes []map[string]interface{}) error {
+	_, err := c.applyWritesResults(ctx, writes)
+	return err
+}
+
+// applyWritesResult is the outcome of one write in an applyWrites call; the
+// URI and CID are set for creates and updates
+type applyWritesResult struct {
+	URI string `json:"uri"`
+	CID string `json:"cid"`
+}
+
+// applyWritesResults applies writes atomically, returning their results in order
+func (c *Client) applyWritesResults(ctx context.Context, writes []map[string]interface{}) ([]applyWritesResult, error) {
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"repo":   c.did,
+		"writes": writes,
+	})
+	
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.applyWrites", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply writes: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to apply writes: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	
+	var applyResp struct {
+		Results []applyWritesResult `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&applyResp); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to decode applyWrites response: %w", err)
+	}
+	
+	// Updated records are cached as written, so reading them back is free
+	for i, write := range writes {
+		if write["$type"] != "com.atproto.repo.applyWrites#update" || i >= len(applyResp.Results) {
+			continue
+		}
+		collection, _ := write["collection"].(string)
+		rkey, _ := write["rkey"].(string)
+		if value, err := json.Marshal(write["value"]); err == nil {
+			c.records.put(recordURI(c.did, collection, rkey), applyResp.Results[i].CID, value)
+		}
+	}
+	return applyResp.Results, nil
+}
+
+// ErrChallengeNotPending is returned when responding to a challenge that was already answered
+var ErrChallengeNotPending = errors.New("challenge is not pending")
+
+// ErrNotChallenged is returned when someone other than the challenged player responds to a challenge
+var ErrNotChallenged = errors.New("challenge is not addressed to this user")
+
+// getRecord fetches any record by AT URI and returns its CID and value
+func (c *Client) getRecord(ctx context.Context, collection, uri string) (string, map[string]interface{}, error) {
+	cid, raw, err := c.fetchRecord(ctx, collection, uri)
+	if err != nil {
+		return "", nil, err
+	}
+	
+	var value map[string]interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	
+	return cid, value, nil
+}
+
+// fetchRecord returns a record's CID and raw value, from the record cache if
+// it has a fresh copy
+func (c *Client) fetchRecord(ctx context.Context, collection, uri string) (string, json.RawMessage, error) {
+	parts := strings.Split(uri, "/")
+	if len(parts) < 5 || !strings.HasPrefix(uri, "at://") {
+		return "", nil, fmt.Errorf("invalid AT Protocol URI format: %s", uri)
+	}
+	
+	if cid, value, ok := c.records.get(uri); ok {
+		return cid, value, nil
+	}
+	
+	repo := parts[2] // The DID
+	rkey := parts[4] // The record key
+	
+	url := fmt.Sprintf("%s/xrpc/com.atproto.repo.getRecord?repo=%s&collection=%s&rkey=%s",
+		c.pdsURL, repo, collection, rkey)
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get %s record: %w", collection, err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", nil, fmt.Errorf("failed to get %s record: HTTP %d - %s", collection, resp.StatusCode, string(body))
+	}
+	
+	var getResp struct {
+		CID   string          `json:"cid"`
+		Value json.RawMessage `json:"value"`
+	}
+	
+	if err := json.NewDecoder(resp.Body).Decode(&getResp); err != nil {
+		return "", nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	
+	c.records.put(uri, getResp.CID, getResp.Value)
+	return getResp.CID, getResp.Value, nil
+}
+
+// getPendingChallenge fetches a challenge and verifies the current user can still respond to it
+func (c *Client) getPendingChallenge(ctx context.Context, challengeURI string) (string, map[string]interface{}, error) {
+	challengeCID, challengeValue, err := c.getRecord(ctx, "app.atchess.challenge", challengeURI)
+	if err != nil {
+		return "", nil, err
+	}
+	
+	if challenged, _ := challengeValue["challenged"].(string); challenged != c.did {
+		return "", nil, ErrNotChallenged
+	}
+	
+	if status, _ := challengeValue["status"].(string); status != "pending" {
+		return "", nil, fmt.Errorf("%w: current status %s", ErrChallengeNotPending, status)
+	}
+	
+	if expiresAt, ok := challengeValue["expiresAt"].(string); ok {
+		if expiry, err := time.Parse(time.RFC3339, expiresAt); err == nil && expiry.Before(time.Now()) {
+			return "", nil, fmt.Errorf("%w: challenge expired at %s", ErrChallengeNotPending, expiresAt)
+		}
+	}
+	
+	return challengeCID, challengeValue, nil
+}
+
+// updateChallengeRecord writes a modified challenge record back to the challenger's repository
+func (c *Client) updateChallengeRecord(ctx context.Context, challengeURI, challengeCID string, challengeValue map[string]interface{}) error {
+	return c.updateRecord(ctx, "app.atchess.challenge", challengeURI, challengeCID, challengeValue)
+}
+
+// updateRecord writes a modified record back to the repository it came from,
+// failing if it changed since it was read
+func (c *Client) updateRecord(ctx context.Context, collection, uri, cid string, value map[string]interface{}) error {
+	return c.putRecord(ctx, collection, uri, cid, value)
+}
+
+// AcceptChallenge accepts a pending challenge addressed to the current user. It creates
+// the game in the accepter's repository under the challenge's proposedGameId, records an
+// app.atchess.challengeAcceptance and marks the original challenge as accepted.
+func (c *Client) AcceptChallenge(ctx context.Context, challengeURI, message string) (*chess.Game, error) {
+	challengeCID, challengeValue, err := c.getPendingChallenge(ctx, challengeURI)
+	if err != nil {
+		return nil, err
+	}
+	
+	challengerDID, _ := challengeValue["challenger"].(string)
+	
+	// The color field is the challenger's preference; random currently gives the challenger white
+	color := "black"
+	if challengerColor, _ := challengeValue["color"].(string); challengerColor == "black" {
+		color = "white"
+	}
+	
+	var game *chess.Game
+	if proposedGameID, _ := challengeValue["proposedGameId"].(string); proposedGameID != "" {
+		game, err = c.CreateGameFromChallenge(ctx, challengerDID, color, proposedGameID, challengeURI, challengeCID)
+	} else {
+		game, err = c.createGame(ctx, challengerDID, color, gameOptions{challengeURI: challengeURI, challengeCID: challengeCID})
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	gameCID, _, err := c.getGameRecord(ctx, game.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get created game record: %w", err)
+	}
+	
+	acceptanceRecord := map[string]interface{}{
+		"$type":     "app.atchess.challengeAcceptance",
+		"createdAt": time.Now().Format(time.RFC3339),
+		"challenge": map[string]interface{}{
+			"uri": challengeURI,
+			"cid": challengeCID,
+		},
+		"accepter": c.did,
+		"game": map[string]interface{}{
+			"uri": game.ID,
+			"cid": gameCID,
+		},
+	}
+	if message != "" {
+		acceptanceRecord["message"] = message
+	}
+	
+	createReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.challengeAcceptance",
+		"record":     acceptanceRecord,
+	}
+	
+	reqBody, _ := json.Marshal(createReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create challenge acceptance record: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to create challenge acceptance record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	
+	// Mark the challenge as accepted. The challenge lives in the challenger's
+	// repository, so this is best-effort - the acceptance record is authoritative.
+	games, _ := challengeValue["games"].([]interface{})
+	challengeValue["games"] = append(games, map[string]interface{}{
+		"uri":   game.ID,
+		"cid":   gameCID,
+		"owner": c.did,
+	})
+	challengeValue["status"] = "accepted"
+	if err := c.updateChallengeRecord(ctx, challengeURI, challengeCID, challengeValue); err != nil {
+		fmt.Printf("Warning: Could not update challenge status: %v\n", err)
+	}
+	
+	return game, nil
+}
+
+// DeclineChallenge declines a pending challenge addressed to the current user
+func (c *Client) DeclineChallenge(ctx context.Context, challengeURI string) error {
+	challengeCID, challengeValue, err := c.getPendingChallenge(ctx, challengeURI)
+	if err != nil {
+		return err
+	}
+	
+	challengeValue["status"] = "declined"
+	return c.updateChallengeRecord(ctx, challengeURI, challengeCID, challengeValue)
+}
+
+// GetChallenge fetches a challenge record
+func (c *Client) GetChallenge(ctx context.Context, challengeURI string) (*chess.Challenge, error) {
+	_, value, err := c.getRecord(ctx, "app.atchess.challenge", challengeURI)
+	if err != nil {
+		return nil, err
+	}
+	
+	challenge := &chess.Challenge{ID: challengeURI}
+	challenge.Challenger, _ = value["challenger"].(string)
+	challenge.Challenged, _ = value["challenged"].(string)
+	challenge.Status, _ = value["status"].(string)
+	challenge.Color, _ = value["color"].(string)
+	challenge.ProposedGameId, _ = value["proposedGameId"].(string)
+	challenge.Message, _ = value["message"].(string)
+	challenge.CreatedAt, _ = value["createdAt"].(string)
+	challenge.ExpiresAt, _ = value["expiresAt"].(string)
+	if tc, ok := value["timeControl"].(map[string]interface{}); ok {
+		challenge.TimeControl = &chess.TimeControl{}
+		challenge.TimeControl.Type, _ = tc["type"].(string)
+		challenge.TimeControl.Initial = intValue(tc["initial"])
+		challenge.TimeControl.Increment = intValue(tc["increment"])
+		challenge.TimeControl.DaysPerMove = intValue(tc["daysPerMove"])
+	}
+	return challenge, nil
+}
+
+// ExpireChallenge marks a pending challenge in our repository as expired
+// once its expiresAt has passed, deleting the notification it left with the
+// challenged player if we can. It reports whether the challenge was changed;
+// challenges already answered or not yet due are left alone.
+func (c *Client) ExpireChallenge(ctx context.Context, challengeURI string) (bool, error) {
+	challengeCID, challengeValue, err := c.getRecord(ctx, "app.atchess.challenge", challengeURI)
+	if err != nil {
+		return false, err
+	}
+	
+	if challenger, _ := challengeValue["challenger"].(string); challenger != c.did {
+		return false, ErrNotOwnRecord
+	}
+	if status, _ := challengeValue["status"].(string); status != "pending" {
+		return false, nil
+	}
+	expiresAt, _ := challengeValue["expiresAt"].(string)
+	expiry, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil || expiry.After(time.Now()) {
+		return false, nil
+	}
+	
+	challengeValue["status"] = "expired"
+	challengeValue["expiredAt"] = time.Now().UTC().Format(time.RFC3339)
+	if err := c.updateChallengeRecord(ctx, challengeURI, challengeCID, challengeValue); err != nil {
+		return false, err
+	}
+	
+	challenged, _ := challengeValue["challenged"].(string)
+	if _, err := c.deleteChallengeNotifications(ctx, challenged, map[string]bool{challengeURI: true}); err != nil {
+		fmt.Printf("Warning: Could not delete challenge notification: %v\n", err)
+	}
+	return true, nil
+}
+
+// DefaultSeekDuration is how long a seek stays open when it doesn't set an expiry
+const DefaultSeekDuration = time.Hour
+
+// ErrSeekNotOpen is returned when accepting a seek that was already matched or has expired
+var ErrSeekNotOpen = errors.New("seek is not open")
+
+// ErrOwnSeek is returned when a player tries to accept their own seek
+var ErrOwnSeek = errors.New("cannot accept your own seek")
+
+// CreateSeek publishes an open challenge for anyone to accept. The seek's
+// player, ID and timestamps are filled in.
+func (c *Client) CreateSeek(ctx context.Context, seek *chess.Seek) (*chess.Seek, error) {
+	createdAt := time.Now()
+	seek.Player = c.did
+	seek.CreatedAt = createdAt.Format(time.RFC3339)
+	if seek.ExpiresAt == "" {
+		seek.ExpiresAt = createdAt.Add(DefaultSeekDuration).Format(time.RFC3339)
+	}
+	
+	seekRecord := map[string]interface{}{
+		"$type":     "app.atchess.seek",
+		"createdAt": seek.CreatedAt,
+		"player":    seek.Player,
+		"status":    "open",
+		"color":     seek.Color,
+		"expiresAt": seek.ExpiresAt,
+	}
+	if seek.TimeControl != nil {
+		seekRecord["timeControl"] = timeControlRecord(seek.TimeControl)
+	}
+	if seek.MinRating > 0 || seek.MaxRating > 0 {
+		ratingRange := map[string]interface{}{}
+		if seek.MinRating > 0 {
+			ratingRange["min"] = seek.MinRating
+		}
+		if seek.MaxRating > 0 {
+			ratingRange["max"] = seek.MaxRating
+		}
+		seekRecord["ratingRange"] = ratingRange
+	}
+	
+	createReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.seek",
+		"record":     seekRecord,
+	}
+	
+	reqBody, _ := json.Marshal(createReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create seek record: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to create seek record: HTTP %d", resp.StatusCode)
+	}
+	
+	var createResp struct {
+		URI string `json:"uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&createResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	
+	seek.ID = createResp.URI
+	return seek, nil
+}
+
+// GetSeek fetches a seek from the seeker's repository
+func (c *Client) GetSeek(ctx context.Context, seekURI string) (*chess.Seek, error) {
+	_, seekValue, err := c.getRecord(ctx, "app.atchess.seek", seekURI)
+	if err != nil {
+		return nil, err
+	}
+	return seekFromRecord(seekURI, seekValue), nil
+}
+
+// seekFromRecord converts an app.atchess.seek record value
+func seekFromRecord(uri string, value map[string]interface{}) *chess.Seek {
+	seek := &chess.Seek{ID: uri}
+	seek.Player, _ = value["player"].(string)
+	seek.Color, _ = value["color"].(string)
+	seek.CreatedAt, _ = value["createdAt"].(string)
+	seek.ExpiresAt, _ = value["expiresAt"].(string)
+	if tc, ok := value["timeControl"].(map[string]interface{}); ok {
+		seek.TimeControl = &chess.TimeControl{}
+		seek.TimeControl.Type, _ = tc["type"].(string)
+		seek.TimeControl.Initial = intValue(tc["initial"])
+		seek.TimeControl.Increment = intValue(tc["increment"])
+		seek.TimeControl.DaysPerMove = intValue(tc["daysPerMove"])
+	}
+	if ratingRange, ok := value["ratingRange"].(map[string]interface{}); ok {
+		seek.MinRating = intValue(ratingRange["min"])
+		seek.MaxRating = intValue(ratingRange["max"])
+	}
+	return seek
+}
+
+// intValue reads a JSON number from a decoded record
+func intValue(value interface{}) int {
+	n, _ := value.(float64)
+	return int(n)
+}
+
+// timeControlRecord is the stored form of a time control
+func timeControlRecord(tc *chess.TimeControl) map[string]interface{} {
+	record := map[string]interface{}{"type": tc.Type}
+	if tc.Initial > 0 {
+		record["initial"] = tc.Initial
+	}
+	if tc.Increment > 0 {
+		record["increment"] = tc.Increment
+	}
+	if tc.DaysPerMove > 0 {
+		record["daysPerMove"] = tc.DaysPerMove
+	}
+	return record
+}
+
+// AcceptSeek pairs the current user with the player who published a seek. The
+// game is created in the accepter's repository with a reference to the seek,
+// which is how indexers learn the seek has been taken.
+func (c *Client) AcceptSeek(ctx context.Context, seekURI string) (*chess.Game, error) {
+	seekCID, seekValue, err := c.getRecord(ctx, "app.atchess.seek", seekURI)
+	if err != nil {
+		return nil, err
+	}
+	
+	seek := seekFromRecord(seekURI, seekValue)
+	if seek.Player == c.did {
+		return nil, ErrOwnSeek
+	}
+	if status, _ := seekValue["status"].(string); status != "open" {
+		return nil, fmt.Errorf("%w: current status %s", ErrSeekNotOpen, status)
+	}
+	if expiry, err := time.Parse(time.RFC3339, seek.ExpiresAt); err == nil && expiry.Before(time.Now()) {
+		return nil, fmt.Errorf("%w: seek expired at %s", ErrSeekNotOpen, seek.ExpiresAt)
+	}
+	
+	// The color field is the seeker's preference
+	color := "black"
+	switch seek.Color {
+	case "black":
+		color = "white"
+	case "random":
+		if rand.Intn(2) == 0 {
+			color = "white"
+		}
+	}
+	
+	game, err := c.createGame(ctx, seek.Player, color, gameOptions{
+		seekURI:     seekURI,
+		seekCID:     seekCID,
+		timeControl: seek.TimeControl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	// Mark the seek as matched. It lives in the seeker's repository, so this is
+	// best-effort - the game's seek reference is authoritative.
+	gameCID, _, err := c.getGameRecord(ctx, game.ID)
+	if err == nil {
+		seekValue["status"] = "matched"
+		seekValue["games"] = []interface{}{map[string]interface{}{
+			"uri":   game.ID,
+			"cid":   gameCID,
+			"owner": c.did,
+		}}
+		err = c.updateRecord(ctx, "app.atchess.seek", seekURI, seekCID, seekValue)
+	}
+	if err != nil {
+		fmt.Printf("Warning: Could not update seek status: %v\n", err)
+	}
+	
+	return game, nil
+}
+
+// DeleteSeek withdraws one of the current user's seeks
+func (c *Client) DeleteSeek(ctx context.Context, seekURI string) error {
+	parts := strings.Split(seekURI, "/")
+	if len(parts) < 5 || !strings.HasPrefix(seekURI, "at://") {
+		return fmt.Errorf("invalid seek URI format: %s", seekURI)
+	}
+	if parts[2] != c.did {
+		return fmt.Errorf("cannot delete seek from another user's repository")
+	}
+	
+	deleteReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.seek",
+		"rkey":       parts[4],
+	}
+	
+	reqBody, _ := json.Marshal(deleteReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.deleteRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to delete seek: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete seek: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	
+	return nil
+}
+
+// ErrNotInGame is returned when someone other than the game's players tries
+// to chat in it or offer a rematch
+var ErrNotInGame = errors.New("not a player in this game")
+
+// SendChatMessage records a chat message in a game in the sender's repository
+func (c *Client) SendChatMessage(ctx context.Context, gameURI, text string) (*chess.ChatMessage, error) {
+	gameCID, gameValue, err := c.getGameRecord(ctx, gameURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game record: %w", err)
+	}
+	
+	if white, black := gameValue["white"], gameValue["black"]; c.did != white && c.did != black {
+		return nil, ErrNotInGame
+	}
+	
+	message := &chess.ChatMessage{
+		GameID:    gameURI,
+		Sender:    c.did,
+		Text:      text,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	
+	createReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.chatMessage",
+		"record": map[string]interface{}{
+			"$type":     "app.atchess.chatMessage",
+			"createdAt": message.CreatedAt,
+			"game": map[string]interface{}{
+				"uri": gameURI,
+				"cid": gameCID,
+			},
+			"sender": message.Sender,
+			"text":   message.Text,
+		},
+	}
+	
+	reqBody, _ := json.Marshal(createReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat message record: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to create chat message record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	
+	var createResp struct {
+		URI string `json:"uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&createResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	
+	message.ID = createResp.URI
+	return message, nil
 }
 
 // OfferDraw creates a draw offer record for a game
@@ -755,16 +2275,25 @@ func (c *Client) OfferDraw(ctx context.Context, gameID string, message string) (
 		return nil, fmt.Errorf("cannot offer draw in a game with status: %s", status)
 	}
 	
-	// Create draw offer record
+	// Create draw offer record. It lapses at the offering player's next move,
+	// after the move it was offered at, or once it expires.
+	now := time.Now()
+	fen, _ := gameValue["fen"].(string)
 	drawOfferRecord := map[string]interface{}{
 		"$type":     "app.atchess.drawOffer",
-		"createdAt": time.Now().Format(time.RFC3339),
+		"createdAt": now.Format(time.RFC3339),
 		"game": map[string]interface{}{
 			"uri": gameID,
 			"cid": gameCID,
 		},
-		"offeredBy": c.did,
-		"status":    "pending",
+		"offeredBy":  c.did,
+		"moveNumber": plyFromFEN(fen) + 1,
+		"status":     "pending",
+	}
+	var expiresAt string
+	if c.drawOfferTimeout > 0 {
+		expiresAt = now.Add(c.drawOfferTimeout).UTC().Format(time.RFC3339)
+		drawOfferRecord["expiresAt"] = expiresAt
 	}
 	
 	// Add optional message
@@ -780,7 +2309,7 @@ func (c *Client) OfferDraw(ctx context.Context, gameID string, message string) (
 	}
 	
 	reqBody, _ := json.Marshal(createReq)
-	resp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create draw offer record: %w", err)
 	}
@@ -801,14 +2330,16 @@ func (c *Client) OfferDraw(ctx context.Context, gameID string, message string) (
 	}
 	
 	return &DrawOffer{
-		URI:       createResp.URI,
-		CID:       createResp.CID,
-		CreatedAt: drawOfferRecord["createdAt"].(string),
-		GameURI:   gameID,
-		GameCID:   gameCID,
-		OfferedBy: c.did,
-		Message:   message,
-		Status:    "pending",
+		URI:        createResp.URI,
+		CID:        createResp.CID,
+		CreatedAt:  drawOfferRecord["createdAt"].(string),
+		GameURI:    gameID,
+		GameCID:    gameCID,
+		OfferedBy:  c.did,
+		MoveNumber: plyFromFEN(fen) + 1,
+		Message:    message,
+		Status:     "pending",
+		ExpiresAt:  expiresAt,
 	}, nil
 }
 
@@ -826,7 +2357,7 @@ func (c *Client) RespondToDrawOffer(ctx context.Context, drawOfferURI string, ac
 	// Get the draw offer record
 	url := fmt.Sprintf("%s/xrpc/com.atproto.repo.getRecord?repo=%s&collection=app.atchess.drawOffer&rkey=%s", 
 		c.pdsURL, repo, rkey)
-	resp, err := c.makeRequest("GET", url, nil)
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to get draw offer record: %w", err)
 	}
@@ -847,11 +2378,6 @@ func (c *Client) RespondToDrawOffer(ctx context.Context, drawOfferURI string, ac
 		return fmt.Errorf("failed to decode response: %w", err)
 	}
 	
-	// Verify the draw offer is still pending
-	if status, ok := getResp.Value["status"].(string); ok && status != "pending" {
-		return fmt.Errorf("draw offer is not pending, current status: %s", status)
-	}
-	
 	// Get the game reference
 	gameRef, ok := getResp.Value["game"].(map[string]interface{})
 	if !ok {
@@ -862,75 +2388,52 @@ func (c *Client) RespondToDrawOffer(ctx context.Context, drawOfferURI string, ac
 		return fmt.Errorf("missing game URI in draw offer")
 	}
 	
-	// Update the draw offer record
-	getResp.Value["status"] = "accepted"
+	response := "accepted"
 	if !accept {
-		getResp.Value["status"] = "declined"
+		response = "declined"
 	}
-	getResp.Value["respondedAt"] = time.Now().Format(time.RFC3339)
-	getResp.Value["respondedBy"] = c.did
 	
-	// Update the draw offer record
-	putReq := map[string]interface{}{
-		"repo":       repo,
-		"collection": "app.atchess.drawOffer",
-		"rkey":       rkey,
-		"record":     getResp.Value,
-		"swapCid":    getResp.CID,
+	// Update the draw offer record, unless it's been answered meanwhile
+	err = c.swapRecord(ctx, "app.atchess.drawOffer", drawOfferURI, getResp.CID, getResp.Value, func(value map[string]interface{}) error {
+		status, _ := value["status"].(string)
+		if status == response && value["respondedBy"] == c.did {
+			return errUnchanged
+		}
+		if status != "" && status != "pending" {
+			return fmt.Errorf("%w: draw offer is not pending, current status: %s", ErrConflict, status)
+		}
+		value["status"] = response
+		value["respondedAt"] = time.Now().Format(time.RFC3339)
+		value["respondedBy"] = c.did
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 	
-	putReqBody, _ := json.Marshal(putReq)
-	putResp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", putReqBody)
-	if err != nil {
-		return fmt.Errorf("failed to update draw offer record: %w", err)
+	// If the draw was accepted, update the game status if we own the game record
+	gameParts := strings.Split(gameURI, "/")
+	if !accept || len(gameParts) < 5 || gameParts[2] != c.did {
+		return nil
 	}
-	defer putResp.Body.Close()
 	
-	if putResp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(putResp.Body)
-		return fmt.Errorf("failed to update draw offer record: HTTP %d - %s", putResp.StatusCode, string(body))
+	gameCID, gameValue, err := c.getGameRecord(ctx, gameURI)
+	if err != nil {
+		return fmt.Errorf("failed to get game record for status update: %w", err)
 	}
 	
-	// If the draw was accepted, update the game status
-	if accept {
-		// Get the game record
-		gameCID, gameValue, err := c.getGameRecord(ctx, gameURI)
-		if err != nil {
-			return fmt.Errorf("failed to get game record for status update: %w", err)
+	return c.swapRecord(ctx, "app.atchess.game", gameURI, gameCID, gameValue, func(value map[string]interface{}) error {
+		status, _ := value["status"].(string)
+		if status == "draw" {
+			return errUnchanged
 		}
-		
-		// Parse the game URI to check if we own the game record
-		gameParts := strings.Split(gameURI, "/")
-		if len(gameParts) >= 5 && gameParts[2] == c.did {
-			// Update the game status to draw
-			gameValue["status"] = "draw"
-			gameValue["updatedAt"] = time.Now().Format(time.RFC3339)
-			
-			// Update the game record
-			gameRkey := gameParts[4]
-			updateGameReq := map[string]interface{}{
-				"repo":       c.did,
-				"collection": "app.atchess.game",
-				"rkey":       gameRkey,
-				"record":     gameValue,
-				"swapCid":    gameCID,
-			}
-			
-			updateGameReqBody, _ := json.Marshal(updateGameReq)
-			updateGameResp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", updateGameReqBody)
-			if err != nil {
-				return fmt.Errorf("failed to update game record: %w", err)
-			}
-			defer updateGameResp.Body.Close()
-			
-			if updateGameResp.StatusCode != http.StatusOK {
-				body, _ := io.ReadAll(updateGameResp.Body)
-				return fmt.Errorf("failed to update game record: HTTP %d - %s", updateGameResp.StatusCode, string(body))
-			}
+		if status != "" && status != "active" {
+			return fmt.Errorf("%w: game is %s", ErrConflict, status)
 		}
-	}
-	
-	return nil
+		setFinalStatus(value, chess.StatusDraw)
+		value["updatedAt"] = time.Now().Format(time.RFC3339)
+		return nil
+	})
 }
 
 // ResignGame creates a resignation record and updates the game status
@@ -943,7 +2446,7 @@ func (c *Client) ResignGame(ctx context.Context, gameID string, reason string) e
 	
 	// Verify the game is active
 	if status, ok := gameValue["status"].(string); ok && status != "active" {
-		return fmt.Errorf("cannot resign from a game with status: %s", status)
+		return fmt.Errorf("%w: cannot resign from a game with status: %s", ErrConflict, status)
 	}
 	
 	// Determine who won based on who is resigning
@@ -983,7 +2486,7 @@ func (c *Client) ResignGame(ctx context.Context, gameID string, reason string) e
 	}
 	
 	reqBody, _ := json.Marshal(createReq)
-	resp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
 	if err != nil {
 		return fmt.Errorf("failed to create resignation record: %w", err)
 	}
@@ -994,101 +2497,223 @@ func (c *Client) ResignGame(ctx context.Context, gameID string, reason string) e
 		return fmt.Errorf("failed to create resignation record: HTTP %d - %s", resp.StatusCode, string(body))
 	}
 	
+	var created struct {
+		URI string `json:"uri"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&created)
+	
 	// Update the game status if we own the game record
 	parts := strings.Split(gameID, "/")
-	if len(parts) >= 5 && parts[2] == c.did {
-		gameValue["status"] = newStatus
-		gameValue["updatedAt"] = time.Now().Format(time.RFC3339)
-		
-		// Update the game record
-		rkey := parts[4]
-		updateReq := map[string]interface{}{
-			"repo":       c.did,
-			"collection": "app.atchess.game",
-			"rkey":       rkey,
-			"record":     gameValue,
-			"swapCid":    gameCID,
+	if len(parts) < 5 || parts[2] != c.did {
+		return nil
+	}
+	
+	err = c.swapRecord(ctx, "app.atchess.game", gameID, gameCID, gameValue, func(value map[string]interface{}) error {
+		status, _ := value["status"].(string)
+		if status == newStatus {
+			return errUnchanged
 		}
-		
-		updateReqBody, _ := json.Marshal(updateReq)
-		updateResp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", updateReqBody)
-		if err != nil {
-			return fmt.Errorf("failed to update game record: %w", err)
+		if status != "" && status != "active" {
+			return fmt.Errorf("%w: game is %s", ErrConflict, status)
 		}
-		defer updateResp.Body.Close()
-		
-		if updateResp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(updateResp.Body)
-			return fmt.Errorf("failed to update game record: HTTP %d - %s", updateResp.StatusCode, string(body))
+		setFinalStatus(value, chess.GameStatus(newStatus))
+		value["updatedAt"] = time.Now().Format(time.RFC3339)
+		return nil
+	})
+	return c.withDiscard(ctx, err, "app.atchess.resignation", created.URI)
+}
+
+// GetDrawOffers retrieves the pending draw offer for a game. Offers that were
+// superseded by a later offer or by the game ending, withdrawn by the offering
+// player moving again, or that have expired are left out even if the sweeper
+// hasn't marked them yet.
+func (c *Client) GetDrawOffers(ctx context.Context, gameID string) ([]*DrawOffer, error) {
+	all, err := c.listDrawOffers(ctx, c.did)
+	if err != nil {
+		return nil, err
+	}
+	
+	var pending []*DrawOffer
+	for _, offer := range all {
+		if offer.GameURI == gameID && offer.Status == "pending" {
+			pending = append(pending, offer)
 		}
 	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
 	
-	return nil
+	_, gameValue, err := c.getGameRecord(ctx, gameID)
+	if err != nil {
+		gameValue = nil // Judged on the offers alone
+	}
+	superseded := supersededDrawOffers(pending, func(gameURI string) bool {
+		return ended(gameValue)
+	})
+	
+	now := time.Now()
+	var offers []*DrawOffer
+	for _, offer := range pending {
+		if _, ok := superseded[offer.URI]; ok {
+			continue
+		}
+		if staleDrawOffer(offer, gameValue, now) != "" {
+			continue
+		}
+		offers = append(offers, offer)
+	}
+	return offers, nil
 }
 
-// GetDrawOffers retrieves pending draw offers for a game
-func (c *Client) GetDrawOffers(ctx context.Context, gameID string) ([]*DrawOffer, error) {
-	// List draw offer records
-	url := fmt.Sprintf("%s/xrpc/com.atproto.repo.listRecords?repo=%s&collection=app.atchess.drawOffer&limit=100",
-		c.pdsURL, c.did)
-	resp, err := c.makeRequest("GET", url, nil)
+// listDrawOffers returns every draw offer in a repository
+func (c *Client) listDrawOffers(ctx context.Context, did string) ([]*DrawOffer, error) {
+	var offers []*DrawOffer
+	err := c.listAllRecords(ctx, did, "app.atchess.drawOffer", func(uri, cid string, value json.RawMessage) error {
+		var record struct {
+			CreatedAt string `json:"createdAt"`
+			Game      struct {
+				URI string `json:"uri"`
+				CID string `json:"cid"`
+			} `json:"game"`
+			OfferedBy   string `json:"offeredBy"`
+			MoveNumber  int    `json:"moveNumber"`
+			Message     string `json:"message"`
+			Status      string `json:"status"`
+			RespondedAt string `json:"respondedAt"`
+			RespondedBy string `json:"respondedBy"`
+			ExpiresAt   string `json:"expiresAt"`
+		}
+		if err := json.Unmarshal(value, &record); err != nil {
+			return nil // Skip malformed records
+		}
+		offers = append(offers, &DrawOffer{
+			URI:         uri,
+			CID:         cid,
+			CreatedAt:   record.CreatedAt,
+			GameURI:     record.Game.URI,
+			GameCID:     record.Game.CID,
+			OfferedBy:   record.OfferedBy,
+			MoveNumber:  record.MoveNumber,
+			Message:     record.Message,
+			Status:      record.Status,
+			RespondedAt: record.RespondedAt,
+			RespondedBy: record.RespondedBy,
+			ExpiresAt:   record.ExpiresAt,
+		})
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list draw offers: %w", err)
 	}
-	defer resp.Body.Close()
+	return offers, nil
+}
+
+// ended reports whether a game record has a final result. Games that
+// couldn't be loaded are treated as still running.
+func ended(gameValue map[string]interface{}) bool {
+	status, _ := gameValue["status"].(string)
+	return status != "" && status != "active"
+}
+
+// supersededDrawOffers returns the pending offers that no longer need an
+// answer, mapped to what superseded them: the newest offer in the same game,
+// or the game itself once it has ended
+func supersededDrawOffers(pending []*DrawOffer, ended func(gameURI string) bool) map[string]string {
+	byGame := make(map[string][]*DrawOffer)
+	for _, offer := range pending {
+		byGame[offer.GameURI] = append(byGame[offer.GameURI], offer)
+	}
+	
+	superseded := make(map[string]string)
+	for gameURI, offers := range byGame {
+		if ended(gameURI) {
+			for _, offer := range offers {
+				superseded[offer.URI] = gameURI
+			}
+			continue
+		}
+		
+		// Record keys are TIDs, so they order offers made in the same second
+		sort.Slice(offers, func(i, j int) bool {
+			if offers[i].CreatedAt != offers[j].CreatedAt {
+				return offers[i].CreatedAt < offers[j].CreatedAt
+			}
+			return offers[i].URI < offers[j].URI
+		})
+		latest := offers[len(offers)-1]
+		for _, offer := range offers[:len(offers)-1] {
+			superseded[offer.URI] = latest.URI
+		}
+	}
+	return superseded
+}
+
+// ExpireSupersededDrawOffers marks the user's pending draw offers that were
+// superseded by a later offer or by the game ending as expired, so they stop
+// showing up as open. Offers that lapsed are marked too: withdrawn once the
+// offering player moved again, or expired once they timed out. The updates
+// are batched with applyWrites. It returns how many offers were marked.
+func (c *Client) ExpireSupersededDrawOffers(ctx context.Context) (int, error) {
+	all, err := c.listDrawOffers(ctx, c.did)
+	if err != nil {
+		return 0, err
+	}
 	
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to list draw offers: HTTP %d - %s", resp.StatusCode, string(body))
-	}
-	
-	var listResp struct {
-		Records []struct {
-			URI   string `json:"uri"`
-			CID   string `json:"cid"`
-			Value struct {
-				Type      string `json:"$type"`
-				CreatedAt string `json:"createdAt"`
-				Game struct {
-					URI string `json:"uri"`
-					CID string `json:"cid"`
-				} `json:"game"`
-				OfferedBy    string `json:"offeredBy"`
-				MoveNumber   int    `json:"moveNumber"`
-				Message      string `json:"message"`
-				Status       string `json:"status"`
-				RespondedAt  string `json:"respondedAt"`
-				RespondedBy  string `json:"respondedBy"`
-			} `json:"value"`
-		} `json:"records"`
-	}
-	
-	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	var pending []*DrawOffer
+	for _, offer := range all {
+		if offer.Status == "pending" {
+			pending = append(pending, offer)
+		}
 	}
 	
-	// Filter for the specific game and pending status
-	var offers []*DrawOffer
-	for _, record := range listResp.Records {
-		if record.Value.Game.URI == gameID && record.Value.Status == "pending" {
-			offer := &DrawOffer{
-				URI:         record.URI,
-				CID:         record.CID,
-				CreatedAt:   record.Value.CreatedAt,
-				GameURI:     record.Value.Game.URI,
-				GameCID:     record.Value.Game.CID,
-				OfferedBy:   record.Value.OfferedBy,
-				MoveNumber:  record.Value.MoveNumber,
-				Message:     record.Value.Message,
-				Status:      record.Value.Status,
-				RespondedAt: record.Value.RespondedAt,
-				RespondedBy: record.Value.RespondedBy,
+	games := make(map[string]map[string]interface{})
+	game := func(gameURI string) map[string]interface{} {
+		if _, ok := games[gameURI]; !ok {
+			_, games[gameURI], _ = c.getGameRecord(ctx, gameURI)
+		}
+		return games[gameURI]
+	}
+	superseded := supersededDrawOffers(pending, func(gameURI string) bool {
+		return ended(game(gameURI))
+	})
+	
+	now := time.Now()
+	updates := make(map[string]map[string]interface{})
+	for _, offer := range pending {
+		by, isSuperseded := superseded[offer.URI]
+		lapsed := ""
+		if !isSuperseded {
+			if lapsed = staleDrawOffer(offer, game(offer.GameURI), now); lapsed == "" {
+				continue
 			}
-			offers = append(offers, offer)
 		}
+		_, value, err := c.getRecord(ctx, "app.atchess.drawOffer", offer.URI)
+		if err != nil {
+			continue // Picked up by the next sweep
+		}
+		switch {
+		case isSuperseded:
+			value["status"] = "expired"
+			value["expiredAt"] = now.Format(time.RFC3339)
+			value["supersededBy"] = by
+		case lapsed == "withdrawn":
+			value["status"] = "withdrawn"
+			value["withdrawnAt"] = now.Format(time.RFC3339)
+		default:
+			value["status"] = "expired"
+			value["expiredAt"] = now.Format(time.RFC3339)
+		}
+		updates[offer.URI[strings.LastIndex(offer.URI, "/")+1:]] = value
+	}
+	if len(updates) == 0 {
+		return 0, nil
 	}
 	
-	return offers, nil
+	failures := c.updateRecordsBatched(ctx, "app.atchess.drawOffer", updates)
+	for _, err := range failures {
+		return len(updates) - len(failures), fmt.Errorf("failed to expire %d draw offers: %w", len(failures), err)
+	}
+	return len(updates), nil
 }
 
 // DrawOffer represents a draw offer record
@@ -1104,6 +2729,7 @@ type DrawOffer struct {
 	Status      string
 	RespondedAt string
 	RespondedBy string
+	ExpiresAt   string // when the offer lapses unanswered, if it has a time limit
 }
 
 // TimeViolation represents a time violation claim record
@@ -1167,7 +2793,7 @@ func (c *Client) CheckTimeViolation(ctx context.Context, gameID string) (bool, *
 				
 				url := fmt.Sprintf("%s/xrpc/com.atproto.repo.getRecord?repo=%s&collection=app.atchess.challenge&rkey=%s",
 					c.pdsURL, challengeRepo, challengeRkey)
-				resp, err := c.makeRequest("GET", url, nil)
+				resp, err := c.makeRequest(ctx, "GET", url, nil)
 				if err == nil && resp.StatusCode == http.StatusOK {
 					defer resp.Body.Close()
 					
@@ -1201,7 +2827,7 @@ func (c *Client) CheckTimeViolation(ctx context.Context, gameID string) (bool, *
 	// For correspondence games, check the last move timestamp
 	if timeControlType == "correspondence" {
 		// Get the most recent move
-		lastMove, err := c.getLastMove(ctx, gameID, currentPlayerDID)
+		lastMove, err := c.getLastMove(ctx, gameID, gameValue, fen, currentPlayerDID)
 		if err != nil {
 			return false, nil, fmt.Errorf("failed to get last move: %w", err)
 		}
@@ -1225,9 +2851,9 @@ func (c *Client) CheckTimeViolation(ctx context.Context, gameID string) (bool, *
 			}
 		}
 		
-		// Check if time has expired
+		// Check if time has expired, not counting time the clock was paused
 		timeLimit := time.Duration(daysPerMove) * 24 * time.Hour
-		if time.Since(lastMoveTime) > timeLimit {
+		if time.Since(lastMoveTime)-c.pausedSince(gameID, lastMoveTime) > timeLimit {
 			// Time violation detected
 			violation := &TimeViolation{
 				GameURI:           gameID,
@@ -1248,99 +2874,6 @@ func (c *Client) CheckTimeViolation(ctx context.Context, gameID string) (bool, *
 	return false, nil, nil
 }
 
-// getLastMove retrieves the most recent move in a game
-func (c *Client) getLastMove(ctx context.Context, gameID string, excludePlayerDID string) (*struct {
-	CreatedAt string
-	Player    string
-}, error) {
-	// List moves for both players
-	players := []string{}
-	
-	// Parse game URI to get players
-	gameParts := strings.Split(gameID, "/")
-	if len(gameParts) >= 5 {
-		gameRepo := gameParts[2]
-		players = append(players, gameRepo)
-	}
-	
-	// Get game record to find the other player
-	_, gameValue, err := c.getGameRecord(ctx, gameID)
-	if err != nil {
-		return nil, err
-	}
-	
-	whiteDID, _ := gameValue["white"].(string)
-	blackDID, _ := gameValue["black"].(string)
-	
-	// Add the other player if different from repo owner
-	if whiteDID != players[0] {
-		players = append(players, whiteDID)
-	}
-	if blackDID != players[0] && blackDID != whiteDID {
-		players = append(players, blackDID)
-	}
-	
-	var lastMove *struct {
-		CreatedAt string
-		Player    string
-	}
-	var lastMoveTime time.Time
-	
-	// Check moves from all players
-	for _, playerDID := range players {
-		url := fmt.Sprintf("%s/xrpc/com.atproto.repo.listRecords?repo=%s&collection=app.atchess.move&limit=100",
-			c.pdsURL, playerDID)
-		resp, err := c.makeRequest("GET", url, nil)
-		if err != nil {
-			continue // Skip if we can't access this player's moves
-		}
-		defer resp.Body.Close()
-		
-		if resp.StatusCode != http.StatusOK {
-			continue
-		}
-		
-		var listResp struct {
-			Records []struct {
-				Value struct {
-					CreatedAt string `json:"createdAt"`
-					Game      struct {
-						URI string `json:"uri"`
-					} `json:"game"`
-					Player string `json:"player"`
-				} `json:"value"`
-			} `json:"records"`
-		}
-		
-		if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
-			continue
-		}
-		
-		// Find the most recent move for this game
-		for _, record := range listResp.Records {
-			if record.Value.Game.URI == gameID && record.Value.Player != excludePlayerDID {
-				moveTime, err := time.Parse(time.RFC3339, record.Value.CreatedAt)
-				if err != nil {
-					continue
-				}
-				
-				if lastMove == nil || moveTime.After(lastMoveTime) {
-					lastMoveTime = moveTime
-					lastMove = &struct {
-						CreatedAt string
-						Player    string
-					}{
-						CreatedAt: record.Value.CreatedAt,
-						Player:    record.Value.Player,
-					}
-				}
-			}
-		}
-	}
-	
-	return lastMove, nil
-}
-
 // ClaimTimeVictory claims victory due to opponent's time violation
 func (c *Client) ClaimTimeVictory(ctx context.Context, gameID string) error {
 	// First check if there's actually a time violation
@@ -1396,7 +2929,7 @@ func (c *Client) ClaimTimeVictory(ctx context.Context, gameID string) error {
 	}
 	
 	reqBody, _ := json.Marshal(createReq)
-	resp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
 	if err != nil {
 		return fmt.Errorf("failed to create time violation record: %w", err)
 	}
@@ -1418,7 +2951,7 @@ func (c *Client) ClaimTimeVictory(ctx context.Context, gameID string) error {
 			newStatus = "white_won"
 		}
 		
-		gameValue["status"] = newStatus
+		setFinalStatus(gameValue, chess.GameStatus(newStatus))
 		gameValue["updatedAt"] = time.Now().Format(time.RFC3339)
 		
 		// Update the game record
@@ -1432,7 +2965,97 @@ func (c *Client) ClaimTimeVictory(ctx context.Context, gameID string) error {
 		}
 		
 		updateReqBody, _ := json.Marshal(updateReq)
-		updateResp, err := c.makeRequest("POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", updateReqBody)
+		updateResp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", updateReqBody)
+		if err != nil {
+			return fmt.Errorf("failed to update game record: %w", err)
+		}
+		defer updateResp.Body.Close()
+		
+		if updateResp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(updateResp.Body)
+			return fmt.Errorf("failed to update game record: HTTP %d - %s", updateResp.StatusCode, string(body))
+		}
+	}
+	
+	return nil
+}
+
+// ClaimDisconnectVictory claims victory because the opponent dropped out of a
+// live game and didn't reconnect within the grace period
+func (c *Client) ClaimDisconnectVictory(ctx context.Context, gameID, absentDID string) error {
+	gameCID, gameValue, err := c.getGameRecord(ctx, gameID)
+	if err != nil {
+		return fmt.Errorf("failed to get game record: %w", err)
+	}
+	
+	if status, ok := gameValue["status"].(string); ok && status != "active" {
+		return fmt.Errorf("cannot claim victory in a game with status: %s", status)
+	}
+	
+	whiteDID, _ := gameValue["white"].(string)
+	blackDID, _ := gameValue["black"].(string)
+	
+	var newStatus string
+	switch {
+	case c.did == whiteDID && absentDID == blackDID:
+		newStatus = "white_won"
+	case c.did == blackDID && absentDID == whiteDID:
+		newStatus = "black_won"
+	default:
+		return fmt.Errorf("you and the absent player must be the two players in this game")
+	}
+	
+	violationRecord := map[string]interface{}{
+		"$type":     "app.atchess.timeViolation",
+		"createdAt": time.Now().Format(time.RFC3339),
+		"game": map[string]interface{}{
+			"uri": gameID,
+			"cid": gameCID,
+		},
+		"claimingPlayer":  c.did,
+		"violatingPlayer": absentDID,
+		"reason":          "disconnected",
+	}
+	if tc, ok := gameValue["timeControl"].(map[string]interface{}); ok {
+		if tcType, ok := tc["type"].(string); ok {
+			violationRecord["timeControlType"] = tcType
+		}
+	}
+	
+	createReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.timeViolation",
+		"record":     violationRecord,
+	}
+	
+	reqBody, _ := json.Marshal(createReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create time violation record: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to create time violation record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	
+	// Update game status if we own the game record
+	parts := strings.Split(gameID, "/")
+	if len(parts) >= 5 && parts[2] == c.did {
+		setFinalStatus(gameValue, chess.GameStatus(newStatus))
+		gameValue["updatedAt"] = time.Now().Format(time.RFC3339)
+		
+		updateReq := map[string]interface{}{
+			"repo":       c.did,
+			"collection": "app.atchess.game",
+			"rkey":       parts[4],
+			"record":     gameValue,
+			"swapCid":    gameCID,
+		}
+		
+		updateReqBody, _ := json.Marshal(updateReq)
+		updateResp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", updateReqBody)
 		if err != nil {
 			return fmt.Errorf("failed to update game record: %w", err)
 		}
@@ -1447,17 +3070,49 @@ func (c *Client) ClaimTimeVictory(ctx context.Context, gameID string) error {
 	return nil
 }
 
+// ErrNoMoveDeadline is returned for games whose time control has no per-move deadline
+var ErrNoMoveDeadline = errors.New("time control has no per-move deadline")
+
+// ErrGameNotActive is returned when a game has already finished
+var ErrGameNotActive = errors.New("game is not active")
+
+// MoveDeadline is when the player to move in a game must move by
+type MoveDeadline struct {
+	GameID       string
+	PlayerToMove string // DID
+	// TurnStarted is when the opponent's last move was made, or the game
+	// created if nobody has moved
+	TurnStarted time.Time
+	Deadline    time.Time
+}
+
 // GetTimeRemaining calculates time remaining for the current player in a game
 func (c *Client) GetTimeRemaining(ctx context.Context, gameID string) (time.Duration, error) {
+	deadline, err := c.GetMoveDeadline(ctx, gameID)
+	if err != nil {
+		return 0, err
+	}
+	
+	remaining := time.Until(deadline.Deadline)
+	if remaining < 0 {
+		return 0, nil // Time has expired
+	}
+	
+	return remaining, nil
+}
+
+// GetMoveDeadline returns when the player to move must move by. Only
+// correspondence games have per-move deadlines.
+func (c *Client) GetMoveDeadline(ctx context.Context, gameID string) (*MoveDeadline, error) {
 	// Get the game record
 	_, gameValue, err := c.getGameRecord(ctx, gameID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get game record: %w", err)
+		return nil, fmt.Errorf("failed to get game record: %w", err)
 	}
 	
 	// Check if game is still active
 	if status, ok := gameValue["status"].(string); ok && status != "active" {
-		return 0, fmt.Errorf("game is not active")
+		return nil, fmt.Errorf("%w: %s", ErrGameNotActive, status)
 	}
 	
 	// Get players
@@ -1468,7 +3123,7 @@ func (c *Client) GetTimeRemaining(ctx context.Context, gameID string) (time.Dura
 	fen, _ := gameValue["fen"].(string)
 	fenParts := strings.Split(fen, " ")
 	if len(fenParts) < 2 {
-		return 0, fmt.Errorf("invalid FEN format")
+		return nil, fmt.Errorf("invalid FEN format")
 	}
 	
 	var currentPlayerDID string
@@ -1478,11 +3133,14 @@ func (c *Client) GetTimeRemaining(ctx context.Context, gameID string) (time.Dura
 		currentPlayerDID = blackDID
 	}
 	
-	// Get time control settings from challenge
+	// Get time control settings from the game, or else from its challenge
 	var timeControlType string
 	var daysPerMove int
 	
-	if challengeRef, ok := gameValue["challenge"].(map[string]interface{}); ok {
+	if tc, ok := gameValue["timeControl"].(map[string]interface{}); ok {
+		timeControlType, _ = tc["type"].(string)
+		daysPerMove = intValue(tc["daysPerMove"])
+	} else if challengeRef, ok := gameValue["challenge"].(map[string]interface{}); ok {
 		challengeURI, _ := challengeRef["uri"].(string)
 		if challengeURI != "" {
 			challengeParts := strings.Split(challengeURI, "/")
@@ -1492,7 +3150,7 @@ func (c *Client) GetTimeRemaining(ctx context.Context, gameID string) (time.Dura
 				
 				url := fmt.Sprintf("%s/xrpc/com.atproto.repo.getRecord?repo=%s&collection=app.atchess.challenge&rkey=%s",
 					c.pdsURL, challengeRepo, challengeRkey)
-				resp, err := c.makeRequest("GET", url, nil)
+				resp, err := c.makeRequest(ctx, "GET", url, nil)
 				if err == nil && resp.StatusCode == http.StatusOK {
 					defer resp.Body.Close()
 					
@@ -1526,41 +3184,41 @@ func (c *Client) GetTimeRemaining(ctx context.Context, gameID string) (time.Dura
 	// For correspondence games, calculate time remaining
 	if timeControlType == "correspondence" {
 		// Get the most recent move
-		lastMove, err := c.getLastMove(ctx, gameID, currentPlayerDID)
+		lastMove, err := c.getLastMove(ctx, gameID, gameValue, fen, currentPlayerDID)
 		if err != nil {
-			return 0, fmt.Errorf("failed to get last move: %w", err)
+			return nil, fmt.Errorf("failed to get last move: %w", err)
 		}
 		
 		var lastMoveTime time.Time
 		if lastMove != nil {
 			lastMoveTime, err = time.Parse(time.RFC3339, lastMove.CreatedAt)
 			if err != nil {
-				return 0, fmt.Errorf("failed to parse move timestamp: %w", err)
+				return nil, fmt.Errorf("failed to parse move timestamp: %w", err)
 			}
 		} else {
 			// Use game creation time
 			if createdAt, ok := gameValue["createdAt"].(string); ok {
 				lastMoveTime, err = time.Parse(time.RFC3339, createdAt)
 				if err != nil {
-					return 0, fmt.Errorf("failed to parse game creation timestamp: %w", err)
+					return nil, fmt.Errorf("failed to parse game creation timestamp: %w", err)
 				}
 			} else {
-				return 0, fmt.Errorf("game missing createdAt timestamp")
+				return nil, fmt.Errorf("game missing createdAt timestamp")
 			}
 		}
 		
-		// Calculate time remaining
-		timeLimit := time.Duration(daysPerMove) * 24 * time.Hour
-		elapsed := time.Since(lastMoveTime)
-		remaining := timeLimit - elapsed
-		
-		if remaining < 0 {
-			return 0, nil // Time has expired
+		if daysPerMove <= 0 {
+			daysPerMove = 3
 		}
 		
-		return remaining, nil
+		return &MoveDeadline{
+			GameID:       gameID,
+			PlayerToMove: currentPlayerDID,
+			TurnStarted:  lastMoveTime.UTC(),
+			Deadline:     lastMoveTime.Add(time.Duration(daysPerMove)*24*time.Hour + c.pausedSince(gameID, lastMoveTime)).UTC(),
+		}, nil
 	}
 	
 	// TODO: Implement for other time control types
-	return 0, fmt.Errorf("time control type %s not yet implemented", timeControlType)
+	return nil, fmt.Errorf("%w: %s", ErrNoMoveDeadline, timeControlType)
 }
\ No newline at end of file
@@ -1,10 +1,17 @@
 package atproto
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/justinabrahms/atchess/internal/auth"
+	"github.com/justinabrahms/atchess/internal/oauth"
 )
 
 func TestNewClientWithDPoP(t *testing.T) {
@@ -134,4 +141,58 @@ func TestNewClientWithoutDPoP(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create game: %v", err)
 	}
-}
\ No newline at end of file
+}
+func TestNewClientWithOAuthSession(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	wantJWK, _ := auth.PrivateKeyToJWK(key)
+
+	var pdsURL string
+	mockPDS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/xrpc/com.atproto.server.createSession" {
+			t.Error("An OAuth client mustn't create a password session")
+		}
+		if got := r.Header.Get("Authorization"); got != "DPoP oauth-access" {
+			t.Errorf("Expected the session's access token, got %q", got)
+		}
+		proof := r.Header.Get("DPoP")
+		if err := auth.ValidateProof(proof, r.Method, pdsURL+r.URL.Path, "oauth-access"); err != nil {
+			t.Errorf("Invalid DPoP proof: %v", err)
+		}
+		// The proof must be signed with the key the tokens are bound to
+		if header, _, err := auth.VerifyJWT(proof); err != nil || header.JWK.X != wantJWK.X || header.JWK.Y != wantJWK.Y {
+			t.Errorf("Expected the proof to carry the session's key, got %+v, %v", header, err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"uri": "at://did:plc:oauthuser/app.atchess.game/abc123", "cid": "test-cid"}`))
+	}))
+	defer mockPDS.Close()
+	pdsURL = mockPDS.URL
+
+	client, err := NewClientWithOAuthSession(mockPDS.URL, &oauth.Session{
+		DID:         "did:plc:oauthuser",
+		Handle:      "oauth.user",
+		AccessToken: "oauth-access",
+		DPoPKey:     key,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if client.GetDID() != "did:plc:oauthuser" || client.GetHandle() != "oauth.user" {
+		t.Errorf("Expected the session's identity, got %s %s", client.GetDID(), client.GetHandle())
+	}
+
+	game, err := client.CreateGame(context.Background(), "did:plc:opponent", "white")
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	if game.White != "did:plc:oauthuser" {
+		t.Errorf("Expected the game to be created as the session's user, got %s", game.White)
+	}
+
+	if _, err := NewClientWithOAuthSession(mockPDS.URL, &oauth.Session{DID: "did:plc:oauthuser"}); err == nil {
+		t.Error("Expected a session without a DPoP key to be refused")
+	}
+}
@@ -3,10 +3,15 @@ package atproto
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
 )
 
 func TestCreateChallengeNotification(t *testing.T) {
@@ -290,4 +295,139 @@ func TestChallengeNotificationExpiration(t *testing.T) {
 	if len(notifications) > 0 && notifications[0].ChallengerHandle != "player1.chess" {
 		t.Errorf("Expected valid notification from player1.chess, got %s", notifications[0].ChallengerHandle)
 	}
-}
\ No newline at end of file
+}
+func TestGameUpdatesPreserveExtensions(t *testing.T) {
+	gameURI := "at://did:plc:test123/app.atchess.game/g1"
+	var stored map[string]interface{}
+	mockPDS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/xrpc/com.atproto.server.createSession":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"accessJwt": "test-jwt",
+				"did":       "did:plc:test123",
+				"handle":    "test.user",
+			})
+		case "/xrpc/com.atproto.repo.getRecord":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"uri": gameURI,
+				"cid": "game-cid",
+				"value": map[string]interface{}{
+					"createdAt": "2024-01-01T00:00:00Z",
+					"white":     "did:plc:test123",
+					"black":     "did:plc:opponent",
+					"status":    "active",
+					"fen":       "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+					"extensions": map[string]interface{}{
+						"com.example.overlay": map[string]interface{}{"layout": "compact"},
+						"invalid":             map[string]interface{}{"dropped": true},
+					},
+				},
+			})
+		case "/xrpc/com.atproto.repo.listRecords":
+			json.NewEncoder(w).Encode(map[string]interface{}{"records": []interface{}{}})
+		case "/xrpc/com.atproto.repo.createRecord":
+			json.NewEncoder(w).Encode(map[string]interface{}{"uri": "at://did:plc:test123/app.atchess.move/m1", "cid": "move-cid"})
+		case "/xrpc/com.atproto.repo.putRecord":
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			stored, _ = req["record"].(map[string]interface{})
+			json.NewEncoder(w).Encode(map[string]interface{}{"uri": gameURI, "cid": "game-cid-2"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockPDS.Close()
+
+	client, err := NewClient(mockPDS.URL, "test.user", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	game, err := client.GetGame(context.Background(), gameURI)
+	if err != nil {
+		t.Fatalf("Failed to get game: %v", err)
+	}
+	if len(game.Extensions) != 1 || game.Extensions["com.example.overlay"]["layout"] != "compact" {
+		t.Errorf("Expected the valid extension to be surfaced, got %v", game.Extensions)
+	}
+
+	move := &chess.MoveResult{From: "e2", To: "e4", SAN: "e4", FEN: "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"}
+	if err := client.RecordMove(context.Background(), gameURI, move); err != nil {
+		t.Fatalf("Failed to record move: %v", err)
+	}
+	extensions, _ := stored["extensions"].(map[string]interface{})
+	if len(extensions) != 2 {
+		t.Errorf("Expected the game update to keep the record's extensions as they were, got %v", stored["extensions"])
+	}
+}
+
+// pagedPDS serves n challenge notifications and moves a page at a time,
+// counting listRecords requests
+func pagedPDS(t *testing.T, n int, requests *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/xrpc/com.atproto.server.createSession":
+			json.NewEncoder(w).Encode(map[string]interface{}{"accessJwt": "test-jwt", "did": "did:plc:test123", "handle": "test.user"})
+		case "/xrpc/com.atproto.repo.listRecords":
+			*requests++
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			start, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+			end := min(start+limit, n)
+			collection := r.URL.Query().Get("collection")
+			var records []map[string]interface{}
+			for i := start; i < end; i++ {
+				value := map[string]interface{}{
+					"createdAt": time.Now().Format(time.RFC3339),
+					"expiresAt": time.Now().Add(time.Hour).Format(time.RFC3339),
+					"game":      map[string]string{"uri": fmt.Sprintf("at://did:plc:test123/app.atchess.game/g%d", i)},
+				}
+				records = append(records, map[string]interface{}{
+					"uri":   fmt.Sprintf("at://did:plc:test123/%s/r%d", collection, i),
+					"cid":   fmt.Sprintf("cid%d", i),
+					"value": value,
+				})
+			}
+			resp := map[string]interface{}{"records": records}
+			if end < n {
+				resp["cursor"] = strconv.Itoa(end)
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestListingFollowsCursors(t *testing.T) {
+	requests := 0
+	server := pagedPDS(t, 250, &requests)
+	client, err := NewClient(server.URL, "test.user", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	notifications, err := client.GetChallengeNotifications(context.Background())
+	if err != nil || len(notifications) != 250 || requests != 3 {
+		t.Fatalf("Expected every notification over three pages, got %d in %d requests (%v)", len(notifications), requests, err)
+	}
+
+	// A lookup stops at the page where it finds its record
+	client.SetListPageSize(20)
+	requests = 0
+	uri, _, _, found, err := client.findRecord(context.Background(), client.did, "app.atchess.move", func(uri string, value json.RawMessage) bool {
+		return strings.HasSuffix(uri, "/r45")
+	})
+	if err != nil || !found || !strings.HasSuffix(uri, "/r45") || requests != 3 {
+		t.Errorf("Expected r45 found on the third page, got %s after %d requests (%v)", uri, requests, err)
+	}
+
+	client.SetListPageSize(1000)
+	if client.ListPageSize() != MaxListPageSize {
+		t.Errorf("Expected the page size capped at %d, got %d", MaxListPageSize, client.ListPageSize())
+	}
+}
@@ -0,0 +1,156 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Conditional move statuses, as in the app.atchess.conditionalMove lexicon
+const (
+	ConditionalActive    = "active"
+	ConditionalCompleted = "completed"
+	ConditionalBroken    = "broken"
+	ConditionalCancelled = "cancelled"
+)
+
+// ConditionalMove is an app.atchess.conditionalMove record: a line of moves
+// in UCI notation alternating the opponent's expected move and the player's
+// reply, starting from FEN with the opponent to move
+type ConditionalMove struct {
+	URI       string   `json:"uri"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt,omitempty"`
+	GameURI   string   `json:"gameUri"`
+	Player    string   `json:"player"`
+	FEN       string   `json:"fen"`
+	Moves     []string `json:"moves"`
+	Played    int      `json:"played"` // moves of the line played so far
+	Status    string   `json:"status"`
+}
+
+// CreateConditionalMove stores a line of conditional moves for a game in the
+// current account's repository
+func (c *Client) CreateConditionalMove(ctx context.Context, gameURI, fen string, moves []string) (*ConditionalMove, error) {
+	gameCID, _, err := c.getGameRecord(ctx, gameURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game record: %w", err)
+	}
+
+	conditional := &ConditionalMove{
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		GameURI:   gameURI,
+		Player:    c.did,
+		FEN:       fen,
+		Moves:     moves,
+		Status:    ConditionalActive,
+	}
+	createReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.conditionalMove",
+		"record": map[string]interface{}{
+			"$type":     "app.atchess.conditionalMove",
+			"createdAt": conditional.CreatedAt,
+			"game": map[string]interface{}{
+				"uri": gameURI,
+				"cid": gameCID,
+			},
+			"player": c.did,
+			"fen":    fen,
+			"moves":  moves,
+			"played": 0,
+			"status": ConditionalActive,
+		},
+	}
+
+	reqBody, _ := json.Marshal(createReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conditional move record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to create conditional move record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var createResp struct {
+		URI string `json:"uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&createResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	conditional.URI = createResp.URI
+	return conditional, nil
+}
+
+// ListConditionalMoves returns a player's conditional moves for a game,
+// oldest first
+func (c *Client) ListConditionalMoves(ctx context.Context, did, gameURI string) ([]*ConditionalMove, error) {
+	conditionals := []*ConditionalMove{}
+	err := c.listAllRecords(ctx, did, "app.atchess.conditionalMove", func(uri, cid string, value json.RawMessage) error {
+		var record struct {
+			CreatedAt string `json:"createdAt"`
+			UpdatedAt string `json:"updatedAt"`
+			Game      struct {
+				URI string `json:"uri"`
+			} `json:"game"`
+			Player string   `json:"player"`
+			FEN    string   `json:"fen"`
+			Moves  []string `json:"moves"`
+			Played int      `json:"played"`
+			Status string   `json:"status"`
+		}
+		if err := json.Unmarshal(value, &record); err != nil {
+			return nil // Skip malformed records
+		}
+		if record.Game.URI != gameURI || record.Player != did {
+			return nil
+		}
+		conditionals = append(conditionals, &ConditionalMove{
+			URI:       uri,
+			CreatedAt: record.CreatedAt,
+			UpdatedAt: record.UpdatedAt,
+			GameURI:   record.Game.URI,
+			Player:    record.Player,
+			FEN:       record.FEN,
+			Moves:     record.Moves,
+			Played:    record.Played,
+			Status:    record.Status,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conditional moves: %w", err)
+	}
+	// Record keys are TIDs, so they order lines entered in the same second
+	sort.Slice(conditionals, func(i, j int) bool {
+		if conditionals[i].CreatedAt != conditionals[j].CreatedAt {
+			return conditionals[i].CreatedAt < conditionals[j].CreatedAt
+		}
+		return conditionals[i].URI < conditionals[j].URI
+	})
+	return conditionals, nil
+}
+
+// UpdateConditionalMove records how far a line of conditional moves has been
+// played and its status
+func (c *Client) UpdateConditionalMove(ctx context.Context, conditional *ConditionalMove) error {
+	cid, value, err := c.getRecord(ctx, "app.atchess.conditionalMove", conditional.URI)
+	if err != nil {
+		return fmt.Errorf("failed to get conditional move record: %w", err)
+	}
+	conditional.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	value["played"] = conditional.Played
+	value["status"] = conditional.Status
+	value["updatedAt"] = conditional.UpdatedAt
+	if err := c.updateRecord(ctx, "app.atchess.conditionalMove", conditional.URI, cid, value); err != nil {
+		return fmt.Errorf("failed to update conditional move record: %w", err)
+	}
+	return nil
+}
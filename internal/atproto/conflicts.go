@@ -0,0 +1,125 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrConflict is returned when a record changed concurrently in a way the
+// update can't be reapplied to, e.g. the game finished or the opponent's
+// move was recorded first
+var ErrConflict = errors.New("conflicting concurrent update")
+
+// maxSwapAttempts bounds how many times an update whose swapCid no longer
+// matches is re-read and reapplied
+const maxSwapAttempts = 4
+
+// errSwapFailed is returned by putRecord when the record's CID no longer
+// matches swapCid
+var errSwapFailed = errors.New("record was modified concurrently")
+
+// errUnchanged is returned by a swapRecord update when the record already
+// reflects it, so there's nothing to write
+var errUnchanged = errors.New("record already up to date")
+
+// swapRecord applies update to a record read at cid and writes it back with
+// swapCid, so a concurrent write isn't overwritten. If the record changed in
+// the meantime, it's read again and update reapplied to the new value, up to
+// maxSwapAttempts times. update returns ErrConflict when the record's current
+// state makes the change impossible, and errUnchanged when it's already made.
+func (c *Client) swapRecord(ctx context.Context, collection, uri, cid string, value map[string]interface{}, update func(value map[string]interface{}) error) error {
+	for attempt := 1; ; attempt++ {
+		if err := update(value); err != nil {
+			if errors.Is(err, errUnchanged) {
+				return nil
+			}
+			return err
+		}
+
+		err := c.putRecord(ctx, collection, uri, cid, value)
+		if !errors.Is(err, errSwapFailed) {
+			return err
+		}
+		if attempt >= maxSwapAttempts {
+			return fmt.Errorf("failed to update %s record after %d attempts: %w", collection, attempt, err)
+		}
+
+		cid, value, err = c.getRecord(ctx, collection, uri)
+		if err != nil {
+			return fmt.Errorf("failed to re-read %s record: %w", collection, err)
+		}
+	}
+}
+
+// putRecord writes a record back to the repository it came from, failing
+// with errSwapFailed if it changed since it was read at cid
+func (c *Client) putRecord(ctx context.Context, collection, uri, cid string, value map[string]interface{}) error {
+	parts := strings.Split(uri, "/")
+	if len(parts) < 5 || !strings.HasPrefix(uri, "at://") {
+		return fmt.Errorf("invalid AT Protocol URI format: %s", uri)
+	}
+
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"repo":       parts[2],
+		"collection": collection,
+		"rkey":       parts[4],
+		"record":     value,
+		"swapCid":    cid,
+	})
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to update %s record: %w", collection, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		var xrpcErr struct {
+			Error string `json:"error"`
+		}
+		if resp.StatusCode == http.StatusBadRequest && json.Unmarshal(body, &xrpcErr) == nil && xrpcErr.Error == "InvalidSwap" {
+			return fmt.Errorf("failed to update %s record: %w", collection, errSwapFailed)
+		}
+		return fmt.Errorf("failed to update %s record: HTTP %d - %s", collection, resp.StatusCode, string(body))
+	}
+
+	// The response names the new version, so reading the record back is free
+	var putResp struct {
+		CID string `json:"cid"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&putResp) == nil {
+		if record, err := json.Marshal(value); err == nil {
+			c.records.put(uri, putResp.CID, record)
+		}
+	}
+	return nil
+}
+
+// discardRecord deletes a record of ours created as part of an update that
+// then conflicted, so it doesn't linger as if the update had happened
+func (c *Client) discardRecord(ctx context.Context, collection, uri string) error {
+	parts := strings.Split(uri, "/")
+	if len(parts) < 5 || parts[2] != c.did {
+		return nil
+	}
+	if err := c.deleteRecordsBatched(ctx, collection, []string{parts[4]})[parts[4]]; err != nil {
+		return fmt.Errorf("failed to delete %s record: %w", collection, err)
+	}
+	return nil
+}
+
+// withDiscard returns the error of a conflicting update, after deleting the
+// record created alongside it
+func (c *Client) withDiscard(ctx context.Context, err error, collection, uri string) error {
+	if errors.Is(err, ErrConflict) && uri != "" {
+		if discardErr := c.discardRecord(ctx, collection, uri); discardErr != nil {
+			return fmt.Errorf("%w (%v)", err, discardErr)
+		}
+	}
+	return err
+}
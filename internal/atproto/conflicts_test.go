@@ -0,0 +1,174 @@
+package atproto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+const (
+	swapGameURI  = "at://did:plc:test123/app.atchess.game/g1"
+	swapOfferURI = "at://did:plc:test123/app.atchess.drawOffer/d1"
+	afterE4      = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"
+)
+
+// newSwapPDS holds an active game and a pending draw offer in it
+func newSwapPDS(t *testing.T) *fakePDS {
+	t.Helper()
+	pds := newFakePDS(t)
+	pds.put(swapGameURI, map[string]interface{}{
+		"createdAt": "2024-01-01T00:00:00Z",
+		"white":     "did:plc:test123",
+		"black":     "did:plc:opponent",
+		"status":    "active",
+		"fen":       chess.StartingFEN,
+	})
+	pds.put(swapOfferURI, map[string]interface{}{
+		"createdAt": "2024-01-01T00:00:00Z",
+		"game":      map[string]interface{}{"uri": swapGameURI, "cid": "cid1"},
+		"offeredBy": "did:plc:opponent",
+		"status":    "pending",
+	})
+	return pds
+}
+
+// interfereOnce makes a concurrent edit before the first putRecord only
+func interfereOnce(edit func(value map[string]interface{})) func(*fakePDS, string) {
+	done := false
+	return func(pds *fakePDS, uri string) {
+		if !done {
+			done = true
+			pds.change(uri, edit)
+		}
+	}
+}
+
+func TestRecordMoveReappliesAfterUnrelatedWrite(t *testing.T) {
+	pds := newSwapPDS(t)
+	pds.interfere = interfereOnce(func(value map[string]interface{}) {
+		value["extensions"] = map[string]interface{}{"com.example.overlay": map[string]interface{}{"layout": "compact"}}
+	})
+	client := newFakeClient(t, pds)
+
+	move := &chess.MoveResult{From: "e2", To: "e4", SAN: "e4", FEN: afterE4}
+	if err := client.RecordMove(context.Background(), swapGameURI, move); err != nil {
+		t.Fatalf("Expected the move to be reapplied, got %v", err)
+	}
+
+	game := pds.record(swapGameURI)
+	if game["fen"] != afterE4 || game["extensions"] == nil {
+		t.Errorf("Expected the move and the concurrent write to both be kept, got %v", game)
+	}
+	if puts := pds.requests("com.atproto.repo.putRecord"); puts != 2 {
+		t.Errorf("Expected one retry, got %d writes", puts)
+	}
+	if len(pds.deleted) != 0 {
+		t.Errorf("Expected the move record to be kept, deleted %v", pds.deleted)
+	}
+}
+
+func TestRecordMoveConflictsWithFinishedGame(t *testing.T) {
+	pds := newSwapPDS(t)
+	pds.interfere = interfereOnce(func(value map[string]interface{}) {
+		value["status"] = "black_won"
+	})
+	client := newFakeClient(t, pds)
+
+	move := &chess.MoveResult{From: "e2", To: "e4", SAN: "e4", FEN: afterE4}
+	err := client.RecordMove(context.Background(), swapGameURI, move)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+	if pds.record(swapGameURI)["fen"] != chess.StartingFEN {
+		t.Error("Expected the finished game to be left alone")
+	}
+	if len(pds.deleted) != 1 || pds.deleted[0] != "app.atchess.move/new1" {
+		t.Errorf("Expected the orphaned move record to be deleted, got %v", pds.deleted)
+	}
+}
+
+func TestRecordMoveConflictsWithOtherMove(t *testing.T) {
+	pds := newSwapPDS(t)
+	pds.interfere = interfereOnce(func(value map[string]interface{}) {
+		value["fen"] = "rnbqkbnr/pppppppp/8/8/3P4/8/PPP1PPPP/RNBQKBNR b KQkq d3 0 1"
+	})
+	client := newFakeClient(t, pds)
+
+	move := &chess.MoveResult{From: "e2", To: "e4", SAN: "e4", FEN: afterE4}
+	if err := client.RecordMove(context.Background(), swapGameURI, move); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+}
+
+func TestResignConflictsWithFinishedGame(t *testing.T) {
+	pds := newSwapPDS(t)
+	pds.interfere = interfereOnce(func(value map[string]interface{}) {
+		value["status"] = "draw"
+	})
+	client := newFakeClient(t, pds)
+
+	if err := client.ResignGame(context.Background(), swapGameURI, ""); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+	if pds.record(swapGameURI)["status"] != "draw" {
+		t.Errorf("Expected the draw to stand, got %v", pds.record(swapGameURI)["status"])
+	}
+	if len(pds.deleted) != 1 || !strings.HasPrefix(pds.deleted[0], "app.atchess.resignation/") {
+		t.Errorf("Expected the resignation record to be deleted, got %v", pds.deleted)
+	}
+}
+
+func TestDrawResponseConflictsWithEarlierResponse(t *testing.T) {
+	pds := newSwapPDS(t)
+	pds.interfere = interfereOnce(func(value map[string]interface{}) {
+		value["status"] = "expired"
+	})
+	client := newFakeClient(t, pds)
+
+	if err := client.RespondToDrawOffer(context.Background(), swapOfferURI, true); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+	if pds.record(swapGameURI)["status"] != "active" {
+		t.Error("Expected the game to continue")
+	}
+}
+
+func TestAcceptedDrawReappliesAfterUnrelatedWrite(t *testing.T) {
+	pds := newSwapPDS(t)
+	pds.interfere = func(pds *fakePDS, uri string) {
+		if uri == swapGameURI && pds.records[uri]["updatedAt"] == nil {
+			pds.change(uri, func(value map[string]interface{}) { value["updatedAt"] = "2024-01-01T00:00:00Z" })
+		}
+	}
+	client := newFakeClient(t, pds)
+
+	if err := client.RespondToDrawOffer(context.Background(), swapOfferURI, true); err != nil {
+		t.Fatalf("Expected the draw to be recorded, got %v", err)
+	}
+	if pds.record(swapOfferURI)["status"] != "accepted" || pds.record(swapGameURI)["status"] != "draw" {
+		t.Errorf("Expected an accepted offer and a drawn game, got %v and %v", pds.record(swapOfferURI), pds.record(swapGameURI))
+	}
+}
+
+func TestSwapRetriesAreBounded(t *testing.T) {
+	pds := newSwapPDS(t)
+	pds.interfere = func(pds *fakePDS, uri string) {
+		pds.change(uri, func(value map[string]interface{}) {
+			value["updatedAt"] = fmt.Sprint(pds.calls["com.atproto.repo.putRecord"])
+		})
+	}
+	client := newFakeClient(t, pds)
+
+	move := &chess.MoveResult{From: "e2", To: "e4", SAN: "e4", FEN: afterE4}
+	err := client.RecordMove(context.Background(), swapGameURI, move)
+	if err == nil || errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected a contention error distinct from ErrConflict, got %v", err)
+	}
+	if puts := pds.requests("com.atproto.repo.putRecord"); puts != maxSwapAttempts {
+		t.Errorf("Expected %d attempts, got %d", maxSwapAttempts, puts)
+	}
+}
@@ -0,0 +1,94 @@
+package atproto
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SetDrawOfferTimeout sets how long a draw offer stays open without an
+// answer. Zero, the default, keeps offers open until the offering player's
+// next move.
+func (c *Client) SetDrawOfferTimeout(timeout time.Duration) {
+	c.drawOfferTimeout = max(timeout, 0)
+}
+
+// DrawOfferTimeout returns how long a draw offer stays open without an answer
+func (c *Client) DrawOfferTimeout() time.Duration {
+	return c.drawOfferTimeout
+}
+
+// staleDrawOffer returns the status a pending offer should move to, given the
+// game it was made in, or "" while it's still open. An offer lapses when the
+// offering player makes their next move, which withdraws it, or when it
+// expires unanswered.
+func staleDrawOffer(offer *DrawOffer, gameValue map[string]interface{}, now time.Time) string {
+	if offer.MoveNumber > 0 {
+		fen, _ := gameValue["fen"].(string)
+		white, _ := gameValue["white"].(string)
+		black, _ := gameValue["black"].(string)
+		if next := offerersNextPly(offer.MoveNumber, offer.OfferedBy, white, black); next > 0 && plyFromFEN(fen) >= next {
+			return "withdrawn"
+		}
+	}
+	if offer.ExpiresAt != "" {
+		if expiresAt, err := time.Parse(time.RFC3339, offer.ExpiresAt); err == nil && !now.Before(expiresAt) {
+			return "expired"
+		}
+	}
+	return ""
+}
+
+// offerersNextPly returns the ply of the first move the offering player makes
+// from moveNumber on, or 0 if they aren't a player. White makes the odd plies.
+func offerersNextPly(moveNumber int, offeredBy, white, black string) int {
+	switch offeredBy {
+	case white:
+		if moveNumber%2 == 1 {
+			return moveNumber
+		}
+		return moveNumber + 1
+	case black:
+		if moveNumber%2 == 0 {
+			return moveNumber
+		}
+		return moveNumber + 1
+	}
+	return 0
+}
+
+// WithdrawDrawOffers withdraws the user's pending draw offers in a game once
+// they've moved again, as making a move lets an offer lapse. It returns how
+// many offers were withdrawn.
+func (c *Client) WithdrawDrawOffers(ctx context.Context, gameURI string) (int, error) {
+	all, err := c.listDrawOffers(ctx, c.did)
+	if err != nil {
+		return 0, err
+	}
+	_, gameValue, err := c.getGameRecord(ctx, gameURI)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get game record: %w", err)
+	}
+
+	now := time.Now()
+	updates := make(map[string]map[string]interface{})
+	for _, offer := range all {
+		if offer.GameURI != gameURI || offer.Status != "pending" || staleDrawOffer(offer, gameValue, now) != "withdrawn" {
+			continue
+		}
+		_, value, err := c.getRecord(ctx, "app.atchess.drawOffer", offer.URI)
+		if err != nil {
+			continue // Picked up by the next sweep
+		}
+		value["status"] = "withdrawn"
+		value["withdrawnAt"] = now.Format(time.RFC3339)
+		updates[offer.URI[strings.LastIndex(offer.URI, "/")+1:]] = value
+	}
+
+	failures := c.updateRecordsBatched(ctx, "app.atchess.drawOffer", updates)
+	for _, err := range failures {
+		return len(updates) - len(failures), fmt.Errorf("failed to withdraw %d draw offers: %w", len(failures), err)
+	}
+	return len(updates), nil
+}
@@ -0,0 +1,388 @@
+package atproto
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Account the fake PDS signs in
+const (
+	fakeDID    = "did:plc:test123"
+	fakeHandle = "test.user"
+)
+
+// fakePDS is a PDS for tests. It signs in fakeDID with any password, keeps
+// records in memory with a CID unique to each write, enforces swapCid
+// and lists records newest first a page at a time, as a real PDS does. Tests
+// set up records with put, make requests fail with failNext and breakRepo,
+// and count requests by XRPC method with requests.
+type fakePDS struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	records  map[string]map[string]interface{} // by URI
+	versions map[string]int                    // latest write by URI, for CIDs
+	order    map[string][]string               // URIs by repo and collection, oldest first
+	hosted   map[string]map[string]bool        // other repos here, and which of their collections we may write
+	created  []map[string]interface{}          // records created, in order
+	deleted  []string                          // collection/rkey of records deleted
+	calls    map[string]int                    // requests by XRPC method
+	listings map[string]int                    // listRecords requests by repo, since listed
+	writes   int
+	newRKeys int
+
+	// failures are statuses the next requests other than signing in answer,
+	// in turn, with Retry-After set to retryAfter
+	failures   []int
+	retryAfter string
+	broken     map[string]int // repos every request about answers a status
+
+	// Tokens are numbered, starting with access-1 and refresh-1 at login.
+	// With loginExpired the first access token has already expired; with
+	// refreshExpired refreshing fails.
+	tokens         int
+	loginExpired   bool
+	refreshExpired bool
+
+	// interfere, if set, runs before each putRecord with mu held, to make a
+	// concurrent write
+	interfere func(pds *fakePDS, uri string)
+}
+
+func newFakePDS(tb testing.TB) *fakePDS {
+	tb.Helper()
+	pds := &fakePDS{
+		records:  make(map[string]map[string]interface{}),
+		versions: make(map[string]int),
+		order:    make(map[string][]string),
+		hosted:   make(map[string]map[string]bool),
+		calls:    make(map[string]int),
+		listings: make(map[string]int),
+		broken:   make(map[string]int),
+	}
+	pds.Server = httptest.NewServer(http.HandlerFunc(pds.serve))
+	tb.Cleanup(pds.Close)
+	return pds
+}
+
+// newFakeClient signs in to the fake PDS
+func newFakeClient(tb testing.TB, pds *fakePDS) *Client {
+	tb.Helper()
+	client, err := NewClient(pds.URL, fakeHandle, "password")
+	if err != nil {
+		tb.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+// splitURI returns the repo, collection and record key of an AT URI
+func splitURI(uri string) (repo, collection, rkey string) {
+	parts := strings.SplitN(strings.TrimPrefix(uri, "at://"), "/", 3)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return parts[0], parts[1], parts[2]
+}
+
+// put stores a record as its next version and returns its CID
+func (p *fakePDS) put(uri string, value map[string]interface{}) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.write(uri, value)
+}
+
+// record returns a record's current value
+func (p *fakePDS) record(uri string) map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.records[uri]
+}
+
+// uris returns the URIs of a collection's records, oldest first
+func (p *fakePDS) uris(repo, collection string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.order[repo+"/"+collection]...)
+}
+
+// host makes the fake PDS host another account's repo, in which we may
+// write to the writable collections only
+func (p *fakePDS) host(did string, writable ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hosted[did] = make(map[string]bool)
+	for _, collection := range writable {
+		p.hosted[did][collection] = true
+	}
+}
+
+// failNext makes the next requests other than signing in answer statuses,
+// one each, with Retry-After set to retryAfter unless it's empty
+func (p *fakePDS) failNext(retryAfter string, statuses ...int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures = append(p.failures, statuses...)
+	p.retryAfter = retryAfter
+}
+
+// breakRepo makes every request about repo answer status
+func (p *fakePDS) breakRepo(repo string, status int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.broken[repo] = status
+}
+
+// requests returns how many requests were made of an XRPC method
+func (p *fakePDS) requests(method string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls[method]
+}
+
+// listed returns the listRecords requests by repo since it was last called
+func (p *fakePDS) listed() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	listed := p.listings
+	p.listings = make(map[string]int)
+	return listed
+}
+
+// cid names a record's current version, which no other record shares;
+// callers hold mu
+func (p *fakePDS) cid(uri string) string {
+	return fmt.Sprintf("cid%d", p.versions[uri])
+}
+
+// write stores a record as a new version; callers hold mu
+func (p *fakePDS) write(uri string, value map[string]interface{}) string {
+	if _, ok := p.records[uri]; !ok {
+		repo, collection, _ := splitURI(uri)
+		p.order[repo+"/"+collection] = append(p.order[repo+"/"+collection], uri)
+	}
+	p.records[uri] = value
+	p.writes++
+	p.versions[uri] = p.writes
+	return p.cid(uri)
+}
+
+// remove deletes a record; callers hold mu
+func (p *fakePDS) remove(uri string) {
+	repo, collection, rkey := splitURI(uri)
+	p.deleted = append(p.deleted, collection+"/"+rkey)
+	if _, ok := p.records[uri]; !ok {
+		return
+	}
+	delete(p.records, uri)
+	key := repo + "/" + collection
+	for i, stored := range p.order[key] {
+		if stored == uri {
+			p.order[key] = append(p.order[key][:i:i], p.order[key][i+1:]...)
+			break
+		}
+	}
+}
+
+// change applies a concurrent edit to a copy of a record; callers hold mu
+func (p *fakePDS) change(uri string, edit func(value map[string]interface{})) {
+	value := make(map[string]interface{}, len(p.records[uri]))
+	for k, v := range p.records[uri] {
+		value[k] = v
+	}
+	edit(value)
+	p.write(uri, value)
+}
+
+// hosts reports whether a repo is on this PDS; callers hold mu
+func (p *fakePDS) hosts(repo string) bool {
+	if _, ok := p.hosted[repo]; ok || repo == fakeDID {
+		return true
+	}
+	for key := range p.order {
+		if strings.HasPrefix(key, repo+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writable reports whether we may write to a collection; callers hold mu
+func (p *fakePDS) writable(repo, collection string) bool {
+	return repo == fakeDID || p.hosted[repo][collection]
+}
+
+func (p *fakePDS) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	method := strings.TrimPrefix(r.URL.Path, "/xrpc/")
+	p.calls[method]++
+	auth := r.Header.Get("Authorization")
+
+	switch method {
+	case "com.atproto.server.createSession":
+		p.tokens = 1
+		p.writeSession(w)
+		return
+	case "com.atproto.server.refreshSession":
+		if p.refreshExpired || auth != fmt.Sprintf("Bearer refresh-%d", p.tokens) {
+			p.writeError(w, http.StatusBadRequest, "ExpiredToken", "Token has expired")
+			return
+		}
+		p.tokens++
+		p.writeSession(w)
+		return
+	}
+
+	if len(p.failures) > 0 {
+		status := p.failures[0]
+		p.failures = p.failures[1:]
+		if p.retryAfter != "" {
+			w.Header().Set("Retry-After", p.retryAfter)
+		}
+		p.writeError(w, status, "Unavailable", "try again")
+		return
+	}
+	if p.loginExpired && auth == "Bearer access-1" {
+		p.writeError(w, http.StatusBadRequest, "ExpiredToken", "Token has expired")
+		return
+	}
+
+	var body struct {
+		Repo       string                   `json:"repo"`
+		Collection string                   `json:"collection"`
+		Rkey       string                   `json:"rkey"`
+		Record     map[string]interface{}   `json:"record"`
+		SwapCid    *string                  `json:"swapCid"`
+		Writes     []map[string]interface{} `json:"writes"`
+	}
+	query := r.URL.Query()
+	if r.Method == http.MethodPost {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	} else {
+		body.Repo, body.Collection, body.Rkey = query.Get("repo"), query.Get("collection"), query.Get("rkey")
+	}
+	if status, ok := p.broken[body.Repo]; ok {
+		p.writeError(w, status, "Unavailable", "repo unavailable")
+		return
+	}
+	uri := fmt.Sprintf("at://%s/%s/%s", body.Repo, body.Collection, body.Rkey)
+	if strings.HasPrefix(method, "com.atproto.repo.") && r.Method == http.MethodPost && method != "com.atproto.repo.uploadBlob" {
+		if !p.hosts(body.Repo) {
+			p.writeError(w, http.StatusBadRequest, "RepoNotFound", "Could not find repo: "+body.Repo)
+			return
+		}
+		if method != "com.atproto.repo.applyWrites" && !p.writable(body.Repo, body.Collection) {
+			p.writeError(w, http.StatusForbidden, "AuthRequired", "Cannot write to "+body.Collection)
+			return
+		}
+	}
+
+	switch method {
+	case "com.atproto.repo.describeRepo":
+		if !p.hosts(body.Repo) {
+			p.writeError(w, http.StatusBadRequest, "RepoNotFound", "Could not find repo: "+body.Repo)
+			return
+		}
+		collections := []string{}
+		for key := range p.order {
+			if repo, collection, _ := splitURI(key); repo == body.Repo && len(p.order[key]) > 0 {
+				collections = append(collections, collection)
+			}
+		}
+		sort.Strings(collections)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"did": body.Repo, "collections": collections})
+
+	case "com.atproto.repo.getRecord":
+		value, ok := p.records[uri]
+		if !ok {
+			p.writeError(w, http.StatusBadRequest, "RecordNotFound", "Could not locate record: "+uri)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"uri": uri, "cid": p.cid(uri), "value": value})
+
+	case "com.atproto.repo.listRecords":
+		p.listings[body.Repo]++
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		if limit <= 0 {
+			limit = 50
+		}
+		skip, _ := strconv.Atoi(query.Get("cursor"))
+		all := p.order[body.Repo+"/"+body.Collection]
+		records := []map[string]interface{}{}
+		for i := len(all) - 1 - skip; i >= 0 && len(records) < limit; i-- {
+			records = append(records, map[string]interface{}{"uri": all[i], "cid": p.cid(all[i]), "value": p.records[all[i]]})
+		}
+		resp := map[string]interface{}{"records": records}
+		if next := skip + len(records); next < len(all) {
+			resp["cursor"] = strconv.Itoa(next)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+
+	case "com.atproto.repo.createRecord":
+		if body.Rkey == "" {
+			p.newRKeys++
+			body.Rkey = fmt.Sprintf("new%d", p.newRKeys)
+			uri = fmt.Sprintf("at://%s/%s/%s", body.Repo, body.Collection, body.Rkey)
+		}
+		p.created = append(p.created, body.Record)
+		_ = json.NewEncoder(w).Encode(map[string]string{"uri": uri, "cid": p.write(uri, body.Record)})
+
+	case "com.atproto.repo.putRecord":
+		if p.interfere != nil {
+			p.interfere(p, uri)
+		}
+		if body.SwapCid != nil && *body.SwapCid != p.cid(uri) {
+			p.writeError(w, http.StatusBadRequest, "InvalidSwap", "Record was at "+p.cid(uri))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"uri": uri, "cid": p.write(uri, body.Record)})
+
+	case "com.atproto.repo.deleteRecord":
+		p.remove(uri)
+		_, _ = w.Write([]byte("{}"))
+
+	case "com.atproto.repo.applyWrites":
+		results := []map[string]string{}
+		for _, write := range body.Writes {
+			collection, _ := write["collection"].(string)
+			rkey, _ := write["rkey"].(string)
+			uri := fmt.Sprintf("at://%s/%s/%s", body.Repo, collection, rkey)
+			switch write["$type"] {
+			case "com.atproto.repo.applyWrites#delete":
+				p.remove(uri)
+				results = append(results, map[string]string{})
+			default:
+				value, _ := write["value"].(map[string]interface{})
+				results = append(results, map[string]string{"uri": uri, "cid": p.write(uri, value)})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+
+	default:
+		p.writeError(w, http.StatusNotImplemented, "MethodNotImplemented", "Method not implemented: "+method)
+	}
+}
+
+// writeSession answers a login or refresh with the current tokens
+func (p *fakePDS) writeSession(w http.ResponseWriter) {
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"accessJwt":  fmt.Sprintf("access-%d", p.tokens),
+		"refreshJwt": fmt.Sprintf("refresh-%d", p.tokens),
+		"did":        fakeDID,
+		"handle":     fakeHandle,
+	})
+}
+
+func (p *fakePDS) writeError(w http.ResponseWriter, status int, code, message string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code, "message": message})
+}
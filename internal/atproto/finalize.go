@@ -0,0 +1,108 @@
+package atproto
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// halfmoveClock returns the number of half-moves since the last capture or
+// pawn move, from a position's FEN
+func halfmoveClock(fen string) int {
+	fields := strings.Fields(fen)
+	if len(fields) < 5 {
+		return 0
+	}
+	clock, _ := strconv.Atoi(fields[4])
+	return clock
+}
+
+// setFinalStatus marks a game record as ended, with its PGN result
+func setFinalStatus(value map[string]interface{}, status chess.GameStatus) {
+	value["status"] = string(status)
+	value["result"] = chess.ResultForStatus(status)
+}
+
+// finalizeMove settles the status a move leaves its game in. Replaying the
+// move from its position already catches checkmate, stalemate, insufficient
+// material and the seventy-five-move rule; fivefold repetition needs the
+// game's history, which is only loaded once enough reversible moves have
+// been played for a position to have come up five times.
+func (c *Client) finalizeMove(ctx context.Context, gameURI, variant, prevFEN string, move *chess.MoveResult) error {
+	if move.GameOver || halfmoveClock(move.FEN) < chess.FivefoldPlies {
+		return nil
+	}
+
+	history, err := c.GetMoves(ctx, gameURI)
+	if err != nil {
+		return fmt.Errorf("failed to load moves: %w", err)
+	}
+	// The move is already in the history when the opponent recorded it
+	if n := len(history); n > 0 && history[n-1].FEN == move.FEN {
+		history = history[:n-1]
+	}
+	// A history that doesn't lead to the move, e.g. while the opponent's
+	// move is still being recorded, can't tell us any more than the position
+	if n := len(history); n == 0 || history[n-1].FEN != prevFEN {
+		return nil
+	}
+	return chess.FinalizeMove(variant, history, move)
+}
+
+// FinalizeGame catches a game record we own up with moves that ended the
+// game. The opponent's moves are recorded in their own repository, so when
+// one of them mates or draws, our record is left active until we look. It
+// returns the game as it stands afterwards.
+func (c *Client) FinalizeGame(ctx context.Context, gameURI string) (*chess.Game, error) {
+	game, err := c.GetGame(ctx, gameURI)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(gameURI, "/")
+	if len(parts) < 5 || parts[2] != c.did || game.Status != chess.StatusActive {
+		return game, nil
+	}
+
+	moves, err := c.getMovesForGame(ctx, game)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load moves: %w", err)
+	}
+	if len(moves) == 0 || moves[len(moves)-1].FEN == game.FEN {
+		// Our own moves are finalized as they're recorded
+		return game, nil
+	}
+	engine, err := chess.ReplayGame(game.Variant, moves)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay game: %w", err)
+	}
+	status := engine.GetStatus()
+	if status == chess.StatusActive {
+		return game, nil
+	}
+
+	gameCID, gameValue, err := c.getGameRecord(ctx, gameURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game record: %w", err)
+	}
+	err = c.swapRecord(ctx, "app.atchess.game", gameURI, gameCID, gameValue, func(value map[string]interface{}) error {
+		if status, _ := value["status"].(string); status != "" && status != "active" {
+			// Ended some other way meanwhile, e.g. by resignation
+			return errUnchanged
+		}
+		if fen, _ := value["fen"].(string); fen != game.FEN {
+			return fmt.Errorf("%w: the position changed before the game was finalized", ErrConflict)
+		}
+		value["fen"] = engine.GetFEN()
+		setFinalStatus(value, status)
+		value["updatedAt"] = time.Now().Format(time.RFC3339)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.GetGame(ctx, gameURI)
+}
@@ -0,0 +1,27 @@
+package atproto
+
+import (
+	"context"
+
+	"github.com/justinabrahms/atchess/internal/identity"
+)
+
+// ErrHandleNotFound is returned for handles the PDS couldn't resolve
+var ErrHandleNotFound = identity.ErrHandleNotFound
+
+// SetResolver replaces the client's identity resolver, e.g. to share one,
+// and its cache, between the clients of every signed-in player
+func (c *Client) SetResolver(resolver *identity.Resolver) {
+	c.resolver = resolver
+}
+
+// Resolver returns the client's identity resolver
+func (c *Client) Resolver() *identity.Resolver {
+	return c.resolver
+}
+
+// ResolveHandle resolves a handle to a DID, returning DIDs unchanged. A
+// handle the PDS can't resolve returns ErrHandleNotFound.
+func (c *Client) ResolveHandle(ctx context.Context, handle string) (string, error) {
+	return c.resolver.ResolveHandle(ctx, handle)
+}
@@ -0,0 +1,184 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// GameImport is a game played on another site to record, with its moves as
+// replayed from the starting position
+type GameImport struct {
+	Game  *chess.Game
+	Moves []*chess.MoveResult
+}
+
+// ImportGame records a game played on another site in the current account's
+// repository: the game, marked imported, and every move by both sides. game
+// gives the players, result, time control, start time and source. If the
+// moves can't be written the game record is deleted again.
+func (c *Client) ImportGame(ctx context.Context, game *chess.Game, moves []*chess.MoveResult) (*chess.Game, error) {
+	imported, err := c.ImportGames(ctx, []GameImport{{Game: game, Moves: moves}})
+	if err != nil {
+		return nil, err
+	}
+	return imported[0], nil
+}
+
+// ImportGames records many imported games as ImportGame does, writing the
+// games and then their moves in applyWrites batches. It records all of the
+// games or, deleting whatever it wrote, none of them.
+func (c *Client) ImportGames(ctx context.Context, imports []GameImport) ([]*chess.Game, error) {
+	gameWrites := make([]map[string]interface{}, 0, len(imports))
+	for _, imp := range imports {
+		game := imp.Game
+		if game.Source == nil {
+			return nil, fmt.Errorf("imported game has no source")
+		}
+		if game.White != c.did && game.Black != c.did {
+			return nil, fmt.Errorf("imported game must be one of the current user's")
+		}
+
+		gameRecord := map[string]interface{}{
+			"$type":     "app.atchess.game",
+			"createdAt": game.CreatedAt,
+			"white":     game.White,
+			"black":     game.Black,
+			"status":    string(game.Status),
+			"fen":       finalFEN(imp.Moves),
+			"pgn":       game.PGN,
+			"result":    chess.ResultForStatus(game.Status),
+			"imported":  true,
+			"source":    game.Source,
+		}
+		if game.TimeControl != nil {
+			gameRecord["timeControl"] = timeControlRecord(game.TimeControl)
+		}
+		gameWrites = append(gameWrites, map[string]interface{}{
+			"$type":      "com.atproto.repo.applyWrites#create",
+			"collection": "app.atchess.game",
+			"value":      gameRecord,
+		})
+	}
+
+	// Moves reference their game by CID, so games are written first
+	var written []string // URIs, for cleaning up after a failure
+	cleanup := func(err error) error {
+		byCollection := map[string][]string{}
+		for _, uri := range written {
+			parts := strings.Split(uri, "/")
+			byCollection[parts[3]] = append(byCollection[parts[3]], parts[4])
+		}
+		var cleanupErr error
+		for _, collection := range []string{"app.atchess.move", "app.atchess.game"} {
+			for _, failure := range c.deleteRecordsBatched(ctx, collection, byCollection[collection]) {
+				cleanupErr = failure
+			}
+		}
+		if cleanupErr != nil {
+			return fmt.Errorf("%w (and failed to delete what was written: %v)", err, cleanupErr)
+		}
+		return err
+	}
+	var games []applyWritesResult
+	for start := 0; start < len(gameWrites); start += applyWritesBatchSize {
+		results, err := c.applyWritesResults(ctx, gameWrites[start:min(start+applyWritesBatchSize, len(gameWrites))])
+		if err == nil && len(results) != min(applyWritesBatchSize, len(gameWrites)-start) {
+			err = fmt.Errorf("expected a result for every game, got %d", len(results))
+		}
+		for _, result := range results {
+			written = append(written, result.URI)
+		}
+		if err != nil {
+			return nil, cleanup(fmt.Errorf("failed to create game records: %w", err))
+		}
+		games = append(games, results...)
+	}
+
+	var moveWrites []map[string]interface{}
+	for i, imp := range imports {
+		prevFEN := chess.StartingFEN
+		for ply, move := range imp.Moves {
+			player := imp.Game.White
+			if ply%2 == 1 {
+				player = imp.Game.Black
+			}
+			moveRecord := map[string]interface{}{
+				"$type":      "app.atchess.move",
+				"createdAt":  imp.Game.CreatedAt,
+				"game":       map[string]interface{}{"uri": games[i].URI, "cid": games[i].CID},
+				"player":     player,
+				"from":       move.From,
+				"to":         move.To,
+				"san":        move.SAN,
+				"fen":        move.FEN,
+				"prevFen":    prevFEN,
+				"moveNumber": ply + 1,
+			}
+			if move.Promotion != "" {
+				moveRecord["promotion"] = move.Promotion
+			}
+			if move.Check {
+				moveRecord["check"] = true
+			}
+			if move.Checkmate {
+				moveRecord["checkmate"] = true
+			}
+			moveWrites = append(moveWrites, map[string]interface{}{
+				"$type":      "com.atproto.repo.applyWrites#create",
+				"collection": "app.atchess.move",
+				"value":      moveRecord,
+			})
+			prevFEN = move.FEN
+		}
+	}
+	for start := 0; start < len(moveWrites); start += applyWritesBatchSize {
+		results, err := c.applyWritesResults(ctx, moveWrites[start:min(start+applyWritesBatchSize, len(moveWrites))])
+		for _, result := range results {
+			written = append(written, result.URI)
+		}
+		if err != nil {
+			return nil, cleanup(fmt.Errorf("failed to record moves: %w", err))
+		}
+	}
+
+	imported := make([]*chess.Game, len(imports))
+	for i, imp := range imports {
+		game := *imp.Game
+		game.ID = games[i].URI
+		game.FEN = finalFEN(imp.Moves)
+		game.Imported = true
+		imported[i] = &game
+	}
+	return imported, nil
+}
+
+// finalFEN returns the position after moves played from the starting position
+func finalFEN(moves []*chess.MoveResult) string {
+	if len(moves) == 0 {
+		return chess.StartingFEN
+	}
+	return moves[len(moves)-1].FEN
+}
+
+// ImportedGames returns the games a player has imported, keyed by their
+// source site and ID, e.g. "lichess:abcd1234", so imports can skip games
+// brought in before
+func (c *Client) ImportedGames(ctx context.Context, did string) (map[string]string, error) {
+	imported := make(map[string]string)
+	err := c.listAllRecords(ctx, did, "app.atchess.game", func(uri, cid string, value json.RawMessage) error {
+		var record gameRecordValue
+		if err := json.Unmarshal(value, &record); err != nil || !record.Imported || record.Source == nil {
+			return nil
+		}
+		imported[record.Source.Site+":"+record.Source.ID] = uri
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return imported, nil
+}
@@ -0,0 +1,119 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+// SetMoveIndex lets the client look up games' latest moves in the game index
+// rather than listing players' repositories. Clients share the index with the
+// service's client; nil turns the lookups off.
+func (c *Client) SetMoveIndex(indexer *index.Indexer) {
+	c.moveIndex = indexer
+}
+
+// MoveIndex returns the game index the client looks up moves in, or nil
+func (c *Client) MoveIndex() *index.Indexer {
+	return c.moveIndex
+}
+
+// lastMove is the most recent move in a game by one of its players
+type lastMove struct {
+	CreatedAt string
+	Player    string
+}
+
+// getLastMove returns the most recent move in a game not made by
+// excludePlayerDID, or nil if there's none, once the game has reached fen.
+// The move is found in the game index if the index has caught up with fen.
+// Otherwise the repository of the player who made it is read newest first,
+// stopping at the move that reached fen rather than listing every player's
+// whole repository.
+func (c *Client) getLastMove(ctx context.Context, gameURI string, gameValue map[string]interface{}, fen, excludePlayerDID string) (*lastMove, error) {
+	whiteDID, _ := gameValue["white"].(string)
+	blackDID, _ := gameValue["black"].(string)
+	moverOf := func(ply int) string {
+		if ply%2 == 1 {
+			return whiteDID
+		}
+		return blackDID
+	}
+
+	target := plyFromFEN(fen)
+	if moverOf(target) == excludePlayerDID {
+		target--
+	}
+	mover := moverOf(target)
+	if target <= 0 || mover == "" || mover == excludePlayerDID {
+		return nil, nil
+	}
+
+	if move := c.indexedLastMove(ctx, gameURI, excludePlayerDID, target); move != nil {
+		return move, nil
+	}
+
+	// Records list newest first, so any move after the target, made once the
+	// game record fell behind, comes before it
+	var latest *gameMoveRecord
+	_, _, _, _, err := c.findRecord(ctx, mover, "app.atchess.move", func(uri string, value json.RawMessage) bool {
+		var move gameMoveRecord
+		if err := json.Unmarshal(value, &move); err != nil || move.Game.URI != gameURI || move.Player == excludePlayerDID {
+			return false
+		}
+		if latest == nil || move.ply() > latest.ply() {
+			latest = &move
+		}
+		return move.ply() == target
+	})
+	if err == nil && latest != nil {
+		return &lastMove{CreatedAt: latest.CreatedAt, Player: latest.Player}, nil
+	}
+
+	// Records without move numbers, or a move kept somewhere unexpected, take
+	// a full search of both players' repositories
+	players := []string{whiteDID}
+	if blackDID != whiteDID {
+		players = append(players, blackDID)
+	}
+	return c.scanLastMove(ctx, gameURI, players, excludePlayerDID)
+}
+
+// indexedLastMove returns the latest indexed move of a game not made by
+// excludePlayerDID, if the index has it up to ply target
+func (c *Client) indexedLastMove(ctx context.Context, gameURI, excludePlayerDID string, target int) *lastMove {
+	if c.moveIndex == nil {
+		return nil
+	}
+	moves, err := c.moveIndex.ListMoves(ctx, gameURI)
+	if err != nil {
+		return nil
+	}
+	for i := len(moves) - 1; i >= 0; i-- {
+		if moves[i].Player == excludePlayerDID {
+			continue
+		}
+		if plyFromFEN(moves[i].FEN) < target {
+			return nil
+		}
+		return &lastMove{CreatedAt: moves[i].CreatedAt.UTC().Format(time.RFC3339), Player: moves[i].Player}
+	}
+	return nil
+}
+
+// scanLastMove finds the latest move of a game not made by excludePlayerDID
+// by reading every move in the players' repositories
+func (c *Client) scanLastMove(ctx context.Context, gameURI string, players []string, excludePlayerDID string) (*lastMove, error) {
+	moves, err := c.listGameMoveRecords(ctx, gameURI, players)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(moves) - 1; i >= 0; i-- {
+		if moves[i].Player != excludePlayerDID {
+			return &lastMove{CreatedAt: moves[i].CreatedAt, Player: moves[i].Player}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,171 @@
+package atproto
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+const (
+	lastMoveGameURI = "at://did:plc:test123/app.atchess.game/g0"
+	lastMoveWhite   = "did:plc:test123"
+	lastMoveBlack   = "did:plc:opponent"
+)
+
+// newBusyPDS holds the move collections of two players who have played
+// many games. Game g0 has reached plies moves; each other game has moves of
+// its own in between.
+func newBusyPDS(tb testing.TB, games, plies int) *fakePDS {
+	tb.Helper()
+	pds := newFakePDS(tb)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for ply := 1; ply <= plies; ply++ {
+		for game := 0; game < games; game++ {
+			player := lastMoveWhite
+			if ply%2 == 0 {
+				player = lastMoveBlack
+			}
+			pds.put(fmt.Sprintf("at://%s/app.atchess.move/m%d-%d", player, game, ply), map[string]interface{}{
+				"createdAt":  start.Add(time.Duration(ply*games+game) * time.Minute).Format(time.RFC3339),
+				"game":       map[string]interface{}{"uri": fmt.Sprintf("at://%s/app.atchess.game/g%d", lastMoveWhite, game)},
+				"player":     player,
+				"fen":        positionAfter(ply),
+				"moveNumber": ply,
+			})
+		}
+	}
+	return pds
+}
+
+// indexMoves indexes every move record the PDS holds
+func indexMoves(tb testing.TB, pds *fakePDS, indexer *index.Indexer) {
+	tb.Helper()
+	for _, repo := range []string{lastMoveWhite, lastMoveBlack} {
+		for _, uri := range pds.uris(repo, "app.atchess.move") {
+			_, collection, rkey := splitURI(uri)
+			if err := indexer.Apply(context.Background(), "create", repo, collection+"/"+rkey, pds.record(uri)); err != nil {
+				tb.Fatalf("Failed to index %s: %v", uri, err)
+			}
+		}
+	}
+}
+
+// positionAfter returns a FEN whose move counters say plies have been played
+func positionAfter(plies int) string {
+	if plies%2 == 1 {
+		return fmt.Sprintf("8/8/8/8/8/8/8/K6k b - - 0 %d", (plies+1)/2)
+	}
+	return fmt.Sprintf("8/8/8/8/8/8/8/K6k w - - 0 %d", plies/2+1)
+}
+
+func lastMoveGame() map[string]interface{} {
+	return map[string]interface{}{"white": lastMoveWhite, "black": lastMoveBlack}
+}
+
+func TestLastMoveReadsOnlyTheMoversRecentMoves(t *testing.T) {
+	pds := newBusyPDS(t, 50, 10)
+	client := newFakeClient(t, pds)
+	client.SetListPageSize(20)
+	ctx := context.Background()
+
+	// Black made the 10th move; white is to move
+	last, err := client.getLastMove(ctx, lastMoveGameURI, lastMoveGame(), positionAfter(10), lastMoveWhite)
+	if err != nil || last == nil || last.Player != lastMoveBlack || last.CreatedAt != "2024-01-01T08:20:00Z" {
+		t.Fatalf("Expected black's 10th move, got %+v (%v)", last, err)
+	}
+	if listed := pds.listed(); listed[lastMoveBlack] != 3 || listed[lastMoveWhite] != 0 {
+		t.Errorf("Expected only black's latest moves read, got %v", listed)
+	}
+
+	// Nothing has been played by anyone but the player to move
+	if last, err := client.getLastMove(ctx, lastMoveGameURI, lastMoveGame(), positionAfter(0), lastMoveWhite); err != nil || last != nil {
+		t.Errorf("Expected no last move at the start, got %+v (%v)", last, err)
+	}
+}
+
+func TestLastMoveFromTheIndex(t *testing.T) {
+	pds := newBusyPDS(t, 50, 10)
+	client := newFakeClient(t, pds)
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	indexMoves(t, pds, indexer)
+	client.SetMoveIndex(indexer)
+	ctx := context.Background()
+
+	last, err := client.getLastMove(ctx, lastMoveGameURI, lastMoveGame(), positionAfter(10), lastMoveWhite)
+	if err != nil || last == nil || last.CreatedAt != "2024-01-01T08:20:00Z" {
+		t.Fatalf("Expected black's 10th move, got %+v (%v)", last, err)
+	}
+	if listed := pds.listed(); len(listed) != 0 {
+		t.Errorf("Expected no repository read, got %v", listed)
+	}
+
+	// A move the index hasn't seen yet is looked for in the repository
+	pds.put("at://"+lastMoveWhite+"/app.atchess.move/m0-11", map[string]interface{}{
+		"createdAt":  "2024-02-01T00:00:00Z",
+		"game":       map[string]interface{}{"uri": lastMoveGameURI},
+		"player":     lastMoveWhite,
+		"fen":        positionAfter(11),
+		"moveNumber": 11,
+	})
+	last, err = client.getLastMove(ctx, lastMoveGameURI, lastMoveGame(), positionAfter(11), lastMoveBlack)
+	if err != nil || last == nil || last.CreatedAt != "2024-02-01T00:00:00Z" {
+		t.Fatalf("Expected white's 11th move, got %+v (%v)", last, err)
+	}
+	if listed := pds.listed(); listed[lastMoveWhite] != 1 {
+		t.Errorf("Expected white's repository read once, got %v", listed)
+	}
+}
+
+// benchmarkLastMove looks up the last move of a game between players who
+// have each recorded 1,000 moves across 100 games
+func benchmarkLastMove(b *testing.B, lookup func(client *Client, ctx context.Context) (*lastMove, error), indexed bool) {
+	pds := newBusyPDS(b, 100, 20)
+	client := newFakeClient(b, pds)
+	if indexed {
+		indexer := index.NewIndexer(index.NewMemoryStore())
+		indexMoves(b, pds, indexer)
+		client.SetMoveIndex(indexer)
+	}
+	ctx := context.Background()
+	pds.listed()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if last, err := lookup(client, ctx); err != nil || last == nil {
+			b.Fatalf("Expected the last move, got %+v (%v)", last, err)
+		}
+	}
+	b.StopTimer()
+
+	requests := 0
+	for _, n := range pds.listed() {
+		requests += n
+	}
+	b.ReportMetric(float64(requests)/float64(b.N), "requests/op")
+}
+
+// BenchmarkLastMoveScan reads every move in both players' repositories, as
+// time checks once did
+func BenchmarkLastMoveScan(b *testing.B) {
+	benchmarkLastMove(b, func(client *Client, ctx context.Context) (*lastMove, error) {
+		return client.scanLastMove(ctx, lastMoveGameURI, []string{lastMoveWhite, lastMoveBlack}, lastMoveWhite)
+	}, false)
+}
+
+// BenchmarkLastMoveRepository reads the mover's repository newest first
+func BenchmarkLastMoveRepository(b *testing.B) {
+	benchmarkLastMove(b, func(client *Client, ctx context.Context) (*lastMove, error) {
+		return client.getLastMove(ctx, lastMoveGameURI, lastMoveGame(), positionAfter(20), lastMoveWhite)
+	}, false)
+}
+
+// BenchmarkLastMoveIndex finds the move in the game index
+func BenchmarkLastMoveIndex(b *testing.B) {
+	benchmarkLastMove(b, func(client *Client, ctx context.Context) (*lastMove, error) {
+		return client.getLastMove(ctx, lastMoveGameURI, lastMoveGame(), positionAfter(20), lastMoveWhite)
+	}, true)
+}
@@ -0,0 +1,124 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// ErrDuplicateMove is returned when a move is submitted for a ply of a game
+// that the player has already recorded a move for, e.g. a retried request
+var ErrDuplicateMove = errors.New("move already recorded")
+
+// plyFromFEN returns the number of half-moves played before a position,
+// derived from its full-move number and side to move
+func plyFromFEN(fen string) int {
+	fields := strings.Fields(fen)
+	if len(fields) < 6 {
+		return 0
+	}
+	fullMove, err := strconv.Atoi(fields[5])
+	if err != nil || fullMove < 1 {
+		return 0
+	}
+	if fields[1] == "b" {
+		return 2*fullMove - 1 // White just moved
+	}
+	return 2 * (fullMove - 1) // Black just moved
+}
+
+// checkMoveExtends replays a move under the game's variant from the position
+// it's being recorded against, so a move computed from a position the game
+// has since left can't be stored as if it followed on. It returns the
+// replayed move, whose result is the one to record.
+func checkMoveExtends(variant, prevFEN string, move *chess.MoveResult) (*chess.MoveResult, error) {
+	engine, err := chess.NewVariantEngine(variant, prevFEN)
+	if err != nil {
+		return nil, fmt.Errorf("game can't be played: %w", err)
+	}
+	result, err := engine.MakeMove(move.From, move.To, chess.ParsePromotion(move.Promotion))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s%s can't be played in the current position", ErrConflict, move.From, move.To)
+	}
+	if result.FEN != move.FEN {
+		return nil, fmt.Errorf("%w: %s%s was computed from a different position", ErrConflict, move.From, move.To)
+	}
+	return result, nil
+}
+
+// positionForMove returns the position a move follows on from, along with
+// the replayed move: the game record's position, unless the moves in the
+// players' repositories have taken the game further than the record shows,
+// as happens when the record is in the opponent's repository and they
+// haven't applied our last move yet
+func (c *Client) positionForMove(ctx context.Context, gameURI, variant, recordFEN string, move *chess.MoveResult) (string, *chess.MoveResult, error) {
+	replayed, err := checkMoveExtends(variant, recordFEN, move)
+	if !errors.Is(err, ErrConflict) {
+		return recordFEN, replayed, err
+	}
+
+	history, historyErr := c.GetMoves(ctx, gameURI)
+	if historyErr != nil {
+		return "", nil, fmt.Errorf("failed to load moves: %w", historyErr)
+	}
+	if len(history) == 0 || history[len(history)-1].Ply <= plyFromFEN(recordFEN) {
+		return "", nil, err
+	}
+	latest := history[len(history)-1].FEN
+	replayed, err = checkMoveExtends(variant, latest, move)
+	return latest, replayed, err
+}
+
+// checkDuplicateMove fails with ErrDuplicateMove if our repository already
+// holds a move for ply, or a later one, of the game
+func (c *Client) checkDuplicateMove(ctx context.Context, gameURI string, ply int) error {
+	var recorded int
+	uri, _, _, found, err := c.findRecord(ctx, c.did, "app.atchess.move", func(uri string, value json.RawMessage) bool {
+		var move gameMoveRecord
+		if err := json.Unmarshal(value, &move); err != nil || move.Game.URI != gameURI {
+			return false
+		}
+		recorded = move.ply()
+		return recorded >= ply
+	})
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("%w: ply %d of the game is already recorded as %s", ErrDuplicateMove, recorded, uri)
+	}
+	return nil
+}
+
+// thinkTimeSlack allows for timestamps being recorded to the second when
+// checking a client's think time against them
+const thinkTimeSlack = time.Second
+
+// thinkTime returns how long we spent on a move from prevFEN recorded at
+// now, in milliseconds: the time our client reported, unless it's longer
+// than we've had since the previous move (or the start of the game), in
+// which case that's used instead. It's 0 if neither is known.
+func (c *Client) thinkTime(ctx context.Context, gameURI string, gameValue map[string]interface{}, prevFEN string, reported int64, now time.Time) int64 {
+	turnStarted := time.Time{}
+	if last, err := c.getLastMove(ctx, gameURI, gameValue, prevFEN, c.did); err == nil && last != nil {
+		turnStarted, _ = time.Parse(time.RFC3339, last.CreatedAt)
+	} else if err == nil {
+		createdAt, _ := gameValue["createdAt"].(string)
+		turnStarted, _ = time.Parse(time.RFC3339, createdAt)
+	}
+	if turnStarted.IsZero() {
+		return max(reported, 0)
+	}
+
+	elapsed := max(now.Sub(turnStarted), 0)
+	if reported > 0 && time.Duration(reported)*time.Millisecond <= elapsed+thinkTimeSlack {
+		return reported
+	}
+	return elapsed.Milliseconds()
+}
@@ -0,0 +1,131 @@
+package atproto
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+const (
+	movesGameURI = "at://did:plc:opponent/app.atchess.game/g1"
+	afterE4E5    = "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2"
+	afterNf3     = "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"
+)
+
+// newMovesPDS holds a game in the opponent's repository whose record still
+// shows gameFEN, with moves the move records in each repository, oldest first
+func newMovesPDS(t *testing.T, gameFEN string, moves map[string][]map[string]interface{}) *fakePDS {
+	t.Helper()
+	pds := newFakePDS(t)
+	pds.put(movesGameURI, map[string]interface{}{
+		"white":  "did:plc:test123",
+		"black":  "did:plc:opponent",
+		"status": "active",
+		"fen":    gameFEN,
+	})
+	for repo, values := range moves {
+		for i, value := range values {
+			pds.put("at://"+repo+"/app.atchess.move/m"+string(rune('a'+i)), value)
+		}
+	}
+	return pds
+}
+
+func moveValue(player, from, to, fen string) map[string]interface{} {
+	return map[string]interface{}{
+		"game":      map[string]interface{}{"uri": movesGameURI},
+		"player":    player,
+		"from":      from,
+		"to":        to,
+		"fen":       fen,
+		"createdAt": "2024-01-01T00:00:00Z",
+	}
+}
+
+func TestRecordMoveStoresMoveNumberAndPreviousPosition(t *testing.T) {
+	pds := newMovesPDS(t, chess.StartingFEN, nil)
+	client := newFakeClient(t, pds)
+
+	move := &chess.MoveResult{From: "e2", To: "e4", SAN: "e4", FEN: afterE4}
+	if err := client.RecordMove(context.Background(), movesGameURI, move); err != nil {
+		t.Fatalf("Failed to record move: %v", err)
+	}
+
+	if len(pds.created) != 1 {
+		t.Fatalf("Expected one move record, got %d", len(pds.created))
+	}
+	if pds.created[0]["moveNumber"] != float64(1) || pds.created[0]["prevFen"] != chess.StartingFEN {
+		t.Errorf("Expected move 1 following the starting position, got %v and %v", pds.created[0]["moveNumber"], pds.created[0]["prevFen"])
+	}
+}
+
+func TestRecordMoveFollowsMovesAheadOfGameRecord(t *testing.T) {
+	// The opponent hasn't applied either move to their game record yet
+	pds := newMovesPDS(t, chess.StartingFEN, map[string][]map[string]interface{}{
+		"did:plc:test123":  {moveValue("did:plc:test123", "e2", "e4", afterE4)},
+		"did:plc:opponent": {moveValue("did:plc:opponent", "e7", "e5", afterE4E5)},
+	})
+	client := newFakeClient(t, pds)
+
+	move := &chess.MoveResult{From: "g1", To: "f3", SAN: "Nf3", FEN: afterNf3}
+	if err := client.RecordMove(context.Background(), movesGameURI, move); err != nil {
+		t.Fatalf("Failed to record move: %v", err)
+	}
+	if len(pds.created) != 1 || pds.created[0]["moveNumber"] != float64(3) || pds.created[0]["prevFen"] != afterE4E5 {
+		t.Errorf("Expected move 3 following 1. e4 e5, got %v", pds.created)
+	}
+}
+
+func TestRecordMoveRejectsDuplicate(t *testing.T) {
+	// A retried request for a move that's already in our repository
+	pds := newMovesPDS(t, chess.StartingFEN, map[string][]map[string]interface{}{
+		"did:plc:test123": {moveValue("did:plc:test123", "e2", "e4", afterE4)},
+	})
+	client := newFakeClient(t, pds)
+
+	move := &chess.MoveResult{From: "d2", To: "d4", SAN: "d4", FEN: "rnbqkbnr/pppppppp/8/8/3P4/8/PPP1PPPP/RNBQKBNR b KQkq d3 0 1"}
+	err := client.RecordMove(context.Background(), movesGameURI, move)
+	if !errors.Is(err, ErrDuplicateMove) {
+		t.Fatalf("Expected ErrDuplicateMove, got %v", err)
+	}
+	if len(pds.created) != 0 {
+		t.Errorf("Expected no move record to be created, got %v", pds.created)
+	}
+}
+
+func TestRecordMoveRejectsMoveFromOtherPosition(t *testing.T) {
+	pds := newMovesPDS(t, afterE4, nil)
+	client := newFakeClient(t, pds)
+
+	// Nf3 computed from 1. e4 e5, but e5 was never played
+	move := &chess.MoveResult{From: "g1", To: "f3", SAN: "Nf3", FEN: afterNf3}
+	if err := client.RecordMove(context.Background(), movesGameURI, move); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+	if len(pds.created) != 0 {
+		t.Errorf("Expected no move record to be created, got %v", pds.created)
+	}
+}
+
+func TestGetMovesSkipsMovesFromOtherPositions(t *testing.T) {
+	stray := moveValue("did:plc:opponent", "d7", "d5", "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2")
+	stray["moveNumber"] = 2
+	stray["prevFen"] = "rnbqkbnr/pppppppp/8/8/3P4/8/PPP1PPPP/RNBQKBNR b KQkq d3 0 1"
+	stray["createdAt"] = "2023-12-31T00:00:00Z"
+
+	pds := newMovesPDS(t, afterE4E5, map[string][]map[string]interface{}{
+		"did:plc:test123":  {moveValue("did:plc:test123", "e2", "e4", afterE4)},
+		"did:plc:opponent": {stray, moveValue("did:plc:opponent", "e7", "e5", afterE4E5)},
+	})
+	client := newFakeClient(t, pds)
+
+	moves, err := client.GetMoves(context.Background(), movesGameURI)
+	if err != nil {
+		t.Fatalf("Failed to get moves: %v", err)
+	}
+	if len(moves) != 2 || moves[1].SAN != "e5" {
+		t.Errorf("Expected 1. e4 e5, got %d moves", len(moves))
+	}
+}
@@ -0,0 +1,31 @@
+package atproto
+
+import "time"
+
+// ClockPauses reports how long games' clocks stood still, e.g. while a
+// disconnected player came back, so that time isn't charged to the player
+// to move
+type ClockPauses interface {
+	// PausedSince returns how long a game's clock has been paused since the
+	// given time, counting a pause that hasn't ended yet up to now
+	PausedSince(gameID string, since time.Time) time.Duration
+}
+
+// SetClockPauses sets where move deadlines learn of paused clocks
+func (c *Client) SetClockPauses(pauses ClockPauses) {
+	c.clockPauses = pauses
+}
+
+// ClockPauses returns where move deadlines learn of paused clocks, or nil
+func (c *Client) ClockPauses() ClockPauses {
+	return c.clockPauses
+}
+
+// pausedSince returns how long a game's clock has been paused since a turn
+// started
+func (c *Client) pausedSince(gameID string, since time.Time) time.Duration {
+	if c.clockPauses == nil {
+		return 0
+	}
+	return c.clockPauses.PausedSince(gameID, since)
+}
@@ -0,0 +1,230 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxPostLength is the longest Bluesky post, in characters
+const MaxPostLength = 300
+
+// MaxPostImages is how many images a Bluesky post can embed
+const MaxPostImages = 4
+
+// Post is a Bluesky post to publish from the user's account
+type Post struct {
+	Text string
+	// Link, when it appears in Text, is marked up so it's clickable
+	Link string
+	// Mentions that appear in Text are marked up so they notify the account
+	Mentions []PostMention
+	Images   []PostImage
+}
+
+// PostMention tags an account in a post
+type PostMention struct {
+	Text string // as written in the post, e.g. "@alice.bsky.social"
+	DID  string
+}
+
+// PostImage is an image to upload and embed in a post
+type PostImage struct {
+	Data     []byte
+	MimeType string
+	Alt      string
+	Width    int
+	Height   int
+}
+
+// PostRef identifies a published post
+type PostRef struct {
+	URI string `json:"uri"`
+	CID string `json:"cid"`
+}
+
+// UploadBlob stores data in the user's repository and returns the blob
+// reference to embed in a record
+func (c *Client) UploadBlob(ctx context.Context, data []byte, mimeType string) (map[string]interface{}, error) {
+	resp, err := c.makeTypedRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.uploadBlob", mimeType, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload blob: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to upload blob: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Blob map[string]interface{} `json:"blob"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.Blob == nil {
+		return nil, fmt.Errorf("failed to upload blob: no blob in response")
+	}
+	return result.Blob, nil
+}
+
+// CreatePost publishes an app.bsky.feed.post record. Replies name the root
+// and parent of their thread; top-level posts pass nil for both.
+func (c *Client) CreatePost(ctx context.Context, post Post, root, parent *PostRef) (*PostRef, error) {
+	if utf8.RuneCountInString(post.Text) > MaxPostLength {
+		return nil, fmt.Errorf("post is longer than %d characters", MaxPostLength)
+	}
+	if len(post.Images) > MaxPostImages {
+		return nil, fmt.Errorf("post has more than %d images", MaxPostImages)
+	}
+
+	record := map[string]interface{}{
+		"$type":     "app.bsky.feed.post",
+		"text":      post.Text,
+		"createdAt": time.Now().Format(time.RFC3339),
+		"langs":     []string{"en"},
+	}
+
+	var facets []map[string]interface{}
+	if post.Link != "" {
+		if facet := textFacet(post.Text, post.Link, map[string]interface{}{
+			"$type": "app.bsky.richtext.facet#link",
+			"uri":   post.Link,
+		}); facet != nil {
+			facets = append(facets, facet)
+		}
+	}
+	for _, mention := range post.Mentions {
+		if facet := textFacet(post.Text, mention.Text, map[string]interface{}{
+			"$type": "app.bsky.richtext.facet#mention",
+			"did":   mention.DID,
+		}); facet != nil {
+			facets = append(facets, facet)
+		}
+	}
+	if len(facets) > 0 {
+		record["facets"] = facets
+	}
+
+	if len(post.Images) > 0 {
+		images := make([]map[string]interface{}, 0, len(post.Images))
+		for _, image := range post.Images {
+			blob, err := c.UploadBlob(ctx, image.Data, image.MimeType)
+			if err != nil {
+				return nil, err
+			}
+			embedded := map[string]interface{}{
+				"alt":   image.Alt,
+				"image": blob,
+			}
+			if image.Width > 0 && image.Height > 0 {
+				embedded["aspectRatio"] = map[string]interface{}{
+					"width":  image.Width,
+					"height": image.Height,
+				}
+			}
+			images = append(images, embedded)
+		}
+		record["embed"] = map[string]interface{}{
+			"$type":  "app.bsky.embed.images",
+			"images": images,
+		}
+	}
+
+	if root != nil && parent != nil {
+		record["reply"] = map[string]interface{}{
+			"root":   map[string]interface{}{"uri": root.URI, "cid": root.CID},
+			"parent": map[string]interface{}{"uri": parent.URI, "cid": parent.CID},
+		}
+	}
+
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.bsky.feed.post",
+		"record":     record,
+	})
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to create post: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var ref PostRef
+	if err := json.NewDecoder(resp.Body).Decode(&ref); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &ref, nil
+}
+
+// textFacet marks up the first occurrence of span in text with feature, or
+// returns nil if span doesn't appear. Facet indexes count UTF-8 bytes.
+func textFacet(text, span string, feature map[string]interface{}) map[string]interface{} {
+	start := strings.Index(text, span)
+	if span == "" || start < 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"index": map[string]interface{}{
+			"byteStart": start,
+			"byteEnd":   start + len(span),
+		},
+		"features": []map[string]interface{}{feature},
+	}
+}
+
+// LookupHandle returns an account's handle from its Bluesky profile
+func (c *Client) LookupHandle(ctx context.Context, did string) (string, error) {
+	url := fmt.Sprintf("%s/xrpc/app.bsky.actor.getProfile?actor=%s", c.pdsURL, neturl.QueryEscape(did))
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get profile: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to get profile: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var profile struct {
+		Handle string `json:"handle"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if profile.Handle == "" {
+		return "", fmt.Errorf("failed to get profile: no handle for %s", did)
+	}
+	return profile.Handle, nil
+}
+
+// CreateThread publishes posts as a thread, each replying to the one before.
+// If a post fails, the posts already published are returned with the error.
+func (c *Client) CreateThread(ctx context.Context, posts []Post) ([]PostRef, error) {
+	refs := make([]PostRef, 0, len(posts))
+	var root, parent *PostRef
+	for i, post := range posts {
+		ref, err := c.CreatePost(ctx, post, root, parent)
+		if err != nil {
+			return refs, fmt.Errorf("post %d of %d: %w", i+1, len(posts), err)
+		}
+		refs = append(refs, *ref)
+		if root == nil {
+			root = ref
+		}
+		parent = ref
+	}
+	return refs, nil
+}
@@ -0,0 +1,120 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// ErrNoPreferences is returned for accounts without an app.atchess.preferences record
+var ErrNoPreferences = errors.New("no preferences record")
+
+// PreferencesRecord is the app.atchess.preferences record holding a player's
+// settings in their own repository
+type PreferencesRecord struct {
+	Timezone           string             `json:"timezone,omitempty"`
+	BoardTheme         string             `json:"boardTheme,omitempty"`
+	PieceSet           string             `json:"pieceSet,omitempty"`
+	AutoQueen          bool               `json:"autoQueen,omitempty"`
+	DefaultTimeControl *chess.TimeControl `json:"defaultTimeControl,omitempty"`
+	EmailNotifications EmailNotifications `json:"emailNotifications"`
+	UpdatedAt          string             `json:"updatedAt,omitempty"`
+}
+
+// EmailNotifications are the email notifications a player has opted in to
+type EmailNotifications struct {
+	PendingMoves   bool `json:"pendingMoves"`
+	ExpiringClocks bool `json:"expiringClocks"`
+	Challenges     bool `json:"challenges"`
+}
+
+// Any reports whether any email notification is enabled
+func (e EmailNotifications) Any() bool {
+	return e.PendingMoves || e.ExpiringClocks || e.Challenges
+}
+
+// GetPreferences fetches an account's preferences record, returning
+// ErrNoPreferences if it has never saved any
+func (c *Client) GetPreferences(ctx context.Context, did string) (*PreferencesRecord, error) {
+	url := fmt.Sprintf("%s/xrpc/com.atproto.repo.getRecord?repo=%s&collection=app.atchess.preferences&rkey=self", c.pdsURL, did)
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		var xrpcErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &xrpcErr) == nil && xrpcErr.Error == "RecordNotFound" {
+			return nil, ErrNoPreferences
+		}
+		return nil, fmt.Errorf("failed to get preferences record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var getResp struct {
+		Value PreferencesRecord `json:"value"`
+	}
+	if err := json.Unmarshal(body, &getResp); err != nil {
+		return nil, fmt.Errorf("failed to decode preferences record: %w", err)
+	}
+	return &getResp.Value, nil
+}
+
+// PutPreferences writes the current account's preferences record
+func (c *Client) PutPreferences(ctx context.Context, prefs *PreferencesRecord) error {
+	prefs.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	record := map[string]interface{}{
+		"$type": "app.atchess.preferences",
+		"emailNotifications": map[string]interface{}{
+			"pendingMoves":   prefs.EmailNotifications.PendingMoves,
+			"expiringClocks": prefs.EmailNotifications.ExpiringClocks,
+			"challenges":     prefs.EmailNotifications.Challenges,
+		},
+		"updatedAt": prefs.UpdatedAt,
+	}
+	if prefs.Timezone != "" {
+		record["timezone"] = prefs.Timezone
+	}
+	if prefs.BoardTheme != "" {
+		record["boardTheme"] = prefs.BoardTheme
+	}
+	if prefs.PieceSet != "" {
+		record["pieceSet"] = prefs.PieceSet
+	}
+	if prefs.AutoQueen {
+		record["autoQueen"] = true
+	}
+	if prefs.DefaultTimeControl != nil {
+		record["defaultTimeControl"] = timeControlRecord(prefs.DefaultTimeControl)
+	}
+
+	putReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.preferences",
+		"rkey":       "self",
+		"record":     record,
+	}
+
+	reqBody, _ := json.Marshal(putReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to write preferences record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to write preferences record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
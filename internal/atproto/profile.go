@@ -0,0 +1,66 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+)
+
+// ErrNoProfile is returned for accounts without an app.bsky.actor.profile record
+var ErrNoProfile = errors.New("no profile record")
+
+// Profile is an account's app.bsky.actor.profile record, with the avatar
+// blob turned into a URL it can be fetched from
+type Profile struct {
+	DisplayName string `json:"displayName,omitempty"`
+	Description string `json:"description,omitempty"`
+	Avatar      string `json:"avatar,omitempty"`
+}
+
+// GetProfile fetches an account's Bluesky profile record, returning
+// ErrNoProfile if it has none
+func (c *Client) GetProfile(ctx context.Context, did string) (*Profile, error) {
+	url := fmt.Sprintf("%s/xrpc/com.atproto.repo.getRecord?repo=%s&collection=app.bsky.actor.profile&rkey=self", c.pdsURL, neturl.QueryEscape(did))
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		var xrpcErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &xrpcErr) == nil && xrpcErr.Error == "RecordNotFound" {
+			return nil, ErrNoProfile
+		}
+		return nil, fmt.Errorf("failed to get profile record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var getResp struct {
+		Value struct {
+			DisplayName string `json:"displayName"`
+			Description string `json:"description"`
+			Avatar      *struct {
+				Ref struct {
+					Link string `json:"$link"`
+				} `json:"ref"`
+			} `json:"avatar"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(body, &getResp); err != nil {
+		return nil, fmt.Errorf("failed to decode profile record: %w", err)
+	}
+
+	profile := &Profile{DisplayName: getResp.Value.DisplayName, Description: getResp.Value.Description}
+	if avatar := getResp.Value.Avatar; avatar != nil && avatar.Ref.Link != "" {
+		profile.Avatar = fmt.Sprintf("%s/xrpc/com.atproto.sync.getBlob?did=%s&cid=%s",
+			c.pdsURL, neturl.QueryEscape(did), neturl.QueryEscape(avatar.Ref.Link))
+	}
+	return profile, nil
+}
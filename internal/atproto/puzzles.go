@@ -0,0 +1,78 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// PuzzleRecord is an app.atchess.puzzle record: a position from a played game
+// with a forced mate for the side to move
+type PuzzleRecord struct {
+	URI       string   `json:"-"`
+	CreatedAt string   `json:"createdAt"`
+	Game      string   `json:"game"` // URI of the game the position comes from
+	Ply       int      `json:"ply"`
+	FEN       string   `json:"fen"`
+	Solution  []string `json:"solution"` // UCI, alternating with the defender's replies
+	Theme     string   `json:"theme"`
+}
+
+// CreatePuzzle writes a puzzle record to the current account's repository
+// and returns its URI
+func (c *Client) CreatePuzzle(ctx context.Context, puzzle *PuzzleRecord) (string, error) {
+	createReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.puzzle",
+		"record": map[string]interface{}{
+			"$type":     "app.atchess.puzzle",
+			"createdAt": puzzle.CreatedAt,
+			"game":      puzzle.Game,
+			"ply":       puzzle.Ply,
+			"fen":       puzzle.FEN,
+			"solution":  puzzle.Solution,
+			"theme":     puzzle.Theme,
+		},
+	}
+
+	reqBody, _ := json.Marshal(createReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create puzzle record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to create puzzle record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var createResp struct {
+		URI string `json:"uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&createResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	puzzle.URI = createResp.URI
+	return createResp.URI, nil
+}
+
+// ListPuzzles returns the puzzle records in the current account's repository
+func (c *Client) ListPuzzles(ctx context.Context) ([]*PuzzleRecord, error) {
+	var puzzles []*PuzzleRecord
+	err := c.listAllRecords(ctx, c.did, "app.atchess.puzzle", func(uri, cid string, value json.RawMessage) error {
+		var puzzle PuzzleRecord
+		if err := json.Unmarshal(value, &puzzle); err != nil {
+			return nil // Skip malformed records
+		}
+		puzzle.URI = uri
+		puzzles = append(puzzles, &puzzle)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return puzzles, nil
+}
@@ -0,0 +1,158 @@
+package atproto
+
+import (
+	"container/list"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordCache remembers getRecord responses by AT URI, up to a number of
+// records for a time each, with the least recently used forgotten first.
+// Writes through any client sharing the cache forget the records they touch,
+// and Observe forgets a record once a newer version of it is seen elsewhere,
+// such as on the firehose. A nil *RecordCache caches nothing.
+type RecordCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used at the front
+}
+
+type recordCacheEntry struct {
+	uri     string
+	cid     string
+	value   json.RawMessage
+	expires time.Time
+}
+
+// NewRecordCache creates a cache of up to size records, each kept for ttl
+func NewRecordCache(size int, ttl time.Duration) *RecordCache {
+	return &RecordCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns a cached record; ok is false if there's none or it has expired
+func (c *RecordCache) get(uri string) (cid string, value json.RawMessage, ok bool) {
+	if c == nil {
+		return "", nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[uri]
+	if !ok {
+		return "", nil, false
+	}
+	entry := element.Value.(*recordCacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(element)
+		return "", nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.cid, entry.value, true
+}
+
+// put caches a record as of cid
+func (c *RecordCache) put(uri, cid string, value json.RawMessage) {
+	if c == nil || c.size <= 0 || cid == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &recordCacheEntry{uri: uri, cid: cid, value: value, expires: c.now().Add(c.ttl)}
+	if element, ok := c.entries[uri]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[uri] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Observe notes that the record at uri is now at cid, forgetting any other
+// version of it. An empty cid means the record was deleted.
+func (c *RecordCache) Observe(uri, cid string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[uri]; ok && (cid == "" || element.Value.(*recordCacheEntry).cid != cid) {
+		c.remove(element)
+	}
+}
+
+// Forget drops a record from the cache
+func (c *RecordCache) Forget(uri string) {
+	c.Observe(uri, "")
+}
+
+// Len returns the number of cached records, including expired ones not yet
+// evicted
+func (c *RecordCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *RecordCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*recordCacheEntry).uri)
+}
+
+// forgetWrites drops the records a putRecord, deleteRecord or applyWrites
+// request to url changes. It's called once the request is done, whether or
+// not it succeeded, so a record is read afresh after any attempt to change it.
+func (c *RecordCache) forgetWrites(url string, body []byte) {
+	if c == nil {
+		return
+	}
+	type write struct {
+		Repo       string `json:"repo"`
+		Collection string `json:"collection"`
+		Rkey       string `json:"rkey"`
+	}
+	switch {
+	case strings.HasSuffix(url, "/xrpc/com.atproto.repo.putRecord"),
+		strings.HasSuffix(url, "/xrpc/com.atproto.repo.deleteRecord"):
+		var req write
+		if json.Unmarshal(body, &req) == nil && req.Rkey != "" {
+			c.Forget(recordURI(req.Repo, req.Collection, req.Rkey))
+		}
+
+	case strings.HasSuffix(url, "/xrpc/com.atproto.repo.applyWrites"):
+		var req struct {
+			Repo   string  `json:"repo"`
+			Writes []write `json:"writes"`
+		}
+		if json.Unmarshal(body, &req) != nil {
+			return
+		}
+		for _, w := range req.Writes {
+			if w.Rkey != "" {
+				c.Forget(recordURI(req.Repo, w.Collection, w.Rkey))
+			}
+		}
+	}
+}
+
+func recordURI(repo, collection, rkey string) string {
+	return "at://" + repo + "/" + collection + "/" + rkey
+}
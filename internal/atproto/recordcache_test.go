@@ -0,0 +1,88 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+func TestRecordCacheEvictsAndExpires(t *testing.T) {
+	now := time.Now()
+	cache := NewRecordCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.put("at://a", "cid-a", json.RawMessage(`{}`))
+	cache.put("at://b", "cid-b", json.RawMessage(`{}`))
+	cache.get("at://a")
+	cache.put("at://c", "cid-c", json.RawMessage(`{}`))
+	if _, _, ok := cache.get("at://b"); ok {
+		t.Error("Expected the least recently used record evicted")
+	}
+	if cid, _, ok := cache.get("at://a"); !ok || cid != "cid-a" {
+		t.Errorf("Expected a kept, got %q %v", cid, ok)
+	}
+
+	cache.Observe("at://a", "cid-a")
+	if _, _, ok := cache.get("at://a"); !ok {
+		t.Error("Expected a kept after seeing the version it holds")
+	}
+	cache.Observe("at://a", "cid-a2")
+	if _, _, ok := cache.get("at://a"); ok {
+		t.Error("Expected a forgotten after seeing a newer version")
+	}
+
+	now = now.Add(time.Minute)
+	if _, _, ok := cache.get("at://c"); ok || cache.Len() != 0 {
+		t.Errorf("Expected c expired, %d records left", cache.Len())
+	}
+
+	var disabled *RecordCache
+	disabled.put("at://a", "cid-a", json.RawMessage(`{}`))
+	if _, _, ok := disabled.get("at://a"); ok {
+		t.Error("Expected a nil cache to cache nothing")
+	}
+}
+
+func TestRecordCacheFollowsWrites(t *testing.T) {
+	pds := newSwapPDS(t)
+	pds.interfere = interfereOnce(func(value map[string]interface{}) {
+		value["extensions"] = map[string]interface{}{"com.example.overlay": map[string]interface{}{"layout": "compact"}}
+	})
+	client := newFakeClient(t, pds)
+	client.SetRecordCache(NewRecordCache(10, time.Minute))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetGame(ctx, swapGameURI); err != nil {
+			t.Fatalf("GetGame failed: %v", err)
+		}
+	}
+	if gets := pds.requests("com.atproto.repo.getRecord"); gets != 1 {
+		t.Fatalf("Expected the game read once, got %d reads", gets)
+	}
+
+	// The conflicting write forgets the cached game, so the retry reads the
+	// concurrent change rather than failing again
+	move := &chess.MoveResult{From: "e2", To: "e4", SAN: "e4", FEN: afterE4}
+	if err := client.RecordMove(ctx, swapGameURI, move); err != nil {
+		t.Fatalf("Expected the move to be reapplied, got %v", err)
+	}
+	gets := pds.requests("com.atproto.repo.getRecord")
+	game, err := client.GetGame(ctx, swapGameURI)
+	if reads := pds.requests("com.atproto.repo.getRecord") - gets; err != nil || game.FEN != afterE4 || reads != 0 {
+		t.Fatalf("Expected the written game served from the cache, got %v after %d reads (%v)", game, reads, err)
+	}
+
+	// A version seen on the firehose replaces the cached one
+	pds.mu.Lock()
+	pds.change(swapGameURI, func(value map[string]interface{}) { value["status"] = "draw" })
+	cid := pds.cid(swapGameURI)
+	pds.mu.Unlock()
+	client.RecordCache().Observe(swapGameURI, cid)
+	if game, err := client.GetGame(ctx, swapGameURI); err != nil || game.Status != chess.StatusDraw {
+		t.Errorf("Expected the newer version read, got %v (%v)", game, err)
+	}
+}
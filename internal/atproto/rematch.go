@@ -0,0 +1,222 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// ErrRematchNotPending is returned when responding to a rematch offer that
+// was already accepted or declined
+var ErrRematchNotPending = errors.New("rematch offer is not pending")
+
+// ErrNotRematchOpponent is returned when responding to a rematch offer made
+// to someone else
+var ErrNotRematchOpponent = errors.New("rematch offer is not addressed to this user")
+
+// RematchOffer is an offer to play a finished game again with colors swapped
+type RematchOffer struct {
+	URI        string `json:"uri"`
+	CID        string `json:"cid"`
+	CreatedAt  string `json:"createdAt"`
+	GameURI    string `json:"gameUri"`
+	GameCID    string `json:"gameCid"`
+	OfferedBy  string `json:"offeredBy"`
+	Opponent   string `json:"opponent"`
+	Color      string `json:"color"` // the offering player's color in the rematch
+	Message    string `json:"message,omitempty"`
+	Status     string `json:"status"`
+	RematchURI string `json:"rematchUri,omitempty"` // the new game, once accepted
+}
+
+// OfferRematch creates a rematch offer for a finished game in our
+// repository. The offering player takes the other color in the rematch.
+func (c *Client) OfferRematch(ctx context.Context, gameURI, message string) (*RematchOffer, error) {
+	gameCID, gameValue, err := c.getGameRecord(ctx, gameURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game record: %w", err)
+	}
+
+	white, _ := gameValue["white"].(string)
+	black, _ := gameValue["black"].(string)
+	var opponent, color string
+	switch c.did {
+	case white:
+		opponent, color = black, "black"
+	case black:
+		opponent, color = white, "white"
+	default:
+		return nil, ErrNotInGame
+	}
+
+	offer := &RematchOffer{
+		CreatedAt: time.Now().Format(time.RFC3339),
+		GameURI:   gameURI,
+		GameCID:   gameCID,
+		OfferedBy: c.did,
+		Opponent:  opponent,
+		Color:     color,
+		Message:   message,
+		Status:    "pending",
+	}
+	offerRecord := map[string]interface{}{
+		"$type":     "app.atchess.rematchOffer",
+		"createdAt": offer.CreatedAt,
+		"game": map[string]interface{}{
+			"uri": gameURI,
+			"cid": gameCID,
+		},
+		"offeredBy": c.did,
+		"opponent":  opponent,
+		"color":     color,
+		"status":    "pending",
+	}
+	if message != "" {
+		offerRecord["message"] = message
+	}
+
+	createReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.rematchOffer",
+		"record":     offerRecord,
+	}
+
+	reqBody, _ := json.Marshal(createReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rematch offer record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to create rematch offer record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var createResp struct {
+		URI string `json:"uri"`
+		CID string `json:"cid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&createResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	offer.URI = createResp.URI
+	offer.CID = createResp.CID
+	return offer, nil
+}
+
+// GetRematchOffer loads a rematch offer from the offering player's repository
+func (c *Client) GetRematchOffer(ctx context.Context, offerURI string) (*RematchOffer, error) {
+	cid, value, err := c.getRecord(ctx, "app.atchess.rematchOffer", offerURI)
+	if err != nil {
+		return nil, err
+	}
+	return rematchOfferFromRecord(offerURI, cid, value), nil
+}
+
+func rematchOfferFromRecord(uri, cid string, value map[string]interface{}) *RematchOffer {
+	offer := &RematchOffer{URI: uri, CID: cid}
+	offer.CreatedAt, _ = value["createdAt"].(string)
+	offer.OfferedBy, _ = value["offeredBy"].(string)
+	offer.Opponent, _ = value["opponent"].(string)
+	offer.Color, _ = value["color"].(string)
+	offer.Message, _ = value["message"].(string)
+	offer.Status, _ = value["status"].(string)
+	if game, ok := value["game"].(map[string]interface{}); ok {
+		offer.GameURI, _ = game["uri"].(string)
+		offer.GameCID, _ = game["cid"].(string)
+	}
+	if rematch, ok := value["rematch"].(map[string]interface{}); ok {
+		offer.RematchURI, _ = rematch["uri"].(string)
+	}
+	return offer
+}
+
+// AcceptRematch accepts a pending rematch offer made to the current user. It
+// creates the new game in the accepter's repository, with the finished
+// game's variant and time control and the colors swapped, and marks the
+// offer as accepted.
+func (c *Client) AcceptRematch(ctx context.Context, offerURI string) (*chess.Game, error) {
+	offerCID, offerValue, offer, err := c.getPendingRematchOffer(ctx, offerURI)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := c.GetGame(ctx, offer.GameURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get finished game: %w", err)
+	}
+
+	color := "white"
+	if offer.Color == "white" {
+		color = "black"
+	}
+	game, err := c.createGame(ctx, offer.OfferedBy, color, gameOptions{
+		rematchURI:  offer.GameURI,
+		rematchCID:  offer.GameCID,
+		timeControl: previous.TimeControl,
+		variant:     previous.Variant,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	gameCID, _, err := c.getGameRecord(ctx, game.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get created game record: %w", err)
+	}
+
+	// The offer lives in the offering player's repository, so this is
+	// best-effort; the new game's rematchOf reference is authoritative
+	offerValue["status"] = "accepted"
+	offerValue["respondedAt"] = time.Now().Format(time.RFC3339)
+	offerValue["rematch"] = map[string]interface{}{
+		"uri": game.ID,
+		"cid": gameCID,
+	}
+	_ = c.updateRecord(ctx, "app.atchess.rematchOffer", offerURI, offerCID, offerValue)
+
+	return game, nil
+}
+
+// DeclineRematch declines a pending rematch offer made to the current user
+func (c *Client) DeclineRematch(ctx context.Context, offerURI string) (*RematchOffer, error) {
+	offerCID, offerValue, offer, err := c.getPendingRematchOffer(ctx, offerURI)
+	if err != nil {
+		return nil, err
+	}
+
+	offerValue["status"] = "declined"
+	offerValue["respondedAt"] = time.Now().Format(time.RFC3339)
+	if err := c.updateRecord(ctx, "app.atchess.rematchOffer", offerURI, offerCID, offerValue); err != nil {
+		return nil, fmt.Errorf("failed to update rematch offer: %w", err)
+	}
+
+	offer.Status = "declined"
+	return offer, nil
+}
+
+// getPendingRematchOffer loads a rematch offer and checks that it's pending
+// and addressed to the current user
+func (c *Client) getPendingRematchOffer(ctx context.Context, offerURI string) (string, map[string]interface{}, *RematchOffer, error) {
+	cid, value, err := c.getRecord(ctx, "app.atchess.rematchOffer", offerURI)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	offer := rematchOfferFromRecord(offerURI, cid, value)
+	if offer.Opponent != c.did {
+		return "", nil, nil, ErrNotRematchOpponent
+	}
+	if offer.Status != "pending" {
+		return "", nil, nil, fmt.Errorf("%w: status is %s", ErrRematchNotPending, offer.Status)
+	}
+	return cid, value, offer, nil
+}
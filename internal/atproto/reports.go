@@ -0,0 +1,80 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Report reasons, as in the app.atchess.report lexicon
+const (
+	ReportCheating       = "cheating"
+	ReportChatAbuse      = "chat_abuse"
+	ReportSpamChallenges = "spam_challenges"
+	ReportOther          = "other"
+)
+
+// ReportRecord is an app.atchess.report record, one player's report of
+// another's conduct. It's kept in the reporter's repository.
+type ReportRecord struct {
+	URI       string `json:"uri"`
+	Reporter  string `json:"reporter"`
+	Subject   string `json:"subject"`
+	Reason    string `json:"reason"`
+	Details   string `json:"details,omitempty"`
+	Game      string `json:"game,omitempty"`   // AT URI of the game the report is about
+	Record    string `json:"record,omitempty"` // AT URI of the offending record, e.g. a chat message
+	CreatedAt string `json:"createdAt"`
+}
+
+// CreateReport files a report in the current account's repository
+func (c *Client) CreateReport(ctx context.Context, report *ReportRecord) (*ReportRecord, error) {
+	report.Reporter = c.did
+	report.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	record := map[string]interface{}{
+		"$type":     "app.atchess.report",
+		"createdAt": report.CreatedAt,
+		"subject":   report.Subject,
+		"reason":    report.Reason,
+	}
+	if report.Details != "" {
+		record["details"] = report.Details
+	}
+	if report.Game != "" {
+		record["game"] = report.Game
+	}
+	if report.Record != "" {
+		record["record"] = report.Record
+	}
+
+	createReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.report",
+		"record":     record,
+	}
+
+	reqBody, _ := json.Marshal(createReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to create report record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var createResp struct {
+		URI string `json:"uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&createResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	report.URI = createResp.URI
+	return report, nil
+}
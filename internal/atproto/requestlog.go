@@ -0,0 +1,22 @@
+package atproto
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/requestid"
+)
+
+// logRequest logs a finished PDS request at debug level through the logger of
+// the API request that made it, so it carries that request's ID
+func logRequest(ctx context.Context, method, url string, start time.Time, resp *http.Response, err error) {
+	event := requestid.Logger(ctx).Debug()
+	if xrpc := xrpcMethod(url); xrpc != "" {
+		event = event.Str("xrpc", xrpc)
+	}
+	if resp != nil {
+		event = event.Int("status", resp.StatusCode)
+	}
+	event.Err(err).Str("method", method).Dur("duration", time.Since(start)).Msg("PDS request")
+}
@@ -0,0 +1,37 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/requestid"
+)
+
+func TestRequestIDIsForwardedToPDS(t *testing.T) {
+	var forwarded string
+	pds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/xrpc/com.atproto.server.createSession" {
+			json.NewEncoder(w).Encode(map[string]string{"accessJwt": "access-1", "did": "did:plc:test123", "handle": "test.user"})
+			return
+		}
+		forwarded = r.Header.Get(requestid.Header)
+		json.NewEncoder(w).Encode(map[string]interface{}{"uri": testRecordURI, "cid": "cid1", "value": map[string]interface{}{}})
+	}))
+	defer pds.Close()
+
+	client, err := NewClient(pds.URL, "test.user", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ctx := requestid.NewContext(context.Background(), "req-42")
+	if _, _, err := client.getRecord(ctx, "app.atchess.seek", testRecordURI); err != nil {
+		t.Fatalf("Expected the request to succeed: %v", err)
+	}
+	if forwarded != "req-42" {
+		t.Errorf("Expected the request ID to be forwarded, got %q", forwarded)
+	}
+}
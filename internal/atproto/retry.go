@@ -0,0 +1,133 @@
+package atproto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RetryPolicy controls how requests to the PDS are retried after transient
+// failures: network errors, 5xx responses and rate limiting. Delays grow
+// exponentially from BaseDelay up to MaxDelay, with random jitter, and a
+// rate-limited response's Retry-After is honored. Retries stop early rather
+// than wait past the request context's deadline.
+//
+// Writes are retried too. A write the PDS applied but failed to acknowledge
+// may then be stored twice; moves are deduplicated when a game is replayed.
+type RetryPolicy struct {
+	MaxAttempts int // including the first; 1 disables retries
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy makes up to four attempts over about two seconds
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// SetRetryPolicy replaces the client's retry policy
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// RetryPolicy returns the client's retry policy
+func (c *Client) RetryPolicy() RetryPolicy {
+	return c.retry
+}
+
+// backoff returns the jittered delay before the given retry, counting from 1
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay << (retry - 1)
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+	// Spread retries over the second half of the delay so clients that failed
+	// together don't retry together
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// retryDelay decides whether a failed attempt is worth retrying, and after how long
+func (p RetryPolicy) retryDelay(retry int, resp *http.Response, err error) (time.Duration, bool) {
+	if err != nil {
+		// The caller gave up; retrying can't help
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return 0, false
+		}
+		return p.backoff(retry), true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if wait, ok := retryAfter(resp); ok {
+			return wait, true
+		}
+		return p.backoff(retry), true
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return p.backoff(retry), true
+	}
+	return 0, false
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// doRequestWithRetry sends a request, retrying transient failures. When it
+// gives up, the last response or error is returned as is.
+func (c *Client) doRequestWithRetry(ctx context.Context, method, url, contentType string, body []byte, token string) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.doRequest(ctx, method, url, contentType, body, token)
+		if attempt >= c.retry.MaxAttempts {
+			return resp, err
+		}
+
+		wait, retry := c.retry.retryDelay(attempt, resp, err)
+		if !retry {
+			return resp, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		addSpanEvent(ctx, "retry", attribute.Int("attempt", attempt+1), attribute.String("wait", wait.String()))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("gave up retrying %s %s: %w", method, url, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
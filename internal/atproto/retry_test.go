@@ -0,0 +1,111 @@
+package atproto
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFlakyPDS answers record requests with the given statuses in turn, then succeeds
+func newFlakyPDS(t *testing.T, retryAfter string, statuses ...int) *fakePDS {
+	t.Helper()
+	pds := newFakePDS(t)
+	pds.put(testRecordURI, map[string]interface{}{"status": "open"})
+	pds.failNext(retryAfter, statuses...)
+	return pds
+}
+
+func newRetryingClient(t *testing.T, pds *fakePDS, maxAttempts int) *Client {
+	t.Helper()
+	client := newFakeClient(t, pds)
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: maxAttempts, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+	return client
+}
+
+func TestTransientFailuresAreRetried(t *testing.T) {
+	pds := newFlakyPDS(t, "", http.StatusServiceUnavailable, http.StatusBadGateway)
+	client := newRetryingClient(t, pds, 4)
+
+	cid, _, err := client.getRecord(context.Background(), "app.atchess.seek", testRecordURI)
+	if err != nil || cid != "cid1" {
+		t.Fatalf("Expected the request to succeed after retrying, got %q (%v)", cid, err)
+	}
+	if attempts := pds.requests("com.atproto.repo.getRecord"); attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestRateLimitHonorsRetryAfter(t *testing.T) {
+	pds := newFlakyPDS(t, "1", http.StatusTooManyRequests)
+	client := newRetryingClient(t, pds, 4)
+
+	start := time.Now()
+	if _, _, err := client.getRecord(context.Background(), "app.atchess.seek", testRecordURI); err != nil {
+		t.Fatalf("Expected the request to succeed after waiting: %v", err)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("Expected to wait for Retry-After, waited %v", waited)
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	pds := newFlakyPDS(t, "", http.StatusBadRequest)
+	client := newRetryingClient(t, pds, 4)
+
+	if _, _, err := client.getRecord(context.Background(), "app.atchess.seek", testRecordURI); err == nil {
+		t.Fatal("Expected the error to be returned")
+	}
+	if attempts := pds.requests("com.atproto.repo.getRecord"); attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestRetriesStopAtMaxAttempts(t *testing.T) {
+	pds := newFlakyPDS(t, "", 503, 503, 503, 503, 503)
+	client := newRetryingClient(t, pds, 3)
+
+	_, _, err := client.getRecord(context.Background(), "app.atchess.seek", testRecordURI)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 503") {
+		t.Errorf("Expected the last failure to be returned, got %v", err)
+	}
+	if attempts := pds.requests("com.atproto.repo.getRecord"); attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestRetriesStopAtContextDeadline(t *testing.T) {
+	pds := newFlakyPDS(t, "30", http.StatusTooManyRequests)
+	client := newRetryingClient(t, pds, 4)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := client.getRecord(ctx, "app.atchess.seek", testRecordURI)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 429") {
+		t.Errorf("Expected the rate limit to be returned, got %v", err)
+	}
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Errorf("Expected to give up without waiting past the deadline, waited %v", waited)
+	}
+	if attempts := pds.requests("com.atproto.repo.getRecord"); attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestBackoffStaysWithinBounds(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for retry := 1; retry <= 8; retry++ {
+		want := policy.BaseDelay << (retry - 1)
+		if want > policy.MaxDelay {
+			want = policy.MaxDelay
+		}
+		for i := 0; i < 20; i++ {
+			if d := policy.backoff(retry); d < want/2 || d > want {
+				t.Fatalf("Retry %d: expected a delay between %v and %v, got %v", retry, want/2, want, d)
+			}
+		}
+	}
+}
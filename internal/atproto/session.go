@@ -0,0 +1,151 @@
+package atproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/identity"
+)
+
+// expiredTokenError is the XRPC error a PDS returns for an expired access token
+const expiredTokenError = "ExpiredToken"
+
+// sessionResponse is the body of createSession and refreshSession responses
+type sessionResponse struct {
+	AccessJwt  string `json:"accessJwt"`
+	RefreshJwt string `json:"refreshJwt"`
+	Did        string `json:"did"`
+	Handle     string `json:"handle"`
+	// Email is only returned to the account's own sessions
+	Email          string `json:"email,omitempty"`
+	EmailConfirmed bool   `json:"emailConfirmed,omitempty"`
+}
+
+// SessionCredentials are the tokens of a password or app password session,
+// enough for another process to act as the user until the refresh token
+// expires
+type SessionCredentials struct {
+	PDSURL     string `json:"pds_url"`
+	DID        string `json:"did"`
+	Handle     string `json:"handle"`
+	AccessJWT  string `json:"access_jwt"`
+	RefreshJWT string `json:"refresh_jwt"`
+}
+
+// SessionCredentials returns the client's current tokens. Sessions bound to
+// a DPoP key, like OAuth sessions, can't be carried over and return false.
+func (c *Client) SessionCredentials() (SessionCredentials, bool) {
+	if c.useDPoP {
+		return SessionCredentials{}, false
+	}
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return SessionCredentials{
+		PDSURL:     c.pdsURL,
+		DID:        c.did,
+		Handle:     c.handle,
+		AccessJWT:  c.accessJWT,
+		RefreshJWT: c.refreshJWT,
+	}, true
+}
+
+// NewClientWithSessionCredentials creates a client resuming a session from
+// its tokens, refreshing them when the access token has expired
+func NewClientWithSessionCredentials(credentials SessionCredentials) *Client {
+	return &Client{
+		pdsURL:     credentials.PDSURL,
+		accessJWT:  credentials.AccessJWT,
+		refreshJWT: credentials.RefreshJWT,
+		did:        credentials.DID,
+		handle:     credentials.Handle,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retry:      DefaultRetryPolicy,
+		pageSize:   MaxListPageSize,
+		resolver:   identity.New(identity.Options{PDSURL: credentials.PDSURL}),
+		scope:      tokenScope(credentials.AccessJWT),
+	}
+}
+
+// token returns the current access token
+func (c *Client) token() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.accessJWT
+}
+
+// isExpiredToken reports whether a response rejects an expired access token.
+// The body is left readable for the caller.
+func isExpiredToken(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	var xrpcErr struct {
+		Error string `json:"error"`
+	}
+	return json.Unmarshal(body, &xrpcErr) == nil && xrpcErr.Error == expiredTokenError
+}
+
+// refreshSession exchanges the refresh token for new tokens with
+// com.atproto.server.refreshSession. stale is the access token that was
+// rejected; if a concurrent request has already replaced it, there's nothing
+// to do.
+func (c *Client) refreshSession(stale string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	c.tokenMu.RLock()
+	current, refreshJWT := c.accessJWT, c.refreshJWT
+	c.tokenMu.RUnlock()
+	if current != stale {
+		return nil
+	}
+	if refreshJWT == "" {
+		return errors.New("no refresh token for this session")
+	}
+
+	req, err := http.NewRequest("POST", c.pdsURL+"/xrpc/com.atproto.server.refreshSession", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.authorize(req, refreshJWT)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to refresh session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to refresh session: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var session sessionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return fmt.Errorf("failed to decode session response: %w", err)
+	}
+	if session.AccessJwt == "" {
+		return errors.New("failed to refresh session: no access token in response")
+	}
+
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.accessJWT = session.AccessJwt
+	if session.RefreshJwt != "" {
+		c.refreshJWT = session.RefreshJwt
+	}
+	return nil
+}
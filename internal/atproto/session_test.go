@@ -0,0 +1,112 @@
+package atproto
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testRecordURI = "at://did:plc:test123/app.atchess.seek/abc"
+
+// newExpiringPDS issues a session whose first access token has already expired
+func newExpiringPDS(t *testing.T) *fakePDS {
+	t.Helper()
+	pds := newFakePDS(t)
+	pds.put(testRecordURI, map[string]interface{}{"status": "open"})
+	pds.loginExpired = true
+	return pds
+}
+
+func TestExpiredTokenIsRefreshedAndRetried(t *testing.T) {
+	pds := newExpiringPDS(t)
+	client := newFakeClient(t, pds)
+
+	cid, value, err := client.getRecord(context.Background(), "app.atchess.seek", testRecordURI)
+	if err != nil || cid != "cid1" || value["status"] != "open" {
+		t.Fatalf("Expected the request to succeed after a refresh, got %v %v (%v)", cid, value, err)
+	}
+	if client.token() != "access-2" || client.refreshJWT != "refresh-2" {
+		t.Errorf("Expected the new tokens to be stored, got %s and %s", client.token(), client.refreshJWT)
+	}
+	if _, _, err := client.getRecord(context.Background(), "app.atchess.seek", testRecordURI); err != nil {
+		t.Errorf("Expected later requests to use the new token: %v", err)
+	}
+	if refreshes := pds.requests("com.atproto.server.refreshSession"); refreshes != 1 {
+		t.Errorf("Expected one refresh, got %d", refreshes)
+	}
+}
+
+func TestSessionCredentialsResumeASession(t *testing.T) {
+	pds := newExpiringPDS(t)
+	client := newFakeClient(t, pds)
+
+	credentials, ok := client.SessionCredentials()
+	if !ok || credentials.DID != "did:plc:test123" || credentials.RefreshJWT != "refresh-1" {
+		t.Fatalf("Expected the session's tokens, got %+v", credentials)
+	}
+
+	// The resumed client refreshes the expired access token like the original
+	resumed := NewClientWithSessionCredentials(credentials)
+	if resumed.GetDID() != "did:plc:test123" {
+		t.Errorf("Expected the session's DID, got %s", resumed.GetDID())
+	}
+	if _, _, err := resumed.getRecord(context.Background(), "app.atchess.seek", testRecordURI); err != nil {
+		t.Fatalf("Expected the resumed session to work: %v", err)
+	}
+	if credentials, _ := resumed.SessionCredentials(); credentials.AccessJWT != "access-2" || credentials.RefreshJWT != "refresh-2" {
+		t.Errorf("Expected the refreshed tokens, got %+v", credentials)
+	}
+
+	if _, ok := (&Client{useDPoP: true}).SessionCredentials(); ok {
+		t.Error("Expected DPoP-bound sessions to have no portable credentials")
+	}
+}
+
+func TestConcurrentRequestsShareOneRefresh(t *testing.T) {
+	pds := newExpiringPDS(t)
+	client := newFakeClient(t, pds)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := client.getRecord(context.Background(), "app.atchess.seek", testRecordURI); err != nil {
+				t.Errorf("Request failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if refreshes := pds.requests("com.atproto.server.refreshSession"); refreshes != 1 {
+		t.Errorf("Expected one refresh, got %d", refreshes)
+	}
+}
+
+func TestFailedRefreshReturnsOriginalError(t *testing.T) {
+	pds := newExpiringPDS(t)
+	pds.refreshExpired = true
+	client := newFakeClient(t, pds)
+
+	_, _, err := client.getRecord(context.Background(), "app.atchess.seek", testRecordURI)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 400") || !strings.Contains(err.Error(), "ExpiredToken") {
+		t.Errorf("Expected the expired token error, got %v", err)
+	}
+	if client.token() != "access-1" {
+		t.Errorf("Expected the tokens to be kept, got %s", client.token())
+	}
+}
+
+func TestOtherErrorsAreNotRetried(t *testing.T) {
+	pds := newFakePDS(t)
+	client := newFakeClient(t, pds)
+
+	_, _, err := client.getRecord(context.Background(), "app.atchess.seek", "at://did:plc:test123/app.atchess.seek/invalid")
+	if err == nil || !strings.Contains(err.Error(), "Could not locate record") {
+		t.Errorf("Expected the error body to be passed through, got %v", err)
+	}
+	if refreshes := pds.requests("com.atproto.server.refreshSession"); refreshes != 0 {
+		t.Errorf("Expected no refresh, got %d", refreshes)
+	}
+}
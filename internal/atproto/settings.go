@@ -0,0 +1,85 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ErrNoSettings is returned for accounts without an app.atchess.settings record
+var ErrNoSettings = errors.New("no settings record")
+
+// SettingsRecord is the app.atchess.settings record, where a player says who
+// they accept challenges from. It's public, so every instance can honor it.
+type SettingsRecord struct {
+	// ChallengeDenyList holds the DIDs of players whose challenges are refused
+	ChallengeDenyList []string `json:"challengeDenyList,omitempty"`
+	UpdatedAt         string   `json:"updatedAt,omitempty"`
+}
+
+// GetSettings fetches an account's settings record, returning ErrNoSettings
+// if it has never saved any
+func (c *Client) GetSettings(ctx context.Context, did string) (*SettingsRecord, error) {
+	url := fmt.Sprintf("%s/xrpc/com.atproto.repo.getRecord?repo=%s&collection=app.atchess.settings&rkey=self", c.pdsURL, did)
+	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		var xrpcErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &xrpcErr) == nil && xrpcErr.Error == "RecordNotFound" {
+			return nil, ErrNoSettings
+		}
+		return nil, fmt.Errorf("failed to get settings record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var getResp struct {
+		Value SettingsRecord `json:"value"`
+	}
+	if err := json.Unmarshal(body, &getResp); err != nil {
+		return nil, fmt.Errorf("failed to decode settings record: %w", err)
+	}
+	return &getResp.Value, nil
+}
+
+// PutSettings writes the current account's settings record
+func (c *Client) PutSettings(ctx context.Context, settings *SettingsRecord) error {
+	settings.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	record := map[string]interface{}{
+		"$type":     "app.atchess.settings",
+		"updatedAt": settings.UpdatedAt,
+	}
+	if len(settings.ChallengeDenyList) > 0 {
+		record["challengeDenyList"] = settings.ChallengeDenyList
+	}
+
+	putReq := map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.settings",
+		"rkey":       "self",
+		"record":     record,
+	}
+
+	reqBody, _ := json.Marshal(putReq)
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to write settings record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to write settings record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/justinabrahms/atchess/internal/study"
+)
+
+// studyValue is the stored form of an app.atchess.study record
+type studyValue struct {
+	CreatedAt   string           `json:"createdAt"`
+	UpdatedAt   string           `json:"updatedAt"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Members     []string         `json:"members"`
+	Version     int              `json:"version"`
+	Chapters    []*study.Chapter `json:"chapters"`
+}
+
+// studyRecord is a study as written to its owner's repository
+func studyRecord(s *study.Study) map[string]interface{} {
+	record := map[string]interface{}{
+		"$type":     "app.atchess.study",
+		"createdAt": s.CreatedAt,
+		"name":      s.Name,
+		"members":   s.Members,
+		"version":   s.Version,
+		"chapters":  s.Chapters,
+	}
+	if s.UpdatedAt != "" {
+		record["updatedAt"] = s.UpdatedAt
+	}
+	if s.Description != "" {
+		record["description"] = s.Description
+	}
+	return record
+}
+
+// CreateStudy writes a new study to the current account's repository and
+// sets its URI and owner
+func (c *Client) CreateStudy(ctx context.Context, s *study.Study) error {
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"repo":       c.did,
+		"collection": "app.atchess.study",
+		"record":     studyRecord(s),
+	})
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.createRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create study record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to create study record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var createResp struct {
+		URI string `json:"uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&createResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	s.URI, s.Owner = createResp.URI, c.did
+	return nil
+}
+
+// SaveStudy replaces a study record in the current account's repository
+func (c *Client) SaveStudy(ctx context.Context, s *study.Study) error {
+	repo, rkey, err := studyKey(s.URI)
+	if err != nil {
+		return err
+	}
+	if repo != c.did {
+		return fmt.Errorf("cannot save a study in another user's repository")
+	}
+
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"repo":       repo,
+		"collection": "app.atchess.study",
+		"rkey":       rkey,
+		"record":     studyRecord(s),
+	})
+	resp, err := c.makeRequest(ctx, "POST", c.pdsURL+"/xrpc/com.atproto.repo.putRecord", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to save study record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to save study record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// GetStudy reads a study from its owner's repository, returning
+// study.ErrNotFound if there's no such record
+func (c *Client) GetStudy(ctx context.Context, uri string) (*study.Study, error) {
+	repo, rkey, err := studyKey(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"repo": {repo}, "collection": {"app.atchess.study"}, "rkey": {rkey}}
+	resp, err := c.makeRequest(ctx, "GET", c.pdsURL+"/xrpc/com.atproto.repo.getRecord?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get study record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		var xrpcErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &xrpcErr) == nil && xrpcErr.Error == "RecordNotFound" {
+			return nil, study.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get study record: HTTP %d - %s", resp.StatusCode, string(body))
+	}
+
+	var getResp struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&getResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return decodeStudy(uri, repo, getResp.Value)
+}
+
+// ListStudies returns the studies in a player's repository
+func (c *Client) ListStudies(ctx context.Context, did string) ([]*study.Study, error) {
+	studies := []*study.Study{}
+	err := c.listAllRecords(ctx, did, "app.atchess.study", func(uri, cid string, value json.RawMessage) error {
+		s, err := decodeStudy(uri, did, value)
+		if err != nil {
+			return nil // Skip malformed records
+		}
+		studies = append(studies, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return studies, nil
+}
+
+// DeleteStudy deletes a study from the current account's repository
+func (c *Client) DeleteStudy(ctx context.Context, uri string) error {
+	repo, rkey, err := studyKey(uri)
+	if err != nil {
+		return err
+	}
+	if repo != c.did {
+		return fmt.Errorf("cannot delete a study from another user's repository")
+	}
+	if err := c.deleteRecordsBatched(ctx, "app.atchess.study", []string{rkey})[rkey]; err != nil {
+		return fmt.Errorf("failed to delete study record: %w", err)
+	}
+	return nil
+}
+
+// decodeStudy reads a stored study record
+func decodeStudy(uri, owner string, raw json.RawMessage) (*study.Study, error) {
+	var value studyValue
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode study record: %w", err)
+	}
+	s := &study.Study{
+		URI:         uri,
+		Owner:       owner,
+		Name:        value.Name,
+		Description: value.Description,
+		Members:     value.Members,
+		Chapters:    value.Chapters,
+		Version:     value.Version,
+		CreatedAt:   value.CreatedAt,
+		UpdatedAt:   value.UpdatedAt,
+	}
+	if s.Members == nil {
+		s.Members = []string{}
+	}
+	if s.Chapters == nil {
+		s.Chapters = []*study.Chapter{}
+	}
+	for _, chapter := range s.Chapters {
+		if chapter.Nodes == nil {
+			chapter.Nodes = []*study.Node{}
+		}
+	}
+	return s, nil
+}
+
+// studyKey splits a study URI into its repository and record key
+func studyKey(uri string) (repo, rkey string, err error) {
+	parts := strings.Split(uri, "/")
+	if len(parts) != 5 || !strings.HasPrefix(uri, "at://") || parts[3] != "app.atchess.study" {
+		return "", "", fmt.Errorf("invalid study URI: %s", uri)
+	}
+	return parts[2], parts[4], nil
+}
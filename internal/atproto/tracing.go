@@ -0,0 +1,65 @@
+package atproto
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/justinabrahms/atchess/internal/atproto"
+
+// xrpcMethod returns the NSID of the XRPC method a URL calls, e.g.
+// "com.atproto.repo.putRecord"
+func xrpcMethod(url string) string {
+	i := strings.Index(url, "/xrpc/")
+	if i < 0 {
+		return ""
+	}
+	method := url[i+len("/xrpc/"):]
+	if end := strings.IndexAny(method, "?#"); end >= 0 {
+		method = method[:end]
+	}
+	return method
+}
+
+// startRequestSpan starts a client span for a PDS request, named by its XRPC
+// method. Retries and session refreshes are recorded as events on it.
+func startRequestSpan(ctx context.Context, method, url string) (context.Context, trace.Span) {
+	name := xrpcMethod(url)
+	if name == "" {
+		name = method + " PDS"
+	}
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.RPCSystemKey.String("xrpc"),
+			semconv.RPCMethod(name),
+			semconv.HTTPRequestMethodKey.String(method),
+		),
+	)
+}
+
+// endRequestSpan records a PDS request's outcome and ends its span
+func endRequestSpan(span trace.Span, resp *http.Response, err error) {
+	defer span.End()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+}
+
+// addSpanEvent notes something that happened during a traced request
+func addSpanEvent(ctx context.Context, name string, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(attrs...))
+}
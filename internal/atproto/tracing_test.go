@@ -0,0 +1,51 @@
+package atproto
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestXRPCMethod(t *testing.T) {
+	cases := map[string]string{
+		"https://pds.example/xrpc/com.atproto.repo.getRecord?repo=did:plc:a": "com.atproto.repo.getRecord",
+		"http://localhost:3000/xrpc/com.atproto.repo.applyWrites":            "com.atproto.repo.applyWrites",
+		"https://pds.example/health":                                         "",
+	}
+	for url, want := range cases {
+		if got := xrpcMethod(url); got != want {
+			t.Errorf("xrpcMethod(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestRequestsAreTraced(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	pds := newFlakyPDS(t, "", http.StatusServiceUnavailable)
+	client := newRetryingClient(t, pds, 4)
+	if _, _, err := client.getRecord(context.Background(), "app.atchess.seek", testRecordURI); err != nil {
+		t.Fatalf("Expected the request to succeed: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "com.atproto.repo.getRecord" {
+		t.Fatalf("Expected a span named by the XRPC method, got %v", spans)
+	}
+	events := spans[0].Events()
+	if len(events) != 1 || events[0].Name != "retry" {
+		t.Errorf("Expected the retry to be recorded on the span, got %v", events)
+	}
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == "http.response.status_code" && attr.Value.AsInt64() != http.StatusOK {
+			t.Errorf("Expected the final status to be recorded, got %v", attr.Value.AsInt64())
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package atproto
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/justinabrahms/atchess/internal/lexicon"
+)
+
+// validateWrite checks the records in a createRecord, putRecord or
+// applyWrites request against their lexicons, so a malformed record never
+// reaches the PDS. Other requests pass through.
+func validateWrite(url string, body []byte) error {
+	switch {
+	case strings.HasSuffix(url, "/xrpc/com.atproto.repo.createRecord"),
+		strings.HasSuffix(url, "/xrpc/com.atproto.repo.putRecord"):
+		var req struct {
+			Collection string          `json:"collection"`
+			Record     json.RawMessage `json:"record"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil
+		}
+		return validateRecord(req.Collection, req.Record)
+
+	case strings.HasSuffix(url, "/xrpc/com.atproto.repo.applyWrites"):
+		var req struct {
+			Writes []struct {
+				Collection string          `json:"collection"`
+				Value      json.RawMessage `json:"value"`
+			} `json:"writes"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil
+		}
+		for _, write := range req.Writes {
+			// Deletes carry no value
+			if len(write.Value) == 0 {
+				continue
+			}
+			if err := validateRecord(write.Collection, write.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateRecord(collection string, record json.RawMessage) error {
+	if !lexicon.Known(collection) {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(record, &value); err != nil {
+		return fmt.Errorf("invalid %s record: %w", collection, err)
+	}
+	return lexicon.Validate(collection, value)
+}
@@ -0,0 +1,55 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/lexicon"
+)
+
+func TestInvalidRecordsAreNotWritten(t *testing.T) {
+	var writes int32
+	mockPDS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/xrpc/com.atproto.server.createSession":
+			json.NewEncoder(w).Encode(map[string]string{"accessJwt": "access-1", "did": "did:plc:test123", "handle": "test.user"})
+		case "/xrpc/com.atproto.repo.createRecord":
+			atomic.AddInt32(&writes, 1)
+			json.NewEncoder(w).Encode(map[string]string{"uri": "at://did:plc:test123/app.atchess.challenge/c1", "cid": "challenge-cid"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockPDS.Close()
+
+	client, err := NewClient(mockPDS.URL, "test.user", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	_, err = client.CreateChallenge(context.Background(), "did:plc:opponent", "white", strings.Repeat("x", 301))
+	if !errors.Is(err, lexicon.ErrInvalidRecord) {
+		t.Fatalf("Expected ErrInvalidRecord, got %v", err)
+	}
+	_, err = client.CreateChallenge(context.Background(), "not-a-did", "white", "")
+	if !errors.Is(err, lexicon.ErrInvalidRecord) {
+		t.Fatalf("Expected ErrInvalidRecord, got %v", err)
+	}
+	if n := atomic.LoadInt32(&writes); n != 0 {
+		t.Errorf("Expected no records to reach the PDS, got %d", n)
+	}
+
+	if _, err := client.CreateChallenge(context.Background(), "did:plc:opponent", "white", "Good luck!"); err != nil {
+		t.Fatalf("Expected a valid challenge to be written, got %v", err)
+	}
+	if n := atomic.LoadInt32(&writes); n != 1 {
+		t.Errorf("Expected the valid challenge to be written, got %d writes", n)
+	}
+}
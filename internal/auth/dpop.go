@@ -1,9 +1,13 @@
 package auth
 
 import (
+	"bytes"
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -16,12 +20,14 @@ type DPoPManager struct {
 	currentJWK  *JWK
 	keyRotation time.Time
 	proofCache  map[string]time.Time // Track recently used JTIs to prevent replay
+	nonces      map[string]string    // Latest DPoP-Nonce from each origin
 }
 
 // NewDPoPManager creates a new DPoP manager
 func NewDPoPManager() (*DPoPManager, error) {
 	manager := &DPoPManager{
 		proofCache: make(map[string]time.Time),
+		nonces:     make(map[string]string),
 	}
 	
 	// Generate initial key pair
@@ -35,8 +41,35 @@ func NewDPoPManager() (*DPoPManager, error) {
 	return manager, nil
 }
 
+// NewDPoPManagerWithKey creates a DPoP manager that signs proofs with an
+// existing key, such as the one an OAuth session's tokens are bound to. The
+// key mustn't be rotated, since the tokens would stop working.
+func NewDPoPManagerWithKey(key *ecdsa.PrivateKey) (*DPoPManager, error) {
+	jwk, err := PrivateKeyToJWK(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert key to JWK: %w", err)
+	}
+	
+	manager := &DPoPManager{
+		currentKey:  key,
+		currentJWK:  jwk,
+		keyRotation: time.Now(),
+		proofCache:  make(map[string]time.Time),
+		nonces:      make(map[string]string),
+	}
+	go manager.cleanupProofCache()
+	
+	return manager, nil
+}
+
 // CreateProof creates a DPoP proof JWT for a request
 func (m *DPoPManager) CreateProof(method, uri, accessToken string) (string, error) {
+	return m.CreateProofWithNonce(method, uri, accessToken, "")
+}
+
+// CreateProofWithNonce creates a DPoP proof JWT carrying a nonce the server
+// issued; an empty nonce is left out
+func (m *DPoPManager) CreateProofWithNonce(method, uri, accessToken, nonce string) (string, error) {
 	m.mu.RLock()
 	privateKey := m.currentKey
 	jwk := m.currentJWK
@@ -62,6 +95,7 @@ func (m *DPoPManager) CreateProof(method, uri, accessToken string) (string, erro
 		HTTPMethod: strings.ToUpper(method),
 		HTTPURI:    uri,
 		IssuedAt:   now,
+		Nonce:      nonce,
 	}
 	
 	// Add access token hash if provided
@@ -78,12 +112,35 @@ func (m *DPoPManager) CreateProof(method, uri, accessToken string) (string, erro
 	return CreateJWT(header, claims, privateKey)
 }
 
-// CreateProofForRequest creates a DPoP proof for an HTTP request
+// CreateProofForRequest creates a DPoP proof for an HTTP request, with the
+// latest nonce the request's server issued
 func (m *DPoPManager) CreateProofForRequest(req *http.Request, accessToken string) (string, error) {
 	// Extract the URI without query parameters for the htu claim
 	uri := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
 	
-	return m.CreateProof(req.Method, uri, accessToken)
+	return m.CreateProofWithNonce(req.Method, uri, accessToken, m.Nonce(origin(req.URL)))
+}
+
+// Nonce returns the latest DPoP nonce an origin (scheme://host) issued
+func (m *DPoPManager) Nonce(origin string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.nonces[origin]
+}
+
+// SetNonce remembers the DPoP nonce an origin issued, for its next proofs
+func (m *DPoPManager) SetNonce(origin, nonce string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nonces == nil {
+		m.nonces = make(map[string]string)
+	}
+	m.nonces[origin] = nonce
+}
+
+// origin identifies the server a URL belongs to; nonces are per server
+func origin(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
 }
 
 // AddDPoPHeader adds a DPoP header to an HTTP request
@@ -247,31 +304,93 @@ type DPoPInterceptor struct {
 	GetToken  func() string // Function to get the current access token
 }
 
-// RoundTrip implements http.RoundTripper
+// RoundTrip implements http.RoundTripper. Nonces servers issue in DPoP-Nonce
+// headers are remembered for later proofs, and a request the server rejects
+// for want of a fresh nonce is retried once with it.
 func (d *DPoPInterceptor) RoundTrip(req *http.Request) (*http.Response, error) {
-	// Clone the request to avoid modifying the original
-	req = req.Clone(req.Context())
-	
 	// Get current access token
 	accessToken := ""
 	if d.GetToken != nil {
 		accessToken = d.GetToken()
 	}
 	
-	// Add DPoP header
-	if err := d.Manager.AddDPoPHeader(req, accessToken); err != nil {
-		return nil, fmt.Errorf("failed to add DPoP header: %w", err)
-	}
-	
 	// Use default transport if none provided
 	transport := d.Transport
 	if transport == nil {
 		transport = http.DefaultTransport
 	}
 	
+	server := origin(req.URL)
+	sentNonce := d.Manager.Nonce(server)
+	resp, err := d.send(transport, req, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	
+	nonce := resp.Header.Get("DPoP-Nonce")
+	if nonce == "" {
+		return resp, nil
+	}
+	d.Manager.SetNonce(server, nonce)
+	
+	// Only retry if the nonce is new and the body can be sent again
+	if nonce == sentNonce || !isNonceChallenge(resp) || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+	retry := req
+	if req.Body != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry = req.WithContext(req.Context())
+		retry.Body = body
+	}
+	resp.Body.Close()
+	
+	return d.send(transport, retry, accessToken)
+}
+
+// send signs a copy of the request with a fresh proof and sends it
+func (d *DPoPInterceptor) send(transport http.RoundTripper, req *http.Request, accessToken string) (*http.Response, error) {
+	// Clone the request to avoid modifying the original
+	req = req.Clone(req.Context())
+	
+	// Add DPoP header
+	if err := d.Manager.AddDPoPHeader(req, accessToken); err != nil {
+		return nil, fmt.Errorf("failed to add DPoP header: %w", err)
+	}
+	
 	return transport.RoundTrip(req)
 }
 
+// isNonceChallenge reports whether a response demands a DPoP nonce.
+// Resource servers such as a PDS say so in WWW-Authenticate on a 401;
+// authorization servers with a use_dpop_nonce error body on a 400. The body
+// is left readable for the caller.
+func isNonceChallenge(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		if strings.Contains(resp.Header.Get("WWW-Authenticate"), "use_dpop_nonce") {
+			return true
+		}
+	case http.StatusBadRequest:
+	default:
+		return false
+	}
+	
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	var oauthErr struct {
+		Error string `json:"error"`
+	}
+	return json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error == "use_dpop_nonce"
+}
+
 // NewDPoPClient creates an HTTP client with automatic DPoP support
 func NewDPoPClient(manager *DPoPManager, getToken func() string) *http.Client {
 	return &http.Client{
@@ -1,8 +1,13 @@
 package auth
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -213,4 +218,105 @@ func TestURINormalization(t *testing.T) {
 				test.uri1, test.uri2, test.expected, result)
 		}
 	}
-}
\ No newline at end of file
+}
+// nonceServer requires proofs to carry its current nonce, answering like a
+// PDS (401 with WWW-Authenticate) or, if asJSON, like an authorization server
+// (400 with a use_dpop_nonce error). It counts requests and records bodies.
+func nonceServer(t *testing.T, asJSON bool, requests *int32, bodies *[]string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	nonce := "nonce-1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		body, _ := io.ReadAll(r.Body)
+		_, claims, err := VerifyJWT(r.Header.Get("DPoP"))
+		if err != nil {
+			t.Errorf("Invalid DPoP proof: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		*bodies = append(*bodies, string(body))
+		w.Header().Set("DPoP-Nonce", nonce)
+		if claims == nil || claims.Nonce != nonce {
+			if asJSON {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"use_dpop_nonce"}`))
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDPoPInterceptorRetriesWithNonce(t *testing.T) {
+	for _, asJSON := range []bool{false, true} {
+		manager, err := NewDPoPManager()
+		if err != nil {
+			t.Fatalf("Failed to create DPoP manager: %v", err)
+		}
+		var requests int32
+		var bodies []string
+		server := nonceServer(t, asJSON, &requests, &bodies)
+		client := NewDPoPClient(manager, func() string { return "token" })
+
+		resp, err := client.Post(server.URL+"/xrpc/com.atproto.repo.createRecord", "application/json", strings.NewReader(`{"a":1}`))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected the retry with the nonce to succeed, got %d", resp.StatusCode)
+		}
+		if n := atomic.LoadInt32(&requests); n != 2 {
+			t.Errorf("Expected one retry, got %d requests", n)
+		}
+		if len(bodies) != 2 || bodies[1] != `{"a":1}` {
+			t.Errorf("Expected the body to be sent again, got %q", bodies)
+		}
+
+		// The nonce is remembered for the server's next requests
+		atomic.StoreInt32(&requests, 0)
+		resp, err = client.Get(server.URL + "/xrpc/com.atproto.repo.getRecord")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if n := atomic.LoadInt32(&requests); n != 1 || resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected the cached nonce to be used, got %d requests and status %d", n, resp.StatusCode)
+		}
+		if got := manager.Nonce(strings.ToLower(server.URL)); got != "nonce-1" {
+			t.Errorf("Expected nonce-1 to be cached, got %q", got)
+		}
+	}
+}
+
+func TestDPoPInterceptorRetriesOnlyOnce(t *testing.T) {
+	manager, err := NewDPoPManager()
+	if err != nil {
+		t.Fatalf("Failed to create DPoP manager: %v", err)
+	}
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every response demands a different nonce
+		n := atomic.AddInt32(&requests, 1)
+		w.Header().Set("DPoP-Nonce", fmt.Sprintf("nonce-%d", n))
+		w.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	resp, err := NewDPoPClient(manager, nil).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("Expected one retry and then the rejection, got %d after %d requests", resp.StatusCode, requests)
+	}
+}
@@ -43,6 +43,7 @@ type JWTClaims struct {
 	HTTPMethod  string `json:"htm,omitempty"`
 	HTTPURI     string `json:"htu,omitempty"`
 	AccessToken string `json:"ath,omitempty"` // SHA256 hash of access token
+	Nonce       string `json:"nonce,omitempty"` // server-provided DPoP nonce
 	
 	// Additional claims
 	Extra map[string]interface{} `json:"-"`
@@ -103,6 +104,9 @@ func (c *JWTClaims) MarshalJSON() ([]byte, error) {
 	if c.AccessToken != "" {
 		m["ath"] = c.AccessToken
 	}
+	if c.Nonce != "" {
+		m["nonce"] = c.Nonce
+	}
 	
 	// Add extra fields
 	for k, v := range c.Extra {
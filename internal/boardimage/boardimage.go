@@ -0,0 +1,120 @@
+// Package boardimage draws chess positions as PNG or SVG images, for post
+// attachments, link previews and thumbnails. Pieces are drawn as discs marked
+// with their letter so the images need no fonts or artwork.
+package boardimage
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/notnil/chess"
+)
+
+const (
+	// DefaultSize is the width and height of a board, in pixels, when the
+	// options don't set one
+	DefaultSize = 8 * 60
+	// MinSize and MaxSize bound the size of a board, in pixels
+	MinSize = 8 * 10
+	MaxSize = 8 * 120
+)
+
+// Options control how a board is drawn
+type Options struct {
+	// Flipped draws the board from black's side
+	Flipped bool
+	// Highlight tints squares, such as the from and to squares of the last move
+	Highlight []string
+	// Size is the width and height in pixels, rounded down to a multiple of
+	// 8 and clamped to MinSize..MaxSize; 0 means DefaultSize
+	Size int
+}
+
+var (
+	lightSquare     = color.RGBA{0xf0, 0xd9, 0xb5, 0xff}
+	darkSquare      = color.RGBA{0xb5, 0x88, 0x63, 0xff}
+	lightHighlight  = color.RGBA{0xf7, 0xec, 0x74, 0xff}
+	darkHighlight   = color.RGBA{0xda, 0xc3, 0x4b, 0xff}
+	whitePieceFill  = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	blackPieceFill  = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	pieceOutline    = color.RGBA{0x00, 0x00, 0x00, 0xff}
+	whitePieceGlyph = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	blackPieceGlyph = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// pieceGlyphs are 5x7 bitmaps of the letter drawn on each piece's disc
+var pieceGlyphs = map[chess.PieceType][7]string{
+	chess.King:   {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	chess.Queen:  {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	chess.Rook:   {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	chess.Bishop: {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	chess.Knight: {"#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#", "#...#"},
+	chess.Pawn:   {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+}
+
+// square is one square of a board as laid out in an image
+type square struct {
+	col, row int // from the top left corner of the image
+	fill     color.RGBA
+	piece    chess.Piece
+}
+
+// layout parses a FEN and places its squares for the options, returning the
+// size of a square in pixels
+func layout(fen string, opts Options) ([]square, int, error) {
+	fenOpt, err := chess.FEN(fen)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid FEN: %w", err)
+	}
+	board := chess.NewGame(fenOpt).Position().Board()
+
+	highlighted := make(map[chess.Square]bool, len(opts.Highlight))
+	for _, sq := range opts.Highlight {
+		if parsed, ok := parseSquare(sq); ok {
+			highlighted[parsed] = true
+		}
+	}
+
+	squares := make([]square, 0, 64)
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		file, rank := int(sq.File()), int(sq.Rank())
+		col, row := file, 7-rank
+		if opts.Flipped {
+			col, row = 7-file, rank
+		}
+
+		fill := lightSquare
+		if (file+rank)%2 == 0 {
+			fill = darkSquare
+		}
+		if highlighted[sq] {
+			fill = lightHighlight
+			if (file+rank)%2 == 0 {
+				fill = darkHighlight
+			}
+		}
+		squares = append(squares, square{col: col, row: row, fill: fill, piece: board.Piece(sq)})
+	}
+	return squares, squareSize(opts.Size), nil
+}
+
+// squareSize is the size of a square in pixels for a board of size pixels
+func squareSize(size int) int {
+	switch {
+	case size == 0:
+		size = DefaultSize
+	case size < MinSize:
+		size = MinSize
+	case size > MaxSize:
+		size = MaxSize
+	}
+	return size / 8
+}
+
+// parseSquare parses a square in algebraic notation, e.g. "e4"
+func parseSquare(sq string) (chess.Square, bool) {
+	if len(sq) != 2 || sq[0] < 'a' || sq[0] > 'h' || sq[1] < '1' || sq[1] > '8' {
+		return chess.NoSquare, false
+	}
+	return chess.Square(int(sq[1]-'1')*8 + int(sq[0]-'a')), true
+}
@@ -0,0 +1,92 @@
+package boardimage
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+const startingFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+func TestPNG(t *testing.T) {
+	data, err := PNG(startingFEN, Options{Highlight: []string{"e2", "e4"}})
+	if err != nil {
+		t.Fatalf("PNG failed: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a PNG: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != DefaultSize || size.Y != DefaultSize {
+		t.Fatalf("Expected a %dpx board, got %v", DefaultSize, size)
+	}
+
+	// e4 is the fifth file and fifth rank from the top, drawn empty and tinted
+	square := DefaultSize / 8
+	corner := func(col, row int) (uint32, uint32, uint32) {
+		r, g, b, _ := img.At(col*square+1, row*square+1).RGBA()
+		return r >> 8, g >> 8, b >> 8
+	}
+	if r, g, b := corner(4, 4); r != uint32(lightHighlight.R) || g != uint32(lightHighlight.G) || b != uint32(lightHighlight.B) {
+		t.Errorf("Expected e4 to be highlighted, got %d,%d,%d", r, g, b)
+	}
+	if r, g, b := corner(0, 7); r != uint32(darkSquare.R) || g != uint32(darkSquare.G) || b != uint32(darkSquare.B) {
+		t.Errorf("Expected a1 to be a dark square, got %d,%d,%d", r, g, b)
+	}
+
+	flipped, err := PNG(startingFEN, Options{Flipped: true})
+	if err != nil {
+		t.Fatalf("PNG failed: %v", err)
+	}
+	if bytes.Equal(flipped, data) {
+		t.Error("Expected the flipped board to differ")
+	}
+
+	if _, err := PNG("not a fen", Options{}); err == nil {
+		t.Error("Expected an invalid FEN to be rejected")
+	}
+}
+
+func TestPNGSize(t *testing.T) {
+	for _, tt := range []struct{ requested, drawn int }{
+		{160, 160},
+		{165, 160}, // rounded down to whole squares
+		{10, MinSize},
+		{5000, MaxSize},
+	} {
+		data, err := PNG(startingFEN, Options{Size: tt.requested})
+		if err != nil {
+			t.Fatalf("PNG failed: %v", err)
+		}
+		img, _ := png.Decode(bytes.NewReader(data))
+		if size := img.Bounds().Size(); size.X != tt.drawn || size.Y != tt.drawn {
+			t.Errorf("Expected a %dpx board for size %d, got %v", tt.drawn, tt.requested, size)
+		}
+	}
+}
+
+func TestSVG(t *testing.T) {
+	data, err := SVG(startingFEN, Options{Size: 240, Highlight: []string{"e4"}})
+	if err != nil {
+		t.Fatalf("SVG failed: %v", err)
+	}
+	svg := string(data)
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="240" height="240"`) || !strings.HasSuffix(svg, "</svg>") {
+		t.Errorf("Expected a 240px SVG document, got %.120s...", svg)
+	}
+	if squares := strings.Count(svg, "<rect "); squares != 64 {
+		t.Errorf("Expected 64 squares, got %d", squares)
+	}
+	if pieces := strings.Count(svg, "<circle "); pieces != 32 {
+		t.Errorf("Expected 32 pieces, got %d", pieces)
+	}
+	// e4 is drawn at the fifth column and row from the top left
+	if !strings.Contains(svg, `<rect x="240" y="240" width="60" height="60" fill="#f7ec74"/>`) {
+		t.Error("Expected e4 to be highlighted")
+	}
+
+	if _, err := SVG("not a fen", Options{}); err == nil {
+		t.Error("Expected an invalid FEN to be rejected")
+	}
+}
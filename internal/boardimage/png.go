@@ -0,0 +1,76 @@
+package boardimage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	"github.com/notnil/chess"
+)
+
+// PNG draws the position of a FEN as a PNG image
+func PNG(fen string, opts Options) ([]byte, error) {
+	squares, size, err := layout(fen, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 8*size, 8*size))
+	for _, sq := range squares {
+		x0, y0 := sq.col*size, sq.row*size
+		fillRect(img, x0, y0, size, size, sq.fill)
+		if sq.piece != chess.NoPiece {
+			drawPiece(img, x0, y0, size, sq.piece)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode board image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func fillRect(img *image.RGBA, x0, y0, w, h int, c color.RGBA) {
+	for y := y0; y < y0+h; y++ {
+		for x := x0; x < x0+w; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// drawPiece draws a piece centered in the square of size pixels at x0, y0
+func drawPiece(img *image.RGBA, x0, y0, size int, piece chess.Piece) {
+	fill, glyph := whitePieceFill, whitePieceGlyph
+	if piece.Color() == chess.Black {
+		fill, glyph = blackPieceFill, blackPieceGlyph
+	}
+
+	cx, cy := x0+size/2, y0+size/2
+	inner := size * 2 / 5
+	outer := inner + max(size/30, 1)
+	for y := y0; y < y0+size; y++ {
+		for x := x0; x < x0+size; x++ {
+			d := (x-cx)*(x-cx) + (y-cy)*(y-cy)
+			switch {
+			case d <= inner*inner:
+				img.SetRGBA(x, y, fill)
+			case d <= outer*outer:
+				img.SetRGBA(x, y, pieceOutline)
+			}
+		}
+	}
+
+	scale := max(size/15, 1)
+	bitmap := pieceGlyphs[piece.Type()]
+	gx, gy := cx-5*scale/2, cy-7*scale/2
+	for row, line := range bitmap {
+		for col, bit := range line {
+			if bit == '#' {
+				fillRect(img, gx+col*scale, gy+row*scale, scale, scale, glyph)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package boardimage
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+
+	"github.com/notnil/chess"
+)
+
+// svgSquare is the size of a square in the SVG's own coordinates; the image
+// is scaled to the requested size by its width and height
+const svgSquare = 60
+
+// SVG draws the position of a FEN as an SVG image
+func SVG(fen string, opts Options) ([]byte, error) {
+	squares, size, err := layout(fen, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		8*size, 8*size, 8*svgSquare, 8*svgSquare)
+	for _, sq := range squares {
+		x0, y0 := sq.col*svgSquare, sq.row*svgSquare
+		fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, x0, y0, svgSquare, svgSquare, hex(sq.fill))
+		if sq.piece != chess.NoPiece {
+			writePiece(&buf, x0, y0, sq.piece)
+		}
+	}
+	buf.WriteString(`</svg>`)
+	return buf.Bytes(), nil
+}
+
+// writePiece draws a piece centered in the square at x0, y0, matching the
+// PNG rendering
+func writePiece(buf *bytes.Buffer, x0, y0 int, piece chess.Piece) {
+	fill, glyph := whitePieceFill, whitePieceGlyph
+	if piece.Color() == chess.Black {
+		fill, glyph = blackPieceFill, blackPieceGlyph
+	}
+
+	cx, cy := x0+svgSquare/2, y0+svgSquare/2
+	fmt.Fprintf(buf, `<circle cx="%d" cy="%d" r="%d" fill="%s" stroke="%s" stroke-width="2"/>`,
+		cx, cy, svgSquare*2/5+1, hex(fill), hex(pieceOutline))
+
+	const scale = svgSquare / 15
+	gx, gy := cx-5*scale/2, cy-7*scale/2
+	fmt.Fprintf(buf, `<path fill="%s" d="`, hex(glyph))
+	for row, line := range pieceGlyphs[piece.Type()] {
+		for col, bit := range line {
+			if bit == '#' {
+				fmt.Fprintf(buf, "M%d %dh%dv%dh-%dz", gx+col*scale, gy+row*scale, scale, scale, scale)
+			}
+		}
+	}
+	buf.WriteString(`"/>`)
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
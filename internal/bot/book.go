@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// BookLine is an opening line in UCI notation from the starting position,
+// e.g. "e2e4 e7e5 g1f3". Weight is how often it's played relative to other
+// lines through the same positions.
+type BookLine struct {
+	Moves  string
+	Weight int
+}
+
+// BookMove is a move the book knows in a position
+type BookMove struct {
+	UCI    string
+	Weight int
+}
+
+// Book is an opening book. The engine plays its first moves from the book,
+// picking at random by weight, so games against it don't all start the same.
+type Book struct {
+	positions map[string][]BookMove
+}
+
+// defaultLines are common openings, weighted roughly by popularity
+var defaultLines = []BookLine{
+	{"e2e4 e7e5 g1f3 b8c6 f1b5 a7a6 b5a4 g8f6 e1g1 f8e7", 10},
+	{"e2e4 e7e5 g1f3 b8c6 f1c4 f8c5 c2c3 g8f6 d2d3", 8},
+	{"e2e4 e7e5 g1f3 b8c6 d2d4 e5d4 f3d4 g8f6", 4},
+	{"e2e4 e7e5 g1f3 g8f6 f3e5 d7d6 e5f3 f6e4", 3},
+	{"e2e4 c7c5 g1f3 d7d6 d2d4 c5d4 f3d4 g8f6 b1c3 a7a6", 10},
+	{"e2e4 c7c5 g1f3 b8c6 d2d4 c5d4 f3d4 g8f6 b1c3 e7e5", 5},
+	{"e2e4 c7c5 c2c3 g8f6 e4e5 f6d5 d2d4 c5d4", 3},
+	{"e2e4 e7e6 d2d4 d7d5 b1c3 g8f6 c1g5 f8e7", 6},
+	{"e2e4 e7e6 d2d4 d7d5 e4e5 c7c5 c2c3 b8c6", 4},
+	{"e2e4 c7c6 d2d4 d7d5 b1c3 d5e4 c3e4 c8f5", 6},
+	{"e2e4 d7d5 e4d5 d8d5 b1c3 d5a5", 2},
+	{"d2d4 d7d5 c2c4 e7e6 b1c3 g8f6 c1g5 f8e7", 8},
+	{"d2d4 d7d5 c2c4 c7c6 g1f3 g8f6 b1c3 d5c4", 6},
+	{"d2d4 d7d5 c2c4 d5c4 g1f3 g8f6 e2e3 e7e6", 3},
+	{"d2d4 d7d5 c1f4 g8f6 e2e3 c7c5 c2c3 b8c6", 4},
+	{"d2d4 g8f6 c2c4 g7g6 b1c3 f8g7 e2e4 d7d6 g1f3 e8g8", 7},
+	{"d2d4 g8f6 c2c4 g7g6 b1c3 d7d5 c4d5 f6d5 e2e4 d5c3 b2c3 f8g7", 4},
+	{"d2d4 g8f6 c2c4 e7e6 b1c3 f8b4 d1c2 e8g8", 7},
+	{"d2d4 g8f6 c2c4 e7e6 g1f3 b7b6 g2g3 c8b7", 4},
+	{"d2d4 f7f5 g2g3 g8f6 f1g2 e7e6 g1f3 f8e7", 2},
+	{"c2c4 e7e5 b1c3 g8f6 g1f3 b8c6 g2g3 d7d5", 5},
+	{"g1f3 d7d5 g2g3 g8f6 f1g2 e7e6 e1g1 f8e7", 4},
+}
+
+// DefaultBook returns a book of common openings
+func DefaultBook() *Book {
+	book, err := NewBook(defaultLines)
+	if err != nil {
+		panic(err)
+	}
+	return book
+}
+
+// NewBook builds a book from opening lines, checking that every move is legal.
+// Lines sharing a move add their weights together.
+func NewBook(lines []BookLine) (*Book, error) {
+	book := &Book{positions: make(map[string][]BookMove)}
+	for _, line := range lines {
+		if line.Weight < 1 {
+			return nil, fmt.Errorf("line %q must have a positive weight", line.Moves)
+		}
+		engine := chess.NewEngine()
+		for _, uci := range strings.Fields(line.Moves) {
+			if len(uci) < 4 {
+				return nil, fmt.Errorf("line %q has invalid move %q", line.Moves, uci)
+			}
+			key := PositionKey(engine.GetFEN())
+			if _, err := engine.MakeMoveUCI(uci); err != nil {
+				return nil, fmt.Errorf("line %q has illegal move %s: %w", line.Moves, uci, err)
+			}
+			book.add(key, uci, line.Weight)
+		}
+	}
+	return book, nil
+}
+
+func (b *Book) add(key, uci string, weight int) {
+	for i, move := range b.positions[key] {
+		if move.UCI == uci {
+			b.positions[key][i].Weight += weight
+			return
+		}
+	}
+	b.positions[key] = append(b.positions[key], BookMove{UCI: uci, Weight: weight})
+}
+
+// Moves returns the book moves for a position, or nil once out of the book
+func (b *Book) Moves(fen string) []BookMove {
+	return b.positions[PositionKey(fen)]
+}
+
+// Pick chooses a book move for a position at random in proportion to the
+// moves' weights. Moves in avoid are skipped unless nothing else is left.
+func (b *Book) Pick(fen string, rng *rand.Rand, avoid map[string]bool) (string, bool) {
+	moves := b.Moves(fen)
+	if len(moves) == 0 {
+		return "", false
+	}
+
+	candidates := make([]BookMove, 0, len(moves))
+	for _, move := range moves {
+		if !avoid[move.UCI] {
+			candidates = append(candidates, move)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = moves
+	}
+
+	total := 0
+	for _, move := range candidates {
+		total += move.Weight
+	}
+	n := rng.Intn(total)
+	for _, move := range candidates {
+		if n < move.Weight {
+			return move.UCI, true
+		}
+		n -= move.Weight
+	}
+	return candidates[len(candidates)-1].UCI, true
+}
+
+// PositionKey identifies a position by the placement, side to move, castling
+// and en passant fields of its FEN, so the same position is found whatever
+// the move counters
+func PositionKey(fen string) string {
+	fields := strings.Fields(fen)
+	if len(fields) > 4 {
+		fields = fields[:4]
+	}
+	return strings.Join(fields, " ")
+}
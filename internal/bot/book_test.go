@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+func TestDefaultBook(t *testing.T) {
+	book := DefaultBook()
+
+	weights := map[string]int{}
+	for _, move := range book.Moves(chess.StartingFEN) {
+		weights[move.UCI] = move.Weight
+	}
+	if len(weights) != 4 || weights["e2e4"] != 61 || weights["d2d4"] != 45 {
+		t.Errorf("Unexpected first moves: %v", weights)
+	}
+
+	// Lines through the same position share it, with their weights summed
+	afterE4E5 := "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2"
+	if moves := book.Moves(afterE4E5); len(moves) != 1 || moves[0].UCI != "g1f3" || moves[0].Weight != 25 {
+		t.Errorf("Expected 2. Nf3 from four lines, got %v", moves)
+	}
+	if moves := book.Moves("8/8/8/4k3/8/8/8/4K3 w - - 0 1"); moves != nil {
+		t.Errorf("Expected no book moves out of the book, got %v", moves)
+	}
+}
+
+func TestNewBookRejectsIllegalLines(t *testing.T) {
+	for _, line := range []BookLine{
+		{"e2e4 e2e4", 1},
+		{"e2e4 e7", 1},
+		{"e2e4", 0},
+	} {
+		if _, err := NewBook([]BookLine{line}); err == nil {
+			t.Errorf("Expected %+v to be rejected", line)
+		}
+	}
+}
+
+func TestPickFollowsWeights(t *testing.T) {
+	book, err := NewBook([]BookLine{{"e2e4", 3}, {"d2d4", 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		uci, ok := book.Pick(chess.StartingFEN, rng, nil)
+		if !ok {
+			t.Fatal("Expected a book move")
+		}
+		counts[uci]++
+	}
+	if counts["e2e4"] < 700 || counts["e2e4"] > 800 {
+		t.Errorf("Expected e4 about three times as often as d4, got %v", counts)
+	}
+}
+
+func TestPickAvoidsRecentMoves(t *testing.T) {
+	book, _ := NewBook([]BookLine{{"e2e4", 100}, {"d2d4", 1}})
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20; i++ {
+		if uci, _ := book.Pick(chess.StartingFEN, rng, map[string]bool{"e2e4": true}); uci != "d2d4" {
+			t.Fatalf("Expected the avoided move to be skipped, got %s", uci)
+		}
+	}
+	// With every move avoided, any book move will do
+	if uci, ok := book.Pick(chess.StartingFEN, rng, map[string]bool{"e2e4": true, "d2d4": true}); !ok || uci == "" {
+		t.Errorf("Expected a move when all are avoided, got %q", uci)
+	}
+}
+
+func TestPositionKeyIgnoresMoveCounters(t *testing.T) {
+	a := PositionKey("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	b := PositionKey("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 4 3")
+	if a != b {
+		t.Errorf("Expected the same key, got %q and %q", a, b)
+	}
+}
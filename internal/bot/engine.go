@@ -0,0 +1,386 @@
+// Package bot plays moves with an external UCI chess engine such as
+// Stockfish, so people can play against the computer.
+package bot
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxLevel is the strongest level; levels are numbered from 1
+const MaxLevel = 8
+
+// ErrNoMove is returned when the engine has no legal move in the position
+var ErrNoMove = errors.New("engine found no legal move")
+
+// Level is how strongly the engine plays
+type Level struct {
+	Skill     int           // UCI "Skill Level" option, 0-20
+	Depth     int           // search depth limit; 0 for none
+	MoveTime  time.Duration // time to think per move
+	BookMoves int           // how many of its first moves may come from the opening book
+}
+
+// levels maps each level to a skill, depth limit and opening book depth,
+// weakest first
+var levels = [MaxLevel]struct{ skill, depth, bookMoves int }{
+	{0, 1, 2}, {3, 2, 2}, {6, 3, 3}, {9, 4, 4}, {11, 6, 5}, {14, 8, 6}, {17, 12, 8}, {20, 0, 10},
+}
+
+// LevelSettings returns the engine settings for a level. The strongest level
+// thinks for thinkTime per move and weaker levels proportionally less.
+func LevelSettings(level int, thinkTime time.Duration) (Level, error) {
+	if level < 1 || level > MaxLevel {
+		return Level{}, fmt.Errorf("level must be between 1 and %d", MaxLevel)
+	}
+	settings := levels[level-1]
+	return Level{
+		Skill:     settings.skill,
+		Depth:     settings.depth,
+		MoveTime:  thinkTime * time.Duration(level) / MaxLevel,
+		BookMoves: settings.bookMoves,
+	}, nil
+}
+
+// Engine is a UCI engine process. It is started on first use, searches one
+// position at a time, and is restarted if it fails.
+type Engine struct {
+	path string
+	args []string
+
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+}
+
+// NewEngine creates an engine that runs the binary at path
+func NewEngine(path string, args ...string) *Engine {
+	return &Engine{path: path, args: args}
+}
+
+// BestMove searches a position and returns the engine's move in UCI
+// notation, e.g. "e2e4" or "e7e8q"
+func (e *Engine) BestMove(ctx context.Context, fen string, level Level) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	move, err := e.search(ctx, fen, level)
+	if err != nil && !errors.Is(err, ErrNoMove) {
+		// The engine is in an unknown state; start afresh next time
+		e.stop()
+	}
+	return move, err
+}
+
+func (e *Engine) search(ctx context.Context, fen string, level Level) (string, error) {
+	if e.cmd == nil {
+		if err := e.start(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	goCmd := "go movetime " + strconv.FormatInt(level.MoveTime.Milliseconds(), 10)
+	if level.Depth > 0 {
+		goCmd += " depth " + strconv.Itoa(level.Depth)
+	}
+	err := e.send(
+		"ucinewgame",
+		"setoption name Skill Level value "+strconv.Itoa(level.Skill),
+		"isready",
+	)
+	if err != nil {
+		return "", err
+	}
+	if _, err := e.waitFor(ctx, "readyok"); err != nil {
+		return "", err
+	}
+	if err := e.send("position fen "+fen, goCmd); err != nil {
+		return "", err
+	}
+
+	line, err := e.waitFor(ctx, "bestmove")
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[1] == "(none)" || fields[1] == "0000" {
+		return "", ErrNoMove
+	}
+	return fields[1], nil
+}
+
+// MateSearchTime bounds how long FindMate searches one position
+const MateSearchTime = time.Second
+
+// FindMate asks the engine for a forced checkmate by the side to move in at
+// most maxMoves of its moves, giving up after MateSearchTime. It returns the
+// mating line in UCI notation, ending with the mating move, or nil if the
+// engine found none.
+func (e *Engine) FindMate(ctx context.Context, fen string, maxMoves int) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	line, err := e.searchMate(ctx, fen, maxMoves)
+	if err != nil {
+		e.stop()
+	}
+	return line, err
+}
+
+func (e *Engine) searchMate(ctx context.Context, fen string, maxMoves int) ([]string, error) {
+	if e.cmd == nil {
+		if err := e.start(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	err := e.send(
+		"ucinewgame",
+		"setoption name Skill Level value 20",
+		"isready",
+	)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := e.waitFor(ctx, "readyok"); err != nil {
+		return nil, err
+	}
+	if err := e.send("position fen "+fen, "go mate "+strconv.Itoa(maxMoves)+" movetime "+strconv.FormatInt(MateSearchTime.Milliseconds(), 10)); err != nil {
+		return nil, err
+	}
+
+	// The last info line before bestmove has the deepest search
+	var line []string
+	for {
+		output, err := e.waitFor(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(output, "bestmove") {
+			return line, nil
+		}
+		if !strings.HasPrefix(output, "info ") || !strings.Contains(output, " pv ") {
+			continue
+		}
+		line = nil
+		if mate, pv := parseMateInfo(output); mate > 0 && mate <= maxMoves && len(pv) >= 2*mate-1 {
+			line = pv[:2*mate-1]
+		}
+	}
+}
+
+// parseMateInfo reads the mate score and principal variation from a UCI info
+// line. The score is 0 unless the side to move mates, e.g. 2 for
+// "score mate 2".
+func parseMateInfo(info string) (int, []string) {
+	fields := strings.Fields(info)
+	mate := 0
+	for i, field := range fields {
+		switch {
+		case field == "score" && i+2 < len(fields) && fields[i+1] == "mate":
+			mate, _ = strconv.Atoi(fields[i+2])
+		case field == "pv":
+			return mate, fields[i+1:]
+		}
+	}
+	return mate, nil
+}
+
+// AnalysisTime bounds how long Analyze searches one position
+const AnalysisTime = time.Second
+
+// Score is the engine's evaluation of a position, from the point of view of
+// the side to move
+type Score struct {
+	Centipawns int    // when Mate is 0
+	Mate       int    // moves to mate; negative when the side to move is mated
+	BestMove   string // UCI notation; empty when there are no legal moves
+}
+
+// Analyze evaluates a position at full strength, searching to depth - or
+// without a depth limit if it's 0 - for at most AnalysisTime
+func (e *Engine) Analyze(ctx context.Context, fen string, depth int) (*Score, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	score, err := e.analyze(ctx, fen, depth)
+	if err != nil {
+		e.stop()
+	}
+	return score, err
+}
+
+func (e *Engine) analyze(ctx context.Context, fen string, depth int) (*Score, error) {
+	if e.cmd == nil {
+		if err := e.start(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	goCmd := "go movetime " + strconv.FormatInt(AnalysisTime.Milliseconds(), 10)
+	if depth > 0 {
+		goCmd += " depth " + strconv.Itoa(depth)
+	}
+	err := e.send(
+		"ucinewgame",
+		"setoption name Skill Level value 20",
+		"isready",
+	)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := e.waitFor(ctx, "readyok"); err != nil {
+		return nil, err
+	}
+	if err := e.send("position fen "+fen, goCmd); err != nil {
+		return nil, err
+	}
+
+	// The last scored info line before bestmove has the deepest search
+	score := &Score{}
+	for {
+		output, err := e.waitFor(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(output, "bestmove") {
+			if fields := strings.Fields(output); len(fields) >= 2 && fields[1] != "(none)" && fields[1] != "0000" {
+				score.BestMove = fields[1]
+			}
+			return score, nil
+		}
+		if !strings.HasPrefix(output, "info ") {
+			continue
+		}
+		if centipawns, mate, ok := parseScoreInfo(output); ok {
+			score.Centipawns, score.Mate = centipawns, mate
+		}
+	}
+}
+
+// parseScoreInfo reads the score from a UCI info line, e.g. 35 for "score cp
+// 35" or a mate of -2 for "score mate -2". Lower and upper bounds from an
+// interrupted search aren't reported.
+func parseScoreInfo(info string) (centipawns, mate int, ok bool) {
+	fields := strings.Fields(info)
+	for i, field := range fields {
+		if field != "score" || i+2 >= len(fields) {
+			continue
+		}
+		if i+3 < len(fields) && (fields[i+3] == "lowerbound" || fields[i+3] == "upperbound") {
+			return 0, 0, false
+		}
+		value, err := strconv.Atoi(fields[i+2])
+		if err != nil {
+			return 0, 0, false
+		}
+		switch fields[i+1] {
+		case "cp":
+			return value, 0, true
+		case "mate":
+			return 0, value, true
+		}
+	}
+	return 0, 0, false
+}
+
+// start launches the engine and completes the UCI handshake
+func (e *Engine) start(ctx context.Context) error {
+	cmd := exec.Command(e.path, e.args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open engine stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open engine stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start engine %s: %w", e.path, err)
+	}
+
+	lines := make(chan string, 64)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	e.cmd, e.stdin, e.lines = cmd, stdin, lines
+	if err := e.send("uci"); err != nil {
+		return err
+	}
+	if _, err := e.waitFor(ctx, "uciok"); err != nil {
+		return fmt.Errorf("engine did not complete UCI handshake: %w", err)
+	}
+	return nil
+}
+
+func (e *Engine) send(commands ...string) error {
+	for _, command := range commands {
+		if _, err := io.WriteString(e.stdin, command+"\n"); err != nil {
+			return fmt.Errorf("failed to write to engine: %w", err)
+		}
+	}
+	return nil
+}
+
+// waitFor reads output until a line starting with prefix
+func (e *Engine) waitFor(ctx context.Context, prefix string) (string, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case line, ok := <-e.lines:
+			if !ok {
+				return "", fmt.Errorf("engine exited while waiting for %s", prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line, nil
+			}
+		}
+	}
+}
+
+// stop kills the engine process
+func (e *Engine) stop() {
+	if e.cmd == nil {
+		return
+	}
+	e.stdin.Close()
+	_ = e.cmd.Process.Kill()
+	_ = e.cmd.Wait()
+	e.cmd, e.stdin, e.lines = nil, nil, nil
+}
+
+// Close asks the engine to quit and waits for it to exit
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cmd == nil {
+		return nil
+	}
+	_ = e.send("quit")
+	done := make(chan error, 1)
+	go func() { done <- e.cmd.Wait() }()
+	select {
+	case err := <-done:
+		e.cmd, e.stdin, e.lines = nil, nil, nil
+		return err
+	case <-time.After(2 * time.Second):
+		e.stop()
+		return nil
+	}
+}
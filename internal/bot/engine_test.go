@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFakeEngine isn't a real test: it's a minimal UCI engine that the other
+// tests run as a subprocess
+func TestFakeEngine(t *testing.T) {
+	if os.Getenv("ATCHESS_FAKE_UCI_ENGINE") != "1" {
+		t.Skip("only runs as a subprocess")
+	}
+
+	var fen string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "uci":
+			fmt.Println("id name Fake")
+			fmt.Println("uciok")
+		case line == "isready":
+			fmt.Println("readyok")
+		case strings.HasPrefix(line, "position fen "):
+			fen = strings.TrimPrefix(line, "position fen ")
+		case strings.HasPrefix(line, "go mate "):
+			if strings.Contains(fen, "4p2Q") {
+				fmt.Println("info depth 2 score mate 1 nodes 40 pv h5f7")
+				fmt.Println("bestmove h5f7")
+			} else {
+				fmt.Println("info depth 4 score cp 20 nodes 900 pv e2e4 e7e5")
+				fmt.Println("bestmove e2e4")
+			}
+		case strings.HasPrefix(line, "go "):
+			fmt.Println("info depth 1 score cp 20")
+			switch {
+			case strings.Contains(fen, "mated"):
+				fmt.Println("bestmove (none)")
+			case strings.Contains(fen, " w "):
+				fmt.Println("bestmove e2e4")
+			default:
+				fmt.Println("bestmove e7e5")
+			}
+		case line == "quit":
+			os.Exit(0)
+		}
+	}
+	os.Exit(0)
+}
+
+func newFakeEngine(t *testing.T) *Engine {
+	t.Setenv("ATCHESS_FAKE_UCI_ENGINE", "1")
+	engine := NewEngine(os.Args[0], "-test.run=^TestFakeEngine$")
+	t.Cleanup(func() { engine.Close() })
+	return engine
+}
+
+func TestBestMove(t *testing.T) {
+	engine := newFakeEngine(t)
+	level, _ := LevelSettings(3, time.Second)
+	ctx := context.Background()
+
+	move, err := engine.BestMove(ctx, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", level)
+	if err != nil || move != "e2e4" {
+		t.Fatalf("Expected e2e4, got %q (%v)", move, err)
+	}
+	// The same process answers the next search
+	move, err = engine.BestMove(ctx, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1", level)
+	if err != nil || move != "e7e5" {
+		t.Fatalf("Expected e7e5, got %q (%v)", move, err)
+	}
+
+	if _, err := engine.BestMove(ctx, "mated", level); !errors.Is(err, ErrNoMove) {
+		t.Errorf("Expected ErrNoMove, got %v", err)
+	}
+
+	// A cancelled search kills the process; the next one starts a new one
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := engine.BestMove(cancelled, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", level); err == nil {
+		t.Error("Expected an error for a cancelled search")
+	}
+	if move, err := engine.BestMove(ctx, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", level); err != nil || move != "e2e4" {
+		t.Errorf("Expected the engine to restart, got %q (%v)", move, err)
+	}
+}
+
+func TestFindMate(t *testing.T) {
+	engine := newFakeEngine(t)
+	ctx := context.Background()
+
+	line, err := engine.FindMate(ctx, "r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4", 2)
+	if err != nil || len(line) != 1 || line[0] != "h5f7" {
+		t.Fatalf("Expected mate with h5f7, got %v (%v)", line, err)
+	}
+	line, err = engine.FindMate(ctx, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 2)
+	if err != nil || line != nil {
+		t.Errorf("Expected no mate, got %v (%v)", line, err)
+	}
+}
+
+func TestParseMateInfo(t *testing.T) {
+	mate, pv := parseMateInfo("info depth 9 seldepth 4 multipv 1 score mate 2 nodes 1200 pv d5f6 g7f6 c4f7")
+	if mate != 2 || len(pv) != 3 || pv[2] != "c4f7" {
+		t.Errorf("Unexpected mate %d pv %v", mate, pv)
+	}
+	if mate, _ := parseMateInfo("info depth 9 score mate -3 pv e2e4"); mate != -3 {
+		t.Errorf("Expected to be mated in 3, got %d", mate)
+	}
+	if mate, _ := parseMateInfo("info depth 9 score cp 35 pv e2e4"); mate != 0 {
+		t.Errorf("Expected no mate score, got %d", mate)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	engine := newFakeEngine(t)
+
+	score, err := engine.Analyze(context.Background(), "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1", 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score.Centipawns != 20 || score.Mate != 0 || score.BestMove != "e7e5" {
+		t.Errorf("Expected +0.20 with e7e5, got %+v", score)
+	}
+}
+
+func TestParseScoreInfo(t *testing.T) {
+	tests := []struct {
+		info             string
+		centipawns, mate int
+		ok               bool
+	}{
+		{"info depth 12 seldepth 18 score cp -35 nodes 90000 pv e7e5", -35, 0, true},
+		{"info depth 9 score mate 2 pv d5f6 g7f6 c4f7", 0, 2, true},
+		{"info depth 9 score mate -3 pv e2e4", 0, -3, true},
+		{"info depth 14 score cp 80 lowerbound nodes 120000", 0, 0, false},
+		{"info string NNUE evaluation enabled", 0, 0, false},
+	}
+	for _, tt := range tests {
+		centipawns, mate, ok := parseScoreInfo(tt.info)
+		if centipawns != tt.centipawns || mate != tt.mate || ok != tt.ok {
+			t.Errorf("parseScoreInfo(%q) = %d, %d, %v; want %d, %d, %v", tt.info, centipawns, mate, ok, tt.centipawns, tt.mate, tt.ok)
+		}
+	}
+}
+
+func TestMissingEngine(t *testing.T) {
+	engine := NewEngine("/nonexistent/stockfish")
+	level, _ := LevelSettings(1, time.Second)
+	if _, err := engine.BestMove(context.Background(), "8/8/8/8/8/8/8/8 w - - 0 1", level); err == nil {
+		t.Error("Expected an error when the engine binary is missing")
+	}
+}
+
+func TestLevelSettings(t *testing.T) {
+	weakest, err := LevelSettings(1, 8*time.Second)
+	if err != nil || weakest.Skill != 0 || weakest.Depth != 1 || weakest.MoveTime != time.Second {
+		t.Errorf("Unexpected level 1 settings: %+v (%v)", weakest, err)
+	}
+	strongest, _ := LevelSettings(MaxLevel, 8*time.Second)
+	if strongest.Skill != 20 || strongest.Depth != 0 || strongest.MoveTime != 8*time.Second {
+		t.Errorf("Unexpected level %d settings: %+v", MaxLevel, strongest)
+	}
+	for _, level := range []int{0, MaxLevel + 1} {
+		if _, err := LevelSettings(level, time.Second); err == nil {
+			t.Errorf("Expected an error for level %d", level)
+		}
+	}
+}
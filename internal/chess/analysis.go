@@ -0,0 +1,131 @@
+package chess
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/notnil/chess"
+)
+
+// mateScore is the centipawn score assigned to a forced checkmate
+const mateScore = 100000
+
+// Evaluation is a shallow engine assessment of a position
+type Evaluation struct {
+	FEN      string `json:"fen"`
+	Depth    int    `json:"depth"`
+	Score    int    `json:"score"` // centipawns, positive = white advantage
+	Mate     bool   `json:"mate"`
+	BestMove string `json:"bestMove,omitempty"` // e.g. "e2e4"
+	BestSAN  string `json:"bestSan,omitempty"`
+}
+
+// Evaluate searches the current position to the given depth using material
+// balance and returns the score and best move. It is intended for quick
+// spectator commentary, not strong play.
+func (e *Engine) Evaluate(depth int) (*Evaluation, error) {
+	if depth < 1 {
+		return nil, fmt.Errorf("depth must be at least 1, got %d", depth)
+	}
+
+	position := e.game.Position()
+	eval := &Evaluation{
+		FEN:   position.String(),
+		Depth: depth,
+	}
+
+	moves := orderMoves(position.ValidMoves())
+	if len(moves) == 0 {
+		score := terminalScore(position, 0)
+		eval.Score = whitePerspective(position, score)
+		eval.Mate = position.Status() == chess.Checkmate
+		return eval, nil
+	}
+
+	best := moves[0]
+	alpha, beta := -mateScore-1, mateScore+1
+	for _, move := range moves {
+		score := -negamax(position.Update(move), depth-1, -beta, -alpha, 1)
+		if score > alpha {
+			alpha = score
+			best = move
+		}
+	}
+
+	eval.Score = whitePerspective(position, alpha)
+	eval.Mate = alpha >= mateScore-depth || alpha <= -mateScore+depth
+	eval.BestMove = best.String()
+	eval.BestSAN = chess.AlgebraicNotation{}.Encode(position, best)
+	return eval, nil
+}
+
+// negamax returns the score of a position from the side to move's perspective
+func negamax(position *chess.Position, depth, alpha, beta, ply int) int {
+	moves := position.ValidMoves()
+	if len(moves) == 0 {
+		return terminalScore(position, ply)
+	}
+	if depth == 0 {
+		return materialScore(position)
+	}
+
+	for _, move := range orderMoves(moves) {
+		score := -negamax(position.Update(move), depth-1, -beta, -alpha, ply+1)
+		if score >= beta {
+			return beta
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	return alpha
+}
+
+// terminalScore scores a position with no legal moves; quicker mates score higher
+func terminalScore(position *chess.Position, ply int) int {
+	if position.Status() == chess.Checkmate {
+		return -mateScore + ply
+	}
+	return 0
+}
+
+// materialScore returns the material balance in centipawns for the side to move
+func materialScore(position *chess.Position) int {
+	score := 0
+	for _, piece := range position.Board().SquareMap() {
+		value := getPieceValue(piece.Type()) * 100
+		if piece.Color() == position.Turn() {
+			score += value
+		} else {
+			score -= value
+		}
+	}
+	return score
+}
+
+// whitePerspective converts a side-to-move score to a white-relative score
+func whitePerspective(position *chess.Position, score int) int {
+	if position.Turn() == chess.Black {
+		return -score
+	}
+	return score
+}
+
+// orderMoves searches captures and promotions first so alpha-beta prunes more
+func orderMoves(moves []*chess.Move) []*chess.Move {
+	sort.SliceStable(moves, func(i, j int) bool {
+		return movePriority(moves[i]) > movePriority(moves[j])
+	})
+	return moves
+}
+
+func movePriority(move *chess.Move) int {
+	priority := 0
+	if move.HasTag(chess.Capture) || move.HasTag(chess.EnPassant) {
+		priority += 2
+	}
+	if move.Promo() != chess.NoPieceType {
+		priority++
+	}
+	return priority
+}
@@ -0,0 +1,78 @@
+package chess
+
+import (
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name         string
+		fen          string
+		depth        int
+		expectedBest string
+		expectMate   bool
+		scoreSign    int // 1 = white better, -1 = black better, 0 = equal
+	}{
+		{
+			name:      "Starting position is level",
+			fen:       "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+			depth:     2,
+			scoreSign: 0,
+		},
+		{
+			name:         "Hanging queen is taken",
+			fen:          "4k3/8/8/3n4/8/4Q3/8/4K3 b - - 0 1",
+			depth:        2,
+			expectedBest: "d5e3",
+			scoreSign:    -1,
+		},
+		{
+			name:         "Mate in one is found",
+			fen:          "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1",
+			depth:        2,
+			expectedBest: "a1a8",
+			expectMate:   true,
+			scoreSign:    1,
+		},
+		{
+			name:       "Checkmated side has no move",
+			fen:        "R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1",
+			depth:      2,
+			expectMate: true,
+			scoreSign:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := NewEngineFromFEN(tt.fen)
+			if err != nil {
+				t.Fatalf("Failed to create engine: %v", err)
+			}
+
+			eval, err := engine.Evaluate(tt.depth)
+			if err != nil {
+				t.Fatalf("Evaluate failed: %v", err)
+			}
+
+			if tt.expectedBest != "" && eval.BestMove != tt.expectedBest {
+				t.Errorf("Expected best move %s, got %s", tt.expectedBest, eval.BestMove)
+			}
+			if eval.Mate != tt.expectMate {
+				t.Errorf("Expected mate=%v, got %v (score %d)", tt.expectMate, eval.Mate, eval.Score)
+			}
+
+			switch {
+			case tt.scoreSign > 0 && eval.Score <= 0,
+				tt.scoreSign < 0 && eval.Score >= 0,
+				tt.scoreSign == 0 && eval.Score != 0:
+				t.Errorf("Unexpected score %d for %s", eval.Score, tt.name)
+			}
+		})
+	}
+
+	engine := NewEngine()
+	if _, err := engine.Evaluate(0); err == nil {
+		t.Error("Expected error for depth 0")
+	}
+}
@@ -0,0 +1,104 @@
+package chess
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// operaGame is Morphy's Opera Game (Paris, 1858), a complete 33-ply game
+// with captures, castling and mate
+var operaGame = []PGNMove{
+	{From: "e2", To: "e4"}, {From: "e7", To: "e5"},
+	{From: "g1", To: "f3"}, {From: "d7", To: "d6"},
+	{From: "d2", To: "d4"}, {From: "c8", To: "g4"},
+	{From: "d4", To: "e5"}, {From: "g4", To: "f3"},
+	{From: "d1", To: "f3"}, {From: "d6", To: "e5"},
+	{From: "f1", To: "c4"}, {From: "g8", To: "f6"},
+	{From: "f3", To: "b3"}, {From: "d8", To: "e7"},
+	{From: "b1", To: "c3"}, {From: "c7", To: "c6"},
+	{From: "c1", To: "g5"}, {From: "b7", To: "b5"},
+	{From: "c3", To: "b5"}, {From: "c6", To: "b5"},
+	{From: "c4", To: "b5"}, {From: "b8", To: "d7"},
+	{From: "e1", To: "c1"}, {From: "a8", To: "d8"},
+	{From: "d1", To: "d7"}, {From: "d8", To: "d7"},
+	{From: "h1", To: "d1"}, {From: "e7", To: "e6"},
+	{From: "b5", To: "d7"}, {From: "f6", To: "d7"},
+	{From: "b3", To: "b8"}, {From: "d7", To: "b8"},
+	{From: "d1", To: "d8"},
+}
+
+// positionsBefore returns the FEN before each of a game's moves
+func positionsBefore(b *testing.B, moves []PGNMove) []string {
+	b.Helper()
+	engine := NewEngine()
+	fens := make([]string, len(moves))
+	for i, move := range moves {
+		fens[i] = engine.GetFEN()
+		if _, err := engine.MakeMove(move.From, move.To, ParsePromotion(move.Promotion)); err != nil {
+			b.Fatalf("Move %d (%s%s) is illegal: %v", i+1, move.From, move.To, err)
+		}
+	}
+	return fens
+}
+
+// BenchmarkMovePipeline measures the work of one move request: loading the
+// stored position, validating and making the move, and encoding the result
+func BenchmarkMovePipeline(b *testing.B) {
+	fens := positionsBefore(b, operaGame)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ply := i % len(operaGame)
+		engine, err := NewEngineFromFEN(fens[ply])
+		if err != nil {
+			b.Fatal(err)
+		}
+		result, err := engine.MakeMove(operaGame[ply].From, operaGame[ply].To, ParsePromotion(operaGame[ply].Promotion))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := json.Marshal(result); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParseFEN measures loading a stored position on its own
+func BenchmarkParseFEN(b *testing.B) {
+	fens := positionsBefore(b, operaGame)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewEngineFromFEN(fens[i%len(fens)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkReplayGame measures validating a full game's history from the
+// starting position, as is done whenever a game's moves are listed
+func BenchmarkReplayGame(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		engine := NewEngine()
+		for _, move := range operaGame {
+			if _, err := engine.MakeMove(move.From, move.To, ParsePromotion(move.Promotion)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkExportPGN measures replaying and formatting a full game as PGN
+func BenchmarkExportPGN(b *testing.B) {
+	tags := PGNTags{White: "did:plc:white", Black: "did:plc:black", Result: ResultForStatus(StatusWhiteWon)}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ExportPGN("", tags, operaGame); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -7,7 +7,8 @@ import (
 )
 
 type Engine struct {
-	game *chess.Game
+	game    *chess.Game
+	variant Variant // nil for standard chess
 }
 
 func NewEngine() *Engine {
@@ -36,7 +37,7 @@ func (e *Engine) MakeMove(from, to string, promotion chess.PieceType) (*MoveResu
 	}
 	
 	// Validate move
-	validMoves := e.game.ValidMoves()
+	validMoves := e.validMoves()
 	var validMove *chess.Move
 	for _, vm := range validMoves {
 		if vm.S1() == fromSquare && vm.S2() == toSquare && vm.Promo() == promotion {
@@ -65,9 +66,10 @@ func (e *Engine) MakeMove(from, to string, promotion chess.PieceType) (*MoveResu
 	isCheck := len(san) > 0 && (san[len(san)-1] == '+' || san[len(san)-1] == '#')
 	isCheckmate := len(san) > 0 && san[len(san)-1] == '#'
 	
-	// Check for automatic draws after the move
-	isDraw := e.game.Outcome() == chess.Draw
-	gameOver := e.game.Outcome() != chess.NoOutcome
+	// Check for automatic draws, and the variant's own results, after the move
+	status := e.GetStatus()
+	isDraw := status == StatusDraw
+	gameOver := status != StatusActive
 	
 	result := &MoveResult{
 		From:      from,
@@ -78,16 +80,24 @@ func (e *Engine) MakeMove(from, to string, promotion chess.PieceType) (*MoveResu
 		Checkmate: isCheckmate,
 		Draw:      isDraw,
 		GameOver:  gameOver,
+		Status:    status,
 	}
 	
-	// Set the result string based on the outcome
-	if e.game.Outcome() != chess.NoOutcome {
+	if promotion != chess.NoPieceType {
+		result.Promotion = promotion.String()
+	}
+	
+	// Set the result string based on the outcome, unless the variant decided it
+	if e.game.Outcome() != chess.NoOutcome && status == e.standardStatus() {
 		result.Result = e.game.Outcome().String()
 		
 		// Add draw reason to result if it's a draw
 		if isDraw && e.GetDrawReason() != "" {
 			result.Result = result.Result + " - " + e.GetDrawReason()
 		}
+	} else if gameOver {
+		// Ended by a rule of the variant
+		result.Result = ResultForStatus(status)
 	}
 	
 	return result, nil
@@ -101,7 +111,14 @@ func (e *Engine) GetPGN() string {
 	return e.game.String()
 }
 
+// GetStatus returns the result of the game in the current position under the
+// engine's variant
 func (e *Engine) GetStatus() GameStatus {
+	return e.Variant().Outcome(e.game.Position(), e.standardStatus())
+}
+
+// standardStatus is the status of the game under standard rules
+func (e *Engine) standardStatus() GameStatus {
 	switch e.game.Outcome() {
 	case chess.WhiteWon:
 		return StatusWhiteWon
@@ -109,9 +126,8 @@ func (e *Engine) GetStatus() GameStatus {
 		return StatusBlackWon
 	case chess.Draw:
 		return StatusDraw
-	default:
-		return StatusActive
 	}
+	return StatusActive
 }
 
 func (e *Engine) GetActiveColor() string {
@@ -0,0 +1,70 @@
+package chess
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// MaxExtensionSize bounds the encoded size of one extension's metadata
+const MaxExtensionSize = 4096
+
+// reservedNamespace is ATChess's own namespace, which extensions can't use
+const reservedNamespace = "app.atchess."
+
+// Extensions is metadata other applications attach to a game record, e.g. a
+// streaming overlay's layout or a club's tags. Each entry is keyed by the
+// owning app's namespace, an NSID such as "com.example.overlay", and holds a
+// JSON object ATChess stores and serves without interpreting.
+type Extensions map[string]map[string]interface{}
+
+// ParseExtensions reads the extensions field of a game record. Entries that
+// aren't objects under a valid namespace, or that are too large, are dropped
+// so one bad entry doesn't hide the rest. It returns nil if none are valid.
+func ParseExtensions(value interface{}) Extensions {
+	raw, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var extensions Extensions
+	for namespace, data := range raw {
+		object, ok := data.(map[string]interface{})
+		if !ok || !ValidExtensionNamespace(namespace) {
+			continue
+		}
+		if encoded, err := json.Marshal(object); err != nil || len(encoded) > MaxExtensionSize {
+			continue
+		}
+		if extensions == nil {
+			extensions = make(Extensions)
+		}
+		extensions[namespace] = object
+	}
+	return extensions
+}
+
+// ValidExtensionNamespace reports whether namespace is an NSID outside
+// ATChess's own app.atchess namespace: at least three dot-separated segments
+// of letters, digits and hyphens, the last starting with a letter
+func ValidExtensionNamespace(namespace string) bool {
+	if len(namespace) > 317 || strings.HasPrefix(strings.ToLower(namespace), reservedNamespace) {
+		return false
+	}
+
+	segments := strings.Split(namespace, ".")
+	if len(segments) < 3 {
+		return false
+	}
+	for _, segment := range segments {
+		if segment == "" || len(segment) > 63 || segment[0] == '-' || segment[len(segment)-1] == '-' {
+			return false
+		}
+		for _, r := range segment {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	name := segments[len(segments)-1]
+	return name[0] >= 'a' && name[0] <= 'z' || name[0] >= 'A' && name[0] <= 'Z'
+}
@@ -0,0 +1,54 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseExtensionsKeepsValidEntries(t *testing.T) {
+	extensions := ParseExtensions(map[string]interface{}{
+		"com.example.overlay": map[string]interface{}{"layout": "compact"},
+		"club.chess.tags":     map[string]interface{}{"tags": []interface{}{"league"}},
+		"app.atchess.game":    map[string]interface{}{"status": "white_won"},
+		"notannsid":           map[string]interface{}{"a": 1},
+		"com.example.string":  "not an object",
+		"com.example.big":     map[string]interface{}{"data": strings.Repeat("x", MaxExtensionSize)},
+	})
+
+	if len(extensions) != 2 {
+		t.Fatalf("Expected 2 valid extensions, got %v", extensions)
+	}
+	if extensions["com.example.overlay"]["layout"] != "compact" {
+		t.Errorf("Expected the overlay metadata to be kept, got %v", extensions["com.example.overlay"])
+	}
+	if _, ok := extensions["club.chess.tags"]; !ok {
+		t.Error("Expected the club tags to be kept")
+	}
+}
+
+func TestParseExtensionsWithoutValidEntries(t *testing.T) {
+	for _, value := range []interface{}{nil, "text", map[string]interface{}{"bad": map[string]interface{}{}}} {
+		if extensions := ParseExtensions(value); extensions != nil {
+			t.Errorf("Expected nil for %v, got %v", value, extensions)
+		}
+	}
+}
+
+func TestValidExtensionNamespace(t *testing.T) {
+	tests := map[string]bool{
+		"com.example.overlay":   true,
+		"tv.stream-deck.scene2": true,
+		"com.example":           false,
+		"com..example.overlay":  false,
+		"com.example.-overlay":  false,
+		"com.example.2overlay":  false,
+		"com.example.over_lay":  false,
+		"app.atchess.overlay":   false,
+		"App.ATChess.overlay":   false,
+	}
+	for namespace, want := range tests {
+		if got := ValidExtensionNamespace(namespace); got != want {
+			t.Errorf("ValidExtensionNamespace(%q) = %v, want %v", namespace, got, want)
+		}
+	}
+}
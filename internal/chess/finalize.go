@@ -0,0 +1,52 @@
+package chess
+
+import "fmt"
+
+// FivefoldPlies is the fewest reversible half-moves a game needs for a
+// position to have occurred five times, so the fewest after which fivefold
+// repetition can end it
+const FivefoldPlies = 16
+
+// ReplayGame plays a game's moves from its variant's starting position and
+// returns the engine left at the end, which knows the game's full history.
+// Moves are checked against the FEN recorded with them.
+func ReplayGame(variant string, moves []*Move) (*Engine, error) {
+	engine, err := NewVariantEngine(variant, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, move := range moves {
+		result, err := engine.MakeMove(move.From, move.To, ParsePromotion(move.Promotion))
+		if err != nil {
+			return nil, fmt.Errorf("invalid move %d (%s): %w", move.Ply, move.SAN, err)
+		}
+		if move.FEN != "" && result.FEN != move.FEN {
+			return nil, fmt.Errorf("move %d (%s) doesn't reach its recorded position", move.Ply, move.SAN)
+		}
+	}
+	return engine, nil
+}
+
+// FinalizeMove settles the status a move leaves its game in by playing it
+// after the game's earlier moves. A move played from its position alone
+// can't see rules that depend on the game's history, such as fivefold
+// repetition. The move's Status, GameOver, Draw and Result are updated.
+func FinalizeMove(variant string, history []*Move, move *MoveResult) error {
+	engine, err := ReplayGame(variant, history)
+	if err != nil {
+		return err
+	}
+	final, err := engine.MakeMove(move.From, move.To, ParsePromotion(move.Promotion))
+	if err != nil {
+		return fmt.Errorf("move %s%s doesn't follow on from the game's moves: %w", move.From, move.To, err)
+	}
+	if final.FEN != move.FEN {
+		return fmt.Errorf("move %s%s doesn't follow on from the game's moves", move.From, move.To)
+	}
+
+	move.Status = final.Status
+	move.GameOver = final.GameOver
+	move.Draw = final.Draw
+	move.Result = final.Result
+	return nil
+}
@@ -0,0 +1,74 @@
+package chess
+
+import (
+	"testing"
+)
+
+// knightShuffle returns moves shuffling both knights out and back, which
+// repeat the position after 1. Nf3 on plies 1, 5, 9, 13 and 17
+func knightShuffle(t *testing.T, plies int) []*Move {
+	t.Helper()
+	ucis := []string{"g1f3", "g8f6", "f3g1", "f6g8"}
+	engine := NewEngine()
+	var moves []*Move
+	for ply := 1; ply <= plies; ply++ {
+		result, err := engine.MakeMoveUCI(ucis[(ply-1)%len(ucis)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		moves = append(moves, &Move{Ply: ply, From: result.From, To: result.To, SAN: result.SAN, FEN: result.FEN})
+	}
+	return moves
+}
+
+func TestReplayGame(t *testing.T) {
+	moves := knightShuffle(t, 3)
+	engine, err := ReplayGame("", moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if engine.GetFEN() != moves[2].FEN {
+		t.Errorf("Expected to end at %s, got %s", moves[2].FEN, engine.GetFEN())
+	}
+
+	moves[1].FEN = StartingFEN
+	if _, err := ReplayGame("", moves); err == nil {
+		t.Error("Expected a move that doesn't reach its recorded position to be rejected")
+	}
+}
+
+func TestFinalizeMoveDetectsFivefoldRepetition(t *testing.T) {
+	moves := knightShuffle(t, 17)
+	last := moves[16]
+
+	// From the position alone the repetition can't be seen
+	engine, err := NewEngineFromFEN(moves[15].FEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	move, err := engine.MakeMoveUCI("g1f3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if move.GameOver || move.FEN != last.FEN {
+		t.Fatalf("Expected the game to go on from the position alone, got %+v", move)
+	}
+
+	if err := FinalizeMove("", moves[:16], move); err != nil {
+		t.Fatal(err)
+	}
+	if !move.GameOver || !move.Draw || move.Status != StatusDraw {
+		t.Errorf("Expected a draw by fivefold repetition, got %+v", move)
+	}
+	if move.Result != "1/2-1/2 - Automatic draw by fivefold repetition" {
+		t.Errorf("Unexpected result %q", move.Result)
+	}
+}
+
+func TestFinalizeMoveRejectsMoveNotFollowingHistory(t *testing.T) {
+	moves := knightShuffle(t, 4)
+	move := &MoveResult{From: "e2", To: "e4", FEN: "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"}
+	if err := FinalizeMove("", moves[:3], move); err == nil {
+		t.Error("Expected a move from another position to be rejected")
+	}
+}
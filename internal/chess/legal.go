@@ -0,0 +1,48 @@
+package chess
+
+import (
+	"sort"
+
+	"github.com/notnil/chess"
+)
+
+// LegalMove is a move the side to move can play
+type LegalMove struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Promotion string `json:"promotion,omitempty"`
+	SAN       string `json:"san"`
+}
+
+// LegalMoves returns every move the side to move can play, ordered by origin
+// and destination square. A game that's over has none.
+func (e *Engine) LegalMoves() []LegalMove {
+	moves := []LegalMove{}
+	if e.GetStatus() != StatusActive {
+		return moves
+	}
+
+	position := e.game.Position()
+	for _, move := range e.validMoves() {
+		legal := LegalMove{
+			From: move.S1().String(),
+			To:   move.S2().String(),
+			SAN:  chess.AlgebraicNotation{}.Encode(position, move),
+		}
+		if move.Promo() != chess.NoPieceType {
+			legal.Promotion = move.Promo().String()
+		}
+		moves = append(moves, legal)
+	}
+
+	sort.Slice(moves, func(i, j int) bool {
+		if moves[i].From != moves[j].From {
+			return moves[i].From < moves[j].From
+		}
+		if moves[i].To != moves[j].To {
+			return moves[i].To < moves[j].To
+		}
+		return moves[i].Promotion < moves[j].Promotion
+	})
+	return moves
+}
@@ -0,0 +1,57 @@
+package chess
+
+import (
+	"testing"
+)
+
+func TestLegalMovesFromStart(t *testing.T) {
+	engine := NewEngine()
+	moves := engine.LegalMoves()
+	if len(moves) != 20 {
+		t.Fatalf("Expected 20 legal moves, got %d", len(moves))
+	}
+
+	found := false
+	for _, move := range moves {
+		if move.From == "g1" && move.To == "f3" {
+			found = true
+			if move.SAN != "Nf3" {
+				t.Errorf("Expected Nf3, got %s", move.SAN)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected g1-f3 to be legal")
+	}
+}
+
+func TestLegalMovesIncludePromotions(t *testing.T) {
+	engine, err := NewEngineFromFEN("8/P7/8/8/8/8/8/k6K w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	promotions := map[string]string{}
+	for _, move := range engine.LegalMoves() {
+		if move.From == "a7" {
+			promotions[move.Promotion] = move.SAN
+		}
+	}
+	want := map[string]string{"q": "a8=Q+", "r": "a8=R+", "b": "a8=B", "n": "a8=N"}
+	for promotion, san := range want {
+		if promotions[promotion] != san {
+			t.Errorf("Expected promotion to %s as %s, got %q", promotion, san, promotions[promotion])
+		}
+	}
+}
+
+func TestLegalMovesAfterCheckmate(t *testing.T) {
+	// Fool's mate
+	engine, err := NewEngineFromFEN("rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moves := engine.LegalMoves(); moves == nil || len(moves) != 0 {
+		t.Errorf("Expected no legal moves after checkmate, got %v", moves)
+	}
+}
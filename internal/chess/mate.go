@@ -0,0 +1,63 @@
+package chess
+
+import (
+	"github.com/notnil/chess"
+)
+
+// FindMate looks for a forced checkmate by the side to move in at most
+// maxMoves of its moves. It returns the shortest mating line in UCI notation,
+// alternating with the defender's most stubborn replies and ending with the
+// mating move, or nil if there isn't one. The search is exhaustive, so keep
+// maxMoves small; 2 or 3 is practical.
+func (e *Engine) FindMate(maxMoves int) []string {
+	position := e.game.Position()
+	for moves := 1; moves <= maxMoves; moves++ {
+		if line := mateLine(position, moves); line != nil {
+			uci := make([]string, len(line))
+			for i, move := range line {
+				uci[i] = move.String()
+			}
+			return uci
+		}
+	}
+	return nil
+}
+
+// mateLine returns a line in which the side to move mates in at most moves
+// moves whatever the defence, or nil
+func mateLine(position *chess.Position, moves int) []*chess.Move {
+	for _, move := range orderMoves(position.ValidMoves()) {
+		// The mating move itself has to give check
+		if moves == 1 && !move.HasTag(chess.Check) {
+			continue
+		}
+		next := position.Update(move)
+		if next.Status() == chess.Checkmate {
+			return []*chess.Move{move}
+		}
+		if moves == 1 {
+			continue
+		}
+
+		replies := next.ValidMoves()
+		if len(replies) == 0 {
+			// Stalemate
+			continue
+		}
+		var longest []*chess.Move
+		for _, reply := range replies {
+			rest := mateLine(next.Update(reply), moves-1)
+			if rest == nil {
+				longest = nil
+				break
+			}
+			if longest == nil || len(rest)+1 > len(longest) {
+				longest = append([]*chess.Move{reply}, rest...)
+			}
+		}
+		if longest != nil {
+			return append([]*chess.Move{move}, longest...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package chess
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindMate(t *testing.T) {
+	tests := []struct {
+		name     string
+		fen      string
+		maxMoves int
+		want     []string
+	}{
+		{
+			name:     "scholar's mate",
+			fen:      "r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4",
+			maxMoves: 2,
+			want:     []string{"h5f7"},
+		},
+		{
+			name:     "back rank",
+			fen:      "6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1",
+			maxMoves: 1,
+			want:     []string{"d1d8"},
+		},
+		{
+			name:     "mate in three for black",
+			fen:      "r1b1kb1r/pppp1ppp/5q2/4n3/3KP3/2N3PN/PPP4P/R1BQ1B1R b kq - 0 1",
+			maxMoves: 3,
+			want:     []string{"f8c5", "d4c5", "f6b6", "c5d5", "b6d6"},
+		},
+		{
+			name:     "too deep",
+			fen:      "r1b1kb1r/pppp1ppp/5q2/4n3/3KP3/2N3PN/PPP4P/R1BQ1B1R b kq - 0 1",
+			maxMoves: 2,
+		},
+		{
+			name:     "no mate",
+			fen:      StartingFEN,
+			maxMoves: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := NewEngineFromFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := engine.FindMate(tt.maxMoves); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package chess
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+var uciPattern = regexp.MustCompile(`^[a-h][1-8][a-h][1-8][qrbn]?$`)
+
+// IsUCI reports whether a move is written in UCI notation, e.g. "e2e4" or
+// "e7e8q", rather than SAN
+func IsUCI(move string) bool {
+	return uciPattern.MatchString(move)
+}
+
+// MakeMoveUCI plays a move written in UCI notation: the origin and
+// destination squares, then the promotion piece if any, e.g. "e7e8q"
+func (e *Engine) MakeMoveUCI(uci string) (*MoveResult, error) {
+	if !IsUCI(uci) {
+		return nil, fmt.Errorf("invalid UCI move: %q", uci)
+	}
+	return e.MakeMove(uci[0:2], uci[2:4], ParsePromotion(uci[4:]))
+}
+
+// MakeMoveSAN plays a move written in standard algebraic notation, e.g.
+// "Nf3", "exd5", "O-O" or "e8=Q". Check marks and annotations such as "!?"
+// are ignored, and castling may be written with zeros.
+func (e *Engine) MakeMoveSAN(san string) (*MoveResult, error) {
+	cleaned := strings.TrimRight(strings.TrimSpace(san), "!?")
+	cleaned = strings.ReplaceAll(cleaned, "0", "O")
+	if cleaned == "" {
+		return nil, fmt.Errorf("invalid SAN move: %q", san)
+	}
+
+	move, err := chess.AlgebraicNotation{}.Decode(e.game.Position(), cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("invalid move: %s", san)
+	}
+	return e.MakeMove(move.S1().String(), move.S2().String(), move.Promo())
+}
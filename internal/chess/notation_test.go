@@ -0,0 +1,62 @@
+package chess
+
+import (
+	"testing"
+)
+
+func TestIsUCI(t *testing.T) {
+	for move, want := range map[string]bool{
+		"e2e4":  true,
+		"e7e8q": true,
+		"Nf3":   false,
+		"O-O":   false,
+		"e4":    false,
+		"e7e8k": false,
+		"i2i4":  false,
+	} {
+		if got := IsUCI(move); got != want {
+			t.Errorf("IsUCI(%q) = %v, want %v", move, got, want)
+		}
+	}
+}
+
+func TestMakeMoveSAN(t *testing.T) {
+	engine := NewEngine()
+	for _, san := range []string{"e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5", "0-0"} {
+		result, err := engine.MakeMoveSAN(san)
+		if err != nil {
+			t.Fatalf("Failed to play %s: %v", san, err)
+		}
+		if san == "Nf3" && (result.From != "g1" || result.To != "f3" || result.SAN != "Nf3") {
+			t.Errorf("Expected Nf3 to be played from g1 to f3, got %+v", result)
+		}
+		if san == "0-0" && (result.From != "e1" || result.To != "g1" || result.SAN != "O-O") {
+			t.Errorf("Expected castling with zeros to castle kingside, got %+v", result)
+		}
+	}
+
+	if _, err := engine.MakeMoveSAN("Qxf7#!!"); err == nil {
+		t.Error("Expected an illegal move to be rejected")
+	}
+	if _, err := engine.MakeMoveSAN("Nf6!?"); err != nil {
+		t.Errorf("Expected annotations to be ignored, got %v", err)
+	}
+}
+
+func TestMakeMoveUCIPromotes(t *testing.T) {
+	engine, err := NewEngineFromFEN("8/P7/8/8/8/8/8/k6K w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := engine.MakeMoveUCI("a7a8n")
+	if err != nil {
+		t.Fatalf("Failed to promote: %v", err)
+	}
+	if result.Promotion != "n" || result.SAN != "a8=N" {
+		t.Errorf("Expected an underpromotion to a knight, got %+v", result)
+	}
+
+	if _, err := engine.MakeMoveUCI("Nf3"); err == nil {
+		t.Error("Expected SAN to be rejected as UCI")
+	}
+}
@@ -0,0 +1,153 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// StartingFEN is the standard initial position
+const StartingFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// pgnLineWidth is the maximum movetext line length recommended by the PGN standard
+const pgnLineWidth = 80
+
+// PGNTags holds the Seven Tag Roster for an exported game
+type PGNTags struct {
+	Event  string
+	Site   string
+	Date   string // YYYY.MM.DD, "??" for unknown parts
+	Round  string
+	White  string
+	Black  string
+	Result string
+}
+
+// PGNMove is a move to replay when building a PGN
+type PGNMove struct {
+	From      string
+	To        string
+	Promotion string // "q", "r", "b" or "n"
+	SAN       string // used to recover the promotion piece when Promotion is unset
+}
+
+// ResultForStatus returns the PGN result token for a game status
+func ResultForStatus(status GameStatus) string {
+	switch status {
+	case StatusWhiteWon:
+		return "1-0"
+	case StatusBlackWon:
+		return "0-1"
+	case StatusDraw:
+		return "1/2-1/2"
+	default:
+		return "*"
+	}
+}
+
+// ExportPGN replays moves from startFEN, validating each one, and returns the
+// game in PGN export format
+func ExportPGN(startFEN string, tags PGNTags, moves []PGNMove) (string, error) {
+	if startFEN == "" {
+		startFEN = StartingFEN
+	}
+
+	engine, err := NewEngineFromFEN(startFEN)
+	if err != nil {
+		return "", err
+	}
+
+	// Move numbering continues from the starting position
+	startPosition := engine.game.Position()
+	moveNumber := 1
+	if fields := strings.Fields(startFEN); len(fields) == 6 {
+		fmt.Sscanf(fields[5], "%d", &moveNumber)
+	}
+	whiteToMove := startPosition.Turn() == chess.White
+
+	var tokens []string
+	for i, move := range moves {
+		promotion := move.Promotion
+		if promotion == "" {
+			promotion = PromotionFromSAN(move.SAN)
+		}
+		result, err := engine.MakeMove(move.From, move.To, ParsePromotion(promotion))
+		if err != nil {
+			return "", fmt.Errorf("move %d (%s%s) is illegal: %w", i+1, move.From, move.To, err)
+		}
+
+		if whiteToMove {
+			tokens = append(tokens, fmt.Sprintf("%d.", moveNumber))
+		} else if i == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d...", moveNumber))
+		}
+		tokens = append(tokens, result.SAN)
+
+		if !whiteToMove {
+			moveNumber++
+		}
+		whiteToMove = !whiteToMove
+	}
+
+	if tags.Result == "" {
+		tags.Result = "*"
+	}
+	tokens = append(tokens, tags.Result)
+
+	var b strings.Builder
+	writeTag(&b, "Event", orUnknown(tags.Event))
+	writeTag(&b, "Site", orUnknown(tags.Site))
+	writeTag(&b, "Date", orDefault(tags.Date, "????.??.??"))
+	writeTag(&b, "Round", orDefault(tags.Round, "-"))
+	writeTag(&b, "White", orUnknown(tags.White))
+	writeTag(&b, "Black", orUnknown(tags.Black))
+	writeTag(&b, "Result", tags.Result)
+	if startFEN != StartingFEN {
+		writeTag(&b, "SetUp", "1")
+		writeTag(&b, "FEN", startFEN)
+	}
+	b.WriteString("\n")
+
+	lineLength := 0
+	for _, token := range tokens {
+		if lineLength > 0 && lineLength+1+len(token) > pgnLineWidth {
+			b.WriteString("\n")
+			lineLength = 0
+		}
+		if lineLength > 0 {
+			b.WriteString(" ")
+			lineLength++
+		}
+		b.WriteString(token)
+		lineLength += len(token)
+	}
+	b.WriteString("\n")
+
+	return b.String(), nil
+}
+
+// PromotionFromSAN extracts the promotion piece from SAN such as "e8=Q+"
+func PromotionFromSAN(san string) string {
+	if i := strings.Index(san, "="); i >= 0 && i+1 < len(san) {
+		return strings.ToLower(san[i+1 : i+2])
+	}
+	return ""
+}
+
+func writeTag(b *strings.Builder, key, value string) {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	fmt.Fprintf(b, "[%s \"%s\"]\n", key, value)
+}
+
+func orUnknown(value string) string {
+	return orDefault(value, "?")
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
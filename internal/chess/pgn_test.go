@@ -0,0 +1,94 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportPGN(t *testing.T) {
+	scholarsMate := []PGNMove{
+		{From: "e2", To: "e4"}, {From: "e7", To: "e5"},
+		{From: "f1", To: "c4"}, {From: "b8", To: "c6"},
+		{From: "d1", To: "h5"}, {From: "g8", To: "f6"},
+		{From: "h5", To: "f7"},
+	}
+
+	pgn, err := ExportPGN("", PGNTags{
+		Event:  "Casual \"blitz\"",
+		Site:   "https://atchess.example",
+		Date:   "2024.01.02",
+		White:  "did:plc:white",
+		Black:  "did:plc:black",
+		Result: ResultForStatus(StatusWhiteWon),
+	}, scholarsMate)
+	if err != nil {
+		t.Fatalf("ExportPGN failed: %v", err)
+	}
+
+	expectedTags := []string{
+		`[Event "Casual \"blitz\""]`,
+		`[Site "https://atchess.example"]`,
+		`[Date "2024.01.02"]`,
+		`[Round "-"]`,
+		`[White "did:plc:white"]`,
+		`[Black "did:plc:black"]`,
+		`[Result "1-0"]`,
+	}
+	for _, tag := range expectedTags {
+		if !strings.Contains(pgn, tag+"\n") {
+			t.Errorf("Expected tag %s in PGN:\n%s", tag, pgn)
+		}
+	}
+
+	expectedMoves := "1. e4 e5 2. Bc4 Nc6 3. Qh5 Nf6 4. Qxf7# 1-0\n"
+	if !strings.HasSuffix(pgn, "\n\n"+expectedMoves) {
+		t.Errorf("Expected movetext %q, got:\n%s", expectedMoves, pgn)
+	}
+}
+
+func TestExportPGNFromCustomPosition(t *testing.T) {
+	fen := "8/4P3/8/8/8/8/k7/4K3 b - - 0 40"
+	pgn, err := ExportPGN(fen, PGNTags{}, []PGNMove{
+		{From: "a2", To: "b2"},
+		{From: "e7", To: "e8", SAN: "e8=N"},
+	})
+	if err != nil {
+		t.Fatalf("ExportPGN failed: %v", err)
+	}
+
+	if !strings.Contains(pgn, `[SetUp "1"]`) || !strings.Contains(pgn, `[FEN "`+fen+`"]`) {
+		t.Errorf("Expected SetUp and FEN tags for custom position:\n%s", pgn)
+	}
+	if !strings.Contains(pgn, `[Result "*"]`) || !strings.Contains(pgn, `[Event "?"]`) {
+		t.Errorf("Expected default tags:\n%s", pgn)
+	}
+	if !strings.HasSuffix(pgn, "40... Kb2 41. e8=N *\n") {
+		t.Errorf("Expected black-first numbering and promotion from SAN:\n%s", pgn)
+	}
+}
+
+func TestExportPGNRejectsIllegalMoves(t *testing.T) {
+	_, err := ExportPGN("", PGNTags{}, []PGNMove{{From: "e2", To: "e4"}, {From: "e2", To: "e4"}})
+	if err == nil {
+		t.Error("Expected error for illegal move sequence")
+	}
+}
+
+func TestExportPGNWrapsLongGames(t *testing.T) {
+	var moves []PGNMove
+	for i := 0; i < 10; i++ {
+		moves = append(moves,
+			PGNMove{From: "g1", To: "f3"}, PGNMove{From: "g8", To: "f6"},
+			PGNMove{From: "f3", To: "g1"}, PGNMove{From: "f6", To: "g8"})
+	}
+
+	pgn, err := ExportPGN("", PGNTags{}, moves)
+	if err != nil {
+		t.Fatalf("ExportPGN failed: %v", err)
+	}
+	for _, line := range strings.Split(pgn, "\n") {
+		if len(line) > 80 {
+			t.Errorf("Line exceeds 80 characters: %q", line)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package chess
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// moveNumberPattern matches a move number such as "12." or "12..."
+var moveNumberPattern = regexp.MustCompile(`^[0-9]+\.+`)
+
+// PGNGame is a game read from PGN: its tag pairs and the moves of its main
+// line as written
+type PGNGame struct {
+	Tags  map[string]string
+	Moves []string // SAN, without move numbers, comments or variations
+}
+
+// ErrInvalidPGN is returned for a game whose movetext can't be read
+var ErrInvalidPGN = errors.New("invalid PGN")
+
+// maxPGNLine bounds the length of a line in a PGN file
+const maxPGNLine = 1 << 20
+
+// ReadPGN reads every game in a PGN file. Comments, annotation glyphs and
+// variations are dropped, leaving each game's main line.
+func ReadPGN(text string) ([]*PGNGame, error) {
+	var games []*PGNGame
+	reader := NewPGNReader(strings.NewReader(text))
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			return games, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, game)
+	}
+}
+
+// PGNReader reads the games in a PGN file one at a time, so files of any
+// size can be read without holding them in memory
+type PGNReader struct {
+	scanner *bufio.Scanner
+	games   int // read so far, including invalid ones
+
+	// The game being read, whose tags may have been read with the last
+	// game's movetext
+	current    *PGNGame
+	movetext   strings.Builder
+	inMovetext bool
+}
+
+// NewPGNReader creates a reader for the PGN file in r
+func NewPGNReader(r io.Reader) *PGNReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxPGNLine)
+	return &PGNReader{scanner: scanner}
+}
+
+// Next returns the next game, or io.EOF after the last one. A game that
+// can't be read returns an error wrapping ErrInvalidPGN, after which the
+// following games can still be read; any other error is from reading the
+// file itself.
+func (p *PGNReader) Next() (*PGNGame, error) {
+	for p.scanner.Scan() {
+		line := p.scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "%") {
+			continue // escaped line
+		}
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			// A tag after movetext starts the next game
+			var finished *PGNGame
+			var err error
+			if p.inMovetext {
+				finished, err = p.finish()
+			}
+			if p.current == nil {
+				p.current = &PGNGame{Tags: map[string]string{}}
+			}
+			if key, value, ok := readTag(trimmed); ok {
+				p.current.Tags[key] = value
+			}
+			if finished != nil || err != nil {
+				return finished, err
+			}
+			continue
+		}
+		if trimmed == "" {
+			continue
+		}
+		if p.current == nil {
+			p.current = &PGNGame{Tags: map[string]string{}}
+		}
+		p.inMovetext = true
+		p.movetext.WriteString(line)
+		p.movetext.WriteString("\n")
+	}
+	if err := p.scanner.Err(); err != nil {
+		return nil, err
+	}
+	if p.current == nil {
+		return nil, io.EOF
+	}
+	return p.finish()
+}
+
+// finish completes the game being read
+func (p *PGNReader) finish() (*PGNGame, error) {
+	game := p.current
+	moves, result, err := readMovetext(p.movetext.String())
+	p.current = nil
+	p.movetext.Reset()
+	p.inMovetext = false
+	p.games++
+	if err != nil {
+		return nil, fmt.Errorf("%w: game %d: %v", ErrInvalidPGN, p.games, err)
+	}
+	game.Moves = moves
+	if game.Tags["Result"] == "" && result != "" {
+		game.Tags["Result"] = result
+	}
+	return game, nil
+}
+
+// readTag parses a tag pair such as [White "Carlsen, Magnus"]
+func readTag(line string) (key, value string, ok bool) {
+	inner := strings.TrimSpace(line[1 : len(line)-1])
+	space := strings.IndexAny(inner, " \t")
+	if space <= 0 {
+		return "", "", false
+	}
+	key = inner[:space]
+	quoted := strings.TrimSpace(inner[space:])
+	if len(quoted) < 2 || quoted[0] != '"' || quoted[len(quoted)-1] != '"' {
+		return "", "", false
+	}
+	value = strings.ReplaceAll(quoted[1:len(quoted)-1], `\"`, `"`)
+	value = strings.ReplaceAll(value, `\\`, `\`)
+	return key, value, true
+}
+
+// readMovetext returns the main line's moves and the result token, if any
+func readMovetext(text string) (moves []string, result string, err error) {
+	depth := 0 // of nested variations
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated comment")
+			}
+			i += end + 1
+			continue
+		case c == ';':
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				end = len(text) - i
+			}
+			i += end
+			continue
+		case c == '(':
+			depth++
+			i++
+			continue
+		case c == ')':
+			if depth == 0 {
+				return nil, "", fmt.Errorf("unbalanced variation")
+			}
+			depth--
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(text) && !strings.ContainsRune(" \t\n\r{};()", rune(text[end])) {
+			end++
+		}
+		token := text[i:end]
+		i = end
+		if depth > 0 {
+			continue
+		}
+
+		switch {
+		case token == "1-0" || token == "0-1" || token == "1/2-1/2" || token == "*":
+			result = token
+		case strings.HasPrefix(token, "$"):
+			// Numeric annotation glyph
+		default:
+			// Move numbers may be run together with the move, as in "1.e4"
+			token = strings.TrimLeft(moveNumberPattern.ReplaceAllString(token, ""), ".")
+			if token != "" {
+				moves = append(moves, token)
+			}
+		}
+	}
+	if depth > 0 {
+		return nil, "", fmt.Errorf("unbalanced variation")
+	}
+	return moves, result, nil
+}
+
+// StartFEN returns the position the game starts from
+func (g *PGNGame) StartFEN() string {
+	if fen := g.Tags["FEN"]; fen != "" {
+		return fen
+	}
+	return StartingFEN
+}
+
+// Replay plays the game's moves from its starting position, returning each
+// move as played. It fails at the first move that isn't legal.
+func (g *PGNGame) Replay() ([]*MoveResult, error) {
+	engine, err := NewEngineFromFEN(g.StartFEN())
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*MoveResult, 0, len(g.Moves))
+	for i, san := range g.Moves {
+		result, err := engine.MakeMoveSAN(san)
+		if err != nil {
+			return nil, fmt.Errorf("move %d (%s) is illegal: %w", i+1, san, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Status returns the game status for the game's Result tag: finished, or
+// active for "*" and a missing result
+func (g *PGNGame) Status() GameStatus {
+	switch g.Tags["Result"] {
+	case "1-0":
+		return StatusWhiteWon
+	case "0-1":
+		return StatusBlackWon
+	case "1/2-1/2":
+		return StatusDraw
+	default:
+		return StatusActive
+	}
+}
+
+// PlayedAt returns when the game was played, from the UTCDate and UTCTime
+// tags or else the Date tag. ok is false if the date isn't known.
+func (g *PGNGame) PlayedAt() (at time.Time, ok bool) {
+	date := g.Tags["UTCDate"]
+	if date == "" {
+		date = g.Tags["Date"]
+	}
+	clock := g.Tags["UTCTime"]
+	if clock == "" {
+		clock = g.Tags["StartTime"]
+	}
+	if clock == "" {
+		clock = "00:00:00"
+	}
+	at, err := time.Parse("2006.01.02 15:04:05", date+" "+clock)
+	if err != nil {
+		if at, err = time.Parse("2006.01.02", date); err != nil {
+			return time.Time{}, false
+		}
+	}
+	return at.UTC(), true
+}
+
+// TimeControl returns the game's time control from its TimeControl tag,
+// e.g. "300+2" for five minutes plus two seconds a move or "1/86400" for a
+// day per move, or nil if there's none
+func (g *PGNGame) TimeControl() *TimeControl {
+	tag := g.Tags["TimeControl"]
+	if days, ok := strings.CutPrefix(tag, "1/"); ok {
+		seconds, err := strconv.Atoi(days)
+		if err != nil || seconds <= 0 {
+			return nil
+		}
+		return &TimeControl{Type: "correspondence", DaysPerMove: max(seconds/86400, 1)}
+	}
+
+	initialTag, incrementTag, _ := strings.Cut(tag, "+")
+	initial, err := strconv.Atoi(initialTag)
+	if err != nil || initial <= 0 {
+		return nil
+	}
+	increment := 0
+	if incrementTag != "" {
+		if increment, err = strconv.Atoi(incrementTag); err != nil {
+			return nil
+		}
+	}
+
+	// Classified by the expected length of a 40-move game
+	tc := &TimeControl{Initial: initial, Increment: increment}
+	switch estimate := initial + 40*increment; {
+	case estimate < 180:
+		tc.Type = "bullet"
+	case estimate < 480:
+		tc.Type = "blitz"
+	default:
+		tc.Type = "rapid"
+	}
+	return tc
+}
@@ -0,0 +1,106 @@
+package chess
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const twoGamePGN = `[Event "Rated blitz game"]
+[Site "https://lichess.org/abcd1234"]
+[UTCDate "2024.03.05"]
+[UTCTime "18:04:10"]
+[White "alice"]
+[Black "bob"]
+[Result "1-0"]
+[TimeControl "180+2"]
+
+1. e4 { [%clk 0:03:00] } 1... e5 2. Bc4 (2. Nf3 Nc6 (2... d6) 3. Bb5) 2... Nc6 $6
+3. Qh5 Nf6?? 4. Qxf7# 1-0
+
+[Event "Daily"]
+[Date "2023.12.31"]
+[White "carol"]
+[Black "dave"]
+[TimeControl "1/259200"]
+
+1.d4 d5 2.c4 ; the Queen's Gambit
+2...dxc4 3.e3 O-O-O *
+`
+
+func TestReadPGN(t *testing.T) {
+	games, err := ReadPGN(twoGamePGN)
+	if err != nil {
+		t.Fatalf("ReadPGN failed: %v", err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("Expected 2 games, got %d", len(games))
+	}
+
+	first := games[0]
+	expected := []string{"e4", "e5", "Bc4", "Nc6", "Qh5", "Nf6??", "Qxf7#"}
+	if len(first.Moves) != len(expected) {
+		t.Fatalf("Expected the main line %v, got %v", expected, first.Moves)
+	}
+	for i, move := range expected {
+		if first.Moves[i] != move {
+			t.Errorf("Move %d: expected %s, got %s", i+1, move, first.Moves[i])
+		}
+	}
+	if first.Status() != StatusWhiteWon || first.Tags["White"] != "alice" {
+		t.Errorf("Unexpected tags: %v", first.Tags)
+	}
+	if at, ok := first.PlayedAt(); !ok || !at.Equal(time.Date(2024, 3, 5, 18, 4, 10, 0, time.UTC)) {
+		t.Errorf("Expected the UTC start time, got %v", at)
+	}
+	if tc := first.TimeControl(); tc == nil || tc.Type != "blitz" || tc.Initial != 180 || tc.Increment != 2 {
+		t.Errorf("Expected 3+2 blitz, got %+v", tc)
+	}
+	moves, err := first.Replay()
+	if err != nil || len(moves) != 7 || !moves[6].Checkmate {
+		t.Errorf("Expected the game to replay to mate, got %v %v", err, moves)
+	}
+
+	second := games[1]
+	if second.Status() != StatusActive || len(second.Moves) != 6 || second.Moves[2] != "c4" {
+		t.Errorf("Expected an unfinished game with numbers run into moves, got %v %v", second.Status(), second.Moves)
+	}
+	if tc := second.TimeControl(); tc == nil || tc.Type != "correspondence" || tc.DaysPerMove != 3 {
+		t.Errorf("Expected three days per move, got %+v", tc)
+	}
+	if _, err := second.Replay(); err == nil {
+		t.Error("Expected castling through pieces to be illegal")
+	}
+}
+
+func TestReadPGNRejectsBrokenMovetext(t *testing.T) {
+	if _, err := ReadPGN("1. e4 { unterminated"); err == nil {
+		t.Error("Expected an unterminated comment to fail")
+	}
+	if _, err := ReadPGN("1. e4 (1. d4 e5"); err == nil {
+		t.Error("Expected an unbalanced variation to fail")
+	}
+}
+
+func TestPGNReaderSkipsPastInvalidGames(t *testing.T) {
+	reader := NewPGNReader(strings.NewReader(`[White "a"]
+
+1. e4 (1. d4 1-0
+
+[White "b"]
+
+1. d4 d5 *
+`))
+	if _, err := reader.Next(); !errors.Is(err, ErrInvalidPGN) {
+		t.Fatalf("Expected the first game to be invalid, got %v", err)
+	}
+	game, err := reader.Next()
+	if err != nil || game.Tags["White"] != "b" || len(game.Moves) != 2 {
+		t.Fatalf("Expected the second game to be read, got %v %+v", err, game)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected the end of the file, got %v", err)
+	}
+}
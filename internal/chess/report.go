@@ -0,0 +1,181 @@
+package chess
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/notnil/chess"
+)
+
+// PositionEvaluator scores positions for AnalyzeGame, typically with a UCI
+// engine far stronger than Evaluate. Scores are white-relative, like
+// Evaluate's.
+type PositionEvaluator interface {
+	EvaluatePosition(ctx context.Context, fen string) (*Evaluation, error)
+}
+
+// MoveAnalysis is the engine's verdict on one move of a game
+type MoveAnalysis struct {
+	Ply            int    `json:"ply"`
+	Color          string `json:"color"` // side that moved, "white" or "black"
+	SAN            string `json:"san"`
+	EvalBefore     int    `json:"evalBefore"` // centipawns, positive = white advantage
+	EvalAfter      int    `json:"evalAfter"`
+	Loss           int    `json:"loss"`                     // centipawns the move gave away, from the mover's side
+	Classification string `json:"classification,omitempty"` // inaccuracy, mistake or blunder
+	BestMove       string `json:"bestMove,omitempty"`       // engine's choice, when it wasn't played
+	BestSAN        string `json:"bestSan,omitempty"`
+}
+
+// PlayerAnalysis sums up how accurately one side played
+type PlayerAnalysis struct {
+	AverageLoss  int `json:"averageCentipawnLoss"`
+	Inaccuracies int `json:"inaccuracies"`
+	Mistakes     int `json:"mistakes"`
+	Blunders     int `json:"blunders"`
+}
+
+// GameAnalysis is a move-by-move computer analysis of a game
+type GameAnalysis struct {
+	Moves []MoveAnalysis `json:"moves"`
+	White PlayerAnalysis `json:"white"`
+	Black PlayerAnalysis `json:"black"`
+}
+
+// Classify names a move by the centipawns it lost: a blunder, mistake or
+// inaccuracy, or "" for a good move
+func Classify(loss int) string {
+	switch {
+	case loss >= BlunderThreshold:
+		return ClassBlunder
+	case loss >= MistakeThreshold:
+		return ClassMistake
+	case loss >= InaccuracyThreshold:
+		return ClassInaccuracy
+	}
+	return ""
+}
+
+// UCIScore converts a UCI engine's score for the side to move - centipawns,
+// or the moves to mate when mate isn't 0 - to a white-relative score on
+// Evaluate's scale
+func UCIScore(whiteToMove bool, centipawns, mate int) int {
+	score := centipawns
+	switch {
+	case mate > 0:
+		score = mateScore - (2*mate - 1)
+	case mate < 0:
+		score = -mateScore - 2*mate
+	}
+	if !whiteToMove {
+		return -score
+	}
+	return score
+}
+
+// AnalyzeGame evaluates every position of a game played from startFEN and
+// measures the centipawns each move lost against the evaluator's best play.
+// Like ReviewGame, it caps evaluations at ten pawns either way, so a move
+// that allows mate counts as no worse than one that gives away ten pawns.
+// Positions with no legal moves are scored without asking the evaluator.
+func AnalyzeGame(ctx context.Context, evaluator PositionEvaluator, startFEN string, moves []*Move) (*GameAnalysis, error) {
+	if startFEN == "" {
+		startFEN = StartingFEN
+	}
+
+	fens := append(make([]string, 0, len(moves)+1), startFEN)
+	for _, move := range moves {
+		fens = append(fens, move.FEN)
+	}
+	positions := make([]*chess.Position, 0, len(fens))
+	evaluations := make([]*Evaluation, 0, len(fens))
+	for ply, fen := range fens {
+		engine, err := NewEngineFromFEN(fen)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load position %q: %w", fen, err)
+		}
+		position := engine.game.Position()
+		positions = append(positions, position)
+
+		if len(position.ValidMoves()) == 0 {
+			evaluations = append(evaluations, &Evaluation{
+				FEN:   fen,
+				Score: whitePerspective(position, terminalScore(position, 0)),
+				Mate:  position.Status() == chess.Checkmate,
+			})
+			continue
+		}
+		eval, err := evaluator.EvaluatePosition(ctx, fen)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate ply %d: %w", ply, err)
+		}
+		evaluations = append(evaluations, eval)
+	}
+
+	analysis := &GameAnalysis{Moves: make([]MoveAnalysis, 0, len(moves))}
+	var whiteLoss, whiteMoves, blackLoss, blackMoves int
+	for i, move := range moves {
+		before, after := evaluations[i], evaluations[i+1]
+		result := MoveAnalysis{
+			Ply:        i + 1,
+			Color:      "white",
+			SAN:        move.SAN,
+			EvalBefore: before.Score,
+			EvalAfter:  after.Score,
+		}
+
+		result.Loss = clampScore(before.Score) - clampScore(after.Score)
+		player, loss, played := &analysis.White, &whiteLoss, &whiteMoves
+		if positions[i].Turn() == chess.Black {
+			result.Color = "black"
+			result.Loss = -result.Loss
+			player, loss, played = &analysis.Black, &blackLoss, &blackMoves
+		}
+		if result.Loss < 0 {
+			// The engine missed something the move found
+			result.Loss = 0
+		}
+		result.Classification = Classify(result.Loss)
+
+		if before.BestMove != "" && before.BestMove != move.From+move.To+move.Promotion {
+			result.BestMove, result.BestSAN = before.BestMove, before.BestSAN
+			if result.BestSAN == "" {
+				result.BestSAN = uciToSAN(positions[i], before.BestMove)
+			}
+		}
+
+		*loss += result.Loss
+		*played++
+		switch result.Classification {
+		case ClassBlunder:
+			player.Blunders++
+		case ClassMistake:
+			player.Mistakes++
+		case ClassInaccuracy:
+			player.Inaccuracies++
+		}
+		analysis.Moves = append(analysis.Moves, result)
+	}
+	analysis.White.AverageLoss = averageLoss(whiteLoss, whiteMoves)
+	analysis.Black.AverageLoss = averageLoss(blackLoss, blackMoves)
+
+	return analysis, nil
+}
+
+// uciToSAN writes a UCI move in SAN, or returns "" if it isn't legal in the
+// position
+func uciToSAN(position *chess.Position, uci string) string {
+	move, err := chess.UCINotation{}.Decode(position, uci)
+	if err != nil {
+		return ""
+	}
+	return chess.AlgebraicNotation{}.Encode(position, move)
+}
+
+func averageLoss(total, moves int) int {
+	if moves == 0 {
+		return 0
+	}
+	return int(math.Round(float64(total) / float64(moves)))
+}
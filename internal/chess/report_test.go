@@ -0,0 +1,91 @@
+package chess
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// builtinEvaluator scores positions with Evaluate
+type builtinEvaluator struct{ calls int }
+
+func (b *builtinEvaluator) EvaluatePosition(ctx context.Context, fen string) (*Evaluation, error) {
+	b.calls++
+	engine, err := NewEngineFromFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	return engine.Evaluate(2)
+}
+
+func TestAnalyzeGameMeasuresLoss(t *testing.T) {
+	// Fool's mate: 1. f3 e5 2. g4?? Qh4#
+	moves := playMoves(t, "f2", "f3", "e7", "e5", "g2", "g4", "d8", "h4")
+	evaluator := &builtinEvaluator{}
+
+	analysis, err := AnalyzeGame(context.Background(), evaluator, "", moves)
+	if err != nil {
+		t.Fatalf("AnalyzeGame failed: %v", err)
+	}
+	if evaluator.calls != len(moves) {
+		t.Errorf("Expected the final checkmate not to be evaluated, got %d calls", evaluator.calls)
+	}
+	if len(analysis.Moves) != len(moves) {
+		t.Fatalf("Expected %d analysed moves, got %d", len(moves), len(analysis.Moves))
+	}
+
+	blunder := analysis.Moves[2]
+	if blunder.SAN != "g4" || blunder.Color != "white" || blunder.Loss != maxReviewScore || blunder.Classification != ClassBlunder {
+		t.Errorf("Expected 2. g4 to be a blunder by white, got %+v", blunder)
+	}
+	if mate := analysis.Moves[3]; mate.Color != "black" || mate.Loss != 0 || mate.Classification != "" || mate.BestMove != "" {
+		t.Errorf("Expected 2... Qh4# to be the best move, got %+v", mate)
+	}
+
+	if analysis.White.Blunders != 1 || analysis.White.AverageLoss != maxReviewScore/2 {
+		t.Errorf("Expected one blunder averaging %d for white, got %+v", maxReviewScore/2, analysis.White)
+	}
+	if analysis.Black.Blunders != 0 || analysis.Black.AverageLoss != 0 {
+		t.Errorf("Expected black to play perfectly, got %+v", analysis.Black)
+	}
+}
+
+type failingEvaluator struct{}
+
+func (failingEvaluator) EvaluatePosition(ctx context.Context, fen string) (*Evaluation, error) {
+	return nil, errors.New("engine crashed")
+}
+
+func TestAnalyzeGameReportsEvaluatorErrors(t *testing.T) {
+	moves := playMoves(t, "e2", "e4")
+	if _, err := AnalyzeGame(context.Background(), failingEvaluator{}, "", moves); err == nil {
+		t.Error("Expected the evaluator's error")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := map[int]string{
+		0:                   "",
+		InaccuracyThreshold: ClassInaccuracy,
+		MistakeThreshold:    ClassMistake,
+		BlunderThreshold:    ClassBlunder,
+		maxReviewScore:      ClassBlunder,
+	}
+	for loss, expected := range tests {
+		if got := Classify(loss); got != expected {
+			t.Errorf("Classify(%d) = %q, want %q", loss, got, expected)
+		}
+	}
+}
+
+func TestUCIScore(t *testing.T) {
+	if score := UCIScore(false, 35, 0); score != -35 {
+		t.Errorf("Expected black's +35 to be -35, got %d", score)
+	}
+	if score := UCIScore(true, 0, 2); FormatEval(score) != "+#" || score != mateScore-3 {
+		t.Errorf("Expected white mating in 2 to score %d, got %d", mateScore-3, score)
+	}
+	if score := UCIScore(false, 0, -1); FormatEval(score) != "+#" || score != mateScore-2 {
+		t.Errorf("Expected black being mated in 1 to score %d, got %d", mateScore-2, score)
+	}
+}
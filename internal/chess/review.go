@@ -0,0 +1,185 @@
+package chess
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Review thresholds, in centipawns lost by the side that moved
+const (
+	BlunderThreshold    = 300
+	MistakeThreshold    = 150
+	InaccuracyThreshold = 50
+)
+
+// maxReviewScore caps evaluations when measuring swings, so a move that
+// allows mate doesn't count as a thousand times worse than losing a rook
+const maxReviewScore = 1000
+
+// Move classifications in a review
+const (
+	ClassBlunder    = "blunder"
+	ClassMistake    = "mistake"
+	ClassInaccuracy = "inaccuracy"
+	ClassCheckmate  = "checkmate"
+)
+
+// ReviewMoment is a turning point of a game: a move that gave away a
+// significant advantage, or the move that ended it
+type ReviewMoment struct {
+	Ply            int    `json:"ply"`
+	Color          string `json:"color"` // side that moved, "white" or "black"
+	SAN            string `json:"san"`
+	From           string `json:"from"`
+	To             string `json:"to"`
+	FENBefore      string `json:"fenBefore"`
+	FEN            string `json:"fen"`        // position after the move
+	EvalBefore     int    `json:"evalBefore"` // centipawns, positive = white advantage
+	EvalAfter      int    `json:"evalAfter"`
+	Loss           int    `json:"loss"` // centipawns the move gave away, from the mover's side
+	Classification string `json:"classification"`
+	BestSAN        string `json:"bestSan,omitempty"` // engine's choice in the position before
+}
+
+// MoveNumber returns the full-move number of the moment, e.g. 12 for 12. Nf3
+// and for 12... Nf6
+func (m ReviewMoment) MoveNumber() int {
+	return (m.Ply + 1) / 2
+}
+
+// Notation returns the move as it's written in a score sheet, e.g. "12. Nf3"
+// or "12... Nf6"
+func (m ReviewMoment) Notation() string {
+	if m.Color == "white" {
+		return fmt.Sprintf("%d. %s", m.MoveNumber(), m.SAN)
+	}
+	return fmt.Sprintf("%d... %s", m.MoveNumber(), m.SAN)
+}
+
+// GameReview is a post-game analysis: the evaluation after every move and
+// the moments where the game turned
+type GameReview struct {
+	Depth   int            `json:"depth"`
+	Evals   []int          `json:"evals"` // evals[0] is the start, evals[ply] after that ply
+	Moments []ReviewMoment `json:"moments"`
+}
+
+// ReviewGame evaluates every position of a game played from startFEN and
+// picks out up to maxMoments turning points, in the order they were played.
+// Blunders and mistakes are ranked by how much they gave away; a checkmate
+// is always included. Like Evaluate, it uses a shallow material search, so
+// it finds dropped pieces and missed tactics rather than positional errors.
+func ReviewGame(startFEN string, moves []*Move, depth, maxMoments int) (*GameReview, error) {
+	if startFEN == "" {
+		startFEN = StartingFEN
+	}
+
+	evaluations := make([]*Evaluation, 0, len(moves)+1)
+	fens := append(make([]string, 0, len(moves)+1), startFEN)
+	for _, move := range moves {
+		fens = append(fens, move.FEN)
+	}
+	for _, fen := range fens {
+		engine, err := NewEngineFromFEN(fen)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load position %q: %w", fen, err)
+		}
+		eval, err := engine.Evaluate(depth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate position %q: %w", fen, err)
+		}
+		evaluations = append(evaluations, eval)
+	}
+
+	review := &GameReview{
+		Depth:   depth,
+		Evals:   make([]int, len(evaluations)),
+		Moments: []ReviewMoment{},
+	}
+	for i, eval := range evaluations {
+		review.Evals[i] = eval.Score
+	}
+
+	var candidates []ReviewMoment
+	for i, move := range moves {
+		before, after := evaluations[i], evaluations[i+1]
+		moment := ReviewMoment{
+			Ply:        i + 1,
+			Color:      "white",
+			SAN:        move.SAN,
+			From:       move.From,
+			To:         move.To,
+			FENBefore:  fens[i],
+			FEN:        move.FEN,
+			EvalBefore: before.Score,
+			EvalAfter:  after.Score,
+			BestSAN:    before.BestSAN,
+		}
+		if moment.Ply%2 == 0 {
+			moment.Color = "black"
+		}
+
+		moment.Loss = clampScore(before.Score) - clampScore(after.Score)
+		if moment.Color == "black" {
+			moment.Loss = -moment.Loss
+		}
+
+		switch {
+		case after.Mate && after.BestMove == "":
+			moment.Classification = ClassCheckmate
+		case moment.Loss >= BlunderThreshold:
+			moment.Classification = ClassBlunder
+		case moment.Loss >= MistakeThreshold:
+			moment.Classification = ClassMistake
+		default:
+			continue
+		}
+		if moment.BestSAN == moment.SAN {
+			moment.BestSAN = ""
+		}
+		candidates = append(candidates, moment)
+	}
+
+	// Keep the checkmate, then the costliest errors
+	sort.SliceStable(candidates, func(i, j int) bool {
+		iMate := candidates[i].Classification == ClassCheckmate
+		jMate := candidates[j].Classification == ClassCheckmate
+		if iMate != jMate {
+			return iMate
+		}
+		return candidates[i].Loss > candidates[j].Loss
+	})
+	if maxMoments > 0 && len(candidates) > maxMoments {
+		candidates = candidates[:maxMoments]
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Ply < candidates[j].Ply
+	})
+	review.Moments = append(review.Moments, candidates...)
+
+	return review, nil
+}
+
+func clampScore(score int) int {
+	if score > maxReviewScore {
+		return maxReviewScore
+	}
+	if score < -maxReviewScore {
+		return -maxReviewScore
+	}
+	return score
+}
+
+// FormatEval writes a white-relative evaluation the way players read one:
+// "+1.5", "-0.3", "0.0", or "+#" and "-#" for a forced mate
+func FormatEval(score int) string {
+	switch {
+	case score >= mateScore-100:
+		return "+#"
+	case score <= -mateScore+100:
+		return "-#"
+	case score == 0:
+		return "0.0"
+	}
+	return fmt.Sprintf("%+.1f", float64(score)/100)
+}
@@ -0,0 +1,87 @@
+package chess
+
+import "testing"
+
+// playMoves replays moves given as from-to pairs from the starting position
+func playMoves(t *testing.T, squares ...string) []*Move {
+	t.Helper()
+	engine := NewEngine()
+	var moves []*Move
+	for i := 0; i+1 < len(squares); i += 2 {
+		result, err := engine.MakeMove(squares[i], squares[i+1], 0)
+		if err != nil {
+			t.Fatalf("Failed to play %s%s: %v", squares[i], squares[i+1], err)
+		}
+		moves = append(moves, &Move{
+			Ply:  len(moves) + 1,
+			From: result.From,
+			To:   result.To,
+			SAN:  result.SAN,
+			FEN:  result.FEN,
+		})
+	}
+	return moves
+}
+
+func TestReviewGameFindsBlunderAndMate(t *testing.T) {
+	// Fool's mate: 1. f3 e5 2. g4?? Qh4#
+	moves := playMoves(t, "f2", "f3", "e7", "e5", "g2", "g4", "d8", "h4")
+
+	review, err := ReviewGame("", moves, 2, 6)
+	if err != nil {
+		t.Fatalf("ReviewGame failed: %v", err)
+	}
+	if len(review.Evals) != len(moves)+1 {
+		t.Fatalf("Expected %d evaluations, got %d", len(moves)+1, len(review.Evals))
+	}
+	if len(review.Moments) != 2 {
+		t.Fatalf("Expected 2 key moments, got %+v", review.Moments)
+	}
+
+	blunder, mate := review.Moments[0], review.Moments[1]
+	if blunder.Ply != 3 || blunder.Classification != ClassBlunder || blunder.Color != "white" {
+		t.Errorf("Expected 2. g4 to be a blunder by white, got %+v", blunder)
+	}
+	if blunder.Notation() != "2. g4" {
+		t.Errorf("Expected notation 2. g4, got %q", blunder.Notation())
+	}
+	if mate.Ply != 4 || mate.Classification != ClassCheckmate || mate.Notation() != "2... Qh4#" {
+		t.Errorf("Expected 2... Qh4# to be the checkmate, got %+v", mate)
+	}
+}
+
+func TestReviewGameKeepsCostliestMoments(t *testing.T) {
+	// White drops a knight, then black gives up the queen for a bishop
+	moves := playMoves(t,
+		"g1", "f3", "e7", "e5",
+		"f3", "g5", "d8", "g5",
+		"d2", "d3", "g5", "c1",
+		"d1", "c1", "f8", "a3",
+	)
+
+	review, err := ReviewGame(StartingFEN, moves, 2, 1)
+	if err != nil {
+		t.Fatalf("ReviewGame failed: %v", err)
+	}
+	if len(review.Moments) != 1 {
+		t.Fatalf("Expected 1 key moment, got %+v", review.Moments)
+	}
+	if review.Moments[0].Loss < BlunderThreshold {
+		t.Errorf("Expected the costliest blunder to be kept, got %+v", review.Moments[0])
+	}
+}
+
+func TestFormatEval(t *testing.T) {
+	tests := map[int]string{
+		0:              "0.0",
+		150:            "+1.5",
+		-30:            "-0.3",
+		mateScore - 1:  "+#",
+		-mateScore + 2: "-#",
+	}
+	for score, want := range tests {
+		if got := FormatEval(score); got != want {
+			t.Errorf("FormatEval(%d) = %q, want %q", score, got, want)
+		}
+	}
+}
@@ -20,6 +20,7 @@ type TimeControlService struct {
 	// For now, we'll use in-memory tracking
 	gameTimeControls map[string]TimeControl
 	lastMoves        map[string]map[string]time.Time // gameID -> playerDID -> lastMoveTime
+	paused           map[string]time.Time            // gameID -> when the clock was paused
 }
 
 // NewTimeControlService creates a new time control service
@@ -27,6 +28,7 @@ func NewTimeControlService() *TimeControlService {
 	return &TimeControlService{
 		gameTimeControls: make(map[string]TimeControl),
 		lastMoves:        make(map[string]map[string]time.Time),
+		paused:           make(map[string]time.Time),
 	}
 }
 
@@ -46,8 +48,50 @@ func (s *TimeControlService) RecordMove(gameID, playerDID string, moveTime time.
 	s.lastMoves[gameID][playerDID] = moveTime
 }
 
+// Pause stops a game's clock, e.g. while a player reconnects. Pausing an
+// already paused clock keeps the original pause time.
+func (s *TimeControlService) Pause(gameID string, at time.Time) {
+	if _, ok := s.paused[gameID]; !ok {
+		s.paused[gameID] = at
+	}
+}
+
+// Resume restarts a paused clock and returns how long it was paused. Last move
+// times are moved forward by that long so the pause counts against nobody.
+func (s *TimeControlService) Resume(gameID string, at time.Time) time.Duration {
+	pausedAt, ok := s.paused[gameID]
+	if !ok {
+		return 0
+	}
+	delete(s.paused, gameID)
+
+	pause := at.Sub(pausedAt)
+	if pause < 0 {
+		pause = 0
+	}
+	for playerDID, moveTime := range s.lastMoves[gameID] {
+		s.lastMoves[gameID][playerDID] = moveTime.Add(pause)
+	}
+	return pause
+}
+
+// IsPaused reports whether a game's clock is paused
+func (s *TimeControlService) IsPaused(gameID string) bool {
+	_, ok := s.paused[gameID]
+	return ok
+}
+
+// clockTime is the time a game's clock reads: it stands still while paused
+func (s *TimeControlService) clockTime(gameID string, currentTime time.Time) time.Time {
+	if pausedAt, ok := s.paused[gameID]; ok && pausedAt.Before(currentTime) {
+		return pausedAt
+	}
+	return currentTime
+}
+
 // CheckTimeViolation checks if a player has violated time control
 func (s *TimeControlService) CheckTimeViolation(gameID, playerDID string, currentTime time.Time) (*TimeViolation, error) {
+	currentTime = s.clockTime(gameID, currentTime)
 	tc, ok := s.gameTimeControls[gameID]
 	if !ok {
 		return nil, fmt.Errorf("no time control set for game %s", gameID)
@@ -85,6 +129,7 @@ func (s *TimeControlService) CheckTimeViolation(gameID, playerDID string, curren
 
 // GetTimeRemaining returns the time remaining for a player to move
 func (s *TimeControlService) GetTimeRemaining(gameID, playerDID string, currentTime time.Time) (time.Duration, error) {
+	currentTime = s.clockTime(gameID, currentTime)
 	tc, ok := s.gameTimeControls[gameID]
 	if !ok {
 		return 0, fmt.Errorf("no time control set for game %s", gameID)
@@ -112,6 +157,7 @@ func (s *TimeControlService) GetTimeRemaining(gameID, playerDID string, currentT
 
 // CheckAbandonment checks if a game has been abandoned (no moves from either player)
 func (s *TimeControlService) CheckAbandonment(gameID string, currentTime time.Time) (*TimeViolation, error) {
+	currentTime = s.clockTime(gameID, currentTime)
 	tc, ok := s.gameTimeControls[gameID]
 	if !ok {
 		return nil, fmt.Errorf("no time control set for game %s", gameID)
@@ -164,4 +164,42 @@ func TestTimeControlWithNoTimeLimit(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error when getting time remaining for non-time-controlled game")
 	}
-}
\ No newline at end of file
+}
+
+func TestPauseStopsTheClock(t *testing.T) {
+	service := NewTimeControlService()
+	gameID := "paused-game"
+	playerDID := "did:plc:player1"
+	service.SetGameTimeControl(gameID, TimeControl{Type: "correspondence", DaysPerMove: 1})
+
+	moveTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.RecordMove(gameID, playerDID, moveTime)
+
+	// Paused with an hour left; the deadline passes while paused
+	pausedAt := moveTime.Add(23 * time.Hour)
+	service.Pause(gameID, pausedAt)
+	service.Pause(gameID, pausedAt.Add(time.Minute))
+	if !service.IsPaused(gameID) {
+		t.Fatal("Expected clock to be paused")
+	}
+	later := pausedAt.Add(2 * time.Hour)
+	if violation, _ := service.CheckTimeViolation(gameID, playerDID, later); violation != nil {
+		t.Error("Expected no violation while paused")
+	}
+	if remaining, _ := service.GetTimeRemaining(gameID, playerDID, later); remaining != time.Hour {
+		t.Errorf("Expected the clock to stand at 1h while paused, got %v", remaining)
+	}
+
+	if pause := service.Resume(gameID, later); pause != 2*time.Hour {
+		t.Errorf("Expected a 2h pause from the first Pause call, got %v", pause)
+	}
+	if service.IsPaused(gameID) || service.Resume(gameID, later) != 0 {
+		t.Error("Expected clock to be running after Resume")
+	}
+	if remaining, _ := service.GetTimeRemaining(gameID, playerDID, later); remaining != time.Hour {
+		t.Errorf("Expected the pause not to count, got %v remaining", remaining)
+	}
+	if violation, _ := service.CheckTimeViolation(gameID, playerDID, later.Add(2*time.Hour)); violation == nil {
+		t.Error("Expected the clock to run again after resuming")
+	}
+}
@@ -1,5 +1,10 @@
 package chess
 
+import (
+	"fmt"
+	"strings"
+)
+
 type GameStatus string
 
 const (
@@ -13,6 +18,7 @@ const (
 type MoveResult struct {
 	From      string `json:"from"`
 	To        string `json:"to"`
+	Promotion string `json:"promotion,omitempty"`
 	SAN       string `json:"san"`
 	FEN       string `json:"fen"`
 	Check     bool   `json:"check"`
@@ -20,6 +26,27 @@ type MoveResult struct {
 	Draw      bool   `json:"draw"`
 	GameOver  bool   `json:"gameOver"`
 	Result    string `json:"result"`
+	// Status is the game's status after the move under its variant's rules
+	Status    GameStatus `json:"status,omitempty"`
+	// ThinkTimeMs is how long the player spent on the move, in milliseconds
+	ThinkTimeMs int64 `json:"thinkTimeMs,omitempty"`
+}
+
+// Move is a validated half-move from a game's history
+type Move struct {
+	Ply       int    `json:"ply"` // 1-based half-move number
+	URI       string `json:"uri"`
+	CID       string `json:"cid"`
+	Player    string `json:"player"` // DID
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Promotion string `json:"promotion,omitempty"`
+	SAN       string `json:"san"`
+	FEN       string `json:"fen"` // position after the move
+	CreatedAt string `json:"createdAt"`
+	// ThinkTimeMs is how long the player spent on the move, in milliseconds,
+	// if it was recorded
+	ThinkTimeMs int64 `json:"thinkTimeMs,omitempty"`
 }
 
 type Game struct {
@@ -31,6 +58,58 @@ type Game struct {
 	PGN         string      `json:"pgn"`
 	TimeControl *TimeControl `json:"timeControl"`
 	CreatedAt   string      `json:"createdAt"`
+	Variant     string       `json:"variant,omitempty"` // rules the game is played under; empty for standard chess
+	Bot         *BotOpponent `json:"bot,omitempty"` // set when one side is the computer
+	BotAccounts []string     `json:"botAccounts,omitempty"` // players that are bot accounts, playing through the bot API
+	Extensions  Extensions   `json:"extensions,omitempty"` // read-only metadata from other apps
+	Imported    bool         `json:"imported,omitempty"` // played on another site and imported
+	Source      *GameSource  `json:"source,omitempty"` // where an imported game was played
+	UnderReview bool         `json:"underReview,omitempty"` // reported for cheating, pending an operator's review; not part of the record
+}
+
+// GameSource is where an imported game was played, and its players' names
+// there. The opponent, who has no DID, is recorded under the site's did:web.
+type GameSource struct {
+	Site  string `json:"site"` // "lichess", "chess.com", or "pgn" for other games from PGN files
+	ID    string `json:"id"`
+	URL   string `json:"url,omitempty"`
+	White string `json:"white,omitempty"`
+	Black string `json:"black,omitempty"`
+}
+
+// BotOpponent describes the computer's side in a game against an engine
+type BotOpponent struct {
+	Player string `json:"player"` // DID the engine's moves are recorded under
+	Level  int    `json:"level"`
+}
+
+// SideToMove returns "white" or "black" based on the active color field of the game's FEN
+func (g *Game) SideToMove() (string, error) {
+	fields := strings.Fields(g.FEN)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("invalid FEN: %q", g.FEN)
+	}
+	
+	switch fields[1] {
+	case "w":
+		return "white", nil
+	case "b":
+		return "black", nil
+	default:
+		return "", fmt.Errorf("invalid active color in FEN: %q", fields[1])
+	}
+}
+
+// PlayerToMove returns the DID of the player whose turn it is
+func (g *Game) PlayerToMove() (string, error) {
+	side, err := g.SideToMove()
+	if err != nil {
+		return "", err
+	}
+	if side == "white" {
+		return g.White, nil
+	}
+	return g.Black, nil
 }
 
 type TimeControl struct {
@@ -53,6 +132,38 @@ type Challenge struct {
 	ExpiresAt       string
 }
 
+// Seek is an open challenge that anyone in the lobby can accept
+type Seek struct {
+	ID          string       `json:"id"`
+	Player      string       `json:"player"` // DID of the player seeking a game
+	Color       string       `json:"color"`  // the seeker's color: white, black or random
+	TimeControl *TimeControl `json:"timeControl,omitempty"`
+	MinRating   int          `json:"minRating,omitempty"` // 0 for no lower bound
+	MaxRating   int          `json:"maxRating,omitempty"` // 0 for no upper bound
+	CreatedAt   string       `json:"createdAt"`
+	ExpiresAt   string       `json:"expiresAt"`
+}
+
+// AcceptsRating reports whether a player with the given rating may accept the seek
+func (s *Seek) AcceptsRating(rating int) bool {
+	if s.MinRating > 0 && rating < s.MinRating {
+		return false
+	}
+	if s.MaxRating > 0 && rating > s.MaxRating {
+		return false
+	}
+	return true
+}
+
+// ChatMessage is a message a player sent during a game
+type ChatMessage struct {
+	ID        string `json:"id"`     // AT Protocol URI of the message record
+	GameID    string `json:"gameId"` // AT Protocol URI of the game
+	Sender    string `json:"sender"` // DID
+	Text      string `json:"text"`
+	CreatedAt string `json:"createdAt"`
+}
+
 // MaterialCount represents the material count for both sides
 type MaterialCount struct {
 	White int `json:"white"`
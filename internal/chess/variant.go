@@ -0,0 +1,136 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/notnil/chess"
+)
+
+// VariantStandard is the name of standard chess, the variant of games whose
+// record doesn't name one
+const VariantStandard = "standard"
+
+// Variant is a set of rules a game is played under. A variant starts from
+// the standard rules and adjusts them through hooks, so variants can be
+// built up a rule at a time. Moves can only narrow the standard moves, e.g.
+// to make captures compulsory; variants that add moves, such as piece drops,
+// need a move generator of their own first.
+type Variant interface {
+	// Name identifies the variant in game records and the API
+	Name() string
+
+	// StartingFEN is the position games of the variant start from
+	StartingFEN() string
+
+	// Moves returns the legal moves in a position, given the moves that are
+	// legal there under standard rules
+	Moves(position *chess.Position, standard []*chess.Move) []*chess.Move
+
+	// Outcome returns the status of a game in a position, given its status
+	// under standard rules. StatusActive means the game goes on.
+	Outcome(position *chess.Position, standard GameStatus) GameStatus
+}
+
+// ErrUnknownVariant is returned for a variant name that isn't registered
+var ErrUnknownVariant = errors.New("unknown variant")
+
+// plannedVariants are variants games may name that aren't implemented yet
+var plannedVariants = map[string]bool{
+	"crazyhouse": true,
+	"atomic":     true,
+	"antichess":  true,
+}
+
+var (
+	variantsMu sync.RWMutex
+	variants   = map[string]Variant{VariantStandard: Standard{}}
+)
+
+// RegisterVariant makes a variant available to games by its name, replacing
+// any registered under the same name
+func RegisterVariant(variant Variant) {
+	variantsMu.Lock()
+	defer variantsMu.Unlock()
+	variants[variant.Name()] = variant
+}
+
+// LookupVariant returns the registered variant with the given name. An empty
+// name is standard chess.
+func LookupVariant(name string) (Variant, error) {
+	if name == "" {
+		name = VariantStandard
+	}
+
+	variantsMu.RLock()
+	variant, ok := variants[name]
+	variantsMu.RUnlock()
+	if ok {
+		return variant, nil
+	}
+	if plannedVariants[name] {
+		return nil, fmt.Errorf("%w: %s is not supported yet", ErrUnknownVariant, name)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownVariant, name)
+}
+
+// Variants returns the names of the registered variants, sorted
+func Variants() []string {
+	variantsMu.RLock()
+	defer variantsMu.RUnlock()
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Standard is standard chess, with no adjustments to its rules
+type Standard struct{}
+
+func (Standard) Name() string { return VariantStandard }
+
+func (Standard) StartingFEN() string { return StartingFEN }
+
+func (Standard) Moves(position *chess.Position, standard []*chess.Move) []*chess.Move {
+	return standard
+}
+
+func (Standard) Outcome(position *chess.Position, standard GameStatus) GameStatus {
+	return standard
+}
+
+// NewVariantEngine creates an engine playing the named variant from a
+// position, or from the variant's starting position if fen is empty
+func NewVariantEngine(name, fen string) (*Engine, error) {
+	variant, err := LookupVariant(name)
+	if err != nil {
+		return nil, err
+	}
+	if fen == "" {
+		fen = variant.StartingFEN()
+	}
+	engine, err := NewEngineFromFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	engine.variant = variant
+	return engine, nil
+}
+
+// Variant returns the rules the engine plays by
+func (e *Engine) Variant() Variant {
+	if e.variant == nil {
+		return Standard{}
+	}
+	return e.variant
+}
+
+// validMoves returns the moves legal in the current position under the
+// engine's variant
+func (e *Engine) validMoves() []*chess.Move {
+	return e.Variant().Moves(e.game.Position(), e.game.ValidMoves())
+}
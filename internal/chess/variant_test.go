@@ -0,0 +1,145 @@
+package chess
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+// hillVariant makes captures compulsory and wins the game for a side whose
+// king reaches the centre, to exercise both hooks
+type hillVariant struct{}
+
+func (hillVariant) Name() string { return "test-hill" }
+
+func (hillVariant) StartingFEN() string { return StartingFEN }
+
+func (hillVariant) Moves(position *chess.Position, standard []*chess.Move) []*chess.Move {
+	var captures []*chess.Move
+	for _, move := range standard {
+		if move.HasTag(chess.Capture) || move.HasTag(chess.EnPassant) {
+			captures = append(captures, move)
+		}
+	}
+	if len(captures) > 0 {
+		return captures
+	}
+	return standard
+}
+
+func (hillVariant) Outcome(position *chess.Position, standard GameStatus) GameStatus {
+	board := position.Board()
+	for _, square := range []chess.Square{chess.D4, chess.E4, chess.D5, chess.E5} {
+		switch board.Piece(square) {
+		case chess.WhiteKing:
+			return StatusWhiteWon
+		case chess.BlackKing:
+			return StatusBlackWon
+		}
+	}
+	return standard
+}
+
+func TestLookupVariant(t *testing.T) {
+	for _, name := range []string{"", VariantStandard} {
+		variant, err := LookupVariant(name)
+		if err != nil {
+			t.Fatalf("LookupVariant(%q): %v", name, err)
+		}
+		if variant.Name() != VariantStandard {
+			t.Errorf("LookupVariant(%q) = %s, want standard", name, variant.Name())
+		}
+	}
+
+	for _, name := range []string{"atomic", "nonsense"} {
+		if _, err := LookupVariant(name); !errors.Is(err, ErrUnknownVariant) {
+			t.Errorf("LookupVariant(%q) error = %v, want ErrUnknownVariant", name, err)
+		}
+	}
+}
+
+func TestRegisterVariant(t *testing.T) {
+	RegisterVariant(hillVariant{})
+
+	found := false
+	for _, name := range Variants() {
+		if name == "test-hill" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected test-hill in %v", Variants())
+	}
+}
+
+func TestVariantNarrowsMoves(t *testing.T) {
+	RegisterVariant(hillVariant{})
+
+	// After 1. e4 d5 white has to take on d5
+	engine, err := NewVariantEngine("test-hill", "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moves := engine.LegalMoves()
+	if len(moves) != 1 || moves[0].SAN != "exd5" {
+		t.Fatalf("Expected only exd5, got %v", moves)
+	}
+	if _, err := engine.MakeMove("g1", "f3", chess.NoPieceType); err == nil {
+		t.Error("Expected a non-capture to be rejected while a capture is available")
+	}
+	if _, err := engine.MakeMove("e4", "d5", chess.NoPieceType); err != nil {
+		t.Errorf("Expected exd5 to be played, got %v", err)
+	}
+}
+
+func TestVariantDecidesOutcome(t *testing.T) {
+	RegisterVariant(hillVariant{})
+
+	engine, err := NewVariantEngine("test-hill", "k7/p7/8/8/8/3K4/P7/8 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := engine.MakeMove("d3", "d4", chess.NoPieceType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.GameOver || result.Status != StatusWhiteWon {
+		t.Errorf("Expected white to win on reaching the centre, got %+v", result)
+	}
+	if result.Result != "1-0" {
+		t.Errorf("Expected result 1-0, got %q", result.Result)
+	}
+	if moves := engine.LegalMoves(); len(moves) != 0 {
+		t.Errorf("Expected no legal moves once the game is over, got %d", len(moves))
+	}
+
+	// The same move under standard rules doesn't end the game
+	standard, _ := NewEngineFromFEN("k7/p7/8/8/8/3K4/P7/8 w - - 0 1")
+	result, err = standard.MakeMove("d3", "d4", chess.NoPieceType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.GameOver || result.Status != StatusActive {
+		t.Errorf("Expected the standard game to go on, got %+v", result)
+	}
+}
+
+func TestNewVariantEngineStartsFromVariantPosition(t *testing.T) {
+	engine, err := NewVariantEngine("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if engine.GetFEN() != StartingFEN {
+		t.Errorf("Expected the starting position, got %s", engine.GetFEN())
+	}
+	if engine.Variant().Name() != VariantStandard {
+		t.Errorf("Expected standard rules, got %s", engine.Variant().Name())
+	}
+
+	if _, err := NewVariantEngine("crazyhouse", ""); !errors.Is(err, ErrUnknownVariant) {
+		t.Errorf("Expected ErrUnknownVariant for crazyhouse, got %v", err)
+	}
+}
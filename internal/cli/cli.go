@@ -0,0 +1,112 @@
+// Package cli runs the subcommands of the command-line tools, such as
+// atchess and atchess-admin: it picks the subcommand named on the command
+// line, prints usage and help, and turns errors into exit statuses.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+)
+
+// ErrUsage is returned by commands given the wrong arguments, after printing
+// their usage
+var ErrUsage = errors.New("usage")
+
+// ErrReported is returned by commands that ran but found something wrong,
+// to exit non-zero without repeating what they printed
+var ErrReported = errors.New("problems found")
+
+// Command is a subcommand. Run gets what the tool sets up for every command,
+// such as a logged-in client, and the arguments after the command's name.
+type Command[E any] struct {
+	Usage   string
+	Summary string
+	Run     func(ctx context.Context, env E, args []string) error
+}
+
+// Tool is a command-line tool run as NAME COMMAND [ARGS]
+type Tool[E any] struct {
+	Name     string
+	Commands map[string]Command[E]
+	// Order is the order commands are listed in the help
+	Order []string
+	// Help prints the tool's help, listing the commands with PrintCommands
+	Help func()
+	// Setup prepares what every command gets, once the command is known
+	Setup func(ctx context.Context) (E, error)
+}
+
+// Main runs the command named by the process's arguments and exits
+func (t *Tool[E]) Main() {
+	os.Exit(t.Run(os.Args[1:]))
+}
+
+// Run runs the command named by args[0] and returns the exit status: 0 on
+// success, 1 when the command fails and 2 on a usage error
+func (t *Tool[E]) Run(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		t.Help()
+		return 0
+	}
+	cmd, ok := t.Commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		t.Help()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	env, err := t.Setup(ctx)
+	if err == nil {
+		err = cmd.Run(ctx, env, args[1:])
+	}
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, ErrUsage):
+		return 2
+	case !errors.Is(err, ErrReported):
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+	return 1
+}
+
+// FlagSet creates the flags of a command, whose usage prints the command's
+func (t *Tool[E]) FlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s\n", t.Name, t.Commands[name].Usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// PrintCommands lists the commands with their summaries, for the help
+func (t *Tool[E]) PrintCommands(w io.Writer) {
+	out := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range t.Order {
+		fmt.Fprintf(out, "    %s\t%s\n", t.Commands[name].Usage, t.Commands[name].Summary)
+	}
+	out.Flush()
+}
+
+// ParseArgs parses a command's flags and checks it got n arguments
+func ParseArgs(flags *flag.FlagSet, args []string, n int) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() != n {
+		flags.Usage()
+		return nil, ErrUsage
+	}
+	return flags.Args(), nil
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func testTool(setupErr error) (*Tool[string], *[]string) {
+	var ran []string
+	tool := &Tool[string]{Name: "test"}
+	tool.Commands = map[string]Command[string]{
+		"echo": {
+			Usage: "echo [--upper] WORD",
+			Run: func(ctx context.Context, env string, args []string) error {
+				flags := tool.FlagSet("echo")
+				flags.Bool("upper", false, "shout")
+				args, err := ParseArgs(flags, args, 1)
+				if err != nil {
+					return err
+				}
+				ran = append(ran, env+":"+args[0])
+				return nil
+			},
+		},
+		"check": {
+			Run: func(ctx context.Context, env string, args []string) error { return ErrReported },
+		},
+		"fail": {
+			Run: func(ctx context.Context, env string, args []string) error { return errors.New("broken") },
+		},
+	}
+	tool.Order = []string{"echo", "check", "fail"}
+	tool.Help = func() {}
+	tool.Setup = func(ctx context.Context) (string, error) { return "env", setupErr }
+	return tool, &ran
+}
+
+func TestRunExitStatuses(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected int
+	}{
+		{args: nil, expected: 0},
+		{args: []string{"help"}, expected: 0},
+		{args: []string{"unknown"}, expected: 2},
+		{args: []string{"echo", "hello"}, expected: 0},
+		{args: []string{"echo", "--help"}, expected: 0},
+		{args: []string{"echo"}, expected: 2},
+		{args: []string{"echo", "--bogus", "hello"}, expected: 1},
+		{args: []string{"check"}, expected: 1},
+		{args: []string{"fail"}, expected: 1},
+	}
+
+	for _, tc := range testCases {
+		tool, _ := testTool(nil)
+		if status := tool.Run(tc.args); status != tc.expected {
+			t.Errorf("Expected %v to exit %d, got %d", tc.args, tc.expected, status)
+		}
+	}
+}
+
+func TestRunGivesCommandsTheSetUpEnvironment(t *testing.T) {
+	tool, ran := testTool(nil)
+	if status := tool.Run([]string{"echo", "--upper", "hello"}); status != 0 || len(*ran) != 1 || (*ran)[0] != "env:hello" {
+		t.Errorf("Expected the command to run with the environment, got %d %v", status, *ran)
+	}
+
+	tool, ran = testTool(errors.New("no credentials"))
+	if status := tool.Run([]string{"echo", "hello"}); status != 1 || len(*ran) != 0 {
+		t.Errorf("Expected a failed setup to stop the command, got %d %v", status, *ran)
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// ClientConfig is what the command-line clients need to act as a player
+type ClientConfig struct {
+	// Server is the protocol service's API, e.g. http://localhost:8080/api/v1
+	Server   string `mapstructure:"server"`
+	Handle   string `mapstructure:"handle"`
+	Password string `mapstructure:"password"`
+}
+
+// DefaultClientConfigPath is where LoadClient looks for a client
+// configuration when it isn't given one: atchess/cli.yaml in the user's
+// configuration directory
+func DefaultClientConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "atchess", "cli.yaml")
+}
+
+// LoadClient reads a client configuration from path, or the default path when
+// it's empty. The file is optional; ATCHESS_SERVER, ATCHESS_HANDLE and
+// ATCHESS_PASSWORD override it.
+func LoadClient(path string) (*ClientConfig, error) {
+	v := viper.New()
+	v.SetDefault("server", "http://localhost:8080/api/v1")
+	v.SetEnvPrefix("ATCHESS")
+	for _, key := range []string{"server", "handle", "password"} {
+		v.BindEnv(key)
+	}
+
+	explicit := path != ""
+	if !explicit {
+		path = DefaultClientConfigPath()
+	}
+	if path != "" {
+		v.SetConfigFile(path)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			// Only a file that was asked for has to exist
+			var notFound *os.PathError
+			if explicit || !errors.As(err, &notFound) {
+				return nil, err
+			}
+		}
+	}
+
+	var cfg ClientConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
@@ -2,34 +2,140 @@ package config
 
 import (
 	"fmt"
-	"strings"
+	"time"
 
+	"github.com/justinabrahms/atchess/internal/secrets"
 	"github.com/spf13/viper"
 )
 
+// Config is the protocol service's configuration. Fields tagged redact hold
+// secrets, which Print leaves out; see Load for where values come from.
 type Config struct {
 	Server      ServerConfig      `mapstructure:"server"`
 	ATProto     ATProtoConfig     `mapstructure:"atproto"`
 	Development DevelopmentConfig `mapstructure:"development"`
 	Firehose    FirehoseConfig    `mapstructure:"firehose"`
+	Spectator   SpectatorConfig   `mapstructure:"spectator"`
+	Federation  FederationConfig  `mapstructure:"federation"`
+	Index       IndexConfig       `mapstructure:"index"`
+	PublicAPI   PublicAPIConfig   `mapstructure:"public_api"`
+	LiveGames   LiveGamesConfig   `mapstructure:"live_games"`
+	Bot         BotConfig         `mapstructure:"bot"`
+	Matchmaking MatchmakingConfig `mapstructure:"matchmaking"`
+	Tracing     TracingConfig     `mapstructure:"tracing"`
+	RateLimit   RateLimitConfig   `mapstructure:"rate_limit"`
+	Webhooks    WebhooksConfig    `mapstructure:"webhooks"`
+	BotAPI      BotAPIConfig      `mapstructure:"bot_api"`
+	Broker      BrokerConfig      `mapstructure:"broker"`
+	Push        PushConfig        `mapstructure:"push"`
+	Email       EmailConfig       `mapstructure:"email"`
+	Identity    IdentityConfig    `mapstructure:"identity"`
+	OAuth       OAuthConfig       `mapstructure:"oauth"`
+	Scheduler   SchedulerConfig   `mapstructure:"scheduler"`
+	DrawOffers  DrawOffersConfig  `mapstructure:"draw_offers"`
+	Challenges  ChallengesConfig  `mapstructure:"challenges"`
+	Moderation  ModerationConfig  `mapstructure:"moderation"`
+	AntiCheat   AntiCheatConfig   `mapstructure:"anticheat"`
 }
 
 type ServerConfig struct {
 	Host    string `mapstructure:"host"`
 	Port    int    `mapstructure:"port"`
 	BaseURL string `mapstructure:"base_url"`
+	// OperatorDIDs may post server announcements
+	OperatorDIDs []string `mapstructure:"operator_dids"`
+	// ServeUI serves the web interface alongside the API, so one process
+	// runs the whole site
+	ServeUI bool `mapstructure:"serve_ui"`
+	// StaticDir serves the web interface from a directory instead of the
+	// files built into the binary
+	StaticDir string    `mapstructure:"static_dir"`
+	TLS       TLSConfig `mapstructure:"tls"`
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies in
+	// front of the server. Requests from them are rate limited by the client
+	// address they put in X-Forwarded-For; the header is ignored from anyone
+	// else, who could otherwise pick their own address.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
+// TLSConfig serves HTTPS directly, with the certificate in CertFile and
+// KeyFile or, with Autocert, certificates for Domains from Let's Encrypt
+// (or the ACME server at DirectoryURL) kept in CacheDir. While it's on, plain
+// HTTP on RedirectAddr is redirected to HTTPS and answers ACME challenges;
+// an empty RedirectAddr turns that off.
+type TLSConfig struct {
+	CertFile     string   `mapstructure:"cert_file"`
+	KeyFile      string   `mapstructure:"key_file"`
+	Autocert     bool     `mapstructure:"autocert"`
+	Domains      []string `mapstructure:"domains"`
+	Email        string   `mapstructure:"email"`
+	CacheDir     string   `mapstructure:"cache_dir"`
+	DirectoryURL string   `mapstructure:"directory_url"`
+	RedirectAddr string   `mapstructure:"redirect_addr"`
+}
+
+// Enabled reports whether HTTPS is configured
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.Autocert
+}
+
+// ATProtoConfig is the service account and the PDS it's on. AppPassword is
+// used in preference to Password, which is the account's main password.
+// With RequireAppPasswords, players can't log in with their main passwords
+// either.
 type ATProtoConfig struct {
-	PDSURL    string `mapstructure:"pds_url"`
-	Handle    string `mapstructure:"handle"`
-	Password  string `mapstructure:"password"`
-	UseDPoP   bool   `mapstructure:"use_dpop"`
+	PDSURL              string      `mapstructure:"pds_url"`
+	Handle              string      `mapstructure:"handle"`
+	Password            string      `mapstructure:"password" redact:"secret"`
+	AppPassword         string      `mapstructure:"app_password" redact:"secret"`
+	RequireAppPasswords bool        `mapstructure:"require_app_passwords"`
+	UseDPoP             bool        `mapstructure:"use_dpop"`
+	Retry               RetryConfig `mapstructure:"retry"`
+	// ListPageSize is how many records are requested in each page when
+	// listing a repository's collection, at most 100
+	ListPageSize int `mapstructure:"list_page_size"`
+	// RecordCache keeps records read from PDSes, such as games, for reuse
+	RecordCache RecordCacheConfig `mapstructure:"record_cache"`
+}
+
+// ServicePassword returns the password the service account logs in with
+func (c ATProtoConfig) ServicePassword() string {
+	if c.AppPassword != "" {
+		return c.AppPassword
+	}
+	return c.Password
+}
+
+// RetryConfig controls how PDS requests are retried after transient failures.
+// MaxAttempts includes the first request; 1 disables retries.
+type RetryConfig struct {
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	BaseDelay   time.Duration `mapstructure:"base_delay"`
+	MaxDelay    time.Duration `mapstructure:"max_delay"`
+}
+
+// RecordCacheConfig sizes the cache of records read from PDSes. Size 0
+// disables it; TTL bounds how stale a record changed elsewhere can be when
+// the firehose doesn't report it.
+type RecordCacheConfig struct {
+	Size int           `mapstructure:"size"`
+	TTL  time.Duration `mapstructure:"ttl"`
 }
 
 type DevelopmentConfig struct {
-	Debug    bool   `mapstructure:"debug"`
-	LogLevel string `mapstructure:"log_level"`
+	Debug          bool                 `mapstructure:"debug"`
+	LogLevel       string               `mapstructure:"log_level"`
+	FaultInjection FaultInjectionConfig `mapstructure:"fault_injection"`
+}
+
+// FaultInjectionConfig simulates a slow or flaky network for resilience testing.
+// It only takes effect when development.debug is also enabled.
+type FaultInjectionConfig struct {
+	Enabled                    bool          `mapstructure:"enabled"`
+	Latency                    time.Duration `mapstructure:"latency"`
+	LatencyJitter              time.Duration `mapstructure:"latency_jitter"`
+	PDSErrorRate               float64       `mapstructure:"pds_error_rate"`
+	FirehoseDisconnectInterval time.Duration `mapstructure:"firehose_disconnect_interval"`
 }
 
 type FirehoseConfig struct {
@@ -37,74 +143,384 @@ type FirehoseConfig struct {
 	URL     string `mapstructure:"url"`
 }
 
+type SpectatorConfig struct {
+	KibitzEnabled  bool          `mapstructure:"kibitz_enabled"`
+	KibitzDepth    int           `mapstructure:"kibitz_depth"`
+	KibitzInterval time.Duration `mapstructure:"kibitz_interval"`
+	TVInterval     time.Duration `mapstructure:"tv_interval"` // how often the featured game is reconsidered
+}
+
+type FederationConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	Name            string        `mapstructure:"name"`
+	Description     string        `mapstructure:"description"`
+	Peers           []string      `mapstructure:"peers"`
+	AcceptInbound   bool          `mapstructure:"accept_inbound"`
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+// IndexConfig selects where games seen on the firehose are indexed.
+// Driver "memory" keeps the index in memory; any other value is a
+// database/sql driver name (e.g. "sqlite" or "postgres") that must be
+// linked into the binary.
+type IndexConfig struct {
+	Driver string `mapstructure:"driver"`
+	DSN    string `mapstructure:"dsn" redact:"url"`
+}
+
+// PublicAPIConfig controls the unauthenticated read-only API under /public/v1.
+// Requests are limited per IP address; requests with one of APIKeys get the
+// higher KeyRequestsPerMinute limit instead.
+type PublicAPIConfig struct {
+	Enabled              bool          `mapstructure:"enabled"`
+	RequestsPerMinute    int           `mapstructure:"requests_per_minute"`
+	KeyRequestsPerMinute int           `mapstructure:"key_requests_per_minute"`
+	APIKeys              []string      `mapstructure:"api_keys" redact:"secret"`
+	CacheTTL             time.Duration `mapstructure:"cache_ttl"`
+}
+
+// LiveGamesConfig controls what happens when a player's connection to a game
+// drops while their opponent is connected. DisconnectPolicy is one of "off",
+// "announce" (tell the opponent), "pause" (also pause the clock for
+// GracePeriod) or "forfeit" (pause, then forfeit if they haven't returned).
+type LiveGamesConfig struct {
+	DisconnectPolicy string        `mapstructure:"disconnect_policy"`
+	GracePeriod      time.Duration `mapstructure:"grace_period"`
+}
+
+// BotConfig enables playing against a UCI engine such as Stockfish.
+// ThinkTime is how long the strongest level thinks per move. With
+// OpeningBook the engine's first moves come from a built-in opening book,
+// varied between games against the same player; BookMoves optionally sets
+// how many moves that is at each level, weakest first. The same engine
+// analyses finished games on request, searching each position to
+// AnalysisDepth.
+type BotConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	EnginePath    string        `mapstructure:"engine_path"`
+	ThinkTime     time.Duration `mapstructure:"think_time"`
+	OpeningBook   bool          `mapstructure:"opening_book"`
+	BookMoves     []int         `mapstructure:"book_moves"`
+	AnalysisDepth int           `mapstructure:"analysis_depth"`
+}
+
+// MatchmakingConfig controls the matchmaking queue. Players are paired within
+// rating bands RatingBand points wide; after waiting WidenAfter a player may
+// also be paired with the neighbouring bands, and so on. The queue is checked
+// for pairs every PairInterval.
+type MatchmakingConfig struct {
+	RatingBand   int           `mapstructure:"rating_band"`
+	WidenAfter   time.Duration `mapstructure:"widen_after"`
+	PairInterval time.Duration `mapstructure:"pair_interval"`
+}
+
+// TracingConfig exports OpenTelemetry traces over OTLP/HTTP. Endpoint is the
+// collector's URL, e.g. "http://localhost:4318"; when empty the standard
+// OTEL_EXPORTER_OTLP_* environment variables apply. Headers are sent with
+// every export, e.g. for a hosted collector's API key. SampleRatio is the
+// fraction of new traces recorded.
+type TracingConfig struct {
+	Enabled     bool              `mapstructure:"enabled"`
+	Endpoint    string            `mapstructure:"otlp_endpoint"`
+	Headers     map[string]string `mapstructure:"otlp_headers" redact:"secret"`
+	ServiceName string            `mapstructure:"service_name"`
+	SampleRatio float64           `mapstructure:"sample_ratio"`
+}
+
+// RateLimitConfig limits API requests per IP address and, for logged-in
+// users, per session. Logging in and creating challenges have their own
+// stricter limits on top, to stop password guessing and challenge spam.
+type RateLimitConfig struct {
+	Enabled                  bool `mapstructure:"enabled"`
+	RequestsPerMinute        int  `mapstructure:"requests_per_minute"`
+	SessionRequestsPerMinute int  `mapstructure:"session_requests_per_minute"`
+	LoginsPerMinute          int  `mapstructure:"logins_per_minute"`
+	ChallengesPerMinute      int  `mapstructure:"challenges_per_minute"`
+}
+
+// WebhooksConfig controls delivery of game events to players' webhooks,
+// which needs the firehose. Private addresses are refused unless allowed,
+// which is only meant for local development. Registrations are kept by
+// Driver as for the index: "memory" loses them on restart and keeps them to
+// one replica; a database/sql driver name (e.g. "sqlite" or "postgres")
+// keeps them at DSN.
+type WebhooksConfig struct {
+	Enabled               bool          `mapstructure:"enabled"`
+	Driver                string        `mapstructure:"driver"`
+	DSN                   string        `mapstructure:"dsn" redact:"url"`
+	MaxAttempts           int           `mapstructure:"max_attempts"`
+	InitialBackoff        time.Duration `mapstructure:"initial_backoff"`
+	AllowPrivateAddresses bool          `mapstructure:"allow_private_addresses"`
+}
+
+// BotAPIConfig opens the bot API to engine developers. Accounts are the DIDs
+// whose games are played by programs; they may create bot API tokens, and
+// games they play are marked as bot games. PollTimeout is the longest a
+// request for events waits for one to arrive.
+type BotAPIConfig struct {
+	Accounts    []string      `mapstructure:"accounts"`
+	PollTimeout time.Duration `mapstructure:"poll_timeout"`
+}
+
+// BrokerConfig shares WebSocket updates between replicas of the protocol
+// service. The "memory" driver keeps them to this process, which is all a
+// single replica needs; "redis" publishes them on Channel of the Redis
+// server at RedisURL.
+type BrokerConfig struct {
+	Driver   string `mapstructure:"driver"`
+	RedisURL string `mapstructure:"redis_url" redact:"url"`
+	Channel  string `mapstructure:"channel"`
+}
+
+// PushConfig controls Web Push notifications of moves and challenges, which
+// need the firehose. The VAPID keys are base64url encoded and required, since
+// browsers subscribed with one key can't be reached with another. Subject is
+// an email address or https URL push services can use to contact the
+// operator. Subscriptions are kept by Driver as for the index: "memory" loses
+// them on restart and keeps them to one replica; a database/sql driver name
+// (e.g. "sqlite" or "postgres") keeps them at DSN.
+type PushConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	Driver          string        `mapstructure:"driver"`
+	DSN             string        `mapstructure:"dsn" redact:"url"`
+	VAPIDPublicKey  string        `mapstructure:"vapid_public_key"`
+	VAPIDPrivateKey string        `mapstructure:"vapid_private_key" redact:"secret"`
+	Subject         string        `mapstructure:"subject"`
+	TTL             time.Duration `mapstructure:"ttl"`
+}
+
+// EmailConfig controls the email digest sent through the SMTP server at
+// SMTPHost to players who opt in. Every DigestInterval each player is told
+// about games where it's been their move for PendingAfter, correspondence
+// clocks with less than ExpiryWarning left, and challenges received since the
+// last digest. Addresses come from players' PDS accounts when they log in.
+type EmailConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	SMTPHost       string        `mapstructure:"smtp_host"`
+	SMTPPort       int           `mapstructure:"smtp_port"`
+	Username       string        `mapstructure:"username"`
+	Password       string        `mapstructure:"password" redact:"secret"`
+	From           string        `mapstructure:"from"`
+	DigestInterval time.Duration `mapstructure:"digest_interval"`
+	PendingAfter   time.Duration `mapstructure:"pending_after"`
+	ExpiryWarning  time.Duration `mapstructure:"expiry_warning"`
+}
+
+// IdentityConfig controls how handles and DIDs are resolved. did:plc
+// documents are read from PLCDirectories, trying each mirror in turn, before
+// falling back to asking the PDS. Each request gives up after Timeout, and up
+// to CacheSize resolutions are trusted for CacheTTL.
+type IdentityConfig struct {
+	PLCDirectories []string      `mapstructure:"plc_directories"`
+	Timeout        time.Duration `mapstructure:"timeout"`
+	CacheSize      int           `mapstructure:"cache_size"`
+	CacheTTL       time.Duration `mapstructure:"cache_ttl"`
+}
+
+// OAuthConfig selects where user sessions, OAuth sessions and pending logins
+// are kept. Driver "memory" keeps them in memory, so restarts log everyone
+// out and replicas don't share sessions; "redis" keeps them at RedisURL; any
+// other value is a database/sql driver name (e.g. "sqlite" or "postgres")
+// that must be linked into the binary. EncryptionKey, 32 base64-encoded
+// bytes, encrypts sessions' tokens and DPoP keys and is required for every
+// driver but memory.
+type OAuthConfig struct {
+	Driver        string `mapstructure:"driver"`
+	DSN           string `mapstructure:"dsn" redact:"url"`
+	RedisURL      string `mapstructure:"redis_url" redact:"url"`
+	EncryptionKey string `mapstructure:"encryption_key" redact:"secret"`
+}
+
+// SchedulerConfig controls background jobs that finalize correspondence
+// games whose clocks have run out, expire stale challenges and sweep
+// superseded draw offers. Jobs are kept by Driver as for the index: "memory"
+// loses pending timers on restart, and they're rebuilt from the index every
+// DeadlineSyncInterval; a database/sql driver name (e.g. "sqlite" or
+// "postgres") keeps them at DSN. Due jobs are looked for every PollInterval.
+type SchedulerConfig struct {
+	Enabled              bool          `mapstructure:"enabled"`
+	Driver               string        `mapstructure:"driver"`
+	DSN                  string        `mapstructure:"dsn" redact:"url"`
+	PollInterval         time.Duration `mapstructure:"poll_interval"`
+	DeadlineSyncInterval time.Duration `mapstructure:"deadline_sync_interval"`
+}
+
+// DrawOffersConfig controls how long draw offers stay open. An offer always
+// lapses when the offering player makes their next move; after Timeout
+// without an answer it expires too. Zero leaves offers open until then.
+type DrawOffersConfig struct {
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// ChallengesConfig limits challenges between two players. MaxPendingPerOpponent
+// is how many unanswered challenges one player may have out to another;
+// notifications past it aren't listed either. Zero is no limit.
+type ChallengesConfig struct {
+	MaxPendingPerOpponent int `mapstructure:"max_pending_per_opponent"`
+}
+
+// ModerationConfig controls what happens to reported players before an
+// operator reviews them. Once HideSeeksAfter different players have open
+// reports against someone, their seeks are left out of the lobby. Zero only
+// hides seeks of players whose reports an operator has acted on.
+type ModerationConfig struct {
+	HideSeeksAfter int `mapstructure:"hide_seeks_after"`
+}
+
+// AntiCheatConfig controls the background analysis of finished rated games.
+// The bot's engine scores each player from 0 to 100 by how often they played
+// its first choice and how evenly they spent their time; games where a
+// player scores FlagScore or more are listed for operators. It needs the bot
+// engine and the firehose's game index.
+type AntiCheatConfig struct {
+	Enabled   bool `mapstructure:"enabled"`
+	FlagScore int  `mapstructure:"flag_score"`
+}
+
+// Options changes where LoadOptions reads configuration from
+type Options struct {
+	// File is read instead of looking for config.yaml, and must exist
+	File string
+	// Overrides set keys such as "server.port", taking precedence over the
+	// file and the environment; see Flags
+	Overrides map[string]string
+	// Secrets looks up references in secret settings; secrets.NewResolver()
+	// when nil
+	Secrets *secrets.Resolver
+}
+
+// Load reads config.yaml from the current directory or ./config, if there is
+// one, applies ATCHESS_ environment variables on top and validates the result
 func Load() (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("./config")
-	
-	// Enable environment variables
-	viper.SetEnvPrefix("ATCHESS")
-	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	
-	// Also bind specific environment variables for compatibility
-	// This allows both ATCHESS_ prefixed and unprefixed versions
-	viper.BindEnv("server.host", "SERVER_HOST", "ATCHESS_SERVER_HOST")
-	viper.BindEnv("server.port", "SERVER_PORT", "ATCHESS_SERVER_PORT")
-	viper.BindEnv("server.base_url", "SERVER_BASE_URL", "ATCHESS_SERVER_BASE_URL")
-	viper.BindEnv("atproto.pds_url", "ATPROTO_PDS_URL", "ATCHESS_ATPROTO_PDS_URL")
-	viper.BindEnv("atproto.handle", "ATPROTO_HANDLE", "ATCHESS_ATPROTO_HANDLE")
-	viper.BindEnv("atproto.password", "ATPROTO_PASSWORD", "ATCHESS_ATPROTO_PASSWORD")
-	viper.BindEnv("atproto.use_dpop", "ATPROTO_USE_DPOP", "ATCHESS_ATPROTO_USE_DPOP")
-	viper.BindEnv("development.debug", "DEVELOPMENT_DEBUG", "ATCHESS_DEVELOPMENT_DEBUG")
-	viper.BindEnv("development.log_level", "DEVELOPMENT_LOG_LEVEL", "ATCHESS_DEVELOPMENT_LOG_LEVEL")
-	viper.BindEnv("firehose.enabled", "FIREHOSE_ENABLED", "ATCHESS_FIREHOSE_ENABLED")
-	viper.BindEnv("firehose.url", "FIREHOSE_URL", "ATCHESS_FIREHOSE_URL")
-	
-	// Set defaults
-	viper.SetDefault("server.host", "localhost")
-	viper.SetDefault("server.port", 8080)
-	viper.SetDefault("atproto.pds_url", "http://localhost:3000")
-	viper.SetDefault("atproto.use_dpop", false)
-	viper.SetDefault("development.debug", false)
-	viper.SetDefault("development.log_level", "info")
-	viper.SetDefault("firehose.enabled", false)
-	viper.SetDefault("firehose.url", "wss://bsky.social/xrpc/com.atproto.sync.subscribeRepos")
-	
-	// Read config
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			// Config file not found, use defaults
-			return loadDefaults(), nil
+	return LoadOptions(Options{})
+}
+
+// LoadOptions is Load with a config file or overrides from the command line.
+// Every key can be set from the environment as ATCHESS_ and the key in upper
+// case with dots as underscores, e.g. ATCHESS_SERVER_PORT for server.port.
+// Settings holding secrets may instead be references such as
+// file:///run/secrets/pds_password (see secrets.NewResolver), or be read from
+// the file named by their variable with _FILE added.
+func LoadOptions(opts Options) (*Config, error) {
+	v := viper.New()
+	if opts.File != "" {
+		v.SetConfigFile(opts.File)
+	} else {
+		v.SetConfigName("config")
+		v.SetConfigType("yaml")
+		v.AddConfigPath(".")
+		v.AddConfigPath("./config")
+	}
+
+	bindEnv(v)
+	setDefaults(v)
+
+	if err := v.ReadInConfig(); err != nil {
+		// Without a config file, the defaults and environment are used
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok || opts.File != "" {
+			return nil, fmt.Errorf("failed to read config: %w", err)
 		}
-		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
-	
+	setSecretFiles(v)
+	for key, value := range opts.Overrides {
+		v.Set(key, value)
+	}
+	if err := checkKeys(v.AllKeys()); err != nil {
+		return nil, err
+	}
+
 	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
+	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
-	
+	if opts.Secrets == nil {
+		opts.Secrets = secrets.NewResolver()
+	}
+	if err := resolveSecrets(&cfg, opts.Secrets); err != nil {
+		return nil, fmt.Errorf("failed to load secret: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	return &cfg, nil
 }
 
-func loadDefaults() *Config {
-	return &Config{
-		Server: ServerConfig{
-			Host: "localhost",
-			Port: 8080,
-		},
-		ATProto: ATProtoConfig{
-			PDSURL: "http://localhost:3000",
-		},
-		Development: DevelopmentConfig{
-			Debug:    false,
-			LogLevel: "info",
-		},
-		Firehose: FirehoseConfig{
-			Enabled: false,
-			URL:     "wss://bsky.social/xrpc/com.atproto.sync.subscribeRepos",
-		},
-	}
-}
\ No newline at end of file
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server.host", "localhost")
+	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.serve_ui", true)
+	v.SetDefault("server.tls.cache_dir", "certs")
+	v.SetDefault("server.tls.redirect_addr", ":80")
+	v.SetDefault("atproto.pds_url", "http://localhost:3000")
+	v.SetDefault("atproto.use_dpop", false)
+	v.SetDefault("atproto.retry.max_attempts", 4)
+	v.SetDefault("atproto.retry.base_delay", 200*time.Millisecond)
+	v.SetDefault("atproto.retry.max_delay", 5*time.Second)
+	v.SetDefault("atproto.list_page_size", 100)
+	v.SetDefault("atproto.record_cache.size", 1000)
+	v.SetDefault("atproto.record_cache.ttl", 30*time.Second)
+	v.SetDefault("development.debug", false)
+	v.SetDefault("development.log_level", "info")
+	v.SetDefault("development.fault_injection.enabled", false)
+	v.SetDefault("firehose.enabled", false)
+	v.SetDefault("firehose.url", "wss://bsky.social/xrpc/com.atproto.sync.subscribeRepos")
+	v.SetDefault("spectator.kibitz_enabled", false)
+	v.SetDefault("spectator.kibitz_depth", 2)
+	v.SetDefault("spectator.kibitz_interval", 5*time.Second)
+	v.SetDefault("spectator.tv_interval", 10*time.Second)
+	v.SetDefault("federation.enabled", false)
+	v.SetDefault("federation.name", "ATChess")
+	v.SetDefault("federation.accept_inbound", false)
+	v.SetDefault("federation.refresh_interval", 10*time.Minute)
+	v.SetDefault("index.driver", "memory")
+	v.SetDefault("public_api.enabled", false)
+	v.SetDefault("public_api.requests_per_minute", 30)
+	v.SetDefault("public_api.key_requests_per_minute", 600)
+	v.SetDefault("public_api.cache_ttl", 5*time.Minute)
+	v.SetDefault("live_games.disconnect_policy", "pause")
+	v.SetDefault("live_games.grace_period", time.Minute)
+	v.SetDefault("bot.enabled", false)
+	v.SetDefault("bot.engine_path", "stockfish")
+	v.SetDefault("bot.think_time", 2*time.Second)
+	v.SetDefault("bot.opening_book", true)
+	v.SetDefault("bot.analysis_depth", 14)
+	v.SetDefault("matchmaking.rating_band", 200)
+	v.SetDefault("matchmaking.widen_after", 30*time.Second)
+	v.SetDefault("matchmaking.pair_interval", 2*time.Second)
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("tracing.service_name", "atchess")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+	v.SetDefault("rate_limit.enabled", true)
+	v.SetDefault("rate_limit.requests_per_minute", 300)
+	v.SetDefault("rate_limit.session_requests_per_minute", 600)
+	v.SetDefault("rate_limit.logins_per_minute", 10)
+	v.SetDefault("rate_limit.challenges_per_minute", 10)
+	v.SetDefault("webhooks.enabled", true)
+	v.SetDefault("webhooks.driver", "memory")
+	v.SetDefault("webhooks.max_attempts", 5)
+	v.SetDefault("webhooks.initial_backoff", 2*time.Second)
+	v.SetDefault("bot_api.poll_timeout", 30*time.Second)
+	v.SetDefault("broker.driver", "memory")
+	v.SetDefault("broker.channel", "atchess:hub")
+	v.SetDefault("push.driver", "memory")
+	v.SetDefault("push.ttl", 24*time.Hour)
+	v.SetDefault("email.enabled", false)
+	v.SetDefault("email.smtp_port", 587)
+	v.SetDefault("email.digest_interval", time.Hour)
+	v.SetDefault("email.pending_after", 24*time.Hour)
+	v.SetDefault("email.expiry_warning", 12*time.Hour)
+	v.SetDefault("identity.plc_directories", []string{"https://plc.directory"})
+	v.SetDefault("identity.timeout", 10*time.Second)
+	v.SetDefault("identity.cache_size", 10000)
+	v.SetDefault("identity.cache_ttl", time.Hour)
+	v.SetDefault("oauth.driver", "memory")
+	v.SetDefault("scheduler.enabled", true)
+	v.SetDefault("scheduler.driver", "memory")
+	v.SetDefault("scheduler.poll_interval", 30*time.Second)
+	v.SetDefault("scheduler.deadline_sync_interval", 10*time.Minute)
+	v.SetDefault("draw_offers.timeout", 72*time.Hour)
+	v.SetDefault("challenges.max_pending_per_opponent", 3)
+	v.SetDefault("moderation.hide_seeks_after", 3)
+	v.SetDefault("anticheat.enabled", false)
+	v.SetDefault("anticheat.flag_score", 75)
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/secrets"
+)
+
+// inTempDir runs the test where there's no config.yaml to find
+func inTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func TestLoad_EnvironmentWithoutConfigFile(t *testing.T) {
+	inTempDir(t)
+	t.Setenv("ATCHESS_SERVER_PORT", "9000")
+	t.Setenv("ATCHESS_ATPROTO_HANDLE", "atchess.test")
+	t.Setenv("ATCHESS_ATPROTO_RETRY_MAX_DELAY", "10s")
+	t.Setenv("ATCHESS_BOT_API_ACCOUNTS", "did:plc:a,did:plc:b")
+	t.Setenv("ATPROTO_PDS_URL", "https://pds.example")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port != 9000 || cfg.ATProto.Handle != "atchess.test" || cfg.ATProto.PDSURL != "https://pds.example" {
+		t.Errorf("Expected settings from the environment, got %+v %+v", cfg.Server, cfg.ATProto)
+	}
+	if cfg.ATProto.Retry.MaxDelay != 10*time.Second || cfg.ATProto.Retry.MaxAttempts != 4 {
+		t.Errorf("Expected the environment on top of the defaults, got %+v", cfg.ATProto.Retry)
+	}
+	if len(cfg.BotAPI.Accounts) != 2 || cfg.BotAPI.Accounts[1] != "did:plc:b" {
+		t.Errorf("Expected a comma-separated list, got %q", cfg.BotAPI.Accounts)
+	}
+}
+
+func TestLoad_FlagsOverrideFileAndEnvironment(t *testing.T) {
+	dir := inTempDir(t)
+	file := filepath.Join(dir, "atchess.yaml")
+	if err := os.WriteFile(file, []byte("server:\n  port: 7000\n  host: example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ATCHESS_SERVER_HOST", "env.example.com")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	if err := fs.Parse([]string{"--config", file, "--server.port", "7100", "--webhooks.enabled=false", "--development.debug"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadOptions(flags.Options())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port != 7100 {
+		t.Errorf("Expected the flag's port, got %d", cfg.Server.Port)
+	}
+	if cfg.Server.Host != "env.example.com" {
+		t.Errorf("Expected the environment over the file, got %q", cfg.Server.Host)
+	}
+	if cfg.Webhooks.Enabled || !cfg.Development.Debug {
+		t.Errorf("Expected boolean flags to apply, got webhooks %v debug %v", cfg.Webhooks.Enabled, cfg.Development.Debug)
+	}
+
+	if _, err := LoadOptions(Options{File: filepath.Join(dir, "missing.yaml")}); err == nil {
+		t.Error("Expected an error for a config file that doesn't exist")
+	}
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	dir := inTempDir(t)
+	config := "server:\n  prot: 8080\nlive_games:\n  disconnect_policy: wait\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load()
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || !strings.Contains(err.Error(), "server.prot: unknown setting (did you mean server.port?)") {
+		t.Fatalf("Expected the misspelt key to be reported, got %v", err)
+	}
+
+	os.Remove(filepath.Join(dir, "config.yaml"))
+	_, err = LoadOptions(Options{Overrides: map[string]string{
+		"server.port":                  "70000",
+		"live_games.disconnect_policy": "wait",
+		"oauth.driver":                 "redis",
+		"server.tls.autocert":          "true",
+		"server.trusted_proxies":       "10.0.0.0/8,proxy.internal",
+		"webhooks.driver":              "sqlite",
+		"push.enabled":                 "true",
+	}})
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	for _, want := range []string{
+		"server.port: must be a port between 1 and 65535, got 70000",
+		`live_games.disconnect_policy: must be one of off, announce, pause, forfeit, got "wait"`,
+		"oauth.encryption_key: required for oauth.driver redis",
+		"server.tls.domains: required when server.tls.autocert is true",
+		`server.trusted_proxies: must be IP addresses or CIDR ranges such as 10.0.0.0/8, got "proxy.internal"`,
+		"webhooks.dsn: required for webhooks.driver sqlite",
+		"push.vapid_public_key: required when push.enabled is true",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestConfig_PrintRedactsSecrets(t *testing.T) {
+	inTempDir(t)
+	t.Setenv("ATCHESS_ATPROTO_PASSWORD", "hunter2")
+	t.Setenv("ATCHESS_PUBLIC_API_KEYS", "research-key")
+	t.Setenv("ATCHESS_INDEX_DSN", "postgres://atchess:s3cret@db/atchess")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cfg.Tracing.Headers = map[string]string{"x-api-key": "collector-key"}
+
+	var out bytes.Buffer
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("Print failed: %v", err)
+	}
+	printed := out.String()
+	for _, secret := range []string{"hunter2", "research-key", "s3cret", "collector-key"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("Expected %q to be redacted:\n%s", secret, printed)
+		}
+	}
+	for _, want := range []string{"  password: REDACTED\n", "  app_password: \"\"\n", "dsn: postgres://atchess:xxxxx@db/atchess", "x-api-key: REDACTED", "max_delay: 5s"} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("Expected %q in:\n%s", want, printed)
+		}
+	}
+}
+
+func TestLoad_Secrets(t *testing.T) {
+	dir := inTempDir(t)
+	passwordFile := filepath.Join(dir, "pds_password")
+	keysFile := filepath.Join(dir, "api_keys")
+	os.WriteFile(passwordFile, []byte("from-file\n"), 0o600)
+	os.WriteFile(keysFile, []byte("key-one\nkey-two\n"), 0o600)
+
+	t.Setenv("ATPROTO_PASSWORD", "from-env")
+	t.Setenv("ATPROTO_PASSWORD_FILE", passwordFile)
+	t.Setenv("ATCHESS_PUBLIC_API_KEYS_FILE", keysFile)
+	t.Setenv("ATCHESS_EMAIL_PASSWORD", "vault://secret/atchess#smtp")
+
+	resolver := secrets.NewResolver()
+	resolver.Register("vault", secrets.ProviderFunc(func(_ context.Context, ref string) (string, error) {
+		if ref != "secret/atchess#smtp" {
+			return "", secrets.ErrNotFound
+		}
+		return "from-vault", nil
+	}))
+	cfg, err := LoadOptions(Options{Secrets: resolver})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.ATProto.Password != "from-file" {
+		t.Errorf("Expected the _FILE variable to win, got %q", cfg.ATProto.Password)
+	}
+	if len(cfg.PublicAPI.APIKeys) != 2 || cfg.PublicAPI.APIKeys[1] != "key-two" {
+		t.Errorf("Expected a key per line, got %q", cfg.PublicAPI.APIKeys)
+	}
+	if cfg.Email.Password != "from-vault" {
+		t.Errorf("Expected the referenced secret, got %q", cfg.Email.Password)
+	}
+
+	t.Setenv("ATCHESS_EMAIL_PASSWORD", "vault://secret/other#smtp")
+	if _, err := LoadOptions(Options{Secrets: resolver}); err == nil || !strings.Contains(err.Error(), "email.password") {
+		t.Errorf("Expected an error naming the setting, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Flags are the command-line options for configuration: --config FILE,
+// --print-config, and --KEY VALUE for every setting, e.g. --server.port 9000.
+// Settings from flags take precedence over the config file and environment.
+type Flags struct {
+	File        string
+	PrintConfig bool
+	Overrides   map[string]string
+}
+
+// RegisterFlags adds the configuration flags to fs, to be filled in when it's
+// parsed
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{Overrides: make(map[string]string)}
+	fs.StringVar(&f.File, "config", "", "Read configuration from `FILE` instead of config.yaml")
+	fs.BoolVar(&f.PrintConfig, "print-config", false, "Print the effective configuration, with secrets redacted, and exit")
+	for _, s := range settings {
+		if s.isMap() {
+			continue
+		}
+		key := s.key
+		usage := fmt.Sprintf("Set %s (also %s)", key, EnvVar(key))
+		if s.field.Type.Kind() == reflect.Bool {
+			// Let --development.debug stand for --development.debug=true
+			fs.Var(boolOverride{f.Overrides, key}, key, usage)
+			continue
+		}
+		fs.Func(key, usage, func(value string) error {
+			f.Overrides[key] = value
+			return nil
+		})
+	}
+	return f
+}
+
+// Options are the Options for LoadOptions the flags ask for
+func (f *Flags) Options() Options {
+	return Options{File: f.File, Overrides: f.Overrides}
+}
+
+// boolOverride is a flag.Value that may be given without a value, like a
+// flag.Bool
+type boolOverride struct {
+	overrides map[string]string
+	key       string
+}
+
+func (b boolOverride) String() string {
+	if b.overrides == nil {
+		return ""
+	}
+	return b.overrides[b.key]
+}
+
+func (b boolOverride) Set(value string) error {
+	switch strings.ToLower(value) {
+	case "true", "false", "1", "0", "t", "f":
+		b.overrides[b.key] = value
+		return nil
+	}
+	return errors.New("must be true or false")
+}
+
+func (b boolOverride) IsBoolFlag() bool {
+	return true
+}
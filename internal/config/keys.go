@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// setting is one configuration key, found from Config's mapstructure tags
+type setting struct {
+	key   string
+	field reflect.StructField
+	// index finds the field from Config, for reflect.Value.FieldByIndex
+	index []int
+}
+
+// settings lists every key in Config, in the order the fields are declared
+var settings = collectSettings(reflect.TypeOf(Config{}), "", nil)
+
+func collectSettings(t reflect.Type, prefix string, index []int) []setting {
+	var out []setting
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+		fieldIndex := append(slices.Clip(index), i)
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+			out = append(out, collectSettings(field.Type, key+".", fieldIndex)...)
+			continue
+		}
+		out = append(out, setting{key: key, field: field, index: fieldIndex})
+	}
+	return out
+}
+
+// isMap reports whether the setting's keys are chosen by the operator, like
+// tracing.otlp_headers
+func (s setting) isMap() bool {
+	return s.field.Type.Kind() == reflect.Map
+}
+
+// EnvVar is the environment variable that sets key, e.g. ATCHESS_SERVER_PORT
+// for server.port
+func EnvVar(key string) string {
+	return "ATCHESS_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// legacyEnvVars are names some keys could be set by before they all had an
+// ATCHESS_ variable, or shorter ones. They're read ahead of the EnvVar names.
+var legacyEnvVars = map[string]string{
+	"server.host":           "SERVER_HOST",
+	"server.port":           "SERVER_PORT",
+	"server.base_url":       "SERVER_BASE_URL",
+	"atproto.pds_url":       "ATPROTO_PDS_URL",
+	"atproto.handle":        "ATPROTO_HANDLE",
+	"atproto.password":      "ATPROTO_PASSWORD",
+	"atproto.app_password":  "ATPROTO_APP_PASSWORD",
+	"atproto.use_dpop":      "ATPROTO_USE_DPOP",
+	"development.debug":     "DEVELOPMENT_DEBUG",
+	"development.log_level": "DEVELOPMENT_LOG_LEVEL",
+	"firehose.enabled":      "FIREHOSE_ENABLED",
+	"firehose.url":          "FIREHOSE_URL",
+	"public_api.api_keys":   "ATCHESS_PUBLIC_API_KEYS",
+}
+
+func bindEnv(v *viper.Viper) {
+	for _, s := range settings {
+		// Maps can't be set from a single variable
+		if s.isMap() {
+			continue
+		}
+		v.BindEnv(append([]string{s.key}, envVars(s.key)...)...)
+	}
+}
+
+// envVars are the variables that set key, in the order they're read
+func envVars(key string) []string {
+	if legacy, ok := legacyEnvVars[key]; ok {
+		return []string{legacy, EnvVar(key)}
+	}
+	return []string{EnvVar(key)}
+}
+
+// checkKeys rejects keys that aren't settings, which are usually misspelt
+func checkKeys(keys []string) error {
+	known := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		known[s.key] = s
+	}
+
+	var problems []string
+	for _, key := range keys {
+		if _, ok := known[key]; ok || underMap(key, known) {
+			continue
+		}
+		problem := fmt.Sprintf("%s: unknown setting", key)
+		if suggestion := closestKey(key); suggestion != "" {
+			problem += fmt.Sprintf(" (did you mean %s?)", suggestion)
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// underMap reports whether key is an entry in a map setting
+func underMap(key string, known map[string]setting) bool {
+	for i := strings.LastIndex(key, "."); i > 0; i = strings.LastIndex(key[:i], ".") {
+		if s, ok := known[key[:i]]; ok && s.isMap() {
+			return true
+		}
+	}
+	return false
+}
+
+// closestKey returns the setting a misspelt key was probably meant to be,
+// or "" if none is close
+func closestKey(key string) string {
+	best, bestDistance := "", 4
+	for _, s := range settings {
+		if d := editDistance(key, s.key); d < bestDistance {
+			best, bestDistance = s.key, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"io"
+	"net/url"
+	"reflect"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted stands in for secrets in printed configuration
+const redacted = "REDACTED"
+
+// Print writes the configuration as YAML laid out like config.yaml, with
+// secrets redacted so the output can be shared. Settings that are secrets
+// but empty are printed empty, to show they aren't set.
+func (c *Config) Print(w io.Writer) error {
+	node, err := structNode(reflect.ValueOf(*c))
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// structNode is a YAML mapping of a config struct's fields, in the order
+// they're declared
+func structNode(v reflect.Value) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: field.Tag.Get("mapstructure")}
+
+		var value *yaml.Node
+		var err error
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+			value, err = structNode(v.Field(i))
+		} else {
+			value, err = valueNode(v.Field(i), field.Tag.Get("redact"))
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, key, value)
+	}
+	return node, nil
+}
+
+// valueNode encodes one setting. redact is "secret" to hide the whole value,
+// or "url" to hide only a password inside it.
+func valueNode(v reflect.Value, redact string) (*yaml.Node, error) {
+	value := v.Interface()
+	switch {
+	case redact == "secret" && !v.IsZero():
+		switch v.Kind() {
+		case reflect.Slice:
+			hidden := make([]string, v.Len())
+			for i := range hidden {
+				hidden[i] = redacted
+			}
+			value = hidden
+		case reflect.Map:
+			// The names, like header names, aren't secret
+			hidden := make(map[string]string, v.Len())
+			for _, name := range v.MapKeys() {
+				hidden[name.String()] = redacted
+			}
+			value = hidden
+		default:
+			value = redacted
+		}
+	case redact == "url":
+		value = redactURL(v.String())
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		value = v.Interface().(time.Duration).String()
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// dsnPassword matches a password in a key=value database DSN or URL query
+var dsnPassword = regexp.MustCompile(`(?i)(password=)[^\s&;]+`)
+
+// redactURL hides the password in a URL or database DSN, leaving the rest
+// readable
+func redactURL(s string) string {
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		s = u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(s, "${1}xxxxx")
+}
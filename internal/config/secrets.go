@@ -0,0 +1,90 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/secrets"
+	"github.com/spf13/viper"
+)
+
+// secretsTimeout bounds looking up every secret at startup
+const secretsTimeout = 30 * time.Second
+
+// isSecret reports whether a setting may hold a secret, so may be a
+// reference to one or be read from a file
+func (s setting) isSecret() bool {
+	return s.field.Tag.Get("redact") != ""
+}
+
+// setSecretFiles points secret settings at the files named by their
+// variables with _FILE added, e.g. ATCHESS_ATPROTO_PASSWORD_FILE, as Docker
+// secrets are mounted. They take precedence over the file and environment.
+func setSecretFiles(v *viper.Viper) {
+	for _, s := range settings {
+		if !s.isSecret() || s.isMap() {
+			continue
+		}
+		for _, name := range envVars(s.key) {
+			if path := os.Getenv(name + "_FILE"); path != "" {
+				v.Set(s.key, "file://"+path)
+				break
+			}
+		}
+	}
+}
+
+// resolveSecrets replaces references in secret settings, like
+// vault://secret/atchess#pds_password, with the secrets they name. A list
+// setting's secrets may hold several values, one per line.
+func resolveSecrets(cfg *Config, resolver *secrets.Resolver) error {
+	ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+	defer cancel()
+
+	root := reflect.ValueOf(cfg).Elem()
+	for _, s := range settings {
+		if !s.isSecret() {
+			continue
+		}
+		field := root.FieldByIndex(s.index)
+		switch field.Kind() {
+		case reflect.String:
+			value, err := resolver.Resolve(ctx, field.String())
+			if err != nil {
+				return fmt.Errorf("%s: %w", s.key, err)
+			}
+			field.SetString(value)
+		case reflect.Slice:
+			var values []string
+			for _, ref := range field.Interface().([]string) {
+				value, err := resolver.Resolve(ctx, ref)
+				if err != nil {
+					return fmt.Errorf("%s: %w", s.key, err)
+				}
+				if !resolver.IsReference(ref) {
+					values = append(values, value)
+					continue
+				}
+				for _, line := range strings.Split(value, "\n") {
+					if line = strings.TrimSpace(line); line != "" {
+						values = append(values, line)
+					}
+				}
+			}
+			field.Set(reflect.ValueOf(values))
+		case reflect.Map:
+			for _, name := range field.MapKeys() {
+				value, err := resolver.Resolve(ctx, field.MapIndex(name).String())
+				if err != nil {
+					return fmt.Errorf("%s.%s: %w", s.key, name, err)
+				}
+				field.SetMapIndex(name, reflect.ValueOf(value))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ValidationError lists everything wrong with a configuration, so it can all
+// be fixed at once
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+var (
+	logLevels          = []string{"trace", "debug", "info", "warn", "error", "fatal", "panic", "disabled"}
+	disconnectPolicies = []string{"off", "announce", "pause", "forfeit"}
+	// An empty driver is the same as memory
+	brokerDrivers = []string{"", "memory", "redis"}
+)
+
+// validator collects problems with a configuration, each prefixed with the
+// key it's about
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(key, format string, args ...any) {
+	v.problems = append(v.problems, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(key, value, why string) {
+	if value == "" {
+		v.add(key, "required %s", why)
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed []string) {
+	if !slices.Contains(allowed, value) {
+		v.add(key, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+}
+
+func (v *validator) port(key string, value int) {
+	if value < 1 || value > 65535 {
+		v.add(key, "must be a port between 1 and 65535, got %d", value)
+	}
+}
+
+func (v *validator) positive(key string, value int) {
+	if value < 1 {
+		v.add(key, "must be at least 1, got %d", value)
+	}
+}
+
+func (v *validator) duration(key string, value time.Duration) {
+	if value <= 0 {
+		v.add(key, "must be a positive duration such as 30s or 5m, got %s", value)
+	}
+}
+
+func (v *validator) fraction(key string, value float64) {
+	if value < 0 || value > 1 {
+		v.add(key, "must be between 0 and 1, got %g", value)
+	}
+}
+
+// encryptionKey checks value is 32 base64-encoded bytes
+func (v *validator) encryptionKey(key, value, why string) {
+	if value == "" {
+		v.required(key, value, why)
+		return
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(value); err != nil || len(decoded) != 32 {
+		v.add(key, "must be 32 base64-encoded bytes, e.g. from openssl rand -base64 32")
+	}
+}
+
+// url checks value is an absolute URL with one of schemes
+func (v *validator) url(key, value string, schemes ...string) {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" || !slices.Contains(schemes, u.Scheme) {
+		v.add(key, "must be a %s URL, got %q", strings.Join(schemes, " or "), redactURL(value))
+	}
+}
+
+// Validate checks the settings make sense together, returning a
+// *ValidationError listing every problem. The service account's credentials
+// aren't required, since the web server doesn't use them.
+func (c *Config) Validate() error {
+	var v validator
+
+	v.port("server.port", c.Server.Port)
+	if c.Server.BaseURL != "" {
+		v.url("server.base_url", c.Server.BaseURL, "http", "https")
+	}
+	if tls := c.Server.TLS; tls.Autocert {
+		if tls.CertFile != "" {
+			v.add("server.tls.autocert", "can't be used with server.tls.cert_file")
+		}
+		if len(tls.Domains) == 0 {
+			v.add("server.tls.domains", "required when server.tls.autocert is true, to say which names to get certificates for")
+		}
+		v.required("server.tls.cache_dir", tls.CacheDir, "when server.tls.autocert is true, so certificates survive restarts")
+		if tls.DirectoryURL != "" {
+			v.url("server.tls.directory_url", tls.DirectoryURL, "https", "http")
+		}
+	} else if (tls.CertFile == "") != (tls.KeyFile == "") {
+		v.add("server.tls", "cert_file and key_file must be set together")
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			v.add("server.trusted_proxies", "must be IP addresses or CIDR ranges such as 10.0.0.0/8, got %q", proxy)
+		}
+	}
+
+	v.url("atproto.pds_url", c.ATProto.PDSURL, "http", "https")
+	v.positive("atproto.retry.max_attempts", c.ATProto.Retry.MaxAttempts)
+	if c.ATProto.Retry.MaxAttempts > 1 {
+		v.duration("atproto.retry.base_delay", c.ATProto.Retry.BaseDelay)
+		if c.ATProto.Retry.MaxDelay < c.ATProto.Retry.BaseDelay {
+			v.add("atproto.retry.max_delay", "must be at least atproto.retry.base_delay (%s), got %s", c.ATProto.Retry.BaseDelay, c.ATProto.Retry.MaxDelay)
+		}
+	}
+
+	v.oneOf("development.log_level", c.Development.LogLevel, logLevels)
+	v.fraction("development.fault_injection.pds_error_rate", c.Development.FaultInjection.PDSErrorRate)
+
+	if c.Firehose.Enabled {
+		v.url("firehose.url", c.Firehose.URL, "ws", "wss")
+		v.duration("spectator.tv_interval", c.Spectator.TVInterval)
+	}
+
+	if c.Spectator.KibitzEnabled {
+		v.positive("spectator.kibitz_depth", c.Spectator.KibitzDepth)
+		v.duration("spectator.kibitz_interval", c.Spectator.KibitzInterval)
+	}
+
+	if c.Federation.Enabled {
+		v.duration("federation.refresh_interval", c.Federation.RefreshInterval)
+		for _, peer := range c.Federation.Peers {
+			v.url("federation.peers", peer, "http", "https")
+		}
+	}
+
+	if c.Index.Driver != "" && c.Index.Driver != "memory" {
+		v.required("index.dsn", c.Index.DSN, "for index.driver "+c.Index.Driver)
+	}
+
+	if c.PublicAPI.Enabled {
+		v.positive("public_api.requests_per_minute", c.PublicAPI.RequestsPerMinute)
+		v.positive("public_api.key_requests_per_minute", c.PublicAPI.KeyRequestsPerMinute)
+	}
+
+	v.oneOf("live_games.disconnect_policy", c.LiveGames.DisconnectPolicy, disconnectPolicies)
+	if c.LiveGames.DisconnectPolicy == "pause" || c.LiveGames.DisconnectPolicy == "forfeit" {
+		v.duration("live_games.grace_period", c.LiveGames.GracePeriod)
+	}
+
+	if c.Bot.Enabled {
+		v.required("bot.engine_path", c.Bot.EnginePath, "when bot.enabled is true")
+		v.duration("bot.think_time", c.Bot.ThinkTime)
+		v.positive("bot.analysis_depth", c.Bot.AnalysisDepth)
+	}
+
+	if c.AntiCheat.Enabled {
+		if !c.Bot.Enabled {
+			v.add("anticheat.enabled", "needs bot.enabled, whose engine analyses the games")
+		}
+		if !c.Firehose.Enabled {
+			v.add("anticheat.enabled", "needs firehose.enabled, whose game index holds the scores")
+		}
+		if c.AntiCheat.FlagScore < 1 || c.AntiCheat.FlagScore > 100 {
+			v.add("anticheat.flag_score", "must be between 1 and 100, got %d", c.AntiCheat.FlagScore)
+		}
+	}
+
+	v.positive("matchmaking.rating_band", c.Matchmaking.RatingBand)
+	v.duration("matchmaking.pair_interval", c.Matchmaking.PairInterval)
+
+	if c.Tracing.Enabled {
+		v.fraction("tracing.sample_ratio", c.Tracing.SampleRatio)
+		if c.Tracing.Endpoint != "" {
+			v.url("tracing.otlp_endpoint", c.Tracing.Endpoint, "http", "https")
+		}
+	}
+
+	if c.RateLimit.Enabled {
+		v.positive("rate_limit.requests_per_minute", c.RateLimit.RequestsPerMinute)
+		v.positive("rate_limit.session_requests_per_minute", c.RateLimit.SessionRequestsPerMinute)
+		v.positive("rate_limit.logins_per_minute", c.RateLimit.LoginsPerMinute)
+		v.positive("rate_limit.challenges_per_minute", c.RateLimit.ChallengesPerMinute)
+	}
+
+	if c.Webhooks.Enabled {
+		v.positive("webhooks.max_attempts", c.Webhooks.MaxAttempts)
+		v.duration("webhooks.initial_backoff", c.Webhooks.InitialBackoff)
+		if c.Webhooks.Driver != "" && c.Webhooks.Driver != "memory" {
+			v.required("webhooks.dsn", c.Webhooks.DSN, "for webhooks.driver "+c.Webhooks.Driver)
+		}
+	}
+
+	v.oneOf("broker.driver", c.Broker.Driver, brokerDrivers)
+	if c.Broker.Driver == "redis" {
+		v.url("broker.redis_url", c.Broker.RedisURL, "redis", "rediss")
+		v.required("broker.channel", c.Broker.Channel, "for broker.driver redis")
+	}
+
+	if c.Push.Enabled {
+		if c.Push.Subject != "" && !strings.HasPrefix(c.Push.Subject, "mailto:") && !strings.HasPrefix(c.Push.Subject, "https://") {
+			v.add("push.subject", "must be a mailto: address or https URL, got %q", c.Push.Subject)
+		}
+		// A pair made up at startup would strand every browser subscribed
+		// before a restart, or through another replica
+		v.required("push.vapid_public_key", c.Push.VAPIDPublicKey, "when push.enabled is true; generate a pair once, e.g. with npx web-push generate-vapid-keys")
+		v.required("push.vapid_private_key", c.Push.VAPIDPrivateKey, "when push.enabled is true")
+		if c.Push.Driver != "" && c.Push.Driver != "memory" {
+			v.required("push.dsn", c.Push.DSN, "for push.driver "+c.Push.Driver)
+		}
+	}
+
+	if c.Email.Enabled {
+		v.required("email.smtp_host", c.Email.SMTPHost, "when email.enabled is true")
+		v.port("email.smtp_port", c.Email.SMTPPort)
+		v.required("email.from", c.Email.From, "when email.enabled is true")
+		v.duration("email.digest_interval", c.Email.DigestInterval)
+	}
+
+	for _, dir := range c.Identity.PLCDirectories {
+		v.url("identity.plc_directories", dir, "http", "https")
+	}
+
+	switch c.OAuth.Driver {
+	case "", "memory":
+	case "redis":
+		v.url("oauth.redis_url", c.OAuth.RedisURL, "redis", "rediss")
+		v.encryptionKey("oauth.encryption_key", c.OAuth.EncryptionKey, "for oauth.driver redis")
+	default:
+		v.required("oauth.dsn", c.OAuth.DSN, "for oauth.driver "+c.OAuth.Driver)
+		v.encryptionKey("oauth.encryption_key", c.OAuth.EncryptionKey, "for oauth.driver "+c.OAuth.Driver)
+	}
+
+	if c.Scheduler.Enabled {
+		v.duration("scheduler.poll_interval", c.Scheduler.PollInterval)
+		v.duration("scheduler.deadline_sync_interval", c.Scheduler.DeadlineSyncInterval)
+		if c.Scheduler.Driver != "" && c.Scheduler.Driver != "memory" {
+			v.required("scheduler.dsn", c.Scheduler.DSN, "for scheduler.driver "+c.Scheduler.Driver)
+		}
+	}
+
+	if c.DrawOffers.Timeout < 0 {
+		v.add("draw_offers.timeout", "must not be negative, got %s", c.DrawOffers.Timeout)
+	}
+	if c.Challenges.MaxPendingPerOpponent < 0 {
+		v.add("challenges.max_pending_per_opponent", "must not be negative, got %d", c.Challenges.MaxPendingPerOpponent)
+	}
+	if c.Moderation.HideSeeksAfter < 0 {
+		v.add("moderation.hide_seeks_after", "must not be negative, got %d", c.Moderation.HideSeeksAfter)
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Package faults injects artificial latency and failures for local
+// resilience testing. It must never be enabled in production.
+package faults
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/rs/zerolog/log"
+)
+
+// Injector applies the configured faults to HTTP handlers and outbound PDS requests
+type Injector struct {
+	cfg config.FaultInjectionConfig
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New creates an injector for the given configuration
+func New(cfg config.FaultInjectionConfig) *Injector {
+	return &Injector{
+		cfg: cfg,
+		rng: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// FirehoseDisconnectInterval returns how often the firehose connection should be dropped
+func (i *Injector) FirehoseDisconnectInterval() time.Duration {
+	return i.cfg.FirehoseDisconnectInterval
+}
+
+// delay returns the latency to inject for one request
+func (i *Injector) delay() time.Duration {
+	d := i.cfg.Latency
+	if i.cfg.LatencyJitter > 0 {
+		i.mu.Lock()
+		d += time.Duration(i.rng.Int63n(int64(i.cfg.LatencyJitter)))
+		i.mu.Unlock()
+	}
+	return d
+}
+
+// shouldFail reports whether a PDS request should be failed
+func (i *Injector) shouldFail() bool {
+	if i.cfg.PDSErrorRate <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Float64() < i.cfg.PDSErrorRate
+}
+
+// Middleware delays every API request by the configured latency
+func (i *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d := i.delay(); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Transport wraps a round tripper so PDS requests are delayed and randomly
+// answered with a synthetic 503
+func (i *Injector) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &faultTransport{injector: i, base: base}
+}
+
+type faultTransport struct {
+	injector *Injector
+	base     http.RoundTripper
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if d := t.injector.delay(); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	if t.injector.shouldFail() {
+		log.Debug().Str("url", req.URL.String()).Msg("Injecting PDS failure")
+		body := `{"error":"InjectedFault","message":"simulated PDS failure"}`
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Status:     strconv.Itoa(http.StatusServiceUnavailable) + " " + http.StatusText(http.StatusServiceUnavailable),
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+			Request:    req,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+		}, nil
+	}
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,61 @@
+package faults
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/config"
+)
+
+func TestTransportInjectsErrorsAtConfiguredRate(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	testCases := []struct {
+		name         string
+		errorRate    float64
+		expectedCode int
+	}{
+		{name: "never fail", errorRate: 0, expectedCode: http.StatusOK},
+		{name: "always fail", errorRate: 1, expectedCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			injector := New(config.FaultInjectionConfig{Enabled: true, PDSErrorRate: tc.errorRate})
+			client := &http.Client{Transport: injector.Transport(nil)}
+
+			resp, err := client.Get(upstream.URL)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.expectedCode {
+				t.Errorf("Expected status %d, got %d", tc.expectedCode, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestMiddlewareAddsLatency(t *testing.T) {
+	injector := New(config.FaultInjectionConfig{Enabled: true, Latency: 50 * time.Millisecond})
+	handler := injector.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/health", nil))
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected at least 50ms of injected latency, got %v", elapsed)
+	}
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected wrapped handler to run, got status %d", w.Code)
+	}
+}
@@ -0,0 +1,302 @@
+// Package federation lets ATChess instances discover each other through
+// app.atchess.instance records and read each other's public indexes.
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/rs/zerolog/log"
+)
+
+// WellKnownPath is where an instance advertises its DID and instance record
+const WellKnownPath = "/.well-known/atchess-instance"
+
+// HelloPath is where a peer announces itself after a successful handshake
+const HelloPath = "/api/federation/hello"
+
+// Feature names used as keys in an instance record's endpoints map
+const (
+	EndpointSpectatorGames = "spectatorGames"
+	EndpointOpenChallenges = "openChallenges"
+)
+
+// InstanceFetcher reads the app.atchess.instance record an account has published
+type InstanceFetcher interface {
+	GetInstance(ctx context.Context, did string) (*atproto.InstanceRecord, error)
+}
+
+// WellKnown is the document served at WellKnownPath
+type WellKnown struct {
+	DID      string                  `json:"did"`
+	Instance *atproto.InstanceRecord `json:"instance"`
+}
+
+// Peer is a verified remote instance
+type Peer struct {
+	DID      string                 `json:"did"`
+	Instance atproto.InstanceRecord `json:"instance"`
+	LastSeen time.Time              `json:"lastSeen"`
+}
+
+// RemoteItem is an entry read from a peer's index
+type RemoteItem struct {
+	Instance string
+	Item     json.RawMessage
+}
+
+// Directory tracks the peers this instance federates with
+type Directory struct {
+	selfDID       string
+	self          *atproto.InstanceRecord
+	fetcher       InstanceFetcher
+	httpClient    *http.Client
+	acceptInbound bool
+
+	mu    sync.RWMutex
+	peers map[string]*Peer
+}
+
+// NewDirectory creates a directory for the instance identified by selfDID.
+// Unknown instances announcing themselves are only added if acceptInbound is set.
+func NewDirectory(selfDID string, self *atproto.InstanceRecord, fetcher InstanceFetcher, acceptInbound bool) *Directory {
+	return &Directory{
+		selfDID:       selfDID,
+		self:          self,
+		fetcher:       fetcher,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		acceptInbound: acceptInbound,
+		peers:         make(map[string]*Peer),
+	}
+}
+
+// WellKnown returns the document advertising this instance
+func (d *Directory) WellKnown() WellKnown {
+	return WellKnown{DID: d.selfDID, Instance: d.self}
+}
+
+// Handshake discovers the instance at peerURL, verifies that its DID has
+// published a matching instance record, and announces this instance to it
+func (d *Directory) Handshake(ctx context.Context, peerURL string) (*Peer, error) {
+	peerURL = strings.TrimRight(peerURL, "/")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", peerURL+WellKnownPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer URL %s: %w", peerURL, err)
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach peer %s: %w", peerURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer %s does not advertise an instance: HTTP %d", peerURL, resp.StatusCode)
+	}
+
+	var wellKnown WellKnown
+	if err := json.NewDecoder(resp.Body).Decode(&wellKnown); err != nil {
+		return nil, fmt.Errorf("failed to decode instance document from %s: %w", peerURL, err)
+	}
+
+	peer, err := d.verify(ctx, wellKnown.DID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(peer.Instance.Endpoint, "/") != peerURL {
+		return nil, fmt.Errorf("instance record for %s points at %s, not %s", wellKnown.DID, peer.Instance.Endpoint, peerURL)
+	}
+
+	d.store(peer)
+
+	// Let the peer know about us; failure here doesn't invalidate the handshake
+	if err := d.announce(ctx, peerURL); err != nil {
+		log.Warn().Err(err).Str("peer", peerURL).Msg("Failed to announce instance to peer")
+	}
+
+	return peer, nil
+}
+
+// Accept handles an inbound hello from a peer, verifying its instance record
+func (d *Directory) Accept(ctx context.Context, did string) (*Peer, error) {
+	d.mu.RLock()
+	_, known := d.peers[did]
+	d.mu.RUnlock()
+
+	if !known && !d.acceptInbound {
+		return nil, fmt.Errorf("instance %s is not a configured peer", did)
+	}
+
+	peer, err := d.verify(ctx, did)
+	if err != nil {
+		return nil, err
+	}
+
+	d.store(peer)
+	return peer, nil
+}
+
+// Peers returns the verified peers sorted by DID
+func (d *Directory) Peers() []Peer {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	peers := make([]Peer, 0, len(d.peers))
+	for _, peer := range d.peers {
+		peers = append(peers, *peer)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].DID < peers[j].DID
+	})
+	return peers
+}
+
+// Collect reads the list stored under listKey from every peer exposing the
+// given feature endpoint. Peers are asked for local data only (local=true) so
+// instances never recursively aggregate each other. Unreachable peers are skipped.
+func (d *Directory) Collect(ctx context.Context, feature, listKey string) []RemoteItem {
+	var items []RemoteItem
+
+	for _, peer := range d.Peers() {
+		path, ok := peer.Instance.Endpoints[feature]
+		if !ok {
+			continue
+		}
+
+		list, err := d.fetchList(ctx, strings.TrimRight(peer.Instance.Endpoint, "/")+path, listKey)
+		if err != nil {
+			log.Warn().Err(err).Str("peer", peer.DID).Str("feature", feature).Msg("Failed to read peer index")
+			continue
+		}
+
+		for _, item := range list {
+			items = append(items, RemoteItem{Instance: peer.DID, Item: item})
+		}
+	}
+
+	return items
+}
+
+// Refresh re-runs the handshake with each peer URL
+func (d *Directory) Refresh(ctx context.Context, peerURLs []string) {
+	for _, peerURL := range peerURLs {
+		if _, err := d.Handshake(ctx, peerURL); err != nil {
+			log.Warn().Err(err).Str("peer", peerURL).Msg("Federation handshake failed")
+		}
+	}
+}
+
+// StartRefresh performs the handshakes now and then periodically
+func (d *Directory) StartRefresh(ctx context.Context, peerURLs []string, interval time.Duration) {
+	go func() {
+		d.Refresh(ctx, peerURLs)
+		if interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.Refresh(ctx, peerURLs)
+			}
+		}
+	}()
+}
+
+func (d *Directory) verify(ctx context.Context, did string) (*Peer, error) {
+	if !strings.HasPrefix(did, "did:") {
+		return nil, fmt.Errorf("invalid instance DID: %q", did)
+	}
+	if did == d.selfDID {
+		return nil, fmt.Errorf("refusing to federate with ourselves")
+	}
+
+	instance, err := d.fetcher.GetInstance(ctx, did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch instance record for %s: %w", did, err)
+	}
+
+	endpoint, err := url.Parse(instance.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("instance record for %s has invalid endpoint %q", did, instance.Endpoint)
+	}
+
+	return &Peer{DID: did, Instance: *instance, LastSeen: time.Now()}, nil
+}
+
+func (d *Directory) store(peer *Peer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.peers[peer.DID] = peer
+}
+
+func (d *Directory) announce(ctx context.Context, peerURL string) error {
+	body, _ := json.Marshal(map[string]string{"did": d.selfDID})
+	req, err := http.NewRequestWithContext(ctx, "POST", peerURL+HelloPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer rejected hello: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *Directory) fetchList(ctx context.Context, endpoint, listKey string) ([]json.RawMessage, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("local", "true")
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d from %s", resp.StatusCode, endpoint)
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var list []json.RawMessage
+	if raw, ok := body[listKey]; ok {
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Errorf("field %s is not a list: %w", listKey, err)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,135 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+type fakeFetcher map[string]*atproto.InstanceRecord
+
+func (f fakeFetcher) GetInstance(ctx context.Context, did string) (*atproto.InstanceRecord, error) {
+	instance, ok := f[did]
+	if !ok {
+		return nil, fmt.Errorf("no instance record for %s", did)
+	}
+	return instance, nil
+}
+
+// newPeer starts a fake instance serving the well-known document, hello and
+// a spectator index, and records hello announcements it receives
+func newPeer(t *testing.T, did string, fetcher fakeFetcher) (*httptest.Server, *[]string) {
+	t.Helper()
+	var hellos []string
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	instance := &atproto.InstanceRecord{
+		Name:      "Peer",
+		Endpoint:  server.URL + "/",
+		Endpoints: map[string]string{EndpointSpectatorGames: "/api/spectator/games"},
+	}
+	fetcher[did] = instance
+
+	mux.HandleFunc(WellKnownPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(WellKnown{DID: did, Instance: instance})
+	})
+	mux.HandleFunc(HelloPath, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			DID string `json:"did"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		hellos = append(hellos, req.DID)
+	})
+	mux.HandleFunc("/api/spectator/games", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("local") != "true" {
+			t.Errorf("Expected peers to be queried with local=true")
+		}
+		_, _ = w.Write([]byte(`{"games":[{"gameId":"remote-1"},{"gameId":"remote-2"}],"total":2}`))
+	})
+
+	return server, &hellos
+}
+
+func TestHandshakeVerifiesAndAnnounces(t *testing.T) {
+	fetcher := fakeFetcher{}
+	server, hellos := newPeer(t, "did:plc:peer", fetcher)
+
+	dir := NewDirectory("did:plc:self", &atproto.InstanceRecord{Name: "Self"}, fetcher, false)
+	peer, err := dir.Handshake(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+
+	if peer.DID != "did:plc:peer" || len(dir.Peers()) != 1 {
+		t.Errorf("Expected peer to be stored, got %+v", dir.Peers())
+	}
+	if len(*hellos) != 1 || (*hellos)[0] != "did:plc:self" {
+		t.Errorf("Expected hello from did:plc:self, got %v", *hellos)
+	}
+}
+
+func TestHandshakeRejectsMismatchedEndpoint(t *testing.T) {
+	fetcher := fakeFetcher{}
+	server, _ := newPeer(t, "did:plc:peer", fetcher)
+	fetcher["did:plc:peer"] = &atproto.InstanceRecord{Endpoint: "https://elsewhere.example"}
+
+	dir := NewDirectory("did:plc:self", &atproto.InstanceRecord{}, fetcher, false)
+	if _, err := dir.Handshake(context.Background(), server.URL); err == nil {
+		t.Error("Expected handshake to fail when the instance record points elsewhere")
+	}
+	if len(dir.Peers()) != 0 {
+		t.Errorf("Expected no peers, got %+v", dir.Peers())
+	}
+}
+
+func TestAcceptRequiresOptIn(t *testing.T) {
+	fetcher := fakeFetcher{
+		"did:plc:stranger": {Endpoint: "https://stranger.example"},
+		"did:plc:self":     {Endpoint: "https://self.example"},
+	}
+
+	closed := NewDirectory("did:plc:self", &atproto.InstanceRecord{}, fetcher, false)
+	if _, err := closed.Accept(context.Background(), "did:plc:stranger"); err == nil {
+		t.Error("Expected unknown instance to be rejected without accept_inbound")
+	}
+
+	open := NewDirectory("did:plc:self", &atproto.InstanceRecord{}, fetcher, true)
+	if _, err := open.Accept(context.Background(), "did:plc:stranger"); err != nil {
+		t.Errorf("Expected inbound instance to be accepted: %v", err)
+	}
+	if _, err := open.Accept(context.Background(), "did:plc:self"); err == nil {
+		t.Error("Expected own DID to be rejected")
+	}
+}
+
+func TestCollectTagsItemsWithInstance(t *testing.T) {
+	fetcher := fakeFetcher{}
+	server, _ := newPeer(t, "did:plc:peer", fetcher)
+
+	dir := NewDirectory("did:plc:self", &atproto.InstanceRecord{}, fetcher, false)
+	if _, err := dir.Handshake(context.Background(), server.URL); err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+
+	items := dir.Collect(context.Background(), EndpointSpectatorGames, "games")
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 remote games, got %d", len(items))
+	}
+	for _, item := range items {
+		if item.Instance != "did:plc:peer" {
+			t.Errorf("Expected item tagged with did:plc:peer, got %s", item.Instance)
+		}
+	}
+
+	if items := dir.Collect(context.Background(), EndpointOpenChallenges, "challenges"); len(items) != 0 {
+		t.Errorf("Expected no items for an endpoint the peer doesn't expose, got %d", len(items))
+	}
+}
@@ -45,16 +45,22 @@ const (
 	EventTypeChallenge  EventType = "challenge"
 	EventTypeChallengeAcceptance EventType = "challengeAcceptance"
 	EventTypeChallengeNotification EventType = "challengeNotification"
+	EventTypeSeek       EventType = "seek"
+	EventTypeChatMessage EventType = "chatMessage"
 )
 
 // Event represents a chess-related event from the firehose
 type Event struct {
 	Type      EventType
+	Action    string    // "create", "update" or "delete"
 	Repo      string    // DID of the repository
 	Path      string    // Record path
 	CID       string    // Content ID
 	Timestamp time.Time
 	Record    interface{} // Decoded record data
+	
+	// ctx carries the event's trace span to handlers; see Context
+	ctx context.Context
 }
 
 // EventHandler is called for each chess-related event
@@ -73,6 +79,9 @@ type Client struct {
 	connected     bool
 	lastSequence  int64
 	
+	// Development fault injection: drop the connection at this interval
+	forcedDisconnectInterval time.Duration
+	
 	// For testing
 	dialer        *websocket.Dialer
 	mockWebSocket bool
@@ -110,6 +119,22 @@ func WithInitialReconnectDelay(delay time.Duration) Option {
 	}
 }
 
+// WithForcedDisconnects drops the connection every interval to exercise
+// reconnect and cursor resume logic during development
+func WithForcedDisconnects(interval time.Duration) Option {
+	return func(c *Client) {
+		c.forcedDisconnectInterval = interval
+	}
+}
+
+// WithCursor starts the subscription at sequence number seq rather than at
+// the live head, replaying the events the relay still holds since then
+func WithCursor(seq int64) Option {
+	return func(c *Client) {
+		c.lastSequence = seq
+	}
+}
+
 // NewClient creates a new firehose client
 func NewClient(handler EventHandler, opts ...Option) *Client {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -212,6 +237,10 @@ func (c *Client) connect() error {
 	
 	c.logger.Info().Msg("Connected to firehose")
 	
+	if c.forcedDisconnectInterval > 0 {
+		go c.forceDisconnectAfter(conn, c.forcedDisconnectInterval)
+	}
+	
 	// Set up ping/pong handlers
 	conn.SetPongHandler(func(string) error {
 		conn.SetReadDeadline(time.Now().Add(pongTimeout))
@@ -251,29 +280,20 @@ func (c *Client) listen() error {
 }
 
 func (c *Client) processMessage(data []byte) error {
-	// The AT Protocol firehose uses a specific message format
-	// For testing purposes, we'll handle both test format and real format
+	// Real subscribeRepos frames are DAG-CBOR and always start with a map header
+	if isCBORFrame(data) {
+		return c.processFrame(data)
+	}
 	
-	// First try to parse as our test format (with 4-byte header length prefix)
+	// Otherwise fall back to the simplified test format (4-byte header length prefix + JSON)
 	if len(data) >= 4 {
 		headerLen := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3])
 		if len(data) >= 4+headerLen && headerLen > 0 && headerLen < len(data) {
-			// Looks like our test format
 			return c.processTestMessage(data)
 		}
 	}
 	
-	// Otherwise, try to parse as actual AT Protocol format
-	// The real format is more complex with CBOR encoding
-	// For now, we'll log and skip
-	c.logger.Debug().Int("len", len(data)).Msg("Received firehose message")
-	
-	// TODO: Implement real AT Protocol firehose message parsing
-	// This would involve:
-	// 1. Parsing the DAG-CBOR encoded message
-	// 2. Extracting the commit information
-	// 3. Processing the CAR blocks
-	
+	c.logger.Debug().Int("len", len(data)).Msg("Ignoring unrecognised firehose message")
 	return nil
 }
 
@@ -323,6 +343,7 @@ func (c *Client) processTestMessage(data []byte) error {
 		// Just create a simple event
 		event := Event{
 			Type:      getEventType(op.Path),
+			Action:    op.Action,
 			Repo:      message.Repo,
 			Path:      op.Path,
 			CID:       op.CID,
@@ -330,9 +351,7 @@ func (c *Client) processTestMessage(data []byte) error {
 			Record:    map[string]interface{}{}, // Empty record for tests
 		}
 		
-		if err := c.handler(event); err != nil {
-			c.logger.Error().Err(err).Msg("Event handler error")
-		}
+		c.dispatch(event)
 	}
 	
 	return nil
@@ -426,6 +445,17 @@ func nodeToGo(node ipld.Node) (interface{}, error) {
 	case ipld.Kind_Null:
 		return nil, nil
 		
+	case ipld.Kind_Bytes:
+		return node.AsBytes()
+		
+	case ipld.Kind_Link:
+		// Links (e.g. blob refs) are represented by their CID string
+		link, err := node.AsLink()
+		if err != nil {
+			return nil, err
+		}
+		return link.String(), nil
+		
 	default:
 		return nil, fmt.Errorf("unsupported node kind: %v", node.Kind())
 	}
@@ -456,6 +486,25 @@ func (c *Client) pingLoop() {
 	}
 }
 
+// forceDisconnectAfter closes conn once the interval elapses, unless the
+// client has already moved on to another connection
+func (c *Client) forceDisconnectAfter(conn *websocket.Conn, interval time.Duration) {
+	select {
+	case <-time.After(interval):
+	case <-c.ctx.Done():
+		return
+	}
+	
+	c.mu.RLock()
+	current := c.conn
+	c.mu.RUnlock()
+	
+	if current == conn {
+		c.logger.Warn().Msg("Injecting firehose disconnect")
+		conn.Close()
+	}
+}
+
 func (c *Client) handleReconnect() {
 	c.mu.Lock()
 	c.connected = false
@@ -496,10 +545,17 @@ func getEventType(path string) EventType {
 		return EventTypeResignation
 	case strings.Contains(path, "app.atchess.game"):
 		return EventTypeGame
+	case strings.Contains(path, "app.atchess.seek"):
+		return EventTypeSeek
+	case strings.Contains(path, "app.atchess.chatMessage"):
+		return EventTypeChatMessage
 	case strings.Contains(path, "app.atchess.challenge"):
 		if strings.Contains(path, "app.atchess.challengeAcceptance") {
 			return EventTypeChallengeAcceptance
 		}
+		if strings.Contains(path, "app.atchess.challengeNotification") {
+			return EventTypeChallengeNotification
+		}
 		return EventTypeChallenge
 	default:
 		return EventTypeGame
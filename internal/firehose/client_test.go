@@ -363,6 +363,9 @@ func TestGetEventType(t *testing.T) {
 		{"app.atchess.game", EventTypeGame},
 		{"app.atchess.challenge", EventTypeChallenge},
 		{"app.atchess.challengeAcceptance", EventTypeChallengeAcceptance},
+		{"app.atchess.challengeNotification/3k2a", EventTypeChallengeNotification},
+		{"app.atchess.seek/3k2b", EventTypeSeek},
+		{"app.atchess.chatMessage/3k2c", EventTypeChatMessage},
 		{"app.atchess.unknown", EventTypeGame}, // default
 	}
 	
@@ -374,4 +377,38 @@ func TestGetEventType(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestClient_StartsAtCursor(t *testing.T) {
+	cursors := make(chan string, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case cursors <- r.URL.Query().Get("cursor"):
+		default:
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+	
+	client := NewClient(func(Event) error { return nil }, WithURL("ws"+strings.TrimPrefix(server.URL, "http")), WithCursor(42))
+	client.Start()
+	defer client.Stop()
+	
+	select {
+	case cursor := <-cursors:
+		if cursor != "42" {
+			t.Errorf("Expected to subscribe from cursor 42, got %q", cursor)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Client never connected")
+	}
+}
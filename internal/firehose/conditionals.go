@@ -0,0 +1,39 @@
+package firehose
+
+import (
+	"context"
+
+	"github.com/justinabrahms/atchess/internal/webhook"
+)
+
+// ConditionalMovePlayer plays the replies players have queued in
+// app.atchess.conditionalMove records; web.Service is one
+type ConditionalMovePlayer interface {
+	PlayConditionalMoves(ctx context.Context, gameURI, mover, fen string)
+}
+
+// WithConditionalMoves wraps handler so players' conditional replies are
+// played as their opponents' moves arrive
+func WithConditionalMoves(player ConditionalMovePlayer, handler EventHandler) EventHandler {
+	return withRelay(conditionalSink{player}, handler)
+}
+
+// conditionalSink hands relayed moves to a ConditionalMovePlayer
+type conditionalSink struct {
+	player ConditionalMovePlayer
+}
+
+func (c conditionalSink) Dispatch(ctx context.Context, event webhook.Event) {
+	if event.Type != webhook.EventMove || event.Game == "" {
+		return
+	}
+	record, _ := event.Record.(map[string]interface{})
+	mover, _ := record["player"].(string)
+	if mover == "" {
+		mover = repoOf(event.URI)
+	}
+	fen, _ := record["fen"].(string)
+	// Replies are written to the players' repositories, which mustn't hold
+	// up the rest of the firehose
+	go c.player.PlayConditionalMoves(context.WithoutCancel(ctx), event.Game, mover, fen)
+}
@@ -0,0 +1,47 @@
+package firehose
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// movesSeen records the moves a conditional move player is handed
+type movesSeen chan [3]string
+
+func (m movesSeen) PlayConditionalMoves(ctx context.Context, gameURI, mover, fen string) {
+	m <- [3]string{gameURI, mover, fen}
+}
+
+func TestConditionalMovesPlayedOnOpponentMoves(t *testing.T) {
+	seen := make(movesSeen, 2)
+	handler := WithConditionalMoves(seen, func(event Event) error { return nil })
+	gameURI := "at://" + white + "/app.atchess.game/g1"
+	afterE4 := "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"
+	feed := []Event{
+		{Type: EventTypeGame, Action: "create", Repo: white, Path: "app.atchess.game/g1",
+			Record: map[string]interface{}{"white": white, "black": black, "status": "active"}},
+		{Type: EventTypeMove, Action: "create", Repo: white, Path: "app.atchess.move/m1",
+			Record: map[string]interface{}{"game": map[string]interface{}{"uri": gameURI}, "player": white, "fen": afterE4}},
+		{Type: EventTypeMove, Action: "delete", Repo: white, Path: "app.atchess.move/m1"},
+	}
+	for _, event := range feed {
+		if err := handler(event); err != nil {
+			t.Fatalf("Handler failed: %v", err)
+		}
+	}
+
+	select {
+	case move := <-seen:
+		if move != [3]string{gameURI, white, afterE4} {
+			t.Errorf("Expected white's move to be handed over, got %v", move)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the move to be handed to the conditional move player")
+	}
+	select {
+	case move := <-seen:
+		t.Errorf("Expected only the created move to be handed over, also got %v", move)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
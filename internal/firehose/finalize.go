@@ -0,0 +1,38 @@
+package firehose
+
+import (
+	"context"
+
+	"github.com/justinabrahms/atchess/internal/webhook"
+)
+
+// GameFinalizer settles game records once moves made in the other player's
+// repository end the game; web.Service is one
+type GameFinalizer interface {
+	FinalizeMovedGame(ctx context.Context, gameURI, mover string)
+}
+
+// WithFinalizer wraps handler so game records are settled as the moves that
+// end their games arrive, rather than when they're next read
+func WithFinalizer(finalizer GameFinalizer, handler EventHandler) EventHandler {
+	return withRelay(finalizerSink{finalizer}, handler)
+}
+
+// finalizerSink hands relayed moves to a GameFinalizer
+type finalizerSink struct {
+	finalizer GameFinalizer
+}
+
+func (f finalizerSink) Dispatch(ctx context.Context, event webhook.Event) {
+	if event.Type != webhook.EventMove || event.Game == "" {
+		return
+	}
+	record, _ := event.Record.(map[string]interface{})
+	mover, _ := record["player"].(string)
+	if mover == "" {
+		mover = repoOf(event.URI)
+	}
+	// Finalizing writes to the owner's repository, which mustn't hold up the
+	// rest of the firehose
+	go f.finalizer.FinalizeMovedGame(context.WithoutCancel(ctx), event.Game, mover)
+}
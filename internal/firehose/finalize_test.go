@@ -0,0 +1,44 @@
+package firehose
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// gamesSettled records the moves a game finalizer is handed
+type gamesSettled chan [2]string
+
+func (g gamesSettled) FinalizeMovedGame(ctx context.Context, gameURI, mover string) {
+	g <- [2]string{gameURI, mover}
+}
+
+func TestFinalizerHandedCreatedMoves(t *testing.T) {
+	settled := make(gamesSettled, 2)
+	handler := WithFinalizer(settled, func(event Event) error { return nil })
+	gameURI := "at://" + white + "/app.atchess.game/g1"
+	feed := []Event{
+		{Type: EventTypeMove, Action: "create", Repo: black, Path: "app.atchess.move/m2",
+			Record: map[string]interface{}{"game": map[string]interface{}{"uri": gameURI}, "player": black}},
+		{Type: EventTypeMove, Action: "delete", Repo: black, Path: "app.atchess.move/m2"},
+	}
+	for _, event := range feed {
+		if err := handler(event); err != nil {
+			t.Fatalf("Handler failed: %v", err)
+		}
+	}
+
+	select {
+	case move := <-settled:
+		if move != [2]string{gameURI, black} {
+			t.Errorf("Expected black's move to be handed over, got %v", move)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the move to be handed to the finalizer")
+	}
+	select {
+	case move := <-settled:
+		t.Errorf("Expected only the created move to be handed over, also got %v", move)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,169 @@
+package firehose
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+)
+
+// Frame header op values defined by com.atproto.sync.subscribeRepos
+const (
+	frameOpMessage = 1
+	frameOpError   = -1
+)
+
+// isCBORFrame reports whether data starts with a CBOR map, as every
+// subscribeRepos frame header does
+func isCBORFrame(data []byte) bool {
+	return len(data) > 0 && data[0]>>5 == 5
+}
+
+// processFrame decodes a subscribeRepos frame: a DAG-CBOR header followed
+// immediately by a DAG-CBOR body
+func (c *Client) processFrame(data []byte) error {
+	reader := bytes.NewReader(data)
+
+	header, err := decodeCBOR(reader, true)
+	if err != nil {
+		return fmt.Errorf("failed to decode frame header: %w", err)
+	}
+	body, err := decodeCBOR(reader, false)
+	if err != nil {
+		return fmt.Errorf("failed to decode frame body: %w", err)
+	}
+
+	op, _ := intField(header, "op")
+	if op == frameOpError {
+		return fmt.Errorf("firehose error frame: %s: %s", stringField(body, "error"), stringField(body, "message"))
+	}
+	if op != frameOpMessage {
+		return fmt.Errorf("unknown frame op %d", op)
+	}
+
+	// Every message type except #info carries a sequence number
+	if seq, ok := intField(body, "seq"); ok && seq > 0 {
+		c.lastSequence = seq
+	}
+
+	switch t := stringField(header, "t"); t {
+	case "#commit":
+		return c.processCommit(body)
+	case "#info":
+		c.logger.Info().
+			Str("name", stringField(body, "name")).
+			Str("message", stringField(body, "message")).
+			Msg("Firehose info message")
+	default:
+		// #identity, #account, #handle, #tombstone etc. don't carry records
+		c.logger.Debug().Str("type", t).Msg("Ignoring firehose message")
+	}
+
+	return nil
+}
+
+// processCommit emits an event for every chess record touched by a #commit
+func (c *Client) processCommit(body ipld.Node) error {
+	repo := stringField(body, "repo")
+
+	timestamp := time.Now()
+	if t, err := time.Parse(time.RFC3339, stringField(body, "time")); err == nil {
+		timestamp = t
+	}
+
+	tooBig, _ := boolField(body, "tooBig")
+	var blocks []byte
+	if node, err := body.LookupByString("blocks"); err == nil && node.Kind() == ipld.Kind_Bytes {
+		blocks, _ = node.AsBytes()
+	}
+
+	ops, err := body.LookupByString("ops")
+	if err != nil || ops.Kind() != ipld.Kind_List {
+		return fmt.Errorf("commit from %s has no ops", repo)
+	}
+
+	iter := ops.ListIterator()
+	for !iter.Done() {
+		_, op, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("failed to read commit op: %w", err)
+		}
+
+		path := stringField(op, "path")
+		if !isChessRecord(path) {
+			continue
+		}
+
+		action := stringField(op, "action")
+		event := Event{
+			Type:      getEventType(path),
+			Action:    action,
+			Repo:      repo,
+			Path:      path,
+			Timestamp: timestamp,
+		}
+
+		// Deletes have a null CID and no record block
+		if node, err := op.LookupByString("cid"); err == nil && node.Kind() == ipld.Kind_Link {
+			link, _ := node.AsLink()
+			event.CID = link.String()
+		}
+
+		if action != "delete" && event.CID != "" {
+			if tooBig {
+				c.logger.Warn().Str("repo", repo).Str("path", path).Msg("Commit too big to include blocks, skipping record")
+				continue
+			}
+
+			record, err := c.extractRecord(blocks, event.CID)
+			if err != nil {
+				c.logger.Warn().Err(err).Str("repo", repo).Str("path", path).Msg("Failed to extract record from commit")
+				continue
+			}
+			event.Record = record
+		}
+
+		c.dispatch(event)
+	}
+
+	return nil
+}
+
+func decodeCBOR(reader *bytes.Reader, stopAtEnd bool) (ipld.Node, error) {
+	nb := basicnode.Prototype.Any.NewBuilder()
+	opts := dagcbor.DecodeOptions{AllowLinks: true, DontParseBeyondEnd: stopAtEnd}
+	if err := opts.Decode(nb, reader); err != nil {
+		return nil, err
+	}
+	return nb.Build(), nil
+}
+
+func stringField(node ipld.Node, key string) string {
+	value, err := node.LookupByString(key)
+	if err != nil {
+		return ""
+	}
+	s, _ := value.AsString()
+	return s
+}
+
+func intField(node ipld.Node, key string) (int64, bool) {
+	value, err := node.LookupByString(key)
+	if err != nil {
+		return 0, false
+	}
+	i, err := value.AsInt()
+	return i, err == nil
+}
+
+func boolField(node ipld.Node, key string) (bool, bool) {
+	value, err := node.LookupByString(key)
+	if err != nil {
+		return false, false
+	}
+	b, err := value.AsBool()
+	return b, err == nil
+}
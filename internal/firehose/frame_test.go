@@ -0,0 +1,157 @@
+package firehose
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/rs/zerolog"
+)
+
+// cborBlock encodes a node as DAG-CBOR and returns its bytes and CID
+func cborBlock(t *testing.T, node datamodel.Node) ([]byte, cid.Cid) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := dagcbor.Encode(node, &buf); err != nil {
+		t.Fatalf("Failed to encode block: %v", err)
+	}
+	// 0x12 is the sha2-256 multihash code
+	c, err := cid.Prefix{Version: 1, Codec: cid.DagCBOR, MhType: 0x12, MhLength: -1}.Sum(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to compute CID: %v", err)
+	}
+	return buf.Bytes(), c
+}
+
+// commitFrame builds a subscribeRepos #commit frame with a move record
+// created and a game record deleted
+func commitFrame(t *testing.T, seq int64) ([]byte, cid.Cid) {
+	t.Helper()
+
+	record, err := qp.BuildMap(basicnode.Prototype.Any, -1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "$type", qp.String("app.atchess.move"))
+		qp.MapEntry(ma, "san", qp.String("e4"))
+		qp.MapEntry(ma, "game", qp.Map(-1, func(ma datamodel.MapAssembler) {
+			qp.MapEntry(ma, "uri", qp.String("at://did:plc:white/app.atchess.game/g1"))
+		}))
+	})
+	if err != nil {
+		t.Fatalf("Failed to build record: %v", err)
+	}
+	recordBytes, recordCID := cborBlock(t, record)
+
+	var carBuf bytes.Buffer
+	if err := car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{recordCID}, Version: 1}, &carBuf); err != nil {
+		t.Fatalf("Failed to write CAR header: %v", err)
+	}
+	if err := carutil.LdWrite(&carBuf, recordCID.Bytes(), recordBytes); err != nil {
+		t.Fatalf("Failed to write CAR block: %v", err)
+	}
+
+	header, _ := qp.BuildMap(basicnode.Prototype.Any, -1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "op", qp.Int(1))
+		qp.MapEntry(ma, "t", qp.String("#commit"))
+	})
+	body, err := qp.BuildMap(basicnode.Prototype.Any, -1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "seq", qp.Int(seq))
+		qp.MapEntry(ma, "repo", qp.String("did:plc:white"))
+		qp.MapEntry(ma, "time", qp.String("2024-05-01T12:00:00Z"))
+		qp.MapEntry(ma, "tooBig", qp.Bool(false))
+		qp.MapEntry(ma, "blocks", qp.Bytes(carBuf.Bytes()))
+		qp.MapEntry(ma, "ops", qp.List(-1, func(la datamodel.ListAssembler) {
+			qp.ListEntry(la, qp.Map(-1, func(ma datamodel.MapAssembler) {
+				qp.MapEntry(ma, "action", qp.String("create"))
+				qp.MapEntry(ma, "path", qp.String("app.atchess.move/3kmove"))
+				qp.MapEntry(ma, "cid", qp.Link(cidlink.Link{Cid: recordCID}))
+			}))
+			qp.ListEntry(la, qp.Map(-1, func(ma datamodel.MapAssembler) {
+				qp.MapEntry(ma, "action", qp.String("create"))
+				qp.MapEntry(ma, "path", qp.String("app.bsky.feed.post/3kpost"))
+				qp.MapEntry(ma, "cid", qp.Link(cidlink.Link{Cid: recordCID}))
+			}))
+			qp.ListEntry(la, qp.Map(-1, func(ma datamodel.MapAssembler) {
+				qp.MapEntry(ma, "action", qp.String("delete"))
+				qp.MapEntry(ma, "path", qp.String("app.atchess.game/3kgame"))
+				qp.MapEntry(ma, "cid", qp.Null())
+			}))
+		}))
+	})
+	if err != nil {
+		t.Fatalf("Failed to build body: %v", err)
+	}
+
+	var frame bytes.Buffer
+	if err := dagcbor.Encode(header, &frame); err != nil {
+		t.Fatalf("Failed to encode header: %v", err)
+	}
+	if err := dagcbor.Encode(body, &frame); err != nil {
+		t.Fatalf("Failed to encode body: %v", err)
+	}
+	return frame.Bytes(), recordCID
+}
+
+func TestProcessMessageDecodesCommitFrames(t *testing.T) {
+	var events []Event
+	client := NewClient(func(event Event) error {
+		events = append(events, event)
+		return nil
+	}, WithLogger(zerolog.New(zerolog.NewTestWriter(t))))
+
+	frame, recordCID := commitFrame(t, 4242)
+	if err := client.processMessage(frame); err != nil {
+		t.Fatalf("processMessage failed: %v", err)
+	}
+
+	if client.lastSequence != 4242 {
+		t.Errorf("Expected sequence 4242, got %d", client.lastSequence)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 chess events, got %d", len(events))
+	}
+
+	move := events[0]
+	if move.Type != EventTypeMove || move.Action != "create" || move.Repo != "did:plc:white" {
+		t.Errorf("Unexpected move event: %+v", move)
+	}
+	if move.CID != recordCID.String() {
+		t.Errorf("Expected CID %s, got %s", recordCID, move.CID)
+	}
+	if move.Timestamp.Format("2006-01-02T15:04:05Z") != "2024-05-01T12:00:00Z" {
+		t.Errorf("Expected commit time as timestamp, got %s", move.Timestamp)
+	}
+	record, ok := move.Record.(map[string]interface{})
+	if !ok || record["san"] != "e4" || getGameReference(record) != "at://did:plc:white/app.atchess.game/g1" {
+		t.Errorf("Expected decoded move record, got %#v", move.Record)
+	}
+
+	deleted := events[1]
+	if deleted.Type != EventTypeGame || deleted.Action != "delete" || deleted.Record != nil {
+		t.Errorf("Unexpected delete event: %+v", deleted)
+	}
+}
+
+func TestProcessMessageReportsErrorFrames(t *testing.T) {
+	client := NewClient(func(event Event) error { return nil })
+
+	header, _ := qp.BuildMap(basicnode.Prototype.Any, -1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "op", qp.Int(-1))
+	})
+	body, _ := qp.BuildMap(basicnode.Prototype.Any, -1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "error", qp.String("FutureCursor"))
+		qp.MapEntry(ma, "message", qp.String("Cursor in the future."))
+	})
+	var frame bytes.Buffer
+	_ = dagcbor.Encode(header, &frame)
+	_ = dagcbor.Encode(body, &frame)
+
+	if err := client.processMessage(frame.Bytes()); err == nil {
+		t.Error("Expected error frame to be reported")
+	}
+}
@@ -0,0 +1,22 @@
+package firehose
+
+import (
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/rs/zerolog/log"
+)
+
+// WithIndexer wraps handler so every chess record is written to the index
+// before being passed on. Each event's commit time extends the index's
+// coverage window.
+func WithIndexer(indexer *index.Indexer, handler EventHandler) EventHandler {
+	return func(event Event) error {
+		indexer.Observe(event.Timestamp)
+		if isChessRecord(event.Path) {
+			record, _ := event.Record.(map[string]interface{})
+			if err := indexer.Apply(event.Context(), event.Action, event.Repo, event.Path, record); err != nil {
+				log.Warn().Err(err).Str("repo", event.Repo).Str("path", event.Path).Msg("Failed to index record")
+			}
+		}
+		return handler(event)
+	}
+}
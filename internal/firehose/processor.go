@@ -9,9 +9,15 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// ChallengeFilter reports whether a challenge notification from challenger
+// should reach challenged
+type ChallengeFilter func(ctx context.Context, challenger, challenged string) bool
+
 // EventProcessor handles chess events from the firehose
 type EventProcessor struct {
 	hub *web.Hub
+	// challengeFilter, if set, drops unwanted challenge notifications
+	challengeFilter ChallengeFilter
 	// Map of game IDs we're tracking
 	trackedGames map[string]bool
 	// Map of player DIDs we're tracking
@@ -42,8 +48,19 @@ func (p *EventProcessor) TrackPlayer(did string) {
 	p.trackedPlayers[did] = true
 }
 
+// SetChallengeFilter drops challenge notifications the filter refuses, such as
+// those from players the challenged player blocks
+func (p *EventProcessor) SetChallengeFilter(filter ChallengeFilter) {
+	p.challengeFilter = filter
+}
+
 // ProcessEvent handles an event from the firehose
 func (p *EventProcessor) ProcessEvent(ctx context.Context, event Event) error {
+	// Deleted records carry no data to broadcast
+	if event.Action == "delete" {
+		return nil
+	}
+
 	// Check if we care about this event
 	if !p.shouldProcessEvent(event) {
 		return nil
@@ -203,13 +220,19 @@ func (p *EventProcessor) processChallengeNotificationEvent(ctx context.Context,
 		return fmt.Errorf("invalid challenge notification record format")
 	}
 
+	// The repo is the challenged player's DID
+	challenger, _ := notification["challenger"].(string)
+	if p.challengeFilter != nil && !p.challengeFilter(ctx, challenger, event.Repo) {
+		log.Debug().Str("challenger", challenger).Str("challenged", event.Repo).Msg("Dropping refused challenge notification")
+		return nil
+	}
+
 	// Send to the challenged player
 	update := web.GameUpdate{
 		Type: "challenge_notification",
 		Data: notification,
 	}
 
-	// The repo is the challenged player's DID
 	p.hub.BroadcastToPlayer(event.Repo, update)
 	return nil
 }
@@ -263,6 +286,10 @@ func getGameReference(record map[string]interface{}) string {
 		if ref, ok := game["$link"].(string); ok {
 			return ref
 		}
+		// Strong references ({uri, cid}) as written by atproto.Client
+		if ref, ok := game["uri"].(string); ok {
+			return ref
+		}
 	}
 
 	// Try game ID field
@@ -287,6 +314,6 @@ func CreateChessEventHandler(processor *EventProcessor) EventHandler {
 		}
 
 		// Process the event
-		return processor.ProcessEvent(context.Background(), event)
+		return processor.ProcessEvent(event.Context(), event)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,66 @@
+package firehose
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/justinabrahms/atchess/internal/push"
+	"github.com/justinabrahms/atchess/internal/webhook"
+)
+
+// WithPush wraps handler so players with push subscriptions are notified of
+// their opponents' moves and of challenges sent to them
+func WithPush(notifier *push.Notifier, handler EventHandler) EventHandler {
+	return withRelay(pushSink{notifier}, handler)
+}
+
+// pushSink turns relayed game events into push notifications
+type pushSink struct {
+	notifier *push.Notifier
+}
+
+func (p pushSink) Dispatch(ctx context.Context, event webhook.Event) {
+	record, _ := event.Record.(map[string]interface{})
+
+	switch event.Type {
+	case webhook.EventMove:
+		mover, _ := record["player"].(string)
+		if mover == "" {
+			mover = repoOf(event.URI)
+		}
+		body := "Your opponent has moved"
+		if san, _ := record["san"].(string); san != "" {
+			body = "Your opponent played " + san
+		}
+		for _, player := range event.Players {
+			if player == "" || player == mover {
+				continue
+			}
+			p.notifier.Notify(ctx, player, push.Notification{
+				Type:   webhook.EventMove,
+				Title:  "Your move",
+				Body:   body,
+				GameID: event.Game,
+				URL:    gameURL(event.Game),
+			})
+		}
+	case webhook.EventChallenge:
+		if len(event.Players) < 2 || event.Players[1] == "" {
+			return
+		}
+		p.notifier.Notify(ctx, event.Players[1], push.Notification{
+			Type:  webhook.EventChallenge,
+			Title: "New challenge",
+			Body:  event.Players[0] + " challenged you to a game",
+			URL:   "/",
+		})
+	}
+}
+
+// gameURL is the web interface's page for a game
+func gameURL(gameURI string) string {
+	if gameURI == "" {
+		return "/"
+	}
+	return "/?game=" + base64.URLEncoding.EncodeToString([]byte(gameURI))
+}
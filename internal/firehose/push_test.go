@@ -0,0 +1,70 @@
+package firehose
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/push"
+)
+
+func TestPushNotifiesTheOpponentAndTheChallenged(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]int) // subscription path to notifications
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.URL.Path]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	publicKey, privateKey, _ := push.GenerateKeys()
+	notifier := push.NewNotifier(push.Options{PublicKey: publicKey, PrivateKey: privateKey, HTTPClient: server.Client(), AllowPrivateAddresses: true})
+	for _, did := range []string{white, black} {
+		key, _ := ecdh.P256().GenerateKey(rand.Reader)
+		keys := push.Keys{
+			P256dh: base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+			Auth:   base64.RawURLEncoding.EncodeToString(make([]byte, 16)),
+		}
+		if _, err := notifier.Subscribe(context.Background(), did, server.URL+"/"+did, keys); err != nil {
+			t.Fatalf("Failed to subscribe: %v", err)
+		}
+	}
+
+	handler := WithPush(notifier, func(event Event) error { return nil })
+	gameURI := "at://" + white + "/app.atchess.game/g1"
+	move := map[string]interface{}{"game": map[string]interface{}{"uri": gameURI}, "player": white, "san": "e4"}
+	feed := []Event{
+		{Type: EventTypeChallenge, Action: "create", Repo: white, Path: "app.atchess.challenge/c1",
+			Record: map[string]interface{}{"challenger": white, "challenged": black}},
+		{Type: EventTypeGame, Action: "create", Repo: white, Path: "app.atchess.game/g1",
+			Record: map[string]interface{}{"white": white, "black": black, "status": "active"}},
+		{Type: EventTypeMove, Action: "create", Repo: white, Path: "app.atchess.move/m1", Record: move},
+	}
+	for _, event := range feed {
+		if err := handler(event); err != nil {
+			t.Fatalf("Handler failed: %v", err)
+		}
+	}
+	notifier.Wait()
+
+	if received["/"+black] != 2 || received["/"+white] != 0 {
+		t.Errorf("Expected black to hear of the challenge and the move, and white of nothing, got %v", received)
+	}
+}
+
+func TestGameURL(t *testing.T) {
+	uri := "at://" + white + "/app.atchess.game/g1"
+	if got := gameURL(uri); got != "/?game="+base64.URLEncoding.EncodeToString([]byte(uri)) {
+		t.Errorf("Unexpected game URL %q", got)
+	}
+	if gameURL("") != "/" {
+		t.Error("Expected notifications without a game to open the home page")
+	}
+}
@@ -0,0 +1,19 @@
+package firehose
+
+import (
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+// WithRecordCache wraps handler so records changed or deleted on the
+// firehose are forgotten by the record cache before being passed on
+func WithRecordCache(cache *atproto.RecordCache, handler EventHandler) EventHandler {
+	return func(event Event) error {
+		uri := "at://" + event.Repo + "/" + event.Path
+		if event.Action == "delete" {
+			cache.Forget(uri)
+		} else {
+			cache.Observe(uri, event.CID)
+		}
+		return handler(event)
+	}
+}
@@ -0,0 +1,42 @@
+package firehose
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/justinabrahms/atchess/internal/firehose"
+
+// Context returns the context the event is being handled in, which carries
+// its trace span. Events built outside a client get a background context.
+func (e Event) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// dispatch passes an event to the handler inside a span covering everything
+// the handler does with it, such as indexing and notifying players
+func (c *Client) dispatch(event Event) {
+	ctx, span := otel.Tracer(tracerName).Start(context.Background(), "firehose "+string(event.Type),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("atproto.repo", event.Repo),
+			attribute.String("atproto.path", event.Path),
+			attribute.String("atproto.action", event.Action),
+		),
+	)
+	defer span.End()
+
+	event.ctx = ctx
+	if err := c.handler(event); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.logger.Error().Err(err).Msg("Event handler error")
+	}
+}
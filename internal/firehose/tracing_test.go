@@ -0,0 +1,38 @@
+package firehose
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestEventsAreHandledInsideSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	client := NewClient(func(event Event) error {
+		if !trace.SpanFromContext(event.Context()).SpanContext().IsValid() {
+			t.Errorf("Expected %s to be handled inside a span", event.Path)
+		}
+		return nil
+	}, WithLogger(zerolog.New(zerolog.NewTestWriter(t))))
+
+	frame, _ := commitFrame(t, 1)
+	if err := client.processMessage(frame); err != nil {
+		t.Fatalf("processMessage failed: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "firehose move" || spans[1].Name() != "firehose game" {
+		t.Fatalf("Expected a span per chess event, got %v", spans)
+	}
+	if spans[0].SpanKind() != trace.SpanKindConsumer {
+		t.Errorf("Expected a consumer span, got %v", spans[0].SpanKind())
+	}
+}
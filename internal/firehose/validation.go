@@ -0,0 +1,25 @@
+package firehose
+
+import (
+	"strings"
+
+	"github.com/justinabrahms/atchess/internal/lexicon"
+	"github.com/rs/zerolog/log"
+)
+
+// WithValidation wraps handler so created and updated records that don't
+// match their lexicon are dropped before anything processes them. Records
+// from other repositories are untrusted; a malformed one mustn't reach the
+// index or be broadcast to players.
+func WithValidation(handler EventHandler) EventHandler {
+	return func(event Event) error {
+		if event.Action != "delete" {
+			collection, _, _ := strings.Cut(event.Path, "/")
+			if err := lexicon.Validate(collection, event.Record); err != nil {
+				log.Warn().Err(err).Str("repo", event.Repo).Str("path", event.Path).Msg("Dropping invalid record")
+				return nil
+			}
+		}
+		return handler(event)
+	}
+}
@@ -0,0 +1,49 @@
+package firehose
+
+import (
+	"testing"
+)
+
+func TestWithValidationDropsInvalidRecords(t *testing.T) {
+	var handled []string
+	handler := WithValidation(func(event Event) error {
+		handled = append(handled, event.Path)
+		return nil
+	})
+
+	valid := map[string]interface{}{
+		"createdAt":  "2024-01-01T00:00:00Z",
+		"game":       map[string]interface{}{"uri": "at://did:plc:white/app.atchess.game/g1", "cid": "game-cid"},
+		"player":     "did:plc:white",
+		"from":       "e2",
+		"to":         "e4",
+		"fen":        "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+		"moveNumber": int64(1),
+	}
+	invalid := map[string]interface{}{
+		"createdAt": "yesterday",
+		"player":    "did:plc:white",
+	}
+
+	events := []Event{
+		{Type: EventTypeMove, Action: "create", Path: "app.atchess.move/valid", Record: valid},
+		{Type: EventTypeMove, Action: "create", Path: "app.atchess.move/invalid", Record: invalid},
+		{Type: EventTypeMove, Action: "delete", Path: "app.atchess.move/deleted"},
+		{Type: EventTypeSeek, Action: "create", Path: "app.atchess.unknown/other", Record: map[string]interface{}{}},
+	}
+	for _, event := range events {
+		if err := handler(event); err != nil {
+			t.Fatalf("Handler returned %v", err)
+		}
+	}
+
+	want := []string{"app.atchess.move/valid", "app.atchess.move/deleted", "app.atchess.unknown/other"}
+	if len(handled) != len(want) {
+		t.Fatalf("Expected %v to be handled, got %v", want, handled)
+	}
+	for i := range want {
+		if handled[i] != want[i] {
+			t.Errorf("Expected %v to be handled, got %v", want, handled)
+		}
+	}
+}
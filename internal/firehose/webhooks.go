@@ -0,0 +1,141 @@
+package firehose
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/justinabrahms/atchess/internal/webhook"
+)
+
+// maxRelayedGames bounds the games whose players are remembered for webhooks
+const maxRelayedGames = 10000
+
+// eventSink receives the game events a relay derives from the firehose
+type eventSink interface {
+	Dispatch(ctx context.Context, event webhook.Event)
+}
+
+// webhookRelay turns firehose events into webhook events. It remembers the
+// players of games it has seen so moves reach both of them.
+type webhookRelay struct {
+	dispatcher eventSink
+
+	mu      sync.Mutex
+	players map[string][]string // game URI to its white and black DIDs
+	ended   map[string]bool
+}
+
+// WithWebhooks wraps handler so moves, finished games and challenges are
+// delivered to the webhooks of the players involved
+func WithWebhooks(dispatcher *webhook.Dispatcher, handler EventHandler) EventHandler {
+	return withRelay(dispatcher, handler)
+}
+
+// withRelay wraps handler so game events reach sink
+func withRelay(sink eventSink, handler EventHandler) EventHandler {
+	relay := &webhookRelay{
+		dispatcher: sink,
+		players:    make(map[string][]string),
+		ended:      make(map[string]bool),
+	}
+	return func(event Event) error {
+		if event.Action == "create" || event.Action == "update" {
+			relay.relay(event)
+		}
+		return handler(event)
+	}
+}
+
+func (w *webhookRelay) relay(event Event) {
+	record, ok := event.Record.(map[string]interface{})
+	if !ok {
+		return
+	}
+	uri := "at://" + event.Repo + "/" + event.Path
+	hook := webhook.Event{URI: uri, Record: record, CreatedAt: event.Timestamp}
+
+	switch event.Type {
+	case EventTypeMove:
+		if event.Action != "create" {
+			return
+		}
+		game := getGameReference(record)
+		hook.Type = webhook.EventMove
+		hook.Game = game
+		hook.Players = w.gamePlayers(game, event.Repo)
+	case EventTypeGame:
+		white, _ := record["white"].(string)
+		black, _ := record["black"].(string)
+		status, _ := record["status"].(string)
+		if !w.observeGame(uri, white, black, status) {
+			return
+		}
+		hook.Type = webhook.EventGameEnd
+		hook.Game = uri
+		hook.Players = []string{white, black}
+	case EventTypeChallenge:
+		if event.Action != "create" {
+			return
+		}
+		challenged, _ := record["challenged"].(string)
+		hook.Type = webhook.EventChallenge
+		hook.Players = []string{event.Repo, challenged}
+	default:
+		return
+	}
+	w.dispatcher.Dispatch(event.Context(), hook)
+}
+
+// gamePlayers returns the DIDs of a game's players, falling back to the
+// mover and the game's creator for games the relay hasn't seen
+func (w *webhookRelay) gamePlayers(gameURI, mover string) []string {
+	w.mu.Lock()
+	players, ok := w.players[gameURI]
+	w.mu.Unlock()
+	if ok {
+		return players
+	}
+	players = []string{mover}
+	if creator := repoOf(gameURI); creator != "" && creator != mover {
+		players = append(players, creator)
+	}
+	return players
+}
+
+// observeGame remembers a game's players and reports whether this record is
+// the first to show it finished. Game records are rewritten after they
+// finish, so each game ends only once.
+func (w *webhookRelay) observeGame(uri, white, black, status string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ended[uri] {
+		return false
+	}
+	switch status {
+	case "white_won", "black_won", "draw", "abandoned":
+		delete(w.players, uri)
+		if len(w.ended) >= maxRelayedGames {
+			w.ended = make(map[string]bool)
+		}
+		w.ended[uri] = true
+		return true
+	}
+	if _, ok := w.players[uri]; !ok && len(w.players) >= maxRelayedGames {
+		// Forget everything rather than grow without bound; moves fall
+		// back to the mover and creator until the games are seen again
+		w.players = make(map[string][]string)
+	}
+	w.players[uri] = []string{white, black}
+	return false
+}
+
+// repoOf returns the DID in an at:// URI
+func repoOf(uri string) string {
+	rest, ok := strings.CutPrefix(uri, "at://")
+	if !ok {
+		return ""
+	}
+	did, _, _ := strings.Cut(rest, "/")
+	return did
+}
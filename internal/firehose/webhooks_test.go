@@ -0,0 +1,90 @@
+package firehose
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/webhook"
+)
+
+const (
+	white = "did:plc:white"
+	black = "did:plc:black"
+)
+
+func TestWebhooksReceiveGameEvents(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]string) // webhook path to event types
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]interface{}
+		_ = json.Unmarshal(body, &payload)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], payload["event"].(string))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	dispatcher := webhook.NewDispatcher(webhook.WithHTTPClient(server.Client()), webhook.WithRetries(1, time.Millisecond))
+	events := []string{webhook.EventMove, webhook.EventGameEnd, webhook.EventChallenge}
+	for _, did := range []string{white, black} {
+		if _, err := dispatcher.Subscribe(context.Background(), did, server.URL+"/"+did, "0123456789abcdef", events); err != nil {
+			t.Fatalf("Failed to subscribe: %v", err)
+		}
+	}
+
+	var handled int
+	handler := WithWebhooks(dispatcher, func(event Event) error {
+		handled++
+		return nil
+	})
+
+	gameURI := "at://" + white + "/app.atchess.game/g1"
+	game := func(status string) map[string]interface{} {
+		return map[string]interface{}{"white": white, "black": black, "status": status}
+	}
+	move := map[string]interface{}{"game": map[string]interface{}{"uri": gameURI}, "san": "e4"}
+	feed := []Event{
+		{Type: EventTypeChallenge, Action: "create", Repo: white, Path: "app.atchess.challenge/c1",
+			Record: map[string]interface{}{"challenger": white, "challenged": black}},
+		{Type: EventTypeGame, Action: "create", Repo: white, Path: "app.atchess.game/g1", Record: game("active")},
+		// The game's creator moves; their opponent must still hear about it
+		{Type: EventTypeMove, Action: "create", Repo: white, Path: "app.atchess.move/m1", Record: move},
+		{Type: EventTypeGame, Action: "update", Repo: white, Path: "app.atchess.game/g1", Record: game("white_won")},
+		// Finished games are rewritten; the game only ends once
+		{Type: EventTypeGame, Action: "update", Repo: white, Path: "app.atchess.game/g1", Record: game("white_won")},
+		{Type: EventTypeMove, Action: "delete", Repo: white, Path: "app.atchess.move/m1"},
+	}
+	for _, event := range feed {
+		if err := handler(event); err != nil {
+			t.Fatalf("Handler failed: %v", err)
+		}
+	}
+	dispatcher.Wait()
+
+	if handled != len(feed) {
+		t.Errorf("Expected every event to be passed on, got %d", handled)
+	}
+	want := []string{webhook.EventChallenge, webhook.EventMove, webhook.EventGameEnd}
+	for _, did := range []string{white, black} {
+		got := received["/"+did]
+		if len(got) != len(want) {
+			t.Errorf("Expected %s to receive %v, got %v", did, want, got)
+		}
+	}
+}
+
+func TestRepoOf(t *testing.T) {
+	if did := repoOf("at://did:plc:abc/app.atchess.game/1"); did != "did:plc:abc" {
+		t.Errorf("Expected did:plc:abc, got %q", did)
+	}
+	if did := repoOf("https://example.com"); did != "" {
+		t.Errorf("Expected no DID, got %q", did)
+	}
+}
@@ -0,0 +1,384 @@
+// Package identity resolves AT Protocol handles to DIDs and DIDs to their
+// documents. did:plc documents are read from a PLC directory, failing over
+// between mirrors; did:web documents from the domain that names them. When
+// neither answers, the PDS is asked with com.atproto.repo.describeRepo.
+// Resolutions are cached, and a Resolver is safe to share between clients.
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Defaults for Options left at zero
+const (
+	DefaultPLCDirectory = "https://plc.directory"
+	DefaultTimeout      = 10 * time.Second
+	DefaultCacheSize    = 10000
+	// DefaultCacheTTL is how long a resolution is trusted. Handles and PDSes
+	// rarely move, but when they do the move should be noticed within the
+	// hour.
+	DefaultCacheTTL = time.Hour
+	// DefaultNegativeTTL is how long a handle or DID that didn't resolve is
+	// remembered, so typos aren't looked up again on every request
+	DefaultNegativeTTL = 5 * time.Minute
+)
+
+// maxDocumentSize bounds DID documents, which are served by whoever controls
+// a did:web domain
+const maxDocumentSize = 1 << 20
+
+var (
+	// ErrHandleNotFound is returned for handles the PDS couldn't resolve
+	ErrHandleNotFound = errors.New("handle not found")
+	// ErrDIDNotFound is returned for DIDs no source has a document for
+	ErrDIDNotFound = errors.New("DID not found")
+	// ErrUnsupportedDID is returned for DID methods other than plc and web,
+	// and for malformed DIDs
+	ErrUnsupportedDID = errors.New("unsupported DID")
+)
+
+// Options configure a Resolver
+type Options struct {
+	// PDSURL is asked to resolve handles, and for DID documents no
+	// directory could provide
+	PDSURL string
+	// PLCDirectories are tried in order for did:plc documents
+	PLCDirectories []string
+	// Timeout bounds each request to a directory, domain or PDS
+	Timeout time.Duration
+	// CacheSize is how many handles, and separately how many documents, are
+	// remembered
+	CacheSize   int
+	CacheTTL    time.Duration
+	NegativeTTL time.Duration
+	// HTTPClient makes the requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Document is the part of a DID document the AT Protocol uses
+type Document struct {
+	ID          string    `json:"id"`
+	AlsoKnownAs []string  `json:"alsoKnownAs,omitempty"`
+	Service     []Service `json:"service,omitempty"`
+}
+
+// Service is a service endpoint listed in a DID document
+type Service struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	ServiceEndpoint string `json:"serviceEndpoint"`
+}
+
+// PDSEndpoint returns the URL of the account's PDS, or "" if the document
+// doesn't list one
+func (d *Document) PDSEndpoint() string {
+	for _, service := range d.Service {
+		if service.ID == "#atproto_pds" || service.ID == d.ID+"#atproto_pds" {
+			return service.ServiceEndpoint
+		}
+	}
+	return ""
+}
+
+// Handle returns the handle the document claims, or "" if it claims none.
+// The claim is only trustworthy if the handle resolves back to the DID.
+func (d *Document) Handle() string {
+	for _, aka := range d.AlsoKnownAs {
+		if strings.HasPrefix(aka, "at://") {
+			return strings.TrimPrefix(aka, "at://")
+		}
+	}
+	return ""
+}
+
+// Resolver resolves handles and DIDs, caching the results
+type Resolver struct {
+	pdsURL         string
+	plcDirectories []string
+	timeout        time.Duration
+	ttl            time.Duration
+	negativeTTL    time.Duration
+	httpClient     *http.Client
+
+	handles   *cache // handle -> DID, or nil if it didn't resolve
+	documents *cache // DID -> *Document, or nil if it didn't resolve
+}
+
+// New creates a resolver
+func New(opts Options) *Resolver {
+	if len(opts.PLCDirectories) == 0 {
+		opts.PLCDirectories = []string{DefaultPLCDirectory}
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = DefaultCacheSize
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = DefaultCacheTTL
+	}
+	if opts.NegativeTTL <= 0 {
+		opts.NegativeTTL = DefaultNegativeTTL
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+
+	directories := make([]string, 0, len(opts.PLCDirectories))
+	for _, directory := range opts.PLCDirectories {
+		if directory = strings.TrimSuffix(strings.TrimSpace(directory), "/"); directory != "" {
+			directories = append(directories, directory)
+		}
+	}
+
+	return &Resolver{
+		pdsURL:         strings.TrimSuffix(opts.PDSURL, "/"),
+		plcDirectories: directories,
+		timeout:        opts.Timeout,
+		ttl:            opts.CacheTTL,
+		negativeTTL:    opts.NegativeTTL,
+		httpClient:     opts.HTTPClient,
+		handles:        newCache(opts.CacheSize, time.Now),
+		documents:      newCache(opts.CacheSize, time.Now),
+	}
+}
+
+// NormalizeHandle puts a handle in its canonical form: handles are
+// case-insensitive and are often written with a leading @
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+// Invalidate forgets what a handle or DID resolved to, so it's resolved
+// again next time. Callers should invalidate a handle whose DID turned out
+// to be wrong, and a DID whose PDS stopped answering.
+func (r *Resolver) Invalidate(identifier string) {
+	if r == nil {
+		return
+	}
+	if strings.HasPrefix(identifier, "did:") {
+		r.documents.delete(identifier)
+		return
+	}
+	r.handles.delete(NormalizeHandle(identifier))
+}
+
+// ResolveHandle resolves a handle to a DID, returning DIDs unchanged. A
+// handle the PDS can't resolve returns ErrHandleNotFound. Other failures
+// aren't cached, so the next call tries again.
+func (r *Resolver) ResolveHandle(ctx context.Context, handle string) (string, error) {
+	if strings.HasPrefix(handle, "did:") {
+		return handle, nil
+	}
+
+	handle = NormalizeHandle(handle)
+	if value, ok := r.handles.get(handle); ok {
+		if value == nil {
+			return "", fmt.Errorf("%w: %s", ErrHandleNotFound, handle)
+		}
+		return value.(string), nil
+	}
+
+	did, err := r.resolveHandle(ctx, handle)
+	switch {
+	case err == nil:
+		r.handles.put(handle, did, r.ttl)
+	case errors.Is(err, ErrHandleNotFound):
+		r.handles.put(handle, nil, r.negativeTTL)
+	}
+	return did, err
+}
+
+// resolveHandle asks the PDS to resolve a handle with
+// com.atproto.identity.resolveHandle
+func (r *Resolver) resolveHandle(ctx context.Context, handle string) (string, error) {
+	endpoint := fmt.Sprintf("%s/xrpc/com.atproto.identity.resolveHandle?handle=%s", r.pdsURL, url.QueryEscape(handle))
+
+	var result struct {
+		DID string `json:"did"`
+	}
+	status, err := r.getJSON(ctx, endpoint, &result)
+	// The PDS answers 400 for handles that don't resolve; anything else is a
+	// failure worth retrying
+	if status == http.StatusBadRequest {
+		return "", fmt.Errorf("%w: %s", ErrHandleNotFound, handle)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve handle: %w", err)
+	}
+	if !strings.HasPrefix(result.DID, "did:") {
+		return "", fmt.Errorf("failed to resolve handle: PDS returned %q", result.DID)
+	}
+	return result.DID, nil
+}
+
+// ResolveDID returns a DID's document. A DID no source has a document for
+// returns ErrDIDNotFound; an unsupported or malformed one ErrUnsupportedDID.
+func (r *Resolver) ResolveDID(ctx context.Context, did string) (*Document, error) {
+	if value, ok := r.documents.get(did); ok {
+		if value == nil {
+			return nil, fmt.Errorf("%w: %s", ErrDIDNotFound, did)
+		}
+		return value.(*Document), nil
+	}
+
+	doc, err := r.resolveDID(ctx, did)
+	switch {
+	case err == nil:
+		r.documents.put(did, doc, r.ttl)
+	case errors.Is(err, ErrDIDNotFound):
+		r.documents.put(did, nil, r.negativeTTL)
+	}
+	return doc, err
+}
+
+// resolveDID tries each source of the DID's document in turn
+func (r *Resolver) resolveDID(ctx context.Context, did string) (*Document, error) {
+	var sources []string
+	switch {
+	case strings.HasPrefix(did, "did:plc:") && len(did) > len("did:plc:"):
+		for _, directory := range r.plcDirectories {
+			sources = append(sources, directory+"/"+url.PathEscape(did))
+		}
+	case strings.HasPrefix(did, "did:web:"):
+		source, err := didWebURL(did)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDID, did)
+	}
+
+	// Until a source fails for another reason, the DID is taken not to exist
+	notFound := true
+	var errs []error
+	for _, source := range sources {
+		var doc Document
+		status, err := r.getJSON(ctx, source, &doc)
+		if err == nil {
+			err = checkDocument(&doc, did)
+		}
+		if err == nil {
+			return &doc, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		notFound = notFound && (status == http.StatusNotFound || status == http.StatusGone)
+		errs = append(errs, fmt.Errorf("%s: %w", source, err))
+	}
+
+	doc, found, err := r.describeRepo(ctx, did)
+	if err == nil && found {
+		return doc, nil
+	}
+	if err != nil {
+		notFound = false
+		errs = append(errs, fmt.Errorf("describeRepo: %w", err))
+	}
+
+	if notFound {
+		return nil, fmt.Errorf("%w: %s", ErrDIDNotFound, did)
+	}
+	return nil, fmt.Errorf("failed to resolve %s: %w", did, errors.Join(errs...))
+}
+
+// describeRepo asks the PDS for the DID's document. found is false if the
+// PDS doesn't host the repo.
+func (r *Resolver) describeRepo(ctx context.Context, did string) (doc *Document, found bool, err error) {
+	if r.pdsURL == "" {
+		return nil, false, nil
+	}
+	endpoint := fmt.Sprintf("%s/xrpc/com.atproto.repo.describeRepo?repo=%s", r.pdsURL, url.QueryEscape(did))
+
+	var result struct {
+		DIDDoc *Document `json:"didDoc"`
+	}
+	status, err := r.getJSON(ctx, endpoint, &result)
+	if status == http.StatusBadRequest || status == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if result.DIDDoc == nil {
+		return nil, false, nil
+	}
+	if err := checkDocument(result.DIDDoc, did); err != nil {
+		return nil, false, err
+	}
+	return result.DIDDoc, true, nil
+}
+
+// checkDocument makes sure a document is for the DID that was asked for, so
+// a document for another DID can't redirect a login elsewhere
+func checkDocument(doc *Document, did string) error {
+	if doc.ID != did {
+		return fmt.Errorf("DID document is for %q, not %s", doc.ID, did)
+	}
+	return nil
+}
+
+// getJSON fetches a URL and decodes its JSON body, returning the HTTP status
+// even when the request fails
+func (r *Resolver) getJSON(ctx context.Context, endpoint string, v interface{}) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("HTTP %d - %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(v); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// didWebURL returns where a did:web document is served. The method-specific
+// ID is a domain, with an optional percent-encoded port, followed by
+// colon-separated path segments: did:web:example.com is served at
+// https://example.com/.well-known/did.json and did:web:example.com:u:alice
+// at https://example.com/u/alice/did.json.
+func didWebURL(did string) (string, error) {
+	segments := strings.Split(strings.TrimPrefix(did, "did:web:"), ":")
+	host, err := url.PathUnescape(segments[0])
+	if err != nil || host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", fmt.Errorf("%w: invalid did:web domain in %s", ErrUnsupportedDID, did)
+	}
+
+	path := "/.well-known"
+	if len(segments) > 1 {
+		path = ""
+		for _, segment := range segments[1:] {
+			decoded, err := url.PathUnescape(segment)
+			if err != nil || decoded == "" || decoded == "." || decoded == ".." || strings.Contains(decoded, "/") {
+				return "", fmt.Errorf("%w: invalid did:web path in %s", ErrUnsupportedDID, did)
+			}
+			path += "/" + url.PathEscape(decoded)
+		}
+	}
+	return "https://" + host + path + "/did.json", nil
+}
@@ -0,0 +1,262 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newHandlePDS resolves alice.test, answers 400 for unknown handles and 502
+// for broken.test, counting resolveHandle calls
+func newHandlePDS(t *testing.T, calls *int32) *Resolver {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/xrpc/com.atproto.identity.resolveHandle" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(calls, 1)
+		switch r.URL.Query().Get("handle") {
+		case "alice.test":
+			json.NewEncoder(w).Encode(map[string]string{"did": "did:plc:alice"})
+		case "broken.test":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "InvalidRequest", "message": "Unable to resolve handle"})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return New(Options{PDSURL: server.URL, NegativeTTL: time.Minute})
+}
+
+// document returns a DID document whose PDS is pds
+func document(did, pds string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          did,
+		"alsoKnownAs": []string{"at://alice.test"},
+		"service": []map[string]string{
+			{"id": "#atproto_pds", "type": "AtprotoPersonalDataServer", "serviceEndpoint": pds},
+		},
+	}
+}
+
+func TestResolveHandleCaches(t *testing.T) {
+	var calls int32
+	resolver := newHandlePDS(t, &calls)
+	ctx := context.Background()
+
+	for _, handle := range []string{"alice.test", "@Alice.Test", "alice.test"} {
+		did, err := resolver.ResolveHandle(ctx, handle)
+		if err != nil || did != "did:plc:alice" {
+			t.Fatalf("Expected %s to resolve, got %q, %v", handle, did, err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected one lookup, got %d", n)
+	}
+
+	resolver.Invalidate("alice.test")
+	_, _ = resolver.ResolveHandle(ctx, "alice.test")
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected an invalidated handle to be looked up again, got %d lookups", n)
+	}
+}
+
+func TestResolveHandleCachesFailures(t *testing.T) {
+	var calls int32
+	resolver := newHandlePDS(t, &calls)
+	now := time.Now()
+	resolver.handles.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := resolver.ResolveHandle(ctx, "nobody.test"); !errors.Is(err, ErrHandleNotFound) {
+			t.Fatalf("Expected ErrHandleNotFound, got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected an unknown handle to be remembered, got %d lookups", n)
+	}
+	now = now.Add(2 * time.Minute)
+	_, _ = resolver.ResolveHandle(ctx, "nobody.test")
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected the negative result to expire, got %d lookups", n)
+	}
+
+	// Server errors aren't cached
+	atomic.StoreInt32(&calls, 0)
+	for i := 0; i < 2; i++ {
+		if _, err := resolver.ResolveHandle(ctx, "broken.test"); err == nil || errors.Is(err, ErrHandleNotFound) {
+			t.Fatalf("Expected a resolution failure, got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected failures to be retried, got %d lookups", n)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newCache(2, time.Now)
+	c.put("a.test", "did:plc:a", time.Hour)
+	c.put("b.test", "did:plc:b", time.Hour)
+	c.get("a.test")
+	c.put("c.test", "did:plc:c", time.Hour)
+
+	if _, ok := c.get("b.test"); ok {
+		t.Error("Expected the least recently used key to be evicted")
+	}
+	if did, ok := c.get("a.test"); !ok || did != "did:plc:a" {
+		t.Error("Expected a recently used key to be kept")
+	}
+	if c.len() != 2 {
+		t.Errorf("Expected two cached keys, got %d", c.len())
+	}
+}
+
+func TestResolveDIDFailsOverBetweenDirectories(t *testing.T) {
+	const did = "did:plc:alice"
+	var downCalls, upCalls int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downCalls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upCalls, 1)
+		if r.URL.Path != "/"+did {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(document(did, "https://pds.example.com"))
+	}))
+	defer up.Close()
+
+	resolver := New(Options{PLCDirectories: []string{down.URL, up.URL + "/"}})
+	for i := 0; i < 2; i++ {
+		doc, err := resolver.ResolveDID(context.Background(), did)
+		if err != nil {
+			t.Fatalf("Expected the second directory to answer, got %v", err)
+		}
+		if doc.PDSEndpoint() != "https://pds.example.com" || doc.Handle() != "alice.test" {
+			t.Errorf("Unexpected document %+v", doc)
+		}
+	}
+	if atomic.LoadInt32(&downCalls) != 1 || atomic.LoadInt32(&upCalls) != 1 {
+		t.Errorf("Expected one request to each directory, got %d and %d", downCalls, upCalls)
+	}
+}
+
+func TestResolveDIDFallsBackToPDS(t *testing.T) {
+	const did = "did:plc:alice"
+	directory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer directory.Close()
+	pds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xrpc/com.atproto.repo.describeRepo" || r.URL.Query().Get("repo") != did {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"did":    did,
+			"handle": "alice.test",
+			"didDoc": document(did, "https://pds.example.com"),
+		})
+	}))
+	defer pds.Close()
+
+	resolver := New(Options{PDSURL: pds.URL, PLCDirectories: []string{directory.URL}})
+	doc, err := resolver.ResolveDID(context.Background(), did)
+	if err != nil || doc.PDSEndpoint() != "https://pds.example.com" {
+		t.Fatalf("Expected the PDS to provide the document, got %+v, %v", doc, err)
+	}
+
+	if _, err := resolver.ResolveDID(context.Background(), "did:plc:bob"); err == nil || errors.Is(err, ErrDIDNotFound) {
+		t.Errorf("Expected a failure while the directory is down, got %v", err)
+	}
+}
+
+func TestResolveDIDNotFound(t *testing.T) {
+	var calls int32
+	directory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.NotFound(w, r)
+	}))
+	defer directory.Close()
+
+	resolver := New(Options{PLCDirectories: []string{directory.URL}})
+	for i := 0; i < 2; i++ {
+		if _, err := resolver.ResolveDID(context.Background(), "did:plc:nobody"); !errors.Is(err, ErrDIDNotFound) {
+			t.Fatalf("Expected ErrDIDNotFound, got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected a missing DID to be remembered, got %d lookups", n)
+	}
+
+	if _, err := resolver.ResolveDID(context.Background(), "did:key:z6Mk"); !errors.Is(err, ErrUnsupportedDID) {
+		t.Errorf("Expected ErrUnsupportedDID, got %v", err)
+	}
+}
+
+func TestDidWebURL(t *testing.T) {
+	tests := map[string]string{
+		"did:web:example.com":             "https://example.com/.well-known/did.json",
+		"did:web:example.com%3A8443":      "https://example.com:8443/.well-known/did.json",
+		"did:web:example.com:users:alice": "https://example.com/users/alice/did.json",
+		"did:web:example.com:users:a%20b": "https://example.com/users/a%20b/did.json",
+	}
+	for did, want := range tests {
+		if got, err := didWebURL(did); err != nil || got != want {
+			t.Errorf("didWebURL(%s) = %q, %v; want %q", did, got, err, want)
+		}
+	}
+
+	for _, did := range []string{"did:web:", "did:web:evil.com%2Fx", "did:web:a@b.com", "did:web:example.com::x", "did:web:example.com:..", "did:web:example.com:a%2Fb"} {
+		if got, err := didWebURL(did); err == nil {
+			t.Errorf("Expected %s to be rejected, got %s", did, got)
+		}
+	}
+}
+
+func TestResolveDIDForDidWeb(t *testing.T) {
+	var did string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/players/alice/did.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(document(did, "https://pds.example.com"))
+	}))
+	defer server.Close()
+
+	resolver := New(Options{HTTPClient: server.Client()})
+	host := strings.ReplaceAll(strings.TrimPrefix(server.URL, "https://"), ":", "%3A")
+	did = "did:web:" + host + ":players:alice"
+
+	doc, err := resolver.ResolveDID(context.Background(), did)
+	if err != nil {
+		t.Fatalf("Expected the DID document, got %v", err)
+	}
+	if pds := doc.PDSEndpoint(); pds != "https://pds.example.com" {
+		t.Errorf("Expected the PDS from the document, got %q", pds)
+	}
+
+	if _, err := resolver.ResolveDID(context.Background(), "did:web:"+host+":players:bob"); !errors.Is(err, ErrDIDNotFound) {
+		t.Errorf("Expected a missing document to be not found, got %v", err)
+	}
+	served := did
+	did = "did:web:" + host + ":players:mallory"
+	resolver.Invalidate(served)
+	if _, err := resolver.ResolveDID(context.Background(), served); err == nil {
+		t.Error("Expected a document for a different DID to be refused")
+	}
+}
@@ -0,0 +1,401 @@
+// Package importer fetches a player's games from Lichess and Chess.com
+// through their public APIs so they can be brought onto AT Protocol.
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// Sites games can be imported from. SitePGN is for games from PGN files
+// that don't link to either site.
+const (
+	SiteLichess  = "lichess"
+	SiteChessCom = "chess.com"
+	SitePGN      = "pgn"
+)
+
+// Public API base URLs
+const (
+	LichessURL  = "https://lichess.org"
+	ChessComURL = "https://api.chess.com"
+)
+
+// DefaultGames and MaxGames bound how many of a player's games are fetched
+const (
+	DefaultGames = 20
+	MaxGames     = 100
+)
+
+// chessComArchives is how many monthly archives are searched for a game
+// given only its URL
+const chessComArchives = 6
+
+// ErrNotFound is returned when the site has no such game or player
+var ErrNotFound = errors.New("not found")
+
+// ErrUnsupportedURL is returned for links that aren't to a Lichess or Chess.com game
+var ErrUnsupportedURL = errors.New("not a Lichess or Chess.com game URL")
+
+var (
+	lichessGameID  = regexp.MustCompile(`^[A-Za-z0-9]{8}([A-Za-z0-9]{4})?$`)
+	chessComGameID = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// SiteDID returns the did:web standing in for players on a site, who have
+// no DID of their own. Opponents in games from elsewhere get a name under
+// the reserved .invalid domain, which resolves nowhere.
+func SiteDID(site string) string {
+	switch site {
+	case SiteLichess:
+		return "did:web:lichess.org"
+	case SiteChessCom:
+		return "did:web:chess.com"
+	default:
+		return "did:web:pgn.invalid"
+	}
+}
+
+// Game is a game fetched from a site
+type Game struct {
+	Site string
+	ID   string
+	URL  string
+	PGN  *chess.PGNGame
+}
+
+// FromPGN identifies a game read from a PGN file: by its Lichess or
+// Chess.com link when it has one, as in files exported from those sites, or
+// else by its players, date and moves
+func FromPGN(pgn *chess.PGNGame) *Game {
+	for _, tag := range []string{"Site", "Link"} {
+		site, id, err := ParseGameURL(pgn.Tags[tag])
+		if err != nil {
+			continue
+		}
+		gameURL := strings.TrimSpace(pgn.Tags[tag])
+		if site == SiteLichess {
+			gameURL = "https://lichess.org/" + id
+		}
+		return &Game{Site: site, ID: id, URL: gameURL, PGN: pgn}
+	}
+
+	hash := sha256.New()
+	for _, tag := range []string{"White", "Black", "Date", "UTCDate", "UTCTime", "Result"} {
+		fmt.Fprintf(hash, "%s\x00", pgn.Tags[tag])
+	}
+	fmt.Fprint(hash, strings.Join(pgn.Moves, " "))
+	return &Game{Site: SitePGN, ID: hex.EncodeToString(hash.Sum(nil))[:16], PGN: pgn}
+}
+
+// Fetcher reads games from the sites' public APIs
+type Fetcher struct {
+	httpClient  *http.Client
+	lichessURL  string
+	chessComURL string
+}
+
+// NewFetcher creates a fetcher using the given API base URLs, normally
+// LichessURL and ChessComURL
+func NewFetcher(lichessURL, chessComURL string) *Fetcher {
+	return &Fetcher{
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		lichessURL:  strings.TrimRight(lichessURL, "/"),
+		chessComURL: strings.TrimRight(chessComURL, "/"),
+	}
+}
+
+// ParseGameURL returns the site and game ID a game URL points at, e.g.
+// https://lichess.org/abcd1234 or https://www.chess.com/game/live/123456789
+func ParseGameURL(raw string) (site, id string, err error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", "", ErrUnsupportedURL
+	}
+	segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+
+	switch strings.TrimPrefix(strings.ToLower(u.Host), "www.") {
+	case "lichess.org":
+		// The game ID may be followed by a player's four-character suffix
+		if len(segments) > 0 && lichessGameID.MatchString(segments[0]) {
+			return SiteLichess, segments[0][:8], nil
+		}
+	case "chess.com":
+		// /game/live/ID, /game/daily/ID or the older /live/game/ID
+		if len(segments) >= 3 && (segments[0] == "game" || segments[1] == "game") && chessComGameID.MatchString(segments[2]) {
+			return SiteChessCom, segments[2], nil
+		}
+	}
+	return "", "", ErrUnsupportedURL
+}
+
+// FetchGame fetches the game at a game URL. Chess.com only publishes games
+// by player, so username, one of the game's players, is needed for its games.
+func (f *Fetcher) FetchGame(ctx context.Context, gameURL, username string) (*Game, error) {
+	site, id, err := ParseGameURL(gameURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if site == SiteLichess {
+		body, err := f.get(ctx, fmt.Sprintf("%s/game/export/%s?clocks=false&evals=false", f.lichessURL, id), "application/x-chess-pgn")
+		if err != nil {
+			return nil, err
+		}
+		games, err := chess.ReadPGN(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read game %s: %w", id, err)
+		}
+		if len(games) == 0 {
+			return nil, ErrNotFound
+		}
+		return &Game{Site: SiteLichess, ID: id, URL: "https://lichess.org/" + id, PGN: games[0]}, nil
+	}
+
+	if username == "" {
+		return nil, fmt.Errorf("a Chess.com username is needed to find game %s", id)
+	}
+	archives, err := f.chessComArchives(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(archives) - 1; i >= 0 && i >= len(archives)-chessComArchives; i-- {
+		games, err := f.chessComArchive(ctx, archives[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, game := range games {
+			if game.ID == id {
+				return game, nil
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// FetchPlayerGames fetches up to max of a player's most recent finished
+// games on a site, newest first
+func (f *Fetcher) FetchPlayerGames(ctx context.Context, site, username string, max int) ([]*Game, error) {
+	if max <= 0 {
+		max = DefaultGames
+	}
+	max = min(max, MaxGames)
+
+	switch site {
+	case SiteLichess:
+		body, err := f.get(ctx, fmt.Sprintf("%s/api/games/user/%s?max=%d&finished=true&clocks=false&evals=false",
+			f.lichessURL, url.PathEscape(username), max), "application/x-chess-pgn")
+		if err != nil {
+			return nil, err
+		}
+		pgns, err := chess.ReadPGN(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s's games: %w", username, err)
+		}
+		games := make([]*Game, 0, len(pgns))
+		for _, pgn := range pgns {
+			_, id, err := ParseGameURL(pgn.Tags["Site"])
+			if err != nil {
+				continue
+			}
+			games = append(games, &Game{Site: SiteLichess, ID: id, URL: "https://lichess.org/" + id, PGN: pgn})
+		}
+		return games, nil
+
+	case SiteChessCom:
+		archives, err := f.chessComArchives(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		var games []*Game
+		for i := len(archives) - 1; i >= 0 && len(games) < max; i-- {
+			month, err := f.chessComArchive(ctx, archives[i])
+			if err != nil {
+				return nil, err
+			}
+			// Archives list games oldest first
+			for j := len(month) - 1; j >= 0 && len(games) < max; j-- {
+				games = append(games, month[j])
+			}
+		}
+		return games, nil
+
+	default:
+		return nil, fmt.Errorf("unknown site %q", site)
+	}
+}
+
+// chessComArchives returns the URLs of a player's monthly game archives,
+// oldest first
+func (f *Fetcher) chessComArchives(ctx context.Context, username string) ([]string, error) {
+	body, err := f.get(ctx, fmt.Sprintf("%s/pub/player/%s/games/archives", f.chessComURL, url.PathEscape(strings.ToLower(username))), "application/json")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Archives []string `json:"archives"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode archives for %s: %w", username, err)
+	}
+	return resp.Archives, nil
+}
+
+// chessComArchive returns the standard chess games in a monthly archive
+func (f *Fetcher) chessComArchive(ctx context.Context, archiveURL string) ([]*Game, error) {
+	// Archive URLs are absolute; keep only the path so they're read from
+	// the configured API
+	if u, err := url.Parse(archiveURL); err == nil && u.Host != "" {
+		archiveURL = f.chessComURL + u.Path
+	}
+	body, err := f.get(ctx, archiveURL, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Games []struct {
+			URL   string `json:"url"`
+			PGN   string `json:"pgn"`
+			Rules string `json:"rules"`
+		} `json:"games"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode archive %s: %w", archiveURL, err)
+	}
+
+	var games []*Game
+	for _, g := range resp.Games {
+		if g.Rules != "chess" || g.PGN == "" {
+			continue
+		}
+		_, id, err := ParseGameURL(g.URL)
+		if err != nil {
+			continue
+		}
+		pgns, err := chess.ReadPGN(g.PGN)
+		if err != nil || len(pgns) == 0 {
+			continue
+		}
+		games = append(games, &Game{Site: SiteChessCom, ID: id, URL: g.URL, PGN: pgns[0]})
+	}
+	return games, nil
+}
+
+func (f *Fetcher) get(ctx context.Context, url, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s returned HTTP %d - %s", req.URL.Host, resp.StatusCode, string(body))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Side returns the color username played in the game, "white" or "black",
+// or "" if they didn't play in it. Names are matched ignoring case.
+func (g *Game) Side(username string) string {
+	switch {
+	case username == "":
+		return ""
+	case strings.EqualFold(g.PGN.Tags["White"], username):
+		return "white"
+	case strings.EqualFold(g.PGN.Tags["Black"], username):
+		return "black"
+	default:
+		return ""
+	}
+}
+
+// Convert replays the game into the game and moves to record for the
+// player did, who played color. The opponent is recorded as the site's DID.
+// Only finished standard games from the initial position can be converted;
+// the error otherwise says why the game can't be imported.
+func (g *Game) Convert(did, color string) (*chess.Game, []*chess.MoveResult, error) {
+	tags := g.PGN.Tags
+	if variant := tags["Variant"]; variant != "" && !strings.EqualFold(variant, chess.VariantStandard) {
+		return nil, nil, fmt.Errorf("%s games can't be imported", variant)
+	}
+	if tags["SetUp"] == "1" || tags["FEN"] != "" {
+		return nil, nil, fmt.Errorf("games from a set-up position can't be imported")
+	}
+	status := g.PGN.Status()
+	if status == chess.StatusActive {
+		return nil, nil, fmt.Errorf("game is unfinished")
+	}
+	moves, err := g.PGN.Replay()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	game := &chess.Game{
+		White:       SiteDID(g.Site),
+		Black:       SiteDID(g.Site),
+		Status:      status,
+		TimeControl: g.PGN.TimeControl(),
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		Source: &chess.GameSource{
+			Site:  g.Site,
+			ID:    g.ID,
+			URL:   g.URL,
+			White: tags["White"],
+			Black: tags["Black"],
+		},
+	}
+	switch color {
+	case "white":
+		game.White = did
+	case "black":
+		game.Black = did
+	default:
+		return nil, nil, fmt.Errorf("unknown color %q", color)
+	}
+	if at, ok := g.PGN.PlayedAt(); ok {
+		game.CreatedAt = at.Format(time.RFC3339)
+	}
+
+	pgnMoves := make([]chess.PGNMove, len(moves))
+	for i, move := range moves {
+		pgnMoves[i] = chess.PGNMove{From: move.From, To: move.To, Promotion: move.Promotion, SAN: move.SAN}
+	}
+	date := tags["UTCDate"]
+	if date == "" {
+		date = tags["Date"]
+	}
+	game.PGN, err = chess.ExportPGN(chess.StartingFEN, chess.PGNTags{
+		Event:  tags["Event"],
+		Site:   g.URL,
+		Date:   date,
+		Round:  tags["Round"],
+		White:  tags["White"],
+		Black:  tags["Black"],
+		Result: chess.ResultForStatus(status),
+	}, pgnMoves)
+	if err != nil {
+		return nil, nil, err
+	}
+	return game, moves, nil
+}
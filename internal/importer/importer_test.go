@@ -0,0 +1,189 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+const lichessGame = `[Event "Rated blitz game"]
+[Site "https://lichess.org/abcd1234"]
+[UTCDate "2024.03.05"]
+[UTCTime "18:04:10"]
+[White "Alice"]
+[Black "bob"]
+[Result "1-0"]
+[Variant "Standard"]
+[TimeControl "180+2"]
+
+1. e4 e5 2. Bc4 Nc6 3. Qh5 Nf6 4. Qxf7# 1-0
+`
+
+const lichessFromPosition = `[Event "Casual game"]
+[Site "https://lichess.org/efgh5678"]
+[White "alice"]
+[Black "carol"]
+[Result "0-1"]
+[Variant "From Position"]
+[FEN "8/8/8/4k3/8/8/4P3/4K3 w - - 0 1"]
+[SetUp "1"]
+
+1. e4 Kd4 0-1
+`
+
+func newServers(t *testing.T) *Fetcher {
+	t.Helper()
+	lichess := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/game/export/abcd1234":
+			_, _ = w.Write([]byte(lichessGame))
+		case "/api/games/user/alice":
+			_, _ = w.Write([]byte(lichessGame + "\n" + lichessFromPosition))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(lichess.Close)
+
+	chessCom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pub/player/dave/games/archives":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"archives": []string{
+				"https://api.chess.com/pub/player/dave/games/2024/01",
+				"https://api.chess.com/pub/player/dave/games/2024/02",
+			}})
+		case "/pub/player/dave/games/2024/01":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"games": []map[string]string{
+				{"url": "https://www.chess.com/game/daily/111", "rules": "chess", "pgn": "[White \"dave\"]\n[Black \"erin\"]\n[Result \"1/2-1/2\"]\n\n1. d4 d5 1/2-1/2"},
+			}})
+		case "/pub/player/dave/games/2024/02":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"games": []map[string]string{
+				{"url": "https://www.chess.com/game/live/222", "rules": "chess960", "pgn": "[White \"dave\"]\n\n1. e4 *"},
+				{"url": "https://www.chess.com/game/live/333", "rules": "chess", "pgn": "[White \"erin\"]\n[Black \"Dave\"]\n[Result \"0-1\"]\n\n1. f3 e5 2. g4 Qh4# 0-1"},
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(chessCom.Close)
+
+	return NewFetcher(lichess.URL, chessCom.URL)
+}
+
+func TestParseGameURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		site string
+		id   string
+	}{
+		{"https://lichess.org/abcd1234", SiteLichess, "abcd1234"},
+		{"https://lichess.org/abcd1234wxyz/black#12", SiteLichess, "abcd1234"},
+		{"https://www.chess.com/game/live/123456789", SiteChessCom, "123456789"},
+		{"https://chess.com/live/game/42", SiteChessCom, "42"},
+		{"https://lichess.org/@/alice", "", ""},
+		{"https://example.com/abcd1234", "", ""},
+	}
+	for _, tt := range tests {
+		site, id, err := ParseGameURL(tt.url)
+		if tt.site == "" {
+			if !errors.Is(err, ErrUnsupportedURL) {
+				t.Errorf("%s: expected an unsupported URL, got %s %s", tt.url, site, id)
+			}
+			continue
+		}
+		if err != nil || site != tt.site || id != tt.id {
+			t.Errorf("%s: expected %s %s, got %s %s (%v)", tt.url, tt.site, tt.id, site, id, err)
+		}
+	}
+}
+
+func TestFetchAndConvertLichessGames(t *testing.T) {
+	fetcher := newServers(t)
+	ctx := context.Background()
+
+	game, err := fetcher.FetchGame(ctx, "https://lichess.org/abcd1234/white", "")
+	if err != nil {
+		t.Fatalf("Failed to fetch game: %v", err)
+	}
+	if game.Side("alice") != "white" || game.Side("mallory") != "" {
+		t.Errorf("Expected names matched ignoring case")
+	}
+	converted, moves, err := game.Convert("did:plc:alice", "white")
+	if err != nil {
+		t.Fatalf("Failed to convert game: %v", err)
+	}
+	if converted.White != "did:plc:alice" || converted.Black != "did:web:lichess.org" || converted.Status != chess.StatusWhiteWon {
+		t.Errorf("Expected alice's win over the site's DID, got %+v", converted)
+	}
+	if converted.CreatedAt != "2024-03-05T18:04:10Z" || converted.TimeControl.Type != "blitz" || len(moves) != 7 {
+		t.Errorf("Expected the game's time and moves, got %s %+v %d", converted.CreatedAt, converted.TimeControl, len(moves))
+	}
+	if converted.Source.URL != "https://lichess.org/abcd1234" || converted.Source.Black != "bob" {
+		t.Errorf("Expected the source recorded, got %+v", converted.Source)
+	}
+
+	games, err := fetcher.FetchPlayerGames(ctx, SiteLichess, "alice", 0)
+	if err != nil || len(games) != 2 || games[1].ID != "efgh5678" {
+		t.Fatalf("Expected both of alice's games, got %v %v", err, games)
+	}
+	if _, _, err := games[1].Convert("did:plc:alice", "white"); err == nil {
+		t.Error("Expected a game from a position to be refused")
+	}
+
+	if _, err := fetcher.FetchGame(ctx, "https://lichess.org/zzzz9999", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a missing game to be not found, got %v", err)
+	}
+}
+
+func TestFetchChessComGames(t *testing.T) {
+	fetcher := newServers(t)
+	ctx := context.Background()
+
+	games, err := fetcher.FetchPlayerGames(ctx, SiteChessCom, "Dave", 5)
+	if err != nil {
+		t.Fatalf("Failed to fetch games: %v", err)
+	}
+	if len(games) != 2 || games[0].ID != "333" || games[1].ID != "111" {
+		t.Fatalf("Expected standard games newest first, got %+v", games)
+	}
+	if games[0].Side("dave") != "black" {
+		t.Errorf("Expected dave as black, got %q", games[0].Side("dave"))
+	}
+
+	game, err := fetcher.FetchGame(ctx, "https://www.chess.com/game/daily/111", "dave")
+	if err != nil || game.PGN.Status() != chess.StatusDraw {
+		t.Errorf("Expected the game found in an older archive, got %v %+v", err, game)
+	}
+	if _, err := fetcher.FetchGame(ctx, "https://www.chess.com/game/live/333", ""); err == nil {
+		t.Error("Expected a username to be needed for Chess.com games")
+	}
+}
+
+func TestFromPGN(t *testing.T) {
+	pgns, err := chess.ReadPGN(`[Site "Chess.com"]
+[White "dave"]
+[Link "https://www.chess.com/game/live/333"]
+
+1. f3 e5 *
+
+[Site "Club night"]
+[White "dave"]
+
+1. f3 e5 *
+`)
+	if err != nil {
+		t.Fatalf("ReadPGN failed: %v", err)
+	}
+	if game := FromPGN(pgns[0]); game.Site != SiteChessCom || game.ID != "333" {
+		t.Errorf("Expected the game identified by its link, got %+v", game)
+	}
+	game := FromPGN(pgns[1])
+	if game.Site != SitePGN || len(game.ID) != 16 || FromPGN(pgns[1]).ID != game.ID {
+		t.Errorf("Expected the game identified by its content, got %+v", game)
+	}
+}
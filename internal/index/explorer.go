@@ -0,0 +1,161 @@
+package index
+
+import (
+	"context"
+	"sort"
+)
+
+// Results tallies game outcomes
+type Results struct {
+	Games     int `json:"games"`
+	WhiteWins int `json:"whiteWins"`
+	Draws     int `json:"draws"`
+	BlackWins int `json:"blackWins"`
+}
+
+func (r *Results) add(status string) {
+	r.Games++
+	switch status {
+	case "white_won":
+		r.WhiteWins++
+	case "black_won":
+		r.BlackWins++
+	case "draw":
+		r.Draws++
+	}
+}
+
+// Continuation is a move played from an explored position
+type Continuation struct {
+	SAN string `json:"san"`
+	Results
+}
+
+// Exploration aggregates finished games that reached a position by a given
+// sequence of moves, and what was played next
+type Exploration struct {
+	Moves []string `json:"moves"`
+	Results
+	Next []*Continuation `json:"next"`
+}
+
+// Explore aggregates the most recent maxGames finished games that began
+// with moves (in SAN), counting results and the next move played
+func (i *Indexer) Explore(ctx context.Context, moves []string, maxGames int) (*Exploration, error) {
+	exploration := &Exploration{Moves: moves, Next: []*Continuation{}}
+	next := make(map[string]*Continuation)
+
+	query := Query{Finished: true, Limit: MaxLimit}
+	seen := 0
+	for seen < maxGames {
+		page, err := i.ListGames(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, game := range page.Games {
+			if seen == maxGames {
+				break
+			}
+			seen++
+
+			played, err := i.store.ListMoves(ctx, game.URI)
+			if err != nil {
+				return nil, err
+			}
+			if !startsWith(played, moves) {
+				continue
+			}
+
+			exploration.add(game.Status)
+			if len(played) > len(moves) {
+				san := played[len(moves)].SAN
+				if next[san] == nil {
+					next[san] = &Continuation{SAN: san}
+					exploration.Next = append(exploration.Next, next[san])
+				}
+				next[san].add(game.Status)
+			}
+		}
+
+		if page.Cursor == "" {
+			break
+		}
+		query.Cursor = page.Cursor
+	}
+
+	sort.Slice(exploration.Next, func(a, b int) bool {
+		if exploration.Next[a].Games != exploration.Next[b].Games {
+			return exploration.Next[a].Games > exploration.Next[b].Games
+		}
+		return exploration.Next[a].SAN < exploration.Next[b].SAN
+	})
+	return exploration, nil
+}
+
+func startsWith(played []*Move, prefix []string) bool {
+	if len(played) < len(prefix) {
+		return false
+	}
+	for i, san := range prefix {
+		if played[i].SAN != san {
+			return false
+		}
+	}
+	return true
+}
+
+// OpeningResults tallies the finished games played in one opening
+type OpeningResults struct {
+	ECO  string `json:"eco"`
+	Name string `json:"name"`
+	Results
+}
+
+// ExploreOpenings aggregates the most recent maxGames finished games by
+// opening, most played first. eco limits it to openings whose code starts
+// with a prefix such as "B" or "B9"; games without a known opening aren't
+// counted.
+func (i *Indexer) ExploreOpenings(ctx context.Context, eco string, maxGames int) ([]*OpeningResults, error) {
+	results := []*OpeningResults{}
+	byOpening := make(map[string]*OpeningResults)
+
+	query := Query{Finished: true, ECO: eco, Limit: MaxLimit}
+	seen := 0
+	for seen < maxGames {
+		page, err := i.ListGames(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, game := range page.Games {
+			if seen == maxGames {
+				break
+			}
+			seen++
+			if game.ECO == "" {
+				continue
+			}
+
+			key := game.ECO + " " + game.Opening
+			if byOpening[key] == nil {
+				byOpening[key] = &OpeningResults{ECO: game.ECO, Name: game.Opening}
+				results = append(results, byOpening[key])
+			}
+			byOpening[key].add(game.Status)
+		}
+
+		if page.Cursor == "" {
+			break
+		}
+		query.Cursor = page.Cursor
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Games != results[b].Games {
+			return results[a].Games > results[b].Games
+		}
+		return results[a].ECO < results[b].ECO
+	})
+	return results, nil
+}
@@ -0,0 +1,124 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+func indexGame(t *testing.T, indexer *Indexer, rkey, status string, sans ...string) {
+	t.Helper()
+	ctx := context.Background()
+	gameURI := "at://did:plc:alice/app.atchess.game/" + rkey
+	if err := indexer.Apply(ctx, "create", "did:plc:alice", "app.atchess.game/"+rkey, gameRecord("did:plc:alice", "did:plc:bob", status, "blitz", "2024-01-01T10:00:00Z")); err != nil {
+		t.Fatalf("Failed to index game: %v", err)
+	}
+	engine := chess.NewEngine()
+	for i, san := range sans {
+		result, err := engine.MakeMoveSAN(san)
+		if err != nil {
+			t.Fatalf("Illegal move %s: %v", san, err)
+		}
+		move := moveRecord(gameURI, "did:plc:alice", fmt.Sprintf("2024-01-01T10:%02d:00Z", i+1))
+		move["san"] = san
+		move["fen"] = result.FEN
+		if err := indexer.Apply(ctx, "create", "did:plc:alice", fmt.Sprintf("app.atchess.move/%s-%d", rkey, i), move); err != nil {
+			t.Fatalf("Failed to index move: %v", err)
+		}
+	}
+}
+
+func TestExplore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		indexer := NewIndexer(store)
+		indexGame(t, indexer, "g1", "white_won", "e4", "e5", "Nf3")
+		indexGame(t, indexer, "g2", "draw", "e4", "c5")
+		indexGame(t, indexer, "g3", "black_won", "e4", "e5", "Bc4")
+		indexGame(t, indexer, "g4", "white_won", "d4", "d5")
+		// Unfinished games aren't counted
+		indexGame(t, indexer, "g5", "active", "e4", "e5")
+
+		page, err := indexer.ListGames(ctx, Query{Finished: true})
+		if err != nil {
+			t.Fatalf("ListGames failed: %v", err)
+		}
+		if page.Total != 4 {
+			t.Errorf("Expected 4 finished games, got %d", page.Total)
+		}
+
+		exploration, err := indexer.Explore(ctx, []string{"e4"}, 100)
+		if err != nil {
+			t.Fatalf("Explore failed: %v", err)
+		}
+		if exploration.Games != 3 || exploration.WhiteWins != 1 || exploration.Draws != 1 || exploration.BlackWins != 1 {
+			t.Errorf("Unexpected results: %+v", exploration.Results)
+		}
+		if len(exploration.Next) != 2 || exploration.Next[0].SAN != "e5" || exploration.Next[0].Games != 2 || exploration.Next[1].SAN != "c5" {
+			t.Errorf("Unexpected continuations: %+v", exploration.Next)
+		}
+
+		exploration, err = indexer.Explore(ctx, nil, 100)
+		if err != nil {
+			t.Fatalf("Explore failed: %v", err)
+		}
+		if exploration.Games != 4 || len(exploration.Next) != 2 || exploration.Next[0].SAN != "e4" {
+			t.Errorf("Unexpected starting position: %+v", exploration)
+		}
+
+		// The sample is limited to the most recent games
+		exploration, _ = indexer.Explore(ctx, nil, 1)
+		if exploration.Games != 1 {
+			t.Errorf("Expected 1 sampled game, got %d", exploration.Games)
+		}
+	})
+}
+
+func TestExploreOpenings(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		indexer := NewIndexer(store)
+		indexGame(t, indexer, "g1", "white_won", "e4", "e5", "Nf3", "Nc6", "Bb5")
+		indexGame(t, indexer, "g2", "draw", "e4", "e5", "Nf3", "Nc6", "Bb5", "a6")
+		indexGame(t, indexer, "g3", "black_won", "e4", "c5", "Nf3", "d6")
+		indexGame(t, indexer, "g4", "white_won", "e4", "e5", "Nf3", "Nc6", "Bb5", "Nf6")
+		// Unknown lines and unfinished games aren't counted
+		indexGame(t, indexer, "g5", "draw", "a3", "h6")
+		indexGame(t, indexer, "g6", "active", "d4", "d5", "c4")
+
+		page, err := indexer.ListGames(ctx, Query{})
+		if err != nil {
+			t.Fatalf("ListGames failed: %v", err)
+		}
+		ecos := make(map[string]string)
+		for _, game := range page.Games {
+			ecos[game.URI[len(game.URI)-2:]] = game.ECO + " " + game.Opening
+		}
+		if ecos["g2"] != "C68 Ruy Lopez: Morphy Defense" || ecos["g5"] != " " || ecos["g6"] != "D06 Queen's Gambit" {
+			t.Errorf("Unexpected openings: %v", ecos)
+		}
+
+		results, err := indexer.ExploreOpenings(ctx, "", 100)
+		if err != nil {
+			t.Fatalf("ExploreOpenings failed: %v", err)
+		}
+		if len(results) != 4 {
+			t.Fatalf("Expected 4 openings, got %+v", results)
+		}
+		if results[0].ECO != "B50" || results[0].Games != 1 || results[0].BlackWins != 1 {
+			t.Errorf("Unexpected first opening: %+v", results[0])
+		}
+
+		results, _ = indexer.ExploreOpenings(ctx, "C6", 100)
+		if len(results) != 3 {
+			t.Errorf("Expected 3 Ruy Lopez lines, got %+v", results)
+		}
+		for _, result := range results {
+			if result.ECO[:2] != "C6" {
+				t.Errorf("Expected only C6x openings, got %+v", result)
+			}
+		}
+	})
+}
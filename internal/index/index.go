@@ -0,0 +1,316 @@
+// Package index maintains a queryable view of chess games, moves and players
+// built from records seen on the firehose.
+package index
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// Collections indexed from the firehose
+const (
+	GameCollection = "app.atchess.game"
+	MoveCollection = "app.atchess.move"
+)
+
+const (
+	// DefaultLimit is the page size when a query doesn't set one
+	DefaultLimit = 50
+	// MaxLimit bounds the page size of a query
+	MaxLimit = 100
+)
+
+// ErrInvalidCursor is returned for cursors not produced by a previous page
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Game is an indexed game record
+type Game struct {
+	URI         string     `json:"uri"`
+	White       string     `json:"white"`
+	Black       string     `json:"black"`
+	Status      string     `json:"status"`
+	FEN         string     `json:"fen"`
+	TimeControl string     `json:"timeControl,omitempty"` // correspondence, rapid, blitz or bullet
+	MoveCount   int        `json:"moveCount"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastMoveAt  *time.Time `json:"lastMoveAt,omitempty"`
+	ECO         string     `json:"eco,omitempty"`     // opening code, once the game reaches a known line
+	Opening     string     `json:"opening,omitempty"` // opening name
+	// Extensions is metadata other apps attached to the game record
+	Extensions chess.Extensions `json:"extensions,omitempty"`
+}
+
+// lastActivity orders games in listings, most recently active first
+func (g *Game) lastActivity() time.Time {
+	if g.LastMoveAt != nil {
+		return *g.LastMoveAt
+	}
+	return g.CreatedAt
+}
+
+// finishedStatuses are the statuses of games with a final result
+var finishedStatuses = []string{"white_won", "black_won", "draw", "abandoned"}
+
+// finished reports whether the game has a final result
+func (g *Game) finished() bool {
+	for _, status := range finishedStatuses {
+		if g.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Move is an indexed move record
+type Move struct {
+	URI       string    `json:"uri"`
+	GameURI   string    `json:"gameUri"`
+	Player    string    `json:"player"`
+	SAN       string    `json:"san,omitempty"`
+	FEN       string    `json:"fen"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Player summarises a DID's indexed games
+type Player struct {
+	DID        string    `json:"did"`
+	Games      int       `json:"games"`
+	LastActive time.Time `json:"lastActive"`
+}
+
+// Query filters and pages a game listing. Empty fields match everything.
+type Query struct {
+	Player      string
+	Opponent    string // only games against this player as well, with Player
+	Status      string
+	Finished    bool // only games with a final result
+	TimeControl string
+	Extension   string // only games with metadata under this namespace
+	ECO         string // only games whose opening code starts with this, e.g. "C" or "C6"
+	Limit       int
+	Cursor      string
+}
+
+// Page is one page of a game listing
+type Page struct {
+	Games  []*Game `json:"games"`
+	Total  int     `json:"total"`            // games matching the filters across all pages
+	Cursor string  `json:"cursor,omitempty"` // empty on the last page
+}
+
+// Store persists the index
+type Store interface {
+	PutGame(ctx context.Context, game *Game) error
+	DeleteGame(ctx context.Context, uri string) error
+	// PutMove records a move and updates its game's move count, last move
+	// time and opening
+	PutMove(ctx context.Context, move *Move) error
+	DeleteMove(ctx context.Context, uri string) error
+	// ListMoves returns a game's moves in the order they were made
+	ListMoves(ctx context.Context, gameURI string) ([]*Move, error)
+	ListGames(ctx context.Context, query Query) (*Page, error)
+	GetPlayer(ctx context.Context, did string) (*Player, error)
+	PutSeek(ctx context.Context, seek *Seek) error
+	DeleteSeek(ctx context.Context, uri string) error
+	// MatchSeek records that a game was created from a seek, closing it
+	MatchSeek(ctx context.Context, seekURI, gameURI string) error
+	// ListSeeks returns open, unmatched seeks, newest first
+	ListSeeks(ctx context.Context, query SeekQuery) ([]*Seek, error)
+	PutSuspicion(ctx context.Context, suspicion *Suspicion) error
+	// ListSuspicions returns anti-cheat results, highest score first
+	ListSuspicions(ctx context.Context, query SuspicionQuery) ([]*Suspicion, error)
+	Close() error
+}
+
+// Indexer turns repository record operations into index updates
+type Indexer struct {
+	store    Store
+	finished []func(ctx context.Context, game *Game)
+	coverage coverage
+}
+
+// NewIndexer creates an indexer writing to store
+func NewIndexer(store Store) *Indexer {
+	return &Indexer{store: store}
+}
+
+// OnGameFinished registers fn to be called whenever a game record with a
+// final result is indexed. Game records are rewritten after they finish, so
+// fn may see the same game more than once.
+func (i *Indexer) OnGameFinished(fn func(ctx context.Context, game *Game)) {
+	i.finished = append(i.finished, fn)
+}
+
+// ListGames queries the index
+func (i *Indexer) ListGames(ctx context.Context, query Query) (*Page, error) {
+	return i.store.ListGames(ctx, query.normalize())
+}
+
+// ListMoves returns a game's moves in the order they were made
+func (i *Indexer) ListMoves(ctx context.Context, gameURI string) ([]*Move, error) {
+	return i.store.ListMoves(ctx, gameURI)
+}
+
+// GetPlayer returns a player's summary
+func (i *Indexer) GetPlayer(ctx context.Context, did string) (*Player, error) {
+	return i.store.GetPlayer(ctx, did)
+}
+
+// Apply indexes a create, update or delete of the record at repo/path.
+// Records in collections the index doesn't track are ignored.
+func (i *Indexer) Apply(ctx context.Context, action, repo, path string, record map[string]interface{}) error {
+	collection, rkey, ok := strings.Cut(path, "/")
+	if !ok {
+		return fmt.Errorf("invalid record path %q", path)
+	}
+	uri := fmt.Sprintf("at://%s/%s/%s", repo, collection, rkey)
+
+	switch collection {
+	case GameCollection:
+		if action == "delete" {
+			return i.store.DeleteGame(ctx, uri)
+		}
+		// Games imported from other sites weren't played here, so they
+		// stay out of listings, statistics and ratings
+		if imported, _ := record["imported"].(bool); imported {
+			log.Debug().Str("uri", uri).Msg("Not indexing imported game")
+			return nil
+		}
+		game, err := gameFromRecord(uri, record)
+		if err != nil {
+			return err
+		}
+		if err := i.store.PutGame(ctx, game); err != nil {
+			return err
+		}
+		if seekURI := seekReference(record); seekURI != "" {
+			if err := i.store.MatchSeek(ctx, seekURI, uri); err != nil {
+				return err
+			}
+		}
+		if game.finished() {
+			for _, fn := range i.finished {
+				fn(ctx, game)
+			}
+		}
+		return nil
+	case MoveCollection:
+		if action == "delete" {
+			return i.store.DeleteMove(ctx, uri)
+		}
+		move, err := moveFromRecord(uri, record)
+		if err != nil {
+			return err
+		}
+		return i.store.PutMove(ctx, move)
+	case SeekCollection:
+		return i.applySeek(ctx, action, uri, record)
+	}
+
+	log.Debug().Str("path", path).Msg("Not indexing record")
+	return nil
+}
+
+func gameFromRecord(uri string, record map[string]interface{}) (*Game, error) {
+	white, _ := record["white"].(string)
+	black, _ := record["black"].(string)
+	if white == "" || black == "" {
+		return nil, fmt.Errorf("game %s is missing players", uri)
+	}
+
+	game := &Game{
+		URI:       uri,
+		White:     white,
+		Black:     black,
+		CreatedAt: parseTime(record["createdAt"]),
+	}
+	game.Status, _ = record["status"].(string)
+	game.FEN, _ = record["fen"].(string)
+	if tc, ok := record["timeControl"].(map[string]interface{}); ok {
+		game.TimeControl, _ = tc["type"].(string)
+	}
+	game.Extensions = chess.ParseExtensions(record["extensions"])
+	return game, nil
+}
+
+func moveFromRecord(uri string, record map[string]interface{}) (*Move, error) {
+	move := &Move{
+		URI:       uri,
+		CreatedAt: parseTime(record["createdAt"]),
+	}
+	if ref, ok := record["game"].(map[string]interface{}); ok {
+		move.GameURI, _ = ref["uri"].(string)
+	}
+	if move.GameURI == "" {
+		return nil, fmt.Errorf("move %s is missing its game reference", uri)
+	}
+	move.Player, _ = record["player"].(string)
+	move.SAN, _ = record["san"].(string)
+	move.FEN, _ = record["fen"].(string)
+	return move, nil
+}
+
+func parseTime(value interface{}) time.Time {
+	s, _ := value.(string)
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+func (q Query) normalize() Query {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	return q
+}
+
+// cursor identifies the last game of a page by its sort key
+type cursor struct {
+	activity time.Time
+	uri      string
+}
+
+func encodeCursor(game *Game) string {
+	raw := strconv.FormatInt(game.lastActivity().UnixNano(), 10) + " " + game.URI
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	nanos, uri, ok := strings.Cut(string(raw), " ")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor{activity: time.Unix(0, n), uri: uri}, nil
+}
+
+// after reports whether game sorts after the cursor (newest activity first, then by URI)
+func (c *cursor) after(game *Game) bool {
+	activity := game.lastActivity()
+	if !activity.Equal(c.activity) {
+		return activity.Before(c.activity)
+	}
+	return game.URI > c.uri
+}
@@ -0,0 +1,364 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// forEachStore runs a test against every kind of store: in memory, and
+// SQLite in a temporary file
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		store, err := OpenSQLStore(context.Background(), "sqlite", filepath.Join(t.TempDir(), "index.db"))
+		if err != nil {
+			t.Fatalf("Failed to open SQLite store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		test(t, store)
+	})
+}
+
+func gameRecord(white, black, status, timeControl, createdAt string) map[string]interface{} {
+	return map[string]interface{}{
+		"$type":       GameCollection,
+		"white":       white,
+		"black":       black,
+		"status":      status,
+		"fen":         "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"createdAt":   createdAt,
+		"timeControl": map[string]interface{}{"type": timeControl},
+	}
+}
+
+func moveRecord(gameURI, player, createdAt string) map[string]interface{} {
+	return map[string]interface{}{
+		"$type":     MoveCollection,
+		"game":      map[string]interface{}{"uri": gameURI, "cid": "bafy"},
+		"player":    player,
+		"san":       "e4",
+		"fen":       "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+		"createdAt": createdAt,
+	}
+}
+
+func TestIndexerTracksGamesAndMoves(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		indexer := NewIndexer(store)
+
+		// A move can arrive before the game it belongs to
+		if err := indexer.Apply(ctx, "create", "did:plc:alice", "app.atchess.move/m1", moveRecord("at://did:plc:alice/app.atchess.game/g1", "did:plc:alice", "2024-01-01T10:05:00Z")); err != nil {
+			t.Fatalf("Failed to index move: %v", err)
+		}
+		if err := indexer.Apply(ctx, "create", "did:plc:alice", "app.atchess.game/g1", gameRecord("did:plc:alice", "did:plc:bob", "active", "blitz", "2024-01-01T10:00:00Z")); err != nil {
+			t.Fatalf("Failed to index game: %v", err)
+		}
+		if err := indexer.Apply(ctx, "create", "did:plc:bob", "app.atchess.move/m2", moveRecord("at://did:plc:alice/app.atchess.game/g1", "did:plc:bob", "2024-01-01T10:06:00Z")); err != nil {
+			t.Fatalf("Failed to index move: %v", err)
+		}
+		// Other collections are ignored
+		if err := indexer.Apply(ctx, "create", "did:plc:bob", "app.atchess.drawOffer/d1", map[string]interface{}{}); err != nil {
+			t.Fatalf("Expected unindexed collections to be ignored, got %v", err)
+		}
+
+		page, err := indexer.ListGames(ctx, Query{})
+		if err != nil {
+			t.Fatalf("ListGames failed: %v", err)
+		}
+		if len(page.Games) != 1 {
+			t.Fatalf("Expected 1 game, got %d", len(page.Games))
+		}
+		game := page.Games[0]
+		if game.MoveCount != 2 || game.LastMoveAt == nil || game.LastMoveAt.Format("15:04") != "10:06" || game.TimeControl != "blitz" {
+			t.Errorf("Unexpected indexed game: %+v", game)
+		}
+
+		if err := indexer.Apply(ctx, "delete", "did:plc:bob", "app.atchess.move/m2", nil); err != nil {
+			t.Fatalf("Failed to delete move: %v", err)
+		}
+		page, _ = indexer.ListGames(ctx, Query{})
+		if page.Games[0].MoveCount != 1 {
+			t.Errorf("Expected deleted move to be uncounted, got %d", page.Games[0].MoveCount)
+		}
+
+		player, _ := indexer.GetPlayer(ctx, "did:plc:bob")
+		if player.Games != 1 {
+			t.Errorf("Expected bob to have 1 game, got %+v", player)
+		}
+
+		if err := indexer.Apply(ctx, "delete", "did:plc:alice", "app.atchess.game/g1", nil); err != nil {
+			t.Fatalf("Failed to delete game: %v", err)
+		}
+		if page, _ := indexer.ListGames(ctx, Query{}); page.Total != 0 {
+			t.Errorf("Expected deleted game to be removed, got %d", page.Total)
+		}
+
+		if err := indexer.Apply(ctx, "create", "did:plc:alice", "app.atchess.game/g2", map[string]interface{}{"status": "active"}); err == nil {
+			t.Error("Expected a game without players to be rejected")
+		}
+	})
+}
+
+func TestListGamesFiltersAndPages(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		indexer := NewIndexer(store)
+
+		for i := 0; i < 7; i++ {
+			status, tc := "active", "correspondence"
+			if i%3 == 0 {
+				status = "white_won"
+			}
+			if i%2 == 0 {
+				tc = "rapid"
+			}
+			black := "did:plc:bob"
+			if i == 6 {
+				black = "did:plc:carol"
+			}
+			record := gameRecord("did:plc:alice", black, status, tc, fmt.Sprintf("2024-01-01T10:0%d:00Z", i))
+			if err := indexer.Apply(ctx, "create", "did:plc:alice", fmt.Sprintf("app.atchess.game/g%d", i), record); err != nil {
+				t.Fatalf("Failed to index game: %v", err)
+			}
+		}
+
+		tests := []struct {
+			name  string
+			query Query
+			total int
+		}{
+			{"all", Query{}, 7},
+			{"status", Query{Status: "active"}, 4},
+			{"time control", Query{TimeControl: "rapid"}, 4},
+			{"player", Query{Player: "did:plc:carol"}, 1},
+			{"opponent", Query{Player: "did:plc:alice", Opponent: "did:plc:bob"}, 6},
+			{"combined", Query{Status: "active", TimeControl: "correspondence"}, 2},
+		}
+		for _, tt := range tests {
+			page, err := indexer.ListGames(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("%s: ListGames failed: %v", tt.name, err)
+			}
+			if page.Total != tt.total || len(page.Games) != tt.total {
+				t.Errorf("%s: expected %d games, got total %d and %d on the page", tt.name, tt.total, page.Total, len(page.Games))
+			}
+		}
+
+		// Walk every page; each game appears once, newest first
+		var seen []string
+		query := Query{Limit: 3}
+		for pages := 0; ; pages++ {
+			if pages > 5 {
+				t.Fatal("Pagination did not terminate")
+			}
+			page, err := indexer.ListGames(ctx, query)
+			if err != nil {
+				t.Fatalf("ListGames failed: %v", err)
+			}
+			for _, game := range page.Games {
+				seen = append(seen, game.URI)
+			}
+			if page.Cursor == "" {
+				break
+			}
+			query.Cursor = page.Cursor
+		}
+		if len(seen) != 7 || seen[0] != "at://did:plc:alice/app.atchess.game/g6" || seen[6] != "at://did:plc:alice/app.atchess.game/g0" {
+			t.Errorf("Unexpected pagination order: %v", seen)
+		}
+
+		if _, err := indexer.ListGames(ctx, Query{Cursor: "not-a-cursor"}); err != ErrInvalidCursor {
+			t.Errorf("Expected ErrInvalidCursor, got %v", err)
+		}
+	})
+}
+
+func TestIndexerStoresGameExtensions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		indexer := NewIndexer(store)
+
+		tagged := gameRecord("did:plc:alice", "did:plc:bob", "active", "blitz", "2024-01-01T10:00:00Z")
+		tagged["extensions"] = map[string]interface{}{
+			"club.chess.tags":  map[string]interface{}{"tags": []interface{}{"league"}},
+			"app.atchess.fake": map[string]interface{}{"status": "draw"},
+		}
+		for path, record := range map[string]map[string]interface{}{
+			"app.atchess.game/g1": tagged,
+			"app.atchess.game/g2": gameRecord("did:plc:alice", "did:plc:carol", "active", "blitz", "2024-01-01T10:01:00Z"),
+		} {
+			if err := indexer.Apply(ctx, "create", "did:plc:alice", path, record); err != nil {
+				t.Fatalf("Failed to index game: %v", err)
+			}
+		}
+
+		page, err := indexer.ListGames(ctx, Query{Extension: "club.chess.tags"})
+		if err != nil {
+			t.Fatalf("ListGames failed: %v", err)
+		}
+		if page.Total != 1 || page.Games[0].URI != "at://did:plc:alice/app.atchess.game/g1" {
+			t.Fatalf("Expected only the tagged game, got %+v", page.Games)
+		}
+		extensions := page.Games[0].Extensions
+		if len(extensions) != 1 || extensions["club.chess.tags"] == nil {
+			t.Errorf("Expected the valid extension to be kept, got %v", extensions)
+		}
+
+		if page, _ := indexer.ListGames(ctx, Query{Extension: "app.atchess.fake"}); page.Total != 0 {
+			t.Errorf("Expected reserved namespaces not to be indexed, got %d games", page.Total)
+		}
+	})
+}
+
+func TestIndexerSkipsImportedGames(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		indexer := NewIndexer(store)
+		rated := 0
+		indexer.OnGameFinished(func(ctx context.Context, game *Game) { rated++ })
+
+		imported := gameRecord("did:plc:alice", "did:web:lichess.org", "white_won", "blitz", "2024-01-01T10:00:00Z")
+		imported["imported"] = true
+		if err := indexer.Apply(ctx, "create", "did:plc:alice", "app.atchess.game/g1", imported); err != nil {
+			t.Fatalf("Expected an imported game to be skipped, got %v", err)
+		}
+		if page, _ := indexer.ListGames(ctx, Query{}); page.Total != 0 || rated != 0 {
+			t.Errorf("Expected imported games left out, got %d games and %d finished", page.Total, rated)
+		}
+	})
+}
+
+func seekRecord(player, timeControl, createdAt string, minRating, maxRating int) map[string]interface{} {
+	record := map[string]interface{}{
+		"$type":       SeekCollection,
+		"player":      player,
+		"status":      "open",
+		"color":       "random",
+		"timeControl": map[string]interface{}{"type": timeControl, "initial": float64(300)},
+		"createdAt":   createdAt,
+		"expiresAt":   "2024-01-01T11:00:00Z",
+	}
+	if minRating > 0 || maxRating > 0 {
+		record["ratingRange"] = map[string]interface{}{"min": float64(minRating), "max": float64(maxRating)}
+	}
+	return record
+}
+
+func TestIndexerTracksSeeks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		indexer := NewIndexer(store)
+		now := parseTime("2024-01-01T10:30:00Z")
+
+		seeks := []struct {
+			path   string
+			record map[string]interface{}
+		}{
+			{"app.atchess.seek/s1", seekRecord("did:plc:alice", "blitz", "2024-01-01T10:00:00Z", 0, 0)},
+			{"app.atchess.seek/s2", seekRecord("did:plc:bob", "rapid", "2024-01-01T10:01:00Z", 1400, 1600)},
+			{"app.atchess.seek/s3", seekRecord("did:plc:carol", "blitz", "2024-01-01T10:02:00Z", 1800, 0)},
+		}
+		for _, seek := range seeks {
+			repo := seek.record["player"].(string)
+			if err := indexer.Apply(ctx, "create", repo, seek.path, seek.record); err != nil {
+				t.Fatalf("Failed to index seek: %v", err)
+			}
+		}
+
+		tests := []struct {
+			name  string
+			query SeekQuery
+			want  []string
+		}{
+			{"all, newest first", SeekQuery{Now: now}, []string{"did:plc:carol", "did:plc:bob", "did:plc:alice"}},
+			{"time control", SeekQuery{Now: now, TimeControl: "blitz"}, []string{"did:plc:carol", "did:plc:alice"}},
+			{"rating", SeekQuery{Now: now, Rating: 1500}, []string{"did:plc:bob", "did:plc:alice"}},
+			{"expired", SeekQuery{Now: parseTime("2024-01-01T11:00:00Z")}, nil},
+		}
+		for _, tt := range tests {
+			listed, err := indexer.ListSeeks(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("%s: ListSeeks failed: %v", tt.name, err)
+			}
+			var players []string
+			for _, seek := range listed {
+				players = append(players, seek.Player)
+			}
+			if fmt.Sprint(players) != fmt.Sprint(tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, players)
+			}
+		}
+
+		// A game created from a seek takes it out of the lobby
+		game := gameRecord("did:plc:dave", "did:plc:alice", "active", "blitz", "2024-01-01T10:10:00Z")
+		game["seek"] = map[string]interface{}{"uri": "at://did:plc:alice/app.atchess.seek/s1", "cid": "bafy"}
+		if err := indexer.Apply(ctx, "create", "did:plc:dave", "app.atchess.game/g1", game); err != nil {
+			t.Fatalf("Failed to index game: %v", err)
+		}
+		// Withdrawn and closed seeks are removed too
+		if err := indexer.Apply(ctx, "delete", "did:plc:bob", "app.atchess.seek/s2", nil); err != nil {
+			t.Fatalf("Failed to delete seek: %v", err)
+		}
+		closed := seekRecord("did:plc:carol", "blitz", "2024-01-01T10:02:00Z", 1800, 0)
+		closed["status"] = "matched"
+		if err := indexer.Apply(ctx, "update", "did:plc:carol", "app.atchess.seek/s3", closed); err != nil {
+			t.Fatalf("Failed to update seek: %v", err)
+		}
+		if listed, _ := indexer.ListSeeks(ctx, SeekQuery{Now: now}); len(listed) != 0 {
+			t.Errorf("Expected no open seeks, got %d", len(listed))
+		}
+	})
+}
+
+func TestIndexerStoresSuspicions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		indexer := NewIndexer(store)
+		analyzedAt := parseTime("2024-01-01T10:00:00Z")
+
+		for _, suspicion := range []*Suspicion{
+			{GameURI: "at://did:plc:alice/app.atchess.game/g1", Player: "did:plc:alice", Score: 40, AnalyzedAt: analyzedAt},
+			{GameURI: "at://did:plc:alice/app.atchess.game/g1", Player: "did:plc:bob", Score: 90, AnalyzedAt: analyzedAt},
+			{GameURI: "at://did:plc:bob/app.atchess.game/g2", Player: "did:plc:bob", Score: 80, AnalyzedAt: analyzedAt},
+			// A second analysis replaces the first
+			{GameURI: "at://did:plc:alice/app.atchess.game/g1", Player: "did:plc:alice", Score: 85, AnalyzedAt: analyzedAt.Add(time.Hour)},
+		} {
+			if err := indexer.PutSuspicion(ctx, suspicion); err != nil {
+				t.Fatalf("Failed to store suspicion: %v", err)
+			}
+		}
+
+		tests := []struct {
+			name  string
+			query SuspicionQuery
+			want  []int
+		}{
+			{"all, highest first", SuspicionQuery{}, []int{90, 85, 80}},
+			{"flagged", SuspicionQuery{MinScore: 85}, []int{90, 85}},
+			{"player", SuspicionQuery{Player: "did:plc:bob"}, []int{90, 80}},
+			{"game", SuspicionQuery{Game: "at://did:plc:bob/app.atchess.game/g2"}, []int{80}},
+			{"limit", SuspicionQuery{Limit: 1}, []int{90}},
+		}
+		for _, tt := range tests {
+			listed, err := indexer.ListSuspicions(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("%s: ListSuspicions failed: %v", tt.name, err)
+			}
+			var scores []int
+			for _, suspicion := range listed {
+				scores = append(scores, suspicion.Score)
+			}
+			if fmt.Sprint(scores) != fmt.Sprint(tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, scores)
+			}
+		}
+	})
+}
@@ -0,0 +1,317 @@
+package index
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/justinabrahms/atchess/internal/openings"
+)
+
+// MemoryStore is a Store held in memory. It's used when no database is
+// configured and is rebuilt from the firehose after a restart.
+type MemoryStore struct {
+	games      map[string]*Game
+	moves      map[string]*Move
+	seeks      map[string]*Seek
+	matched    map[string]string     // seek URI to the game created from it
+	suspicions map[string]*Suspicion // anti-cheat results by game URI and player
+	mu         sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		games:      make(map[string]*Game),
+		moves:      make(map[string]*Move),
+		seeks:      make(map[string]*Seek),
+		matched:    make(map[string]string),
+		suspicions: make(map[string]*Suspicion),
+	}
+}
+
+// PutGame inserts or replaces a game
+func (m *MemoryStore) PutGame(ctx context.Context, game *Game) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *game
+	m.games[game.URI] = &stored
+	m.refreshLocked(game.URI)
+	return nil
+}
+
+// DeleteGame removes a game; its moves are kept in case the game is re-created
+func (m *MemoryStore) DeleteGame(ctx context.Context, uri string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.games, uri)
+	return nil
+}
+
+// PutMove inserts or replaces a move
+func (m *MemoryStore) PutMove(ctx context.Context, move *Move) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *move
+	m.moves[move.URI] = &stored
+	m.refreshLocked(move.GameURI)
+	return nil
+}
+
+// DeleteMove removes a move
+func (m *MemoryStore) DeleteMove(ctx context.Context, uri string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	move, ok := m.moves[uri]
+	if !ok {
+		return nil
+	}
+	delete(m.moves, uri)
+	m.refreshLocked(move.GameURI)
+	return nil
+}
+
+// refreshLocked recomputes a game's move count, last move time and opening.
+// Moves can arrive before their game, so this runs on both.
+func (m *MemoryStore) refreshLocked(gameURI string) {
+	game, ok := m.games[gameURI]
+	if !ok {
+		return
+	}
+
+	game.MoveCount = 0
+	game.LastMoveAt = nil
+	var fens []string
+	for _, move := range m.moves {
+		if move.GameURI != gameURI {
+			continue
+		}
+		game.MoveCount++
+		if game.LastMoveAt == nil || move.CreatedAt.After(*game.LastMoveAt) {
+			createdAt := move.CreatedAt
+			game.LastMoveAt = &createdAt
+		}
+		fens = append(fens, move.FEN)
+	}
+
+	game.ECO, game.Opening = "", ""
+	if opening := openings.Classify(fens); opening != nil {
+		game.ECO, game.Opening = opening.ECO, opening.Name
+	}
+}
+
+// ListMoves returns a game's moves, oldest first
+func (m *MemoryStore) ListMoves(ctx context.Context, gameURI string) ([]*Move, error) {
+	m.mu.RLock()
+	var moves []*Move
+	for _, move := range m.moves {
+		if move.GameURI == gameURI {
+			copied := *move
+			moves = append(moves, &copied)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(moves, func(i, j int) bool {
+		if !moves[i].CreatedAt.Equal(moves[j].CreatedAt) {
+			return moves[i].CreatedAt.Before(moves[j].CreatedAt)
+		}
+		return moves[i].URI < moves[j].URI
+	})
+	return moves, nil
+}
+
+// ListGames returns games matching the query, most recently active first
+func (m *MemoryStore) ListGames(ctx context.Context, query Query) (*Page, error) {
+	after, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	var matched []*Game
+	for _, game := range m.games {
+		if matches(game, query) {
+			copied := *game
+			matched = append(matched, &copied)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		ai, aj := matched[i].lastActivity(), matched[j].lastActivity()
+		if !ai.Equal(aj) {
+			return ai.After(aj)
+		}
+		return matched[i].URI < matched[j].URI
+	})
+
+	page := &Page{Games: []*Game{}, Total: len(matched)}
+	for _, game := range matched {
+		if after != nil && !after.after(game) {
+			continue
+		}
+		if len(page.Games) == query.Limit {
+			page.Cursor = encodeCursor(page.Games[len(page.Games)-1])
+			break
+		}
+		page.Games = append(page.Games, game)
+	}
+	return page, nil
+}
+
+// GetPlayer summarises the games a DID has played
+func (m *MemoryStore) GetPlayer(ctx context.Context, did string) (*Player, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	player := &Player{DID: did}
+	for _, game := range m.games {
+		if game.White != did && game.Black != did {
+			continue
+		}
+		player.Games++
+		if activity := game.lastActivity(); activity.After(player.LastActive) {
+			player.LastActive = activity
+		}
+	}
+	return player, nil
+}
+
+// PutSeek inserts or replaces a seek. Seeks that had expired by the time the
+// new one was created are dropped, so the lobby doesn't grow without bound.
+func (m *MemoryStore) PutSeek(ctx context.Context, seek *Seek) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for uri, existing := range m.seeks {
+		if existing.ExpiresAt.Before(seek.CreatedAt) {
+			delete(m.seeks, uri)
+			delete(m.matched, uri)
+		}
+	}
+
+	stored := *seek
+	m.seeks[seek.URI] = &stored
+	return nil
+}
+
+// DeleteSeek removes a seek
+func (m *MemoryStore) DeleteSeek(ctx context.Context, uri string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.seeks, uri)
+	return nil
+}
+
+// MatchSeek records that a game was created from a seek. The game can arrive
+// before the seek, so the match is kept even if the seek isn't known yet.
+func (m *MemoryStore) MatchSeek(ctx context.Context, seekURI, gameURI string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.matched[seekURI] = gameURI
+	return nil
+}
+
+// ListSeeks returns open, unmatched seeks, newest first
+func (m *MemoryStore) ListSeeks(ctx context.Context, query SeekQuery) ([]*Seek, error) {
+	m.mu.RLock()
+	seeks := []*Seek{}
+	for uri, seek := range m.seeks {
+		if _, ok := m.matched[uri]; ok || !matchesSeek(seek, query) {
+			continue
+		}
+		copied := *seek
+		seeks = append(seeks, &copied)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(seeks, func(i, j int) bool {
+		if !seeks[i].CreatedAt.Equal(seeks[j].CreatedAt) {
+			return seeks[i].CreatedAt.After(seeks[j].CreatedAt)
+		}
+		return seeks[i].URI < seeks[j].URI
+	})
+	if len(seeks) > query.Limit {
+		seeks = seeks[:query.Limit]
+	}
+	return seeks, nil
+}
+
+// PutSuspicion inserts or replaces a player's anti-cheat result for a game
+func (m *MemoryStore) PutSuspicion(ctx context.Context, suspicion *Suspicion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *suspicion
+	m.suspicions[suspicion.GameURI+" "+suspicion.Player] = &stored
+	return nil
+}
+
+// ListSuspicions returns anti-cheat results, highest score first, then most
+// recently analysed
+func (m *MemoryStore) ListSuspicions(ctx context.Context, query SuspicionQuery) ([]*Suspicion, error) {
+	m.mu.RLock()
+	suspicions := []*Suspicion{}
+	for _, suspicion := range m.suspicions {
+		if matchesSuspicion(suspicion, query) {
+			copied := *suspicion
+			suspicions = append(suspicions, &copied)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(suspicions, func(i, j int) bool {
+		a, b := suspicions[i], suspicions[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.AnalyzedAt.Equal(b.AnalyzedAt) {
+			return a.AnalyzedAt.After(b.AnalyzedAt)
+		}
+		return a.GameURI+a.Player < b.GameURI+b.Player
+	})
+	if len(suspicions) > query.Limit {
+		suspicions = suspicions[:query.Limit]
+	}
+	return suspicions, nil
+}
+
+// Close is a no-op for the in-memory store
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+func matches(game *Game, query Query) bool {
+	if query.Player != "" && game.White != query.Player && game.Black != query.Player {
+		return false
+	}
+	if query.Opponent != "" && game.White != query.Opponent && game.Black != query.Opponent {
+		return false
+	}
+	if query.Status != "" && game.Status != query.Status {
+		return false
+	}
+	if query.Finished && !game.finished() {
+		return false
+	}
+	if query.TimeControl != "" && game.TimeControl != query.TimeControl {
+		return false
+	}
+	if _, ok := game.Extensions[query.Extension]; query.Extension != "" && !ok {
+		return false
+	}
+	if query.ECO != "" && !strings.HasPrefix(game.ECO, query.ECO) {
+		return false
+	}
+	return true
+}
+
+var _ Store = (*MemoryStore)(nil)
@@ -0,0 +1,230 @@
+package index
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+// Intervals of a player's activity series
+const (
+	IntervalDay  = "day"
+	IntervalWeek = "week" // weeks start on Monday
+)
+
+// playerStatsOpenings is how many of a player's openings PlayerStats lists
+const playerStatsOpenings = 5
+
+// Record counts the results of a player's finished games
+type Record struct {
+	Games  int `json:"games"`
+	Wins   int `json:"wins"`
+	Losses int `json:"losses"`
+	Draws  int `json:"draws"`
+	// WinRate is wins over games won, lost or drawn, from 0 to 1
+	WinRate float64 `json:"winRate"`
+}
+
+// add counts a game the player won (score 1), lost (-1) or drew (0), or an
+// abandoned game if decided is false
+func (r *Record) add(score int, decided bool) {
+	r.Games++
+	if !decided {
+		return
+	}
+	switch score {
+	case 1:
+		r.Wins++
+	case -1:
+		r.Losses++
+	default:
+		r.Draws++
+	}
+}
+
+func (r *Record) rate() {
+	if decided := r.Wins + r.Losses + r.Draws; decided > 0 {
+		r.WinRate = math.Round(float64(r.Wins)/float64(decided)*1000) / 1000
+	}
+}
+
+// OpeningRecord is a player's record in one opening
+type OpeningRecord struct {
+	ECO     string `json:"eco"`
+	Opening string `json:"opening"`
+	Record
+}
+
+// ActivityPeriod counts a player's games over one day or week
+type ActivityPeriod struct {
+	Start string `json:"start"` // YYYY-MM-DD
+	Record
+}
+
+// PlayerStats describe one player's games, for profile graphs
+type PlayerStats struct {
+	DID         string    `json:"did"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Finished games, including abandoned ones, which have no winner
+	Record
+	Abandoned   int    `json:"abandoned"`
+	ActiveGames int    `json:"activeGames"`
+	White       Record `json:"white"`
+	Black       Record `json:"black"`
+	// AverageGameLength is the mean number of full moves in finished games
+	AverageGameLength float64 `json:"averageGameLength"`
+	// FavoriteOpenings are the openings of the player's finished games, most
+	// played first
+	FavoriteOpenings []OpeningRecord `json:"favoriteOpenings"`
+	// Activity counts the games finished in each of the most recent days or
+	// weeks, oldest first, including periods without games
+	Interval string           `json:"interval"`
+	Activity []ActivityPeriod `json:"activity"`
+}
+
+// PeriodStart returns the start of the UTC day or week t falls in
+func PeriodStart(t time.Time, interval string) time.Time {
+	day := t.UTC().Truncate(24 * time.Hour)
+	if interval == IntervalWeek {
+		// Sunday is the last day of the week
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
+
+// PeriodLength returns how many days a period of interval spans
+func PeriodLength(interval string) int {
+	if interval == IntervalWeek {
+		return 7
+	}
+	return 1
+}
+
+// score returns +1 if did won a finished game, -1 if they lost, 0 for a draw
+// and ok false for abandoned games
+func (g *Game) score(did string) (score int, ok bool) {
+	switch g.Status {
+	case "white_won":
+		score = 1
+	case "black_won":
+		score = -1
+	case "draw":
+		return 0, true
+	default:
+		return 0, false
+	}
+	if did == g.Black {
+		score = -score
+	}
+	return score, true
+}
+
+// PlayerStats aggregates a player's indexed games as of now: their record
+// overall and by color, their favorite openings, the length of their games
+// and their results in each of the last periods days or weeks. Games against
+// themselves are left out.
+func (i *Indexer) PlayerStats(ctx context.Context, did string, now time.Time, interval string, periods int) (*PlayerStats, error) {
+	if interval != IntervalWeek {
+		interval = IntervalDay
+	}
+	if periods < 1 {
+		periods = 1
+	}
+	now = now.UTC()
+	stats := &PlayerStats{
+		DID:              did,
+		GeneratedAt:      now,
+		FavoriteOpenings: []OpeningRecord{},
+		Interval:         interval,
+		Activity:         make([]ActivityPeriod, periods),
+	}
+
+	length := PeriodLength(interval)
+	first := PeriodStart(now, interval).AddDate(0, 0, -(periods-1)*length)
+	for p := range stats.Activity {
+		stats.Activity[p].Start = first.AddDate(0, 0, p*length).Format("2006-01-02")
+	}
+
+	openings := make(map[string]*OpeningRecord)
+	totalMoves := 0
+
+	query := Query{Player: did, Limit: MaxLimit}
+	for {
+		page, err := i.ListGames(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, game := range page.Games {
+			if game.White == game.Black {
+				continue
+			}
+			if !game.finished() {
+				if game.Status == "active" {
+					stats.ActiveGames++
+				}
+				continue
+			}
+
+			score, decided := game.score(did)
+			if !decided {
+				stats.Abandoned++
+			}
+			stats.Record.add(score, decided)
+			totalMoves += game.MoveCount
+			if game.White == did {
+				stats.White.add(score, decided)
+			} else {
+				stats.Black.add(score, decided)
+			}
+
+			if game.ECO != "" {
+				opening, ok := openings[game.ECO+game.Opening]
+				if !ok {
+					opening = &OpeningRecord{ECO: game.ECO, Opening: game.Opening}
+					openings[game.ECO+game.Opening] = opening
+				}
+				opening.add(score, decided)
+			}
+
+			played := PeriodStart(game.lastActivity(), interval)
+			if !played.Before(first) && !played.After(now) {
+				stats.Activity[int(played.Sub(first)/(24*time.Hour))/length].add(score, decided)
+			}
+		}
+
+		if page.Cursor == "" {
+			break
+		}
+		query.Cursor = page.Cursor
+	}
+
+	stats.Record.rate()
+	stats.White.rate()
+	stats.Black.rate()
+	for p := range stats.Activity {
+		stats.Activity[p].rate()
+	}
+	for _, opening := range openings {
+		opening.rate()
+		stats.FavoriteOpenings = append(stats.FavoriteOpenings, *opening)
+	}
+	sort.Slice(stats.FavoriteOpenings, func(a, b int) bool {
+		x, y := stats.FavoriteOpenings[a], stats.FavoriteOpenings[b]
+		if x.Games != y.Games {
+			return x.Games > y.Games
+		}
+		return x.ECO+x.Opening < y.ECO+y.Opening
+	})
+	if len(stats.FavoriteOpenings) > playerStatsOpenings {
+		stats.FavoriteOpenings = stats.FavoriteOpenings[:playerStatsOpenings]
+	}
+
+	if stats.Games > 0 {
+		// Each full move is a ply by each side
+		average := float64(totalMoves) / 2 / float64(stats.Games)
+		stats.AverageGameLength = math.Round(average*10) / 10
+	}
+	return stats, nil
+}
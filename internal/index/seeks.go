@@ -0,0 +1,128 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SeekCollection holds open challenges published to the lobby
+const SeekCollection = "app.atchess.seek"
+
+// Seek is an indexed open challenge
+type Seek struct {
+	URI         string    `json:"uri"`
+	Player      string    `json:"player"`
+	Color       string    `json:"color"` // the seeker's color: white, black or random
+	TimeControl string    `json:"timeControl,omitempty"`
+	Initial     int       `json:"initial,omitempty"`     // seconds
+	Increment   int       `json:"increment,omitempty"`   // seconds per move
+	DaysPerMove int       `json:"daysPerMove,omitempty"` // correspondence only
+	MinRating   int       `json:"minRating,omitempty"`
+	MaxRating   int       `json:"maxRating,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// acceptsRating reports whether a player with the given rating may accept the
+// seek; a zero rating matches every seek
+func (s *Seek) acceptsRating(rating int) bool {
+	if rating == 0 {
+		return true
+	}
+	return (s.MinRating == 0 || rating >= s.MinRating) && (s.MaxRating == 0 || rating <= s.MaxRating)
+}
+
+// SeekQuery filters the lobby. Empty fields match everything.
+type SeekQuery struct {
+	TimeControl string
+	Rating      int       // only seeks a player with this rating may accept
+	Now         time.Time // seeks expiring before this are left out; defaults to the current time
+	Limit       int
+}
+
+// matchesSeek reports whether an open seek passes the query's filters
+func matchesSeek(seek *Seek, query SeekQuery) bool {
+	if !seek.ExpiresAt.After(query.Now) {
+		return false
+	}
+	if query.TimeControl != "" && seek.TimeControl != query.TimeControl {
+		return false
+	}
+	return seek.acceptsRating(query.Rating)
+}
+
+// ListSeeks returns open seeks that haven't been matched or expired, newest first
+func (i *Indexer) ListSeeks(ctx context.Context, query SeekQuery) ([]*Seek, error) {
+	if query.Now.IsZero() {
+		query.Now = time.Now()
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultLimit
+	}
+	if query.Limit > MaxLimit {
+		query.Limit = MaxLimit
+	}
+	return i.store.ListSeeks(ctx, query)
+}
+
+// applySeek indexes a seek record. Seeks that are no longer open are removed.
+func (i *Indexer) applySeek(ctx context.Context, action, uri string, record map[string]interface{}) error {
+	if status, _ := record["status"].(string); action == "delete" || status != "open" {
+		return i.store.DeleteSeek(ctx, uri)
+	}
+	seek, err := seekFromRecord(uri, record)
+	if err != nil {
+		return err
+	}
+	return i.store.PutSeek(ctx, seek)
+}
+
+func seekFromRecord(uri string, record map[string]interface{}) (*Seek, error) {
+	seek := &Seek{
+		URI:       uri,
+		CreatedAt: parseTime(record["createdAt"]),
+	}
+	seek.Player, _ = record["player"].(string)
+	if seek.Player == "" {
+		return nil, fmt.Errorf("seek %s is missing its player", uri)
+	}
+	seek.Color, _ = record["color"].(string)
+	if tc, ok := record["timeControl"].(map[string]interface{}); ok {
+		seek.TimeControl, _ = tc["type"].(string)
+		seek.Initial = intField(tc["initial"])
+		seek.Increment = intField(tc["increment"])
+		seek.DaysPerMove = intField(tc["daysPerMove"])
+	}
+	if ratingRange, ok := record["ratingRange"].(map[string]interface{}); ok {
+		seek.MinRating = intField(ratingRange["min"])
+		seek.MaxRating = intField(ratingRange["max"])
+	}
+	if expiresAt, ok := record["expiresAt"].(string); ok {
+		seek.ExpiresAt = parseTime(expiresAt)
+	} else {
+		seek.ExpiresAt = seek.CreatedAt.Add(time.Hour)
+	}
+	return seek, nil
+}
+
+// seekReference returns the URI of the seek a game record was created from
+func seekReference(record map[string]interface{}) string {
+	ref, _ := record["seek"].(map[string]interface{})
+	uri, _ := ref["uri"].(string)
+	return uri
+}
+
+// intField reads a number from a record, whether it was decoded from JSON
+// (float64) or CBOR (int64)
+func intField(value interface{}) int {
+	switch n := value.(type) {
+	case float64:
+		return int(n)
+	case int64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,616 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/openings"
+	"github.com/justinabrahms/atchess/internal/sqlstore"
+)
+
+// schema is portable between SQLite (3.24+) and Postgres. Times are stored as
+// Unix nanoseconds so ordering and cursors behave the same on both.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS games (
+		uri TEXT PRIMARY KEY,
+		white TEXT NOT NULL,
+		black TEXT NOT NULL,
+		status TEXT NOT NULL,
+		fen TEXT NOT NULL,
+		time_control TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		move_count INTEGER NOT NULL DEFAULT 0,
+		last_move_at BIGINT,
+		last_activity BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS games_white ON games (white)`,
+	`CREATE INDEX IF NOT EXISTS games_black ON games (black)`,
+	`CREATE INDEX IF NOT EXISTS games_activity ON games (last_activity DESC, uri)`,
+	`CREATE TABLE IF NOT EXISTS game_extensions (
+		game_uri TEXT NOT NULL,
+		namespace TEXT NOT NULL,
+		data TEXT NOT NULL,
+		PRIMARY KEY (game_uri, namespace)
+	)`,
+	`CREATE INDEX IF NOT EXISTS game_extensions_namespace ON game_extensions (namespace)`,
+	`CREATE TABLE IF NOT EXISTS game_openings (
+		game_uri TEXT PRIMARY KEY,
+		eco TEXT NOT NULL,
+		name TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS game_openings_eco ON game_openings (eco)`,
+	`CREATE TABLE IF NOT EXISTS moves (
+		uri TEXT PRIMARY KEY,
+		game_uri TEXT NOT NULL,
+		player TEXT NOT NULL,
+		san TEXT NOT NULL,
+		fen TEXT NOT NULL,
+		created_at BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS moves_game ON moves (game_uri)`,
+	`CREATE TABLE IF NOT EXISTS seeks (
+		uri TEXT PRIMARY KEY,
+		player TEXT NOT NULL,
+		color TEXT NOT NULL,
+		time_control TEXT NOT NULL,
+		initial INTEGER NOT NULL,
+		increment INTEGER NOT NULL,
+		days_per_move INTEGER NOT NULL,
+		min_rating INTEGER NOT NULL,
+		max_rating INTEGER NOT NULL,
+		created_at BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS seeks_expiry ON seeks (expires_at)`,
+	`CREATE TABLE IF NOT EXISTS seek_matches (
+		seek_uri TEXT PRIMARY KEY,
+		game_uri TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS suspicions (
+		game_uri TEXT NOT NULL,
+		player TEXT NOT NULL,
+		color TEXT NOT NULL,
+		score INTEGER NOT NULL,
+		moves INTEGER NOT NULL,
+		engine_match DOUBLE PRECISION NOT NULL,
+		average_loss INTEGER NOT NULL,
+		move_time_ms BIGINT NOT NULL,
+		move_time_variation DOUBLE PRECISION NOT NULL,
+		analyzed_at BIGINT NOT NULL,
+		PRIMARY KEY (game_uri, player)
+	)`,
+	`CREATE INDEX IF NOT EXISTS suspicions_score ON suspicions (score DESC, analyzed_at DESC)`,
+}
+
+// SQLStore is a Store backed by database/sql. The driver must be linked into
+// the binary, e.g. with a blank import of a SQLite or Postgres driver.
+type SQLStore struct {
+	db *sqlstore.DB
+}
+
+// OpenSQLStore connects to the database and creates the schema if needed
+func OpenSQLStore(ctx context.Context, driver, dsn string) (*SQLStore, error) {
+	db, err := sqlstore.Open(ctx, "index", driver, dsn, schema)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// PutGame inserts or replaces a game
+func (s *SQLStore) PutGame(ctx context.Context, game *Game) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, s.db.Rebind(`
+			INSERT INTO games (uri, white, black, status, fen, time_control, created_at, last_activity)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (uri) DO UPDATE SET
+				white = excluded.white,
+				black = excluded.black,
+				status = excluded.status,
+				fen = excluded.fen,
+				time_control = excluded.time_control,
+				created_at = excluded.created_at`),
+			game.URI, game.White, game.Black, game.Status, game.FEN, game.TimeControl,
+			game.CreatedAt.UnixNano(), game.CreatedAt.UnixNano())
+		if err != nil {
+			return fmt.Errorf("failed to store game: %w", err)
+		}
+		if err := s.putExtensions(ctx, tx, game); err != nil {
+			return err
+		}
+		return s.refresh(ctx, tx, game.URI)
+	})
+}
+
+// putExtensions replaces a game's extensions
+func (s *SQLStore) putExtensions(ctx context.Context, tx *sql.Tx, game *Game) error {
+	if _, err := tx.ExecContext(ctx, s.db.Rebind(`DELETE FROM game_extensions WHERE game_uri = ?`), game.URI); err != nil {
+		return fmt.Errorf("failed to store game extensions: %w", err)
+	}
+	for namespace, data := range game.Extensions {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("failed to encode game extension %s: %w", namespace, err)
+		}
+		_, err = tx.ExecContext(ctx, s.db.Rebind(`
+			INSERT INTO game_extensions (game_uri, namespace, data) VALUES (?, ?, ?)`),
+			game.URI, namespace, string(encoded))
+		if err != nil {
+			return fmt.Errorf("failed to store game extensions: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteGame removes a game; its moves are kept in case the game is re-created
+func (s *SQLStore) DeleteGame(ctx context.Context, uri string) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.db.Rebind(`DELETE FROM games WHERE uri = ?`), uri); err != nil {
+			return fmt.Errorf("failed to delete game: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, s.db.Rebind(`DELETE FROM game_extensions WHERE game_uri = ?`), uri); err != nil {
+			return fmt.Errorf("failed to delete game extensions: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, s.db.Rebind(`DELETE FROM game_openings WHERE game_uri = ?`), uri); err != nil {
+			return fmt.Errorf("failed to delete game opening: %w", err)
+		}
+		return nil
+	})
+}
+
+// PutMove inserts or replaces a move
+func (s *SQLStore) PutMove(ctx context.Context, move *Move) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, s.db.Rebind(`
+			INSERT INTO moves (uri, game_uri, player, san, fen, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (uri) DO UPDATE SET
+				game_uri = excluded.game_uri,
+				player = excluded.player,
+				san = excluded.san,
+				fen = excluded.fen,
+				created_at = excluded.created_at`),
+			move.URI, move.GameURI, move.Player, move.SAN, move.FEN, move.CreatedAt.UnixNano())
+		if err != nil {
+			return fmt.Errorf("failed to store move: %w", err)
+		}
+		return s.refresh(ctx, tx, move.GameURI)
+	})
+}
+
+// DeleteMove removes a move
+func (s *SQLStore) DeleteMove(ctx context.Context, uri string) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		var gameURI string
+		err := tx.QueryRowContext(ctx, s.db.Rebind(`SELECT game_uri FROM moves WHERE uri = ?`), uri).Scan(&gameURI)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up move: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, s.db.Rebind(`DELETE FROM moves WHERE uri = ?`), uri); err != nil {
+			return fmt.Errorf("failed to delete move: %w", err)
+		}
+		return s.refresh(ctx, tx, gameURI)
+	})
+}
+
+// ListMoves returns a game's moves, oldest first
+func (s *SQLStore) ListMoves(ctx context.Context, gameURI string) ([]*Move, error) {
+	rows, err := s.db.QueryContext(ctx, s.db.Rebind(`
+		SELECT uri, game_uri, player, san, fen, created_at
+		FROM moves WHERE game_uri = ?
+		ORDER BY created_at ASC, uri ASC`), gameURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list moves: %w", err)
+	}
+	defer rows.Close()
+
+	var moves []*Move
+	for rows.Next() {
+		var move Move
+		var createdAt int64
+		if err := rows.Scan(&move.URI, &move.GameURI, &move.Player, &move.SAN, &move.FEN, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to read move: %w", err)
+		}
+		move.CreatedAt = time.Unix(0, createdAt)
+		moves = append(moves, &move)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list moves: %w", err)
+	}
+	return moves, nil
+}
+
+// refresh recomputes a game's move count, activity and opening from its moves
+func (s *SQLStore) refresh(ctx context.Context, tx *sql.Tx, gameURI string) error {
+	_, err := tx.ExecContext(ctx, s.db.Rebind(`
+		UPDATE games SET
+			move_count = (SELECT COUNT(*) FROM moves WHERE game_uri = ?),
+			last_move_at = (SELECT MAX(created_at) FROM moves WHERE game_uri = ?),
+			last_activity = COALESCE((SELECT MAX(created_at) FROM moves WHERE game_uri = ?), created_at)
+		WHERE uri = ?`), gameURI, gameURI, gameURI, gameURI)
+	if err != nil {
+		return fmt.Errorf("failed to update game activity: %w", err)
+	}
+	return s.refreshOpening(ctx, tx, gameURI)
+}
+
+// refreshOpening classifies a game's opening from the positions its moves
+// reached
+func (s *SQLStore) refreshOpening(ctx context.Context, tx *sql.Tx, gameURI string) error {
+	rows, err := tx.QueryContext(ctx, s.db.Rebind(`SELECT fen FROM moves WHERE game_uri = ?`), gameURI)
+	if err != nil {
+		return fmt.Errorf("failed to load game positions: %w", err)
+	}
+	var fens []string
+	for rows.Next() {
+		var fen string
+		if err := rows.Scan(&fen); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read game position: %w", err)
+		}
+		fens = append(fens, fen)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load game positions: %w", err)
+	}
+
+	opening := openings.Classify(fens)
+	if opening == nil {
+		if _, err := tx.ExecContext(ctx, s.db.Rebind(`DELETE FROM game_openings WHERE game_uri = ?`), gameURI); err != nil {
+			return fmt.Errorf("failed to update game opening: %w", err)
+		}
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, s.db.Rebind(`
+		INSERT INTO game_openings (game_uri, eco, name) VALUES (?, ?, ?)
+		ON CONFLICT (game_uri) DO UPDATE SET eco = excluded.eco, name = excluded.name`),
+		gameURI, opening.ECO, opening.Name)
+	if err != nil {
+		return fmt.Errorf("failed to update game opening: %w", err)
+	}
+	return nil
+}
+
+// ListGames returns games matching the query, most recently active first
+func (s *SQLStore) ListGames(ctx context.Context, query Query) (*Page, error) {
+	after, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var where []string
+	var args []interface{}
+	if query.Player != "" {
+		where = append(where, "(white = ? OR black = ?)")
+		args = append(args, query.Player, query.Player)
+	}
+	if query.Opponent != "" {
+		where = append(where, "(white = ? OR black = ?)")
+		args = append(args, query.Opponent, query.Opponent)
+	}
+	if query.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, query.Status)
+	}
+	if query.Finished {
+		where = append(where, "status IN (?"+strings.Repeat(", ?", len(finishedStatuses)-1)+")")
+		for _, status := range finishedStatuses {
+			args = append(args, status)
+		}
+	}
+	if query.TimeControl != "" {
+		where = append(where, "time_control = ?")
+		args = append(args, query.TimeControl)
+	}
+	if query.Extension != "" {
+		where = append(where, "uri IN (SELECT game_uri FROM game_extensions WHERE namespace = ?)")
+		args = append(args, query.Extension)
+	}
+	if query.ECO != "" {
+		where = append(where, "uri IN (SELECT game_uri FROM game_openings WHERE eco LIKE ?)")
+		args = append(args, query.ECO+"%")
+	}
+	filter := ""
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	page := &Page{Games: []*Game{}}
+	if err := s.db.QueryRowContext(ctx, s.db.Rebind(`SELECT COUNT(*) FROM games`+filter), args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count games: %w", err)
+	}
+
+	if after != nil {
+		nanos := after.activity.UnixNano()
+		where = append(where, "(last_activity < ? OR (last_activity = ? AND uri > ?))")
+		args = append(args, nanos, nanos, after.uri)
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+	// Fetch one extra row to know whether there's another page
+	args = append(args, query.Limit+1)
+
+	rows, err := s.db.QueryContext(ctx, s.db.Rebind(`
+		SELECT uri, white, black, status, fen, time_control, created_at, move_count, last_move_at,
+			COALESCE(eco, ''), COALESCE(name, '')
+		FROM games LEFT JOIN game_openings ON game_openings.game_uri = games.uri`+filter+`
+		ORDER BY last_activity DESC, uri ASC
+		LIMIT ?`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list games: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var game Game
+		var createdAt int64
+		var lastMoveAt sql.NullInt64
+		if err := rows.Scan(&game.URI, &game.White, &game.Black, &game.Status, &game.FEN,
+			&game.TimeControl, &createdAt, &game.MoveCount, &lastMoveAt, &game.ECO, &game.Opening); err != nil {
+			return nil, fmt.Errorf("failed to read game: %w", err)
+		}
+		game.CreatedAt = time.Unix(0, createdAt)
+		if lastMoveAt.Valid {
+			t := time.Unix(0, lastMoveAt.Int64)
+			game.LastMoveAt = &t
+		}
+
+		if len(page.Games) == query.Limit {
+			page.Cursor = encodeCursor(page.Games[len(page.Games)-1])
+			break
+		}
+		page.Games = append(page.Games, &game)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list games: %w", err)
+	}
+	if err := s.loadExtensions(ctx, page.Games); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// loadExtensions fills in the extensions of a page of games
+func (s *SQLStore) loadExtensions(ctx context.Context, games []*Game) error {
+	if len(games) == 0 {
+		return nil
+	}
+	byURI := make(map[string]*Game, len(games))
+	args := make([]interface{}, 0, len(games))
+	for _, game := range games {
+		byURI[game.URI] = game
+		args = append(args, game.URI)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.db.Rebind(`
+		SELECT game_uri, namespace, data FROM game_extensions
+		WHERE game_uri IN (?`+strings.Repeat(", ?", len(games)-1)+`)`), args...)
+	if err != nil {
+		return fmt.Errorf("failed to load game extensions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var uri, namespace, data string
+		if err := rows.Scan(&uri, &namespace, &data); err != nil {
+			return fmt.Errorf("failed to read game extension: %w", err)
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &object); err != nil {
+			return fmt.Errorf("failed to decode game extension %s: %w", namespace, err)
+		}
+		game := byURI[uri]
+		if game.Extensions == nil {
+			game.Extensions = make(chess.Extensions)
+		}
+		game.Extensions[namespace] = object
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load game extensions: %w", err)
+	}
+	return nil
+}
+
+// GetPlayer summarises the games a DID has played
+func (s *SQLStore) GetPlayer(ctx context.Context, did string) (*Player, error) {
+	player := &Player{DID: did}
+	var lastActive sql.NullInt64
+	err := s.db.QueryRowContext(ctx, s.db.Rebind(`
+		SELECT COUNT(*), MAX(last_activity) FROM games WHERE white = ? OR black = ?`), did, did).
+		Scan(&player.Games, &lastActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up player: %w", err)
+	}
+	if lastActive.Valid {
+		player.LastActive = time.Unix(0, lastActive.Int64)
+	}
+	return player, nil
+}
+
+// PutSeek inserts or replaces a seek, and deletes seeks that had expired by
+// the time it was created
+func (s *SQLStore) PutSeek(ctx context.Context, seek *Seek) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.db.Rebind(`DELETE FROM seeks WHERE expires_at < ?`), seek.CreatedAt.UnixNano()); err != nil {
+			return fmt.Errorf("failed to prune seeks: %w", err)
+		}
+		_, err := tx.ExecContext(ctx, s.db.Rebind(`
+			INSERT INTO seeks (uri, player, color, time_control, initial, increment, days_per_move,
+				min_rating, max_rating, created_at, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (uri) DO UPDATE SET
+				player = excluded.player,
+				color = excluded.color,
+				time_control = excluded.time_control,
+				initial = excluded.initial,
+				increment = excluded.increment,
+				days_per_move = excluded.days_per_move,
+				min_rating = excluded.min_rating,
+				max_rating = excluded.max_rating,
+				created_at = excluded.created_at,
+				expires_at = excluded.expires_at`),
+			seek.URI, seek.Player, seek.Color, seek.TimeControl, seek.Initial, seek.Increment, seek.DaysPerMove,
+			seek.MinRating, seek.MaxRating, seek.CreatedAt.UnixNano(), seek.ExpiresAt.UnixNano())
+		if err != nil {
+			return fmt.Errorf("failed to store seek: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteSeek removes a seek
+func (s *SQLStore) DeleteSeek(ctx context.Context, uri string) error {
+	if _, err := s.db.ExecContext(ctx, s.db.Rebind(`DELETE FROM seeks WHERE uri = ?`), uri); err != nil {
+		return fmt.Errorf("failed to delete seek: %w", err)
+	}
+	return nil
+}
+
+// MatchSeek records that a game was created from a seek
+func (s *SQLStore) MatchSeek(ctx context.Context, seekURI, gameURI string) error {
+	_, err := s.db.ExecContext(ctx, s.db.Rebind(`
+		INSERT INTO seek_matches (seek_uri, game_uri) VALUES (?, ?)
+		ON CONFLICT (seek_uri) DO UPDATE SET game_uri = excluded.game_uri`), seekURI, gameURI)
+	if err != nil {
+		return fmt.Errorf("failed to match seek: %w", err)
+	}
+	return nil
+}
+
+// ListSeeks returns open, unmatched seeks, newest first
+func (s *SQLStore) ListSeeks(ctx context.Context, query SeekQuery) ([]*Seek, error) {
+	where := []string{"expires_at > ?", "uri NOT IN (SELECT seek_uri FROM seek_matches)"}
+	args := []interface{}{query.Now.UnixNano()}
+	if query.TimeControl != "" {
+		where = append(where, "time_control = ?")
+		args = append(args, query.TimeControl)
+	}
+	if query.Rating != 0 {
+		where = append(where, "(min_rating = 0 OR min_rating <= ?)", "(max_rating = 0 OR max_rating >= ?)")
+		args = append(args, query.Rating, query.Rating)
+	}
+	args = append(args, query.Limit)
+
+	rows, err := s.db.QueryContext(ctx, s.db.Rebind(`
+		SELECT uri, player, color, time_control, initial, increment, days_per_move,
+			min_rating, max_rating, created_at, expires_at
+		FROM seeks WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY created_at DESC, uri ASC
+		LIMIT ?`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seeks: %w", err)
+	}
+	defer rows.Close()
+
+	seeks := []*Seek{}
+	for rows.Next() {
+		var seek Seek
+		var createdAt, expiresAt int64
+		if err := rows.Scan(&seek.URI, &seek.Player, &seek.Color, &seek.TimeControl, &seek.Initial,
+			&seek.Increment, &seek.DaysPerMove, &seek.MinRating, &seek.MaxRating, &createdAt, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to read seek: %w", err)
+		}
+		seek.CreatedAt = time.Unix(0, createdAt)
+		seek.ExpiresAt = time.Unix(0, expiresAt)
+		seeks = append(seeks, &seek)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list seeks: %w", err)
+	}
+	return seeks, nil
+}
+
+// PutSuspicion inserts or replaces a player's anti-cheat result for a game
+func (s *SQLStore) PutSuspicion(ctx context.Context, suspicion *Suspicion) error {
+	_, err := s.db.ExecContext(ctx, s.db.Rebind(`
+		INSERT INTO suspicions (game_uri, player, color, score, moves, engine_match, average_loss,
+			move_time_ms, move_time_variation, analyzed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (game_uri, player) DO UPDATE SET
+			color = excluded.color,
+			score = excluded.score,
+			moves = excluded.moves,
+			engine_match = excluded.engine_match,
+			average_loss = excluded.average_loss,
+			move_time_ms = excluded.move_time_ms,
+			move_time_variation = excluded.move_time_variation,
+			analyzed_at = excluded.analyzed_at`),
+		suspicion.GameURI, suspicion.Player, suspicion.Color, suspicion.Score, suspicion.Moves,
+		suspicion.EngineMatch, suspicion.AverageLoss, suspicion.MoveTimeMs, suspicion.MoveTimeVariation,
+		suspicion.AnalyzedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to store suspicion: %w", err)
+	}
+	return nil
+}
+
+// ListSuspicions returns anti-cheat results, highest score first, then most
+// recently analysed
+func (s *SQLStore) ListSuspicions(ctx context.Context, query SuspicionQuery) ([]*Suspicion, error) {
+	where := []string{"score >= ?"}
+	args := []interface{}{query.MinScore}
+	if query.Game != "" {
+		where = append(where, "game_uri = ?")
+		args = append(args, query.Game)
+	}
+	if query.Player != "" {
+		where = append(where, "player = ?")
+		args = append(args, query.Player)
+	}
+	args = append(args, query.Limit)
+
+	rows, err := s.db.QueryContext(ctx, s.db.Rebind(`
+		SELECT game_uri, player, color, score, moves, engine_match, average_loss,
+			move_time_ms, move_time_variation, analyzed_at
+		FROM suspicions WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY score DESC, analyzed_at DESC, game_uri ASC, player ASC
+		LIMIT ?`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list suspicions: %w", err)
+	}
+	defer rows.Close()
+
+	suspicions := []*Suspicion{}
+	for rows.Next() {
+		var suspicion Suspicion
+		var analyzedAt int64
+		if err := rows.Scan(&suspicion.GameURI, &suspicion.Player, &suspicion.Color, &suspicion.Score,
+			&suspicion.Moves, &suspicion.EngineMatch, &suspicion.AverageLoss, &suspicion.MoveTimeMs,
+			&suspicion.MoveTimeVariation, &analyzedAt); err != nil {
+			return nil, fmt.Errorf("failed to read suspicion: %w", err)
+		}
+		suspicion.AnalyzedAt = time.Unix(0, analyzedAt)
+		suspicions = append(suspicions, &suspicion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list suspicions: %w", err)
+	}
+	return suspicions, nil
+}
+
+// Close closes the database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+var _ Store = (*SQLStore)(nil)
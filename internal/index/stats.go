@@ -0,0 +1,140 @@
+package index
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// DailyGames counts the games created on one UTC day
+type DailyGames struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Games int    `json:"games"`
+}
+
+// Coverage is the span of firehose commits the index has applied since the
+// server started. Games created before it are only indexed once they change.
+type Coverage struct {
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+}
+
+// Stats are aggregate, anonymous metrics about the games on an instance
+type Stats struct {
+	GeneratedAt   time.Time `json:"generatedAt"`
+	TotalGames    int       `json:"totalGames"`
+	ActiveGames   int       `json:"activeGames"`
+	FinishedGames int       `json:"finishedGames"`
+	// GamesPerDay covers the most recent days, oldest first, including days
+	// without games
+	GamesPerDay []DailyGames `json:"gamesPerDay"`
+	// ActivePlayers counts the players with a game created or moved in during
+	// ActiveWindow
+	ActivePlayers int    `json:"activePlayers"`
+	ActiveWindow  string `json:"activeWindow"`
+	// AverageGameLength is the mean number of full moves in finished games
+	AverageGameLength float64  `json:"averageGameLength"`
+	FirehoseCoverage  Coverage `json:"firehoseCoverage"`
+}
+
+// coverage tracks the commit times of firehose events the index has seen
+type coverage struct {
+	mu       sync.Mutex
+	from, to time.Time
+}
+
+func (c *coverage) observe(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.from.IsZero() || t.Before(c.from) {
+		c.from = t
+	}
+	if t.After(c.to) {
+		c.to = t
+	}
+}
+
+func (c *coverage) window() Coverage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.from.IsZero() {
+		return Coverage{}
+	}
+	from, to := c.from, c.to
+	return Coverage{From: &from, To: &to}
+}
+
+// Observe records the commit time of a firehose event, extending the
+// coverage window reported by Stats
+func (i *Indexer) Observe(commitTime time.Time) {
+	if !commitTime.IsZero() {
+		i.coverage.observe(commitTime.UTC())
+	}
+}
+
+// Stats aggregates every indexed game as of now: games created on each of
+// the last days days, players active within activeWindow and the length of
+// finished games. Only counts are returned, never players or games.
+func (i *Indexer) Stats(ctx context.Context, now time.Time, days int, activeWindow time.Duration) (*Stats, error) {
+	if days < 1 {
+		days = 1
+	}
+	now = now.UTC()
+	stats := &Stats{
+		GeneratedAt:      now,
+		GamesPerDay:      make([]DailyGames, days),
+		ActiveWindow:     activeWindow.String(),
+		FirehoseCoverage: i.coverage.window(),
+	}
+
+	today := now.Truncate(24 * time.Hour)
+	first := today.AddDate(0, 0, -(days - 1))
+	for d := range stats.GamesPerDay {
+		stats.GamesPerDay[d].Date = first.AddDate(0, 0, d).Format("2006-01-02")
+	}
+
+	activeSince := now.Add(-activeWindow)
+	active := make(map[string]bool)
+	totalMoves := 0
+
+	query := Query{Limit: MaxLimit}
+	for {
+		page, err := i.ListGames(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, game := range page.Games {
+			stats.TotalGames++
+			if game.finished() {
+				stats.FinishedGames++
+				totalMoves += game.MoveCount
+			} else if game.Status == "active" {
+				stats.ActiveGames++
+			}
+
+			created := game.CreatedAt.UTC()
+			if !created.Before(first) && !created.After(now) {
+				stats.GamesPerDay[int(created.Sub(first)/(24*time.Hour))].Games++
+			}
+			if game.lastActivity().After(activeSince) {
+				active[game.White] = true
+				active[game.Black] = true
+			}
+		}
+
+		if page.Cursor == "" {
+			break
+		}
+		query.Cursor = page.Cursor
+	}
+
+	stats.ActivePlayers = len(active)
+	if stats.FinishedGames > 0 {
+		// Each full move is a ply by each side
+		average := float64(totalMoves) / 2 / float64(stats.FinishedGames)
+		stats.AverageGameLength = math.Round(average*10) / 10
+	}
+	return stats, nil
+}
@@ -0,0 +1,160 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStatsAggregatesGames(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		indexer := NewIndexer(store)
+		now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+		games := []struct {
+			rkey, white, black, status string
+			created                    time.Time
+			plies                      int
+		}{
+			{"g1", "did:plc:alice", "did:plc:bob", "white_won", now.Add(-2 * time.Hour), 40},
+			{"g2", "did:plc:carol", "did:plc:alice", "draw", now.AddDate(0, 0, -1), 20},
+			{"g3", "did:plc:dave", "did:plc:erin", "active", now.AddDate(0, 0, -1), 0},
+			{"g4", "did:plc:old", "did:plc:timer", "black_won", now.AddDate(0, 0, -20), 10},
+		}
+		for _, g := range games {
+			uri := "at://" + g.white + "/app.atchess.game/" + g.rkey
+			created := g.created.Format(time.RFC3339)
+			if err := indexer.Apply(ctx, "create", g.white, "app.atchess.game/"+g.rkey, gameRecord(g.white, g.black, g.status, "blitz", created)); err != nil {
+				t.Fatalf("Failed to index game: %v", err)
+			}
+			for ply := 0; ply < g.plies; ply++ {
+				if err := indexer.Apply(ctx, "create", g.white, fmt.Sprintf("app.atchess.move/%s-%d", g.rkey, ply), moveRecord(uri, g.white, created)); err != nil {
+					t.Fatalf("Failed to index move: %v", err)
+				}
+			}
+		}
+
+		stats, err := indexer.Stats(ctx, now, 7, 7*24*time.Hour)
+		if err != nil {
+			t.Fatalf("Stats failed: %v", err)
+		}
+
+		if stats.TotalGames != 4 || stats.FinishedGames != 3 || stats.ActiveGames != 1 {
+			t.Errorf("Unexpected game counts: %+v", stats)
+		}
+		if len(stats.GamesPerDay) != 7 || stats.GamesPerDay[0].Date != "2024-03-04" || stats.GamesPerDay[6].Date != "2024-03-10" {
+			t.Fatalf("Expected the last 7 days oldest first, got %+v", stats.GamesPerDay)
+		}
+		if stats.GamesPerDay[6].Games != 1 || stats.GamesPerDay[5].Games != 2 || stats.GamesPerDay[0].Games != 0 {
+			t.Errorf("Unexpected games per day: %+v", stats.GamesPerDay)
+		}
+		// alice, bob, carol, dave and erin played this week; the 20-day-old game doesn't count
+		if stats.ActivePlayers != 5 {
+			t.Errorf("Expected 5 active players, got %d", stats.ActivePlayers)
+		}
+		// (40 + 20 + 10) plies over 3 finished games
+		if stats.AverageGameLength != 11.7 {
+			t.Errorf("Expected an average of 11.7 moves, got %v", stats.AverageGameLength)
+		}
+		if stats.FirehoseCoverage.From != nil {
+			t.Errorf("Expected no coverage before any firehose event, got %+v", stats.FirehoseCoverage)
+		}
+	})
+}
+
+func TestObserveExtendsCoverage(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		indexer := NewIndexer(store)
+		start := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+		indexer.Observe(start.Add(time.Minute))
+		indexer.Observe(start)
+		indexer.Observe(time.Time{})
+		indexer.Observe(start.Add(time.Hour))
+
+		stats, err := indexer.Stats(context.Background(), start, 1, time.Hour)
+		if err != nil {
+			t.Fatalf("Stats failed: %v", err)
+		}
+		coverage := stats.FirehoseCoverage
+		if coverage.From == nil || !coverage.From.Equal(start) || !coverage.To.Equal(start.Add(time.Hour)) {
+			t.Errorf("Expected coverage from the earliest to the latest event, got %+v", coverage)
+		}
+	})
+}
+
+func TestPlayerStatsAggregatesOnePlayersGames(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		indexer := NewIndexer(store)
+		// A Sunday, so the current week started on the 4th
+		now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+		games := []struct {
+			rkey, white, black, status string
+			created                    time.Time
+			plies                      int
+		}{
+			{"g1", "did:plc:alice", "did:plc:bob", "white_won", now.Add(-2 * time.Hour), 40},
+			{"g2", "did:plc:carol", "did:plc:alice", "white_won", now.AddDate(0, 0, -1), 20},
+			{"g3", "did:plc:alice", "did:plc:dave", "draw", now.AddDate(0, 0, -7), 0},
+			{"g4", "did:plc:erin", "did:plc:alice", "abandoned", now.AddDate(0, 0, -30), 0},
+			{"g5", "did:plc:alice", "did:plc:erin", "active", now, 0},
+			{"g6", "did:plc:alice", "did:plc:alice", "white_won", now, 0},
+			{"g7", "did:plc:bob", "did:plc:carol", "black_won", now, 0},
+		}
+		for _, g := range games {
+			uri := "at://" + g.white + "/app.atchess.game/" + g.rkey
+			created := g.created.Format(time.RFC3339)
+			if err := indexer.Apply(ctx, "create", g.white, "app.atchess.game/"+g.rkey, gameRecord(g.white, g.black, g.status, "blitz", created)); err != nil {
+				t.Fatalf("Failed to index game: %v", err)
+			}
+			for ply := 0; ply < g.plies; ply++ {
+				if err := indexer.Apply(ctx, "create", g.white, fmt.Sprintf("app.atchess.move/%s-%d", g.rkey, ply), moveRecord(uri, g.white, created)); err != nil {
+					t.Fatalf("Failed to index move: %v", err)
+				}
+			}
+		}
+
+		stats, err := indexer.PlayerStats(ctx, "did:plc:alice", now, IntervalWeek, 4)
+		if err != nil {
+			t.Fatalf("PlayerStats failed: %v", err)
+		}
+
+		// The game against herself and other players' games don't count
+		if stats.Games != 4 || stats.Wins != 1 || stats.Losses != 1 || stats.Draws != 1 || stats.Abandoned != 1 || stats.ActiveGames != 1 {
+			t.Errorf("Unexpected record: %+v", stats.Record)
+		}
+		if stats.WinRate != 0.333 {
+			t.Errorf("Expected a win rate of 0.333 leaving out the abandoned game, got %v", stats.WinRate)
+		}
+		if stats.White.Games != 2 || stats.White.Wins != 1 || stats.White.WinRate != 0.5 || stats.Black.Games != 2 || stats.Black.Losses != 1 || stats.Black.WinRate != 0 {
+			t.Errorf("Unexpected records by color: white %+v, black %+v", stats.White, stats.Black)
+		}
+		// (40 + 20) plies over 4 finished games
+		if stats.AverageGameLength != 7.5 {
+			t.Errorf("Expected an average of 7.5 moves, got %v", stats.AverageGameLength)
+		}
+		if len(stats.FavoriteOpenings) != 1 || stats.FavoriteOpenings[0].ECO == "" || stats.FavoriteOpenings[0].Games != 2 {
+			t.Errorf("Expected both games that reached an opening under one entry, got %+v", stats.FavoriteOpenings)
+		}
+
+		if len(stats.Activity) != 4 || stats.Activity[0].Start != "2024-02-12" || stats.Activity[3].Start != "2024-03-04" {
+			t.Fatalf("Expected the last 4 weeks oldest first, got %+v", stats.Activity)
+		}
+		// The abandoned game was more than 4 weeks ago
+		if stats.Activity[3].Games != 2 || stats.Activity[3].WinRate != 0.5 || stats.Activity[2].Draws != 1 || stats.Activity[0].Games != 0 {
+			t.Errorf("Unexpected weekly activity: %+v", stats.Activity)
+		}
+
+		daily, err := indexer.PlayerStats(ctx, "did:plc:alice", now, IntervalDay, 7)
+		if err != nil {
+			t.Fatalf("PlayerStats failed: %v", err)
+		}
+		if daily.Activity[0].Start != "2024-03-04" || daily.Activity[6].Games != 1 || daily.Activity[5].Losses != 1 || daily.Activity[0].Games != 0 {
+			t.Errorf("Unexpected daily activity: %+v", daily.Activity)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package index
+
+import (
+	"context"
+	"time"
+)
+
+// Suspicion is the anti-cheat analysis of one player's moves in a rated
+// game: how often they played the engine's first choice, how much they lost
+// when they didn't, and how evenly they spent their time
+type Suspicion struct {
+	GameURI           string    `json:"gameUri"`
+	Player            string    `json:"player"`
+	Color             string    `json:"color"`                       // "white" or "black"
+	Score             int       `json:"score"`                       // 0 to 100, higher is more engine-like
+	Moves             int       `json:"moves"`                       // moves considered, leaving out the opening and decided positions
+	EngineMatch       float64   `json:"engineMatch"`                 // share of those moves that were the engine's first choice
+	AverageLoss       int       `json:"averageCentipawnLoss"`        // over those moves
+	MoveTimeMs        int64     `json:"moveTimeMs,omitempty"`        // average time they took, in games with a clock
+	MoveTimeVariation float64   `json:"moveTimeVariation,omitempty"` // standard deviation of that time over its average
+	AnalyzedAt        time.Time `json:"analyzedAt"`
+}
+
+// SuspicionQuery filters anti-cheat results. Empty fields match everything.
+type SuspicionQuery struct {
+	Game     string
+	Player   string
+	MinScore int
+	Limit    int
+}
+
+// matchesSuspicion reports whether a result passes the query's filters
+func matchesSuspicion(suspicion *Suspicion, query SuspicionQuery) bool {
+	if query.Game != "" && suspicion.GameURI != query.Game {
+		return false
+	}
+	if query.Player != "" && suspicion.Player != query.Player {
+		return false
+	}
+	return suspicion.Score >= query.MinScore
+}
+
+// PutSuspicion stores a player's anti-cheat result for a game, replacing any
+// earlier one
+func (i *Indexer) PutSuspicion(ctx context.Context, suspicion *Suspicion) error {
+	return i.store.PutSuspicion(ctx, suspicion)
+}
+
+// ListSuspicions returns anti-cheat results, highest score first
+func (i *Indexer) ListSuspicions(ctx context.Context, query SuspicionQuery) ([]*Suspicion, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultLimit
+	}
+	if query.Limit > MaxLimit {
+		query.Limit = MaxLimit
+	}
+	return i.store.ListSuspicions(ctx, query)
+}
@@ -0,0 +1,147 @@
+// Package lexicon describes the app.atchess records as Go types and checks
+// records against their lexicon schemas, so malformed records are caught
+// before they're written to a PDS and when they arrive from other
+// repositories over the firehose.
+package lexicon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/justinabrahms/atchess/lexicons"
+)
+
+// NSIDs of the records ATChess writes and reads
+const (
+	GameNSID                  = "app.atchess.game"
+	MoveNSID                  = "app.atchess.move"
+	ChallengeNSID             = "app.atchess.challenge"
+	DrawOfferNSID             = "app.atchess.drawOffer"
+	RematchOfferNSID          = "app.atchess.rematchOffer"
+	ResignationNSID           = "app.atchess.resignation"
+	TimeViolationNSID         = "app.atchess.timeViolation"
+	ChallengeNotificationNSID = "app.atchess.challengeNotification"
+	PreferencesNSID           = "app.atchess.preferences"
+	SettingsNSID              = "app.atchess.settings"
+	ReportNSID                = "app.atchess.report"
+	StudyNSID                 = "app.atchess.study"
+	ConditionalMoveNSID       = "app.atchess.conditionalMove"
+	AchievementNSID           = "app.atchess.achievement"
+	AnnouncementNSID          = "app.atchess.announcement"
+)
+
+// ErrInvalidRecord is wrapped by every validation failure
+var ErrInvalidRecord = errors.New("invalid record")
+
+// ValidationError describes the first field of a record that doesn't match
+// its lexicon
+type ValidationError struct {
+	Collection string
+	Path       string // dotted path of the field, e.g. "game.uri"
+	Message    string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("invalid %s record: %s", e.Collection, e.Message)
+	}
+	return fmt.Sprintf("invalid %s record: %s: %s", e.Collection, e.Path, e.Message)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidRecord
+}
+
+// document is a lexicon file, of which only the main record definition is
+// used
+type document struct {
+	ID   string `json:"id"`
+	Defs struct {
+		Main struct {
+			Type   string  `json:"type"`
+			Record *schema `json:"record"`
+		} `json:"main"`
+	} `json:"defs"`
+}
+
+// schemas holds the record schema of every embedded lexicon, by NSID
+var schemas = mustLoad(lexicons.FS)
+
+func mustLoad(fsys fs.FS) map[string]*schema {
+	loaded, err := load(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return loaded
+}
+
+func load(fsys fs.FS) (map[string]*schema, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[string]*schema, len(files))
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var doc document
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse lexicon %s: %w", file, err)
+		}
+		if doc.ID+".json" != file {
+			return nil, fmt.Errorf("lexicon %s has id %q", file, doc.ID)
+		}
+		if doc.Defs.Main.Type == "record" && doc.Defs.Main.Record != nil {
+			loaded[doc.ID] = doc.Defs.Main.Record
+		}
+	}
+	return loaded, nil
+}
+
+// Known reports whether collection has a lexicon to validate against
+func Known(collection string) bool {
+	_, ok := schemas[collection]
+	return ok
+}
+
+// Validate checks a record against the lexicon of its collection. The record
+// may be one of this package's types or a map decoded from JSON or CBOR.
+// Fields the lexicon doesn't define are allowed, as lexicons are open to
+// extension. Collections without a lexicon here, such as app.bsky.feed.post,
+// aren't checked.
+func Validate(collection string, record interface{}) error {
+	s, ok := schemas[collection]
+	if !ok {
+		return nil
+	}
+
+	// Normalize to JSON values, so numbers are float64 whatever they were
+	// decoded or built as
+	data, err := json.Marshal(record)
+	if err != nil {
+		return &ValidationError{Collection: collection, Message: err.Error()}
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return &ValidationError{Collection: collection, Message: err.Error()}
+	}
+
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return &ValidationError{Collection: collection, Message: "must be an object"}
+	}
+	if recordType, ok := fields["$type"]; ok && recordType != collection {
+		return &ValidationError{Collection: collection, Path: "$type", Message: fmt.Sprintf("must be %q", collection)}
+	}
+
+	if path, message := s.check(nil, value); message != "" {
+		return &ValidationError{Collection: collection, Path: strings.Join(path, "."), Message: message}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package lexicon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func validMove() *Move {
+	return &Move{
+		Type:      MoveNSID,
+		CreatedAt: "2024-01-01T00:00:00Z",
+		Game:      StrongRef{URI: "at://did:plc:white/app.atchess.game/g1", CID: "game-cid"},
+		Player:    "did:plc:white",
+		From:      "e2",
+		To:        "e4",
+		SAN:       "e4",
+		FEN:       "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+	}
+}
+
+func TestEmbeddedLexiconsLoad(t *testing.T) {
+	for _, nsid := range []string{GameNSID, MoveNSID, ChallengeNSID, DrawOfferNSID, RematchOfferNSID, ResignationNSID, TimeViolationNSID, ChallengeNotificationNSID, PreferencesNSID, SettingsNSID, ReportNSID, StudyNSID, ConditionalMoveNSID, AchievementNSID, AnnouncementNSID} {
+		if !Known(nsid) {
+			t.Errorf("Expected a lexicon for %s", nsid)
+		}
+	}
+	if Known("app.bsky.feed.post") {
+		t.Error("Expected no lexicon for app.bsky.feed.post")
+	}
+}
+
+func TestValidRecords(t *testing.T) {
+	records := map[string]interface{ Validate() error }{
+		"move": validMove(),
+		"game": &Game{
+			CreatedAt:   "2024-01-01T00:00:00Z",
+			White:       "did:plc:white",
+			Black:       "did:plc:black",
+			Status:      "active",
+			FEN:         "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+			TimeControl: &TimeControl{Type: "correspondence", DaysPerMove: 3},
+			Bot:         &Bot{Player: "did:plc:black", Level: 3},
+			Extensions:  map[string]map[string]interface{}{"com.example.overlay": {"layout": "compact"}},
+		},
+		"imported game": &Game{
+			CreatedAt: "2024-01-01T00:00:00Z",
+			White:     "did:plc:white",
+			Black:     "did:web:lichess.org",
+			Status:    "white_won",
+			FEN:       "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1",
+			Result:    "1-0",
+			Imported:  true,
+			Source:    &GameSource{Site: "lichess", ID: "abcd1234", URL: "https://lichess.org/abcd1234", White: "alice", Black: "bob"},
+		},
+		"challenge": &Challenge{
+			CreatedAt:  "2024-01-01T00:00:00Z",
+			Challenger: "did:plc:white",
+			Challenged: "did:plc:black",
+			Status:     "pending",
+			Color:      "random",
+			Games:      []ChallengeGame{{URI: "at://did:plc:white/app.atchess.game/g1", CID: "game-cid", Owner: "did:plc:white"}},
+		},
+		"drawOffer": &DrawOffer{
+			CreatedAt: "2024-01-01T00:00:00Z",
+			Game:      StrongRef{URI: "at://did:plc:white/app.atchess.game/g1", CID: "game-cid"},
+			OfferedBy: "did:plc:white",
+			Status:    "expired",
+		},
+		"rematchOffer": &RematchOffer{
+			CreatedAt: "2024-01-01T00:00:00Z",
+			Game:      StrongRef{URI: "at://did:plc:white/app.atchess.game/g1", CID: "game-cid"},
+			OfferedBy: "did:plc:white",
+			Opponent:  "did:plc:black",
+			Color:     "black",
+			Status:    "accepted",
+			Rematch:   &StrongRef{URI: "at://did:plc:black/app.atchess.game/g2", CID: "rematch-cid"},
+		},
+		"resignation": &Resignation{
+			CreatedAt:       "2024-01-01T00:00:00Z",
+			Game:            StrongRef{URI: "at://did:plc:white/app.atchess.game/g1", CID: "game-cid"},
+			ResigningPlayer: "did:plc:white",
+		},
+		"timeViolation": &TimeViolation{
+			CreatedAt:       "2024-01-01T00:00:00Z",
+			Game:            StrongRef{URI: "at://did:plc:white/app.atchess.game/g1", CID: "game-cid"},
+			ClaimingPlayer:  "did:plc:white",
+			ViolatingPlayer: "did:plc:black",
+			Reason:          "disconnected",
+		},
+		"challengeNotification": &ChallengeNotification{
+			CreatedAt:  "2024-01-01T00:00:00Z",
+			Challenge:  StrongRef{URI: "at://did:plc:white/app.atchess.challenge/c1", CID: "challenge-cid"},
+			Challenger: "did:plc:white",
+			Color:      "white",
+		},
+		"preferences": &Preferences{
+			Timezone:           "Europe/Paris",
+			BoardTheme:         "green",
+			AutoQueen:          true,
+			DefaultTimeControl: &TimeControl{Type: "correspondence", DaysPerMove: 3},
+			EmailNotifications: &EmailNotifications{Challenges: true},
+			UpdatedAt:          "2024-01-01T00:00:00Z",
+		},
+		"report": &Report{
+			CreatedAt: "2024-01-01T00:00:00Z",
+			Subject:   "did:plc:black",
+			Reason:    "cheating",
+			Details:   "Every move matched the engine",
+			Game:      "at://did:plc:white/app.atchess.game/g1",
+		},
+		"conditionalMove": &ConditionalMove{
+			CreatedAt: "2024-01-01T00:00:00Z",
+			Game:      StrongRef{URI: "at://did:plc:white/app.atchess.game/g1", CID: "game-cid"},
+			Player:    "did:plc:white",
+			FEN:       "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+			Moves:     []string{"e7e5", "g1f3", "b8c6", "f1b5"},
+			Played:    2,
+			Status:    "active",
+		},
+		"achievement": &Achievement{
+			Kind:       "knight_promotion_mate",
+			Game:       "at://did:plc:white/app.atchess.game/g1",
+			UnlockedAt: "2024-01-01T00:00:00Z",
+			Issuer:     "did:plc:instance",
+		},
+		"announcement": &Announcement{
+			CreatedAt: "2024-01-01T00:00:00Z",
+			Title:     "Maintenance",
+			Message:   "Back in 10 minutes",
+			Level:     "maintenance",
+			Author:    "did:plc:operator",
+			ExpiresAt: "2024-01-01T01:00:00Z",
+		},
+		"study": &Study{
+			CreatedAt: "2024-01-01T00:00:00Z",
+			Name:      "Fool's mate",
+			Members:   []string{"did:plc:black"},
+			Chapters: []StudyChapter{{
+				ID:   "c1",
+				Name: "Chapter 1",
+				FEN:  "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+				Nodes: []StudyNode{
+					{ID: "n1", UCI: "f2f3", SAN: "f3", FEN: "rnbqkbnr/pppppppp/8/8/8/5P2/PPPPP1PP/RNBQKBNR b KQkq - 0 1", Comment: "Weakens the king"},
+					{ID: "n2", Parent: "n1", UCI: "e7e5", SAN: "e5", FEN: "rnbqkbnr/pppp1ppp/8/4p3/8/5P2/PPPPP1PP/RNBQKBNR w KQkq e6 0 2"},
+				},
+			}},
+		},
+	}
+
+	for name, record := range records {
+		if err := record.Validate(); err != nil {
+			t.Errorf("Expected a valid %s, got %v", name, err)
+		}
+	}
+}
+
+func TestInvalidRecords(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(m map[string]interface{})
+		path   string
+	}{
+		{"missing required field", func(m map[string]interface{}) { delete(m, "fen") }, "fen"},
+		{"wrong type", func(m map[string]interface{}) { m["from"] = 52 }, "from"},
+		{"bad datetime", func(m map[string]interface{}) { m["createdAt"] = "yesterday" }, "createdAt"},
+		{"bad DID", func(m map[string]interface{}) { m["player"] = "alice.bsky.social" }, "player"},
+		{"strong ref without CID", func(m map[string]interface{}) {
+			m["game"] = map[string]interface{}{"uri": "at://did:plc:white/app.atchess.game/g1"}
+		}, "game.cid"},
+		{"strong ref to a URL", func(m map[string]interface{}) {
+			m["game"] = map[string]interface{}{"uri": "https://example.com", "cid": "game-cid"}
+		}, "game.uri"},
+		{"bad enum value", func(m map[string]interface{}) { m["promotion"] = "k" }, "promotion"},
+		{"fractional integer", func(m map[string]interface{}) { m["moveNumber"] = 1.5 }, "moveNumber"},
+		{"integer below minimum", func(m map[string]interface{}) { m["moveNumber"] = 0 }, "moveNumber"},
+		{"wrong $type", func(m map[string]interface{}) { m["$type"] = GameNSID }, "$type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := map[string]interface{}{
+				"$type":     MoveNSID,
+				"createdAt": "2024-01-01T00:00:00Z",
+				"game":      map[string]interface{}{"uri": "at://did:plc:white/app.atchess.game/g1", "cid": "game-cid"},
+				"player":    "did:plc:white",
+				"from":      "e2",
+				"to":        "e4",
+				"fen":       "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+			}
+			tt.mutate(record)
+
+			err := Validate(MoveNSID, record)
+			if !errors.Is(err, ErrInvalidRecord) {
+				t.Fatalf("Expected ErrInvalidRecord, got %v", err)
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Path != tt.path {
+				t.Errorf("Expected the error to name %s, got %v", tt.path, err)
+			}
+		})
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	game := &Game{
+		CreatedAt: "2024-01-01T00:00:00Z",
+		White:     "did:plc:white",
+		Black:     "did:plc:black",
+		Status:    "active",
+		FEN:       "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		Bot:       &Bot{Player: "did:plc:black", Level: 9},
+	}
+	if err := game.Validate(); err == nil || !strings.Contains(err.Error(), "bot.level") {
+		t.Errorf("Expected the bot level to be out of range, got %v", err)
+	}
+
+	challenge := &Challenge{
+		CreatedAt:  "2024-01-01T00:00:00Z",
+		Challenger: "did:plc:white",
+		Challenged: "did:plc:black",
+		Status:     "pending",
+		Message:    strings.Repeat("♟", 101),
+	}
+	if err := challenge.Validate(); err == nil || !strings.Contains(err.Error(), "message") {
+		t.Errorf("Expected the message to be too long, got %v", err)
+	}
+
+	prefs := &Preferences{
+		DefaultTimeControl: &TimeControl{Type: "correspondence", DaysPerMove: 14},
+		UpdatedAt:          "2024-01-01T00:00:00Z",
+	}
+	if err := prefs.Validate(); err == nil || !strings.Contains(err.Error(), "defaultTimeControl.daysPerMove") {
+		t.Errorf("Expected the days per move to be out of range, got %v", err)
+	}
+
+	settings := &Settings{UpdatedAt: "2024-01-01T00:00:00Z"}
+	for i := 0; i <= 1000; i++ {
+		settings.ChallengeDenyList = append(settings.ChallengeDenyList, fmt.Sprintf("did:plc:spammer%d", i))
+	}
+	if err := settings.Validate(); err == nil || !strings.Contains(err.Error(), "challengeDenyList") {
+		t.Errorf("Expected the deny-list to be too long, got %v", err)
+	}
+}
+
+func TestValidateAllowsUnknownFieldsAndCollections(t *testing.T) {
+	record := map[string]interface{}{
+		"createdAt":  "2024-01-01T00:00:00Z",
+		"game":       map[string]interface{}{"uri": "at://did:plc:white/app.atchess.game/g1", "cid": "game-cid"},
+		"player":     "did:plc:white",
+		"from":       "e2",
+		"to":         "e4",
+		"fen":        "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+		"clockNotes": "added by a newer client",
+	}
+	if err := Validate(MoveNSID, record); err != nil {
+		t.Errorf("Expected fields the lexicon doesn't define to be allowed, got %v", err)
+	}
+	if err := Validate("app.bsky.feed.post", map[string]interface{}{"text": 1}); err != nil {
+		t.Errorf("Expected records without a lexicon to pass, got %v", err)
+	}
+}
@@ -0,0 +1,319 @@
+package lexicon
+
+// StrongRef is a com.atproto.repo.strongRef, pointing at one version of a
+// record
+type StrongRef struct {
+	URI string `json:"uri"`
+	CID string `json:"cid"`
+}
+
+// TimeControl is the time control of a game or challenge
+type TimeControl struct {
+	Type        string `json:"type,omitempty"`
+	Initial     int    `json:"initial,omitempty"`
+	Increment   int    `json:"increment,omitempty"`
+	DaysPerMove int    `json:"daysPerMove,omitempty"`
+}
+
+// Bot marks the side of a game played by the computer
+type Bot struct {
+	Player string `json:"player"`
+	Level  int    `json:"level"`
+}
+
+// GameSource is where an imported game was played
+type GameSource struct {
+	Site  string `json:"site"`
+	ID    string `json:"id"`
+	URL   string `json:"url,omitempty"`
+	White string `json:"white,omitempty"`
+	Black string `json:"black,omitempty"`
+}
+
+// Game is an app.atchess.game record
+type Game struct {
+	Type        string                            `json:"$type,omitempty"`
+	CreatedAt   string                            `json:"createdAt"`
+	UpdatedAt   string                            `json:"updatedAt,omitempty"`
+	White       string                            `json:"white"`
+	Black       string                            `json:"black"`
+	Status      string                            `json:"status"`
+	FEN         string                            `json:"fen"`
+	PGN         string                            `json:"pgn,omitempty"`
+	Variant     string                            `json:"variant,omitempty"`
+	Challenge   *StrongRef                        `json:"challenge,omitempty"`
+	Seek        *StrongRef                        `json:"seek,omitempty"`
+	RematchOf   *StrongRef                        `json:"rematchOf,omitempty"`
+	TimeControl *TimeControl                      `json:"timeControl,omitempty"`
+	Bot         *Bot                              `json:"bot,omitempty"`
+	BotAccounts []string                          `json:"botAccounts,omitempty"`
+	Result      string                            `json:"result,omitempty"`
+	Imported    bool                              `json:"imported,omitempty"`
+	Source      *GameSource                       `json:"source,omitempty"`
+	Extensions  map[string]map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Validate checks the game against its lexicon
+func (r *Game) Validate() error { return Validate(GameNSID, r) }
+
+// Move is an app.atchess.move record
+type Move struct {
+	Type        string    `json:"$type,omitempty"`
+	CreatedAt   string    `json:"createdAt"`
+	Game        StrongRef `json:"game"`
+	Player      string    `json:"player"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	SAN         string    `json:"san,omitempty"`
+	FEN         string    `json:"fen"`
+	Promotion   string    `json:"promotion,omitempty"`
+	Check       bool      `json:"check,omitempty"`
+	Checkmate   bool      `json:"checkmate,omitempty"`
+	MoveNumber  int       `json:"moveNumber,omitempty"`
+	PrevFEN     string    `json:"prevFen,omitempty"`
+	ThinkTimeMs int64     `json:"thinkTimeMs,omitempty"`
+}
+
+// Validate checks the move against its lexicon
+func (r *Move) Validate() error { return Validate(MoveNSID, r) }
+
+// ChallengeGame is a game record created from a challenge
+type ChallengeGame struct {
+	URI   string `json:"uri"`
+	CID   string `json:"cid"`
+	Owner string `json:"owner,omitempty"`
+}
+
+// Challenge is an app.atchess.challenge record
+type Challenge struct {
+	Type           string          `json:"$type,omitempty"`
+	CreatedAt      string          `json:"createdAt"`
+	Challenger     string          `json:"challenger"`
+	Challenged     string          `json:"challenged"`
+	Status         string          `json:"status"`
+	Color          string          `json:"color,omitempty"`
+	ProposedGameID string          `json:"proposedGameId,omitempty"`
+	TimeControl    *TimeControl    `json:"timeControl,omitempty"`
+	Message        string          `json:"message,omitempty"`
+	ExpiresAt      string          `json:"expiresAt,omitempty"`
+	ExpiredAt      string          `json:"expiredAt,omitempty"`
+	CancelledAt    string          `json:"cancelledAt,omitempty"`
+	Games          []ChallengeGame `json:"games,omitempty"`
+}
+
+// Validate checks the challenge against its lexicon
+func (r *Challenge) Validate() error { return Validate(ChallengeNSID, r) }
+
+// DrawOffer is an app.atchess.drawOffer record
+type DrawOffer struct {
+	Type         string    `json:"$type,omitempty"`
+	CreatedAt    string    `json:"createdAt"`
+	Game         StrongRef `json:"game"`
+	OfferedBy    string    `json:"offeredBy"`
+	MoveNumber   int       `json:"moveNumber,omitempty"`
+	Message      string    `json:"message,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	RespondedAt  string    `json:"respondedAt,omitempty"`
+	RespondedBy  string    `json:"respondedBy,omitempty"`
+	ExpiresAt    string    `json:"expiresAt,omitempty"`
+	WithdrawnAt  string    `json:"withdrawnAt,omitempty"`
+	ExpiredAt    string    `json:"expiredAt,omitempty"`
+	SupersededBy string    `json:"supersededBy,omitempty"`
+}
+
+// Validate checks the draw offer against its lexicon
+func (r *DrawOffer) Validate() error { return Validate(DrawOfferNSID, r) }
+
+// RematchOffer is an app.atchess.rematchOffer record, offering to play a
+// finished game again with colors swapped
+type RematchOffer struct {
+	Type        string     `json:"$type,omitempty"`
+	CreatedAt   string     `json:"createdAt"`
+	Game        StrongRef  `json:"game"`
+	OfferedBy   string     `json:"offeredBy"`
+	Opponent    string     `json:"opponent"`
+	Color       string     `json:"color"`
+	Message     string     `json:"message,omitempty"`
+	Status      string     `json:"status"`
+	RespondedAt string     `json:"respondedAt,omitempty"`
+	Rematch     *StrongRef `json:"rematch,omitempty"`
+}
+
+// Validate checks the rematch offer against its lexicon
+func (r *RematchOffer) Validate() error { return Validate(RematchOfferNSID, r) }
+
+// Resignation is an app.atchess.resignation record
+type Resignation struct {
+	Type            string    `json:"$type,omitempty"`
+	CreatedAt       string    `json:"createdAt"`
+	Game            StrongRef `json:"game"`
+	ResigningPlayer string    `json:"resigningPlayer"`
+	MoveNumber      int       `json:"moveNumber,omitempty"`
+	Reason          string    `json:"reason,omitempty"`
+}
+
+// Validate checks the resignation against its lexicon
+func (r *Resignation) Validate() error { return Validate(ResignationNSID, r) }
+
+// TimeViolation is an app.atchess.timeViolation record, claiming a win on
+// time or by disconnection
+type TimeViolation struct {
+	Type              string    `json:"$type,omitempty"`
+	CreatedAt         string    `json:"createdAt"`
+	Game              StrongRef `json:"game"`
+	ClaimingPlayer    string    `json:"claimingPlayer"`
+	ViolatingPlayer   string    `json:"violatingPlayer"`
+	LastMoveTimestamp string    `json:"lastMoveTimestamp,omitempty"`
+	TimeControlType   string    `json:"timeControlType,omitempty"`
+	DaysPerMove       int       `json:"daysPerMove,omitempty"`
+	TimeRemaining     int       `json:"timeRemaining,omitempty"`
+	Reason            string    `json:"reason,omitempty"`
+}
+
+// Validate checks the time violation against its lexicon
+func (r *TimeViolation) Validate() error { return Validate(TimeViolationNSID, r) }
+
+// ChallengeNotification is an app.atchess.challengeNotification record,
+// written to the challenged player's repository
+type ChallengeNotification struct {
+	Type             string       `json:"$type,omitempty"`
+	CreatedAt        string       `json:"createdAt"`
+	Challenge        StrongRef    `json:"challenge"`
+	Challenger       string       `json:"challenger"`
+	ChallengerHandle string       `json:"challengerHandle,omitempty"`
+	TimeControl      *TimeControl `json:"timeControl,omitempty"`
+	Color            string       `json:"color,omitempty"`
+	Message          string       `json:"message,omitempty"`
+	ExpiresAt        string       `json:"expiresAt,omitempty"`
+}
+
+// Validate checks the notification against its lexicon
+func (r *ChallengeNotification) Validate() error { return Validate(ChallengeNotificationNSID, r) }
+
+// EmailNotifications are the email notifications a player has opted in to
+type EmailNotifications struct {
+	PendingMoves   bool `json:"pendingMoves,omitempty"`
+	ExpiringClocks bool `json:"expiringClocks,omitempty"`
+	Challenges     bool `json:"challenges,omitempty"`
+}
+
+// Preferences is an app.atchess.preferences record, the player's settings.
+// There is one per repository, with the record key "self".
+type Preferences struct {
+	Type               string              `json:"$type,omitempty"`
+	Timezone           string              `json:"timezone,omitempty"`
+	BoardTheme         string              `json:"boardTheme,omitempty"`
+	PieceSet           string              `json:"pieceSet,omitempty"`
+	AutoQueen          bool                `json:"autoQueen,omitempty"`
+	DefaultTimeControl *TimeControl        `json:"defaultTimeControl,omitempty"`
+	EmailNotifications *EmailNotifications `json:"emailNotifications,omitempty"`
+	UpdatedAt          string              `json:"updatedAt"`
+}
+
+// Validate checks the preferences against their lexicon
+func (r *Preferences) Validate() error { return Validate(PreferencesNSID, r) }
+
+// Settings is an app.atchess.settings record, saying who the player accepts
+// challenges from. There is one per repository, with the record key "self".
+type Settings struct {
+	Type              string   `json:"$type,omitempty"`
+	ChallengeDenyList []string `json:"challengeDenyList,omitempty"`
+	UpdatedAt         string   `json:"updatedAt"`
+}
+
+// Validate checks the settings against their lexicon
+func (r *Settings) Validate() error { return Validate(SettingsNSID, r) }
+
+// Report is an app.atchess.report record, a player's report of another
+// player's conduct
+type Report struct {
+	Type      string `json:"$type,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	Subject   string `json:"subject"`
+	Reason    string `json:"reason"`
+	Details   string `json:"details,omitempty"`
+	Game      string `json:"game,omitempty"`
+	Record    string `json:"record,omitempty"`
+}
+
+// Validate checks the report against its lexicon
+func (r *Report) Validate() error { return Validate(ReportNSID, r) }
+
+// StudyNode is a move in a study chapter's tree of variations
+type StudyNode struct {
+	ID      string `json:"id"`
+	Parent  string `json:"parent,omitempty"`
+	UCI     string `json:"uci"`
+	SAN     string `json:"san"`
+	FEN     string `json:"fen"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// StudyChapter is a starting position in a study and the moves explored from it
+type StudyChapter struct {
+	ID    string      `json:"id"`
+	Name  string      `json:"name"`
+	FEN   string      `json:"fen"`
+	Nodes []StudyNode `json:"nodes"`
+}
+
+// Study is an app.atchess.study record, a shared analysis board
+type Study struct {
+	Type        string         `json:"$type,omitempty"`
+	CreatedAt   string         `json:"createdAt"`
+	UpdatedAt   string         `json:"updatedAt,omitempty"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Members     []string       `json:"members,omitempty"`
+	Version     int            `json:"version,omitempty"`
+	Chapters    []StudyChapter `json:"chapters"`
+}
+
+// Validate checks the study against its lexicon
+func (r *Study) Validate() error { return Validate(StudyNSID, r) }
+
+// ConditionalMove is an app.atchess.conditionalMove record, a line of replies
+// to play if the opponent makes the expected moves
+type ConditionalMove struct {
+	Type      string    `json:"$type,omitempty"`
+	CreatedAt string    `json:"createdAt"`
+	UpdatedAt string    `json:"updatedAt,omitempty"`
+	Game      StrongRef `json:"game"`
+	Player    string    `json:"player"`
+	FEN       string    `json:"fen"`
+	Moves     []string  `json:"moves"`
+	Played    int       `json:"played,omitempty"`
+	Status    string    `json:"status"`
+}
+
+// Validate checks the conditional move against its lexicon
+func (r *ConditionalMove) Validate() error { return Validate(ConditionalMoveNSID, r) }
+
+// Achievement is an app.atchess.achievement record, a milestone a player has
+// reached
+type Achievement struct {
+	Type       string `json:"$type,omitempty"`
+	Kind       string `json:"kind"`
+	Game       string `json:"game,omitempty"`
+	UnlockedAt string `json:"unlockedAt"`
+	Issuer     string `json:"issuer,omitempty"`
+}
+
+// Validate checks the achievement against its lexicon
+func (r *Achievement) Validate() error { return Validate(AchievementNSID, r) }
+
+// Announcement is an app.atchess.announcement record, an operator message
+// published in the instance's repository
+type Announcement struct {
+	Type      string `json:"$type,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	Level     string `json:"level"`
+	Author    string `json:"author"`
+	StartsAt  string `json:"startsAt,omitempty"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+}
+
+// Validate checks the announcement against its lexicon
+func (r *Announcement) Validate() error { return Validate(AnnouncementNSID, r) }
@@ -0,0 +1,152 @@
+package lexicon
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// schema is a lexicon field definition, limited to the parts the app.atchess
+// lexicons use
+type schema struct {
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Ref        string             `json:"ref"`
+	Required   []string           `json:"required"`
+	Properties map[string]*schema `json:"properties"`
+	Items      *schema            `json:"items"`
+	Enum       []string           `json:"enum"`
+	MaxLength  *int               `json:"maxLength"`
+	Minimum    *int64             `json:"minimum"`
+	Maximum    *int64             `json:"maximum"`
+}
+
+// strongRef is com.atproto.repo.strongRef, the only ref the lexicons use
+var strongRef = &schema{
+	Type:     "object",
+	Required: []string{"uri", "cid"},
+	Properties: map[string]*schema{
+		"uri": {Type: "string", Format: "at-uri"},
+		"cid": {Type: "string"},
+	},
+}
+
+var didPattern = regexp.MustCompile(`^did:[a-z]+:[a-zA-Z0-9._:%-]+$`)
+
+// check validates a JSON value against s. It returns the path to the first
+// offending field and what's wrong with it, or an empty message if the value
+// is valid.
+func (s *schema) check(path []string, value interface{}) ([]string, string) {
+	switch s.Type {
+	case "ref":
+		if s.Ref != "com.atproto.repo.strongRef" {
+			return path, fmt.Sprintf("unsupported ref %s", s.Ref)
+		}
+		return strongRef.check(path, value)
+
+	case "object":
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return path, "must be an object"
+		}
+		for _, name := range s.Required {
+			if field, ok := fields[name]; !ok || field == nil {
+				return append(path, name), "is required"
+			}
+		}
+		for name, field := range fields {
+			property, ok := s.Properties[name]
+			if !ok || field == nil {
+				continue
+			}
+			if fieldPath, message := property.check(append(path, name), field); message != "" {
+				return fieldPath, message
+			}
+		}
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return path, "must be an array"
+		}
+		if s.MaxLength != nil && len(items) > *s.MaxLength {
+			return path, fmt.Sprintf("must have at most %d items", *s.MaxLength)
+		}
+		if s.Items == nil {
+			break
+		}
+		for i, item := range items {
+			if itemPath, message := s.Items.check(append(path, fmt.Sprint(i)), item); message != "" {
+				return itemPath, message
+			}
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return path, "must be a string"
+		}
+		if s.MaxLength != nil && len(str) > *s.MaxLength {
+			return path, fmt.Sprintf("must be at most %d bytes", *s.MaxLength)
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			return path, fmt.Sprintf("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+		if message := checkFormat(s.Format, str); message != "" {
+			return path, message
+		}
+
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return path, "must be an integer"
+		}
+		if s.Minimum != nil && number < float64(*s.Minimum) {
+			return path, fmt.Sprintf("must be at least %d", *s.Minimum)
+		}
+		if s.Maximum != nil && number > float64(*s.Maximum) {
+			return path, fmt.Sprintf("must be at most %d", *s.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return path, "must be a boolean"
+		}
+	}
+
+	return nil, ""
+}
+
+func checkFormat(format, value string) string {
+	switch format {
+	case "datetime":
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			return "must be an RFC 3339 datetime"
+		}
+	case "did":
+		if !didPattern.MatchString(value) {
+			return "must be a DID"
+		}
+	case "at-uri":
+		if !strings.HasPrefix(value, "at://") || len(value) == len("at://") {
+			return "must be an at:// URI"
+		}
+	case "uri":
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" {
+			return "must be a URI"
+		}
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
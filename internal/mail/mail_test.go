@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestComposeKeepsUserDataOutOfHeaders(t *testing.T) {
+	msg := Compose("ATChess <chess@example.com>", Message{
+		To:      "player@example.com\r\nBcc: victim@example.com",
+		Subject: "Your move ♞",
+		Body:    "Line one\nLine two",
+	})
+	text := string(msg)
+
+	if strings.Contains(text, "\r\nBcc:") {
+		t.Errorf("Expected line breaks to be stripped from headers, got:\n%s", text)
+	}
+	if !strings.Contains(text, "Subject: =?utf-8?q?") {
+		t.Errorf("Expected a non-ASCII subject to be encoded, got:\n%s", text)
+	}
+	if !strings.HasSuffix(text, "\r\n\r\nLine one\r\nLine two") {
+		t.Errorf("Expected the body after the headers with CRLF line endings, got %q", text)
+	}
+}
+
+func TestSendRejectsInvalidAddress(t *testing.T) {
+	sender := &SMTP{Host: "localhost", Port: 25, From: "chess@example.com"}
+	err := sender.Send(context.Background(), Message{To: "not an address"})
+	if !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Expected ErrInvalidAddress, got %v", err)
+	}
+}
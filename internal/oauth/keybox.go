@@ -0,0 +1,99 @@
+package oauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeyBox encrypts DPoP private keys and other session secrets with
+// AES-256-GCM before they're stored, so whoever can read the session storage
+// can't use the sessions' tokens
+type KeyBox struct {
+	aead cipher.AEAD
+}
+
+// NewKeyBox creates a key box from a 32-byte key
+func NewKeyBox(key []byte) (*KeyBox, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyBox{aead: aead}, nil
+}
+
+// ParseKeyBox creates a key box from a base64-encoded 32-byte key, as
+// generated by e.g. `openssl rand -base64 32`
+func ParseKeyBox(encoded string) (*KeyBox, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key isn't valid base64: %w", err)
+	}
+	return NewKeyBox(key)
+}
+
+// Seal encrypts a secret, such as a session's tokens, before it's stored.
+// Without a key box the secret is stored unencrypted, which is only
+// acceptable for storage in memory.
+func (b *KeyBox) Seal(secret []byte) ([]byte, error) {
+	if b == nil {
+		return secret, nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, secret, nil), nil
+}
+
+// Open decrypts a secret encrypted by Seal
+func (b *KeyBox) Open(sealed []byte) ([]byte, error) {
+	if b == nil {
+		return sealed, nil
+	}
+	size := b.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("sealed value is too short")
+	}
+	return b.aead.Open(nil, sealed[:size], sealed[size:], nil)
+}
+
+// seal encrypts a DPoP key
+func (b *KeyBox) seal(key *ecdsa.PrivateKey) ([]byte, error) {
+	if key == nil {
+		return nil, nil
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal DPoP key: %w", err)
+	}
+	return b.Seal(der)
+}
+
+// open decrypts a key sealed by seal
+func (b *KeyBox) open(sealed []byte) (*ecdsa.PrivateKey, error) {
+	if len(sealed) == 0 {
+		return nil, nil
+	}
+	der, err := b.Open(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt DPoP key: %w", err)
+	}
+	key, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DPoP key: %w", err)
+	}
+	return key, nil
+}
@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/x509"
@@ -9,25 +10,30 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+
+	"github.com/justinabrahms/atchess/internal/secrets"
 )
 
-// LoadPrivateKey loads the private key from file or environment
+// LoadPrivateKey loads the private key from the environment or a file.
+// OAUTH_PRIVATE_KEY may hold the PEM or a reference to it such as
+// vault://secret/atchess#oauth_key; otherwise it's read from the file at
+// OAUTH_PRIVATE_KEY_FILE (or OAUTH_PRIVATE_KEY_PATH), or oauth-private-key.pem.
 func LoadPrivateKey() (*ecdsa.PrivateKey, error) {
-	// Try environment variable first
 	keyPEM := os.Getenv("OAUTH_PRIVATE_KEY")
-	
-	// If not in env, try file
 	if keyPEM == "" {
-		keyPath := os.Getenv("OAUTH_PRIVATE_KEY_PATH")
+		keyPath := os.Getenv("OAUTH_PRIVATE_KEY_FILE")
 		if keyPath == "" {
-			keyPath = "oauth-private-key.pem"
+			keyPath = os.Getenv("OAUTH_PRIVATE_KEY_PATH")
 		}
-		
-		keyBytes, err := os.ReadFile(keyPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read private key file: %w", err)
+		if keyPath == "" {
+			keyPath = "oauth-private-key.pem"
 		}
-		keyPEM = string(keyBytes)
+		keyPEM = "file://" + keyPath
+	}
+
+	keyPEM, err := secrets.NewResolver().Resolve(context.Background(), keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
 	}
 	
 	// Parse the PEM
@@ -0,0 +1,103 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStorage is Storage in Redis. Values expire through Redis's own key
+// expiry, so DeleteExpired has nothing to do.
+type RedisStorage struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStorage connects to the Redis server at url
+// (redis://[user:pass@]host:port/db) and keeps values under keys starting
+// with prefix
+func NewRedisStorage(url, prefix string) (*RedisStorage, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	return &RedisStorage{client: redis.NewClient(opts), prefix: prefix}, nil
+}
+
+// Ping checks the Redis server can be reached
+func (r *RedisStorage) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Put stores value under key until expires
+func (r *RedisStorage) Put(ctx context.Context, key string, value []byte, expires time.Time) error {
+	ttl := time.Until(expires)
+	if ttl <= 0 {
+		return r.Delete(ctx, key)
+	}
+	if err := r.client.Set(ctx, r.prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return nil
+}
+
+// Get returns the value under key
+func (r *RedisStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// Take returns and removes the value under key with GETDEL, so two callers
+// can't both take it
+func (r *RedisStorage) Take(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.GetDel(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to take %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// Delete removes the value under key
+func (r *RedisStorage) Delete(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, r.prefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// DeleteExpired does nothing; Redis expires values itself
+func (r *RedisStorage) DeleteExpired(ctx context.Context, now time.Time) error {
+	return nil
+}
+
+// Keys returns the keys starting with prefix, found with SCAN so large
+// databases aren't blocked
+func (r *RedisStorage) Keys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	iter := r.client.Scan(ctx, 0, r.prefix+prefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), r.prefix))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list %s keys: %w", prefix, err)
+	}
+	return keys, nil
+}
+
+// Close disconnects from Redis
+func (r *RedisStorage) Close() error {
+	return r.client.Close()
+}
@@ -1,10 +1,11 @@
 package oauth
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"sync"
 	"time"
 )
 
@@ -12,74 +13,123 @@ import (
 type Session struct {
 	DID          string    `json:"did"`
 	Handle       string    `json:"handle"`
+	PDSURL       string    `json:"pds_url"` // the PDS the tokens are for
 	AccessToken  string    `json:"access_token"`
 	RefreshToken string    `json:"refresh_token"`
 	ExpiresAt    time.Time `json:"expires_at"`
 	DPoPKey      *ecdsa.PrivateKey `json:"-"`
 }
 
+// Storage keys are prefixed by what they hold
+const (
+	sessionKeyPrefix       = "session:"
+	authorizationKeyPrefix = "authorization:"
+)
+
+// authorizationTTL is how long a user has to finish logging in
+const authorizationTTL = 15 * time.Minute
+
+// storedSession is a Session as written to storage
+type storedSession struct {
+	DID          string    `json:"did"`
+	Handle       string    `json:"handle"`
+	PDSURL       string    `json:"pds_url"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	DPoPKey      []byte    `json:"dpop_key,omitempty"` // sealed by the store's KeyBox
+}
+
 // SessionStore manages OAuth sessions
 type SessionStore struct {
-	sessions map[string]*Session // map session ID to session
-	mu       sync.RWMutex
+	storage Storage
+	keys    *KeyBox
 }
 
-// NewSessionStore creates a new session store
+// NewSessionStore creates a session store in memory
 func NewSessionStore() *SessionStore {
-	return &SessionStore{
-		sessions: make(map[string]*Session),
-	}
+	return NewSessionStoreWithStorage(NewMemoryStorage(), nil)
+}
+
+// NewSessionStoreWithStorage creates a session store kept in storage, with
+// DPoP keys encrypted by keys. keys may only be nil for storage in memory.
+func NewSessionStoreWithStorage(storage Storage, keys *KeyBox) *SessionStore {
+	return &SessionStore{storage: storage, keys: keys}
 }
 
 // CreateSession stores a new session and returns a session ID
-func (s *SessionStore) CreateSession(session *Session) string {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *SessionStore) CreateSession(ctx context.Context, session *Session) (string, error) {
+	sealed, err := s.keys.seal(session.DPoPKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(storedSession{
+		DID:          session.DID,
+		Handle:       session.Handle,
+		PDSURL:       session.PDSURL,
+		AccessToken:  session.AccessToken,
+		RefreshToken: session.RefreshToken,
+		ExpiresAt:    session.ExpiresAt,
+		DPoPKey:      sealed,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session: %w", err)
+	}
 	
 	// Generate session ID
 	sessionID := generateJTI()
-	s.sessions[sessionID] = session
+	if err := s.storage.Put(ctx, sessionKeyPrefix+sessionID, data, session.ExpiresAt); err != nil {
+		return "", err
+	}
 	
-	return sessionID
+	return sessionID, nil
 }
 
 // GetSession retrieves a session by ID
-func (s *SessionStore) GetSession(sessionID string) (*Session, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	session, exists := s.sessions[sessionID]
-	if !exists {
+func (s *SessionStore) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	data, err := s.storage.Get(ctx, sessionKeyPrefix+sessionID)
+	if errors.Is(err, ErrNotFound) {
 		return nil, fmt.Errorf("session not found")
 	}
+	if err != nil {
+		return nil, err
+	}
+	
+	var stored storedSession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
 	
 	// Check if session is expired
-	if time.Now().After(session.ExpiresAt) {
+	if time.Now().After(stored.ExpiresAt) {
 		return nil, fmt.Errorf("session expired")
 	}
 	
-	return session, nil
+	key, err := s.keys.open(stored.DPoPKey)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &Session{
+		DID:          stored.DID,
+		Handle:       stored.Handle,
+		PDSURL:       stored.PDSURL,
+		AccessToken:  stored.AccessToken,
+		RefreshToken: stored.RefreshToken,
+		ExpiresAt:    stored.ExpiresAt,
+		DPoPKey:      key,
+	}, nil
 }
 
 // DeleteSession removes a session
-func (s *SessionStore) DeleteSession(sessionID string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	delete(s.sessions, sessionID)
+func (s *SessionStore) DeleteSession(ctx context.Context, sessionID string) error {
+	return s.storage.Delete(ctx, sessionKeyPrefix+sessionID)
 }
 
-// CleanupExpiredSessions removes all expired sessions
-func (s *SessionStore) CleanupExpiredSessions() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	now := time.Now()
-	for id, session := range s.sessions {
-		if now.After(session.ExpiresAt) {
-			delete(s.sessions, id)
-		}
-	}
+// CleanupExpiredSessions removes all expired sessions, along with expired
+// authorization requests kept in the same storage
+func (s *SessionStore) CleanupExpiredSessions(ctx context.Context) error {
+	return s.storage.DeleteExpired(ctx, time.Now())
 }
 
 // StartCleanupRoutine starts a goroutine that periodically cleans up expired sessions
@@ -89,7 +139,7 @@ func (s *SessionStore) StartCleanupRoutine() {
 		defer ticker.Stop()
 		
 		for range ticker.C {
-			s.CleanupExpiredSessions()
+			_ = s.CleanupExpiredSessions(context.Background())
 		}
 	}()
 }
@@ -99,49 +149,93 @@ type AuthorizationRequest struct {
 	State         string    `json:"state"`
 	CodeVerifier  string    `json:"code_verifier"`
 	Handle        string    `json:"handle"`
+	PDSURL        string    `json:"pds_url"`
 	CreatedAt     time.Time `json:"created_at"`
 	DPoPKey       *ecdsa.PrivateKey `json:"-"`
 }
 
+// storedAuthorization is an AuthorizationRequest as written to storage
+type storedAuthorization struct {
+	State        string    `json:"state"`
+	CodeVerifier string    `json:"code_verifier"`
+	Handle       string    `json:"handle"`
+	PDSURL       string    `json:"pds_url"`
+	CreatedAt    time.Time `json:"created_at"`
+	DPoPKey      []byte    `json:"dpop_key,omitempty"` // sealed by the store's KeyBox
+}
+
 // AuthorizationStore manages pending authorization requests
 type AuthorizationStore struct {
-	requests map[string]*AuthorizationRequest // map state to request
-	mu       sync.RWMutex
+	storage Storage
+	keys    *KeyBox
 }
 
-// NewAuthorizationStore creates a new authorization store
+// NewAuthorizationStore creates an authorization store in memory
 func NewAuthorizationStore() *AuthorizationStore {
-	return &AuthorizationStore{
-		requests: make(map[string]*AuthorizationRequest),
-	}
+	return NewAuthorizationStoreWithStorage(NewMemoryStorage(), nil)
+}
+
+// NewAuthorizationStoreWithStorage creates an authorization store kept in
+// storage, with DPoP keys encrypted by keys. keys may only be nil for
+// storage in memory.
+func NewAuthorizationStoreWithStorage(storage Storage, keys *KeyBox) *AuthorizationStore {
+	return &AuthorizationStore{storage: storage, keys: keys}
 }
 
 // StoreAuthorization stores a pending authorization request
-func (a *AuthorizationStore) StoreAuthorization(req *AuthorizationRequest) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+func (a *AuthorizationStore) StoreAuthorization(ctx context.Context, req *AuthorizationRequest) error {
+	sealed, err := a.keys.seal(req.DPoPKey)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(storedAuthorization{
+		State:        req.State,
+		CodeVerifier: req.CodeVerifier,
+		Handle:       req.Handle,
+		PDSURL:       req.PDSURL,
+		CreatedAt:    req.CreatedAt,
+		DPoPKey:      sealed,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode authorization request: %w", err)
+	}
 	
-	a.requests[req.State] = req
+	return a.storage.Put(ctx, authorizationKeyPrefix+req.State, data, req.CreatedAt.Add(authorizationTTL))
 }
 
 // GetAndDeleteAuthorization retrieves and removes an authorization request
-func (a *AuthorizationStore) GetAndDeleteAuthorization(state string) (*AuthorizationRequest, error) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	
-	req, exists := a.requests[state]
-	if !exists {
+func (a *AuthorizationStore) GetAndDeleteAuthorization(ctx context.Context, state string) (*AuthorizationRequest, error) {
+	data, err := a.storage.Take(ctx, authorizationKeyPrefix+state)
+	if errors.Is(err, ErrNotFound) {
 		return nil, fmt.Errorf("authorization request not found")
 	}
+	if err != nil {
+		return nil, err
+	}
+	
+	var stored storedAuthorization
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode authorization request: %w", err)
+	}
 	
 	// Check if request is too old (15 minutes)
-	if time.Since(req.CreatedAt) > 15*time.Minute {
-		delete(a.requests, state)
+	if time.Since(stored.CreatedAt) > authorizationTTL {
 		return nil, fmt.Errorf("authorization request expired")
 	}
 	
-	delete(a.requests, state)
-	return req, nil
+	key, err := a.keys.open(stored.DPoPKey)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &AuthorizationRequest{
+		State:        stored.State,
+		CodeVerifier: stored.CodeVerifier,
+		Handle:       stored.Handle,
+		PDSURL:       stored.PDSURL,
+		CreatedAt:    stored.CreatedAt,
+		DPoPKey:      key,
+	}, nil
 }
 
 // MarshalJSON custom marshaller to handle private key serialization
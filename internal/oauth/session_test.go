@@ -0,0 +1,253 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	_ "modernc.org/sqlite"
+)
+
+func newKeyBox(t *testing.T) *KeyBox {
+	t.Helper()
+	keys, err := NewKeyBox(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("Failed to create key box: %v", err)
+	}
+	return keys
+}
+
+func newDPoPKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return key
+}
+
+// storages returns each kind of storage that can run without a database
+func storages(t *testing.T) map[string]Storage {
+	t.Helper()
+	server := miniredis.RunT(t)
+	redisStorage, err := NewRedisStorage("redis://"+server.Addr(), "test:")
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	t.Cleanup(func() { redisStorage.Close() })
+	sqlStorage, err := OpenSQLStorage(context.Background(), "sqlite", filepath.Join(t.TempDir(), "oauth.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite storage: %v", err)
+	}
+	t.Cleanup(func() { sqlStorage.Close() })
+	return map[string]Storage{"memory": NewMemoryStorage(), "redis": redisStorage, "sqlite": sqlStorage}
+}
+
+func TestSessionStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			// A second store over the same storage stands in for a restart
+			// or another replica
+			keys := newKeyBox(t)
+			key := newDPoPKey(t)
+			id, err := NewSessionStoreWithStorage(storage, keys).CreateSession(ctx, &Session{
+				DID:         "did:plc:alice",
+				Handle:      "alice.test",
+				AccessToken: "access",
+				ExpiresAt:   time.Now().Add(time.Hour),
+				DPoPKey:     key,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create session: %v", err)
+			}
+
+			store := NewSessionStoreWithStorage(storage, keys)
+			session, err := store.GetSession(ctx, id)
+			if err != nil {
+				t.Fatalf("Expected the session, got %v", err)
+			}
+			if session.DID != "did:plc:alice" || session.AccessToken != "access" || !session.DPoPKey.Equal(key) {
+				t.Errorf("Unexpected session %+v", session)
+			}
+
+			if err := store.DeleteSession(ctx, id); err != nil {
+				t.Fatalf("Failed to delete session: %v", err)
+			}
+			if _, err := store.GetSession(ctx, id); err == nil {
+				t.Error("Expected a deleted session to be gone")
+			}
+		})
+	}
+}
+
+func TestAuthorizationStoreTakesOnce(t *testing.T) {
+	ctx := context.Background()
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			store := NewAuthorizationStoreWithStorage(storage, newKeyBox(t))
+			key := newDPoPKey(t)
+			err := store.StoreAuthorization(ctx, &AuthorizationRequest{
+				State:        "state-1",
+				CodeVerifier: "verifier",
+				CreatedAt:    time.Now(),
+				DPoPKey:      key,
+			})
+			if err != nil {
+				t.Fatalf("Failed to store authorization: %v", err)
+			}
+
+			req, err := store.GetAndDeleteAuthorization(ctx, "state-1")
+			if err != nil || req.CodeVerifier != "verifier" || !req.DPoPKey.Equal(key) {
+				t.Fatalf("Expected the authorization, got %+v, %v", req, err)
+			}
+			if _, err := store.GetAndDeleteAuthorization(ctx, "state-1"); err == nil {
+				t.Error("Expected an authorization to be usable once")
+			}
+
+			err = store.StoreAuthorization(ctx, &AuthorizationRequest{State: "old", CreatedAt: time.Now().Add(-time.Hour)})
+			if err != nil {
+				t.Fatalf("Failed to store authorization: %v", err)
+			}
+			if _, err := store.GetAndDeleteAuthorization(ctx, "old"); err == nil {
+				t.Error("Expected an old authorization to have expired")
+			}
+		})
+	}
+}
+
+func TestStorageExpiresValues(t *testing.T) {
+	ctx := context.Background()
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			later := time.Now().Add(time.Hour)
+			if err := storage.Put(ctx, "session:a", []byte("first"), later); err != nil {
+				t.Fatalf("Failed to store: %v", err)
+			}
+			if err := storage.Put(ctx, "session:a", []byte("second"), later); err != nil {
+				t.Fatalf("Failed to replace: %v", err)
+			}
+			if value, err := storage.Get(ctx, "session:a"); err != nil || string(value) != "second" {
+				t.Errorf("Expected the replaced value, got %q, %v", value, err)
+			}
+			if err := storage.Delete(ctx, "session:a"); err != nil {
+				t.Fatalf("Failed to delete: %v", err)
+			}
+			if _, err := storage.Get(ctx, "session:a"); err == nil {
+				t.Error("Expected a deleted value to be gone")
+			}
+
+			if err := storage.Put(ctx, "session:old", []byte("value"), time.Now().Add(-time.Second)); err != nil {
+				t.Fatalf("Failed to store an expired value: %v", err)
+			}
+			if _, err := storage.Get(ctx, "session:old"); err == nil {
+				t.Error("Expected an expired value to be gone")
+			}
+			if _, err := storage.Take(ctx, "session:old"); err == nil {
+				t.Error("Expected an expired value not to be taken")
+			}
+			if err := storage.DeleteExpired(ctx, time.Now()); err != nil {
+				t.Errorf("Failed to delete expired values: %v", err)
+			}
+		})
+	}
+}
+
+func TestStorageListsKeysByPrefix(t *testing.T) {
+	ctx := context.Background()
+	for name, storage := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			later := time.Now().Add(time.Hour)
+			for _, key := range []string{"client-session:a", "client-session:b", "session:a"} {
+				if err := storage.Put(ctx, key, []byte("value"), later); err != nil {
+					t.Fatalf("Failed to store %s: %v", key, err)
+				}
+			}
+			if err := storage.Put(ctx, "client-session:old", []byte("value"), time.Now().Add(-time.Second)); err != nil {
+				t.Fatalf("Failed to store an expired value: %v", err)
+			}
+
+			keys, err := storage.Keys(ctx, "client-session:")
+			if err != nil {
+				t.Fatalf("Failed to list keys: %v", err)
+			}
+			sort.Strings(keys)
+			if strings.Join(keys, ",") != "client-session:a,client-session:b" {
+				t.Errorf("Expected the unexpired keys with the prefix, got %v", keys)
+			}
+		})
+	}
+}
+
+func TestKeyBoxSealsSecrets(t *testing.T) {
+	keys := newKeyBox(t)
+	sealed, err := keys.Seal([]byte("refresh-token"))
+	if err != nil {
+		t.Fatalf("Failed to seal: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("refresh-token")) {
+		t.Error("Expected the sealed secret to be encrypted")
+	}
+	if secret, err := keys.Open(sealed); err != nil || string(secret) != "refresh-token" {
+		t.Errorf("Expected the secret back, got %q, %v", secret, err)
+	}
+
+	otherKeys, _ := NewKeyBox(bytes.Repeat([]byte{8}, 32))
+	if _, err := otherKeys.Open(sealed); err == nil {
+		t.Error("Expected the wrong encryption key to be refused")
+	}
+}
+
+func TestDPoPKeysAreEncryptedAtRest(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	key := newDPoPKey(t)
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := NewSessionStoreWithStorage(storage, newKeyBox(t)).CreateSession(ctx, &Session{
+		DID:       "did:plc:alice",
+		ExpiresAt: time.Now().Add(time.Hour),
+		DPoPKey:   key,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	data, err := storage.Get(ctx, sessionKeyPrefix+id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, der) || bytes.Contains(data, []byte(base64.StdEncoding.EncodeToString(der))) {
+		t.Error("Expected the stored DPoP key to be encrypted")
+	}
+
+	// Another key can't open it
+	otherKeys, _ := NewKeyBox(bytes.Repeat([]byte{8}, 32))
+	if _, err := NewSessionStoreWithStorage(storage, otherKeys).GetSession(ctx, id); err == nil {
+		t.Error("Expected the wrong encryption key to be refused")
+	}
+}
+
+func TestParseKeyBox(t *testing.T) {
+	if _, err := ParseKeyBox("c2hvcnQ="); err == nil {
+		t.Error("Expected a short key to be refused")
+	}
+	if _, err := ParseKeyBox("not base64!"); err == nil {
+		t.Error("Expected invalid base64 to be refused")
+	}
+	if _, err := ParseKeyBox(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))); err != nil {
+		t.Errorf("Expected a 32-byte key to be accepted, got %v", err)
+	}
+}
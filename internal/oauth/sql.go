@@ -0,0 +1,128 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/sqlstore"
+)
+
+// sqlSchema is portable between SQLite (3.35+, for RETURNING) and Postgres.
+// Values are stored base64-encoded and expiry as Unix nanoseconds so both
+// behave the same.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS oauth_storage (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS oauth_storage_expiry ON oauth_storage (expires_at)`,
+}
+
+// SQLStorage is Storage backed by database/sql. The driver must be linked
+// into the binary, e.g. with a blank import of a SQLite or Postgres driver.
+type SQLStorage struct {
+	db *sqlstore.DB
+}
+
+// OpenSQLStorage connects to the database and creates the table if needed
+func OpenSQLStorage(ctx context.Context, driver, dsn string) (*SQLStorage, error) {
+	db, err := sqlstore.Open(ctx, "OAuth", driver, dsn, sqlSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLStorage{db: db}, nil
+}
+
+// Put stores value under key until expires
+func (s *SQLStorage) Put(ctx context.Context, key string, value []byte, expires time.Time) error {
+	_, err := s.db.ExecContext(ctx, s.db.Rebind(`
+		INSERT INTO oauth_storage (key, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`),
+		key, base64.StdEncoding.EncodeToString(value), expires.UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return nil
+}
+
+// Get returns the value under key
+func (s *SQLStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	row := s.db.QueryRowContext(ctx, s.db.Rebind(`SELECT value FROM oauth_storage WHERE key = ? AND expires_at > ?`),
+		key, time.Now().UnixNano())
+	return scanValue(row, key)
+}
+
+// Take returns and removes the value under key in one statement, so two
+// callers can't both take it
+func (s *SQLStorage) Take(ctx context.Context, key string) ([]byte, error) {
+	row := s.db.QueryRowContext(ctx, s.db.Rebind(`DELETE FROM oauth_storage WHERE key = ? RETURNING value, expires_at`), key)
+	var encoded string
+	var expires int64
+	if err := row.Scan(&encoded, &expires); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to take %s: %w", key, err)
+	}
+	if time.Now().UnixNano() >= expires {
+		return nil, ErrNotFound
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// Delete removes the value under key
+func (s *SQLStorage) Delete(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, s.db.Rebind(`DELETE FROM oauth_storage WHERE key = ?`), key); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// DeleteExpired removes values that expired before now
+func (s *SQLStorage) DeleteExpired(ctx context.Context, now time.Time) error {
+	if _, err := s.db.ExecContext(ctx, s.db.Rebind(`DELETE FROM oauth_storage WHERE expires_at <= ?`), now.UnixNano()); err != nil {
+		return fmt.Errorf("failed to delete expired OAuth values: %w", err)
+	}
+	return nil
+}
+
+// Keys returns the unexpired keys starting with prefix
+func (s *SQLStorage) Keys(ctx context.Context, prefix string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.db.Rebind(`SELECT key FROM oauth_storage WHERE substr(key, 1, ?) = ? AND expires_at > ?`),
+		len(prefix), prefix, time.Now().UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s keys: %w", prefix, err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to list %s keys: %w", prefix, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Close closes the database
+func (s *SQLStorage) Close() error {
+	return s.db.Close()
+}
+
+func scanValue(row *sql.Row, key string) ([]byte, error) {
+	var encoded string
+	if err := row.Scan(&encoded); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
@@ -0,0 +1,123 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by storage for keys it doesn't hold, or holds only
+// expired values for
+var ErrNotFound = errors.New("not found")
+
+// Storage keeps the serialized sessions and pending authorizations behind
+// SessionStore and AuthorizationStore. Values arrive with their DPoP keys
+// already encrypted, so implementations only need to store bytes until they
+// expire. Storage shared between replicas lets a login that starts on one
+// finish on another.
+type Storage interface {
+	// Put stores value under key until expires, replacing any existing value
+	Put(ctx context.Context, key string, value []byte, expires time.Time) error
+	// Get returns the value under key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Take returns and removes the value under key, or returns ErrNotFound.
+	// Only one caller may take a value.
+	Take(ctx context.Context, key string) ([]byte, error)
+	// Delete removes the value under key, if any
+	Delete(ctx context.Context, key string) error
+	// DeleteExpired removes values that expired before now
+	DeleteExpired(ctx context.Context, now time.Time) error
+	// Keys returns the keys starting with prefix whose values haven't
+	// expired, in no particular order
+	Keys(ctx context.Context, prefix string) ([]string, error)
+	Close() error
+}
+
+// storedValue is a value in MemoryStorage
+type storedValue struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStorage is Storage in memory, lost on restart
+type MemoryStorage struct {
+	mu     sync.Mutex
+	values map[string]storedValue
+}
+
+// NewMemoryStorage creates empty in-memory storage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{values: make(map[string]storedValue)}
+}
+
+// Put stores value under key until expires
+func (m *MemoryStorage) Put(ctx context.Context, key string, value []byte, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = storedValue{value: value, expires: expires}
+	return nil
+}
+
+// Get returns the value under key
+func (m *MemoryStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.values[key]
+	if !ok || time.Now().After(stored.expires) {
+		return nil, ErrNotFound
+	}
+	return stored.value, nil
+}
+
+// Take returns and removes the value under key
+func (m *MemoryStorage) Take(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.values[key]
+	delete(m.values, key)
+	if !ok || time.Now().After(stored.expires) {
+		return nil, ErrNotFound
+	}
+	return stored.value, nil
+}
+
+// Delete removes the value under key
+func (m *MemoryStorage) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, key)
+	return nil
+}
+
+// DeleteExpired removes values that expired before now
+func (m *MemoryStorage) DeleteExpired(ctx context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, stored := range m.values {
+		if now.After(stored.expires) {
+			delete(m.values, key)
+		}
+	}
+	return nil
+}
+
+// Keys returns the unexpired keys starting with prefix
+func (m *MemoryStorage) Keys(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var keys []string
+	for key, stored := range m.values {
+		if strings.HasPrefix(key, prefix) && !now.After(stored.expires) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Close does nothing
+func (m *MemoryStorage) Close() error {
+	return nil
+}
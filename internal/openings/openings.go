@@ -0,0 +1,126 @@
+// Package openings names the opening a game was played in, from an embedded
+// database of common lines with their ECO (Encyclopaedia of Chess Openings)
+// codes.
+package openings
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// Opening is a named opening line
+type Opening struct {
+	ECO   string   `json:"eco"` // e.g. "C60"
+	Name  string   `json:"name"`
+	Moves []string `json:"moves"` // SAN from the starting position
+}
+
+// Ply is the number of half-moves in the opening's line
+func (o *Opening) Ply() int {
+	return len(o.Moves)
+}
+
+//go:embed eco.tsv
+var ecoTSV string
+
+// database is loaded on first use. Its openings are shared and must not be
+// modified.
+var database struct {
+	once       sync.Once
+	all        []*Opening
+	byPosition map[string]*Opening
+}
+
+func loaded() ([]*Opening, map[string]*Opening) {
+	database.once.Do(func() {
+		all, byPosition, err := load(ecoTSV)
+		if err != nil {
+			panic(err)
+		}
+		database.all, database.byPosition = all, byPosition
+	})
+	return database.all, database.byPosition
+}
+
+// load parses the database: one opening per line, with its ECO code, name
+// and moves separated by tabs. Every line must be legal. It returns the
+// openings and the position each line ends in; where two lines reach the
+// same position, the first is kept.
+func load(data string) ([]*Opening, map[string]*Opening, error) {
+	var openings []*Opening
+	byPosition := make(map[string]*Opening)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return nil, nil, fmt.Errorf("line %d: expected ECO, name and moves, got %q", n, line)
+		}
+
+		opening := &Opening{ECO: fields[0], Name: fields[1], Moves: strings.Fields(fields[2])}
+		engine := chess.NewEngine()
+		for _, san := range opening.Moves {
+			if _, err := engine.MakeMoveSAN(san); err != nil {
+				return nil, nil, fmt.Errorf("line %d: %s has an illegal move %s: %w", n, opening.Name, san, err)
+			}
+		}
+		openings = append(openings, opening)
+		if key := positionKey(engine.GetFEN()); byPosition[key] == nil {
+			byPosition[key] = opening
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return openings, byPosition, nil
+}
+
+// positionKey identifies a position by the placement, side to move and
+// castling fields of its FEN. En passant squares are left out because not
+// every client records them the same way.
+func positionKey(fen string) string {
+	fields := strings.Fields(fen)
+	if len(fields) > 3 {
+		fields = fields[:3]
+	}
+	return strings.Join(fields, " ")
+}
+
+// Lookup returns the opening whose line ends in the position, or nil
+func Lookup(fen string) *Opening {
+	_, byPosition := loaded()
+	return byPosition[positionKey(fen)]
+}
+
+// Classify names the opening of a game from the positions it reached after
+// each move, in any order: the opening with the longest line whose position
+// was reached. Transpositions into a known line are recognised. It returns
+// nil if the game never reached a position in the database.
+func Classify(fens []string) *Opening {
+	var best *Opening
+	for _, fen := range fens {
+		if opening := Lookup(fen); opening != nil && (best == nil || opening.Ply() > best.Ply()) {
+			best = opening
+		}
+	}
+	return best
+}
+
+// All returns every opening in the database, ordered by ECO code
+func All() []*Opening {
+	all, _ := loaded()
+	sorted := append([]*Opening(nil), all...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ECO < sorted[j].ECO
+	})
+	return sorted
+}
@@ -0,0 +1,74 @@
+package openings
+
+import (
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// playedFENs returns the positions after each move of a game played in SAN
+func playedFENs(t *testing.T, sans ...string) []string {
+	t.Helper()
+	engine := chess.NewEngine()
+	var fens []string
+	for _, san := range sans {
+		result, err := engine.MakeMoveSAN(san)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fens = append(fens, result.FEN)
+	}
+	return fens
+}
+
+func TestDatabaseHasNoDuplicatePositions(t *testing.T) {
+	for _, opening := range All() {
+		fens := playedFENs(t, opening.Moves...)
+		if found := Lookup(fens[len(fens)-1]); found != opening {
+			t.Errorf("%s %s reaches the same position as %s %s", opening.ECO, opening.Name, found.ECO, found.Name)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		moves []string
+		eco   string
+		name  string
+	}{
+		{[]string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6", "Ba4", "Nf6", "O-O", "Be7", "Re1"}, "C84", "Ruy Lopez: Closed"},
+		{[]string{"e4", "c5", "Nf3", "d6", "d4", "cxd4", "Nxd4", "Nf6", "Nc3", "a6", "Be3"}, "B90", "Sicilian Defense: Najdorf Variation"},
+		{[]string{"d4", "Nf6", "c4", "e6", "Nc3", "Bb4"}, "E20", "Nimzo-Indian Defense"},
+		// A Queen's Gambit reached from the English
+		{[]string{"c4", "e6", "Nc3", "d5", "d4"}, "D31", "Queen's Gambit Declined"},
+	}
+	for _, tt := range tests {
+		opening := Classify(playedFENs(t, tt.moves...))
+		if opening == nil || opening.ECO != tt.eco || opening.Name != tt.name {
+			t.Errorf("Expected %s %s for %v, got %+v", tt.eco, tt.name, tt.moves, opening)
+		}
+	}
+
+	// Positions can arrive in any order
+	fens := playedFENs(t, "e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5")
+	fens[0], fens[5] = fens[5], fens[0]
+	if opening := Classify(fens); opening == nil || opening.ECO != "C50" || opening.Name != "Italian Game: Giuoco Piano" {
+		t.Errorf("Expected the Giuoco Piano, got %+v", opening)
+	}
+
+	if opening := Classify(nil); opening != nil {
+		t.Errorf("Expected no opening without moves, got %+v", opening)
+	}
+	if opening := Classify(playedFENs(t, "a3", "h6")); opening != nil {
+		t.Errorf("Expected no opening for an unknown line, got %+v", opening)
+	}
+}
+
+func TestLoadRejectsIllegalLines(t *testing.T) {
+	if _, _, err := load("C60\tRuy Lopez\te4 e5 Nf3 Nc6 Bb6\n"); err == nil {
+		t.Error("Expected an illegal move to be rejected")
+	}
+	if _, _, err := load("C60 Ruy Lopez e4 e5\n"); err == nil {
+		t.Error("Expected a line without tabs to be rejected")
+	}
+}
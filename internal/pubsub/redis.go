@@ -0,0 +1,72 @@
+// Package pubsub connects protocol service replicas, so WebSocket updates
+// raised on one replica reach players connected to another.
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis publishes and receives messages on a Redis pub/sub channel
+type Redis struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedis connects to the Redis server at url (redis://[user:pass@]host:port/db)
+// and uses channel for messages
+func NewRedis(url, channel string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	return &Redis{client: redis.NewClient(opts), channel: channel}, nil
+}
+
+// Ping checks the Redis server can be reached
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Publish sends message to every subscriber of the channel
+func (r *Redis) Publish(ctx context.Context, message []byte) error {
+	return r.client.Publish(ctx, r.channel, message).Err()
+}
+
+// Subscribe calls handler with each message on the channel until ctx is
+// cancelled. Once subscribed, dropped connections are re-established by the
+// Redis client; messages published in the meantime are lost.
+func (r *Redis) Subscribe(ctx context.Context, handler func(message []byte)) error {
+	sub := r.client.Subscribe(ctx, r.channel)
+	defer sub.Close()
+
+	// Wait for the subscription to be confirmed, so an unreachable server
+	// is reported instead of retried silently
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", r.channel, err)
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return fmt.Errorf("subscription to %s closed", r.channel)
+			}
+			handler([]byte(msg.Payload))
+		}
+	}
+}
+
+// Close disconnects from Redis
+func (r *Redis) Close() error {
+	if err := r.client.Close(); err != nil && !errors.Is(err, redis.ErrClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisDeliversToEverySubscriber(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var received []chan string
+	for i := 0; i < 2; i++ {
+		broker, err := NewRedis("redis://"+server.Addr(), "atchess:test")
+		if err != nil {
+			t.Fatalf("Failed to create broker: %v", err)
+		}
+		defer broker.Close()
+		messages := make(chan string, 1)
+		received = append(received, messages)
+		go broker.Subscribe(ctx, func(message []byte) { messages <- string(message) })
+	}
+
+	publisher, _ := NewRedis("redis://"+server.Addr(), "atchess:test")
+	defer publisher.Close()
+	// Wait until both subscriptions are in place
+	deadline := time.Now().Add(2 * time.Second)
+	for server.PubSubNumSub("atchess:test")["atchess:test"] < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Subscribers never joined the channel")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := publisher.Publish(ctx, []byte("hello")); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	for i, messages := range received {
+		select {
+		case got := <-messages:
+			if got != "hello" {
+				t.Errorf("Subscriber %d got %q", i, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Subscriber %d never got the message", i)
+		}
+	}
+}
+
+func TestRedisSubscribeStopsWithContext(t *testing.T) {
+	server := miniredis.RunT(t)
+	broker, _ := NewRedis("redis://"+server.Addr(), "atchess:test")
+	defer broker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- broker.Subscribe(ctx, func([]byte) {}) }()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the subscription to end with the context, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Subscribe did not return after the context was cancelled")
+	}
+}
+
+func TestNewRedisRejectsBadURL(t *testing.T) {
+	if _, err := NewRedis("http://localhost", "atchess:test"); err == nil {
+		t.Error("Expected a non-redis URL to be rejected")
+	}
+}
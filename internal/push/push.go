@@ -0,0 +1,267 @@
+// Package push sends Web Push notifications to players' browsers, so
+// correspondence players hear about moves and challenges without keeping a
+// tab open. Notifications are signed with the instance's VAPID key and
+// encrypted for each subscription as browsers require.
+package push
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	webpush "github.com/SherClockHolmes/webpush-go"
+	"github.com/justinabrahms/atchess/internal/safehttp"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxSubscriptions bounds the browsers one player can register
+const MaxSubscriptions = 10
+
+// sendTimeout bounds delivering one notification to a push service
+const sendTimeout = 10 * time.Second
+
+var (
+	ErrInvalidEndpoint      = errors.New("push endpoint must be an absolute https URL")
+	ErrPrivateEndpoint      = errors.New("push endpoint must not be on a private address")
+	ErrInvalidKeys          = errors.New("push subscription keys must be base64url p256dh and auth values")
+	ErrTooManySubscriptions = fmt.Errorf("at most %d push subscriptions can be registered", MaxSubscriptions)
+)
+
+// Keys are the browser's encryption keys from its PushSubscription
+type Keys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// Subscription is a browser a player has allowed to receive notifications
+type Subscription struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	Endpoint  string    `json:"endpoint"`
+	CreatedAt time.Time `json:"createdAt"`
+	keys      Keys
+}
+
+// Notification is the payload sent to the browser, for the service worker
+// to show
+type Notification struct {
+	Type   string `json:"type"` // "move" or "challenge"
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	GameID string `json:"gameId,omitempty"`
+	// URL is opened when the notification is clicked
+	URL string `json:"url,omitempty"`
+}
+
+// Options configure a notifier
+type Options struct {
+	PublicKey  string
+	PrivateKey string
+	// Subject is an email address or https URL push services can use to
+	// contact the operator
+	Subject string
+	// TTL is how long push services hold a notification for an offline browser
+	TTL time.Duration
+	// HTTPClient sends notifications; when nil, a client that refuses
+	// private addresses, so endpoints can't reach the server's own network
+	HTTPClient *http.Client
+	// Store keeps subscriptions; in memory when nil
+	Store Store
+	// Resolver looks up endpoints' hosts when they're registered, to refuse
+	// private addresses; net.DefaultResolver when nil
+	Resolver safehttp.Resolver
+	// AllowPrivateAddresses accepts endpoints on private addresses, for tests
+	AllowPrivateAddresses bool
+}
+
+// Notifier keeps push subscriptions and sends notifications to them
+type Notifier struct {
+	opts Options
+
+	// mu serializes subscribing, so this replica can't exceed the limit
+	mu    sync.Mutex
+	sends sync.WaitGroup
+}
+
+// GenerateKeys returns a new VAPID key pair, base64url encoded
+func GenerateKeys() (publicKey, privateKey string, err error) {
+	privateKey, publicKey, err = webpush.GenerateVAPIDKeys()
+	return publicKey, privateKey, err
+}
+
+// NewNotifier creates a notifier signing with the given VAPID key pair
+func NewNotifier(opts Options) *Notifier {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = safehttp.Client()
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	return &Notifier{opts: opts}
+}
+
+// PublicKey returns the VAPID public key browsers subscribe with
+func (n *Notifier) PublicKey() string {
+	return n.opts.PublicKey
+}
+
+// Subscribe registers a browser for owner. Registering the same endpoint
+// again replaces its keys rather than adding a second subscription.
+func (n *Notifier) Subscribe(ctx context.Context, owner, endpoint string, keys Keys) (*Subscription, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, ErrInvalidEndpoint
+	}
+	if !validKey(keys.P256dh) || !validKey(keys.Auth) {
+		return nil, ErrInvalidKeys
+	}
+	if !n.opts.AllowPrivateAddresses {
+		if err := safehttp.CheckHost(ctx, n.opts.Resolver, u.Hostname()); errors.Is(err, safehttp.ErrPrivateAddress) {
+			return nil, ErrPrivateEndpoint
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEndpoint, err)
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	existing, err := n.opts.Store.List(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range existing {
+		if sub.Endpoint == endpoint {
+			sub.keys = keys
+			if err := n.opts.Store.Put(ctx, sub); err != nil {
+				return nil, err
+			}
+			return sub, nil
+		}
+	}
+	if len(existing) >= MaxSubscriptions {
+		return nil, ErrTooManySubscriptions
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	sub := &Subscription{ID: id, Owner: owner, Endpoint: endpoint, CreatedAt: time.Now().UTC(), keys: keys}
+	if err := n.opts.Store.Put(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// validKey reports whether a subscription key is base64url, as browsers send them
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil {
+		_, err = base64.URLEncoding.DecodeString(key)
+	}
+	return err == nil
+}
+
+// List returns owner's subscriptions, oldest first
+func (n *Notifier) List(ctx context.Context, owner string) ([]*Subscription, error) {
+	return n.opts.Store.List(ctx, owner)
+}
+
+// Unsubscribe removes one of owner's subscriptions, reporting whether it existed
+func (n *Notifier) Unsubscribe(ctx context.Context, owner, id string) (bool, error) {
+	return n.opts.Store.Delete(ctx, owner, id)
+}
+
+// Notify sends a notification to every browser player has subscribed, in
+// the background. Subscriptions the push service reports gone are removed.
+func (n *Notifier) Notify(ctx context.Context, player string, notification Notification) {
+	targets, err := n.List(ctx, player)
+	if err != nil {
+		log.Error().Err(err).Str("player", player).Msg("Failed to look up push subscriptions")
+		return
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		log.Error().Err(err).Str("type", notification.Type).Msg("Failed to encode push notification")
+		return
+	}
+	// Notifications outlive the event that triggered them
+	ctx = context.WithoutCancel(ctx)
+	for _, sub := range targets {
+		n.sends.Add(1)
+		go func(sub *Subscription) {
+			defer n.sends.Done()
+			n.send(ctx, sub, payload)
+		}(sub)
+	}
+}
+
+// Wait blocks until all notifications in progress have been sent
+func (n *Notifier) Wait() {
+	n.sends.Wait()
+}
+
+func (n *Notifier) send(ctx context.Context, sub *Subscription, payload []byte) {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	logger := log.With().Str("subscription", sub.ID).Str("owner", sub.Owner).Logger()
+
+	keys := sub.keys
+	resp, err := webpush.SendNotificationWithContext(ctx, payload, &webpush.Subscription{
+		Endpoint: sub.Endpoint,
+		Keys:     webpush.Keys{P256dh: keys.P256dh, Auth: keys.Auth},
+	}, &webpush.Options{
+		HTTPClient:      n.opts.HTTPClient,
+		Subscriber:      n.opts.Subject,
+		TTL:             int(n.opts.TTL.Seconds()),
+		Urgency:         webpush.UrgencyNormal,
+		VAPIDPublicKey:  n.opts.PublicKey,
+		VAPIDPrivateKey: n.opts.PrivateKey,
+	})
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to send push notification")
+		return
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		// The browser unsubscribed or the subscription expired
+		if _, err := n.Unsubscribe(ctx, sub.Owner, sub.ID); err != nil {
+			logger.Warn().Err(err).Msg("Failed to remove expired push subscription")
+			return
+		}
+		logger.Info().Msg("Removed expired push subscription")
+	case resp.StatusCode >= 300:
+		logger.Warn().Str("status", resp.Status).Msg("Push service rejected notification")
+	default:
+		logger.Debug().Msg("Sent push notification")
+	}
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
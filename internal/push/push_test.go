@@ -0,0 +1,246 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const owner = "did:plc:white"
+
+// browserKeys returns subscription keys as a browser would create them
+func browserKeys(t *testing.T) Keys {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	auth := make([]byte, 16)
+	_, _ = rand.Read(auth)
+	return Keys{
+		P256dh: base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		Auth:   base64.RawURLEncoding.EncodeToString(auth),
+	}
+}
+
+// pushService records the notifications sent to it and answers with status
+type pushService struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+}
+
+func newPushService(t *testing.T, status int) *pushService {
+	t.Helper()
+	svc := &pushService{status: status}
+	svc.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		svc.mu.Lock()
+		svc.requests = append(svc.requests, r)
+		svc.mu.Unlock()
+		w.WriteHeader(svc.status)
+	}))
+	t.Cleanup(svc.Close)
+	return svc
+}
+
+// resolver resolves hosts named internal to a private address and every
+// other host to a public one
+type resolver struct{}
+
+func (resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if strings.HasPrefix(host, "internal.") {
+		return []net.IPAddr{{IP: net.ParseIP("10.0.0.5")}}, nil
+	}
+	return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+}
+
+// newTestNotifier creates a notifier sending through client. The test push
+// service is on loopback, so private addresses are allowed with a client.
+func newTestNotifier(t *testing.T, client *http.Client, store Store) *Notifier {
+	t.Helper()
+	publicKey, privateKey, err := GenerateKeys()
+	if err != nil {
+		t.Fatalf("Failed to generate VAPID keys: %v", err)
+	}
+	return NewNotifier(Options{
+		PublicKey:             publicKey,
+		PrivateKey:            privateKey,
+		Subject:               "ops@example.com",
+		HTTPClient:            client,
+		Store:                 store,
+		Resolver:              resolver{},
+		AllowPrivateAddresses: client != nil,
+	})
+}
+
+// forEachStore runs a test against the in-memory store and SQLite
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		store, err := OpenSQLStore(context.Background(), "sqlite", filepath.Join(t.TempDir(), "push.db"))
+		if err != nil {
+			t.Fatalf("Failed to open SQLite store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		test(t, store)
+	})
+}
+
+func TestSubscribeValidates(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		notifier := newTestNotifier(t, nil, store)
+		keys := browserKeys(t)
+
+		if _, err := notifier.Subscribe(ctx, owner, "http://push.example.com/abc", keys); err != ErrInvalidEndpoint {
+			t.Errorf("Expected a plain http endpoint to be refused, got %v", err)
+		}
+		if _, err := notifier.Subscribe(ctx, owner, "https://push.example.com/abc", Keys{P256dh: "not base64!", Auth: keys.Auth}); err != ErrInvalidKeys {
+			t.Errorf("Expected invalid keys to be refused, got %v", err)
+		}
+		for _, endpoint := range []string{"https://127.0.0.1/abc", "https://[::1]:8443/abc", "https://internal.example.com/abc"} {
+			if _, err := notifier.Subscribe(ctx, owner, endpoint, keys); err != ErrPrivateEndpoint {
+				t.Errorf("Expected %s to be refused as private, got %v", endpoint, err)
+			}
+		}
+
+		first, err := notifier.Subscribe(ctx, owner, "https://push.example.com/abc", keys)
+		if err != nil {
+			t.Fatalf("Failed to subscribe: %v", err)
+		}
+		newKeys := browserKeys(t)
+		again, _ := notifier.Subscribe(ctx, owner, "https://push.example.com/abc", newKeys)
+		if subs, _ := notifier.List(ctx, owner); again.ID != first.ID || len(subs) != 1 || subs[0].keys != newKeys {
+			t.Error("Expected subscribing the same browser again to replace its keys")
+		}
+
+		for i := 1; i < MaxSubscriptions; i++ {
+			if _, err := notifier.Subscribe(ctx, owner, "https://push.example.com/"+strings.Repeat("x", i), keys); err != nil {
+				t.Fatalf("Failed to subscribe: %v", err)
+			}
+		}
+		if _, err := notifier.Subscribe(ctx, owner, "https://push.example.com/one-too-many", keys); err != ErrTooManySubscriptions {
+			t.Errorf("Expected the subscription limit to apply, got %v", err)
+		}
+	})
+}
+
+func TestNotifySendsSignedEncryptedNotifications(t *testing.T) {
+	svc := newPushService(t, http.StatusCreated)
+	notifier := newTestNotifier(t, svc.Client(), nil)
+	if _, err := notifier.Subscribe(context.Background(), owner, svc.URL+"/browser", browserKeys(t)); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	notifier.Notify(context.Background(), owner, Notification{Type: "move", Title: "Your move"})
+	notifier.Notify(context.Background(), "did:plc:nobody", Notification{Type: "move", Title: "Your move"})
+	notifier.Wait()
+
+	if len(svc.requests) != 1 {
+		t.Fatalf("Expected one notification, got %d", len(svc.requests))
+	}
+	req := svc.requests[0]
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "vapid t=") || !strings.Contains(req.Header.Get("Authorization"), "k="+notifier.PublicKey()) {
+		t.Errorf("Expected a VAPID authorization, got %q", req.Header.Get("Authorization"))
+	}
+	if req.Header.Get("Content-Encoding") != "aes128gcm" {
+		t.Errorf("Expected an encrypted payload, got encoding %q", req.Header.Get("Content-Encoding"))
+	}
+}
+
+func TestDefaultClientRefusesPrivateAddresses(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+	notifier := newTestNotifier(t, nil, nil)
+	// An endpoint whose host resolved to a public address when it was
+	// registered, and no longer does
+	sub := &Subscription{ID: "s1", Owner: owner, Endpoint: server.URL + "/browser", CreatedAt: time.Now(), keys: browserKeys(t)}
+	if err := notifier.opts.Store.Put(context.Background(), sub); err != nil {
+		t.Fatalf("Failed to store subscription: %v", err)
+	}
+
+	notifier.Notify(context.Background(), owner, Notification{Type: "move", Title: "Your move"})
+	notifier.Wait()
+	if requests != 0 {
+		t.Errorf("Expected a loopback endpoint not to be called, got %d requests", requests)
+	}
+}
+
+func TestNotifyDropsExpiredSubscriptions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		svc := newPushService(t, http.StatusGone)
+		notifier := newTestNotifier(t, svc.Client(), store)
+		if _, err := notifier.Subscribe(context.Background(), owner, svc.URL+"/browser", browserKeys(t)); err != nil {
+			t.Fatalf("Failed to subscribe: %v", err)
+		}
+
+		notifier.Notify(context.Background(), owner, Notification{Type: "challenge", Title: "New challenge"})
+		notifier.Wait()
+		if subs, _ := notifier.List(context.Background(), owner); len(subs) != 0 {
+			t.Errorf("Expected the expired subscription to be removed, got %d", len(subs))
+		}
+	})
+}
+
+func TestUnsubscribe(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		notifier := newTestNotifier(t, nil, store)
+		sub, _ := notifier.Subscribe(ctx, owner, "https://push.example.com/abc", browserKeys(t))
+
+		if removed, err := notifier.Unsubscribe(ctx, "did:plc:black", sub.ID); removed || err != nil {
+			t.Errorf("Expected another player not to remove the subscription, got %v (%v)", removed, err)
+		}
+		if removed, err := notifier.Unsubscribe(ctx, owner, sub.ID); !removed || err != nil {
+			t.Errorf("Expected the owner to remove the subscription, got %v (%v)", removed, err)
+		}
+		if subs, _ := notifier.List(ctx, owner); len(subs) != 0 {
+			t.Errorf("Expected no subscriptions left, got %v", subs)
+		}
+	})
+}
+
+func TestSQLStoreKeepsSubscriptionsAcrossRestarts(t *testing.T) {
+	ctx := context.Background()
+	svc := newPushService(t, http.StatusCreated)
+	dsn := filepath.Join(t.TempDir(), "push.db")
+	store, err := OpenSQLStore(ctx, "sqlite", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open SQLite store: %v", err)
+	}
+	if _, err := newTestNotifier(t, svc.Client(), store).Subscribe(ctx, owner, svc.URL+"/browser", browserKeys(t)); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	store.Close()
+
+	// Another replica, or this one after a restart, reaches the browser
+	store, err = OpenSQLStore(ctx, "sqlite", dsn)
+	if err != nil {
+		t.Fatalf("Failed to reopen SQLite store: %v", err)
+	}
+	defer store.Close()
+	notifier := newTestNotifier(t, svc.Client(), store)
+	notifier.Notify(ctx, owner, Notification{Type: "move", Title: "Your move"})
+	notifier.Wait()
+	if len(svc.requests) != 1 || svc.requests[0].Header.Get("Content-Encoding") != "aes128gcm" {
+		t.Errorf("Expected an encrypted notification from the reopened store, got %d requests", len(svc.requests))
+	}
+}
@@ -0,0 +1,93 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/sqlstore"
+)
+
+// sqlSchema is portable between SQLite and Postgres. Creation times are
+// stored as Unix nanoseconds so both order them the same.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS push_subscriptions (
+		id TEXT PRIMARY KEY,
+		owner TEXT NOT NULL,
+		endpoint TEXT NOT NULL,
+		p256dh TEXT NOT NULL,
+		auth TEXT NOT NULL,
+		created_at BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS push_subscriptions_owner ON push_subscriptions (owner, created_at)`,
+}
+
+// SQLStore is a Store backed by database/sql, so subscriptions survive
+// restarts and every replica sends to them. The driver must be linked into
+// the binary, e.g. with a blank import of a SQLite or Postgres driver.
+type SQLStore struct {
+	db *sqlstore.DB
+}
+
+// OpenSQLStore connects to the database and creates the table if needed
+func OpenSQLStore(ctx context.Context, driver, dsn string) (*SQLStore, error) {
+	db, err := sqlstore.Open(ctx, "push", driver, dsn, sqlSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Put stores a subscription, replacing any with the same ID
+func (s *SQLStore) Put(ctx context.Context, sub *Subscription) error {
+	_, err := s.db.ExecContext(ctx, s.db.Rebind(`
+		INSERT INTO push_subscriptions (id, owner, endpoint, p256dh, auth, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, endpoint = excluded.endpoint,
+			p256dh = excluded.p256dh, auth = excluded.auth, created_at = excluded.created_at`),
+		sub.ID, sub.Owner, sub.Endpoint, sub.keys.P256dh, sub.keys.Auth, sub.CreatedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to store push subscription %s: %w", sub.ID, err)
+	}
+	return nil
+}
+
+// List returns owner's subscriptions, oldest first
+func (s *SQLStore) List(ctx context.Context, owner string) ([]*Subscription, error) {
+	rows, err := s.db.QueryContext(ctx, s.db.Rebind(`
+		SELECT id, owner, endpoint, p256dh, auth, created_at FROM push_subscriptions
+		WHERE owner = ? ORDER BY created_at, id`), owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list push subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []*Subscription
+	for rows.Next() {
+		var sub Subscription
+		var created int64
+		if err := rows.Scan(&sub.ID, &sub.Owner, &sub.Endpoint, &sub.keys.P256dh, &sub.keys.Auth, &created); err != nil {
+			return nil, fmt.Errorf("failed to read push subscription: %w", err)
+		}
+		sub.CreatedAt = time.Unix(0, created).UTC()
+		subs = append(subs, &sub)
+	}
+	return subs, rows.Err()
+}
+
+// Delete removes one of owner's subscriptions
+func (s *SQLStore) Delete(ctx context.Context, owner, id string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, s.db.Rebind(`DELETE FROM push_subscriptions WHERE id = ? AND owner = ?`), id, owner)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete push subscription %s: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete push subscription %s: %w", id, err)
+	}
+	return n > 0, nil
+}
+
+// Close closes the database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,74 @@
+package push
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Store keeps push subscriptions, keys included. A store shared between
+// replicas lets each of them notify every browser.
+type Store interface {
+	// Put stores a subscription, replacing any with the same ID
+	Put(ctx context.Context, sub *Subscription) error
+	// List returns owner's subscriptions, oldest first
+	List(ctx context.Context, owner string) ([]*Subscription, error)
+	// Delete removes one of owner's subscriptions, reporting whether it existed
+	Delete(ctx context.Context, owner, id string) (bool, error)
+	Close() error
+}
+
+// MemoryStore is a Store held in memory. Subscriptions are lost on restart
+// and each replica has its own.
+type MemoryStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]*Subscription
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{subscriptions: make(map[string]*Subscription)}
+}
+
+// Put stores a subscription
+func (m *MemoryStore) Put(ctx context.Context, sub *Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *sub
+	m.subscriptions[sub.ID] = &stored
+	return nil
+}
+
+// List returns owner's subscriptions, oldest first
+func (m *MemoryStore) List(ctx context.Context, owner string) ([]*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var subs []*Subscription
+	for _, sub := range m.subscriptions {
+		if sub.Owner == owner {
+			found := *sub
+			subs = append(subs, &found)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+	return subs, nil
+}
+
+// Delete removes one of owner's subscriptions
+func (m *MemoryStore) Delete(ctx context.Context, owner, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub, ok := m.subscriptions[id]
+	if !ok || sub.Owner != owner {
+		return false, nil
+	}
+	delete(m.subscriptions, id)
+	return true, nil
+}
+
+// Close does nothing
+func (m *MemoryStore) Close() error {
+	return nil
+}
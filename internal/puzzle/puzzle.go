@@ -0,0 +1,124 @@
+// Package puzzle mines tactical positions from finished games and checks
+// players' solutions to them.
+package puzzle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// DefaultMaxMateMoves is how many moves deep games are searched for forced
+// mates
+const DefaultMaxMateMoves = 3
+
+// ErrIllegalMove is returned for an attempt with a move that can't be played
+var ErrIllegalMove = errors.New("illegal move")
+
+// Puzzle is a position from a played game in which the side to move can
+// force checkmate
+type Puzzle struct {
+	ID        string    `json:"id"` // record key
+	URI       string    `json:"uri,omitempty"`
+	GameURI   string    `json:"game"`
+	Ply       int       `json:"ply"` // the half-move the game was about to play
+	FEN       string    `json:"fen"`
+	Color     string    `json:"color"` // the side to move, who solves the puzzle
+	Theme     string    `json:"theme"` // e.g. "mateIn2"
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Solution is the mating line in UCI notation, alternating with the
+	// defender's replies. It's kept from players until they've tried.
+	Solution []string `json:"-"`
+}
+
+// Attempt is the outcome of playing a player's moves against a puzzle
+type Attempt struct {
+	Correct  bool     `json:"correct"`
+	Solved   bool     `json:"solved"`
+	Reply    string   `json:"reply,omitempty"` // the defender's answer to the last move, in UCI
+	ReplySAN string   `json:"replySan,omitempty"`
+	FEN      string   `json:"fen"`                // the position after the moves and replies
+	Solution []string `json:"solution,omitempty"` // once the puzzle is solved or failed
+}
+
+// Solver finds forced mates. The built-in search and the bot's UCI engine
+// are both solvers.
+type Solver interface {
+	// FindMate returns the mating line in UCI notation for a forced mate by
+	// the side to move in at most maxMoves moves, or nil
+	FindMate(ctx context.Context, fen string, maxMoves int) ([]string, error)
+}
+
+// BuiltinSolver searches for mates with the built-in move generator
+type BuiltinSolver struct{}
+
+// FindMate implements Solver
+func (BuiltinSolver) FindMate(ctx context.Context, fen string, maxMoves int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	engine, err := chess.NewEngineFromFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	return engine.FindMate(maxMoves), nil
+}
+
+// sideToMove returns "white" or "black" from a FEN
+func sideToMove(fen string) string {
+	if fields := strings.Fields(fen); len(fields) > 1 && fields[1] == "b" {
+		return "black"
+	}
+	return "white"
+}
+
+// Check plays a player's moves, in UCI or SAN, from the puzzle's position,
+// answering each with the defender's reply from the solution. Moves must
+// follow the solution, except that any checkmate solves the puzzle. An
+// attempt can be checked a move at a time: while it's correct but unsolved,
+// Reply is the defender's answer to the last move.
+func (p *Puzzle) Check(moves []string) (*Attempt, error) {
+	engine, err := chess.NewEngineFromFEN(p.FEN)
+	if err != nil {
+		return nil, fmt.Errorf("puzzle %s has an invalid position: %w", p.ID, err)
+	}
+
+	attempt := &Attempt{Correct: true}
+	for i, move := range moves {
+		var result *chess.MoveResult
+		if chess.IsUCI(move) {
+			result, err = engine.MakeMoveUCI(move)
+		} else {
+			result, err = engine.MakeMoveSAN(move)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrIllegalMove, move)
+		}
+
+		attempt.Reply, attempt.ReplySAN = "", ""
+		step := 2 * i
+		if result.Checkmate {
+			attempt.Solved = true
+			attempt.Solution = p.Solution
+			break
+		}
+		if step+1 >= len(p.Solution) || result.From+result.To+result.Promotion != p.Solution[step] {
+			attempt.Correct = false
+			attempt.Solution = p.Solution
+			break
+		}
+
+		reply, err := engine.MakeMoveUCI(p.Solution[step+1])
+		if err != nil {
+			return nil, fmt.Errorf("puzzle %s has an invalid solution: %w", p.ID, err)
+		}
+		attempt.Reply, attempt.ReplySAN = p.Solution[step+1], reply.SAN
+	}
+	attempt.FEN = engine.GetFEN()
+	return attempt, nil
+}
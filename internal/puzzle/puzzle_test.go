@@ -0,0 +1,140 @@
+package puzzle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+// mateInThree is black to mate: 1... Bc5+ 2. Kxc5 Qb6+ 3. Kd5 Qd6#
+var mateInThree = &Puzzle{
+	ID:       "m3",
+	FEN:      "r1b1kb1r/pppp1ppp/5q2/4n3/3KP3/2N3PN/PPP4P/R1BQ1B1R b kq - 0 1",
+	Solution: []string{"f8c5", "d4c5", "f6b6", "c5d5", "b6d6"},
+}
+
+func TestCheckFollowsSolution(t *testing.T) {
+	attempt, err := mateInThree.Check([]string{"Bc5+"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !attempt.Correct || attempt.Solved || attempt.Reply != "d4c5" || attempt.ReplySAN != "Kxc5" {
+		t.Errorf("Expected the first move to be answered with Kxc5, got %+v", attempt)
+	}
+	if attempt.Solution != nil {
+		t.Error("Expected the solution to stay hidden mid-attempt")
+	}
+
+	attempt, err = mateInThree.Check([]string{"Bc5+", "f6b6", "Qd6#"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !attempt.Correct || !attempt.Solved || attempt.Reply != "" {
+		t.Errorf("Expected the puzzle to be solved, got %+v", attempt)
+	}
+	if !reflect.DeepEqual(attempt.Solution, mateInThree.Solution) {
+		t.Errorf("Expected the solution once solved, got %v", attempt.Solution)
+	}
+}
+
+func TestCheckRejectsWrongMoves(t *testing.T) {
+	attempt, err := mateInThree.Check([]string{"Qf2+"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Correct || attempt.Solved || attempt.Solution == nil {
+		t.Errorf("Expected a wrong move to fail the puzzle and show the solution, got %+v", attempt)
+	}
+
+	if _, err := mateInThree.Check([]string{"Ke2"}); !errors.Is(err, ErrIllegalMove) {
+		t.Errorf("Expected ErrIllegalMove, got %v", err)
+	}
+}
+
+type fakeMoves map[string][]*index.Move
+
+func (f fakeMoves) ListMoves(ctx context.Context, gameURI string) ([]*index.Move, error) {
+	return f[gameURI], nil
+}
+
+type fakePublisher struct{ published []*Puzzle }
+
+func (f *fakePublisher) PublishPuzzle(ctx context.Context, puzzle *Puzzle) (string, error) {
+	f.published = append(f.published, puzzle)
+	return fmt.Sprintf("at://did:plc:instance/app.atchess.puzzle/p%d", len(f.published)), nil
+}
+
+// playGame returns the indexed moves of a game played in SAN
+func playGame(t *testing.T, sans ...string) []*index.Move {
+	t.Helper()
+	engine := chess.NewEngine()
+	var moves []*index.Move
+	for _, san := range sans {
+		result, err := engine.MakeMoveSAN(san)
+		if err != nil {
+			t.Fatal(err)
+		}
+		moves = append(moves, &index.Move{SAN: result.SAN, FEN: result.FEN})
+	}
+	return moves
+}
+
+func TestMineGameFindsMate(t *testing.T) {
+	scholars := "at://did:plc:white/app.atchess.game/scholars"
+	quiet := "at://did:plc:white/app.atchess.game/quiet"
+	moves := fakeMoves{
+		scholars: playGame(t, "e4", "e5", "Bc4", "Nc6", "Qh5", "Nf6", "Qxf7#"),
+		quiet:    playGame(t, "d4", "d5", "Nf3", "Nf6"),
+	}
+	publisher := &fakePublisher{}
+	puzzles := NewPuzzles(BuiltinSolver{}, moves, publisher)
+	ctx := context.Background()
+
+	puzzle, err := puzzles.MineGame(ctx, &index.Game{URI: scholars, Status: "white_won"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if puzzle == nil || puzzle.Ply != 7 || puzzle.Color != "white" || puzzle.Theme != "mateIn1" || !reflect.DeepEqual(puzzle.Solution, []string{"h5f7"}) {
+		t.Fatalf("Expected the Qxf7# position as a mate in one, got %+v", puzzle)
+	}
+	if puzzle.ID != "p1" || len(publisher.published) != 1 || puzzles.Get("p1") != puzzle {
+		t.Errorf("Expected the puzzle to be published as p1, got %+v", puzzle)
+	}
+
+	// Each game is only mined once, and games without a mate give nothing
+	if again, _ := puzzles.MineGame(ctx, &index.Game{URI: scholars, Status: "white_won"}); again != nil {
+		t.Errorf("Expected the game not to be mined again, got %+v", again)
+	}
+	if none, err := puzzles.MineGame(ctx, &index.Game{URI: quiet, Status: "draw"}); none != nil || err != nil {
+		t.Errorf("Expected no puzzle, got %+v (%v)", none, err)
+	}
+	if len(publisher.published) != 1 {
+		t.Errorf("Expected one published puzzle, got %d", len(publisher.published))
+	}
+}
+
+func TestDaily(t *testing.T) {
+	puzzles := NewPuzzles(BuiltinSolver{}, fakeMoves{}, nil)
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	if puzzles.Daily(day) != nil {
+		t.Error("Expected no daily puzzle without puzzles")
+	}
+
+	puzzles.Load([]*Puzzle{
+		{ID: "b", CreatedAt: day.Add(-time.Hour)},
+		{ID: "a", CreatedAt: day.Add(-2 * time.Hour)},
+	})
+	morning, evening := puzzles.Daily(day.Add(time.Hour)), puzzles.Daily(day.Add(23*time.Hour))
+	if morning == nil || morning != evening {
+		t.Errorf("Expected the same puzzle all day, got %+v and %+v", morning, evening)
+	}
+	if tomorrow := puzzles.Daily(day.Add(25 * time.Hour)); tomorrow == morning {
+		t.Errorf("Expected another puzzle the next day, got %+v again", tomorrow)
+	}
+}
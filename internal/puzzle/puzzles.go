@@ -0,0 +1,176 @@
+package puzzle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/rs/zerolog/log"
+)
+
+// Publisher writes a puzzle record and returns its URI
+type Publisher interface {
+	PublishPuzzle(ctx context.Context, puzzle *Puzzle) (string, error)
+}
+
+// MoveSource lists a game's moves in the order they were made; the game
+// index is one
+type MoveSource interface {
+	ListMoves(ctx context.Context, gameURI string) ([]*index.Move, error)
+}
+
+// Puzzles mines finished games for puzzles and keeps the ones found
+type Puzzles struct {
+	solver    Solver
+	moves     MoveSource
+	publisher Publisher
+	maxMoves  int
+
+	mu      sync.Mutex
+	puzzles []*Puzzle // oldest first
+	byID    map[string]*Puzzle
+	mined   map[string]bool // game URIs already searched
+}
+
+// NewPuzzles creates an empty puzzle collection. publisher may be nil, in
+// which case puzzles are only kept in memory.
+func NewPuzzles(solver Solver, moves MoveSource, publisher Publisher) *Puzzles {
+	return &Puzzles{
+		solver:    solver,
+		moves:     moves,
+		publisher: publisher,
+		maxMoves:  DefaultMaxMateMoves,
+		byID:      make(map[string]*Puzzle),
+		mined:     make(map[string]bool),
+	}
+}
+
+// Load adds puzzles published earlier, e.g. read back from the repository
+// at startup. Their games aren't mined again.
+func (p *Puzzles) Load(puzzles []*Puzzle) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, puzzle := range puzzles {
+		if puzzle.Color == "" {
+			puzzle.Color = sideToMove(puzzle.FEN)
+		}
+		p.mined[puzzle.GameURI] = true
+		p.addLocked(puzzle)
+	}
+}
+
+func (p *Puzzles) addLocked(puzzle *Puzzle) {
+	if _, ok := p.byID[puzzle.ID]; ok {
+		return
+	}
+	p.byID[puzzle.ID] = puzzle
+	p.puzzles = append(p.puzzles, puzzle)
+	sort.SliceStable(p.puzzles, func(i, j int) bool {
+		if !p.puzzles[i].CreatedAt.Equal(p.puzzles[j].CreatedAt) {
+			return p.puzzles[i].CreatedAt.Before(p.puzzles[j].CreatedAt)
+		}
+		return p.puzzles[i].ID < p.puzzles[j].ID
+	})
+}
+
+// MineGame searches every position of a finished game for a forced mate by
+// the side to move, and publishes the longest one found - the earliest, if
+// there are several - as a puzzle. It returns nil if there was none. Each
+// game is only searched once.
+func (p *Puzzles) MineGame(ctx context.Context, game *index.Game) (*Puzzle, error) {
+	switch game.Status {
+	case "white_won", "black_won", "draw":
+	default:
+		return nil, nil
+	}
+
+	p.mu.Lock()
+	if p.mined[game.URI] {
+		p.mu.Unlock()
+		return nil, nil
+	}
+	p.mined[game.URI] = true
+	p.mu.Unlock()
+
+	moves, err := p.moves.ListMoves(ctx, game.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list moves: %w", err)
+	}
+
+	var best *Puzzle
+	fen := chess.StartingFEN
+	for i, move := range moves {
+		line, err := p.solver.FindMate(ctx, fen, p.maxMoves)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search ply %d: %w", i+1, err)
+		}
+		if line != nil && (best == nil || len(line) > len(best.Solution)) {
+			best = &Puzzle{
+				GameURI:  game.URI,
+				Ply:      i + 1,
+				FEN:      fen,
+				Color:    sideToMove(fen),
+				Theme:    fmt.Sprintf("mateIn%d", (len(line)+1)/2),
+				Solution: line,
+			}
+		}
+		fen = move.FEN
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	best.CreatedAt = time.Now().UTC()
+	if p.publisher != nil {
+		uri, err := p.publisher.PublishPuzzle(ctx, best)
+		if err != nil {
+			return nil, fmt.Errorf("failed to publish puzzle: %w", err)
+		}
+		best.URI = uri
+		best.ID = uri[strings.LastIndex(uri, "/")+1:]
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if best.ID == "" {
+		best.ID = strconv.Itoa(len(p.puzzles) + 1)
+	}
+	p.addLocked(best)
+
+	log.Info().
+		Str("game", game.URI).
+		Int("ply", best.Ply).
+		Str("theme", best.Theme).
+		Str("puzzle", best.ID).
+		Msg("Mined puzzle")
+	return best, nil
+}
+
+// Get returns a puzzle by ID, or nil
+func (p *Puzzles) Get(id string) *Puzzle {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.byID[id]
+}
+
+// Daily returns the puzzle of the day, the same for everyone through each
+// UTC day, or nil if there are no puzzles yet
+func (p *Puzzles) Daily(now time.Time) *Puzzle {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.puzzles) == 0 {
+		return nil
+	}
+	day := now.UTC().Unix() / int64((24 * time.Hour).Seconds())
+	return p.puzzles[day%int64(len(p.puzzles))]
+}
@@ -0,0 +1,100 @@
+// Package rating computes Glicko-2 player ratings from finished games.
+package rating
+
+import "math"
+
+// Glicko-2 constants. Every game is treated as its own rating period, which
+// suits correspondence play where games finish days apart.
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+
+	// tau constrains how quickly volatility changes
+	tau = 0.5
+	// scale converts between the Glicko and Glicko-2 scales
+	scale = 173.7178
+	// convergence tolerance for the volatility iteration
+	epsilon = 0.000001
+)
+
+// Scores for the result of a game from a player's perspective
+const (
+	Win  = 1.0
+	Draw = 0.5
+	Loss = 0.0
+)
+
+// Glicko is a player's rating, rating deviation and volatility
+type Glicko struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+// Initial is the rating every new player starts with
+func Initial() Glicko {
+	return Glicko{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// Update returns the player's new rating after scoring score against opponent
+func Update(player, opponent Glicko, score float64) Glicko {
+	mu := (player.Rating - DefaultRating) / scale
+	phi := player.Deviation / scale
+	muJ := (opponent.Rating - DefaultRating) / scale
+	phiJ := opponent.Deviation / scale
+
+	g := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+	e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+	v := 1 / (g * g * e * (1 - e))
+	delta := v * g * (score - e)
+
+	sigma := newVolatility(phi, player.Volatility, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*g*(score-e)
+
+	return Glicko{
+		Rating:     newMu*scale + DefaultRating,
+		Deviation:  math.Min(newPhi*scale, DefaultDeviation),
+		Volatility: sigma,
+	}
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm
+// (step 5 of Glickman's "Example of the Glicko-2 system")
+func newVolatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
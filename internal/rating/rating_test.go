@@ -0,0 +1,189 @@
+package rating
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+func TestNewVolatilityMatchesGlickmanExample(t *testing.T) {
+	// Values from step 5 of Glickman's "Example of the Glicko-2 system"
+	sigma := newVolatility(1.1513, 0.06, 1.7785, -0.4834)
+	if math.Abs(sigma-0.05999) > 0.00001 {
+		t.Errorf("Expected volatility 0.05999, got %.5f", sigma)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	winner := Update(Initial(), Initial(), Win)
+	loser := Update(Initial(), Initial(), Loss)
+	drawn := Update(Initial(), Initial(), Draw)
+
+	if winner.Rating <= DefaultRating || loser.Rating >= DefaultRating {
+		t.Errorf("Expected the winner to gain and the loser to drop, got %.1f and %.1f", winner.Rating, loser.Rating)
+	}
+	if math.Abs((winner.Rating-DefaultRating)+(loser.Rating-DefaultRating)) > 0.001 {
+		t.Errorf("Expected symmetric changes between equal players, got %.3f and %.3f", winner.Rating, loser.Rating)
+	}
+	if math.Abs(drawn.Rating-DefaultRating) > 0.001 {
+		t.Errorf("Expected a draw between equal players to keep the rating, got %.3f", drawn.Rating)
+	}
+	if winner.Deviation >= DefaultDeviation {
+		t.Errorf("Expected deviation to shrink after a game, got %.1f", winner.Deviation)
+	}
+
+	// Beating a much stronger player is worth more than beating an equal one
+	strong := Glicko{Rating: 1900, Deviation: 80, Volatility: DefaultVolatility}
+	if upset := Update(Initial(), strong, Win); upset.Rating <= winner.Rating {
+		t.Errorf("Expected an upset to gain more, got %.1f vs %.1f", upset.Rating, winner.Rating)
+	}
+}
+
+type fakePublisher struct {
+	connected map[string]bool
+	published map[string]int
+}
+
+func (f *fakePublisher) PublishRating(ctx context.Context, did string, rating *PlayerRating) error {
+	if !f.connected[did] {
+		return ErrNotConnected
+	}
+	f.published[did]++
+	return nil
+}
+
+func finishedGame(uri, white, black, status string) *index.Game {
+	return &index.Game{URI: uri, White: white, Black: black, Status: status}
+}
+
+func TestRecordGame(t *testing.T) {
+	ctx := context.Background()
+	publisher := &fakePublisher{connected: map[string]bool{"did:plc:alice": true}, published: map[string]int{}}
+	ratings := NewRatings(publisher)
+
+	if ratings.RecordGame(ctx, finishedGame("at://g0", "did:plc:alice", "did:plc:bob", "active")) {
+		t.Error("Expected active games not to be rated")
+	}
+	if ratings.RecordGame(ctx, finishedGame("at://g0", "did:plc:alice", "did:plc:bob", "abandoned")) {
+		t.Error("Expected abandoned games not to be rated")
+	}
+
+	if !ratings.RecordGame(ctx, finishedGame("at://g1", "did:plc:alice", "did:plc:bob", "white_won")) {
+		t.Fatal("Expected finished game to be rated")
+	}
+	// The game record is rewritten after it finishes; it only counts once
+	if ratings.RecordGame(ctx, finishedGame("at://g1", "did:plc:alice", "did:plc:bob", "white_won")) {
+		t.Error("Expected a game to be rated only once")
+	}
+
+	alice, bob := ratings.Get("did:plc:alice"), ratings.Get("did:plc:bob")
+	if alice.Games != 1 || alice.Wins != 1 || bob.Losses != 1 || alice.Rating <= bob.Rating {
+		t.Errorf("Unexpected ratings: %+v %+v", alice, bob)
+	}
+	if history := ratings.History("did:plc:alice"); len(history) != 1 || history[0].Game != "at://g1" || history[0].Rating != alice.Rating {
+		t.Errorf("Expected one point in alice's rating history, got %+v", history)
+	}
+	if unrated := ratings.Get("did:plc:carol"); unrated.Games != 0 || unrated.Rating != DefaultRating || !unrated.Provisional {
+		t.Errorf("Expected initial rating for an unrated player, got %+v", unrated)
+	}
+
+	// Bob wasn't logged in, so his rating is published when he comes back
+	if publisher.published["did:plc:alice"] != 1 || publisher.published["did:plc:bob"] != 0 {
+		t.Fatalf("Unexpected publications: %v", publisher.published)
+	}
+	publisher.connected["did:plc:bob"] = true
+	ratings.PublishPending(ctx, "did:plc:bob")
+	ratings.PublishPending(ctx, "did:plc:bob")
+	if publisher.published["did:plc:bob"] != 1 {
+		t.Errorf("Expected pending rating to be published once, got %d", publisher.published["did:plc:bob"])
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	ctx := context.Background()
+	ratings := NewRatings(nil)
+
+	// Alice beats Bob and draws Carol; Bob and Carol draw
+	for i := 0; i < 20; i++ {
+		round := fmt.Sprintf("at://round%d/", i)
+		ratings.RecordGame(ctx, finishedGame(round+"1", "did:plc:alice", "did:plc:bob", "white_won"))
+		ratings.RecordGame(ctx, finishedGame(round+"2", "did:plc:carol", "did:plc:alice", "draw"))
+		ratings.RecordGame(ctx, finishedGame(round+"3", "did:plc:bob", "did:plc:carol", "draw"))
+	}
+	ratings.RecordGame(ctx, finishedGame("at://new", "did:plc:dave", "did:plc:erin", "draw"))
+
+	board := ratings.Leaderboard(10, 1, false)
+	if len(board) != 3 || board[0].DID != "did:plc:alice" || board[1].DID != "did:plc:carol" || board[2].DID != "did:plc:bob" {
+		t.Fatalf("Unexpected leaderboard: %+v", board)
+	}
+	if len(ratings.Leaderboard(10, 1, true)) != 5 {
+		t.Error("Expected provisional players when requested")
+	}
+	if len(ratings.Leaderboard(2, 1, false)) != 2 {
+		t.Error("Expected leaderboard to respect the limit")
+	}
+	if len(ratings.Leaderboard(10, 41, false)) != 0 {
+		t.Error("Expected minGames to filter players")
+	}
+
+	// Rebuilding from the index gives the same result without publishing
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	_ = indexer.Apply(ctx, "create", "did:plc:alice", "app.atchess.game/1", map[string]interface{}{
+		"white": "did:plc:alice", "black": "did:plc:bob", "status": "black_won", "createdAt": "2024-01-01T00:00:00Z",
+	})
+	rebuilt := NewRatings(nil)
+	if err := rebuilt.Rebuild(ctx, indexer); err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	if rebuilt.Get("did:plc:bob").Wins != 1 {
+		t.Errorf("Expected rebuilt ratings to include indexed games, got %+v", rebuilt.Get("did:plc:bob"))
+	}
+}
+
+func TestTimeControlLeaderboard(t *testing.T) {
+	ctx := context.Background()
+	ratings := NewRatings(nil)
+
+	// Alice has the better of Bob at blitz, and Bob at rapid
+	for i := 0; i < 40; i++ {
+		blitzResult, rapidResult := "white_won", "black_won"
+		if i%2 == 1 {
+			blitzResult, rapidResult = "draw", "draw"
+		}
+		blitz := finishedGame(fmt.Sprintf("at://blitz%d", i), "did:plc:alice", "did:plc:bob", blitzResult)
+		blitz.TimeControl = "blitz"
+		rapid := finishedGame(fmt.Sprintf("at://rapid%d", i), "did:plc:alice", "did:plc:bob", rapidResult)
+		rapid.TimeControl = "rapid"
+		ratings.RecordGame(ctx, blitz)
+		ratings.RecordGame(ctx, rapid)
+	}
+
+	board, total := ratings.TimeControlLeaderboard("blitz", 0, 10, 1, false)
+	if total != 2 || len(board) != 2 || board[0].DID != "did:plc:alice" || board[0].Games != 40 || board[0].TimeControl != "blitz" {
+		t.Fatalf("Unexpected blitz leaderboard: %d %+v", total, board)
+	}
+	board, _ = ratings.TimeControlLeaderboard("rapid", 0, 10, 1, false)
+	if len(board) != 2 || board[0].DID != "did:plc:bob" {
+		t.Errorf("Unexpected rapid leaderboard: %+v", board)
+	}
+	if overall := ratings.Get("did:plc:alice"); overall.Games != 80 || overall.TimeControl != "" {
+		t.Errorf("Expected the overall rating to count every game, got %+v", overall)
+	}
+
+	board, total = ratings.TimeControlLeaderboard("blitz", 1, 10, 1, false)
+	if total != 2 || len(board) != 1 || board[0].DID != "did:plc:bob" {
+		t.Errorf("Expected the second page to start after the offset, got %d %+v", total, board)
+	}
+	if board, total = ratings.TimeControlLeaderboard("bullet", 0, 10, 1, true); total != 0 || len(board) != 0 {
+		t.Errorf("Expected an empty leaderboard for a time control without games, got %+v", board)
+	}
+
+	// A new game re-ranks the board
+	ratings.RecordGame(ctx, &index.Game{URI: "at://blitz-new", White: "did:plc:carol", Black: "did:plc:alice", Status: "white_won", TimeControl: "blitz"})
+	if _, total := ratings.TimeControlLeaderboard("blitz", 0, 10, 1, true); total != 3 {
+		t.Errorf("Expected the new player on the blitz leaderboard, got %d", total)
+	}
+}
@@ -0,0 +1,323 @@
+package rating
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/rs/zerolog/log"
+)
+
+// ProvisionalDeviation is the rating deviation above which a rating is
+// considered provisional and kept off the leaderboard by default
+const ProvisionalDeviation = 110.0
+
+// ErrNotConnected is returned by a Publisher that has no way to write to a
+// player's repository right now; the rating is published later
+var ErrNotConnected = errors.New("player is not connected")
+
+// PlayerRating is a player's current rating and record
+type PlayerRating struct {
+	DID string `json:"did"`
+	// TimeControl is set on ratings from one time control's games alone
+	TimeControl string `json:"timeControl,omitempty"`
+	Glicko
+	Games       int       `json:"games"`
+	Wins        int       `json:"wins"`
+	Losses      int       `json:"losses"`
+	Draws       int       `json:"draws"`
+	Provisional bool      `json:"provisional"`
+	LastGame    string    `json:"lastGame,omitempty"` // URI of the most recent rated game
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// RatingPoint is a player's overall rating after one of their rated games
+type RatingPoint struct {
+	Game     string    `json:"game"`
+	PlayedAt time.Time `json:"playedAt"` // when the game's last move was made
+	Glicko
+}
+
+// Publisher writes a player's rating record to their repository
+type Publisher interface {
+	PublishRating(ctx context.Context, did string, rating *PlayerRating) error
+}
+
+// Ratings keeps ratings for every player seen in a finished game
+type Ratings struct {
+	players map[string]*PlayerRating
+	// byTimeControl rates each time control's games separately as well, so
+	// a player has a blitz rating from their blitz games alone
+	byTimeControl map[string]map[string]*PlayerRating
+	// history is each player's overall rating after each of their games,
+	// in the order they were rated
+	history map[string][]RatingPoint
+	// boards are ratings sorted best first, by time control ("" for
+	// overall), dropped whenever a game changes them and re-sorted when next
+	// asked for
+	boards    map[string][]*PlayerRating
+	rated     map[string]bool // game URIs already applied
+	pending   map[string]bool // DIDs whose rating record still needs publishing
+	publisher Publisher
+	mu        sync.Mutex
+}
+
+// NewRatings creates an empty rating table. publisher may be nil.
+func NewRatings(publisher Publisher) *Ratings {
+	return &Ratings{
+		players:       make(map[string]*PlayerRating),
+		byTimeControl: make(map[string]map[string]*PlayerRating),
+		history:       make(map[string][]RatingPoint),
+		boards:        make(map[string][]*PlayerRating),
+		rated:         make(map[string]bool),
+		pending:       make(map[string]bool),
+		publisher:     publisher,
+	}
+}
+
+// scores returns each side's score for a finished game
+func scores(status string) (white, black float64, ok bool) {
+	switch status {
+	case "white_won":
+		return Win, Loss, true
+	case "black_won":
+		return Loss, Win, true
+	case "draw":
+		return Draw, Draw, true
+	}
+	// Active and abandoned games aren't rated
+	return 0, 0, false
+}
+
+// RecordGame applies a finished game to both players' ratings and publishes
+// the new ratings. Each game is only applied once; games that aren't finished
+// are ignored. It reports whether the game was rated.
+func (r *Ratings) RecordGame(ctx context.Context, game *index.Game) bool {
+	return r.record(ctx, game, true)
+}
+
+func (r *Ratings) record(ctx context.Context, game *index.Game, publish bool) bool {
+	whiteScore, blackScore, ok := scores(game.Status)
+	if !ok || game.White == game.Black {
+		return false
+	}
+
+	r.mu.Lock()
+	if r.rated[game.URI] {
+		r.mu.Unlock()
+		return false
+	}
+	r.rated[game.URI] = true
+
+	white, black := rate(r.players, "", game, whiteScore, blackScore)
+	delete(r.boards, "")
+	playedAt := game.CreatedAt
+	if game.LastMoveAt != nil {
+		playedAt = *game.LastMoveAt
+	}
+	for _, p := range []*PlayerRating{white, black} {
+		r.history[p.DID] = append(r.history[p.DID], RatingPoint{Game: game.URI, PlayedAt: playedAt, Glicko: p.Glicko})
+	}
+	if game.TimeControl != "" {
+		pool := r.byTimeControl[game.TimeControl]
+		if pool == nil {
+			pool = make(map[string]*PlayerRating)
+			r.byTimeControl[game.TimeControl] = pool
+		}
+		rate(pool, game.TimeControl, game, whiteScore, blackScore)
+		delete(r.boards, game.TimeControl)
+	}
+	newWhite, newBlack := white.Glicko, black.Glicko
+
+	updated := []PlayerRating{*white, *black}
+	r.mu.Unlock()
+
+	log.Info().
+		Str("game", game.URI).
+		Str("white", game.White).
+		Float64("whiteRating", newWhite.Rating).
+		Str("black", game.Black).
+		Float64("blackRating", newBlack.Rating).
+		Msg("Rated game")
+
+	if publish {
+		for i := range updated {
+			r.publish(ctx, &updated[i])
+		}
+	}
+	return true
+}
+
+// rate applies a game's result to both players' ratings in players, and
+// returns them
+func rate(players map[string]*PlayerRating, timeControl string, game *index.Game, whiteScore, blackScore float64) (white, black *PlayerRating) {
+	white, black = player(players, timeControl, game.White), player(players, timeControl, game.Black)
+	newWhite := Update(white.Glicko, black.Glicko, whiteScore)
+	newBlack := Update(black.Glicko, white.Glicko, blackScore)
+	white.apply(newWhite, whiteScore, game.URI)
+	black.apply(newBlack, blackScore, game.URI)
+	return white, black
+}
+
+// player returns a player's rating in players, adding the initial rating if
+// they have none
+func player(players map[string]*PlayerRating, timeControl, did string) *PlayerRating {
+	rating, ok := players[did]
+	if !ok {
+		rating = &PlayerRating{DID: did, TimeControl: timeControl, Glicko: Initial(), Provisional: true}
+		players[did] = rating
+	}
+	return rating
+}
+
+func (p *PlayerRating) apply(rating Glicko, score float64, gameURI string) {
+	p.Glicko = rating
+	p.Games++
+	switch score {
+	case Win:
+		p.Wins++
+	case Loss:
+		p.Losses++
+	default:
+		p.Draws++
+	}
+	p.Provisional = rating.Deviation > ProvisionalDeviation
+	p.LastGame = gameURI
+	p.UpdatedAt = time.Now()
+}
+
+func (r *Ratings) publish(ctx context.Context, rating *PlayerRating) {
+	if r.publisher == nil {
+		return
+	}
+
+	err := r.publisher.PublishRating(ctx, rating.DID, rating)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.pending[rating.DID] = true
+		if !errors.Is(err, ErrNotConnected) {
+			log.Warn().Err(err).Str("did", rating.DID).Msg("Failed to publish rating record")
+		}
+		return
+	}
+	delete(r.pending, rating.DID)
+}
+
+// PublishPending publishes a player's rating if an earlier attempt couldn't,
+// e.g. when they log in after their game finished
+func (r *Ratings) PublishPending(ctx context.Context, did string) {
+	r.mu.Lock()
+	player, ok := r.players[did]
+	if !ok || !r.pending[did] {
+		r.mu.Unlock()
+		return
+	}
+	rating := *player
+	r.mu.Unlock()
+
+	r.publish(ctx, &rating)
+}
+
+// Get returns a player's rating. Players without rated games get the initial rating.
+func (r *Ratings) Get(did string) *PlayerRating {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if player, ok := r.players[did]; ok {
+		copied := *player
+		return &copied
+	}
+	return &PlayerRating{DID: did, Glicko: Initial(), Provisional: true}
+}
+
+// History returns a player's overall rating after each of their rated games,
+// oldest first
+func (r *Ratings) History(did string) []RatingPoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RatingPoint{}, r.history[did]...)
+}
+
+// Leaderboard returns the highest rated players with at least minGames
+// games, leaving out provisional ratings unless includeProvisional is set
+func (r *Ratings) Leaderboard(limit, minGames int, includeProvisional bool) []*PlayerRating {
+	board, _ := r.TimeControlLeaderboard("", 0, limit, minGames, includeProvisional)
+	return board
+}
+
+// TimeControlLeaderboard is Leaderboard for ratings from one time control's
+// games, or overall ratings for "", paged: it returns the limit players
+// after the first offset, and how many players qualify in all
+func (r *Ratings) TimeControlLeaderboard(timeControl string, offset, limit, minGames int, includeProvisional bool) ([]*PlayerRating, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	board := []*PlayerRating{}
+	total := 0
+	for _, player := range r.boardLocked(timeControl) {
+		if player.Games < minGames || (player.Provisional && !includeProvisional) {
+			continue
+		}
+		if total >= offset && len(board) < limit {
+			copied := *player
+			board = append(board, &copied)
+		}
+		total++
+	}
+	return board, total
+}
+
+// boardLocked returns a time control's ratings best first, sorting them if a
+// game has changed them since they were last sorted
+func (r *Ratings) boardLocked(timeControl string) []*PlayerRating {
+	if board, ok := r.boards[timeControl]; ok {
+		return board
+	}
+
+	players := r.players
+	if timeControl != "" {
+		players = r.byTimeControl[timeControl]
+	}
+	board := make([]*PlayerRating, 0, len(players))
+	for _, player := range players {
+		board = append(board, player)
+	}
+	sort.Slice(board, func(i, j int) bool {
+		if board[i].Rating != board[j].Rating {
+			return board[i].Rating > board[j].Rating
+		}
+		return board[i].DID < board[j].DID
+	})
+	r.boards[timeControl] = board
+	return board
+}
+
+// Rebuild replays every finished game in the index, oldest first, without
+// republishing rating records. It's used at startup when the index is
+// persisted across restarts.
+func (r *Ratings) Rebuild(ctx context.Context, indexer *index.Indexer) error {
+	var games []*index.Game
+	query := index.Query{Limit: index.MaxLimit}
+	for {
+		page, err := indexer.ListGames(ctx, query)
+		if err != nil {
+			return err
+		}
+		games = append(games, page.Games...)
+		if page.Cursor == "" {
+			break
+		}
+		query.Cursor = page.Cursor
+	}
+
+	// Listings are newest first
+	for i := len(games) - 1; i >= 0; i-- {
+		r.record(ctx, games[i], false)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Package requestid tags every API request with an ID that is returned in the
+// X-Request-ID response header and attached to everything logged while
+// serving it, so a player's report can be matched to the server's logs.
+package requestid
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header carries the request ID in both directions. An ID sent by a proxy or
+// client is kept; otherwise one is generated.
+const Header = "X-Request-ID"
+
+// maxLength bounds the IDs accepted from callers
+const maxLength = 128
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, if any
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// generate returns a random 16-byte ID in hex
+func generate() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// valid reports whether an ID from a caller is safe to log and echo back
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware assigns each request its ID, returns it in the response and
+// puts a logger carrying it (and the trace ID, when the request is traced)
+// in the request context for handlers to log through with Logger. Once the
+// request has been served it is logged with its status and duration.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = generate()
+		}
+		w.Header().Set(Header, id)
+
+		logContext := log.With().Str("requestID", id)
+		if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+			logContext = logContext.Str("traceID", span.TraceID().String())
+		}
+		logger := logContext.Logger()
+		ctx := logger.WithContext(NewContext(r.Context(), id))
+
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		event := logger.Info()
+		if recorder.status >= http.StatusInternalServerError {
+			event = logger.Warn()
+		}
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				event = event.Str("route", template)
+			}
+		}
+		event.Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", recorder.status).
+			Int("bytes", recorder.bytes).
+			Dur("duration", time.Since(start)).
+			Msg("Request served")
+	})
+}
+
+// Logger returns the request's logger, or the global logger outside a request
+func Logger(ctx context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return &log.Logger
+}
+
+// responseRecorder remembers a response's status code and size. It passes
+// hijacking through so WebSocket upgrades still work.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
@@ -0,0 +1,79 @@
+package requestid
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// captureLogs sends the global logger's output to a buffer for the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = previous })
+	return &buf
+}
+
+func TestMiddlewareAssignsRequestIDs(t *testing.T) {
+	logs := captureLogs(t)
+
+	var seen string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+		Logger(r.Context()).Info().Msg("Handling move")
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/moves", nil))
+	id := w.Header().Get(Header)
+	if len(id) != 32 || seen != id {
+		t.Fatalf("Expected a generated ID in the response and context, got %q and %q", id, seen)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected the handler's log and the request log, got %q", logs.String())
+	}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		_ = json.Unmarshal([]byte(line), &entry)
+		if entry["requestID"] != id {
+			t.Errorf("Expected %q to carry the request ID", line)
+		}
+	}
+	var served map[string]interface{}
+	_ = json.Unmarshal([]byte(lines[1]), &served)
+	if served["status"] != float64(http.StatusCreated) || served["path"] != "/api/moves" {
+		t.Errorf("Unexpected request log %q", lines[1])
+	}
+}
+
+func TestMiddlewareKeepsCallersRequestID(t *testing.T) {
+	captureLogs(t)
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/api/games", nil)
+	req.Header.Set(Header, "lb-1234.abcd")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get(Header); got != "lb-1234.abcd" {
+		t.Errorf("Expected the caller's ID to be kept, got %q", got)
+	}
+
+	// IDs that can't be safely logged are replaced
+	req.Header.Set(Header, "bad id\n")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get(Header); got == "bad id\n" || len(got) != 32 {
+		t.Errorf("Expected an invalid ID to be replaced, got %q", got)
+	}
+}
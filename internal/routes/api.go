@@ -0,0 +1,203 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/justinabrahms/atchess/internal/federation"
+	"github.com/justinabrahms/atchess/internal/web"
+)
+
+// Root lists the routes served outside /api, ahead of the static files
+func Root(s *web.Service) []Route {
+	return []Route{
+		// Health endpoint for load balancers and monitoring
+		{Method: http.MethodGet, Path: "/health", Handler: s.HealthHandler},
+		// OAuth client metadata
+		{Method: http.MethodGet, Path: "/client-metadata.json", Handler: s.ClientMetadataHandler},
+		// Instance discovery document for federation
+		{Method: http.MethodGet, Path: federation.WellKnownPath, Handler: s.InstanceWellKnownHandler},
+	}
+}
+
+// UI lists the web interface's routes besides its static files
+func UI() []Route {
+	return []Route{
+		// Deep links: /resolve?uri=at://... redirects to the page for that record
+		{Method: http.MethodGet, Path: "/resolve", Handler: web.ResolveHandler},
+	}
+}
+
+// API lists the routes under /api/v1, also served from the deprecated /api
+// aliases. Order matters: a game's sub-resources must come before the
+// catch-all routes for games, which take the rest of the path as the ID.
+func API(s *web.Service, hub *web.Hub) []Route {
+	return []Route{
+		{Method: http.MethodGet, Path: "/health", Handler: s.HealthHandler},
+
+		// Accounts and sessions
+		{Method: http.MethodPost, Path: "/auth/login", Handler: s.LoginHandler},
+		{Method: http.MethodPost, Path: "/auth/app-password", Handler: s.ValidateAppPasswordHandler},
+		{Method: http.MethodGet, Path: "/auth/current", Handler: s.GetCurrentUserHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/auth/sessions", Handler: s.ListSessionsHandler, Auth: Required},
+		{Method: http.MethodDelete, Path: "/auth/sessions", Handler: s.RevokeOtherSessionsHandler, Auth: Required},
+		{Method: http.MethodDelete, Path: "/auth/sessions/{id}", Handler: s.RevokeSessionHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/auth/oauth/login", Handler: s.OAuthLoginHandler},
+		{Method: http.MethodGet, Path: "/callback", Handler: s.OAuthCallbackHandler},
+		{Method: http.MethodGet, Path: "/auth/session", Handler: s.GetSessionHandler},
+		{Method: http.MethodPost, Path: "/auth/logout", Handler: s.LogoutHandler},
+		{Method: http.MethodGet, Path: "/stats/instance", Handler: s.InstanceStatsHandler},
+
+		// Webhooks for the caller's games
+		{Method: http.MethodGet, Path: "/webhooks", Handler: s.ListWebhooksHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/webhooks", Handler: s.CreateWebhookHandler, Auth: Required},
+		{Method: http.MethodDelete, Path: "/webhooks/{id}", Handler: s.DeleteWebhookHandler, Auth: Required},
+
+		// Web Push notifications to the caller's browsers
+		{Method: http.MethodGet, Path: "/push/vapid-key", Handler: s.PushKeyHandler},
+		{Method: http.MethodGet, Path: "/push/subscriptions", Handler: s.ListPushSubscriptionsHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/push/subscriptions", Handler: s.CreatePushSubscriptionHandler, Auth: Required},
+		{Method: http.MethodDelete, Path: "/push/subscriptions/{id}", Handler: s.DeletePushSubscriptionHandler, Auth: Required},
+
+		// Bot API tokens for bot accounts
+		{Method: http.MethodGet, Path: "/bot-tokens", Handler: s.ListBotTokensHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/bot-tokens", Handler: s.CreateBotTokenHandler, Auth: Required},
+		{Method: http.MethodDelete, Path: "/bot-tokens/{id}", Handler: s.RevokeBotTokenHandler, Auth: Required},
+
+		// Games
+		{Method: http.MethodPost, Path: "/games", Handler: s.CreateGameHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/games", Handler: s.ListGamesHandler},
+		{Method: http.MethodGet, Path: "/games/{id}/pgn", Handler: s.ExportPGNHandler},
+		{Method: http.MethodGet, Path: "/games/{id}/draft", Handler: s.GetDraftHandler, Auth: Required},
+		{Method: http.MethodPut, Path: "/games/{id}/draft", Handler: s.SaveDraftHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/games/{id}/conditionals", Handler: s.ListConditionalMovesHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/games/{id}/conditionals", Handler: s.CreateConditionalMoveHandler, Auth: Required},
+		{Method: http.MethodDelete, Path: "/games/{id}/conditionals/{rkey}", Handler: s.CancelConditionalMoveHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/games/{id}/chat", Handler: s.SendChatHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/games/{id}/review/thread", Handler: s.PreviewReviewHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/games/{id}/review/thread", Handler: s.ShareReviewHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/games/{id}/review/images/{ply}", Handler: s.ReviewImageHandler},
+		{Method: http.MethodGet, Path: "/games/{id}/legal-moves", Handler: s.GameLegalMovesHandler},
+		{Method: http.MethodPost, Path: "/games/{id}/rematch", Handler: s.OfferRematchHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/games/{id}/rematch/respond", Handler: s.RespondToRematchHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/games/{id}/analyze", Handler: s.AnalyzeGameHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/games/{id}/analysis", Handler: s.GetAnalysisHandler},
+		{Method: http.MethodGet, Path: "/games/{id}/opening", Handler: s.GameOpeningHandler},
+		{Method: http.MethodGet, Path: "/games/{id}/timing", Handler: s.GetGameTimingHandler},
+		{Method: http.MethodPost, Path: "/games/{id}/share", Handler: s.ShareGameHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/games/{id}/board.{format:png|svg}", Handler: s.BoardImageHandler},
+		{Method: http.MethodGet, Path: "/games/{id:.*}/time-violation", Handler: s.CheckTimeViolationHandler},
+		{Method: http.MethodPost, Path: "/games/{id:.*}/claim-time", Handler: s.ClaimTimeVictoryHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/games/{id:.*}/time-remaining", Handler: s.GetTimeRemainingHandler},
+		{Method: http.MethodGet, Path: "/games/{id:.*}", Handler: s.GetGameHandler},
+		{Method: http.MethodPost, Path: "/moves", Handler: s.MakeMoveHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/legal-moves", Handler: s.LegalMovesHandler},
+
+		// Challenges, draw offers and resignation
+		{Method: http.MethodPost, Path: "/challenges", Handler: s.CreateChallengeHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/challenges/accept", Handler: s.AcceptChallengeHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/challenges/decline", Handler: s.DeclineChallengeHandler, Auth: Required},
+		{Method: http.MethodDelete, Path: "/challenges/{id}", Handler: s.CancelChallengeHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/challenge-notifications", Handler: s.GetChallengeNotificationsHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/challenge-notifications/ack", Handler: s.AckChallengeNotificationsHandler, Auth: Required},
+		{Method: http.MethodDelete, Path: "/challenge-notifications/{key}", Handler: s.DeleteChallengeNotificationHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/draw-offers", Handler: s.OfferDrawHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/draw-offers/respond", Handler: s.RespondToDrawHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/resign", Handler: s.ResignGameHandler, Auth: Required},
+
+		// Lobby of open challenges, matchmaking and puzzles
+		{Method: http.MethodPost, Path: "/seeks", Handler: s.CreateSeekHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/seeks", Handler: s.ListSeeksHandler},
+		{Method: http.MethodPost, Path: "/seeks/{id:.*}/accept", Handler: s.AcceptSeekHandler, Auth: Required},
+		{Method: http.MethodDelete, Path: "/seeks/{id:.*}", Handler: s.DeleteSeekHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/matchmaking/join", Handler: s.JoinMatchmakingHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/matchmaking/leave", Handler: s.LeaveMatchmakingHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/puzzles/daily", Handler: s.DailyPuzzleHandler},
+		{Method: http.MethodPost, Path: "/puzzles/{id}/attempt", Handler: s.AttemptPuzzleHandler},
+
+		// Studies: shared analysis boards edited together over the study channel
+		{Method: http.MethodPost, Path: "/studies", Handler: s.CreateStudyHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/studies/{id}/edits", Handler: s.EditStudyHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/studies/{id}", Handler: s.GetStudyHandler},
+		{Method: http.MethodPatch, Path: "/studies/{id}", Handler: s.UpdateStudyHandler, Auth: Required},
+		{Method: http.MethodDelete, Path: "/studies/{id}", Handler: s.DeleteStudyHandler, Auth: Required},
+
+		// Bringing games played on Lichess and Chess.com into the caller's repository
+		{Method: http.MethodPost, Path: "/import", Handler: s.ImportGamesHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/import/pgn", Handler: s.ImportPGNHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/import/pgn/{id}", Handler: s.GetPGNImportHandler, Auth: Required},
+
+		// Operator announcements
+		{Method: http.MethodGet, Path: "/announcements", Handler: s.ListAnnouncementsHandler},
+		{Method: http.MethodPost, Path: "/announcements", Handler: s.CreateAnnouncementHandler(hub), Auth: Required},
+		{Method: http.MethodDelete, Path: "/announcements/{id}", Handler: s.DeleteAnnouncementHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/announcements/{id}/dismiss", Handler: s.DismissAnnouncementHandler, Auth: Required},
+
+		// Reporting players, and the operators' review queues
+		{Method: http.MethodPost, Path: "/reports", Handler: s.CreateReportHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/admin/reports", Handler: s.ListReportsHandler, Auth: Required},
+		{Method: http.MethodPost, Path: "/admin/reports/{id}/resolve", Handler: s.ResolveReportHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/admin/flagged-games", Handler: s.FlaggedGamesHandler, Auth: Required},
+
+		// Federation and spectating
+		{Method: http.MethodPost, Path: "/federation/hello", Handler: s.FederationHelloHandler},
+		{Method: http.MethodGet, Path: "/federation/instances", Handler: s.ListInstancesHandler},
+		{Method: http.MethodGet, Path: "/spectator/games", Handler: s.GetActiveGamesHandler},
+		{Method: http.MethodGet, Path: "/spectator/tv", Handler: s.TVHandler},
+		{Method: http.MethodPost, Path: "/spectator/games/{id:.*}/count", Handler: s.UpdateSpectatorCountHandler(hub)},
+		{Method: http.MethodGet, Path: "/spectator/games/{id:.*}/abandonment", Handler: s.CheckAbandonmentHandler},
+		{Method: http.MethodPost, Path: "/spectator/games/{id:.*}/claim-abandonment", Handler: s.ClaimAbandonedGameHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/spectator/games/{id:.*}", Handler: s.GetSpectatorGameHandler},
+
+		// Time controls
+		{Method: http.MethodGet, Path: "/deadlines", Handler: s.ListDeadlinesHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/players/{did}/deadlines.ics", Handler: s.DeadlinesCalendarHandler},
+
+		// Player preferences, settings, profiles, ratings and presence
+		{Method: http.MethodGet, Path: "/preferences", Handler: s.GetPreferencesHandler, Auth: Required},
+		{Method: http.MethodPut, Path: "/preferences", Handler: s.SavePreferencesHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/settings", Handler: s.GetSettingsHandler, Auth: Required},
+		{Method: http.MethodPut, Path: "/settings", Handler: s.SaveSettingsHandler, Auth: Required},
+		{Method: http.MethodGet, Path: "/players/{didOrHandle}", Handler: s.GetPlayerProfileHandler},
+		{Method: http.MethodGet, Path: "/players/{did}/rating", Handler: s.GetPlayerRatingHandler},
+		{Method: http.MethodGet, Path: "/players/{did}/achievements", Handler: s.GetPlayerAchievementsHandler},
+		{Method: http.MethodGet, Path: "/players/{did}/stats", Handler: s.PlayerStatsHandler},
+		{Method: http.MethodGet, Path: "/players/{didA}/vs/{didB}", Handler: s.HeadToHeadHandler},
+		{Method: http.MethodGet, Path: "/players/{didOrHandle}/studies", Handler: s.ListPlayerStudiesHandler},
+		{Method: http.MethodGet, Path: "/players/{did}/presence", Handler: s.PlayerPresenceHandler},
+		{Method: http.MethodGet, Path: "/leaderboard", Handler: s.LeaderboardHandler},
+		{Method: http.MethodGet, Path: "/leaderboards/{timeControl}", Handler: s.TimeControlLeaderboardHandler},
+
+		// WebSocket endpoint for real-time updates
+		{Path: "/ws", Handler: s.WebSocketHandler(hub)},
+	}
+}
+
+// BotPrefix is where the bot API is served
+const BotPrefix = LegacyPrefix + "/v" + APIVersion + "/bot"
+
+// Bot lists the bot API, for programs playing as bot accounts. Requests
+// authenticate with a bot API token rather than a session.
+func Bot(s *web.Service) []Route {
+	return []Route{
+		{Method: http.MethodGet, Path: "/account", Handler: s.BotAccountHandler},
+		{Method: http.MethodGet, Path: "/events", Handler: s.BotEventsHandler},
+		{Method: http.MethodGet, Path: "/stream/event", Handler: s.BotStreamHandler},
+		{Method: http.MethodPost, Path: "/challenges/accept", Handler: s.AcceptChallengeHandler},
+		{Method: http.MethodPost, Path: "/challenges/decline", Handler: s.DeclineChallengeHandler},
+		{Method: http.MethodGet, Path: "/games/{id}", Handler: s.GetGameHandler},
+		{Method: http.MethodPost, Path: "/games/{id}/move/{move}", Handler: s.BotMoveHandler},
+		{Method: http.MethodPost, Path: "/resign", Handler: s.ResignGameHandler},
+		{Method: http.MethodPost, Path: "/draw-offers/respond", Handler: s.RespondToDrawHandler},
+	}
+}
+
+// Public lists the unauthenticated, read-only API under /public/v1
+func Public(s *web.Service) []Route {
+	return []Route{
+		{Method: http.MethodGet, Path: "/games", Handler: s.PublicGamesHandler},
+		{Method: http.MethodGet, Path: "/explorer", Handler: s.PublicExplorerHandler},
+		{Method: http.MethodGet, Path: "/openings", Handler: s.PublicOpeningsHandler},
+		{Method: http.MethodGet, Path: "/leaderboard", Handler: s.LeaderboardHandler},
+		{Method: http.MethodGet, Path: "/leaderboards/{timeControl}", Handler: s.TimeControlLeaderboardHandler},
+	}
+}
@@ -0,0 +1,104 @@
+// Package routes declares the HTTP API as route tables and mounts them on a
+// router. The registrar applies what every route needs - tracing, request
+// logging, CORS and preflight handling - and the session check for routes
+// that require one, so adding an endpoint is a single table entry.
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/requestid"
+	"github.com/justinabrahms/atchess/internal/tracing"
+	"github.com/justinabrahms/atchess/internal/web"
+)
+
+// Auth says what a route requires of the caller
+type Auth int
+
+const (
+	// Optional routes act as the logged-in user when a session is sent and
+	// are anonymous otherwise, which only lets them read public records
+	Optional Auth = iota
+	// Required routes reject requests without a session with 401
+	Required
+)
+
+// Route is one entry of a route table
+type Route struct {
+	// Method is empty for routes that accept any method, like WebSocket upgrades
+	Method  string
+	Path    string // mux path template, relative to the table's prefix
+	Handler http.HandlerFunc
+	Auth    Auth
+}
+
+// allowedHeaders are the request headers browsers may send cross-origin
+var allowedHeaders = "Content-Type, Authorization, " + web.SessionHeader + ", " + requestid.Header + ", " + web.APIKeyHeader + ", " + VersionHeader
+
+// exposedHeaders are the response headers browsers may read cross-origin
+var exposedHeaders = requestid.Header + ", Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, " + VersionHeader + ", Deprecation, Link"
+
+// Registrar mounts route tables on a router
+type Registrar struct {
+	router         *mux.Router
+	requireSession mux.MiddlewareFunc
+}
+
+// NewRegistrar prepares router for route tables. requireSession guards
+// routes with Required auth; it runs after each table's own middleware, so
+// sessions have been resolved by then.
+func NewRegistrar(router *mux.Router, requireSession mux.MiddlewareFunc) *Registrar {
+	router.Use(tracing.Middleware, requestid.Middleware, cors)
+	return &Registrar{router: router, requireSession: requireSession}
+}
+
+// Register mounts routes under prefix, in order, behind middleware. Every
+// path gets an OPTIONS route for CORS preflight requests.
+func (reg *Registrar) Register(prefix string, routes []Route, middleware ...mux.MiddlewareFunc) {
+	sub := reg.router.NewRoute().Subrouter()
+	if prefix != "" {
+		sub = reg.router.PathPrefix(prefix).Subrouter()
+	}
+	sub.Use(middleware...)
+
+	preflight := make(map[string]bool)
+	for _, route := range routes {
+		var handler http.Handler = route.Handler
+		if route.Auth == Required {
+			handler = reg.requireSession(handler)
+		}
+
+		if route.Method == "" {
+			sub.Handle(route.Path, handler)
+			continue
+		}
+		sub.Handle(route.Path, handler).Methods(route.Method)
+		if !preflight[route.Path] {
+			preflight[route.Path] = true
+			sub.HandleFunc(route.Path, preflightHandler).Methods(http.MethodOptions)
+		}
+	}
+}
+
+// preflightHandler answers OPTIONS requests; cors has set the headers
+func preflightHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// cors allows the API to be called from any origin
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+		w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+
+		if r.Method == http.MethodOptions {
+			preflightHandler(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
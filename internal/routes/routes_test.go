@@ -0,0 +1,190 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/justinabrahms/atchess/internal/web"
+)
+
+// denyAll stands in for a session check that no request passes
+func denyAll(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+}
+
+func serve(router http.Handler, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestRegistrarMountsTables(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	var tagged bool
+	tag := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tagged = true
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	router := mux.NewRouter()
+	NewRegistrar(router, denyAll).Register("/api", []Route{
+		{Method: http.MethodGet, Path: "/games/{id}/pgn", Handler: ok},
+		{Method: http.MethodGet, Path: "/games/{id:.*}", Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		}},
+		{Method: http.MethodPost, Path: "/games/{id}/share", Handler: ok, Auth: Required},
+	}, tag)
+
+	w := serve(router, "GET", "/api/games/abc/pgn")
+	if w.Code != http.StatusOK || !tagged {
+		t.Errorf("Expected the table's middleware and handler to run, got %d", w.Code)
+	}
+	if w.Header().Get("X-Request-ID") == "" || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected request IDs and CORS headers on every route, got %v", w.Header())
+	}
+	if w = serve(router, "GET", "/api/games/abc"); w.Code != http.StatusAccepted {
+		t.Errorf("Expected routes to be matched in table order, got %d", w.Code)
+	}
+	if w = serve(router, "POST", "/api/games/abc/share"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a route requiring a session to be guarded, got %d", w.Code)
+	}
+	w = serve(router, "OPTIONS", "/api/games/abc/share")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Errorf("Expected a CORS preflight response, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestAPITableHasNoDuplicateRoutes(t *testing.T) {
+	service := web.NewService(nil, &config.Config{})
+	seen := make(map[string]bool)
+	for _, route := range API(service, web.NewHub()) {
+		key := route.Method + " " + route.Path
+		if seen[key] {
+			t.Errorf("%s is registered twice", key)
+		}
+		seen[key] = true
+		if route.Handler == nil {
+			t.Errorf("%s has no handler", key)
+		}
+	}
+}
+
+// pathVariable matches a variable in a mux path template
+var pathVariable = regexp.MustCompile(`\{(\w+)(?::([^}]*))?\}`)
+
+// samplePath fills in a path template's variables with values they accept.
+// Catch-all IDs get a record path, since that's what they're there for.
+func samplePath(template string) string {
+	return pathVariable.ReplaceAllStringFunc(template, func(variable string) string {
+		pattern := pathVariable.FindStringSubmatch(variable)[2]
+		switch pattern {
+		case "":
+			return "sample"
+		case ".*":
+			return "did:plc:white/app.atchess.game/1"
+		default:
+			return strings.Split(pattern, "|")[0]
+		}
+	})
+}
+
+func TestAPIRoutesAreNotShadowed(t *testing.T) {
+	service := web.NewService(nil, &config.Config{})
+	table := API(service, web.NewHub())
+	router := mux.NewRouter()
+	for i, route := range table {
+		r := router.Handle(route.Path, route.Handler).Name(strconv.Itoa(i))
+		if route.Method != "" {
+			r.Methods(route.Method)
+		}
+	}
+
+	for i, route := range table {
+		method := route.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+		var match mux.RouteMatch
+		if !router.Match(httptest.NewRequest(method, samplePath(route.Path), nil), &match) {
+			t.Errorf("%s %s doesn't match its own path", method, route.Path)
+			continue
+		}
+		if name := match.Route.GetName(); name != strconv.Itoa(i) {
+			winner, _ := strconv.Atoi(name)
+			t.Errorf("%s %s is shadowed by %s, which comes first", method, route.Path, table[winner].Path)
+		}
+	}
+}
+
+// anonymousWrites are the API routes that change state without a session:
+// signing in and out, and stateless or public-only endpoints
+var anonymousWrites = map[string]bool{
+	"POST /auth/login":                    true,
+	"POST /auth/app-password":             true,
+	"POST /auth/oauth/login":              true,
+	"POST /auth/logout":                   true,
+	"POST /legal-moves":                   true,
+	"POST /puzzles/{id}/attempt":          true,
+	"POST /federation/hello":              true,
+	"POST /spectator/games/{id:.*}/count": true,
+}
+
+func TestAPIWritesRequireASession(t *testing.T) {
+	service := web.NewService(nil, &config.Config{})
+	table := API(service, web.NewHub())
+	for _, route := range table {
+		key := route.Method + " " + route.Path
+		if route.Method != http.MethodGet && route.Method != "" && route.Auth != Required && !anonymousWrites[key] {
+			t.Errorf("%s writes without requiring a session", key)
+		}
+	}
+
+	router := mux.NewRouter()
+	NewRegistrar(router, service.RequireSession).Register(LegacyPrefix, table, service.SessionMiddleware)
+	req := httptest.NewRequest("POST", "/api/moves", strings.NewReader(`{"gameId":"at://did:plc:white/app.atchess.game/1","from":"e2","to":"e4"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a move without a session to be rejected with 401, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestVersionedAndLegacyPaths(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	table := []Route{{Method: http.MethodGet, Path: "/games", Handler: ok}}
+	router := mux.NewRouter()
+	registrar := NewRegistrar(router, denyAll)
+	registrar.Register(VersionPrefix(APIVersion), table, Version(APIVersion))
+	registrar.Register(LegacyPrefix, table, Deprecated)
+
+	w := serve(router, "GET", "/api/v1/games")
+	if w.Code != http.StatusOK || w.Header().Get(VersionHeader) != "1" || w.Header().Get("Deprecation") != "" {
+		t.Errorf("Expected the versioned path to be served as v1, got %d %v", w.Code, w.Header())
+	}
+
+	w = serve(router, "GET", "/api/games")
+	if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "true" {
+		t.Errorf("Expected the legacy path to be served but deprecated, got %d %v", w.Code, w.Header())
+	}
+	if link := w.Header().Get("Link"); link != `</api/v1/games>; rel="successor-version"` {
+		t.Errorf("Expected a link to the versioned path, got %q", link)
+	}
+
+	req := httptest.NewRequest("GET", "/api/games", nil)
+	req.Header.Set(VersionHeader, "2")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unsupported_version") {
+		t.Errorf("Expected an unknown version to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,67 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+)
+
+// APIVersion is the current version of the API, served under /api/v1
+const APIVersion = "1"
+
+// VersionHeader names the API version a request asks for and a response was
+// served with. Requests to the unversioned /api aliases may send it to pin
+// the version they were written against.
+const VersionHeader = "X-API-Version"
+
+// LegacyPrefix is where the API was served before it was versioned. Its
+// paths remain as deprecated aliases of the current version.
+const LegacyPrefix = "/api"
+
+// VersionPrefix returns the path prefix of an API version, e.g. "/api/v1"
+func VersionPrefix(version string) string {
+	return LegacyPrefix + "/v" + version
+}
+
+// supportedVersions are the API versions this server can serve
+var supportedVersions = map[string]bool{APIVersion: true}
+
+// Version marks responses with the API version a table is served as, and
+// rejects requests that ask for a different one.
+func Version(version string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requested := r.Header.Get(VersionHeader); requested != "" && requested != version {
+				apierror.Write(w, apierror.ErrUnsupportedVersion.WithMessage(
+					"This endpoint serves API version "+version+", not "+requested))
+				return
+			}
+			w.Header().Set(VersionHeader, version)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Deprecated serves the unversioned /api aliases. They answer as the version
+// a request asks for in VersionHeader, defaulting to the current one, and
+// point clients at the versioned path with Deprecation and Link headers.
+func Deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := r.Header.Get(VersionHeader)
+		if version == "" {
+			version = APIVersion
+		}
+		if !supportedVersions[version] {
+			apierror.Write(w, apierror.ErrUnsupportedVersion.WithMessage("Unsupported API version "+version))
+			return
+		}
+
+		successor := VersionPrefix(version) + strings.TrimPrefix(r.URL.Path, LegacyPrefix)
+		w.Header().Set(VersionHeader, version)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+		next.ServeHTTP(w, r)
+	})
+}
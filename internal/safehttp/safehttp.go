@@ -0,0 +1,78 @@
+// Package safehttp makes requests to URLs players give us, such as webhooks
+// and push endpoints, without letting them reach the server's own network.
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for hosts on loopback, private or link-local
+// addresses
+var ErrPrivateAddress = errors.New("address is private")
+
+// Resolver looks up a host's addresses; net.DefaultResolver is one
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// IsPrivate reports whether ip is loopback, private, link-local or unspecified
+func IsPrivate(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// CheckHost returns ErrPrivateAddress if host is, or resolves to, a private
+// address. It's for refusing URLs when they're registered; the host may
+// resolve elsewhere later, so requests still need Client.
+func CheckHost(ctx context.Context, resolver Resolver, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if IsPrivate(ip) {
+			return ErrPrivateAddress
+		}
+		return nil
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if IsPrivate(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// Client returns an HTTP client that refuses to connect to private
+// addresses, checked after DNS resolution, and doesn't follow redirects
+func Client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || IsPrivate(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &http.Client{
+		Transport: transport,
+		// Redirects could lead anywhere; treat them as failures
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
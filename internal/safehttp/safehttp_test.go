@@ -0,0 +1,56 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// staticResolver resolves every host to the same addresses
+type staticResolver []string
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	var addrs []net.IPAddr
+	for _, ip := range r {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func TestCheckHost(t *testing.T) {
+	ctx := context.Background()
+	public := staticResolver{"93.184.216.34"}
+	cases := []struct {
+		host     string
+		resolver Resolver
+		want     error
+	}{
+		{"93.184.216.34", public, nil},
+		{"127.0.0.1", public, ErrPrivateAddress},
+		{"::1", public, ErrPrivateAddress},
+		{"169.254.169.254", public, ErrPrivateAddress},
+		{"push.example.com", public, nil},
+		{"internal.example.com", staticResolver{"93.184.216.34", "10.0.0.5"}, ErrPrivateAddress},
+	}
+	for _, c := range cases {
+		if err := CheckHost(ctx, c.resolver, c.host); !errors.Is(err, c.want) {
+			t.Errorf("CheckHost(%q): expected %v, got %v", c.host, c.want, err)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	_, err := Client().Get(server.URL)
+	if !errors.Is(err, ErrPrivateAddress) || called {
+		t.Errorf("Expected the loopback server not to be called, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store held in memory. Jobs are lost on restart, though
+// recurring ones are scheduled again at startup.
+type MemoryStore struct {
+	jobs map[string]*Job
+	mu   sync.Mutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]*Job)}
+}
+
+// Put stores a job, replacing any with the same ID
+func (m *MemoryStore) Put(ctx context.Context, job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *job
+	m.jobs[job.ID] = &stored
+	return nil
+}
+
+// Get returns the job with an ID, or nil if there's none
+func (m *MemoryStore) Get(ctx context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *job
+	return &found, nil
+}
+
+// Delete removes a job
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.jobs, id)
+	return nil
+}
+
+// Due returns up to limit jobs due at or before now, soonest first
+func (m *MemoryStore) Due(ctx context.Context, now time.Time, limit int) ([]*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []*Job
+	for _, job := range m.jobs {
+		if !job.Due.After(now) {
+			found := *job
+			due = append(due, &found)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].Due.Equal(due[j].Due) {
+			return due[i].Due.Before(due[j].Due)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// Close does nothing
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,251 @@
+// Package scheduler runs jobs at set times, such as finalizing a game whose
+// clock has run out. Jobs are kept in a Store, so with persistent storage
+// they survive restarts and run late rather than not at all.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Defaults for Options left at zero
+const (
+	DefaultPollInterval  = 30 * time.Second
+	DefaultRetryDelay    = time.Minute
+	DefaultMaxRetryDelay = time.Hour
+	DefaultMaxAttempts   = 10
+)
+
+// dueBatch is how many due jobs are run per pass
+const dueBatch = 100
+
+// ErrUnknownKind is returned when scheduling a job no handler runs
+var ErrUnknownKind = errors.New("no handler for job kind")
+
+// Job is something to do at a time. There is at most one job per ID;
+// scheduling a job again replaces it.
+type Job struct {
+	ID       string    `json:"id"`
+	Kind     string    `json:"kind"`
+	Subject  string    `json:"subject"` // what the job acts on, e.g. a game URI
+	Due      time.Time `json:"due"`
+	Attempts int       `json:"attempts"` // failed runs so far
+}
+
+// Handler runs a job. It returns when the job should run again, or the zero
+// time once it's done. Jobs that fail are retried with backoff.
+type Handler func(ctx context.Context, job *Job) (next time.Time, err error)
+
+// Store keeps scheduled jobs
+type Store interface {
+	// Put stores a job, replacing any with the same ID
+	Put(ctx context.Context, job *Job) error
+	// Get returns the job with an ID, or nil if there's none
+	Get(ctx context.Context, id string) (*Job, error)
+	// Delete removes a job, if it exists
+	Delete(ctx context.Context, id string) error
+	// Due returns up to limit jobs due at or before now, soonest first
+	Due(ctx context.Context, now time.Time, limit int) ([]*Job, error)
+	Close() error
+}
+
+// Options configure a scheduler
+type Options struct {
+	// PollInterval is how often the store is checked for due jobs; jobs
+	// scheduled sooner wake the scheduler early
+	PollInterval time.Duration
+	// RetryDelay is the wait before retrying a failed job, doubling with
+	// each failure up to MaxRetryDelay
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// MaxAttempts is how many times a job may fail before it's dropped
+	MaxAttempts int
+}
+
+// Scheduler runs jobs from a store when they fall due
+type Scheduler struct {
+	store   Store
+	options Options
+	now     func() time.Time
+	wake    chan struct{}
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// New creates a scheduler running jobs kept in store
+func New(store Store, options Options) *Scheduler {
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = DefaultRetryDelay
+	}
+	if options.MaxRetryDelay <= 0 {
+		options.MaxRetryDelay = DefaultMaxRetryDelay
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = DefaultMaxAttempts
+	}
+	return &Scheduler{
+		store:    store,
+		options:  options,
+		now:      time.Now,
+		wake:     make(chan struct{}, 1),
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Handle registers the handler for jobs of a kind
+func (s *Scheduler) Handle(kind string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[kind] = handler
+}
+
+func (s *Scheduler) handler(kind string) Handler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.handlers[kind]
+}
+
+// Schedule runs a job of a registered kind at due, replacing any job with
+// the same ID
+func (s *Scheduler) Schedule(ctx context.Context, id, kind, subject string, due time.Time) error {
+	if s.handler(kind) == nil {
+		return fmt.Errorf("%w %q", ErrUnknownKind, kind)
+	}
+	if err := s.store.Put(ctx, &Job{ID: id, Kind: kind, Subject: subject, Due: due.UTC()}); err != nil {
+		return err
+	}
+	if !due.After(s.now()) {
+		s.Wake()
+	}
+	return nil
+}
+
+// ScheduleIfAbsent schedules a job unless one with the same ID is already
+// waiting, e.g. a recurring job carried over from before a restart
+func (s *Scheduler) ScheduleIfAbsent(ctx context.Context, id, kind, subject string, due time.Time) error {
+	existing, err := s.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+	return s.Schedule(ctx, id, kind, subject, due)
+}
+
+// Cancel removes a job
+func (s *Scheduler) Cancel(ctx context.Context, id string) error {
+	return s.store.Delete(ctx, id)
+}
+
+// Job returns a scheduled job, or nil if there's none with the ID
+func (s *Scheduler) Job(ctx context.Context, id string) (*Job, error) {
+	return s.store.Get(ctx, id)
+}
+
+// Wake makes a running scheduler check for due jobs now
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs due jobs until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.RunDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// RunDue runs the jobs due now, returning how many ran
+func (s *Scheduler) RunDue(ctx context.Context) int {
+	ran := 0
+	for ctx.Err() == nil {
+		jobs, err := s.store.Due(ctx, s.now(), dueBatch)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to load due jobs")
+			return ran
+		}
+		for _, job := range jobs {
+			s.run(ctx, job)
+		}
+		ran += len(jobs)
+		if len(jobs) < dueBatch {
+			return ran
+		}
+	}
+	return ran
+}
+
+// run runs one job and stores what's next for it
+func (s *Scheduler) run(ctx context.Context, job *Job) {
+	logger := log.With().Str("job", job.ID).Str("kind", job.Kind).Logger()
+
+	handler := s.handler(job.Kind)
+	if handler == nil {
+		logger.Warn().Msg("Dropping job with no handler")
+		s.delete(ctx, job)
+		return
+	}
+
+	next, err := handler(ctx, job)
+	if err != nil {
+		job.Attempts++
+		if job.Attempts >= s.options.MaxAttempts {
+			logger.Error().Err(err).Int("attempts", job.Attempts).Msg("Dropping job after repeated failures")
+			s.delete(ctx, job)
+			return
+		}
+		delay := s.options.RetryDelay << (job.Attempts - 1)
+		if delay <= 0 || delay > s.options.MaxRetryDelay {
+			delay = s.options.MaxRetryDelay
+		}
+		logger.Warn().Err(err).Int("attempts", job.Attempts).Dur("retryIn", delay).Msg("Job failed")
+		next = s.now().Add(delay)
+	} else if next.IsZero() {
+		s.delete(ctx, job)
+		return
+	} else {
+		job.Attempts = 0
+	}
+
+	// A job the handler rescheduled itself, e.g. with a new subject, wins
+	current, getErr := s.store.Get(ctx, job.ID)
+	if getErr == nil && current != nil && (!current.Due.Equal(job.Due) || current.Kind != job.Kind) {
+		return
+	}
+	job.Due = next.UTC()
+	if err := s.store.Put(ctx, job); err != nil {
+		logger.Error().Err(err).Msg("Failed to reschedule job")
+	}
+}
+
+func (s *Scheduler) delete(ctx context.Context, job *Job) {
+	// Keep the job if the handler replaced it while running
+	current, err := s.store.Get(ctx, job.ID)
+	if err == nil && current != nil && (!current.Due.Equal(job.Due) || current.Kind != job.Kind) {
+		return
+	}
+	if err := s.store.Delete(ctx, job.ID); err != nil {
+		log.Error().Err(err).Str("job", job.ID).Msg("Failed to delete finished job")
+	}
+}
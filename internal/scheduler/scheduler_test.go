@@ -0,0 +1,175 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite opens a SQL store on a SQLite database in dir
+func openSQLite(t *testing.T, dir string) *SQLStore {
+	store, err := OpenSQLStore(context.Background(), "sqlite", filepath.Join(dir, "jobs.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// forEachStore runs a test against every kind of store: in memory, and
+// SQLite in a temporary file
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, openSQLite(t, t.TempDir()))
+	})
+}
+
+// testScheduler returns a scheduler over store whose clock is set by the
+// returned func
+func testScheduler(store Store) (*Scheduler, func(time.Time)) {
+	sched := New(store, Options{RetryDelay: time.Minute, MaxRetryDelay: 4 * time.Minute, MaxAttempts: 3})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sched.now = func() time.Time { return now }
+	return sched, func(t time.Time) { now = t }
+}
+
+func TestSchedulerRunsJobsWhenDue(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		sched, setNow := testScheduler(store)
+		start := sched.now()
+
+		var ran []string
+		sched.Handle("clock", func(ctx context.Context, job *Job) (time.Time, error) {
+			ran = append(ran, job.Subject)
+			return time.Time{}, nil
+		})
+		if err := sched.Schedule(ctx, "clock:b", "clock", "game-b", start.Add(2*time.Hour)); err != nil {
+			t.Fatalf("Failed to schedule: %v", err)
+		}
+		if err := sched.Schedule(ctx, "clock:a", "clock", "game-a", start.Add(time.Hour)); err != nil {
+			t.Fatalf("Failed to schedule: %v", err)
+		}
+		if err := sched.Schedule(ctx, "nothing", "unknown", "", start); !errors.Is(err, ErrUnknownKind) {
+			t.Errorf("Expected ErrUnknownKind, got %v", err)
+		}
+
+		if n := sched.RunDue(ctx); n != 0 {
+			t.Errorf("Expected nothing due yet, ran %d", n)
+		}
+		setNow(start.Add(3 * time.Hour))
+		if n := sched.RunDue(ctx); n != 2 || len(ran) != 2 || ran[0] != "game-a" || ran[1] != "game-b" {
+			t.Errorf("Expected both games in due order, ran %d: %v", n, ran)
+		}
+		if job, _ := store.Get(ctx, "clock:a"); job != nil {
+			t.Errorf("Expected finished job removed, got %+v", job)
+		}
+
+		// Scheduling again replaces a job
+		sched.Schedule(ctx, "clock:a", "clock", "game-a", start.Add(5*time.Hour))
+		sched.Schedule(ctx, "clock:a", "clock", "game-a2", start.Add(4*time.Hour))
+		setNow(start.Add(6 * time.Hour))
+		ran = nil
+		if sched.RunDue(ctx); len(ran) != 1 || ran[0] != "game-a2" {
+			t.Errorf("Expected the replacement to run once, ran %v", ran)
+		}
+	})
+}
+
+func TestSchedulerReschedulesAndRetries(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		sched, setNow := testScheduler(store)
+		start := sched.now()
+
+		// A recurring job runs again when its handler says
+		runs := 0
+		sched.Handle("sweep", func(ctx context.Context, job *Job) (time.Time, error) {
+			runs++
+			return sched.now().Add(10 * time.Minute), nil
+		})
+		sched.ScheduleIfAbsent(ctx, "sweep", "sweep", "", start)
+		sched.ScheduleIfAbsent(ctx, "sweep", "sweep", "", start.Add(time.Hour))
+		sched.RunDue(ctx)
+		if job, _ := store.Get(ctx, "sweep"); runs != 1 || job == nil || !job.Due.Equal(start.Add(10*time.Minute)) {
+			t.Errorf("Expected the sweep to run once and come back in 10m, ran %d: %+v", runs, job)
+		}
+
+		// A failing job backs off, then is dropped
+		fails := 0
+		sched.Handle("flaky", func(ctx context.Context, job *Job) (time.Time, error) {
+			fails++
+			return time.Time{}, errors.New("PDS unavailable")
+		})
+		sched.Schedule(ctx, "flaky", "flaky", "", start)
+		sched.RunDue(ctx)
+		job, _ := store.Get(ctx, "flaky")
+		if job == nil || job.Attempts != 1 || !job.Due.Equal(start.Add(time.Minute)) {
+			t.Fatalf("Expected a retry in 1m, got %+v", job)
+		}
+		setNow(start.Add(time.Minute))
+		sched.RunDue(ctx)
+		if job, _ := store.Get(ctx, "flaky"); job == nil || job.Attempts != 2 || !job.Due.Equal(start.Add(3*time.Minute)) {
+			t.Fatalf("Expected a retry 2m later, got %+v", job)
+		}
+		setNow(start.Add(3 * time.Minute))
+		sched.RunDue(ctx)
+		if job, _ := store.Get(ctx, "flaky"); fails != 3 || job != nil {
+			t.Errorf("Expected the job dropped after 3 failures, failed %d: %+v", fails, job)
+		}
+	})
+}
+
+func TestSchedulerRunWakesForDueJobs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sched := New(NewMemoryStore(), Options{PollInterval: time.Hour})
+
+	done := make(chan string, 1)
+	sched.Handle("clock", func(ctx context.Context, job *Job) (time.Time, error) {
+		done <- job.Subject
+		return time.Time{}, nil
+	})
+	go sched.Run(ctx)
+
+	sched.Schedule(ctx, "clock:g1", "clock", "g1", time.Now())
+	select {
+	case subject := <-done:
+		if subject != "g1" {
+			t.Errorf("Expected g1, got %s", subject)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the due job to run without waiting for the poll")
+	}
+}
+
+func TestSQLStoreKeepsJobsAcrossRestarts(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	due := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	sched, _ := testScheduler(openSQLite(t, dir))
+	sched.Handle("clock", func(ctx context.Context, job *Job) (time.Time, error) {
+		return time.Time{}, nil
+	})
+	if err := sched.Schedule(ctx, "clock:g1", "clock", "g1", due); err != nil {
+		t.Fatalf("Failed to schedule: %v", err)
+	}
+
+	reopened := openSQLite(t, dir)
+	job, err := reopened.Get(ctx, "clock:g1")
+	if err != nil || job == nil || job.Kind != "clock" || job.Subject != "g1" || !job.Due.Equal(due) {
+		t.Fatalf("Expected the job after reopening, got %+v, %v", job, err)
+	}
+	jobs, err := reopened.Due(ctx, due, 10)
+	if err != nil || len(jobs) != 1 || jobs[0].ID != "clock:g1" {
+		t.Errorf("Expected the job due, got %+v, %v", jobs, err)
+	}
+}
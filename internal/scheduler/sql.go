@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/sqlstore"
+)
+
+// sqlSchema is portable between SQLite and Postgres. Due times are stored as
+// Unix nanoseconds so both order them the same.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS scheduled_jobs (
+		id TEXT PRIMARY KEY,
+		kind TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		due_at BIGINT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS scheduled_jobs_due ON scheduled_jobs (due_at)`,
+}
+
+// SQLStore is a Store backed by database/sql, so jobs survive restarts. The
+// driver must be linked into the binary, e.g. with a blank import of a
+// SQLite or Postgres driver.
+type SQLStore struct {
+	db *sqlstore.DB
+}
+
+// OpenSQLStore connects to the database and creates the table if needed
+func OpenSQLStore(ctx context.Context, driver, dsn string) (*SQLStore, error) {
+	db, err := sqlstore.Open(ctx, "scheduler", driver, dsn, sqlSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Put stores a job, replacing any with the same ID
+func (s *SQLStore) Put(ctx context.Context, job *Job) error {
+	_, err := s.db.ExecContext(ctx, s.db.Rebind(`
+		INSERT INTO scheduled_jobs (id, kind, subject, due_at, attempts) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET kind = excluded.kind, subject = excluded.subject,
+			due_at = excluded.due_at, attempts = excluded.attempts`),
+		job.ID, job.Kind, job.Subject, job.Due.UnixNano(), job.Attempts)
+	if err != nil {
+		return fmt.Errorf("failed to store job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// Get returns the job with an ID, or nil if there's none
+func (s *SQLStore) Get(ctx context.Context, id string) (*Job, error) {
+	row := s.db.QueryRowContext(ctx, s.db.Rebind(`
+		SELECT id, kind, subject, due_at, attempts FROM scheduled_jobs WHERE id = ?`), id)
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job %s: %w", id, err)
+	}
+	return job, nil
+}
+
+// Delete removes a job
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, s.db.Rebind(`DELETE FROM scheduled_jobs WHERE id = ?`), id); err != nil {
+		return fmt.Errorf("failed to delete job %s: %w", id, err)
+	}
+	return nil
+}
+
+// Due returns up to limit jobs due at or before now, soonest first
+func (s *SQLStore) Due(ctx context.Context, now time.Time, limit int) ([]*Job, error) {
+	rows, err := s.db.QueryContext(ctx, s.db.Rebind(`
+		SELECT id, kind, subject, due_at, attempts FROM scheduled_jobs
+		WHERE due_at <= ? ORDER BY due_at, id LIMIT ?`), now.UnixNano(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read due job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Close closes the database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row scanner) (*Job, error) {
+	var job Job
+	var due int64
+	if err := row.Scan(&job.ID, &job.Kind, &job.Subject, &due, &job.Attempts); err != nil {
+		return nil, err
+	}
+	job.Due = time.Unix(0, due).UTC()
+	return &job, nil
+}
@@ -0,0 +1,106 @@
+// Package secrets looks up passwords and keys kept outside the configuration,
+// so they needn't sit in config files or plain environment variables.
+// Configuration values written as references, like
+// file:///run/secrets/pds_password or vault://secret/atchess#password, are
+// replaced by the secret they name.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned when a reference names a secret that doesn't exist
+var ErrNotFound = errors.New("secret not found")
+
+// Provider fetches secrets from one kind of store
+type Provider interface {
+	// Get returns the secret ref names; ref is the reference after scheme://
+	Get(ctx context.Context, ref string) (string, error)
+}
+
+// ProviderFunc adapts a function to a Provider
+type ProviderFunc func(ctx context.Context, ref string) (string, error)
+
+func (f ProviderFunc) Get(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// Resolver replaces references with the secrets they name, choosing the
+// provider by the reference's scheme
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver returns a resolver for the built-in schemes:
+//
+//	file://PATH                  the file's contents, e.g. a Docker secret
+//	env://NAME                   an environment variable
+//	vault://MOUNT/PATH#FIELD     a field of a HashiCorp Vault KV v2 secret
+//	awssm://SECRET-ID[#KEY]      an AWS Secrets Manager secret, or a key of
+//	                             one holding JSON
+//
+// Vault and AWS are configured by their usual environment variables and
+// only need to be when they're used.
+func NewResolver() *Resolver {
+	r := &Resolver{providers: make(map[string]Provider)}
+	r.Register("file", ProviderFunc(readFile))
+	r.Register("env", ProviderFunc(lookupEnv))
+	r.Register("vault", VaultFromEnv())
+	r.Register("awssm", AWSFromEnv())
+	return r
+}
+
+// Register adds or replaces the provider for scheme
+func (r *Resolver) Register(scheme string, provider Provider) {
+	r.providers[scheme] = provider
+}
+
+// Resolve returns the secret value names if it's a reference, or value
+// itself if it isn't
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+	provider, ok := r.providers[scheme]
+	if !ok {
+		return value, nil
+	}
+	secret, err := provider.Get(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("%s secret %q: %w", scheme, ref, err)
+	}
+	return secret, nil
+}
+
+// IsReference reports whether value is a reference this resolver would look up
+func (r *Resolver) IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	_, known := r.providers[scheme]
+	return ok && known
+}
+
+// readFile returns a file's contents without the trailing newline editors
+// and echo leave
+func readFile(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func lookupEnv(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
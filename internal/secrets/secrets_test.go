@@ -0,0 +1,118 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolver_FileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pds_password")
+	if err := os.WriteFile(path, []byte("hunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ATCHESS_TEST_SECRET", "s3cret")
+	r := NewResolver()
+	ctx := context.Background()
+
+	for value, want := range map[string]string{
+		"file://" + path:            "hunter2",
+		"env://ATCHESS_TEST_SECRET": "s3cret",
+		"plain-password":            "plain-password",
+		"redis://localhost:6379/0":  "redis://localhost:6379/0",
+	} {
+		got, err := r.Resolve(ctx, value)
+		if err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+
+	if _, err := r.Resolve(ctx, "file://"+path+"-missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing file, got %v", err)
+	}
+	if !r.IsReference("env://X") || r.IsReference("redis://localhost") {
+		t.Error("Expected only known schemes to be references")
+	}
+}
+
+func TestVault_Get(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/atchess/prod" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"pds_password":"from-vault"},"metadata":{"version":3}}}`))
+	}))
+	defer server.Close()
+
+	r := NewResolver()
+	r.Register("vault", &Vault{Addr: server.URL, Token: "root"})
+	got, err := r.Resolve(context.Background(), "vault://secret/atchess/prod#pds_password")
+	if err != nil || got != "from-vault" {
+		t.Fatalf("Expected the Vault secret, got %q, %v", got, err)
+	}
+	if _, err := r.Resolve(context.Background(), "vault://secret/atchess/prod#other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing field, got %v", err)
+	}
+	if _, err := (&Vault{}).Get(context.Background(), "secret/x#y"); err == nil || !strings.Contains(err.Error(), "VAULT_ADDR") {
+		t.Errorf("Expected an error naming VAULT_ADDR, got %v", err)
+	}
+}
+
+func TestAWS_Get(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var req struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.SecretId != "atchess/production" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`))
+			return
+		}
+		w.Write([]byte(`{"Name":"atchess/production","SecretString":"{\"pds_password\":\"from-aws\"}"}`))
+	}))
+	defer server.Close()
+
+	provider := &AWS{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret", Endpoint: server.URL}
+	got, err := provider.Get(context.Background(), "atchess/production#pds_password")
+	if err != nil || got != "from-aws" {
+		t.Fatalf("Expected the key from the JSON secret, got %q, %v", got, err)
+	}
+	got, err = provider.Get(context.Background(), "atchess/production")
+	if err != nil || got != `{"pds_password":"from-aws"}` {
+		t.Errorf("Expected the whole secret, got %q, %v", got, err)
+	}
+	if _, err := provider.Get(context.Background(), "atchess/staging"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing secret, got %v", err)
+	}
+}
+
+func TestAWS_SignMatchesReferenceExample(t *testing.T) {
+	// The worked example from AWS's Signature Version 4 documentation
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	provider := &AWS{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	provider.sign(req, nil, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Signature doesn't match the example:\n got %s\nwant %s", got, want)
+	}
+}
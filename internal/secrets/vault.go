@@ -0,0 +1,90 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds a request to a secrets manager
+const requestTimeout = 10 * time.Second
+
+// Vault reads secrets from a HashiCorp Vault KV version 2 secrets engine.
+// References are the engine's mount, the secret's path and the field
+// wanted, e.g. secret/atchess#pds_password.
+type Vault struct {
+	Addr  string
+	Token string
+	// Namespace is the Vault Enterprise namespace, if any
+	Namespace  string
+	HTTPClient *http.Client
+}
+
+// VaultFromEnv configures Vault from VAULT_ADDR, VAULT_TOKEN and
+// VAULT_NAMESPACE, as the vault command does
+func VaultFromEnv() *Vault {
+	return &Vault{
+		Addr:      os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+	}
+}
+
+func (v *Vault) Get(ctx context.Context, ref string) (string, error) {
+	if v.Addr == "" || v.Token == "" {
+		return "", errors.New("VAULT_ADDR and VAULT_TOKEN must be set to read from Vault")
+	}
+	secretPath, field, _ := strings.Cut(ref, "#")
+	mount, path, ok := strings.Cut(secretPath, "/")
+	if !ok || path == "" || field == "" {
+		return "", errors.New("expected vault://MOUNT/PATH#FIELD")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	endpoint := strings.TrimSuffix(v.Addr, "/") + "/v1/" + url.PathEscape(mount) + "/data/" + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+
+	client := v.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+	value, ok := body.Data.Data[field].(string)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
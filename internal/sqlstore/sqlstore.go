@@ -0,0 +1,59 @@
+// Package sqlstore holds what the database/sql stores have in common:
+// connecting and creating their schema, and writing each query once for both
+// SQLite and Postgres. Drivers must be linked into the binary, e.g. with a
+// blank import of a SQLite or Postgres driver.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DB is a database whose queries use ? placeholders whatever the driver
+type DB struct {
+	*sql.DB
+	postgres bool
+}
+
+// Open connects to the database and runs the schema statements, which should
+// create their tables and indexes only if they don't exist. name says which
+// store the database is for in errors.
+func Open(ctx context.Context, name, driver, dsn string, schema []string) (*DB, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", name, err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s database: %w", name, err)
+	}
+
+	for _, stmt := range schema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create %s schema: %w", name, err)
+		}
+	}
+	return &DB{DB: db, postgres: driver == "postgres" || driver == "pgx"}, nil
+}
+
+// Rebind rewrites ? placeholders as $1, $2... for Postgres
+func (db *DB) Rebind(query string) string {
+	if !db.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
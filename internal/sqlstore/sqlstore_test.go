@@ -0,0 +1,48 @@
+package sqlstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestRebind(t *testing.T) {
+	query := `SELECT a FROM t WHERE b = ? AND c > ?`
+	if got := (&DB{}).Rebind(query); got != query {
+		t.Errorf("Expected SQLite queries unchanged, got %q", got)
+	}
+	if got := (&DB{postgres: true}).Rebind(query); got != `SELECT a FROM t WHERE b = $1 AND c > $2` {
+		t.Errorf("Expected numbered placeholders for Postgres, got %q", got)
+	}
+}
+
+func TestOpenCreatesTheSchemaOnce(t *testing.T) {
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	schema := []string{`CREATE TABLE IF NOT EXISTS things (id TEXT PRIMARY KEY)`}
+
+	db, err := Open(ctx, "test", "sqlite", dsn, schema)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, db.Rebind(`INSERT INTO things (id) VALUES (?)`), "a"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	db.Close()
+
+	db, err = Open(ctx, "test", "sqlite", dsn, schema)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM things`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("Expected the row kept, got %d (%v)", n, err)
+	}
+
+	if _, err := Open(ctx, "test", "sqlite", dsn, []string{`NOT SQL`}); err == nil {
+		t.Error("Expected a bad schema to be reported")
+	}
+}
@@ -0,0 +1,168 @@
+package study
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNotConnected is returned by a Store that has no way to write to a
+// study owner's repository right now; the study is saved later
+var ErrNotConnected = errors.New("owner is not connected")
+
+// Store reads and writes study records
+type Store interface {
+	LoadStudy(ctx context.Context, uri string) (*Study, error)
+	SaveStudy(ctx context.Context, study *Study) error
+}
+
+// EditFunc is told about every edit made to a study
+type EditFunc func(study *Study, did string, edit *Edit)
+
+// Studies keeps the studies being edited, so edits from several players are
+// applied one at a time in the order they arrive. Each edit is saved to the
+// owner's repository, or kept until the owner logs in if it can't be.
+type Studies struct {
+	store  Store
+	onEdit EditFunc
+
+	mu   sync.Mutex
+	live map[string]*liveStudy
+}
+
+// liveStudy is a study being edited
+type liveStudy struct {
+	mu      sync.Mutex
+	study   *Study
+	pending bool // has edits that haven't been saved
+}
+
+// NewStudies creates an empty set of live studies backed by store
+func NewStudies(store Store) *Studies {
+	return &Studies{store: store, live: make(map[string]*liveStudy)}
+}
+
+// OnEdit registers fn to be told about every edit
+func (s *Studies) OnEdit(fn EditFunc) {
+	s.onEdit = fn
+}
+
+// Get returns a study, including edits not yet saved
+func (s *Studies) Get(ctx context.Context, uri string) (*Study, error) {
+	s.mu.Lock()
+	live, ok := s.live[uri]
+	s.mu.Unlock()
+	if !ok {
+		return s.store.LoadStudy(ctx, uri)
+	}
+
+	live.mu.Lock()
+	defer live.mu.Unlock()
+	if live.study == nil {
+		return s.store.LoadStudy(ctx, uri)
+	}
+	return live.study.Clone(), nil
+}
+
+// Edit applies an edit to a study on behalf of did and saves it, returning
+// the study as edited. An edit that's applied but can't be saved yet because
+// the owner isn't connected is kept and saved by SavePending.
+func (s *Studies) Edit(ctx context.Context, uri, did string, edit *Edit) (*Study, error) {
+	live, err := s.load(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer live.mu.Unlock()
+
+	if err := live.study.Apply(did, edit); err != nil {
+		return nil, err
+	}
+	live.study.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	edited := live.study.Clone()
+	if s.onEdit != nil {
+		s.onEdit(edited, did, edit)
+	}
+
+	s.save(ctx, live)
+	return edited, nil
+}
+
+// Forget drops a deleted study
+func (s *Studies) Forget(uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.live, uri)
+}
+
+// SavePending saves the studies owned by did that have edits an earlier
+// attempt couldn't save, e.g. when the owner logs in after others edited
+func (s *Studies) SavePending(ctx context.Context, did string) {
+	s.mu.Lock()
+	var owned []*liveStudy
+	for _, live := range s.live {
+		owned = append(owned, live)
+	}
+	s.mu.Unlock()
+
+	for _, live := range owned {
+		live.mu.Lock()
+		if live.pending && live.study.Owner == did {
+			s.save(ctx, live)
+		}
+		live.mu.Unlock()
+	}
+}
+
+// load returns the live copy of a study, loading it if nobody is editing
+// it. live.mu is held on return.
+func (s *Studies) load(ctx context.Context, uri string) (*liveStudy, error) {
+	for {
+		s.mu.Lock()
+		live, ok := s.live[uri]
+		if !ok {
+			live = &liveStudy{}
+			s.live[uri] = live
+		}
+		s.mu.Unlock()
+
+		live.mu.Lock()
+		if live.study != nil {
+			return live, nil
+		}
+		s.mu.Lock()
+		current := s.live[uri] == live
+		s.mu.Unlock()
+		if !current {
+			// An earlier load failed and dropped it
+			live.mu.Unlock()
+			continue
+		}
+
+		study, err := s.store.LoadStudy(ctx, uri)
+		if err != nil {
+			s.mu.Lock()
+			delete(s.live, uri)
+			s.mu.Unlock()
+			live.mu.Unlock()
+			return nil, err
+		}
+		live.study = study
+		return live, nil
+	}
+}
+
+// save writes a live study to its owner's repository; live.mu is held
+func (s *Studies) save(ctx context.Context, live *liveStudy) {
+	err := s.store.SaveStudy(ctx, live.study.Clone())
+	if err != nil {
+		live.pending = true
+		if !errors.Is(err, ErrNotConnected) {
+			log.Warn().Err(err).Str("study", live.study.URI).Msg("Failed to save study")
+		}
+		return
+	}
+	live.pending = false
+}
@@ -0,0 +1,390 @@
+// Package study keeps shared analysis boards: studies made of chapters, each
+// a starting position and a tree of variations that a group edits together.
+package study
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// Limits on a study's size, which is bounded by the size of a record
+const (
+	MaxChapters      = 64
+	MaxNodes         = 1000 // moves per chapter
+	MaxMembers       = 50
+	MaxNameLength    = 100
+	MaxCommentLength = 2000
+)
+
+var (
+	// ErrNotFound is returned for a study that doesn't exist
+	ErrNotFound = errors.New("study not found")
+
+	// ErrForbidden is returned when someone other than the owner or a member
+	// edits a study, or a member makes an edit only the owner may
+	ErrForbidden = errors.New("not allowed to edit the study")
+
+	// ErrInvalidEdit is returned for an edit that can't be made, e.g. an
+	// illegal move or a chapter that doesn't exist
+	ErrInvalidEdit = errors.New("invalid edit")
+)
+
+// Edit operations
+const (
+	OpAddChapter    = "addChapter"
+	OpRenameChapter = "renameChapter"
+	OpDeleteChapter = "deleteChapter"
+	OpAddMove       = "addMove"
+	OpDeleteMove    = "deleteMove"
+	OpComment       = "comment"
+	OpSetInfo       = "setInfo"    // owner only
+	OpSetMembers    = "setMembers" // owner only
+)
+
+// Node is a move in a chapter's tree of variations
+type Node struct {
+	ID      string `json:"id"`
+	Parent  string `json:"parent,omitempty"` // empty for a move from the chapter's starting position
+	UCI     string `json:"uci"`
+	SAN     string `json:"san"`
+	FEN     string `json:"fen"` // position after the move
+	Comment string `json:"comment,omitempty"`
+}
+
+// Chapter is a starting position and the variations explored from it
+type Chapter struct {
+	ID    string  `json:"id"`
+	Name  string  `json:"name"`
+	FEN   string  `json:"fen"`
+	Nodes []*Node `json:"nodes"` // every parent comes before its children
+}
+
+// Study is a set of chapters analysed together. It's kept as an
+// app.atchess.study record in its owner's repository.
+type Study struct {
+	URI         string     `json:"uri"`
+	Owner       string     `json:"owner"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Members     []string   `json:"members"` // players besides the owner who may edit it
+	Chapters    []*Chapter `json:"chapters"`
+	Version     int        `json:"version"` // counts edits, so clients can tell when they've missed one
+	CreatedAt   string     `json:"createdAt"`
+	UpdatedAt   string     `json:"updatedAt"`
+}
+
+// Edit is a change to a study. Applying it fills in the IDs of what it
+// created, so it can be passed on to everyone else editing the study.
+type Edit struct {
+	Op          string   `json:"op"`
+	Chapter     string   `json:"chapter,omitempty"`
+	Node        string   `json:"node,omitempty"` // the move to add after, or the move edited
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	FEN         string   `json:"fen,omitempty"`
+	Move        string   `json:"move,omitempty"` // in SAN or UCI
+	Comment     string   `json:"comment,omitempty"`
+	Members     []string `json:"members,omitempty"`
+
+	// Created is the chapter or move the edit added
+	Created string `json:"created,omitempty"`
+	// Result is the move added, as played
+	Result *Node `json:"result,omitempty"`
+}
+
+// New creates a study with a single chapter from the starting position
+func New(owner, name, description string) (*Study, error) {
+	study := &Study{Owner: owner, Members: []string{}, Chapters: []*Chapter{}}
+	if err := study.Apply(owner, &Edit{Op: OpSetInfo, Name: name, Description: description}); err != nil {
+		return nil, err
+	}
+	if err := study.Apply(owner, &Edit{Op: OpAddChapter, Name: "Chapter 1"}); err != nil {
+		return nil, err
+	}
+	study.Version = 0
+	return study, nil
+}
+
+// CanEdit reports whether a player may edit the study's chapters
+func (s *Study) CanEdit(did string) bool {
+	return did != "" && (did == s.Owner || slices.Contains(s.Members, did))
+}
+
+// Clone returns a deep copy of the study
+func (s *Study) Clone() *Study {
+	clone := *s
+	clone.Members = slices.Clone(s.Members)
+	clone.Chapters = make([]*Chapter, len(s.Chapters))
+	for i, chapter := range s.Chapters {
+		copied := *chapter
+		copied.Nodes = make([]*Node, len(chapter.Nodes))
+		for j, node := range chapter.Nodes {
+			n := *node
+			copied.Nodes[j] = &n
+		}
+		clone.Chapters[i] = &copied
+	}
+	return &clone
+}
+
+// Chapter returns the chapter with an ID, or nil
+func (s *Study) Chapter(id string) *Chapter {
+	for _, chapter := range s.Chapters {
+		if chapter.ID == id {
+			return chapter
+		}
+	}
+	return nil
+}
+
+// Apply makes an edit on behalf of did and counts it in the version. The
+// study is left unchanged if the edit fails.
+func (s *Study) Apply(did string, edit *Edit) error {
+	if !s.CanEdit(did) {
+		return ErrForbidden
+	}
+	if (edit.Op == OpSetInfo || edit.Op == OpSetMembers) && did != s.Owner {
+		return ErrForbidden
+	}
+
+	var err error
+	switch edit.Op {
+	case OpSetInfo:
+		err = s.setInfo(edit)
+	case OpSetMembers:
+		err = s.setMembers(edit)
+	case OpAddChapter:
+		err = s.addChapter(edit)
+	case OpRenameChapter:
+		err = s.renameChapter(edit)
+	case OpDeleteChapter:
+		err = s.deleteChapter(edit)
+	case OpAddMove:
+		err = s.addMove(edit)
+	case OpDeleteMove:
+		err = s.deleteMove(edit)
+	case OpComment:
+		err = s.comment(edit)
+	default:
+		err = invalid("unknown op %q", edit.Op)
+	}
+	if err != nil {
+		return err
+	}
+	s.Version++
+	return nil
+}
+
+func (s *Study) setInfo(edit *Edit) error {
+	name := strings.TrimSpace(edit.Name)
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(edit.Description) > MaxCommentLength {
+		return invalid("description is longer than %d characters", MaxCommentLength)
+	}
+	s.Name, s.Description = name, edit.Description
+	return nil
+}
+
+func (s *Study) setMembers(edit *Edit) error {
+	if len(edit.Members) > MaxMembers {
+		return invalid("a study can have at most %d members", MaxMembers)
+	}
+	members := []string{}
+	for _, did := range edit.Members {
+		if !strings.HasPrefix(did, "did:") {
+			return invalid("member %q is not a DID", did)
+		}
+		if did != s.Owner && !slices.Contains(members, did) {
+			members = append(members, did)
+		}
+	}
+	s.Members = members
+	return nil
+}
+
+func (s *Study) addChapter(edit *Edit) error {
+	if len(s.Chapters) >= MaxChapters {
+		return invalid("a study can have at most %d chapters", MaxChapters)
+	}
+	name := strings.TrimSpace(edit.Name)
+	if name == "" {
+		name = fmt.Sprintf("Chapter %d", len(s.Chapters)+1)
+	}
+	if err := checkName(name); err != nil {
+		return err
+	}
+	fen := edit.FEN
+	if fen == "" {
+		fen = chess.StartingFEN
+	}
+	engine, err := chess.NewEngineFromFEN(fen)
+	if err != nil {
+		return invalid("%v", err)
+	}
+
+	chapter := &Chapter{ID: newID(), Name: name, FEN: engine.GetFEN(), Nodes: []*Node{}}
+	s.Chapters = append(s.Chapters, chapter)
+	edit.Created, edit.FEN, edit.Name = chapter.ID, chapter.FEN, chapter.Name
+	return nil
+}
+
+func (s *Study) renameChapter(edit *Edit) error {
+	chapter, err := s.chapter(edit)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(edit.Name)
+	if err := checkName(name); err != nil {
+		return err
+	}
+	chapter.Name, edit.Name = name, name
+	return nil
+}
+
+func (s *Study) deleteChapter(edit *Edit) error {
+	if _, err := s.chapter(edit); err != nil {
+		return err
+	}
+	if len(s.Chapters) == 1 {
+		return invalid("a study needs at least one chapter")
+	}
+	s.Chapters = slices.DeleteFunc(s.Chapters, func(chapter *Chapter) bool { return chapter.ID == edit.Chapter })
+	return nil
+}
+
+func (s *Study) addMove(edit *Edit) error {
+	chapter, err := s.chapter(edit)
+	if err != nil {
+		return err
+	}
+	fen := chapter.FEN
+	if edit.Node != "" {
+		parent := chapter.node(edit.Node)
+		if parent == nil {
+			return invalid("no move %q in the chapter", edit.Node)
+		}
+		fen = parent.FEN
+	}
+
+	engine, err := chess.NewEngineFromFEN(fen)
+	if err != nil {
+		return invalid("%v", err)
+	}
+	var result *chess.MoveResult
+	if chess.IsUCI(edit.Move) {
+		result, err = engine.MakeMoveUCI(edit.Move)
+	} else {
+		result, err = engine.MakeMoveSAN(edit.Move)
+	}
+	if err != nil {
+		return invalid("%s can't be played: %v", edit.Move, err)
+	}
+	uci := result.From + result.To + result.Promotion
+
+	// A move that's already in the tree is followed rather than added again
+	for _, node := range chapter.Nodes {
+		if node.Parent == edit.Node && node.UCI == uci {
+			copied := *node
+			edit.Created, edit.Result = node.ID, &copied
+			return nil
+		}
+	}
+	if len(chapter.Nodes) >= MaxNodes {
+		return invalid("a chapter can have at most %d moves", MaxNodes)
+	}
+
+	node := &Node{ID: newID(), Parent: edit.Node, UCI: uci, SAN: result.SAN, FEN: result.FEN}
+	chapter.Nodes = append(chapter.Nodes, node)
+	copied := *node
+	edit.Created, edit.Result = node.ID, &copied
+	return nil
+}
+
+func (s *Study) deleteMove(edit *Edit) error {
+	chapter, err := s.chapter(edit)
+	if err != nil {
+		return err
+	}
+	if chapter.node(edit.Node) == nil {
+		return invalid("no move %q in the chapter", edit.Node)
+	}
+
+	// Parents come before their children, so one pass finds every
+	// descendant
+	deleted := map[string]bool{edit.Node: true}
+	kept := chapter.Nodes[:0:0]
+	for _, node := range chapter.Nodes {
+		if deleted[node.ID] || deleted[node.Parent] {
+			deleted[node.ID] = true
+			continue
+		}
+		kept = append(kept, node)
+	}
+	chapter.Nodes = kept
+	return nil
+}
+
+func (s *Study) comment(edit *Edit) error {
+	chapter, err := s.chapter(edit)
+	if err != nil {
+		return err
+	}
+	node := chapter.node(edit.Node)
+	if node == nil {
+		return invalid("no move %q in the chapter", edit.Node)
+	}
+	if utf8.RuneCountInString(edit.Comment) > MaxCommentLength {
+		return invalid("comment is longer than %d characters", MaxCommentLength)
+	}
+	node.Comment = edit.Comment
+	return nil
+}
+
+// chapter returns the chapter an edit is to, or an error if there's none
+func (s *Study) chapter(edit *Edit) (*Chapter, error) {
+	chapter := s.Chapter(edit.Chapter)
+	if chapter == nil {
+		return nil, invalid("no chapter %q in the study", edit.Chapter)
+	}
+	return chapter, nil
+}
+
+// node returns the move with an ID, or nil
+func (c *Chapter) node(id string) *Node {
+	for _, node := range c.Nodes {
+		if node.ID == id {
+			return node
+		}
+	}
+	return nil
+}
+
+func checkName(name string) error {
+	if name == "" {
+		return invalid("name is empty")
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return invalid("name is longer than %d characters", MaxNameLength)
+	}
+	return nil
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidEdit, fmt.Sprintf(format, args...))
+}
+
+// newID returns a random ID for a chapter or move
+func newID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,145 @@
+package study
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const (
+	owner  = "did:plc:owner"
+	member = "did:plc:member"
+)
+
+func newStudy(t *testing.T) *Study {
+	t.Helper()
+	s, err := New(owner, "Openings", "")
+	if err != nil {
+		t.Fatalf("Failed to create study: %v", err)
+	}
+	s.URI = "at://" + owner + "/app.atchess.study/s1"
+	if err := s.Apply(owner, &Edit{Op: OpSetMembers, Members: []string{member, owner, member}}); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+	return s
+}
+
+func TestAddMoveBuildsVariations(t *testing.T) {
+	s := newStudy(t)
+	chapter := s.Chapters[0].ID
+	if len(s.Members) != 1 {
+		t.Fatalf("Expected the owner and duplicates left out of members, got %v", s.Members)
+	}
+
+	e4 := &Edit{Op: OpAddMove, Chapter: chapter, Move: "e4"}
+	if err := s.Apply(member, e4); err != nil {
+		t.Fatalf("Expected a member to add a move: %v", err)
+	}
+	e5 := &Edit{Op: OpAddMove, Chapter: chapter, Node: e4.Created, Move: "e7e5"}
+	c5 := &Edit{Op: OpAddMove, Chapter: chapter, Node: e4.Created, Move: "c5"}
+	if err := s.Apply(owner, e5); err != nil {
+		t.Fatalf("Expected a UCI move: %v", err)
+	}
+	if err := s.Apply(member, c5); err != nil {
+		t.Fatalf("Expected a second variation: %v", err)
+	}
+	if e5.Result.SAN != "e5" || c5.Result.UCI != "c7c5" || c5.Result.Parent != e4.Created {
+		t.Errorf("Expected both replies as played, got %+v and %+v", e5.Result, c5.Result)
+	}
+
+	// Playing a move that's already there follows it
+	again := &Edit{Op: OpAddMove, Chapter: chapter, Move: "e2e4"}
+	if err := s.Apply(member, again); err != nil || again.Created != e4.Created || len(s.Chapters[0].Nodes) != 3 {
+		t.Errorf("Expected the existing move to be followed, got %v %+v", err, again)
+	}
+
+	if err := s.Apply(member, &Edit{Op: OpAddMove, Chapter: chapter, Node: e5.Created, Move: "e5"}); !errors.Is(err, ErrInvalidEdit) {
+		t.Errorf("Expected an illegal move to be invalid, got %v", err)
+	}
+	if s.Version != 5 {
+		t.Errorf("Expected only applied edits counted, got version %d", s.Version)
+	}
+
+	// Deleting a move deletes what follows it
+	if err := s.Apply(member, &Edit{Op: OpDeleteMove, Chapter: chapter, Node: e4.Created}); err != nil || len(s.Chapters[0].Nodes) != 0 {
+		t.Errorf("Expected the whole line deleted, got %v %d", err, len(s.Chapters[0].Nodes))
+	}
+}
+
+func TestApplyChecksPermissions(t *testing.T) {
+	s := newStudy(t)
+	if err := s.Apply("did:plc:other", &Edit{Op: OpAddChapter}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected outsiders to be refused, got %v", err)
+	}
+	if err := s.Apply(member, &Edit{Op: OpSetInfo, Name: "Mine"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected members not to rename the study, got %v", err)
+	}
+	if err := s.Apply(member, &Edit{Op: OpDeleteChapter, Chapter: s.Chapters[0].ID}); !errors.Is(err, ErrInvalidEdit) {
+		t.Errorf("Expected the last chapter to be kept, got %v", err)
+	}
+
+	clone := s.Clone()
+	if err := s.Apply(member, &Edit{Op: OpAddChapter, FEN: "8/8/8/4k3/8/8/4P3/4K3 w - - 0 1"}); err != nil {
+		t.Fatalf("Expected a chapter from a position: %v", err)
+	}
+	if len(clone.Chapters) != 1 || s.Chapters[1].Name != "Chapter 2" {
+		t.Errorf("Expected the clone unchanged and the new chapter named, got %d %q", len(clone.Chapters), s.Chapters[1].Name)
+	}
+}
+
+// memoryStore saves studies unless disconnected
+type memoryStore struct {
+	studies      map[string]*Study
+	disconnected bool
+}
+
+func (m *memoryStore) LoadStudy(ctx context.Context, uri string) (*Study, error) {
+	if s, ok := m.studies[uri]; ok {
+		return s.Clone(), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (m *memoryStore) SaveStudy(ctx context.Context, s *Study) error {
+	if m.disconnected {
+		return ErrNotConnected
+	}
+	m.studies[s.URI] = s
+	return nil
+}
+
+func TestStudiesSavePendingEditsWhenTheOwnerReturns(t *testing.T) {
+	s := newStudy(t)
+	store := &memoryStore{studies: map[string]*Study{s.URI: s}, disconnected: true}
+	studies := NewStudies(store)
+	var seen []string
+	studies.OnEdit(func(study *Study, did string, edit *Edit) { seen = append(seen, did+" "+edit.Op) })
+
+	ctx := context.Background()
+	if _, err := studies.Edit(ctx, "at://nowhere/app.atchess.study/none", member, &Edit{Op: OpAddChapter}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a missing study to be not found, got %v", err)
+	}
+	edited, err := studies.Edit(ctx, s.URI, member, &Edit{Op: OpAddChapter, Name: "Sidelines"})
+	if err != nil || len(edited.Chapters) != 2 {
+		t.Fatalf("Expected the edit applied, got %v", err)
+	}
+	if len(store.studies[s.URI].Chapters) != 1 {
+		t.Error("Expected nothing saved while the owner is away")
+	}
+	if got, _ := studies.Get(ctx, s.URI); len(got.Chapters) != 2 {
+		t.Error("Expected reads to include the unsaved edit")
+	}
+
+	store.disconnected = false
+	studies.SavePending(ctx, member)
+	if len(store.studies[s.URI].Chapters) != 1 {
+		t.Error("Expected only the owner's logins to save")
+	}
+	studies.SavePending(ctx, owner)
+	if len(store.studies[s.URI].Chapters) != 2 || store.studies[s.URI].Version != 2 {
+		t.Errorf("Expected the pending edit saved, got %+v", store.studies[s.URI])
+	}
+	if len(seen) != 1 || seen[0] != member+" "+OpAddChapter {
+		t.Errorf("Expected one edit announced, got %v", seen)
+	}
+}
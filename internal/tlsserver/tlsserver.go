@@ -0,0 +1,171 @@
+// Package tlsserver serves HTTPS directly, from certificate files or with
+// certificates from Let's Encrypt, so small deployments needn't run a
+// reverse proxy in front of the services.
+package tlsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// reloadInterval is how often certificate files are checked for renewals
+const reloadInterval = time.Minute
+
+// Server runs an http.Server over HTTPS when TLS is configured, alongside a
+// plain HTTP server redirecting to it. Without TLS it's the http.Server alone.
+type Server struct {
+	srv *http.Server
+	// redirect serves plain HTTP when srv serves HTTPS
+	redirect *http.Server
+}
+
+// New prepares srv to serve as cfg says, loading certificate files so
+// mistakes show up at startup
+func New(srv *http.Server, cfg config.TLSConfig) (*Server, error) {
+	s := &Server{srv: srv}
+	if !cfg.Enabled() {
+		return s, nil
+	}
+
+	_, port, _ := net.SplitHostPort(srv.Addr)
+	fallback := RedirectHandler(port)
+	var httpHandler http.Handler
+	if cfg.Autocert {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+			Cache:      autocert.DirCache(cfg.CacheDir),
+			Email:      cfg.Email,
+		}
+		if cfg.DirectoryURL != "" {
+			manager.Client = &acme.Client{DirectoryURL: cfg.DirectoryURL}
+		}
+		// Answers TLS-ALPN challenges as well as serving certificates
+		srv.TLSConfig = manager.TLSConfig()
+		// Answers HTTP challenges, redirecting everything else
+		httpHandler = manager.HTTPHandler(fallback)
+	} else {
+		pair := &keyPair{certFile: cfg.CertFile, keyFile: cfg.KeyFile}
+		if err := pair.load(); err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = &tls.Config{GetCertificate: pair.getCertificate}
+		httpHandler = fallback
+	}
+	srv.TLSConfig.MinVersion = tls.VersionTLS12
+
+	if cfg.RedirectAddr != "" {
+		s.redirect = &http.Server{
+			Addr:         cfg.RedirectAddr,
+			Handler:      httpHandler,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Second,
+		}
+	}
+	return s, nil
+}
+
+// TLS reports whether the server serves HTTPS
+func (s *Server) TLS() bool {
+	return s.srv.TLSConfig != nil
+}
+
+// ListenAndServe serves until Shutdown, like http.Server.ListenAndServe
+func (s *Server) ListenAndServe() error {
+	if !s.TLS() {
+		return s.srv.ListenAndServe()
+	}
+	if s.redirect != nil {
+		go func() {
+			log.Info().Str("addr", s.redirect.Addr).Msg("Redirecting HTTP to HTTPS")
+			if err := s.redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error().Err(err).Str("addr", s.redirect.Addr).Msg("HTTP redirect server failed")
+			}
+		}()
+	}
+	// The certificates come from TLSConfig
+	return s.srv.ListenAndServeTLS("", "")
+}
+
+// Shutdown gracefully stops both servers
+func (s *Server) Shutdown(ctx context.Context) error {
+	var redirectErr error
+	if s.redirect != nil {
+		redirectErr = s.redirect.Shutdown(ctx)
+	}
+	return errors.Join(s.srv.Shutdown(ctx), redirectErr)
+}
+
+// RedirectHandler redirects requests to the same URL over HTTPS on port,
+// which is left out of the URL when it's 443
+func RedirectHandler(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if port != "" && port != "443" {
+			host += ":" + port
+		}
+		// 308 keeps the method and body, so API clients' POSTs aren't lost
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// keyPair serves a certificate from files, reloading it when they change so
+// renewals by certbot and the like are picked up without a restart
+type keyPair struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func (k *keyPair) load() error {
+	info, err := os.Stat(k.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(k.certFile, k.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+	k.cert, k.modTime, k.checked = &cert, info.ModTime(), time.Now()
+	return nil
+}
+
+func (k *keyPair) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if time.Since(k.checked) < reloadInterval {
+		return k.cert, nil
+	}
+	k.checked = time.Now()
+	if info, err := os.Stat(k.certFile); err == nil && !info.ModTime().Equal(k.modTime) {
+		// A half-written renewal keeps the old certificate until the next check
+		if err := k.load(); err != nil {
+			log.Warn().Err(err).Str("cert", k.certFile).Msg("Failed to reload certificate")
+		} else {
+			log.Info().Str("cert", k.certFile).Msg("Reloaded certificate")
+		}
+	}
+	return k.cert, nil
+}
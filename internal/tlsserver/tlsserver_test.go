@@ -0,0 +1,126 @@
+package tlsserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/config"
+)
+
+// writeCertificate writes a self-signed certificate for localhost
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func TestNew_ServesHTTPSFromCertificateFiles(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir())
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "over tls")
+	})}
+	s, err := New(srv, config.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if !s.TLS() {
+		t.Fatal("Expected HTTPS to be on")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeTLS(ln, "", "")
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "over tls" || resp.TLS == nil {
+		t.Errorf("Expected the handler over TLS, got %q", body)
+	}
+}
+
+func TestNew_ChecksCertificateFilesAtStartup(t *testing.T) {
+	dir := t.TempDir()
+	_, err := New(&http.Server{}, config.TLSConfig{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: filepath.Join(dir, "key.pem")})
+	if err == nil {
+		t.Error("Expected an error for missing certificate files")
+	}
+
+	s, err := New(&http.Server{}, config.TLSConfig{})
+	if err != nil || s.TLS() {
+		t.Errorf("Expected plain HTTP without TLS settings, got %v", err)
+	}
+}
+
+func TestNew_Autocert(t *testing.T) {
+	srv := &http.Server{Addr: ":443"}
+	s, err := New(srv, config.TLSConfig{Autocert: true, Domains: []string{"chess.example.com"}, CacheDir: t.TempDir(), RedirectAddr: ":80"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if !slices.Contains(srv.TLSConfig.NextProtos, "acme-tls/1") {
+		t.Errorf("Expected TLS-ALPN challenges to be answered, got %q", srv.TLSConfig.NextProtos)
+	}
+	if _, err := srv.TLSConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.com"}); err == nil {
+		t.Error("Expected no certificate for a domain that isn't configured")
+	}
+
+	// Plain HTTP redirects, apart from ACME challenges
+	rec := httptest.NewRecorder()
+	s.redirect.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://chess.example.com/game/abc?x=1", nil))
+	if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != "https://chess.example.com/game/abc?x=1" {
+		t.Errorf("Expected a redirect to HTTPS, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestRedirectHandler_KeepsPort(t *testing.T) {
+	rec := httptest.NewRecorder()
+	RedirectHandler("8443").ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/moves", nil))
+	if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != "https://localhost:8443/api/moves" {
+		t.Errorf("Expected a redirect to port 8443, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestMiddlewareNamesSpansByRoute(t *testing.T) {
+	recorder := recordSpans(t)
+
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	api := router.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/games/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !trace.SpanFromContext(r.Context()).SpanContext().IsValid() {
+			t.Error("Expected the handler to run inside the request's span")
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}).Methods("GET")
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/games/abc123", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /api/games/{id}" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("Expected a server span named by the route, got %q", span.Name())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("Expected a 5xx response to mark the span failed, got %v", span.Status())
+	}
+	for _, attr := range span.Attributes() {
+		if attr.Key == "http.response.status_code" && attr.Value.AsInt64() != http.StatusBadGateway {
+			t.Errorf("Expected status 502, got %v", attr.Value.AsInt64())
+		}
+	}
+}
+
+func TestMiddlewareContinuesCallersTrace(t *testing.T) {
+	recorder := recordSpans(t)
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(previous)
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("POST", "/api/moves", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("Expected the span to join the caller's trace, got %v", spans)
+	}
+	if spans[0].Name() != "POST /api/moves" {
+		t.Errorf("Expected the path to name an unrouted request, got %q", spans[0].Name())
+	}
+}
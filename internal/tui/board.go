@@ -0,0 +1,69 @@
+// Package tui is a line-oriented terminal client for playing games through
+// the protocol service's API.
+package tui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pieces maps FEN piece letters to their Unicode symbols
+var pieces = map[rune]string{
+	'K': "♔", 'Q': "♕", 'R': "♖", 'B': "♗", 'N': "♘", 'P': "♙",
+	'k': "♚", 'q': "♛", 'r': "♜", 'b': "♝", 'n': "♞", 'p': "♟",
+}
+
+// RenderBoard draws the position in fen with Unicode pieces and rank and file
+// labels, from white's side unless perspective is "black"
+func RenderBoard(fen, perspective string) (string, error) {
+	fields := strings.Fields(fen)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("invalid FEN: %q", fen)
+	}
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return "", fmt.Errorf("invalid FEN: %q", fen)
+	}
+
+	// squares[0] is the eighth rank, from the a-file
+	var squares [8][8]string
+	for r, rank := range ranks {
+		file := 0
+		for _, c := range rank {
+			switch {
+			case c >= '1' && c <= '8':
+				for n := 0; n < int(c-'0') && file < 8; n++ {
+					squares[r][file] = "·"
+					file++
+				}
+			case pieces[c] != "" && file < 8:
+				squares[r][file] = pieces[c]
+				file++
+			default:
+				return "", fmt.Errorf("invalid FEN: %q", fen)
+			}
+		}
+		if file != 8 {
+			return "", fmt.Errorf("invalid FEN: %q", fen)
+		}
+	}
+
+	files := "a b c d e f g h"
+	order := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	if perspective == "black" {
+		files = "h g f e d c b a"
+		order = []int{7, 6, 5, 4, 3, 2, 1, 0}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %s\n", files)
+	for _, r := range order {
+		fmt.Fprintf(&b, "%d", 8-r)
+		for _, f := range order {
+			b.WriteString(" " + squares[r][f])
+		}
+		fmt.Fprintf(&b, " %d\n", 8-r)
+	}
+	fmt.Fprintf(&b, "  %s\n", files)
+	return b.String(), nil
+}
@@ -0,0 +1,63 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+)
+
+const startingFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+func TestRenderBoard_White(t *testing.T) {
+	board, err := RenderBoard(startingFEN, "white")
+	if err != nil {
+		t.Fatalf("RenderBoard failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(board, "\n"), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("Expected 10 lines, got %d:\n%s", len(lines), board)
+	}
+	for i, want := range map[int]string{
+		0: "  a b c d e f g h",
+		1: "8 ♜ ♞ ♝ ♛ ♚ ♝ ♞ ♜ 8",
+		4: "5 · · · · · · · · 5",
+		8: "1 ♖ ♘ ♗ ♕ ♔ ♗ ♘ ♖ 1",
+		9: "  a b c d e f g h",
+	} {
+		if lines[i] != want {
+			t.Errorf("Line %d: expected %q, got %q", i, want, lines[i])
+		}
+	}
+}
+
+func TestRenderBoard_Black(t *testing.T) {
+	// After 1. e4 the pawn sits on e4, left of centre from black's side
+	board, err := RenderBoard("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", "black")
+	if err != nil {
+		t.Fatalf("RenderBoard failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(board, "\n"), "\n")
+	for i, want := range map[int]string{
+		0: "  h g f e d c b a",
+		1: "1 ♖ ♘ ♗ ♔ ♕ ♗ ♘ ♖ 1",
+		2: "2 ♙ ♙ ♙ · ♙ ♙ ♙ ♙ 2",
+		4: "4 · · · ♙ · · · · 4",
+		8: "8 ♜ ♞ ♝ ♚ ♛ ♝ ♞ ♜ 8",
+	} {
+		if lines[i] != want {
+			t.Errorf("Line %d: expected %q, got %q", i, want, lines[i])
+		}
+	}
+}
+
+func TestRenderBoard_InvalidFEN(t *testing.T) {
+	for _, fen := range []string{
+		"",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/ppppxppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	} {
+		if _, err := RenderBoard(fen, "white"); err == nil {
+			t.Errorf("Expected an error for %q", fen)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package tui
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/justinabrahms/atchess/internal/apiclient"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// errQuit ends a session
+var errQuit = errors.New("quit")
+
+// Session reads commands a line at a time and carries them out as the
+// logged-in player. Commands read from a file or pipe work just as typed
+// ones do, so a session can be scripted.
+type Session struct {
+	client *apiclient.Client
+	out    io.Writer
+	// games and notifications are the last listings, which commands refer
+	// to by number
+	games         []*chess.Game
+	notifications []*apiclient.Notification
+	// game is the open game
+	game *chess.Game
+}
+
+// NewSession creates a session for a logged-in client, writing to out
+func NewSession(client *apiclient.Client, out io.Writer) *Session {
+	return &Session{client: client, out: out}
+}
+
+// Game returns the open game, or nil
+func (s *Session) Game() *chess.Game {
+	return s.game
+}
+
+// Run carries out commands from in until it ends or a quit command. Failed
+// commands are reported and the session goes on.
+func (s *Session) Run(ctx context.Context, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	s.prompt()
+	for scanner.Scan() {
+		err := s.Exec(ctx, scanner.Text())
+		if errors.Is(err, errQuit) {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(s.out, "error: %v\n", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s.prompt()
+	}
+	return scanner.Err()
+}
+
+func (s *Session) prompt() {
+	fmt.Fprint(s.out, "> ")
+}
+
+// Exec carries out a single command
+func (s *Session) Exec(ctx context.Context, line string) error {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return nil
+	}
+	command, args := strings.ToLower(args[0]), args[1:]
+	switch command {
+	case "games", "g":
+		status := ""
+		if len(args) > 0 {
+			status = args[0]
+		}
+		return s.listGames(ctx, status)
+	case "open", "o":
+		if len(args) != 1 {
+			return errors.New("usage: open <number|game URI>")
+		}
+		return s.openGame(ctx, args[0])
+	case "board", "b":
+		if s.game == nil {
+			return errors.New("no game is open")
+		}
+		return s.openGame(ctx, s.game.ID)
+	case "move", "m":
+		if len(args) != 1 {
+			return errors.New("usage: move <SAN|UCI>")
+		}
+		return s.move(ctx, args[0])
+	case "challenges", "c":
+		return s.listChallenges(ctx)
+	case "accept":
+		notification, err := s.notification(args)
+		if err != nil {
+			return err
+		}
+		game, err := s.client.AcceptChallenge(ctx, notification.ChallengeURI)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "Accepted; game %s started\n", game.ID)
+		return s.openGame(ctx, game.ID)
+	case "decline":
+		notification, err := s.notification(args)
+		if err != nil {
+			return err
+		}
+		if err := s.client.DeclineChallenge(ctx, notification.ChallengeURI); err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, "Declined")
+		return nil
+	case "challenge":
+		if len(args) == 0 {
+			return errors.New("usage: challenge <handle|DID> [white|black|random] [message]")
+		}
+		color := "random"
+		if len(args) > 1 {
+			color = args[1]
+		}
+		message := ""
+		if len(args) > 2 {
+			message = strings.Join(args[2:], " ")
+		}
+		challenge, err := s.client.Challenge(ctx, args[0], color, message)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "Challenge sent: %s\n", challenge.ID)
+		return nil
+	case "new":
+		if len(args) == 0 {
+			return errors.New("usage: new <opponent DID> [white|black]")
+		}
+		color := "white"
+		if len(args) > 1 {
+			color = args[1]
+		}
+		game, err := s.client.CreateGame(ctx, args[0], color)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "Game %s created\n", game.ID)
+		return s.openGame(ctx, game.ID)
+	case "draw":
+		if s.game == nil {
+			return errors.New("no game is open")
+		}
+		if err := s.client.OfferDraw(ctx, s.game.ID); err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, "Draw offered")
+		return nil
+	case "resign":
+		if s.game == nil {
+			return errors.New("no game is open")
+		}
+		if err := s.client.Resign(ctx, s.game.ID); err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, "Resigned")
+		return s.openGame(ctx, s.game.ID)
+	case "help", "h", "?":
+		fmt.Fprint(s.out, helpText)
+		return nil
+	case "quit", "exit", "q":
+		return errQuit
+	default:
+		return fmt.Errorf("unknown command %q; try help", command)
+	}
+}
+
+const helpText = `Commands:
+  games [active|finished]                    list your games
+  open <number|game URI>                     open a game and show its board
+  board                                      refresh the open game
+  move <SAN|UCI>                             move in the open game, e.g. Nf3 or g1f3
+  draw                                       offer a draw in the open game
+  resign                                     resign the open game
+  challenges                                 list challenges waiting for you
+  accept <number>                            accept a listed challenge
+  decline <number>                           decline a listed challenge
+  challenge <handle|DID> [color] [message]   challenge a player
+  new <DID> [color]                          start a game against a player
+  help                                       show this help
+  quit                                       leave
+`
+
+func (s *Session) listGames(ctx context.Context, status string) error {
+	games, err := s.client.ListGames(ctx, status)
+	if err != nil {
+		return err
+	}
+	s.games = games
+	if len(games) == 0 {
+		fmt.Fprintln(s.out, "No games")
+		return nil
+	}
+	for i, game := range games {
+		fmt.Fprintf(s.out, "%2d. %s\n", i+1, s.describe(game))
+	}
+	return nil
+}
+
+// describe sums up a game from the player's side
+func (s *Session) describe(game *chess.Game) string {
+	color, opponent := "white", game.Black
+	if me := s.me(); me != "" && me == game.Black {
+		color, opponent = "black", game.White
+	}
+	summary := fmt.Sprintf("vs %s as %s, %s", opponent, color, game.Status)
+	if game.Status == chess.StatusActive {
+		if player, err := game.PlayerToMove(); err == nil && player == s.me() {
+			summary += ", your move"
+		}
+	}
+	return summary + "  " + game.ID
+}
+
+func (s *Session) me() string {
+	if session := s.client.Session(); session != nil {
+		return session.DID
+	}
+	return ""
+}
+
+// openGame opens a game by its number in the last listing or its URI and
+// shows its board
+func (s *Session) openGame(ctx context.Context, ref string) error {
+	uri := ref
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(s.games) {
+			return fmt.Errorf("no game %d; list them with games", n)
+		}
+		uri = s.games[n-1].ID
+	}
+	game, err := s.client.GetGame(ctx, uri)
+	if err != nil {
+		return err
+	}
+	s.game = game
+	return s.showBoard()
+}
+
+func (s *Session) showBoard() error {
+	perspective := "white"
+	if s.me() == s.game.Black {
+		perspective = "black"
+	}
+	board, err := RenderBoard(s.game.FEN, perspective)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(s.out, board)
+	fmt.Fprintln(s.out, s.describe(s.game))
+	return nil
+}
+
+func (s *Session) move(ctx context.Context, move string) error {
+	if s.game == nil {
+		return errors.New("no game is open")
+	}
+	result, err := s.client.Move(ctx, s.game.ID, s.game.FEN, move)
+	var apiErr *apiclient.Error
+	if errors.As(err, &apiErr) && apiErr.Code == "position_mismatch" {
+		// The board we showed is out of date; show the current one
+		if refreshErr := s.openGame(ctx, s.game.ID); refreshErr != nil {
+			return refreshErr
+		}
+		return errors.New("the game has moved on; try again on the current board")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "Played %s\n", result.SAN)
+	if result.GameOver {
+		fmt.Fprintf(s.out, "Game over: %s\n", result.Result)
+	}
+	return s.openGame(ctx, s.game.ID)
+}
+
+func (s *Session) listChallenges(ctx context.Context) error {
+	notifications, err := s.client.ChallengeNotifications(ctx)
+	if err != nil {
+		return err
+	}
+	s.notifications = notifications
+	if len(notifications) == 0 {
+		fmt.Fprintln(s.out, "No challenges")
+		return nil
+	}
+	for i, n := range notifications {
+		challenger := n.ChallengerHandle
+		if challenger == "" {
+			challenger = n.Challenger
+		}
+		line := fmt.Sprintf("%2d. from %s, you play %s", i+1, challenger, n.Color)
+		if n.Message != "" {
+			line += fmt.Sprintf(": %q", n.Message)
+		}
+		fmt.Fprintln(s.out, line)
+	}
+	return nil
+}
+
+// notification returns the challenge notification numbered by args
+func (s *Session) notification(args []string) (*apiclient.Notification, error) {
+	if len(args) != 1 {
+		return nil, errors.New("usage: accept|decline <number>")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || n > len(s.notifications) {
+		return nil, fmt.Errorf("no challenge %s; list them with challenges", args[0])
+	}
+	return s.notifications[n-1], nil
+}
@@ -0,0 +1,162 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/apiclient"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+const testGameURI = "at://did:plc:alice/app.atchess.game/abc"
+
+// fakeAPI serves the parts of the protocol service's API a session uses, for
+// alice playing white in a single game
+type fakeAPI struct {
+	game  *chess.Game
+	moves []map[string]string
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *httptest.Server) {
+	api := &fakeAPI{game: &chess.Game{
+		ID:     testGameURI,
+		White:  "did:plc:alice",
+		Black:  "did:plc:bob",
+		Status: chess.StatusActive,
+		FEN:    startingFEN,
+	}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/auth/login" && r.Header.Get(apiclient.SessionHeader) != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"code": "unauthorized", "message": "Authentication required"}})
+			return
+		}
+		switch r.URL.Path {
+		case "/auth/login":
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "did": "did:plc:alice", "handle": "alice.test", "accessToken": "token"})
+		case "/games":
+			json.NewEncoder(w).Encode(map[string]interface{}{"games": []*chess.Game{api.game}, "total": 1})
+		case "/games/" + apiclient.EncodeGameID(testGameURI):
+			json.NewEncoder(w).Encode(api.game)
+		case "/moves":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["fen"] != api.game.FEN {
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"code": "position_mismatch", "message": "stale"}})
+				return
+			}
+			api.moves = append(api.moves, req)
+			engine, _ := chess.NewEngineFromFEN(api.game.FEN)
+			result, err := engine.MakeMoveSAN(req["move"])
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"code": "invalid_move", "message": err.Error()}})
+				return
+			}
+			api.game.FEN = result.FEN
+			json.NewEncoder(w).Encode(result)
+		case "/challenge-notifications":
+			json.NewEncoder(w).Encode([]map[string]string{{
+				"URI":              "at://did:plc:alice/app.atchess.challengeNotification/n1",
+				"ChallengeURI":     "at://did:plc:carol/app.atchess.challenge/c1",
+				"Challenger":       "did:plc:carol",
+				"ChallengerHandle": "carol.test",
+				"Color":            "black",
+				"Message":          "fancy a game?",
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return api, server
+}
+
+func newTestSession(t *testing.T) (*fakeAPI, *Session, *bytes.Buffer) {
+	api, server := newFakeAPI(t)
+	client := apiclient.New(server.URL)
+	if _, err := client.Login(context.Background(), "alice.test", "abcd-efgh-ijkl-mnop"); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	var out bytes.Buffer
+	return api, NewSession(client, &out), &out
+}
+
+func TestSession_PlaysScriptedMoves(t *testing.T) {
+	api, session, out := newTestSession(t)
+
+	script := "games\nopen 1\nmove e4\nquit\nmove d4\n"
+	if err := session.Run(context.Background(), strings.NewReader(script)); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(api.moves) != 1 || api.moves[0]["move"] != "e4" || api.moves[0]["game_id"] != testGameURI {
+		t.Fatalf("Expected only e4 to be played, got %v", api.moves)
+	}
+	if api.moves[0]["fen"] != startingFEN {
+		t.Errorf("Expected the move to be played against the shown position, got %q", api.moves[0]["fen"])
+	}
+	output := out.String()
+	for _, want := range []string{"1. vs did:plc:bob as white, active, your move", "Played e4", "4 · · · · ♙ · · · 4"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+	if session.Game() == nil || session.Game().FEN == startingFEN {
+		t.Error("Expected the open game to be refreshed after the move")
+	}
+}
+
+func TestSession_RefreshesStaleBoard(t *testing.T) {
+	api, session, out := newTestSession(t)
+	ctx := context.Background()
+	if err := session.Exec(ctx, "open "+testGameURI); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	// The game moves on behind the session's back
+	api.game.FEN = "rnbqkbnr/pppppppp/8/8/3P4/8/PPP1PPPP/RNBQKBNR b KQkq d3 0 1"
+	out.Reset()
+	err := session.Exec(ctx, "move e4")
+	if err == nil || !strings.Contains(err.Error(), "moved on") {
+		t.Fatalf("Expected a stale position error, got %v", err)
+	}
+	if session.Game().FEN != api.game.FEN {
+		t.Error("Expected the board to be refreshed")
+	}
+	if !strings.Contains(out.String(), "4 · · · ♙ · · · · 4") {
+		t.Errorf("Expected the current board to be shown:\n%s", out.String())
+	}
+}
+
+func TestSession_ListsChallenges(t *testing.T) {
+	_, session, out := newTestSession(t)
+	if err := session.Exec(context.Background(), "challenges"); err != nil {
+		t.Fatalf("challenges failed: %v", err)
+	}
+	if want := `1. from carol.test, you play black: "fancy a game?"`; !strings.Contains(out.String(), want) {
+		t.Errorf("Expected %q in:\n%s", want, out.String())
+	}
+	if err := session.Exec(context.Background(), "decline 2"); err == nil {
+		t.Error("Expected an error declining an unlisted challenge")
+	}
+}
+
+func TestSession_ReportsErrors(t *testing.T) {
+	_, session, out := newTestSession(t)
+	script := "move e4\nfrobnicate\n"
+	if err := session.Run(context.Background(), strings.NewReader(script)); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for _, want := range []string{"error: no game is open", `error: unknown command "frobnicate"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/rs/zerolog/log"
+)
+
+// achievementTitles are the names and descriptions shown for each kind of
+// achievement, in the order they're listed
+var achievementTitles = []struct {
+	kind, title, description string
+}{
+	{atproto.AchievementFirstWin, "First win", "Won a game"},
+	{atproto.AchievementHundredGames, "Centurion", "Finished 100 games"},
+	{atproto.AchievementWinStreak5, "On a roll", "Won 5 games in a row"},
+	{atproto.AchievementWinStreak10, "Unstoppable", "Won 10 games in a row"},
+	{atproto.AchievementKnightPromotionMate, "Knight rider", "Delivered checkmate by promoting to a knight"},
+}
+
+// Achievement is an unlocked achievement with its name and description
+type Achievement struct {
+	*atproto.AchievementRecord
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+func describeAchievement(record *atproto.AchievementRecord) Achievement {
+	achievement := Achievement{AchievementRecord: record}
+	for _, t := range achievementTitles {
+		if t.kind == record.Kind {
+			achievement.Title, achievement.Description = t.title, t.description
+		}
+	}
+	return achievement
+}
+
+// AwardAchievements checks both players of a finished game for milestones
+// they've reached in the game index, for Indexer.OnGameFinished. New
+// achievements are written to the player's repository, which needs them to
+// be signed in here, and announced on their connections; ones missed while
+// they weren't are awarded after a later game.
+func (s *Service) AwardAchievements(ctx context.Context, game *index.Game) {
+	if s.gameIndex == nil || !ratedStatuses[game.Status] {
+		return
+	}
+	for _, did := range []string{game.White, game.Black} {
+		if err := s.awardAchievements(ctx, did, game); err != nil {
+			log.Warn().Err(err).Str("player", did).Str("game", game.URI).Msg("Failed to award achievements")
+		}
+	}
+}
+
+func (s *Service) awardAchievements(ctx context.Context, did string, game *index.Game) error {
+	client := s.playerClient(did)
+	if client == nil {
+		return nil
+	}
+	reached, err := s.milestones(ctx, did, game)
+	if err != nil {
+		return err
+	}
+	if len(reached) == 0 {
+		return nil
+	}
+
+	held, err := client.ListAchievements(ctx, did)
+	if err != nil {
+		return err
+	}
+	for _, achievement := range held {
+		delete(reached, achievement.Kind)
+	}
+	for _, t := range achievementTitles {
+		gameURI, ok := reached[t.kind]
+		if !ok {
+			continue
+		}
+		record := &atproto.AchievementRecord{Kind: t.kind, Game: gameURI, Issuer: s.client.GetDID()}
+		if err := client.PublishAchievement(ctx, record); err != nil {
+			return err
+		}
+		log.Info().Str("player", did).Str("achievement", t.kind).Msg("Achievement unlocked")
+		s.notifyPlayer(did, NotificationAchievement, gameURI, describeAchievement(record))
+	}
+	return nil
+}
+
+// milestones returns the achievements a player's indexed games have reached,
+// mapped to the game that reached each. Only games with a result count;
+// abandoned games neither add to nor break a streak. Checkmates are only
+// looked for in the game that just finished.
+func (s *Service) milestones(ctx context.Context, did string, finished *index.Game) (map[string]string, error) {
+	var games []*index.Game
+	query := index.Query{Player: did, Finished: true, Limit: index.MaxLimit}
+	for {
+		page, err := s.gameIndex.ListGames(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, page.Games...)
+		if page.Cursor == "" {
+			break
+		}
+		query.Cursor = page.Cursor
+	}
+
+	reached := make(map[string]string)
+	played, streak := 0, 0
+	// Listings are most recently active first
+	for i := len(games) - 1; i >= 0; i-- {
+		game := games[i]
+		if !ratedStatuses[game.Status] {
+			continue
+		}
+		played++
+		if played == 100 {
+			reached[atproto.AchievementHundredGames] = game.URI
+		}
+		if indexedWinner(game) != did {
+			streak = 0
+			continue
+		}
+		streak++
+		if _, ok := reached[atproto.AchievementFirstWin]; !ok {
+			reached[atproto.AchievementFirstWin] = game.URI
+		}
+		switch streak {
+		case 5:
+			if _, ok := reached[atproto.AchievementWinStreak5]; !ok {
+				reached[atproto.AchievementWinStreak5] = game.URI
+			}
+		case 10:
+			if _, ok := reached[atproto.AchievementWinStreak10]; !ok {
+				reached[atproto.AchievementWinStreak10] = game.URI
+			}
+		}
+	}
+
+	if indexedWinner(finished) == did {
+		moves, err := s.gameIndex.ListMoves(ctx, finished.URI)
+		if err != nil {
+			return nil, err
+		}
+		if last := len(moves) - 1; last >= 0 && moves[last].Player == did && knightPromotionMate(moves[last].SAN) {
+			reached[atproto.AchievementKnightPromotionMate] = finished.URI
+		}
+	}
+	return reached, nil
+}
+
+// knightPromotionMate reports whether a move in SAN promotes a pawn to a
+// knight and gives checkmate, e.g. "exf8=N#"
+func knightPromotionMate(san string) bool {
+	return strings.Contains(san, "=N") && strings.HasSuffix(strings.TrimRight(san, "!?"), "#")
+}
+
+// GetPlayerAchievementsHandler lists the achievements in a player's
+// repository, oldest first
+func (s *Service) GetPlayerAchievementsHandler(w http.ResponseWriter, r *http.Request) {
+	did := mux.Vars(r)["did"]
+	if !didPattern.MatchString(did) {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid DID"))
+		return
+	}
+
+	records, err := s.client.ListAchievements(r.Context(), did)
+	if err != nil {
+		log.Error().Err(err).Str("did", did).Msg("Failed to list achievements")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list achievements"))
+		return
+	}
+	achievements := make([]Achievement, 0, len(records))
+	for _, record := range records {
+		achievements = append(achievements, describeAchievement(record))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"did":          did,
+		"achievements": achievements,
+	})
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+func TestAchievementsAwardedFromIndexedGames(t *testing.T) {
+	ctx := context.Background()
+	game := newLiveGame(t, DisconnectPolicyPause, time.Hour)
+	service := game.service
+	service.SetHub(game.hub)
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	service.SetGameIndex(indexer)
+	white := game.connectPlayerChannel(t, testWhiteDID)
+
+	// White wins the first five games, the rest are drawn until white wins
+	// the hundredth by promoting to a knight with mate
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var last *index.Game
+	for i := 0; i < 100; i++ {
+		status := "draw"
+		if i < 5 || i == 99 {
+			status = "white_won"
+		}
+		path := fmt.Sprintf("%s/g%03d", index.GameCollection, i)
+		err := indexer.Apply(ctx, "create", testWhiteDID, path, map[string]interface{}{
+			"createdAt": start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+			"white":     testWhiteDID,
+			"black":     testBlackDID,
+			"status":    status,
+		})
+		if err != nil {
+			t.Fatalf("Failed to index game: %v", err)
+		}
+		last = &index.Game{URI: "at://" + testWhiteDID + "/" + path, White: testWhiteDID, Black: testBlackDID, Status: status}
+	}
+	err := indexer.Apply(ctx, "create", testWhiteDID, index.MoveCollection+"/m1", map[string]interface{}{
+		"createdAt": start.Add(99 * time.Hour).Format(time.RFC3339),
+		"game":      map[string]interface{}{"uri": last.URI},
+		"player":    testWhiteDID,
+		"san":       "exf8=N#",
+	})
+	if err != nil {
+		t.Fatalf("Failed to index move: %v", err)
+	}
+
+	service.AwardAchievements(ctx, last)
+
+	achievements := func() []Achievement {
+		var body struct {
+			Achievements []Achievement `json:"achievements"`
+		}
+		w := httptest.NewRecorder()
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/api/players/"+testWhiteDID+"/achievements", nil), map[string]string{"did": testWhiteDID})
+		service.GetPlayerAchievementsHandler(w, req)
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return body.Achievements
+	}
+	earned := make(map[string]Achievement)
+	for _, achievement := range achievements() {
+		earned[achievement.Kind] = achievement
+	}
+	_, streak10 := earned[atproto.AchievementWinStreak10]
+	_, knightMate := earned[atproto.AchievementKnightPromotionMate]
+	if len(earned) != 4 || streak10 || !knightMate {
+		t.Fatalf("Expected white's first win, streak of five, hundred games and knight mate, got %+v", earned)
+	}
+	if got := earned[atproto.AchievementWinStreak5]; got.Game != "at://"+testWhiteDID+"/"+index.GameCollection+"/g004" || got.Title == "" {
+		t.Errorf("Expected the streak to be reached in the fifth game, got %+v", got)
+	}
+	if got := earned[atproto.AchievementKnightPromotionMate]; got.Game != last.URI {
+		t.Errorf("Expected the knight mate in the last game, got %+v", got)
+	}
+	if frame := readFrame(t, white, NotificationAchievement); frame.Data["kind"] != atproto.AchievementFirstWin || frame.Data["title"] != "First win" {
+		t.Errorf("Expected white to be told of the first achievement, got %+v", frame.Data)
+	}
+	for i := 1; i < len(earned); i++ {
+		readFrame(t, white, NotificationAchievement)
+	}
+
+	// Black, drawing or losing every game, only reached a hundred games
+	if records := game.blackPDS.collection(testBlackDID, "app.atchess.achievement"); len(records) != 1 {
+		t.Errorf("Expected one achievement for black, got %v", records)
+	}
+
+	// Seeing the game finish again awards nothing new
+	service.AwardAchievements(ctx, last)
+	if n := len(achievements()); n != 4 {
+		t.Errorf("Expected achievements to be held once, got %d", n)
+	}
+	expectSilence(t, white, NotificationAchievement)
+}
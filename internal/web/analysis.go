@@ -0,0 +1,152 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/bot"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// AnalysisEngine evaluates positions at full strength; bot.Engine is one
+type AnalysisEngine interface {
+	Analyze(ctx context.Context, fen string, depth int) (*bot.Score, error)
+}
+
+// GameAnalyzer runs finished games through a UCI engine for post-game
+// analysis reports
+type GameAnalyzer struct {
+	engine AnalysisEngine
+	name   string
+	depth  int
+}
+
+// NewGameAnalyzer creates an analyzer that searches each position to depth
+// with engine, which is credited in reports by name
+func NewGameAnalyzer(engine AnalysisEngine, name string, depth int) *GameAnalyzer {
+	return &GameAnalyzer{engine: engine, name: name, depth: depth}
+}
+
+// EvaluatePosition scores a position for chess.AnalyzeGame
+func (a *GameAnalyzer) EvaluatePosition(ctx context.Context, fen string) (*chess.Evaluation, error) {
+	score, err := a.engine.Analyze(ctx, fen, a.depth)
+	if err != nil {
+		return nil, err
+	}
+	whiteToMove := !strings.Contains(fen, " b ")
+	return &chess.Evaluation{
+		FEN:      fen,
+		Depth:    a.depth,
+		Score:    chess.UCIScore(whiteToMove, score.Centipawns, score.Mate),
+		Mate:     score.Mate != 0,
+		BestMove: score.BestMove,
+	}, nil
+}
+
+// SetAnalyzer enables post-game computer analysis
+func (s *Service) SetAnalyzer(analyzer *GameAnalyzer) {
+	s.analyzer = analyzer
+}
+
+// AnalyzeGameHandler runs every position of one of the caller's finished
+// games through the engine, and publishes the centipawn loss and
+// classification of each move as an app.atchess.analysis record in the
+// caller's repository
+func (s *Service) AnalyzeGameHandler(w http.ResponseWriter, r *http.Request) {
+	if s.analyzer == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Computer analysis is not enabled"))
+		return
+	}
+
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	client := s.clientFor(r)
+	game, ok := s.loadGameForPlayer(w, client.GetGame, gameID, client.GetDID())
+	if !ok {
+		return
+	}
+	if game.Status == chess.StatusActive {
+		apierror.Write(w, apierror.ErrGameInProgress)
+		return
+	}
+
+	moves, err := client.GetMoves(context.Background(), gameID)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to load moves for analysis")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to load moves"))
+		return
+	}
+
+	analysis, err := chess.AnalyzeGame(context.Background(), s.analyzer, chess.StartingFEN, moves)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to analyse game")
+		apierror.Write(w, apierror.ErrUnavailable.WithMessage("Failed to analyse game"))
+		return
+	}
+
+	record := &atproto.AnalysisRecord{
+		CreatedAt:    time.Now().UTC().Format(time.RFC3339),
+		Game:         gameID,
+		Engine:       s.analyzer.name,
+		Depth:        s.analyzer.depth,
+		GameAnalysis: analysis,
+	}
+	if err := client.PublishAnalysis(context.Background(), record); err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to publish analysis")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to save analysis"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(record)
+}
+
+// GetAnalysisHandler returns a game's computer analysis. The caller's own
+// analysis is preferred, then either player's.
+func (s *Service) GetAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	client := s.readerFor(r)
+	game, err := client.GetGame(context.Background(), gameID)
+	if err != nil {
+		apierror.Write(w, apierror.ErrGameNotFound)
+		return
+	}
+
+	checked := make(map[string]bool)
+	for _, did := range []string{client.GetDID(), game.White, game.Black} {
+		if checked[did] {
+			continue
+		}
+		checked[did] = true
+
+		record, err := client.GetAnalysis(context.Background(), did, gameID)
+		if err != nil {
+			log.Warn().Err(err).Str("gameID", gameID).Str("repo", did).Msg("Failed to read analysis")
+			continue
+		}
+		if record != nil {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(record)
+			return
+		}
+	}
+
+	apierror.Write(w, apierror.ErrNotFound.WithMessage("Game has not been analysed"))
+}
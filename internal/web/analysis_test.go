@@ -0,0 +1,97 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/bot"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// foolsMateEngine sees the mate after 2. g4 and calls every other position
+// equal
+type foolsMateEngine struct{ depths []int }
+
+func (e *foolsMateEngine) Analyze(ctx context.Context, fen string, depth int) (*bot.Score, error) {
+	e.depths = append(e.depths, depth)
+	if strings.HasPrefix(fen, "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/") {
+		return &bot.Score{Mate: 1, BestMove: "d8h4"}, nil
+	}
+	return &bot.Score{BestMove: "e2e4"}, nil
+}
+
+func TestAnalyzeGamePublishesAnalysis(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	gameID := seedFoolsMate(pds, "black_won")
+	service := newServiceForPDS(t, pds)
+
+	w := reviewRequest(service.AnalyzeGameHandler, service, "POST", gameID, ownSession(service), nil)
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "disabled" {
+		t.Fatalf("Expected analysis to be disabled, got %d: %s", w.Code, w.Body.String())
+	}
+
+	engine := &foolsMateEngine{}
+	service.SetAnalyzer(NewGameAnalyzer(engine, "stockfish", 12))
+	w = reviewRequest(service.AnalyzeGameHandler, service, "POST", gameID, ownSession(service), nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected the game to be analysed, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(engine.depths) != 4 || engine.depths[0] != 12 {
+		t.Errorf("Expected every position but the mate to be searched to depth 12, got %v", engine.depths)
+	}
+
+	var analysis atproto.AnalysisRecord
+	_ = json.Unmarshal(w.Body.Bytes(), &analysis)
+	if analysis.GameAnalysis == nil || len(analysis.Moves) != 4 {
+		t.Fatalf("Expected four analysed moves, got %s", w.Body.String())
+	}
+	blunder := analysis.Moves[2]
+	if blunder.SAN != "g4" || blunder.Classification != chess.ClassBlunder || blunder.BestSAN != "e4" {
+		t.Errorf("Expected 2. g4 to be a blunder where e4 was best, got %+v", blunder)
+	}
+	if analysis.White.Blunders != 1 || analysis.Black.AverageLoss != 0 {
+		t.Errorf("Expected one white blunder and perfect play by black, got %+v / %+v", analysis.White, analysis.Black)
+	}
+
+	record := pds.get(analysis.URI)
+	if record["$type"] != "app.atchess.analysis" || record["game"] != gameID || record["engine"] != "stockfish" {
+		t.Errorf("Expected an app.atchess.analysis record, got %v", record)
+	}
+	if !strings.HasPrefix(analysis.URI, "at://"+testBlackDID+"/app.atchess.analysis/") {
+		t.Errorf("Expected the analysis in the caller's repository, got %s", analysis.URI)
+	}
+
+	// The opponent reads the same analysis from the analysing player's repository
+	pds.did = testWhiteDID
+	opponent := newServiceForPDS(t, pds)
+	w = reviewRequest(opponent.GetAnalysisHandler, opponent, "GET", gameID, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the analysis, got %d: %s", w.Code, w.Body.String())
+	}
+	var fetched atproto.AnalysisRecord
+	_ = json.Unmarshal(w.Body.Bytes(), &fetched)
+	if fetched.URI != analysis.URI || fetched.GameAnalysis == nil || fetched.White.Blunders != 1 {
+		t.Errorf("Expected the published analysis, got %s", w.Body.String())
+	}
+}
+
+func TestAnalyzeGameRejectsUnfinishedGames(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	gameID := seedFoolsMate(pds, "active")
+	service := newServiceForPDS(t, pds)
+	service.SetAnalyzer(NewGameAnalyzer(&foolsMateEngine{}, "stockfish", 12))
+
+	w := reviewRequest(service.AnalyzeGameHandler, service, "POST", gameID, ownSession(service), nil)
+	if w.Code != http.StatusConflict || errorCode(t, w) != "game_in_progress" {
+		t.Errorf("Expected game_in_progress, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = reviewRequest(service.GetAnalysisHandler, service, "GET", gameID, "", nil)
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "not_found" {
+		t.Errorf("Expected no analysis yet, got %d: %s", w.Code, w.Body.String())
+	}
+}
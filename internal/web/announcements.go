@@ -0,0 +1,391 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/rs/zerolog/log"
+)
+
+// Announcement levels
+const (
+	AnnouncementInfo        = "info"
+	AnnouncementWarning     = "warning"
+	AnnouncementMaintenance = "maintenance"
+)
+
+// maxAnnouncementLength bounds the message of an announcement, and
+// maxAnnouncementTitleLength its title, as in the lexicon
+const (
+	maxAnnouncementLength      = 2000
+	maxAnnouncementTitleLength = 200
+)
+
+// Announcement is an operator message shown to everyone using the instance.
+// It's published as an app.atchess.announcement record in the instance's
+// repository, whose signed commit proves the instance posted it; URI and CID
+// identify the record, and com.atproto.sync.getRecord returns the proof.
+type Announcement struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Message   string     `json:"message"`
+	Level     string     `json:"level"`
+	Author    string     `json:"author"` // DID of the operator who posted it
+	CreatedAt time.Time  `json:"createdAt"`
+	StartsAt  *time.Time `json:"startsAt,omitempty"` // e.g. start of a maintenance window
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	URI       string     `json:"uri,omitempty"`
+	CID       string     `json:"cid,omitempty"`
+}
+
+// record returns the announcement as an app.atchess.announcement record
+func (a *Announcement) record() *atproto.AnnouncementRecord {
+	record := &atproto.AnnouncementRecord{
+		CreatedAt: a.CreatedAt.UTC().Format(time.RFC3339),
+		Title:     a.Title,
+		Message:   a.Message,
+		Level:     a.Level,
+		Author:    a.Author,
+	}
+	if a.StartsAt != nil {
+		record.StartsAt = a.StartsAt.UTC().Format(time.RFC3339)
+	}
+	if a.ExpiresAt != nil {
+		record.ExpiresAt = a.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return record
+}
+
+// announcementFromRecord reads an announcement back from its record
+func announcementFromRecord(record *atproto.AnnouncementRecord) *Announcement {
+	announcement := &Announcement{
+		ID:      record.URI[strings.LastIndex(record.URI, "/")+1:],
+		Title:   record.Title,
+		Message: record.Message,
+		Level:   record.Level,
+		Author:  record.Author,
+		URI:     record.URI,
+		CID:     record.CID,
+	}
+	announcement.CreatedAt, _ = time.Parse(time.RFC3339, record.CreatedAt)
+	if startsAt, err := time.Parse(time.RFC3339, record.StartsAt); err == nil {
+		announcement.StartsAt = &startsAt
+	}
+	if expiresAt, err := time.Parse(time.RFC3339, record.ExpiresAt); err == nil {
+		announcement.ExpiresAt = &expiresAt
+	}
+	return announcement
+}
+
+func (a *Announcement) expired(now time.Time) bool {
+	return a.ExpiresAt != nil && !now.Before(*a.ExpiresAt)
+}
+
+// AnnouncementStore keeps the announcements published in the instance's
+// repository and which sessions have dismissed them
+type AnnouncementStore struct {
+	announcements map[string]*Announcement
+	dismissed     map[string]map[string]bool // announcement ID -> session token -> dismissed
+	mu            sync.RWMutex
+}
+
+// NewAnnouncementStore creates an empty announcement store
+func NewAnnouncementStore() *AnnouncementStore {
+	return &AnnouncementStore{
+		announcements: make(map[string]*Announcement),
+		dismissed:     make(map[string]map[string]bool),
+	}
+}
+
+// Add stores an announcement
+func (a *AnnouncementStore) Add(announcement *Announcement) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pruneLocked(time.Now())
+	a.announcements[announcement.ID] = announcement
+}
+
+// Load replaces the announcements with those read from the repository,
+// keeping the dismissals of ones still there
+func (a *AnnouncementStore) Load(announcements []*Announcement) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.announcements = make(map[string]*Announcement, len(announcements))
+	for _, announcement := range announcements {
+		a.announcements[announcement.ID] = announcement
+	}
+	for id := range a.dismissed {
+		if _, ok := a.announcements[id]; !ok {
+			delete(a.dismissed, id)
+		}
+	}
+	a.pruneLocked(time.Now())
+}
+
+// Has reports whether an announcement exists
+func (a *AnnouncementStore) Has(id string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	_, ok := a.announcements[id]
+	return ok
+}
+
+// Remove deletes an announcement and its dismissals
+func (a *AnnouncementStore) Remove(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.announcements[id]; !ok {
+		return false
+	}
+	delete(a.announcements, id)
+	delete(a.dismissed, id)
+	return true
+}
+
+// Active returns unexpired announcements, newest first
+func (a *AnnouncementStore) Active() []*Announcement {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	now := time.Now()
+	var active []*Announcement
+	for _, announcement := range a.announcements {
+		if !announcement.expired(now) {
+			active = append(active, announcement)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].CreatedAt.After(active[j].CreatedAt)
+	})
+	return active
+}
+
+// Dismiss records that a session has dismissed an announcement
+func (a *AnnouncementStore) Dismiss(id, token string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.announcements[id]; !ok {
+		return false
+	}
+	if a.dismissed[id] == nil {
+		a.dismissed[id] = make(map[string]bool)
+	}
+	a.dismissed[id][token] = true
+	return true
+}
+
+// IsDismissed reports whether a session has dismissed an announcement
+func (a *AnnouncementStore) IsDismissed(id, token string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.dismissed[id][token]
+}
+
+func (a *AnnouncementStore) pruneLocked(now time.Time) {
+	for id, announcement := range a.announcements {
+		if announcement.expired(now) {
+			delete(a.announcements, id)
+			delete(a.dismissed, id)
+		}
+	}
+}
+
+// AnnouncementView is an announcement as seen by a particular session
+type AnnouncementView struct {
+	*Announcement
+	Dismissed bool `json:"dismissed"`
+}
+
+// CreateAnnouncementRequest is the body of POST /api/announcements
+type CreateAnnouncementRequest struct {
+	Title     string     `json:"title"`
+	Message   string     `json:"message"`
+	Level     string     `json:"level,omitempty"`
+	StartsAt  *time.Time `json:"startsAt,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// isOperator reports whether did may post announcements
+func (s *Service) isOperator(did string) bool {
+	for _, operator := range s.config.Server.OperatorDIDs {
+		if operator == did {
+			return true
+		}
+	}
+	return false
+}
+
+// ListAnnouncementsHandler returns active announcements, flagging those this session dismissed
+func (s *Service) ListAnnouncementsHandler(w http.ResponseWriter, r *http.Request) {
+	token, _ := r.Context().Value(sessionTokenKey).(string)
+
+	views := []AnnouncementView{}
+	for _, announcement := range s.announcements.Active() {
+		dismissed := token != "" && s.announcements.IsDismissed(announcement.ID, token)
+		if dismissed && r.URL.Query().Get("includeDismissed") != "true" {
+			continue
+		}
+		views = append(views, AnnouncementView{Announcement: announcement, Dismissed: dismissed})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"announcements": views,
+	})
+}
+
+// CreateAnnouncementHandler lets an operator post an announcement, which is
+// broadcast to everyone subscribed to the announcements channel
+func (s *Service) CreateAnnouncementHandler(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(sessionTokenKey).(string); !ok {
+			apierror.Write(w, apierror.ErrUnauthorized)
+			return
+		}
+		author := s.clientFor(r).GetDID()
+		if !s.isOperator(author) {
+			apierror.Write(w, apierror.ErrNotOperator.WithMessage("Only operators can post announcements"))
+			return
+		}
+
+		var req CreateAnnouncementRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.Write(w, apierror.ErrInvalidBody)
+			return
+		}
+
+		if req.Title == "" || req.Message == "" {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("title and message are required"))
+			return
+		}
+		if len(req.Title) > maxAnnouncementTitleLength {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("title is too long"))
+			return
+		}
+		if len(req.Message) > maxAnnouncementLength {
+			apierror.Write(w, apierror.ErrMessageTooLong)
+			return
+		}
+		switch req.Level {
+		case "":
+			req.Level = AnnouncementInfo
+		case AnnouncementInfo, AnnouncementWarning, AnnouncementMaintenance:
+		default:
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("level must be info, warning or maintenance"))
+			return
+		}
+		now := time.Now()
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("expiresAt must be in the future"))
+			return
+		}
+
+		announcement := &Announcement{
+			ID:        newAnnouncementID(),
+			Title:     req.Title,
+			Message:   req.Message,
+			Level:     req.Level,
+			Author:    author,
+			CreatedAt: now,
+			StartsAt:  req.StartsAt,
+			ExpiresAt: req.ExpiresAt,
+		}
+
+		// Publishing it in our repository signs it and keeps it across restarts
+		record := announcement.record()
+		if err := s.client.PublishAnnouncement(r.Context(), announcement.ID, record); err != nil {
+			log.Error().Err(err).Str("author", author).Msg("Failed to publish announcement")
+			apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to publish announcement"))
+			return
+		}
+		announcement.URI, announcement.CID = record.URI, record.CID
+		s.announcements.Add(announcement)
+
+		hub.BroadcastGameUpdate(GameUpdate{
+			Type: "announcement",
+			Data: announcement,
+		})
+
+		log.Info().Str("id", announcement.ID).Str("author", author).Str("level", announcement.Level).Msg("Announcement posted")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(announcement)
+	}
+}
+
+// DeleteAnnouncementHandler lets an operator withdraw an announcement
+func (s *Service) DeleteAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.Context().Value(sessionTokenKey).(string); !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+	if !s.isOperator(s.clientFor(r).GetDID()) {
+		apierror.Write(w, apierror.ErrNotOperator.WithMessage("Only operators can remove announcements"))
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if !s.announcements.Has(id) {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Announcement not found"))
+		return
+	}
+	if err := s.client.DeleteAnnouncement(r.Context(), id); err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to delete announcement")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to delete announcement"))
+		return
+	}
+	s.announcements.Remove(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LoadAnnouncements reads back the announcements the instance published
+// earlier
+func (s *Service) LoadAnnouncements(ctx context.Context) error {
+	records, err := s.client.ListAnnouncements(ctx)
+	if err != nil {
+		return err
+	}
+
+	announcements := make([]*Announcement, 0, len(records))
+	for _, record := range records {
+		announcements = append(announcements, announcementFromRecord(record))
+	}
+	s.announcements.Load(announcements)
+	return nil
+}
+
+// DismissAnnouncementHandler hides an announcement for the current session
+func (s *Service) DismissAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := r.Context().Value(sessionTokenKey).(string)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	if !s.announcements.Dismiss(mux.Vars(r)["id"], token) {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Announcement not found"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newAnnouncementID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
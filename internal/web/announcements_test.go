@@ -0,0 +1,137 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+func TestAnnouncementsPostedByOperatorAndDismissedPerSession(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	service.config.Server.OperatorDIDs = []string{testBlackDID}
+
+	newSession := func(did string) string {
+		client, err := atproto.NewClient(newFakePDS(t, did).URL, "user", "password")
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		token, _ := service.Sessions().CreateForDevice(client, "")
+		return token
+	}
+	operator := newSession(testBlackDID)
+	player := newSession(testWhiteDID)
+
+	do := func(method, path, token string, handler http.HandlerFunc, body interface{}, vars map[string]string) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(reqBody))
+		if token != "" {
+			req.Header.Set(SessionHeader, token)
+		}
+		if vars != nil {
+			req = mux.SetURLVars(req, vars)
+		}
+		w := httptest.NewRecorder()
+		service.SessionMiddleware(handler).ServeHTTP(w, req)
+		return w
+	}
+	create := service.CreateAnnouncementHandler(NewHub())
+	list := func(token string) []AnnouncementView {
+		var resp struct {
+			Announcements []AnnouncementView `json:"announcements"`
+		}
+		w := do("GET", "/api/announcements?includeDismissed=true", token, service.ListAnnouncementsHandler, nil, nil)
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to parse announcements: %v", err)
+		}
+		return resp.Announcements
+	}
+
+	expires := time.Now().Add(time.Hour)
+	req := CreateAnnouncementRequest{Title: "Maintenance", Message: "Back in 10 minutes", Level: AnnouncementMaintenance, ExpiresAt: &expires}
+
+	if w := do("POST", "/api/announcements", player, create, req, nil); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-operator, got %d", w.Code)
+	}
+	if w := do("POST", "/api/announcements", operator, create, CreateAnnouncementRequest{Title: "x", Message: "y", Level: "urgent"}, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown level, got %d", w.Code)
+	}
+
+	w := do("POST", "/api/announcements", operator, create, req, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected announcement to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	var posted Announcement
+	_ = json.Unmarshal(w.Body.Bytes(), &posted)
+	if posted.Author != testBlackDID || posted.Level != AnnouncementMaintenance {
+		t.Errorf("Unexpected announcement: %+v", posted)
+	}
+
+	// It's published in the instance's repository, which signs it
+	records := pds.collection(testWhiteDID, "app.atchess.announcement")
+	if len(records) != 1 || posted.URI != records[0] || posted.CID == "" {
+		t.Fatalf("Expected the announcement's record to be exposed, got %+v and records %v", posted, records)
+	}
+	if record := pds.get(records[0]); record["author"] != testBlackDID || record["level"] != AnnouncementMaintenance {
+		t.Errorf("Unexpected announcement record: %v", record)
+	}
+
+	// A restarted instance reads it back
+	restarted := newServiceForPDS(t, pds)
+	if err := restarted.LoadAnnouncements(context.Background()); err != nil {
+		t.Fatalf("Failed to load announcements: %v", err)
+	}
+	if active := restarted.announcements.Active(); len(active) != 1 || active[0].ID != posted.ID || active[0].ExpiresAt == nil || active[0].URI != posted.URI {
+		t.Errorf("Expected the announcement after a restart, got %+v", active)
+	}
+
+	if w := do("POST", "/api/announcements/"+posted.ID+"/dismiss", "", service.DismissAnnouncementHandler, nil, map[string]string{"id": posted.ID}); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 dismissing without a session, got %d", w.Code)
+	}
+	if w := do("POST", "/api/announcements/"+posted.ID+"/dismiss", player, service.DismissAnnouncementHandler, nil, map[string]string{"id": posted.ID}); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected dismissal, got %d", w.Code)
+	}
+
+	if views := list(player); len(views) != 1 || !views[0].Dismissed {
+		t.Errorf("Expected announcement dismissed for the player, got %+v", views)
+	}
+	if views := list(operator); len(views) != 1 || views[0].Dismissed {
+		t.Errorf("Expected announcement still visible to other sessions, got %+v", views)
+	}
+	w = do("GET", "/api/announcements", player, service.ListAnnouncementsHandler, nil, nil)
+	if bytes.Contains(w.Body.Bytes(), []byte(posted.ID)) {
+		t.Errorf("Expected dismissed announcement to be hidden by default: %s", w.Body.String())
+	}
+
+	if w := do("DELETE", "/api/announcements/"+posted.ID, operator, service.DeleteAnnouncementHandler, nil, map[string]string{"id": posted.ID}); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected announcement to be removed, got %d", w.Code)
+	}
+	if views := list(""); len(views) != 0 {
+		t.Errorf("Expected no announcements after removal, got %+v", views)
+	}
+	if records := pds.collection(testWhiteDID, "app.atchess.announcement"); len(records) != 0 {
+		t.Errorf("Expected the announcement's record to be deleted, got %v", records)
+	}
+}
+
+func TestAnnouncementsExpire(t *testing.T) {
+	store := NewAnnouncementStore()
+	past := time.Now().Add(-time.Minute)
+	store.Add(&Announcement{ID: "old", CreatedAt: past.Add(-time.Hour), ExpiresAt: &past})
+	store.Add(&Announcement{ID: "current", CreatedAt: time.Now()})
+
+	active := store.Active()
+	if len(active) != 1 || active[0].ID != "current" {
+		t.Errorf("Expected only the unexpired announcement, got %+v", active)
+	}
+	if updateRoom(GameUpdate{Type: "announcement"}) != roomKey("", AnnouncementsChannel) {
+		t.Error("Expected announcements to be delivered to the announcements channel")
+	}
+}
@@ -0,0 +1,289 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// anticheatQueueSize is how many finished games can wait to be analysed
+	anticheatQueueSize = 256
+	// anticheatOpeningPlies are left out of the analysis: book moves match
+	// the engine whoever plays them
+	anticheatOpeningPlies = 16
+	// anticheatDecidedScore leaves out positions where one side is already
+	// this far ahead, in centipawns, as most moves there win
+	anticheatDecidedScore = 500
+	// anticheatMinMoves is the fewest moves a player must have left after
+	// that for their play to be scored
+	anticheatMinMoves = 10
+)
+
+// ratedStatuses are the results of games that count towards ratings
+var ratedStatuses = map[string]bool{"white_won": true, "black_won": true, "draw": true}
+
+// GameSource reads games and their moves from players' repositories;
+// atproto.Client is one
+type GameSource interface {
+	GetGame(ctx context.Context, gameURI string) (*chess.Game, error)
+	GetMoves(ctx context.Context, gameURI string) ([]*chess.Move, error)
+}
+
+// CheatDetector runs finished rated games through the analysis engine in the
+// background, scores how engine-like each player's moves and move times were,
+// and stores the scores in the game index for operators to review
+type CheatDetector struct {
+	analyzer *GameAnalyzer
+	indexer  *index.Indexer
+	games    GameSource
+	queue    chan string
+	queued   map[string]bool // game URIs waiting or being analysed
+	mu       sync.Mutex
+}
+
+// NewCheatDetector creates a detector that analyses games read from games
+// with analyzer; Run works through the games queued by GameFinished
+func NewCheatDetector(analyzer *GameAnalyzer, indexer *index.Indexer, games GameSource) *CheatDetector {
+	return &CheatDetector{
+		analyzer: analyzer,
+		indexer:  indexer,
+		games:    games,
+		queue:    make(chan string, anticheatQueueSize),
+		queued:   make(map[string]bool),
+	}
+}
+
+// GameFinished queues a rated game for analysis, for Indexer.OnGameFinished.
+// Games already analysed, or waiting to be, aren't queued again.
+func (d *CheatDetector) GameFinished(ctx context.Context, game *index.Game) {
+	if !ratedStatuses[game.Status] || game.White == game.Black {
+		return
+	}
+	existing, err := d.indexer.ListSuspicions(ctx, index.SuspicionQuery{Game: game.URI, Limit: 1})
+	if err == nil && len(existing) > 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.queued[game.URI] {
+		return
+	}
+	select {
+	case d.queue <- game.URI:
+		d.queued[game.URI] = true
+	default:
+		log.Warn().Str("game", game.URI).Msg("Anti-cheat queue is full; not analysing game")
+	}
+}
+
+// Run analyses queued games until ctx is done
+func (d *CheatDetector) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case gameURI := <-d.queue:
+			suspicions, err := d.AnalyzeGame(ctx, gameURI)
+			if err != nil {
+				log.Warn().Err(err).Str("game", gameURI).Msg("Failed to run anti-cheat analysis")
+			}
+			for _, suspicion := range suspicions {
+				log.Info().Str("game", gameURI).Str("player", suspicion.Player).Int("score", suspicion.Score).Msg("Scored game for anti-cheat review")
+			}
+			d.mu.Lock()
+			delete(d.queued, gameURI)
+			d.mu.Unlock()
+		}
+	}
+}
+
+// AnalyzeGame scores both players of a finished game and stores the scores
+// in the index. Players who are bots, games against the built-in engine and
+// variant games aren't scored, nor are players with too few moves to judge.
+func (d *CheatDetector) AnalyzeGame(ctx context.Context, gameURI string) ([]*index.Suspicion, error) {
+	game, err := d.games.GetGame(ctx, gameURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load game: %w", err)
+	}
+	if game.Bot != nil || game.Variant != "" {
+		return nil, nil
+	}
+	moves, err := d.games.GetMoves(ctx, gameURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load moves: %w", err)
+	}
+	if len(moves) <= anticheatOpeningPlies {
+		return nil, nil
+	}
+
+	analysis, err := chess.AnalyzeGame(ctx, d.analyzer, chess.StartingFEN, moves)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyse game: %w", err)
+	}
+	// Move times only say something when there's a clock to play against
+	timed := game.TimeControl != nil && game.TimeControl.Initial > 0
+	timing := gameTiming(game, moves, time.Now())
+
+	bots := make(map[string]bool)
+	for _, did := range game.BotAccounts {
+		bots[did] = true
+	}
+
+	var suspicions []*index.Suspicion
+	for color, player := range map[string]string{"white": game.White, "black": game.Black} {
+		if bots[player] {
+			continue
+		}
+		suspicion := scoreSuspicion(analysis, timing, color, timed)
+		if suspicion == nil {
+			continue
+		}
+		suspicion.GameURI, suspicion.Player, suspicion.AnalyzedAt = gameURI, player, time.Now()
+		if err := d.indexer.PutSuspicion(ctx, suspicion); err != nil {
+			return suspicions, fmt.Errorf("failed to store suspicion: %w", err)
+		}
+		suspicions = append(suspicions, suspicion)
+	}
+	return suspicions, nil
+}
+
+// scoreSuspicion rates one side's play from 0 to 100 by how often it matched
+// the engine's first choice, how few centipawns it lost, and, in timed games,
+// how little its move times varied. It returns nil if too few moves are left
+// once the opening and decided positions are set aside.
+func scoreSuspicion(analysis *chess.GameAnalysis, timing *GameTiming, color string, timed bool) *index.Suspicion {
+	var moves, matches, loss int
+	var times []float64
+	for i, move := range analysis.Moves {
+		if move.Color != color || move.Ply <= anticheatOpeningPlies {
+			continue
+		}
+		if move.EvalBefore > anticheatDecidedScore || move.EvalBefore < -anticheatDecidedScore {
+			continue
+		}
+		moves++
+		loss += move.Loss
+		if move.BestMove == "" {
+			matches++
+		}
+		if i < len(timing.Moves) {
+			times = append(times, float64(timing.Moves[i].ThinkMs))
+		}
+	}
+	if moves < anticheatMinMoves {
+		return nil
+	}
+
+	suspicion := &index.Suspicion{
+		Color:       color,
+		Moves:       moves,
+		EngineMatch: float64(matches) / float64(moves),
+		AverageLoss: int(math.Round(float64(loss) / float64(moves))),
+	}
+	// Matching the engine more than half the time starts to stand out, and
+	// nine times in ten is as much as counts; likewise an average loss under
+	// 60 centipawns, down to 10, and move times varying less than their
+	// average, down to a fifth of it
+	matchScore := clamp01((suspicion.EngineMatch - 0.5) / 0.4)
+	lossScore := clamp01(float64(60-suspicion.AverageLoss) / 50)
+	score := 0.6*matchScore + 0.4*lossScore
+
+	if mean, variation, ok := timeVariation(times); timed && ok {
+		suspicion.MoveTimeMs = int64(math.Round(mean))
+		suspicion.MoveTimeVariation = math.Round(variation*100) / 100
+		timeScore := clamp01((1 - variation) / 0.8)
+		score = 0.5*matchScore + 0.3*lossScore + 0.2*timeScore
+	}
+	suspicion.Score = int(math.Round(100 * score))
+	return suspicion
+}
+
+// timeVariation returns the average of move times and their coefficient of
+// variation, the standard deviation over the average
+func timeVariation(times []float64) (mean, variation float64, ok bool) {
+	if len(times) < anticheatMinMoves {
+		return 0, 0, false
+	}
+	for _, t := range times {
+		mean += t
+	}
+	mean /= float64(len(times))
+	if mean <= 0 {
+		return 0, 0, false
+	}
+	var squares float64
+	for _, t := range times {
+		squares += (t - mean) * (t - mean)
+	}
+	return mean, math.Sqrt(squares/float64(len(times))) / mean, true
+}
+
+func clamp01(x float64) float64 {
+	return math.Max(0, math.Min(1, x))
+}
+
+// SetCheatDetector enables anti-cheat analysis of finished games
+func (s *Service) SetCheatDetector(detector *CheatDetector) {
+	s.cheatDetector = detector
+}
+
+// FlaggedGamesHandler lists players' anti-cheat scores to operators, highest
+// first. Only scores of at least anticheat.flag_score are listed unless
+// minScore says otherwise; player narrows the list to one player.
+func (s *Service) FlaggedGamesHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.Context().Value(sessionTokenKey).(string); !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+	if !s.isOperator(s.clientFor(r).GetDID()) {
+		apierror.Write(w, apierror.ErrNotOperator.WithMessage("Only operators can review flagged games"))
+		return
+	}
+	if s.cheatDetector == nil || s.gameIndex == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Anti-cheat analysis is not enabled"))
+		return
+	}
+
+	params := r.URL.Query()
+	query := index.SuspicionQuery{Player: params.Get("player"), MinScore: s.config.AntiCheat.FlagScore}
+	if minScore := params.Get("minScore"); minScore != "" {
+		n, err := strconv.Atoi(minScore)
+		if err != nil || n < 0 || n > 100 {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("minScore must be between 0 and 100"))
+			return
+		}
+		query.MinScore = n
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			apierror.Write(w, apierror.ErrInvalidLimit)
+			return
+		}
+		query.Limit = n
+	}
+
+	suspicions, err := s.gameIndex.ListSuspicions(r.Context(), query)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list flagged games")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list flagged games"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"games": suspicions,
+	})
+}
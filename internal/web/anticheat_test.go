@@ -0,0 +1,155 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/bot"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+// staticGames serves one game and its moves
+type staticGames struct {
+	game  *chess.Game
+	moves []*chess.Move
+}
+
+func (g *staticGames) GetGame(ctx context.Context, gameURI string) (*chess.Game, error) {
+	if gameURI != g.game.ID {
+		return nil, fmt.Errorf("no game %s", gameURI)
+	}
+	return g.game, nil
+}
+
+func (g *staticGames) GetMoves(ctx context.Context, gameURI string) ([]*chess.Move, error) {
+	return g.moves, nil
+}
+
+// openingEngine wants 1. e4 from white and 1... e5 from black, and calls
+// every position equal
+type openingEngine struct{}
+
+func (openingEngine) Analyze(ctx context.Context, fen string, depth int) (*bot.Score, error) {
+	if strings.Contains(fen, " w ") {
+		return &bot.Score{BestMove: "e2e4"}, nil
+	}
+	return &bot.Score{BestMove: "e7e5"}, nil
+}
+
+// engineLikeGame is 40 plies where white always plays the engine's choice
+// in the same two seconds, and black never does and takes its time
+func engineLikeGame(gameURI string) *staticGames {
+	afterE4 := "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"
+	games := &staticGames{game: &chess.Game{
+		ID:          gameURI,
+		White:       testWhiteDID,
+		Black:       testBlackDID,
+		Status:      chess.StatusWhiteWon,
+		TimeControl: &chess.TimeControl{Type: "blitz", Initial: 300},
+		CreatedAt:   "2024-01-01T00:00:00Z",
+	}}
+	for ply := 1; ply <= 40; ply++ {
+		move := &chess.Move{Ply: ply, Player: testWhiteDID, From: "e2", To: "e4", SAN: "e4", FEN: afterE4, ThinkTimeMs: 2000}
+		if ply%2 == 0 {
+			move = &chess.Move{Ply: ply, Player: testBlackDID, From: "g8", To: "f6", SAN: "Nf6", FEN: chess.StartingFEN, ThinkTimeMs: int64(1000 + 7000*(ply%6))}
+		}
+		games.moves = append(games.moves, move)
+	}
+	return games
+}
+
+func TestCheatDetectorScoresRatedGames(t *testing.T) {
+	ctx := context.Background()
+	gameURI := "at://did:plc:white/app.atchess.game/g1"
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	detector := NewCheatDetector(NewGameAnalyzer(openingEngine{}, "stockfish", 12), indexer, engineLikeGame(gameURI))
+
+	// Only finished, rated games are queued, once
+	detector.GameFinished(ctx, &index.Game{URI: "at://did:plc:white/app.atchess.game/g0", White: testWhiteDID, Black: testBlackDID, Status: "abandoned"})
+	for i := 0; i < 2; i++ {
+		detector.GameFinished(ctx, &index.Game{URI: gameURI, White: testWhiteDID, Black: testBlackDID, Status: "white_won"})
+	}
+	if queued := len(detector.queue); queued != 1 {
+		t.Fatalf("Expected one game queued, got %d", queued)
+	}
+
+	if _, err := detector.AnalyzeGame(ctx, <-detector.queue); err != nil {
+		t.Fatalf("Failed to analyse game: %v", err)
+	}
+	scores := make(map[string]*index.Suspicion)
+	suspicions, _ := indexer.ListSuspicions(ctx, index.SuspicionQuery{Game: gameURI})
+	for _, suspicion := range suspicions {
+		scores[suspicion.Color] = suspicion
+	}
+
+	white, black := scores["white"], scores["black"]
+	if white == nil || black == nil {
+		t.Fatalf("Expected both players to be scored, got %v", suspicions)
+	}
+	// The first 16 plies are the opening, leaving 12 moves each
+	if white.Moves != 12 || white.EngineMatch != 1 || white.MoveTimeMs != 2000 || white.MoveTimeVariation != 0 || white.Score != 100 {
+		t.Errorf("Expected white's play to look like an engine's, got %+v", white)
+	}
+	if black.EngineMatch != 0 || black.MoveTimeVariation < 0.5 || black.Score >= 50 {
+		t.Errorf("Expected black's play to look human, got %+v", black)
+	}
+
+	// A game already analysed isn't queued again
+	detector.GameFinished(ctx, &index.Game{URI: gameURI, White: testWhiteDID, Black: testBlackDID, Status: "white_won"})
+	if queued := len(detector.queue); queued != 0 {
+		t.Errorf("Expected the analysed game not to be queued, got %d", queued)
+	}
+}
+
+func TestFlaggedGamesListedForOperators(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	service.config.Server.OperatorDIDs = []string{"did:plc:operator"}
+	service.config.AntiCheat.FlagScore = 75
+	operator := newFakePDS(t, "did:plc:operator")
+	flagged := func(reviewer *fakePDS, query string) *http.Response {
+		return sessionRequest(t, service, reviewer, service.FlaggedGamesHandler, "GET", "/api/admin/flagged-games"+query, nil, nil, true).Result()
+	}
+
+	if resp := flagged(operator, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 while anti-cheat analysis is disabled, got %d", resp.StatusCode)
+	}
+
+	gameURI := "at://did:plc:white/app.atchess.game/g1"
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	service.SetGameIndex(indexer)
+	detector := NewCheatDetector(NewGameAnalyzer(openingEngine{}, "stockfish", 12), indexer, engineLikeGame(gameURI))
+	service.SetCheatDetector(detector)
+	if _, err := detector.AnalyzeGame(context.Background(), gameURI); err != nil {
+		t.Fatalf("Failed to analyse game: %v", err)
+	}
+
+	if resp := flagged(pds, ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for a player, got %d", resp.StatusCode)
+	}
+	list := func(query string) []*index.Suspicion {
+		var body struct {
+			Games []*index.Suspicion `json:"games"`
+		}
+		resp := flagged(operator, query)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected flagged games, got %d", resp.StatusCode)
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return body.Games
+	}
+	if games := list(""); len(games) != 1 || games[0].Player != testWhiteDID || games[0].GameURI != gameURI {
+		t.Errorf("Expected only white to be flagged, got %+v", games)
+	}
+	if games := list("?minScore=0"); len(games) != 2 {
+		t.Errorf("Expected both players with minScore=0, got %+v", games)
+	}
+	if resp := flagged(operator, "?minScore=101"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an out-of-range minScore, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/rs/zerolog/log"
+)
+
+// mainPasswordWarning tells players who logged in with their main password
+// what to do instead
+const mainPasswordWarning = "You logged in with your account's main password. Create an app password in your account settings and log in with that instead."
+
+// AppPasswordCheck says whether a password is an app password
+type AppPasswordCheck struct {
+	// Format is whether the password looks like xxxx-xxxx-xxxx-xxxx
+	Format bool `json:"format"`
+	// Verified is whether the PDS accepted the password; it's only checked
+	// when a handle is given
+	Verified bool `json:"verified"`
+	// AppPassword is whether the PDS says it's an app password
+	AppPassword bool   `json:"appPassword"`
+	Message     string `json:"message"`
+}
+
+// passwordWarning returns the warning for a player's login, if it needs one
+func passwordWarning(client *atproto.Client) string {
+	if client.UsesMainPassword() {
+		return mainPasswordWarning
+	}
+	return ""
+}
+
+// ValidateAppPasswordHandler checks a password before a player logs in with
+// it. Without a handle only its form is checked; with one, a session is
+// created to ask the PDS what kind of password it is, and ended straight away.
+func (s *Service) ValidateAppPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req AuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if req.Password == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Password is required"))
+		return
+	}
+
+	check := AppPasswordCheck{Format: atproto.LooksLikeAppPassword(req.Password)}
+	if req.Handle == "" {
+		if check.Format {
+			check.Message = "This looks like an app password."
+		} else {
+			check.Message = "App passwords look like xxxx-xxxx-xxxx-xxxx. Create one in your account settings."
+		}
+		writeAppPasswordCheck(w, check)
+		return
+	}
+
+	client, err := atproto.NewClientWithDPoP(s.config.ATProto.PDSURL, req.Handle, req.Password, s.config.ATProto.UseDPoP)
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidCredentials)
+		return
+	}
+	if err := client.DeleteSession(r.Context()); err != nil {
+		log.Warn().Err(err).Str("handle", req.Handle).Msg("Failed to end password check session")
+	}
+
+	check.Verified = true
+	check.AppPassword = client.UsesAppPassword()
+	switch {
+	case check.AppPassword:
+		check.Message = "This is an app password."
+	case client.UsesMainPassword():
+		check.Message = "This is your account's main password. Create an app password in your account settings and use that instead."
+	default:
+		check.Message = "Your PDS accepted the password but didn't say what kind it is."
+	}
+	writeAppPasswordCheck(w, check)
+}
+
+func writeAppPasswordCheck(w http.ResponseWriter, check AppPasswordCheck) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(check)
+}
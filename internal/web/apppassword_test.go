@@ -0,0 +1,91 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+func checkAppPassword(t *testing.T, service *Service, req AuthRequest) (int, AppPasswordCheck) {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	service.ValidateAppPasswordHandler(w, httptest.NewRequest("POST", "/api/auth/app-password", bytes.NewReader(body)))
+	var check AppPasswordCheck
+	_ = json.Unmarshal(w.Body.Bytes(), &check)
+	return w.Code, check
+}
+
+func TestValidateAppPasswordChecksFormat(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, testWhiteDID))
+
+	if _, check := checkAppPassword(t, service, AuthRequest{Password: "abcd-efgh-ijkl-mnop"}); !check.Format || check.Verified {
+		t.Errorf("Expected an unverified app password format, got %+v", check)
+	}
+	if _, check := checkAppPassword(t, service, AuthRequest{Password: "hunter2"}); check.Format {
+		t.Errorf("Expected a plain password to fail the format check, got %+v", check)
+	}
+	if code, _ := checkAppPassword(t, service, AuthRequest{}); code != http.StatusBadRequest {
+		t.Errorf("Expected a missing password to be refused, got %d", code)
+	}
+}
+
+func TestValidateAppPasswordAsksThePDS(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	service := newServiceForPDS(t, pds)
+	service.config.ATProto.PDSURL = pds.URL
+
+	pds.scope = atproto.ScopeAccess
+	_, check := checkAppPassword(t, service, AuthRequest{Handle: "user", Password: "hunter2"})
+	if !check.Verified || check.AppPassword {
+		t.Errorf("Expected a verified main password, got %+v", check)
+	}
+
+	pds.scope = atproto.ScopeAppPass
+	_, check = checkAppPassword(t, service, AuthRequest{Handle: "user", Password: "abcd-efgh-ijkl-mnop"})
+	if !check.Verified || !check.AppPassword {
+		t.Errorf("Expected a verified app password, got %+v", check)
+	}
+
+	pds.mu.Lock()
+	deleted := pds.deletedSessions
+	pds.mu.Unlock()
+	if deleted != 2 {
+		t.Errorf("Expected each check's session to be ended, got %d", deleted)
+	}
+}
+
+func TestLoginHandlerRefusesMainPasswords(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	pds.scope = atproto.ScopeAccess
+	service := newServiceForPDS(t, pds)
+	service.config.ATProto.PDSURL = pds.URL
+
+	login := func() (*httptest.ResponseRecorder, AuthResponse) {
+		body, _ := json.Marshal(AuthRequest{Handle: "user", Password: "hunter2"})
+		w := httptest.NewRecorder()
+		service.LoginHandler(w, httptest.NewRequest("POST", "/api/auth/login", bytes.NewReader(body)))
+		var resp AuthResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	// Allowed, with a warning, until app passwords are required
+	if w, resp := login(); w.Code != http.StatusOK || resp.Warning == "" || resp.AppPassword {
+		t.Errorf("Expected a warned login, got %d %+v", w.Code, resp)
+	}
+
+	service.config.ATProto.RequireAppPasswords = true
+	if w, _ := login(); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a main password login to be refused, got %d", w.Code)
+	}
+
+	pds.scope = atproto.ScopeAppPass
+	if w, resp := login(); w.Code != http.StatusOK || resp.Warning != "" || !resp.AppPassword {
+		t.Errorf("Expected an app password login, got %d %+v", w.Code, resp)
+	}
+}
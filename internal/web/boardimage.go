@@ -0,0 +1,96 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/boardimage"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// BoardImageHandler draws a game's position as a PNG or SVG, by the format
+// in the path, for link previews, post attachments and spectator thumbnails.
+// move=N shows the position after the Nth half-move, 0 being the start;
+// otherwise the current position is shown. The last move is highlighted.
+// orientation=black draws the board from black's side and size sets its
+// width in pixels.
+func (s *Service) BoardImageHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID, err := s.decodeGameID(vars["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	params := r.URL.Query()
+	opts := boardimage.Options{Flipped: params.Get("orientation") == "black"}
+	if size := params.Get("size"); size != "" {
+		opts.Size, err = strconv.Atoi(size)
+		if err != nil || opts.Size < 1 {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid size"))
+			return
+		}
+	}
+	ply := -1
+	if move := params.Get("move"); move != "" {
+		ply, err = strconv.Atoi(move)
+		if err != nil || ply < 0 {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid move number"))
+			return
+		}
+	}
+
+	client := s.readerFor(r)
+	game, err := client.GetGame(context.Background(), gameID)
+	if err != nil {
+		apierror.Write(w, apierror.ErrGameNotFound)
+		return
+	}
+	moves, err := client.GetMoves(context.Background(), gameID)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to load moves for board image")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to load moves"))
+		return
+	}
+
+	fen := game.FEN
+	// The current position changes as the game goes on; past ones don't
+	cacheControl := "public, max-age=60"
+	switch {
+	case ply > len(moves):
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("No such move"))
+		return
+	case ply == 0:
+		fen = chess.StartingFEN
+		cacheControl = "public, max-age=86400"
+	case ply > 0:
+		fen = moves[ply-1].FEN
+		opts.Highlight = []string{moves[ply-1].From, moves[ply-1].To}
+		cacheControl = "public, max-age=86400"
+	case len(moves) > 0 && moves[len(moves)-1].FEN == game.FEN:
+		last := moves[len(moves)-1]
+		opts.Highlight = []string{last.From, last.To}
+	}
+
+	var image []byte
+	contentType := "image/png"
+	if vars["format"] == "svg" {
+		image, err = boardimage.SVG(fen, opts)
+		contentType = "image/svg+xml"
+	} else {
+		image, err = boardimage.PNG(fen, opts)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Str("fen", fen).Msg("Failed to render board image")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to render board image"))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	_, _ = w.Write(image)
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func getBoardImage(s *Service, gameID, format, query string) *httptest.ResponseRecorder {
+	encoded := base64.URLEncoding.EncodeToString([]byte(gameID))
+	target := "/api/games/" + encoded + "/board." + format + query
+	req := mux.SetURLVars(httptest.NewRequest("GET", target, nil), map[string]string{"id": encoded, "format": format})
+	w := httptest.NewRecorder()
+	s.BoardImageHandler(w, req)
+	return w
+}
+
+func TestBoardImageHandler(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	gameID := seedFoolsMate(pds, "black_won")
+	service := newServiceForPDS(t, pds)
+
+	w := getBoardImage(service, gameID, "png", "?size=160")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Expected a PNG, got %d: %s", w.Code, w.Body.String())
+	}
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("Expected a valid PNG: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != 160 {
+		t.Errorf("Expected a 160px board, got %v", size)
+	}
+	if cache := w.Header().Get("Cache-Control"); cache != "public, max-age=60" {
+		t.Errorf("Expected the current position to be cached briefly, got %q", cache)
+	}
+
+	// Past positions never change
+	w = getBoardImage(service, gameID, "svg", "?move=2&orientation=black")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("Expected an SVG, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.HasPrefix(w.Body.String(), "<svg") || w.Header().Get("Cache-Control") != "public, max-age=86400" {
+		t.Errorf("Expected a cacheable SVG, got %q", w.Header().Get("Cache-Control"))
+	}
+	start := getBoardImage(service, gameID, "svg", "?move=0")
+	if start.Code != http.StatusOK || start.Body.String() == w.Body.String() {
+		t.Errorf("Expected the starting position to differ, got %d", start.Code)
+	}
+
+	if w = getBoardImage(service, gameID, "png", "?move=5"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 past the last move, got %d", w.Code)
+	}
+	if w = getBoardImage(service, gameID, "png", "?move=-1"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a negative move, got %d", w.Code)
+	}
+	if w = getBoardImage(service, gameID, "png", "?size=big"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid size, got %d", w.Code)
+	}
+}
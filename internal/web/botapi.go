@@ -0,0 +1,413 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/rs/zerolog/log"
+)
+
+// botEventBacklog bounds the events kept for a bot that isn't listening;
+// the oldest are dropped first
+const botEventBacklog = 100
+
+// defaultBotPollTimeout is how long a request for events waits when the
+// config doesn't say
+const defaultBotPollTimeout = 30 * time.Second
+
+// botStreamKeepAlive is how often an idle event stream writes an empty line,
+// so proxies and the bot can tell the connection is alive
+const botStreamKeepAlive = 15 * time.Second
+
+// BotToken is an API token of a bot account. Tokens only work on the bot API,
+// and act as the account that created them.
+type BotToken struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastUsed  time.Time `json:"lastUsed"`
+
+	token  string
+	client *atproto.Client
+}
+
+// BotTokenStore keeps the bot API tokens of bot accounts
+type BotTokenStore struct {
+	tokens map[string]*BotToken
+	mu     sync.RWMutex
+}
+
+// NewBotTokenStore creates an empty token store
+func NewBotTokenStore() *BotTokenStore {
+	return &BotTokenStore{tokens: make(map[string]*BotToken)}
+}
+
+// Create issues a token acting as client's account
+func (s *BotTokenStore) Create(client *atproto.Client) (string, *BotToken, error) {
+	token, err := generateSessionToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	bt := &BotToken{ID: sessionID(token), CreatedAt: now, LastUsed: now, token: token, client: client}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = bt
+	copied := *bt
+	return token, &copied, nil
+}
+
+// Get returns the client a token acts as, refreshing its last-used time
+func (s *BotTokenStore) Get(token string) (*atproto.Client, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bt, ok := s.tokens[token]
+	if !ok {
+		return nil, false
+	}
+	bt.LastUsed = time.Now()
+	return bt.client, true
+}
+
+// List returns a bot's tokens, oldest first
+func (s *BotTokenStore) List(did string) []*BotToken {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokens := []*BotToken{}
+	for _, bt := range s.tokens {
+		if bt.client.GetDID() == did {
+			copied := *bt
+			tokens = append(tokens, &copied)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens
+}
+
+// Revoke removes one of a bot's tokens by ID, reporting whether it existed
+func (s *BotTokenStore) Revoke(did, id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, bt := range s.tokens {
+		if bt.ID == id && bt.client.GetDID() == did {
+			delete(s.tokens, token)
+			return true
+		}
+	}
+	return false
+}
+
+// BotEvent is something a bot may want to act on, such as its turn to move
+// or an incoming challenge. Types are the player notification types.
+type BotEvent struct {
+	Type      string      `json:"type"`
+	GameID    string      `json:"gameId,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// botEvents queues events for bot accounts until they poll or stream them
+type botEvents struct {
+	mu      sync.Mutex
+	pending map[string][]BotEvent
+	// closed, and replaced, when an event arrives for the bot
+	arrived map[string]chan struct{}
+}
+
+func newBotEvents() *botEvents {
+	return &botEvents{
+		pending: make(map[string][]BotEvent),
+		arrived: make(map[string]chan struct{}),
+	}
+}
+
+// publish queues an event for a bot and wakes anyone waiting for it
+func (e *botEvents) publish(did string, event BotEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	queue := append(e.pending[did], event)
+	if len(queue) > botEventBacklog {
+		queue = queue[len(queue)-botEventBacklog:]
+	}
+	e.pending[did] = queue
+	if ch, ok := e.arrived[did]; ok {
+		close(ch)
+		delete(e.arrived, did)
+	}
+}
+
+// take removes a bot's queued events, and returns a channel closed when the
+// next one arrives
+func (e *botEvents) take(did string) ([]BotEvent, <-chan struct{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	events := e.pending[did]
+	delete(e.pending, did)
+	ch, ok := e.arrived[did]
+	if !ok {
+		ch = make(chan struct{})
+		e.arrived[did] = ch
+	}
+	return events, ch
+}
+
+// botAPIEnabled reports whether any accounts are configured as bots
+func (s *Service) botAPIEnabled() bool {
+	return len(s.client.BotAccounts()) > 0
+}
+
+// botPollTimeout is the longest a request for events may wait
+func (s *Service) botPollTimeout() time.Duration {
+	if s.config.BotAPI.PollTimeout > 0 {
+		return s.config.BotAPI.PollTimeout
+	}
+	return defaultBotPollTimeout
+}
+
+// BotTokenMiddleware authenticates bot API requests by their bearer token,
+// acting as the bot account that created it. Session IDs aren't accepted.
+func (s *Service) BotTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.botAPIEnabled() {
+			apierror.Write(w, apierror.ErrDisabled.WithMessage("The bot API is not enabled"))
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			apierror.Write(w, apierror.ErrUnauthorized.WithMessage("Send a bot API token as Authorization: Bearer <token>"))
+			return
+		}
+		client, ok := s.botTokens.Get(token)
+		if !ok {
+			apierror.Write(w, apierror.ErrInvalidSession.WithMessage("Invalid or revoked bot API token"))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userClientKey, client)))
+	})
+}
+
+// CreateBotTokenHandler issues a bot API token to a logged-in bot account.
+// The token is only shown once.
+func (s *Service) CreateBotTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.botAPIEnabled() {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("The bot API is not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+	if !s.client.IsBotAccount(did) {
+		apierror.Write(w, apierror.ErrForbidden.WithMessage("Only accounts configured as bots can create bot API tokens"))
+		return
+	}
+
+	token, bt, err := s.botTokens.Create(s.clientFor(r))
+	if err != nil {
+		log.Error().Err(err).Str("did", did).Msg("Failed to create bot token")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to create bot token"))
+		return
+	}
+	log.Info().Str("did", did).Str("token", bt.ID).Msg("Bot token created")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":     token,
+		"id":        bt.ID,
+		"createdAt": bt.CreatedAt,
+	})
+}
+
+// ListBotTokensHandler lists the caller's bot API tokens, without the tokens
+// themselves
+func (s *Service) ListBotTokensHandler(w http.ResponseWriter, r *http.Request) {
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"tokens": s.botTokens.List(did),
+	})
+}
+
+// RevokeBotTokenHandler revokes one of the caller's bot API tokens by ID
+func (s *Service) RevokeBotTokenHandler(w http.ResponseWriter, r *http.Request) {
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if !s.botTokens.Revoke(did, id) {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Bot token not found"))
+		return
+	}
+	log.Info().Str("did", did).Str("token", id).Msg("Bot token revoked")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BotAccountHandler describes the bot a token acts as
+func (s *Service) BotAccountHandler(w http.ResponseWriter, r *http.Request) {
+	client := s.clientFor(r)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"did":    client.GetDID(),
+		"handle": client.GetHandle(),
+		"bot":    true,
+	})
+}
+
+// BotEventsHandler long-polls for the bot's events. It answers as soon as
+// there are any, or with none after ?timeout= seconds, at most the
+// configured poll timeout.
+func (s *Service) BotEventsHandler(w http.ResponseWriter, r *http.Request) {
+	timeout := s.botPollTimeout()
+	if raw := r.URL.Query().Get("timeout"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("timeout must be a number of seconds"))
+			return
+		}
+		if wait := time.Duration(seconds) * time.Second; wait < timeout {
+			timeout = wait
+		}
+	}
+
+	did := s.clientFor(r).GetDID()
+	events, arrived := s.botEvents.take(did)
+	if len(events) == 0 && timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-arrived:
+			events, _ = s.botEvents.take(did)
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if events == nil {
+		events = []BotEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": events,
+	})
+}
+
+// BotStreamHandler streams the bot's events as newline-delimited JSON until
+// the bot disconnects. It opens with a your_move event for every active game
+// waiting on the bot, so a bot that reconnects picks up where it left off.
+func (s *Service) BotStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Streaming is not supported"))
+		return
+	}
+	// The stream outlives the server's write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	client := s.clientFor(r)
+	did := client.GetDID()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+
+	for _, event := range s.pendingTurns(r.Context(), client) {
+		_ = encoder.Encode(event)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(botStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		events, arrived := s.botEvents.take(did)
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		select {
+		case <-arrived:
+		case <-keepAlive.C:
+			if _, err := w.Write([]byte("\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// pendingTurns returns a your_move event for each of the bot's active games
+// in which it is to move
+func (s *Service) pendingTurns(ctx context.Context, client *atproto.Client) []BotEvent {
+	did := client.GetDID()
+	games, err := client.ListGames(ctx, did, "active")
+	if err != nil {
+		log.Warn().Err(err).Str("did", did).Msg("Failed to list bot's active games")
+		return nil
+	}
+
+	var events []BotEvent
+	for _, game := range games {
+		if toMove, err := game.PlayerToMove(); err != nil || toMove != did {
+			continue
+		}
+		events = append(events, BotEvent{
+			Type:   NotificationYourMove,
+			GameID: game.ID,
+			Data: map[string]interface{}{
+				"opponent": opponentOf(game, did),
+				"fen":      game.FEN,
+			},
+			CreatedAt: time.Now().UTC(),
+		})
+	}
+	return events
+}
+
+// BotMoveHandler plays the bot's move, given in UCI or SAN notation, in the
+// game with the base64-encoded ID
+func (s *Service) BotMoveHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID, err := s.decodeGameID(vars["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+	if vars["move"] == "" {
+		apierror.Write(w, apierror.ErrInvalidMove.WithMessage("Missing move"))
+		return
+	}
+	s.makeMove(w, r, MakeMoveRequest{GameID: gameID, Move: vars["move"]})
+}
@@ -0,0 +1,162 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+// newBotAPIService sets up a service on which black is a bot account, with a
+// session for the bot and one for the human playing white
+func newBotAPIService(t *testing.T) (*Service, *fakePDS, *atproto.Client, string, string) {
+	t.Helper()
+	pds := newFakePDS(t, testWhiteDID)
+	human, err := atproto.NewClient(pds.URL, "white", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	pds.did = testBlackDID
+	service := newServiceForPDS(t, pds)
+	service.client.SetBotAccounts([]string{testBlackDID})
+
+	botClient, err := atproto.NewClient(pds.URL, "black", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	botClient.SetBotAccounts(service.client.BotAccounts())
+	botSession, _ := service.Sessions().Create(botClient)
+	humanSession, _ := service.Sessions().Create(human)
+	return service, pds, botClient, botSession, humanSession
+}
+
+func botRequest(s *Service, handler http.HandlerFunc, method, target string, vars map[string]string, token string) *httptest.ResponseRecorder {
+	req := mux.SetURLVars(httptest.NewRequest(method, target, nil), vars)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.BotTokenMiddleware(handler).ServeHTTP(w, req)
+	return w
+}
+
+func createBotToken(t *testing.T, s *Service, session string) string {
+	t.Helper()
+	w := serveAs(s.CreateBotTokenHandler, s, session, "/api/bot-tokens", nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected a bot token, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp.Token
+}
+
+func TestBotTokens(t *testing.T) {
+	service, _, _, botSession, humanSession := newBotAPIService(t)
+
+	w := serveAs(service.CreateBotTokenHandler, service, humanSession, "/api/bot-tokens", nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected only bot accounts to get tokens, got %d", w.Code)
+	}
+
+	token := createBotToken(t, service, botSession)
+	w = botRequest(service, service.BotAccountHandler, "GET", "/api/v1/bot/account", nil, token)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), testBlackDID) {
+		t.Fatalf("Expected the token to act as the bot, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = botRequest(service, service.BotAccountHandler, "GET", "/api/v1/bot/account", nil, botSession); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a session ID not to work as a bot token, got %d", w.Code)
+	}
+
+	w = serveAs(service.ListBotTokensHandler, service, botSession, "/api/bot-tokens", nil)
+	var list struct {
+		Tokens []BotToken `json:"tokens"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Tokens) != 1 || strings.Contains(w.Body.String(), token) {
+		t.Fatalf("Expected one token listed without its secret, got %s", w.Body.String())
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/api/bot-tokens/"+list.Tokens[0].ID, nil), map[string]string{"id": list.Tokens[0].ID})
+	req.Header.Set(SessionHeader, botSession)
+	w = httptest.NewRecorder()
+	service.SessionMiddleware(http.HandlerFunc(service.RevokeBotTokenHandler)).ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected the token to be revoked, got %d", w.Code)
+	}
+	if w = botRequest(service, service.BotAccountHandler, "GET", "/api/v1/bot/account", nil, token); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a revoked token to be rejected, got %d", w.Code)
+	}
+}
+
+func TestBotPlaysThroughEventsAndMoves(t *testing.T) {
+	service, pds, _, botSession, humanSession := newBotAPIService(t)
+	gameID := seedGame(pds, startFEN, "active")
+	token := createBotToken(t, service, botSession)
+
+	w := serveAs(service.MakeMoveHandler, service, humanSession, "/api/moves", map[string]string{"move": "e2e4", "game_id": gameID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the human's move to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = botRequest(service, service.BotEventsHandler, "GET", "/api/v1/bot/events?timeout=0", nil, token)
+	var resp struct {
+		Events []BotEvent `json:"events"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || len(resp.Events) != 1 || resp.Events[0].Type != NotificationYourMove || resp.Events[0].GameID != gameID {
+		t.Fatalf("Expected a your_move event, got %d: %s", w.Code, w.Body.String())
+	}
+
+	vars := map[string]string{"id": base64.URLEncoding.EncodeToString([]byte(gameID)), "move": "e7e5"}
+	w = botRequest(service, service.BotMoveHandler, "POST", "/api/v1/bot/games/x/move/e7e5", vars, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the bot's move to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if moves := pds.collection(testBlackDID, "app.atchess.move"); len(moves) != 1 {
+		t.Errorf("Expected the bot's move to be recorded in its repository, got %v", moves)
+	}
+}
+
+func TestBotEventsLongPoll(t *testing.T) {
+	service, _, _, botSession, _ := newBotAPIService(t)
+	token := createBotToken(t, service, botSession)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- botRequest(service, service.BotEventsHandler, "GET", "/api/v1/bot/events?timeout=5", nil, token)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	service.notifyPlayer(testBlackDID, NotificationChallenge, "", map[string]string{"challenger": testWhiteDID})
+
+	select {
+	case w := <-done:
+		if !strings.Contains(w.Body.String(), `"type":"challenge"`) {
+			t.Errorf("Expected the challenge to be delivered, got %s", w.Body.String())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the poll to answer as soon as an event arrived")
+	}
+}
+
+func TestGamesRecordBotAccounts(t *testing.T) {
+	_, pds, botClient, _, _ := newBotAPIService(t)
+
+	game, err := botClient.CreateGame(context.Background(), testWhiteDID, "black")
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	bots, _ := pds.get(game.ID)["botAccounts"].([]interface{})
+	if len(bots) != 1 || bots[0] != testBlackDID || len(game.BotAccounts) != 1 {
+		t.Errorf("Expected the bot to be marked in the game record, got %v", pds.get(game.ID))
+	}
+}
@@ -0,0 +1,243 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/bot"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// BotOpponentPrefix in a new game's opponent asks for the computer at a
+// level, e.g. "bot:level-3"
+const BotOpponentPrefix = "bot:level-"
+
+// botMoveTimeout bounds a single engine search
+const botMoveTimeout = 30 * time.Second
+
+// recentBookChoices is how many of the bot's recent book moves in a position
+// are remembered per player, to be avoided in their next games
+const recentBookChoices = 4
+
+// MoveEngine picks a move, in UCI notation, for a position
+type MoveEngine interface {
+	BestMove(ctx context.Context, fen string, level bot.Level) (string, error)
+}
+
+// BotPlayer plays the computer's side of bot games, recording its moves under
+// the service's own account
+type BotPlayer struct {
+	hub       *Hub
+	engine    MoveEngine
+	client    *atproto.Client
+	thinkTime time.Duration
+
+	book      *bot.Book
+	bookMoves []int // per-level overrides of how many moves come from the book
+	rng       *rand.Rand
+
+	mu       sync.Mutex
+	thinking map[string]bool
+	// recent book moves per player, by position, so repeated games vary
+	openings map[string]map[string][]string
+}
+
+// NewBotPlayer creates a bot that thinks for up to thinkTime per move at the
+// strongest level
+func NewBotPlayer(hub *Hub, engine MoveEngine, client *atproto.Client, thinkTime time.Duration) *BotPlayer {
+	if thinkTime <= 0 {
+		thinkTime = 2 * time.Second
+	}
+	return &BotPlayer{
+		hub:       hub,
+		engine:    engine,
+		client:    client,
+		thinkTime: thinkTime,
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		thinking:  make(map[string]bool),
+		openings:  make(map[string]map[string][]string),
+	}
+}
+
+// SetOpeningBook makes the bot play its first moves from a book. bookMoves
+// optionally sets how many moves come from the book at each level, weakest
+// first; levels it doesn't cover keep their defaults.
+func (b *BotPlayer) SetOpeningBook(book *bot.Book, bookMoves []int) {
+	b.book = book
+	b.bookMoves = bookMoves
+}
+
+// bookMove picks the bot's move from the opening book, steering away from the
+// moves it played in the same position in recent games against this player
+func (b *BotPlayer) bookMove(game *chess.Game, fen string, level bot.Level) (string, bool) {
+	if b.book == nil {
+		return "", false
+	}
+	if game.Bot.Level <= len(b.bookMoves) {
+		level.BookMoves = b.bookMoves[game.Bot.Level-1]
+	}
+	// The full move number counts the bot's own moves for either color
+	fields := strings.Fields(fen)
+	if len(fields) != 6 {
+		return "", false
+	}
+	if moveNumber, err := strconv.Atoi(fields[5]); err != nil || moveNumber > level.BookMoves {
+		return "", false
+	}
+
+	player := opponentOf(game, game.Bot.Player)
+	key := bot.PositionKey(fen)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	avoid := make(map[string]bool)
+	for _, uci := range b.openings[player][key] {
+		avoid[uci] = true
+	}
+	uci, ok := b.book.Pick(fen, b.rng, avoid)
+	if !ok {
+		return "", false
+	}
+
+	if b.openings[player] == nil {
+		b.openings[player] = make(map[string][]string)
+	}
+	recent := append(b.openings[player][key], uci)
+	if len(recent) > recentBookChoices {
+		recent = recent[len(recent)-recentBookChoices:]
+	}
+	b.openings[player][key] = recent
+	return uci, true
+}
+
+// parseBotOpponent returns the level of a "bot:level-N" opponent
+func parseBotOpponent(opponent string) (int, bool, error) {
+	if !strings.HasPrefix(opponent, "bot:") {
+		return 0, false, nil
+	}
+	level, err := strconv.Atoi(strings.TrimPrefix(opponent, BotOpponentPrefix))
+	if err != nil || !strings.HasPrefix(opponent, BotOpponentPrefix) || level < 1 || level > bot.MaxLevel {
+		return 0, true, fmt.Errorf("bot opponent must be %s1 to %s%d", BotOpponentPrefix, BotOpponentPrefix, bot.MaxLevel)
+	}
+	return level, true, nil
+}
+
+// ApplyMove stores the human's move in the game record, which is in the
+// bot's repository, and starts the bot's reply unless the game is over
+func (b *BotPlayer) ApplyMove(ctx context.Context, game *chess.Game, move *chess.MoveResult) error {
+	if err := b.client.ApplyOpponentMove(ctx, game.ID, move); err != nil {
+		return fmt.Errorf("failed to apply move to bot game: %w", err)
+	}
+	if !move.GameOver {
+		b.Reply(game, move.FEN)
+	}
+	return nil
+}
+
+// Reply plays the bot's move from a position in the background
+func (b *BotPlayer) Reply(game *chess.Game, fen string) {
+	b.mu.Lock()
+	if b.thinking[game.ID] {
+		b.mu.Unlock()
+		return
+	}
+	b.thinking[game.ID] = true
+	b.mu.Unlock()
+
+	go func() {
+		defer func() {
+			b.mu.Lock()
+			delete(b.thinking, game.ID)
+			b.mu.Unlock()
+		}()
+		if err := b.reply(game, fen); err != nil {
+			log.Error().Err(err).Str("gameID", game.ID).Msg("Bot failed to reply")
+		}
+	}()
+}
+
+func (b *BotPlayer) reply(game *chess.Game, fen string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), botMoveTimeout)
+	defer cancel()
+
+	level, err := bot.LevelSettings(game.Bot.Level, b.thinkTime)
+	if err != nil {
+		return err
+	}
+	uci, fromBook := b.bookMove(game, fen, level)
+	if !fromBook {
+		uci, err = b.engine.BestMove(ctx, fen, level)
+		if err != nil {
+			return fmt.Errorf("engine search failed: %w", err)
+		}
+	}
+	if len(uci) < 4 {
+		return fmt.Errorf("engine returned invalid move %q", uci)
+	}
+
+	engine, err := chess.NewEngineFromFEN(fen)
+	if err != nil {
+		return err
+	}
+	move, err := engine.MakeMoveUCI(uci)
+	if err != nil {
+		return fmt.Errorf("engine move %s rejected: %w", uci, err)
+	}
+	if err := b.client.RecordMove(ctx, game.ID, move); err != nil {
+		return fmt.Errorf("failed to record bot move: %w", err)
+	}
+
+	log.Info().Str("gameID", game.ID).Str("san", move.SAN).Int("level", game.Bot.Level).Bool("book", fromBook).Msg("Bot moved")
+	b.hub.BroadcastGameUpdate(GameUpdate{GameID: game.ID, Type: "move", Data: move})
+	return nil
+}
+
+// SetBot enables games against the computer
+func (s *Service) SetBot(player *BotPlayer) {
+	s.bot = player
+}
+
+// createBotGame starts a game between the logged-in user and the bot, which
+// owns the game record so it can keep the position up to date
+func (s *Service) createBotGame(w http.ResponseWriter, r *http.Request, level int, color string) {
+	if s.bot == nil {
+		apierror.Write(w, apierror.ErrUnavailable.WithMessage("Playing the computer is not enabled"))
+		return
+	}
+
+	human := s.clientFor(r).GetDID()
+	if human == s.bot.client.GetDID() {
+		apierror.Write(w, apierror.ErrUnauthorized.WithMessage("Log in to play the computer"))
+		return
+	}
+
+	// color is the human's; the bot takes the other side
+	botColor := "black"
+	if color == "black" {
+		botColor = "white"
+	}
+	game, err := s.bot.client.CreateBotGame(r.Context(), human, botColor, level)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create bot game")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to create game"))
+		return
+	}
+
+	if botColor == "white" {
+		s.bot.Reply(game, game.FEN)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(game)
+}
@@ -0,0 +1,186 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/bot"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+const testBotDID = "did:plc:bot"
+
+// scriptedEngine answers each side with a fixed move, optionally waiting for
+// release before answering
+type scriptedEngine struct {
+	release chan struct{}
+	levels  chan bot.Level
+}
+
+func (e *scriptedEngine) BestMove(ctx context.Context, fen string, level bot.Level) (string, error) {
+	if e.release != nil {
+		<-e.release
+	}
+	e.levels <- level
+	if strings.Contains(fen, " w ") {
+		return "e2e4", nil
+	}
+	return "e7e5", nil
+}
+
+// newBotService sets up a service whose own account plays the bot, and a
+// signed-in human. The fake PDS serves both accounts' records.
+func newBotService(t *testing.T, engine MoveEngine) (*Service, *fakePDS, string) {
+	t.Helper()
+	pds := newFakePDS(t, testWhiteDID)
+	human, err := atproto.NewClient(pds.URL, "white", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	pds.did = testBotDID
+	service := newServiceForPDS(t, pds)
+
+	hub := NewHub()
+	go hub.Run()
+	service.SetBot(NewBotPlayer(hub, engine, service.client, 8*time.Second))
+	token, _ := service.Sessions().Create(human)
+	return service, pds, token
+}
+
+func serveAs(handler http.HandlerFunc, s *Service, token, target string, body interface{}) *httptest.ResponseRecorder {
+	reqBody, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", target, bytes.NewReader(reqBody))
+	if token != "" {
+		req.Header.Set(SessionHeader, token)
+	}
+	w := httptest.NewRecorder()
+	s.SessionMiddleware(handler).ServeHTTP(w, req)
+	return w
+}
+
+// waitForFEN waits until the stored game reaches a position with the given
+// side to move
+func waitForFEN(t *testing.T, pds *fakePDS, gameID, side string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if fen, _ := pds.get(gameID)["fen"].(string); strings.Contains(fen, " "+side+" ") && fen != startFEN {
+			return fen
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s to move in %v", side, pds.get(gameID))
+	return ""
+}
+
+func TestBotRepliesToMoves(t *testing.T) {
+	engine := &scriptedEngine{levels: make(chan bot.Level, 4)}
+	service, pds, token := newBotService(t, engine)
+
+	w := serveAs(service.CreateGameHandler, service, token, "/api/games", map[string]string{"opponent_did": "bot:level-4", "color": "white"})
+	var game chess.Game
+	_ = json.Unmarshal(w.Body.Bytes(), &game)
+	if w.Code != http.StatusOK || game.White != testWhiteDID || game.Black != testBotDID || game.Bot == nil || game.Bot.Level != 4 {
+		t.Fatalf("Unexpected bot game: %d %s", w.Code, w.Body.String())
+	}
+	if !strings.HasPrefix(game.ID, "at://"+testBotDID+"/") {
+		t.Errorf("Expected the bot to own the game record, got %s", game.ID)
+	}
+
+	w = serveAs(service.MakeMoveHandler, service, token, "/api/moves", map[string]string{"from": "e2", "to": "e4", "game_id": game.ID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected move to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	fen := waitForFEN(t, pds, game.ID, "w")
+	if !strings.HasPrefix(fen, "rnbqkbnr/pppp1ppp/8/4p3/4P3/") {
+		t.Errorf("Expected 1. e4 e5, got %s", fen)
+	}
+	if level := <-engine.levels; level.Skill != 9 || level.MoveTime != 4*time.Second {
+		t.Errorf("Expected level 4 settings, got %+v", level)
+	}
+	if moves := pds.collection(testBotDID, "app.atchess.move"); len(moves) != 1 {
+		t.Errorf("Expected the bot's move in its repository, got %v", moves)
+	}
+}
+
+func TestBotMovesFirstAsWhite(t *testing.T) {
+	engine := &scriptedEngine{levels: make(chan bot.Level, 4)}
+	service, pds, token := newBotService(t, engine)
+
+	w := serveAs(service.CreateGameHandler, service, token, "/api/games", map[string]string{"opponent_did": "bot:level-1", "color": "black"})
+	var game chess.Game
+	_ = json.Unmarshal(w.Body.Bytes(), &game)
+	if game.White != testBotDID || game.Black != testWhiteDID {
+		t.Fatalf("Expected the bot to play white, got %s", w.Body.String())
+	}
+	waitForFEN(t, pds, game.ID, "b")
+}
+
+func TestBotMovesCannotBeMadeByHand(t *testing.T) {
+	engine := &scriptedEngine{release: make(chan struct{}), levels: make(chan bot.Level, 4)}
+	defer close(engine.release)
+	service, _, token := newBotService(t, engine)
+
+	w := serveAs(service.CreateGameHandler, service, token, "/api/games", map[string]string{"opponent_did": "bot:level-2", "color": "white"})
+	var game chess.Game
+	_ = json.Unmarshal(w.Body.Bytes(), &game)
+	serveAs(service.MakeMoveHandler, service, token, "/api/moves", map[string]string{"from": "e2", "to": "e4", "game_id": game.ID})
+
+	// While the engine thinks, a request without a session acts as the service account
+	w = serveAs(service.MakeMoveHandler, service, ownSession(service), "/api/moves", map[string]string{"from": "e7", "to": "e5", "game_id": game.ID})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a hand-made bot move, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCreateBotGameValidation(t *testing.T) {
+	service, _, token := newBotService(t, &scriptedEngine{levels: make(chan bot.Level, 4)})
+
+	w := serveAs(service.CreateGameHandler, service, token, "/api/games", map[string]string{"opponent_did": "bot:level-9"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown level, got %d", w.Code)
+	}
+	w = serveAs(service.CreateGameHandler, service, ownSession(service), "/api/games", map[string]string{"opponent_did": "bot:level-1"})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a session, got %d", w.Code)
+	}
+
+	service.SetBot(nil)
+	w = serveAs(service.CreateGameHandler, service, token, "/api/games", map[string]string{"opponent_did": "bot:level-1"})
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when the bot is disabled, got %d", w.Code)
+	}
+}
+
+func TestBotVariesBookOpeningsPerPlayer(t *testing.T) {
+	engine := &scriptedEngine{levels: make(chan bot.Level, 4)}
+	service, pds, token := newBotService(t, engine)
+	book, err := bot.NewBook([]bot.BookLine{{Moves: "e2e4", Weight: 100}, {Moves: "d2d4", Weight: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.bot.SetOpeningBook(book, nil)
+
+	firstMoves := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		w := serveAs(service.CreateGameHandler, service, token, "/api/games", map[string]string{"opponent_did": "bot:level-1", "color": "black"})
+		var game chess.Game
+		_ = json.Unmarshal(w.Body.Bytes(), &game)
+		firstMoves[strings.Fields(waitForFEN(t, pds, game.ID, "b"))[0]] = true
+	}
+
+	if len(firstMoves) != 2 {
+		t.Errorf("Expected the bot to vary its opening against the same player, got %v", firstMoves)
+	}
+	if len(engine.levels) != 0 {
+		t.Error("Expected book moves to be played without asking the engine")
+	}
+}
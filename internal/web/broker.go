@@ -0,0 +1,130 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Broker fans hub updates out across protocol service replicas, so players
+// connected to one replica see moves made through another. Publish sends a
+// message to every replica, including this one; Subscribe calls handler with
+// each message until ctx is cancelled or the subscription fails.
+type Broker interface {
+	Publish(ctx context.Context, message []byte) error
+	Subscribe(ctx context.Context, handler func(message []byte)) error
+}
+
+// brokerQueueSize is how many updates can wait to be published
+const brokerQueueSize = 256
+
+// brokerPublishTimeout bounds publishing a single update
+const brokerPublishTimeout = 5 * time.Second
+
+// brokerRetryDelay is the longest wait before resubscribing after the
+// subscription fails; the wait doubles from a second up to it
+const brokerRetryDelay = 30 * time.Second
+
+// brokerMessage is a hub update as sent between replicas
+type brokerMessage struct {
+	// Origin is the replica that published the update; it has delivered it
+	// to its own clients already
+	Origin    string     `json:"origin"`
+	Update    GameUpdate `json:"update"`
+	Recipient string     `json:"recipient,omitempty"`
+}
+
+// UseBroker shares the hub's updates with other replicas through broker,
+// until ctx is cancelled. Updates are still delivered to this replica's
+// clients directly; the broker delivers them to everyone else's.
+func (h *Hub) UseBroker(ctx context.Context, broker Broker) {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	h.mu.Lock()
+	h.replicaID = hex.EncodeToString(id)
+	h.outbound = make(chan brokerMessage, brokerQueueSize)
+	h.mu.Unlock()
+
+	go h.publishLoop(ctx, broker)
+	go h.subscribeLoop(ctx, broker)
+}
+
+// publish queues an update for the other replicas, if there is a broker
+func (h *Hub) publish(update GameUpdate) {
+	h.mu.RLock()
+	outbound, origin := h.outbound, h.replicaID
+	h.mu.RUnlock()
+	if outbound == nil {
+		return
+	}
+
+	select {
+	case outbound <- brokerMessage{Origin: origin, Update: update, Recipient: update.recipient}:
+	default:
+		log.Warn().Str("gameID", update.GameID).Msg("Broker queue full, update not shared with other replicas")
+	}
+}
+
+func (h *Hub) publishLoop(ctx context.Context, broker Broker) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-h.outbound:
+			data, err := json.Marshal(message)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to encode update for broker")
+				continue
+			}
+			publishCtx, cancel := context.WithTimeout(ctx, brokerPublishTimeout)
+			if err := broker.Publish(publishCtx, data); err != nil {
+				log.Error().Err(err).Str("gameID", message.Update.GameID).Msg("Failed to publish update to broker")
+			}
+			cancel()
+		}
+	}
+}
+
+func (h *Hub) subscribeLoop(ctx context.Context, broker Broker) {
+	delay := time.Second
+	for {
+		err := broker.Subscribe(ctx, h.receive)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error().Err(err).Dur("retryIn", delay).Msg("Broker subscription failed")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > brokerRetryDelay {
+			delay = brokerRetryDelay
+		}
+	}
+}
+
+// receive delivers an update published by another replica to our clients
+func (h *Hub) receive(data []byte) {
+	var message brokerMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		log.Warn().Err(err).Msg("Ignoring malformed broker message")
+		return
+	}
+
+	h.mu.RLock()
+	self := h.replicaID
+	h.mu.RUnlock()
+	if message.Origin == self {
+		return
+	}
+
+	update := message.Update
+	update.recipient = message.Recipient
+	h.deliver(update)
+}
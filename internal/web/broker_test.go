@@ -0,0 +1,122 @@
+package web
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBroker delivers every message to every subscriber, like Redis
+// pub/sub does for replicas sharing a channel
+type memoryBroker struct {
+	mu          sync.Mutex
+	subscribers []func([]byte)
+}
+
+func (b *memoryBroker) Publish(ctx context.Context, message []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, handler := range b.subscribers {
+		handler(message)
+	}
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(ctx context.Context, handler func([]byte)) error {
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, handler)
+	b.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (b *memoryBroker) subscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// connectedHub starts a hub sharing updates through broker, with a client in room
+func connectedHub(t *testing.T, ctx context.Context, broker *memoryBroker, gameID, userID, channel string) *Client {
+	t.Helper()
+	hub := NewHub()
+	go hub.Run()
+	hub.UseBroker(ctx, broker)
+	client := &Client{hub: hub, send: make(chan []byte, 8), gameID: gameID, userID: userID, channel: channel}
+	hub.register <- client
+	return client
+}
+
+func expectFrame(t *testing.T, client *Client, want string) {
+	t.Helper()
+	select {
+	case frame := <-client.send:
+		if !strings.Contains(string(frame), want) {
+			t.Errorf("Expected a frame containing %s, got %s", want, frame)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a frame containing %s", want)
+	}
+}
+
+func expectNoFrame(t *testing.T, client *Client) {
+	t.Helper()
+	select {
+	case frame := <-client.send:
+		t.Errorf("Expected no more frames, got %s", frame)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBrokerSharesUpdatesBetweenHubs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker := &memoryBroker{}
+
+	local := connectedHub(t, ctx, broker, "game-1", "anonymous", GameChannel)
+	remote := connectedHub(t, ctx, broker, "game-1", "anonymous", GameChannel)
+	for broker.subscriberCount() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	local.hub.BroadcastGameUpdate(GameUpdate{GameID: "game-1", Type: "move", Data: map[string]string{"san": "e4"}})
+	expectFrame(t, local, `"san":"e4"`)
+	expectFrame(t, remote, `"san":"e4"`)
+	// The publishing hub skips its own message when the broker echoes it
+	expectNoFrame(t, local)
+}
+
+func TestBrokerKeepsPlayerUpdatesForTheirRecipient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker := &memoryBroker{}
+
+	sender := connectedHub(t, ctx, broker, "", testWhiteDID, PlayerChannel)
+	recipient := connectedHub(t, ctx, broker, "", testBlackDID, PlayerChannel)
+	for broker.subscriberCount() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	sender.hub.BroadcastToPlayer(testBlackDID, GameUpdate{GameID: "game-1", Type: NotificationYourMove})
+	expectFrame(t, recipient, `"type":"your_move"`)
+	expectNoFrame(t, sender)
+}
+
+func TestFirehoseUpdatesStayOnTheirReplica(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker := &memoryBroker{}
+
+	local := connectedHub(t, ctx, broker, "game-1", "anonymous", GameChannel)
+	remote := connectedHub(t, ctx, broker, "game-1", "anonymous", GameChannel)
+	for broker.subscriberCount() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Every replica reads the firehose, so sharing would deliver it twice
+	local.hub.BroadcastToGame("game-1", GameUpdate{Type: "move"})
+	expectFrame(t, local, `"type":"move"`)
+	expectNoFrame(t, remote)
+}
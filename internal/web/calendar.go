@@ -0,0 +1,105 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/rs/zerolog/log"
+)
+
+// icsTimeFormat is a UTC date-time in iCalendar (RFC 5545)
+const icsTimeFormat = "20060102T150405Z"
+
+// icsLineLength is the longest content line iCalendar allows, in octets
+const icsLineLength = 75
+
+// DeadlinesCalendarHandler serves a player's move deadlines as an iCalendar
+// feed that calendar apps can subscribe to. Calendar apps can't sign in, so
+// the feed is public; deadlines follow from the player's public game records
+// anyway. Times are in UTC, which calendar apps show in their own timezone.
+func (s *Service) DeadlinesCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	did := mux.Vars(r)["did"]
+	if !strings.HasPrefix(did, "did:") {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Expected a DID"))
+		return
+	}
+
+	deadlines, err := s.playerDeadlines(r.Context(), s.client, did, time.UTC)
+	if err != nil {
+		log.Error().Err(err).Str("did", did).Msg("Failed to list deadlines for calendar")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list deadlines"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	_, _ = w.Write([]byte(s.deadlinesCalendar(deadlines, time.Now())))
+}
+
+// deadlinesCalendar formats deadlines as an iCalendar document. Each game
+// keeps one event, moved to the new deadline after every move.
+func (s *Service) deadlinesCalendar(deadlines []*Deadline, now time.Time) string {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//ATChess//Move deadlines//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:ATChess move deadlines",
+	}
+	for _, deadline := range deadlines {
+		summary := "Opponent's move due in ATChess"
+		if deadline.YourMove {
+			summary = "Your move due in ATChess"
+		}
+		id := sha256.Sum256([]byte(deadline.GameID))
+
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:deadline-"+hex.EncodeToString(id[:16])+"@atchess",
+			"DTSTAMP:"+now.UTC().Format(icsTimeFormat),
+			"DTSTART:"+deadline.Deadline.UTC().Format(icsTimeFormat),
+			"SUMMARY:"+icsText(summary),
+			"DESCRIPTION:"+icsText(fmt.Sprintf("Move by %s in %s", deadline.Display, deadline.GameID)),
+		)
+		if link := s.gameLink(deadline.GameID); link != "" {
+			lines = append(lines, "URL:"+link)
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(foldICSLine(line))
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+// icsText escapes a TEXT value
+func icsText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+// foldICSLine splits a content line longer than iCalendar allows into lines
+// continued with a leading space, without splitting characters
+func foldICSLine(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > icsLineLength {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/rs/zerolog/log"
+)
+
+// challengeCleanupInterval is how often expired challenges are tidied up
+const challengeCleanupInterval = time.Hour
+
+// CancelChallengeHandler lets a challenger withdraw a pending challenge. The
+// id is the challenge's URI in URL-safe base64, like a game ID, or its record
+// key in the signed-in player's repository.
+func (s *Service) CancelChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	client := s.clientFor(r)
+	id := mux.Vars(r)["id"]
+	challengeURI, err := s.decodeGameID(id)
+	if err != nil || !strings.HasPrefix(challengeURI, "at://") {
+		challengeURI = "at://" + client.GetDID() + "/app.atchess.challenge/" + id
+	}
+
+	challenge, err := client.CancelChallenge(context.Background(), challengeURI)
+	if err != nil {
+		log.Error().Err(err).Str("uri", challengeURI).Msg("Failed to cancel challenge")
+		if errors.Is(err, atproto.ErrNotChallenger) {
+			apierror.Write(w, apierror.ErrNotChallenger)
+			return
+		}
+		apierror.Write(w, challengeError(err, "Failed to cancel challenge"))
+		return
+	}
+
+	if s.scheduler != nil {
+		if err := s.scheduler.Cancel(context.Background(), challengeJobID(challengeURI)); err != nil {
+			log.Warn().Err(err).Str("challenge", challengeURI).Msg("Failed to unschedule cancelled challenge")
+		}
+	}
+	s.notifyPlayer(challenge.Challenged, NotificationChallengeCancelled, "", challenge)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CleanupChallenges marks expired challenges and prunes their notifications
+// in the repositories of the service account and every signed-in player, who
+// are the only ones we can write for. It returns the totals.
+func (s *Service) CleanupChallenges(ctx context.Context) atproto.ChallengeCleanup {
+	clients := []*atproto.Client{s.client}
+	for _, client := range s.sessions.Clients() {
+		if client.GetDID() != s.client.GetDID() {
+			clients = append(clients, client)
+		}
+	}
+
+	var total atproto.ChallengeCleanup
+	now := time.Now()
+	for _, client := range clients {
+		cleanup, err := client.CleanupChallenges(ctx, now)
+		if err != nil {
+			log.Warn().Err(err).Str("did", client.GetDID()).Msg("Failed to clean up challenges")
+		}
+		total.Expired += cleanup.Expired
+		total.Pruned += cleanup.Pruned
+	}
+	if total.Expired > 0 || total.Pruned > 0 {
+		log.Info().Int("expired", total.Expired).Int("pruned", total.Pruned).Msg("Cleaned up expired challenges")
+	}
+	return total
+}
+
+// StartChallengeCleanup starts a goroutine that periodically cleans up
+// expired challenges
+func (s *Service) StartChallengeCleanup() {
+	go func() {
+		ticker := time.NewTicker(challengeCleanupInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.CleanupChallenges(context.Background())
+		}
+	}()
+}
@@ -0,0 +1,199 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// seedNotifiedChallenge stores a pending challenge and the notification it
+// left in the challenged player's repository, returning the challenge's URI
+func seedNotifiedChallenge(pds *fakePDS, challenger, challenged, rkey string, expiresAt time.Time) string {
+	uri := fmt.Sprintf("at://%s/app.atchess.challenge/%s", challenger, rkey)
+	pds.put(uri, map[string]interface{}{
+		"$type":      "app.atchess.challenge",
+		"createdAt":  expiresAt.Add(-24 * time.Hour).Format(time.RFC3339),
+		"challenger": challenger,
+		"challenged": challenged,
+		"status":     "pending",
+		"expiresAt":  expiresAt.Format(time.RFC3339),
+	})
+	pds.put(fmt.Sprintf("at://%s/app.atchess.challengeNotification/%s", challenged, rkey), map[string]interface{}{
+		"$type":      "app.atchess.challengeNotification",
+		"createdAt":  expiresAt.Add(-24 * time.Hour).Format(time.RFC3339),
+		"challenge":  map[string]interface{}{"uri": uri, "cid": "cid-" + uri},
+		"challenger": challenger,
+		"expiresAt":  expiresAt.Format(time.RFC3339),
+	})
+	return uri
+}
+
+func TestCancelChallenge(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	mine := seedNotifiedChallenge(pds, testWhiteDID, testBlackDID, "mine", time.Now().Add(time.Hour))
+	theirs := seedNotifiedChallenge(pds, testBlackDID, testWhiteDID, "theirs", time.Now().Add(time.Hour))
+
+	cancel := func(id string) *http.Response {
+		return sessionRequest(t, service, pds, service.CancelChallengeHandler, "DELETE", "/api/challenges/"+id, map[string]string{"id": id}, nil, true).Result()
+	}
+
+	if resp := cancel(base64.URLEncoding.EncodeToString([]byte(mine))); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", resp.StatusCode)
+	}
+	if value := pds.get(mine); value["status"] != "cancelled" || value["cancelledAt"] == nil {
+		t.Errorf("Expected the challenge to be cancelled, got %v", value)
+	}
+	if notifications := pds.collection(testBlackDID, "app.atchess.challengeNotification"); len(notifications) != 0 {
+		t.Errorf("Expected the opponent's notification to be deleted, got %v", notifications)
+	}
+
+	// A challenge can only be cancelled once, and only by its challenger
+	if resp := cancel("mine"); resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected 409 cancelling again by record key, got %d", resp.StatusCode)
+	}
+	if resp := cancel(base64.URLEncoding.EncodeToString([]byte(theirs))); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 cancelling someone else's challenge, got %d", resp.StatusCode)
+	}
+	if status := pds.get(theirs)["status"]; status != "pending" {
+		t.Errorf("Expected the opponent's challenge to stay pending, got %v", status)
+	}
+}
+
+func TestCleanupChallenges(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	stale := seedNotifiedChallenge(pds, testWhiteDID, testBlackDID, "stale", time.Now().Add(-time.Minute))
+	fresh := seedNotifiedChallenge(pds, testWhiteDID, testBlackDID, "fresh", time.Now().Add(time.Hour))
+
+	// Notifications to us: one expired, one whose challenge was declined and one still open
+	seedNotifiedChallenge(pds, testBlackDID, testWhiteDID, "lapsed", time.Now().Add(-time.Minute))
+	declined := seedNotifiedChallenge(pds, testBlackDID, testWhiteDID, "declined", time.Now().Add(time.Hour))
+	record := pds.get(declined)
+	record["status"] = "declined"
+	pds.put(declined, record)
+	seedNotifiedChallenge(pds, testBlackDID, testWhiteDID, "open", time.Now().Add(time.Hour))
+
+	cleanup := service.CleanupChallenges(context.Background())
+	if cleanup.Expired != 1 || cleanup.Pruned != 3 {
+		t.Errorf("Expected 1 challenge expired and 3 notifications pruned, got %+v", cleanup)
+	}
+	if value := pds.get(stale); value["status"] != "expired" || value["expiredAt"] == nil {
+		t.Errorf("Expected the stale challenge to expire, got %v", value)
+	}
+	if status := pds.get(fresh)["status"]; status != "pending" {
+		t.Errorf("Expected the fresh challenge to stay pending, got %v", status)
+	}
+
+	want := map[string][]string{
+		testBlackDID: {fmt.Sprintf("at://%s/app.atchess.challengeNotification/fresh", testBlackDID)},
+		testWhiteDID: {fmt.Sprintf("at://%s/app.atchess.challengeNotification/open", testWhiteDID)},
+	}
+	for repo, uris := range want {
+		if got := pds.collection(repo, "app.atchess.challengeNotification"); fmt.Sprint(got) != fmt.Sprint(uris) {
+			t.Errorf("Expected %s's notifications to be %v, got %v", repo, uris, got)
+		}
+	}
+}
+
+// postChallenge challenges opponent as the service account
+func postChallenge(service *Service, opponent string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(CreateChallengeRequest{OpponentDID: opponent, Color: "white"})
+	w := httptest.NewRecorder()
+	service.CreateChallengeHandler(w, asPlayer(httptest.NewRequest("POST", "/api/challenges", bytes.NewReader(body)), service.client))
+	return w
+}
+
+func TestChallengesRefusedByBlocksAndDenyList(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	service.client.SetChallengeCap(2)
+
+	// Black blocks white on Bluesky; carol has white on her deny-list
+	pds.put("at://did:plc:black/app.bsky.graph.block/b1", map[string]interface{}{
+		"$type":     "app.bsky.graph.block",
+		"subject":   testWhiteDID,
+		"createdAt": "2024-01-01T00:00:00Z",
+	})
+	pds.put("at://did:plc:carol/app.atchess.settings/self", map[string]interface{}{
+		"$type":             "app.atchess.settings",
+		"challengeDenyList": []interface{}{testWhiteDID},
+		"updatedAt":         "2024-01-01T00:00:00Z",
+	})
+
+	for _, opponent := range []string{testBlackDID, "did:plc:carol"} {
+		if w := postChallenge(service, opponent); w.Code != http.StatusForbidden || errorCode(t, w) != "challenge_refused" {
+			t.Errorf("Expected challenging %s to be refused, got %d: %s", opponent, w.Code, w.Body.String())
+		}
+	}
+	if challenges := pds.collection(testWhiteDID, "app.atchess.challenge"); len(challenges) != 0 {
+		t.Errorf("Expected no challenges to be created, got %v", challenges)
+	}
+	if notifications := pds.collection("did:plc:carol", "app.atchess.challengeNotification"); len(notifications) != 0 {
+		t.Errorf("Expected no notifications to be created, got %v", notifications)
+	}
+
+	// Dave takes challenges, up to the cap
+	for i := 0; i < 2; i++ {
+		if w := postChallenge(service, "did:plc:dave"); w.Code != http.StatusOK {
+			t.Fatalf("Expected challenge %d to be created, got %d: %s", i+1, w.Code, w.Body.String())
+		}
+	}
+	if w := postChallenge(service, "did:plc:dave"); w.Code != http.StatusTooManyRequests || errorCode(t, w) != "challenge_limit" {
+		t.Errorf("Expected the third challenge to hit the cap, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestChallengeNotificationsLeaveOutRefusedChallengers(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	service.client.SetChallengeCap(2)
+
+	pds.put("at://did:plc:white/app.bsky.graph.block/b1", map[string]interface{}{
+		"$type":     "app.bsky.graph.block",
+		"subject":   testBlackDID,
+		"createdAt": "2024-01-01T00:00:00Z",
+	})
+	// Settings are only written for a signed-in player, never to the
+	// service account's repository
+	anonymous := sessionRequest(t, service, pds, service.SaveSettingsHandler, "PUT", "/api/settings", nil,
+		Settings{ChallengeDenyList: []string{"did:plc:carol"}}, false)
+	if anonymous.Code != http.StatusUnauthorized || pds.get("at://did:plc:white/app.atchess.settings/self") != nil {
+		t.Fatalf("Expected 401 saving settings without a session, got %d", anonymous.Code)
+	}
+
+	w := sessionRequest(t, service, pds, service.SaveSettingsHandler, "PUT", "/api/settings", nil,
+		Settings{ChallengeDenyList: []string{"did:plc:carol", "did:plc:carol"}}, true)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected settings to save, got %d: %s", w.Code, w.Body.String())
+	}
+	if denied := pds.get("at://did:plc:white/app.atchess.settings/self")["challengeDenyList"]; fmt.Sprint(denied) != "[did:plc:carol]" {
+		t.Errorf("Expected carol to be denied once, got %v", denied)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	seedNotifiedChallenge(pds, testBlackDID, testWhiteDID, "black", expiresAt)
+	seedNotifiedChallenge(pds, "did:plc:carol", testWhiteDID, "carol", expiresAt)
+	for i := 0; i < 3; i++ {
+		seedNotifiedChallenge(pds, "did:plc:dave", testWhiteDID, fmt.Sprintf("dave%d", i), expiresAt)
+	}
+
+	notifications, err := service.client.GetChallengeNotifications(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list notifications: %v", err)
+	}
+	if len(notifications) != 2 {
+		t.Fatalf("Expected dave's first two challenges, got %d notifications", len(notifications))
+	}
+	for _, notification := range notifications {
+		if notification.Challenger != "did:plc:dave" {
+			t.Errorf("Expected only dave's challenges, got one from %s", notification.Challenger)
+		}
+	}
+}
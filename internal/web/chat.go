@@ -0,0 +1,98 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// MaxChatMessageLength bounds a chat message, in characters
+	MaxChatMessageLength = 500
+
+	// ChatMessagesPerMinute is how many messages a player may send, across all
+	// their games, before being rate limited
+	ChatMessagesPerMinute = 10
+)
+
+// SendChatRequest is a chat message to post in a game
+type SendChatRequest struct {
+	Text string `json:"text"`
+}
+
+// BroadcastChat sends a chat message to the players and spectators of its game
+func (h *Hub) BroadcastChat(message *chess.ChatMessage) {
+	h.BroadcastGameUpdate(GameUpdate{
+		GameID: message.GameID,
+		Type:   "chat",
+		Data:   message,
+	})
+}
+
+// SendChatHandler records a player's chat message as an app.atchess.chatMessage
+// record in their repository and delivers it to everyone watching the game
+func (s *Service) SendChatHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	var req SendChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Message is empty"))
+		return
+	}
+	if utf8.RuneCountInString(text) > MaxChatMessageLength {
+		apierror.Write(w, apierror.ErrMessageTooLong)
+		return
+	}
+
+	client := s.clientFor(r)
+	did := client.GetDID()
+
+	if _, ok := s.loadGameForPlayer(w, client.GetGame, gameID, did); !ok {
+		return
+	}
+
+	allowed, _, wait := s.chatLimiter.Allow(did, ChatMessagesPerMinute)
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		apierror.Write(w, apierror.ErrRateLimited.WithMessage("You are sending messages too quickly"))
+		return
+	}
+
+	message, err := client.SendChatMessage(context.Background(), gameID, text)
+	if errors.Is(err, atproto.ErrNotInGame) {
+		apierror.Write(w, apierror.ErrNotAPlayer)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to send chat message")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to send message"))
+		return
+	}
+
+	if s.hub != nil {
+		s.hub.BroadcastChat(message)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(message)
+}
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+func chatRequest(s *Service, gameID, text string) *httptest.ResponseRecorder {
+	encoded := base64.URLEncoding.EncodeToString([]byte(gameID))
+	reqBody, _ := json.Marshal(SendChatRequest{Text: text})
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/api/games/"+encoded+"/chat", bytes.NewReader(reqBody)), map[string]string{"id": encoded})
+	w := httptest.NewRecorder()
+	s.SendChatHandler(w, asPlayer(req, s.client))
+	return w
+}
+
+func TestChatRecordedAndBroadcastToSpectators(t *testing.T) {
+	game := newLiveGame(t, DisconnectPolicyPause, time.Hour)
+	game.service.SetHub(game.hub)
+	spectator, _, err := websocket.DefaultDialer.Dial(game.wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect spectator: %v", err)
+	}
+	defer spectator.Close()
+	for game.hub.SubscriberCount(game.gameID, GameChannel) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	w := chatRequest(game.service, game.gameID, "  good luck, have fun  ")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected message to be sent, got %d: %s", w.Code, w.Body.String())
+	}
+	var message chess.ChatMessage
+	_ = json.Unmarshal(w.Body.Bytes(), &message)
+	if message.Sender != testWhiteDID || message.Text != "good luck, have fun" || message.GameID != game.gameID {
+		t.Errorf("Unexpected message: %+v", message)
+	}
+
+	records := game.whitePDS.collection(testWhiteDID, "app.atchess.chatMessage")
+	if len(records) != 1 || records[0] != message.ID {
+		t.Fatalf("Expected the message to be recorded, got %v", records)
+	}
+	if ref, _ := game.whitePDS.get(message.ID)["game"].(map[string]interface{}); ref["uri"] != game.gameID {
+		t.Errorf("Expected the message to reference the game, got %v", ref)
+	}
+
+	frame := readFrame(t, spectator, "chat")
+	if frame.Data["text"] != "good luck, have fun" || frame.Data["sender"] != testWhiteDID {
+		t.Errorf("Unexpected chat frame: %+v", frame.Data)
+	}
+}
+
+func TestChatValidation(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	for _, text := range []string{"", "   ", strings.Repeat("♞", MaxChatMessageLength+1)} {
+		if w := chatRequest(service, gameID, text); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for a %d character message, got %d", len(text), w.Code)
+		}
+	}
+	if w := chatRequest(service, gameID, strings.Repeat("♞", MaxChatMessageLength)); w.Code != http.StatusOK {
+		t.Errorf("Expected a message at the limit to be sent, got %d", w.Code)
+	}
+}
+
+func TestChatRequiresPlayer(t *testing.T) {
+	pds := newFakePDS(t, "did:plc:spectator")
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	if w := chatRequest(service, gameID, "hello"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-player, got %d", w.Code)
+	}
+}
+
+func TestChatRateLimited(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	for i := 0; i < ChatMessagesPerMinute; i++ {
+		if w := chatRequest(service, gameID, "spam"); w.Code != http.StatusOK {
+			t.Fatalf("Expected message %d to be sent, got %d", i+1, w.Code)
+		}
+	}
+	w := chatRequest(service, gameID, "spam")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After, got %d", w.Code)
+	}
+	if records := pds.collection(testWhiteDID, "app.atchess.chatMessage"); len(records) != ChatMessagesPerMinute {
+		t.Errorf("Expected only %d messages to be recorded, got %d", ChatMessagesPerMinute, len(records))
+	}
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// trustedProxies are the reverse proxies whose X-Forwarded-For header is
+// believed about who made a request
+type trustedProxies []*net.IPNet
+
+// parseTrustedProxies parses server.trusted_proxies, which are addresses or
+// CIDR ranges. Config validation has already rejected anything else.
+func parseTrustedProxies(entries []string) trustedProxies {
+	var proxies trustedProxies
+	for _, entry := range entries {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			proxies = append(proxies, network)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			log.Warn().Str("proxy", entry).Msg("Ignoring trusted proxy that isn't an IP address or CIDR range")
+			continue
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return proxies
+}
+
+// trusts reports whether addr is one of the proxies
+func (p trustedProxies) trusts(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client that made r. That's the peer's
+// address, unless the peer is a trusted proxy: then it's the last address in
+// X-Forwarded-For that isn't one, since each proxy appends the address it
+// was connected from and anything before that could be made up.
+func (p trustedProxies) clientIP(r *http.Request) string {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	if !p.trusts(client) {
+		return client
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		client = hop
+		if !p.trusts(hop) {
+			break
+		}
+	}
+	return client
+}
@@ -0,0 +1,43 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPBelievesOnlyTrustedProxies(t *testing.T) {
+	proxies := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+
+	testCases := []struct {
+		name      string
+		peer      string
+		forwarded []string
+		expected  string
+	}{
+		{name: "direct client", peer: "203.0.113.5:4321", expected: "203.0.113.5"},
+		{name: "untrusted peer can't pick its address", peer: "203.0.113.5:4321", forwarded: []string{"198.51.100.1"}, expected: "203.0.113.5"},
+		{name: "trusted proxy", peer: "10.0.0.2:4321", forwarded: []string{"198.51.100.1"}, expected: "198.51.100.1"},
+		{name: "single trusted address", peer: "192.168.1.1:4321", forwarded: []string{"198.51.100.1"}, expected: "198.51.100.1"},
+		{name: "address just outside the trusted one", peer: "192.168.1.2:4321", forwarded: []string{"198.51.100.1"}, expected: "192.168.1.2"},
+		{name: "chain of proxies", peer: "10.0.0.2:4321", forwarded: []string{"198.51.100.1, 10.0.0.3"}, expected: "198.51.100.1"},
+		{name: "forged entries before the proxy's are ignored", peer: "10.0.0.2:4321", forwarded: []string{"1.2.3.4, 198.51.100.1"}, expected: "198.51.100.1"},
+		{name: "repeated headers", peer: "10.0.0.2:4321", forwarded: []string{"1.2.3.4", "198.51.100.1"}, expected: "198.51.100.1"},
+		{name: "garbage stops the walk", peer: "10.0.0.2:4321", forwarded: []string{"198.51.100.1, unknown"}, expected: "10.0.0.2"},
+		{name: "trusted proxy without the header", peer: "10.0.0.2:4321", expected: "10.0.0.2"},
+		{name: "IPv6 proxy", peer: "[fd00::1]:4321", forwarded: []string{"2001:db8::7"}, expected: "2001:db8::7"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/games", nil)
+			req.RemoteAddr = tc.peer
+			for _, header := range tc.forwarded {
+				req.Header.Add("X-Forwarded-For", header)
+			}
+			if ip := proxies.clientIP(req); ip != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, ip)
+			}
+		})
+	}
+}
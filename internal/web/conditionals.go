@@ -0,0 +1,297 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxConditionalMoves bounds a line of conditional moves, counting both the
+// opponent's expected moves and the replies
+const MaxConditionalMoves = 20
+
+// CreateConditionalMoveRequest is the body of POST /api/games/{id}/conditionals
+type CreateConditionalMoveRequest struct {
+	// Moves alternate the opponent's expected move and the reply to it, in
+	// UCI or SAN, starting from the game's current position
+	Moves []string `json:"moves"`
+}
+
+// CreateConditionalMoveHandler stores a line of conditional moves for the
+// caller in a correspondence game: "if my opponent plays this, I reply with
+// that". It's entered while the opponent is to move, and every move in it
+// must be legal in turn from the current position.
+func (s *Service) CreateConditionalMoveHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	var req CreateConditionalMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if len(req.Moves) < 2 || len(req.Moves)%2 != 0 || len(req.Moves) > MaxConditionalMoves {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(fmt.Sprintf("A conditional line is pairs of an expected move and a reply, at most %d moves in all", MaxConditionalMoves)))
+		return
+	}
+
+	client := s.clientFor(r)
+	did := client.GetDID()
+
+	game, ok := s.loadGameForPlayer(w, client.GetGame, gameID, did)
+	if !ok {
+		return
+	}
+	if game.Status != chess.StatusActive {
+		apierror.Write(w, apierror.ErrGameFinished)
+		return
+	}
+	if toMove, err := game.PlayerToMove(); err != nil || toMove == did {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Conditional moves are for while your opponent is to move; make your move instead"))
+		return
+	}
+
+	line, err := conditionalLine(game, req.Moves)
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidMove.WithMessage(err.Error()))
+		return
+	}
+
+	conditional, err := client.CreateConditionalMove(r.Context(), gameID, game.FEN, line)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to create conditional move")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to save conditional moves"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(conditional)
+}
+
+// ListConditionalMovesHandler lists the caller's conditional moves for a game
+func (s *Service) ListConditionalMovesHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	client := s.clientFor(r)
+	did := client.GetDID()
+	if _, ok := s.loadGameForPlayer(w, client.GetGame, gameID, did); !ok {
+		return
+	}
+
+	conditionals, err := client.ListConditionalMoves(r.Context(), did, gameID)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to list conditional moves")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list conditional moves"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"conditionals": conditionals,
+	})
+}
+
+// CancelConditionalMoveHandler cancels one of the caller's active lines of
+// conditional moves, named by its record key
+func (s *Service) CancelConditionalMoveHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID, err := s.decodeGameID(vars["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	client := s.clientFor(r)
+	did := client.GetDID()
+	if _, ok := s.loadGameForPlayer(w, client.GetGame, gameID, did); !ok {
+		return
+	}
+
+	conditionals, err := client.ListConditionalMoves(r.Context(), did, gameID)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to list conditional moves")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list conditional moves"))
+		return
+	}
+	var conditional *atproto.ConditionalMove
+	for _, c := range conditionals {
+		if strings.HasSuffix(c.URI, "/"+vars["rkey"]) {
+			conditional = c
+		}
+	}
+	if conditional == nil {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("No such conditional moves"))
+		return
+	}
+	if conditional.Status != atproto.ConditionalActive {
+		apierror.Write(w, apierror.ErrConflict.WithMessage(fmt.Sprintf("Conditional moves are already %s", conditional.Status)))
+		return
+	}
+
+	conditional.Status = atproto.ConditionalCancelled
+	if err := client.UpdateConditionalMove(r.Context(), conditional); err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to cancel conditional moves")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to cancel conditional moves"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(conditional)
+}
+
+// conditionalLine plays moves in turn from the game's position, under its
+// rules, and returns them in UCI notation
+func conditionalLine(game *chess.Game, moves []string) ([]string, error) {
+	engine, err := chess.NewVariantEngine(game.Variant, game.FEN)
+	if err != nil {
+		return nil, fmt.Errorf("game has an invalid position: %w", err)
+	}
+	line := make([]string, 0, len(moves))
+	for i, move := range moves {
+		var result *chess.MoveResult
+		if chess.IsUCI(move) {
+			result, err = engine.MakeMoveUCI(move)
+		} else {
+			result, err = engine.MakeMoveSAN(move)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("move %d (%s) is not legal: %w", i+1, move, err)
+		}
+		if result.GameOver && i < len(moves)-1 {
+			return nil, fmt.Errorf("the game is over after move %d (%s)", i+1, move)
+		}
+		line = append(line, result.From+result.To+result.Promotion)
+	}
+	return line, nil
+}
+
+// conditionalReply finds the opponent's move in a line that leads to fen and
+// returns the index of the reply to it, or -1 if the line doesn't reach fen
+// by an opponent's move
+func conditionalReply(variant string, conditional *atproto.ConditionalMove, fen string) int {
+	engine, err := chess.NewVariantEngine(variant, conditional.FEN)
+	if err != nil {
+		return -1
+	}
+	for i, move := range conditional.Moves {
+		result, err := engine.MakeMoveUCI(move)
+		if err != nil {
+			return -1
+		}
+		if i%2 == 0 && samePosition(result.FEN, fen) && i+1 < len(conditional.Moves) {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// samePosition compares FENs by their placement, side to move, castling
+// rights and en passant square, leaving out the move counters
+func samePosition(a, b string) bool {
+	fieldsA, fieldsB := strings.Fields(a), strings.Fields(b)
+	if len(fieldsA) < 4 || len(fieldsB) < 4 {
+		return a == b
+	}
+	return strings.Join(fieldsA[:4], " ") == strings.Join(fieldsB[:4], " ")
+}
+
+// PlayConditionalMoves answers a move with the reply its mover's opponent
+// queued for it, if they have one and are signed in here. fen is the position
+// after the move, or "" for the game record's. The oldest active line that
+// expected the move is played; lines that didn't are marked broken, as the
+// game has left them.
+func (s *Service) PlayConditionalMoves(ctx context.Context, gameURI, mover, fen string) {
+	logger := log.With().Str("gameID", gameURI).Logger()
+
+	game, err := s.client.GetGame(ctx, gameURI)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to load game for conditional moves")
+		return
+	}
+	owner := opponentOf(game, mover)
+	if game.Status != chess.StatusActive || owner == "" || owner == mover {
+		return
+	}
+	// The game record may not have caught up with the move yet
+	if fen != "" {
+		game.FEN = fen
+	}
+	if toMove, err := game.PlayerToMove(); err != nil || toMove != owner {
+		return
+	}
+	client := s.playerClient(owner)
+	if client == nil {
+		return
+	}
+
+	conditionals, err := client.ListConditionalMoves(ctx, owner, gameURI)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to list conditional moves")
+		return
+	}
+	replied := false
+	for _, conditional := range conditionals {
+		if conditional.Status != atproto.ConditionalActive {
+			continue
+		}
+		reply := conditionalReply(game.Variant, conditional, game.FEN)
+		switch {
+		case reply < 0:
+			conditional.Status = atproto.ConditionalBroken
+		case reply < conditional.Played || replied:
+			// Already answered, or another line answered it first
+			continue
+		default:
+			moveResult, err := s.playConditionalReply(ctx, client, game, conditional.Moves[reply])
+			if err != nil {
+				// Left active: the move may have been answered already,
+				// and a line the game leaves is broken next time
+				logger.Warn().Err(err).Str("conditional", conditional.URI).Msg("Failed to play conditional move")
+				continue
+			}
+			replied = true
+			conditional.Played = reply + 1
+			if conditional.Played == len(conditional.Moves) || moveResult.GameOver {
+				conditional.Status = atproto.ConditionalCompleted
+			}
+			logger.Info().Str("player", owner).Str("san", moveResult.SAN).Msg("Played conditional move")
+		}
+		if err := client.UpdateConditionalMove(ctx, conditional); err != nil {
+			logger.Warn().Err(err).Str("conditional", conditional.URI).Msg("Failed to update conditional moves")
+		}
+	}
+}
+
+// playConditionalReply records a queued reply for its player as if they'd
+// made it themselves
+func (s *Service) playConditionalReply(ctx context.Context, client *atproto.Client, game *chess.Game, uci string) (*chess.MoveResult, error) {
+	engine, err := chess.NewVariantEngine(game.Variant, game.FEN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid position: %w", err)
+	}
+	moveResult, err := engine.MakeMoveUCI(uci)
+	if err != nil {
+		return nil, fmt.Errorf("reply is no longer legal: %w", err)
+	}
+	if err := client.RecordMove(ctx, game.ID, moveResult); err != nil {
+		return nil, err
+	}
+	s.moveRecorded(ctx, client, game, moveResult)
+	return moveResult, nil
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// positionAfter plays UCI moves from fen and returns the position they reach
+func positionAfter(t *testing.T, fen string, moves ...string) string {
+	t.Helper()
+	engine, err := chess.NewVariantEngine("", fen)
+	if err != nil {
+		t.Fatalf("Invalid FEN: %v", err)
+	}
+	for _, move := range moves {
+		result, err := engine.MakeMoveUCI(move)
+		if err != nil {
+			t.Fatalf("Illegal move %s: %v", move, err)
+		}
+		fen = result.FEN
+	}
+	return fen
+}
+
+func TestConditionalMovesAnswerExpectedMoves(t *testing.T) {
+	ctx := context.Background()
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	afterE4 := positionAfter(t, startFEN, "e2e4")
+	gameURI := seedGame(pds, afterE4, "active")
+	id := base64.URLEncoding.EncodeToString([]byte(gameURI))
+	vars := map[string]string{"id": id}
+	path := "/api/games/" + id + "/conditionals"
+
+	create := func(moves ...string) *httptest.ResponseRecorder {
+		return sessionRequest(t, service, pds, service.CreateConditionalMoveHandler, "POST", path, vars, CreateConditionalMoveRequest{Moves: moves}, true)
+	}
+	cancel := func(uri string) *httptest.ResponseRecorder {
+		rkey := uri[strings.LastIndex(uri, "/")+1:]
+		return sessionRequest(t, service, pds, service.CancelConditionalMoveHandler, "DELETE", path+"/"+rkey,
+			map[string]string{"id": id, "rkey": rkey}, nil, true)
+	}
+	list := func() map[string]*atproto.ConditionalMove {
+		var body struct {
+			Conditionals []*atproto.ConditionalMove `json:"conditionals"`
+		}
+		_ = json.Unmarshal(sessionRequest(t, service, pds, service.ListConditionalMovesHandler, "GET", path, vars, nil, true).Body.Bytes(), &body)
+		byURI := make(map[string]*atproto.ConditionalMove)
+		for _, conditional := range body.Conditionals {
+			byURI[conditional.URI] = conditional
+		}
+		return byURI
+	}
+	opponentMoves := func(moves ...string) {
+		fen := positionAfter(t, pds.get(gameURI)["fen"].(string), moves...)
+		game := pds.get(gameURI)
+		game["fen"] = fen
+		pds.put(gameURI, game)
+		service.PlayConditionalMoves(ctx, gameURI, testBlackDID, fen)
+	}
+
+	if w := create("e5"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a line without a reply, got %d", w.Code)
+	}
+	if w := create("e5", "Qh8"); w.Code != http.StatusBadRequest || errorCode(t, w) != "invalid_move" {
+		t.Errorf("Expected an illegal reply to be refused, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := create("e5", "Nf3", "b8c6", "Bb5")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected the line to be saved, got %d: %s", w.Code, w.Body.String())
+	}
+	var ruyLopez atproto.ConditionalMove
+	_ = json.Unmarshal(w.Body.Bytes(), &ruyLopez)
+	if strings.Join(ruyLopez.Moves, " ") != "e7e5 g1f3 b8c6 f1b5" || ruyLopez.FEN != afterE4 {
+		t.Errorf("Expected the line in UCI from the current position, got %+v", ruyLopez)
+	}
+	var sicilian, scandinavian atproto.ConditionalMove
+	_ = json.Unmarshal(create("c5", "Nf3").Body.Bytes(), &sicilian)
+	_ = json.Unmarshal(create("d5", "exd5").Body.Bytes(), &scandinavian)
+
+	if w := cancel(scandinavian.URI); w.Code != http.StatusOK {
+		t.Fatalf("Expected the line to be cancelled, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := cancel(scandinavian.URI); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 cancelling a cancelled line, got %d", w.Code)
+	}
+	if w := cancel(gameURI + "/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown line, got %d", w.Code)
+	}
+
+	// Black plays the expected move and white's reply is played at once
+	opponentMoves("e7e5")
+	moves := pds.collection(testWhiteDID, "app.atchess.move")
+	if len(moves) != 1 || pds.get(moves[0])["to"] != "f3" {
+		t.Fatalf("Expected Nf3 to be played for white, got %v", moves)
+	}
+	if fen := pds.get(gameURI)["fen"]; fen != positionAfter(t, afterE4, "e7e5", "g1f3") {
+		t.Errorf("Expected the game to be after Nf3, got %v", fen)
+	}
+	lines := list()
+	if line := lines[ruyLopez.URI]; line.Played != 2 || line.Status != atproto.ConditionalActive {
+		t.Errorf("Expected the line to be half played, got %+v", line)
+	}
+	if line := lines[sicilian.URI]; line.Status != atproto.ConditionalBroken {
+		t.Errorf("Expected the Sicilian line to be broken, got %+v", line)
+	}
+	if line := lines[scandinavian.URI]; line.Status != atproto.ConditionalCancelled {
+		t.Errorf("Expected the cancelled line to stay cancelled, got %+v", line)
+	}
+
+	// Seeing the move again doesn't answer it twice
+	service.PlayConditionalMoves(ctx, gameURI, testBlackDID, positionAfter(t, afterE4, "e7e5"))
+	if moves := pds.collection(testWhiteDID, "app.atchess.move"); len(moves) != 1 {
+		t.Errorf("Expected one move for white, got %v", moves)
+	}
+
+	// Black leaves the line, so it's broken and white is left to move
+	opponentMoves("d7d6")
+	if moves := pds.collection(testWhiteDID, "app.atchess.move"); len(moves) != 1 {
+		t.Errorf("Expected no reply to a move the line didn't expect, got %v", moves)
+	}
+	if line := list()[ruyLopez.URI]; line.Played != 2 || line.Status != atproto.ConditionalBroken {
+		t.Errorf("Expected the line to be broken, got %+v", line)
+	}
+	if w := create("a2a3", "a7a6"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 entering a line on white's own move, got %d", w.Code)
+	}
+}
@@ -0,0 +1,311 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// What to do when a player's connection to a live game drops
+const (
+	DisconnectPolicyOff      = "off"      // nothing
+	DisconnectPolicyAnnounce = "announce" // tell the opponent
+	DisconnectPolicyPause    = "pause"    // also pause the clock for the grace period
+	DisconnectPolicyForfeit  = "forfeit"  // pause, then forfeit if they haven't returned
+)
+
+// DefaultGracePeriod is how long a disconnected player has to come back
+const DefaultGracePeriod = time.Minute
+
+// ForfeitFunc ends a game in the opponent's favour because absentDID left
+type ForfeitFunc func(ctx context.Context, gameID, absentDID, winnerDID string) error
+
+// ConnectionUpdate is the payload of "player_disconnected",
+// "player_reconnected" and "clock_resumed" frames
+type ConnectionUpdate struct {
+	PlayerDID     string     `json:"playerDid"`
+	Policy        string     `json:"policy"`
+	ClockPaused   bool       `json:"clockPaused,omitempty"`
+	GraceSeconds  int        `json:"graceSeconds,omitempty"`
+	Deadline      *time.Time `json:"deadline,omitempty"` // end of the grace period
+	PausedSeconds int        `json:"pausedSeconds,omitempty"`
+}
+
+// clockPause is a span of time a game's clock stood still
+type clockPause struct {
+	start time.Time
+	end   time.Time // zero while the clock is still paused
+}
+
+// absence is a player who dropped out of a game their opponent is watching
+type absence struct {
+	opponent string
+	timer    *time.Timer
+}
+
+// ConnectionMonitor watches players' connections to their games and applies
+// the disconnect policy when one drops while the opponent is connected
+type ConnectionMonitor struct {
+	hub     *Hub
+	fetch   GameFetcher
+	forfeit ForfeitFunc
+	policy  string
+	grace   time.Duration
+	now     func() time.Time
+
+	mu     sync.Mutex
+	paused map[string][]clockPause // by gameID, since its last move
+	away   map[string]*absence     // by gameID + " " + playerDID
+}
+
+// NewConnectionMonitor creates a monitor; call hub.OnPresence with its
+// PlayerPresence method to start it
+func NewConnectionMonitor(hub *Hub, fetch GameFetcher, forfeit ForfeitFunc, policy string, grace time.Duration) *ConnectionMonitor {
+	switch policy {
+	case DisconnectPolicyOff, DisconnectPolicyAnnounce, DisconnectPolicyPause, DisconnectPolicyForfeit:
+	default:
+		log.Warn().Str("policy", policy).Msg("Unknown disconnect policy, using pause")
+		policy = DisconnectPolicyPause
+	}
+	if grace <= 0 {
+		grace = DefaultGracePeriod
+	}
+	return &ConnectionMonitor{
+		hub:     hub,
+		fetch:   fetch,
+		forfeit: forfeit,
+		policy:  policy,
+		grace:   grace,
+		now:     time.Now,
+		paused:  make(map[string][]clockPause),
+		away:    make(map[string]*absence),
+	}
+}
+
+// pauses reports whether the policy pauses the clock
+func (m *ConnectionMonitor) pauses() bool {
+	return m.policy == DisconnectPolicyPause || m.policy == DisconnectPolicyForfeit
+}
+
+// PlayerPresence handles a player connecting to or disconnecting from a game
+func (m *ConnectionMonitor) PlayerPresence(gameID, playerDID string, online bool) {
+	if m.policy == DisconnectPolicyOff {
+		return
+	}
+	if online {
+		m.reconnected(gameID, playerDID)
+	} else {
+		m.disconnected(gameID, playerDID)
+	}
+}
+
+func (m *ConnectionMonitor) disconnected(gameID, playerDID string) {
+	game, err := m.fetch(context.Background(), gameID)
+	if err != nil {
+		log.Warn().Err(err).Str("gameID", gameID).Msg("Failed to fetch game after disconnect")
+		return
+	}
+	if game.Status != chess.StatusActive {
+		return
+	}
+
+	var opponent string
+	switch playerDID {
+	case game.White:
+		opponent = game.Black
+	case game.Black:
+		opponent = game.White
+	default:
+		return
+	}
+	// It's only a live game if the opponent is there to notice
+	if !m.hub.IsOnline(gameID, opponent) {
+		return
+	}
+
+	key := gameID + " " + playerDID
+	update := ConnectionUpdate{PlayerDID: playerDID, Policy: m.policy}
+
+	m.mu.Lock()
+	if m.away[key] != nil {
+		m.mu.Unlock()
+		return
+	}
+	away := &absence{opponent: opponent}
+	if m.pauses() {
+		now := m.now()
+		if !m.isPaused(gameID) {
+			m.paused[gameID] = append(m.paused[gameID], clockPause{start: now})
+		}
+		away.timer = time.AfterFunc(m.grace, func() { m.expire(gameID, playerDID) })
+
+		deadline := now.Add(m.grace)
+		update.ClockPaused = true
+		update.GraceSeconds = int(m.grace.Seconds())
+		update.Deadline = &deadline
+	}
+	m.away[key] = away
+	m.mu.Unlock()
+
+	log.Info().Str("gameID", gameID).Str("player", playerDID).Str("policy", m.policy).Msg("Player disconnected from live game")
+	m.hub.BroadcastGameUpdate(GameUpdate{GameID: gameID, Type: "player_disconnected", Data: update})
+}
+
+func (m *ConnectionMonitor) reconnected(gameID, playerDID string) {
+	key := gameID + " " + playerDID
+
+	m.mu.Lock()
+	away := m.away[key]
+	if away == nil {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.away, key)
+	if away.timer != nil {
+		away.timer.Stop()
+	}
+	paused := m.resume(gameID)
+	m.mu.Unlock()
+
+	log.Info().Str("gameID", gameID).Str("player", playerDID).Dur("paused", paused).Msg("Player reconnected to live game")
+	m.hub.BroadcastGameUpdate(GameUpdate{
+		GameID: gameID,
+		Type:   "player_reconnected",
+		Data: ConnectionUpdate{
+			PlayerDID:     playerDID,
+			Policy:        m.policy,
+			PausedSeconds: int(paused.Seconds()),
+		},
+	})
+}
+
+// expire ends a player's grace period: the clock restarts, and under the
+// forfeit policy the game is awarded to their opponent
+func (m *ConnectionMonitor) expire(gameID, playerDID string) {
+	key := gameID + " " + playerDID
+
+	m.mu.Lock()
+	away := m.away[key]
+	if away == nil {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.away, key)
+	m.resume(gameID)
+	if m.policy == DisconnectPolicyForfeit {
+		delete(m.paused, gameID)
+	}
+	m.mu.Unlock()
+
+	if m.policy != DisconnectPolicyForfeit {
+		m.hub.BroadcastGameUpdate(GameUpdate{
+			GameID: gameID,
+			Type:   "clock_resumed",
+			Data:   ConnectionUpdate{PlayerDID: playerDID, Policy: m.policy},
+		})
+		return
+	}
+
+	if err := m.forfeit(context.Background(), gameID, playerDID, away.opponent); err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Str("player", playerDID).Msg("Failed to forfeit game after disconnect")
+		return
+	}
+	log.Info().Str("gameID", gameID).Str("player", playerDID).Msg("Player forfeited live game by disconnecting")
+	m.hub.BroadcastGameUpdate(GameUpdate{
+		GameID: gameID,
+		Type:   "game_end",
+		Data: map[string]interface{}{
+			"reason":      "disconnected",
+			"winner":      away.opponent,
+			"forfeitedBy": playerDID,
+		},
+	})
+}
+
+// resume restarts the clock once nobody in the game is away; the caller must
+// hold m.mu
+func (m *ConnectionMonitor) resume(gameID string) time.Duration {
+	for key := range m.away {
+		if strings.HasPrefix(key, gameID+" ") {
+			return 0
+		}
+	}
+	if !m.isPaused(gameID) {
+		return 0
+	}
+	pauses := m.paused[gameID]
+	last := &pauses[len(pauses)-1]
+	last.end = m.now()
+	return last.end.Sub(last.start)
+}
+
+// isPaused reports whether a game's clock is paused; the caller must hold m.mu
+func (m *ConnectionMonitor) isPaused(gameID string) bool {
+	pauses := m.paused[gameID]
+	return len(pauses) > 0 && pauses[len(pauses)-1].end.IsZero()
+}
+
+// RecordMove starts a new turn in a game. Pauses that are over can't count
+// toward it, so they're forgotten.
+func (m *ConnectionMonitor) RecordMove(gameID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.isPaused(gameID) {
+		pauses := m.paused[gameID]
+		m.paused[gameID] = pauses[len(pauses)-1:]
+	} else {
+		delete(m.paused, gameID)
+	}
+}
+
+// IsPaused reports whether a game's clock is paused for a disconnected player
+func (m *ConnectionMonitor) IsPaused(gameID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.isPaused(gameID)
+}
+
+// PausedSince returns how long a game's clock has been paused since the given
+// time, so move deadlines can be pushed back by it
+func (m *ConnectionMonitor) PausedSince(gameID string, since time.Time) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	var total time.Duration
+	for _, pause := range m.paused[gameID] {
+		start, end := pause.start, pause.end
+		if end.IsZero() {
+			end = now
+		}
+		if start.Before(since) {
+			start = since
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+// SetConnectionMonitor enables disconnect handling for live games. Move
+// deadlines are pushed back by the time the monitor paused clocks for.
+func (s *Service) SetConnectionMonitor(monitor *ConnectionMonitor) {
+	s.connections = monitor
+	s.client.SetClockPauses(monitor)
+}
+
+// ForfeitDisconnected claims the win for winnerDID using one of their
+// sessions, since only the players can write to their repositories
+func (s *Service) ForfeitDisconnected(ctx context.Context, gameID, absentDID, winnerDID string) error {
+	sessions := s.sessions.ListForDID(winnerDID)
+	if len(sessions) == 0 {
+		return fmt.Errorf("no session for %s to claim the game with", winnerDID)
+	}
+	return sessions[0].Client.ClaimDisconnectVictory(ctx, gameID, absentDID)
+}
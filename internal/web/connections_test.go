@@ -0,0 +1,249 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+type liveGame struct {
+	service  *Service
+	hub      *Hub
+	monitor  *ConnectionMonitor
+	whitePDS *fakePDS
+	blackPDS *fakePDS
+	gameID   string
+	wsURL    string
+	tokens   map[string]string
+}
+
+// newLiveGame sets up a game in white's repository with both players signed in
+func newLiveGame(t *testing.T, policy string, grace time.Duration) *liveGame {
+	t.Helper()
+	whitePDS := newFakePDS(t, testWhiteDID)
+	blackPDS := newFakePDS(t, testBlackDID)
+	game := &liveGame{
+		whitePDS: whitePDS,
+		blackPDS: blackPDS,
+		gameID:   seedGame(whitePDS, startFEN, "active"),
+		service:  newServiceForPDS(t, whitePDS),
+		hub:      NewHub(),
+		tokens:   make(map[string]string),
+	}
+	go game.hub.Run()
+
+	for did, pds := range map[string]*fakePDS{testWhiteDID: whitePDS, testBlackDID: blackPDS} {
+		client, err := atproto.NewClient(pds.URL, did, "password")
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		game.tokens[did], _ = game.service.Sessions().Create(client)
+	}
+
+	game.monitor = NewConnectionMonitor(game.hub, game.service.client.GetGame, game.service.ForfeitDisconnected, policy, grace)
+	game.hub.OnPresence(game.monitor.PlayerPresence)
+	game.service.SetConnectionMonitor(game.monitor)
+
+	server := httptest.NewServer(game.service.WebSocketHandler(game.hub))
+	t.Cleanup(server.Close)
+	game.wsURL = "ws" + strings.TrimPrefix(server.URL, "http") + "/?gameId=" + url.QueryEscape(game.gameID)
+	return game
+}
+
+// connect opens a player's game connection and waits for the hub to count it
+func (g *liveGame) connect(t *testing.T, did string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(g.wsURL+"&session="+g.tokens[did], nil)
+	if err != nil {
+		t.Fatalf("Failed to connect %s: %v", did, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !g.hub.IsOnline(g.gameID, did) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to be online", did)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return conn
+}
+
+type connectionFrame struct {
+	Type string                 `json:"type"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// readFrame reads frames until one of the given type arrives
+func readFrame(t *testing.T, conn *websocket.Conn, frameType string) connectionFrame {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Expected %s frame: %v", frameType, err)
+		}
+		// Queued frames are sent newline-separated in one message
+		for _, line := range strings.Split(string(message), "\n") {
+			var frame connectionFrame
+			if json.Unmarshal([]byte(line), &frame) == nil && frame.Type == frameType {
+				return frame
+			}
+		}
+	}
+}
+
+func TestDisconnectPausesClockUntilReconnect(t *testing.T) {
+	game := newLiveGame(t, DisconnectPolicyPause, time.Hour)
+	white := game.connect(t, testWhiteDID)
+	black := game.connect(t, testBlackDID)
+
+	black.Close()
+	frame := readFrame(t, white, "player_disconnected")
+	if frame.Data["playerDid"] != testBlackDID || frame.Data["clockPaused"] != true || frame.Data["graceSeconds"] != float64(3600) {
+		t.Errorf("Unexpected disconnect frame: %+v", frame.Data)
+	}
+	if !game.monitor.IsPaused(game.gameID) {
+		t.Error("Expected the clock to be paused")
+	}
+
+	game.connect(t, testBlackDID)
+	frame = readFrame(t, white, "player_reconnected")
+	if frame.Data["playerDid"] != testBlackDID {
+		t.Errorf("Unexpected reconnect frame: %+v", frame.Data)
+	}
+	if game.monitor.IsPaused(game.gameID) {
+		t.Error("Expected the clock to run again after reconnecting")
+	}
+}
+
+func TestPausedTimeIsNotChargedToThePlayerToMove(t *testing.T) {
+	game := newLiveGame(t, DisconnectPolicyPause, time.Hour)
+	ctx := context.Background()
+
+	// White's three days ran out half an hour ago, but the clock has been
+	// paused for the last hour and a half
+	record := game.whitePDS.get(game.gameID)
+	record["createdAt"] = time.Now().Add(-72*time.Hour - 30*time.Minute).Format(time.RFC3339)
+	game.whitePDS.put(game.gameID, record)
+	game.monitor.mu.Lock()
+	game.monitor.paused[game.gameID] = []clockPause{{start: time.Now().Add(-90 * time.Minute)}}
+	game.monitor.mu.Unlock()
+
+	deadline, err := game.service.client.GetMoveDeadline(ctx, game.gameID)
+	if err != nil {
+		t.Fatalf("Failed to get deadline: %v", err)
+	}
+	if remaining := time.Until(deadline.Deadline); remaining < 59*time.Minute || remaining > 61*time.Minute {
+		t.Errorf("Expected the deadline to be pushed back by the pause, %v left", remaining)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/games/x/time-violation", nil), map[string]string{"id": game.gameID})
+	w := httptest.NewRecorder()
+	game.service.CheckTimeViolationHandler(w, req)
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp["hasViolation"] != false || resp["clockPaused"] != true {
+		t.Errorf("Expected no violation while paused, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Once the clock runs again the pause still counts for this turn
+	game.monitor.mu.Lock()
+	game.monitor.resume(game.gameID)
+	game.monitor.mu.Unlock()
+	if violated, _, err := game.service.client.CheckTimeViolation(ctx, game.gameID); err != nil || violated {
+		t.Errorf("Expected the pause not to count against white, got %v %v", violated, err)
+	}
+
+	// A move starts a new turn, so the pause is forgotten
+	game.monitor.RecordMove(game.gameID)
+	if violated, _, err := game.service.client.CheckTimeViolation(ctx, game.gameID); err != nil || !violated {
+		t.Errorf("Expected a violation without the pause, got %v %v", violated, err)
+	}
+}
+
+func TestDisconnectForfeitsAfterGracePeriod(t *testing.T) {
+	game := newLiveGame(t, DisconnectPolicyForfeit, 50*time.Millisecond)
+	white := game.connect(t, testWhiteDID)
+	black := game.connect(t, testBlackDID)
+
+	black.Close()
+	readFrame(t, white, "player_disconnected")
+	frame := readFrame(t, white, "game_end")
+	if frame.Data["reason"] != "disconnected" || frame.Data["winner"] != testWhiteDID {
+		t.Errorf("Unexpected game end frame: %+v", frame.Data)
+	}
+
+	if status := game.whitePDS.get(game.gameID)["status"]; status != "white_won" {
+		t.Errorf("Expected white to win by forfeit, got %v", status)
+	}
+	claims := game.whitePDS.collection(testWhiteDID, "app.atchess.timeViolation")
+	if len(claims) != 1 {
+		t.Fatalf("Expected a disconnect claim, got %v", claims)
+	}
+	if claim := game.whitePDS.get(claims[0]); claim["reason"] != "disconnected" || claim["violatingPlayer"] != testBlackDID {
+		t.Errorf("Unexpected disconnect claim: %v", claim)
+	}
+}
+
+func TestDisconnectIgnoredWithoutOpponent(t *testing.T) {
+	game := newLiveGame(t, DisconnectPolicyForfeit, time.Hour)
+
+	// Nobody is watching, so this isn't a live game
+	game.monitor.PlayerPresence(game.gameID, testBlackDID, false)
+	if game.monitor.IsPaused(game.gameID) || len(game.monitor.away) != 0 {
+		t.Error("Expected no action when the opponent isn't connected")
+	}
+}
+
+func TestHubShutdownDrainsConnections(t *testing.T) {
+	game := newLiveGame(t, "forfeit", time.Millisecond)
+	conn := game.connect(t, testWhiteDID)
+
+	game.hub.BroadcastToGame(game.gameID, GameUpdate{Type: "move", Data: map[string]string{"san": "e4"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := game.hub.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	// The queued update still arrives, then the close frame
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	moved := false
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseServiceRestart) || !strings.Contains(err.Error(), "server restarting") {
+				t.Fatalf("Expected a service restart close frame, got %v", err)
+			}
+			break
+		}
+		for _, line := range strings.Split(string(message), "\n") {
+			var frame connectionFrame
+			json.Unmarshal([]byte(line), &frame)
+			switch frame.Type {
+			case "move":
+				moved = true
+			case "opponent_forfeit", "game_ended":
+				t.Errorf("Expected a restart not to count as leaving the game, got %s", line)
+			}
+		}
+	}
+
+	if !moved {
+		t.Error("Expected the queued move before the close frame")
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(game.wsURL+"&session="+game.tokens[testBlackDID], nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected new connections to be refused with 503, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// Clock thresholds used when a frame carries the per-move time limit
+const (
+	LowTimeFraction      = 0.10
+	CriticalTimeFraction = 0.02
+)
+
+// Clock thresholds used when the time limit is unknown
+const (
+	LowTimeThreshold      = time.Hour
+	CriticalTimeThreshold = 10 * time.Minute
+)
+
+// FrameCues are sound/vibration hints attached to broadcast frames so clients
+// don't have to re-derive them from the game state
+type FrameCues struct {
+	Check        bool `json:"check,omitempty"`
+	Checkmate    bool `json:"checkmate,omitempty"`
+	Capture      bool `json:"capture,omitempty"`
+	LowTime      bool `json:"lowTime,omitempty"`
+	CriticalTime bool `json:"criticalTime,omitempty"`
+}
+
+// ClockUpdate is the payload of a "clock" frame
+type ClockUpdate struct {
+	PlayerDID        string `json:"playerDid"`
+	RemainingSeconds int    `json:"remainingSeconds"`
+	LimitSeconds     int    `json:"limitSeconds,omitempty"`
+}
+
+// NewClockUpdate builds a clock frame for the player whose clock is running
+func NewClockUpdate(gameID, playerDID string, remaining, limit time.Duration) GameUpdate {
+	return GameUpdate{
+		GameID: gameID,
+		Type:   "clock",
+		Data: ClockUpdate{
+			PlayerDID:        playerDID,
+			RemainingSeconds: int(remaining.Seconds()),
+			LimitSeconds:     int(limit.Seconds()),
+		},
+	}
+}
+
+// enrichUpdate computes the cues for move and clock frames
+func enrichUpdate(update GameUpdate) GameUpdate {
+	switch update.Type {
+	case "move":
+		update.Cues = moveCues(update.Data)
+	case "clock":
+		update.Cues = clockCues(update.Data)
+	}
+	return update
+}
+
+func moveCues(data interface{}) *FrameCues {
+	var san string
+	cues := &FrameCues{}
+
+	switch move := data.(type) {
+	case *chess.MoveResult:
+		san, cues.Check, cues.Checkmate = move.SAN, move.Check, move.Checkmate
+	case chess.MoveResult:
+		san, cues.Check, cues.Checkmate = move.SAN, move.Check, move.Checkmate
+	case map[string]interface{}:
+		san, _ = move["san"].(string)
+		cues.Check, _ = move["check"].(bool)
+		cues.Checkmate, _ = move["checkmate"].(bool)
+	default:
+		return nil
+	}
+
+	// SAN marks captures with "x" and check/mate with "+"/"#"
+	cues.Capture = strings.Contains(san, "x")
+	if strings.HasSuffix(san, "#") {
+		cues.Checkmate = true
+	}
+	if strings.HasSuffix(san, "+") || cues.Checkmate {
+		cues.Check = true
+	}
+
+	return cues
+}
+
+func clockCues(data interface{}) *FrameCues {
+	var remaining, limit time.Duration
+
+	switch clock := data.(type) {
+	case ClockUpdate:
+		remaining = time.Duration(clock.RemainingSeconds) * time.Second
+		limit = time.Duration(clock.LimitSeconds) * time.Second
+	case *ClockUpdate:
+		remaining = time.Duration(clock.RemainingSeconds) * time.Second
+		limit = time.Duration(clock.LimitSeconds) * time.Second
+	case map[string]interface{}:
+		seconds, ok := clock["remainingSeconds"].(float64)
+		if !ok {
+			return nil
+		}
+		remaining = time.Duration(seconds) * time.Second
+		if limitSeconds, ok := clock["limitSeconds"].(float64); ok {
+			limit = time.Duration(limitSeconds) * time.Second
+		}
+	default:
+		return nil
+	}
+
+	low, critical := LowTimeThreshold, CriticalTimeThreshold
+	if limit > 0 {
+		low = time.Duration(float64(limit) * LowTimeFraction)
+		critical = time.Duration(float64(limit) * CriticalTimeFraction)
+	}
+
+	return &FrameCues{
+		LowTime:      remaining <= low,
+		CriticalTime: remaining <= critical,
+	}
+}
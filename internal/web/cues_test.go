@@ -0,0 +1,75 @@
+package web
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+func TestMoveCues(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     interface{}
+		expected FrameCues
+	}{
+		{name: "quiet move", data: &chess.MoveResult{SAN: "e4"}, expected: FrameCues{}},
+		{name: "capture with check", data: &chess.MoveResult{SAN: "Bxf7+", Check: true}, expected: FrameCues{Check: true, Capture: true}},
+		{name: "firehose record checkmate", data: map[string]interface{}{"san": "Qxf7#", "checkmate": true}, expected: FrameCues{Check: true, Checkmate: true, Capture: true}},
+		{name: "record without flags", data: map[string]interface{}{"san": "Nf3+"}, expected: FrameCues{Check: true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			update := enrichUpdate(GameUpdate{GameID: "game", Type: "move", Data: tc.data})
+			if update.Cues == nil {
+				t.Fatalf("Expected cues on move frame")
+			}
+			if *update.Cues != tc.expected {
+				t.Errorf("Expected cues %+v, got %+v", tc.expected, *update.Cues)
+			}
+		})
+	}
+}
+
+func TestClockCues(t *testing.T) {
+	limit := 24 * time.Hour
+
+	testCases := []struct {
+		name      string
+		remaining time.Duration
+		limit     time.Duration
+		expected  FrameCues
+	}{
+		{name: "plenty of time", remaining: 12 * time.Hour, limit: limit, expected: FrameCues{}},
+		{name: "low time", remaining: 2 * time.Hour, limit: limit, expected: FrameCues{LowTime: true}},
+		{name: "critical time", remaining: 20 * time.Minute, limit: limit, expected: FrameCues{LowTime: true, CriticalTime: true}},
+		{name: "unknown limit uses absolute thresholds", remaining: 30 * time.Minute, expected: FrameCues{LowTime: true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			update := enrichUpdate(NewClockUpdate("game", testWhiteDID, tc.remaining, tc.limit))
+			if update.Cues == nil {
+				t.Fatalf("Expected cues on clock frame")
+			}
+			if *update.Cues != tc.expected {
+				t.Errorf("Expected cues %+v, got %+v", tc.expected, *update.Cues)
+			}
+		})
+	}
+}
+
+func TestEnrichedFrameSerialization(t *testing.T) {
+	update := enrichUpdate(GameUpdate{GameID: "game", Type: "draw_offer", Data: map[string]interface{}{}})
+	data, _ := json.Marshal(update)
+
+	var frame map[string]interface{}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		t.Fatalf("Failed to parse frame: %v", err)
+	}
+	if _, ok := frame["cues"]; ok {
+		t.Errorf("Expected no cues on non-move frames, got %v", frame["cues"])
+	}
+}
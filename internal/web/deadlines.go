@@ -0,0 +1,104 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// deadlineDisplayFormat is how deadlines are shown to players, in their timezone
+const deadlineDisplayFormat = "Mon 2 Jan 2006 15:04 MST"
+
+// Deadline is when a game's player to move must move by, both as an absolute
+// UTC time and in the viewer's timezone, so every view of the game - and
+// every player, wherever they are - agrees on the same moment
+type Deadline struct {
+	GameID           string    `json:"gameId"`
+	PlayerToMove     string    `json:"playerToMove"`
+	YourMove         bool      `json:"yourMove"`
+	Deadline         time.Time `json:"deadline"`      // UTC
+	LocalDeadline    string    `json:"localDeadline"` // RFC 3339 with the viewer's UTC offset
+	Timezone         string    `json:"timezone"`
+	Display          string    `json:"display"` // e.g. "Tue 5 Mar 2024 18:30 CET"
+	RemainingSeconds int       `json:"remainingSeconds"`
+}
+
+// NewDeadline localizes a move deadline for a viewer in loc
+func NewDeadline(move *atproto.MoveDeadline, viewerDID string, loc *time.Location, now time.Time) *Deadline {
+	deadline := move.Deadline.UTC()
+	local := deadline.In(loc)
+
+	remaining := deadline.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &Deadline{
+		GameID:           move.GameID,
+		PlayerToMove:     move.PlayerToMove,
+		YourMove:         move.PlayerToMove == viewerDID,
+		Deadline:         deadline,
+		LocalDeadline:    local.Format(time.RFC3339),
+		Timezone:         loc.String(),
+		Display:          local.Format(deadlineDisplayFormat),
+		RemainingSeconds: int(remaining.Seconds()),
+	}
+}
+
+// Deadlines returns the move deadlines of a player's active games in their
+// preferred timezone, soonest first. Games without per-move deadlines are
+// left out.
+func (s *Service) Deadlines(ctx context.Context, client *atproto.Client) ([]*Deadline, error) {
+	return s.playerDeadlines(ctx, client, client.GetDID(), s.loadPreferences(ctx, client).Location())
+}
+
+// playerDeadlines returns the move deadlines of did's active games in loc,
+// read through reader, soonest first
+func (s *Service) playerDeadlines(ctx context.Context, reader *atproto.Client, did string, loc *time.Location) ([]*Deadline, error) {
+	games, err := reader.ListGames(ctx, did, string(chess.StatusActive))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deadlines := []*Deadline{}
+	for _, game := range games {
+		move, err := reader.GetMoveDeadline(ctx, game.ID)
+		if errors.Is(err, atproto.ErrNoMoveDeadline) {
+			continue
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("gameID", game.ID).Msg("Failed to compute move deadline")
+			continue
+		}
+		deadlines = append(deadlines, NewDeadline(move, did, loc, now))
+	}
+
+	sort.Slice(deadlines, func(i, j int) bool {
+		return deadlines[i].Deadline.Before(deadlines[j].Deadline)
+	})
+	return deadlines, nil
+}
+
+// ListDeadlinesHandler returns the current player's move deadlines
+func (s *Service) ListDeadlinesHandler(w http.ResponseWriter, r *http.Request) {
+	deadlines, err := s.Deadlines(r.Context(), s.clientFor(r))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list deadlines")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list deadlines"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"deadlines": deadlines,
+	})
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+func TestNewDeadlineLocalizesForViewer(t *testing.T) {
+	move := &atproto.MoveDeadline{
+		GameID:       "at://did:plc:white/app.atchess.game/g1",
+		PlayerToMove: testWhiteDID,
+		Deadline:     time.Date(2024, 3, 5, 17, 30, 0, 0, time.UTC),
+	}
+	now := time.Date(2024, 3, 5, 16, 30, 0, 0, time.UTC)
+
+	paris, _ := time.LoadLocation("Europe/Paris")
+	newYork, _ := time.LoadLocation("America/New_York")
+	white := NewDeadline(move, testWhiteDID, paris, now)
+	black := NewDeadline(move, testBlackDID, newYork, now)
+
+	if white.LocalDeadline != "2024-03-05T18:30:00+01:00" || white.Display != "Tue 5 Mar 2024 18:30 CET" || !white.YourMove {
+		t.Errorf("Unexpected deadline in Paris: %+v", white)
+	}
+	if black.LocalDeadline != "2024-03-05T12:30:00-05:00" || black.Display != "Tue 5 Mar 2024 12:30 EST" || black.YourMove {
+		t.Errorf("Unexpected deadline in New York: %+v", black)
+	}
+	// Both players see the same instant
+	if !white.Deadline.Equal(black.Deadline) || white.Deadline.Location() != time.UTC {
+		t.Errorf("Expected the same UTC deadline, got %v and %v", white.Deadline, black.Deadline)
+	}
+	if white.RemainingSeconds != 3600 {
+		t.Errorf("Expected an hour remaining, got %d seconds", white.RemainingSeconds)
+	}
+}
+
+func TestListDeadlinesUsesPreferredTimezone(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	createdAt := time.Now().Add(-24 * time.Hour).UTC().Truncate(time.Second)
+	for rkey, tc := range map[string]map[string]interface{}{
+		"corr":  {"type": "correspondence", "daysPerMove": float64(2)},
+		"blitz": {"type": "blitz", "initial": float64(300)},
+	} {
+		pds.put(fmt.Sprintf("at://%s/app.atchess.game/%s", testWhiteDID, rkey), map[string]interface{}{
+			"createdAt":   createdAt.Format(time.RFC3339),
+			"white":       testWhiteDID,
+			"black":       testBlackDID,
+			"status":      "active",
+			"fen":         startFEN,
+			"timeControl": tc,
+		})
+	}
+
+	save := func(body string) int {
+		return savePreferences(t, service, pds, body).Code
+	}
+	if code := save(`{"timezone": "Mars/Olympus_Mons"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown timezone, got %d", code)
+	}
+	if code := save(`{"timezone": "Local"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for the server's local timezone, got %d", code)
+	}
+	if code := save(`{"timezone": "Asia/Tokyo"}`); code != http.StatusOK {
+		t.Fatalf("Expected preferences to be saved, got %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	service.ListDeadlinesHandler(w, asPlayer(httptest.NewRequest("GET", "/api/deadlines", nil), service.client))
+	var resp struct {
+		Deadlines []*Deadline `json:"deadlines"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+
+	// Live games have no per-move deadline
+	if len(resp.Deadlines) != 1 {
+		t.Fatalf("Expected one deadline, got %s", w.Body.String())
+	}
+	deadline := resp.Deadlines[0]
+	want := createdAt.Add(48 * time.Hour)
+	if !deadline.Deadline.Equal(want) || deadline.Timezone != "Asia/Tokyo" || !deadline.YourMove {
+		t.Errorf("Unexpected deadline: %+v", deadline)
+	}
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	if deadline.LocalDeadline != want.In(tokyo).Format(time.RFC3339) {
+		t.Errorf("Expected the deadline in Tokyo time, got %s", deadline.LocalDeadline)
+	}
+}
+
+func TestDeadlinesCalendarFeed(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	service.config.Server.BaseURL = "https://chess.example.com"
+
+	createdAt := time.Now().Add(-24 * time.Hour).UTC().Truncate(time.Second)
+	gameID := fmt.Sprintf("at://%s/app.atchess.game/corr", testWhiteDID)
+	pds.put(gameID, map[string]interface{}{
+		"createdAt":   createdAt.Format(time.RFC3339),
+		"white":       testWhiteDID,
+		"black":       testBlackDID,
+		"status":      "active",
+		"fen":         startFEN,
+		"timeControl": map[string]interface{}{"type": "correspondence", "daysPerMove": float64(2)},
+	})
+
+	// Calendar apps subscribe without a session
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/players/"+testWhiteDID+"/deadlines.ics", nil), map[string]string{"did": testWhiteDID})
+	w := httptest.NewRecorder()
+	service.DeadlinesCalendarHandler(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/calendar; charset=utf-8" {
+		t.Fatalf("Expected a calendar, got %d %v", w.Code, w.Header())
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"BEGIN:VEVENT\r\n",
+		"DTSTART:" + createdAt.Add(48*time.Hour).Format("20060102T150405Z") + "\r\n",
+		"SUMMARY:Your move due in ATChess\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the calendar to contain %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Count(body, "BEGIN:VEVENT") != 1 {
+		t.Errorf("Expected one event, got:\n%s", body)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Expected lines to be folded at 75 octets, got %q", line)
+		}
+	}
+	// Folded lines unfold to the game's link
+	if unfolded := strings.ReplaceAll(body, "\r\n ", ""); !strings.Contains(unfolded, "URL:https://chess.example.com/") {
+		t.Errorf("Expected a link to the game, got:\n%s", body)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/api/players/white/deadlines.ics", nil), map[string]string{"did": "white.test"})
+	w = httptest.NewRecorder()
+	service.DeadlinesCalendarHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a feed that isn't by DID, got %d", w.Code)
+	}
+}
+
+func TestFoldICSLineKeepsCharactersWhole(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("é", 40)
+	folded := foldICSLine(line)
+	for _, part := range strings.Split(folded, "\r\n") {
+		if len(part) > 75 || !utf8.ValidString(part) {
+			t.Errorf("Expected whole characters in lines of at most 75 octets, got %q", part)
+		}
+	}
+	if strings.ReplaceAll(folded, "\r\n ", "") != line {
+		t.Errorf("Expected the folded line to unfold to the original, got %q", folded)
+	}
+}
@@ -0,0 +1,351 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/mail"
+	"github.com/rs/zerolog/log"
+)
+
+// Defaults for DigestOptions left at zero
+const (
+	DefaultDigestInterval = time.Hour
+	DefaultPendingAfter   = 24 * time.Hour
+	DefaultExpiryWarning  = 12 * time.Hour
+)
+
+// Kinds of digest item
+const (
+	digestPendingMove   = "pending"
+	digestExpiringClock = "expiring"
+	digestChallenge     = "challenge"
+)
+
+// DigestOptions control what goes into each player's email digest
+type DigestOptions struct {
+	// Interval is how often digests are put together
+	Interval time.Duration
+	// PendingAfter is how long it must have been the player's move before
+	// they are reminded of the game
+	PendingAfter time.Duration
+	// ExpiryWarning is how close a correspondence deadline must be before
+	// the player is warned about it
+	ExpiryWarning time.Duration
+	// BaseURL prefixes links to games; without it links are left out
+	BaseURL string
+}
+
+// digestItem is one line of a digest
+type digestItem struct {
+	kind     string
+	gameID   string
+	opponent string // DID
+	deadline time.Time
+	message  string // a challenge's message
+}
+
+// EmailDigest emails players who opt in a summary of games waiting on them:
+// moves they haven't made in a while, correspondence clocks about to run
+// out, and challenges they've received. Each game is only mentioned once per
+// turn, and nothing is sent to players with nothing new.
+//
+// Players' addresses come from their PDS accounts and are only known once
+// they've logged in since the service started; they are never written to
+// their repositories.
+type EmailDigest struct {
+	sender  mail.Sender
+	client  *atproto.Client
+	options DigestOptions
+	now     func() time.Time
+
+	mu         sync.Mutex
+	addresses  map[string]string       // DID -> email address
+	challenges map[string][]digestItem // DID -> challenges since their last digest
+	sent       map[string]time.Time    // reminders already sent -> when they lapse
+}
+
+// NewEmailDigest creates a digest that reads players' preferences and games
+// through client and sends through sender
+func NewEmailDigest(sender mail.Sender, client *atproto.Client, options DigestOptions) *EmailDigest {
+	if options.Interval <= 0 {
+		options.Interval = DefaultDigestInterval
+	}
+	if options.PendingAfter <= 0 {
+		options.PendingAfter = DefaultPendingAfter
+	}
+	if options.ExpiryWarning <= 0 {
+		options.ExpiryWarning = DefaultExpiryWarning
+	}
+	options.BaseURL = strings.TrimSuffix(options.BaseURL, "/")
+	return &EmailDigest{
+		sender:     sender,
+		client:     client,
+		options:    options,
+		now:        time.Now,
+		addresses:  make(map[string]string),
+		challenges: make(map[string][]digestItem),
+		sent:       make(map[string]time.Time),
+	}
+}
+
+// SetEmailDigest enables email digests for players who opt in
+func (s *Service) SetEmailDigest(digest *EmailDigest) {
+	s.emailDigest = digest
+}
+
+// Register records the address a player's digests go to
+func (d *EmailDigest) Register(did, address string) {
+	if did == "" || address == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.addresses[did] = address
+}
+
+// QueueChallenge adds a challenge to its recipient's next digest
+func (d *EmailDigest) QueueChallenge(challenge *chess.Challenge) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.addresses[challenge.Challenged]; !ok {
+		return
+	}
+	d.challenges[challenge.Challenged] = append(d.challenges[challenge.Challenged], digestItem{
+		kind:     digestChallenge,
+		opponent: challenge.Challenger,
+		message:  challenge.Message,
+	})
+}
+
+// Start sends digests every Interval until ctx is cancelled
+func (d *EmailDigest) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.options.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.Run(ctx)
+			}
+		}
+	}()
+}
+
+// Run sends each registered player who has something new their digest
+func (d *EmailDigest) Run(ctx context.Context) {
+	now := d.now()
+
+	d.mu.Lock()
+	for key, lapses := range d.sent {
+		if now.After(lapses) {
+			delete(d.sent, key)
+		}
+	}
+	addresses := make(map[string]string, len(d.addresses))
+	for did, address := range d.addresses {
+		addresses[did] = address
+	}
+	d.mu.Unlock()
+
+	for did, address := range addresses {
+		if ctx.Err() != nil {
+			return
+		}
+		d.runFor(ctx, did, address, now)
+	}
+}
+
+// runFor sends one player's digest, if they want one and there's anything in it
+func (d *EmailDigest) runFor(ctx context.Context, did, address string, now time.Time) {
+	prefs, err := d.client.GetPreferences(ctx, did)
+	if err != nil && !errors.Is(err, atproto.ErrNoPreferences) {
+		log.Warn().Err(err).Str("did", did).Msg("Failed to read preferences for email digest")
+		return
+	}
+	if prefs == nil || !prefs.EmailNotifications.Any() {
+		d.mu.Lock()
+		delete(d.challenges, did)
+		d.mu.Unlock()
+		return
+	}
+	opts := prefs.EmailNotifications
+
+	d.mu.Lock()
+	var items []digestItem
+	if opts.Challenges {
+		items = append(items, d.challenges[did]...)
+	}
+	queued := len(d.challenges[did])
+	d.mu.Unlock()
+
+	reminders, keys := d.reminders(ctx, did, opts, now)
+	items = append(items, reminders...)
+	if len(items) == 0 {
+		d.dropChallenges(did, queued)
+		return
+	}
+
+	loc := Preferences{Timezone: prefs.Timezone}.Location()
+	msg := mail.Message{
+		To:      address,
+		Subject: digestSubject(items),
+		Body:    d.digestBody(items, loc),
+	}
+	if err := d.sender.Send(ctx, msg); err != nil {
+		// Everything stays queued for the next digest
+		log.Error().Err(err).Str("did", did).Msg("Failed to send email digest")
+		return
+	}
+
+	d.mu.Lock()
+	for key, lapses := range keys {
+		d.sent[key] = lapses
+	}
+	d.mu.Unlock()
+	d.dropChallenges(did, queued)
+}
+
+// dropChallenges removes the first n queued challenges, which have been dealt
+// with; any that arrived since stay for the next digest
+func (d *EmailDigest) dropChallenges(did string, n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if remaining := d.challenges[did][n:]; len(remaining) > 0 {
+		d.challenges[did] = remaining
+	} else {
+		delete(d.challenges, did)
+	}
+}
+
+// reminders finds the player's games that are due a reminder they haven't
+// had yet, along with the keys to mark them sent by and when those lapse
+func (d *EmailDigest) reminders(ctx context.Context, did string, opts atproto.EmailNotifications, now time.Time) ([]digestItem, map[string]time.Time) {
+	if !opts.PendingMoves && !opts.ExpiringClocks {
+		return nil, nil
+	}
+	games, err := d.client.ListGames(ctx, did, string(chess.StatusActive))
+	if err != nil {
+		log.Warn().Err(err).Str("did", did).Msg("Failed to list games for email digest")
+		return nil, nil
+	}
+
+	var items []digestItem
+	keys := make(map[string]time.Time)
+	for _, game := range games {
+		move, err := d.client.GetMoveDeadline(ctx, game.ID)
+		if err != nil {
+			if !errors.Is(err, atproto.ErrNoMoveDeadline) {
+				log.Warn().Err(err).Str("gameID", game.ID).Msg("Failed to compute move deadline for email digest")
+			}
+			continue
+		}
+		if move.PlayerToMove != did || !now.Before(move.Deadline) {
+			continue
+		}
+
+		// A clock about to run out says all a pending move reminder would
+		kind := ""
+		switch {
+		case opts.ExpiringClocks && move.Deadline.Sub(now) <= d.options.ExpiryWarning:
+			kind = digestExpiringClock
+		case opts.PendingMoves && now.Sub(move.TurnStarted) >= d.options.PendingAfter:
+			kind = digestPendingMove
+		default:
+			continue
+		}
+
+		key := strings.Join([]string{did, game.ID, kind, move.TurnStarted.Format(time.RFC3339)}, "|")
+		d.mu.Lock()
+		_, done := d.sent[key]
+		d.mu.Unlock()
+		if done {
+			continue
+		}
+		keys[key] = move.Deadline
+		items = append(items, digestItem{
+			kind:     kind,
+			gameID:   game.ID,
+			opponent: opponentOf(game, did),
+			deadline: move.Deadline,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].deadline.Before(items[j].deadline)
+	})
+	return items, keys
+}
+
+// digestSubject sums up a digest in its subject line
+func digestSubject(items []digestItem) string {
+	counts := make(map[string]int)
+	for _, item := range items {
+		counts[item.kind]++
+	}
+	var parts []string
+	if n := counts[digestExpiringClock]; n > 0 {
+		parts = append(parts, plural(n, "clock", "clocks")+" running out")
+	}
+	if n := counts[digestPendingMove]; n > 0 {
+		parts = append(parts, plural(n, "game", "games")+" waiting on you")
+	}
+	if n := counts[digestChallenge]; n > 0 {
+		parts = append(parts, plural(n, "new challenge", "new challenges"))
+	}
+	return "ATChess: " + strings.Join(parts, ", ")
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// digestBody lists a digest's items, with deadlines in the player's timezone
+func (d *EmailDigest) digestBody(items []digestItem, loc *time.Location) string {
+	var b strings.Builder
+	b.WriteString("Here's what's waiting for you on ATChess.\n")
+	for _, item := range items {
+		b.WriteString("\n")
+		switch item.kind {
+		case digestExpiringClock:
+			fmt.Fprintf(&b, "Your clock against %s runs out at %s.\n", item.opponent, item.deadline.In(loc).Format(deadlineDisplayFormat))
+		case digestPendingMove:
+			fmt.Fprintf(&b, "It's your move against %s. You have until %s.\n", item.opponent, item.deadline.In(loc).Format(deadlineDisplayFormat))
+		case digestChallenge:
+			fmt.Fprintf(&b, "%s has challenged you to a game.\n", item.opponent)
+			if item.message != "" {
+				fmt.Fprintf(&b, "  %q\n", item.message)
+			}
+		}
+		if link := d.link(item.gameID); link != "" {
+			fmt.Fprintf(&b, "  %s\n", link)
+		}
+	}
+	b.WriteString("\nYou can turn these emails off in your ATChess preferences.\n")
+	return b.String()
+}
+
+// link returns a link to a game, or to the lobby for challenges
+func (d *EmailDigest) link(gameID string) string {
+	if d.options.BaseURL == "" {
+		return ""
+	}
+	if gameID == "" {
+		return d.options.BaseURL + "/"
+	}
+	return d.options.BaseURL + "/?game=" + base64.URLEncoding.EncodeToString([]byte(gameID))
+}
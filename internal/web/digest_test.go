@@ -0,0 +1,114 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/mail"
+)
+
+// fakeSender records the email it's asked to send
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []mail.Message
+}
+
+func (f *fakeSender) Send(ctx context.Context, msg mail.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func (f *fakeSender) take() []mail.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sent := f.sent
+	f.sent = nil
+	return sent
+}
+
+func TestEmailDigest(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	// White has had 30 hours of a two-day move
+	now := time.Now().UTC().Truncate(time.Second)
+	gameID := fmt.Sprintf("at://%s/app.atchess.game/corr", testWhiteDID)
+	pds.put(gameID, map[string]interface{}{
+		"createdAt":   now.Add(-30 * time.Hour).Format(time.RFC3339),
+		"white":       testWhiteDID,
+		"black":       testBlackDID,
+		"status":      "active",
+		"fen":         startFEN,
+		"timeControl": map[string]interface{}{"type": "correspondence", "daysPerMove": float64(2)},
+	})
+
+	sender := &fakeSender{}
+	digest := NewEmailDigest(sender, service.client, DigestOptions{
+		PendingAfter:  24 * time.Hour,
+		ExpiryWarning: 12 * time.Hour,
+		BaseURL:       "https://chess.example/",
+	})
+	digest.now = func() time.Time { return now }
+	digest.Register(testWhiteDID, "white@example.com")
+	digest.QueueChallenge(&chess.Challenge{Challenger: testBlackDID, Challenged: testWhiteDID, Message: "Fancy a game?"})
+
+	// Nothing is sent until the player opts in
+	digest.Run(context.Background())
+	if sent := sender.take(); len(sent) != 0 {
+		t.Fatalf("Expected no email without opting in, got %v", sent)
+	}
+
+	pds.put(fmt.Sprintf("at://%s/app.atchess.preferences/self", testWhiteDID), map[string]interface{}{
+		"timezone":           "Asia/Tokyo",
+		"emailNotifications": map[string]interface{}{"pendingMoves": true, "expiringClocks": true, "challenges": true},
+		"updatedAt":          now.Format(time.RFC3339),
+	})
+	digest.QueueChallenge(&chess.Challenge{Challenger: testBlackDID, Challenged: testWhiteDID, Message: "Fancy a game?"})
+	digest.Run(context.Background())
+	sent := sender.take()
+	if len(sent) != 1 {
+		t.Fatalf("Expected one digest, got %d", len(sent))
+	}
+	if sent[0].To != "white@example.com" || sent[0].Subject != "ATChess: 1 game waiting on you, 1 new challenge" {
+		t.Errorf("Unexpected digest: %+v", sent[0])
+	}
+	for _, want := range []string{"It's your move against " + testBlackDID, "JST", "Fancy a game?", "https://chess.example/?game="} {
+		if !strings.Contains(sent[0].Body, want) {
+			t.Errorf("Expected the digest to contain %q, got:\n%s", want, sent[0].Body)
+		}
+	}
+
+	// The same turn isn't mentioned twice
+	digest.Run(context.Background())
+	if sent := sender.take(); len(sent) != 0 {
+		t.Fatalf("Expected nothing new to send, got %v", sent)
+	}
+
+	// Until the clock is about to run out
+	digest.now = func() time.Time { return now.Add(7 * time.Hour) }
+	digest.Run(context.Background())
+	sent = sender.take()
+	if len(sent) != 1 || !strings.Contains(sent[0].Body, "Your clock against "+testBlackDID+" runs out") {
+		t.Fatalf("Expected a warning about the clock, got %v", sent)
+	}
+}
+
+func TestEmailDigestIgnoresUnregisteredPlayers(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	sender := &fakeSender{}
+	digest := NewEmailDigest(sender, service.client, DigestOptions{})
+
+	digest.QueueChallenge(&chess.Challenge{Challenger: testBlackDID, Challenged: testWhiteDID})
+	digest.Run(context.Background())
+	if sent := sender.take(); len(sent) != 0 {
+		t.Errorf("Expected no email for a player without an address, got %v", sent)
+	}
+}
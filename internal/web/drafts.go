@@ -0,0 +1,201 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxDraftNoteLength bounds the analysis note stored with a draft
+const MaxDraftNoteLength = 4000
+
+var (
+	squarePattern    = regexp.MustCompile(`^[a-h][1-8]$`)
+	promotionPattern = regexp.MustCompile(`^[qrbn]$`)
+)
+
+// MoveDraft is an unsent reply a player is composing in a correspondence game.
+// Drafts are kept on the server rather than in the player's repository so the
+// opponent can't read them.
+type MoveDraft struct {
+	GameID    string    `json:"gameId"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+	Promotion string    `json:"promotion,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	FEN       string    `json:"fen"` // position the draft was composed against
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// DraftStore keeps one draft per player per game
+type DraftStore struct {
+	drafts map[string]*MoveDraft
+	mu     sync.RWMutex
+}
+
+// NewDraftStore creates an empty draft store
+func NewDraftStore() *DraftStore {
+	return &DraftStore{drafts: make(map[string]*MoveDraft)}
+}
+
+func draftKey(did, gameID string) string {
+	return did + "|" + gameID
+}
+
+// Get returns the player's draft for a game
+func (d *DraftStore) Get(did, gameID string) (*MoveDraft, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	draft, ok := d.drafts[draftKey(did, gameID)]
+	if !ok {
+		return nil, false
+	}
+	copied := *draft
+	return &copied, true
+}
+
+// Put replaces the player's draft for a game
+func (d *DraftStore) Put(did string, draft *MoveDraft) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.drafts[draftKey(did, draft.GameID)] = draft
+}
+
+// Delete removes the player's draft for a game
+func (d *DraftStore) Delete(did, gameID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.drafts, draftKey(did, gameID))
+}
+
+// SaveDraftRequest is the body of PUT /api/games/{id}/draft
+type SaveDraftRequest struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Promotion string `json:"promotion"`
+	Note      string `json:"note"`
+}
+
+// DraftResponse is a stored draft along with whether the game has moved on since
+type DraftResponse struct {
+	*MoveDraft
+	Stale bool `json:"stale"`
+}
+
+// SaveDraftHandler stores the caller's unsent move and note for a game.
+// An empty draft clears any stored one.
+func (s *Service) SaveDraftHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	var req SaveDraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+
+	if (req.From == "") != (req.To == "") {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Both from and to are required for a draft move"))
+		return
+	}
+	if req.From != "" && (!squarePattern.MatchString(req.From) || !squarePattern.MatchString(req.To)) {
+		apierror.Write(w, apierror.ErrInvalidMove.WithMessage("Invalid square in draft move"))
+		return
+	}
+	if req.Promotion != "" && !promotionPattern.MatchString(req.Promotion) {
+		apierror.Write(w, apierror.ErrInvalidMove.WithMessage("Invalid promotion piece"))
+		return
+	}
+	if len(req.Note) > MaxDraftNoteLength {
+		apierror.Write(w, apierror.ErrMessageTooLong.WithMessage("Note is too long"))
+		return
+	}
+
+	client := s.clientFor(r)
+	did := client.GetDID()
+
+	game, ok := s.loadGameForPlayer(w, client.GetGame, gameID, did)
+	if !ok {
+		return
+	}
+
+	if req.From == "" && req.Note == "" {
+		s.drafts.Delete(did, gameID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	draft := &MoveDraft{
+		GameID:    gameID,
+		From:      req.From,
+		To:        req.To,
+		Promotion: req.Promotion,
+		Note:      req.Note,
+		FEN:       game.FEN,
+		UpdatedAt: time.Now(),
+	}
+	s.drafts.Put(did, draft)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(DraftResponse{MoveDraft: draft})
+}
+
+// GetDraftHandler returns the caller's stored draft for a game
+func (s *Service) GetDraftHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	client := s.clientFor(r)
+	did := client.GetDID()
+
+	game, ok := s.loadGameForPlayer(w, client.GetGame, gameID, did)
+	if !ok {
+		return
+	}
+
+	draft, found := s.drafts.Get(did, gameID)
+	if !found {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("No draft for this game"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(DraftResponse{
+		MoveDraft: draft,
+		Stale:     draft.FEN != game.FEN,
+	})
+}
+
+// loadGameForPlayer fetches a game and writes an error response unless did is one of its players
+func (s *Service) loadGameForPlayer(w http.ResponseWriter, fetch GameFetcher, gameID, did string) (*chess.Game, bool) {
+	game, err := fetch(context.Background(), gameID)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to fetch game for draft")
+		apierror.Write(w, apierror.ErrGameNotFound)
+		return nil, false
+	}
+
+	if did != game.White && did != game.Black {
+		apierror.Write(w, apierror.ErrNotAPlayer)
+		return nil, false
+	}
+
+	return game, true
+}
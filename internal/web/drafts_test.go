@@ -0,0 +1,115 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func draftRequest(s *Service, method, gameID string, body interface{}) *httptest.ResponseRecorder {
+	encoded := base64.URLEncoding.EncodeToString([]byte(gameID))
+	reqBody, _ := json.Marshal(body)
+	req := mux.SetURLVars(httptest.NewRequest(method, "/api/games/"+encoded+"/draft", bytes.NewReader(reqBody)), map[string]string{"id": encoded})
+	w := httptest.NewRecorder()
+	if method == "PUT" {
+		s.SaveDraftHandler(w, asPlayer(req, s.client))
+	} else {
+		s.GetDraftHandler(w, asPlayer(req, s.client))
+	}
+	return w
+}
+
+func TestDraftRoundTrip(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	if w := draftRequest(service, "GET", gameID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 before a draft is saved, got %d", w.Code)
+	}
+
+	w := draftRequest(service, "PUT", gameID, SaveDraftRequest{From: "g1", To: "f3", Note: "Keep e4 flexible"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected draft to be saved, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = draftRequest(service, "GET", gameID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected stored draft, got %d: %s", w.Code, w.Body.String())
+	}
+	var draft DraftResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &draft); err != nil {
+		t.Fatalf("Failed to decode draft: %v", err)
+	}
+	if draft.From != "g1" || draft.To != "f3" || draft.Note != "Keep e4 flexible" || draft.Stale {
+		t.Errorf("Unexpected draft: %+v", draft)
+	}
+
+	if w := draftRequest(service, "PUT", gameID, SaveDraftRequest{}); w.Code != http.StatusNoContent {
+		t.Errorf("Expected empty draft to clear, got %d", w.Code)
+	}
+	if w := draftRequest(service, "GET", gameID, nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected draft to be cleared, got %d", w.Code)
+	}
+}
+
+func TestDraftClearedAfterMoveAndMarkedStale(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	draftRequest(service, "PUT", gameID, SaveDraftRequest{From: "e2", To: "e4"})
+
+	if w := postMove(service, map[string]interface{}{"from": "e2", "to": "e4", "game_id": gameID}); w.Code != http.StatusOK {
+		t.Fatalf("Expected move to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if _, ok := service.drafts.Get(testWhiteDID, gameID); ok {
+		t.Error("Expected the mover's draft to be cleared")
+	}
+
+	draftRequest(service, "PUT", gameID, SaveDraftRequest{Note: "Plan"})
+	// The opponent replies after the draft was saved
+	record := pds.get(gameID)
+	record["fen"] = "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2"
+	pds.put(gameID, record)
+
+	var draft DraftResponse
+	w := draftRequest(service, "GET", gameID, nil)
+	_ = json.Unmarshal(w.Body.Bytes(), &draft)
+	if !draft.Stale {
+		t.Errorf("Expected draft composed against an older position to be stale: %s", w.Body.String())
+	}
+}
+
+func TestDraftRejectsInvalidInput(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	for _, req := range []SaveDraftRequest{
+		{From: "e2"},
+		{From: "e9", To: "e4"},
+		{From: "e7", To: "e8", Promotion: "k"},
+		{Note: string(make([]byte, MaxDraftNoteLength+1))},
+	} {
+		if w := draftRequest(service, "PUT", gameID, req); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %+v, got %d", req, w.Code)
+		}
+	}
+}
+
+func TestDraftRequiresPlayer(t *testing.T) {
+	pds := newFakePDS(t, "did:plc:spectator")
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	if w := draftRequest(service, "PUT", gameID, SaveDraftRequest{Note: "hi"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-player, got %d", w.Code)
+	}
+}
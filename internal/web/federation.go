@@ -0,0 +1,86 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/federation"
+	"github.com/rs/zerolog/log"
+)
+
+// SetFederation enables discovery of, and by, other ATChess instances
+func (s *Service) SetFederation(dir *federation.Directory) {
+	s.federation = dir
+}
+
+// InstanceWellKnownHandler advertises this instance's DID and instance record
+func (s *Service) InstanceWellKnownHandler(w http.ResponseWriter, r *http.Request) {
+	if s.federation == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Federation is disabled"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.federation.WellKnown())
+}
+
+// FederationHelloHandler registers a peer instance that completed a handshake with us
+func (s *Service) FederationHelloHandler(w http.ResponseWriter, r *http.Request) {
+	if s.federation == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Federation is disabled"))
+		return
+	}
+
+	var req struct {
+		DID string `json:"did"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+
+	peer, err := s.federation.Accept(r.Context(), req.DID)
+	if err != nil {
+		log.Warn().Err(err).Str("did", req.DID).Msg("Rejected federation hello")
+		apierror.Write(w, apierror.ErrForbidden.WithMessage(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(peer)
+}
+
+// ListInstancesHandler returns the peer instances this instance federates with
+func (s *Service) ListInstancesHandler(w http.ResponseWriter, r *http.Request) {
+	peers := []federation.Peer{}
+	if s.federation != nil {
+		peers = s.federation.Peers()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"instances": peers,
+		"total":     len(peers),
+	})
+}
+
+// remoteGames reads the spectator index of every peer instance
+func (s *Service) remoteGames(ctx context.Context) []GameIndex {
+	if s.federation == nil {
+		return nil
+	}
+
+	var games []GameIndex
+	for _, item := range s.federation.Collect(ctx, federation.EndpointSpectatorGames, "games") {
+		var game GameIndex
+		if err := json.Unmarshal(item.Item, &game); err != nil {
+			log.Warn().Err(err).Str("instance", item.Instance).Msg("Skipping malformed remote game")
+			continue
+		}
+		game.Instance = item.Instance
+		games = append(games, game)
+	}
+	return games
+}
@@ -0,0 +1,26 @@
+package web
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FinalizeMovedGame settles a game once a move made by mover is in, if the
+// game record is their opponent's. Records are only written by their owners,
+// so this takes the owner being signed in here; our own moves are finalized
+// as they're recorded.
+func (s *Service) FinalizeMovedGame(ctx context.Context, gameURI, mover string) {
+	parts := strings.Split(gameURI, "/")
+	if len(parts) < 5 || parts[2] == mover {
+		return
+	}
+	client := s.playerClient(parts[2])
+	if client == nil {
+		return
+	}
+	if _, err := client.FinalizeGame(ctx, gameURI); err != nil {
+		log.Warn().Err(err).Str("gameID", gameURI).Msg("Failed to finalize game")
+	}
+}
@@ -0,0 +1,172 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// getGame fetches a game as the service account's player, who settles games
+// whose record is theirs
+func getGame(s *Service, gameID string) *httptest.ResponseRecorder {
+	encoded := base64.URLEncoding.EncodeToString([]byte(gameID))
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/games/"+encoded, nil), map[string]string{"id": encoded})
+	w := httptest.NewRecorder()
+	s.GetGameHandler(w, asPlayer(req, s.client))
+	return w
+}
+
+func TestMakeMoveFinalizesFivefoldRepetition(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+
+	// Both knights out and back four times, each player's moves in their own
+	// repository; white's next Nf3 brings its position up a fifth time
+	ucis := []string{"g1f3", "g8f6", "f3g1", "f6g8"}
+	engine := chess.NewEngine()
+	gameID := seedGame(pds, startFEN, "active")
+	for ply := 1; ply <= 16; ply++ {
+		move, err := engine.MakeMoveUCI(ucis[(ply-1)%len(ucis)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		player := testWhiteDID
+		if ply%2 == 0 {
+			player = testBlackDID
+		}
+		seedMove(pds, gameID, player, fmt.Sprintf("m%02d", ply), move.From, move.To, move.SAN, move.FEN)
+	}
+	pds.get(gameID)["fen"] = engine.GetFEN()
+	service := newServiceForPDS(t, pds)
+
+	w := postMove(service, map[string]interface{}{"move": "Nf3", "game_id": gameID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the move to be recorded, got %d: %s", w.Code, w.Body.String())
+	}
+	var result chess.MoveResult
+	_ = json.Unmarshal(w.Body.Bytes(), &result)
+	if !result.GameOver || result.Status != chess.StatusDraw {
+		t.Errorf("Expected the response to report the draw, got %+v", result)
+	}
+
+	record := pds.get(gameID)
+	if record["status"] != "draw" || record["result"] != "1/2-1/2" {
+		t.Errorf("Expected the game to be drawn with result 1/2-1/2, got status %v result %v", record["status"], record["result"])
+	}
+}
+
+func TestMakeMoveRecordsResultOfCheckmate(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	// 1. e4 f6 2. d4 g5, white to mate with Qh5#
+	gameID := seedGame(pds, "rnbqkbnr/ppppp2p/5p2/6p1/3PP3/8/PPP2PPP/RNBQKBNR w KQkq g6 0 3", "active")
+	service := newServiceForPDS(t, pds)
+
+	w := postMove(service, map[string]interface{}{"move": "Qh5#", "game_id": gameID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the move to be recorded, got %d: %s", w.Code, w.Body.String())
+	}
+	record := pds.get(gameID)
+	if record["status"] != "white_won" || record["result"] != "1-0" {
+		t.Errorf("Expected white to win 1-0, got status %v result %v", record["status"], record["result"])
+	}
+}
+
+// foolsMateAfterG4 is the position before black's Qh4#
+const foolsMateAfterG4 = "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq g3 0 2"
+
+// seedFoolsMateUpToMate seeds a game in white's repository up to black's mate, each
+// player's moves in their own repository
+func seedFoolsMateUpToMate(pds *fakePDS) string {
+	gameID := seedGame(pds, foolsMateAfterG4, "active")
+	seedMove(pds, gameID, testWhiteDID, "m1", "f2", "f3", "f3", "rnbqkbnr/pppppppp/8/8/8/5P2/PPPPP1PP/RNBQKBNR b KQkq - 0 1")
+	seedMove(pds, gameID, testBlackDID, "m2", "e7", "e5", "e5", "rnbqkbnr/pppp1ppp/8/4p3/8/5P2/PPPPP1PP/RNBQKBNR w KQkq e6 0 2")
+	seedMove(pds, gameID, testWhiteDID, "m3", "g2", "g4", "g4", foolsMateAfterG4)
+	return gameID
+}
+
+// expectFinalized checks white's record was settled by black's mate
+func expectFinalized(t *testing.T, pds *fakePDS, gameID string) {
+	t.Helper()
+	record := pds.get(gameID)
+	if record["status"] != "black_won" || record["result"] != "0-1" {
+		t.Errorf("Expected the record to be finalized 0-1, got status %v result %v", record["status"], record["result"])
+	}
+	if record["fen"] != "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3" {
+		t.Errorf("Expected the record to have the final position, got %v", record["fen"])
+	}
+}
+
+func TestFinalizeMovedGameSettlesOpponentsGameEndingMove(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedFoolsMateUpToMate(pds)
+	// Black's mate is only in black's repository
+	seedMove(pds, gameID, testBlackDID, "m4", "d8", "h4", "Qh4#", "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3")
+	service := newServiceForPDS(t, pds)
+
+	// Reading the game doesn't write to it
+	if w := getGame(service, gameID); w.Code != http.StatusOK {
+		t.Fatalf("Expected the game, got %d: %s", w.Code, w.Body.String())
+	}
+	if status := pds.get(gameID)["status"]; status != "active" {
+		t.Fatalf("Expected the game to be served as recorded, got %v", status)
+	}
+
+	// White's own moves are settled as they're recorded
+	service.FinalizeMovedGame(context.Background(), gameID, testWhiteDID)
+	if status := pds.get(gameID)["status"]; status != "active" {
+		t.Fatalf("Expected the owner's moves to be left alone, got %v", status)
+	}
+
+	service.FinalizeMovedGame(context.Background(), gameID, testBlackDID)
+	expectFinalized(t, pds, gameID)
+
+	w := getGame(service, gameID)
+	var game chess.Game
+	_ = json.Unmarshal(w.Body.Bytes(), &game)
+	if game.Status != chess.StatusBlackWon {
+		t.Errorf("Expected black to have won, got %s", game.Status)
+	}
+}
+
+func TestMakeMoveFinalizesOpponentsRecord(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	black, err := atproto.NewClient(pds.URL, "black", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	pds.did = testWhiteDID
+	gameID := seedFoolsMateUpToMate(pds)
+	service := newServiceForPDS(t, pds)
+
+	// White is signed in here through the service's client
+	req, _ := json.Marshal(map[string]interface{}{"move": "Qh4#", "fen": foolsMateAfterG4, "game_id": gameID})
+	w := httptest.NewRecorder()
+	service.MakeMoveHandler(w, asPlayer(httptest.NewRequest("POST", "/api/moves", bytes.NewReader(req)), black))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the move to be recorded, got %d: %s", w.Code, w.Body.String())
+	}
+	expectFinalized(t, pds, gameID)
+}
+
+func TestGetGameLeavesUnfinishedGameAlone(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	seedMove(pds, gameID, testWhiteDID, "m1", "e2", "e4", "e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1")
+	service := newServiceForPDS(t, pds)
+
+	if w := getGame(service, gameID); w.Code != http.StatusOK {
+		t.Fatalf("Expected the game, got %d: %s", w.Code, w.Body.String())
+	}
+	record := pds.get(gameID)
+	if record["status"] != "active" || record["result"] != nil {
+		t.Errorf("Expected the game to stay active, got status %v result %v", record["status"], record["result"])
+	}
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/rs/zerolog/log"
+)
+
+// HeadToHeadGame is a game between two rivals, with links to view and
+// download it
+type HeadToHeadGame struct {
+	GameIndex
+	Result string `json:"result,omitempty"` // "1-0", "0-1" or "1/2-1/2" once finished
+	Winner string `json:"winner,omitempty"` // DID of the winner of a decisive game
+	URL    string `json:"url,omitempty"`    // the game's page
+	PGN    string `json:"pgn"`              // the game as PGN
+}
+
+// HeadToHeadSummary is two players' record against each other, from the
+// first player's side
+type HeadToHeadSummary struct {
+	Games  int `json:"games"`
+	Active int `json:"active"`
+	Wins   int `json:"wins"`
+	Losses int `json:"losses"`
+	Draws  int `json:"draws"`
+}
+
+// HeadToHead lists every indexed game between two players, most recent first,
+// and summarises the results from player's side
+func (s *Service) HeadToHead(ctx context.Context, player, opponent string) ([]HeadToHeadGame, HeadToHeadSummary, error) {
+	games := []HeadToHeadGame{}
+	var summary HeadToHeadSummary
+
+	query := index.Query{Player: player, Opponent: opponent, Limit: index.MaxLimit}
+	for {
+		page, err := s.gameIndex.ListGames(ctx, query)
+		if err != nil {
+			return nil, summary, err
+		}
+		for _, game := range page.Games {
+			entry := headToHeadGame(game)
+			summary.Games++
+			switch {
+			case game.Status == string(chess.StatusActive):
+				summary.Active++
+			case entry.Winner == player:
+				summary.Wins++
+			case entry.Winner == opponent:
+				summary.Losses++
+			case game.Status == string(chess.StatusDraw):
+				summary.Draws++
+			}
+			games = append(games, entry)
+		}
+		if page.Cursor == "" {
+			return games, summary, nil
+		}
+		query.Cursor = page.Cursor
+	}
+}
+
+// headToHeadGame adds the result and links to an indexed game
+func headToHeadGame(game *index.Game) HeadToHeadGame {
+	entry := HeadToHeadGame{
+		GameIndex: indexedGame(game),
+		PGN:       "/api/v1/games/" + base64.URLEncoding.EncodeToString([]byte(game.URI)) + "/pgn",
+	}
+	if uri, err := ParseRecordURI(game.URI); err == nil {
+		entry.URL, _ = AppPath(uri)
+	}
+	entry.Winner = indexedWinner(game)
+	if result := chess.ResultForStatus(chess.GameStatus(game.Status)); result != "*" {
+		entry.Result = result
+	}
+	return entry
+}
+
+// indexedWinner returns the DID of the winner of an indexed game, or "" if
+// it isn't decided
+func indexedWinner(game *index.Game) string {
+	switch chess.GameStatus(game.Status) {
+	case chess.StatusWhiteWon:
+		return game.White
+	case chess.StatusBlackWon:
+		return game.Black
+	}
+	return ""
+}
+
+// HeadToHeadHandler returns the games between two players, named by DID or
+// handle, and the first player's record against the second
+func (s *Service) HeadToHeadHandler(w http.ResponseWriter, r *http.Request) {
+	if s.gameIndex == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Head-to-head history needs the game index, which is built from the firehose"))
+		return
+	}
+
+	vars := mux.Vars(r)
+	player, ok := s.resolvePlayer(w, r, vars["didA"])
+	if !ok {
+		return
+	}
+	opponent, ok := s.resolvePlayer(w, r, vars["didB"])
+	if !ok {
+		return
+	}
+	if player == opponent {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Choose two different players"))
+		return
+	}
+
+	games, summary, err := s.HeadToHead(r.Context(), player, opponent)
+	if err != nil {
+		log.Error().Err(err).Str("player", player).Str("opponent", opponent).Msg("Failed to list head-to-head games")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list games"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"player":   player,
+		"opponent": opponent,
+		"summary":  summary,
+		"games":    games,
+	})
+}
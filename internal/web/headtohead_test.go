@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+func TestHeadToHeadSummarisesGamesBetweenTwoPlayers(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, "did:plc:service"))
+	request := func(a, b string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/api/players/"+a+"/vs/"+b, nil), map[string]string{"didA": a, "didB": b})
+		w := httptest.NewRecorder()
+		service.HeadToHeadHandler(w, req)
+		return w
+	}
+	if w := request(testWhiteDID, testBlackDID); w.Code != http.StatusNotFound {
+		t.Errorf("Expected head-to-head to be disabled without the index, got %d", w.Code)
+	}
+
+	ctx := context.Background()
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	service.SetGameIndex(indexer)
+	for _, game := range []struct{ rkey, white, black, status string }{
+		{"g1", testWhiteDID, testBlackDID, "white_won"},
+		{"g2", testBlackDID, testWhiteDID, "white_won"},
+		{"g3", testBlackDID, testWhiteDID, "black_won"},
+		{"g4", testWhiteDID, testBlackDID, "draw"},
+		{"g5", testWhiteDID, testBlackDID, "active"},
+		{"other", testWhiteDID, "did:plc:someone", "white_won"},
+	} {
+		_ = indexer.Apply(ctx, "create", game.white, "app.atchess.game/"+game.rkey, map[string]interface{}{
+			"white":     game.white,
+			"black":     game.black,
+			"status":    game.status,
+			"fen":       startFEN,
+			"createdAt": "2024-01-01T00:00:00Z",
+		})
+	}
+
+	w := request(testWhiteDID, testBlackDID)
+	var resp struct {
+		Summary HeadToHeadSummary `json:"summary"`
+		Games   []HeadToHeadGame  `json:"games"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.Summary != (HeadToHeadSummary{Games: 5, Active: 1, Wins: 2, Losses: 1, Draws: 1}) {
+		t.Errorf("Expected 2 wins, 1 loss, 1 draw and a game in progress, got %+v", resp.Summary)
+	}
+	for _, game := range resp.Games {
+		if game.URL == "" || game.PGN == "" {
+			t.Errorf("Expected links for every game, got %+v", game)
+		}
+		if game.Status == "active" && game.Result != "" {
+			t.Errorf("Expected no result for a game in progress, got %q", game.Result)
+		}
+	}
+
+	if w := request(testWhiteDID, testWhiteDID); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a player against themselves, got %d", w.Code)
+	}
+}
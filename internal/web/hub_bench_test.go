@@ -0,0 +1,77 @@
+package web
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// hubBenchBatch is how many updates are broadcast before waiting for every
+// client to catch up, keeping send buffers from overflowing
+const hubBenchBatch = 128
+
+// BenchmarkHubBroadcast measures fanning move updates out to everyone
+// watching a game, including marshalling and cue computation
+func BenchmarkHubBroadcast(b *testing.B) {
+	// Connection logging would swamp the results
+	defer func(logger zerolog.Logger) { log.Logger = logger }(log.Logger)
+	log.Logger = zerolog.Nop()
+
+	for _, clients := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("clients=%d", clients), func(b *testing.B) {
+			benchmarkHubBroadcast(b, clients)
+		})
+	}
+}
+
+func benchmarkHubBroadcast(b *testing.B, n int) {
+	hub := NewHub()
+	go hub.Run()
+
+	var delivered int64
+	gameID := "at://did:plc:white/app.atchess.game/bench"
+	for i := 0; i < n; i++ {
+		client := &Client{hub: hub, send: make(chan []byte, 256), gameID: gameID, userID: "anonymous", channel: GameChannel}
+		hub.register <- client
+		go func() {
+			for range client.send {
+				atomic.AddInt64(&delivered, 1)
+			}
+		}()
+	}
+	for hub.SubscriberCount(gameID, GameChannel) < n {
+		runtime.Gosched()
+	}
+
+	update := GameUpdate{
+		GameID: gameID,
+		Type:   "move",
+		Data:   &chess.MoveResult{From: "g1", To: "f3", SAN: "Nf3+", FEN: chess.StartingFEN, Check: true},
+	}
+	waitFor := func(broadcasts int) {
+		for atomic.LoadInt64(&delivered) < int64(broadcasts*n) {
+			if hub.SubscriberCount(gameID, GameChannel) < n {
+				b.Fatal("A client was dropped for falling behind")
+			}
+			runtime.Gosched()
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 1; i <= b.N; i++ {
+		hub.BroadcastToGame(gameID, update)
+		if i%hubBenchBatch == 0 {
+			waitFor(i)
+		}
+	}
+	waitFor(b.N)
+	b.StopTimer()
+
+	b.ReportMetric(float64(b.N*n)/b.Elapsed().Seconds(), "deliveries/s")
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/importer"
+	"github.com/rs/zerolog/log"
+)
+
+// SetImporter enables importing games from Lichess and Chess.com
+func (s *Service) SetImporter(fetcher *importer.Fetcher) {
+	s.importer = fetcher
+}
+
+// ImportGamesRequest names the games to import: one game by URL, or a
+// player's recent games on a site by username
+type ImportGamesRequest struct {
+	Site     string `json:"site"`     // "lichess" or "chess.com", for a username
+	Username string `json:"username"` // the caller's name on the site
+	URL      string `json:"url"`      // a single game
+	Color    string `json:"color"`    // the caller's side in a game given by URL, if username isn't given
+	Max      int    `json:"max"`      // how many recent games, at most importer.MaxGames
+}
+
+// ImportedGame is a game brought into the caller's repository
+type ImportedGame struct {
+	URI  string `json:"uri"`
+	Site string `json:"site"`
+	ID   string `json:"id"`
+	URL  string `json:"url"`
+}
+
+// SkippedGame is a game that wasn't imported, and why
+type SkippedGame struct {
+	Number int    `json:"number,omitempty"` // position in an uploaded PGN file
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// ImportGamesResponse lists the games imported and skipped
+type ImportGamesResponse struct {
+	Imported []ImportedGame `json:"imported"`
+	Skipped  []SkippedGame  `json:"skipped"`
+}
+
+// ImportGamesHandler fetches games from Lichess or Chess.com and records
+// them, with their moves, in the caller's repository as imported games.
+// Games imported before are skipped.
+func (s *Service) ImportGamesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.importer == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Importing games is not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	var req ImportGamesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+
+	var games []*importer.Game
+	var err error
+	switch {
+	case req.URL != "":
+		if req.Color != "" && req.Color != "white" && req.Color != "black" {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("color must be white or black"))
+			return
+		}
+		if req.Color == "" && req.Username == "" {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("username or color is required to know which side is yours"))
+			return
+		}
+		var game *importer.Game
+		if game, err = s.importer.FetchGame(r.Context(), req.URL, req.Username); err == nil {
+			games = append(games, game)
+		}
+	case req.Username != "":
+		if req.Site != importer.SiteLichess && req.Site != importer.SiteChessCom {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("site must be lichess or chess.com"))
+			return
+		}
+		games, err = s.importer.FetchPlayerGames(r.Context(), req.Site, req.Username, req.Max)
+	default:
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("url or username is required"))
+		return
+	}
+	switch {
+	case errors.Is(err, importer.ErrUnsupportedURL):
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+		return
+	case errors.Is(err, importer.ErrNotFound):
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Game or player not found"))
+		return
+	case err != nil:
+		log.Error().Err(err).Str("did", did).Msg("Failed to fetch games to import")
+		apierror.Write(w, apierror.ErrUnavailable.WithMessage("Failed to fetch games"))
+		return
+	}
+
+	client := s.clientFor(r)
+	existing, err := client.ImportedGames(r.Context(), did)
+	if err != nil {
+		log.Error().Err(err).Str("did", did).Msg("Failed to list imported games")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list imported games"))
+		return
+	}
+
+	resp := ImportGamesResponse{Imported: []ImportedGame{}, Skipped: []SkippedGame{}}
+	skip := func(game *importer.Game, reason string) {
+		resp.Skipped = append(resp.Skipped, SkippedGame{ID: game.ID, URL: game.URL, Reason: reason})
+	}
+	for _, game := range games {
+		if _, ok := existing[game.Site+":"+game.ID]; ok {
+			skip(game, "already imported")
+			continue
+		}
+		color := game.Side(req.Username)
+		if req.URL != "" && req.Color != "" {
+			color = req.Color
+		}
+		if color == "" {
+			skip(game, req.Username+" did not play in this game")
+			continue
+		}
+
+		converted, moves, err := game.Convert(did, color)
+		if err != nil {
+			skip(game, err.Error())
+			continue
+		}
+		imported, err := client.ImportGame(r.Context(), converted, moves)
+		if err != nil {
+			log.Error().Err(err).Str("did", did).Str("game", game.URL).Msg("Failed to import game")
+			skip(game, "failed to record the game")
+			continue
+		}
+		existing[game.Site+":"+game.ID] = imported.ID
+		resp.Imported = append(resp.Imported, ImportedGame{URI: imported.ID, Site: game.Site, ID: game.ID, URL: game.URL})
+	}
+
+	log.Info().Str("did", did).Int("imported", len(resp.Imported)).Int("skipped", len(resp.Skipped)).Msg("Imported games")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/importer"
+)
+
+func TestImportGamesRecordsGamesOnce(t *testing.T) {
+	lichess := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/games/user/alice" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`[Site "https://lichess.org/abcd1234"]
+[Date "2024.03.05"]
+[White "bob"]
+[Black "alice"]
+[Result "0-1"]
+
+1. f3 e5 2. g4 Qh4# 0-1
+
+[Site "https://lichess.org/efgh5678"]
+[White "alice"]
+[Black "carol"]
+[Result "*"]
+
+1. e4 *
+`))
+	}))
+	defer lichess.Close()
+
+	pds := newFakePDS(t, "did:plc:service")
+	service := newServiceForPDS(t, pds)
+	service.SetImporter(importer.NewFetcher(lichess.URL, lichess.URL))
+	pds.did = testBlackDID
+	client, err := atproto.NewClient(pds.URL, "alice", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	token, _ := service.Sessions().Create(client)
+
+	request := map[string]interface{}{"site": "lichess", "username": "alice"}
+	w := serveAs(service.ImportGamesHandler, service, token, "/api/import", request)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the import to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp ImportGamesResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Imported) != 1 || len(resp.Skipped) != 1 || resp.Skipped[0].ID != "efgh5678" {
+		t.Fatalf("Expected the finished game imported and the other skipped, got %s", w.Body.String())
+	}
+
+	game := pds.get(resp.Imported[0].URI)
+	if game["imported"] != true || game["white"] != "did:web:lichess.org" || game["black"] != testBlackDID || game["status"] != "black_won" {
+		t.Errorf("Expected an imported win for black, got %v", game)
+	}
+	moves := pds.collection(testBlackDID, "app.atchess.move")
+	if len(moves) != 4 {
+		t.Fatalf("Expected all four moves in the importer's repository, got %d", len(moves))
+	}
+	if first := pds.get(moves[0]); first["player"] != "did:web:lichess.org" || first["san"] != "f3" {
+		t.Errorf("Expected white's first move by the site's DID, got %v", first)
+	}
+
+	w = serveAs(service.ImportGamesHandler, service, token, "/api/import", request)
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Imported) != 0 || resp.Skipped[0].Reason != "already imported" {
+		t.Errorf("Expected the game not imported twice, got %s", w.Body.String())
+	}
+
+	w = serveAs(service.ImportGamesHandler, service, token, "/api/import", map[string]interface{}{"url": "https://example.com/game/1", "color": "white"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected other sites to be refused, got %d", w.Code)
+	}
+}
@@ -0,0 +1,171 @@
+package web
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// GameFetcher loads the current state of a game
+type GameFetcher func(ctx context.Context, gameID string) (*chess.Game, error)
+
+// Kibitzer runs a shallow engine evaluation of spectated games and streams
+// the results on the kibitz channel while anyone is subscribed
+type Kibitzer struct {
+	hub      *Hub
+	fetch    GameFetcher
+	depth    int
+	interval time.Duration
+
+	mu      sync.Mutex
+	running map[string]bool
+	// latest is each watched game's most recent evaluation, so spectators loading
+	// the game get it without waiting for the next frame
+	latest map[string]kibitzResult
+}
+
+// kibitzResult is an evaluation and the game FEN it was made from
+type kibitzResult struct {
+	fen  string
+	eval *chess.Evaluation
+}
+
+// NewKibitzer creates a kibitzer that re-checks positions every interval
+func NewKibitzer(hub *Hub, fetch GameFetcher, depth int, interval time.Duration) *Kibitzer {
+	if depth < 1 {
+		depth = 1
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &Kibitzer{
+		hub:      hub,
+		fetch:    fetch,
+		depth:    depth,
+		interval: interval,
+		running:  make(map[string]bool),
+		latest:   make(map[string]kibitzResult),
+	}
+}
+
+// Watch starts analysing a game unless it is already being analysed
+func (k *Kibitzer) Watch(gameID string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.running[gameID] {
+		return
+	}
+	k.running[gameID] = true
+	go k.run(gameID)
+}
+
+func (k *Kibitzer) run(gameID string) {
+	defer func() {
+		k.mu.Lock()
+		delete(k.running, gameID)
+		delete(k.latest, gameID)
+		k.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+
+	var lastFEN string
+	for {
+		active := k.analyse(gameID, &lastFEN)
+		if !active {
+			return
+		}
+
+		<-ticker.C
+		if k.hub.SubscriberCount(gameID, KibitzChannel) == 0 {
+			log.Debug().Str("gameID", gameID).Msg("No kibitz subscribers left, stopping analysis")
+			return
+		}
+	}
+}
+
+// analyse evaluates the game's position if it changed since the last run and
+// reports whether the game is still in progress
+func (k *Kibitzer) analyse(gameID string, lastFEN *string) bool {
+	game, err := k.fetch(context.Background(), gameID)
+	if err != nil {
+		log.Warn().Err(err).Str("gameID", gameID).Msg("Failed to fetch game for kibitz analysis")
+		return true
+	}
+
+	if game.FEN != *lastFEN {
+		*lastFEN = game.FEN
+
+		eval, err := k.Evaluation(gameID, game.Variant, game.FEN)
+		if err != nil {
+			log.Error().Err(err).Str("gameID", gameID).Msg("Kibitz evaluation failed")
+			return false
+		}
+
+		// Every replica with kibitz subscribers runs its own analysis, so
+		// the frames aren't shared through the broker
+		k.hub.deliver(GameUpdate{
+			GameID: gameID,
+			Type:   "kibitz",
+			Data:   eval,
+		})
+	}
+
+	return game.Status == chess.StatusActive
+}
+
+// Evaluation returns the evaluation of a game's position under the rules of
+// its variant, searching it unless it's the position last evaluated for that
+// game
+func (k *Kibitzer) Evaluation(gameID, variant, fen string) (*chess.Evaluation, error) {
+	k.mu.Lock()
+	latest, ok := k.latest[gameID]
+	k.mu.Unlock()
+	if ok && latest.fen == fen {
+		return latest.eval, nil
+	}
+
+	engine, err := chess.NewVariantEngine(variant, fen)
+	if err != nil {
+		return nil, err
+	}
+	eval, err := engine.Evaluate(k.depth)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only games being watched are remembered, and forgotten when it stops
+	k.mu.Lock()
+	if k.running[gameID] {
+		k.latest[gameID] = kibitzResult{fen: fen, eval: eval}
+	}
+	k.mu.Unlock()
+	return eval, nil
+}
+
+// SetKibitzer enables the spectator-only kibitz channel
+func (s *Service) SetKibitzer(kibitzer *Kibitzer) {
+	s.kibitzer = kibitzer
+}
+
+// isActivePlayer reports whether did is playing the game and it is still in
+// progress. If the game can't be loaded they're taken to be, so analysis isn't
+// shown to a player just because their PDS was unreachable.
+func (s *Service) isActivePlayer(gameID, did string) bool {
+	if did == "" || did == "anonymous" {
+		return false
+	}
+
+	game, err := s.client.GetGame(context.Background(), gameID)
+	if err != nil {
+		log.Warn().Err(err).Str("gameID", gameID).Msg("Failed to load game to check its players")
+		return true
+	}
+
+	return game.Status == chess.StatusActive && (game.White == did || game.Black == did)
+}
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+func newKibitzService(t *testing.T) (*Service, *Hub, string) {
+	t.Helper()
+	pds := newFakePDS(t, "did:plc:service")
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	hub := NewHub()
+	go hub.Run()
+	service.SetKibitzer(NewKibitzer(hub, service.client.GetGame, 1, time.Hour))
+	return service, hub, gameID
+}
+
+// kibitzSession signs did in to service
+func kibitzSession(t *testing.T, service *Service, did string) string {
+	t.Helper()
+	pds := newFakePDS(t, did)
+	client, err := atproto.NewClient(pds.URL, "user", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client for %s: %v", did, err)
+	}
+	token, _ := service.Sessions().Create(client)
+	return token
+}
+
+func TestKibitzChannelRejectsPlayers(t *testing.T) {
+	service, hub, gameID := newKibitzService(t)
+	token := kibitzSession(t, service, testWhiteDID)
+
+	req := httptest.NewRequest("GET", "/api/ws?channel=kibitz&gameId="+url.QueryEscape(gameID)+"&session="+token, nil)
+	w := httptest.NewRecorder()
+	service.WebSocketHandler(hub)(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for player subscribing to kibitz, got %d", w.Code)
+	}
+}
+
+func TestKibitzChannelRejectsAnonymousUsers(t *testing.T) {
+	service, hub, gameID := newKibitzService(t)
+
+	req := httptest.NewRequest("GET", "/api/ws?channel=kibitz&gameId="+url.QueryEscape(gameID), nil)
+	w := httptest.NewRecorder()
+	service.WebSocketHandler(hub)(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an anonymous kibitz subscription, got %d", w.Code)
+	}
+}
+
+func TestKibitzChannelDisabled(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	req := httptest.NewRequest("GET", "/api/ws?channel=kibitz&gameId=game", nil)
+	w := httptest.NewRecorder()
+	service.WebSocketHandler(NewHub())(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when kibitz is disabled, got %d", w.Code)
+	}
+}
+
+func TestKibitzChannelStreamsEvaluationToSpectators(t *testing.T) {
+	service, hub, gameID := newKibitzService(t)
+	token := kibitzSession(t, service, "did:plc:spectator")
+
+	server := httptest.NewServer(service.WebSocketHandler(hub))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/?channel=kibitz&gameId=" + url.QueryEscape(gameID) + "&session=" + token
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect spectator: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Expected kibitz frame: %v", err)
+	}
+
+	var frame struct {
+		GameID string `json:"gameId"`
+		Type   string `json:"type"`
+		Data   struct {
+			FEN      string `json:"fen"`
+			BestMove string `json:"bestMove"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(message, &frame); err != nil {
+		t.Fatalf("Failed to parse frame: %v", err)
+	}
+	if frame.Type != "kibitz" || frame.GameID != gameID {
+		t.Errorf("Expected kibitz frame for %s, got %s for %s", gameID, frame.Type, frame.GameID)
+	}
+	if frame.Data.FEN != startFEN || frame.Data.BestMove == "" {
+		t.Errorf("Expected evaluation of the current position, got %+v", frame.Data)
+	}
+}
+
+func TestKibitzUpdatesAreRoutedSeparately(t *testing.T) {
+	if room := updateRoom(GameUpdate{GameID: "g", Type: "kibitz"}); room != roomKey("g", KibitzChannel) {
+		t.Errorf("Expected kibitz updates in the kibitz room, got %s", room)
+	}
+	if room := updateRoom(GameUpdate{GameID: "g", Type: "move"}); room != "g" {
+		t.Errorf("Expected move updates in the game room, got %s", room)
+	}
+}
+
+func TestKibitzFramesStayOnTheirReplica(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker := &memoryBroker{}
+
+	local := connectedHub(t, ctx, broker, "game-1", "did:plc:spectator", KibitzChannel)
+	remote := connectedHub(t, ctx, broker, "game-1", "did:plc:spectator", KibitzChannel)
+	for broker.subscriberCount() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Each replica analyses the games its own spectators watch
+	fetch := func(ctx context.Context, gameID string) (*chess.Game, error) {
+		return &chess.Game{ID: gameID, FEN: startFEN, Status: chess.StatusActive}, nil
+	}
+	kibitzer := NewKibitzer(local.hub, fetch, 1, time.Hour)
+	var lastFEN string
+	kibitzer.analyse("game-1", &lastFEN)
+	expectFrame(t, local, `"type":"kibitz"`)
+	expectNoFrame(t, remote)
+}
+
+func TestKibitzEvaluatesUnderTheGamesVariant(t *testing.T) {
+	kibitzer := NewKibitzer(NewHub(), nil, 1, time.Hour)
+	if _, err := kibitzer.Evaluation("game-1", "atomic", startFEN); !errors.Is(err, chess.ErrUnknownVariant) {
+		t.Errorf("Expected a variant without rules here not to be evaluated as standard chess, got %v", err)
+	}
+	if eval, err := kibitzer.Evaluation("game-1", "", startFEN); err != nil || eval.BestMove == "" {
+		t.Errorf("Expected games without a variant to be evaluated as standard chess, got %+v %v", eval, err)
+	}
+}
+
+func TestKibitzChannelFailsClosedWhenTheGameCantBeLoaded(t *testing.T) {
+	service, hub, _ := newKibitzService(t)
+	token := kibitzSession(t, service, "did:plc:spectator")
+
+	missing := "at://did:plc:service/app.atchess.game/missing"
+	req := httptest.NewRequest("GET", "/api/ws?channel=kibitz&gameId="+url.QueryEscape(missing)+"&session="+token, nil)
+	w := httptest.NewRecorder()
+	service.WebSocketHandler(hub)(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 when the game's players can't be checked, got %d", w.Code)
+	}
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// LegalMovesRequest asks for the legal moves in a position
+type LegalMovesRequest struct {
+	FEN     string `json:"fen"`
+	Variant string `json:"variant,omitempty"` // defaults to standard chess
+}
+
+// LegalMovesResponse lists the moves the side to move can play, so clients
+// can highlight destinations without implementing the rules themselves
+type LegalMovesResponse struct {
+	GameID string            `json:"gameId,omitempty"`
+	FEN    string            `json:"fen"`
+	Turn   string            `json:"turn"` // "white" or "black"
+	Moves  []chess.LegalMove `json:"moves"`
+}
+
+// GameLegalMovesHandler returns the legal moves in a game's current
+// position. A game that's over has none.
+func (s *Service) GameLegalMovesHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	game, err := s.readerFor(r).GetGame(context.Background(), gameID)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to fetch game for legal moves")
+		apierror.Write(w, apierror.ErrGameNotFound)
+		return
+	}
+
+	engine, err := chess.NewVariantEngine(game.Variant, game.FEN)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Str("fen", game.FEN).Msg("Stored game has invalid FEN")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Game has an invalid position"))
+		return
+	}
+
+	resp := LegalMovesResponse{
+		GameID: gameID,
+		FEN:    game.FEN,
+		Turn:   engine.GetActiveColor(),
+		Moves:  []chess.LegalMove{},
+	}
+	// Resigned, drawn and abandoned games can end in a position with moves left
+	if game.Status == chess.StatusActive {
+		resp.Moves = engine.LegalMoves()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// LegalMovesHandler returns the legal moves in any position, given as FEN,
+// for analysis boards and positions outside a game
+func (s *Service) LegalMovesHandler(w http.ResponseWriter, r *http.Request) {
+	var req LegalMovesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+
+	if _, err := chess.LookupVariant(req.Variant); err != nil {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+		return
+	}
+
+	engine, err := chess.NewVariantEngine(req.Variant, req.FEN)
+	if req.FEN == "" || err != nil {
+		apierror.Write(w, apierror.ErrInvalidFEN)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(LegalMovesResponse{
+		FEN:   engine.GetFEN(),
+		Turn:  engine.GetActiveColor(),
+		Moves: engine.LegalMoves(),
+	})
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+func legalMovesRequest(s *Service, gameID string) *httptest.ResponseRecorder {
+	encoded := base64.URLEncoding.EncodeToString([]byte(gameID))
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/games/"+encoded+"/legal-moves", nil), map[string]string{"id": encoded})
+	w := httptest.NewRecorder()
+	s.GameLegalMovesHandler(w, req)
+	return w
+}
+
+func TestGameLegalMoves(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", "active")
+	service := newServiceForPDS(t, pds)
+
+	w := legalMovesRequest(service, gameID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected legal moves, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp LegalMovesResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.GameID != gameID || resp.Turn != "black" || len(resp.Moves) != 20 {
+		t.Errorf("Expected black's 20 replies to 1. e4, got %+v", resp)
+	}
+}
+
+func TestGameLegalMovesForFinishedGame(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, chess.StartingFEN, "draw")
+	service := newServiceForPDS(t, pds)
+
+	w := legalMovesRequest(service, gameID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected a response, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"moves":[]`) {
+		t.Errorf("Expected no moves in a finished game, got %s", w.Body.String())
+	}
+}
+
+func TestGameLegalMovesUnknownGame(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	w := legalMovesRequest(service, "at://"+testWhiteDID+"/app.atchess.game/missing")
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "game_not_found" {
+		t.Errorf("Expected game_not_found, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLegalMovesForPosition(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, testWhiteDID))
+
+	req := httptest.NewRequest("POST", "/api/legal-moves", strings.NewReader(`{"fen":"8/P7/8/8/8/8/8/k6K w - - 0 1"}`))
+	w := httptest.NewRecorder()
+	service.LegalMovesHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected legal moves, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp LegalMovesResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	promotions := 0
+	for _, move := range resp.Moves {
+		if move.From == "a7" && move.Promotion != "" {
+			promotions++
+		}
+	}
+	if resp.Turn != "white" || promotions != 4 {
+		t.Errorf("Expected white's four promotions, got %+v", resp)
+	}
+
+	for _, body := range []string{`{"fen":"not a position"}`, `{}`} {
+		req := httptest.NewRequest("POST", "/api/legal-moves", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		service.LegalMovesHandler(w, req)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != "invalid_fen" {
+			t.Errorf("Expected invalid_fen for %s, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/rating"
+	"github.com/rs/zerolog/log"
+)
+
+// Matchmaking defaults, used when the configuration leaves them unset
+const (
+	DefaultRatingBand   = 200
+	DefaultWidenAfter   = 30 * time.Second
+	DefaultPairInterval = 2 * time.Second
+)
+
+// JoinMatchmakingRequest enters the matchmaking queue for a time control
+type JoinMatchmakingRequest struct {
+	TimeControl *chess.TimeControl `json:"timeControl"`
+}
+
+// MatchmakingEntry is a player waiting in the matchmaking queue
+type MatchmakingEntry struct {
+	PlayerDID   string             `json:"playerDid"`
+	TimeControl *chess.TimeControl `json:"timeControl"`
+	Rating      int                `json:"rating"`
+	Queue       string             `json:"queue"` // time control and rating band, e.g. "blitz 300+2 / 1400-1599"
+	JoinedAt    time.Time          `json:"joinedAt"`
+
+	band   int
+	client *atproto.Client // the player's session, to create the game with
+}
+
+// MatchFound is the payload of the "matched" frame sent to both players
+type MatchFound struct {
+	Game     *chess.Game `json:"game"`
+	Opponent string      `json:"opponent"`
+	Color    string      `json:"color"`
+}
+
+// Matchmaker queues players by time control and rating band and pairs
+// compatible ones. The longer a player waits, the wider the range of bands
+// they can be paired with.
+type Matchmaker struct {
+	bandWidth  int
+	widenAfter time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	waiting map[string]*MatchmakingEntry // by player DID
+}
+
+// NewMatchmaker creates an empty matchmaking queue
+func NewMatchmaker(bandWidth int, widenAfter time.Duration) *Matchmaker {
+	if bandWidth <= 0 {
+		bandWidth = DefaultRatingBand
+	}
+	if widenAfter <= 0 {
+		widenAfter = DefaultWidenAfter
+	}
+	return &Matchmaker{
+		bandWidth:  bandWidth,
+		widenAfter: widenAfter,
+		now:        time.Now,
+		waiting:    make(map[string]*MatchmakingEntry),
+	}
+}
+
+// timeControlKey names a time control; only players asking for the same one
+// are paired
+func timeControlKey(tc *chess.TimeControl) string {
+	if tc.Type == "correspondence" {
+		return fmt.Sprintf("correspondence %dd", tc.DaysPerMove)
+	}
+	return fmt.Sprintf("%s %d+%d", tc.Type, tc.Initial, tc.Increment)
+}
+
+// Join puts a player in the queue, replacing any place they already had
+func (m *Matchmaker) Join(client *atproto.Client, timeControl *chess.TimeControl, playerRating int) *MatchmakingEntry {
+	band := playerRating / m.bandWidth
+	entry := &MatchmakingEntry{
+		PlayerDID:   client.GetDID(),
+		TimeControl: timeControl,
+		Rating:      playerRating,
+		Queue:       fmt.Sprintf("%s / %d-%d", timeControlKey(timeControl), band*m.bandWidth, (band+1)*m.bandWidth-1),
+		JoinedAt:    m.now(),
+		band:        band,
+		client:      client,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.waiting[entry.PlayerDID] = entry
+	return entry
+}
+
+// Leave takes a player out of the queue, reporting whether they were in it
+func (m *Matchmaker) Leave(playerDID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.waiting[playerDID]
+	delete(m.waiting, playerDID)
+	return ok
+}
+
+// Waiting returns a player's place in the queue, or nil
+func (m *Matchmaker) Waiting(playerDID string) *MatchmakingEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.waiting[playerDID]
+}
+
+// compatible reports whether two queued players may be paired now. Players in
+// the same band always can; each widenAfter the longer-waiting of the two has
+// waited lets them be one band further apart.
+func (m *Matchmaker) compatible(a, b *MatchmakingEntry, now time.Time) bool {
+	if timeControlKey(a.TimeControl) != timeControlKey(b.TimeControl) {
+		return false
+	}
+	waited := now.Sub(a.JoinedAt)
+	if w := now.Sub(b.JoinedAt); w > waited {
+		waited = w
+	}
+	reach := int(waited / m.widenAfter)
+	distance := a.band - b.band
+	if distance < 0 {
+		distance = -distance
+	}
+	return distance <= reach
+}
+
+// takePairs removes and returns every pair that can be made now, pairing the
+// longest-waiting players first
+func (m *Matchmaker) takePairs() [][2]*MatchmakingEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queue := make([]*MatchmakingEntry, 0, len(m.waiting))
+	for _, entry := range m.waiting {
+		queue = append(queue, entry)
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		return queue[i].JoinedAt.Before(queue[j].JoinedAt)
+	})
+
+	now := m.now()
+	paired := make(map[string]bool)
+	var pairs [][2]*MatchmakingEntry
+	for i, a := range queue {
+		if paired[a.PlayerDID] {
+			continue
+		}
+		for _, b := range queue[i+1:] {
+			if paired[b.PlayerDID] || !m.compatible(a, b, now) {
+				continue
+			}
+			paired[a.PlayerDID] = true
+			paired[b.PlayerDID] = true
+			delete(m.waiting, a.PlayerDID)
+			delete(m.waiting, b.PlayerDID)
+			pairs = append(pairs, [2]*MatchmakingEntry{a, b})
+			break
+		}
+	}
+	return pairs
+}
+
+// requeue puts back players whose game couldn't be created, keeping their
+// place unless they've joined again since
+func (m *Matchmaker) requeue(entries ...*MatchmakingEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, entry := range entries {
+		if m.waiting[entry.PlayerDID] == nil {
+			m.waiting[entry.PlayerDID] = entry
+		}
+	}
+}
+
+// SetMatchmaker enables the matchmaking endpoints
+func (s *Service) SetMatchmaker(matchmaker *Matchmaker) {
+	s.matchmaker = matchmaker
+}
+
+// StartMatchmaking pairs queued players now and then every interval
+func (s *Service) StartMatchmaking(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultPairInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.PairMatchmaking(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PairMatchmaking pairs compatible players in the queue. Each game is created
+// in the repository of the player who waited longest, with colors drawn at
+// random, and both players get a "matched" frame on the player channel.
+func (s *Service) PairMatchmaking(ctx context.Context) {
+	if s.matchmaker == nil {
+		return
+	}
+	for _, pair := range s.matchmaker.takePairs() {
+		first, second := pair[0], pair[1]
+		color := "white"
+		if rand.Intn(2) == 0 {
+			color = "black"
+		}
+
+		game, err := first.client.CreateTimedGame(ctx, second.PlayerDID, color, first.TimeControl)
+		if err != nil {
+			log.Error().Err(err).Str("player", first.PlayerDID).Str("opponent", second.PlayerDID).Msg("Failed to create matched game")
+			s.matchmaker.requeue(first, second)
+			continue
+		}
+
+		s.notifyPlayer(game.White, NotificationMatched, game.ID, MatchFound{Game: game, Opponent: game.Black, Color: "white"})
+		s.notifyPlayer(game.Black, NotificationMatched, game.ID, MatchFound{Game: game, Opponent: game.White, Color: "black"})
+	}
+}
+
+// JoinMatchmakingHandler enters the current player into the matchmaking queue
+// for a time control, in the band of their current rating
+func (s *Service) JoinMatchmakingHandler(w http.ResponseWriter, r *http.Request) {
+	if s.matchmaker == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Matchmaking is not enabled"))
+		return
+	}
+
+	var req JoinMatchmakingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	client := s.clientFor(r)
+	if req.TimeControl == nil {
+		req.TimeControl = s.loadPreferences(r.Context(), client).DefaultTimeControl
+	}
+	if req.TimeControl == nil || !timeControlTypes[req.TimeControl.Type] {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("timeControl type must be correspondence, rapid, blitz or bullet"))
+		return
+	}
+
+	playerRating := int(rating.DefaultRating)
+	if s.ratings != nil {
+		playerRating = int(math.Round(s.ratings.Get(client.GetDID()).Rating))
+	}
+	entry := s.matchmaker.Join(client, req.TimeControl, playerRating)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entry)
+}
+
+// LeaveMatchmakingHandler takes the current player out of the matchmaking
+// queue
+func (s *Service) LeaveMatchmakingHandler(w http.ResponseWriter, r *http.Request) {
+	if s.matchmaker == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Matchmaking is not enabled"))
+		return
+	}
+
+	if !s.matchmaker.Leave(s.clientFor(r).GetDID()) {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("You are not in the matchmaking queue"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+var blitz = &chess.TimeControl{Type: "blitz", Initial: 300, Increment: 2}
+
+func TestMatchmakerPairsWithinBandsAndWidens(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	clientFor := func(did string) *atproto.Client {
+		pds.did = did
+		client, err := atproto.NewClient(pds.URL, did, "password")
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		return client
+	}
+
+	now := time.Date(2024, 3, 5, 18, 0, 0, 0, time.UTC)
+	m := NewMatchmaker(200, time.Minute)
+	m.now = func() time.Time { return now }
+
+	entry := m.Join(clientFor("did:plc:a"), blitz, 1500)
+	if entry.Queue != "blitz 300+2 / 1400-1599" {
+		t.Errorf("Unexpected queue %q", entry.Queue)
+	}
+	m.Join(clientFor("did:plc:rapid"), &chess.TimeControl{Type: "rapid", Initial: 600}, 1500)
+	m.Join(clientFor("did:plc:c"), blitz, 1650)
+	now = now.Add(time.Second)
+	m.Join(clientFor("did:plc:b"), blitz, 1550)
+
+	pairs := m.takePairs()
+	if len(pairs) != 1 || pairs[0][0].PlayerDID != "did:plc:a" || pairs[0][1].PlayerDID != "did:plc:b" {
+		t.Fatalf("Expected only the two blitz players in the same band to be paired, got %v", pairs)
+	}
+	if m.Waiting("did:plc:a") != nil || m.Waiting("did:plc:c") == nil {
+		t.Error("Expected paired players to leave the queue and the others to stay")
+	}
+
+	// A neighbouring band is only in reach once one of them has waited
+	m.Join(clientFor("did:plc:e"), blitz, 1420)
+	if pairs := m.takePairs(); len(pairs) != 0 {
+		t.Fatalf("Expected no pairs across bands yet, got %v", pairs)
+	}
+	now = now.Add(time.Minute)
+	pairs = m.takePairs()
+	if len(pairs) != 1 || pairs[0][0].PlayerDID != "did:plc:c" || pairs[0][1].PlayerDID != "did:plc:e" {
+		t.Fatalf("Expected the neighbouring bands to be paired after waiting, got %v", pairs)
+	}
+	if m.Waiting("did:plc:rapid") == nil {
+		t.Error("Expected the rapid player to still be waiting")
+	}
+}
+
+func TestMatchmakingPairsQueuedPlayers(t *testing.T) {
+	game := newSharedPDSGame(t, "active")
+	game.service.SetMatchmaker(NewMatchmaker(DefaultRatingBand, DefaultWidenAfter))
+	white := game.connectPlayerChannel(t, testWhiteDID)
+	black := game.connectPlayerChannel(t, testBlackDID)
+
+	for _, did := range []string{testWhiteDID, testBlackDID} {
+		w := serveAs(game.service.JoinMatchmakingHandler, game.service, game.tokens[did], "/api/matchmaking/join", JoinMatchmakingRequest{TimeControl: blitz})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %s to join the queue, got %d: %s", did, w.Code, w.Body.String())
+		}
+	}
+	game.service.PairMatchmaking(context.Background())
+
+	whiteFrame := readFrame(t, white, NotificationMatched)
+	blackFrame := readFrame(t, black, NotificationMatched)
+	whiteGame, _ := whiteFrame.Data["game"].(map[string]interface{})
+	blackGame, _ := blackFrame.Data["game"].(map[string]interface{})
+	gameID, _ := whiteGame["id"].(string)
+	if gameID == "" || gameID != blackGame["id"] {
+		t.Fatalf("Expected both players to be matched into the same game, got %v and %v", whiteGame, blackGame)
+	}
+	if whiteFrame.Data["opponent"] != testBlackDID || blackFrame.Data["opponent"] != testWhiteDID {
+		t.Errorf("Expected each player to be told their opponent, got %v and %v", whiteFrame.Data, blackFrame.Data)
+	}
+	if whiteFrame.Data["color"] == blackFrame.Data["color"] {
+		t.Errorf("Expected the players to get different colors, both got %v", whiteFrame.Data["color"])
+	}
+
+	record := game.whitePDS.get(gameID)
+	tc, _ := record["timeControl"].(map[string]interface{})
+	if record["status"] != "active" || tc["type"] != "blitz" {
+		t.Errorf("Expected an active blitz game, got %v", record)
+	}
+	if game.service.matchmaker.Waiting(testWhiteDID) != nil || game.service.matchmaker.Waiting(testBlackDID) != nil {
+		t.Error("Expected the queue to be empty")
+	}
+}
+
+func TestMatchmakingLeave(t *testing.T) {
+	game := newSharedPDSGame(t, "active")
+	token := game.tokens[testWhiteDID]
+
+	w := serveAs(game.service.JoinMatchmakingHandler, game.service, token, "/api/matchmaking/join", JoinMatchmakingRequest{TimeControl: blitz})
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "disabled" {
+		t.Errorf("Expected matchmaking to be disabled, got %d: %s", w.Code, w.Body.String())
+	}
+
+	game.service.SetMatchmaker(NewMatchmaker(DefaultRatingBand, DefaultWidenAfter))
+	w = serveAs(game.service.JoinMatchmakingHandler, game.service, token, "/api/matchmaking/join", JoinMatchmakingRequest{})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a time control to be required, got %d: %s", w.Code, w.Body.String())
+	}
+	w = serveAs(game.service.JoinMatchmakingHandler, game.service, token, "/api/matchmaking/join", JoinMatchmakingRequest{TimeControl: blitz})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected to join the queue, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serveAs(game.service.LeaveMatchmakingHandler, game.service, token, "/api/matchmaking/leave", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected to leave the queue, got %d: %s", w.Code, w.Body.String())
+	}
+	w = serveAs(game.service.LeaveMatchmakingHandler, game.service, token, "/api/matchmaking/leave", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected to no longer be queued, got %d: %s", w.Code, w.Body.String())
+	}
+}
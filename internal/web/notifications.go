@@ -0,0 +1,63 @@
+package web
+
+import (
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// Notification types delivered to a player on the player channel
+const (
+	NotificationChallenge    = "challenge"
+	NotificationDrawOffer    = "draw_offer"
+	NotificationYourMove     = "your_move"
+	NotificationRematchOffer = "rematch_offer"
+	NotificationRematch      = "rematch" // a rematch was accepted; the update's game is the new one
+	NotificationMatched      = "matched" // the matchmaking queue paired the player
+	NotificationTimeout      = "timeout" // a correspondence game ended when a player ran out of time
+	// NotificationChallengeExpired tells both players a challenge went unanswered
+	NotificationChallengeExpired = "challenge_expired"
+	// NotificationChallengeCancelled tells the challenged player a challenge was withdrawn
+	NotificationChallengeCancelled = "challenge_cancelled"
+	// NotificationDrawOfferWithdrawn tells a player the draw they were offered
+	// lapsed when the offering player moved again
+	NotificationDrawOfferWithdrawn = "draw_offer_withdrawn"
+	// NotificationAchievement tells a player they've unlocked an achievement
+	NotificationAchievement = "achievement"
+)
+
+// SetHub lets handlers deliver notifications to players' own connections
+func (s *Service) SetHub(hub *Hub) {
+	s.hub = hub
+}
+
+// notifyPlayer delivers an update to a player's connections on the player
+// channel. Without a hub, players find out on their next poll instead.
+func (s *Service) notifyPlayer(playerDID, notificationType, gameID string, data interface{}) {
+	if playerDID == "" {
+		return
+	}
+	// Bots may be polling the bot API rather than holding a connection open
+	if s.client.IsBotAccount(playerDID) {
+		s.botEvents.publish(playerDID, BotEvent{Type: notificationType, GameID: gameID, Data: data, CreatedAt: time.Now().UTC()})
+	}
+	if s.hub == nil {
+		return
+	}
+	s.hub.BroadcastToPlayer(playerDID, GameUpdate{
+		GameID: gameID,
+		Type:   notificationType,
+		Data:   data,
+	})
+}
+
+// opponentOf returns the other player in a game, or "" if playerDID isn't in it
+func opponentOf(game *chess.Game, playerDID string) string {
+	switch playerDID {
+	case game.White:
+		return game.Black
+	case game.Black:
+		return game.White
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// connectPlayerChannel opens a player's notification connection and waits
+// for the hub to register it
+func (g *liveGame) connectPlayerChannel(t *testing.T, did string) *websocket.Conn {
+	t.Helper()
+	base := strings.SplitN(g.wsURL, "?", 2)[0]
+	conn, _, err := websocket.DefaultDialer.Dial(base+"?channel=player&session="+g.tokens[did], nil)
+	if err != nil {
+		t.Fatalf("Failed to connect %s: %v", did, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for g.hub.SubscriberCount(did, PlayerChannel) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to be registered on the player channel", did)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return conn
+}
+
+// expectSilence fails if a frame of the given type arrives within a short wait
+func expectSilence(t *testing.T, conn *websocket.Conn, frameType string) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if strings.Contains(string(message), `"type":"`+frameType+`"`) {
+			t.Fatalf("Expected no %s frame, got %s", frameType, message)
+		}
+	}
+}
+
+func TestPlayerChannelDeliversOnlyToRecipient(t *testing.T) {
+	game := newLiveGame(t, DisconnectPolicyPause, time.Hour)
+	white := game.connectPlayerChannel(t, testWhiteDID)
+	black := game.connectPlayerChannel(t, testBlackDID)
+	spectator, _, err := websocket.DefaultDialer.Dial(game.wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect spectator: %v", err)
+	}
+	defer spectator.Close()
+	for game.hub.SubscriberCount(game.gameID, GameChannel) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	game.hub.BroadcastToPlayer(testBlackDID, GameUpdate{GameID: game.gameID, Type: "test_notification", Data: map[string]interface{}{"n": 1}})
+
+	frame := readFrame(t, black, "test_notification")
+	if frame.Data["n"] != float64(1) {
+		t.Errorf("Expected the data to be delivered unwrapped, got %+v", frame.Data)
+	}
+	expectSilence(t, white, "test_notification")
+	expectSilence(t, spectator, "test_notification")
+}
+
+func TestPlayerChannelRequiresSession(t *testing.T) {
+	game := newLiveGame(t, DisconnectPolicyPause, time.Hour)
+	base := strings.SplitN(game.wsURL, "?", 2)[0]
+
+	for name, url := range map[string]string{
+		"anonymous":       base + "?channel=player",
+		"invalid session": base + "?channel=player&session=bogus",
+		"invalid on game": game.wsURL + "&session=bogus",
+	} {
+		_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %v (%v)", name, resp, err)
+		}
+	}
+}
+
+func TestMoveNotifiesOpponent(t *testing.T) {
+	game := newLiveGame(t, DisconnectPolicyPause, time.Hour)
+	game.service.SetHub(game.hub)
+	white := game.connectPlayerChannel(t, testWhiteDID)
+	black := game.connectPlayerChannel(t, testBlackDID)
+
+	// Without a session the move is made as the service account, white
+	w := postMove(game.service, map[string]interface{}{"from": "e2", "to": "e4", "game_id": game.gameID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected move to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	frame := readFrame(t, black, NotificationYourMove)
+	if frame.Data["opponent"] != testWhiteDID || frame.Data["san"] != "e4" {
+		t.Errorf("Unexpected your_move frame: %+v", frame.Data)
+	}
+	expectSilence(t, white, NotificationYourMove)
+}
+
+func TestDrawOfferNotifiesOpponent(t *testing.T) {
+	game := newLiveGame(t, DisconnectPolicyPause, time.Hour)
+	game.service.SetHub(game.hub)
+	black := game.connectPlayerChannel(t, testBlackDID)
+
+	w := serveAs(game.service.OfferDrawHandler, game.service, ownSession(game.service), "/api/draw-offers", map[string]interface{}{"gameId": game.gameID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected draw offer to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	frame := readFrame(t, black, NotificationDrawOffer)
+	if frame.Data["OfferedBy"] != testWhiteDID {
+		t.Errorf("Unexpected draw_offer frame: %+v", frame.Data)
+	}
+}
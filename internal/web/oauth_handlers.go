@@ -9,9 +9,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 
+	"github.com/justinabrahms/atchess/internal/apierror"
 	"github.com/justinabrahms/atchess/internal/oauth"
 	"github.com/rs/zerolog/log"
 )
@@ -23,8 +23,9 @@ var (
 	authStore *oauth.AuthorizationStore
 )
 
-// InitializeOAuth sets up the OAuth client and stores
-func InitializeOAuth(baseURL string) error {
+// InitializeOAuth sets up the OAuth client and stores. Sessions and pending
+// logins are kept in storage, with their DPoP keys encrypted by keys.
+func InitializeOAuth(baseURL string, storage oauth.Storage, keys *oauth.KeyBox) error {
 	clientID := baseURL + "/client-metadata.json"
 	redirectURI := baseURL + "/api/callback"
 	
@@ -34,8 +35,8 @@ func InitializeOAuth(baseURL string) error {
 	}
 	
 	oauthClient = client
-	sessionStore = oauth.NewSessionStore()
-	authStore = oauth.NewAuthorizationStore()
+	sessionStore = oauth.NewSessionStoreWithStorage(storage, keys)
+	authStore = oauth.NewAuthorizationStoreWithStorage(storage, keys)
 	
 	// Start session cleanup routine
 	sessionStore.StartCleanupRoutine()
@@ -62,7 +63,7 @@ func (s *Service) OAuthLoginHandler(w http.ResponseWriter, r *http.Request) {
 	// Check if OAuth is initialized
 	if oauthClient == nil || authStore == nil || sessionStore == nil {
 		log.Error().Msg("OAuth not initialized - SERVER_BASE_URL may not be set")
-		http.Error(w, "OAuth not configured. Please ensure SERVER_BASE_URL is set.", http.StatusServiceUnavailable)
+		apierror.Write(w, apierror.ErrUnavailable.WithMessage("OAuth not configured. Please ensure SERVER_BASE_URL is set."))
 		return
 	}
 	
@@ -71,7 +72,7 @@ func (s *Service) OAuthLoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidBody)
 		return
 	}
 	
@@ -79,39 +80,45 @@ func (s *Service) OAuthLoginHandler(w http.ResponseWriter, r *http.Request) {
 	pdsURL, authEndpoint, err := s.resolveOAuthEndpoints(req.Handle)
 	if err != nil {
 		log.Error().Err(err).Str("handle", req.Handle).Msg("Failed to resolve OAuth endpoints")
-		http.Error(w, "Failed to resolve authentication server", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to resolve authentication server"))
 		return
 	}
 	
 	// Generate PKCE parameters
 	verifier, challenge, err := oauth.GeneratePKCE()
 	if err != nil {
-		http.Error(w, "Failed to generate PKCE", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to generate PKCE"))
 		return
 	}
 	
 	// Generate state
 	state, err := oauth.GenerateState()
 	if err != nil {
-		http.Error(w, "Failed to generate state", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to generate state"))
 		return
 	}
 	
 	// Generate DPoP key for this session
 	dpopKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
-		http.Error(w, "Failed to generate DPoP key", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to generate DPoP key"))
 		return
 	}
 	
 	// Store authorization request
-	authStore.StoreAuthorization(&oauth.AuthorizationRequest{
+	err = authStore.StoreAuthorization(r.Context(), &oauth.AuthorizationRequest{
 		State:        state,
 		CodeVerifier: verifier,
 		Handle:       req.Handle,
+		PDSURL:       pdsURL,
 		CreatedAt:    time.Now(),
 		DPoPKey:      dpopKey,
 	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to store authorization request")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to start login"))
+		return
+	}
 	
 	// Build authorization URL
 	authURL := oauthClient.BuildAuthorizationURL(authEndpoint, req.Handle, state, challenge)
@@ -129,7 +136,7 @@ func (s *Service) OAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	// Check if OAuth is initialized
 	if oauthClient == nil || authStore == nil || sessionStore == nil {
 		log.Error().Msg("OAuth not initialized - SERVER_BASE_URL may not be set")
-		http.Error(w, "OAuth not configured. Please ensure SERVER_BASE_URL is set.", http.StatusServiceUnavailable)
+		apierror.Write(w, apierror.ErrUnavailable.WithMessage("OAuth not configured. Please ensure SERVER_BASE_URL is set."))
 		return
 	}
 	
@@ -139,15 +146,15 @@ func (s *Service) OAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	iss := r.URL.Query().Get("iss")
 	
 	if code == "" || state == "" {
-		http.Error(w, "Missing code or state", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Missing code or state"))
 		return
 	}
 	
 	// Retrieve authorization request
-	authReq, err := authStore.GetAndDeleteAuthorization(state)
+	authReq, err := authStore.GetAndDeleteAuthorization(r.Context(), state)
 	if err != nil {
 		log.Error().Err(err).Str("state", state).Msg("Failed to retrieve authorization")
-		http.Error(w, "Invalid or expired authorization", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid or expired authorization"))
 		return
 	}
 	
@@ -155,7 +162,7 @@ func (s *Service) OAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	tokenEndpoint, err := s.getTokenEndpoint(iss)
 	if err != nil {
 		log.Error().Err(err).Str("iss", iss).Msg("Failed to get token endpoint")
-		http.Error(w, "Failed to get token endpoint", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to get token endpoint"))
 		return
 	}
 	
@@ -168,7 +175,7 @@ func (s *Service) OAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
 			Str("code", code[:10]+"...").
 			Str("iss", iss).
 			Msg("Failed to exchange code for tokens")
-		http.Error(w, fmt.Sprintf("Failed to exchange authorization code: %v", err), http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage(fmt.Sprintf("Failed to exchange authorization code: %v", err)))
 		return
 	}
 	
@@ -176,13 +183,19 @@ func (s *Service) OAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	session := &oauth.Session{
 		DID:          tokens.Sub,
 		Handle:       authReq.Handle,
+		PDSURL:       authReq.PDSURL,
 		AccessToken:  tokens.AccessToken,
 		RefreshToken: tokens.RefreshToken,
 		ExpiresAt:    time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second),
 		DPoPKey:      authReq.DPoPKey,
 	}
 	
-	sessionID := sessionStore.CreateSession(session)
+	sessionID, err := sessionStore.CreateSession(r.Context(), session)
+	if err != nil {
+		log.Error().Err(err).Str("did", session.DID).Msg("Failed to store OAuth session")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to create session"))
+		return
+	}
 	
 	// Redirect to main page with session
 	http.Redirect(w, r, "/?session="+sessionID, http.StatusFound)
@@ -192,13 +205,18 @@ func (s *Service) OAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
 func (s *Service) GetSessionHandler(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.Header.Get("X-Session-ID")
 	if sessionID == "" {
-		http.Error(w, "No session", http.StatusUnauthorized)
+		apierror.Write(w, apierror.ErrUnauthorized.WithMessage("No session"))
+		return
+	}
+	
+	if sessionStore == nil {
+		apierror.Write(w, apierror.ErrInvalidSession.WithMessage("Invalid session"))
 		return
 	}
 	
-	session, err := sessionStore.GetSession(sessionID)
+	session, err := sessionStore.GetSession(r.Context(), sessionID)
 	if err != nil {
-		http.Error(w, "Invalid session", http.StatusUnauthorized)
+		apierror.Write(w, apierror.ErrInvalidSession.WithMessage("Invalid session"))
 		return
 	}
 	
@@ -215,7 +233,12 @@ func (s *Service) GetSessionHandler(w http.ResponseWriter, r *http.Request) {
 func (s *Service) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.Header.Get("X-Session-ID")
 	if sessionID != "" {
-		sessionStore.DeleteSession(sessionID)
+		s.sessions.Delete(sessionID)
+		if sessionStore != nil {
+			if err := sessionStore.DeleteSession(r.Context(), sessionID); err != nil {
+				log.Warn().Err(err).Msg("Failed to delete OAuth session")
+			}
+		}
 	}
 	
 	w.WriteHeader(http.StatusNoContent)
@@ -224,20 +247,23 @@ func (s *Service) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 // Helper methods
 
 func (s *Service) resolveOAuthEndpoints(handle string) (pdsURL, authEndpoint string, err error) {
+	resolver := s.client.Resolver()
+	
 	// First resolve handle to DID
-	did, err := s.client.ResolveHandle(context.Background(), handle)
+	did, err := resolver.ResolveHandle(context.Background(), handle)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to resolve handle: %w", err)
 	}
 	
 	// Get DID document to find PDS
-	didDoc, err := s.getDidDocument(did)
+	didDoc, err := resolver.ResolveDID(context.Background(), did)
 	if err != nil {
+		resolver.Invalidate(handle)
 		return "", "", fmt.Errorf("failed to get DID document: %w", err)
 	}
 	
 	// Extract PDS URL from DID document
-	pdsURL = s.extractPDSFromDidDoc(didDoc)
+	pdsURL = didDoc.PDSEndpoint()
 	if pdsURL == "" {
 		return "", "", fmt.Errorf("no PDS URL in DID document")
 	}
@@ -257,54 +283,6 @@ func (s *Service) resolveOAuthEndpoints(handle string) (pdsURL, authEndpoint str
 	return pdsURL, authEndpoint, nil
 }
 
-func (s *Service) getDidDocument(did string) (map[string]interface{}, error) {
-	// For did:plc, use PLC directory
-	if strings.HasPrefix(did, "did:plc:") {
-		resp, err := http.Get(fmt.Sprintf("https://plc.directory/%s", did))
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-		
-		var doc map[string]interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
-			return nil, err
-		}
-		
-		return doc, nil
-	}
-	
-	// For did:web, resolve via HTTPS
-	if strings.HasPrefix(did, "did:web:") {
-		// Implementation for did:web resolution
-		return nil, fmt.Errorf("did:web not yet implemented")
-	}
-	
-	return nil, fmt.Errorf("unsupported DID method")
-}
-
-func (s *Service) extractPDSFromDidDoc(doc map[string]interface{}) string {
-	// Look for atproto_pds service
-	services, ok := doc["service"].([]interface{})
-	if !ok {
-		return ""
-	}
-	
-	for _, svc := range services {
-		service, ok := svc.(map[string]interface{})
-		if !ok {
-			continue
-		}
-		
-		if service["id"] == "#atproto_pds" {
-			endpoint, _ := service["serviceEndpoint"].(string)
-			return endpoint
-		}
-	}
-	
-	return ""
-}
-
 func (s *Service) getAuthorizationServer(pdsURL string) (string, error) {
 	// Get resource server metadata
 	resp, err := http.Get(pdsURL + "/.well-known/oauth-protected-resource")
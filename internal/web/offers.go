@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// offerSweepInterval is how often superseded offers are expired
+const offerSweepInterval = 10 * time.Minute
+
+// SweepSupersededOffers expires superseded draw offers in the repositories of
+// the service account and every signed-in player. Only a repository's owner
+// can rewrite its records, so players who aren't signed in are swept at
+// their next visit. It returns how many offers were expired.
+func (s *Service) SweepSupersededOffers(ctx context.Context) int {
+	clients := []*atproto.Client{s.client}
+	for _, client := range s.sessions.Clients() {
+		if client.GetDID() != s.client.GetDID() {
+			clients = append(clients, client)
+		}
+	}
+
+	total := 0
+	for _, client := range clients {
+		expired, err := client.ExpireSupersededDrawOffers(ctx)
+		if err != nil {
+			log.Warn().Err(err).Str("did", client.GetDID()).Msg("Failed to expire superseded draw offers")
+		}
+		total += expired
+	}
+	if total > 0 {
+		log.Info().Int("expired", total).Msg("Expired superseded draw offers")
+	}
+	return total
+}
+
+// StartOfferSweeper starts a goroutine that periodically expires superseded offers
+func (s *Service) StartOfferSweeper() {
+	go func() {
+		ticker := time.NewTicker(offerSweepInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.SweepSupersededOffers(context.Background())
+		}
+	}()
+}
+
+// withdrawDrawOffers withdraws the draw offers a player let lapse by moving
+// again and tells their opponent. It runs in the background so the move's
+// reply isn't held up.
+func (s *Service) withdrawDrawOffers(client *atproto.Client, game *chess.Game) {
+	go func() {
+		withdrawn, err := client.WithdrawDrawOffers(context.Background(), game.ID)
+		if err != nil {
+			log.Warn().Err(err).Str("gameID", game.ID).Msg("Failed to withdraw lapsed draw offers")
+		}
+		if withdrawn > 0 {
+			s.notifyPlayer(opponentOf(game, client.GetDID()), NotificationDrawOfferWithdrawn, game.ID, map[string]interface{}{
+				"offeredBy": client.GetDID(),
+				"withdrawn": withdrawn,
+			})
+		}
+	}()
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+func seedDrawOffer(pds *fakePDS, did, rkey, gameURI, status, createdAt string) string {
+	uri := fmt.Sprintf("at://%s/app.atchess.drawOffer/%s", did, rkey)
+	pds.put(uri, map[string]interface{}{
+		"$type":     "app.atchess.drawOffer",
+		"createdAt": createdAt,
+		"game":      map[string]interface{}{"uri": gameURI, "cid": "cid-" + gameURI},
+		"offeredBy": did,
+		"status":    status,
+	})
+	return uri
+}
+
+func TestSweepExpiresSupersededDrawOffers(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	black, err := atproto.NewClient(pds.URL, "black", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	pds.did = testWhiteDID
+	service := newServiceForPDS(t, pds)
+	_, _ = service.Sessions().Create(black)
+
+	active := seedGame(pds, startFEN, "active")
+	finished := fmt.Sprintf("at://%s/app.atchess.game/game2", testWhiteDID)
+	pds.put(finished, map[string]interface{}{"white": testWhiteDID, "black": testBlackDID, "status": "white_won", "fen": startFEN})
+
+	older := seedDrawOffer(pds, testWhiteDID, "o1", active, "pending", "2024-01-01T10:00:00Z")
+	latest := seedDrawOffer(pds, testWhiteDID, "o2", active, "pending", "2024-01-01T10:05:00Z")
+	afterEnd := seedDrawOffer(pds, testWhiteDID, "o3", finished, "pending", "2024-01-01T09:00:00Z")
+	declined := seedDrawOffer(pds, testWhiteDID, "o4", active, "declined", "2024-01-01T09:30:00Z")
+	blackOlder := seedDrawOffer(pds, testBlackDID, "b1", active, "pending", "2024-01-01T10:01:00Z")
+	seedDrawOffer(pds, testBlackDID, "b2", active, "pending", "2024-01-01T10:02:00Z")
+
+	// Superseded offers are hidden before the sweeper gets to them
+	offers, err := service.client.GetDrawOffers(context.Background(), active)
+	if err != nil || len(offers) != 1 || offers[0].URI != latest {
+		t.Fatalf("Expected only the latest offer, got %v (%v)", offers, err)
+	}
+	if offers, _ := service.client.GetDrawOffers(context.Background(), finished); len(offers) != 0 {
+		t.Errorf("Expected no open offers in a finished game, got %d", len(offers))
+	}
+
+	if expired := service.SweepSupersededOffers(context.Background()); expired != 3 {
+		t.Errorf("Expected 3 offers to be expired, got %d", expired)
+	}
+	if pds.applyWritesCalls != 2 {
+		t.Errorf("Expected one applyWrites batch per repository, got %d", pds.applyWritesCalls)
+	}
+
+	for uri, supersededBy := range map[string]string{older: latest, afterEnd: finished, blackOlder: fmt.Sprintf("at://%s/app.atchess.drawOffer/b2", testBlackDID)} {
+		record := pds.get(uri)
+		if record["status"] != "expired" || record["supersededBy"] != supersededBy || record["expiredAt"] == nil {
+			t.Errorf("Expected %s to be expired in favour of %s, got %v", uri, supersededBy, record)
+		}
+	}
+	if status := pds.get(latest)["status"]; status != "pending" {
+		t.Errorf("Expected the latest offer to stay pending, got %v", status)
+	}
+	if status := pds.get(declined)["status"]; status != "declined" {
+		t.Errorf("Expected answered offers to be left alone, got %v", status)
+	}
+
+	if expired := service.SweepSupersededOffers(context.Background()); expired != 0 {
+		t.Errorf("Expected a second sweep to find nothing, got %d", expired)
+	}
+}
+
+func TestDrawOffersLapse(t *testing.T) {
+	ctx := context.Background()
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	// Each game has reached 1. e4 e5, with white to move
+	const afterE5 = "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2"
+	game := func(rkey string) string {
+		uri := fmt.Sprintf("at://%s/app.atchess.game/%s", testWhiteDID, rkey)
+		pds.put(uri, map[string]interface{}{"white": testWhiteDID, "black": testBlackDID, "status": "active", "fen": afterE5})
+		return uri
+	}
+	offer := func(rkey, gameURI string, moveNumber int, expiresAt time.Time) string {
+		uri := seedDrawOffer(pds, testWhiteDID, rkey, gameURI, "pending", "2024-01-01T10:00:00Z")
+		pds.get(uri)["moveNumber"] = float64(moveNumber)
+		pds.get(uri)["expiresAt"] = expiresAt.UTC().Format(time.RFC3339)
+		return uri
+	}
+	moved, timedOut, open := game("moved"), game("timedout"), game("open")
+	// White offered before 1. e4 and has moved since
+	withdrawn := offer("o1", moved, 1, time.Now().Add(time.Hour))
+	// White offered before playing 2. and nobody answered in time
+	expired := offer("o2", timedOut, 3, time.Now().Add(-time.Minute))
+	// White offered during black's first move and hasn't moved since
+	pending := offer("o3", open, 2, time.Now().Add(time.Hour))
+
+	for gameURI, want := range map[string]int{moved: 0, timedOut: 0, open: 1} {
+		if offers, err := service.client.GetDrawOffers(ctx, gameURI); err != nil || len(offers) != want {
+			t.Errorf("Expected %d open offers in %s, got %v (%v)", want, gameURI, offers, err)
+		}
+	}
+
+	if marked := service.SweepSupersededOffers(ctx); marked != 2 {
+		t.Errorf("Expected 2 lapsed offers to be marked, got %d", marked)
+	}
+	if record := pds.get(withdrawn); record["status"] != "withdrawn" || record["withdrawnAt"] == nil {
+		t.Errorf("Expected the offer to be withdrawn, got %v", record)
+	}
+	if record := pds.get(expired); record["status"] != "expired" || record["expiredAt"] == nil || record["supersededBy"] != nil {
+		t.Errorf("Expected the offer to expire, got %v", record)
+	}
+	if status := pds.get(pending)["status"]; status != "pending" {
+		t.Errorf("Expected the open offer to stay pending, got %v", status)
+	}
+
+	// White's next move withdraws the offer
+	pds.get(open)["fen"] = "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"
+	if n, err := service.client.WithdrawDrawOffers(ctx, open); err != nil || n != 1 {
+		t.Fatalf("Expected one offer withdrawn, got %d (%v)", n, err)
+	}
+	if status := pds.get(pending)["status"]; status != "withdrawn" {
+		t.Errorf("Expected the offer to be withdrawn by the move, got %v", status)
+	}
+
+	// New offers record when they lapse
+	service.client.SetDrawOfferTimeout(time.Hour)
+	created, err := service.client.OfferDraw(ctx, open, "")
+	if err != nil {
+		t.Fatalf("Failed to offer draw: %v", err)
+	}
+	record := pds.get(created.URI)
+	if record["moveNumber"] != float64(4) || created.MoveNumber != 4 || record["expiresAt"] == nil || created.ExpiresAt == "" {
+		t.Errorf("Expected the offer to lapse at move 4 or in an hour, got %v", record)
+	}
+}
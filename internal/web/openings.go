@@ -0,0 +1,78 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/openings"
+	"github.com/rs/zerolog/log"
+)
+
+// ecoPrefixPattern matches an ECO code or a prefix of one, e.g. "C" or "C6"
+var ecoPrefixPattern = regexp.MustCompile(`^[A-E]([0-9][0-9]?)?$`)
+
+// GameOpeningHandler names the opening a game was played in, from the
+// positions its moves reached
+func (s *Service) GameOpeningHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	client := s.readerFor(r)
+	if _, err := client.GetGame(context.Background(), gameID); err != nil {
+		apierror.Write(w, apierror.ErrGameNotFound)
+		return
+	}
+	moves, err := client.GetMoves(context.Background(), gameID)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to load moves for opening")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to load moves"))
+		return
+	}
+
+	fens := make([]string, 0, len(moves))
+	for _, move := range moves {
+		fens = append(fens, move.FEN)
+	}
+	opening := openings.Classify(fens)
+	if opening == nil {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Game has not reached a known opening"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(opening)
+}
+
+// PublicOpeningsHandler aggregates results of recent finished games by
+// opening, optionally limited to ECO codes starting with eco
+func (s *Service) PublicOpeningsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.gameIndex == nil {
+		apierror.Write(w, apierror.ErrUnavailable.WithMessage("Game index is not enabled"))
+		return
+	}
+
+	eco := r.URL.Query().Get("eco")
+	if eco != "" && !ecoPrefixPattern.MatchString(eco) {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid ECO code: "+eco))
+		return
+	}
+
+	results, err := s.gameIndex.ExploreOpenings(r.Context(), eco, explorerSampleSize)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to explore openings for public API")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to explore openings"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"openings": results,
+	})
+}
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/openings"
+)
+
+func getOpening(s *Service, gameID string) *httptest.ResponseRecorder {
+	encoded := base64.URLEncoding.EncodeToString([]byte(gameID))
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/games/"+encoded+"/opening", nil), map[string]string{"id": encoded})
+	w := httptest.NewRecorder()
+	s.GameOpeningHandler(w, req)
+	return w
+}
+
+func TestGameOpeningHandler(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	w := getOpening(service, gameID)
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "not_found" {
+		t.Errorf("Expected no opening before any moves, got %d: %s", w.Code, w.Body.String())
+	}
+
+	seedMove(pds, gameID, testWhiteDID, "m1", "e2", "e4", "e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1")
+	seedMove(pds, gameID, testBlackDID, "m2", "e7", "e5", "e5", "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2")
+	seedMove(pds, gameID, testWhiteDID, "m3", "g1", "f3", "Nf3", "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2")
+
+	w = getOpening(service, gameID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the opening, got %d: %s", w.Code, w.Body.String())
+	}
+	var opening openings.Opening
+	_ = json.Unmarshal(w.Body.Bytes(), &opening)
+	if opening.ECO != "C40" || opening.Name != "King's Knight Opening" || len(opening.Moves) != 3 {
+		t.Errorf("Expected the King's Knight Opening, got %s", w.Body.String())
+	}
+
+	if w = getOpening(service, "at://did:plc:white/app.atchess.game/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing game, got %d", w.Code)
+	}
+}
+
+func TestPublicOpeningsHandler(t *testing.T) {
+	service, router := newPublicAPIRouter(t, 100)
+	router.HandleFunc("/public/v1/openings", service.PublicOpeningsHandler).Methods("GET")
+
+	if w := publicGet(router, "/public/v1/openings", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without an index, got %d", w.Code)
+	}
+
+	ctx := context.Background()
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	service.SetGameIndex(indexer)
+	games := []struct {
+		rkey, status string
+		sans         []string
+	}{
+		{"g1", "white_won", []string{"e4", "c5"}},
+		{"g2", "draw", []string{"e4", "c5", "Nf3"}},
+		{"g3", "black_won", []string{"e4", "c5"}},
+		{"g4", "white_won", []string{"d4", "d5", "c4"}},
+	}
+	for _, game := range games {
+		gameURI := "at://" + testWhiteDID + "/app.atchess.game/" + game.rkey
+		_ = indexer.Apply(ctx, "create", testWhiteDID, "app.atchess.game/"+game.rkey, map[string]interface{}{
+			"white":     testWhiteDID,
+			"black":     testBlackDID,
+			"status":    game.status,
+			"fen":       startFEN,
+			"createdAt": "2024-01-01T00:00:00Z",
+		})
+		engine := chess.NewEngine()
+		for i, san := range game.sans {
+			result, err := engine.MakeMoveSAN(san)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = indexer.Apply(ctx, "create", testWhiteDID, fmt.Sprintf("app.atchess.move/%s-%d", game.rkey, i), map[string]interface{}{
+				"game":      map[string]interface{}{"uri": gameURI},
+				"player":    testWhiteDID,
+				"san":       san,
+				"fen":       result.FEN,
+				"createdAt": fmt.Sprintf("2024-01-01T00:%02d:00Z", i+1),
+			})
+		}
+	}
+
+	var body struct {
+		Openings []index.OpeningResults `json:"openings"`
+	}
+	w := publicGet(router, "/public/v1/openings?eco=B", "")
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusOK || len(body.Openings) != 2 {
+		t.Fatalf("Expected two Sicilian lines, got %d: %s", w.Code, w.Body.String())
+	}
+	if sicilian := body.Openings[0]; sicilian.ECO != "B20" || sicilian.Games != 2 || sicilian.WhiteWins != 1 || sicilian.BlackWins != 1 {
+		t.Errorf("Unexpected results for the Sicilian: %+v", sicilian)
+	}
+
+	if w = publicGet(router, "/public/v1/openings?eco=Z9", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid ECO code, got %d", w.Code)
+	}
+}
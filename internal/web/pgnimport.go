@@ -0,0 +1,456 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/importer"
+	"github.com/rs/zerolog/log"
+)
+
+// PGN import job statuses
+const (
+	PGNImportQueued  = "queued"
+	PGNImportRunning = "running"
+	PGNImportDone    = "done"
+	PGNImportFailed  = "failed"
+)
+
+const (
+	// maxPGNUploadBytes bounds an uploaded PGN file
+	maxPGNUploadBytes = 64 << 20
+	// pgnImportQueueSize is how many uploads can wait to be imported
+	pgnImportQueueSize = 16
+	// pgnImportBatchGames is how many games are recorded together
+	pgnImportBatchGames = 20
+	// maxReportedSkips bounds the skipped games listed in a job's progress
+	maxReportedSkips = 100
+	// pgnImportRetention is how long a finished job's progress is kept
+	pgnImportRetention = time.Hour
+)
+
+// ErrImportQueueFull is returned when too many uploads are waiting
+var ErrImportQueueFull = errors.New("import queue is full")
+
+// ErrImportInProgress is returned when the player already has an import
+// queued or running
+var ErrImportInProgress = errors.New("an import is already in progress")
+
+// PGNImportJob is the progress of a PGN file being imported
+type PGNImportJob struct {
+	ID       string  `json:"id"`
+	DID      string  `json:"did"`
+	Status   string  `json:"status"`
+	Progress float64 `json:"progress"` // fraction of the file read
+	Read     int     `json:"read"`     // games read so far
+	Imported int     `json:"imported"`
+	Skipped  int     `json:"skipped"`
+	// SkippedGames lists the first games skipped, and why
+	SkippedGames []SkippedGame `json:"skippedGames"`
+	Error        string        `json:"error,omitempty"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	FinishedAt   *time.Time    `json:"finishedAt,omitempty"`
+}
+
+// pgnImportTask is an uploaded file waiting to be imported
+type pgnImportTask struct {
+	job      *PGNImportJob
+	client   *atproto.Client
+	path     string
+	size     int64
+	username string
+	color    string
+}
+
+// PGNImports imports uploaded PGN files in the background, one at a time,
+// and keeps each job's progress
+type PGNImports struct {
+	queue chan *pgnImportTask
+	now   func() time.Time
+
+	mu   sync.Mutex
+	jobs map[string]*PGNImportJob
+}
+
+// NewPGNImports creates an empty import queue; Run works through it
+func NewPGNImports() *PGNImports {
+	return &PGNImports{
+		queue: make(chan *pgnImportTask, pgnImportQueueSize),
+		now:   time.Now,
+		jobs:  make(map[string]*PGNImportJob),
+	}
+}
+
+// SetPGNImports enables bulk imports of PGN files
+func (s *Service) SetPGNImports(imports *PGNImports) {
+	s.pgnImports = imports
+}
+
+// Run imports queued files until ctx is done
+func (p *PGNImports) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case task := <-p.queue:
+			p.run(ctx, task)
+		}
+	}
+}
+
+// Enqueue queues a spooled upload at path for import into the repository of
+// client's account. The file is removed once it has been imported.
+func (p *PGNImports) Enqueue(client *atproto.Client, path string, size int64, username, color string) (*PGNImportJob, error) {
+	did := client.GetDID()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pruneLocked()
+	for _, job := range p.jobs {
+		if job.DID == did && (job.Status == PGNImportQueued || job.Status == PGNImportRunning) {
+			return nil, ErrImportInProgress
+		}
+	}
+
+	job := &PGNImportJob{
+		ID:           newPGNImportID(),
+		DID:          did,
+		Status:       PGNImportQueued,
+		SkippedGames: []SkippedGame{},
+		CreatedAt:    p.now().UTC(),
+	}
+	select {
+	case p.queue <- &pgnImportTask{job: job, client: client, path: path, size: size, username: username, color: color}:
+	default:
+		return nil, ErrImportQueueFull
+	}
+	p.jobs[job.ID] = job
+	return p.snapshotLocked(job), nil
+}
+
+// Job returns a copy of a job's progress
+func (p *PGNImports) Job(id string) (*PGNImportJob, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	job, ok := p.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return p.snapshotLocked(job), true
+}
+
+func (p *PGNImports) snapshotLocked(job *PGNImportJob) *PGNImportJob {
+	snapshot := *job
+	snapshot.SkippedGames = append([]SkippedGame{}, job.SkippedGames...)
+	return &snapshot
+}
+
+// pruneLocked forgets jobs that finished over pgnImportRetention ago
+func (p *PGNImports) pruneLocked() {
+	cutoff := p.now().Add(-pgnImportRetention)
+	for id, job := range p.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(p.jobs, id)
+		}
+	}
+}
+
+// update changes a job's progress under the lock
+func (p *PGNImports) update(job *PGNImportJob, fn func(job *PGNImportJob)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn(job)
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// run imports one uploaded file, reading it a game at a time and recording
+// the games in batches
+func (p *PGNImports) run(ctx context.Context, task *pgnImportTask) {
+	job := task.job
+	defer os.Remove(task.path)
+	p.update(job, func(job *PGNImportJob) { job.Status = PGNImportRunning })
+
+	finish := func(err error) {
+		p.update(job, func(job *PGNImportJob) {
+			now := p.now().UTC()
+			job.FinishedAt = &now
+			job.Status = PGNImportDone
+			if err != nil {
+				job.Status = PGNImportFailed
+				job.Error = err.Error()
+			}
+		})
+		log.Info().Str("did", job.DID).Str("job", job.ID).Int("imported", job.Imported).Int("skipped", job.Skipped).Err(err).Msg("Finished PGN import")
+	}
+	skip := func(number int, game *importer.Game, reason string) {
+		p.update(job, func(job *PGNImportJob) {
+			job.Skipped++
+			if len(job.SkippedGames) < maxReportedSkips {
+				skipped := SkippedGame{Number: number, Reason: reason}
+				if game != nil {
+					skipped.ID, skipped.URL = game.ID, game.URL
+				}
+				job.SkippedGames = append(job.SkippedGames, skipped)
+			}
+		})
+	}
+
+	file, err := os.Open(task.path)
+	if err != nil {
+		finish(fmt.Errorf("failed to open upload: %w", err))
+		return
+	}
+	defer file.Close()
+
+	existing, err := task.client.ImportedGames(ctx, job.DID)
+	if err != nil {
+		finish(fmt.Errorf("failed to list imported games: %w", err))
+		return
+	}
+
+	var batch []atproto.GameImport
+	var batchGames []*importer.Game
+	var batchNumbers []int
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if _, err := task.client.ImportGames(ctx, batch); err != nil {
+			log.Error().Err(err).Str("did", job.DID).Str("job", job.ID).Msg("Failed to record imported games")
+			for i, game := range batchGames {
+				delete(existing, game.Site+":"+game.ID)
+				skip(batchNumbers[i], game, "failed to record the game")
+			}
+		} else {
+			p.update(job, func(job *PGNImportJob) { job.Imported += len(batch) })
+		}
+		batch, batchGames, batchNumbers = nil, nil, nil
+	}
+
+	counter := &countingReader{r: file}
+	reader := chess.NewPGNReader(counter)
+	for number := 1; ; number++ {
+		if ctx.Err() != nil {
+			finish(ctx.Err())
+			return
+		}
+		pgn, err := reader.Next()
+		p.update(job, func(job *PGNImportJob) {
+			if err != io.EOF {
+				job.Read = number
+			}
+			if task.size > 0 {
+				job.Progress = min(float64(counter.n)/float64(task.size), 1)
+			}
+		})
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, chess.ErrInvalidPGN) {
+			skip(number, nil, err.Error())
+			continue
+		}
+		if err != nil {
+			flush()
+			finish(fmt.Errorf("failed to read upload: %w", err))
+			return
+		}
+
+		game := importer.FromPGN(pgn)
+		if _, ok := existing[game.Site+":"+game.ID]; ok {
+			skip(number, game, "already imported")
+			continue
+		}
+		color := game.Side(task.username)
+		if task.color != "" {
+			color = task.color
+		}
+		if color == "" {
+			skip(number, game, task.username+" did not play in this game")
+			continue
+		}
+		converted, moves, err := game.Convert(job.DID, color)
+		if err != nil {
+			skip(number, game, err.Error())
+			continue
+		}
+
+		existing[game.Site+":"+game.ID] = ""
+		batch = append(batch, atproto.GameImport{Game: converted, Moves: moves})
+		batchGames = append(batchGames, game)
+		batchNumbers = append(batchNumbers, number)
+		if len(batch) >= pgnImportBatchGames {
+			flush()
+		}
+	}
+	flush()
+	p.update(job, func(job *PGNImportJob) { job.Progress = 1 })
+	finish(nil)
+}
+
+// ImportPGNHandler accepts a PGN file of any number of games as a
+// multipart/form-data upload, with the file in the "file" part and the
+// caller's name in the games in "username" (or their side in every game in
+// "color"). The games are imported in the background; the response is the
+// job, whose progress GetPGNImportHandler reports.
+func (s *Service) ImportPGNHandler(w http.ResponseWriter, r *http.Request) {
+	if s.pgnImports == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Importing PGN files is not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPGNUploadBytes)
+	parts, err := r.MultipartReader()
+	if err != nil {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Expected a multipart/form-data upload"))
+		return
+	}
+
+	// The file is spooled to disk so the import can outlive the request
+	var path, username, color string
+	var size int64
+	defer func() {
+		if path != "" {
+			os.Remove(path)
+		}
+	}()
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			apierror.Write(w, uploadError(err))
+			return
+		}
+		switch part.FormName() {
+		case "file":
+			if path != "" {
+				apierror.Write(w, apierror.ErrBadRequest.WithMessage("Only one file can be imported at a time"))
+				return
+			}
+			spool, err := os.CreateTemp("", "atchess-import-*.pgn")
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to create file for PGN upload")
+				apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to store upload"))
+				return
+			}
+			path = spool.Name()
+			size, err = io.Copy(spool, part)
+			spool.Close()
+			if err != nil {
+				apierror.Write(w, uploadError(err))
+				return
+			}
+		case "username", "color":
+			value, err := io.ReadAll(io.LimitReader(part, 256))
+			if err != nil {
+				apierror.Write(w, uploadError(err))
+				return
+			}
+			if part.FormName() == "username" {
+				username = strings.TrimSpace(string(value))
+			} else {
+				color = strings.TrimSpace(string(value))
+			}
+		}
+	}
+
+	if path == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("file is required"))
+		return
+	}
+	if color != "" && color != "white" && color != "black" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("color must be white or black"))
+		return
+	}
+	if username == "" && color == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("username or color is required to know which side is yours"))
+		return
+	}
+
+	job, err := s.pgnImports.Enqueue(s.clientFor(r), path, size, username, color)
+	switch {
+	case errors.Is(err, ErrImportInProgress):
+		apierror.Write(w, apierror.ErrConflict.WithMessage("You already have an import in progress"))
+		return
+	case errors.Is(err, ErrImportQueueFull):
+		apierror.Write(w, apierror.ErrUnavailable.WithMessage("Too many imports are waiting; try again later"))
+		return
+	case err != nil:
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to queue import"))
+		return
+	}
+	path = "" // the job removes it
+
+	log.Info().Str("did", did).Str("job", job.ID).Int64("bytes", size).Msg("Queued PGN import")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// GetPGNImportHandler reports the progress of one of the caller's PGN imports
+func (s *Service) GetPGNImportHandler(w http.ResponseWriter, r *http.Request) {
+	if s.pgnImports == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Importing PGN files is not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	job, ok := s.pgnImports.Job(mux.Vars(r)["id"])
+	if !ok || job.DID != did {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Import not found"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// uploadError describes a failure reading an upload
+func uploadError(err error) *apierror.Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apierror.ErrBadRequest.WithMessage(fmt.Sprintf("PGN files can be at most %d MB", maxPGNUploadBytes>>20))
+	}
+	return apierror.ErrBadRequest.WithMessage("Failed to read upload")
+}
+
+func newPGNImportID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,127 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+const uploadedPGN = `[Site "https://lichess.org/abcd1234"]
+[White "alice"]
+[Black "bob"]
+[Result "1-0"]
+
+1. e4 e5 2. Bc4 Nc6 3. Qh5 Nf6 4. Qxf7# 1-0
+
+[Event "Club night"]
+[White "carol"]
+[Black "Alice"]
+[Result "1/2-1/2"]
+
+1. d4 d5 { and a draw was agreed } 1/2-1/2
+
+[White "alice"]
+[Black "dave"]
+[Result "0-1"]
+
+1. e4 (1. d4 0-1
+
+[Site "https://lichess.org/abcd1234"]
+[White "alice"]
+[Black "bob"]
+[Result "1-0"]
+
+1. e4 e5 2. Bc4 Nc6 3. Qh5 Nf6 4. Qxf7# 1-0
+`
+
+func uploadPGN(s *Service, token string, fields map[string]string, file string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		_ = form.WriteField(name, value)
+	}
+	if file != "" {
+		part, _ := form.CreateFormFile("file", "games.pgn")
+		_, _ = part.Write([]byte(file))
+	}
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/api/import/pgn", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set(SessionHeader, token)
+	w := httptest.NewRecorder()
+	s.SessionMiddleware(http.HandlerFunc(s.ImportPGNHandler)).ServeHTTP(w, req)
+	return w
+}
+
+func TestImportPGNFileInTheBackground(t *testing.T) {
+	pds := newFakePDS(t, "did:plc:service")
+	service := newServiceForPDS(t, pds)
+	imports := NewPGNImports()
+	service.SetPGNImports(imports)
+	pds.did = testWhiteDID
+	client, err := atproto.NewClient(pds.URL, "alice", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	token, _ := service.Sessions().Create(client)
+
+	if w := uploadPGN(service, token, map[string]string{"username": "alice"}, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an upload without a file to be refused, got %d", w.Code)
+	}
+
+	w := uploadPGN(service, token, map[string]string{"username": "alice"}, uploadedPGN)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected the upload to be queued, got %d: %s", w.Code, w.Body.String())
+	}
+	var job PGNImportJob
+	_ = json.Unmarshal(w.Body.Bytes(), &job)
+	if job.Status != PGNImportQueued {
+		t.Fatalf("Expected a queued job, got %+v", job)
+	}
+	if w := uploadPGN(service, token, map[string]string{"username": "alice"}, uploadedPGN); w.Code != http.StatusConflict {
+		t.Errorf("Expected a second upload to wait for the first, got %d", w.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go imports.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.FinishedAt == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/api/import/pgn/"+job.ID, nil), map[string]string{"id": job.ID})
+		req.Header.Set(SessionHeader, token)
+		w = httptest.NewRecorder()
+		service.SessionMiddleware(http.HandlerFunc(service.GetPGNImportHandler)).ServeHTTP(w, req)
+		_ = json.Unmarshal(w.Body.Bytes(), &job)
+	}
+	if job.Status != PGNImportDone || job.Read != 4 || job.Imported != 2 || job.Skipped != 2 || job.Progress != 1 {
+		t.Fatalf("Expected two games imported and two skipped, got %s", w.Body.String())
+	}
+	if job.SkippedGames[0].Number != 3 || job.SkippedGames[1].Reason != "already imported" {
+		t.Errorf("Expected the broken game and the repeat skipped, got %+v", job.SkippedGames)
+	}
+
+	games := pds.collection(testWhiteDID, "app.atchess.game")
+	if len(games) != 2 {
+		t.Fatalf("Expected two games recorded, got %d", len(games))
+	}
+	club := pds.get(games[1])
+	source, _ := club["source"].(map[string]interface{})
+	if club["black"] != testWhiteDID || club["white"] != "did:web:pgn.invalid" || source["site"] != "pgn" || !strings.Contains(club["pgn"].(string), `[Event "Club night"]`) {
+		t.Errorf("Expected the club game recorded from the file, got %v", club)
+	}
+	if moves := pds.collection(testWhiteDID, "app.atchess.move"); len(moves) != 9 {
+		t.Errorf("Expected the moves of both games, got %d", len(moves))
+	}
+}
@@ -0,0 +1,228 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+	// Timezone names are validated against the embedded database so they
+	// behave the same whatever the host has installed
+	_ "time/tzdata"
+
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/lexicon"
+	"github.com/rs/zerolog/log"
+)
+
+// preferencesTTL is how long preferences read from a player's repository are
+// trusted before being read again, in case another client changed them
+const preferencesTTL = 10 * time.Minute
+
+// maxPreferenceNameLength bounds board theme and piece set names
+const maxPreferenceNameLength = 32
+
+// Preferences are a player's settings. They're stored as the
+// app.atchess.preferences record in the player's own repository, so they
+// follow the player to other clients and instances.
+type Preferences struct {
+	Timezone   string `json:"timezone"`             // IANA name such as "Europe/Paris"; empty for UTC
+	BoardTheme string `json:"boardTheme,omitempty"` // e.g. "green"; empty for the client's default
+	PieceSet   string `json:"pieceSet,omitempty"`   // e.g. "solid"; empty for the client's default
+	// AutoQueen promotes pawns to queens when a move doesn't say what to
+	// promote to
+	AutoQueen bool `json:"autoQueen"`
+	// DefaultTimeControl is used for seeks and matchmaking when the player
+	// doesn't choose one
+	DefaultTimeControl *chess.TimeControl          `json:"defaultTimeControl,omitempty"`
+	EmailNotifications atproto.EmailNotifications `json:"emailNotifications"`
+	UpdatedAt          string                     `json:"updatedAt,omitempty"`
+}
+
+// preferencesFromRecord converts a preferences record to the API's shape
+func preferencesFromRecord(record *atproto.PreferencesRecord) Preferences {
+	return Preferences{
+		Timezone:           record.Timezone,
+		BoardTheme:         record.BoardTheme,
+		PieceSet:           record.PieceSet,
+		AutoQueen:          record.AutoQueen,
+		DefaultTimeControl: record.DefaultTimeControl,
+		EmailNotifications: record.EmailNotifications,
+		UpdatedAt:          record.UpdatedAt,
+	}
+}
+
+// record converts the preferences to the record written to the repository
+func (p Preferences) record() *atproto.PreferencesRecord {
+	return &atproto.PreferencesRecord{
+		Timezone:           p.Timezone,
+		BoardTheme:         p.BoardTheme,
+		PieceSet:           p.PieceSet,
+		AutoQueen:          p.AutoQueen,
+		DefaultTimeControl: p.DefaultTimeControl,
+		EmailNotifications: p.EmailNotifications,
+	}
+}
+
+// validate checks settings the lexicon can't
+func (p Preferences) validate() error {
+	if p.Timezone != "" {
+		// "Local" would be the server's timezone, not the player's
+		if _, err := time.LoadLocation(p.Timezone); err != nil || p.Timezone == "Local" {
+			return errors.New("Unknown timezone")
+		}
+	}
+	if len(p.BoardTheme) > maxPreferenceNameLength || len(p.PieceSet) > maxPreferenceNameLength {
+		return errors.New("boardTheme and pieceSet must be at most 32 characters")
+	}
+	if tc := p.DefaultTimeControl; tc != nil {
+		if !timeControlTypes[tc.Type] {
+			return errors.New("defaultTimeControl type must be correspondence, rapid, blitz or bullet")
+		}
+		if tc.Type == "correspondence" && (tc.DaysPerMove < 1 || tc.DaysPerMove > 7) {
+			return errors.New("defaultTimeControl daysPerMove must be between 1 and 7")
+		}
+		if tc.Type != "correspondence" && (tc.Initial <= 0 || tc.Increment < 0) {
+			return errors.New("defaultTimeControl needs a positive initial time")
+		}
+	}
+	return nil
+}
+
+// Location returns the preferred timezone, falling back to UTC
+func (p Preferences) Location() *time.Location {
+	if p.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// cachedPreferences are preferences as of when they were read
+type cachedPreferences struct {
+	prefs   Preferences
+	fetched time.Time
+}
+
+// PreferenceStore caches players' preferences, keyed by DID, so handlers that
+// only need a timezone don't read the record on every request
+type PreferenceStore struct {
+	prefs map[string]cachedPreferences
+	mu    sync.RWMutex
+}
+
+// NewPreferenceStore creates an empty preference store
+func NewPreferenceStore() *PreferenceStore {
+	return &PreferenceStore{prefs: make(map[string]cachedPreferences)}
+}
+
+// Get returns a player's cached preferences, if they're fresh
+func (p *PreferenceStore) Get(did string) (Preferences, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	cached, ok := p.prefs[did]
+	if !ok || time.Since(cached.fetched) > preferencesTTL {
+		return Preferences{}, false
+	}
+	return cached.prefs, true
+}
+
+// Put caches a player's preferences
+func (p *PreferenceStore) Put(did string, prefs Preferences) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prefs[did] = cachedPreferences{prefs: prefs, fetched: time.Now()}
+}
+
+// readPreferences reads a player's preferences record, returning the defaults
+// if they've never saved any, and caches the result
+func (s *Service) readPreferences(ctx context.Context, client *atproto.Client) (Preferences, error) {
+	did := client.GetDID()
+	record, err := client.GetPreferences(ctx, did)
+	if errors.Is(err, atproto.ErrNoPreferences) {
+		record, err = &atproto.PreferencesRecord{}, nil
+	}
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	prefs := preferencesFromRecord(record)
+	s.preferences.Put(did, prefs)
+	return prefs, nil
+}
+
+// loadPreferences returns a player's preferences from the cache, reading
+// their record if needed. If the record can't be read the defaults are used.
+func (s *Service) loadPreferences(ctx context.Context, client *atproto.Client) Preferences {
+	if prefs, ok := s.preferences.Get(client.GetDID()); ok {
+		return prefs
+	}
+	prefs, err := s.readPreferences(ctx, client)
+	if err != nil {
+		log.Warn().Err(err).Str("did", client.GetDID()).Msg("Failed to read preferences, using defaults")
+	}
+	return prefs
+}
+
+// GetPreferencesHandler returns the current player's preferences, as saved in
+// their repository
+func (s *Service) GetPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.Context().Value(sessionTokenKey).(string); !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	prefs, err := s.readPreferences(r.Context(), s.clientFor(r))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read preferences record")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to read preferences"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(prefs)
+}
+
+// SavePreferencesHandler replaces the current player's preferences, writing
+// them to the app.atchess.preferences record in their repository
+func (s *Service) SavePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.Context().Value(sessionTokenKey).(string); !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	var prefs Preferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if err := prefs.validate(); err != nil {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+		return
+	}
+
+	client := s.clientFor(r)
+	record := prefs.record()
+	if err := client.PutPreferences(r.Context(), record); err != nil {
+		if errors.Is(err, lexicon.ErrInvalidRecord) {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+			return
+		}
+		log.Error().Err(err).Msg("Failed to save preferences record")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to save preferences"))
+		return
+	}
+	prefs.UpdatedAt = record.UpdatedAt
+	s.preferences.Put(client.GetDID(), prefs)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(prefs)
+}
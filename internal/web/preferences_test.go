@@ -0,0 +1,121 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// savePreferences saves preferences as the player signed in to pds
+func savePreferences(t *testing.T, s *Service, pds *fakePDS, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	return sessionRequest(t, s, pds, s.SavePreferencesHandler, "PUT", "/api/preferences", nil, json.RawMessage(body), true)
+}
+
+func TestPreferencesFollowThePlayer(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	body := `{
+		"timezone": "Europe/Paris",
+		"boardTheme": "green",
+		"pieceSet": "solid",
+		"autoQueen": true,
+		"defaultTimeControl": {"type": "correspondence", "daysPerMove": 3},
+		"emailNotifications": {"challenges": true}
+	}`
+	if w := savePreferences(t, service, pds, body); w.Code != http.StatusOK {
+		t.Fatalf("Expected preferences to be saved, got %d: %s", w.Code, w.Body.String())
+	}
+	record := pds.get("at://" + testWhiteDID + "/app.atchess.preferences/self")
+	if record["boardTheme"] != "green" || record["autoQueen"] != true || record["updatedAt"] == nil {
+		t.Fatalf("Expected the preferences in the player's repository, got %v", record)
+	}
+
+	// Another instance, with nothing cached, reads them from the repository
+	other := newServiceForPDS(t, pds)
+	w := sessionRequest(t, other, pds, other.GetPreferencesHandler, "GET", "/api/preferences", nil, nil, true)
+	var prefs Preferences
+	_ = json.Unmarshal(w.Body.Bytes(), &prefs)
+	if w.Code != http.StatusOK || prefs.Timezone != "Europe/Paris" || prefs.PieceSet != "solid" || !prefs.AutoQueen ||
+		prefs.DefaultTimeControl == nil || prefs.DefaultTimeControl.DaysPerMove != 3 || !prefs.EmailNotifications.Challenges {
+		t.Errorf("Expected the saved preferences, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGetPreferencesDefaults(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	w := sessionRequest(t, service, pds, service.GetPreferencesHandler, "GET", "/api/preferences", nil, nil, true)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"autoQueen":false`) {
+		t.Errorf("Expected default preferences for a player who hasn't saved any, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPreferencesNeedASession(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	if w := sessionRequest(t, service, pds, service.SavePreferencesHandler, "PUT", "/api/preferences", nil, Preferences{AutoQueen: true}, false); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 saving preferences without a session, got %d", w.Code)
+	}
+	if w := sessionRequest(t, service, pds, service.GetPreferencesHandler, "GET", "/api/preferences", nil, nil, false); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 reading preferences without a session, got %d", w.Code)
+	}
+	if records := pds.collection(testWhiteDID, "app.atchess.preferences"); len(records) != 0 {
+		t.Errorf("Expected nothing written to the service account's repository, got %v", records)
+	}
+}
+
+func TestSavePreferencesValidation(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	for _, body := range []string{
+		`{"defaultTimeControl": {"type": "hourglass"}}`,
+		`{"defaultTimeControl": {"type": "correspondence", "daysPerMove": 14}}`,
+		`{"defaultTimeControl": {"type": "blitz"}}`,
+		`{"boardTheme": "` + strings.Repeat("x", 33) + `"}`,
+	} {
+		if w := savePreferences(t, service, pds, body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+	if records := pds.collection(testWhiteDID, "app.atchess.preferences"); len(records) != 0 {
+		t.Errorf("Expected nothing to be written, got %v", records)
+	}
+}
+
+func TestAutoQueenPromotion(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	gameID := seedGame(pds, "8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "active")
+
+	move := map[string]interface{}{"from": "e7", "to": "e8", "game_id": gameID}
+	if w := postMove(service, move); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected a promotion without a piece to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := savePreferences(t, service, pds, `{"autoQueen": true}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected preferences to be saved, got %d", w.Code)
+	}
+	w := postMove(service, move)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"san":"e8=Q`) {
+		t.Errorf("Expected the pawn to be queened, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSeekUsesDefaultTimeControl(t *testing.T) {
+	service, pds, _ := newSeekService(t)
+	if w := savePreferences(t, service, pds, `{"defaultTimeControl": {"type": "blitz", "initial": 300, "increment": 2}}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected preferences to be saved, got %d: %s", w.Code, w.Body.String())
+	}
+
+	seek := createSeek(t, service, map[string]interface{}{"color": "white"})
+	if seek.TimeControl == nil || seek.TimeControl.Type != "blitz" || seek.TimeControl.Initial != 300 {
+		t.Errorf("Expected the default time control, got %+v", seek.TimeControl)
+	}
+}
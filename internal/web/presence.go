@@ -0,0 +1,69 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+)
+
+// PresenceType is the frame sent to a game's channel when one of its players
+// connects to or disconnects from it
+const PresenceType = "presence"
+
+// PlayerPresence says whether a player is connected, and if not, when they
+// last were
+type PlayerPresence struct {
+	DID      string     `json:"did"`
+	Online   bool       `json:"online"`
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+}
+
+// signedIn reports whether a client was opened with a player's session
+func (c *Client) signedIn() bool {
+	return c.userID != "" && c.userID != "anonymous"
+}
+
+// Presence returns whether a player has any connection open, on any channel
+// of this replica, or when they closed their last one
+func (h *Hub) Presence(did string) PlayerPresence {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.playerPresence(did)
+}
+
+// playerPresence is Presence for callers holding h.mu
+func (h *Hub) playerPresence(did string) PlayerPresence {
+	presence := PlayerPresence{DID: did, Online: h.online[did] > 0}
+	if seen, ok := h.lastSeen[did]; ok && !presence.Online {
+		presence.LastSeen = &seen
+	}
+	return presence
+}
+
+// announcePresence tells a game's channel that a player came or went; the
+// caller must hold h.mu, so the update is sent from its own goroutine
+func (h *Hub) announcePresence(gameID, did string) {
+	update := GameUpdate{GameID: gameID, Type: PresenceType, Data: h.playerPresence(did)}
+	go h.BroadcastGameUpdate(update)
+}
+
+// PlayerPresenceHandler returns whether a player is online, or when they were
+// last seen
+func (s *Service) PlayerPresenceHandler(w http.ResponseWriter, r *http.Request) {
+	if s.hub == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Live updates are not enabled"))
+		return
+	}
+
+	did := mux.Vars(r)["did"]
+	if !didPattern.MatchString(did) {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid DID"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.hub.Presence(did))
+}
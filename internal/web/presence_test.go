@@ -0,0 +1,79 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+func presenceOf(t *testing.T, s *Service, did string) (int, PlayerPresence) {
+	t.Helper()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/players/"+did+"/presence", nil), map[string]string{"did": did})
+	w := httptest.NewRecorder()
+	s.PlayerPresenceHandler(w, req)
+	var presence PlayerPresence
+	_ = json.Unmarshal(w.Body.Bytes(), &presence)
+	return w.Code, presence
+}
+
+// readPresence reads presence frames until one about did arrives
+func readPresence(t *testing.T, conn *websocket.Conn, did string) connectionFrame {
+	t.Helper()
+	for {
+		if frame := readFrame(t, conn, PresenceType); frame.Data["did"] == did {
+			return frame
+		}
+	}
+}
+
+func TestPresenceIsAnnouncedToTheGame(t *testing.T) {
+	game := newLiveGame(t, DisconnectPolicyOff, time.Hour)
+	white := game.connect(t, testWhiteDID)
+	black := game.connect(t, testBlackDID)
+
+	frame := readPresence(t, white, testBlackDID)
+	if frame.Data["online"] != true {
+		t.Errorf("Expected black to be announced online, got %+v", frame.Data)
+	}
+
+	black.Close()
+	frame = readPresence(t, white, testBlackDID)
+	if frame.Data["online"] != false || frame.Data["lastSeen"] == nil {
+		t.Errorf("Expected black to be announced offline with a last seen time, got %+v", frame.Data)
+	}
+}
+
+func TestPlayerPresenceHandler(t *testing.T) {
+	game := newLiveGame(t, DisconnectPolicyOff, time.Hour)
+	game.service.SetHub(game.hub)
+
+	if code, presence := presenceOf(t, game.service, testBlackDID); code != http.StatusOK || presence.Online || presence.LastSeen != nil {
+		t.Errorf("Expected a player who never connected to be offline with no last seen time, got %d %+v", code, presence)
+	}
+
+	black := game.connect(t, testBlackDID)
+	if _, presence := presenceOf(t, game.service, testBlackDID); !presence.Online {
+		t.Errorf("Expected black to be online, got %+v", presence)
+	}
+
+	black.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for game.hub.IsOnline(game.gameID, testBlackDID) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected black to go offline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, presence := presenceOf(t, game.service, testBlackDID); presence.Online || presence.LastSeen == nil {
+		t.Errorf("Expected black to be offline with a last seen time, got %+v", presence)
+	}
+
+	if code, _ := presenceOf(t, game.service, "nobody"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid DID, got %d", code)
+	}
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/rating"
+	"github.com/rs/zerolog/log"
+)
+
+// Games listed on a profile
+const (
+	profileActiveGames = 20
+	profileRecentGames = 10
+)
+
+// PlayerRecord is a player's results in finished games
+type PlayerRecord struct {
+	Games  int `json:"games"`
+	Wins   int `json:"wins"`
+	Losses int `json:"losses"`
+	Draws  int `json:"draws"`
+}
+
+// PlayerProfile gathers what a profile page shows about a player from their
+// Bluesky profile, their rating and the game index
+type PlayerProfile struct {
+	DID         string               `json:"did"`
+	Handle      string               `json:"handle,omitempty"`
+	DisplayName string               `json:"displayName,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Avatar      string               `json:"avatar,omitempty"` // URL of the avatar image
+	Rating      *rating.PlayerRating `json:"rating,omitempty"` // when ratings are enabled
+	Record      PlayerRecord         `json:"record"`
+	ActiveGames []GameIndex          `json:"activeGames"`
+	RecentGames []GameIndex          `json:"recentGames"` // finished games, most recent first
+}
+
+// PlayerProfile builds a player's profile. Parts that can't be loaded are
+// logged and left out, so one slow source doesn't hide the rest.
+func (s *Service) PlayerProfile(ctx context.Context, did string) *PlayerProfile {
+	profile := &PlayerProfile{DID: did, ActiveGames: []GameIndex{}, RecentGames: []GameIndex{}}
+
+	if handle, err := s.client.LookupHandle(ctx, did); err != nil {
+		log.Warn().Err(err).Str("did", did).Msg("Failed to look up handle for profile")
+	} else {
+		profile.Handle = handle
+	}
+
+	bsky, err := s.client.GetProfile(ctx, did)
+	switch {
+	case errors.Is(err, atproto.ErrNoProfile):
+	case err != nil:
+		log.Warn().Err(err).Str("did", did).Msg("Failed to load Bluesky profile")
+	default:
+		profile.DisplayName, profile.Description, profile.Avatar = bsky.DisplayName, bsky.Description, bsky.Avatar
+	}
+
+	if s.ratings != nil {
+		profile.Rating = s.ratings.Get(did)
+		profile.Record = PlayerRecord{
+			Games:  profile.Rating.Games,
+			Wins:   profile.Rating.Wins,
+			Losses: profile.Rating.Losses,
+			Draws:  profile.Rating.Draws,
+		}
+	}
+
+	if s.gameIndex != nil {
+		profile.ActiveGames = s.profileGames(ctx, index.Query{Player: did, Status: string(chess.StatusActive), Limit: profileActiveGames})
+		profile.RecentGames = s.profileGames(ctx, index.Query{Player: did, Finished: true, Limit: profileRecentGames})
+	}
+	return profile
+}
+
+// profileGames lists the indexed games matching query
+func (s *Service) profileGames(ctx context.Context, query index.Query) []GameIndex {
+	games := []GameIndex{}
+	page, err := s.gameIndex.ListGames(ctx, query)
+	if err != nil {
+		log.Warn().Err(err).Str("did", query.Player).Msg("Failed to list games for profile")
+		return games
+	}
+	for _, game := range page.Games {
+		games = append(games, indexedGame(game))
+	}
+	return games
+}
+
+// GetPlayerProfileHandler returns the profile of the player named by DID or handle
+func (s *Service) GetPlayerProfileHandler(w http.ResponseWriter, r *http.Request) {
+	actor := mux.Vars(r)["didOrHandle"]
+	did, ok := s.resolvePlayer(w, r, actor)
+	if !ok {
+		return
+	}
+
+	profile := s.PlayerProfile(r.Context(), did)
+	if profile.Handle == "" && !strings.HasPrefix(actor, "did:") {
+		// The handle resolved, so it's the one to show
+		profile.Handle = actor
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(profile)
+}
+
+// resolvePlayer resolves a DID or handle from the URL to a DID, writing the
+// error response if it can't
+func (s *Service) resolvePlayer(w http.ResponseWriter, r *http.Request, actor string) (string, bool) {
+	did, err := s.client.ResolveHandle(r.Context(), actor)
+	if errors.Is(err, atproto.ErrHandleNotFound) {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Player not found"))
+		return "", false
+	}
+	if err != nil {
+		log.Error().Err(err).Str("actor", actor).Msg("Failed to resolve player")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to resolve player"))
+		return "", false
+	}
+	if !didPattern.MatchString(did) {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid DID or handle"))
+		return "", false
+	}
+	return did, true
+}
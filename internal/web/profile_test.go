@@ -0,0 +1,75 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/rating"
+)
+
+func TestPlayerProfileAggregatesProfileRatingAndGames(t *testing.T) {
+	pds := newFakePDS(t, "did:plc:service")
+	pds.put("at://"+testWhiteDID+"/app.bsky.actor.profile/self", map[string]interface{}{
+		"displayName": "White Player",
+		"avatar": map[string]interface{}{
+			"$type":    "blob",
+			"ref":      map[string]string{"$link": "bafyavatar"},
+			"mimeType": "image/jpeg",
+		},
+	})
+	service := newServiceForPDS(t, pds)
+
+	ctx := context.Background()
+	ratings := rating.NewRatings(nil)
+	service.SetRatings(ratings)
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	indexer.OnGameFinished(func(ctx context.Context, game *index.Game) {
+		ratings.RecordGame(ctx, game)
+	})
+	service.SetGameIndex(indexer)
+	for rkey, status := range map[string]string{"won": "white_won", "drawn": "draw", "live": "active"} {
+		_ = indexer.Apply(ctx, "create", testWhiteDID, "app.atchess.game/"+rkey, map[string]interface{}{
+			"white":     testWhiteDID,
+			"black":     testBlackDID,
+			"status":    status,
+			"fen":       startFEN,
+			"createdAt": "2024-01-01T00:00:00Z",
+		})
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/players/"+testWhiteDID, nil), map[string]string{"didOrHandle": testWhiteDID})
+	w := httptest.NewRecorder()
+	service.GetPlayerProfileHandler(w, req)
+
+	var profile PlayerProfile
+	if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+		t.Fatalf("Failed to parse profile: %v", err)
+	}
+	if w.Code != http.StatusOK || profile.Handle != "white.test" || profile.DisplayName != "White Player" {
+		t.Errorf("Expected the Bluesky profile, got %d %s", w.Code, w.Body.String())
+	}
+	if !strings.HasSuffix(profile.Avatar, "/xrpc/com.atproto.sync.getBlob?did="+strings.ReplaceAll(testWhiteDID, ":", "%3A")+"&cid=bafyavatar") {
+		t.Errorf("Expected an avatar blob URL, got %q", profile.Avatar)
+	}
+	if profile.Rating == nil || profile.Record != (PlayerRecord{Games: 2, Wins: 1, Draws: 1}) {
+		t.Errorf("Expected a win and a draw, got %+v", profile.Record)
+	}
+	if len(profile.ActiveGames) != 1 || len(profile.RecentGames) != 2 {
+		t.Errorf("Expected one active and two finished games, got %d and %d", len(profile.ActiveGames), len(profile.RecentGames))
+	}
+
+	// A player without a Bluesky profile, ratings or index still has a profile
+	bare := newServiceForPDS(t, pds)
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/api/players/"+testBlackDID, nil), map[string]string{"didOrHandle": testBlackDID})
+	w = httptest.NewRecorder()
+	bare.GetPlayerProfileHandler(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"activeGames":[]`) {
+		t.Errorf("Expected an empty profile, got %d %s", w.Code, w.Body.String())
+	}
+}
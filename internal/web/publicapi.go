@@ -0,0 +1,290 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/rs/zerolog/log"
+)
+
+// APIKeyHeader carries an optional key for the higher public API rate limit
+const APIKeyHeader = "X-API-Key"
+
+const (
+	// explorerSampleSize is how many recent finished games the explorer aggregates
+	explorerSampleSize = 10000
+	// maxExplorerDepth bounds the move sequence accepted by the explorer
+	maxExplorerDepth = 30
+)
+
+var sanPattern = regexp.MustCompile(`^([KQRBN]?[a-h]?[1-8]?x?[a-h][1-8](=[QRBN])?|O-O(-O)?)[+#]?$`)
+
+// cachedResponse is a stored public API response body
+type cachedResponse struct {
+	body    []byte
+	expires time.Time
+}
+
+// responseCache holds successful public API responses for a fixed time
+type responseCache struct {
+	entries map[string]*cachedResponse
+	ttl     time.Duration
+	mu      sync.Mutex
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{entries: make(map[string]*cachedResponse), ttl: ttl}
+}
+
+func (c *responseCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.body, true
+}
+
+func (c *responseCache) put(key string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = &cachedResponse{body: body, expires: now.Add(c.ttl)}
+}
+
+// capturingWriter records a response so it can be cached
+type capturingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *capturingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// PublicAPIMiddleware rate limits and caches the unauthenticated public API.
+// Clients are limited by IP address unless they send a configured API key.
+func (s *Service) PublicAPIMiddleware() mux.MiddlewareFunc {
+	cfg := s.config.PublicAPI
+	anonymousLimit := cfg.RequestsPerMinute
+	if anonymousLimit <= 0 {
+		anonymousLimit = 30
+	}
+	keyLimit := cfg.KeyRequestsPerMinute
+	if keyLimit <= 0 {
+		keyLimit = 600
+	}
+	ttl := cfg.CacheTTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+
+	keys := make(map[string]bool)
+	for _, key := range cfg.APIKeys {
+		keys[key] = true
+	}
+	limiter := newRateLimiter()
+	cache := newResponseCache(ttl)
+	proxies := parseTrustedProxies(s.config.Server.TrustedProxies)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				apierror.Write(w, apierror.ErrMethodNotAllowed.WithMessage("The public API is read-only"))
+				return
+			}
+
+			client, limit := "ip:"+proxies.clientIP(r), anonymousLimit
+			if key := r.Header.Get(APIKeyHeader); key != "" {
+				if !keys[key] {
+					apierror.Write(w, apierror.ErrUnauthorized.WithMessage("Unknown API key"))
+					return
+				}
+				client, limit = "key:"+key, keyLimit
+			}
+
+			allowed, remaining, wait := limiter.Allow(client, limit)
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				apierror.Write(w, apierror.ErrRateLimited)
+				return
+			}
+
+			w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(ttl.Seconds())))
+			cacheKey := r.URL.Path + "?" + r.URL.Query().Encode()
+			if body, ok := cache.get(cacheKey); ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Cache", "HIT")
+				_, _ = w.Write(body)
+				return
+			}
+
+			w.Header().Set("X-Cache", "MISS")
+			capture := &capturingWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(capture, r)
+			if capture.status == http.StatusOK {
+				cache.put(cacheKey, capture.body.Bytes())
+			}
+		})
+	}
+}
+
+// PublicGame is a finished game as exposed by the public API
+type PublicGame struct {
+	URI         string    `json:"uri"`
+	White       string    `json:"white"`
+	Black       string    `json:"black"`
+	Status      string    `json:"status"`
+	Result      string    `json:"result"`
+	TimeControl string    `json:"timeControl,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Moves       []string  `json:"moves"` // SAN
+	ECO         string    `json:"eco,omitempty"`
+	Opening     string    `json:"opening,omitempty"`
+	// Extensions is metadata other apps attached to the game
+	Extensions chess.Extensions `json:"extensions,omitempty"`
+}
+
+// PublicGamesHandler lists finished games with their moves, filtered by
+// player, status, timeControl and extension namespace and paged with limit
+// and cursor
+func (s *Service) PublicGamesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.gameIndex == nil {
+		apierror.Write(w, apierror.ErrUnavailable.WithMessage("Game index is not enabled"))
+		return
+	}
+
+	params := r.URL.Query()
+	query := index.Query{
+		Player:      params.Get("player"),
+		Status:      params.Get("status"),
+		Finished:    true,
+		TimeControl: params.Get("timeControl"),
+		Extension:   params.Get("extension"),
+		Cursor:      params.Get("cursor"),
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			apierror.Write(w, apierror.ErrInvalidLimit)
+			return
+		}
+		query.Limit = n
+	}
+
+	page, err := s.gameIndex.ListGames(r.Context(), query)
+	if errors.Is(err, index.ErrInvalidCursor) {
+		apierror.Write(w, apierror.ErrInvalidCursor)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list games for public API")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list games"))
+		return
+	}
+
+	games := make([]PublicGame, 0, len(page.Games))
+	for _, game := range page.Games {
+		moves, err := s.gameIndex.ListMoves(r.Context(), game.URI)
+		if err != nil {
+			log.Error().Err(err).Str("game", game.URI).Msg("Failed to list moves for public API")
+			apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list games"))
+			return
+		}
+		sans := make([]string, 0, len(moves))
+		for _, move := range moves {
+			sans = append(sans, move.SAN)
+		}
+		games = append(games, PublicGame{
+			URI:         game.URI,
+			White:       game.White,
+			Black:       game.Black,
+			Status:      game.Status,
+			Result:      chess.ResultForStatus(chess.GameStatus(game.Status)),
+			TimeControl: game.TimeControl,
+			CreatedAt:   game.CreatedAt,
+			Moves:       sans,
+			ECO:         game.ECO,
+			Opening:     game.Opening,
+			Extensions:  game.Extensions,
+		})
+	}
+
+	response := map[string]interface{}{
+		"games": games,
+		"total": page.Total,
+	}
+	if page.Cursor != "" {
+		response["cursor"] = page.Cursor
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// PublicExplorerHandler aggregates results and next moves for the position
+// reached by moves, a comma separated list of SAN moves from the start
+func (s *Service) PublicExplorerHandler(w http.ResponseWriter, r *http.Request) {
+	if s.gameIndex == nil {
+		apierror.Write(w, apierror.ErrUnavailable.WithMessage("Game index is not enabled"))
+		return
+	}
+
+	moves := []string{}
+	if param := r.URL.Query().Get("moves"); param != "" {
+		moves = strings.Split(param, ",")
+	}
+	if len(moves) > maxExplorerDepth {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Too many moves"))
+		return
+	}
+	for _, san := range moves {
+		if !sanPattern.MatchString(san) {
+			apierror.Write(w, apierror.ErrInvalidMove.WithMessage("Invalid move: "+san))
+			return
+		}
+	}
+
+	exploration, err := s.gameIndex.Explore(r.Context(), moves, explorerSampleSize)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to explore games for public API")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to explore games"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(exploration)
+}
@@ -0,0 +1,141 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+func newPublicAPIRouter(t *testing.T, perMinute int) (*Service, *mux.Router) {
+	t.Helper()
+	service := newServiceForPDS(t, newFakePDS(t, testWhiteDID))
+	service.config.PublicAPI.RequestsPerMinute = perMinute
+	service.config.PublicAPI.KeyRequestsPerMinute = 5
+	service.config.PublicAPI.APIKeys = []string{"research"}
+
+	router := mux.NewRouter()
+	public := router.PathPrefix("/public/v1").Subrouter()
+	public.Use(service.PublicAPIMiddleware())
+	public.HandleFunc("/games", service.PublicGamesHandler).Methods("GET")
+	public.HandleFunc("/explorer", service.PublicExplorerHandler).Methods("GET")
+	return service, router
+}
+
+func publicGet(router http.Handler, target, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	if key != "" {
+		req.Header.Set(APIKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPublicAPIRateLimitsAndCaches(t *testing.T) {
+	service, router := newPublicAPIRouter(t, 2)
+	service.SetGameIndex(index.NewIndexer(index.NewMemoryStore()))
+
+	w := publicGet(router, "/public/v1/games", "")
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" || w.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Fatalf("Unexpected first response: %d %v", w.Code, w.Header())
+	}
+	w = publicGet(router, "/public/v1/games", "")
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected cached response, got %d %v", w.Code, w.Header())
+	}
+	w = publicGet(router, "/public/v1/games", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+
+	// An API key gets its own, larger allowance
+	for i := 0; i < 5; i++ {
+		if w = publicGet(router, "/public/v1/games", "research"); w.Code != http.StatusOK {
+			t.Fatalf("Expected keyed request %d to succeed, got %d", i, w.Code)
+		}
+	}
+	if w.Header().Get("X-RateLimit-Limit") != "5" {
+		t.Errorf("Expected keyed limit of 5, got %s", w.Header().Get("X-RateLimit-Limit"))
+	}
+	if w = publicGet(router, "/public/v1/games", "research"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected keyed client to be limited, got %d", w.Code)
+	}
+	if w = publicGet(router, "/public/v1/games", "guess"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unknown key, got %d", w.Code)
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	limiter := newRateLimiter()
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	if ok, _, _ := limiter.Allow("ip:1", 60); !ok {
+		t.Fatal("Expected first request to be allowed")
+	}
+	for i := 0; i < 59; i++ {
+		limiter.Allow("ip:1", 60)
+	}
+	ok, _, wait := limiter.Allow("ip:1", 60)
+	if ok || wait <= 0 || wait > time.Second {
+		t.Fatalf("Expected empty bucket with a short wait, got %v %v", ok, wait)
+	}
+	now = now.Add(time.Second)
+	if ok, _, _ := limiter.Allow("ip:1", 60); !ok {
+		t.Error("Expected bucket to refill over time")
+	}
+}
+
+func TestPublicGamesAndExplorer(t *testing.T) {
+	service, router := newPublicAPIRouter(t, 100)
+
+	if w := publicGet(router, "/public/v1/games", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without an index, got %d", w.Code)
+	}
+
+	ctx := context.Background()
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	service.SetGameIndex(indexer)
+	for _, game := range []struct{ rkey, status string }{{"done", "white_won"}, {"live", "active"}} {
+		_ = indexer.Apply(ctx, "create", testWhiteDID, "app.atchess.game/"+game.rkey, map[string]interface{}{
+			"white":     testWhiteDID,
+			"black":     testBlackDID,
+			"status":    game.status,
+			"fen":       startFEN,
+			"createdAt": "2024-01-01T00:00:00Z",
+		})
+		_ = indexer.Apply(ctx, "create", testWhiteDID, "app.atchess.move/"+game.rkey, map[string]interface{}{
+			"game":      map[string]interface{}{"uri": "at://" + testWhiteDID + "/app.atchess.game/" + game.rkey},
+			"player":    testWhiteDID,
+			"san":       "e4",
+			"fen":       startFEN,
+			"createdAt": "2024-01-01T00:01:00Z",
+		})
+	}
+
+	w := publicGet(router, "/public/v1/games", "")
+	var games struct {
+		Games []PublicGame `json:"games"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &games)
+	if len(games.Games) != 1 || games.Games[0].Result != "1-0" || len(games.Games[0].Moves) != 1 || games.Games[0].Moves[0] != "e4" {
+		t.Errorf("Expected only the finished game, got %s", w.Body.String())
+	}
+
+	w = publicGet(router, "/public/v1/explorer?moves=e4", "")
+	var exploration index.Exploration
+	_ = json.Unmarshal(w.Body.Bytes(), &exploration)
+	if w.Code != http.StatusOK || exploration.Games != 1 || exploration.WhiteWins != 1 {
+		t.Errorf("Unexpected exploration: %d %s", w.Code, w.Body.String())
+	}
+
+	if w = publicGet(router, "/public/v1/explorer?moves=e4,zz9", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid move, got %d", w.Code)
+	}
+}
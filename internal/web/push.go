@@ -0,0 +1,122 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/push"
+)
+
+// SetPush enables Web Push subscriptions
+func (s *Service) SetPush(notifier *push.Notifier) {
+	s.pushNotifier = notifier
+}
+
+// CreatePushSubscriptionRequest is the browser's PushSubscription as JSON
+type CreatePushSubscriptionRequest struct {
+	Endpoint string    `json:"endpoint"`
+	Keys     push.Keys `json:"keys"`
+}
+
+// PushSubscriptionsResponse lists the caller's push subscriptions
+type PushSubscriptionsResponse struct {
+	Subscriptions []*push.Subscription `json:"subscriptions"`
+}
+
+// PushKeyHandler returns the VAPID public key browsers subscribe with
+func (s *Service) PushKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if s.pushNotifier == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Push notifications are not enabled"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"publicKey": s.pushNotifier.PublicKey()})
+}
+
+// CreatePushSubscriptionHandler registers one of the caller's browsers for
+// notifications of moves and challenges
+func (s *Service) CreatePushSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	if s.pushNotifier == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Push notifications are not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	var req CreatePushSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+
+	sub, err := s.pushNotifier.Subscribe(r.Context(), did, req.Endpoint, req.Keys)
+	switch {
+	case errors.Is(err, push.ErrTooManySubscriptions):
+		apierror.Write(w, apierror.ErrConflict.WithMessage(err.Error()))
+		return
+	case errors.Is(err, push.ErrInvalidEndpoint), errors.Is(err, push.ErrPrivateEndpoint), errors.Is(err, push.ErrInvalidKeys):
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+		return
+	case err != nil:
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to register push subscription"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(sub)
+}
+
+// ListPushSubscriptionsHandler lists the caller's push subscriptions
+func (s *Service) ListPushSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.pushNotifier == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Push notifications are not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	subs, err := s.pushNotifier.List(r.Context(), did)
+	if err != nil {
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list push subscriptions"))
+		return
+	}
+	if subs == nil {
+		subs = []*push.Subscription{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(PushSubscriptionsResponse{Subscriptions: subs})
+}
+
+// DeletePushSubscriptionHandler unregisters one of the caller's browsers
+func (s *Service) DeletePushSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	if s.pushNotifier == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Push notifications are not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	removed, err := s.pushNotifier.Unsubscribe(r.Context(), did, mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to remove push subscription"))
+		return
+	}
+	if !removed {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Push subscription not found"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
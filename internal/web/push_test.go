@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/push"
+)
+
+// publicResolver resolves every host to a public address
+type publicResolver struct{}
+
+func (publicResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+}
+
+func TestPushSubscriptionHandlers(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	create := CreatePushSubscriptionRequest{
+		Endpoint: "https://push.example.com/send/abc",
+		Keys:     push.Keys{P256dh: "BOr4mBwYfpa6WnUmD7u3jkpyWXW4LiCkmQeLqNEuPkPL", Auth: "c2VjcmV0LWF1dGgtMTIzNA"},
+	}
+	w := sessionRequest(t, service, pds, service.CreatePushSubscriptionHandler, "POST", "/api/push/subscriptions", nil, create, true)
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "disabled" {
+		t.Errorf("Expected push to be disabled without a notifier, got %d", w.Code)
+	}
+
+	publicKey, privateKey, _ := push.GenerateKeys()
+	service.SetPush(push.NewNotifier(push.Options{PublicKey: publicKey, PrivateKey: privateKey, Resolver: publicResolver{}}))
+
+	w = httptest.NewRecorder()
+	service.PushKeyHandler(w, httptest.NewRequest("GET", "/api/push/vapid-key", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the public key, got %d", w.Code)
+	}
+	var key map[string]string
+	_ = json.Unmarshal(w.Body.Bytes(), &key)
+	if key["publicKey"] != publicKey {
+		t.Errorf("Expected public key %s, got %v", publicKey, key)
+	}
+
+	w = sessionRequest(t, service, pds, service.CreatePushSubscriptionHandler, "POST", "/api/push/subscriptions", nil, create, true)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var sub push.Subscription
+	_ = json.Unmarshal(w.Body.Bytes(), &sub)
+	if sub.ID == "" || sub.Owner != testWhiteDID || sub.Endpoint != create.Endpoint {
+		t.Errorf("Unexpected subscription %+v", sub)
+	}
+
+	bad := create
+	bad.Endpoint = "http://localhost:8080/"
+	w = sessionRequest(t, service, pds, service.CreatePushSubscriptionHandler, "POST", "/api/push/subscriptions", nil, bad, true)
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "bad_request" {
+		t.Errorf("Expected a non-https endpoint to be rejected, got %d", w.Code)
+	}
+	bad.Endpoint = "https://169.254.169.254/latest/meta-data"
+	w = sessionRequest(t, service, pds, service.CreatePushSubscriptionHandler, "POST", "/api/push/subscriptions", nil, bad, true)
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "bad_request" {
+		t.Errorf("Expected an endpoint on a private address to be rejected, got %d", w.Code)
+	}
+
+	w = sessionRequest(t, service, pds, service.ListPushSubscriptionsHandler, "GET", "/api/push/subscriptions", nil, nil, true)
+	var list PushSubscriptionsResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != http.StatusOK || len(list.Subscriptions) != 1 || list.Subscriptions[0].ID != sub.ID {
+		t.Errorf("Expected the subscription to be listed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	vars := map[string]string{"id": sub.ID}
+	if w = sessionRequest(t, service, pds, service.DeletePushSubscriptionHandler, "DELETE", "/api/push/subscriptions/"+sub.ID, vars, nil, true); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if w = sessionRequest(t, service, pds, service.DeletePushSubscriptionHandler, "DELETE", "/api/push/subscriptions/"+sub.ID, vars, nil, true); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a removed subscription to be gone, got %d", w.Code)
+	}
+}
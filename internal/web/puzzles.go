@@ -0,0 +1,120 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/puzzle"
+	"github.com/rs/zerolog/log"
+)
+
+// AttemptPuzzleRequest is a player's moves so far in a puzzle, in UCI or SAN
+type AttemptPuzzleRequest struct {
+	Moves []string `json:"moves"`
+}
+
+// SetPuzzles enables the puzzle endpoints
+func (s *Service) SetPuzzles(puzzles *puzzle.Puzzles) {
+	s.puzzles = puzzles
+}
+
+// PublishPuzzle writes a puzzle record to the instance's repository
+func (s *Service) PublishPuzzle(ctx context.Context, p *puzzle.Puzzle) (string, error) {
+	return s.client.CreatePuzzle(ctx, &atproto.PuzzleRecord{
+		CreatedAt: p.CreatedAt.Format(time.RFC3339),
+		Game:      p.GameURI,
+		Ply:       p.Ply,
+		FEN:       p.FEN,
+		Solution:  p.Solution,
+		Theme:     p.Theme,
+	})
+}
+
+// LoadPuzzles reads back the puzzles the instance published earlier
+func (s *Service) LoadPuzzles(ctx context.Context) error {
+	records, err := s.client.ListPuzzles(ctx)
+	if err != nil {
+		return err
+	}
+
+	puzzles := make([]*puzzle.Puzzle, 0, len(records))
+	for _, record := range records {
+		createdAt, _ := time.Parse(time.RFC3339, record.CreatedAt)
+		puzzles = append(puzzles, &puzzle.Puzzle{
+			ID:        record.URI[strings.LastIndex(record.URI, "/")+1:],
+			URI:       record.URI,
+			GameURI:   record.Game,
+			Ply:       record.Ply,
+			FEN:       record.FEN,
+			Theme:     record.Theme,
+			CreatedAt: createdAt,
+			Solution:  record.Solution,
+		})
+	}
+	s.puzzles.Load(puzzles)
+	return nil
+}
+
+// DailyPuzzleHandler returns the puzzle of the day, without its solution
+func (s *Service) DailyPuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	if s.puzzles == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Puzzles are not enabled"))
+		return
+	}
+
+	daily := s.puzzles.Daily(time.Now())
+	if daily == nil {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("No puzzles yet"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(daily)
+}
+
+// AttemptPuzzleHandler checks a player's moves against a puzzle's solution.
+// Moves can be sent one at a time, with the moves before them: while the
+// attempt is on track, the response has the defender's reply.
+func (s *Service) AttemptPuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	if s.puzzles == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Puzzles are not enabled"))
+		return
+	}
+
+	p := s.puzzles.Get(mux.Vars(r)["id"])
+	if p == nil {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Puzzle not found"))
+		return
+	}
+
+	var req AttemptPuzzleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if len(req.Moves) == 0 {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Missing moves"))
+		return
+	}
+
+	attempt, err := p.Check(req.Moves)
+	if errors.Is(err, puzzle.ErrIllegalMove) {
+		apierror.Write(w, apierror.ErrInvalidMove.WithMessage(err.Error()))
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("puzzle", p.ID).Msg("Failed to check puzzle attempt")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to check attempt"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(attempt)
+}
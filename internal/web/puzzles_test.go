@@ -0,0 +1,103 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/puzzle"
+)
+
+// gameMoves serves one game's moves to the puzzle miner
+type gameMoves []*index.Move
+
+func (g gameMoves) ListMoves(ctx context.Context, gameURI string) ([]*index.Move, error) {
+	return g, nil
+}
+
+func attemptPuzzle(s *Service, id string, moves ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(AttemptPuzzleRequest{Moves: moves})
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/api/puzzles/"+id+"/attempt", bytes.NewReader(body)), map[string]string{"id": id})
+	w := httptest.NewRecorder()
+	s.AttemptPuzzleHandler(w, req)
+	return w
+}
+
+func TestPuzzlesArePublishedAndServed(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	// Scholar's mate, which ends on a mate in one
+	engine := chess.NewEngine()
+	var moves gameMoves
+	for _, san := range []string{"e4", "e5", "Bc4", "Nc6", "Qh5", "Nf6", "Qxf7#"} {
+		result, err := engine.MakeMoveSAN(san)
+		if err != nil {
+			t.Fatal(err)
+		}
+		moves = append(moves, &index.Move{SAN: result.SAN, FEN: result.FEN})
+	}
+	miner := puzzle.NewPuzzles(puzzle.BuiltinSolver{}, moves, service)
+	mined, err := miner.MineGame(context.Background(), &index.Game{URI: "at://did:plc:white/app.atchess.game/scholars", Status: "white_won"})
+	if err != nil || mined == nil {
+		t.Fatalf("Expected a puzzle, got %+v (%v)", mined, err)
+	}
+	record := pds.get(mined.URI)
+	if record["$type"] != "app.atchess.puzzle" || record["theme"] != "mateIn1" {
+		t.Fatalf("Expected an app.atchess.puzzle record, got %v", record)
+	}
+
+	// A restarted instance reads its puzzles back
+	restarted := newServiceForPDS(t, pds)
+	w := httptest.NewRecorder()
+	restarted.DailyPuzzleHandler(w, httptest.NewRequest("GET", "/api/puzzles/daily", nil))
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "disabled" {
+		t.Errorf("Expected puzzles to be disabled, got %d: %s", w.Code, w.Body.String())
+	}
+	restarted.SetPuzzles(puzzle.NewPuzzles(puzzle.BuiltinSolver{}, gameMoves{}, restarted))
+	if err := restarted.LoadPuzzles(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	restarted.DailyPuzzleHandler(w, httptest.NewRequest("GET", "/api/puzzles/daily", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the daily puzzle, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "h5f7") {
+		t.Errorf("Expected the solution to be hidden, got %s", w.Body.String())
+	}
+	var daily puzzle.Puzzle
+	_ = json.Unmarshal(w.Body.Bytes(), &daily)
+	if daily.ID != mined.ID || daily.FEN != mined.FEN || daily.Color != "white" {
+		t.Errorf("Expected the mined puzzle, got %+v", daily)
+	}
+
+	w = attemptPuzzle(restarted, daily.ID, "Qxf7#")
+	var attempt puzzle.Attempt
+	_ = json.Unmarshal(w.Body.Bytes(), &attempt)
+	if w.Code != http.StatusOK || !attempt.Solved {
+		t.Errorf("Expected the puzzle to be solved, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = attemptPuzzle(restarted, daily.ID, "Qxh7")
+	attempt = puzzle.Attempt{}
+	_ = json.Unmarshal(w.Body.Bytes(), &attempt)
+	if w.Code != http.StatusOK || attempt.Correct || len(attempt.Solution) != 1 {
+		t.Errorf("Expected a wrong answer with the solution, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := attemptPuzzle(restarted, daily.ID, "Ke3"); w.Code != http.StatusBadRequest || errorCode(t, w) != "invalid_move" {
+		t.Errorf("Expected invalid_move, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := attemptPuzzle(restarted, "missing", "Qxf7#"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown puzzle to be not found, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,164 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/rs/zerolog/log"
+)
+
+// bucketIdleTimeout is how long an unused rate limit bucket is kept
+const bucketIdleTimeout = 10 * time.Minute
+
+// RateLimitStore keeps the token buckets API requests are limited by. The
+// default store is in memory; a shared one (e.g. backed by Redis) makes
+// several instances behind a load balancer enforce one limit.
+type RateLimitStore interface {
+	// Allow takes a token from the bucket for key, which holds perMinute
+	// tokens and refills at that rate. It returns the tokens left, or how
+	// long to wait for one.
+	Allow(key string, perMinute int) (allowed bool, remaining int, wait time.Duration)
+}
+
+// NewMemoryRateLimitStore returns a rate limit store for a single instance
+func NewMemoryRateLimitStore() RateLimitStore {
+	return newRateLimiter()
+}
+
+// tokenBucket allows bursts up to its capacity and refills continuously
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps a token bucket per client in memory
+type rateLimiter struct {
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*tokenBucket), now: time.Now}
+}
+
+// Allow takes a token from the client's bucket, returning the tokens left and
+// how long to wait when none are
+func (l *rateLimiter) Allow(client string, perMinute int) (bool, int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastPrune) > bucketIdleTimeout {
+		for key, bucket := range l.buckets {
+			if now.Sub(bucket.last) > bucketIdleTimeout {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	capacity := float64(perMinute)
+	rate := capacity / 60 // tokens per second
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+		return false, 0, wait
+	}
+	bucket.tokens--
+	return true, int(bucket.tokens), 0
+}
+
+// SetRateLimitStore replaces the in-memory store used by RateLimitMiddleware
+func (s *Service) SetRateLimitStore(store RateLimitStore) {
+	s.rateLimits = store
+}
+
+// rateLimitCheck is a bucket a request takes a token from
+type rateLimitCheck struct {
+	bucket    string
+	perMinute int
+}
+
+// RateLimitMiddleware limits API requests per session for logged-in users
+// and per IP address otherwise, answering 429 with Retry-After once a client
+// runs out. Logging in and creating challenges are limited more strictly. It
+// must run after SessionMiddleware so sessions are known.
+func (s *Service) RateLimitMiddleware() mux.MiddlewareFunc {
+	cfg := s.config.RateLimit
+	store := s.rateLimits
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+	proxies := parseTrustedProxies(s.config.Server.TrustedProxies)
+	// Stricter limits for some routes, on top of the general one
+	rules := map[string]rateLimitCheck{
+		"POST /api/auth/login":       {"login", cfg.LoginsPerMinute},
+		"POST /api/auth/oauth/login": {"login", cfg.LoginsPerMinute},
+		"POST /api/challenges":       {"challenges", cfg.ChallengesPerMinute},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client, limit := "ip:"+proxies.clientIP(r), cfg.RequestsPerMinute
+			if token, _, ok := s.currentSession(r); ok {
+				sum := sha256.Sum256([]byte(token))
+				client, limit = "session:"+hex.EncodeToString(sum[:8]), cfg.SessionRequestsPerMinute
+			}
+
+			// The stricter limit is checked first and reported in the headers
+			checks := []rateLimitCheck{{client, limit}}
+			if current := mux.CurrentRoute(r); current != nil {
+				template, _ := current.GetPathTemplate()
+				// Versioned paths share their rule with the unversioned alias
+				if rest, ok := strings.CutPrefix(template, "/api/v"); ok {
+					if i := strings.Index(rest, "/"); i >= 0 {
+						template = "/api" + rest[i:]
+					}
+				}
+				if rule, ok := rules[r.Method+" "+template]; ok && rule.perMinute > 0 {
+					checks = append([]rateLimitCheck{{rule.bucket + ":" + client, rule.perMinute}}, checks...)
+				}
+			}
+
+			for i, check := range checks {
+				if check.perMinute <= 0 {
+					continue
+				}
+				allowed, remaining, wait := store.Allow(check.bucket, check.perMinute)
+				if i == 0 {
+					w.Header().Set("X-RateLimit-Limit", strconv.Itoa(check.perMinute))
+					w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+				}
+				if !allowed {
+					log.Warn().Str("bucket", check.bucket).Str("path", r.URL.Path).Msg("Rate limit exceeded")
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+					apierror.Write(w, apierror.ErrRateLimited)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+// recordingStore is a RateLimitStore that allows a fixed number of requests
+// per bucket and records which buckets were used
+type recordingStore struct {
+	limit   int
+	buckets map[string]int
+}
+
+func (s *recordingStore) Allow(key string, perMinute int) (bool, int, time.Duration) {
+	s.buckets[key]++
+	if s.buckets[key] > s.limit {
+		return false, 0, 1500 * time.Millisecond
+	}
+	return true, s.limit - s.buckets[key], 0
+}
+
+func newRateLimitedRouter(t *testing.T) (*Service, *fakePDS, *mux.Router) {
+	t.Helper()
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	service.config.RateLimit.RequestsPerMinute = 3
+	service.config.RateLimit.SessionRequestsPerMinute = 5
+	service.config.RateLimit.LoginsPerMinute = 1
+	service.config.RateLimit.ChallengesPerMinute = 2
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	limiter := service.RateLimitMiddleware()
+	router := mux.NewRouter()
+	for _, prefix := range []string{"/api/v1", "/api"} {
+		api := router.PathPrefix(prefix).Subrouter()
+		api.Use(service.SessionMiddleware)
+		api.Use(limiter)
+		api.HandleFunc("/auth/login", ok).Methods("POST")
+		api.HandleFunc("/challenges", ok).Methods("POST")
+		api.HandleFunc("/games", ok).Methods("GET")
+	}
+	return service, pds, router
+}
+
+func limitedRequest(router http.Handler, method, target, ip, session string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = ip + ":4321"
+	if session != "" {
+		req.Header.Set(SessionHeader, session)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitMiddlewareLimitsPerIP(t *testing.T) {
+	_, _, router := newRateLimitedRouter(t)
+
+	for i := 0; i < 3; i++ {
+		if w := limitedRequest(router, "GET", "/api/games", "10.0.0.1", ""); w.Code != http.StatusOK {
+			t.Fatalf("Expected request %d to succeed, got %d", i, w.Code)
+		}
+	}
+	w := limitedRequest(router, "GET", "/api/games", "10.0.0.1", "")
+	if w.Code != http.StatusTooManyRequests || errorCode(t, w) != "rate_limited" || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	if w = limitedRequest(router, "GET", "/api/games", "10.0.0.2", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected another IP to have its own allowance, got %d", w.Code)
+	}
+}
+
+func TestRateLimitMiddlewareLimitsClientsBehindTrustedProxies(t *testing.T) {
+	service, _, _ := newRateLimitedRouter(t)
+	service.config.Server.TrustedProxies = []string{"10.0.0.0/8"}
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router := mux.NewRouter()
+	router.Use(service.RateLimitMiddleware())
+	router.HandleFunc("/api/games", ok)
+
+	forwarded := func(peer, client string) int {
+		req := httptest.NewRequest("GET", "/api/games", nil)
+		req.RemoteAddr = peer + ":4321"
+		req.Header.Set("X-Forwarded-For", client)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Clients behind the proxy each have their own allowance
+	for i := 0; i < 3; i++ {
+		if code := forwarded("10.0.0.1", "198.51.100.1"); code != http.StatusOK {
+			t.Fatalf("Expected request %d to succeed, got %d", i, code)
+		}
+	}
+	if code := forwarded("10.0.0.1", "198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the client behind the proxy to be limited, got %d", code)
+	}
+	if code := forwarded("10.0.0.1", "198.51.100.2"); code != http.StatusOK {
+		t.Errorf("Expected another client behind the proxy to have its own allowance, got %d", code)
+	}
+
+	// Anyone else is limited by their own address, whatever they claim
+	for i := 0; i < 3; i++ {
+		forwarded("203.0.113.5", "198.51.100.3")
+	}
+	if code := forwarded("203.0.113.5", "198.51.100.4"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected X-Forwarded-For from an untrusted peer to be ignored, got %d", code)
+	}
+}
+
+func TestRateLimitMiddlewareIsStricterOnLoginAndChallenges(t *testing.T) {
+	service, pds, router := newRateLimitedRouter(t)
+
+	if w := limitedRequest(router, "POST", "/api/auth/login", "10.0.0.1", ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected the first login to succeed, got %d", w.Code)
+	}
+	w := limitedRequest(router, "POST", "/api/auth/login", "10.0.0.1", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("X-RateLimit-Limit") != "1" {
+		t.Errorf("Expected a second login to be limited, got %d %v", w.Code, w.Header())
+	}
+	if w = limitedRequest(router, "POST", "/api/v1/auth/login", "10.0.0.1", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the versioned login to share the limit, got %d", w.Code)
+	}
+
+	// Logged-in players are limited per session rather than per IP
+	player, err := atproto.NewClient(pds.URL, "player", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	token, _ := service.Sessions().Create(player)
+	for i := 0; i < 2; i++ {
+		if w = limitedRequest(router, "POST", "/api/challenges", "10.0.0.1", token); w.Code != http.StatusOK {
+			t.Fatalf("Expected challenge %d to succeed, got %d", i, w.Code)
+		}
+	}
+	if w = limitedRequest(router, "POST", "/api/challenges", "10.0.0.1", token); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected challenge spam to be limited, got %d", w.Code)
+	}
+	if w = limitedRequest(router, "GET", "/api/games", "10.0.0.1", token); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "5" {
+		t.Errorf("Expected other requests to use the session limit, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestRateLimitMiddlewareUsesConfiguredStore(t *testing.T) {
+	service, _, _ := newRateLimitedRouter(t)
+	store := &recordingStore{limit: 1, buckets: make(map[string]int)}
+	service.SetRateLimitStore(store)
+
+	router := mux.NewRouter()
+	router.Use(service.RateLimitMiddleware())
+	router.HandleFunc("/api/games", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	limitedRequest(router, "GET", "/api/games", "10.0.0.9", "")
+	w := limitedRequest(router, "GET", "/api/games", "10.0.0.9", "")
+	if store.buckets["ip:10.0.0.9"] != 2 || w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("Expected the store to decide, got %v and %d %v", store.buckets, w.Code, w.Header())
+	}
+}
@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/rating"
+)
+
+const (
+	defaultLeaderboardSize = 50
+	maxLeaderboardSize     = 100
+)
+
+// SetRatings enables the rating endpoints
+func (s *Service) SetRatings(ratings *rating.Ratings) {
+	s.ratings = ratings
+}
+
+// PublishRating writes a player's rating record using one of their logged-in
+// sessions, since only the player can write to their repository
+func (s *Service) PublishRating(ctx context.Context, did string, r *rating.PlayerRating) error {
+	sessions := s.sessions.ListForDID(did)
+	if len(sessions) == 0 {
+		return rating.ErrNotConnected
+	}
+
+	return sessions[0].Client.PublishRating(ctx, &atproto.RatingRecord{
+		System:    "glicko2",
+		Rating:    int(math.Round(r.Rating)),
+		Deviation: int(math.Round(r.Deviation)),
+		Games:     r.Games,
+		Wins:      r.Wins,
+		Losses:    r.Losses,
+		Draws:     r.Draws,
+		Issuer:    s.client.GetDID(),
+		LastGame:  r.LastGame,
+		UpdatedAt: r.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+// GetPlayerRatingHandler returns a player's rating
+func (s *Service) GetPlayerRatingHandler(w http.ResponseWriter, r *http.Request) {
+	if s.ratings == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Ratings are not enabled"))
+		return
+	}
+
+	did := mux.Vars(r)["did"]
+	if !didPattern.MatchString(did) {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid DID"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.ratings.Get(did))
+}
+
+// LeaderboardHandler returns the highest rated players. Provisional ratings
+// are left out unless provisional=true.
+func (s *Service) LeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	if s.ratings == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Ratings are not enabled"))
+		return
+	}
+
+	params, ok := parseLeaderboardParams(w, r)
+	if !ok {
+		return
+	}
+	players := s.ratings.Leaderboard(params.limit, params.minGames, params.provisional)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"players": players,
+	})
+}
+
+// RankedPlayer is a player's place on a leaderboard
+type RankedPlayer struct {
+	Rank int `json:"rank"`
+	*rating.PlayerRating
+}
+
+// TimeControlLeaderboardHandler returns the highest rated players in one time
+// control, rated on their games in that time control alone. It takes the same
+// parameters as LeaderboardHandler, and pages with the returned cursor.
+func (s *Service) TimeControlLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	if s.ratings == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Ratings are not enabled"))
+		return
+	}
+
+	timeControl := mux.Vars(r)["timeControl"]
+	if !timeControlTypes[timeControl] {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("timeControl must be correspondence, rapid, blitz or bullet"))
+		return
+	}
+	params, ok := parseLeaderboardParams(w, r)
+	if !ok {
+		return
+	}
+	offset := 0
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			apierror.Write(w, apierror.ErrInvalidCursor)
+			return
+		}
+		offset = n
+	}
+
+	board, total := s.ratings.TimeControlLeaderboard(timeControl, offset, params.limit, params.minGames, params.provisional)
+	players := make([]RankedPlayer, len(board))
+	for i, player := range board {
+		players[i] = RankedPlayer{Rank: offset + i + 1, PlayerRating: player}
+	}
+	cursor := ""
+	if next := offset + len(board); next < total {
+		cursor = strconv.Itoa(next)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"timeControl": timeControl,
+		"players":     players,
+		"total":       total,
+		"cursor":      cursor,
+	})
+}
+
+// leaderboardParams are the filters leaderboards accept
+type leaderboardParams struct {
+	limit       int
+	minGames    int
+	provisional bool
+}
+
+// parseLeaderboardParams reads limit, minGames and provisional from the
+// query, writing the error response if they're invalid
+func parseLeaderboardParams(w http.ResponseWriter, r *http.Request) (leaderboardParams, bool) {
+	query := r.URL.Query()
+	params := leaderboardParams{
+		limit:       defaultLeaderboardSize,
+		minGames:    1,
+		provisional: query.Get("provisional") == "true",
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			apierror.Write(w, apierror.ErrInvalidLimit)
+			return params, false
+		}
+		params.limit = min(n, maxLeaderboardSize)
+	}
+	if v := query.Get("minGames"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid minGames"))
+			return params, false
+		}
+		params.minGames = n
+	}
+	return params, true
+}
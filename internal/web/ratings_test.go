@@ -0,0 +1,150 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/rating"
+)
+
+func TestRatingsPublishedToPlayerReposAndServed(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, "did:plc:service"))
+	ratings := rating.NewRatings(service)
+	service.SetRatings(ratings)
+
+	// Only white is logged in when the game finishes
+	whitePDS := newFakePDS(t, testWhiteDID)
+	whiteClient, err := atproto.NewClient(whitePDS.URL, "white", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	_, _ = service.Sessions().Create(whiteClient)
+
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	indexer.OnGameFinished(func(ctx context.Context, game *index.Game) {
+		ratings.RecordGame(ctx, game)
+	})
+	_ = indexer.Apply(context.Background(), "update", testWhiteDID, "app.atchess.game/g1", map[string]interface{}{
+		"white":     testWhiteDID,
+		"black":     testBlackDID,
+		"status":    "white_won",
+		"fen":       startFEN,
+		"createdAt": "2024-01-01T00:00:00Z",
+	})
+
+	record := whitePDS.get("at://did:plc:white/app.atchess.rating/self")
+	if record == nil {
+		t.Fatal("Expected rating record in the winner's repository")
+	}
+	if record["system"] != "glicko2" || record["issuer"] != "did:plc:service" || record["wins"] != float64(1) || record["rating"].(float64) <= 1500 {
+		t.Errorf("Unexpected rating record: %v", record)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/players/"+testBlackDID+"/rating", nil), map[string]string{"did": testBlackDID})
+	w := httptest.NewRecorder()
+	service.GetPlayerRatingHandler(w, req)
+	var black rating.PlayerRating
+	if err := json.Unmarshal(w.Body.Bytes(), &black); err != nil {
+		t.Fatalf("Failed to parse rating: %v", err)
+	}
+	if w.Code != http.StatusOK || black.Losses != 1 || black.Rating >= 1500 {
+		t.Errorf("Unexpected rating for black: %d %+v", w.Code, black)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/api/players/nobody/rating", nil), map[string]string{"did": "nobody"})
+	w = httptest.NewRecorder()
+	service.GetPlayerRatingHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid DID, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	service.LeaderboardHandler(w, httptest.NewRequest("GET", "/api/leaderboard?provisional=true", nil))
+	var board struct {
+		Players []rating.PlayerRating `json:"players"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &board)
+	if len(board.Players) != 2 || board.Players[0].DID != testWhiteDID {
+		t.Errorf("Unexpected leaderboard: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	service.LeaderboardHandler(w, httptest.NewRequest("GET", "/api/leaderboard?limit=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid limit, got %d", w.Code)
+	}
+}
+
+func TestTimeControlLeaderboardHandler(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, "did:plc:service"))
+	ratings := rating.NewRatings(nil)
+	service.SetRatings(ratings)
+
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	indexer.OnGameFinished(func(ctx context.Context, game *index.Game) {
+		ratings.RecordGame(ctx, game)
+	})
+	for i, black := range []string{testBlackDID, "did:plc:carol"} {
+		_ = indexer.Apply(context.Background(), "create", testWhiteDID, fmt.Sprintf("app.atchess.game/g%d", i), map[string]interface{}{
+			"white":       testWhiteDID,
+			"black":       black,
+			"status":      "white_won",
+			"fen":         startFEN,
+			"timeControl": map[string]interface{}{"type": "blitz", "initial": 180, "increment": 2},
+			"createdAt":   "2024-01-01T00:00:00Z",
+		})
+	}
+
+	get := func(timeControl, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/leaderboards/"+timeControl+query, nil)
+		w := httptest.NewRecorder()
+		service.TimeControlLeaderboardHandler(w, mux.SetURLVars(req, map[string]string{"timeControl": timeControl}))
+		return w
+	}
+	var board struct {
+		TimeControl string         `json:"timeControl"`
+		Players     []RankedPlayer `json:"players"`
+		Total       int            `json:"total"`
+		Cursor      string         `json:"cursor"`
+	}
+
+	w := get("blitz", "?provisional=true&limit=2")
+	_ = json.Unmarshal(w.Body.Bytes(), &board)
+	if w.Code != http.StatusOK || board.Total != 3 || len(board.Players) != 2 || board.Players[0].DID != testWhiteDID || board.Players[0].Rank != 1 || board.Cursor != "2" {
+		t.Fatalf("Unexpected blitz leaderboard: %d %s", w.Code, w.Body.String())
+	}
+
+	board.Cursor = ""
+	w = get("blitz", "?provisional=true&limit=2&cursor=2")
+	_ = json.Unmarshal(w.Body.Bytes(), &board)
+	if len(board.Players) != 1 || board.Players[0].Rank != 3 || board.Cursor != "" {
+		t.Errorf("Unexpected second page: %s", w.Body.String())
+	}
+
+	w = get("blitz", "?minGames=2&provisional=true")
+	_ = json.Unmarshal(w.Body.Bytes(), &board)
+	if len(board.Players) != 1 || board.Players[0].DID != testWhiteDID {
+		t.Errorf("Expected minGames to filter players, got %s", w.Body.String())
+	}
+	if w = get("blitz", ""); !strings.Contains(w.Body.String(), `"players":[]`) {
+		t.Errorf("Expected provisional ratings to be left out, got %s", w.Body.String())
+	}
+	if w = get("rapid", "?provisional=true"); !strings.Contains(w.Body.String(), `"total":0`) {
+		t.Errorf("Expected no rapid players, got %s", w.Body.String())
+	}
+
+	if w = get("classical", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown time control, got %d", w.Code)
+	}
+	if w = get("blitz", "?cursor=x"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid cursor, got %d", w.Code)
+	}
+}
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxRematchMessageLength bounds the message with a rematch offer, in bytes,
+// as its lexicon does
+const MaxRematchMessageLength = 300
+
+// OfferRematchRequest offers to play a finished game again
+type OfferRematchRequest struct {
+	Message string `json:"message,omitempty"`
+}
+
+// RespondToRematchRequest accepts or declines a rematch offer
+type RespondToRematchRequest struct {
+	OfferURI string `json:"offerUri"`
+	Accept   bool   `json:"accept"`
+}
+
+// RematchStarted is the notification both players get when a rematch is
+// accepted
+type RematchStarted struct {
+	PreviousGameID string      `json:"previousGameId"`
+	Game           *chess.Game `json:"game"`
+}
+
+// OfferRematchHandler records an app.atchess.rematchOffer for a finished
+// game in the player's repository and notifies their opponent
+func (s *Service) OfferRematchHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	// The body is optional, as the message is
+	var req OfferRematchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if len(req.Message) > MaxRematchMessageLength {
+		apierror.Write(w, apierror.ErrMessageTooLong)
+		return
+	}
+
+	client := s.clientFor(r)
+	game, ok := s.loadGameForPlayer(w, client.GetGame, gameID, client.GetDID())
+	if !ok {
+		return
+	}
+	if game.Status == chess.StatusActive {
+		apierror.Write(w, apierror.ErrGameInProgress)
+		return
+	}
+	if game.Bot != nil {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Start a new game to play the computer again"))
+		return
+	}
+
+	offer, err := client.OfferRematch(context.Background(), gameID, req.Message)
+	if errors.Is(err, atproto.ErrNotInGame) {
+		apierror.Write(w, apierror.ErrNotAPlayer)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to offer rematch")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to offer rematch"))
+		return
+	}
+
+	s.notifyPlayer(offer.Opponent, NotificationRematchOffer, gameID, offer)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(offer)
+}
+
+// RespondToRematchHandler accepts or declines a rematch offer. Accepting
+// creates the new game in the accepting player's repository and tells both
+// players about it.
+func (s *Service) RespondToRematchHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	var req RespondToRematchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if req.OfferURI == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Missing offer URI"))
+		return
+	}
+
+	client := s.clientFor(r)
+	offer, err := client.GetRematchOffer(context.Background(), req.OfferURI)
+	if err != nil {
+		log.Error().Err(err).Str("uri", req.OfferURI).Msg("Failed to fetch rematch offer")
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Rematch offer not found"))
+		return
+	}
+	if offer.GameURI != gameID {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("The rematch offer is for another game"))
+		return
+	}
+
+	if !req.Accept {
+		offer, err = client.DeclineRematch(context.Background(), req.OfferURI)
+		if err != nil {
+			log.Error().Err(err).Str("uri", req.OfferURI).Msg("Failed to decline rematch")
+			apierror.Write(w, rematchError(err, "Failed to decline rematch"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(offer)
+		return
+	}
+
+	game, err := client.AcceptRematch(context.Background(), req.OfferURI)
+	if err != nil {
+		log.Error().Err(err).Str("uri", req.OfferURI).Msg("Failed to accept rematch")
+		apierror.Write(w, rematchError(err, "Failed to accept rematch"))
+		return
+	}
+
+	started := RematchStarted{PreviousGameID: gameID, Game: game}
+	s.notifyPlayer(game.White, NotificationRematch, game.ID, started)
+	s.notifyPlayer(game.Black, NotificationRematch, game.ID, started)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(game)
+}
+
+// rematchError maps rematch response errors to API errors
+func rematchError(err error, message string) *apierror.Error {
+	switch {
+	case errors.Is(err, atproto.ErrNotRematchOpponent):
+		return apierror.ErrNotRematchOpponent
+	case errors.Is(err, atproto.ErrRematchNotPending):
+		return apierror.ErrRematchNotPending
+	default:
+		return apierror.ErrInternal.WithMessage(message)
+	}
+}
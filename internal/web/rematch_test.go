@@ -0,0 +1,156 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+// newSharedPDSGame sets up a game in white's repository with both players
+// signed in to the same PDS, so each can read the other's records
+func newSharedPDSGame(t *testing.T, status string) *liveGame {
+	t.Helper()
+	pds := newFakePDS(t, testWhiteDID)
+	game := &liveGame{
+		whitePDS: pds,
+		gameID:   seedGame(pds, startFEN, status),
+		service:  newServiceForPDS(t, pds),
+		hub:      NewHub(),
+		tokens:   make(map[string]string),
+	}
+	go game.hub.Run()
+	game.service.SetHub(game.hub)
+
+	for _, did := range []string{testWhiteDID, testBlackDID} {
+		pds.did = did
+		client, err := atproto.NewClient(pds.URL, did, "password")
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		game.tokens[did], _ = game.service.Sessions().Create(client)
+	}
+	pds.did = testWhiteDID
+
+	server := httptest.NewServer(game.service.WebSocketHandler(game.hub))
+	t.Cleanup(server.Close)
+	game.wsURL = "ws" + strings.TrimPrefix(server.URL, "http") + "/?gameId=" + url.QueryEscape(game.gameID)
+	return game
+}
+
+// rematchRequest calls a rematch handler for a game as a signed-in player
+func rematchRequest(handler http.HandlerFunc, g *liveGame, did, path string, body interface{}) *httptest.ResponseRecorder {
+	reqBody, _ := json.Marshal(body)
+	encoded := base64.URLEncoding.EncodeToString([]byte(g.gameID))
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/api/games/"+encoded+path, bytes.NewReader(reqBody)), map[string]string{"id": encoded})
+	req.Header.Set(SessionHeader, g.tokens[did])
+	w := httptest.NewRecorder()
+	g.service.SessionMiddleware(handler).ServeHTTP(w, req)
+	return w
+}
+
+func TestRematchOfferAndAcceptance(t *testing.T) {
+	game := newSharedPDSGame(t, "white_won")
+	white := game.connectPlayerChannel(t, testWhiteDID)
+	black := game.connectPlayerChannel(t, testBlackDID)
+
+	w := rematchRequest(game.service.OfferRematchHandler, game, testWhiteDID, "/rematch", map[string]string{"message": "again?"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the rematch to be offered, got %d: %s", w.Code, w.Body.String())
+	}
+	var offer atproto.RematchOffer
+	_ = json.Unmarshal(w.Body.Bytes(), &offer)
+	if offer.Opponent != testBlackDID || offer.Color != "black" || offer.Status != "pending" {
+		t.Errorf("Expected white to offer black a rematch playing black, got %+v", offer)
+	}
+	if record := game.whitePDS.get(offer.URI); record["$type"] != "app.atchess.rematchOffer" || record["game"] == nil {
+		t.Errorf("Expected an app.atchess.rematchOffer record referencing the game, got %v", record)
+	}
+
+	frame := readFrame(t, black, NotificationRematchOffer)
+	if frame.Data["uri"] != offer.URI {
+		t.Errorf("Unexpected rematch_offer frame: %+v", frame.Data)
+	}
+
+	w = rematchRequest(game.service.RespondToRematchHandler, game, testBlackDID, "/rematch/respond", RespondToRematchRequest{OfferURI: offer.URI, Accept: true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the rematch to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+	var rematch chess.Game
+	_ = json.Unmarshal(w.Body.Bytes(), &rematch)
+	if rematch.White != testBlackDID || rematch.Black != testWhiteDID || rematch.Status != chess.StatusActive {
+		t.Errorf("Expected a new game with colors swapped, got %+v", rematch)
+	}
+	if !strings.HasPrefix(rematch.ID, "at://"+testBlackDID+"/app.atchess.game/") {
+		t.Errorf("Expected the rematch in the accepter's repository, got %s", rematch.ID)
+	}
+	ref, _ := game.whitePDS.get(rematch.ID)["rematchOf"].(map[string]interface{})
+	if ref["uri"] != game.gameID {
+		t.Errorf("Expected the rematch to reference the finished game, got %v", ref)
+	}
+	if status := game.whitePDS.get(offer.URI)["status"]; status != "accepted" {
+		t.Errorf("Expected the offer to be accepted, got %v", status)
+	}
+
+	for did, conn := range map[string]*websocket.Conn{testWhiteDID: white, testBlackDID: black} {
+		frame := readFrame(t, conn, NotificationRematch)
+		if frame.Data["previousGameId"] != game.gameID {
+			t.Errorf("Expected %s to be told about the rematch, got %+v", did, frame.Data)
+		}
+	}
+
+	// The offer can only be answered once
+	w = rematchRequest(game.service.RespondToRematchHandler, game, testBlackDID, "/rematch/respond", RespondToRematchRequest{OfferURI: offer.URI, Accept: true})
+	if w.Code != http.StatusConflict || errorCode(t, w) != "rematch_not_pending" {
+		t.Errorf("Expected rematch_not_pending, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRematchRequiresFinishedGame(t *testing.T) {
+	game := newSharedPDSGame(t, "active")
+
+	w := rematchRequest(game.service.OfferRematchHandler, game, testWhiteDID, "/rematch", nil)
+	if w.Code != http.StatusConflict || errorCode(t, w) != "game_in_progress" {
+		t.Errorf("Expected game_in_progress, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRematchDecline(t *testing.T) {
+	game := newSharedPDSGame(t, "draw")
+
+	w := rematchRequest(game.service.OfferRematchHandler, game, testBlackDID, "/rematch", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the rematch to be offered, got %d: %s", w.Code, w.Body.String())
+	}
+	var offer atproto.RematchOffer
+	_ = json.Unmarshal(w.Body.Bytes(), &offer)
+	if offer.Opponent != testWhiteDID || offer.Color != "white" {
+		t.Errorf("Expected black to offer white a rematch playing white, got %+v", offer)
+	}
+
+	// Only the opponent can answer
+	w = rematchRequest(game.service.RespondToRematchHandler, game, testBlackDID, "/rematch/respond", RespondToRematchRequest{OfferURI: offer.URI, Accept: true})
+	if w.Code != http.StatusForbidden || errorCode(t, w) != "not_rematch_opponent" {
+		t.Errorf("Expected not_rematch_opponent, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = rematchRequest(game.service.RespondToRematchHandler, game, testWhiteDID, "/rematch/respond", RespondToRematchRequest{OfferURI: offer.URI})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the rematch to be declined, got %d: %s", w.Code, w.Body.String())
+	}
+	if status := game.whitePDS.get(offer.URI)["status"]; status != "declined" {
+		t.Errorf("Expected the offer to be declined, got %v", status)
+	}
+	if games := game.whitePDS.collection(testWhiteDID, "app.atchess.game"); len(games) != 1 {
+		t.Errorf("Expected no new game, got %v", games)
+	}
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ResyncType is sent to a resuming client when the updates it missed are no
+// longer buffered; it should reload the game instead
+const ResyncType = "resync"
+
+// replayBufferSize is how many recent updates each room keeps for clients
+// that reconnect
+const replayBufferSize = 128
+
+// replayRetention is how long a room's updates are kept after its last one
+// once nobody is connected
+const replayRetention = 10 * time.Minute
+
+// replayBuffer holds a room's most recent frames, numbered from 1. Numbers
+// start again whenever a buffer is created (after a restart, on another
+// replica, or once the room's history was pruned), so each buffer has an
+// epoch that frames carry alongside their number.
+type replayBuffer struct {
+	epoch   string
+	last    uint64
+	frames  [replayBufferSize][]byte
+	updated time.Time
+}
+
+// add buffers frame as the room's next update, overwriting the oldest one
+// when full
+func (b *replayBuffer) add(seq uint64, frame []byte) {
+	b.last = seq
+	b.frames[seq%replayBufferSize] = frame
+	b.updated = time.Now()
+}
+
+// newReplayBuffer creates an empty buffer with a new epoch
+func newReplayBuffer() *replayBuffer {
+	epoch := make([]byte, 8)
+	_, _ = rand.Read(epoch)
+	return &replayBuffer{epoch: hex.EncodeToString(epoch)}
+}
+
+// after returns the frames sent after seq in epoch, or false when some of
+// them have been overwritten, or seq is one this buffer never handed out
+func (b *replayBuffer) after(epoch string, seq uint64) ([][]byte, bool) {
+	if epoch != b.epoch || seq > b.last || b.last-seq > replayBufferSize {
+		return nil, false
+	}
+	frames := make([][]byte, 0, b.last-seq)
+	for next := seq + 1; next <= b.last; next++ {
+		frames = append(frames, b.frames[next%replayBufferSize])
+	}
+	return frames, true
+}
+
+// sequence numbers an update for its room, buffers it and returns the frame
+// to send; the caller must be the hub's run loop
+func (h *Hub) sequence(room string, update GameUpdate) ([]byte, error) {
+	buffer := h.history[room]
+	if buffer == nil {
+		buffer = newReplayBuffer()
+		h.history[room] = buffer
+	}
+	update.Epoch = buffer.epoch
+	update.Seq = buffer.last + 1
+
+	frame, err := json.Marshal(enrichUpdate(update))
+	if err != nil {
+		return nil, err
+	}
+	buffer.add(update.Seq, frame)
+	return frame, nil
+}
+
+// replay sends a resuming client the updates it missed, or a resync frame
+// when they are gone or were numbered by another buffer; the caller must be
+// the hub's run loop
+func (h *Hub) replay(client *Client) {
+	var epoch string
+	var last uint64
+	frames, ok := [][]byte(nil), client.since == 0
+	if buffer := h.history[client.room()]; buffer != nil {
+		epoch, last = buffer.epoch, buffer.last
+		frames, ok = buffer.after(client.epoch, client.since)
+	}
+	if !ok {
+		frame, _ := json.Marshal(GameUpdate{GameID: client.gameID, Type: ResyncType, Epoch: epoch, Seq: last})
+		frames = [][]byte{frame}
+	}
+
+	for _, frame := range frames {
+		select {
+		case client.send <- frame:
+		default:
+			log.Warn().Str("gameID", client.gameID).Msg("Send buffer full while replaying missed updates")
+			return
+		}
+	}
+}
+
+// pruneHistory forgets the updates of rooms nobody has been in for a while;
+// the caller must be the hub's run loop
+func (h *Hub) pruneHistory(now time.Time) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for room, buffer := range h.history {
+		if len(h.gameClients[room]) == 0 && now.Sub(buffer.updated) > replayRetention {
+			delete(h.history, room)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// receiveUpdates reads n frames sent to client
+func receiveUpdates(t *testing.T, client *Client, n int) []GameUpdate {
+	t.Helper()
+	updates := make([]GameUpdate, 0, n)
+	for len(updates) < n {
+		select {
+		case frame := <-client.send:
+			var update GameUpdate
+			if err := json.Unmarshal(frame, &update); err != nil {
+				t.Fatalf("Failed to decode frame %s: %v", frame, err)
+			}
+			updates = append(updates, update)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %d frames, got %d", n, len(updates))
+		}
+	}
+	return updates
+}
+
+// broadcastMoves sends n move updates for a game, waits until a watcher has
+// them and returns the epoch they were numbered in
+func broadcastMoves(t *testing.T, hub *Hub, gameID string, n int) string {
+	t.Helper()
+	watcher := &Client{hub: hub, send: make(chan []byte, replayBufferSize*2), gameID: gameID, userID: "anonymous", channel: GameChannel}
+	hub.register <- watcher
+	for i := 0; i < n; i++ {
+		hub.BroadcastToGame(gameID, GameUpdate{Type: "move", Data: map[string]int{"ply": i + 1}})
+	}
+	updates := receiveUpdates(t, watcher, n)
+	return updates[len(updates)-1].Epoch
+}
+
+func TestUpdatesAreNumberedPerGame(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	client := &Client{hub: hub, send: make(chan []byte, 8), gameID: "game-1", userID: "anonymous", channel: GameChannel}
+	hub.register <- client
+
+	hub.BroadcastToGame("game-2", GameUpdate{Type: "move"})
+	hub.BroadcastToGame("game-1", GameUpdate{Type: "move"})
+	hub.BroadcastToGame("game-1", GameUpdate{Type: "clock"})
+
+	updates := receiveUpdates(t, client, 2)
+	if updates[0].Seq != 1 || updates[1].Seq != 2 {
+		t.Errorf("Expected game-1's updates numbered 1 and 2, got %d and %d", updates[0].Seq, updates[1].Seq)
+	}
+}
+
+func TestResumingClientGetsMissedUpdates(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	epoch := broadcastMoves(t, hub, "game-1", 5)
+
+	client := &Client{hub: hub, send: make(chan []byte, 8), gameID: "game-1", userID: "anonymous", channel: GameChannel, resume: true, since: 3, epoch: epoch}
+	hub.register <- client
+
+	updates := receiveUpdates(t, client, 2)
+	if updates[0].Seq != 4 || updates[1].Seq != 5 || updates[0].Type != "move" {
+		t.Errorf("Expected moves 4 and 5 replayed, got %+v", updates)
+	}
+	expectNoFrame(t, client)
+}
+
+func TestResumingClientIsToldToResync(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	epoch := broadcastMoves(t, hub, "game-1", replayBufferSize+10)
+
+	for _, since := range []uint64{5, replayBufferSize + 20} {
+		t.Run(fmt.Sprint(since), func(t *testing.T) {
+			client := &Client{hub: hub, send: make(chan []byte, 8), gameID: "game-1", userID: "anonymous", channel: GameChannel, resume: true, since: since, epoch: epoch}
+			hub.register <- client
+
+			updates := receiveUpdates(t, client, 1)
+			if updates[0].Type != ResyncType || updates[0].Seq != replayBufferSize+10 {
+				t.Errorf("Expected a resync at the latest update, got %+v", updates[0])
+			}
+			expectNoFrame(t, client)
+		})
+	}
+}
+
+func TestReplayBufferWrapsAround(t *testing.T) {
+	buffer := newReplayBuffer()
+	for seq := uint64(1); seq <= replayBufferSize+3; seq++ {
+		buffer.add(seq, []byte(fmt.Sprint(seq)))
+	}
+
+	frames, ok := buffer.after(buffer.epoch, replayBufferSize+1)
+	if !ok || len(frames) != 2 || string(frames[0]) != fmt.Sprint(replayBufferSize+2) {
+		t.Errorf("Expected the last two frames, got %q", frames)
+	}
+	if _, ok := buffer.after(buffer.epoch, 3); !ok {
+		t.Error("Expected every update after the oldest buffered one to be available")
+	}
+	if _, ok := buffer.after(buffer.epoch, 2); ok {
+		t.Error("Expected an overwritten update to need a resync")
+	}
+}
+
+func TestResumingClientFromAnotherEpochIsToldToResync(t *testing.T) {
+	// A restarted hub numbers its updates from 1 again
+	before := NewHub()
+	go before.Run()
+	epoch := broadcastMoves(t, before, "game-1", 3)
+	after := NewHub()
+	go after.Run()
+	current := broadcastMoves(t, after, "game-1", 5)
+	if epoch == "" || epoch == current {
+		t.Fatalf("Expected each hub to number updates in its own epoch, got %q and %q", epoch, current)
+	}
+
+	for _, epoch := range []string{epoch, ""} {
+		client := &Client{hub: after, send: make(chan []byte, 8), gameID: "game-1", userID: "anonymous", channel: GameChannel, resume: true, since: 3, epoch: epoch}
+		after.register <- client
+
+		updates := receiveUpdates(t, client, 1)
+		if updates[0].Type != ResyncType || updates[0].Seq != 5 || updates[0].Epoch != current {
+			t.Errorf("Expected a resync in the current epoch rather than moves 4 and 5, got %+v", updates[0])
+		}
+		expectNoFrame(t, client)
+	}
+}
+
+func TestPruneHistoryKeepsOccupiedRooms(t *testing.T) {
+	hub := NewHub()
+	stale := time.Now().Add(-2 * replayRetention)
+	hub.history["game-1"] = &replayBuffer{last: 1, updated: stale}
+	hub.history["game-2"] = &replayBuffer{last: 1, updated: stale}
+	hub.gameClients["game-2"] = map[*Client]bool{{}: true}
+
+	hub.pruneHistory(time.Now())
+	if hub.history["game-1"] != nil || hub.history["game-2"] == nil {
+		t.Errorf("Expected only the empty room's history to be dropped, got %v", hub.history)
+	}
+}
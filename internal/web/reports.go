@@ -0,0 +1,384 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/lexicon"
+	"github.com/rs/zerolog/log"
+)
+
+// Report statuses
+const (
+	ReportOpen      = "open"
+	ReportActioned  = "actioned"
+	ReportDismissed = "dismissed"
+)
+
+// maxReportLength bounds the details of a report and an operator's note
+const maxReportLength = 2000
+
+// reportReasons are the reasons a player may be reported for
+var reportReasons = map[string]bool{
+	atproto.ReportCheating:       true,
+	atproto.ReportChatAbuse:      true,
+	atproto.ReportSpamChallenges: true,
+	atproto.ReportOther:          true,
+}
+
+// Report is a report filed on this instance, as it sits in the operators'
+// review queue. The report itself is an app.atchess.report record in the
+// reporter's repository; the queue's status is kept here.
+type Report struct {
+	atproto.ReportRecord
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	ResolvedBy string     `json:"resolvedBy,omitempty"` // DID of the operator who reviewed it
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	Note       string     `json:"note,omitempty"`
+
+	seq uint64 // order the report was queued in
+}
+
+// sameComplaint reports whether two reports are one reporter's complaint
+// about the same thing
+func (r *Report) sameComplaint(other *Report) bool {
+	return r.Reporter == other.Reporter && r.Subject == other.Subject &&
+		r.Reason == other.Reason && r.Game == other.Game
+}
+
+// ReportStore is the review queue of reports filed on this instance
+type ReportStore struct {
+	reports map[string]*Report
+	seq     uint64
+	mu      sync.RWMutex
+}
+
+// NewReportStore creates an empty review queue
+func NewReportStore() *ReportStore {
+	return &ReportStore{reports: make(map[string]*Report)}
+}
+
+// Add queues a report, returning false if the reporter already has an open
+// report about the same thing
+func (rs *ReportStore) Add(report *Report) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.duplicateLocked(report) {
+		return false
+	}
+	rs.seq++
+	report.seq = rs.seq
+	rs.reports[report.ID] = report
+	return true
+}
+
+// Duplicate reports whether the reporter already has an open report about
+// the same thing
+func (rs *ReportStore) Duplicate(report *Report) bool {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.duplicateLocked(report)
+}
+
+func (rs *ReportStore) duplicateLocked(report *Report) bool {
+	for _, queued := range rs.reports {
+		if queued.Status == ReportOpen && queued.sameComplaint(report) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns copies of the reports with a status, or all of them for "",
+// optionally only those about one player, oldest first
+func (rs *ReportStore) List(status, subject string) []Report {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	reports := []Report{}
+	for _, report := range rs.reports {
+		if (status == "" || report.Status == status) && (subject == "" || report.Subject == subject) {
+			reports = append(reports, *report)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].seq < reports[j].seq
+	})
+	return reports
+}
+
+// Resolve records an operator's decision on a report, returning a copy of
+// it. A decision may be revised, e.g. to dismiss a report acted on in error.
+func (rs *ReportStore) Resolve(id, status, operator, note string) (Report, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	report, ok := rs.reports[id]
+	if !ok {
+		return Report{}, false
+	}
+	now := time.Now()
+	report.Status = status
+	report.ResolvedBy = operator
+	report.ResolvedAt = &now
+	report.Note = note
+	return *report, true
+}
+
+// SeeksHidden reports whether a player's seeks are kept out of the lobby:
+// an operator has acted on a report against them, or at least threshold
+// different players have open reports against them. A threshold of zero
+// only counts acted-on reports.
+func (rs *ReportStore) SeeksHidden(did string, threshold int) bool {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	reporters := make(map[string]bool)
+	for _, report := range rs.reports {
+		if report.Subject != did {
+			continue
+		}
+		switch report.Status {
+		case ReportActioned:
+			return true
+		case ReportOpen:
+			reporters[report.Reporter] = true
+		}
+	}
+	return threshold > 0 && len(reporters) >= threshold
+}
+
+// UnderReview reports whether a game has an open cheating report
+func (rs *ReportStore) UnderReview(gameURI string) bool {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	for _, report := range rs.reports {
+		if report.Game == gameURI && report.Reason == atproto.ReportCheating && report.Status == ReportOpen {
+			return true
+		}
+	}
+	return false
+}
+
+// seeksHidden reports whether reports against a player keep their seeks out
+// of the lobby
+func (s *Service) seeksHidden(did string) bool {
+	return s.reports.SeeksHidden(did, s.config.Moderation.HideSeeksAfter)
+}
+
+// CreateReportRequest is the body of POST /api/reports
+type CreateReportRequest struct {
+	Subject string `json:"subject"` // DID or handle of the reported player
+	Reason  string `json:"reason"`
+	Details string `json:"details,omitempty"`
+	Game    string `json:"game,omitempty"`   // AT URI or encoded ID of the game, required for cheating
+	Record  string `json:"record,omitempty"` // AT URI of the offending record
+}
+
+// CreateReportHandler files a report about another player. It's written to
+// the reporter's repository and queued for the operators to review; a
+// cheating report flags its game as under review until then.
+func (s *Service) CreateReportHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.Context().Value(sessionTokenKey).(string); !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+	client := s.clientFor(r)
+
+	var req CreateReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if req.Subject == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("subject is required"))
+		return
+	}
+	if !reportReasons[req.Reason] {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("reason must be cheating, chat_abuse, spam_challenges or other"))
+		return
+	}
+	if req.Reason == atproto.ReportCheating && req.Game == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("game is required for cheating reports"))
+		return
+	}
+	if len(req.Details) > maxReportLength {
+		apierror.Write(w, apierror.ErrMessageTooLong)
+		return
+	}
+	if req.Record != "" && !strings.HasPrefix(req.Record, "at://") {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("record must be an AT URI"))
+		return
+	}
+
+	subject := req.Subject
+	if !strings.HasPrefix(subject, "did:") {
+		did, err := client.ResolveHandle(r.Context(), subject)
+		if err != nil {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage(fmt.Sprintf("Failed to resolve handle '%s'", subject)))
+			return
+		}
+		subject = did
+	}
+	if subject == client.GetDID() {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("You cannot report yourself"))
+		return
+	}
+
+	gameURI := req.Game
+	if gameURI != "" {
+		if !strings.HasPrefix(gameURI, "at://") {
+			decoded, err := s.decodeGameID(gameURI)
+			if err != nil || !strings.HasPrefix(decoded, "at://") {
+				apierror.Write(w, apierror.ErrInvalidGameID)
+				return
+			}
+			gameURI = decoded
+		}
+		game, err := client.GetGame(context.Background(), gameURI)
+		if err != nil {
+			log.Error().Err(err).Str("gameID", gameURI).Msg("Failed to fetch reported game")
+			apierror.Write(w, apierror.ErrGameNotFound)
+			return
+		}
+		if game.White != subject && game.Black != subject {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("The reported player did not play in this game"))
+			return
+		}
+	}
+
+	report := &Report{
+		ReportRecord: atproto.ReportRecord{
+			Reporter: client.GetDID(),
+			Subject:  subject,
+			Reason:   req.Reason,
+			Details:  req.Details,
+			Game:     gameURI,
+			Record:   req.Record,
+		},
+		ID:     newReportID(),
+		Status: ReportOpen,
+	}
+	if s.reports.Duplicate(report) {
+		apierror.Write(w, apierror.ErrAlreadyReported)
+		return
+	}
+
+	if _, err := client.CreateReport(context.Background(), &report.ReportRecord); err != nil {
+		if errors.Is(err, lexicon.ErrInvalidRecord) {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+			return
+		}
+		log.Error().Err(err).Msg("Failed to create report record")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to file report"))
+		return
+	}
+	if !s.reports.Add(report) {
+		apierror.Write(w, apierror.ErrAlreadyReported)
+		return
+	}
+
+	log.Info().Str("id", report.ID).Str("reporter", report.Reporter).Str("subject", report.Subject).Str("reason", report.Reason).Msg("Report filed")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// ListReportsHandler returns the review queue to operators: open reports,
+// oldest first, or those with the given status ("all" for every report),
+// optionally only those about one player
+func (s *Service) ListReportsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.Context().Value(sessionTokenKey).(string); !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+	if !s.isOperator(s.clientFor(r).GetDID()) {
+		apierror.Write(w, apierror.ErrNotOperator.WithMessage("Only operators can review reports"))
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = ReportOpen
+	case "all":
+		status = ""
+	case ReportOpen, ReportActioned, ReportDismissed:
+	default:
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("status must be open, actioned, dismissed or all"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"reports": s.reports.List(status, r.URL.Query().Get("subject")),
+	})
+}
+
+// ResolveReportRequest is the body of POST /api/admin/reports/{id}/resolve
+type ResolveReportRequest struct {
+	Status string `json:"status"` // actioned or dismissed
+	Note   string `json:"note,omitempty"`
+}
+
+// ResolveReportHandler records an operator's decision on a report. Acting on
+// a report hides the reported player's seeks; either decision lifts a game's
+// review flag once no open cheating reports about it remain.
+func (s *Service) ResolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.Context().Value(sessionTokenKey).(string); !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+	operator := s.clientFor(r).GetDID()
+	if !s.isOperator(operator) {
+		apierror.Write(w, apierror.ErrNotOperator.WithMessage("Only operators can review reports"))
+		return
+	}
+
+	var req ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if req.Status != ReportActioned && req.Status != ReportDismissed {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("status must be actioned or dismissed"))
+		return
+	}
+	if len(req.Note) > maxReportLength {
+		apierror.Write(w, apierror.ErrMessageTooLong)
+		return
+	}
+
+	report, ok := s.reports.Resolve(mux.Vars(r)["id"], req.Status, operator, req.Note)
+	if !ok {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Report not found"))
+		return
+	}
+
+	log.Info().Str("id", report.ID).Str("operator", operator).Str("status", report.Status).Str("subject", report.Subject).Msg("Report resolved")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func newReportID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
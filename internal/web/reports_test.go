@@ -0,0 +1,151 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+func TestReportsQueuedForOperatorReview(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	service.config.Server.OperatorDIDs = []string{"did:plc:operator"}
+	service.config.Moderation.HideSeeksAfter = 2
+	gameURI := seedGame(pds, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "active")
+	carol := newFakePDS(t, "did:plc:carol")
+	operator := newFakePDS(t, "did:plc:operator")
+
+	// Black has a seek in the lobby
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	service.SetGameIndex(indexer)
+	err := indexer.Apply(context.Background(), "create", testBlackDID, index.SeekCollection+"/s1", map[string]interface{}{
+		"$type":     index.SeekCollection,
+		"createdAt": time.Now().Format(time.RFC3339),
+		"player":    testBlackDID,
+		"status":    "open",
+		"color":     "random",
+		"expiresAt": time.Now().Add(time.Hour).Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("Failed to index seek: %v", err)
+	}
+	lobby := func() int {
+		w := httptest.NewRecorder()
+		service.ListSeeksHandler(w, httptest.NewRequest("GET", "/api/seeks", nil))
+		var resp struct {
+			Seeks []*index.Seek `json:"seeks"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return len(resp.Seeks)
+	}
+	underReview := func() bool {
+		id := base64.URLEncoding.EncodeToString([]byte(gameURI))
+		w := httptest.NewRecorder()
+		service.GetGameHandler(w, mux.SetURLVars(httptest.NewRequest("GET", "/api/games/"+id, nil), map[string]string{"id": id}))
+		var game chess.Game
+		_ = json.Unmarshal(w.Body.Bytes(), &game)
+		return game.UnderReview
+	}
+	report := func(reporter *fakePDS, req CreateReportRequest) *httptest.ResponseRecorder {
+		return sessionRequest(t, service, reporter, service.CreateReportHandler, "POST", "/api/reports", nil, req, true)
+	}
+
+	for _, req := range []CreateReportRequest{
+		{Subject: testBlackDID, Reason: "cheating"},
+		{Subject: testBlackDID, Reason: "trolling"},
+		{Subject: testWhiteDID, Reason: "other"},
+		{Subject: "did:plc:carol", Reason: "cheating", Game: gameURI},
+	} {
+		if w := report(pds, req); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %+v, got %d: %s", req, w.Code, w.Body.String())
+		}
+	}
+
+	cheating := CreateReportRequest{Subject: testBlackDID, Reason: "cheating", Details: "Every move matched the engine", Game: gameURI}
+	w := report(pds, cheating)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected the report to be filed, got %d: %s", w.Code, w.Body.String())
+	}
+	var filed Report
+	_ = json.Unmarshal(w.Body.Bytes(), &filed)
+	if records := pds.collection(testWhiteDID, "app.atchess.report"); len(records) != 1 || records[0] != filed.URI {
+		t.Errorf("Expected the report in the reporter's repository, got %v", records)
+	}
+	if w := report(pds, cheating); w.Code != http.StatusConflict || errorCode(t, w) != "already_reported" {
+		t.Errorf("Expected a second identical report to be refused, got %d: %s", w.Code, w.Body.String())
+	}
+	if !underReview() {
+		t.Error("Expected the game to be under review")
+	}
+	if n := lobby(); n != 1 {
+		t.Errorf("Expected one reporter not to hide black's seek, got %d seeks", n)
+	}
+
+	if w := report(carol, CreateReportRequest{Subject: testBlackDID, Reason: "chat_abuse"}); w.Code != http.StatusCreated {
+		t.Fatalf("Expected carol's report to be filed, got %d: %s", w.Code, w.Body.String())
+	}
+	if n := lobby(); n != 0 {
+		t.Errorf("Expected two reporters to hide black's seek, got %d seeks", n)
+	}
+
+	// Only operators see the queue
+	list := func(reviewer *fakePDS, query string) *httptest.ResponseRecorder {
+		return sessionRequest(t, service, reviewer, service.ListReportsHandler, "GET", "/api/admin/reports"+query, nil, nil, true)
+	}
+	if w := list(carol, ""); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a player, got %d", w.Code)
+	}
+	var queue struct {
+		Reports []Report `json:"reports"`
+	}
+	_ = json.Unmarshal(list(operator, "").Body.Bytes(), &queue)
+	if len(queue.Reports) != 2 || queue.Reports[0].ID != filed.ID {
+		t.Fatalf("Expected both reports, oldest first, got %+v", queue.Reports)
+	}
+
+	resolve := func(id, status string) *httptest.ResponseRecorder {
+		return sessionRequest(t, service, operator, service.ResolveReportHandler, "POST", "/api/admin/reports/"+id+"/resolve",
+			map[string]string{"id": id}, ResolveReportRequest{Status: status, Note: "Reviewed"}, true)
+	}
+	if w := resolve(filed.ID, "open"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 reopening a report, got %d", w.Code)
+	}
+	if w := resolve("missing", ReportDismissed); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown report, got %d", w.Code)
+	}
+
+	// Dismissing the cheating report clears the game and shows the seek again
+	if w := resolve(filed.ID, ReportDismissed); w.Code != http.StatusOK {
+		t.Fatalf("Expected the report to be dismissed, got %d: %s", w.Code, w.Body.String())
+	}
+	if underReview() {
+		t.Error("Expected the game to no longer be under review")
+	}
+	if n := lobby(); n != 1 {
+		t.Errorf("Expected black's seek back in the lobby, got %d seeks", n)
+	}
+
+	// Acting on carol's report hides it for good
+	if w := resolve(queue.Reports[1].ID, ReportActioned); w.Code != http.StatusOK {
+		t.Fatalf("Expected the report to be actioned, got %d: %s", w.Code, w.Body.String())
+	}
+	if n := lobby(); n != 0 {
+		t.Errorf("Expected black's seek to be hidden, got %d seeks", n)
+	}
+	_ = json.Unmarshal(list(operator, "?status=all").Body.Bytes(), &queue)
+	if len(queue.Reports) != 2 || queue.Reports[1].ResolvedBy != "did:plc:operator" || queue.Reports[1].Note != "Reviewed" {
+		t.Errorf("Expected both reports resolved by the operator, got %+v", queue.Reports)
+	}
+	_ = json.Unmarshal(list(operator, "").Body.Bytes(), &queue)
+	if len(queue.Reports) != 0 {
+		t.Errorf("Expected an empty queue, got %+v", queue.Reports)
+	}
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	didPattern    = regexp.MustCompile(`^did:[a-z]+:[a-zA-Z0-9._:%-]+$`)
+	handlePattern = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+	nsidPattern   = regexp.MustCompile(`^[a-zA-Z]([a-zA-Z0-9-]{0,62})?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,62})?)+$`)
+	rkeyPattern   = regexp.MustCompile(`^[a-zA-Z0-9._:~-]{1,512}$`)
+)
+
+// ErrUnsupportedRecord is returned for valid URIs that have no page in the app
+var ErrUnsupportedRecord = errors.New("no page for this record type")
+
+// RecordURI is a parsed at:// record URI
+type RecordURI struct {
+	Authority  string // DID or handle of the repository
+	Collection string
+	RKey       string
+}
+
+// String returns the at:// form of the URI
+func (u RecordURI) String() string {
+	return fmt.Sprintf("at://%s/%s/%s", u.Authority, u.Collection, u.RKey)
+}
+
+// ParseRecordURI validates an at://authority/collection/rkey URI
+func ParseRecordURI(uri string) (RecordURI, error) {
+	rest, ok := strings.CutPrefix(uri, "at://")
+	if !ok {
+		return RecordURI{}, fmt.Errorf("not an at:// URI: %q", uri)
+	}
+	// Fragments and queries aren't part of a record's identity
+	if i := strings.IndexAny(rest, "?#"); i >= 0 {
+		rest = rest[:i]
+	}
+
+	parts := strings.Split(strings.TrimSuffix(rest, "/"), "/")
+	if len(parts) != 3 {
+		return RecordURI{}, fmt.Errorf("URI must name a single record: %q", uri)
+	}
+	parsed := RecordURI{Authority: parts[0], Collection: parts[1], RKey: parts[2]}
+
+	if !didPattern.MatchString(parsed.Authority) && !handlePattern.MatchString(parsed.Authority) {
+		return RecordURI{}, fmt.Errorf("invalid repository %q", parsed.Authority)
+	}
+	if !nsidPattern.MatchString(parsed.Collection) {
+		return RecordURI{}, fmt.Errorf("invalid collection %q", parsed.Collection)
+	}
+	if !rkeyPattern.MatchString(parsed.RKey) || parsed.RKey == "." || parsed.RKey == ".." {
+		return RecordURI{}, fmt.Errorf("invalid record key %q", parsed.RKey)
+	}
+
+	return parsed, nil
+}
+
+// AppPath returns the path of the app page that shows a record
+func AppPath(uri RecordURI) (string, error) {
+	switch uri.Collection {
+	case "app.atchess.game":
+		// Game pages take the same URL-safe base64 IDs as the API
+		return "/?game=" + base64.URLEncoding.EncodeToString([]byte(uri.String())), nil
+	case "app.atchess.challenge":
+		return "/?challenge=" + url.QueryEscape(uri.String()), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedRecord, uri.Collection)
+}
+
+// ResolveHandler redirects GET /resolve?uri=at://... to the page for that
+// record, so links copied from other AT Protocol clients open in the app
+func ResolveHandler(w http.ResponseWriter, r *http.Request) {
+	raw := r.URL.Query().Get("uri")
+	if raw == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Missing uri parameter"))
+		return
+	}
+
+	uri, err := ParseRecordURI(raw)
+	if err != nil {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid AT Protocol URI: "+err.Error()))
+		return
+	}
+
+	path, err := AppPath(uri)
+	if err != nil {
+		log.Debug().Str("uri", raw).Msg("No page for record")
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("No page for "+uri.Collection+" records"))
+		return
+	}
+
+	http.Redirect(w, r, path, http.StatusFound)
+}
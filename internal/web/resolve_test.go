@@ -0,0 +1,47 @@
+package web
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestResolveHandler(t *testing.T) {
+	gameURI := "at://did:plc:white/app.atchess.game/3kgame"
+	challengeURI := "at://alice.bsky.social/app.atchess.challenge/3kchal"
+
+	tests := []struct {
+		name     string
+		uri      string
+		code     int
+		location string
+	}{
+		{"game", gameURI, http.StatusFound, "/?game=" + base64.URLEncoding.EncodeToString([]byte(gameURI))},
+		{"game with trailing slash", gameURI + "/", http.StatusFound, "/?game=" + base64.URLEncoding.EncodeToString([]byte(gameURI))},
+		{"challenge by handle", challengeURI, http.StatusFound, "/?challenge=" + url.QueryEscape(challengeURI)},
+		{"missing", "", http.StatusBadRequest, ""},
+		{"not at uri", "https://bsky.app/profile/alice", http.StatusBadRequest, ""},
+		{"repo only", "at://did:plc:white", http.StatusBadRequest, ""},
+		{"collection only", "at://did:plc:white/app.atchess.game", http.StatusBadRequest, ""},
+		{"bad repo", "at://not a did/app.atchess.game/1", http.StatusBadRequest, ""},
+		{"bad rkey", "at://did:plc:white/app.atchess.game/..", http.StatusBadRequest, ""},
+		{"unsupported collection", "at://did:plc:white/app.bsky.feed.post/3kpost", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/resolve?uri="+url.QueryEscape(tt.uri), nil)
+			w := httptest.NewRecorder()
+			ResolveHandler(w, req)
+
+			if w.Code != tt.code {
+				t.Fatalf("Expected %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if location := w.Header().Get("Location"); location != tt.location {
+				t.Errorf("Expected redirect to %q, got %q", tt.location, location)
+			}
+		})
+	}
+}
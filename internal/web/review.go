@@ -0,0 +1,330 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/boardimage"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ReviewDepth is the search depth used to review finished games
+	ReviewDepth = 2
+
+	// ReviewMaxMoments bounds how many key moments a shared review covers
+	ReviewMaxMoments = 6
+)
+
+// ReviewPost is one post of a review thread as it will be published
+type ReviewPost struct {
+	Text     string `json:"text"`
+	Ply      int    `json:"ply"` // the position shown in the image; 0 for none
+	ImageURL string `json:"imageUrl,omitempty"`
+	Alt      string `json:"alt,omitempty"`
+}
+
+// ReviewThread is the preview of a game review shared as a Bluesky thread
+type ReviewThread struct {
+	GameID string            `json:"gameId"`
+	Color  string            `json:"color"` // the side the reviewer played
+	Review *chess.GameReview `json:"review"`
+	Posts  []ReviewPost      `json:"posts"`
+
+	// moves are kept to render the images when publishing
+	moves []*chess.Move
+}
+
+// SharedReview describes a published review thread
+type SharedReview struct {
+	Posts []atproto.PostRef `json:"posts"`
+	URL   string            `json:"url"` // the first post on bsky.app
+}
+
+// PreviewReviewHandler returns the posts that sharing a finished game's
+// review would publish, so players can check them before posting
+func (s *Service) PreviewReviewHandler(w http.ResponseWriter, r *http.Request) {
+	thread, ok := s.loadReviewThread(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(thread)
+}
+
+// ShareReviewHandler publishes a finished game's review as a thread of posts
+// from the player's Bluesky account: a summary, then one post per key moment
+// with an image of the board and the engine's verdict. The posts are the
+// ones PreviewReviewHandler returns.
+func (s *Service) ShareReviewHandler(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := s.currentSession(r); !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	thread, ok := s.loadReviewThread(w, r)
+	if !ok {
+		return
+	}
+
+	posts := make([]atproto.Post, 0, len(thread.Posts))
+	for i, preview := range thread.Posts {
+		post := atproto.Post{Text: preview.Text}
+		if i == 0 {
+			post.Link = s.gameLink(thread.GameID)
+		}
+		if preview.Ply > 0 {
+			move := thread.moves[preview.Ply-1]
+			image, err := boardimage.PNG(move.FEN, boardimage.Options{Flipped: thread.Color == "black", Highlight: []string{move.From, move.To}})
+			if err != nil {
+				log.Error().Err(err).Str("gameID", thread.GameID).Int("ply", preview.Ply).Msg("Failed to render review image")
+				apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to render board image"))
+				return
+			}
+			post.Images = []atproto.PostImage{{
+				Data:     image,
+				MimeType: "image/png",
+				Alt:      preview.Alt,
+				Width:    boardimage.DefaultSize,
+				Height:   boardimage.DefaultSize,
+			}}
+		}
+		posts = append(posts, post)
+	}
+
+	client := s.clientFor(r)
+	refs, err := client.CreateThread(context.Background(), posts)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", thread.GameID).Int("published", len(refs)).Msg("Failed to publish review thread")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to publish review"))
+		return
+	}
+
+	shared := SharedReview{Posts: refs}
+	if len(refs) > 0 {
+		shared.URL = bskyPostURL(client.GetDID(), refs[0].URI)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(shared)
+}
+
+// ReviewImageHandler renders the board after a ply as a PNG. Pass
+// orientation=black to draw it from black's side.
+func (s *Service) ReviewImageHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID, err := s.decodeGameID(vars["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+	ply, err := strconv.Atoi(vars["ply"])
+	if err != nil || ply < 1 {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid ply"))
+		return
+	}
+
+	moves, err := s.readerFor(r).GetMoves(context.Background(), gameID)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to load moves for review image")
+		apierror.Write(w, apierror.ErrGameNotFound)
+		return
+	}
+	if ply > len(moves) {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("No such move"))
+		return
+	}
+
+	move := moves[ply-1]
+	image, err := boardimage.PNG(move.FEN, boardimage.Options{Flipped: r.URL.Query().Get("orientation") == "black", Highlight: []string{move.From, move.To}})
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Int("ply", ply).Msg("Failed to render review image")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to render board image"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	_, _ = w.Write(image)
+}
+
+// loadReviewThread reviews the requested game for one of its players and
+// lays the review out as posts. It writes an error response and returns
+// false if the game can't be reviewed.
+func (s *Service) loadReviewThread(w http.ResponseWriter, r *http.Request) (*ReviewThread, bool) {
+	encodedGameID := mux.Vars(r)["id"]
+	gameID, err := s.decodeGameID(encodedGameID)
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return nil, false
+	}
+
+	client := s.clientFor(r)
+	game, ok := s.loadGameForPlayer(w, client.GetGame, gameID, client.GetDID())
+	if !ok {
+		return nil, false
+	}
+	if game.Status == chess.StatusActive {
+		apierror.Write(w, apierror.ErrGameInProgress)
+		return nil, false
+	}
+
+	moves, err := client.GetMoves(context.Background(), gameID)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to load moves for review")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to load moves"))
+		return nil, false
+	}
+
+	review, err := chess.ReviewGame(chess.StartingFEN, moves, ReviewDepth, ReviewMaxMoments)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to review game")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to review game"))
+		return nil, false
+	}
+
+	color := "white"
+	if client.GetDID() == game.Black && game.Black != game.White {
+		color = "black"
+	}
+
+	thread := &ReviewThread{
+		GameID: gameID,
+		Color:  color,
+		Review: review,
+		moves:  moves,
+	}
+	thread.Posts = reviewPosts(game, color, moves, review, s.gameLink(gameID))
+
+	imageBase := "/api/games/" + encodedGameID + "/review/images/"
+	for i := range thread.Posts {
+		if ply := thread.Posts[i].Ply; ply > 0 {
+			thread.Posts[i].ImageURL = imageBase + strconv.Itoa(ply) + "?orientation=" + color
+		}
+	}
+
+	return thread, true
+}
+
+// reviewPosts writes the text of a review thread: a summary showing the
+// final position, then a post for each key moment
+func reviewPosts(game *chess.Game, color string, moves []*chess.Move, review *chess.GameReview, link string) []ReviewPost {
+	summary := fmt.Sprintf("Game review: I played %s and %s.", capitalize(color), reviewOutcome(game.Status, color, len(moves)))
+	switch len(review.Moments) {
+	case 0:
+		summary += " No big swings, a clean game."
+	case 1:
+		summary += " 1 key moment 🧵"
+	default:
+		summary += fmt.Sprintf(" %d key moments 🧵", len(review.Moments))
+	}
+	if link != "" {
+		summary += "\n\n" + link
+	}
+
+	root := ReviewPost{Text: summary}
+	if len(moves) > 0 {
+		last := len(moves)
+		root.Ply = last
+		root.Alt = boardAlt(moves[last-1], color, "Final position")
+	}
+	posts := []ReviewPost{root}
+
+	for _, moment := range review.Moments {
+		move := moves[moment.Ply-1]
+		posts = append(posts, ReviewPost{
+			Text: truncatePost(momentText(moment)),
+			Ply:  moment.Ply,
+			Alt:  boardAlt(move, color, "Position after "+moment.Notation()),
+		})
+	}
+
+	return posts
+}
+
+// momentText describes a key moment, e.g. "12. Nf3?? Blunder. The
+// evaluation went from +0.5 to -3.0. Better was Bxe5."
+func momentText(moment chess.ReviewMoment) string {
+	notation := moment.Notation()
+	switch moment.Classification {
+	case chess.ClassCheckmate:
+		return notation + " Checkmate."
+	case chess.ClassBlunder:
+		notation += "?? Blunder."
+	case chess.ClassMistake:
+		notation += "? Mistake."
+	}
+
+	text := fmt.Sprintf("%s The evaluation went from %s to %s.", notation, chess.FormatEval(moment.EvalBefore), chess.FormatEval(moment.EvalAfter))
+	if moment.BestSAN != "" {
+		text += " Better was " + moment.BestSAN + "."
+	}
+	return text
+}
+
+func reviewOutcome(status chess.GameStatus, color string, moves int) string {
+	fullMoves := (moves + 1) / 2
+	switch {
+	case status == chess.StatusDraw:
+		return fmt.Sprintf("drew in %d moves", fullMoves)
+	case status == chess.StatusAbandoned:
+		return fmt.Sprintf("the game was abandoned after %d moves", fullMoves)
+	case string(status) == color+"_won":
+		return fmt.Sprintf("won in %d moves", fullMoves)
+	}
+	return fmt.Sprintf("lost in %d moves", fullMoves)
+}
+
+func boardAlt(move *chess.Move, color, caption string) string {
+	return fmt.Sprintf("%s, seen from %s's side. The last move went from %s to %s.", caption, capitalize(color), move.From, move.To)
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// truncatePost shortens text to fit in a single post
+func truncatePost(text string) string {
+	if utf8.RuneCountInString(text) <= atproto.MaxPostLength {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:atproto.MaxPostLength-1]) + "…"
+}
+
+// gameLink returns the public URL of a game's page, or "" if this instance
+// doesn't know its own URL
+func (s *Service) gameLink(gameID string) string {
+	if s.config == nil || s.config.Server.BaseURL == "" {
+		return ""
+	}
+	uri, err := ParseRecordURI(gameID)
+	if err != nil {
+		return ""
+	}
+	path, err := AppPath(uri)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(s.config.Server.BaseURL, "/") + path
+}
+
+// bskyPostURL returns the bsky.app page of a post
+func bskyPostURL(did, postURI string) string {
+	parts := strings.Split(postURI, "/")
+	return "https://bsky.app/profile/" + did + "/post/" + parts[len(parts)-1]
+}
@@ -0,0 +1,169 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+// seedFoolsMate stores 1. f3 e5 2. g4 Qh4# across both players' repos
+func seedFoolsMate(pds *fakePDS, status string) string {
+	gameID := seedGame(pds, "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", status)
+	seedMove(pds, gameID, testWhiteDID, "m1", "f2", "f3", "f3", "rnbqkbnr/pppppppp/8/8/8/5P2/PPPPP1PP/RNBQKBNR b KQkq - 0 1")
+	seedMove(pds, gameID, testBlackDID, "m2", "e7", "e5", "e5", "rnbqkbnr/pppp1ppp/8/4p3/8/5P2/PPPPP1PP/RNBQKBNR w KQkq e6 0 2")
+	seedMove(pds, gameID, testWhiteDID, "m3", "g2", "g4", "g4", "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq g3 0 2")
+	seedMove(pds, gameID, testBlackDID, "m4", "d8", "h4", "Qh4#", "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3")
+	return gameID
+}
+
+func reviewRequest(handler http.HandlerFunc, s *Service, method, gameID, token string, vars map[string]string) *httptest.ResponseRecorder {
+	encoded := base64.URLEncoding.EncodeToString([]byte(gameID))
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	vars["id"] = encoded
+	req := mux.SetURLVars(httptest.NewRequest(method, "/api/games/"+encoded+"/review/thread", nil), vars)
+	if token != "" {
+		req.Header.Set(SessionHeader, token)
+	}
+	w := httptest.NewRecorder()
+	s.SessionMiddleware(handler).ServeHTTP(w, req)
+	return w
+}
+
+func TestPreviewReviewDescribesKeyMoments(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	gameID := seedFoolsMate(pds, "black_won")
+	service := newServiceForPDS(t, pds)
+	service.config.Server.BaseURL = "https://chess.example/"
+
+	w := reviewRequest(service.PreviewReviewHandler, service, "GET", gameID, ownSession(service), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected a preview, got %d: %s", w.Code, w.Body.String())
+	}
+	var thread ReviewThread
+	_ = json.Unmarshal(w.Body.Bytes(), &thread)
+	if thread.Color != "black" || len(thread.Posts) != 3 {
+		t.Fatalf("Expected a summary and two moments from black's side, got %+v", thread)
+	}
+
+	summary := thread.Posts[0]
+	for _, expected := range []string{"I played Black and won in 2 moves", "2 key moments", "https://chess.example/?game="} {
+		if !strings.Contains(summary.Text, expected) {
+			t.Errorf("Expected %q in the summary, got %q", expected, summary.Text)
+		}
+	}
+	if summary.Ply != 4 || !strings.HasSuffix(summary.ImageURL, "/review/images/4?orientation=black") {
+		t.Errorf("Expected the summary to show the final position, got %+v", summary)
+	}
+	if !strings.HasPrefix(thread.Posts[1].Text, "2. g4?? Blunder.") {
+		t.Errorf("Expected the blunder to be described, got %q", thread.Posts[1].Text)
+	}
+	if thread.Posts[2].Text != "2... Qh4# Checkmate." {
+		t.Errorf("Expected the mate to be described, got %q", thread.Posts[2].Text)
+	}
+	if len(pds.collection(testBlackDID, "app.bsky.feed.post")) != 0 {
+		t.Error("Expected previewing not to post")
+	}
+}
+
+func TestPreviewReviewRejectsUnfinishedAndOthersGames(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	gameID := seedFoolsMate(pds, "active")
+	service := newServiceForPDS(t, pds)
+
+	w := reviewRequest(service.PreviewReviewHandler, service, "GET", gameID, ownSession(service), nil)
+	if w.Code != http.StatusConflict || errorCode(t, w) != "game_in_progress" {
+		t.Errorf("Expected game_in_progress, got %d: %s", w.Code, w.Body.String())
+	}
+
+	spectatorPDS := newFakePDS(t, "did:plc:spectator")
+	gameID = seedFoolsMate(spectatorPDS, "black_won")
+	spectator := newServiceForPDS(t, spectatorPDS)
+	w = reviewRequest(spectator.PreviewReviewHandler, spectator, "GET", gameID, ownSession(spectator), nil)
+	if w.Code != http.StatusForbidden || errorCode(t, w) != "not_a_player" {
+		t.Errorf("Expected not_a_player, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestShareReviewPublishesThread(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	gameID := seedFoolsMate(pds, "black_won")
+	service := newServiceForPDS(t, pds)
+
+	w := reviewRequest(service.ShareReviewHandler, service, "POST", gameID, "", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected publishing to need a session, got %d: %s", w.Code, w.Body.String())
+	}
+
+	player, err := atproto.NewClient(pds.URL, "black", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	token, _ := service.Sessions().Create(player)
+
+	w = reviewRequest(service.ShareReviewHandler, service, "POST", gameID, token, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected the thread to be published, got %d: %s", w.Code, w.Body.String())
+	}
+	var shared SharedReview
+	_ = json.Unmarshal(w.Body.Bytes(), &shared)
+
+	posts := pds.collection(testBlackDID, "app.bsky.feed.post")
+	if len(posts) != 3 || len(shared.Posts) != 3 || shared.Posts[0].URI != posts[0] {
+		t.Fatalf("Expected three posts, got %v and %+v", posts, shared)
+	}
+	if !strings.HasPrefix(shared.URL, "https://bsky.app/profile/"+testBlackDID+"/post/") {
+		t.Errorf("Unexpected thread URL %q", shared.URL)
+	}
+	if len(pds.blobTypes) != 3 || pds.blobTypes[0] != "image/png" {
+		t.Errorf("Expected a PNG per post, got %v", pds.blobTypes)
+	}
+
+	root := pds.get(posts[0])
+	if _, ok := root["reply"]; ok {
+		t.Error("Expected the first post not to be a reply")
+	}
+	last := pds.get(posts[2])
+	reply, _ := last["reply"].(map[string]interface{})
+	rootRef, _ := reply["root"].(map[string]interface{})
+	parentRef, _ := reply["parent"].(map[string]interface{})
+	if rootRef["uri"] != posts[0] || parentRef["uri"] != posts[1] {
+		t.Errorf("Expected the last post to reply within the thread, got %v", reply)
+	}
+	embed, _ := last["embed"].(map[string]interface{})
+	images, _ := embed["images"].([]interface{})
+	if embed["$type"] != "app.bsky.embed.images" || len(images) != 1 {
+		t.Fatalf("Expected an embedded board image, got %v", embed)
+	}
+	if alt, _ := images[0].(map[string]interface{})["alt"].(string); !strings.Contains(alt, "2... Qh4#") {
+		t.Errorf("Expected alt text naming the move, got %q", alt)
+	}
+}
+
+func TestReviewImageRendersPosition(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedFoolsMate(pds, "black_won")
+	service := newServiceForPDS(t, pds)
+
+	w := reviewRequest(service.ReviewImageHandler, service, "GET", gameID, "", map[string]string{"ply": "4"})
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Expected a PNG, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := png.Decode(bytes.NewReader(w.Body.Bytes())); err != nil {
+		t.Errorf("Expected a valid PNG: %v", err)
+	}
+
+	w = reviewRequest(service.ReviewImageHandler, service, "GET", gameID, "", map[string]string{"ply": "5"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 past the last move, got %d", w.Code)
+	}
+}
@@ -0,0 +1,223 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/push"
+	"github.com/justinabrahms/atchess/internal/scheduler"
+	"github.com/rs/zerolog/log"
+)
+
+// Kinds of scheduled job
+const (
+	jobSyncDeadlines   = "sync_deadlines"   // finds active games to keep clocks for
+	jobClock           = "clock"            // finalizes a game once its player to move runs out of time
+	jobChallengeExpiry = "challenge_expiry" // expires a challenge nobody answered
+	jobSweepOffers     = "sweep_offers"     // expires superseded draw offers
+	jobCleanChallenges = "clean_challenges" // marks missed expiries and prunes notifications
+)
+
+// clockJobID names a game's clock job, so scheduling it again replaces it
+func clockJobID(gameURI string) string {
+	return jobClock + ":" + gameURI
+}
+
+// challengeJobID names a challenge's expiry job
+func challengeJobID(challengeURI string) string {
+	return jobChallengeExpiry + ":" + challengeURI
+}
+
+// SetScheduler runs time-based work on sched instead of waiting for players
+// to poll: correspondence games are finalized when a clock runs out,
+// challenges are expired when nobody answers them, and superseded draw
+// offers and stale challenge notifications are swept. Active games are looked for in the game index every
+// syncInterval, so timers lost with an in-memory job store come back.
+func (s *Service) SetScheduler(sched *scheduler.Scheduler, syncInterval time.Duration) {
+	s.scheduler = sched
+
+	sched.Handle(jobSyncDeadlines, func(ctx context.Context, job *scheduler.Job) (time.Time, error) {
+		return time.Now().Add(syncInterval), s.syncDeadlines(ctx)
+	})
+	sched.Handle(jobClock, s.runClock)
+	sched.Handle(jobChallengeExpiry, s.expireChallenge)
+	sched.Handle(jobSweepOffers, func(ctx context.Context, job *scheduler.Job) (time.Time, error) {
+		s.SweepSupersededOffers(ctx)
+		return time.Now().Add(offerSweepInterval), nil
+	})
+	sched.Handle(jobCleanChallenges, func(ctx context.Context, job *scheduler.Job) (time.Time, error) {
+		s.CleanupChallenges(ctx)
+		return time.Now().Add(challengeCleanupInterval), nil
+	})
+
+	// Recurring jobs carried over from before a restart keep their times
+	ctx := context.Background()
+	now := time.Now()
+	for _, kind := range []string{jobSyncDeadlines, jobSweepOffers, jobCleanChallenges} {
+		if err := sched.ScheduleIfAbsent(ctx, kind, kind, "", now); err != nil {
+			log.Error().Err(err).Str("kind", kind).Msg("Failed to schedule recurring job")
+		}
+	}
+}
+
+// syncDeadlines starts a clock for every active game in the index that
+// doesn't have one. Clocks reschedule themselves as moves are made.
+func (s *Service) syncDeadlines(ctx context.Context) error {
+	if s.gameIndex == nil {
+		return nil
+	}
+	query := index.Query{Status: "active", Limit: index.MaxLimit}
+	for {
+		page, err := s.gameIndex.ListGames(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to list active games: %w", err)
+		}
+		for _, game := range page.Games {
+			// Games without a time control are played by correspondence
+			if game.TimeControl != "" && game.TimeControl != "correspondence" {
+				continue
+			}
+			if err := s.scheduler.ScheduleIfAbsent(ctx, clockJobID(game.URI), jobClock, game.URI, time.Now()); err != nil {
+				return err
+			}
+		}
+		if page.Cursor == "" {
+			return nil
+		}
+		query.Cursor = page.Cursor
+	}
+}
+
+// runClock checks a game's move deadline, waiting until it passes and then
+// claiming the win for the player who isn't to move
+func (s *Service) runClock(ctx context.Context, job *scheduler.Job) (time.Time, error) {
+	gameURI := job.Subject
+	deadline, err := s.client.GetMoveDeadline(ctx, gameURI)
+	if errors.Is(err, atproto.ErrGameNotActive) || errors.Is(err, atproto.ErrNoMoveDeadline) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if time.Now().Before(deadline.Deadline) {
+		return deadline.Deadline, nil
+	}
+
+	game, err := s.client.GetGame(ctx, gameURI)
+	if err != nil {
+		return time.Time{}, err
+	}
+	winner := opponentOf(game, deadline.PlayerToMove)
+	if winner == "" {
+		return time.Time{}, nil
+	}
+	client := s.playerClient(winner)
+	if client == nil {
+		return time.Time{}, fmt.Errorf("no session for %s to claim the game with", winner)
+	}
+	if err := client.ClaimTimeVictory(ctx, gameURI); err != nil {
+		return time.Time{}, err
+	}
+	log.Info().Str("gameID", gameURI).Str("player", deadline.PlayerToMove).Msg("Player ran out of time")
+
+	result := map[string]interface{}{
+		"reason":   "timeout",
+		"winner":   winner,
+		"flagged":  deadline.PlayerToMove,
+		"deadline": deadline.Deadline,
+	}
+	if s.hub != nil {
+		s.hub.BroadcastGameUpdate(GameUpdate{GameID: gameURI, Type: "game_end", Data: result})
+	}
+	s.notifyPlayer(winner, NotificationTimeout, gameURI, result)
+	s.notifyPlayer(deadline.PlayerToMove, NotificationTimeout, gameURI, result)
+	s.sendPush(ctx, winner, push.Notification{Type: NotificationTimeout, Title: "You won on time", Body: "Your opponent ran out of time", GameID: gameURI})
+	s.sendPush(ctx, deadline.PlayerToMove, push.Notification{Type: NotificationTimeout, Title: "You lost on time", Body: "You ran out of time to move", GameID: gameURI})
+	return time.Time{}, nil
+}
+
+// playerClient returns a client that can write to a player's repository:
+// one of their sessions, or the service's own client if it's theirs. It
+// returns nil if the player isn't signed in.
+func (s *Service) playerClient(did string) *atproto.Client {
+	if sessions := s.sessions.ListForDID(did); len(sessions) > 0 {
+		return sessions[0].Client
+	}
+	if s.client.GetDID() == did {
+		return s.client
+	}
+	return nil
+}
+
+// scheduleChallengeExpiry expires a challenge once its expiresAt passes
+func (s *Service) scheduleChallengeExpiry(ctx context.Context, challengeURI, expiresAt string) {
+	if s.scheduler == nil {
+		return
+	}
+	due, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return
+	}
+	if err := s.scheduler.Schedule(ctx, challengeJobID(challengeURI), jobChallengeExpiry, challengeURI, due); err != nil {
+		log.Error().Err(err).Str("challenge", challengeURI).Msg("Failed to schedule challenge expiry")
+	}
+}
+
+// expireChallenge marks a challenge nobody answered as expired and tells
+// both players. Readers already treat a challenge past its expiresAt as
+// closed, so it's only marked if the challenger has a session to do it with.
+func (s *Service) expireChallenge(ctx context.Context, job *scheduler.Job) (time.Time, error) {
+	challenge, err := s.client.GetChallenge(ctx, job.Subject)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if challenge.Status != "pending" {
+		return time.Time{}, nil
+	}
+	if expiresAt, err := time.Parse(time.RFC3339, challenge.ExpiresAt); err == nil && time.Now().Before(expiresAt) {
+		return expiresAt, nil
+	}
+
+	if client := s.playerClient(challenge.Challenger); client != nil {
+		if _, err := client.ExpireChallenge(ctx, challenge.ID); err != nil {
+			return time.Time{}, err
+		}
+		challenge.Status = "expired"
+	}
+
+	s.notifyPlayer(challenge.Challenger, NotificationChallengeExpired, "", challenge)
+	s.notifyPlayer(challenge.Challenged, NotificationChallengeExpired, "", challenge)
+	s.sendPush(ctx, challenge.Challenger, push.Notification{Type: NotificationChallengeExpired, Title: "Challenge expired", Body: "Your challenge wasn't answered in time", URL: "/"})
+	return time.Time{}, nil
+}
+
+// sendPush sends a Web Push notification if push is enabled. Game notifications
+// open the game.
+func (s *Service) sendPush(ctx context.Context, playerDID string, notification push.Notification) {
+	if s.pushNotifier == nil || playerDID == "" {
+		return
+	}
+	if notification.URL == "" {
+		notification.URL = "/"
+		if notification.GameID != "" {
+			notification.URL += "?game=" + base64.URLEncoding.EncodeToString([]byte(notification.GameID))
+		}
+	}
+	s.pushNotifier.Notify(ctx, playerDID, notification)
+}
+
+// GameFinished stops the clock of a game that ended; register it with
+// index.Indexer.OnGameFinished
+func (s *Service) GameFinished(ctx context.Context, game *index.Game) {
+	if s.scheduler == nil {
+		return
+	}
+	if err := s.scheduler.Cancel(ctx, clockJobID(game.URI)); err != nil {
+		log.Warn().Err(err).Str("gameID", game.URI).Msg("Failed to stop clock of finished game")
+	}
+}
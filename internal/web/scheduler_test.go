@@ -0,0 +1,103 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/scheduler"
+)
+
+// afterE4FEN is the position after 1. e4, with black to move
+const afterE4FEN = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"
+
+func TestSchedulerFinalizesFlaggedGames(t *testing.T) {
+	ctx := context.Background()
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	service.SetGameIndex(indexer)
+
+	// Black has had since 2024 to answer 1. e4; the other game just started
+	recent := time.Now().UTC().Truncate(time.Second)
+	games := map[string]string{"flagged": "2024-01-01T00:00:00Z", "recent": recent.Format(time.RFC3339)}
+	for rkey, createdAt := range games {
+		record := map[string]interface{}{
+			"$type":     "app.atchess.game",
+			"createdAt": createdAt,
+			"white":     testWhiteDID,
+			"black":     testBlackDID,
+			"status":    "active",
+			"fen":       afterE4FEN,
+		}
+		pds.put(fmt.Sprintf("at://%s/app.atchess.game/%s", testWhiteDID, rkey), record)
+		if err := indexer.Apply(ctx, "create", testWhiteDID, "app.atchess.game/"+rkey, record); err != nil {
+			t.Fatalf("Failed to index %s: %v", rkey, err)
+		}
+	}
+	flagged := fmt.Sprintf("at://%s/app.atchess.game/flagged", testWhiteDID)
+	recentGame := fmt.Sprintf("at://%s/app.atchess.game/recent", testWhiteDID)
+
+	sched := scheduler.New(scheduler.NewMemoryStore(), scheduler.Options{})
+	service.SetScheduler(sched, time.Hour)
+
+	// The service account plays white, so it can claim the game itself
+	sched.RunDue(ctx)
+	sched.RunDue(ctx)
+
+	if status := pds.get(flagged)["status"]; status != "white_won" {
+		t.Errorf("Expected white to win on time, got %v", status)
+	}
+	if violations := pds.collection(testWhiteDID, "app.atchess.timeViolation"); len(violations) != 1 {
+		t.Errorf("Expected one time violation record, got %v", violations)
+	}
+	if job, _ := sched.Job(ctx, clockJobID(flagged)); job != nil {
+		t.Errorf("Expected the finished game's clock to stop, got %+v", job)
+	}
+
+	// The other game's clock waits for its deadline
+	job, _ := sched.Job(ctx, clockJobID(recentGame))
+	if job == nil || !job.Due.Equal(recent.Add(72*time.Hour)) {
+		t.Errorf("Expected the clock to run until %v, got %+v", recent.Add(72*time.Hour), job)
+	}
+	if status := pds.get(recentGame)["status"]; status != "active" {
+		t.Errorf("Expected the recent game to continue, got %v", status)
+	}
+}
+
+func TestSchedulerExpiresChallenges(t *testing.T) {
+	ctx := context.Background()
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+	sched := scheduler.New(scheduler.NewMemoryStore(), scheduler.Options{})
+	service.SetScheduler(sched, time.Hour)
+
+	challenge := func(rkey string, expiresAt time.Time) string {
+		uri := fmt.Sprintf("at://%s/app.atchess.challenge/%s", testWhiteDID, rkey)
+		pds.put(uri, map[string]interface{}{
+			"$type":      "app.atchess.challenge",
+			"createdAt":  expiresAt.Add(-24 * time.Hour).Format(time.RFC3339),
+			"challenger": testWhiteDID,
+			"challenged": testBlackDID,
+			"status":     "pending",
+			"expiresAt":  expiresAt.Format(time.RFC3339),
+		})
+		service.scheduleChallengeExpiry(ctx, uri, expiresAt.Format(time.RFC3339))
+		return uri
+	}
+	stale := challenge("stale", time.Now().Add(-time.Minute))
+	fresh := challenge("fresh", time.Now().Add(time.Hour))
+
+	sched.RunDue(ctx)
+	if value := pds.get(stale); value["status"] != "expired" || value["expiredAt"] == nil {
+		t.Errorf("Expected the stale challenge to expire, got %v", value)
+	}
+	if status := pds.get(fresh)["status"]; status != "pending" {
+		t.Errorf("Expected the fresh challenge to stay pending, got %v", status)
+	}
+	if job, _ := sched.Job(ctx, challengeJobID(fresh)); job == nil {
+		t.Error("Expected the fresh challenge's expiry to stay scheduled")
+	}
+}
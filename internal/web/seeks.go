@@ -0,0 +1,198 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/rs/zerolog/log"
+)
+
+// timeControlTypes are the time controls a seek may ask for
+var timeControlTypes = map[string]bool{
+	"correspondence": true,
+	"rapid":          true,
+	"blitz":          true,
+	"bullet":         true,
+}
+
+// CreateSeekRequest publishes an open challenge to the lobby
+type CreateSeekRequest struct {
+	Color       string             `json:"color,omitempty"` // white, black or random (default)
+	TimeControl *chess.TimeControl `json:"timeControl,omitempty"`
+	MinRating   int                `json:"minRating,omitempty"`
+	MaxRating   int                `json:"maxRating,omitempty"`
+}
+
+// validate fills in defaults and checks the request
+func (req *CreateSeekRequest) validate() error {
+	switch req.Color {
+	case "":
+		req.Color = "random"
+	case "white", "black", "random":
+	default:
+		return errors.New("color must be white, black or random")
+	}
+	if req.TimeControl != nil && !timeControlTypes[req.TimeControl.Type] {
+		return errors.New("timeControl type must be correspondence, rapid, blitz or bullet")
+	}
+	if req.MinRating < 0 || req.MaxRating < 0 {
+		return errors.New("rating range can't be negative")
+	}
+	if req.MaxRating > 0 && req.MinRating > req.MaxRating {
+		return errors.New("minRating must not be above maxRating")
+	}
+	return nil
+}
+
+// CreateSeekHandler publishes an app.atchess.seek record in the player's repository
+func (s *Service) CreateSeekHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateSeekRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if req.TimeControl == nil {
+		req.TimeControl = s.loadPreferences(r.Context(), s.clientFor(r)).DefaultTimeControl
+	}
+	if err := req.validate(); err != nil {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+		return
+	}
+
+	seek, err := s.clientFor(r).CreateSeek(context.Background(), &chess.Seek{
+		Color:       req.Color,
+		TimeControl: req.TimeControl,
+		MinRating:   req.MinRating,
+		MaxRating:   req.MaxRating,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create seek")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to create seek"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(seek)
+}
+
+// ListSeeksHandler returns the open seeks seen on the network, newest first.
+// They can be filtered by timeControl, and by rating to only show seeks a
+// player with that rating may accept. Seeks of reported players may be
+// hidden; see seeksHidden.
+func (s *Service) ListSeeksHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query := index.SeekQuery{TimeControl: params.Get("timeControl")}
+	if rating := params.Get("rating"); rating != "" {
+		n, err := strconv.Atoi(rating)
+		if err != nil || n < 1 {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid rating"))
+			return
+		}
+		query.Rating = n
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			apierror.Write(w, apierror.ErrInvalidLimit)
+			return
+		}
+		query.Limit = n
+	}
+
+	seeks := []*index.Seek{}
+	if s.gameIndex != nil {
+		listed, err := s.gameIndex.ListSeeks(r.Context(), query)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to list seeks")
+			apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list seeks"))
+			return
+		}
+		// Seeks of players held for moderation are left out of the lobby
+		for _, seek := range listed {
+			if !s.seeksHidden(seek.Player) {
+				seeks = append(seeks, seek)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"seeks": seeks,
+	})
+}
+
+// seekError maps seek acceptance errors to API errors
+func seekError(err error) *apierror.Error {
+	switch {
+	case errors.Is(err, atproto.ErrOwnSeek):
+		return apierror.ErrOwnSeek
+	case errors.Is(err, atproto.ErrSeekNotOpen):
+		return apierror.ErrSeekNotOpen
+	default:
+		return apierror.ErrInternal.WithMessage("Failed to accept seek")
+	}
+}
+
+// AcceptSeekHandler pairs the current player with a seek's author. The game is
+// created in the accepter's repository, with the seek's time control.
+func (s *Service) AcceptSeekHandler(w http.ResponseWriter, r *http.Request) {
+	seekURI := mux.Vars(r)["id"]
+	if !strings.HasPrefix(seekURI, "at://") {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid seek URI"))
+		return
+	}
+	client := s.clientFor(r)
+
+	// The rating range can only be enforced where ratings are computed
+	if s.ratings != nil {
+		seek, err := client.GetSeek(r.Context(), seekURI)
+		if err != nil {
+			log.Error().Err(err).Str("seek", seekURI).Msg("Failed to load seek")
+			apierror.Write(w, apierror.ErrNotFound.WithMessage("Seek not found"))
+			return
+		}
+		rating := int(math.Round(s.ratings.Get(client.GetDID()).Rating))
+		if !seek.AcceptsRating(rating) {
+			apierror.Write(w, apierror.ErrForbidden.WithMessage("Your rating is outside this seek's range"))
+			return
+		}
+	}
+
+	game, err := client.AcceptSeek(r.Context(), seekURI)
+	if err != nil {
+		log.Error().Err(err).Str("seek", seekURI).Msg("Failed to accept seek")
+		apierror.Write(w, seekError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(game)
+}
+
+// DeleteSeekHandler withdraws one of the current player's seeks
+func (s *Service) DeleteSeekHandler(w http.ResponseWriter, r *http.Request) {
+	seekURI := mux.Vars(r)["id"]
+	client := s.clientFor(r)
+	if !strings.HasPrefix(seekURI, "at://"+client.GetDID()+"/") {
+		apierror.Write(w, apierror.ErrForbidden.WithMessage("You can only withdraw your own seeks"))
+		return
+	}
+
+	if err := client.DeleteSeek(r.Context(), seekURI); err != nil {
+		log.Error().Err(err).Str("seek", seekURI).Msg("Failed to delete seek")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to withdraw seek"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
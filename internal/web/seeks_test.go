@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/rating"
+)
+
+// newSeekService sets up a service whose own account (white) publishes seeks,
+// and a signed-in opponent (black) to accept them
+func newSeekService(t *testing.T) (*Service, *fakePDS, string) {
+	t.Helper()
+	pds := newFakePDS(t, testBlackDID)
+	opponent, err := atproto.NewClient(pds.URL, "black", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	pds.did = testWhiteDID
+	service := newServiceForPDS(t, pds)
+	token, _ := service.Sessions().Create(opponent)
+	return service, pds, token
+}
+
+func createSeek(t *testing.T, s *Service, body map[string]interface{}) *chess.Seek {
+	t.Helper()
+	w := serveAs(s.CreateSeekHandler, s, ownSession(s), "/api/seeks", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected seek to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	var seek chess.Seek
+	_ = json.Unmarshal(w.Body.Bytes(), &seek)
+	return &seek
+}
+
+func acceptSeek(s *Service, token, seekURI string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/seeks/"+seekURI+"/accept", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": seekURI})
+	if token != "" {
+		req.Header.Set(SessionHeader, token)
+	}
+	w := httptest.NewRecorder()
+	s.SessionMiddleware(http.HandlerFunc(s.AcceptSeekHandler)).ServeHTTP(w, req)
+	return w
+}
+
+func TestAcceptSeekCreatesGame(t *testing.T) {
+	service, pds, token := newSeekService(t)
+
+	seek := createSeek(t, service, map[string]interface{}{
+		"color":       "black",
+		"timeControl": map[string]interface{}{"type": "blitz", "initial": 300, "increment": 2},
+	})
+	if seek.Player != testWhiteDID || !strings.HasPrefix(seek.ID, "at://"+testWhiteDID+"/app.atchess.seek/") {
+		t.Fatalf("Unexpected seek: %+v", seek)
+	}
+
+	w := acceptSeek(service, token, seek.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected seek to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+	var game chess.Game
+	_ = json.Unmarshal(w.Body.Bytes(), &game)
+	// The seeker asked for black, so the accepter plays white
+	if game.White != testBlackDID || game.Black != testWhiteDID {
+		t.Errorf("Unexpected colors: white %s, black %s", game.White, game.Black)
+	}
+
+	record := pds.get(game.ID)
+	if ref, _ := record["seek"].(map[string]interface{}); ref["uri"] != seek.ID {
+		t.Errorf("Expected the game to reference the seek, got %v", record["seek"])
+	}
+	if tc, _ := record["timeControl"].(map[string]interface{}); tc["type"] != "blitz" || tc["initial"] != float64(300) {
+		t.Errorf("Expected the seek's time control on the game, got %v", record["timeControl"])
+	}
+	if status := pds.get(seek.ID)["status"]; status != "matched" {
+		t.Errorf("Expected the seek to be marked matched, got %v", status)
+	}
+
+	// The seeker finds the game through the reference on their seek
+	games, err := service.client.ListGames(context.Background(), testWhiteDID, "")
+	if err != nil || len(games) != 1 || games[0].ID != game.ID {
+		t.Errorf("Expected the seeker to see the game, got %v (%v)", games, err)
+	}
+
+	if w := acceptSeek(service, token, seek.ID); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a matched seek, got %d", w.Code)
+	}
+	other := createSeek(t, service, map[string]interface{}{})
+	if w := acceptSeek(service, ownSession(service), other.ID); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for accepting your own seek, got %d", w.Code)
+	}
+}
+
+func TestAcceptSeekEnforcesRatingRange(t *testing.T) {
+	service, _, token := newSeekService(t)
+	service.SetRatings(rating.NewRatings(nil))
+
+	// New players start at 1500
+	seek := createSeek(t, service, map[string]interface{}{"minRating": 1600})
+	if w := acceptSeek(service, token, seek.ID); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 outside the rating range, got %d", w.Code)
+	}
+
+	seek = createSeek(t, service, map[string]interface{}{"minRating": 1400, "maxRating": 1600})
+	if w := acceptSeek(service, token, seek.ID); w.Code != http.StatusOK {
+		t.Errorf("Expected a rating inside the range to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCreateSeekValidation(t *testing.T) {
+	service, _, _ := newSeekService(t)
+
+	for _, body := range []map[string]interface{}{
+		{"color": "purple"},
+		{"timeControl": map[string]interface{}{"type": "hourglass"}},
+		{"minRating": 1800, "maxRating": 1200},
+		{"minRating": -1},
+	} {
+		if w := serveAs(service.CreateSeekHandler, service, ownSession(service), "/api/seeks", body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %v, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestListSeeksFromIndex(t *testing.T) {
+	service, pds, _ := newSeekService(t)
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	service.SetGameIndex(indexer)
+
+	blitz := createSeek(t, service, map[string]interface{}{"timeControl": map[string]interface{}{"type": "blitz"}})
+	createSeek(t, service, map[string]interface{}{"timeControl": map[string]interface{}{"type": "rapid"}, "maxRating": 1200})
+	// Index the seeks as the firehose would
+	for _, uri := range pds.collection(testWhiteDID, index.SeekCollection) {
+		path := strings.TrimPrefix(uri, "at://"+testWhiteDID+"/")
+		if err := indexer.Apply(context.Background(), "create", testWhiteDID, path, pds.get(uri)); err != nil {
+			t.Fatalf("Failed to index seek: %v", err)
+		}
+	}
+
+	list := func(query string) []*index.Seek {
+		req := httptest.NewRequest("GET", "/api/seeks"+query, nil)
+		w := httptest.NewRecorder()
+		service.ListSeeksHandler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected seeks to be listed, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Seeks []*index.Seek `json:"seeks"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Seeks
+	}
+
+	if seeks := list(""); len(seeks) != 2 {
+		t.Errorf("Expected 2 seeks, got %d", len(seeks))
+	}
+	if seeks := list("?timeControl=blitz"); len(seeks) != 1 || seeks[0].URI != blitz.ID {
+		t.Errorf("Expected only the blitz seek, got %v", seeks)
+	}
+	if seeks := list("?rating=1500"); len(seeks) != 1 || seeks[0].URI != blitz.ID {
+		t.Errorf("Expected the capped seek to be hidden from a 1500 player, got %v", seeks)
+	}
+}
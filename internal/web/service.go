@@ -4,21 +4,66 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
 	"github.com/justinabrahms/atchess/internal/atproto"
 	"github.com/justinabrahms/atchess/internal/chess"
 	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/justinabrahms/atchess/internal/federation"
+	"github.com/justinabrahms/atchess/internal/importer"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/puzzle"
+	"github.com/justinabrahms/atchess/internal/rating"
+	"github.com/justinabrahms/atchess/internal/requestid"
+	"github.com/justinabrahms/atchess/internal/push"
+	"github.com/justinabrahms/atchess/internal/scheduler"
+	"github.com/justinabrahms/atchess/internal/study"
+	"github.com/justinabrahms/atchess/internal/webhook"
 	"github.com/rs/zerolog/log"
 )
 
 type Service struct {
-	client      *atproto.Client
-	config      *config.Config
-	oauthClient OAuthClientInterface
+	client        *atproto.Client
+	config        *config.Config
+	oauthClient   OAuthClientInterface
+	sessions      *ClientSessionStore
+	kibitzer      *Kibitzer
+	tv            *TV
+	federation    *federation.Directory
+	drafts        *DraftStore
+	preferences   *PreferenceStore
+	announcements *AnnouncementStore
+	reports       *ReportStore
+	gameIndex     *index.Indexer
+	ratings       *rating.Ratings
+	studies       *study.Studies
+	importer      *importer.Fetcher
+	pgnImports    *PGNImports
+	connections   *ConnectionMonitor
+	bot           *BotPlayer
+	matchmaker    *Matchmaker
+	puzzles       *puzzle.Puzzles
+	analyzer      *GameAnalyzer
+	cheatDetector *CheatDetector
+	hub           *Hub
+	chatLimiter   *rateLimiter
+	rateLimits    RateLimitStore
+	statsCache    *responseCache
+	webhooks      *webhook.Dispatcher
+	pushNotifier  *push.Notifier
+	emailDigest   *EmailDigest
+	botTokens     *BotTokenStore
+	botEvents     *botEvents
+	scheduler     *scheduler.Scheduler
+	
+	// wrapTransport is applied to every per-user client created at login
+	wrapTransport func(http.RoundTripper) http.RoundTripper
 }
 
 // OAuthClientInterface defines the methods we need from the OAuth client
@@ -28,11 +73,30 @@ type OAuthClientInterface interface {
 
 func NewService(client *atproto.Client, config *config.Config) *Service {
 	return &Service{
-		client: client,
-		config: config,
+		client:        client,
+		config:        config,
+		sessions:      NewClientSessionStore(DefaultSessionTTL),
+		drafts:        NewDraftStore(),
+		preferences:   NewPreferenceStore(),
+		announcements: NewAnnouncementStore(),
+		reports:       NewReportStore(),
+		chatLimiter:   newRateLimiter(),
+		statsCache:    newResponseCache(instanceStatsTTL),
+		botTokens:     NewBotTokenStore(),
+		botEvents:     newBotEvents(),
 	}
 }
 
+// Sessions returns the store of per-user authenticated clients
+func (s *Service) Sessions() *ClientSessionStore {
+	return s.sessions
+}
+
+// SetClientTransportWrapper installs a transport wrapper on clients created for logged-in users
+func (s *Service) SetClientTransportWrapper(wrap func(http.RoundTripper) http.RoundTripper) {
+	s.wrapTransport = wrap
+}
+
 // SetOAuthClient sets the OAuth client for the service
 func (s *Service) SetOAuthClient(oauthClient OAuthClientInterface) {
 	s.oauthClient = oauthClient
@@ -64,19 +128,45 @@ func (s *Service) HealthHandler(w http.ResponseWriter, r *http.Request) {
 type CreateGameRequest struct {
 	OpponentDID string `json:"opponent_did"`
 	Color       string `json:"color"`
+	Variant     string `json:"variant,omitempty"` // defaults to standard chess
 }
 
 func (s *Service) CreateGameHandler(w http.ResponseWriter, r *http.Request) {
 	var req CreateGameRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	
+	level, isBot, err := parseBotOpponent(req.OpponentDID)
+	if err != nil {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+		return
+	}
+	variant, err := chess.LookupVariant(req.Variant)
+	if err != nil {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+		return
+	}
+	if isBot {
+		// The bots only know the rules of standard chess
+		if variant.Name() != chess.VariantStandard {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("bots only play standard chess"))
+			return
+		}
+		s.createBotGame(w, r, level, req.Color)
 		return
 	}
 	
-	game, err := s.client.CreateGame(context.Background(), req.OpponentDID, req.Color)
+	var game *chess.Game
+	if variant.Name() == chess.VariantStandard {
+		game, err = s.clientFor(r).CreateGame(context.Background(), req.OpponentDID, req.Color)
+	} else {
+		game, err = s.clientFor(r).CreateVariantGame(context.Background(), req.OpponentDID, req.Color, variant.Name())
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create game")
-		http.Error(w, "Failed to create game", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to create game"))
 		return
 	}
 	
@@ -84,64 +174,251 @@ func (s *Service) CreateGameHandler(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(game)
 }
 
+// ListGamesHandler lists the games of a player, defaulting to the logged-in user.
+// Supports ?status=active|finished, ?role=white|black and ?did=.
+func (s *Service) ListGamesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	client := s.readerFor(r)
+	
+	status := query.Get("status")
+	if status != "" && status != "active" && status != "finished" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("status must be active or finished"))
+		return
+	}
+	
+	role := query.Get("role")
+	if role != "" && role != "white" && role != "black" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("role must be white or black"))
+		return
+	}
+	
+	did := query.Get("did")
+	if did == "" {
+		did = s.callerDID(r)
+	}
+	if did == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("did is required without a session"))
+		return
+	}
+	
+	games, err := client.ListGames(context.Background(), did, status)
+	if err != nil {
+		log.Error().Err(err).Str("did", did).Msg("Failed to list games")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list games"))
+		return
+	}
+	
+	filtered := []*chess.Game{}
+	for _, game := range games {
+		if (role == "white" && game.White != did) || (role == "black" && game.Black != did) {
+			continue
+		}
+		filtered = append(filtered, game)
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"games": filtered,
+		"total": len(filtered),
+	})
+}
+
 type MakeMoveRequest struct {
 	From      string `json:"from"`
 	To        string `json:"to"`
 	Promotion string `json:"promotion,omitempty"`
+	// Move is an alternative to from/to: the move in SAN ("Nf3", "O-O") or
+	// UCI ("e2e4", "e7e8q") notation, told apart automatically
+	Move      string `json:"move,omitempty"`
 	FEN       string `json:"fen"`
 	GameID    string `json:"game_id,omitempty"`
+	// Share posts the result to the player's Bluesky feed if the move ends
+	// the game
+	Share *ShareGameRequest `json:"share,omitempty"`
+	// ThinkTimeMs is how long the player spent on the move by the client's
+	// clock. It's recorded unless it's longer than the server saw.
+	ThinkTimeMs int64 `json:"thinkTimeMs,omitempty"`
+}
+
+// MakeMoveResponse is the move as played, and the result post if one was
+// shared
+type MakeMoveResponse struct {
+	*chess.MoveResult
+	Shared *SharedResult `json:"shared,omitempty"`
 }
 
 func (s *Service) MakeMoveHandler(w http.ResponseWriter, r *http.Request) {
 	var req MakeMoveRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidBody)
 		return
 	}
 	
 	// Game ID must be provided in request body
-	gameID := req.GameID
-	if gameID == "" {
-		http.Error(w, "game_id is required in request body", http.StatusBadRequest)
+	if req.GameID == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("game_id is required in request body"))
 		return
 	}
 	
-	// Log for debugging
-	log.Info().Str("gameID", gameID).Str("from", req.From).Str("to", req.To).Str("fen", req.FEN).Str("path", r.URL.Path).Msg("MakeMoveHandler called")
+	s.makeMove(w, r, req)
+}
+
+// makeMove plays a move for the request's user and writes the response
+func (s *Service) makeMove(w http.ResponseWriter, r *http.Request, req MakeMoveRequest) {
+	logger := requestid.Logger(r.Context())
+	gameID := req.GameID
 	
-	// Create chess engine from current position
-	engine, err := chess.NewEngineFromFEN(req.FEN)
+	// Log for debugging
+	logger.Info().Str("gameID", gameID).Str("from", req.From).Str("to", req.To).Str("move", req.Move).Str("fen", req.FEN).Str("path", r.URL.Path).Msg("MakeMoveHandler called")
+
+	client := s.clientFor(r)
+	// Keep the request's trace, but finish recording the move even if the
+	// player disconnects
+	ctx := context.WithoutCancel(r.Context())
+
+	// Load the canonical game record - the submitted FEN is never trusted on its own
+	game, err := client.GetGame(ctx, gameID)
 	if err != nil {
-		log.Error().Err(err).Str("fen", req.FEN).Msg("Invalid FEN")
-		http.Error(w, "Invalid FEN", http.StatusBadRequest)
+		logger.Error().Err(err).Str("gameID", gameID).Msg("Failed to fetch game for move")
+		apierror.Write(w, apierror.ErrGameNotFound)
+		return
+	}
+
+	if game.Status != chess.StatusActive {
+		apierror.Write(w, apierror.ErrGameFinished.WithMessage(fmt.Sprintf("Game is not active (status: %s)", game.Status)))
+		return
+	}
+
+	// Only the player whose turn it is may move
+	actorDID := client.GetDID()
+	if actorDID != game.White && actorDID != game.Black {
+		logger.Warn().Str("gameID", gameID).Str("did", actorDID).Msg("Move attempted by non-player")
+		apierror.Write(w, apierror.ErrNotAPlayer)
+		return
+	}
+
+	playerToMove, err := game.PlayerToMove()
+	if err != nil {
+		logger.Error().Err(err).Str("gameID", gameID).Str("fen", game.FEN).Msg("Stored game has invalid FEN")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Game has an invalid position"))
+		return
+	}
+
+	if playerToMove != actorDID {
+		logger.Warn().Str("gameID", gameID).Str("did", actorDID).Msg("Out-of-turn move rejected")
+		apierror.Write(w, apierror.ErrNotYourTurn)
 		return
 	}
 	
-	// Parse promotion
-	promotion := chess.ParsePromotion(req.Promotion)
+	// The computer's moves are only ever made by the bot itself
+	if game.Bot != nil && game.Bot.Player == actorDID {
+		apierror.Write(w, apierror.ErrBotMove)
+		return
+	}
+
+	// A client working from a different position is out of date
+	if req.FEN != "" && req.FEN != game.FEN {
+		logger.Warn().Str("gameID", gameID).Str("submittedFEN", req.FEN).Str("storedFEN", game.FEN).Msg("Move submitted against stale position")
+		apierror.Write(w, apierror.ErrPositionMismatch)
+		return
+	}
+
+	// Create chess engine from the stored position, under the game's rules
+	engine, err := chess.NewVariantEngine(game.Variant, game.FEN)
+	if err != nil {
+		logger.Error().Err(err).Str("fen", game.FEN).Msg("Invalid FEN")
+		apierror.Write(w, apierror.ErrInvalidFEN)
+		return
+	}
 	
-	// Make move
-	moveResult, err := engine.MakeMove(req.From, req.To, promotion)
+	// Make move, as written in whichever notation was submitted
+	var moveResult *chess.MoveResult
+	switch {
+	case req.Move != "" && chess.IsUCI(req.Move):
+		moveResult, err = engine.MakeMoveUCI(req.Move)
+	case req.Move != "":
+		moveResult, err = engine.MakeMoveSAN(req.Move)
+	default:
+		moveResult, err = engine.MakeMove(req.From, req.To, chess.ParsePromotion(req.Promotion))
+		// A pawn reaching the last rank without saying what it becomes is
+		// queened for players who've asked for that
+		if err != nil && req.Promotion == "" && (strings.HasSuffix(req.To, "8") || strings.HasSuffix(req.To, "1")) &&
+			s.loadPreferences(ctx, client).AutoQueen {
+			moveResult, err = engine.MakeMove(req.From, req.To, chess.ParsePromotion("q"))
+		}
+	}
 	if err != nil {
-		log.Error().Err(err).Str("from", req.From).Str("to", req.To).Msg("Invalid move")
-		http.Error(w, fmt.Sprintf("Invalid move: %s", err.Error()), http.StatusBadRequest)
+		logger.Error().Err(err).Str("from", req.From).Str("to", req.To).Str("move", req.Move).Msg("Invalid move")
+		apierror.Write(w, apierror.ErrInvalidMove.WithMessage(fmt.Sprintf("Invalid move: %s", err.Error())))
 		return
 	}
 	
 	// Log move result
-	log.Info().Str("gameID", gameID).Str("san", moveResult.SAN).Str("resultFEN", moveResult.FEN).Bool("check", moveResult.Check).Bool("checkmate", moveResult.Checkmate).Msg("Move executed successfully")
+	logger.Info().Str("gameID", gameID).Str("san", moveResult.SAN).Str("resultFEN", moveResult.FEN).Bool("check", moveResult.Check).Bool("checkmate", moveResult.Checkmate).Msg("Move executed successfully")
 	
 	// Record move in AT Protocol
-	if err := s.client.RecordMove(context.Background(), gameID, moveResult); err != nil {
-		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to record move")
-		http.Error(w, "Failed to record move", http.StatusInternalServerError)
+	moveResult.ThinkTimeMs = req.ThinkTimeMs
+	if err := client.RecordMove(ctx, gameID, moveResult); err != nil {
+		if errors.Is(err, atproto.ErrDuplicateMove) {
+			logger.Info().Err(err).Str("gameID", gameID).Msg("Duplicate move rejected")
+			apierror.Write(w, apierror.ErrDuplicateMove)
+			return
+		}
+		if errors.Is(err, atproto.ErrConflict) {
+			logger.Info().Err(err).Str("gameID", gameID).Msg("Move conflicted with a concurrent update")
+			apierror.Write(w, apierror.ErrConflict.WithMessage("The game changed while your move was being recorded"))
+			return
+		}
+		logger.Error().Err(err).Str("gameID", gameID).Msg("Failed to record move")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to record move"))
 		return
 	}
 	
-	log.Info().Str("gameID", gameID).Msg("Move recorded in AT Protocol successfully")
+	logger.Info().Str("gameID", gameID).Msg("Move recorded in AT Protocol successfully")
+	
+	s.moveRecorded(ctx, client, game, moveResult)
+	
+	response := MakeMoveResponse{MoveResult: moveResult}
+	if moveResult.GameOver {
+		response.Shared = s.shareIfRequested(r, client, game, moveResult.Status, req.Share)
+	}
 	
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(moveResult)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// moveRecorded follows up a move recorded for client's user: their draft and
+// draw offers lapse, and the bot or their opponent is told it's their turn
+func (s *Service) moveRecorded(ctx context.Context, client *atproto.Client, game *chess.Game, moveResult *chess.MoveResult) {
+	logger := requestid.Logger(ctx)
+	actorDID := client.GetDID()
+	
+	// The reply has been sent, so any draft for it is obsolete
+	s.drafts.Delete(actorDID, game.ID)
+	// Moving again withdraws the player's own draw offers
+	s.withdrawDrawOffers(client, game)
+	
+	if s.connections != nil {
+		s.connections.RecordMove(game.ID)
+	}
+	
+	// A game-ending move in a game whose record is the opponent's settles it
+	// with their session, if they have one here
+	if moveResult.GameOver {
+		s.FinalizeMovedGame(ctx, game.ID, actorDID)
+	}
+	
+	if game.Bot != nil && s.bot != nil {
+		if err := s.bot.ApplyMove(context.Background(), game, moveResult); err != nil {
+			logger.Error().Err(err).Str("gameID", game.ID).Msg("Failed to hand move to bot")
+		}
+	} else if !moveResult.GameOver {
+		s.notifyPlayer(opponentOf(game, actorDID), NotificationYourMove, game.ID, map[string]interface{}{
+			"opponent": actorDID,
+			"san":      moveResult.SAN,
+			"fen":      moveResult.FEN,
+		})
+	}
 }
 
 type CreateChallengeRequest struct {
@@ -158,62 +435,115 @@ func (s *Service) GetGameHandler(w http.ResponseWriter, r *http.Request) {
 	gameID, err := s.decodeGameID(encodedGameID)
 	if err != nil {
 		log.Error().Err(err).Str("encodedGameID", encodedGameID).Msg("Failed to decode game ID")
-		http.Error(w, "Invalid game ID", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidGameID)
 		return
 	}
 	
 	// Log for debugging
 	log.Info().Str("gameID", gameID).Str("encodedGameID", encodedGameID).Str("path", r.URL.Path).Msg("GetGameHandler called")
 	
-	// Fetch game from AT Protocol
-	game, err := s.client.GetGame(context.Background(), gameID)
+	// Fetch game from AT Protocol. Games are settled as moves are made and
+	// arrive from the firehose, so reading one never writes.
+	game, err := s.readerFor(r).GetGame(context.Background(), gameID)
 	if err != nil {
 		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to fetch game")
-		http.Error(w, "Game not found", http.StatusNotFound)
+		apierror.Write(w, apierror.ErrGameNotFound)
 		return
 	}
 	
 	log.Info().Str("gameID", gameID).Str("fen", game.FEN).Str("status", string(game.Status)).Msg("Game fetched successfully")
+	game.UnderReview = s.reports.UnderReview(game.ID)
 	
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(game)
 }
 
+// ExportPGNHandler returns a game as a downloadable PGN file
+func (s *Service) ExportPGNHandler(w http.ResponseWriter, r *http.Request) {
+	encodedGameID := mux.Vars(r)["id"]
+	
+	gameID, err := s.decodeGameID(encodedGameID)
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+	
+	site := s.config.Server.BaseURL
+	if site == "" {
+		site = "ATChess"
+	}
+	
+	pgn, err := s.readerFor(r).ExportPGN(context.Background(), gameID, chess.PGNTags{
+		Event: "ATChess game",
+		Site:  site,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to export PGN")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to export PGN"))
+		return
+	}
+	
+	parts := strings.Split(gameID, "/")
+	filename := "atchess-" + parts[len(parts)-1] + ".pgn"
+	
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	_, _ = w.Write([]byte(pgn))
+}
+
 func (s *Service) CreateChallengeHandler(w http.ResponseWriter, r *http.Request) {
 	var req CreateChallengeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidBody)
 		return
 	}
 	
 	// Resolve handle to DID if necessary
 	opponentDID := req.OpponentDID
 	if !strings.HasPrefix(opponentDID, "did:") {
-		resolvedDID, err := s.client.ResolveHandle(context.Background(), opponentDID)
+		resolvedDID, err := s.clientFor(r).ResolveHandle(context.Background(), opponentDID)
 		if err != nil {
 			log.Error().Err(err).Str("handle", opponentDID).Msg("Failed to resolve handle")
-			http.Error(w, fmt.Sprintf("Failed to resolve handle '%s': %v", opponentDID, err), http.StatusBadRequest)
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage(fmt.Sprintf("Failed to resolve handle '%s': %v", opponentDID, err)))
 			return
 		}
 		opponentDID = resolvedDID
 	}
 	
-	challenge, err := s.client.CreateChallenge(context.Background(), opponentDID, req.Color, req.Message)
+	challenge, err := s.clientFor(r).CreateChallenge(context.Background(), opponentDID, req.Color, req.Message)
+	switch {
+	case errors.Is(err, atproto.ErrChallengeRefused):
+		apierror.Write(w, apierror.ErrChallengeRefused)
+		return
+	case errors.Is(err, atproto.ErrChallengeLimit):
+		apierror.Write(w, apierror.ErrChallengeLimit)
+		return
+	}
 	if err != nil {
+		// The handle may have moved to another account; look it up afresh next time
+		if opponentDID != req.OpponentDID {
+			s.clientFor(r).Resolver().Invalidate(req.OpponentDID)
+		}
 		log.Error().Err(err).Msg("Failed to create challenge")
-		http.Error(w, "Failed to create challenge", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to create challenge"))
 		return
 	}
 	
+	s.notifyPlayer(challenge.Challenged, NotificationChallenge, "", challenge)
+	s.scheduleChallengeExpiry(context.Background(), challenge.ID, challenge.ExpiresAt)
+	if s.emailDigest != nil {
+		s.emailDigest.QueueChallenge(challenge)
+	}
+	
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(challenge)
 }
 
 func (s *Service) GetChallengeNotificationsHandler(w http.ResponseWriter, r *http.Request) {
-	notifications, err := s.client.GetChallengeNotifications(context.Background())
+	notifications, err := s.clientFor(r).GetChallengeNotifications(context.Background())
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch challenge notifications")
-		http.Error(w, "Failed to fetch notifications", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to fetch notifications"))
 		return
 	}
 	
@@ -226,14 +556,133 @@ func (s *Service) DeleteChallengeNotificationHandler(w http.ResponseWriter, r *h
 	notificationKey := vars["key"]
 	
 	if notificationKey == "" {
-		http.Error(w, "Missing notification key", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Missing notification key"))
 		return
 	}
 	
-	err := s.client.DeleteChallengeNotification(context.Background(), notificationKey)
+	err := s.clientFor(r).DeleteChallengeNotification(context.Background(), notificationKey)
 	if err != nil {
 		log.Error().Err(err).Str("key", notificationKey).Msg("Failed to delete notification")
-		http.Error(w, "Failed to delete notification", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to delete notification"))
+		return
+	}
+	
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxAckKeys bounds how many notifications one acknowledgment request may name
+const maxAckKeys = 1000
+
+type AckNotificationsRequest struct {
+	Keys   []string `json:"keys,omitempty"`
+	Before string   `json:"before,omitempty"` // RFC3339; acknowledges everything created earlier
+}
+
+// AckChallengeNotificationsHandler deletes many challenge notifications at once
+// and reports the outcome for each one
+func (s *Service) AckChallengeNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	var req AckNotificationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	
+	if (len(req.Keys) == 0) == (req.Before == "") {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Provide either keys or before"))
+		return
+	}
+	if len(req.Keys) > maxAckKeys {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(fmt.Sprintf("At most %d keys may be acknowledged at once", maxAckKeys)))
+		return
+	}
+	
+	client := s.clientFor(r)
+	var results []atproto.NotificationAckResult
+	var err error
+	if req.Before != "" {
+		before, parseErr := time.Parse(time.RFC3339, req.Before)
+		if parseErr != nil {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("before must be an RFC3339 timestamp"))
+			return
+		}
+		results, err = client.AckChallengeNotificationsBefore(context.Background(), before)
+	} else {
+		results, err = client.AckChallengeNotifications(context.Background(), req.Keys)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to acknowledge notifications")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to acknowledge notifications"))
+		return
+	}
+	
+	deleted := 0
+	for _, result := range results {
+		if result.Status == atproto.AckDeleted {
+			deleted++
+		}
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"results": results,
+		"deleted": deleted,
+	})
+}
+
+type RespondToChallengeRequest struct {
+	ChallengeURI string `json:"challengeUri"`
+	Message      string `json:"message,omitempty"`
+}
+
+// challengeError maps challenge response errors to API errors
+func challengeError(err error, message string) *apierror.Error {
+	switch {
+	case errors.Is(err, atproto.ErrNotChallenged):
+		return apierror.ErrNotChallenged
+	case errors.Is(err, atproto.ErrChallengeNotPending):
+		return apierror.ErrChallengeNotPending
+	default:
+		return apierror.ErrInternal.WithMessage(message)
+	}
+}
+
+func (s *Service) AcceptChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	var req RespondToChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if req.ChallengeURI == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Missing challenge URI"))
+		return
+	}
+	
+	game, err := s.clientFor(r).AcceptChallenge(context.Background(), req.ChallengeURI, req.Message)
+	if err != nil {
+		log.Error().Err(err).Str("uri", req.ChallengeURI).Msg("Failed to accept challenge")
+		apierror.Write(w, challengeError(err, "Failed to accept challenge"))
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(game)
+}
+
+func (s *Service) DeclineChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	var req RespondToChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if req.ChallengeURI == "" {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Missing challenge URI"))
+		return
+	}
+	
+	err := s.clientFor(r).DeclineChallenge(context.Background(), req.ChallengeURI)
+	if err != nil {
+		log.Error().Err(err).Str("uri", req.ChallengeURI).Msg("Failed to decline challenge")
+		apierror.Write(w, challengeError(err, "Failed to decline challenge"))
 		return
 	}
 	
@@ -246,17 +695,24 @@ func (s *Service) OfferDrawHandler(w http.ResponseWriter, r *http.Request) {
 		Message string `json:"message"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidBody)
 		return
 	}
 	
-	drawOffer, err := s.client.OfferDraw(context.Background(), req.GameID, req.Message)
+	client := s.clientFor(r)
+	drawOffer, err := client.OfferDraw(context.Background(), req.GameID, req.Message)
 	if err != nil {
 		log.Error().Err(err).Str("gameID", req.GameID).Msg("Failed to offer draw")
-		http.Error(w, "Failed to offer draw", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to offer draw"))
 		return
 	}
 	
+	if s.hub != nil {
+		if game, err := client.GetGame(context.Background(), req.GameID); err == nil {
+			s.notifyPlayer(opponentOf(game, client.GetDID()), NotificationDrawOffer, req.GameID, drawOffer)
+		}
+	}
+	
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(drawOffer)
 }
@@ -267,14 +723,18 @@ func (s *Service) RespondToDrawHandler(w http.ResponseWriter, r *http.Request) {
 		Accept       bool   `json:"accept"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidBody)
 		return
 	}
 	
-	err := s.client.RespondToDrawOffer(context.Background(), req.DrawOfferURI, req.Accept)
+	err := s.clientFor(r).RespondToDrawOffer(context.Background(), req.DrawOfferURI, req.Accept)
+	if errors.Is(err, atproto.ErrConflict) {
+		apierror.Write(w, apierror.ErrConflict.WithMessage("The draw offer or game changed before your response was recorded"))
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Str("uri", req.DrawOfferURI).Msg("Failed to respond to draw offer")
-		http.Error(w, "Failed to respond to draw offer", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to respond to draw offer"))
 		return
 	}
 	
@@ -285,24 +745,40 @@ func (s *Service) ResignGameHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		GameID string `json:"gameId"`
 		Reason string `json:"reason"`
+		// Share posts the result to the player's Bluesky feed
+		Share *ShareGameRequest `json:"share,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidBody)
 		return
 	}
 	
-	err := s.client.ResignGame(context.Background(), req.GameID, req.Reason)
+	client := s.clientFor(r)
+	err := client.ResignGame(context.Background(), req.GameID, req.Reason)
+	if errors.Is(err, atproto.ErrConflict) {
+		apierror.Write(w, apierror.ErrGameFinished)
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Str("gameID", req.GameID).Msg("Failed to resign game")
-		http.Error(w, "Failed to resign game", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to resign game"))
 		return
 	}
 	
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"success": true,
 		"gameId":  req.GameID,
-	})
+	}
+	if req.Share != nil {
+		if game, err := client.GetGame(context.Background(), req.GameID); err != nil {
+			log.Error().Err(err).Str("gameID", req.GameID).Msg("Failed to load resigned game for sharing")
+		} else if shared := s.shareIfRequested(r, client, game, resignedStatus(game, client.GetDID()), req.Share); shared != nil {
+			response["shared"] = shared
+		}
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
 }
 
 func (s *Service) CheckTimeViolationHandler(w http.ResponseWriter, r *http.Request) {
@@ -310,20 +786,27 @@ func (s *Service) CheckTimeViolationHandler(w http.ResponseWriter, r *http.Reque
 	gameID := vars["id"]
 	
 	if gameID == "" {
-		http.Error(w, "Missing game ID", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidGameID.WithMessage("Missing game ID"))
 		return
 	}
 	
-	hasViolation, violation, err := s.client.CheckTimeViolation(context.Background(), gameID)
+	hasViolation, violation, err := s.readerFor(r).CheckTimeViolation(context.Background(), gameID)
 	if err != nil {
 		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to check time violation")
-		http.Error(w, "Failed to check time violation", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to check time violation"))
 		return
 	}
 	
+	// A violation can't be claimed while the clock is paused for a reconnect
+	clockPaused := s.connections != nil && s.connections.IsPaused(gameID)
+	if clockPaused {
+		hasViolation, violation = false, nil
+	}
+	
 	response := map[string]interface{}{
 		"hasViolation": hasViolation,
 		"violation":    violation,
+		"clockPaused":  clockPaused,
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -335,14 +818,20 @@ func (s *Service) ClaimTimeVictoryHandler(w http.ResponseWriter, r *http.Request
 	gameID := vars["id"]
 	
 	if gameID == "" {
-		http.Error(w, "Missing game ID", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidGameID.WithMessage("Missing game ID"))
+		return
+	}
+	
+	// Nobody loses on time while their opponent's clock is paused for a reconnect
+	if s.connections != nil && s.connections.IsPaused(gameID) {
+		apierror.Write(w, apierror.ErrClockPaused)
 		return
 	}
 	
-	err := s.client.ClaimTimeVictory(context.Background(), gameID)
+	err := s.clientFor(r).ClaimTimeVictory(context.Background(), gameID)
 	if err != nil {
 		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to claim time victory")
-		http.Error(w, "Failed to claim time victory", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Failed to claim time victory"))
 		return
 	}
 	
@@ -354,21 +843,29 @@ func (s *Service) GetTimeRemainingHandler(w http.ResponseWriter, r *http.Request
 	gameID := vars["id"]
 	
 	if gameID == "" {
-		http.Error(w, "Missing game ID", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidGameID.WithMessage("Missing game ID"))
 		return
 	}
 	
-	remaining, err := s.client.GetTimeRemaining(context.Background(), gameID)
+	move, err := s.readerFor(r).GetMoveDeadline(context.Background(), gameID)
 	if err != nil {
 		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to get time remaining")
-		http.Error(w, "Failed to get time remaining", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to get time remaining"))
 		return
 	}
 	
+	// Anonymous viewers see the deadline in UTC
+	location := Preferences{}.Location()
+	if client := s.clientFor(r); client != nil {
+		location = s.loadPreferences(r.Context(), client).Location()
+	}
+	deadline := NewDeadline(move, s.callerDID(r), location, time.Now())
+	remaining := time.Duration(deadline.RemainingSeconds) * time.Second
 	response := map[string]interface{}{
 		"gameId": gameID,
-		"remainingSeconds": int(remaining.Seconds()),
+		"remainingSeconds": deadline.RemainingSeconds,
 		"remainingFormatted": chess.FormatTimeRemaining(remaining),
+		"deadline": deadline,
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -385,23 +882,21 @@ type AuthResponse struct {
 	DID         string `json:"did"`
 	Handle      string `json:"handle"`
 	AccessToken string `json:"accessToken"`
-	Error       string `json:"error,omitempty"`
+	// AppPassword is whether the PDS says the player used an app password
+	AppPassword bool   `json:"appPassword"`
+	Warning     string `json:"warning,omitempty"`
 }
 
 func (s *Service) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var req AuthRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidBody)
 		return
 	}
 	
 	// Validate input
 	if req.Handle == "" || req.Password == "" {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(AuthResponse{
-			Success: false,
-			Error:   "Handle and password are required",
-		})
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Handle and password are required"))
 		return
 	}
 	
@@ -414,32 +909,62 @@ func (s *Service) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	)
 	if err != nil {
 		log.Error().Err(err).Str("handle", req.Handle).Msg("Failed to authenticate user")
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(AuthResponse{
-			Success: false,
-			Error:   "Invalid credentials or authentication failed",
-		})
+		apierror.Write(w, apierror.ErrInvalidCredentials)
+		return
+	}
+	
+	// A main password can do anything to the account, including delete it
+	if userClient.UsesMainPassword() && s.config.ATProto.RequireAppPasswords {
+		if err := userClient.DeleteSession(r.Context()); err != nil {
+			log.Warn().Err(err).Str("handle", req.Handle).Msg("Failed to end main password session")
+		}
+		apierror.Write(w, apierror.ErrMainPassword)
 		return
 	}
 	
-	// Return success with user info
-	// Note: In production, you'd want to create a session token instead of returning the raw JWT
+	s.prepareUserClient(userClient)
+	
+	// Keep the authenticated client so later requests act as this user
+	token, err := s.sessions.CreateForDevice(userClient, r.UserAgent())
+	if err != nil {
+		log.Error().Err(err).Str("handle", req.Handle).Msg("Failed to create session")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to create session"))
+		return
+	}
+	
+	// Digests go to the address the player's PDS has confirmed
+	if s.emailDigest != nil {
+		s.emailDigest.Register(userClient.GetDID(), userClient.GetEmail())
+	}
+	
+	// Publish a rating that changed while the player was away
+	if s.ratings != nil {
+		go s.ratings.PublishPending(context.Background(), userClient.GetDID())
+	}
+	
+	// Save studies others edited while the owner was away
+	if s.studies != nil {
+		go s.studies.SavePending(context.Background(), userClient.GetDID())
+	}
+	
+	// The access token is an opaque session ID to be sent back as X-Session-ID
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(AuthResponse{
 		Success:     true,
 		DID:         userClient.GetDID(),
 		Handle:      userClient.GetHandle(),
-		AccessToken: "session_" + base64.URLEncoding.EncodeToString([]byte(userClient.GetDID())),
+		AccessToken: token,
+		AppPassword: userClient.UsesAppPassword(),
+		Warning:     passwordWarning(userClient),
 	})
 }
 
 func (s *Service) GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
-	// For now, return the service's configured user
-	// In a real implementation, this would validate the session token
+	client := s.clientFor(r)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"did":    s.client.GetDID(),
-		"handle": s.client.GetHandle(),
+		"did":    client.GetDID(),
+		"handle": client.GetHandle(),
 		"authenticated": true,
 	})
 }
@@ -478,7 +1003,7 @@ func (s *Service) ClientMetadataHandler(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Cache-Control", "public, max-age=3600") // Cache for 1 hour
 	if err := json.NewEncoder(w).Encode(metadata); err != nil {
 		log.Error().Err(err).Msg("Failed to encode client metadata")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal)
 	}
 }
 
@@ -0,0 +1,836 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+const (
+	testWhiteDID = "did:plc:white"
+	testBlackDID = "did:plc:black"
+	startFEN     = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+)
+
+// fakePDS is a minimal in-memory PDS that understands the repo XRPC methods
+// used by atproto.Client
+type fakePDS struct {
+	*httptest.Server
+	did   string
+	email string // confirmed address returned by createSession, if set
+	scope string // scope claim of the access token createSession returns, if set
+
+	mu      sync.Mutex
+	records map[string]map[string]interface{} // at:// URI -> record value
+	order   []string                          // URIs in creation order
+	nextKey int
+
+	applyWritesCalls int
+	deletedSessions  int
+	blobTypes        []string // content types of uploaded blobs
+}
+
+func newFakePDS(t *testing.T, did string) *fakePDS {
+	t.Helper()
+	pds := &fakePDS{
+		did:     did,
+		records: make(map[string]map[string]interface{}),
+	}
+	pds.Server = httptest.NewServer(http.HandlerFunc(pds.serve))
+	t.Cleanup(pds.Close)
+	return pds
+}
+
+func (p *fakePDS) put(uri string, value map[string]interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.records[uri]; !exists {
+		p.order = append(p.order, uri)
+	}
+	p.records[uri] = value
+}
+
+func (p *fakePDS) get(uri string) map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.records[uri]
+}
+
+func (p *fakePDS) collection(repo, collection string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prefix := fmt.Sprintf("at://%s/%s/", repo, collection)
+	var uris []string
+	for _, uri := range p.order {
+		if _, ok := p.records[uri]; ok && strings.HasPrefix(uri, prefix) {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+func (p *fakePDS) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+
+	switch r.URL.Path {
+	case "/xrpc/com.atproto.server.createSession":
+		resp := map[string]interface{}{
+			"accessJwt":  "test-jwt",
+			"refreshJwt": "test-refresh-jwt",
+			"did":        p.did,
+			"handle":     "test.user",
+		}
+		if p.scope != "" {
+			claims, _ := json.Marshal(map[string]string{"scope": p.scope, "sub": p.did})
+			resp["accessJwt"] = "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+		}
+		if p.email != "" {
+			resp["email"] = p.email
+			resp["emailConfirmed"] = true
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+
+	case "/xrpc/com.atproto.server.deleteSession":
+		p.mu.Lock()
+		p.deletedSessions++
+		p.mu.Unlock()
+
+	case "/xrpc/com.atproto.repo.getRecord":
+		uri := fmt.Sprintf("at://%s/%s/%s", q.Get("repo"), q.Get("collection"), q.Get("rkey"))
+		value := p.get(uri)
+		if value == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "RecordNotFound"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"uri": uri, "cid": "cid-" + uri, "value": value})
+
+	case "/xrpc/com.atproto.repo.listRecords":
+		uris := p.collection(q.Get("repo"), q.Get("collection"))
+		start, _ := strconv.Atoi(q.Get("cursor"))
+		end := len(uris)
+		if limit, err := strconv.Atoi(q.Get("limit")); err == nil && start+limit < end {
+			end = start + limit
+		}
+		var records []map[string]interface{}
+		for _, uri := range uris[min(start, len(uris)):end] {
+			records = append(records, map[string]interface{}{"uri": uri, "cid": "cid-" + uri, "value": p.get(uri)})
+		}
+		resp := map[string]interface{}{"records": records}
+		if end < len(uris) {
+			resp["cursor"] = strconv.Itoa(end)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+
+	case "/xrpc/com.atproto.repo.createRecord", "/xrpc/com.atproto.repo.putRecord":
+		var req struct {
+			Repo       string                 `json:"repo"`
+			Collection string                 `json:"collection"`
+			Rkey       string                 `json:"rkey"`
+			Record     map[string]interface{} `json:"record"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Rkey == "" {
+			p.mu.Lock()
+			p.nextKey++
+			req.Rkey = fmt.Sprintf("rk%04d", p.nextKey)
+			p.mu.Unlock()
+		}
+		uri := fmt.Sprintf("at://%s/%s/%s", req.Repo, req.Collection, req.Rkey)
+		p.put(uri, req.Record)
+		_ = json.NewEncoder(w).Encode(map[string]string{"uri": uri, "cid": "cid-" + uri})
+
+	case "/xrpc/com.atproto.repo.applyWrites":
+		var req struct {
+			Repo   string `json:"repo"`
+			Writes []struct {
+				Type       string                 `json:"$type"`
+				Collection string                 `json:"collection"`
+				Rkey       string                 `json:"rkey"`
+				Value      map[string]interface{} `json:"value"`
+			} `json:"writes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		p.mu.Lock()
+		p.applyWritesCalls++
+		results := []interface{}{}
+		for _, write := range req.Writes {
+			if write.Rkey == "" {
+				p.nextKey++
+				write.Rkey = fmt.Sprintf("rk%04d", p.nextKey)
+			}
+			uri := fmt.Sprintf("at://%s/%s/%s", req.Repo, write.Collection, write.Rkey)
+			switch write.Type {
+			case "com.atproto.repo.applyWrites#create":
+				p.order = append(p.order, uri)
+				p.records[uri] = write.Value
+			case "com.atproto.repo.applyWrites#delete":
+				delete(p.records, uri)
+			case "com.atproto.repo.applyWrites#update":
+				p.records[uri] = write.Value
+			}
+			results = append(results, map[string]string{"uri": uri, "cid": "cid-" + uri})
+		}
+		p.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+
+	case "/xrpc/com.atproto.repo.uploadBlob":
+		data, _ := io.ReadAll(r.Body)
+		contentType := r.Header.Get("Content-Type")
+		p.mu.Lock()
+		p.blobTypes = append(p.blobTypes, contentType)
+		n := len(p.blobTypes)
+		p.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"blob": map[string]interface{}{
+			"$type":    "blob",
+			"ref":      map[string]string{"$link": fmt.Sprintf("bafyblob%d", n)},
+			"mimeType": contentType,
+			"size":     len(data),
+		}})
+
+	case "/xrpc/app.bsky.actor.getProfile":
+		// Every account's handle is its DID's identifier, e.g. white.test
+		actor := q.Get("actor")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"did":    actor,
+			"handle": strings.TrimPrefix(actor, "did:plc:") + ".test",
+		})
+
+	case "/xrpc/com.atproto.repo.describeRepo":
+		// Every repo is hosted here, so the client may write to any of them
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"collections": []string{}})
+
+	case "/xrpc/com.atproto.repo.deleteRecord":
+		var req struct {
+			Repo       string `json:"repo"`
+			Collection string `json:"collection"`
+			Rkey       string `json:"rkey"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		p.mu.Lock()
+		delete(p.records, fmt.Sprintf("at://%s/%s/%s", req.Repo, req.Collection, req.Rkey))
+		p.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]string{})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newServiceForPDS creates a Service whose client is logged into the fake PDS
+func newServiceForPDS(t *testing.T, pds *fakePDS) *Service {
+	t.Helper()
+	client, err := atproto.NewClient(pds.URL, "test.user", "password")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return NewService(client, &config.Config{})
+}
+
+// ownSession signs in to the service's own account and returns the session
+// token, for tests of handlers that act for the caller
+func ownSession(s *Service) string {
+	token, _ := s.Sessions().Create(s.client)
+	return token
+}
+
+// asPlayer makes r as the player signed in with client, as SessionMiddleware
+// does for a session, for tests calling handlers directly
+func asPlayer(r *http.Request, client *atproto.Client) *http.Request {
+	ctx := context.WithValue(r.Context(), userClientKey, client)
+	ctx = context.WithValue(ctx, sessionTokenKey, "session-"+client.GetDID())
+	return r.WithContext(ctx)
+}
+
+func seedGame(pds *fakePDS, fen, status string) string {
+	uri := fmt.Sprintf("at://%s/app.atchess.game/game1", testWhiteDID)
+	pds.put(uri, map[string]interface{}{
+		"$type":     "app.atchess.game",
+		"createdAt": "2024-01-01T00:00:00Z",
+		"white":     testWhiteDID,
+		"black":     testBlackDID,
+		"status":    status,
+		"fen":       fen,
+		"pgn":       "",
+	})
+	return uri
+}
+
+func postMove(s *Service, body map[string]interface{}) *httptest.ResponseRecorder {
+	reqBody, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/api/moves", bytes.NewReader(reqBody))
+	w := httptest.NewRecorder()
+	s.MakeMoveHandler(w, asPlayer(req, s.client))
+	return w
+}
+
+func TestMakeMoveAllowsPlayerToMove(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	w := postMove(service, map[string]interface{}{"from": "e2", "to": "e4", "fen": startFEN, "game_id": gameID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected move to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if fen := pds.get(gameID)["fen"]; fen == startFEN {
+		t.Errorf("Expected stored FEN to be updated after move")
+	}
+}
+
+func TestMakeMoveAcceptsSANAndUCI(t *testing.T) {
+	for _, move := range []string{"Nf3", "g1f3"} {
+		t.Run(move, func(t *testing.T) {
+			pds := newFakePDS(t, testWhiteDID)
+			gameID := seedGame(pds, startFEN, "active")
+			service := newServiceForPDS(t, pds)
+
+			w := postMove(service, map[string]interface{}{"move": move, "game_id": gameID})
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected move to succeed, got %d: %s", w.Code, w.Body.String())
+			}
+			var result chess.MoveResult
+			_ = json.Unmarshal(w.Body.Bytes(), &result)
+			if result.From != "g1" || result.To != "f3" || result.SAN != "Nf3" {
+				t.Errorf("Expected Nf3 from g1 to f3, got %+v", result)
+			}
+		})
+	}
+
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+	w := postMove(service, map[string]interface{}{"move": "Nf4", "game_id": gameID})
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "invalid_move" {
+		t.Errorf("Expected invalid_move for an impossible SAN move, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMakeMoveRejectsOutOfTurnMove(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	w := postMove(service, map[string]interface{}{"from": "e7", "to": "e5", "fen": startFEN, "game_id": gameID})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 for out-of-turn move, got %d: %s", w.Code, w.Body.String())
+	}
+	if code := errorCode(t, w); code != "not_your_turn" {
+		t.Errorf("Expected not_your_turn, got %q", code)
+	}
+
+	if len(pds.collection(testBlackDID, "app.atchess.move")) != 0 {
+		t.Errorf("Expected no move record to be written")
+	}
+}
+
+func TestMakeMoveRejectsThirdParty(t *testing.T) {
+	pds := newFakePDS(t, "did:plc:spectator")
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	w := postMove(service, map[string]interface{}{"from": "e2", "to": "e4", "fen": startFEN, "game_id": gameID})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 for non-player move, got %d: %s", w.Code, w.Body.String())
+	}
+	if code := errorCode(t, w); code != "not_a_player" {
+		t.Errorf("Expected not_a_player, got %q", code)
+	}
+}
+
+func TestMakeMoveRejectsStalePositionAndFinishedGames(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	staleFEN := "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"
+	w := postMove(service, map[string]interface{}{"from": "e7", "to": "e5", "fen": staleFEN, "game_id": gameID})
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for mismatched FEN, got %d: %s", w.Code, w.Body.String())
+	}
+	if code := errorCode(t, w); code != "position_mismatch" {
+		t.Errorf("Expected position_mismatch, got %q", code)
+	}
+
+	seedGame(pds, startFEN, "draw")
+	w = postMove(service, map[string]interface{}{"from": "e2", "to": "e4", "fen": startFEN, "game_id": gameID})
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for finished game, got %d: %s", w.Code, w.Body.String())
+	}
+	if code := errorCode(t, w); code != "game_finished" {
+		t.Errorf("Expected game_finished, got %q", code)
+	}
+}
+
+// errorCode decodes the code of a JSON error response
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected a JSON error, got %q", ct)
+	}
+	var resp struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error.Message == "" {
+		t.Errorf("Expected an error body, got %s", w.Body.String())
+	}
+	return resp.Error.Code
+}
+
+func seedChallenge(pds *fakePDS, challenged, color string) string {
+	uri := fmt.Sprintf("at://%s/app.atchess.challenge/chal1", testWhiteDID)
+	pds.put(uri, map[string]interface{}{
+		"$type":          "app.atchess.challenge",
+		"createdAt":      time.Now().Format(time.RFC3339),
+		"challenger":     testWhiteDID,
+		"challenged":     challenged,
+		"status":         "pending",
+		"color":          color,
+		"proposedGameId": "chproposed123",
+		"expiresAt":      time.Now().Add(time.Hour).Format(time.RFC3339),
+	})
+	return uri
+}
+
+func postChallengeResponse(s *Service, handler http.HandlerFunc, path, challengeURI string) *httptest.ResponseRecorder {
+	reqBody, _ := json.Marshal(RespondToChallengeRequest{ChallengeURI: challengeURI})
+	w := httptest.NewRecorder()
+	handler(w, asPlayer(httptest.NewRequest("POST", path, bytes.NewReader(reqBody)), s.client))
+	return w
+}
+
+func TestAcceptChallengeCreatesGameAndAcceptance(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	challengeURI := seedChallenge(pds, testBlackDID, "white")
+	service := newServiceForPDS(t, pds)
+
+	w := postChallengeResponse(service, service.AcceptChallengeHandler, "/api/challenges/accept", challengeURI)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected accept to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	gameURI := fmt.Sprintf("at://%s/app.atchess.game/chproposed123", testBlackDID)
+	game := pds.get(gameURI)
+	if game == nil {
+		t.Fatalf("Expected game at proposed rkey %s", gameURI)
+	}
+	if game["white"] != testWhiteDID || game["black"] != testBlackDID {
+		t.Errorf("Expected challenger to play white, got white=%v black=%v", game["white"], game["black"])
+	}
+
+	acceptances := pds.collection(testBlackDID, "app.atchess.challengeAcceptance")
+	if len(acceptances) != 1 {
+		t.Fatalf("Expected 1 acceptance record, got %d", len(acceptances))
+	}
+	acceptance := pds.get(acceptances[0])
+	if ref := acceptance["game"].(map[string]interface{}); ref["uri"] != gameURI {
+		t.Errorf("Expected acceptance to reference %s, got %v", gameURI, ref["uri"])
+	}
+
+	if status := pds.get(challengeURI)["status"]; status != "accepted" {
+		t.Errorf("Expected challenge status accepted, got %v", status)
+	}
+
+	// A second accept must not create another game
+	w = postChallengeResponse(service, service.AcceptChallengeHandler, "/api/challenges/accept", challengeURI)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for already accepted challenge, got %d", w.Code)
+	}
+}
+
+func TestDeclineChallenge(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	challengeURI := seedChallenge(pds, testBlackDID, "random")
+	service := newServiceForPDS(t, pds)
+
+	w := postChallengeResponse(service, service.DeclineChallengeHandler, "/api/challenges/decline", challengeURI)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected decline to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if status := pds.get(challengeURI)["status"]; status != "declined" {
+		t.Errorf("Expected challenge status declined, got %v", status)
+	}
+	if len(pds.collection(testBlackDID, "app.atchess.game")) != 0 {
+		t.Errorf("Expected no game to be created for declined challenge")
+	}
+}
+
+func TestRespondToChallengeRejectsOtherUsers(t *testing.T) {
+	pds := newFakePDS(t, "did:plc:spectator")
+	challengeURI := seedChallenge(pds, testBlackDID, "white")
+	service := newServiceForPDS(t, pds)
+
+	w := postChallengeResponse(service, service.AcceptChallengeHandler, "/api/challenges/accept", challengeURI)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 when accepting someone else's challenge, got %d", w.Code)
+	}
+	w = postChallengeResponse(service, service.DeclineChallengeHandler, "/api/challenges/decline", challengeURI)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 when declining someone else's challenge, got %d", w.Code)
+	}
+	if status := pds.get(challengeURI)["status"]; status != "pending" {
+		t.Errorf("Expected challenge to stay pending, got %v", status)
+	}
+}
+
+func TestListGamesHandlerPagesAndFilters(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	// More games than fit in one listRecords page
+	for i := 0; i < 120; i++ {
+		status := "active"
+		if i%2 == 1 {
+			status = "draw"
+		}
+		pds.put(fmt.Sprintf("at://%s/app.atchess.game/g%03d", testWhiteDID, i), map[string]interface{}{
+			"createdAt": fmt.Sprintf("2024-01-01T00:%02d:%02dZ", i/60, i%60),
+			"white":     testWhiteDID,
+			"black":     testBlackDID,
+			"status":    status,
+			"fen":       startFEN,
+		})
+	}
+
+	// A game the opponent created from our challenge lives only in their repo
+	opponentGame := fmt.Sprintf("at://%s/app.atchess.game/fromchallenge", testBlackDID)
+	pds.put(opponentGame, map[string]interface{}{
+		"createdAt": "2024-02-01T00:00:00Z",
+		"white":     testBlackDID,
+		"black":     testWhiteDID,
+		"status":    "active",
+		"fen":       startFEN,
+	})
+	pds.put(fmt.Sprintf("at://%s/app.atchess.challenge/c1", testWhiteDID), map[string]interface{}{
+		"challenger": testWhiteDID,
+		"challenged": testBlackDID,
+		"status":     "accepted",
+		"games": []interface{}{
+			map[string]interface{}{"uri": opponentGame, "owner": testBlackDID},
+		},
+	})
+
+	testCases := []struct {
+		query         string
+		expectedCode  int
+		expectedTotal int
+	}{
+		{query: "", expectedCode: http.StatusOK, expectedTotal: 121},
+		{query: "?status=active", expectedCode: http.StatusOK, expectedTotal: 61},
+		{query: "?status=finished", expectedCode: http.StatusOK, expectedTotal: 60},
+		{query: "?status=active&role=black", expectedCode: http.StatusOK, expectedTotal: 1},
+		{query: "?status=bogus", expectedCode: http.StatusBadRequest},
+		{query: "?role=bogus", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			service.ListGamesHandler(w, asPlayer(httptest.NewRequest("GET", "/api/games"+tc.query, nil), service.client))
+			if w.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, got %d: %s", tc.expectedCode, w.Code, w.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Games []map[string]interface{} `json:"games"`
+				Total int                      `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if resp.Total != tc.expectedTotal || len(resp.Games) != tc.expectedTotal {
+				t.Errorf("Expected %d games, got total=%d len=%d", tc.expectedTotal, resp.Total, len(resp.Games))
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	service.ListGamesHandler(w, asPlayer(httptest.NewRequest("GET", "/api/games", nil), service.client))
+	var resp struct {
+		Games []map[string]interface{} `json:"games"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Games) == 0 || resp.Games[0]["id"] != opponentGame {
+		t.Errorf("Expected newest game first")
+	}
+}
+
+func seedMove(pds *fakePDS, gameURI, player, rkey, from, to, san, fen string) {
+	pds.put(fmt.Sprintf("at://%s/app.atchess.move/%s", player, rkey), map[string]interface{}{
+		"createdAt": "2024-01-01T00:00:00Z",
+		"game":      map[string]interface{}{"uri": gameURI, "cid": "cid-" + gameURI},
+		"player":    player,
+		"from":      from,
+		"to":        to,
+		"san":       san,
+		"fen":       fen,
+	})
+}
+
+func TestExportPGNHandlerCombinesBothRepos(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "black_won")
+	service := newServiceForPDS(t, pds)
+
+	// Fool's mate, with each player's moves stored in their own repo and identical timestamps
+	seedMove(pds, gameID, testBlackDID, "m2", "e7", "e5", "e5", "rnbqkbnr/pppp1ppp/8/4p3/8/5P2/PPPPP1PP/RNBQKBNR w KQkq e6 0 2")
+	seedMove(pds, gameID, testBlackDID, "m4", "d8", "h4", "Qh4#", "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3")
+	seedMove(pds, gameID, testWhiteDID, "m3", "g2", "g4", "g4", "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq g3 0 2")
+	seedMove(pds, gameID, testWhiteDID, "m1", "f2", "f3", "f3", "rnbqkbnr/pppppppp/8/8/8/5P2/PPPPP1PP/RNBQKBNR b KQkq - 0 1")
+	seedMove(pds, "at://did:plc:white/app.atchess.game/other", testWhiteDID, "x1", "d2", "d4", "d4", "rnbqkbnr/pppppppp/8/8/3P4/8/PPP1PPPP/RNBQKBNR b KQkq d3 0 1")
+
+	encoded := base64.URLEncoding.EncodeToString([]byte(gameID))
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/games/"+encoded+"/pgn", nil), map[string]string{"id": encoded})
+	w := httptest.NewRecorder()
+	service.ExportPGNHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected PGN export to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-chess-pgn" {
+		t.Errorf("Expected PGN content type, got %s", ct)
+	}
+
+	pgn := w.Body.String()
+	for _, expected := range []string{`[White "did:plc:white"]`, `[Black "did:plc:black"]`, `[Result "0-1"]`, `[Date "2024.01.01"]`, "1. f3 e5 2. g4 Qh4# 0-1"} {
+		if !strings.Contains(pgn, expected) {
+			t.Errorf("Expected %q in PGN:\n%s", expected, pgn)
+		}
+	}
+}
+
+func TestGetMovesReplaysBothReposAndDropsDuplicates(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	seedMove(pds, gameID, testWhiteDID, "m1", "e2", "e4", "e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1")
+	seedMove(pds, gameID, testBlackDID, "m2", "e7", "e5", "e5", "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2")
+	// A retried submission of black's reply
+	seedMove(pds, gameID, testBlackDID, "m2-retry", "e7", "e5", "e5", "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2")
+	seedMove(pds, gameID, testWhiteDID, "m3", "g1", "f3", "Nf3", "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2")
+
+	moves, err := service.client.GetMoves(context.Background(), gameID)
+	if err != nil {
+		t.Fatalf("GetMoves failed: %v", err)
+	}
+
+	var sans []string
+	for i, move := range moves {
+		if move.Ply != i+1 {
+			t.Errorf("Expected ply %d, got %d", i+1, move.Ply)
+		}
+		sans = append(sans, move.SAN)
+	}
+	if strings.Join(sans, " ") != "e4 e5 Nf3" {
+		t.Errorf("Expected e4 e5 Nf3, got %v", sans)
+	}
+	if moves[1].Player != testBlackDID {
+		t.Errorf("Expected black's move from black's repo, got %s", moves[1].Player)
+	}
+}
+
+func TestGetMovesRejectsInvalidHistory(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	// Black's record moves one of white's pawns
+	seedMove(pds, gameID, testWhiteDID, "m1", "e2", "e4", "e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1")
+	seedMove(pds, gameID, testBlackDID, "m2", "e4", "e5", "e5", "rnbqkbnr/pppppppp/8/4P3/8/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2")
+
+	if _, err := service.client.GetMoves(context.Background(), gameID); err == nil {
+		t.Error("Expected illegal move history to be rejected")
+	}
+}
+
+func TestCheckAbandonmentUsesLastMove(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	seedMove(pds, gameID, testWhiteDID, "m1", "e2", "e4", "e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1")
+	record := pds.get(fmt.Sprintf("at://%s/app.atchess.move/m1", testWhiteDID))
+	record["createdAt"] = recent
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/spectator/games/x/abandonment", nil), map[string]string{"id": gameID})
+	w := httptest.NewRecorder()
+	service.CheckAbandonmentHandler(w, req)
+
+	var resp struct {
+		Abandoned    bool   `json:"abandoned"`
+		LastActivity string `json:"lastActivity"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Abandoned || resp.LastActivity != recent {
+		t.Errorf("Expected recent move to count as activity, got %s", w.Body.String())
+	}
+}
+
+func seedNotification(pds *fakePDS, rkey, createdAt string) string {
+	uri := fmt.Sprintf("at://%s/app.atchess.challengeNotification/%s", pds.did, rkey)
+	pds.put(uri, map[string]interface{}{
+		"createdAt":  createdAt,
+		"expiresAt":  "2099-01-01T00:00:00Z",
+		"challenger": testWhiteDID,
+	})
+	return uri
+}
+
+func postAck(s *Service, body AckNotificationsRequest) *httptest.ResponseRecorder {
+	reqBody, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/api/challenge-notifications/ack", bytes.NewReader(reqBody))
+	w := httptest.NewRecorder()
+	s.AckChallengeNotificationsHandler(w, asPlayer(req, s.client))
+	return w
+}
+
+func TestAckChallengeNotificationsByKey(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	service := newServiceForPDS(t, pds)
+
+	for i := 0; i < 250; i++ {
+		seedNotification(pds, fmt.Sprintf("n%03d", i), "2024-01-01T00:00:00Z")
+	}
+
+	keys := []string{fmt.Sprintf("at://%s/app.atchess.challengeNotification/n000", testBlackDID), "missing", "at://did:plc:other/app.atchess.challengeNotification/n001"}
+	for i := 1; i < 250; i++ {
+		keys = append(keys, fmt.Sprintf("n%03d", i))
+	}
+
+	w := postAck(service, AckNotificationsRequest{Keys: keys})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected ack to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Results []atproto.NotificationAckResult `json:"results"`
+		Deleted int                             `json:"deleted"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if resp.Deleted != 250 {
+		t.Errorf("Expected 250 deletions, got %d", resp.Deleted)
+	}
+	if resp.Results[1].Status != atproto.AckNotFound || resp.Results[2].Status != atproto.AckInvalid {
+		t.Errorf("Expected per-item not_found and invalid results, got %+v", resp.Results[:3])
+	}
+	if remaining := pds.collection(testBlackDID, "app.atchess.challengeNotification"); len(remaining) != 0 {
+		t.Errorf("Expected all notifications deleted, %d remain", len(remaining))
+	}
+	if pds.applyWritesCalls != 2 {
+		t.Errorf("Expected deletes batched into 2 applyWrites calls, got %d", pds.applyWritesCalls)
+	}
+}
+
+func TestAckChallengeNotificationsBefore(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	service := newServiceForPDS(t, pds)
+
+	seedNotification(pds, "old", "2024-01-01T00:00:00Z")
+	newer := seedNotification(pds, "new", "2024-03-01T00:00:00Z")
+
+	w := postAck(service, AckNotificationsRequest{Before: "2024-02-01T00:00:00Z"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected ack to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	remaining := pds.collection(testBlackDID, "app.atchess.challengeNotification")
+	if len(remaining) != 1 || remaining[0] != newer {
+		t.Errorf("Expected only the newer notification to remain, got %v", remaining)
+	}
+
+	if w := postAck(service, AckNotificationsRequest{}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without keys or before, got %d", w.Code)
+	}
+}
+
+func TestActiveGamesListingUsesIndex(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, testWhiteDID))
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	service.SetGameIndex(indexer)
+
+	for i, status := range []string{"active", "active", "active", "draw"} {
+		_ = indexer.Apply(context.Background(), "create", testWhiteDID, fmt.Sprintf("app.atchess.game/g%d", i), map[string]interface{}{
+			"white":       testWhiteDID,
+			"black":       testBlackDID,
+			"status":      status,
+			"fen":         startFEN,
+			"createdAt":   fmt.Sprintf("2024-01-0%dT00:00:00Z", i+1),
+			"timeControl": map[string]interface{}{"type": "correspondence"},
+		})
+	}
+
+	list := func(query string) (int, []GameIndex, string) {
+		w := httptest.NewRecorder()
+		service.GetActiveGamesHandler(w, httptest.NewRequest("GET", "/api/spectator/games?"+query, nil))
+		var resp struct {
+			Games  []GameIndex `json:"games"`
+			Total  int         `json:"total"`
+			Cursor string      `json:"cursor"`
+		}
+		if w.Code == http.StatusOK {
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		}
+		return w.Code, resp.Games, resp.Cursor
+	}
+
+	code, games, cursor := list("limit=2")
+	if code != http.StatusOK || len(games) != 2 || cursor == "" {
+		t.Fatalf("Expected first page of 2 active games with a cursor, got %d %d %q", code, len(games), cursor)
+	}
+	if games[0].URI != "at://did:plc:white/app.atchess.game/g2" || games[0].Players.Black.DID != testBlackDID || games[0].MaterialCount.White == 0 {
+		t.Errorf("Unexpected first game: %+v", games[0])
+	}
+
+	_, games, cursor = list("limit=2&cursor=" + cursor)
+	if len(games) != 1 || cursor != "" {
+		t.Errorf("Expected last page with 1 game, got %d (cursor %q)", len(games), cursor)
+	}
+
+	if _, games, _ := list("status=any&player=" + testBlackDID); len(games) != 4 {
+		t.Errorf("Expected all 4 games for the player, got %d", len(games))
+	}
+	if _, games, _ := list("timeControl=blitz"); len(games) != 0 {
+		t.Errorf("Expected no blitz games, got %d", len(games))
+	}
+	if code, _, _ := list("cursor=bogus"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid cursor, got %d", code)
+	}
+	if code, _, _ := list("limit=0"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid limit, got %d", code)
+	}
+}
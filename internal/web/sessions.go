@@ -0,0 +1,796 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/oauth"
+	"github.com/rs/zerolog/log"
+)
+
+// SessionHeader is the request header carrying a session token
+const SessionHeader = "X-Session-ID"
+
+// DefaultSessionTTL is how long an idle user session stays valid
+const DefaultSessionTTL = 24 * time.Hour
+
+type contextKey string
+
+const (
+	userClientKey   contextKey = "userClient"
+	sessionTokenKey contextKey = "sessionToken"
+)
+
+// UserSession ties an authenticated AT Protocol client to a session token
+type UserSession struct {
+	Token     string
+	ID        string // public identifier, safe to show to the user unlike Token
+	Device    string // human readable hint derived from the login User-Agent
+	Client    *atproto.Client
+	CreatedAt time.Time
+	LastUsed  time.Time
+
+	saved time.Time // LastUsed when the session was last written to storage
+}
+
+// clientSessionKeyPrefix prefixes the storage keys of user sessions, apart
+// from the OAuth sessions kept in the same storage
+const clientSessionKeyPrefix = "client-session:"
+
+// sessionSaveInterval is how often a session in use is written back to
+// storage, to extend its expiry and keep refreshed tokens
+const sessionSaveInterval = time.Minute
+
+// storedUserSession is a UserSession as written to storage
+type storedUserSession struct {
+	DID       string    `json:"did"`
+	Device    string    `json:"device"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+	// Credentials are the sealed tokens of a password session. OAuth
+	// sessions have none; their clients are rebuilt from the OAuth session
+	// with the same token.
+	Credentials []byte `json:"credentials,omitempty"`
+}
+
+// RestoreFunc rebuilds the client of a session read from storage.
+// credentials is nil for OAuth sessions.
+type RestoreFunc func(ctx context.Context, token string, credentials *atproto.SessionCredentials) (*atproto.Client, error)
+
+// ClientSessionStore keeps per-user AT Protocol clients keyed by session
+// token. With storage, sessions are kept there too, so they survive restarts
+// and are shared between replicas; the clients in memory are then a cache
+// and storage decides which sessions exist.
+type ClientSessionStore struct {
+	sessions map[string]*UserSession
+	ttl      time.Duration
+	mu       sync.RWMutex
+
+	storage oauth.Storage
+	keys    *oauth.KeyBox
+	restore RestoreFunc
+}
+
+// NewClientSessionStore creates a session store whose sessions expire after ttl of inactivity
+func NewClientSessionStore(ttl time.Duration) *ClientSessionStore {
+	return &ClientSessionStore{
+		sessions: make(map[string]*UserSession),
+		ttl:      ttl,
+	}
+}
+
+// SetStorage keeps sessions in storage, with their tokens encrypted by keys,
+// and must be called before the store is used. keys may only be nil for
+// storage in memory. restore rebuilds the clients of sessions created by
+// another replica or before a restart.
+func (s *ClientSessionStore) SetStorage(storage oauth.Storage, keys *oauth.KeyBox, restore RestoreFunc) {
+	s.storage, s.keys, s.restore = storage, keys, restore
+}
+
+// Create stores a client and returns the new session token
+func (s *ClientSessionStore) Create(client *atproto.Client) (string, error) {
+	return s.CreateForDevice(client, "")
+}
+
+// CreateForDevice stores a client along with the User-Agent it logged in from
+// and returns the new session token
+func (s *ClientSessionStore) CreateForDevice(client *atproto.Client, userAgent string) (string, error) {
+	token, err := generateSessionToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.CreateWithToken(token, client, userAgent); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CreateWithToken stores a client under a token issued elsewhere, such as an
+// OAuth session's ID, replacing any session already using it
+func (s *ClientSessionStore) CreateWithToken(token string, client *atproto.Client, userAgent string) error {
+	now := time.Now()
+	session := &UserSession{
+		Token:     token,
+		ID:        sessionID(token),
+		Device:    deviceHint(userAgent),
+		Client:    client,
+		CreatedAt: now,
+		LastUsed:  now,
+	}
+
+	s.mu.Lock()
+	s.sessions[token] = session
+	s.mu.Unlock()
+
+	if s.storage == nil {
+		return nil
+	}
+	if err := s.save(context.Background(), session); err != nil {
+		s.forget(token)
+		return err
+	}
+	return nil
+}
+
+// Get returns the session for a token, refreshing its last-used time
+func (s *ClientSessionStore) Get(token string) (*UserSession, bool) {
+	if s.storage == nil {
+		return s.getInMemory(token)
+	}
+
+	ctx := context.Background()
+	stored, err := s.load(ctx, token)
+	if err != nil {
+		if !errors.Is(err, oauth.ErrNotFound) {
+			log.Warn().Err(err).Msg("Failed to read session")
+		}
+		s.forget(token)
+		return nil, false
+	}
+	session, err := s.session(ctx, token, stored)
+	if err != nil {
+		log.Warn().Err(err).Str("did", stored.DID).Msg("Failed to restore session")
+		return nil, false
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	session.LastUsed = now
+	due := now.Sub(session.saved) >= sessionSaveInterval
+	s.mu.Unlock()
+	if due {
+		if err := s.save(ctx, session); err != nil {
+			log.Warn().Err(err).Str("did", stored.DID).Msg("Failed to save session")
+		}
+	}
+	return session, true
+}
+
+func (s *ClientSessionStore) getInMemory(token string) (*UserSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[token]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Since(session.LastUsed) > s.ttl {
+		delete(s.sessions, token)
+		return nil, false
+	}
+
+	session.LastUsed = time.Now()
+	return session, true
+}
+
+// Delete removes a session
+func (s *ClientSessionStore) Delete(token string) {
+	s.forget(token)
+	if s.storage == nil {
+		return
+	}
+	if err := s.storage.Delete(context.Background(), clientSessionKeyPrefix+token); err != nil {
+		log.Warn().Err(err).Msg("Failed to delete session")
+	}
+}
+
+// forget drops a session's client from memory
+func (s *ClientSessionStore) forget(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// save writes a session to storage, to expire after the TTL of inactivity
+func (s *ClientSessionStore) save(ctx context.Context, session *UserSession) error {
+	s.mu.RLock()
+	stored := storedUserSession{
+		DID:       session.Client.GetDID(),
+		Device:    session.Device,
+		CreatedAt: session.CreatedAt,
+		LastUsed:  session.LastUsed,
+	}
+	s.mu.RUnlock()
+
+	if credentials, ok := session.Client.SessionCredentials(); ok {
+		data, err := json.Marshal(credentials)
+		if err != nil {
+			return fmt.Errorf("failed to encode session credentials: %w", err)
+		}
+		if stored.Credentials, err = s.keys.Seal(data); err != nil {
+			return fmt.Errorf("failed to encrypt session credentials: %w", err)
+		}
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := s.storage.Put(ctx, clientSessionKeyPrefix+session.Token, data, stored.LastUsed.Add(s.ttl)); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	session.saved = stored.LastUsed
+	s.mu.Unlock()
+	return nil
+}
+
+// load reads a session from storage
+func (s *ClientSessionStore) load(ctx context.Context, token string) (*storedUserSession, error) {
+	data, err := s.storage.Get(ctx, clientSessionKeyPrefix+token)
+	if err != nil {
+		return nil, err
+	}
+	var stored storedUserSession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &stored, nil
+}
+
+// session returns the session stored under token, with its client from
+// memory or restored if this process hasn't used it yet
+func (s *ClientSessionStore) session(ctx context.Context, token string, stored *storedUserSession) (*UserSession, error) {
+	s.mu.RLock()
+	session, ok := s.sessions[token]
+	s.mu.RUnlock()
+	if ok {
+		return session, nil
+	}
+
+	var credentials *atproto.SessionCredentials
+	if len(stored.Credentials) > 0 {
+		data, err := s.keys.Open(stored.Credentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt session credentials: %w", err)
+		}
+		credentials = &atproto.SessionCredentials{}
+		if err := json.Unmarshal(data, credentials); err != nil {
+			return nil, fmt.Errorf("failed to decode session credentials: %w", err)
+		}
+	}
+	client, err := s.restore(ctx, token, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[token]; ok {
+		// Restored concurrently
+		return session, nil
+	}
+	session = &UserSession{
+		Token:     token,
+		ID:        sessionID(token),
+		Device:    stored.Device,
+		Client:    client,
+		CreatedAt: stored.CreatedAt,
+		LastUsed:  stored.LastUsed,
+		saved:     stored.LastUsed,
+	}
+	s.sessions[token] = session
+	return session, nil
+}
+
+// stored calls fn with each session in storage belonging to did, or to
+// anyone if did is empty. fn returns false to stop.
+func (s *ClientSessionStore) stored(ctx context.Context, did string, fn func(token string, stored *storedUserSession) bool) {
+	keys, err := s.storage.Keys(ctx, clientSessionKeyPrefix)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list sessions")
+		return
+	}
+	for _, key := range keys {
+		token := strings.TrimPrefix(key, clientSessionKeyPrefix)
+		stored, err := s.load(ctx, token)
+		if err != nil {
+			continue
+		}
+		if did != "" && stored.DID != did {
+			continue
+		}
+		if !fn(token, stored) {
+			return
+		}
+	}
+}
+
+// live returns the sessions in storage belonging to did, or to anyone if
+// did is empty, with their clients
+func (s *ClientSessionStore) live(did string) []*UserSession {
+	ctx := context.Background()
+	var sessions []*UserSession
+	s.stored(ctx, did, func(token string, stored *storedUserSession) bool {
+		session, err := s.session(ctx, token, stored)
+		if err != nil {
+			log.Warn().Err(err).Str("did", stored.DID).Msg("Failed to restore session")
+			return true
+		}
+		s.mu.RLock()
+		copied := *session
+		s.mu.RUnlock()
+		// Another replica may have used it more recently
+		if stored.LastUsed.After(copied.LastUsed) {
+			copied.LastUsed = stored.LastUsed
+		}
+		sessions = append(sessions, &copied)
+		return true
+	})
+	return sessions
+}
+
+// ListForDID returns the live sessions belonging to a user, most recently used first
+func (s *ClientSessionStore) ListForDID(did string) []*UserSession {
+	var sessions []*UserSession
+	if s.storage != nil {
+		sessions = s.live(did)
+	} else {
+		s.mu.RLock()
+		for _, session := range s.sessions {
+			if session.Client.GetDID() != did || time.Since(session.LastUsed) > s.ttl {
+				continue
+			}
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+		s.mu.RUnlock()
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsed.After(sessions[j].LastUsed)
+	})
+	return sessions
+}
+
+// Clients returns the most recently used live client of each signed-in user
+func (s *ClientSessionStore) Clients() []*atproto.Client {
+	var sessions []*UserSession
+	if s.storage != nil {
+		sessions = s.live("")
+	} else {
+		s.mu.RLock()
+		for _, session := range s.sessions {
+			if time.Since(session.LastUsed) <= s.ttl {
+				copied := *session
+				sessions = append(sessions, &copied)
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	latest := make(map[string]*UserSession)
+	for _, session := range sessions {
+		did := session.Client.GetDID()
+		if current, ok := latest[did]; !ok || session.LastUsed.After(current.LastUsed) {
+			latest[did] = session
+		}
+	}
+
+	clients := make([]*atproto.Client, 0, len(latest))
+	for _, session := range latest {
+		clients = append(clients, session.Client)
+	}
+	return clients
+}
+
+// RevokeByID removes one of a user's sessions by its public ID and returns
+// its token
+func (s *ClientSessionStore) RevokeByID(did, id string) (string, bool) {
+	if s.storage != nil {
+		revoked := ""
+		s.stored(context.Background(), did, func(token string, stored *storedUserSession) bool {
+			if sessionID(token) != id {
+				return true
+			}
+			s.Delete(token)
+			revoked = token
+			return false
+		})
+		return revoked, revoked != ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, session := range s.sessions {
+		if session.ID == id && session.Client.GetDID() == did {
+			delete(s.sessions, token)
+			return token, true
+		}
+	}
+	return "", false
+}
+
+// RevokeOthers removes all of a user's sessions except keepToken and returns
+// the tokens removed
+func (s *ClientSessionStore) RevokeOthers(did, keepToken string) []string {
+	revoked := []string{}
+	if s.storage != nil {
+		s.stored(context.Background(), did, func(token string, stored *storedUserSession) bool {
+			if token != keepToken {
+				s.Delete(token)
+				revoked = append(revoked, token)
+			}
+			return true
+		})
+		return revoked
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, session := range s.sessions {
+		if token != keepToken && session.Client.GetDID() == did {
+			delete(s.sessions, token)
+			revoked = append(revoked, token)
+		}
+	}
+	return revoked
+}
+
+// CleanupExpiredSessions removes all sessions idle for longer than the TTL.
+// With storage, clients idle here are dropped from memory even if another
+// replica still uses them; they're restored if they come back.
+func (s *ClientSessionStore) CleanupExpiredSessions() {
+	s.mu.Lock()
+	for token, session := range s.sessions {
+		if time.Since(session.LastUsed) > s.ttl {
+			delete(s.sessions, token)
+		}
+	}
+	s.mu.Unlock()
+
+	if s.storage != nil {
+		if err := s.storage.DeleteExpired(context.Background(), time.Now()); err != nil {
+			log.Warn().Err(err).Msg("Failed to delete expired sessions")
+		}
+	}
+}
+
+// StartCleanupRoutine starts a goroutine that periodically cleans up expired sessions
+func (s *ClientSessionStore) StartCleanupRoutine() {
+	go func() {
+		ticker := time.NewTicker(15 * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.CleanupExpiredSessions()
+		}
+	}()
+}
+
+// sessionID derives a stable public identifier from a session token
+func sessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// deviceHint summarises a User-Agent as "Browser on OS"
+func deviceHint(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	case strings.HasPrefix(userAgent, "curl/"):
+		browser = "curl"
+	}
+
+	platform := ""
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		platform = "Android"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		platform = "iOS"
+	case strings.Contains(userAgent, "Windows"):
+		platform = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		platform = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		platform = "Linux"
+	}
+
+	if platform == "" {
+		return browser
+	}
+	return browser + " on " + platform
+}
+
+func generateSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SessionMiddleware resolves the X-Session-ID header to the user's AT Protocol
+// client so handlers act on behalf of the logged-in user. Requests without a
+// session header are anonymous: they can read public records, and routes
+// that write need RequireSession.
+func (s *Service) SessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(SessionHeader)
+		if token == "" || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if session, ok := s.lookupSession(r, token); ok {
+			ctx := context.WithValue(r.Context(), userClientKey, session.Client)
+			ctx = context.WithValue(ctx, sessionTokenKey, session.Token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		log.Warn().Str("path", r.URL.Path).Msg("Request with unknown or expired session")
+		apierror.Write(w, apierror.ErrInvalidSession)
+	})
+}
+
+// lookupSession finds the session with the given token, falling back to the
+// OAuth session with that ID
+func (s *Service) lookupSession(r *http.Request, token string) (*UserSession, bool) {
+	if session, ok := s.sessions.Get(token); ok {
+		return session, true
+	}
+	// An OAuth session gets its client the first time it's used, and again
+	// after a restart if its storage outlives the process
+	return s.oauthUserSession(r, token)
+}
+
+// oauthUserSession creates a client acting as the user of an OAuth session
+// and keeps it under the session's ID
+func (s *Service) oauthUserSession(r *http.Request, token string) (*UserSession, bool) {
+	client, err := s.oauthSessionClient(r.Context(), token)
+	if err != nil {
+		return nil, false
+	}
+	s.prepareUserClient(client)
+
+	if err := s.sessions.CreateWithToken(token, client, r.UserAgent()); err != nil {
+		log.Error().Err(err).Str("did", client.GetDID()).Msg("Failed to save OAuth session")
+		return nil, false
+	}
+	return s.sessions.Get(token)
+}
+
+// oauthSessionClient creates a client acting as the user of the OAuth session
+// with ID token
+func (s *Service) oauthSessionClient(ctx context.Context, token string) (*atproto.Client, error) {
+	if sessionStore == nil {
+		return nil, errors.New("OAuth is not enabled")
+	}
+	oauthSession, err := sessionStore.GetSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	pdsURL := oauthSession.PDSURL
+	if pdsURL == "" {
+		pdsURL = s.config.ATProto.PDSURL
+	}
+	client, err := atproto.NewClientWithOAuthSession(pdsURL, oauthSession)
+	if err != nil {
+		log.Error().Err(err).Str("did", oauthSession.DID).Msg("Failed to create client for OAuth session")
+		return nil, err
+	}
+	return client, nil
+}
+
+// SetSessionStorage keeps user sessions in storage, the same as OAuth
+// sessions, so they survive restarts and are shared between replicas
+func (s *Service) SetSessionStorage(storage oauth.Storage, keys *oauth.KeyBox) {
+	s.sessions.SetStorage(storage, keys, s.restoreClient)
+}
+
+// restoreClient rebuilds the client of a session kept in storage, from its
+// tokens for password sessions or from its OAuth session
+func (s *Service) restoreClient(ctx context.Context, token string, credentials *atproto.SessionCredentials) (*atproto.Client, error) {
+	var client *atproto.Client
+	if credentials != nil {
+		client = atproto.NewClientWithSessionCredentials(*credentials)
+	} else {
+		var err error
+		if client, err = s.oauthSessionClient(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+	s.prepareUserClient(client)
+	return client, nil
+}
+
+// prepareUserClient gives a signed-in user's client the service client's
+// settings
+func (s *Service) prepareUserClient(client *atproto.Client) {
+	if s.wrapTransport != nil {
+		client.WrapTransport(s.wrapTransport)
+	}
+	client.SetRetryPolicy(s.client.RetryPolicy())
+	client.SetListPageSize(s.client.ListPageSize())
+	client.SetRecordCache(s.client.RecordCache())
+	client.SetMoveIndex(s.client.MoveIndex())
+	client.SetDrawOfferTimeout(s.client.DrawOfferTimeout())
+	client.SetChallengeCap(s.client.ChallengeCap())
+	client.SetBotAccounts(s.client.BotAccounts())
+	client.SetResolver(s.client.Resolver())
+	client.SetClockPauses(s.client.ClockPauses())
+}
+
+// RequireSession rejects requests without a logged-in session. Every route
+// that writes records or acts on the caller's own account uses it. It must
+// run after SessionMiddleware.
+func (s *Service) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := s.currentSession(r); !ok {
+			apierror.Write(w, apierror.ErrUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientFor returns the AT Protocol client acting on behalf of the request's
+// user, or nil for anonymous requests. It never falls back to the service
+// account; handlers of routes requiring a session always get a client.
+func (s *Service) clientFor(r *http.Request) *atproto.Client {
+	client, _ := r.Context().Value(userClientKey).(*atproto.Client)
+	return client
+}
+
+// readerFor returns a client to read public records with: the user's own, or
+// the service account's for anonymous requests. Nothing may be written with it.
+func (s *Service) readerFor(r *http.Request) *atproto.Client {
+	if client := s.clientFor(r); client != nil {
+		return client
+	}
+	return s.client
+}
+
+// callerDID returns the DID of the request's user, or "" for anonymous
+// requests
+func (s *Service) callerDID(r *http.Request) string {
+	if client := s.clientFor(r); client != nil {
+		return client.GetDID()
+	}
+	return ""
+}
+
+// SessionInfo describes a session without exposing its token
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	Device    string    `json:"device"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastUsed  time.Time `json:"lastUsed"`
+	Current   bool      `json:"current"`
+}
+
+// currentSession returns the password session the request was made with
+func (s *Service) currentSession(r *http.Request) (string, string, bool) {
+	token, ok := r.Context().Value(sessionTokenKey).(string)
+	if !ok || token == "" {
+		return "", "", false
+	}
+	return token, s.clientFor(r).GetDID(), true
+}
+
+// ListSessionsHandler lists the caller's active sessions across devices
+func (s *Service) ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	token, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	sessions := []SessionInfo{}
+	for _, session := range s.sessions.ListForDID(did) {
+		sessions = append(sessions, SessionInfo{
+			ID:        session.ID,
+			Device:    session.Device,
+			CreatedAt: session.CreatedAt,
+			LastUsed:  session.LastUsed,
+			Current:   session.Token == token,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+// RevokeSessionHandler revokes one of the caller's sessions by ID
+func (s *Service) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	token, ok := s.sessions.RevokeByID(did, id)
+	if !ok {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Session not found"))
+		return
+	}
+	s.deleteOAuthSession(r.Context(), token)
+
+	log.Info().Str("did", did).Str("session", id).Msg("Session revoked")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessionsHandler signs the caller out everywhere except the current session
+func (s *Service) RevokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	token, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	revoked := s.sessions.RevokeOthers(did, token)
+	for _, token := range revoked {
+		s.deleteOAuthSession(r.Context(), token)
+	}
+	log.Info().Str("did", did).Int("revoked", len(revoked)).Msg("Other sessions revoked")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"revoked": len(revoked),
+	})
+}
+
+// deleteOAuthSession deletes the OAuth session of a revoked session's token,
+// if it was signed in with OAuth, so SessionMiddleware can't restore it
+func (s *Service) deleteOAuthSession(ctx context.Context, token string) {
+	if sessionStore == nil {
+		return
+	}
+	if err := sessionStore.DeleteSession(ctx, token); err != nil {
+		log.Warn().Err(err).Msg("Failed to delete OAuth session")
+	}
+}
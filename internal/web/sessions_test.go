@@ -0,0 +1,429 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/oauth"
+)
+
+func TestClientSessionStoreExpiresIdleSessions(t *testing.T) {
+	store := NewClientSessionStore(time.Minute)
+
+	token, err := store.Create(&atproto.Client{})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	if _, ok := store.Get(token); !ok {
+		t.Fatalf("Expected fresh session to be found")
+	}
+
+	store.sessions[token].LastUsed = time.Now().Add(-2 * time.Minute)
+	if _, ok := store.Get(token); ok {
+		t.Errorf("Expected idle session to be expired")
+	}
+}
+
+func TestSessionMiddlewareActsAsLoggedInUser(t *testing.T) {
+	servicePDS := newFakePDS(t, testWhiteDID)
+	userPDS := newFakePDS(t, testBlackDID)
+	service := newServiceForPDS(t, servicePDS)
+
+	userClient, err := atproto.NewClient(userPDS.URL, "user", "password")
+	if err != nil {
+		t.Fatalf("Failed to create user client: %v", err)
+	}
+	token, err := service.Sessions().Create(userClient)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	handler := service.SessionMiddleware(service.RequireSession(http.HandlerFunc(service.GetCurrentUserHandler)))
+
+	testCases := []struct {
+		name         string
+		token        string
+		expectedCode int
+		expectedDID  string
+	}{
+		{name: "no session is anonymous, never the service account", token: "", expectedCode: http.StatusUnauthorized},
+		{name: "valid session uses user client", token: token, expectedCode: http.StatusOK, expectedDID: testBlackDID},
+		{name: "unknown session is rejected", token: "bogus", expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/auth/current", nil)
+			if tc.token != "" {
+				req.Header.Set(SessionHeader, tc.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, got %d", tc.expectedCode, w.Code)
+			}
+			if tc.expectedDID == "" {
+				return
+			}
+
+			var resp map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if resp["did"] != tc.expectedDID {
+				t.Errorf("Expected did %s, got %v", tc.expectedDID, resp["did"])
+			}
+		})
+	}
+}
+
+func TestSessionMiddlewareActsAsOAuthUser(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, testWhiteDID))
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	original := sessionStore
+	sessionStore = oauth.NewSessionStore()
+	defer func() { sessionStore = original }()
+	ctx := context.Background()
+	token, err := sessionStore.CreateSession(ctx, &oauth.Session{
+		DID:         testBlackDID,
+		Handle:      "black.test",
+		PDSURL:      "https://pds.example.com",
+		AccessToken: "oauth-access",
+		ExpiresAt:   time.Now().Add(time.Hour),
+		DPoPKey:     key,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create OAuth session: %v", err)
+	}
+
+	handler := service.SessionMiddleware(service.RequireSession(http.HandlerFunc(service.GetCurrentUserHandler)))
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/api/auth/current", nil)
+		req.Header.Set(SessionHeader, token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var resp map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp["did"] != testBlackDID {
+			t.Fatalf("Expected to act as the OAuth user, got %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	// The client is kept, so the session is only read once
+	session, ok := service.Sessions().Get(token)
+	if !ok || session.Client.GetHandle() != "black.test" {
+		t.Fatalf("Expected the OAuth session's client to be kept, got %+v", session)
+	}
+
+	_ = sessionStore.DeleteSession(ctx, token)
+	service.Sessions().Delete(token)
+	req := httptest.NewRequest("GET", "/api/auth/current", nil)
+	req.Header.Set(SessionHeader, token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a deleted OAuth session to be rejected, got %d", w.Code)
+	}
+}
+
+func TestWebSocketAcceptsOAuthSession(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, testWhiteDID))
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	original := sessionStore
+	sessionStore = oauth.NewSessionStore()
+	defer func() { sessionStore = original }()
+	token, err := sessionStore.CreateSession(context.Background(), &oauth.Session{
+		DID:         testBlackDID,
+		Handle:      "black.test",
+		PDSURL:      "https://pds.example.com",
+		AccessToken: "oauth-access",
+		ExpiresAt:   time.Now().Add(time.Hour),
+		DPoPKey:     key,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create OAuth session: %v", err)
+	}
+
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(service.WebSocketHandler(hub))
+	defer server.Close()
+
+	// The player channel needs a signed-in user, so connecting proves the
+	// OAuth session was resolved
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/?channel=player&session=" + url.QueryEscape(token)
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		t.Fatalf("Expected the OAuth session to be accepted, got %d: %v", status, err)
+	}
+	conn.Close()
+}
+
+func TestRevokedOAuthSessionsAreSignedOut(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, testWhiteDID))
+	original := sessionStore
+	sessionStore = oauth.NewSessionStore()
+	defer func() { sessionStore = original }()
+
+	ctx := context.Background()
+	newOAuthSession := func() string {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		token, err := sessionStore.CreateSession(ctx, &oauth.Session{
+			DID:         testBlackDID,
+			Handle:      "black.test",
+			PDSURL:      "https://pds.example.com",
+			AccessToken: "oauth-access",
+			ExpiresAt:   time.Now().Add(time.Hour),
+			DPoPKey:     key,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create OAuth session: %v", err)
+		}
+		return token
+	}
+	userClient, err := atproto.NewClient(newFakePDS(t, testBlackDID).URL, "user", "password")
+	if err != nil {
+		t.Fatalf("Failed to create user client: %v", err)
+	}
+	current, err := service.Sessions().Create(userClient)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	serve := func(handler http.HandlerFunc, method, token string, vars map[string]string) int {
+		req := httptest.NewRequest(method, "/api/auth/sessions", nil)
+		req.Header.Set(SessionHeader, token)
+		if vars != nil {
+			req = mux.SetURLVars(req, vars)
+		}
+		w := httptest.NewRecorder()
+		service.SessionMiddleware(service.RequireSession(handler)).ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Each OAuth session is used once, so it's listed with the others
+	one, other := newOAuthSession(), newOAuthSession()
+	for _, token := range []string{one, other} {
+		if code := serve(service.GetCurrentUserHandler, "GET", token, nil); code != http.StatusOK {
+			t.Fatalf("Expected the OAuth session to act as the user, got %d", code)
+		}
+	}
+
+	if code := serve(service.RevokeSessionHandler, "DELETE", current, map[string]string{"id": sessionID(one)}); code != http.StatusNoContent {
+		t.Fatalf("Expected the OAuth session to be revoked, got %d", code)
+	}
+	if code := serve(service.RevokeOtherSessionsHandler, "POST", current, nil); code != http.StatusOK {
+		t.Fatalf("Expected the other sessions to be revoked, got %d", code)
+	}
+
+	// The revoked tokens can't be turned back into sessions from their
+	// OAuth sessions
+	for _, token := range []string{one, other} {
+		if code := serve(service.GetCurrentUserHandler, "GET", token, nil); code != http.StatusUnauthorized {
+			t.Errorf("Expected a revoked OAuth session to be rejected, got %d", code)
+		}
+		if _, err := sessionStore.GetSession(ctx, token); err == nil {
+			t.Error("Expected a revoked session's OAuth session to be deleted")
+		}
+	}
+}
+
+func TestSessionsInStorageAreSharedBetweenReplicas(t *testing.T) {
+	storage := oauth.NewMemoryStorage()
+	keys, err := oauth.NewKeyBox(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("Failed to create key box: %v", err)
+	}
+	replica := func() *Service {
+		service := newServiceForPDS(t, newFakePDS(t, testWhiteDID))
+		service.SetSessionStorage(storage, keys)
+		return service
+	}
+	first, second := replica(), replica()
+
+	userClient, err := atproto.NewClient(newFakePDS(t, testBlackDID).URL, "user", "password")
+	if err != nil {
+		t.Fatalf("Failed to create user client: %v", err)
+	}
+	token, err := first.Sessions().CreateForDevice(userClient, "curl/8.0")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	data, err := storage.Get(context.Background(), clientSessionKeyPrefix+token)
+	if err != nil {
+		t.Fatalf("Expected the session in storage: %v", err)
+	}
+	if bytes.Contains(data, []byte("test-refresh-jwt")) {
+		t.Error("Expected the session's tokens to be encrypted at rest")
+	}
+
+	// The other replica, or this one after a restart, acts as the user
+	handler := second.SessionMiddleware(second.RequireSession(http.HandlerFunc(second.GetCurrentUserHandler)))
+	req := httptest.NewRequest("GET", "/api/auth/current", nil)
+	req.Header.Set(SessionHeader, token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp["did"] != testBlackDID {
+		t.Fatalf("Expected the session to act as the user on another replica, got %d %s", w.Code, w.Body.String())
+	}
+	if sessions := second.Sessions().ListForDID(testBlackDID); len(sessions) != 1 || sessions[0].Device != "curl" {
+		t.Errorf("Expected the session to be listed on another replica, got %+v", sessions)
+	}
+
+	// Revoking on one replica signs the session out on all of them
+	if _, ok := second.Sessions().RevokeByID(testBlackDID, sessionID(token)); !ok {
+		t.Fatal("Expected the session to be revoked")
+	}
+	if _, ok := first.Sessions().Get(token); ok {
+		t.Error("Expected a session revoked on another replica to be unusable")
+	}
+}
+
+func TestLoginHandlerIssuesUsableSession(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	service := newServiceForPDS(t, pds)
+	service.config.ATProto.PDSURL = pds.URL
+
+	body, _ := json.Marshal(AuthRequest{Handle: "user", Password: "password"})
+	w := httptest.NewRecorder()
+	service.LoginHandler(w, httptest.NewRequest("POST", "/api/auth/login", bytes.NewReader(body)))
+
+	var resp AuthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse login response: %v", err)
+	}
+	if !resp.Success || resp.AccessToken == "" {
+		t.Fatalf("Expected successful login with a session token, got %+v", resp)
+	}
+
+	session, ok := service.Sessions().Get(resp.AccessToken)
+	if !ok {
+		t.Fatalf("Expected login to create a session")
+	}
+	if session.Client.GetDID() != testBlackDID {
+		t.Errorf("Expected session client for %s, got %s", testBlackDID, session.Client.GetDID())
+	}
+}
+
+func TestSessionManagementListsAndRevokesDeviceSessions(t *testing.T) {
+	servicePDS := newFakePDS(t, testWhiteDID)
+	userPDS := newFakePDS(t, testBlackDID)
+	otherPDS := newFakePDS(t, "did:plc:other")
+	service := newServiceForPDS(t, servicePDS)
+
+	newClient := func(pds *fakePDS) *atproto.Client {
+		client, err := atproto.NewClient(pds.URL, "user", "password")
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		return client
+	}
+
+	laptop, _ := service.Sessions().CreateForDevice(newClient(userPDS), "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0")
+	phone, _ := service.Sessions().CreateForDevice(newClient(userPDS), "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Version/17.0 Mobile/15E148 Safari/604.1")
+	tablet, _ := service.Sessions().CreateForDevice(newClient(userPDS), "")
+	stranger, _ := service.Sessions().CreateForDevice(newClient(otherPDS), "curl/8.0")
+
+	do := func(method, path, token string, handler http.HandlerFunc, vars map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set(SessionHeader, token)
+		}
+		if vars != nil {
+			req = mux.SetURLVars(req, vars)
+		}
+		w := httptest.NewRecorder()
+		service.SessionMiddleware(handler).ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("GET", "/api/auth/sessions", laptop, service.ListSessionsHandler, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected session list, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Sessions []SessionInfo `json:"sessions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(resp.Sessions) != 3 {
+		t.Fatalf("Expected the user's 3 sessions, got %+v", resp.Sessions)
+	}
+	devices := map[string]bool{}
+	for _, session := range resp.Sessions {
+		devices[session.Device] = true
+		if session.Current != (session.ID == sessionID(laptop)) {
+			t.Errorf("Expected only the laptop session to be current, got %+v", session)
+		}
+	}
+	if !devices["Firefox on Linux"] || !devices["Safari on iOS"] || !devices["Unknown device"] {
+		t.Errorf("Unexpected device hints: %v", devices)
+	}
+	if strings.Contains(w.Body.String(), laptop) {
+		t.Error("Session tokens must not be exposed in the listing")
+	}
+
+	phoneID := sessionID(phone)
+
+	// Another user can't revoke our sessions
+	if w := do("DELETE", "/api/auth/sessions/"+phoneID, stranger, service.RevokeSessionHandler, map[string]string{"id": phoneID}); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 revoking another user's session, got %d", w.Code)
+	}
+
+	if w := do("DELETE", "/api/auth/sessions/"+phoneID, laptop, service.RevokeSessionHandler, map[string]string{"id": phoneID}); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected phone session to be revoked, got %d", w.Code)
+	}
+	if _, ok := service.Sessions().Get(phone); ok {
+		t.Error("Expected revoked session to be unusable")
+	}
+
+	if w := do("DELETE", "/api/auth/sessions", laptop, service.RevokeOtherSessionsHandler, nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected other sessions to be revoked, got %d", w.Code)
+	}
+	if _, ok := service.Sessions().Get(tablet); ok {
+		t.Error("Expected tablet session to be revoked")
+	}
+	if _, ok := service.Sessions().Get(laptop); !ok {
+		t.Error("Expected current session to survive")
+	}
+	if _, ok := service.Sessions().Get(stranger); !ok {
+		t.Error("Expected other users' sessions to be untouched")
+	}
+
+	if w := do("GET", "/api/auth/sessions", "", service.ListSessionsHandler, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a session, got %d", w.Code)
+	}
+}
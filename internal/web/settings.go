@@ -0,0 +1,106 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/lexicon"
+	"github.com/rs/zerolog/log"
+)
+
+// maxDenyListLength bounds the challenge deny-list, as its lexicon does
+const maxDenyListLength = 1000
+
+// Settings say who a player accepts challenges from. They're stored as the
+// public app.atchess.settings record in the player's own repository, so
+// other instances refuse challenges the player doesn't want too.
+type Settings struct {
+	// ChallengeDenyList holds the DIDs of players whose challenges are
+	// refused. Handles may be given when saving; they're stored as DIDs.
+	ChallengeDenyList []string `json:"challengeDenyList"`
+	UpdatedAt         string   `json:"updatedAt,omitempty"`
+}
+
+// GetSettingsHandler returns the current player's settings, as saved in
+// their repository
+func (s *Service) GetSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.Context().Value(sessionTokenKey).(string); !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	client := s.clientFor(r)
+	record, err := client.GetSettings(r.Context(), client.GetDID())
+	if errors.Is(err, atproto.ErrNoSettings) {
+		record, err = &atproto.SettingsRecord{}, nil
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read settings record")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to read settings"))
+		return
+	}
+
+	settings := Settings{ChallengeDenyList: record.ChallengeDenyList, UpdatedAt: record.UpdatedAt}
+	if settings.ChallengeDenyList == nil {
+		settings.ChallengeDenyList = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(settings)
+}
+
+// SaveSettingsHandler replaces the current player's settings, writing them to
+// the app.atchess.settings record in their repository
+func (s *Service) SaveSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.Context().Value(sessionTokenKey).(string); !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	var settings Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	if len(settings.ChallengeDenyList) > maxDenyListLength {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(fmt.Sprintf("challengeDenyList may list at most %d players", maxDenyListLength)))
+		return
+	}
+
+	client := s.clientFor(r)
+	denyList := make([]string, 0, len(settings.ChallengeDenyList))
+	seen := make(map[string]bool)
+	for _, player := range settings.ChallengeDenyList {
+		did := player
+		if !strings.HasPrefix(did, "did:") {
+			resolved, err := client.ResolveHandle(r.Context(), player)
+			if err != nil {
+				apierror.Write(w, apierror.ErrBadRequest.WithMessage(fmt.Sprintf("Failed to resolve handle '%s'", player)))
+				return
+			}
+			did = resolved
+		}
+		if !seen[did] {
+			seen[did] = true
+			denyList = append(denyList, did)
+		}
+	}
+
+	record := &atproto.SettingsRecord{ChallengeDenyList: denyList}
+	if err := client.PutSettings(r.Context(), record); err != nil {
+		if errors.Is(err, lexicon.ErrInvalidRecord) {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+			return
+		}
+		log.Error().Err(err).Msg("Failed to save settings record")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to save settings"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(Settings{ChallengeDenyList: denyList, UpdatedAt: record.UpdatedAt})
+}
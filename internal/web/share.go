@@ -0,0 +1,176 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/boardimage"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// ShareGameRequest is the optional body of ShareGameHandler
+type ShareGameRequest struct {
+	// Image attaches the final position to the post
+	Image bool `json:"image"`
+}
+
+// SharedResult describes a published result post
+type SharedResult struct {
+	Post atproto.PostRef `json:"post"`
+	URL  string          `json:"url"` // the post on bsky.app
+}
+
+// ShareGameHandler posts the result of one of the caller's finished games to
+// their Bluesky feed, e.g. "I beat @alice.bsky.social in 34 moves ♟️", with a
+// link to the game
+func (s *Service) ShareGameHandler(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := s.currentSession(r); !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	var req ShareGameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+
+	client := s.clientFor(r)
+	game, ok := s.loadGameForPlayer(w, client.GetGame, gameID, client.GetDID())
+	if !ok {
+		return
+	}
+	if game.Status == chess.StatusActive {
+		apierror.Write(w, apierror.ErrGameInProgress)
+		return
+	}
+
+	shared, err := s.shareResult(context.Background(), client, game, game.Status, req.Image)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to share game result")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to share game result"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(shared)
+}
+
+// shareResult posts a game's result from the player's account. status is
+// passed separately because a game record owned by the opponent may not have
+// caught up with the move or resignation that ended it.
+func (s *Service) shareResult(ctx context.Context, client *atproto.Client, game *chess.Game, status chess.GameStatus, image bool) (*SharedResult, error) {
+	moves, err := client.GetMoves(ctx, game.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load moves: %w", err)
+	}
+
+	color := "white"
+	if client.GetDID() == game.Black && game.Black != game.White {
+		color = "black"
+	}
+
+	opponent := "my opponent"
+	var mentions []atproto.PostMention
+	switch opponentDID := opponentOf(game, client.GetDID()); {
+	case game.Bot != nil:
+		opponent = fmt.Sprintf("the computer (level %d)", game.Bot.Level)
+	case opponentDID != "":
+		if handle, err := client.LookupHandle(ctx, opponentDID); err != nil {
+			log.Warn().Err(err).Str("did", opponentDID).Msg("Failed to look up opponent's handle")
+		} else {
+			opponent = "@" + handle
+			mentions = []atproto.PostMention{{Text: opponent, DID: opponentDID}}
+		}
+	}
+
+	post := atproto.Post{
+		Text:     resultText(status, color, opponent, len(moves)),
+		Link:     s.gameLink(game.ID),
+		Mentions: mentions,
+	}
+	if post.Link != "" {
+		post.Text += "\n\n" + post.Link
+	}
+	post.Text = truncatePost(post.Text)
+
+	if image && len(moves) > 0 {
+		last := moves[len(moves)-1]
+		data, err := boardimage.PNG(last.FEN, boardimage.Options{Flipped: color == "black", Highlight: []string{last.From, last.To}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to render board: %w", err)
+		}
+		post.Images = []atproto.PostImage{{
+			Data:     data,
+			MimeType: "image/png",
+			Alt:      boardAlt(last, color, "Final position"),
+			Width:    boardimage.DefaultSize,
+			Height:   boardimage.DefaultSize,
+		}}
+	}
+
+	ref, err := client.CreatePost(ctx, post, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SharedResult{Post: *ref, URL: bskyPostURL(client.GetDID(), ref.URI)}, nil
+}
+
+// resultText describes a finished game from one player's side, e.g. "I beat
+// @alice.bsky.social in 34 moves ♟️"
+func resultText(status chess.GameStatus, color, opponent string, moves int) string {
+	length := fmt.Sprintf("%d moves", (moves+1)/2)
+	if moves == 1 || moves == 2 {
+		length = "1 move"
+	}
+	switch {
+	case status == chess.StatusDraw:
+		return fmt.Sprintf("I drew with %s in %s ♟️", opponent, length)
+	case status == chess.StatusAbandoned:
+		return fmt.Sprintf("My game against %s was abandoned after %s ♟️", opponent, length)
+	case string(status) == color+"_won":
+		return fmt.Sprintf("I beat %s in %s ♟️", opponent, length)
+	}
+	return fmt.Sprintf("I lost to %s in %s ♟️", opponent, length)
+}
+
+// resignedStatus is the status a game ends in when player resigns it
+func resignedStatus(game *chess.Game, player string) chess.GameStatus {
+	if player == game.White {
+		return chess.StatusBlackWon
+	}
+	return chess.StatusWhiteWon
+}
+
+// shareIfRequested shares the result of a game that a move or resignation
+// just ended, when the player asked for it alongside. Failures are logged
+// rather than failing the move, which has already been recorded.
+func (s *Service) shareIfRequested(r *http.Request, client *atproto.Client, game *chess.Game, status chess.GameStatus, req *ShareGameRequest) *SharedResult {
+	if req == nil {
+		return nil
+	}
+	if _, _, ok := s.currentSession(r); !ok {
+		return nil
+	}
+	shared, err := s.shareResult(context.Background(), client, game, status, req.Image)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", game.ID).Msg("Failed to share game result")
+		return nil
+	}
+	return shared
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+)
+
+// sessionRequest serves a JSON request to handler through the session
+// middleware, signed in as the PDS's account when withSession is set
+func sessionRequest(t *testing.T, s *Service, pds *fakePDS, handler http.HandlerFunc, method, target string, vars map[string]string, body interface{}, withSession bool) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := mux.SetURLVars(httptest.NewRequest(method, target, reader), vars)
+	if withSession {
+		player, err := atproto.NewClient(pds.URL, "player", "password")
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		token, _ := s.Sessions().Create(player)
+		req.Header.Set(SessionHeader, token)
+	}
+	w := httptest.NewRecorder()
+	s.SessionMiddleware(handler).ServeHTTP(w, req)
+	return w
+}
+
+// facetFeatures returns the facet features of a post by type
+func facetFeatures(post map[string]interface{}) map[string]map[string]interface{} {
+	features := make(map[string]map[string]interface{})
+	facets, _ := post["facets"].([]interface{})
+	for _, facet := range facets {
+		list, _ := facet.(map[string]interface{})["features"].([]interface{})
+		for _, feature := range list {
+			feature := feature.(map[string]interface{})
+			features[feature["$type"].(string)] = feature
+		}
+	}
+	return features
+}
+
+func TestShareGamePostsResult(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	gameID := seedFoolsMate(pds, "black_won")
+	service := newServiceForPDS(t, pds)
+	service.config.Server.BaseURL = "https://chess.example"
+
+	encoded := base64.URLEncoding.EncodeToString([]byte(gameID))
+	vars := map[string]string{"id": encoded}
+	target := "/api/games/" + encoded + "/share"
+
+	w := sessionRequest(t, service, pds, service.ShareGameHandler, "POST", target, vars, nil, false)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected sharing to need a session, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = sessionRequest(t, service, pds, service.ShareGameHandler, "POST", target, vars, ShareGameRequest{Image: true}, true)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected the result to be shared, got %d: %s", w.Code, w.Body.String())
+	}
+	var shared SharedResult
+	_ = json.Unmarshal(w.Body.Bytes(), &shared)
+	if !strings.HasPrefix(shared.URL, "https://bsky.app/profile/"+testBlackDID+"/post/") {
+		t.Errorf("Unexpected post URL %q", shared.URL)
+	}
+
+	post := pds.get(shared.Post.URI)
+	text, _ := post["text"].(string)
+	if !strings.HasPrefix(text, "I beat @white.test in 2 moves ♟️\n\nhttps://chess.example/") {
+		t.Errorf("Unexpected post text %q", text)
+	}
+	features := facetFeatures(post)
+	if mention := features["app.bsky.richtext.facet#mention"]; mention == nil || mention["did"] != testWhiteDID {
+		t.Errorf("Expected the opponent to be mentioned, got %v", post["facets"])
+	}
+	if features["app.bsky.richtext.facet#link"] == nil {
+		t.Errorf("Expected the game link to be marked up, got %v", post["facets"])
+	}
+	if len(pds.blobTypes) != 1 || pds.blobTypes[0] != "image/png" {
+		t.Errorf("Expected the final position as a PNG, got %v", pds.blobTypes)
+	}
+
+	// Without the image option the post is text only
+	w = sessionRequest(t, service, pds, service.ShareGameHandler, "POST", target, vars, nil, true)
+	if w.Code != http.StatusCreated || len(pds.blobTypes) != 1 {
+		t.Errorf("Expected a text post, got %d with blobs %v", w.Code, pds.blobTypes)
+	}
+}
+
+func TestShareGameRejectsUnfinishedGames(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	gameID := seedFoolsMate(pds, "active")
+	service := newServiceForPDS(t, pds)
+
+	encoded := base64.URLEncoding.EncodeToString([]byte(gameID))
+	w := sessionRequest(t, service, pds, service.ShareGameHandler, "POST", "/api/games/"+encoded+"/share", map[string]string{"id": encoded}, nil, true)
+	if w.Code != http.StatusConflict || errorCode(t, w) != "game_in_progress" {
+		t.Errorf("Expected game_in_progress, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(pds.collection(testBlackDID, "app.bsky.feed.post")) != 0 {
+		t.Error("Expected nothing to be posted")
+	}
+}
+
+func TestMatingMoveSharesResult(t *testing.T) {
+	pds := newFakePDS(t, testBlackDID)
+	gameID := seedGame(pds, "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq g3 0 2", "active")
+	seedMove(pds, gameID, testWhiteDID, "m1", "f2", "f3", "f3", "rnbqkbnr/pppppppp/8/8/8/5P2/PPPPP1PP/RNBQKBNR b KQkq - 0 1")
+	seedMove(pds, gameID, testBlackDID, "m2", "e7", "e5", "e5", "rnbqkbnr/pppp1ppp/8/4p3/8/5P2/PPPPP1PP/RNBQKBNR w KQkq e6 0 2")
+	seedMove(pds, gameID, testWhiteDID, "m3", "g2", "g4", "g4", "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq g3 0 2")
+	service := newServiceForPDS(t, pds)
+
+	w := sessionRequest(t, service, pds, service.MakeMoveHandler, "POST", "/api/moves", nil, MakeMoveRequest{
+		GameID: gameID,
+		Move:   "Qh4#",
+		Share:  &ShareGameRequest{},
+	}, true)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the move to be made, got %d: %s", w.Code, w.Body.String())
+	}
+	var response MakeMoveResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	if response.MoveResult == nil || !response.Checkmate || response.Shared == nil {
+		t.Fatalf("Expected checkmate and a shared result, got %s", w.Body.String())
+	}
+	if text, _ := pds.get(response.Shared.Post.URI)["text"].(string); text != "I beat @white.test in 2 moves ♟️" {
+		t.Errorf("Unexpected post text %q", text)
+	}
+}
+
+func TestResignSharesResult(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	seedMove(pds, gameID, testWhiteDID, "m1", "e2", "e4", "e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1")
+	service := newServiceForPDS(t, pds)
+
+	w := sessionRequest(t, service, pds, service.ResignGameHandler, "POST", "/api/resign", nil, map[string]interface{}{
+		"gameId": gameID,
+		"share":  map[string]interface{}{},
+	}, true)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the resignation to be recorded, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Shared *SharedResult `json:"shared"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Shared == nil {
+		t.Fatalf("Expected a shared result, got %s", w.Body.String())
+	}
+	if text, _ := pds.get(response.Shared.Post.URI)["text"].(string); text != "I lost to @black.test in 1 move ♟️" {
+		t.Errorf("Unexpected post text %q", text)
+	}
+}
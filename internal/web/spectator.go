@@ -3,11 +3,16 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
 	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
 	"github.com/rs/zerolog/log"
 )
 
@@ -22,6 +27,8 @@ type GameIndex struct {
 	TimeControl   map[string]interface{} `json:"timeControl,omitempty"`
 	SpectatorCount int              `json:"spectatorCount"`
 	MaterialCount chess.MaterialCount `json:"materialCount"`
+	Instance      string            `json:"instance,omitempty"` // DID of the hosting instance for federated games
+	Extensions    chess.Extensions  `json:"extensions,omitempty"` // metadata other apps attached to the game
 }
 
 type GamePlayers struct {
@@ -34,20 +41,130 @@ type PlayerInfo struct {
 	Handle string `json:"handle"`
 }
 
-// GetActiveGamesHandler returns a list of active games for spectating
+// SpectatorMove is an entry in a spectated game's move list
+type SpectatorMove struct {
+	Ply          int    `json:"ply"`
+	SAN          string `json:"san"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Promotion    string `json:"promotion,omitempty"`
+	Player       string `json:"player"`
+	Color        string `json:"color"` // "white" or "black"
+	CreatedAt    string `json:"createdAt"`
+	ThinkSeconds int    `json:"thinkSeconds"`           // since the previous move, or the start of the game
+	ClockSeconds *int   `json:"clockSeconds,omitempty"` // mover's time left after the move, in games with a clock
+}
+
+// LastMove is the move a spectator's board highlights
+type LastMove struct {
+	Ply   int    `json:"ply"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	SAN   string `json:"san"`
+	Check bool   `json:"check"`
+}
+
+// SpectatorClock is both players' time left when the game was loaded
+type SpectatorClock struct {
+	WhiteSeconds int    `json:"whiteSeconds"`
+	BlackSeconds int    `json:"blackSeconds"`
+	Running      string `json:"running,omitempty"` // color whose clock is running, while the game is active
+}
+
+// SetGameIndex backs the spectator listing with indexed games
+func (s *Service) SetGameIndex(indexer *index.Indexer) {
+	s.gameIndex = indexer
+}
+
+// GetActiveGamesHandler returns a list of games for spectating, filtered by
+// player, status (default active, "any" for all), timeControl and extension
+// namespace, and paged with limit and cursor
 func (s *Service) GetActiveGamesHandler(w http.ResponseWriter, r *http.Request) {
-	// In a real implementation, this would query indexed games from a database
-	// For now, we'll use the firehose processor's tracked games
+	params := r.URL.Query()
+	
+	query := index.Query{
+		Player:      params.Get("player"),
+		Status:      params.Get("status"),
+		TimeControl: params.Get("timeControl"),
+		Extension:   params.Get("extension"),
+		Cursor:      params.Get("cursor"),
+	}
+	switch query.Status {
+	case "":
+		query.Status = string(chess.StatusActive)
+	case "any":
+		query.Status = ""
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			apierror.Write(w, apierror.ErrInvalidLimit)
+			return
+		}
+		query.Limit = n
+	}
 	
-	// TODO: Implement proper game indexing service
-	// This is a placeholder that returns an empty list
 	games := []GameIndex{}
+	total := 0
+	nextCursor := ""
+	if s.gameIndex != nil {
+		page, err := s.gameIndex.ListGames(r.Context(), query)
+		if errors.Is(err, index.ErrInvalidCursor) {
+			apierror.Write(w, apierror.ErrInvalidCursor)
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to list indexed games")
+			apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list games"))
+			return
+		}
+		for _, game := range page.Games {
+			games = append(games, indexedGame(game))
+		}
+		total = page.Total
+		nextCursor = page.Cursor
+	}
 	
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+	// Include games from federated instances on the first page unless a peer is asking for our own
+	if params.Get("local") != "true" && query.Cursor == "" {
+		remote := s.remoteGames(r.Context())
+		games = append(games, remote...)
+		total += len(remote)
+	}
+	
+	response := map[string]interface{}{
 		"games": games,
-		"total": len(games),
-	})
+		"total": total,
+	}
+	if nextCursor != "" {
+		response["cursor"] = nextCursor
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// indexedGame converts an index entry to the spectator listing format
+func indexedGame(game *index.Game) GameIndex {
+	entry := GameIndex{
+		URI:        game.URI,
+		GameID:     game.URI,
+		Status:     chess.GameStatus(game.Status),
+		MoveCount:  game.MoveCount,
+		LastMoveAt: game.LastMoveAt,
+		Extensions: game.Extensions,
+		Players: GamePlayers{
+			White: PlayerInfo{DID: game.White},
+			Black: PlayerInfo{DID: game.Black},
+		},
+	}
+	if game.TimeControl != "" {
+		entry.TimeControl = map[string]interface{}{"type": game.TimeControl}
+	}
+	if engine, err := chess.NewEngineFromFEN(game.FEN); err == nil {
+		entry.MaterialCount = engine.GetMaterialCount()
+	}
+	return entry
 }
 
 // GetSpectatorGameHandler returns game data optimized for spectators
@@ -56,7 +173,7 @@ func (s *Service) GetSpectatorGameHandler(w http.ResponseWriter, r *http.Request
 	gameID := vars["id"]
 	
 	if gameID == "" {
-		http.Error(w, "Missing game ID", http.StatusBadRequest)
+		apierror.Write(w, apierror.ErrInvalidGameID.WithMessage("Missing game ID"))
 		return
 	}
 	
@@ -64,9 +181,10 @@ func (s *Service) GetSpectatorGameHandler(w http.ResponseWriter, r *http.Request
 	game, err := s.client.GetGame(context.Background(), gameID)
 	if err != nil {
 		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to fetch game for spectator")
-		http.Error(w, "Game not found", http.StatusNotFound)
+		apierror.Write(w, apierror.ErrGameNotFound)
 		return
 	}
+	game.UnderReview = s.reports.UnderReview(game.ID)
 	
 	// Get material count
 	engine, err := chess.NewEngineFromFEN(game.FEN)
@@ -79,19 +197,83 @@ func (s *Service) GetSpectatorGameHandler(w http.ResponseWriter, r *http.Request
 		materialCount = engine.GetMaterialCount()
 	}
 	
-	// TODO: Get moves from AT Protocol when move records are implemented
-	// For now, moves are parsed from PGN in the engine
+	// Move history from both players' repositories
+	moves, err := s.client.GetMoves(context.Background(), gameID)
+	if err != nil {
+		log.Warn().Err(err).Str("gameID", gameID).Msg("Failed to load move history for spectator")
+		moves = []*chess.Move{}
+	}
+	moveList, clock := spectatorMoves(game, moves, time.Now())
 	
 	// Prepare spectator response
 	response := map[string]interface{}{
 		"game": game,
 		"materialCount": materialCount,
+		"moves": moveList,
+	}
+	if clock != nil {
+		response["clock"] = clock
+	}
+	if len(moves) > 0 {
+		last := moves[len(moves)-1]
+		response["lastMove"] = LastMove{
+			Ply:   last.Ply,
+			From:  last.From,
+			To:    last.To,
+			SAN:   last.SAN,
+			Check: strings.ContainsAny(last.SAN, "+#"),
+		}
+	}
+	
+	// The same evaluation the kibitz channel streams, kept from the players
+	// like it is there
+	if s.kibitzer != nil && !s.isActivePlayer(gameID, s.callerDID(r)) {
+		if eval, err := s.kibitzer.Evaluation(gameID, game.Variant, game.FEN); err != nil {
+			log.Warn().Err(err).Str("gameID", gameID).Msg("Failed to evaluate position for spectator")
+		} else {
+			response["evaluation"] = eval
+		}
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// spectatorMoves lists a game's moves with the time each took and, in games
+// with a clock, the time each player had left. The clock is nil for games
+// without one, or when a move's time can't be told.
+func spectatorMoves(game *chess.Game, moves []*chess.Move, now time.Time) ([]SpectatorMove, *SpectatorClock) {
+	timing := gameTiming(game, moves, now)
+	list := make([]SpectatorMove, 0, len(moves))
+	for i, move := range moves {
+		entry := SpectatorMove{
+			Ply:          move.Ply,
+			SAN:          move.SAN,
+			From:         move.From,
+			To:           move.To,
+			Promotion:    move.Promotion,
+			Player:       move.Player,
+			Color:        timing.Moves[i].Color,
+			CreatedAt:    move.CreatedAt,
+			ThinkSeconds: int(timing.Moves[i].ThinkMs / 1000),
+		}
+		if left := timing.Moves[i].ClockMs; left != nil {
+			seconds := int(*left / 1000)
+			entry.ClockSeconds = &seconds
+		}
+		list = append(list, entry)
+	}
+	
+	if timing.White.ClockMs == nil {
+		return list, nil
+	}
+	return list, &SpectatorClock{
+		WhiteSeconds: int(*timing.White.ClockMs / 1000),
+		BlackSeconds: int(*timing.Black.ClockMs / 1000),
+		Running:      timing.Running,
+	}
+}
+
 // UpdateSpectatorCountHandler updates the spectator count for a game
 func (s *Service) UpdateSpectatorCountHandler(hub *Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -102,7 +284,7 @@ func (s *Service) UpdateSpectatorCountHandler(hub *Hub) http.HandlerFunc {
 			Action string `json:"action"` // "join" or "leave"
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			apierror.Write(w, apierror.ErrInvalidBody)
 			return
 		}
 		
@@ -139,7 +321,7 @@ func (s *Service) CheckAbandonmentHandler(w http.ResponseWriter, r *http.Request
 	// Fetch game
 	game, err := s.client.GetGame(context.Background(), gameID)
 	if err != nil {
-		http.Error(w, "Game not found", http.StatusNotFound)
+		apierror.Write(w, apierror.ErrGameNotFound)
 		return
 	}
 	
@@ -153,13 +335,18 @@ func (s *Service) CheckAbandonmentHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 	
-	// TODO: Get last move from AT Protocol when move records are implemented
-	// For now, use game creation time as last activity
+	// Last activity is the most recent move, or game creation if nobody has moved
 	lastActivityStr := game.CreatedAt
+	moves, err := s.client.GetMoves(context.Background(), gameID)
+	if err != nil {
+		log.Warn().Err(err).Str("gameID", gameID).Msg("Failed to load moves, using game creation time")
+	} else if len(moves) > 0 {
+		lastActivityStr = moves[len(moves)-1].CreatedAt
+	}
 	lastActivityTime, err := time.Parse(time.RFC3339, lastActivityStr)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to parse activity time")
-		http.Error(w, "Invalid timestamp", http.StatusInternalServerError)
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Invalid timestamp"))
 		return
 	}
 	
@@ -187,5 +374,5 @@ func (s *Service) ClaimAbandonedGameHandler(w http.ResponseWriter, r *http.Reque
 	// 3. Updates game status to winner
 	// 4. Creates a system move or note about abandonment
 	
-	http.Error(w, "Not implemented", http.StatusNotImplemented)
+	apierror.Write(w, apierror.ErrNotImplemented)
 }
\ No newline at end of file
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+func TestSpectatorMovesTracksClocks(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) string { return start.Add(time.Duration(seconds) * time.Second).Format(time.RFC3339) }
+	game := &chess.Game{
+		White:       testWhiteDID,
+		Black:       testBlackDID,
+		Status:      chess.StatusActive,
+		FEN:         "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2",
+		CreatedAt:   at(0),
+		TimeControl: &chess.TimeControl{Type: "blitz", Initial: 300, Increment: 2},
+	}
+	moves := []*chess.Move{
+		{Ply: 1, Player: testWhiteDID, From: "e2", To: "e4", SAN: "e4", CreatedAt: at(10)},
+		{Ply: 2, Player: testBlackDID, From: "e7", To: "e5", SAN: "e5", CreatedAt: at(40)},
+	}
+
+	list, clock := spectatorMoves(game, moves, start.Add(100*time.Second))
+	if len(list) != 2 || list[1].Color != "black" || list[1].ThinkSeconds != 30 {
+		t.Fatalf("Expected black's reply after 30s, got %+v", list)
+	}
+	if *list[0].ClockSeconds != 292 || *list[1].ClockSeconds != 272 {
+		t.Errorf("Expected clocks of 292s and 272s after the increments, got %d and %d", *list[0].ClockSeconds, *list[1].ClockSeconds)
+	}
+	if clock == nil || clock.Running != "white" || clock.WhiteSeconds != 232 || clock.BlackSeconds != 272 {
+		t.Errorf("Expected white's clock running down from the last move, got %+v", clock)
+	}
+
+	game.TimeControl = nil
+	if list, clock := spectatorMoves(game, moves, time.Now()); clock != nil || list[0].ClockSeconds != nil {
+		t.Errorf("Expected no clocks in an untimed game, got %+v", clock)
+	}
+}
+
+func TestGetSpectatorGameRendersLiveBoard(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedFoolsMate(pds, "completed")
+	service := newServiceForPDS(t, pds)
+	hub := NewHub()
+	go hub.Run()
+	service.SetKibitzer(NewKibitzer(hub, service.client.GetGame, 1, time.Hour))
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/spectator/games/x", nil), map[string]string{"id": gameID})
+	w := httptest.NewRecorder()
+	service.GetSpectatorGameHandler(w, req)
+
+	var resp struct {
+		Moves      []SpectatorMove   `json:"moves"`
+		LastMove   *LastMove         `json:"lastMove"`
+		Evaluation *chess.Evaluation `json:"evaluation"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(resp.Moves) != 4 || resp.Moves[0].SAN != "f3" || resp.Moves[3].Color != "black" {
+		t.Errorf("Expected the four moves in order, got %+v", resp.Moves)
+	}
+	if resp.LastMove == nil || resp.LastMove.From != "d8" || resp.LastMove.To != "h4" || !resp.LastMove.Check {
+		t.Errorf("Expected Qh4# highlighted, got %+v", resp.LastMove)
+	}
+	if resp.Evaluation == nil {
+		t.Error("Expected the kibitz evaluation")
+	}
+}
@@ -0,0 +1,154 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/rating"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// instanceStatsDays is how many days of games per day are reported
+	instanceStatsDays = 30
+	// instanceStatsActiveWindow is how recently a player must have played to count as active
+	instanceStatsActiveWindow = 7 * 24 * time.Hour
+	// instanceStatsTTL is how long computed statistics are served before
+	// being recomputed; computing them reads the whole index
+	instanceStatsTTL = 5 * time.Minute
+	// maxPlayerStatsPeriods caps the days or weeks of a player's activity
+	// series
+	maxPlayerStatsPeriods = 366
+)
+
+// defaultPlayerStatsPeriods is how many periods of each interval a player's
+// activity series covers unless periods says otherwise
+var defaultPlayerStatsPeriods = map[string]int{index.IntervalDay: 30, index.IntervalWeek: 12}
+
+// InstanceStatsHandler returns aggregate metrics about the games on this
+// instance for community dashboards. They contain no DIDs or game URIs, so
+// they're served without authentication, and are cached since computing
+// them scans every indexed game.
+func (s *Service) InstanceStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.gameIndex == nil {
+		apierror.Write(w, apierror.ErrUnavailable.WithMessage("Game index is not enabled"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(instanceStatsTTL.Seconds())))
+	if body, ok := s.statsCache.get("instance"); ok {
+		w.Header().Set("X-Cache", "HIT")
+		_, _ = w.Write(body)
+		return
+	}
+
+	stats, err := s.gameIndex.Stats(r.Context(), time.Now(), instanceStatsDays, instanceStatsActiveWindow)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to compute instance statistics")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to compute statistics"))
+		return
+	}
+	body, err := json.Marshal(stats)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode instance statistics")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to compute statistics"))
+		return
+	}
+	s.statsCache.put("instance", body)
+
+	w.Header().Set("X-Cache", "MISS")
+	_, _ = w.Write(body)
+}
+
+// RatingPeriod is a player's overall rating at the end of one day or week,
+// and how many rated games they played in it. The rating is left out before
+// their first rated game.
+type RatingPeriod struct {
+	Start string `json:"start"` // YYYY-MM-DD
+	Games int    `json:"games"`
+	*rating.Glicko
+}
+
+// PlayerStatsResponse is a player's statistics from the game index, with
+// their rating history over the same periods when ratings are enabled
+type PlayerStatsResponse struct {
+	*index.PlayerStats
+	RatingHistory []RatingPeriod `json:"ratingHistory,omitempty"`
+}
+
+// PlayerStatsHandler returns a player's game statistics for profile graphs:
+// their record overall and by color, average game length, favorite openings,
+// and their results and rating over the last periods days or weeks, as set
+// by interval (week by default)
+func (s *Service) PlayerStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.gameIndex == nil {
+		apierror.Write(w, apierror.ErrUnavailable.WithMessage("Game index is not enabled"))
+		return
+	}
+
+	did := mux.Vars(r)["did"]
+	if !didPattern.MatchString(did) {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid DID"))
+		return
+	}
+	query := r.URL.Query()
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = index.IntervalWeek
+	}
+	periods, ok := defaultPlayerStatsPeriods[interval]
+	if !ok {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("interval must be day or week"))
+		return
+	}
+	if v := query.Get("periods"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPlayerStatsPeriods {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("periods must be between 1 and "+strconv.Itoa(maxPlayerStatsPeriods)))
+			return
+		}
+		periods = n
+	}
+
+	stats, err := s.gameIndex.PlayerStats(r.Context(), did, time.Now(), interval, periods)
+	if err != nil {
+		log.Error().Err(err).Str("did", did).Msg("Failed to compute player statistics")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to compute statistics"))
+		return
+	}
+	resp := PlayerStatsResponse{PlayerStats: stats}
+	if s.ratings != nil {
+		resp.RatingHistory = ratingHistory(s.ratings.History(did), stats)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// ratingHistory lines a player's rating history up with the periods of their
+// activity series, carrying their rating through periods without games
+func ratingHistory(history []rating.RatingPoint, stats *index.PlayerStats) []RatingPeriod {
+	periods := make([]RatingPeriod, len(stats.Activity))
+	var current *rating.Glicko
+	next := 0
+	for p, activity := range stats.Activity {
+		start, _ := time.Parse("2006-01-02", activity.Start)
+		end := start.AddDate(0, 0, index.PeriodLength(stats.Interval))
+		periods[p].Start = activity.Start
+		for ; next < len(history) && history[next].PlayedAt.Before(end); next++ {
+			glicko := history[next].Glicko
+			current = &glicko
+			if !history[next].PlayedAt.Before(start) {
+				periods[p].Games++
+			}
+		}
+		periods[p].Glicko = current
+	}
+	return periods
+}
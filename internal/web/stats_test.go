@@ -0,0 +1,134 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/rating"
+)
+
+func TestInstanceStatsAreCachedAndAnonymous(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, testWhiteDID))
+
+	w := httptest.NewRecorder()
+	service.InstanceStatsHandler(w, httptest.NewRequest("GET", "/api/stats/instance", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without an index, got %d", w.Code)
+	}
+
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	service.SetGameIndex(indexer)
+	_ = indexer.Apply(context.Background(), "create", testWhiteDID, "app.atchess.game/g1", map[string]interface{}{
+		"white":     testWhiteDID,
+		"black":     testBlackDID,
+		"status":    "active",
+		"fen":       startFEN,
+		"createdAt": time.Now().Format(time.RFC3339),
+	})
+
+	w = httptest.NewRecorder()
+	service.InstanceStatsHandler(w, httptest.NewRequest("GET", "/api/stats/instance", nil))
+	var stats index.Stats
+	_ = json.Unmarshal(w.Body.Bytes(), &stats)
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("Expected fresh statistics, got %d %v", w.Code, w.Header())
+	}
+	if stats.TotalGames != 1 || stats.ActivePlayers != 2 || len(stats.GamesPerDay) != instanceStatsDays {
+		t.Errorf("Unexpected statistics: %s", w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "did:") {
+		t.Errorf("Expected no DIDs in public statistics: %s", w.Body.String())
+	}
+
+	// Later games aren't counted until the cached statistics expire
+	_ = indexer.Apply(context.Background(), "create", testWhiteDID, "app.atchess.game/g2", map[string]interface{}{
+		"white":     testWhiteDID,
+		"black":     testBlackDID,
+		"status":    "active",
+		"fen":       startFEN,
+		"createdAt": time.Now().Format(time.RFC3339),
+	})
+	w = httptest.NewRecorder()
+	service.InstanceStatsHandler(w, httptest.NewRequest("GET", "/api/stats/instance", nil))
+	_ = json.Unmarshal(w.Body.Bytes(), &stats)
+	if w.Header().Get("X-Cache") != "HIT" || stats.TotalGames != 1 {
+		t.Errorf("Expected cached statistics, got %v %s", w.Header(), w.Body.String())
+	}
+}
+
+func TestPlayerStatsIncludeRatingHistory(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, testWhiteDID))
+	stats := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/players/"+testWhiteDID+"/stats"+query, nil)
+		service.PlayerStatsHandler(w, mux.SetURLVars(r, map[string]string{"did": testWhiteDID}))
+		return w
+	}
+
+	if w := stats(""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without an index, got %d", w.Code)
+	}
+
+	ctx := context.Background()
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	service.SetGameIndex(indexer)
+	ratings := rating.NewRatings(nil)
+	service.SetRatings(ratings)
+	now := time.Now()
+	for rkey, game := range map[string]struct {
+		status  string
+		created time.Time
+	}{
+		"g1": {"white_won", now.AddDate(0, 0, -14)},
+		"g2": {"draw", now},
+	} {
+		_ = indexer.Apply(ctx, "create", testWhiteDID, "app.atchess.game/"+rkey, map[string]interface{}{
+			"white":     testWhiteDID,
+			"black":     testBlackDID,
+			"status":    game.status,
+			"fen":       startFEN,
+			"createdAt": game.created.Format(time.RFC3339),
+		})
+	}
+	page, _ := indexer.ListGames(ctx, index.Query{})
+	for i := len(page.Games) - 1; i >= 0; i-- {
+		ratings.RecordGame(ctx, page.Games[i])
+	}
+
+	for _, query := range []string{"?interval=month", "?periods=0", "?periods=1000"} {
+		if w := stats(query); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, w.Code)
+		}
+	}
+
+	w := stats("?periods=4")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected statistics, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp PlayerStatsResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Interval != index.IntervalWeek || resp.Games != 2 || resp.Wins != 1 || resp.Draws != 1 || resp.White.Games != 2 {
+		t.Errorf("Unexpected statistics: %s", w.Body.String())
+	}
+	history := resp.RatingHistory
+	if len(history) != 4 || history[0].Start != resp.Activity[0].Start {
+		t.Fatalf("Expected rating history over the 4 weeks of activity, got %s", w.Body.String())
+	}
+	// No rating before the first game, then carried through the week without games
+	if history[0].Glicko != nil || history[1].Games != 1 || history[1].Glicko == nil || history[1].Rating <= rating.DefaultRating {
+		t.Errorf("Unexpected rating history: %s", w.Body.String())
+	}
+	if history[2].Games != 0 || history[2].Glicko == nil || history[2].Rating != history[1].Rating || history[3].Games != 1 {
+		t.Errorf("Unexpected rating history: %s", w.Body.String())
+	}
+	if current := ratings.Get(testWhiteDID); history[3].Glicko == nil || history[3].Rating != current.Rating {
+		t.Errorf("Expected the latest week to end on the current rating, got %s", w.Body.String())
+	}
+}
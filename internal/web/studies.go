@@ -0,0 +1,312 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/study"
+	"github.com/rs/zerolog/log"
+)
+
+// SetStudies enables shared analysis boards, passing every edit on to the
+// study's channel so everyone analysing it sees it
+func (s *Service) SetStudies(studies *study.Studies) {
+	s.studies = studies
+	studies.OnEdit(func(edited *study.Study, did string, edit *study.Edit) {
+		if s.hub == nil {
+			return
+		}
+		s.hub.BroadcastGameUpdate(GameUpdate{
+			GameID: edited.URI,
+			Type:   "study_edit",
+			Data: map[string]interface{}{
+				"version":   edited.Version,
+				"by":        did,
+				"edit":      edit,
+				"updatedAt": edited.UpdatedAt,
+			},
+		})
+	})
+}
+
+// LoadStudy implements study.Store by reading the owner's record
+func (s *Service) LoadStudy(ctx context.Context, uri string) (*study.Study, error) {
+	return s.client.GetStudy(ctx, uri)
+}
+
+// SaveStudy implements study.Store by writing to the owner's repository
+// through one of their sessions
+func (s *Service) SaveStudy(ctx context.Context, edited *study.Study) error {
+	sessions := s.sessions.ListForDID(edited.Owner)
+	if len(sessions) == 0 {
+		return study.ErrNotConnected
+	}
+	return sessions[0].Client.SaveStudy(ctx, edited)
+}
+
+// CreateStudyRequest starts a study
+type CreateStudyRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Members     []string `json:"members"`
+}
+
+// UpdateStudyRequest changes a study's details; fields left out are kept
+type UpdateStudyRequest struct {
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	Members     []string `json:"members"`
+}
+
+// StudyEditResponse is the result of an edit to a study
+type StudyEditResponse struct {
+	Version int         `json:"version"`
+	Edit    *study.Edit `json:"edit"`
+}
+
+// CreateStudyHandler creates a study in the caller's repository with a
+// single chapter from the starting position
+func (s *Service) CreateStudyHandler(w http.ResponseWriter, r *http.Request) {
+	if s.studies == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Studies are not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	var req CreateStudyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+
+	created, err := study.New(did, req.Name, req.Description)
+	if err == nil && len(req.Members) > 0 {
+		err = created.Apply(did, &study.Edit{Op: study.OpSetMembers, Members: req.Members})
+		created.Version = 0
+	}
+	if err != nil {
+		writeStudyError(w, err)
+		return
+	}
+	created.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	created.UpdatedAt = created.CreatedAt
+
+	if err := s.clientFor(r).CreateStudy(r.Context(), created); err != nil {
+		log.Error().Err(err).Str("did", did).Msg("Failed to create study")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to create study"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(created)
+}
+
+// GetStudyHandler returns a study, including edits not yet saved to its
+// owner's repository
+func (s *Service) GetStudyHandler(w http.ResponseWriter, r *http.Request) {
+	if s.studies == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Studies are not enabled"))
+		return
+	}
+	uri, ok := s.studyURI(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := s.studies.Get(r.Context(), uri)
+	if err != nil {
+		writeStudyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(found)
+}
+
+// UpdateStudyHandler lets a study's owner rename it, describe it, and choose
+// who may edit it
+func (s *Service) UpdateStudyHandler(w http.ResponseWriter, r *http.Request) {
+	if s.studies == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Studies are not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+	uri, ok := s.studyURI(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateStudyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+
+	current, err := s.studies.Get(r.Context(), uri)
+	if err != nil {
+		writeStudyError(w, err)
+		return
+	}
+	var edits []*study.Edit
+	if req.Name != nil || req.Description != nil {
+		info := &study.Edit{Op: study.OpSetInfo, Name: current.Name, Description: current.Description}
+		if req.Name != nil {
+			info.Name = *req.Name
+		}
+		if req.Description != nil {
+			info.Description = *req.Description
+		}
+		edits = append(edits, info)
+	}
+	if req.Members != nil {
+		edits = append(edits, &study.Edit{Op: study.OpSetMembers, Members: req.Members})
+	}
+
+	for _, edit := range edits {
+		if current, err = s.studies.Edit(r.Context(), uri, did, edit); err != nil {
+			writeStudyError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(current)
+}
+
+// DeleteStudyHandler deletes a study from its owner's repository
+func (s *Service) DeleteStudyHandler(w http.ResponseWriter, r *http.Request) {
+	if s.studies == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Studies are not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+	uri, ok := s.studyURI(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := s.studies.Get(r.Context(), uri)
+	if err != nil {
+		writeStudyError(w, err)
+		return
+	}
+	if found.Owner != did {
+		apierror.Write(w, apierror.ErrForbidden.WithMessage("Only the study's owner can delete it"))
+		return
+	}
+
+	if err := s.clientFor(r).DeleteStudy(r.Context(), uri); err != nil {
+		log.Error().Err(err).Str("study", uri).Msg("Failed to delete study")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to delete study"))
+		return
+	}
+	s.studies.Forget(uri)
+	if s.hub != nil {
+		s.hub.BroadcastGameUpdate(GameUpdate{GameID: uri, Type: "study_deleted"})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EditStudyHandler applies an edit from the owner or a member: adding or
+// deleting chapters and moves, or commenting on a move. The edit is sent to
+// everyone on the study's channel, with the IDs of anything it created.
+func (s *Service) EditStudyHandler(w http.ResponseWriter, r *http.Request) {
+	if s.studies == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Studies are not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+	uri, ok := s.studyURI(w, r)
+	if !ok {
+		return
+	}
+
+	var edit study.Edit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+	// Results are filled in by the server
+	edit.Created, edit.Result = "", nil
+
+	edited, err := s.studies.Edit(r.Context(), uri, did, &edit)
+	if err != nil {
+		writeStudyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(StudyEditResponse{Version: edited.Version, Edit: &edit})
+}
+
+// ListPlayerStudiesHandler lists the studies in a player's repository
+func (s *Service) ListPlayerStudiesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.studies == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Studies are not enabled"))
+		return
+	}
+	did, ok := s.resolvePlayer(w, r, mux.Vars(r)["didOrHandle"])
+	if !ok {
+		return
+	}
+
+	studies, err := s.readerFor(r).ListStudies(r.Context(), did)
+	if err != nil {
+		log.Error().Err(err).Str("did", did).Msg("Failed to list studies")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list studies"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"studies": studies})
+}
+
+// studyURI decodes the study URI from the URL, writing the error response
+// if it can't
+func (s *Service) studyURI(w http.ResponseWriter, r *http.Request) (string, bool) {
+	uri, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil || !strings.HasPrefix(uri, "at://") || !strings.Contains(uri, "/app.atchess.study/") {
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid study ID"))
+		return "", false
+	}
+	return uri, true
+}
+
+// writeStudyError writes the response for an error from the study package
+func writeStudyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, study.ErrNotFound):
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Study not found"))
+	case errors.Is(err, study.ErrForbidden):
+		apierror.Write(w, apierror.ErrForbidden.WithMessage("You can't make that change to this study"))
+	case errors.Is(err, study.ErrInvalidEdit):
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+	default:
+		log.Error().Err(err).Msg("Study request failed")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to load study"))
+	}
+}
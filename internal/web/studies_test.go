@@ -0,0 +1,173 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/study"
+)
+
+// newStudyService sets up a service with studies over a hub, and sessions
+// for white, black and an outsider
+func newStudyService(t *testing.T) (*Service, *Hub, *fakePDS, map[string]string) {
+	t.Helper()
+	pds := newFakePDS(t, "did:plc:service")
+	service := newServiceForPDS(t, pds)
+	hub := NewHub()
+	go hub.Run()
+	service.SetHub(hub)
+	service.SetStudies(study.NewStudies(service))
+
+	tokens := map[string]string{}
+	for _, did := range []string{testWhiteDID, testBlackDID, "did:plc:outsider"} {
+		pds.did = did
+		client, err := atproto.NewClient(pds.URL, "player", "password")
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		tokens[did], _ = service.Sessions().Create(client)
+	}
+	return service, hub, pds, tokens
+}
+
+func studyRequest(handler http.HandlerFunc, s *Service, method, uri, token string, body interface{}) *httptest.ResponseRecorder {
+	reqBody, _ := json.Marshal(body)
+	encoded := base64.URLEncoding.EncodeToString([]byte(uri))
+	req := mux.SetURLVars(httptest.NewRequest(method, "/api/studies/"+encoded, bytes.NewReader(reqBody)), map[string]string{"id": encoded})
+	if token != "" {
+		req.Header.Set(SessionHeader, token)
+	}
+	w := httptest.NewRecorder()
+	s.SessionMiddleware(handler).ServeHTTP(w, req)
+	return w
+}
+
+func TestStudyEditsAreSharedAndSaved(t *testing.T) {
+	service, hub, pds, tokens := newStudyService(t)
+
+	w := studyRequest(service.CreateStudyHandler, service, "POST", "", tokens[testWhiteDID],
+		map[string]interface{}{"name": "Sicilian prep", "members": []string{testBlackDID}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected the study to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	var created study.Study
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Owner != testWhiteDID || !strings.HasPrefix(created.URI, "at://"+testWhiteDID+"/app.atchess.study/") || len(created.Chapters) != 1 {
+		t.Fatalf("Expected a study in white's repository, got %+v", created)
+	}
+	chapter := created.Chapters[0].ID
+
+	server := httptest.NewServer(service.WebSocketHandler(hub))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/?channel=study&gameId="+url.QueryEscape(created.URI), nil)
+	if err != nil {
+		t.Fatalf("Failed to watch the study: %v", err)
+	}
+	defer conn.Close()
+	time.Sleep(50 * time.Millisecond) // let the hub register the watcher
+
+	// A member's move is applied, sent to the channel and saved by the owner
+	w = studyRequest(service.EditStudyHandler, service, "POST", created.URI, tokens[testBlackDID],
+		map[string]interface{}{"op": "addMove", "chapter": chapter, "move": "e4"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the member's edit to be applied, got %d: %s", w.Code, w.Body.String())
+	}
+	var edited StudyEditResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &edited)
+	if edited.Version != 1 || edited.Edit.Result == nil || edited.Edit.Result.UCI != "e2e4" {
+		t.Fatalf("Expected the move as played, got %s", w.Body.String())
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Expected a study_edit frame: %v", err)
+	}
+	var frame struct {
+		Type string `json:"type"`
+		Data struct {
+			Version int        `json:"version"`
+			By      string     `json:"by"`
+			Edit    study.Edit `json:"edit"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(message, &frame)
+	if frame.Type != "study_edit" || frame.Data.By != testBlackDID || frame.Data.Edit.Created != edited.Edit.Created {
+		t.Errorf("Expected black's edit on the channel, got %s", message)
+	}
+
+	saved := pds.get(created.URI)
+	chapters, _ := saved["chapters"].([]interface{})
+	if len(chapters) != 1 || len(chapters[0].(map[string]interface{})["nodes"].([]interface{})) != 1 || saved["version"] != float64(1) {
+		t.Errorf("Expected the move saved to white's repository, got %v", saved)
+	}
+
+	// Others can read the study but not edit it
+	w = studyRequest(service.EditStudyHandler, service, "POST", created.URI, tokens["did:plc:outsider"],
+		map[string]interface{}{"op": "addMove", "chapter": chapter, "move": "d4"})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected an outsider's edit to be refused, got %d", w.Code)
+	}
+	w = studyRequest(service.GetStudyHandler, service, "GET", created.URI, "", nil)
+	var fetched study.Study
+	_ = json.Unmarshal(w.Body.Bytes(), &fetched)
+	if w.Code != http.StatusOK || len(fetched.Chapters[0].Nodes) != 1 {
+		t.Errorf("Expected the study with black's move, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Illegal moves are rejected
+	w = studyRequest(service.EditStudyHandler, service, "POST", created.URI, tokens[testBlackDID],
+		map[string]interface{}{"op": "addMove", "chapter": chapter, "node": edited.Edit.Created, "move": "e4"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an illegal move to be rejected, got %d", w.Code)
+	}
+}
+
+func TestUpdateAndDeleteStudyAreForTheOwner(t *testing.T) {
+	service, _, pds, tokens := newStudyService(t)
+
+	w := studyRequest(service.CreateStudyHandler, service, "POST", "", tokens[testWhiteDID],
+		map[string]interface{}{"name": "Endgames", "members": []string{testBlackDID}})
+	var created study.Study
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+
+	w = studyRequest(service.UpdateStudyHandler, service, "PATCH", created.URI, tokens[testBlackDID], map[string]interface{}{"name": "Mine now"})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected a member's rename to be refused, got %d", w.Code)
+	}
+	w = studyRequest(service.UpdateStudyHandler, service, "PATCH", created.URI, tokens[testWhiteDID], map[string]interface{}{"description": "Rook endings", "members": []string{}})
+	var updated study.Study
+	_ = json.Unmarshal(w.Body.Bytes(), &updated)
+	if w.Code != http.StatusOK || updated.Name != "Endgames" || updated.Description != "Rook endings" || len(updated.Members) != 0 {
+		t.Errorf("Expected the description changed and members removed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = studyRequest(service.DeleteStudyHandler, service, "DELETE", created.URI, tokens[testBlackDID], nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected a former member's delete to be refused, got %d", w.Code)
+	}
+	w = studyRequest(service.DeleteStudyHandler, service, "DELETE", created.URI, tokens[testWhiteDID], nil)
+	if w.Code != http.StatusNoContent || pds.get(created.URI) != nil {
+		t.Fatalf("Expected the study deleted, got %d", w.Code)
+	}
+	w = studyRequest(service.GetStudyHandler, service, "GET", created.URI, "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected a deleted study to be gone, got %d", w.Code)
+	}
+}
+
+func TestStudyUpdatesAreRoutedToTheStudyChannel(t *testing.T) {
+	if room := updateRoom(GameUpdate{GameID: "at://did:plc:white/app.atchess.study/s1", Type: "study_edit"}); room != roomKey("at://did:plc:white/app.atchess.study/s1", StudyChannel) {
+		t.Errorf("Expected study edits in the study room, got %s", room)
+	}
+}
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/rs/zerolog/log"
+)
+
+// MoveTiming is how long a move took, and where it left the mover's clock
+type MoveTiming struct {
+	Ply       int    `json:"ply"`
+	SAN       string `json:"san"`
+	Player    string `json:"player"`
+	Color     string `json:"color"` // "white" or "black"
+	CreatedAt string `json:"createdAt"`
+	ThinkMs   int64  `json:"thinkMs"`           // as recorded, or else since the previous move
+	TotalMs   int64  `json:"totalMs"`           // the mover's time used so far, this move included
+	ClockMs   *int64 `json:"clockMs,omitempty"` // mover's time left after the move, in games with a clock
+}
+
+// PlayerTiming is one player's time usage over a game
+type PlayerTiming struct {
+	Moves      int    `json:"moves"`
+	TotalMs    int64  `json:"totalMs"`
+	AverageMs  int64  `json:"averageMs"`
+	LongestMs  int64  `json:"longestMs"`
+	LongestPly int    `json:"longestPly,omitempty"`
+	ClockMs    *int64 `json:"clockMs,omitempty"` // time left now, in games with a clock
+}
+
+// GameTiming is both players' time usage, move by move
+type GameTiming struct {
+	GameID  string       `json:"gameId"`
+	Moves   []MoveTiming `json:"moves"`
+	White   PlayerTiming `json:"white"`
+	Black   PlayerTiming `json:"black"`
+	Running string       `json:"running,omitempty"` // color whose clock is running, while a game with a clock is active
+}
+
+// gameTiming works out the time each move took, preferring the think time
+// recorded with it over the gap between timestamps, and in games with a
+// clock the time each player had left. Clocks are left out once a move's
+// time can't be told.
+func gameTiming(game *chess.Game, moves []*chess.Move, now time.Time) *GameTiming {
+	timing := &GameTiming{GameID: game.ID, Moves: make([]MoveTiming, 0, len(moves))}
+
+	// Each player starts with the initial time, or a correspondence game's
+	// time per move
+	var full, increment time.Duration
+	correspondence := false
+	if tc := game.TimeControl; tc != nil {
+		full, increment = time.Duration(tc.Initial)*time.Second, time.Duration(tc.Increment)*time.Second
+		if tc.Initial <= 0 && tc.DaysPerMove > 0 {
+			full, correspondence = time.Duration(tc.DaysPerMove)*24*time.Hour, true
+		}
+	}
+	clocks := map[string]time.Duration{"white": full, "black": full}
+	players := map[string]*PlayerTiming{"white": &timing.White, "black": &timing.Black}
+
+	previous, err := time.Parse(time.RFC3339, game.CreatedAt)
+	stamped := err == nil // previous is when the last move was made
+	known := true         // every move's time so far is known
+	for _, move := range moves {
+		color := "white"
+		if move.Player == game.Black {
+			color = "black"
+		}
+		entry := MoveTiming{Ply: move.Ply, SAN: move.SAN, Player: move.Player, Color: color, CreatedAt: move.CreatedAt}
+
+		at, err := time.Parse(time.RFC3339, move.CreatedAt)
+		var think time.Duration
+		switch {
+		case move.ThinkTimeMs > 0:
+			think = time.Duration(move.ThinkTimeMs) * time.Millisecond
+		case stamped && err == nil:
+			think = max(at.Sub(previous), 0)
+		default:
+			known = false
+		}
+		previous, stamped = at, err == nil
+
+		player := players[color]
+		player.Moves++
+		player.TotalMs += think.Milliseconds()
+		if think.Milliseconds() > player.LongestMs {
+			player.LongestMs, player.LongestPly = think.Milliseconds(), move.Ply
+		}
+		entry.ThinkMs, entry.TotalMs = think.Milliseconds(), player.TotalMs
+
+		if full > 0 && known {
+			if correspondence {
+				// Correspondence clocks start again with every move
+				clocks[color] = full
+			} else {
+				clocks[color] = max(clocks[color]-think, 0) + increment
+			}
+			left := clocks[color].Milliseconds()
+			entry.ClockMs = &left
+		}
+		timing.Moves = append(timing.Moves, entry)
+	}
+	for _, player := range players {
+		if player.Moves > 0 {
+			player.AverageMs = player.TotalMs / int64(player.Moves)
+		}
+	}
+
+	if full <= 0 || !known {
+		return timing
+	}
+	if game.Status == chess.StatusActive {
+		if !stamped {
+			return timing
+		}
+		timing.Running = "white"
+		if strings.Contains(game.FEN, " b ") {
+			timing.Running = "black"
+		}
+		clocks[timing.Running] = max(clocks[timing.Running]-now.Sub(previous), 0)
+	}
+	white, black := clocks["white"].Milliseconds(), clocks["black"].Milliseconds()
+	timing.White.ClockMs, timing.Black.ClockMs = &white, &black
+	return timing
+}
+
+// GetGameTimingHandler returns the time each move of a game took and, in
+// games with a clock, the time each player had left after it, for clock
+// displays and post-game time graphs
+func (s *Service) GetGameTimingHandler(w http.ResponseWriter, r *http.Request) {
+	gameID, err := s.decodeGameID(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInvalidGameID)
+		return
+	}
+
+	client := s.readerFor(r)
+	game, err := client.GetGame(context.Background(), gameID)
+	if err != nil {
+		apierror.Write(w, apierror.ErrGameNotFound)
+		return
+	}
+	moves, err := client.GetMoves(context.Background(), gameID)
+	if err != nil {
+		log.Error().Err(err).Str("gameID", gameID).Msg("Failed to load moves for timing")
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to load moves"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(gameTiming(game, moves, time.Now()))
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/chess"
+)
+
+func TestGameTimingPrefersRecordedThinkTime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) string { return start.Add(time.Duration(seconds) * time.Second).Format(time.RFC3339) }
+	game := &chess.Game{
+		ID:          "at://did:plc:white/app.atchess.game/g1",
+		White:       testWhiteDID,
+		Black:       testBlackDID,
+		Status:      chess.StatusWhiteWon,
+		CreatedAt:   at(0),
+		TimeControl: &chess.TimeControl{Type: "rapid", Initial: 600},
+	}
+	moves := []*chess.Move{
+		{Ply: 1, Player: testWhiteDID, SAN: "e4", CreatedAt: at(10), ThinkTimeMs: 8500},
+		{Ply: 2, Player: testBlackDID, SAN: "e5", CreatedAt: at(40)},
+		{Ply: 3, Player: testWhiteDID, SAN: "Nf3", CreatedAt: at(45), ThinkTimeMs: 4250},
+		{Ply: 4, Player: testBlackDID, SAN: "Nc6", CreatedAt: "not a time", ThinkTimeMs: 60000},
+	}
+
+	timing := gameTiming(game, moves, start.Add(time.Hour))
+	if timing.Moves[0].ThinkMs != 8500 || timing.Moves[1].ThinkMs != 30000 || timing.Moves[3].ThinkMs != 60000 {
+		t.Fatalf("Expected recorded think times, and timestamps otherwise, got %+v", timing.Moves)
+	}
+	if timing.Moves[2].TotalMs != 12750 || *timing.Moves[2].ClockMs != 587250 {
+		t.Errorf("Expected white's time used and left to add up, got %+v", timing.Moves[2])
+	}
+	if timing.White.Moves != 2 || timing.White.AverageMs != 6375 || timing.Black.LongestMs != 60000 || timing.Black.LongestPly != 4 {
+		t.Errorf("Unexpected totals: white %+v black %+v", timing.White, timing.Black)
+	}
+	if timing.Running != "" || *timing.Black.ClockMs != 510000 {
+		t.Errorf("Expected the clocks stopped where the game ended, got %q %d", timing.Running, *timing.Black.ClockMs)
+	}
+
+	// A move whose time can't be told leaves the clocks out
+	moves[1].CreatedAt = "not a time"
+	moves[2].ThinkTimeMs = 0
+	if timing := gameTiming(game, moves, start); timing.White.ClockMs != nil || timing.Moves[2].ClockMs != nil {
+		t.Errorf("Expected no clocks once a move's time is unknown, got %+v", timing)
+	}
+}
+
+func TestMakeMoveRecordsThinkTime(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	gameID := seedGame(pds, startFEN, "active")
+	service := newServiceForPDS(t, pds)
+
+	w := postMove(service, map[string]interface{}{"from": "e2", "to": "e4", "game_id": gameID, "thinkTimeMs": 1500})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected move to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var result chess.MoveResult
+	_ = json.Unmarshal(w.Body.Bytes(), &result)
+	if result.ThinkTimeMs != 1500 {
+		t.Errorf("Expected the client's think time, got %d", result.ThinkTimeMs)
+	}
+
+	encoded := base64.URLEncoding.EncodeToString([]byte(gameID))
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/games/"+encoded+"/timing", nil), map[string]string{"id": encoded})
+	w = httptest.NewRecorder()
+	service.GetGameTimingHandler(w, req)
+	var timing GameTiming
+	if err := json.Unmarshal(w.Body.Bytes(), &timing); err != nil {
+		t.Fatalf("Failed to parse timing: %v", err)
+	}
+	if w.Code != http.StatusOK || len(timing.Moves) != 1 || timing.Moves[0].ThinkMs != 1500 || timing.White.TotalMs != 1500 {
+		t.Fatalf("Expected white's recorded think time, got %d %s", w.Code, w.Body.String())
+	}
+
+	// A client claiming more time than the server saw is overruled
+	pds = newFakePDS(t, testWhiteDID)
+	gameID = seedGame(pds, startFEN, "active")
+	service = newServiceForPDS(t, pds)
+	w = postMove(service, map[string]interface{}{"from": "e2", "to": "e4", "game_id": gameID, "thinkTimeMs": 1e15})
+	_ = json.Unmarshal(w.Body.Bytes(), &result)
+	if since := time.Since(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); result.ThinkTimeMs > since.Milliseconds() || result.ThinkTimeMs < since.Milliseconds()-60000 {
+		t.Errorf("Expected the time since the game started, got %dms", result.ThinkTimeMs)
+	}
+}
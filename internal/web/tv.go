@@ -0,0 +1,201 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/rating"
+	"github.com/rs/zerolog/log"
+)
+
+// TVIdleAfter is how long a live game can go without a move and still be
+// featured, which keeps correspondence games off TV
+const TVIdleAfter = 10 * time.Minute
+
+// Weights of the signals that make a game interesting to watch, in rating
+// points: a spectator is worth 50 points of combined rating, and a minute
+// without a move costs 20
+const (
+	tvSpectatorWeight = 50
+	tvIdlePenalty     = 20
+)
+
+// FeaturedGame is the game on TV
+type FeaturedGame struct {
+	GameIndex
+	Score float64   `json:"score"`
+	Since time.Time `json:"since"` // when it became the featured game
+}
+
+// TV picks the most interesting live game from the index - highly rated
+// players, lots of spectators, recent moves - and shows it on the TV
+// channel. Like a broadcaster, it stays with a game until it ends or stalls
+// rather than cutting away whenever another game scores higher.
+type TV struct {
+	hub     *Hub
+	games   *index.Indexer
+	ratings *rating.Ratings
+	now     func() time.Time
+
+	// refresh serializes picks
+	refresh  sync.Mutex
+	mu       sync.RWMutex
+	featured *FeaturedGame
+}
+
+// NewTV creates a TV choosing from the active games in games. ratings may be nil.
+func NewTV(hub *Hub, games *index.Indexer, ratings *rating.Ratings) *TV {
+	return &TV{hub: hub, games: games, ratings: ratings, now: time.Now}
+}
+
+// Featured returns the game on TV, or nil when there's no live game
+func (t *TV) Featured() *FeaturedGame {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.featured == nil {
+		return nil
+	}
+	featured := *t.featured
+	featured.SpectatorCount = t.hub.SubscriberCount(featured.GameID, GameChannel)
+	return &featured
+}
+
+// Run picks a game every interval until ctx is done
+func (t *TV) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := t.Refresh(ctx); err != nil {
+			log.Warn().Err(err).Msg("Failed to pick the featured game")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GameFinished switches to another game straight away when the featured
+// one ends; register it with index.Indexer.OnGameFinished
+func (t *TV) GameFinished(ctx context.Context, game *index.Game) {
+	t.mu.RLock()
+	featured := t.featured != nil && t.featured.GameID == game.URI
+	t.mu.RUnlock()
+	if !featured {
+		return
+	}
+	// Don't hold up indexing
+	go func() {
+		if err := t.Refresh(context.Background()); err != nil {
+			log.Warn().Err(err).Msg("Failed to pick the next featured game")
+		}
+	}()
+}
+
+// Refresh keeps the featured game while it's live, and otherwise switches to
+// the highest scoring live game
+func (t *TV) Refresh(ctx context.Context) error {
+	t.refresh.Lock()
+	defer t.refresh.Unlock()
+
+	// Listings are most recently active first, so the first page holds the
+	// games that haven't stalled
+	page, err := t.games.ListGames(ctx, index.Query{Status: string(chess.StatusActive), Limit: index.MaxLimit})
+	if err != nil {
+		return err
+	}
+
+	now := t.now()
+	t.mu.RLock()
+	current := t.featured
+	t.mu.RUnlock()
+
+	var best *index.Game
+	bestScore := 0.0
+	for _, game := range page.Games {
+		if now.Sub(lastActivity(game)) > TVIdleAfter {
+			continue
+		}
+		if current != nil && game.URI == current.GameID {
+			// Still live: stay with it
+			t.feature(game, t.score(game, now), current.Since)
+			return nil
+		}
+		if score := t.score(game, now); best == nil || score > bestScore {
+			best, bestScore = game, score
+		}
+	}
+
+	if best == nil {
+		if current != nil {
+			t.mu.Lock()
+			t.featured = nil
+			t.mu.Unlock()
+			t.hub.SetFeatured("", nil)
+		}
+		return nil
+	}
+	featured := t.feature(best, bestScore, now)
+	log.Info().Str("gameID", best.URI).Float64("score", bestScore).Msg("Featuring game on TV")
+	t.hub.SetFeatured(best.URI, featured)
+	return nil
+}
+
+// feature makes game the featured game, returning it as shown to viewers
+func (t *TV) feature(game *index.Game, score float64, since time.Time) *FeaturedGame {
+	featured := &FeaturedGame{GameIndex: indexedGame(game), Score: score, Since: since}
+	t.mu.Lock()
+	t.featured = featured
+	t.mu.Unlock()
+	return featured
+}
+
+// score rates how interesting a game is to watch
+func (t *TV) score(game *index.Game, now time.Time) float64 {
+	score := 2 * rating.DefaultRating
+	if t.ratings != nil {
+		score = t.ratings.Get(game.White).Rating + t.ratings.Get(game.Black).Rating
+	}
+	score += tvSpectatorWeight * float64(t.hub.SubscriberCount(game.URI, GameChannel))
+	score -= tvIdlePenalty * now.Sub(lastActivity(game)).Minutes()
+	return score
+}
+
+// lastActivity is when a game last had a move, or started if it hasn't
+func lastActivity(game *index.Game) time.Time {
+	if game.LastMoveAt != nil {
+		return *game.LastMoveAt
+	}
+	return game.CreatedAt
+}
+
+// SetTV enables the featured game
+func (s *Service) SetTV(tv *TV) {
+	s.tv = tv
+}
+
+// TVHandler returns the featured game. Watch it on the WebSocket TV channel
+// (channel=tv), which switches to the next featured game when it ends.
+func (s *Service) TVHandler(w http.ResponseWriter, r *http.Request) {
+	if s.tv == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("TV needs the game index, which is built from the firehose"))
+		return
+	}
+	featured := s.tv.Featured()
+	if featured == nil {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("No live game to feature"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"featured": featured,
+	})
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/index"
+)
+
+func TestTVFeaturesWatchedGameAndSwitchesWhenItEnds(t *testing.T) {
+	ctx := context.Background()
+	indexer := index.NewIndexer(index.NewMemoryStore())
+	createGame := func(rkey, status string, createdAt time.Time) string {
+		_ = indexer.Apply(ctx, "update", testWhiteDID, "app.atchess.game/"+rkey, map[string]interface{}{
+			"white":     testWhiteDID,
+			"black":     testBlackDID,
+			"status":    status,
+			"fen":       startFEN,
+			"createdAt": createdAt.UTC().Format(time.RFC3339),
+		})
+		return "at://" + testWhiteDID + "/app.atchess.game/" + rkey
+	}
+	quiet := createGame("quiet", "active", time.Now())
+	busy := createGame("busy", "active", time.Now())
+	createGame("stale", "active", time.Now().Add(-time.Hour))
+
+	hub := NewHub()
+	go hub.Run()
+	hub.register <- &Client{hub: hub, send: make(chan []byte, 8), gameID: busy, userID: "anonymous", channel: GameChannel}
+	viewer := &Client{hub: hub, send: make(chan []byte, 8), userID: "anonymous", channel: TVChannel}
+	hub.register <- viewer
+
+	service := newServiceForPDS(t, newFakePDS(t, "did:plc:service"))
+	w := httptest.NewRecorder()
+	service.TVHandler(w, httptest.NewRequest("GET", "/api/spectator/tv", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected TV to be disabled without the index, got %d", w.Code)
+	}
+
+	tv := NewTV(hub, indexer, nil)
+	indexer.OnGameFinished(tv.GameFinished)
+	service.SetTV(tv)
+	if err := tv.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	expectFrame(t, viewer, `"gameId":"`+busy+`","type":"tv_featured"`)
+
+	w = httptest.NewRecorder()
+	service.TVHandler(w, httptest.NewRequest("GET", "/api/spectator/tv", nil))
+	var resp struct {
+		Featured FeaturedGame `json:"featured"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Featured.GameID != busy || resp.Featured.SpectatorCount != 1 {
+		t.Errorf("Expected the game with a spectator featured, got %s", w.Body.String())
+	}
+
+	// The featured game's updates reach TV viewers
+	hub.BroadcastToGame(busy, GameUpdate{Type: "move", Data: map[string]string{"san": "e4"}})
+	expectFrame(t, viewer, `"type":"move"`)
+	hub.BroadcastToGame(quiet, GameUpdate{Type: "move", Data: map[string]string{"san": "d4"}})
+
+	// Another refresh stays with the featured game
+	if err := tv.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	select {
+	case frame := <-viewer.send:
+		t.Errorf("Expected no frames from other games or switches, got %s", frame)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// When it ends, TV switches to the other live game, not the stalled one
+	createGame("busy", "white_won", time.Now())
+	expectFrame(t, viewer, `"gameId":"`+quiet+`","type":"tv_featured"`)
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/chess"
+	notnil "github.com/notnil/chess"
+)
+
+// capturesVariant is standard chess with compulsory captures
+type capturesVariant struct{ chess.Standard }
+
+func (capturesVariant) Name() string { return "test-captures" }
+
+func (capturesVariant) Moves(position *notnil.Position, standard []*notnil.Move) []*notnil.Move {
+	var captures []*notnil.Move
+	for _, move := range standard {
+		if move.HasTag(notnil.Capture) {
+			captures = append(captures, move)
+		}
+	}
+	if len(captures) > 0 {
+		return captures
+	}
+	return standard
+}
+
+func createGame(s *Service, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/games", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.CreateGameHandler(w, asPlayer(req, s.client))
+	return w
+}
+
+func TestCreateGameRecordsVariant(t *testing.T) {
+	chess.RegisterVariant(capturesVariant{})
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	w := createGame(service, `{"opponent_did":"`+testBlackDID+`","color":"white","variant":"test-captures"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the game to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	var game chess.Game
+	_ = json.Unmarshal(w.Body.Bytes(), &game)
+	if game.Variant != "test-captures" {
+		t.Errorf("Expected the variant in the response, got %+v", game)
+	}
+	if variant := pds.get(game.ID)["variant"]; variant != "test-captures" {
+		t.Errorf("Expected the variant in the game record, got %v", variant)
+	}
+
+	w = createGame(service, `{"opponent_did":"`+testBlackDID+`","color":"white"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the game to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &game)
+	if _, ok := pds.get(game.ID)["variant"]; ok {
+		t.Error("Expected standard games to leave the variant out of the record")
+	}
+}
+
+func TestCreateGameRejectsUnsupportedVariant(t *testing.T) {
+	service := newServiceForPDS(t, newFakePDS(t, testWhiteDID))
+
+	for _, variant := range []string{"atomic", "nonsense"} {
+		w := createGame(service, `{"opponent_did":"`+testBlackDID+`","variant":"`+variant+`"}`)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != "bad_request" {
+			t.Errorf("Expected bad_request for %s, got %d: %s", variant, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMakeMoveFollowsGameVariant(t *testing.T) {
+	chess.RegisterVariant(capturesVariant{})
+	pds := newFakePDS(t, testWhiteDID)
+	// After 1. e4 d5 white has to take on d5
+	fen := "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2"
+	gameID := seedGame(pds, fen, "active")
+	pds.get(gameID)["variant"] = "test-captures"
+	service := newServiceForPDS(t, pds)
+
+	w := postMove(service, map[string]interface{}{"move": "Nf3", "game_id": gameID})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a non-capture to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = legalMovesRequest(service, gameID)
+	var resp LegalMovesResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Moves) != 1 || resp.Moves[0].SAN != "exd5" {
+		t.Errorf("Expected only exd5 to be legal, got %+v", resp.Moves)
+	}
+
+	w = postMove(service, map[string]interface{}{"move": "exd5", "game_id": gameID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the capture to be played, got %d: %s", w.Code, w.Body.String())
+	}
+}
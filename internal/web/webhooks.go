@@ -0,0 +1,113 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/apierror"
+	"github.com/justinabrahms/atchess/internal/webhook"
+)
+
+// SetWebhooks enables webhook subscriptions
+func (s *Service) SetWebhooks(dispatcher *webhook.Dispatcher) {
+	s.webhooks = dispatcher
+}
+
+// CreateWebhookRequest registers a webhook
+type CreateWebhookRequest struct {
+	URL string `json:"url"`
+	// Secret keys the HMAC-SHA256 signature sent with each delivery
+	Secret string   `json:"secret"`
+	Events []string `json:"events"` // move, game_end and/or challenge
+}
+
+// WebhooksResponse lists the caller's webhooks
+type WebhooksResponse struct {
+	Webhooks []*webhook.Subscription `json:"webhooks"`
+}
+
+// CreateWebhookHandler registers a webhook for the caller's games
+func (s *Service) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Webhooks are not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, apierror.ErrInvalidBody)
+		return
+	}
+
+	sub, err := s.webhooks.Subscribe(r.Context(), did, req.URL, req.Secret, req.Events)
+	switch {
+	case errors.Is(err, webhook.ErrTooManyWebhooks):
+		apierror.Write(w, apierror.ErrConflict.WithMessage(err.Error()))
+		return
+	case errors.Is(err, webhook.ErrInvalidURL), errors.Is(err, webhook.ErrInvalidEvents), errors.Is(err, webhook.ErrSecretTooShort):
+		apierror.Write(w, apierror.ErrBadRequest.WithMessage(err.Error()))
+		return
+	case err != nil:
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to register webhook"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(sub)
+}
+
+// ListWebhooksHandler lists the caller's webhooks. Secrets are never returned.
+func (s *Service) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Webhooks are not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	subs, err := s.webhooks.List(r.Context(), did)
+	if err != nil {
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to list webhooks"))
+		return
+	}
+	if subs == nil {
+		subs = []*webhook.Subscription{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(WebhooksResponse{Webhooks: subs})
+}
+
+// DeleteWebhookHandler removes one of the caller's webhooks
+func (s *Service) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		apierror.Write(w, apierror.ErrDisabled.WithMessage("Webhooks are not enabled"))
+		return
+	}
+	_, did, ok := s.currentSession(r)
+	if !ok {
+		apierror.Write(w, apierror.ErrUnauthorized)
+		return
+	}
+
+	removed, err := s.webhooks.Unsubscribe(r.Context(), did, mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, apierror.ErrInternal.WithMessage("Failed to remove webhook"))
+		return
+	}
+	if !removed {
+		apierror.Write(w, apierror.ErrNotFound.WithMessage("Webhook not found"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
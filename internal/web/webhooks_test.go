@@ -0,0 +1,61 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/webhook"
+)
+
+func TestWebhookHandlers(t *testing.T) {
+	pds := newFakePDS(t, testWhiteDID)
+	service := newServiceForPDS(t, pds)
+
+	create := CreateWebhookRequest{URL: "https://bot.example.com/hook", Secret: "0123456789abcdef", Events: []string{"move"}}
+	w := sessionRequest(t, service, pds, service.CreateWebhookHandler, "POST", "/api/webhooks", nil, create, true)
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "disabled" {
+		t.Errorf("Expected webhooks to be disabled without a dispatcher, got %d", w.Code)
+	}
+
+	service.SetWebhooks(webhook.NewDispatcher())
+	if w = sessionRequest(t, service, pds, service.CreateWebhookHandler, "POST", "/api/webhooks", nil, create, false); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a session, got %d", w.Code)
+	}
+
+	w = sessionRequest(t, service, pds, service.CreateWebhookHandler, "POST", "/api/webhooks", nil, create, true)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), create.Secret) {
+		t.Error("Expected the secret not to be returned")
+	}
+	var sub webhook.Subscription
+	_ = json.Unmarshal(w.Body.Bytes(), &sub)
+	if sub.ID == "" || sub.Owner != testWhiteDID || sub.URL != create.URL {
+		t.Errorf("Unexpected subscription %+v", sub)
+	}
+
+	bad := create
+	bad.Events = []string{"everything"}
+	w = sessionRequest(t, service, pds, service.CreateWebhookHandler, "POST", "/api/webhooks", nil, bad, true)
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "bad_request" {
+		t.Errorf("Expected an unknown event to be rejected, got %d", w.Code)
+	}
+
+	w = sessionRequest(t, service, pds, service.ListWebhooksHandler, "GET", "/api/webhooks", nil, nil, true)
+	var list WebhooksResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != http.StatusOK || len(list.Webhooks) != 1 || list.Webhooks[0].ID != sub.ID {
+		t.Errorf("Expected the webhook to be listed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	vars := map[string]string{"id": sub.ID}
+	if w = sessionRequest(t, service, pds, service.DeleteWebhookHandler, "DELETE", "/api/webhooks/"+sub.ID, vars, nil, true); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if w = sessionRequest(t, service, pds, service.DeleteWebhookHandler, "DELETE", "/api/webhooks/"+sub.ID, vars, nil, true); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a removed webhook to be gone, got %d", w.Code)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/justinabrahms/atchess/internal/apierror"
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
 )
@@ -21,9 +23,39 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// GameChannel carries game events to players and spectators
+const GameChannel = "game"
+
+// KibitzChannel carries engine analysis to spectators only
+const KibitzChannel = "kibitz"
+
+// AnnouncementsChannel carries operator announcements to everyone; it isn't tied to a game
+const AnnouncementsChannel = "announcements"
+
+// PlayerChannel carries notifications addressed to the signed-in player, such
+// as challenges, draw offers and "your move" alerts, across all their games
+const PlayerChannel = "player"
+
+// TVChannel carries the featured game's events, and switches to the next
+// featured game when it ends; it isn't tied to a game
+const TVChannel = "tv"
+
+// StudyChannel carries edits to a study, so everyone analysing it sees each
+// other's moves and comments; gameId is the study's URI
+const StudyChannel = "study"
+
+// restartMessage tells clients the server is going away, so they reconnect
+// and ask for the updates they miss meanwhile
+var restartMessage = websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting")
+
+// broadcastQueueSize is how many updates can wait for the hub, so updates
+// sent back to back, such as one to each player, aren't dropped
+const broadcastQueueSize = 64
+
 // Hub maintains active WebSocket connections
 type Hub struct {
-	// Registered clients by game ID
+	// Registered clients by room (game ID, channel-qualified game ID, or
+	// channel-qualified DID for the player channel)
 	gameClients map[string]map[*Client]bool
 	
 	// Broadcast channel for game updates
@@ -35,62 +67,242 @@ type Hub struct {
 	// Unregister requests from clients
 	unregister chan *Client
 	
+	// Signed-in connections per player on each game's channel
+	presence map[string]int
+	
+	// Called when a player's first connection to a game opens or their last one closes
+	onPresence PresenceFunc
+	
+	// Signed-in connections per player on any channel, and when each player's
+	// last one closed
+	online   map[string]int
+	lastSeen map[string]time.Time
+	
+	// Recent updates by room, for clients that reconnect; only the run loop
+	// uses it
+	history map[string]*replayBuffer
+	
+	// The featured game, whose updates are also sent on the TV channel, and
+	// the frame announcing it for clients joining the channel; only the run
+	// loop uses the frame
+	featured      string
+	featuredFrame []byte
+	
+	// Updates waiting to be shared with other replicas, when a broker is in use
+	outbound  chan brokerMessage
+	replicaID string
+	
+	// closing is closed by Shutdown, after which connections are refused;
+	// stopped is closed when Run has closed every client and returned
+	closing   chan struct{}
+	closeOnce sync.Once
+	stopped   chan struct{}
+	
+	// writers counts clients' write pumps, so Shutdown can wait for each to
+	// send what it has queued and its close frame
+	writers sync.WaitGroup
+	
 	mu sync.RWMutex
 }
 
+// PresenceFunc is told when a player comes online or goes offline in a game
+type PresenceFunc func(gameID, playerDID string, online bool)
+
 // Client represents a WebSocket connection
 type Client struct {
 	hub    *Hub
 	conn   *websocket.Conn
 	send   chan []byte
-	gameID string
-	userID string
+	gameID  string
+	userID  string
+	channel string
+	
+	// closeMessage is the close frame sent when send is closed; set before
+	// closing it
+	closeMessage []byte
+	
+	// resume asks for the room's updates after since, in the numbering of
+	// epoch, when the client joins
+	resume bool
+	since  uint64
+	epoch  string
+}
+
+// roomKey returns the hub room for a game's channel
+func roomKey(gameID, channel string) string {
+	if channel == "" || channel == GameChannel {
+		return gameID
+	}
+	return channel + ":" + gameID
+}
+
+// presenceKey identifies a signed-in player on a game's channel, or is empty
+// for spectators and other channels
+func (c *Client) presenceKey() string {
+	if c.channel != GameChannel || !c.signedIn() {
+		return ""
+	}
+	return c.gameID + " " + c.userID
+}
+
+// room returns the hub room this client is subscribed to
+func (c *Client) room() string {
+	if c.channel == PlayerChannel {
+		return roomKey(c.userID, PlayerChannel)
+	}
+	return roomKey(c.gameID, c.channel)
+}
+
+// updateRoom returns the hub room an update should be delivered to
+func updateRoom(update GameUpdate) string {
+	if update.recipient != "" {
+		return roomKey(update.recipient, PlayerChannel)
+	}
+	switch update.Type {
+	case "kibitz":
+		return roomKey(update.GameID, KibitzChannel)
+	case "announcement":
+		return roomKey("", AnnouncementsChannel)
+	case "tv_featured":
+		return roomKey("", TVChannel)
+	case "study_edit", "study_deleted":
+		return roomKey(update.GameID, StudyChannel)
+	}
+	return update.GameID
 }
 
 // GameUpdate represents an update to broadcast
 type GameUpdate struct {
 	GameID string      `json:"gameId"`
-	Type   string      `json:"type"` // "move", "draw_offer", "resignation", "game_end"
+	Type   string      `json:"type"` // "move", "clock", "draw_offer", "resignation", "game_end", "player_disconnected", "player_reconnected", "clock_resumed", "challenge", "your_move"
 	Data   interface{} `json:"data"`
+	Cues   *FrameCues  `json:"cues,omitempty"`
+	// Seq numbers the updates of each game channel, player channel and so
+	// on from 1, so reconnecting clients can ask for the ones they missed.
+	// Epoch tells numberings apart, since they start again after a restart.
+	Seq   uint64 `json:"seq,omitempty"`
+	Epoch string `json:"epoch,omitempty"`
+
+	// recipient is the player DID for updates delivered on the player channel
+	recipient string
 }
 
 // NewHub creates a new WebSocket hub
 func NewHub() *Hub {
 	return &Hub{
 		gameClients: make(map[string]map[*Client]bool),
-		broadcast:   make(chan GameUpdate),
+		broadcast:   make(chan GameUpdate, broadcastQueueSize),
 		register:    make(chan *Client),
 		unregister:  make(chan *Client),
+		presence:    make(map[string]int),
+		online:      make(map[string]int),
+		lastSeen:    make(map[string]time.Time),
+		history:     make(map[string]*replayBuffer),
+		closing:     make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// OnPresence registers a function to call when players connect to or
+// disconnect from their games. It is called on its own goroutine.
+func (h *Hub) OnPresence(fn PresenceFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onPresence = fn
+}
+
+// IsOnline reports whether a player has a connection open to a game
+func (h *Hub) IsOnline(gameID, playerDID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.presence[gameID+" "+playerDID] > 0
+}
+
+// join counts a client's connection; the caller must hold h.mu
+func (h *Hub) join(client *Client) {
+	if client.signedIn() {
+		h.online[client.userID]++
+	}
+	
+	key := client.presenceKey()
+	if key == "" {
+		return
+	}
+	h.presence[key]++
+	if h.presence[key] == 1 {
+		h.announcePresence(client.gameID, client.userID)
+		if h.onPresence != nil {
+			go h.onPresence(client.gameID, client.userID, true)
+		}
+	}
+}
+
+// leave uncounts a client's connection; the caller must hold h.mu
+func (h *Hub) leave(client *Client) {
+	if client.signedIn() && h.online[client.userID] > 0 {
+		h.online[client.userID]--
+		if h.online[client.userID] == 0 {
+			delete(h.online, client.userID)
+			h.lastSeen[client.userID] = time.Now()
+		}
+	}
+	
+	key := client.presenceKey()
+	if key == "" || h.presence[key] == 0 {
+		return
+	}
+	h.presence[key]--
+	if h.presence[key] == 0 {
+		delete(h.presence, key)
+		h.announcePresence(client.gameID, client.userID)
+		if h.onPresence != nil {
+			go h.onPresence(client.gameID, client.userID, false)
+		}
 	}
 }
 
 // Run starts the hub's main event loop
 func (h *Hub) Run() {
+	prune := time.NewTicker(time.Minute)
+	defer prune.Stop()
+	
 	for {
 		select {
 		case client := <-h.register:
 			h.mu.Lock()
-			if h.gameClients[client.gameID] == nil {
-				h.gameClients[client.gameID] = make(map[*Client]bool)
+			if h.gameClients[client.room()] == nil {
+				h.gameClients[client.room()] = make(map[*Client]bool)
 			}
-			h.gameClients[client.gameID][client] = true
+			h.gameClients[client.room()][client] = true
+			h.join(client)
 			h.mu.Unlock()
+			if client.resume {
+				h.replay(client)
+			} else if client.channel == TVChannel && h.featuredFrame != nil {
+				// Show new viewers what's on
+				select {
+				case client.send <- h.featuredFrame:
+				default:
+				}
+			}
 			
 			log.Info().
 				Str("gameID", client.gameID).
 				Str("userID", client.userID).
+				Str("channel", client.channel).
 				Msg("Client connected to game")
 			
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if clients, ok := h.gameClients[client.gameID]; ok {
+			if clients, ok := h.gameClients[client.room()]; ok {
 				if _, ok := clients[client]; ok {
 					delete(clients, client)
 					close(client.send)
+					h.leave(client)
 					
 					// Clean up empty game rooms
 					if len(clients) == 0 {
-						delete(h.gameClients, client.gameID)
+						delete(h.gameClients, client.room())
 					}
 				}
 			}
@@ -102,35 +314,157 @@ func (h *Hub) Run() {
 				Msg("Client disconnected from game")
 			
 		case update := <-h.broadcast:
-			h.mu.RLock()
-			clients := h.gameClients[update.GameID]
-			h.mu.RUnlock()
+			h.send(update)
 			
-			if clients != nil {
-				message, err := json.Marshal(update)
-				if err != nil {
-					log.Error().Err(err).Msg("Failed to marshal game update")
-					continue
-				}
-				
-				for client := range clients {
-					select {
-					case client.send <- message:
-					default:
-						// Client's send channel is full, close it
-						close(client.send)
-						h.mu.Lock()
-						delete(clients, client)
-						h.mu.Unlock()
-					}
-				}
-			}
+		case now := <-prune.C:
+			h.pruneHistory(now)
+			
+		case <-h.closing:
+			h.stop()
+			return
 		}
 	}
 }
 
-// BroadcastGameUpdate sends an update to all clients watching a game
+// send queues an update for the clients in its room, and on the TV channel
+// too when it's one of the featured game's
+func (h *Hub) send(update GameUpdate) {
+	room := updateRoom(update)
+	if update.Type == "tv_featured" {
+		// Switching here keeps the switch in order with the games' updates
+		h.mu.Lock()
+		h.featured = update.GameID
+		h.mu.Unlock()
+		h.featuredFrame = h.sendTo(room, update)
+		return
+	}
+	h.sendTo(room, update)
+	
+	h.mu.RLock()
+	featured := h.featured
+	h.mu.RUnlock()
+	if featured != "" && room == featured {
+		h.sendTo(roomKey("", TVChannel), update)
+	}
+}
+
+// sendTo queues an update for the clients in room, returning the frame sent
+func (h *Hub) sendTo(room string, update GameUpdate) []byte {
+	message, err := h.sequence(room, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal game update")
+		return nil
+	}
+	
+	h.mu.RLock()
+	clients := h.gameClients[room]
+	h.mu.RUnlock()
+	
+	for client := range clients {
+		select {
+		case client.send <- message:
+		default:
+			// Client's send channel is full, close it
+			close(client.send)
+			h.mu.Lock()
+			delete(clients, client)
+			h.leave(client)
+			h.mu.Unlock()
+		}
+	}
+	return message
+}
+
+// stop delivers the updates still queued, then closes every client with
+// restartMessage. Players aren't counted as leaving their games, since
+// they're expected back once the server is.
+func (h *Hub) stop() {
+	for pending := true; pending; {
+		select {
+		case update := <-h.broadcast:
+			h.send(update)
+		default:
+			pending = false
+		}
+	}
+	
+	h.mu.Lock()
+	for room, clients := range h.gameClients {
+		for client := range clients {
+			client.closeMessage = restartMessage
+			close(client.send)
+		}
+		delete(h.gameClients, room)
+	}
+	h.mu.Unlock()
+	close(h.stopped)
+}
+
+// Shutdown drains the hub before the server stops: new connections are
+// refused, queued updates are delivered, and every client is sent what it's
+// owed followed by a close frame saying the server is restarting. Updates
+// waiting to be shared through the broker are published too. It returns
+// when that's done or ctx expires; Run must be running.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.closeOnce.Do(func() { close(h.closing) })
+	select {
+	case <-h.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	
+	written := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(written)
+	}()
+	select {
+	case <-written:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	
+	h.mu.RLock()
+	outbound := h.outbound
+	h.mu.RUnlock()
+	for outbound != nil && len(outbound) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// SubscriberCount returns how many clients are subscribed to a game's channel
+func (h *Hub) SubscriberCount(gameID, channel string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.gameClients[roomKey(gameID, channel)])
+}
+
+// SetFeatured makes gameID the game shown on the TV channel, telling its
+// viewers with a "tv_featured" frame carrying data. An empty gameID means
+// there's no game to show.
+func (h *Hub) SetFeatured(gameID string, data interface{}) {
+	// Not shared through the broker: every replica picks its own featured
+	// game from the same index
+	select {
+	case h.broadcast <- GameUpdate{GameID: gameID, Type: "tv_featured", Data: data}:
+	case <-h.stopped:
+	}
+}
+
+// BroadcastGameUpdate sends an update to all clients watching a game, on
+// this replica and, through the broker, on every other
 func (h *Hub) BroadcastGameUpdate(update GameUpdate) {
+	h.publish(update)
+	h.deliver(update)
+}
+
+// deliver sends an update to this replica's clients
+func (h *Hub) deliver(update GameUpdate) {
 	select {
 	case h.broadcast <- update:
 	default:
@@ -143,13 +477,84 @@ func (s *Service) WebSocketHandler(hub *Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get game ID from query params
 		gameID := r.URL.Query().Get("gameId")
-		if gameID == "" {
-			http.Error(w, "Missing gameId parameter", http.StatusBadRequest)
+		
+		// Browsers can't set headers on WebSocket requests, so also accept the session as a query param
+		userID := "anonymous"
+		token := r.Header.Get(SessionHeader)
+		if token == "" {
+			token = r.URL.Query().Get("session")
+		}
+		if token != "" {
+			session, ok := s.lookupSession(r, token)
+			if !ok {
+				apierror.Write(w, apierror.ErrInvalidSession)
+				return
+			}
+			userID = session.Client.GetDID()
+		}
+		
+		channel := r.URL.Query().Get("channel")
+		if channel == "" {
+			channel = GameChannel
+		}
+		
+		if gameID == "" && channel != AnnouncementsChannel && channel != PlayerChannel && channel != TVChannel {
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("Missing gameId parameter"))
 			return
 		}
 		
-		// TODO: Get user ID from session/auth
-		userID := "anonymous"
+		switch channel {
+		case GameChannel:
+		case AnnouncementsChannel, TVChannel:
+			gameID = ""
+		case PlayerChannel:
+			if userID == "anonymous" {
+				apierror.Write(w, apierror.ErrUnauthorized.WithMessage("Sign in to receive notifications"))
+				return
+			}
+			gameID = ""
+		case StudyChannel:
+			if s.studies == nil {
+				apierror.Write(w, apierror.ErrDisabled.WithMessage("Studies are not enabled"))
+				return
+			}
+		case KibitzChannel:
+			if s.kibitzer == nil {
+				apierror.Write(w, apierror.ErrDisabled.WithMessage("Kibitz analysis is not enabled"))
+				return
+			}
+			// Anonymous subscribers could be a player of the game in another tab
+			if userID == "anonymous" {
+				apierror.Write(w, apierror.ErrUnauthorized.WithMessage("Sign in to watch analysis"))
+				return
+			}
+			if s.isActivePlayer(gameID, userID) {
+				apierror.Write(w, apierror.ErrForbidden.WithMessage("Only spectators can watch analysis of a game"))
+				return
+			}
+		default:
+			apierror.Write(w, apierror.ErrBadRequest.WithMessage("Unknown channel"))
+			return
+		}
+		
+		// Reconnecting clients send the last sequence number they saw, and
+		// the epoch it was numbered in
+		var since uint64
+		resume := r.URL.Query().Has("since")
+		if resume {
+			var err error
+			if since, err = strconv.ParseUint(r.URL.Query().Get("since"), 10, 64); err != nil {
+				apierror.Write(w, apierror.ErrBadRequest.WithMessage("Invalid since parameter"))
+				return
+			}
+		}
+		
+		select {
+		case <-hub.closing:
+			apierror.Write(w, apierror.ErrUnavailable.WithMessage("The server is restarting"))
+			return
+		default:
+		}
 		
 		// Upgrade connection
 		conn, err := upgrader.Upgrade(w, r, nil)
@@ -160,15 +565,31 @@ func (s *Service) WebSocketHandler(hub *Hub) http.HandlerFunc {
 		
 		// Create client
 		client := &Client{
-			hub:    hub,
-			conn:   conn,
-			send:   make(chan []byte, 256),
-			gameID: gameID,
-			userID: userID,
+			hub:     hub,
+			conn:    conn,
+			send:    make(chan []byte, 256),
+			gameID:  gameID,
+			userID:  userID,
+			channel: channel,
+			resume:  resume,
+			since:   since,
+			epoch:   r.URL.Query().Get("epoch"),
+		}
+		
+		// Register client, unless the hub has started shutting down since
+		hub.writers.Add(1)
+		select {
+		case hub.register <- client:
+		case <-hub.closing:
+			hub.writers.Done()
+			conn.WriteControl(websocket.CloseMessage, restartMessage, time.Now().Add(time.Second))
+			conn.Close()
+			return
 		}
 		
-		// Register client
-		client.hub.register <- client
+		if channel == KibitzChannel {
+			s.kibitzer.Watch(gameID)
+		}
 		
 		// Start client goroutines
 		go client.writePump()
@@ -179,7 +600,11 @@ func (s *Service) WebSocketHandler(hub *Hub) http.HandlerFunc {
 // readPump handles incoming messages from the WebSocket
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.stopped:
+			// Run has closed the client already
+		}
 		c.conn.Close()
 	}()
 	
@@ -221,6 +646,7 @@ func (c *Client) writePump() {
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.writers.Done()
 	}()
 	
 	for {
@@ -228,7 +654,7 @@ func (c *Client) writePump() {
 		case message, ok := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.conn.WriteMessage(websocket.CloseMessage, c.closeMessage)
 				return
 			}
 			
@@ -258,21 +684,22 @@ func (c *Client) writePump() {
 	}
 }
 
-// BroadcastToGame sends an update to all clients watching a specific game
+// BroadcastToGame sends an update to all clients watching a specific game.
+// It is used for firehose events, which every replica sees, so the update is
+// not shared through the broker.
 func (h *Hub) BroadcastToGame(gameID string, update GameUpdate) {
 	update.GameID = gameID
-	h.broadcast <- update
+	select {
+	case h.broadcast <- update:
+	case <-h.stopped:
+	}
 }
 
-// BroadcastToPlayer sends an update to all clients for a specific player
+// BroadcastToPlayer sends an update to a player's connections on the player
+// channel only
 func (h *Hub) BroadcastToPlayer(playerDID string, update GameUpdate) {
-	// For now, we broadcast to all clients and let them filter
-	// In a production system, you'd want to track clients by player DID
-	update.Data = map[string]interface{}{
-		"playerDID": playerDID,
-		"data": update.Data,
-	}
-	h.broadcast <- update
+	update.recipient = playerDID
+	h.BroadcastGameUpdate(update)
 }
 
 // Integration with firehose events
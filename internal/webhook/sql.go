@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/sqlstore"
+)
+
+// sqlSchema is portable between SQLite and Postgres. Creation times are
+// stored as Unix nanoseconds so both order them the same.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id TEXT PRIMARY KEY,
+		owner TEXT NOT NULL,
+		url TEXT NOT NULL,
+		events TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		created_at BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS webhooks_owner ON webhooks (owner, created_at)`,
+}
+
+// SQLStore is a Store backed by database/sql, so webhooks survive restarts
+// and every replica delivers to them. The driver must be linked into the
+// binary, e.g. with a blank import of a SQLite or Postgres driver.
+type SQLStore struct {
+	db *sqlstore.DB
+}
+
+// OpenSQLStore connects to the database and creates the table if needed
+func OpenSQLStore(ctx context.Context, driver, dsn string) (*SQLStore, error) {
+	db, err := sqlstore.Open(ctx, "webhook", driver, dsn, sqlSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Put stores a subscription, replacing any with the same ID
+func (s *SQLStore) Put(ctx context.Context, sub *Subscription) error {
+	_, err := s.db.ExecContext(ctx, s.db.Rebind(`
+		INSERT INTO webhooks (id, owner, url, events, secret, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, url = excluded.url,
+			events = excluded.events, secret = excluded.secret, created_at = excluded.created_at`),
+		sub.ID, sub.Owner, sub.URL, strings.Join(sub.Events, ","), sub.secret, sub.CreatedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to store webhook %s: %w", sub.ID, err)
+	}
+	return nil
+}
+
+// List returns owner's subscriptions, oldest first
+func (s *SQLStore) List(ctx context.Context, owner string) ([]*Subscription, error) {
+	rows, err := s.db.QueryContext(ctx, s.db.Rebind(`
+		SELECT id, owner, url, events, secret, created_at FROM webhooks
+		WHERE owner = ? ORDER BY created_at, id`), owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []*Subscription
+	for rows.Next() {
+		var sub Subscription
+		var events string
+		var created int64
+		if err := rows.Scan(&sub.ID, &sub.Owner, &sub.URL, &events, &sub.secret, &created); err != nil {
+			return nil, fmt.Errorf("failed to read webhook: %w", err)
+		}
+		sub.Events = strings.Split(events, ",")
+		sub.CreatedAt = time.Unix(0, created).UTC()
+		subs = append(subs, &sub)
+	}
+	return subs, rows.Err()
+}
+
+// Delete removes one of owner's subscriptions
+func (s *SQLStore) Delete(ctx context.Context, owner, id string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, s.db.Rebind(`DELETE FROM webhooks WHERE id = ? AND owner = ?`), id, owner)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook %s: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook %s: %w", id, err)
+	}
+	return n > 0, nil
+}
+
+// Close closes the database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,351 @@
+// Package webhook delivers game events to URLs registered by players, signed
+// with a secret they chose, so bots and external services can react to moves
+// without holding a WebSocket open.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/justinabrahms/atchess/internal/safehttp"
+	"github.com/rs/zerolog/log"
+)
+
+// Event types a subscription can ask for
+const (
+	EventMove      = "move"
+	EventGameEnd   = "game_end"
+	EventChallenge = "challenge"
+)
+
+// Headers sent with each delivery
+const (
+	EventHeader     = "X-ATChess-Event"
+	DeliveryHeader  = "X-ATChess-Delivery"
+	SignatureHeader = "X-ATChess-Signature"
+)
+
+// MaxSubscriptions bounds the webhooks one player can register
+const MaxSubscriptions = 10
+
+// MinSecretLength is the shortest secret accepted for signing deliveries
+const MinSecretLength = 16
+
+// deliveryTimeout bounds a single delivery attempt
+const deliveryTimeout = 10 * time.Second
+
+var (
+	ErrInvalidURL       = errors.New("webhook URL must be an absolute http or https URL")
+	ErrInvalidEvents    = errors.New("webhook events must be one or more of move, game_end and challenge")
+	ErrSecretTooShort   = fmt.Errorf("webhook secret must be at least %d characters", MinSecretLength)
+	ErrTooManyWebhooks  = fmt.Errorf("at most %d webhooks can be registered", MaxSubscriptions)
+	errPermanentFailure = errors.New("webhook endpoint rejected the delivery")
+)
+
+// validEvents are the event types subscriptions can filter on
+var validEvents = map[string]bool{EventMove: true, EventGameEnd: true, EventChallenge: true}
+
+// Subscription is a player's registered webhook
+type Subscription struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+	secret    string
+}
+
+// wants reports whether the subscription asked for events of this type
+func (s *Subscription) wants(eventType string) bool {
+	for _, e := range s.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a game event as delivered to subscribers
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"event"`
+	// URI is the record the event was read from
+	URI       string      `json:"uri"`
+	Game      string      `json:"game,omitempty"`
+	Record    interface{} `json:"record"`
+	CreatedAt time.Time   `json:"createdAt"`
+	// Players are the DIDs the event concerns; only their webhooks receive it
+	Players []string `json:"-"`
+}
+
+// Option configures a dispatcher
+type Option func(*Dispatcher)
+
+// WithHTTPClient delivers through client instead of one that refuses
+// private addresses
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithStore keeps subscriptions in store instead of in memory
+func WithStore(store Store) Option {
+	return func(d *Dispatcher) {
+		d.store = store
+	}
+}
+
+// WithRetries sets how many times a delivery is attempted and the wait
+// before the first retry, which doubles after each failure
+func WithRetries(attempts int, backoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		if attempts > 0 {
+			d.attempts = attempts
+		}
+		if backoff > 0 {
+			d.backoff = backoff
+		}
+	}
+}
+
+// Dispatcher keeps webhook subscriptions and delivers events to them
+type Dispatcher struct {
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+	store    Store
+
+	// mu serializes subscribing, so this replica can't exceed the limit
+	mu         sync.Mutex
+	deliveries sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher. By default deliveries are attempted
+// five times, backing off from two seconds, and private addresses are
+// refused so webhooks can't be used to reach the server's own network.
+// Subscriptions are kept in memory unless a store is given.
+func NewDispatcher(opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		client:   safehttp.Client(),
+		attempts: 5,
+		backoff:  2 * time.Second,
+		store:    NewMemoryStore(),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Subscribe registers a webhook for owner
+func (d *Dispatcher) Subscribe(ctx context.Context, owner, rawURL, secret string, events []string) (*Subscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	if len(secret) < MinSecretLength {
+		return nil, ErrSecretTooShort
+	}
+	if len(events) == 0 {
+		return nil, ErrInvalidEvents
+	}
+	seen := make(map[string]bool)
+	var filter []string
+	for _, e := range events {
+		if !validEvents[e] {
+			return nil, ErrInvalidEvents
+		}
+		if !seen[e] {
+			seen[e] = true
+			filter = append(filter, e)
+		}
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	sub := &Subscription{
+		ID:        id,
+		Owner:     owner,
+		URL:       u.String(),
+		Events:    filter,
+		CreatedAt: time.Now().UTC(),
+		secret:    secret,
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	existing, err := d.store.List(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= MaxSubscriptions {
+		return nil, ErrTooManyWebhooks
+	}
+	if err := d.store.Put(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// List returns owner's webhooks, oldest first
+func (d *Dispatcher) List(ctx context.Context, owner string) ([]*Subscription, error) {
+	return d.store.List(ctx, owner)
+}
+
+// Unsubscribe removes one of owner's webhooks, reporting whether it existed
+func (d *Dispatcher) Unsubscribe(ctx context.Context, owner, id string) (bool, error) {
+	return d.store.Delete(ctx, owner, id)
+}
+
+// Dispatch delivers event to the webhooks of the players it concerns that
+// asked for its type. Deliveries happen in the background, each retried with
+// backoff until it succeeds or runs out of attempts.
+func (d *Dispatcher) Dispatch(ctx context.Context, event Event) {
+	if event.ID == "" {
+		id, err := randomID()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to generate webhook delivery ID")
+			return
+		}
+		event.ID = id
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+
+	var targets []*Subscription
+	for _, player := range dedupe(event.Players) {
+		subs, err := d.store.List(ctx, player)
+		if err != nil {
+			log.Error().Err(err).Str("player", player).Msg("Failed to look up webhooks")
+			continue
+		}
+		for _, sub := range subs {
+			if sub.wants(event.Type) {
+				targets = append(targets, sub)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Error().Err(err).Str("event", event.Type).Msg("Failed to encode webhook event")
+		return
+	}
+	// Deliveries outlive the firehose event that triggered them
+	ctx = context.WithoutCancel(ctx)
+	for _, sub := range targets {
+		d.deliveries.Add(1)
+		go func(sub *Subscription) {
+			defer d.deliveries.Done()
+			d.deliver(ctx, sub, event, body)
+		}(sub)
+	}
+}
+
+// Wait blocks until all deliveries in progress have finished
+func (d *Dispatcher) Wait() {
+	d.deliveries.Wait()
+}
+
+// deliver posts an event to one webhook, retrying transient failures
+func (d *Dispatcher) deliver(ctx context.Context, sub *Subscription, event Event, body []byte) {
+	logger := log.With().Str("webhook", sub.ID).Str("owner", sub.Owner).Str("delivery", event.ID).Logger()
+	backoff := d.backoff
+	for attempt := 1; attempt <= d.attempts; attempt++ {
+		err := d.post(ctx, sub, event, body)
+		if err == nil {
+			logger.Debug().Int("attempt", attempt).Msg("Delivered webhook")
+			return
+		}
+		if errors.Is(err, errPermanentFailure) || errors.Is(err, safehttp.ErrPrivateAddress) || attempt == d.attempts {
+			logger.Warn().Err(err).Int("attempt", attempt).Msg("Giving up on webhook delivery")
+			return
+		}
+		logger.Debug().Err(err).Int("attempt", attempt).Dur("retryIn", backoff).Msg("Webhook delivery failed, retrying")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt. Server errors, rate limiting and network
+// failures are worth retrying; other error statuses are not.
+func (d *Dispatcher) post(ctx context.Context, sub *Subscription, event Event, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanentFailure, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ATChess-Webhooks/1.0")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID)
+	req.Header.Set(SignatureHeader, Sign(sub.secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout:
+		return fmt.Errorf("webhook endpoint returned %s", resp.Status)
+	default:
+		return fmt.Errorf("%w: %s", errPermanentFailure, resp.Status)
+	}
+}
+
+// Sign returns the signature header value for a delivery body:
+// "sha256=" followed by the hex HMAC-SHA256 of the body keyed by the secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid signature of body, for
+// receivers checking deliveries came from this server
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// dedupe returns list without repeats, in order
+func dedupe(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	var unique []string
+	for _, item := range list {
+		if !seen[item] {
+			seen[item] = true
+			unique = append(unique, item)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const testSecret = "0123456789abcdef"
+
+// receiver records the deliveries made to it, failing the first few
+type receiver struct {
+	*httptest.Server
+	mu         sync.Mutex
+	failures   int
+	status     int
+	attempts   int
+	deliveries []*http.Request
+	bodies     [][]byte
+}
+
+func newReceiver(t *testing.T, failures, status int) *receiver {
+	t.Helper()
+	rec := &receiver{failures: failures, status: status}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.attempts++
+		if rec.attempts <= rec.failures {
+			w.WriteHeader(rec.status)
+			return
+		}
+		rec.deliveries = append(rec.deliveries, r)
+		rec.bodies = append(rec.bodies, body)
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func newTestDispatcher(rec *receiver) *Dispatcher {
+	return NewDispatcher(WithHTTPClient(rec.Client()), WithRetries(3, time.Millisecond))
+}
+
+func TestSubscribeValidates(t *testing.T) {
+	d := NewDispatcher()
+	cases := []struct {
+		url, secret string
+		events      []string
+		want        error
+	}{
+		{"ftp://example.com/hook", testSecret, []string{EventMove}, ErrInvalidURL},
+		{"/hook", testSecret, []string{EventMove}, ErrInvalidURL},
+		{"https://example.com/hook", "short", []string{EventMove}, ErrSecretTooShort},
+		{"https://example.com/hook", testSecret, nil, ErrInvalidEvents},
+		{"https://example.com/hook", testSecret, []string{"resignation"}, ErrInvalidEvents},
+	}
+	for _, c := range cases {
+		if _, err := d.Subscribe(context.Background(), "did:plc:alice", c.url, c.secret, c.events); err != c.want {
+			t.Errorf("Subscribe(%q, %q, %v): expected %v, got %v", c.url, c.secret, c.events, c.want, err)
+		}
+	}
+
+	for i := 0; i < MaxSubscriptions; i++ {
+		if _, err := d.Subscribe(context.Background(), "did:plc:alice", "https://example.com/hook", testSecret, []string{EventMove}); err != nil {
+			t.Fatalf("Failed to subscribe: %v", err)
+		}
+	}
+	if _, err := d.Subscribe(context.Background(), "did:plc:alice", "https://example.com/hook", testSecret, []string{EventMove}); err != ErrTooManyWebhooks {
+		t.Errorf("Expected the number of webhooks to be limited, got %v", err)
+	}
+	if _, err := d.Subscribe(context.Background(), "did:plc:bob", "https://example.com/hook", testSecret, []string{EventMove}); err != nil {
+		t.Errorf("Expected the limit to be per player, got %v", err)
+	}
+}
+
+func TestDispatchDeliversSignedEventsToMatchingWebhooks(t *testing.T) {
+	rec := newReceiver(t, 0, 0)
+	d := newTestDispatcher(rec)
+	moves, _ := d.Subscribe(context.Background(), "did:plc:alice", rec.URL+"/moves", testSecret, []string{EventMove, EventMove})
+	d.Subscribe(context.Background(), "did:plc:alice", rec.URL+"/endings", testSecret, []string{EventGameEnd})
+	d.Subscribe(context.Background(), "did:plc:carol", rec.URL+"/carol", testSecret, []string{EventMove})
+
+	d.Dispatch(context.Background(), Event{
+		Type:    EventMove,
+		URI:     "at://did:plc:bob/app.atchess.move/1",
+		Game:    "at://did:plc:alice/app.atchess.game/1",
+		Record:  map[string]interface{}{"san": "e4"},
+		Players: []string{"did:plc:bob", "did:plc:alice"},
+	})
+	d.Wait()
+
+	if len(moves.Events) != 1 {
+		t.Errorf("Expected duplicate events to be dropped from the filter, got %v", moves.Events)
+	}
+	if len(rec.deliveries) != 1 {
+		t.Fatalf("Expected one delivery to alice's move webhook, got %d", len(rec.deliveries))
+	}
+	req, body := rec.deliveries[0], rec.bodies[0]
+	if req.URL.Path != "/moves" || req.Header.Get(EventHeader) != EventMove || req.Header.Get(DeliveryHeader) == "" {
+		t.Errorf("Unexpected delivery %s %v", req.URL.Path, req.Header)
+	}
+	if !Verify(testSecret, body, req.Header.Get(SignatureHeader)) {
+		t.Errorf("Expected a valid signature, got %q", req.Header.Get(SignatureHeader))
+	}
+	if Verify("another-secret-value", body, req.Header.Get(SignatureHeader)) {
+		t.Error("Expected the signature to depend on the secret")
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Expected a JSON payload: %v", err)
+	}
+	if payload["event"] != EventMove || payload["game"] != "at://did:plc:alice/app.atchess.game/1" || payload["id"] != req.Header.Get(DeliveryHeader) {
+		t.Errorf("Unexpected payload %v", payload)
+	}
+	if _, ok := payload["Players"]; ok {
+		t.Error("Expected players not to be part of the payload")
+	}
+}
+
+func TestDeliveryRetriesTransientFailures(t *testing.T) {
+	rec := newReceiver(t, 2, http.StatusBadGateway)
+	d := newTestDispatcher(rec)
+	d.Subscribe(context.Background(), "did:plc:alice", rec.URL, testSecret, []string{EventChallenge})
+
+	d.Dispatch(context.Background(), Event{Type: EventChallenge, Players: []string{"did:plc:alice"}})
+	d.Wait()
+	if rec.attempts != 3 || len(rec.deliveries) != 1 {
+		t.Errorf("Expected a delivery on the third attempt, got %d attempts and %d deliveries", rec.attempts, len(rec.deliveries))
+	}
+	if rec.deliveries[0].Header.Get(DeliveryHeader) == "" {
+		t.Error("Expected retries to keep the delivery ID")
+	}
+}
+
+func TestDeliveryGivesUpOnClientErrors(t *testing.T) {
+	rec := newReceiver(t, 5, http.StatusGone)
+	d := newTestDispatcher(rec)
+	d.Subscribe(context.Background(), "did:plc:alice", rec.URL, testSecret, []string{EventChallenge})
+
+	d.Dispatch(context.Background(), Event{Type: EventChallenge, Players: []string{"did:plc:alice"}})
+	d.Wait()
+	if rec.attempts != 1 {
+		t.Errorf("Expected no retries after a 410, got %d attempts", rec.attempts)
+	}
+}
+
+func TestDefaultClientRefusesPrivateAddresses(t *testing.T) {
+	rec := newReceiver(t, 0, 0)
+	d := NewDispatcher(WithRetries(3, time.Millisecond))
+	d.Subscribe(context.Background(), "did:plc:alice", rec.URL, testSecret, []string{EventMove})
+
+	d.Dispatch(context.Background(), Event{Type: EventMove, Players: []string{"did:plc:alice"}})
+	d.Wait()
+	if rec.attempts != 0 {
+		t.Errorf("Expected a loopback webhook not to be called, got %d attempts", rec.attempts)
+	}
+}
+
+// forEachStore runs a test against the in-memory store and SQLite
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		store, err := OpenSQLStore(context.Background(), "sqlite", filepath.Join(t.TempDir(), "webhooks.db"))
+		if err != nil {
+			t.Fatalf("Failed to open SQLite store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		test(t, store)
+	})
+}
+
+func TestUnsubscribe(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		d := NewDispatcher(WithStore(store))
+		sub, _ := d.Subscribe(ctx, "did:plc:alice", "https://example.com/hook", testSecret, []string{EventMove})
+		if removed, err := d.Unsubscribe(ctx, "did:plc:bob", sub.ID); removed || err != nil {
+			t.Errorf("Expected other players not to remove alice's webhook, got %v (%v)", removed, err)
+		}
+		if removed, err := d.Unsubscribe(ctx, "did:plc:alice", sub.ID); !removed || err != nil {
+			t.Errorf("Expected alice's webhook to be removed, got %v (%v)", removed, err)
+		}
+		if subs, _ := d.List(ctx, "did:plc:alice"); len(subs) != 0 {
+			t.Errorf("Expected no webhooks left, got %v", subs)
+		}
+	})
+}
+
+func TestStoresKeepSubscriptions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		d := NewDispatcher(WithStore(store))
+		first, _ := d.Subscribe(ctx, "did:plc:alice", "https://example.com/first", testSecret, []string{EventMove, EventGameEnd})
+		d.Subscribe(ctx, "did:plc:bob", "https://example.com/bob", testSecret, []string{EventMove})
+		second, _ := d.Subscribe(ctx, "did:plc:alice", "https://example.com/second", testSecret+"2", []string{EventChallenge})
+
+		subs, err := store.List(ctx, "did:plc:alice")
+		if err != nil || len(subs) != 2 || subs[0].ID != first.ID || subs[1].ID != second.ID {
+			t.Fatalf("Expected alice's two webhooks oldest first, got %v (%v)", subs, err)
+		}
+		if subs[0].URL != first.URL || len(subs[0].Events) != 2 || !subs[0].wants(EventGameEnd) || subs[0].secret != testSecret {
+			t.Errorf("Expected the webhook stored as registered, got %+v", subs[0])
+		}
+		if !subs[0].CreatedAt.Equal(first.CreatedAt) {
+			t.Errorf("Expected the creation time kept, got %v for %v", subs[0].CreatedAt, first.CreatedAt)
+		}
+	})
+}
+
+func TestSQLStoreKeepsWebhooksAcrossRestarts(t *testing.T) {
+	ctx := context.Background()
+	rec := newReceiver(t, 0, 0)
+	dsn := filepath.Join(t.TempDir(), "webhooks.db")
+	store, err := OpenSQLStore(ctx, "sqlite", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open SQLite store: %v", err)
+	}
+	if _, err := NewDispatcher(WithStore(store)).Subscribe(ctx, "did:plc:alice", rec.URL, testSecret, []string{EventMove}); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	store.Close()
+
+	// Another replica, or this one after a restart, delivers to the webhook
+	store, err = OpenSQLStore(ctx, "sqlite", dsn)
+	if err != nil {
+		t.Fatalf("Failed to reopen SQLite store: %v", err)
+	}
+	defer store.Close()
+	d := NewDispatcher(WithStore(store), WithHTTPClient(rec.Client()), WithRetries(1, time.Millisecond))
+	d.Dispatch(ctx, Event{Type: EventMove, Players: []string{"did:plc:alice"}})
+	d.Wait()
+	if len(rec.deliveries) != 1 || !Verify(testSecret, rec.bodies[0], rec.deliveries[0].Header.Get(SignatureHeader)) {
+		t.Errorf("Expected one signed delivery from the reopened store, got %d", len(rec.deliveries))
+	}
+}
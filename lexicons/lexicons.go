@@ -0,0 +1,10 @@
+// Package lexicons embeds the app.atchess lexicon documents so they can be
+// validated against at runtime
+package lexicons
+
+import "embed"
+
+// FS holds every lexicon document, named after its NSID
+//
+//go:embed *.json
+var FS embed.FS
@@ -0,0 +1,83 @@
+#!/bin/bash
+
+# Benchmarks for the move pipeline, game replay and WebSocket fan-out.
+#
+#   ./scripts/bench.sh           run and compare against the saved baseline
+#   ./scripts/bench.sh baseline  run and save the results as the new baseline
+#
+# A benchmark more than BENCH_THRESHOLD percent (default 20) slower than its
+# baseline fails the comparison. Baselines are machine-specific, so save one
+# on the machine you compare on, e.g. before starting a change.
+
+set -eo pipefail
+
+cd "$(dirname "$0")/.."
+
+BASELINE=bench/baseline.txt
+CURRENT=bench/current.txt
+THRESHOLD=${BENCH_THRESHOLD:-20}
+PACKAGES="./internal/chess/ ./internal/web/"
+
+mkdir -p bench
+
+echo "⏱️  Running benchmarks..."
+go test -run '^$' -bench . -benchmem -benchtime "${BENCH_TIME:-1s}" -count "${BENCH_COUNT:-5}" $PACKAGES | tee "$CURRENT"
+
+if [ "$1" = "baseline" ]; then
+    cp "$CURRENT" "$BASELINE"
+    echo "✅ Saved baseline to $BASELINE"
+    exit 0
+fi
+
+if [ ! -f "$BASELINE" ]; then
+    echo "❌ No baseline found. Save one first:"
+    echo "   ./scripts/bench.sh baseline"
+    exit 1
+fi
+
+# benchstat gives a fuller statistical comparison when it's installed
+if command -v benchstat >/dev/null 2>&1; then
+    benchstat "$BASELINE" "$CURRENT"
+fi
+
+echo ""
+echo "📊 Mean ns/op against baseline (threshold ${THRESHOLD}%)"
+awk -v threshold="$THRESHOLD" '
+    # Collect the mean ns/op per benchmark in each file
+    /^Benchmark/ {
+        for (i = 3; i <= NF; i++) {
+            if ($(i) == "ns/op") {
+                name = $1
+                sub(/-[0-9]+$/, "", name)
+                sum[FILENAME, name] += $(i-1)
+                runs[FILENAME, name]++
+                names[name] = 1
+            }
+        }
+    }
+    END {
+        regressions = 0
+        for (name in names) {
+            if (!((base, name) in runs) || !((curr, name) in runs)) {
+                continue
+            }
+            old = sum[base, name] / runs[base, name]
+            new = sum[curr, name] / runs[curr, name]
+            change = (new - old) / old * 100
+            flag = ""
+            if (change > threshold) {
+                flag = "  ❌ REGRESSION"
+                regressions++
+            }
+            printf "%-50s %14.0f %14.0f %+8.1f%%%s\n", name, old, new, change, flag
+        }
+        exit regressions > 0
+    }
+' base="$BASELINE" "$BASELINE" curr="$CURRENT" "$CURRENT" && status=0 || status=$?
+
+if [ $status -ne 0 ]; then
+    echo "❌ Performance regressed by more than ${THRESHOLD}%"
+    exit 1
+fi
+
+echo "✅ No regressions"
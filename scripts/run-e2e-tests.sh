@@ -55,7 +55,7 @@ echo "🚀 Running end-to-end tests..."
 echo ""
 
 # Run with verbose output and race detection
-go test -v -race -timeout 60s ./test/e2e/...
+go test -v -race -timeout 120s ./test/e2e/... -target=live
 
 echo ""
 echo "🎉 All end-to-end tests completed successfully!"
@@ -64,6 +64,8 @@ echo "📋 Test Summary:"
 echo "   ✅ Fool's mate (white wins): e4 e5 Qh5 Ke7 Qxe5#"
 echo "   ✅ Scholar's mate variant (black wins): g4 e5 f4 Qh4#"
 echo "   ✅ REST API endpoints tested"
+echo "   ✅ Scenarios: challenges, draw offers, resignations, time victories,"
+echo "      reconnection mid-game and firehose-driven updates"
 echo "   ✅ AT Protocol integration verified"
 echo ""
 echo "💡 Next steps:"
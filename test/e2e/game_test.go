@@ -4,25 +4,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
+	"os"
 	"testing"
 
 	"github.com/justinabrahms/atchess/internal/atproto"
 	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/web"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-const (
-	pdsURL = "http://localhost:3000"
-	protocolURL = "http://localhost:8080"
-	player1Handle = "player1.test"
-	player1Pass = "player1pass"
-	player2Handle = "player2.test"
-	player2Pass = "player2pass"
-)
-
 // TestFoolsMate tests the classic fool's mate in 4 moves: e4 e5 Qh5 Ke7 Qxe5#
 func TestFoolsMate(t *testing.T) {
 	// Create clients for both players
@@ -188,8 +182,16 @@ func TestAPIEndpoints(t *testing.T) {
 	}
 	
 	reqBody, _ := json.Marshal(createGameReq)
+
+	// Creating a game acts for the caller, so it needs a session
 	resp, err = http.Post(protocolURL + "/api/games", "application/json", bytes.NewBuffer(reqBody))
 	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	token := liveSession(t, player1Handle, player1Pass)
+	resp, err = postWithSession(protocolURL + "/api/games", token, reqBody)
+	require.NoError(t, err)
 	defer resp.Body.Close()
 	
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
@@ -217,7 +219,7 @@ func TestAPIEndpoints(t *testing.T) {
 	// Try a simpler approach - just use a placeholder ID in URL for now
 	moveURL := fmt.Sprintf("%s/api/games/test-game/moves", protocolURL)
 	t.Logf("Move URL: %s", moveURL)
-	resp, err = http.Post(moveURL, "application/json", bytes.NewBuffer(reqBody))
+	resp, err = postWithSession(moveURL, token, reqBody)
 	require.NoError(t, err)
 	defer resp.Body.Close()
 	
@@ -245,9 +247,35 @@ func TestAPIEndpoints(t *testing.T) {
 	}
 }
 
+// postWithSession posts a JSON body to the protocol service as a signed in player
+func postWithSession(url, token string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(web.SessionHeader, token)
+	return http.DefaultClient.Do(req)
+}
+
 // TestMain sets up the test environment
 func TestMain(m *testing.M) {
-	// Note: This assumes PDS and protocol service are already running
-	// In a real CI environment, you'd start them here
-	m.Run()
-}
\ No newline at end of file
+	flag.Parse()
+	
+	// With -target=live the PDS and protocol service must already be running
+	tearDown, err := setUpTarget()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *target == "live" {
+		if err := targetIsUp(); err != nil {
+			fmt.Fprintf(os.Stderr, "PDS or protocol service is not running: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	
+	code := m.Run()
+	tearDown()
+	os.Exit(code)
+}
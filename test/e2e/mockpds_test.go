@@ -0,0 +1,625 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+)
+
+// mockAccount is an account on the mock PDS
+type mockAccount struct {
+	did      string
+	handle   string
+	password string
+}
+
+// mockRecord is a stored record and the CID of its DAG-CBOR encoding
+type mockRecord struct {
+	cid   cid.Cid
+	block []byte
+	value map[string]interface{}
+}
+
+// mockOp is one record change in a commit
+type mockOp struct {
+	action string
+	path   string
+	record *mockRecord // nil for deletes
+}
+
+// mockCommit is a repository commit as published on subscribeRepos
+type mockCommit struct {
+	seq  int64
+	repo string
+	time time.Time
+	ops  []mockOp
+}
+
+// mockPDS is an in-memory PDS for several accounts. Like a real PDS it only
+// lets an account write to its own repository, checks swapCid, lists records
+// newest first and publishes every commit on com.atproto.sync.subscribeRepos.
+//
+// In chaos mode it adds random latency to every request and drops firehose
+// subscribers every few frames, so clients have to resume from their cursor.
+type mockPDS struct {
+	*httptest.Server
+	chaos bool
+
+	mu       sync.Mutex
+	accounts map[string]*mockAccount // by handle and by DID
+	tokens   map[string]string       // access token -> DID
+	records  map[string]*mockRecord  // at:// URI -> record
+	nextKey  int
+	commits  []*mockCommit
+	changed  chan struct{} // closed and replaced on every commit
+	rng      *rand.Rand
+}
+
+func newMockPDS(chaos bool) *mockPDS {
+	pds := &mockPDS{
+		chaos:    chaos,
+		accounts: make(map[string]*mockAccount),
+		tokens:   make(map[string]string),
+		records:  make(map[string]*mockRecord),
+		changed:  make(chan struct{}),
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	pds.Server = httptest.NewServer(http.HandlerFunc(pds.serve))
+	return pds
+}
+
+// addAccount creates an account that can log in with handle and password
+func (p *mockPDS) addAccount(did, handle, password string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	account := &mockAccount{did: did, handle: handle, password: password}
+	p.accounts[did] = account
+	p.accounts[handle] = account
+}
+
+// firehoseURL is the subscribeRepos endpoint
+func (p *mockPDS) firehoseURL() string {
+	return "ws" + strings.TrimPrefix(p.URL, "http") + "/xrpc/com.atproto.sync.subscribeRepos"
+}
+
+// jitter sleeps for up to max in chaos mode
+func (p *mockPDS) jitter(max time.Duration) {
+	if !p.chaos {
+		return
+	}
+	p.mu.Lock()
+	d := time.Duration(p.rng.Int63n(int64(max)))
+	p.mu.Unlock()
+	time.Sleep(d)
+}
+
+func writeXRPCError(w http.ResponseWriter, status int, name, message string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": name, "message": message})
+}
+
+// authorize returns the DID of the request's access token
+func (p *mockPDS) authorize(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(strings.TrimPrefix(auth, "Bearer "), "DPoP ")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	did, ok := p.tokens[token]
+	return did, ok
+}
+
+func (p *mockPDS) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/xrpc/com.atproto.sync.subscribeRepos" {
+		p.subscribeRepos(w, r)
+		return
+	}
+
+	p.jitter(15 * time.Millisecond)
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+
+	switch r.URL.Path {
+	case "/xrpc/com.atproto.server.describeServer":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"availableUserDomains": []string{".test"}})
+
+	case "/xrpc/com.atproto.server.createSession":
+		var req struct {
+			Identifier string `json:"identifier"`
+			Password   string `json:"password"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		p.mu.Lock()
+		account := p.accounts[req.Identifier]
+		if account == nil || account.password != req.Password {
+			p.mu.Unlock()
+			writeXRPCError(w, http.StatusUnauthorized, "AuthenticationRequired", "Invalid identifier or password")
+			return
+		}
+		token := fmt.Sprintf("jwt-%s-%d", account.did, len(p.tokens))
+		p.tokens[token] = account.did
+		p.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"accessJwt":  token,
+			"refreshJwt": token,
+			"did":        account.did,
+			"handle":     account.handle,
+		})
+
+	case "/xrpc/com.atproto.identity.resolveHandle":
+		p.mu.Lock()
+		account := p.accounts[q.Get("handle")]
+		p.mu.Unlock()
+		if account == nil {
+			writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", "Unable to resolve handle")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"did": account.did})
+
+	case "/xrpc/com.atproto.repo.getRecord":
+		uri := fmt.Sprintf("at://%s/%s/%s", q.Get("repo"), q.Get("collection"), q.Get("rkey"))
+		p.mu.Lock()
+		record := p.records[uri]
+		p.mu.Unlock()
+		if record == nil {
+			writeXRPCError(w, http.StatusBadRequest, "RecordNotFound", "Could not locate record: "+uri)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"uri": uri, "cid": record.cid.String(), "value": record.value})
+
+	case "/xrpc/com.atproto.repo.listRecords":
+		p.listRecords(w, q.Get("repo"), q.Get("collection"), q.Get("cursor"), q.Get("limit"))
+
+	case "/xrpc/com.atproto.repo.createRecord", "/xrpc/com.atproto.repo.putRecord":
+		var req struct {
+			Repo       string                 `json:"repo"`
+			Collection string                 `json:"collection"`
+			Rkey       string                 `json:"rkey"`
+			Record     map[string]interface{} `json:"record"`
+			SwapCID    *string                `json:"swapCid"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", err.Error())
+			return
+		}
+		if !p.ownsRepo(w, r, req.Repo) {
+			return
+		}
+		create := r.URL.Path == "/xrpc/com.atproto.repo.createRecord"
+		uri, record, status, err := p.write(req.Repo, mockWrite{
+			create:     create,
+			collection: req.Collection,
+			rkey:       req.Rkey,
+			value:      req.Record,
+			swapCID:    req.SwapCID,
+		})
+		if err != nil {
+			writeXRPCError(w, status, http.StatusText(status), err.Error())
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"uri": uri, "cid": record.cid.String()})
+
+	case "/xrpc/com.atproto.repo.deleteRecord":
+		var req struct {
+			Repo       string `json:"repo"`
+			Collection string `json:"collection"`
+			Rkey       string `json:"rkey"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !p.ownsRepo(w, r, req.Repo) {
+			return
+		}
+		_, _, _, _ = p.write(req.Repo, mockWrite{del: true, collection: req.Collection, rkey: req.Rkey})
+		_ = json.NewEncoder(w).Encode(map[string]string{})
+
+	case "/xrpc/com.atproto.repo.applyWrites":
+		var req struct {
+			Repo   string `json:"repo"`
+			Writes []struct {
+				Type       string                 `json:"$type"`
+				Collection string                 `json:"collection"`
+				Rkey       string                 `json:"rkey"`
+				Value      map[string]interface{} `json:"value"`
+			} `json:"writes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", err.Error())
+			return
+		}
+		if !p.ownsRepo(w, r, req.Repo) {
+			return
+		}
+		writes := make([]mockWrite, 0, len(req.Writes))
+		for _, write := range req.Writes {
+			op := mockWrite{collection: write.Collection, rkey: write.Rkey, value: write.Value}
+			switch write.Type {
+			case "com.atproto.repo.applyWrites#create":
+				op.create = true
+				if op.rkey == "" {
+					op.rkey = p.newRkey()
+				}
+			case "com.atproto.repo.applyWrites#delete":
+				op.del = true
+			}
+			writes = append(writes, op)
+		}
+		if status, err := p.commit(req.Repo, writes); err != nil {
+			writeXRPCError(w, status, http.StatusText(status), err.Error())
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": []interface{}{}})
+
+	default:
+		writeXRPCError(w, http.StatusNotImplemented, "MethodNotImplemented", r.URL.Path)
+	}
+}
+
+// ownsRepo rejects writes to a repository other than the caller's own
+func (p *mockPDS) ownsRepo(w http.ResponseWriter, r *http.Request, repo string) bool {
+	did, ok := p.authorize(r)
+	if !ok {
+		writeXRPCError(w, http.StatusUnauthorized, "AuthenticationRequired", "Invalid access token")
+		return false
+	}
+	if repo != did {
+		writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", fmt.Sprintf("%s cannot write to %s's repository", did, repo))
+		return false
+	}
+	return true
+}
+
+func (p *mockPDS) listRecords(w http.ResponseWriter, repo, collection, cursor, limitParam string) {
+	prefix := fmt.Sprintf("at://%s/%s/", repo, collection)
+
+	p.mu.Lock()
+	var rkeys []string
+	for uri := range p.records {
+		if strings.HasPrefix(uri, prefix) {
+			rkeys = append(rkeys, strings.TrimPrefix(uri, prefix))
+		}
+	}
+	// Newest first, as rkeys sort by creation time
+	sort.Sort(sort.Reverse(sort.StringSlice(rkeys)))
+
+	limit := 50
+	if n, err := strconv.Atoi(limitParam); err == nil && n > 0 {
+		limit = n
+	}
+	var records []map[string]interface{}
+	next := ""
+	for _, rkey := range rkeys {
+		if cursor != "" && rkey >= cursor {
+			continue
+		}
+		if len(records) == limit {
+			break
+		}
+		record := p.records[prefix+rkey]
+		records = append(records, map[string]interface{}{"uri": prefix + rkey, "cid": record.cid.String(), "value": record.value})
+		next = rkey
+	}
+	p.mu.Unlock()
+
+	resp := map[string]interface{}{"records": records}
+	if len(records) == limit {
+		resp["cursor"] = next
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// mockWrite is one create, update or delete in a commit
+type mockWrite struct {
+	create     bool
+	del        bool
+	collection string
+	rkey       string
+	value      map[string]interface{}
+	swapCID    *string
+}
+
+// newRkey returns a record key that sorts after every earlier one, like a TID
+func (p *mockPDS) newRkey() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nextKey++
+	return fmt.Sprintf("3m%011d", p.nextKey)
+}
+
+// write commits a single write, returning the record's URI
+func (p *mockPDS) write(repo string, write mockWrite) (string, *mockRecord, int, error) {
+	if write.rkey == "" {
+		write.rkey = p.newRkey()
+	}
+	status, err := p.commit(repo, []mockWrite{write})
+	if err != nil {
+		return "", nil, status, err
+	}
+	uri := fmt.Sprintf("at://%s/%s/%s", repo, write.collection, write.rkey)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return uri, p.records[uri], http.StatusOK, nil
+}
+
+// commit applies writes to a repository atomically and publishes them
+func (p *mockPDS) commit(repo string, writes []mockWrite) (int, error) {
+	var ops []mockOp
+	for _, write := range writes {
+		if write.del {
+			ops = append(ops, mockOp{action: "delete", path: write.collection + "/" + write.rkey})
+			continue
+		}
+		record, err := encodeRecord(write.value)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		ops = append(ops, mockOp{path: write.collection + "/" + write.rkey, record: record})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, write := range writes {
+		uri := fmt.Sprintf("at://%s/%s", repo, ops[i].path)
+		existing := p.records[uri]
+		if write.create && existing != nil {
+			return http.StatusBadRequest, fmt.Errorf("record already exists: %s", uri)
+		}
+		if write.swapCID != nil && (existing == nil || existing.cid.String() != *write.swapCID) {
+			return http.StatusBadRequest, fmt.Errorf("record was at a different CID: %s", uri)
+		}
+		if ops[i].action == "" {
+			ops[i].action = "create"
+			if existing != nil {
+				ops[i].action = "update"
+			}
+		}
+	}
+
+	for i := range writes {
+		uri := fmt.Sprintf("at://%s/%s", repo, ops[i].path)
+		if ops[i].record == nil {
+			delete(p.records, uri)
+		} else {
+			p.records[uri] = ops[i].record
+		}
+	}
+
+	p.commits = append(p.commits, &mockCommit{
+		seq:  int64(len(p.commits) + 1),
+		repo: repo,
+		time: time.Now().UTC(),
+		ops:  ops,
+	})
+	close(p.changed)
+	p.changed = make(chan struct{})
+	return http.StatusOK, nil
+}
+
+// commitsAfter returns the commits after a sequence number, and a channel
+// closed on the next commit
+func (p *mockPDS) commitsAfter(seq int64) ([]*mockCommit, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if seq >= int64(len(p.commits)) {
+		return nil, p.changed
+	}
+	return p.commits[seq:], p.changed
+}
+
+var firehoseUpgrader = websocket.Upgrader{}
+
+// subscribeRepos streams commits as DAG-CBOR frames, starting after the
+// cursor if one is given, otherwise with new commits only
+func (p *mockPDS) subscribeRepos(w http.ResponseWriter, r *http.Request) {
+	conn, err := firehoseUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Notice the subscriber going away
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	p.mu.Lock()
+	seq := int64(len(p.commits))
+	dropAfter := 3 + p.rng.Intn(3)
+	p.mu.Unlock()
+	if cursor, err := strconv.ParseInt(r.URL.Query().Get("cursor"), 10, 64); err == nil && cursor <= seq {
+		seq = cursor
+	}
+
+	sent := 0
+	for {
+		commits, changed := p.commitsAfter(seq)
+		for _, commit := range commits {
+			frame, err := commitFrame(commit)
+			if err != nil {
+				return
+			}
+			p.jitter(10 * time.Millisecond)
+			if err := conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+				return
+			}
+			seq = commit.seq
+			sent++
+			if p.chaos && sent >= dropAfter {
+				return
+			}
+		}
+		select {
+		case <-changed:
+		case <-done:
+			return
+		}
+	}
+}
+
+// commitFrame encodes a commit as a subscribeRepos #commit frame, with the
+// changed records in a CAR file
+func commitFrame(commit *mockCommit) ([]byte, error) {
+	var blocks bytes.Buffer
+	var roots []cid.Cid
+	for _, op := range commit.ops {
+		if op.record != nil {
+			roots = append(roots, op.record.cid)
+		}
+	}
+	if len(roots) == 0 {
+		// A CAR file needs a root; deletes carry no record blocks
+		_, empty, err := encodeBlock(basicnode.NewString(""))
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, empty)
+	}
+	if err := car.WriteHeader(&car.CarHeader{Roots: roots, Version: 1}, &blocks); err != nil {
+		return nil, err
+	}
+	for _, op := range commit.ops {
+		if op.record != nil {
+			if err := carutil.LdWrite(&blocks, op.record.cid.Bytes(), op.record.block); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	header, err := qp.BuildMap(basicnode.Prototype.Any, 2, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "op", qp.Int(1))
+		qp.MapEntry(ma, "t", qp.String("#commit"))
+	})
+	if err != nil {
+		return nil, err
+	}
+	body, err := qp.BuildMap(basicnode.Prototype.Any, -1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "seq", qp.Int(commit.seq))
+		qp.MapEntry(ma, "repo", qp.String(commit.repo))
+		qp.MapEntry(ma, "time", qp.String(commit.time.Format(time.RFC3339)))
+		qp.MapEntry(ma, "tooBig", qp.Bool(false))
+		qp.MapEntry(ma, "blocks", qp.Bytes(blocks.Bytes()))
+		qp.MapEntry(ma, "ops", qp.List(-1, func(la datamodel.ListAssembler) {
+			for _, op := range commit.ops {
+				op := op
+				qp.ListEntry(la, qp.Map(-1, func(ma datamodel.MapAssembler) {
+					qp.MapEntry(ma, "action", qp.String(op.action))
+					qp.MapEntry(ma, "path", qp.String(op.path))
+					if op.record != nil {
+						qp.MapEntry(ma, "cid", qp.Link(cidlink.Link{Cid: op.record.cid}))
+					} else {
+						qp.MapEntry(ma, "cid", qp.Null())
+					}
+				}))
+			}
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var frame bytes.Buffer
+	if err := dagcbor.Encode(header, &frame); err != nil {
+		return nil, err
+	}
+	if err := dagcbor.Encode(body, &frame); err != nil {
+		return nil, err
+	}
+	return frame.Bytes(), nil
+}
+
+// encodeRecord stores a JSON record value as a DAG-CBOR block
+func encodeRecord(value map[string]interface{}) (*mockRecord, error) {
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := assemble(nb, value); err != nil {
+		return nil, err
+	}
+	block, c, err := encodeBlock(nb.Build())
+	if err != nil {
+		return nil, err
+	}
+	return &mockRecord{cid: c, block: block, value: value}, nil
+}
+
+func encodeBlock(node datamodel.Node) ([]byte, cid.Cid, error) {
+	var buf bytes.Buffer
+	if err := dagcbor.Encode(node, &buf); err != nil {
+		return nil, cid.Undef, err
+	}
+	// 0x12 is the sha2-256 multihash code
+	c, err := cid.Prefix{Version: 1, Codec: cid.DagCBOR, MhType: 0x12, MhLength: -1}.Sum(buf.Bytes())
+	return buf.Bytes(), c, err
+}
+
+// assemble builds an IPLD node from a decoded JSON value. Whole numbers
+// become integers, as records have no floats.
+func assemble(na datamodel.NodeAssembler, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return na.AssignNull()
+	case bool:
+		return na.AssignBool(v)
+	case string:
+		return na.AssignString(v)
+	case float64:
+		if v == math.Trunc(v) {
+			return na.AssignInt(int64(v))
+		}
+		return na.AssignFloat(v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		ma, err := na.BeginMap(int64(len(v)))
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := ma.AssembleKey().AssignString(key); err != nil {
+				return err
+			}
+			if err := assemble(ma.AssembleValue(), v[key]); err != nil {
+				return err
+			}
+		}
+		return ma.Finish()
+	case []interface{}:
+		la, err := na.BeginList(int64(len(v)))
+		if err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := assemble(la.AssembleValue(), item); err != nil {
+				return err
+			}
+		}
+		return la.Finish()
+	default:
+		return fmt.Errorf("unsupported record value %T", value)
+	}
+}
@@ -0,0 +1,431 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/chess"
+	"github.com/justinabrahms/atchess/internal/firehose"
+	"github.com/justinabrahms/atchess/internal/index"
+	"github.com/justinabrahms/atchess/internal/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Scenarios play out multi-player flows with two clients, player1 and
+// player2, each writing only to their own repository as on a real PDS.
+// Everything that crosses the network is awaited with waitFor rather than
+// fixed sleeps, so they hold up under -chaos.
+
+const scenarioTimeout = 15 * time.Second
+
+// loginPlayers creates AT Protocol clients for both test accounts
+func loginPlayers(t *testing.T) (*atproto.Client, *atproto.Client) {
+	t.Helper()
+	player1, err := atproto.NewClient(pdsURL, player1Handle, player1Pass)
+	require.NoError(t, err)
+	player2, err := atproto.NewClient(pdsURL, player2Handle, player2Pass)
+	require.NoError(t, err)
+	return player1, player2
+}
+
+// play records a move by client in the game, returning the new position.
+// When the mover doesn't own the game record, the owner applies the move.
+func play(t *testing.T, mover, owner *atproto.Client, gameID, fen, from, to string) *chess.MoveResult {
+	t.Helper()
+	engine, err := chess.NewEngineFromFEN(fen)
+	require.NoError(t, err)
+	move, err := engine.MakeMove(from, to, chess.ParsePromotion(""))
+	require.NoError(t, err)
+
+	require.NoError(t, mover.RecordMove(context.Background(), gameID, move))
+	if owner != mover {
+		require.NoError(t, owner.ApplyOpponentMove(context.Background(), gameID, move))
+	}
+	return move
+}
+
+// gameStatus waits for a game record to reach a status
+func gameStatus(t *testing.T, client *atproto.Client, gameID string, want chess.GameStatus) {
+	t.Helper()
+	err := waitFor(context.Background(), scenarioTimeout, func() error {
+		game, err := client.GetGame(context.Background(), gameID)
+		if err != nil {
+			return err
+		}
+		if game.Status != want {
+			return fmt.Errorf("game %s is %s, want %s", gameID, game.Status, want)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// rewriteRecord changes one of the account's own records with raw XRPC
+// calls, for setting up state the clients never write, such as old timestamps
+func rewriteRecord(t *testing.T, handle, password, uri string, change func(value map[string]interface{})) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"identifier": handle, "password": password})
+	resp, err := http.Post(pdsURL+"/xrpc/com.atproto.server.createSession", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	var session struct {
+		AccessJwt string `json:"accessJwt"`
+		Did       string `json:"did"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&session))
+	resp.Body.Close()
+
+	parts := strings.Split(strings.TrimPrefix(uri, "at://"), "/")
+	require.Len(t, parts, 3, "invalid record URI %s", uri)
+	require.Equal(t, session.Did, parts[0], "can only rewrite the account's own records")
+
+	query := url.Values{"repo": {parts[0]}, "collection": {parts[1]}, "rkey": {parts[2]}}
+	resp, err = http.Get(pdsURL + "/xrpc/com.atproto.repo.getRecord?" + query.Encode())
+	require.NoError(t, err)
+	var record struct {
+		CID   string                 `json:"cid"`
+		Value map[string]interface{} `json:"value"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&record))
+	resp.Body.Close()
+
+	change(record.Value)
+	body, _ = json.Marshal(map[string]interface{}{
+		"repo":       parts[0],
+		"collection": parts[1],
+		"rkey":       parts[2],
+		"record":     record.Value,
+		"swapCid":    record.CID,
+	})
+	req, _ := http.NewRequest("POST", pdsURL+"/xrpc/com.atproto.repo.putRecord", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+session.AccessJwt)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "failed to rewrite %s", uri)
+}
+
+func TestScenarioChallengeAccepted(t *testing.T) {
+	ctx := context.Background()
+	player1, player2 := loginPlayers(t)
+
+	challenge, err := player1.CreateChallenge(ctx, player2.GetDID(), "white", "Fancy a game?")
+	require.NoError(t, err)
+	assert.Equal(t, "pending", challenge.Status)
+
+	// The challenged player creates the game in their own repository
+	game, err := player2.AcceptChallenge(ctx, challenge.ID, "Good luck!")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(game.ID, "at://"+player2.GetDID()+"/"), "expected the accepter to own the game, got %s", game.ID)
+	assert.Equal(t, player1.GetDID(), game.White)
+	assert.Equal(t, player2.GetDID(), game.Black)
+
+	// Play 1. e4 e5 across both repositories
+	move := play(t, player1, player2, game.ID, game.FEN, "e2", "e4")
+	move = play(t, player2, player2, game.ID, move.FEN, "e7", "e5")
+
+	err = waitFor(ctx, scenarioTimeout, func() error {
+		current, err := player1.GetGame(ctx, game.ID)
+		if err != nil {
+			return err
+		}
+		if current.FEN != move.FEN {
+			return fmt.Errorf("game is at %s, want %s", current.FEN, move.FEN)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	moves, err := player1.GetMoves(ctx, game.ID)
+	require.NoError(t, err)
+	require.Len(t, moves, 2)
+	assert.Equal(t, "e4", moves[0].SAN)
+	assert.Equal(t, "e5", moves[1].SAN)
+}
+
+func TestScenarioDrawOfferSupersededByResignation(t *testing.T) {
+	ctx := context.Background()
+	player1, player2 := loginPlayers(t)
+
+	game, err := player1.CreateGame(ctx, player2.GetDID(), "white")
+	require.NoError(t, err)
+	play(t, player1, player1, game.ID, game.FEN, "e2", "e4")
+
+	offer, err := player2.OfferDraw(ctx, game.ID, "Shall we call it a day?")
+	require.NoError(t, err)
+	offers, err := player2.GetDrawOffers(ctx, game.ID)
+	require.NoError(t, err)
+	require.Len(t, offers, 1)
+	assert.Equal(t, offer.URI, offers[0].URI)
+
+	// Rather than answer, white resigns, which makes the offer moot
+	require.NoError(t, player1.ResignGame(ctx, game.ID, "Changed my mind"))
+	gameStatus(t, player2, game.ID, chess.StatusBlackWon)
+
+	offers, err = player2.GetDrawOffers(ctx, game.ID)
+	require.NoError(t, err)
+	assert.Empty(t, offers, "an offer in a finished game should no longer be pending")
+
+	// The offerer's sweeper expires the offer in their own repository
+	expired, err := player2.ExpireSupersededDrawOffers(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, expired, 1)
+}
+
+func TestScenarioResignation(t *testing.T) {
+	ctx := context.Background()
+	player1, player2 := loginPlayers(t)
+
+	game, err := player1.CreateGame(ctx, player2.GetDID(), "black")
+	require.NoError(t, err)
+	assert.Equal(t, player2.GetDID(), game.White)
+
+	move := play(t, player2, player1, game.ID, game.FEN, "f2", "f3")
+	play(t, player1, player1, game.ID, move.FEN, "e7", "e5")
+
+	require.NoError(t, player1.ResignGame(ctx, game.ID, ""))
+	gameStatus(t, player2, game.ID, chess.StatusWhiteWon)
+
+	// Neither player can resign a finished game
+	assert.Error(t, player1.ResignGame(ctx, game.ID, ""))
+	assert.Error(t, player2.ResignGame(ctx, game.ID, ""))
+}
+
+func TestScenarioTimeVictory(t *testing.T) {
+	ctx := context.Background()
+	player1, player2 := loginPlayers(t)
+
+	// Player1 owns the game and plays black, so player2 is on the move
+	game, err := player1.CreateGame(ctx, player2.GetDID(), "black")
+	require.NoError(t, err)
+
+	violated, _, err := player1.CheckTimeViolation(ctx, game.ID)
+	require.NoError(t, err)
+	assert.False(t, violated, "a new game can't be lost on time")
+	assert.Error(t, player1.ClaimTimeVictory(ctx, game.ID))
+
+	// Correspondence games allow three days a move by default
+	rewriteRecord(t, player1Handle, player1Pass, game.ID, func(value map[string]interface{}) {
+		value["createdAt"] = time.Now().Add(-4 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	})
+
+	violated, violation, err := player1.CheckTimeViolation(ctx, game.ID)
+	require.NoError(t, err)
+	require.True(t, violated)
+	assert.Equal(t, player2.GetDID(), violation.ViolatingPlayer)
+
+	require.NoError(t, player1.ClaimTimeVictory(ctx, game.ID))
+	gameStatus(t, player2, game.ID, chess.StatusBlackWon)
+}
+
+// liveSession signs a player in to the protocol service
+func liveSession(t *testing.T, handle, password string) string {
+	t.Helper()
+	body, _ := json.Marshal(web.AuthRequest{Handle: handle, Password: password})
+	resp, err := http.Post(protocolURL+"/api/auth/login", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "login failed")
+	var auth web.AuthResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&auth))
+	return auth.AccessToken
+}
+
+// gameSocket is a player's WebSocket connection to a game
+type gameSocket struct {
+	conn    *websocket.Conn
+	pending []web.GameUpdate
+}
+
+// joinGame connects to a game's updates and waits for the protocol service
+// to register the connection
+func joinGame(t *testing.T, token, gameID string) *gameSocket {
+	t.Helper()
+	wsURL := "ws" + strings.TrimPrefix(protocolURL, "http") + "/api/ws?" +
+		url.Values{"gameId": {gameID}, "session": {token}}.Encode()
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+
+	// The hub answers pings only once the connection has joined the game
+	socket := &gameSocket{conn: conn}
+	require.NoError(t, conn.WriteJSON(map[string]string{"type": "ping"}))
+	socket.expect(t, "pong")
+	return socket
+}
+
+// expect reads updates until one of the given type arrives
+func (s *gameSocket) expect(t *testing.T, updateType string) web.GameUpdate {
+	t.Helper()
+	deadline := time.Now().Add(scenarioTimeout)
+	for {
+		for i, update := range s.pending {
+			if update.Type == updateType {
+				s.pending = s.pending[i+1:]
+				return update
+			}
+		}
+		s.pending = nil
+
+		require.NoError(t, s.conn.SetReadDeadline(deadline))
+		_, data, err := s.conn.ReadMessage()
+		require.NoError(t, err, "waiting for %s", updateType)
+		// Queued updates are batched into one frame, a line each
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			var update web.GameUpdate
+			if err := json.Unmarshal(line, &update); err == nil {
+				s.pending = append(s.pending, update)
+			}
+		}
+	}
+}
+
+func TestScenarioReconnectMidGame(t *testing.T) {
+	ctx := context.Background()
+	player1, player2 := loginPlayers(t)
+	token1 := liveSession(t, player1Handle, player1Pass)
+	token2 := liveSession(t, player2Handle, player2Pass)
+
+	game, err := player1.CreateGame(ctx, player2.GetDID(), "white")
+	require.NoError(t, err)
+	move := play(t, player1, player1, game.ID, game.FEN, "d2", "d4")
+
+	white := joinGame(t, token1, game.ID)
+	black := joinGame(t, token2, game.ID)
+	defer black.conn.Close()
+
+	// White's connection drops mid-game; black is told and the clock waits
+	require.NoError(t, white.conn.Close())
+	update := black.expect(t, "player_disconnected")
+	data, _ := update.Data.(map[string]interface{})
+	assert.Equal(t, player1.GetDID(), data["playerDid"])
+	if *target == "mock" {
+		assert.Equal(t, true, data["clockPaused"])
+		assert.Equal(t, float64(reconnectGrace/time.Second), data["graceSeconds"])
+	}
+
+	// White comes back within the grace period and the game carries on
+	white = joinGame(t, token1, game.ID)
+	defer white.conn.Close()
+	update = black.expect(t, "player_reconnected")
+	data, _ = update.Data.(map[string]interface{})
+	assert.Equal(t, player1.GetDID(), data["playerDid"])
+
+	play(t, player2, player1, game.ID, move.FEN, "d7", "d5")
+	gameStatus(t, player2, game.ID, chess.StatusActive)
+}
+
+// firehoseWatcher follows the PDS firehose into a game index, keeping the
+// events for one game
+type firehoseWatcher struct {
+	client   *firehose.Client
+	indexer  *index.Indexer
+	events   chan firehose.Event
+	finished chan *index.Game
+}
+
+func watchFirehose(t *testing.T) *firehoseWatcher {
+	t.Helper()
+	watcher := &firehoseWatcher{
+		indexer:  index.NewIndexer(index.NewMemoryStore()),
+		events:   make(chan firehose.Event, 100),
+		finished: make(chan *index.Game, 10),
+	}
+	watcher.indexer.OnGameFinished(func(ctx context.Context, game *index.Game) {
+		watcher.finished <- game
+	})
+	watcher.client = firehose.NewClient(
+		firehose.WithIndexer(watcher.indexer, func(event firehose.Event) error {
+			watcher.events <- event
+			return nil
+		}),
+		firehose.WithURL(firehoseURL),
+		firehose.WithInitialReconnectDelay(50*time.Millisecond),
+	)
+	require.NoError(t, watcher.client.Start())
+	t.Cleanup(func() { _ = watcher.client.Stop() })
+
+	err := waitFor(context.Background(), scenarioTimeout, func() error {
+		if !watcher.client.IsConnected() {
+			return fmt.Errorf("firehose is not connected")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return watcher
+}
+
+// expect waits for an event of the given type about the game, skipping others
+func (w *firehoseWatcher) expect(t *testing.T, eventType firehose.EventType, gameID string) firehose.Event {
+	t.Helper()
+	timeout := time.After(scenarioTimeout)
+	for {
+		select {
+		case event := <-w.events:
+			record, _ := event.Record.(map[string]interface{})
+			if event.Type != eventType {
+				continue
+			}
+			uri := fmt.Sprintf("at://%s/%s", event.Repo, event.Path)
+			ref, _ := record["game"].(map[string]interface{})
+			if uri == gameID || ref["uri"] == gameID {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for a %s event for %s", eventType, gameID)
+		}
+	}
+}
+
+func TestScenarioFirehoseUpdates(t *testing.T) {
+	ctx := context.Background()
+	player1, player2 := loginPlayers(t)
+	watcher := watchFirehose(t)
+
+	game, err := player1.CreateGame(ctx, player2.GetDID(), "white")
+	require.NoError(t, err)
+	created := watcher.expect(t, firehose.EventTypeGame, game.ID)
+	assert.Equal(t, player1.GetDID(), created.Repo)
+
+	// Each player's move arrives from their own repository, decoded
+	move := play(t, player1, player1, game.ID, game.FEN, "e2", "e4")
+	event := watcher.expect(t, firehose.EventTypeMove, game.ID)
+	assert.Equal(t, player1.GetDID(), event.Repo)
+	assert.Equal(t, "e4", event.Record.(map[string]interface{})["san"])
+
+	play(t, player2, player1, game.ID, move.FEN, "e7", "e5")
+	event = watcher.expect(t, firehose.EventTypeMove, game.ID)
+	assert.Equal(t, player2.GetDID(), event.Repo)
+	assert.Equal(t, "e5", event.Record.(map[string]interface{})["san"])
+
+	// White resigns, and the index learns the result from the game record
+	require.NoError(t, player1.ResignGame(ctx, game.ID, ""))
+	event = watcher.expect(t, firehose.EventTypeResignation, game.ID)
+	assert.Equal(t, player1.GetDID(), event.Record.(map[string]interface{})["resigningPlayer"])
+
+	timeout := time.After(scenarioTimeout)
+	for {
+		select {
+		case finished := <-watcher.finished:
+			if finished.URI != game.ID {
+				continue
+			}
+			assert.Equal(t, string(chess.StatusBlackWon), finished.Status)
+			moves, err := watcher.indexer.ListMoves(ctx, game.ID)
+			require.NoError(t, err)
+			assert.Len(t, moves, 2)
+			return
+		case <-timeout:
+			t.Fatalf("Timed out waiting for the index to see %s finish", game.ID)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package e2e
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinabrahms/atchess/internal/atproto"
+	"github.com/justinabrahms/atchess/internal/config"
+	"github.com/justinabrahms/atchess/internal/web"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// The suite runs against an in-process mock PDS and protocol service by
+// default. Use -target=live to run it against servers started with
+// docker-compose and make run-protocol instead:
+//
+//	go test ./test/e2e/ -target=live -pds=http://localhost:3000 -protocol=http://localhost:8080
+var (
+	target       = flag.String("target", "mock", `"mock" for an in-process PDS and protocol service, or "live" for -pds and -protocol`)
+	livePDS      = flag.String("pds", "http://localhost:3000", "PDS URL when -target=live")
+	liveProtocol = flag.String("protocol", "http://localhost:8080", "protocol service URL when -target=live")
+	chaos        = flag.Bool("chaos", false, "with -target=mock, add latency and drop firehose subscribers every few frames")
+)
+
+var (
+	pdsURL      string
+	protocolURL string
+	firehoseURL string
+)
+
+const (
+	player1Handle = "player1.test"
+	player1Pass   = "player1pass"
+	player2Handle = "player2.test"
+	player2Pass   = "player2pass"
+	serviceHandle = "protocol.test"
+	servicePass   = "protocolpass"
+
+	// reconnectGrace is the disconnect grace period of the mock protocol service
+	reconnectGrace = 30 * time.Second
+)
+
+// setUpTarget points the suite at its servers, returning a function that
+// shuts down any it started
+func setUpTarget() (func(), error) {
+	switch *target {
+	case "live":
+		pdsURL = strings.TrimSuffix(*livePDS, "/")
+		protocolURL = strings.TrimSuffix(*liveProtocol, "/")
+		firehoseURL = "ws" + strings.TrimPrefix(pdsURL, "http") + "/xrpc/com.atproto.sync.subscribeRepos"
+		return func() {}, nil
+
+	case "mock":
+		// Keep expected failures, such as cross-repository writes, out of the test output
+		log.Logger = zerolog.New(os.Stderr).Level(zerolog.Disabled)
+
+		pds := newMockPDS(*chaos)
+		pds.addAccount("did:plc:player1", player1Handle, player1Pass)
+		pds.addAccount("did:plc:player2", player2Handle, player2Pass)
+		pds.addAccount("did:plc:protocol", serviceHandle, servicePass)
+
+		protocol, err := startProtocolService(pds.URL)
+		if err != nil {
+			pds.Close()
+			return nil, err
+		}
+
+		pdsURL = pds.URL
+		protocolURL = protocol.URL
+		firehoseURL = pds.firehoseURL()
+		return func() {
+			protocol.Close()
+			pds.Close()
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown -target %q, want mock or live", *target)
+	}
+}
+
+// startProtocolService runs the protocol service's API against a PDS, with
+// the pause disconnect policy
+func startProtocolService(pdsURL string) (*httptest.Server, error) {
+	client, err := atproto.NewClient(pdsURL, serviceHandle, servicePass)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in the service account: %w", err)
+	}
+
+	cfg := &config.Config{}
+	cfg.ATProto.PDSURL = pdsURL
+	service := web.NewService(client, cfg)
+
+	hub := web.NewHub()
+	go hub.Run()
+	connections := web.NewConnectionMonitor(hub, client.GetGame, service.ForfeitDisconnected, web.DisconnectPolicyPause, reconnectGrace)
+	hub.OnPresence(connections.PlayerPresence)
+	service.SetConnectionMonitor(connections)
+	service.SetHub(hub)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(service.SessionMiddleware)
+	api.HandleFunc("/health", service.HealthHandler).Methods("GET")
+	api.HandleFunc("/auth/login", service.LoginHandler).Methods("POST")
+	// Routes acting for the caller need a session, as in the protocol service
+	signedIn := func(handler http.HandlerFunc) http.Handler {
+		return service.RequireSession(handler)
+	}
+	api.Handle("/games", signedIn(service.CreateGameHandler)).Methods("POST")
+	api.HandleFunc("/games", service.ListGamesHandler).Methods("GET")
+	api.HandleFunc("/games/{id:.*}", service.GetGameHandler).Methods("GET")
+	api.Handle("/moves", signedIn(service.MakeMoveHandler)).Methods("POST")
+	api.Handle("/challenges", signedIn(service.CreateChallengeHandler)).Methods("POST")
+	api.Handle("/challenges/accept", signedIn(service.AcceptChallengeHandler)).Methods("POST")
+	api.Handle("/draw-offers", signedIn(service.OfferDrawHandler)).Methods("POST")
+	api.Handle("/resign", signedIn(service.ResignGameHandler)).Methods("POST")
+	api.HandleFunc("/ws", service.WebSocketHandler(hub))
+
+	return httptest.NewServer(router), nil
+}
+
+// waitFor polls until check succeeds, so scenarios tolerate latency and
+// reordering between the PDS, the firehose and the protocol service
+func waitFor(ctx context.Context, timeout time.Duration, check func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(25 * time.Millisecond):
+		}
+	}
+}
+
+// targetIsUp reports whether the live servers answer, for a clearer failure
+// than connection refused
+func targetIsUp() error {
+	for _, url := range []string{pdsURL + "/xrpc/com.atproto.server.describeServer", protocolURL + "/api/health"} {
+		resp, err := http.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
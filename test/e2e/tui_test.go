@@ -0,0 +1,52 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/justinabrahms/atchess/internal/apiclient"
+	"github.com/justinabrahms/atchess/internal/tui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestScenarioTerminalClient plays a game from the terminal client as
+// player1, with player2 answering through their own repository
+func TestScenarioTerminalClient(t *testing.T) {
+	ctx := context.Background()
+	player1, player2 := loginPlayers(t)
+
+	client := apiclient.New(protocolURL + "/api")
+	session, err := client.Login(ctx, player1Handle, player1Pass)
+	require.NoError(t, err)
+	assert.Equal(t, player1.GetDID(), session.DID)
+
+	var out bytes.Buffer
+	terminal := tui.NewSession(client, &out)
+	require.NoError(t, terminal.Run(ctx, strings.NewReader("new "+player2.GetDID()+" white\nmove e4\n")))
+	game := terminal.Game()
+	require.NotNil(t, game, "expected a game to be open:\n%s", out.String())
+	assert.NotContains(t, out.String(), "error:")
+	assert.Contains(t, out.String(), "Played e4")
+
+	// player2 answers; the terminal picks the move up on refresh
+	move := play(t, player2, player1, game.ID, game.FEN, "e7", "e5")
+	out.Reset()
+	require.NoError(t, terminal.Exec(ctx, "board"))
+	assert.Equal(t, move.FEN, terminal.Game().FEN)
+	assert.Contains(t, out.String(), "your move")
+
+	require.NoError(t, terminal.Exec(ctx, "move g1f3"))
+	out.Reset()
+	require.NoError(t, terminal.Exec(ctx, "games active"))
+	assert.Contains(t, out.String(), game.ID)
+
+	moves, err := player1.GetMoves(ctx, game.ID)
+	require.NoError(t, err)
+	require.Len(t, moves, 3)
+	assert.Equal(t, "e4", moves[0].SAN)
+	assert.Equal(t, "e5", moves[1].SAN)
+	assert.Equal(t, "Nf3", moves[2].SAN)
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// assetMaxAge is how long browsers may use files other than pages without
+// checking back; their ETags make the check cheap after that
+const assetMaxAge = time.Hour
+
+// asset is a file ready to serve, with its compressed forms
+type asset struct {
+	name        string
+	contentType string
+	// hash identifies the contents, for ETags
+	hash string
+	body []byte
+	gzip []byte
+	// brotli comes from a file compressed ahead of time; see BrotliName
+	brotli []byte
+}
+
+// Assets serves the files in fsys with ETags from their contents and
+// Cache-Control headers. Pages and the service worker are checked on every
+// load so changes show up at once; other files are cached for an hour.
+//
+// Text files are gzipped up front for browsers that accept it. Brotli, which
+// the standard library can't write, is served from files compressed at
+// build time and named by BrotliName; ones left over from earlier contents
+// are ignored.
+func Assets(fsys fs.FS) (http.Handler, error) {
+	assets := make(map[string]*asset)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(name, ".br") {
+			return err
+		}
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		a := &asset{
+			name:        name,
+			contentType: mime.TypeByExtension(path.Ext(name)),
+			hash:        contentHash(body),
+			body:        body,
+		}
+		if a.contentType == "" {
+			a.contentType = http.DetectContentType(body)
+		}
+		if compressible(a.contentType) {
+			if a.gzip, err = gzipBytes(body); err != nil {
+				return err
+			}
+		}
+		if brotli, err := fs.ReadFile(fsys, BrotliName(name, body)); err == nil {
+			a.brotli = brotli
+		}
+		assets[name] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &assetHandler{assets: assets}, nil
+}
+
+// BrotliName is the name a file's brotli-compressed copy has alongside it:
+// its name, the start of its contents' SHA-256 and .br
+func BrotliName(name string, body []byte) string {
+	return name + "." + contentHash(body) + ".br"
+}
+
+func contentHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// compressible reports whether files of a content type shrink when compressed
+func compressible(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.Contains(contentType, "javascript") ||
+		strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "svg") ||
+		strings.Contains(contentType, "icon")
+}
+
+func gzipBytes(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type assetHandler struct {
+	assets map[string]*asset
+}
+
+func (h *assetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+	a, ok := h.assets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", a.contentType)
+	if strings.HasSuffix(name, ".html") || name == "sw.js" {
+		header.Set("Cache-Control", "no-cache")
+	} else {
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(assetMaxAge/time.Second)))
+	}
+
+	// Each encoding is a different representation, so has its own ETag
+	body, etag := a.body, a.hash
+	if a.gzip != nil || a.brotli != nil {
+		header.Add("Vary", "Accept-Encoding")
+		switch accepted := r.Header.Get("Accept-Encoding"); {
+		case a.brotli != nil && acceptsEncoding(accepted, "br"):
+			header.Set("Content-Encoding", "br")
+			body, etag = a.brotli, a.hash+"-br"
+		case a.gzip != nil && len(a.gzip) < len(a.body) && acceptsEncoding(accepted, "gzip"):
+			header.Set("Content-Encoding", "gzip")
+			body, etag = a.gzip, a.hash+"-gz"
+		}
+	}
+	header.Set("ETag", `"`+etag+`"`)
+	// ServeContent answers If-None-Match from the ETag
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(body))
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows encoding
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		if strings.TrimSpace(fields[0]) != encoding {
+			continue
+		}
+		for _, param := range fields[1:] {
+			// q=0 means the encoding is refused
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
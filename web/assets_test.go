@@ -0,0 +1,129 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var page = []byte("<html><body>" + strings.Repeat("chess ", 200) + "</body></html>")
+
+func serve(t *testing.T, handler http.Handler, path string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAssets_CacheHeaders(t *testing.T) {
+	handler, err := Assets(fstest.MapFS{
+		"index.html": {Data: page},
+		"logo.jpg":   {Data: []byte{0xff, 0xd8, 0xff}},
+		"sw.js":      {Data: []byte("self.addEventListener('push', () => {});")},
+	})
+	if err != nil {
+		t.Fatalf("Assets failed: %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"/":         "no-cache",
+		"/sw.js":    "no-cache",
+		"/logo.jpg": "public, max-age=3600",
+	} {
+		rec := serve(t, handler, path, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d", path, rec.Code)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != want {
+			t.Errorf("GET %s: expected Cache-Control %q, got %q", path, want, got)
+		}
+	}
+
+	// The ETag comes from the contents, and answers revalidation
+	rec := serve(t, handler, "/logo.jpg", nil)
+	etag := rec.Header().Get("ETag")
+	if etag != `"`+contentHash([]byte{0xff, 0xd8, 0xff})+`"` {
+		t.Errorf("Expected a content hash ETag, got %q", etag)
+	}
+	if rec := serve(t, handler, "/logo.jpg", map[string]string{"If-None-Match": etag}); rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a current ETag, got %d", rec.Code)
+	}
+	if rec := serve(t, handler, "/logo.jpg", map[string]string{"If-None-Match": `"stale"`}); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a stale ETag, got %d", rec.Code)
+	}
+
+	if rec := serve(t, handler, "/missing.js", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing file, got %d", rec.Code)
+	}
+}
+
+func TestAssets_Gzip(t *testing.T) {
+	handler, err := Assets(fstest.MapFS{"index.html": {Data: page}})
+	if err != nil {
+		t.Fatalf("Assets failed: %v", err)
+	}
+
+	rec := serve(t, handler, "/", map[string]string{"Accept-Encoding": "gzip, deflate"})
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", rec.Header().Get("Vary"))
+	}
+	if rec.Header().Get("ETag") == `"`+contentHash(page)+`"` {
+		t.Error("Expected the gzipped copy to have its own ETag")
+	}
+	reader, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Invalid gzip: %v", err)
+	}
+	body, _ := io.ReadAll(reader)
+	if !bytes.Equal(body, page) {
+		t.Error("Gzipped body doesn't match the page")
+	}
+
+	for _, accept := range []string{"", "gzip;q=0", "br"} {
+		rec := serve(t, handler, "/", map[string]string{"Accept-Encoding": accept})
+		if rec.Header().Get("Content-Encoding") != "" || !bytes.Equal(rec.Body.Bytes(), page) {
+			t.Errorf("Accept-Encoding %q: expected the page uncompressed", accept)
+		}
+	}
+}
+
+func TestAssets_PrecompressedBrotli(t *testing.T) {
+	brotli := []byte("pretend brotli")
+	handler, err := Assets(fstest.MapFS{
+		"index.html":                         {Data: page},
+		BrotliName("index.html", page):       {Data: brotli},
+		"spectator.html":                     {Data: page[1:]},
+		"spectator.html.0123456789abcdef.br": {Data: []byte("left over from an old version")},
+	})
+	if err != nil {
+		t.Fatalf("Assets failed: %v", err)
+	}
+
+	rec := serve(t, handler, "/", map[string]string{"Accept-Encoding": "gzip, br"})
+	if rec.Header().Get("Content-Encoding") != "br" || !bytes.Equal(rec.Body.Bytes(), brotli) {
+		t.Errorf("Expected the brotli copy, got %q", rec.Header().Get("Content-Encoding"))
+	}
+
+	// A copy of different contents is ignored
+	rec = serve(t, handler, "/spectator.html", map[string]string{"Accept-Encoding": "br, gzip"})
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected a stale brotli copy to be ignored, got %q", rec.Header().Get("Content-Encoding"))
+	}
+
+	// Compressed copies aren't served as files of their own
+	if rec := serve(t, handler, "/"+BrotliName("index.html", page), nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a .br file, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,24 @@
+// Service worker showing ATChess push notifications of moves and challenges
+self.addEventListener('push', (event) => {
+    const data = event.data ? event.data.json() : {};
+    event.waitUntil(self.registration.showNotification(data.title || 'ATChess', {
+        body: data.body || '',
+        icon: '/logo.jpg',
+        // Replace an earlier notification for the same game rather than stack them
+        tag: data.gameId || data.type,
+        data: { url: data.url || '/' }
+    }));
+});
+
+self.addEventListener('notificationclick', (event) => {
+    event.notification.close();
+    const url = new URL(event.notification.data.url, self.location.origin).href;
+    event.waitUntil(clients.matchAll({ type: 'window' }).then((windows) => {
+        for (const win of windows) {
+            if (win.url === url && 'focus' in win) {
+                return win.focus();
+            }
+        }
+        return clients.openWindow(url);
+    }));
+});
@@ -0,0 +1,41 @@
+// Package web holds the web interface's static files, built into the
+// binaries that serve them so they don't depend on the working directory.
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var files embed.FS
+
+// Static returns the web interface's files, rooted at web/static
+func Static() fs.FS {
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		// The directory is embedded above, so this can't happen
+		panic(err)
+	}
+	return static
+}
+
+// Handler serves the web interface from dir, or from the built-in files when
+// dir is empty. Serving from a directory lets the interface be edited
+// without rebuilding, so its files aren't cached or compressed.
+func Handler(dir string) http.Handler {
+	if dir != "" {
+		files := http.FileServer(http.Dir(dir))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "no-cache")
+			files.ServeHTTP(w, r)
+		})
+	}
+	assets, err := Assets(Static())
+	if err != nil {
+		// The files are embedded above, so reading them can't fail
+		panic(err)
+	}
+	return assets
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandler_ServesBuiltInFiles(t *testing.T) {
+	// Run from elsewhere to show nothing is read from the working directory
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	handler := Handler("")
+	for _, path := range []string{"/", "/spectator.html", "/sw.js"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s: expected 200, got %d", path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), "<html") {
+		t.Errorf("Expected index.html at /, got %q", rec.Body.String()[:min(len(rec.Body.String()), 100)])
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/web.go", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected only static files to be served, got %d for /web.go", rec.Code)
+	}
+}
+
+func TestHandler_ServesDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>edited</html>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	Handler(dir).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "edited") {
+		t.Errorf("Expected the directory's index.html, got %d %q", rec.Code, rec.Body.String())
+	}
+}